@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	apispec "github.com/femi-lawal/new_bank/backend/analytics-service/api"
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/consumer"
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/handler"
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/analytics-service/migrations"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+const serviceName = "analytics-service"
+
+func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema
+	command, args, err := db.ParseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Initialize Logger
+	logger.InitLogger(serviceName, true)
+	slog.Info("Starting Analytics Service")
+
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+
+	// Initialize Tracing
+	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
+	if err != nil {
+		slog.Warn("Failed to initialize tracing", "error", err)
+	} else {
+		defer func() { _ = tp.Shutdown(context.Background()) }()
+	}
+
+	// Connect to Database. The read model can live in its own database, away
+	// from the ledger's; it only ever holds what the events carry.
+	dbConfig := db.Config{
+		Host:     getEnv("DB_HOST", "localhost"),
+		Port:     getEnv("DB_PORT", "5433"),
+		User:     getEnv("DB_USER", "user"),
+		Password: getEnv("DB_PASSWORD", "password"),
+		DBName:   getEnv("DB_NAME", "newbank_core"),
+		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+	}
+
+	database, err := db.Connect(dbConfig)
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		panic(err)
+	}
+
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
+	}
+
+	// Wiring
+	svc := service.NewAnalyticsService(repository.NewAnalyticsRepository(database))
+	h := handler.NewAnalyticsHandler(svc)
+
+	// Ledger events feed the aggregates the API reads
+	kafkaBrokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
+	// Creates the topics this service uses when they are missing, see topics.go;
+	// the outcome, including settings that drifted, is reported in /health
+	topicSettings, err := kafka.TopicSettingsFromEnv()
+	if err != nil {
+		slog.Error("Invalid Kafka topic settings", "error", err)
+		os.Exit(1)
+	}
+	topicAdmin := kafka.NewTopicAdmin(kafkaBrokers)
+	go topicAdmin.Start(context.Background(), kafka.TopicSpecs(topicSettings, serviceTopics...))
+	eventConsumer := consumer.NewEventConsumer(kafkaBrokers, svc)
+	go eventConsumer.Start(context.Background())
+	lagExporter := kafka.NewLagExporter(kafkaBrokers, consumer.ConsumerGroup, consumer.Topics, kafka.DefaultLagInterval)
+	go lagExporter.Start(context.Background())
+
+	// Setup Router
+	r := gin.Default()
+
+	// ============================================
+	// Global Middleware
+	// ============================================
+	r.Use(apperrors.ErrorMiddleware())
+	r.Use(middleware.RequestLogger(serviceName))
+	r.Use(middleware.Tracing(serviceName))
+	r.Use(middleware.CORS())
+	r.Use(middleware.RateLimitWithPolicies(cfg.RateLimitPolicies()))
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
+	r.Use(metrics.PrometheusMiddleware(serviceName))
+
+	// Readiness needs the database with its migrations applied; without
+	// Kafka the service only degrades
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	healthChecks.RegisterOptional("kafka", health.Kafka(kafkaBrokers))
+	healthChecks.Info("kafka_topics", func() interface{} { return topicAdmin.Report() })
+	registerRoutes(r, h, cfg.JWTAuth(), healthChecks)
+
+	port := getEnv("PORT", "8087")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
+
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes.
+func registerRoutes(r *gin.Engine, h *handler.AnalyticsHandler, jwtAuth middleware.JWTAuthConfig, healthChecks *health.Handler) {
+	// ============================================
+	// Public endpoints
+	// ============================================
+	r.GET("/metrics", metrics.MetricsHandler())
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
+
+	// ============================================
+	// Protected endpoints
+	// ============================================
+	// Queries read precomputed aggregates, so they are held to a short deadline
+	api := r.Group("/api/v1/analytics")
+	api.Use(middleware.JWTAuthWithConfig(jwtAuth), middleware.Timeout(2*time.Second))
+	{
+		api.GET("/monthly", h.MonthlySummary)
+		api.GET("/monthly/:month", h.MonthBreakdown)
+		api.GET("/merchants", h.TopMerchants)
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
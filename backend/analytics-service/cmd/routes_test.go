@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	apispec "github.com/femi-lawal/new_bank/backend/analytics-service/api"
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/handler"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when a route is added or removed without
+// updating api/openapi.yaml
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, handler.NewAnalyticsHandler(nil), middleware.DefaultJWTConfig("test-secret"), health.New(serviceName))
+
+	spec, err := openapi.Load(apispec.Spec)
+	require.NoError(t, err)
+	require.NoError(t, openapi.CheckRoutes(spec, r.Routes(), "/metrics", metrics.SLOStatusPath))
+	require.NoError(t, metrics.CheckSLORoutes(serviceSLOs, r.Routes()))
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the analytics service, reported at
+// /slo-status. Every query reads precomputed aggregates, so all are held to the
+// same tight threshold.
+var serviceSLOs = []metrics.SLO{
+	{Name: "monthly-summary", Method: http.MethodGet, Route: "/api/v1/analytics/monthly", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "month-breakdown", Method: http.MethodGet, Route: "/api/v1/analytics/monthly/:month", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "top-merchants", Method: http.MethodGet, Route: "/api/v1/analytics/merchants", Threshold: 100 * time.Millisecond, Objective: 0.99},
+}
@@ -0,0 +1,58 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+)
+
+// ConsumerGroup is the Kafka consumer group the analytics read model uses
+const ConsumerGroup = "analytics-service"
+
+// Topics are the events projected into the read model
+var Topics = []string{
+	kafka.TopicTransactionCategorized,
+}
+
+// EventConsumer feeds ledger events into the analytics read model
+type EventConsumer struct {
+	consumer *kafka.Consumer
+	svc      *service.AnalyticsService
+}
+
+// NewEventConsumer creates a consumer in the analytics consumer group
+func NewEventConsumer(brokers []string, svc *service.AnalyticsService) *EventConsumer {
+	return &EventConsumer{consumer: kafka.NewConsumer(brokers, ConsumerGroup, kafka.TopicTransactionCategorized), svc: svc}
+}
+
+// Start consumes events until the context is cancelled
+func (c *EventConsumer) Start(ctx context.Context) {
+	slog.Info("Starting analytics event consumer", "topic", kafka.TopicTransactionCategorized)
+	if err := c.consumer.Consume(ctx, func(key string, value []byte) error {
+		return Handle(c.svc, kafka.TopicTransactionCategorized, value)
+	}); err != nil && ctx.Err() == nil {
+		slog.Error("Kafka consumer error", "topic", kafka.TopicTransactionCategorized, "error", err)
+	}
+}
+
+// Handle projects one event from the given topic
+func Handle(svc *service.AnalyticsService, topic string, value []byte) error {
+	switch topic {
+	case kafka.TopicTransactionCategorized:
+		var event kafka.TransactionCategorizedEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		return svc.ApplyTransactionCategorized(event)
+	}
+	slog.Warn("Ignoring event from unexpected topic", "topic", topic)
+	return nil
+}
+
+// Close closes the consumer
+func (c *EventConsumer) Close() error {
+	return c.consumer.Close()
+}
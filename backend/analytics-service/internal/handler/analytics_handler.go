@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	Service *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(s *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{Service: s}
+}
+
+// MonthlySummary returns the user's money in and out for each month of a range
+func (h *AnalyticsHandler) MonthlySummary(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	months, err := h.Service.MonthlySummary(userID, c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"months": months})
+}
+
+// MonthBreakdown returns the user's totals for one month, per category
+func (h *AnalyticsHandler) MonthBreakdown(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	breakdown, err := h.Service.MonthBreakdown(userID, c.Param("month"))
+	if err != nil {
+		respondAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, breakdown)
+}
+
+// TopMerchants returns the merchants the user spent most with over a range of months
+func (h *AnalyticsHandler) TopMerchants(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit == 0 {
+			respondAnalyticsError(c, service.ErrInvalidLimit)
+			return
+		}
+	}
+	merchants, err := h.Service.TopMerchants(userID, c.Query("from"), c.Query("to"), limit)
+	if err != nil {
+		respondAnalyticsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"merchants": merchants})
+}
+
+// respondAnalyticsError maps analytics service errors to HTTP statuses
+func respondAnalyticsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidUser):
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+	case errors.Is(err, service.ErrInvalidMonth), errors.Is(err, service.ErrInvalidRange), errors.Is(err, service.ErrInvalidLimit):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Posting is the analytics copy of a ledger posting, built from
+// transaction.categorized events. It is kept so that redelivered events are
+// counted once and a recategorization moves the posting between aggregates.
+type Posting struct {
+	PostingID    uuid.UUID       `gorm:"type:uuid;primary_key"`
+	UserID       uuid.UUID       `gorm:"type:uuid;not null;index"`
+	AccountID    uuid.UUID       `gorm:"type:uuid;not null"`
+	Month        time.Time       `gorm:"type:date;not null"` // first day of the posting's month, UTC
+	Amount       decimal.Decimal `gorm:"type:numeric(19,4);not null"`
+	Direction    int             `gorm:"not null"` // 1 = money in, -1 = money out
+	CurrencyCode string          `gorm:"type:char(3);not null"`
+	Category     string          `gorm:"type:varchar(20)"`
+	Merchant     string          `gorm:"type:varchar(255)"`
+	OccurredAt   time.Time       `gorm:"not null"`
+	UpdatedAt    time.Time
+}
+
+func (Posting) TableName() string {
+	return "analytics_postings"
+}
+
+// MonthlyAggregate totals a user's postings in one month, currency and category
+type MonthlyAggregate struct {
+	UserID       uuid.UUID       `gorm:"type:uuid;primary_key" json:"-"`
+	Month        time.Time       `gorm:"type:date;primary_key" json:"-"`
+	CurrencyCode string          `gorm:"type:char(3);primary_key" json:"currency"`
+	Category     string          `gorm:"type:varchar(20);primary_key" json:"category"`
+	MoneyIn      decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"money_in"`
+	MoneyOut     decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"money_out"`
+	CountIn      int64           `gorm:"not null" json:"count_in"`
+	CountOut     int64           `gorm:"not null" json:"count_out"`
+	UpdatedAt    time.Time       `json:"-"`
+}
+
+func (MonthlyAggregate) TableName() string {
+	return "analytics_monthly_aggregates"
+}
+
+// MerchantSummary totals a user's postings with one merchant in one month and
+// currency. Refunded is money received back from the merchant.
+type MerchantSummary struct {
+	UserID       uuid.UUID       `gorm:"type:uuid;primary_key"`
+	Month        time.Time       `gorm:"type:date;primary_key"`
+	CurrencyCode string          `gorm:"type:char(3);primary_key"`
+	Merchant     string          `gorm:"type:varchar(255);primary_key"`
+	Spent        decimal.Decimal `gorm:"type:numeric(19,4);not null"`
+	Refunded     decimal.Decimal `gorm:"type:numeric(19,4);not null"`
+	Count        int64           `gorm:"not null"`
+	LastSeenAt   time.Time       `gorm:"not null"`
+	UpdatedAt    time.Time
+}
+
+func (MerchantSummary) TableName() string {
+	return "analytics_merchant_summaries"
+}
+
+// MerchantTotal is a merchant's summaries added up over a range of months
+type MerchantTotal struct {
+	Merchant     string          `json:"merchant"`
+	CurrencyCode string          `json:"currency"`
+	Spent        decimal.Decimal `json:"spent"`
+	Refunded     decimal.Decimal `json:"refunded"`
+	Count        int64           `json:"count"`
+	LastSeenAt   time.Time       `json:"last_seen_at"`
+}
+
+// Projection is what storing one posting changes. Posting is the row to save,
+// nil to leave the stored posting as it is. The aggregate amounts and counts
+// are added to the stored ones and may be negative.
+type Projection struct {
+	Posting   *Posting
+	Monthly   []MonthlyAggregate
+	Merchants []MerchantSummary
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnalyticsRepository stores projected postings and the aggregates built from them
+type AnalyticsRepository struct {
+	DB *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{DB: db}
+}
+
+// ApplyPosting passes project the stored copy of the posting, or nil if it is
+// new, and saves the projection it returns in the same transaction. The stored
+// posting is locked meanwhile, so concurrent deliveries of one posting are
+// applied one after the other.
+func (r *AnalyticsRepository) ApplyPosting(postingID uuid.UUID, project func(previous *model.Posting) model.Projection) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var previous *model.Posting
+		var stored model.Posting
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("posting_id = ?", postingID).First(&stored).Error
+		switch {
+		case err == nil:
+			previous = &stored
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		projection := project(previous)
+		if projection.Posting != nil {
+			if err := tx.Save(projection.Posting).Error; err != nil {
+				return err
+			}
+		}
+		if len(projection.Monthly) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_id"}, {Name: "month"}, {Name: "currency_code"}, {Name: "category"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"money_in":   gorm.Expr("analytics_monthly_aggregates.money_in + excluded.money_in"),
+					"money_out":  gorm.Expr("analytics_monthly_aggregates.money_out + excluded.money_out"),
+					"count_in":   gorm.Expr("analytics_monthly_aggregates.count_in + excluded.count_in"),
+					"count_out":  gorm.Expr("analytics_monthly_aggregates.count_out + excluded.count_out"),
+					"updated_at": gorm.Expr("excluded.updated_at"),
+				}),
+			}).Create(&projection.Monthly).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(projection.Merchants) > 0 {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_id"}, {Name: "month"}, {Name: "currency_code"}, {Name: "merchant"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"spent":        gorm.Expr("analytics_merchant_summaries.spent + excluded.spent"),
+					"refunded":     gorm.Expr("analytics_merchant_summaries.refunded + excluded.refunded"),
+					"count":        gorm.Expr("analytics_merchant_summaries.count + excluded.count"),
+					"last_seen_at": gorm.Expr("GREATEST(analytics_merchant_summaries.last_seen_at, excluded.last_seen_at)"),
+					"updated_at":   gorm.Expr("excluded.updated_at"),
+				}),
+			}).Create(&projection.Merchants).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListMonthlyAggregates returns the user's aggregates for the months in [from, to], oldest first
+func (r *AnalyticsRepository) ListMonthlyAggregates(userID uuid.UUID, from, to time.Time) ([]model.MonthlyAggregate, error) {
+	var aggregates []model.MonthlyAggregate
+	err := r.DB.Where("user_id = ? AND month >= ? AND month <= ?", userID, from, to).
+		Order("month, currency_code, category").Find(&aggregates).Error
+	return aggregates, err
+}
+
+// TopMerchants adds up the user's merchant summaries for the months in
+// [from, to] and returns the merchants spent with most
+func (r *AnalyticsRepository) TopMerchants(userID uuid.UUID, from, to time.Time, limit int) ([]model.MerchantTotal, error) {
+	var totals []model.MerchantTotal
+	err := r.DB.Model(&model.MerchantSummary{}).
+		Select("merchant, currency_code, SUM(spent) AS spent, SUM(refunded) AS refunded, SUM(count) AS count, MAX(last_seen_at) AS last_seen_at").
+		Where("user_id = ? AND month >= ? AND month <= ? AND count > 0", userID, from, to).
+		Group("merchant, currency_code").
+		Order("SUM(spent) DESC, merchant").
+		Limit(limit).
+		Scan(&totals).Error
+	return totals, err
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// MaxMonths caps the range of months one query can cover
+	MaxMonths = 24
+	// DefaultMonths is the range queried when none is given, ending this month
+	DefaultMonths = 12
+	// DefaultMerchantLimit and MaxMerchantLimit bound how many merchants are returned
+	DefaultMerchantLimit = 10
+	MaxMerchantLimit     = 100
+
+	monthLayout = "2006-01"
+)
+
+var (
+	ErrInvalidEvent = errors.New("invalid event")
+	ErrInvalidMonth = errors.New("months must be in YYYY-MM format")
+	ErrInvalidRange = errors.New("from must not be after to, and the range can cover at most 24 months")
+	ErrInvalidLimit = errors.New("limit must be between 1 and 100")
+	ErrInvalidUser  = errors.New("invalid user id")
+)
+
+// AnalyticsRepository stores projected postings and queries the aggregates built from them
+type AnalyticsRepository interface {
+	// ApplyPosting passes project the stored copy of the posting, or nil if it
+	// is new, and saves the projection it returns atomically
+	ApplyPosting(postingID uuid.UUID, project func(previous *model.Posting) model.Projection) error
+	ListMonthlyAggregates(userID uuid.UUID, from, to time.Time) ([]model.MonthlyAggregate, error)
+	TopMerchants(userID uuid.UUID, from, to time.Time, limit int) ([]model.MerchantTotal, error)
+}
+
+// AnalyticsService is the query side of the ledger: it projects ledger events
+// into per-user monthly aggregates and merchant summaries and answers
+// analytics queries from them, so those reads never reach the ledger database.
+type AnalyticsService struct {
+	repo AnalyticsRepository
+	now  func() time.Time
+}
+
+func NewAnalyticsService(repo AnalyticsRepository) *AnalyticsService {
+	return &AnalyticsService{repo: repo, now: time.Now}
+}
+
+// CurrencyTotals is what went in and out of a user's accounts in one currency
+type CurrencyTotals struct {
+	CurrencyCode string          `json:"currency"`
+	MoneyIn      decimal.Decimal `json:"money_in"`
+	MoneyOut     decimal.Decimal `json:"money_out"`
+	Net          decimal.Decimal `json:"net"`
+	Count        int64           `json:"count"`
+}
+
+// MonthSummary is a user's totals for one month
+type MonthSummary struct {
+	Month  string           `json:"month"`
+	Totals []CurrencyTotals `json:"totals"`
+}
+
+// MonthBreakdown is a user's totals for one month, and per category
+type MonthBreakdown struct {
+	Month      string                   `json:"month"`
+	Totals     []CurrencyTotals         `json:"totals"`
+	Categories []model.MonthlyAggregate `json:"categories"`
+}
+
+// ApplyTransactionCategorized projects a posting from a transaction.categorized
+// event. A redelivered event changes nothing; a recategorization moves the
+// posting to its new category and merchant in the month it was first seen.
+func (s *AnalyticsService) ApplyTransactionCategorized(event kafka.TransactionCategorizedEvent) error {
+	postingID, err := parseEventID("posting_id", event.PostingID)
+	if err != nil {
+		return err
+	}
+	accountID, err := parseEventID("account_id", event.AccountID)
+	if err != nil {
+		return err
+	}
+	userID, err := parseEventID("user_id", event.UserID)
+	if err != nil {
+		return err
+	}
+	amount, err := decimal.NewFromString(event.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: amount %q", ErrInvalidEvent, event.Amount)
+	}
+	if event.Direction != 1 && event.Direction != -1 {
+		return fmt.Errorf("%w: direction %d", ErrInvalidEvent, event.Direction)
+	}
+
+	occurredAt := s.eventTime(event.Timestamp)
+	next := &model.Posting{
+		PostingID:    postingID,
+		UserID:       userID,
+		AccountID:    accountID,
+		Month:        monthStart(occurredAt),
+		Amount:       amount,
+		Direction:    event.Direction,
+		CurrencyCode: event.Currency,
+		Category:     event.Category,
+		Merchant:     event.Merchant,
+		OccurredAt:   occurredAt,
+		UpdatedAt:    s.now().UTC(),
+	}
+	return s.repo.ApplyPosting(postingID, func(previous *model.Posting) model.Projection {
+		return project(previous, next)
+	})
+}
+
+// project works out what storing next changes, given the stored copy of the
+// posting if there is one
+func project(previous, next *model.Posting) model.Projection {
+	if previous == nil {
+		p := model.Projection{Posting: next}
+		addPosting(&p, next, 1)
+		return p
+	}
+	if previous.Category == next.Category && previous.Merchant == next.Merchant {
+		return model.Projection{}
+	}
+
+	// Postings do not change; only their category and merchant are updated
+	updated := *previous
+	updated.Category = next.Category
+	updated.Merchant = next.Merchant
+	updated.UpdatedAt = next.UpdatedAt
+	p := model.Projection{Posting: &updated}
+	addPosting(&p, previous, -1)
+	addPosting(&p, &updated, 1)
+	return p
+}
+
+// addPosting adds a posting to the projection's aggregates, or takes it away
+// when sign is -1
+func addPosting(p *model.Projection, posting *model.Posting, sign int64) {
+	amount := posting.Amount.Mul(decimal.NewFromInt(sign))
+	monthly := model.MonthlyAggregate{
+		UserID:       posting.UserID,
+		Month:        posting.Month,
+		CurrencyCode: posting.CurrencyCode,
+		Category:     posting.Category,
+		UpdatedAt:    posting.UpdatedAt,
+	}
+	if posting.Direction > 0 {
+		monthly.MoneyIn, monthly.CountIn = amount, sign
+	} else {
+		monthly.MoneyOut, monthly.CountOut = amount, sign
+	}
+	p.Monthly = append(p.Monthly, monthly)
+
+	if posting.Merchant == "" {
+		return
+	}
+	merchant := model.MerchantSummary{
+		UserID:       posting.UserID,
+		Month:        posting.Month,
+		CurrencyCode: posting.CurrencyCode,
+		Merchant:     posting.Merchant,
+		Count:        sign,
+		LastSeenAt:   posting.OccurredAt,
+		UpdatedAt:    posting.UpdatedAt,
+	}
+	if posting.Direction > 0 {
+		merchant.Refunded = amount
+	} else {
+		merchant.Spent = amount
+	}
+	p.Merchants = append(p.Merchants, merchant)
+}
+
+// MonthlySummary returns the user's totals for each month from one month to
+// another, both YYYY-MM and inclusive. Months with no postings are included.
+// Without a range it covers the last DefaultMonths months.
+func (s *AnalyticsService) MonthlySummary(userID, from, to string) ([]MonthSummary, error) {
+	user, err := parseUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := s.monthRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	aggregates, err := s.repo.ListMonthlyAggregates(user, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	byMonth := map[string][]model.MonthlyAggregate{}
+	for _, a := range aggregates {
+		key := a.Month.Format(monthLayout)
+		byMonth[key] = append(byMonth[key], a)
+	}
+	var months []MonthSummary
+	for m := start; !m.After(end); m = m.AddDate(0, 1, 0) {
+		key := m.Format(monthLayout)
+		months = append(months, MonthSummary{Month: key, Totals: currencyTotals(byMonth[key])})
+	}
+	return months, nil
+}
+
+// MonthBreakdown returns the user's totals for one YYYY-MM month and per category
+func (s *AnalyticsService) MonthBreakdown(userID, month string) (*MonthBreakdown, error) {
+	user, err := parseUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	start, err := time.Parse(monthLayout, month)
+	if err != nil {
+		return nil, ErrInvalidMonth
+	}
+	aggregates, err := s.repo.ListMonthlyAggregates(user, start, start)
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]model.MonthlyAggregate, 0, len(aggregates))
+	for _, a := range aggregates {
+		if a.CountIn != 0 || a.CountOut != 0 {
+			categories = append(categories, a)
+		}
+	}
+	return &MonthBreakdown{Month: month, Totals: currencyTotals(categories), Categories: categories}, nil
+}
+
+// TopMerchants returns the merchants the user spent most with over a range of
+// YYYY-MM months, as for MonthlySummary
+func (s *AnalyticsService) TopMerchants(userID, from, to string, limit int) ([]model.MerchantTotal, error) {
+	user, err := parseUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = DefaultMerchantLimit
+	}
+	if limit < 1 || limit > MaxMerchantLimit {
+		return nil, ErrInvalidLimit
+	}
+	start, end, err := s.monthRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.repo.TopMerchants(user, start, end, limit)
+	if err != nil {
+		return nil, err
+	}
+	if totals == nil {
+		totals = []model.MerchantTotal{}
+	}
+	return totals, nil
+}
+
+// monthRange parses an inclusive range of YYYY-MM months. A missing end is
+// this month, and a missing start is DefaultMonths months before the end.
+func (s *AnalyticsService) monthRange(from, to string) (time.Time, time.Time, error) {
+	end := monthStart(s.now())
+	if to != "" {
+		var err error
+		if end, err = time.Parse(monthLayout, to); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidMonth
+		}
+	}
+	start := end.AddDate(0, 1-DefaultMonths, 0)
+	if from != "" {
+		var err error
+		if start, err = time.Parse(monthLayout, from); err != nil {
+			return time.Time{}, time.Time{}, ErrInvalidMonth
+		}
+	}
+	if start.After(end) || !start.AddDate(0, MaxMonths, 0).After(end) {
+		return time.Time{}, time.Time{}, ErrInvalidRange
+	}
+	return start, end, nil
+}
+
+// currencyTotals adds up aggregates per currency, in currency order
+func currencyTotals(aggregates []model.MonthlyAggregate) []CurrencyTotals {
+	byCurrency := map[string]*CurrencyTotals{}
+	for _, a := range aggregates {
+		t, ok := byCurrency[a.CurrencyCode]
+		if !ok {
+			t = &CurrencyTotals{CurrencyCode: a.CurrencyCode, MoneyIn: decimal.Zero, MoneyOut: decimal.Zero}
+			byCurrency[a.CurrencyCode] = t
+		}
+		t.MoneyIn = t.MoneyIn.Add(a.MoneyIn)
+		t.MoneyOut = t.MoneyOut.Add(a.MoneyOut)
+		t.Count += a.CountIn + a.CountOut
+	}
+	totals := make([]CurrencyTotals, 0, len(byCurrency))
+	for _, t := range byCurrency {
+		t.Net = t.MoneyIn.Sub(t.MoneyOut)
+		totals = append(totals, *t)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].CurrencyCode < totals[j].CurrencyCode })
+	return totals
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func parseUserID(userID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidUser
+	}
+	return id, nil
+}
+
+func parseEventID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %s %q", ErrInvalidEvent, field, value)
+	}
+	return id, nil
+}
+
+// eventTime parses an event timestamp, falling back to the time it was received
+func (s *AnalyticsService) eventTime(timestamp string) time.Time {
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return t.UTC()
+	}
+	return s.now().UTC()
+}
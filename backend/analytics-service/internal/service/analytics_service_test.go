@@ -0,0 +1,251 @@
+package service
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type monthlyKey struct {
+	user     uuid.UUID
+	month    time.Time
+	currency string
+	category string
+}
+
+type merchantKey struct {
+	user     uuid.UUID
+	month    time.Time
+	currency string
+	merchant string
+}
+
+// memoryAnalyticsRepository is an in-memory AnalyticsRepository that adds
+// projections up the way the SQL upserts do
+type memoryAnalyticsRepository struct {
+	postings  map[uuid.UUID]model.Posting
+	monthly   map[monthlyKey]model.MonthlyAggregate
+	merchants map[merchantKey]model.MerchantSummary
+}
+
+func newMemoryAnalyticsRepository() *memoryAnalyticsRepository {
+	return &memoryAnalyticsRepository{
+		postings:  map[uuid.UUID]model.Posting{},
+		monthly:   map[monthlyKey]model.MonthlyAggregate{},
+		merchants: map[merchantKey]model.MerchantSummary{},
+	}
+}
+
+func (r *memoryAnalyticsRepository) ApplyPosting(postingID uuid.UUID, project func(previous *model.Posting) model.Projection) error {
+	var previous *model.Posting
+	if stored, ok := r.postings[postingID]; ok {
+		previous = &stored
+	}
+	p := project(previous)
+	if p.Posting != nil {
+		r.postings[postingID] = *p.Posting
+	}
+	for _, a := range p.Monthly {
+		key := monthlyKey{a.UserID, a.Month, a.CurrencyCode, a.Category}
+		if stored, ok := r.monthly[key]; ok {
+			a.MoneyIn = a.MoneyIn.Add(stored.MoneyIn)
+			a.MoneyOut = a.MoneyOut.Add(stored.MoneyOut)
+			a.CountIn += stored.CountIn
+			a.CountOut += stored.CountOut
+		}
+		r.monthly[key] = a
+	}
+	for _, m := range p.Merchants {
+		key := merchantKey{m.UserID, m.Month, m.CurrencyCode, m.Merchant}
+		if stored, ok := r.merchants[key]; ok {
+			m.Spent = m.Spent.Add(stored.Spent)
+			m.Refunded = m.Refunded.Add(stored.Refunded)
+			m.Count += stored.Count
+			if stored.LastSeenAt.After(m.LastSeenAt) {
+				m.LastSeenAt = stored.LastSeenAt
+			}
+		}
+		r.merchants[key] = m
+	}
+	return nil
+}
+
+func (r *memoryAnalyticsRepository) ListMonthlyAggregates(userID uuid.UUID, from, to time.Time) ([]model.MonthlyAggregate, error) {
+	var out []model.MonthlyAggregate
+	for key, a := range r.monthly {
+		if key.user == userID && !key.month.Before(from) && !key.month.After(to) {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Month.Equal(out[j].Month) {
+			return out[i].Month.Before(out[j].Month)
+		}
+		return out[i].Category < out[j].Category
+	})
+	return out, nil
+}
+
+func (r *memoryAnalyticsRepository) TopMerchants(userID uuid.UUID, from, to time.Time, limit int) ([]model.MerchantTotal, error) {
+	totals := map[string]*model.MerchantTotal{}
+	for key, m := range r.merchants {
+		if key.user != userID || key.month.Before(from) || key.month.After(to) || m.Count <= 0 {
+			continue
+		}
+		t, ok := totals[key.merchant+key.currency]
+		if !ok {
+			t = &model.MerchantTotal{Merchant: key.merchant, CurrencyCode: key.currency}
+			totals[key.merchant+key.currency] = t
+		}
+		t.Spent = t.Spent.Add(m.Spent)
+		t.Refunded = t.Refunded.Add(m.Refunded)
+		t.Count += m.Count
+		if m.LastSeenAt.After(t.LastSeenAt) {
+			t.LastSeenAt = m.LastSeenAt
+		}
+	}
+	var out []model.MerchantTotal
+	for _, t := range totals {
+		out = append(out, *t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Spent.GreaterThan(out[j].Spent) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func newAnalyticsService() (*AnalyticsService, *memoryAnalyticsRepository) {
+	repo := newMemoryAnalyticsRepository()
+	svc := NewAnalyticsService(repo)
+	svc.now = func() time.Time { return time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC) }
+	return svc, repo
+}
+
+func categorized(userID uuid.UUID, amount string, direction int, category, merchant, timestamp string) kafka.TransactionCategorizedEvent {
+	return kafka.TransactionCategorizedEvent{
+		TransactionID: uuid.NewString(),
+		PostingID:     uuid.NewString(),
+		AccountID:     uuid.NewString(),
+		UserID:        userID.String(),
+		Category:      category,
+		Merchant:      merchant,
+		Amount:        amount,
+		Currency:      "GBP",
+		Direction:     direction,
+		Timestamp:     timestamp,
+	}
+}
+
+func TestApplyTransactionCategorized_RedeliveryCountedOnce(t *testing.T) {
+	svc, _ := newAnalyticsService()
+	user := uuid.New()
+	event := categorized(user, "42.50", -1, "GROCERIES", "Tesco", "2026-03-02T09:00:00Z")
+
+	require.NoError(t, svc.ApplyTransactionCategorized(event))
+	require.NoError(t, svc.ApplyTransactionCategorized(event))
+
+	breakdown, err := svc.MonthBreakdown(user.String(), "2026-03")
+	require.NoError(t, err)
+	require.Len(t, breakdown.Totals, 1)
+	assert.True(t, decimal.RequireFromString("42.50").Equal(breakdown.Totals[0].MoneyOut))
+	assert.Equal(t, int64(1), breakdown.Totals[0].Count)
+}
+
+func TestApplyTransactionCategorized_RecategorizationMovesTotals(t *testing.T) {
+	svc, _ := newAnalyticsService()
+	user := uuid.New()
+	event := categorized(user, "30", -1, "OTHER", "Corner Shop", "2026-02-27T18:00:00Z")
+	require.NoError(t, svc.ApplyTransactionCategorized(event))
+
+	// The user recategorizes it in March; the posting stays in February
+	event.Category = "GROCERIES"
+	event.Merchant = "Tesco"
+	event.Timestamp = "2026-03-10T08:00:00Z"
+	require.NoError(t, svc.ApplyTransactionCategorized(event))
+
+	february, err := svc.MonthBreakdown(user.String(), "2026-02")
+	require.NoError(t, err)
+	require.Len(t, february.Categories, 1)
+	assert.Equal(t, "GROCERIES", february.Categories[0].Category)
+	assert.Equal(t, int64(1), february.Categories[0].CountOut)
+
+	march, err := svc.MonthBreakdown(user.String(), "2026-03")
+	require.NoError(t, err)
+	assert.Empty(t, march.Categories)
+
+	merchants, err := svc.TopMerchants(user.String(), "2026-01", "2026-03", 0)
+	require.NoError(t, err)
+	require.Len(t, merchants, 1)
+	assert.Equal(t, "Tesco", merchants[0].Merchant)
+	assert.True(t, decimal.NewFromInt(30).Equal(merchants[0].Spent))
+}
+
+func TestMonthlySummary_FillsEmptyMonthsPerCurrency(t *testing.T) {
+	svc, _ := newAnalyticsService()
+	user := uuid.New()
+	require.NoError(t, svc.ApplyTransactionCategorized(categorized(user, "2500", 1, "INCOME", "", "2026-01-28T09:00:00Z")))
+	require.NoError(t, svc.ApplyTransactionCategorized(categorized(user, "120", -1, "BILLS", "Energy Co", "2026-01-30T09:00:00Z")))
+	euros := categorized(user, "15", -1, "TRAVEL", "Metro", "2026-03-01T09:00:00Z")
+	euros.Currency = "EUR"
+	require.NoError(t, svc.ApplyTransactionCategorized(euros))
+	// Another user's postings are not counted
+	require.NoError(t, svc.ApplyTransactionCategorized(categorized(uuid.New(), "99", -1, "BILLS", "", "2026-01-05T09:00:00Z")))
+
+	months, err := svc.MonthlySummary(user.String(), "2026-01", "2026-03")
+	require.NoError(t, err)
+	require.Len(t, months, 3)
+
+	assert.Equal(t, "2026-01", months[0].Month)
+	require.Len(t, months[0].Totals, 1)
+	assert.True(t, decimal.NewFromInt(2380).Equal(months[0].Totals[0].Net))
+	assert.Equal(t, int64(2), months[0].Totals[0].Count)
+
+	assert.Empty(t, months[1].Totals)
+
+	require.Len(t, months[2].Totals, 1)
+	assert.Equal(t, "EUR", months[2].Totals[0].CurrencyCode)
+}
+
+func TestMonthlySummary_RangeValidation(t *testing.T) {
+	svc, _ := newAnalyticsService()
+	user := uuid.New().String()
+
+	// Defaults to the last twelve months, ending this month
+	months, err := svc.MonthlySummary(user, "", "")
+	require.NoError(t, err)
+	require.Len(t, months, DefaultMonths)
+	assert.Equal(t, "2025-04", months[0].Month)
+	assert.Equal(t, "2026-03", months[len(months)-1].Month)
+
+	_, err = svc.MonthlySummary(user, "2026-13", "")
+	assert.ErrorIs(t, err, ErrInvalidMonth)
+	_, err = svc.MonthlySummary(user, "2026-03", "2026-01")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = svc.MonthlySummary(user, "2024-03", "2026-03")
+	assert.ErrorIs(t, err, ErrInvalidRange)
+	_, err = svc.MonthlySummary(user, "2024-04", "2026-03")
+	assert.NoError(t, err)
+
+	_, err = svc.TopMerchants(user, "", "", MaxMerchantLimit+1)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	_, err = svc.MonthlySummary("not-a-user", "", "")
+	assert.ErrorIs(t, err, ErrInvalidUser)
+}
+
+func TestApplyTransactionCategorized_RejectsInvalidEvents(t *testing.T) {
+	svc, repo := newAnalyticsService()
+	event := categorized(uuid.New(), "ten", -1, "OTHER", "", "")
+	assert.ErrorIs(t, svc.ApplyTransactionCategorized(event), ErrInvalidEvent)
+
+	event = categorized(uuid.New(), "10", 0, "OTHER", "", "")
+	assert.ErrorIs(t, svc.ApplyTransactionCategorized(event), ErrInvalidEvent)
+	assert.Empty(t, repo.postings)
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS analytics_merchant_summaries;
+DROP TABLE IF EXISTS analytics_monthly_aggregates;
+DROP TABLE IF EXISTS analytics_postings;
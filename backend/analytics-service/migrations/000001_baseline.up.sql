@@ -0,0 +1,43 @@
+-- Read model for analytics queries, projected from transaction.categorized
+-- events so that reporting reads never reach the ledger database.
+
+CREATE TABLE analytics_postings (
+    posting_id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    month date NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    direction bigint NOT NULL,
+    currency_code char(3) NOT NULL,
+    category varchar(20),
+    merchant varchar(255),
+    occurred_at timestamptz NOT NULL,
+    updated_at timestamptz
+);
+CREATE INDEX idx_analytics_postings_user_id ON analytics_postings (user_id);
+
+CREATE TABLE analytics_monthly_aggregates (
+    user_id uuid NOT NULL,
+    month date NOT NULL,
+    currency_code char(3) NOT NULL,
+    category varchar(20) NOT NULL,
+    money_in numeric(19,4) NOT NULL DEFAULT 0,
+    money_out numeric(19,4) NOT NULL DEFAULT 0,
+    count_in bigint NOT NULL DEFAULT 0,
+    count_out bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz,
+    PRIMARY KEY (user_id, month, currency_code, category)
+);
+
+CREATE TABLE analytics_merchant_summaries (
+    user_id uuid NOT NULL,
+    month date NOT NULL,
+    currency_code char(3) NOT NULL,
+    merchant varchar(255) NOT NULL,
+    spent numeric(19,4) NOT NULL DEFAULT 0,
+    refunded numeric(19,4) NOT NULL DEFAULT 0,
+    count bigint NOT NULL DEFAULT 0,
+    last_seen_at timestamptz NOT NULL,
+    updated_at timestamptz,
+    PRIMARY KEY (user_id, month, currency_code, merchant)
+);
@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/analytics-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsCoverModels(t *testing.T) {
+	migrations, err := db.LoadMigrations(FS)
+	require.NoError(t, err)
+	require.NoError(t, db.VerifyModels(migrations, &model.Posting{}, &model.MonthlyAggregate{}, &model.MerchantSummary{}))
+}
@@ -2,29 +2,77 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"time"
 
+	apispec "github.com/femi-lawal/new_bank/backend/card-service/api"
 	"github.com/femi-lawal/new_bank/backend/card-service/internal/handler"
 	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/card-service/internal/repository"
 	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/card-service/migrations"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
 	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/maintenance"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/serviceauth"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 const serviceName = "card-service"
 
+// The fulfillment partner signs its webhooks with this key ID
+const (
+	fulfillmentKeyID       = "card-fulfillment"
+	fulfillmentWebhookPath = "/webhooks/card-fulfillment"
+)
+
 func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema
+	command, args, err := db.ParseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Initialize Logger
 	logger.InitLogger(serviceName, true)
 	slog.Info("Starting Card Service")
 
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	cfg.Card = &config.CardConfig{EncryptionKey: os.Getenv("CARD_ENCRYPTION_KEY")}
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+	apperrors.Problems().RegisterAll(serviceProblemTypes)
+
 	// Initialize Tracing
 	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
 	if err != nil {
@@ -49,18 +97,155 @@ func main() {
 		panic(err)
 	}
 
-	// Auto Migrate
-	if err := database.AutoMigrate(&model.Card{}); err != nil {
-		slog.Error("Failed to migrate database", "error", err)
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
 	}
 
 	// Wiring
 	repo := repository.NewCardRepository(database)
+	if key := cfg.Card.EncryptionKey; key != "" {
+		if err := service.SetEncryptionKey([]byte(key)); err != nil {
+			slog.Error("Invalid card encryption key", "error", err)
+			panic(err)
+		}
+	}
 	svc := service.NewCardService(repo)
 	h := handler.NewCardHandler(svc)
 
-	// Get JWT secret
-	jwtSecret := requireEnv("JWT_SECRET")
+	// Background work runs from the jobs table, so each scheduled run happens
+	// on one replica and failures are retried and visible in the admin API
+	jobStore := jobs.NewPostgresStore(database)
+	jobRunner := jobs.NewRunner(jobStore, serviceName, jobs.DefaultConfig())
+
+	// Card lifecycle events are written to an outbox with the card changes and relayed to Kafka
+	kafkaBrokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
+	producer := kafka.NewProducer(kafkaBrokers)
+	// Creates the topics this service uses when they are missing, see topics.go;
+	// the outcome, including settings that drifted, is reported in /health
+	topicSettings, err := kafka.TopicSettingsFromEnv()
+	if err != nil {
+		slog.Error("Invalid Kafka topic settings", "error", err)
+		os.Exit(1)
+	}
+	topicAdmin := kafka.NewTopicAdmin(kafkaBrokers)
+	go topicAdmin.Start(context.Background(), kafka.TopicSpecs(topicSettings, serviceTopics...))
+	if producer != nil {
+		slog.Info("Kafka producer initialized")
+		jobRunner.Schedule("card.outbox_relay", jobs.Every(5*time.Second), svc.OutboxRelayJob(producer))
+	}
+	// Cards are renewed 60 days before they expire and the old card is
+	// deactivated at its expiry; renewal notifications go out through the outbox
+	svc.SetRenewals(repo)
+	jobRunner.Schedule("card.renewals", jobs.Every(time.Hour), svc.RenewalJob)
+
+	// The ledger is called through a service account: disputes post provisional
+	// credits with the ledger:write scope, authorizations read balances with ledger:read
+	var ledger *service.LedgerClient
+	if clientID := getEnv("SERVICE_CLIENT_ID", ""); clientID != "" {
+		creds := serviceauth.NewClientCredentials(getEnv("IDENTITY_SERVICE_URL", "http://localhost:8081")+"/auth/token",
+			clientID, requireEnv("SERVICE_CLIENT_SECRET"), "ledger:write", "ledger:read")
+		creds.Audience = []string{"ledger-service"}
+		ledger = service.NewLedgerClient(getEnv("LEDGER_SERVICE_URL", "http://localhost:8082"), creds.Client(10*time.Second))
+	}
+
+	// Disputes post provisional credits from the chargeback suspense account
+	if suspense := getEnv("DISPUTE_SUSPENSE_ACCOUNT_ID", ""); suspense != "" && ledger != nil {
+		suspenseID, err := uuid.Parse(suspense)
+		if err != nil {
+			slog.Error("Invalid DISPUTE_SUSPENSE_ACCOUNT_ID", "error", err)
+			panic(err)
+		}
+		svc.SetDisputes(repo, ledger, suspenseID)
+	} else {
+		slog.Warn("DISPUTE_SUSPENSE_ACCOUNT_ID or SERVICE_CLIENT_ID not set; card disputes are disabled")
+	}
+
+	// Authorizations are checked against the account's available balance. When the
+	// ledger does not answer in time, payments up to CARD_STAND_IN_LIMIT are approved
+	// in stand-in against the last balance seen and verified once it is back.
+	if ledger != nil {
+		svc.SetStandIn(ledger, repo, standInConfigFromEnv())
+		jobRunner.Schedule("card.stand_in_verification", jobs.Every(time.Minute), svc.StandInVerificationJob)
+	} else {
+		slog.Warn("SERVICE_CLIENT_ID not set; authorizations are not checked against the ledger balance")
+	}
+
+	// Foreign transactions are those outside the home country; cards decline them
+	// unless geo-blocking is off or a travel notice covers the merchant's country
+	svc.SetTravelNotices(repo, getEnv("CARD_HOME_COUNTRY", "US"))
+	svc.SetCardControls(repo)
+
+	// Administrators reveal card numbers for operational cases, e.g. a reissue
+	// through the processor, up to CARD_PAN_ACCESS_DAILY_CAP each per day
+	svc.SetPANAccess(repo, panAccessDailyCapFromEnv())
+
+	// Physical cards are tracked through the fulfillment partner's signed
+	// webhook until the cardholder activates them. Where there is no partner,
+	// CARD_FULFILLMENT_SIMULATOR_STEP has the service play its part.
+	fulfillmentSecrets := map[string][]byte{}
+	if secret := getEnv("CARD_FULFILLMENT_WEBHOOK_SECRET", ""); secret != "" {
+		fulfillmentSecrets[fulfillmentKeyID] = []byte(secret)
+		svc.SetCardOrders(repo)
+		if step := fulfillmentSimulatorStepFromEnv(); step > 0 {
+			webhookURL := getEnv("CARD_FULFILLMENT_WEBHOOK_URL", "http://localhost:"+getEnv("PORT", "8085")+fulfillmentWebhookPath)
+			simulator := service.NewFulfillmentSimulator(webhookURL, fulfillmentKeyID, []byte(secret), step)
+			jobRunner.Schedule("card.fulfillment_simulator", jobs.Every(time.Minute), svc.FulfillmentSimulatorJob(simulator))
+			slog.Warn("Card fulfillment is simulated", "step", step)
+		}
+	} else {
+		slog.Warn("CARD_FULFILLMENT_WEBHOOK_SECRET not set; physical card orders are disabled")
+	}
+
+	go jobRunner.Run(context.Background())
+
+	// Settled transactions feed the spending insights, which are cached in Redis
+	// when it is available and otherwise computed on every request
+	var insightsCache service.InsightsCache
+	redisClient, err := cache.NewRedisClient(cache.Config{
+		Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
+		Password: getEnv("REDIS_PASSWORD", ""),
+	})
+	if err != nil {
+		slog.Warn("Redis connection failed, spending insights are not cached", "error", err)
+	} else {
+		insightsCache = service.NewRedisInsightsCache(redisClient)
+	}
+	svc.SetTransactionFeed(repo, insightsCache)
+
+	// Wrong PINs lock a card's PIN verification out for longer each time;
+	// the lockouts are shared by the replicas through Redis when it is available
+	var pinAttempts middleware.AttemptStore = middleware.NewInMemoryAttemptStore()
+	if redisClient != nil {
+		pinAttempts = middleware.NewRedisAttemptStore(redisClient, "attempts:card-service:")
+	}
+	svc.SetPINAttempts(middleware.NewAttemptLimiter("card_pin", pinAttempts, middleware.PINAttemptPolicy()))
+
+	// Maintenance mode: MAINTENANCE_MODE forces it for this deployment, and admins
+	// switch the global and per-service scopes at runtime, shared through Redis
+	var maintenanceStore maintenance.Store = maintenance.NewMemoryStore()
+	if redisClient != nil {
+		maintenanceStore = maintenance.NewRedisStore(redisClient)
+	}
+	forcedMaintenance, err := maintenance.ParseMode(getEnv("MAINTENANCE_MODE", "off"))
+	if err != nil {
+		slog.Error("Invalid MAINTENANCE_MODE", "error", err)
+		panic(err)
+	}
 
 	// Setup Router
 	r := gin.Default()
@@ -72,34 +257,123 @@ func main() {
 	r.Use(middleware.RequestLogger(serviceName))
 	r.Use(middleware.Tracing(serviceName))
 	r.Use(middleware.CORS())
-	r.Use(middleware.RateLimit())
+	r.Use(middleware.RateLimitWithPolicies(cfg.RateLimitPolicies()))
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
 	r.Use(metrics.PrometheusMiddleware(serviceName))
+	// Rejects non-essential requests with 503 while the service is in maintenance
+	r.Use(maintenance.Guard(maintenance.Config{Service: serviceName, Store: maintenanceStore, Forced: forcedMaintenance}))
+
+	// Readiness needs the database with its migrations applied; without
+	// Redis or Kafka the service only degrades
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	if redisClient != nil {
+		healthChecks.RegisterOptional("redis", health.Redis(redisClient))
+	}
+	healthChecks.RegisterOptional("kafka", health.Kafka(kafkaBrokers))
+	healthChecks.Info("kafka_topics", func() interface{} { return topicAdmin.Report() })
+	registerRoutes(r, h, jobs.NewAdminHandler(jobStore, serviceName), maintenance.NewAdminHandler(maintenanceStore, serviceName), cfg.JWTAuth(), fulfillmentSecrets, healthChecks)
+
+	port := getEnv("PORT", "8085")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
 
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes.
+func registerRoutes(r *gin.Engine, h *handler.CardHandler, jobAdmin *jobs.AdminHandler, maintenanceAdmin *maintenance.AdminHandler, jwtAuth middleware.JWTAuthConfig, fulfillmentSecrets map[string][]byte, healthChecks *health.Handler) {
 	// ============================================
 	// Public endpoints
 	// ============================================
 	r.GET("/metrics", metrics.MetricsHandler())
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": serviceName,
-		})
-	})
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
 
 	// ============================================
 	// Protected endpoints (all card operations require auth)
 	// ============================================
 	api := r.Group("/api/v1")
-	api.Use(middleware.JWTAuth(jwtSecret))
+	api.Use(middleware.JWTAuthWithConfig(jwtAuth))
+	// Users who have not accepted the current mandatory terms can only read
+	api.Use(middleware.RequireTermsAccepted())
+	// Fields tagged redact on the card model are hidden from support and third-party callers
+	api.Use(middleware.RedactResponses(model.Card{}))
 	{
 		api.GET("/cards", h.ListCards)
 		api.POST("/cards", h.IssueCard)
+		// Delegate cards: requested by a secondary user, approved and limited by the account owner
+		api.POST("/cards/delegate", h.RequestDelegateCard)
+		api.GET("/accounts/:id/delegate-cards", h.ListDelegateCards)
+		api.POST("/accounts/:id/delegate-cards/:cardId/approve", h.ApproveDelegateCard)
+		api.POST("/accounts/:id/delegate-cards/:cardId/decline", h.DeclineDelegateCard)
+		api.PUT("/accounts/:id/delegate-cards/:cardId/limit", h.SetDelegateLimit)
+		api.POST("/accounts/:id/delegate-cards/:cardId/revoke", h.RevokeDelegateCard)
+		// Physical cards: ordered INACTIVE, activated once shipped
+		api.POST("/cards/orders", h.OrderCard)
+		api.GET("/cards/:id/order", h.GetCardOrder)
+		api.POST("/cards/:id/activate", h.ActivateCard)
+		api.POST("/cards/:id/replace", h.ReplaceCard)
+		api.POST("/cards/:id/pin", middleware.RequireStepUp(middleware.DefaultStepUpMaxAge), h.SetPIN)
+		api.POST("/cards/:id/pin/unblock", middleware.RequireStepUp(middleware.DefaultStepUpMaxAge), h.UnblockPIN)
+		api.POST("/cards/:id/tokens", h.IssueToken)
+		api.GET("/cards/:id/tokens", h.ListTokens)
+		api.DELETE("/cards/:id/tokens/:tokenId", h.RevokeToken)
+		api.GET("/cards/:id/controls", h.GetCardControls)
+		api.PUT("/cards/:id/controls", h.UpdateCardControls)
+		api.GET("/cards/:id/controls/history", h.ListCardControlChanges)
+		api.POST("/cards/:id/travel-notices", h.CreateTravelNotice)
+		api.GET("/cards/:id/travel-notices", h.ListTravelNotices)
+		api.DELETE("/cards/:id/travel-notices/:noticeId", h.CancelTravelNotice)
+		api.GET("/cards/:id/transactions", h.ListCardTransactions)
+		api.GET("/cards/:id/insights", h.GetSpendingInsights)
+		api.GET("/cards/:id/subscriptions", h.ListSubscriptions)
+		api.POST("/cards/:id/subscriptions/:subscriptionId/block", h.BlockSubscription)
+		api.DELETE("/cards/:id/subscriptions/:subscriptionId/block", h.UnblockSubscription)
+		api.POST("/disputes", h.OpenDispute)
+		api.GET("/disputes", h.ListDisputes)
+		api.GET("/disputes/:id", h.GetDispute)
 	}
 
-	port := getEnv("PORT", "8085")
-	slog.Info("Server listening", "port", port)
-	if err := r.Run(":" + port); err != nil {
-		slog.Error("Failed to start server", "error", err)
+	// ============================================
+	// Admin endpoints (dispute and stand-in review)
+	// ============================================
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.JWTAuthWithConfig(jwtAuth.Admin()), middleware.RequireRole("admin"))
+	{
+		admin.GET("/disputes", h.AdminListDisputes)
+		admin.POST("/disputes/:id/review", h.ReviewDispute)
+		admin.POST("/disputes/:id/resolve", h.ResolveDispute)
+		// Payments approved in stand-in while the ledger was unreachable
+		admin.GET("/stand-in-authorizations", h.ListStandInAuthorizations)
+		admin.POST("/stand-in-authorizations/:id/review", h.ReviewStandInAuthorization)
+		// Full card numbers, for operational cases only: a recent step-up, a
+		// reason, and a daily cap per administrator, with every access recorded
+		admin.POST("/cards/:id/pan", middleware.RequireStepUp(middleware.DefaultStepUpMaxAge), h.RevealPAN)
+		admin.GET("/cards/:id/pan-accesses", h.ListPANAccesses)
+	}
+	jobAdmin.RegisterRoutes(admin)
+	maintenanceAdmin.RegisterRoutes(admin)
+
+	// ============================================
+	// Partner webhooks (authenticated by request signature)
+	// ============================================
+	r.POST(fulfillmentWebhookPath, middleware.VerifySignature(middleware.DefaultSignatureConfig(fulfillmentSecrets)), h.FulfillmentWebhook)
+
+	// ============================================
+	// Internal endpoints (service-to-service only)
+	// ============================================
+	internal := r.Group("/internal/v1")
+	internal.Use(middleware.JWTAuthWithConfig(jwtAuth), middleware.RequireRole("service"))
+	{
+		internal.POST("/authorizations/token", h.AuthorizeToken)
+		internal.POST("/cards/:id/pin/verify", h.VerifyPIN)
+		internal.POST("/card-transactions/settlements", h.RecordSettlement)
 	}
 }
 
@@ -110,6 +384,51 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// fulfillmentSimulatorStepFromEnv reads how long the simulated fulfillment
+// partner waits between the steps of an order; zero leaves it off
+func fulfillmentSimulatorStepFromEnv() time.Duration {
+	value := getEnv("CARD_FULFILLMENT_SIMULATOR_STEP", "")
+	if value == "" {
+		return 0
+	}
+	step, err := time.ParseDuration(value)
+	if err != nil || step < 0 {
+		panic("Invalid CARD_FULFILLMENT_SIMULATOR_STEP: " + value)
+	}
+	return step
+}
+
+// standInConfigFromEnv reads the largest payment approved in stand-in from
+// CARD_STAND_IN_LIMIT (default 100, 0 declines them all) and how long the
+// ledger balance check may take from CARD_BALANCE_CHECK_TIMEOUT, e.g. "2s"
+func standInConfigFromEnv() service.StandInConfig {
+	value := getEnv("CARD_STAND_IN_LIMIT", "100")
+	limit, err := decimal.NewFromString(value)
+	if err != nil || limit.IsNegative() {
+		panic("Invalid CARD_STAND_IN_LIMIT: " + value)
+	}
+	cfg := service.StandInConfig{Limit: limit}
+	if value := getEnv("CARD_BALANCE_CHECK_TIMEOUT", ""); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			panic("Invalid CARD_BALANCE_CHECK_TIMEOUT: " + value)
+		}
+		cfg.Timeout = timeout
+	}
+	return cfg
+}
+
+// panAccessDailyCapFromEnv reads how many card numbers one administrator
+// may reveal per day from CARD_PAN_ACCESS_DAILY_CAP; 0 refuses them all
+func panAccessDailyCapFromEnv() int {
+	value := getEnv("CARD_PAN_ACCESS_DAILY_CAP", strconv.Itoa(service.DefaultPANAccessDailyCap))
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		panic("Invalid CARD_PAN_ACCESS_DAILY_CAP: " + value)
+	}
+	return limit
+}
+
 // requireEnv returns the value of an environment variable or panics if not set.
 func requireEnv(key string) string {
 	if value, exists := os.LookupEnv(key); exists && value != "" {
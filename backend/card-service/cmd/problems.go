@@ -0,0 +1,25 @@
+package main
+
+// serviceProblemTypes title the error codes the card service defines, keyed by
+// code. Their problem types are namespaced under the service; the standard
+// codes are registered by apperrors.
+var serviceProblemTypes = map[string]string{
+	"ACTIVATION_DETAILS_MISMATCH": "Card activation details do not match",
+	"CARD_ACTIVATION_LOCKED":      "Card activation is locked after too many attempts",
+	"CARD_ALREADY_REPLACED":       "Card was already replaced",
+	"CARD_CONTROLS_DISABLED":      "Card controls are not configured",
+	"CARD_ORDERS_DISABLED":        "Card orders are not configured",
+	"CARD_ORDER_STATE":            "Card order is in the wrong state",
+	"CARD_PENDING_APPROVAL":       "Card is pending approval",
+	"CARD_PIN_BLOCKED":            "Card PIN is blocked",
+	"DELEGATE_CARD_STATE":         "Delegate card is in the wrong state",
+	"DISPUTES_DISABLED":           "Disputes are not configured",
+	"DISPUTE_EXISTS":              "Transaction is already disputed",
+	"INCORRECT_PIN":               "Incorrect PIN",
+	"INVALID_CARD_STATE":          "Card is in the wrong state",
+	"INVALID_DISPUTE_STATE":       "Dispute is in the wrong state",
+	"STAND_IN_DISABLED":           "Stand-in processing is not configured",
+	"STAND_IN_STATE":              "Stand-in authorization is in the wrong state",
+	"TRANSACTION_FEED_DISABLED":   "Card transaction feed is not configured",
+	"TRAVEL_NOTICES_DISABLED":     "Travel notices are not configured",
+}
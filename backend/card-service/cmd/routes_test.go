@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apispec "github.com/femi-lawal/new_bank/backend/card-service/api"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/handler"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/maintenance"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when a route is added or removed without
+// updating api/openapi.yaml
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, handler.NewCardHandler(nil), jobs.NewAdminHandler(nil, serviceName), maintenance.NewAdminHandler(nil, serviceName), middleware.DefaultJWTConfig("test-secret"), nil, health.New(serviceName))
+
+	spec, err := openapi.Load(apispec.Spec)
+	require.NoError(t, err)
+	require.NoError(t, openapi.CheckRoutes(spec, r.Routes(), "/metrics", metrics.SLOStatusPath))
+	require.NoError(t, metrics.CheckSLORoutes(serviceSLOs, r.Routes()))
+}
+
+// TestRevealPAN_AcceptsSteppedUpAdminTokens checks the PAN route against
+// tokens shaped like the identity service's: only an admin token issued from
+// a recent step-up gets through to the handler
+func TestRevealPAN_AcceptsSteppedUpAdminTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	jwtAuth := middleware.DefaultJWTConfig("test-secret")
+	jwtAuth.Issuer = "neobank"
+	jwtAuth.Audiences = []string{serviceName}
+	registerRoutes(r, handler.NewCardHandler(nil), jobs.NewAdminHandler(nil, serviceName), maintenance.NewAdminHandler(nil, serviceName), jwtAuth, nil, health.New(serviceName))
+
+	token := func(audience string, authTime *time.Time) string {
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss":     "neobank",
+			"aud":     []string{audience},
+			"user_id": "admin-1",
+			"role":    "admin",
+			"iat":     now.Unix(),
+			"exp":     now.Add(15 * time.Minute).Unix(),
+		}
+		if authTime != nil {
+			claims["auth_time"] = authTime.Unix()
+			claims["amr"] = []string{"pwd"}
+		}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		require.NoError(t, err)
+		return signed
+	}
+	reveal := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/cards/card-1/pan", strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	steppedUp := time.Now().Add(-time.Minute)
+	stale := time.Now().Add(-middleware.DefaultStepUpMaxAge - time.Minute)
+
+	// The handler rejects the empty body, so the token was accepted
+	assert.Equal(t, http.StatusBadRequest, reveal(token(middleware.AdminAudience(serviceName), &steppedUp)).Code)
+
+	for name, tok := range map[string]string{
+		"admin token without a step-up":   token(middleware.AdminAudience(serviceName), nil),
+		"admin token from an old step-up": token(middleware.AdminAudience(serviceName), &stale),
+	} {
+		w := reveal(tok)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+		assert.Contains(t, w.Body.String(), "STEP_UP_REQUIRED", name)
+	}
+
+	// A user step-up token is not for the admin routes
+	w := reveal(token(serviceName, &steppedUp))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "STEP_UP_REQUIRED")
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the card service, reported at
+// /slo-status. Token authorizations sit on the card network's timeout budget.
+var serviceSLOs = []metrics.SLO{
+	{Name: "authorize-token", Method: http.MethodPost, Route: "/internal/v1/authorizations/token", Threshold: 100 * time.Millisecond, Objective: 0.999},
+	{Name: "list-cards", Method: http.MethodGet, Route: "/api/v1/cards", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "verify-pin", Method: http.MethodPost, Route: "/internal/v1/cards/:id/pin/verify", Threshold: 250 * time.Millisecond, Objective: 0.999},
+	{Name: "insights", Method: http.MethodGet, Route: "/api/v1/cards/:id/insights", Threshold: 500 * time.Millisecond, Objective: 0.99},
+}
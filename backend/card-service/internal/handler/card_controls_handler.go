@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateCardControlsRequest struct {
+	GeoBlocking *bool `json:"geo_blocking"`
+	// MerchantAllowlist and MerchantDenylist replace the card's lists when
+	// present; an empty array clears a list
+	MerchantAllowlist *[]service.MerchantRuleInput `json:"merchant_allowlist"`
+	MerchantDenylist  *[]service.MerchantRuleInput `json:"merchant_denylist"`
+}
+
+// GetCardControls returns the card's geo-blocking control and merchant lists
+func (h *CardHandler) GetCardControls(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	controls, err := h.Service.GetCardControls(userID, c.Param("id"))
+	if err != nil {
+		respondCardControlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, controls)
+}
+
+// UpdateCardControls changes the card's controls. Turning geo-blocking off
+// allows foreign transactions without a travel notice; a merchant allowlist
+// limits the card to the merchants on it, and a denylist blocks merchants.
+func (h *CardHandler) UpdateCardControls(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req UpdateCardControlsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	if req.GeoBlocking == nil && req.MerchantAllowlist == nil && req.MerchantDenylist == nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("geo_blocking, merchant_allowlist or merchant_denylist is required"))
+		return
+	}
+
+	controls, err := h.Service.UpdateCardControls(userID, c.Param("id"), service.CardControlsUpdate{
+		GeoBlocking:       req.GeoBlocking,
+		MerchantAllowlist: req.MerchantAllowlist,
+		MerchantDenylist:  req.MerchantDenylist,
+	})
+	if err != nil {
+		respondCardControlError(c, err)
+		return
+	}
+
+	metadata := map[string]interface{}{"card_id": controls.ID.String()}
+	if req.GeoBlocking != nil {
+		metadata["geo_blocking"] = controls.GeoBlocking
+	}
+	if req.MerchantAllowlist != nil {
+		metadata["merchant_allowlist"] = len(controls.MerchantAllowlist)
+	}
+	if req.MerchantDenylist != nil {
+		metadata["merchant_denylist"] = len(controls.MerchantDenylist)
+	}
+	h.Audit.LogEvent(middleware.AuditEventCardControlsUpdate, middleware.AuditSeverityInfo, c, metadata)
+	c.JSON(http.StatusOK, controls)
+}
+
+// ListCardControlChanges returns the history of changes to the card's controls, latest first
+func (h *CardHandler) ListCardControlChanges(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	changes, err := h.Service.ListCardControlChanges(userID, c.Param("id"))
+	if err != nil {
+		respondCardControlError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, changes)
+}
+
+// respondCardControlError maps card control errors to API errors
+func respondCardControlError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCardControlsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("CARD_CONTROLS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidMerchantRule), errors.Is(err, service.ErrInvalidNamePattern),
+		errors.Is(err, service.ErrTooManyMerchantRules):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		respondTravelNoticeError(c, err)
+	}
+}
@@ -11,10 +11,14 @@ import (
 
 type CardHandler struct {
 	Service *service.CardService
+	Audit   *middleware.AuditLogger
 }
 
 func NewCardHandler(s *service.CardService) *CardHandler {
-	return &CardHandler{Service: s}
+	return &CardHandler{
+		Service: s,
+		Audit:   middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: "card-service"}),
+	}
 }
 
 type IssueCardRequest struct {
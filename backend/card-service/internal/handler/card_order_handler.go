@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type OrderCardRequest struct {
+	AccountID string `json:"account_id" binding:"required"`
+}
+
+// OrderCard orders a physical card, which is sent out INACTIVE
+func (h *CardHandler) OrderCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req OrderCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	result, err := h.Service.OrderCard(userID, req.AccountID)
+	if errors.Is(err, service.ErrUnauthorized) {
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+		return
+	}
+	if err != nil {
+		respondCardOrderError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardIssue, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":  result.Card.ID.String(),
+		"order_id": result.Order.ID.String(),
+		"physical": true,
+	})
+	c.JSON(http.StatusCreated, result)
+}
+
+// GetCardOrder returns the fulfillment status of a physical card
+func (h *CardHandler) GetCardOrder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	order, err := h.Service.GetCardOrder(userID, c.Param("id"))
+	if err != nil {
+		respondCardOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+type ActivateCardRequest struct {
+	Last4  string `json:"last4" binding:"required,len=4,numeric"`
+	Expiry string `json:"expiry" binding:"required,len=5"`
+}
+
+// ActivateCard activates a physical card that has shipped once the cardholder
+// enters the last four digits and the MM/YY expiry printed on it
+func (h *CardHandler) ActivateCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req ActivateCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	result, err := h.Service.ActivateCard(userID, c.Param("id"), req.Last4, req.Expiry)
+	if err != nil {
+		if errors.Is(err, service.ErrActivationMismatch) || errors.Is(err, service.ErrActivationLocked) {
+			h.Audit.LogEvent(middleware.AuditEventCardActivate, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"card_id": c.Param("id"),
+				"outcome": "FAILED",
+				"reason":  err.Error(),
+			})
+		}
+		respondCardOrderError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardActivate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":  result.Card.ID.String(),
+		"order_id": result.Order.ID.String(),
+		"outcome":  "ACTIVATED",
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+type FulfillmentWebhookRequest struct {
+	OrderID        string    `json:"order_id" binding:"required"`
+	Status         string    `json:"status" binding:"required"`
+	Carrier        string    `json:"carrier" binding:"max=50"`
+	TrackingNumber string    `json:"tracking_number" binding:"max=100"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// FulfillmentWebhook receives status updates from the card fulfillment
+// partner. Requests are authenticated by their signature, so redeliveries
+// are answered with the order as it stands.
+func (h *CardHandler) FulfillmentWebhook(c *gin.Context) {
+	var req FulfillmentWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	order, err := h.Service.HandleFulfillmentEvent(service.FulfillmentEvent{
+		OrderID:        req.OrderID,
+		Status:         model.CardOrderStatus(req.Status),
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+		OccurredAt:     req.OccurredAt,
+	}, time.Now())
+	if err != nil {
+		respondCardOrderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// respondCardOrderError maps physical card order errors to API errors
+func respondCardOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrCardOrdersDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("CARD_ORDERS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidUserID), errors.Is(err, service.ErrInvalidAccountID),
+		errors.Is(err, service.ErrInvalidFulfillmentStatus):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrActivationMismatch):
+		apperrors.RespondWithError(c, apperrors.NewError("ACTIVATION_DETAILS_MISMATCH", err.Error(), http.StatusUnprocessableEntity))
+	case errors.Is(err, service.ErrActivationLocked):
+		apperrors.RespondWithError(c, apperrors.NewError("CARD_ACTIVATION_LOCKED", err.Error(), http.StatusLocked))
+	case errors.Is(err, service.ErrCardNotShipped), errors.Is(err, service.ErrCardAlreadyActivated),
+		errors.Is(err, service.ErrCardNotActivatable):
+		apperrors.RespondWithError(c, apperrors.NewError("CARD_ORDER_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrCardOrderNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
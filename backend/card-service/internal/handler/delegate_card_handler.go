@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// errCardPendingApproval is returned for delegate cards the account owner has not approved
+var errCardPendingApproval = apperrors.NewError("CARD_PENDING_APPROVAL", service.ErrCardPendingApproval.Error(), http.StatusConflict)
+
+type RequestDelegateCardRequest struct {
+	AccountID  string          `json:"account_id" binding:"required,uuid"`
+	DailyLimit decimal.Decimal `json:"daily_limit" binding:"required"`
+}
+
+type ApproveDelegateCardRequest struct {
+	// DailyLimit, when set, replaces the limit the delegate asked for
+	DailyLimit *decimal.Decimal `json:"daily_limit"`
+}
+
+type SetDelegateLimitRequest struct {
+	DailyLimit decimal.Decimal `json:"daily_limit" binding:"required"`
+}
+
+// RequestDelegateCard asks for a card on another user's account. The card is
+// issued to the caller once the account owner approves it.
+func (h *CardHandler) RequestDelegateCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req RequestDelegateCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	card, err := h.Service.RequestDelegateCard(userID, req.AccountID, req.DailyLimit)
+	if err != nil {
+		respondDelegateCardError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardDelegateRequest, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":     card.ID.String(),
+		"account_id":  card.AccountID.String(),
+		"daily_limit": card.DailyLimit.String(),
+	})
+	c.JSON(http.StatusCreated, card)
+}
+
+// ListDelegateCards returns the delegate cards on one of the caller's accounts
+func (h *CardHandler) ListDelegateCards(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	cards, err := h.Service.ListDelegateCards(userID, c.Param("id"))
+	if err != nil {
+		respondDelegateCardError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": cards})
+}
+
+// ApproveDelegateCard activates a delegate card on the caller's account
+func (h *CardHandler) ApproveDelegateCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req ApproveDelegateCardRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+			return
+		}
+	}
+
+	card, err := h.Service.ApproveDelegateCard(userID, c.Param("id"), c.Param("cardId"), req.DailyLimit)
+	if err != nil {
+		respondDelegateCardError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardDelegateApprove, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":     card.ID.String(),
+		"account_id":  card.AccountID.String(),
+		"holder_id":   card.HolderID.String(),
+		"daily_limit": card.DailyLimit.String(),
+	})
+	c.JSON(http.StatusOK, card)
+}
+
+// DeclineDelegateCard refuses a delegate card waiting for approval
+func (h *CardHandler) DeclineDelegateCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	card, err := h.Service.DeclineDelegateCard(userID, c.Param("id"), c.Param("cardId"))
+	if err != nil {
+		respondDelegateCardError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardDelegateDecline, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":    card.ID.String(),
+		"account_id": card.AccountID.String(),
+		"holder_id":  card.HolderID.String(),
+	})
+	c.JSON(http.StatusOK, card)
+}
+
+// SetDelegateLimit changes the daily limit of a delegate card on the caller's account
+func (h *CardHandler) SetDelegateLimit(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req SetDelegateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	card, err := h.Service.SetDelegateLimit(userID, c.Param("id"), c.Param("cardId"), req.DailyLimit)
+	if err != nil {
+		respondDelegateCardError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardControlsUpdate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":     card.ID.String(),
+		"account_id":  card.AccountID.String(),
+		"daily_limit": card.DailyLimit.String(),
+	})
+	c.JSON(http.StatusOK, card)
+}
+
+// RevokeDelegateCard blocks a delegate card on the caller's account
+func (h *CardHandler) RevokeDelegateCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	card, err := h.Service.RevokeDelegateCard(userID, c.Param("id"), c.Param("cardId"))
+	if err != nil {
+		respondDelegateCardError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardBlock, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"card_id":    card.ID.String(),
+		"account_id": card.AccountID.String(),
+		"holder_id":  card.HolderID.String(),
+		"reason":     "delegate_revoked",
+	})
+	c.JSON(http.StatusOK, card)
+}
+
+// respondDelegateCardError maps delegate card errors to API errors
+func respondDelegateCardError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAccountID), errors.Is(err, service.ErrInvalidDailyLimit):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrUnauthorized):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("account not found"))
+	case errors.Is(err, service.ErrDelegateCardNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrSelfApproval):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrDelegateCardNotPending):
+		apperrors.RespondWithError(c, apperrors.NewError("DELEGATE_CARD_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
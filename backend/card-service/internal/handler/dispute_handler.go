@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type OpenDisputeRequest struct {
+	CardID        string          `json:"card_id" binding:"required"`
+	TransactionID string          `json:"transaction_id" binding:"required"`
+	Amount        decimal.Decimal `json:"amount" binding:"required"`
+	Reason        string          `json:"reason" binding:"required"`
+	Description   string          `json:"description" binding:"max=2000"`
+}
+
+// OpenDispute disputes a card transaction and posts a provisional credit
+func (h *CardHandler) OpenDispute(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req OpenDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	dispute, err := h.Service.OpenDispute(userID, req.CardID, req.TransactionID, req.Amount, model.DisputeReason(req.Reason), req.Description)
+	if err != nil {
+		respondDisputeError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardDisputeOpen, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"dispute_id":     dispute.ID.String(),
+		"card_id":        dispute.CardID.String(),
+		"transaction_id": dispute.TransactionID.String(),
+		"amount":         dispute.Amount.String(),
+		"reason":         string(dispute.Reason),
+	})
+	c.JSON(http.StatusCreated, dispute)
+}
+
+// ListDisputes returns the authenticated user's disputes
+func (h *CardHandler) ListDisputes(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	disputes, err := h.Service.ListDisputes(userID)
+	if err != nil {
+		respondDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, disputes)
+}
+
+// GetDispute returns one of the authenticated user's disputes
+func (h *CardHandler) GetDispute(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	dispute, err := h.Service.GetDispute(userID, c.Param("id"))
+	if err != nil {
+		respondDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+// AdminListDisputes returns the dispute queue, optionally filtered by status
+func (h *CardHandler) AdminListDisputes(c *gin.Context) {
+	disputes, err := h.Service.ListAllDisputes(model.DisputeStatus(c.Query("status")))
+	if err != nil {
+		respondDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, disputes)
+}
+
+// ReviewDispute takes an open dispute under review
+func (h *CardHandler) ReviewDispute(c *gin.Context) {
+	dispute, err := h.Service.ReviewDispute(middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		respondDisputeError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation":  "dispute_review",
+		"dispute_id": dispute.ID.String(),
+	})
+	c.JSON(http.StatusOK, dispute)
+}
+
+type ResolveDisputeRequest struct {
+	Outcome string `json:"outcome" binding:"required"`
+	Note    string `json:"note" binding:"max=2000"`
+}
+
+// ResolveDispute closes a dispute as won or lost
+func (h *CardHandler) ResolveDispute(c *gin.Context) {
+	var req ResolveDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	dispute, err := h.Service.ResolveDispute(middleware.GetUserID(c), c.Param("id"), model.DisputeOutcome(req.Outcome), req.Note)
+	if err != nil {
+		respondDisputeError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardDisputeResolve, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"dispute_id": dispute.ID.String(),
+		"outcome":    string(dispute.Outcome),
+		"amount":     dispute.Amount.String(),
+	})
+	c.JSON(http.StatusOK, dispute)
+}
+
+// respondDisputeError maps dispute errors to API errors
+func respondDisputeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrDisputesDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("DISPUTES_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidDisputeReason), errors.Is(err, service.ErrInvalidDisputeOutcome),
+		errors.Is(err, service.ErrInvalidTransactionID), errors.Is(err, service.ErrInvalidAmount):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrDisputeNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	case errors.Is(err, service.ErrDisputeExists):
+		apperrors.RespondWithError(c, apperrors.NewError("DISPUTE_EXISTS", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrInvalidDisputeState):
+		apperrors.RespondWithError(c, apperrors.NewError("INVALID_DISPUTE_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type SettlementRequest struct {
+	CardID               string    `json:"card_id" binding:"required"`
+	NetworkReference     string    `json:"network_reference" binding:"required"`
+	Amount               string    `json:"amount" binding:"required"`
+	Currency             string    `json:"currency" binding:"required"`
+	MerchantName         string    `json:"merchant_name"`
+	MerchantCategoryCode string    `json:"merchant_category_code" binding:"required"`
+	MerchantCountry      string    `json:"merchant_country"`
+	SettledAt            time.Time `json:"settled_at"`
+}
+
+// RecordSettlement adds a transaction settled by the card network to the card's feed.
+// Internal only; a settlement already recorded is returned with 200.
+func (h *CardHandler) RecordSettlement(c *gin.Context) {
+	var req SettlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("invalid amount"))
+		return
+	}
+
+	txn, created, err := h.Service.RecordSettlement(c.Request.Context(), service.Settlement{
+		CardID:               req.CardID,
+		NetworkReference:     req.NetworkReference,
+		Amount:               amount,
+		Currency:             req.Currency,
+		MerchantName:         req.MerchantName,
+		MerchantCategoryCode: req.MerchantCategoryCode,
+		MerchantCountry:      req.MerchantCountry,
+		SettledAt:            req.SettledAt,
+	})
+	if err != nil {
+		respondInsightsError(c, err)
+		return
+	}
+	if !created {
+		c.JSON(http.StatusOK, txn)
+		return
+	}
+	c.JSON(http.StatusCreated, txn)
+}
+
+// ListCardTransactions returns a card's most recent settled transactions
+func (h *CardHandler) ListCardTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	limit := 50
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("limit must be a positive integer"))
+			return
+		}
+		limit = n
+	}
+
+	txns, err := h.Service.ListCardTransactions(userID, c.Param("id"), limit)
+	if err != nil {
+		respondInsightsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, txns)
+}
+
+// GetSpendingInsights returns a card's spend for a month (default the current
+// one) by merchant category, with the trend against the previous month
+func (h *CardHandler) GetSpendingInsights(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	month := time.Now().UTC()
+	if v := c.Query("month"); v != "" {
+		parsed, err := time.Parse("2006-01", v)
+		if err != nil {
+			respondInsightsError(c, service.ErrInvalidInsightsMonth)
+			return
+		}
+		month = parsed
+	}
+
+	insights, err := h.Service.SpendingInsights(c.Request.Context(), userID, c.Param("id"), month)
+	if err != nil {
+		respondInsightsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, insights)
+}
+
+// respondInsightsError maps transaction feed and spending insights errors to API errors
+func respondInsightsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransactionFeedDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("TRANSACTION_FEED_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidSettlement), errors.Is(err, service.ErrInvalidInsightsMonth),
+		errors.Is(err, service.ErrInvalidMerchantCountry):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
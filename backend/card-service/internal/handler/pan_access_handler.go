@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type RevealPANRequest struct {
+	Reason        model.PANAccessReason `json:"reason" binding:"required"`
+	Justification string                `json:"justification" binding:"required,max=500"`
+	Reference     string                `json:"reference" binding:"max=100"`
+}
+
+// RevealPAN returns a card's full number to an administrator who gave a
+// reason for needing it. Every access is recorded and audited, including
+// those refused for the daily cap.
+func (h *CardHandler) RevealPAN(c *gin.Context) {
+	var req RevealPANRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	cardID := c.Param("id")
+	disclosure, err := h.Service.RevealPAN(middleware.GetUserID(c), cardID, service.PANAccessRequest{
+		Reason:        req.Reason,
+		Justification: req.Justification,
+		Reference:     req.Reference,
+		IPAddress:     c.ClientIP(),
+	}, time.Now())
+	if err != nil {
+		if errors.Is(err, service.ErrPANAccessCapReached) {
+			h.Audit.LogEvent(middleware.AuditEventSuspiciousActivity, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"reason":      "pan_access_cap_reached",
+				"card_id":     cardID,
+				"access_code": string(req.Reason),
+			})
+		}
+		respondPANAccessError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventDataView, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":   "card_pan_reveal",
+		"card_id":     disclosure.CardID.String(),
+		"access_id":   disclosure.AccessID.String(),
+		"access_code": string(req.Reason),
+		"reference":   req.Reference,
+	})
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, disclosure)
+}
+
+// ListPANAccesses returns who revealed a card's number and why, newest first
+func (h *CardHandler) ListPANAccesses(c *gin.Context) {
+	accesses, err := h.Service.ListPANAccesses(c.Param("id"))
+	if err != nil {
+		respondPANAccessError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, accesses)
+}
+
+// respondPANAccessError maps card number access errors to API errors
+func respondPANAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPANAccessDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("PAN_ACCESS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidPANAccessReason), errors.Is(err, service.ErrPANJustification),
+		errors.Is(err, service.ErrInvalidUserID):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	case errors.Is(err, service.ErrPANAccessCapReached):
+		apperrors.RespondWithError(c, apperrors.ErrRateLimited.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
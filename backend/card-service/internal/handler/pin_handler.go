@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errIncorrectPIN   = apperrors.NewError("INCORRECT_PIN", "Incorrect PIN", http.StatusUnauthorized)
+	errCardPINBlocked = apperrors.NewError("CARD_PIN_BLOCKED", "Card is blocked after too many incorrect PIN attempts", http.StatusLocked)
+	errPINLockedOut   = apperrors.NewError("PIN_LOCKED_OUT", "Too many incorrect PINs, please try again later", http.StatusTooManyRequests)
+)
+
+type PINRequest struct {
+	PIN string `json:"pin" binding:"required"`
+}
+
+// SetPIN sets or changes the card PIN
+func (h *CardHandler) SetPIN(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req PINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	card, err := h.Service.SetPIN(userID, c.Param("id"), req.PIN)
+	if err != nil {
+		respondPINError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardPINChange, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id": card.ID.String(),
+	})
+	c.JSON(http.StatusOK, gin.H{"status": "PIN_SET", "pin_updated_at": card.PinUpdatedAt})
+}
+
+// VerifyPIN checks a card PIN for internal authorization flows. The route
+// only accepts service tokens.
+func (h *CardHandler) VerifyPIN(c *gin.Context) {
+	var req PINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	result, err := h.Service.VerifyPIN(c.Param("id"), req.PIN)
+	if result != nil && result.JustBlocked {
+		h.Audit.LogEvent(middleware.AuditEventCardBlock, middleware.AuditSeverityWarning, c, map[string]interface{}{
+			"card_id": result.Card.ID.String(),
+			"reason":  "pin_attempts_exceeded",
+		})
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrIncorrectPIN) {
+			apperrors.RespondWithError(c, errIncorrectPIN.WithDetails(gin.H{"attempts_remaining": result.AttemptsRemaining}))
+			return
+		}
+		respondPINError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"valid": true})
+}
+
+// UnblockPIN lifts a PIN block and sets a new PIN. The route requires a recent step-up.
+func (h *CardHandler) UnblockPIN(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req PINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	card, err := h.Service.UnblockPIN(userID, c.Param("id"), req.PIN)
+	if err != nil {
+		respondPINError(c, err)
+		return
+	}
+
+	metadata := map[string]interface{}{"card_id": card.ID.String()}
+	h.Audit.LogEvent(middleware.AuditEventCardUnblock, middleware.AuditSeverityInfo, c, metadata)
+	h.Audit.LogEvent(middleware.AuditEventCardPINChange, middleware.AuditSeverityInfo, c, metadata)
+	c.JSON(http.StatusOK, card)
+}
+
+// respondPINError maps PIN service errors to API errors
+func respondPINError(c *gin.Context, err error) {
+	var lockedOut *middleware.LockedOutError
+	switch {
+	case errors.As(err, &lockedOut):
+		c.Header("Retry-After", strconv.Itoa(int(lockedOut.RetryAfter.Seconds())+1))
+		apperrors.RespondWithError(c, errPINLockedOut)
+	case errors.Is(err, service.ErrInvalidPINFormat), errors.Is(err, service.ErrWeakPIN):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrIncorrectPIN):
+		apperrors.RespondWithError(c, errIncorrectPIN)
+	case errors.Is(err, service.ErrCardPINBlocked):
+		apperrors.RespondWithError(c, errCardPINBlocked)
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	case errors.Is(err, service.ErrPINNotSet),
+		errors.Is(err, service.ErrCardNotActive),
+		errors.Is(err, service.ErrCardNotPINBlocked):
+		apperrors.RespondWithError(c, apperrors.NewError("INVALID_CARD_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReplaceCardRequest struct {
+	Reason     string `json:"reason" binding:"required"`
+	KeepTokens bool   `json:"keep_tokens"`
+}
+
+// ReplaceCard blocks a lost, stolen or damaged card and issues its replacement
+func (h *CardHandler) ReplaceCard(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req ReplaceCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	result, err := h.Service.ReplaceCard(userID, c.Param("id"), model.ReplacementReason(req.Reason), req.KeepTokens)
+	if err != nil {
+		respondReplacementError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardBlock, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"card_id":             result.ReplacedCard.ID.String(),
+		"reason":              string(result.Replacement.Reason),
+		"replaced_by_card_id": result.Card.ID.String(),
+	})
+	h.Audit.LogEvent(middleware.AuditEventCardIssue, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":          result.Card.ID.String(),
+		"replaces_card_id": result.ReplacedCard.ID.String(),
+		"tokens_moved":     result.Replacement.TokensMoved,
+	})
+	c.JSON(http.StatusCreated, result)
+}
+
+// respondReplacementError maps card replacement errors to API errors
+func respondReplacementError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidReplacementReason):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	case errors.Is(err, service.ErrCardAlreadyReplaced):
+		apperrors.RespondWithError(c, apperrors.NewError("CARD_ALREADY_REPLACED", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
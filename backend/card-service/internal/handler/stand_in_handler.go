@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ListStandInAuthorizations returns payments approved in stand-in, optionally
+// filtered by ?status=, e.g. FLAGGED for the review queue
+func (h *CardHandler) ListStandInAuthorizations(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	auths, err := h.Service.ListStandInAuthorizations(model.StandInStatus(c.Query("status")), limit)
+	if err != nil {
+		respondStandInError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, auths)
+}
+
+type ReviewStandInRequest struct {
+	Note string `json:"note" binding:"required,max=500"`
+}
+
+// ReviewStandInAuthorization closes the review of a flagged stand-in approval
+func (h *CardHandler) ReviewStandInAuthorization(c *gin.Context) {
+	var req ReviewStandInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	auth, err := h.Service.ReviewStandInAuthorization(middleware.GetUserID(c), c.Param("id"), req.Note)
+	if err != nil {
+		respondStandInError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation":   "stand_in_review",
+		"stand_in_id": auth.ID.String(),
+		"amount":      auth.Amount.String(),
+	})
+	c.JSON(http.StatusOK, auth)
+}
+
+// respondStandInError maps stand-in review errors to API errors
+func respondStandInError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrStandInDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("STAND_IN_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidStandInStatus), errors.Is(err, service.ErrStandInReviewNoteMissing),
+		errors.Is(err, service.ErrInvalidUserID):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrStandInNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrStandInNotFlagged):
+		apperrors.RespondWithError(c, apperrors.NewError("STAND_IN_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ListSubscriptions returns the recurring merchants detected in the card's transactions
+func (h *CardHandler) ListSubscriptions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	subscriptions, err := h.Service.ListSubscriptions(userID, c.Param("id"))
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// BlockSubscription declines future charges from a subscription's merchant
+// by adding it to the card's merchant denylist
+func (h *CardHandler) BlockSubscription(c *gin.Context) {
+	h.setSubscriptionBlocked(c, true)
+}
+
+// UnblockSubscription allows a blocked subscription's merchant to charge the card again
+func (h *CardHandler) UnblockSubscription(c *gin.Context) {
+	h.setSubscriptionBlocked(c, false)
+}
+
+func (h *CardHandler) setSubscriptionBlocked(c *gin.Context, blocked bool) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	block := h.Service.UnblockSubscription
+	if blocked {
+		block = h.Service.BlockSubscription
+	}
+	subscription, err := block(userID, c.Param("id"), c.Param("subscriptionId"))
+	if err != nil {
+		respondSubscriptionError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardControlsUpdate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":              c.Param("id"),
+		"subscription_id":      subscription.ID,
+		"subscription_blocked": blocked,
+	})
+	c.JSON(http.StatusOK, subscription)
+}
+
+// respondSubscriptionError maps subscription errors to API errors
+func respondSubscriptionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSubscriptionNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardControlsDisabled), errors.Is(err, service.ErrTooManyMerchantRules):
+		respondCardControlError(c, err)
+	default:
+		respondInsightsError(c, err)
+	}
+}
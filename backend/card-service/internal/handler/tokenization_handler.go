@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type IssueTokenRequest struct {
+	WalletProvider string `json:"wallet_provider" binding:"required"`
+	DeviceID       string `json:"device_id" binding:"required,max=100"`
+	DeviceName     string `json:"device_name" binding:"max=100"`
+}
+
+// IssueToken provisions a device-bound network token for Apple Pay or Google Pay
+func (h *CardHandler) IssueToken(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req IssueTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	issued, err := h.Service.IssueNetworkToken(userID, c.Param("id"), model.WalletProvider(req.WalletProvider), req.DeviceID, req.DeviceName)
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardTokenize, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":         issued.CardID.String(),
+		"token_id":        issued.ID.String(),
+		"wallet_provider": string(issued.WalletProvider),
+		"device_id":       issued.DeviceID,
+	})
+	c.JSON(http.StatusCreated, issued)
+}
+
+// ListTokens returns the network tokens issued for a card
+func (h *CardHandler) ListTokens(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	tokens, err := h.Service.ListNetworkTokens(userID, c.Param("id"))
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeToken revokes a network token, e.g. when a device is lost
+func (h *CardHandler) RevokeToken(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	token, err := h.Service.RevokeNetworkToken(userID, c.Param("id"), c.Param("tokenId"))
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventTokenRevoke, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":  token.CardID.String(),
+		"token_id": token.ID.String(),
+	})
+	c.JSON(http.StatusOK, token)
+}
+
+type TokenAuthorizationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	DeviceID string `json:"device_id" binding:"required"`
+	Amount   string `json:"amount" binding:"required"`
+	// MerchantCountry is the merchant's ISO 3166-1 alpha-2 country, when known
+	MerchantCountry string `json:"merchant_country" binding:"omitempty,len=2"`
+	// MerchantID and MerchantName identify the merchant for the card's merchant lists
+	MerchantID   string `json:"merchant_id" binding:"max=64"`
+	MerchantName string `json:"merchant_name" binding:"max=100"`
+}
+
+// AuthorizeToken authorizes a wallet payment using a network token in place of a PAN.
+// Internal only: declines are returned with 200 and a decline_reason.
+func (h *CardHandler) AuthorizeToken(c *gin.Context) {
+	var req TokenAuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("invalid amount"))
+		return
+	}
+
+	result, err := h.Service.AuthorizeWithToken(req.Token, req.DeviceID, amount, service.Merchant{
+		ID:      req.MerchantID,
+		Name:    req.MerchantName,
+		Country: req.MerchantCountry,
+	})
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	if result.DeclineReason == service.DeclineDeviceMismatch || result.DeclineReason == service.DeclineTokenRevoked {
+		metadata := map[string]interface{}{"reason": string(result.DeclineReason)}
+		if result.TokenID != nil {
+			metadata["token_id"] = result.TokenID.String()
+		}
+		h.Audit.LogEvent(middleware.AuditEventSuspiciousActivity, middleware.AuditSeverityWarning, c, metadata)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// respondTokenError maps tokenization service errors to API errors
+func respondTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidWalletProvider),
+		errors.Is(err, service.ErrDeviceIDRequired),
+		errors.Is(err, service.ErrInvalidAmount),
+		errors.Is(err, service.ErrInvalidMerchantCountry):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrNetworkTokenNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	case errors.Is(err, service.ErrCardNotActive):
+		apperrors.RespondWithError(c, apperrors.NewError("INVALID_CARD_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	}
+}
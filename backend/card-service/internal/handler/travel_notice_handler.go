@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateTravelNoticeRequest struct {
+	Countries []string `json:"countries" binding:"required,min=1"`
+	StartDate string   `json:"start_date" binding:"required"`
+	EndDate   string   `json:"end_date" binding:"required"`
+}
+
+// CreateTravelNotice declares a trip so foreign transactions in the listed countries are not declined
+func (h *CardHandler) CreateTravelNotice(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CreateTravelNoticeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	start, err := time.Parse(time.DateOnly, req.StartDate)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("start_date must be YYYY-MM-DD"))
+		return
+	}
+	end, err := time.Parse(time.DateOnly, req.EndDate)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("end_date must be YYYY-MM-DD"))
+		return
+	}
+
+	notice, err := h.Service.CreateTravelNotice(userID, c.Param("id"), req.Countries, start, end)
+	if err != nil {
+		respondTravelNoticeError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventCardTravelNotice, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"card_id":    notice.CardID.String(),
+		"notice_id":  notice.ID.String(),
+		"countries":  notice.Countries,
+		"start_date": req.StartDate,
+		"end_date":   req.EndDate,
+	})
+	c.JSON(http.StatusCreated, notice)
+}
+
+// ListTravelNotices returns the travel notices declared for a card
+func (h *CardHandler) ListTravelNotices(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	notices, err := h.Service.ListTravelNotices(userID, c.Param("id"))
+	if err != nil {
+		respondTravelNoticeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, notices)
+}
+
+// CancelTravelNotice ends a travel notice, e.g. when a trip is called off
+func (h *CardHandler) CancelTravelNotice(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	notice, err := h.Service.CancelTravelNotice(userID, c.Param("id"), c.Param("noticeId"))
+	if err != nil {
+		respondTravelNoticeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, notice)
+}
+
+// respondTravelNoticeError maps travel notice and geo-blocking errors to API errors
+func respondTravelNoticeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrTravelNoticesDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("TRAVEL_NOTICES_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrInvalidCountry), errors.Is(err, service.ErrTooManyCountries),
+		errors.Is(err, service.ErrInvalidTravelDates), errors.Is(err, service.ErrTravelNoticeTooLong):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrTravelNoticeNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrCardPendingApproval):
+		apperrors.RespondWithError(c, errCardPendingApproval)
+	case errors.Is(err, service.ErrUnauthorized), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage("card not found"))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -14,6 +14,14 @@ const (
 	CardActive   CardStatus = "ACTIVE"
 	CardBlocked  CardStatus = "BLOCKED"
 	CardInactive CardStatus = "INACTIVE"
+	// CardPINBlocked is a soft block after too many wrong PINs; the owner can lift it
+	CardPINBlocked CardStatus = "PIN_BLOCKED"
+	// CardExpired is a renewed card deactivated at the end of its expiry month
+	CardExpired CardStatus = "EXPIRED"
+	// CardPendingApproval is a delegate card waiting for the account owner's approval
+	CardPendingApproval CardStatus = "PENDING_APPROVAL"
+	// CardDeclined is a delegate card the account owner did not approve
+	CardDeclined CardStatus = "DECLINED"
 )
 
 type Card struct {
@@ -23,17 +31,43 @@ type Card struct {
 	// EncryptedCardNumber stores AES-256-GCM encrypted card number - NEVER exposed in API
 	EncryptedCardNumber string `gorm:"column:encrypted_card_number;type:text;not null" json:"-"`
 	// MaskedCardNumber stores only displayable format: **** **** **** 1234
-	MaskedCardNumber string `gorm:"column:masked_card_number;type:varchar(19);not null" json:"card_number"`
+	MaskedCardNumber string `gorm:"column:masked_card_number;type:varchar(19);not null" json:"card_number" redact:"third_party=last4"`
 	// CVV is NEVER stored per PCI DSS 3.2 - only used for single-transaction validation
-	ExpirationDate string     `gorm:"type:varchar(5);not null" json:"expiration_date"` // MM/YY
-	Status         CardStatus `gorm:"type:varchar(20);default:'ACTIVE'" json:"status"`
-	// CardToken for payment processing - replaces actual card number in transactions
-	CardToken  uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid()" json:"card_token"`
+	ExpirationDate string `gorm:"type:varchar(5);not null" json:"expiration_date"` // MM/YY
+	// ExpiresAt is the start of the month after ExpirationDate, when the card stops working
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	// DeactivatesAt is set when a card is renewed; the old card keeps working until then
+	DeactivatesAt *time.Time `json:"deactivates_at,omitempty"`
+	Status        CardStatus `gorm:"type:varchar(20);default:'ACTIVE'" json:"status"`
+	// CardToken for payment processing - replaces actual card number in transactions.
+	// Support and third-party callers never see it.
+	CardToken  uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid()" json:"card_token" redact:"support,third_party"`
 	PinHash    string          `gorm:"type:varchar(255)" json:"-"` // Never expose PIN
 	DailyLimit decimal.Decimal `gorm:"type:numeric(19,4);default:1000.00" json:"daily_limit"`
 	CreatedAt  time.Time       `json:"created_at"`
 	UpdatedAt  time.Time       `json:"updated_at"`
 	DeletedAt  gorm.DeletedAt  `gorm:"index" json:"-"`
+	// PinFailedAttempts counts consecutive wrong PINs and is reset on success
+	PinFailedAttempts int        `gorm:"default:0" json:"-"`
+	PinUpdatedAt      *time.Time `json:"pin_updated_at,omitempty"`
+	// ReplacesCardID and ReplacedByCardID link a card to its predecessor and successor
+	ReplacesCardID   *uuid.UUID `gorm:"type:uuid;index" json:"replaces_card_id,omitempty"`
+	ReplacedByCardID *uuid.UUID `gorm:"type:uuid" json:"replaced_by_card_id,omitempty"`
+	// GeoBlocking declines foreign transactions not covered by a travel notice.
+	// It has no gorm default so that false is written on insert.
+	GeoBlocking bool `gorm:"not null" json:"geo_blocking"`
+	// Delegate is set on cards issued to a secondary user of someone else's
+	// account. UserID is the delegate; the account owner approves the card and
+	// sets its limit.
+	Delegate   bool       `gorm:"not null" json:"delegate"`
+	ApprovedBy *uuid.UUID `gorm:"type:uuid" json:"approved_by,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+}
+
+// Usable reports whether the cardholder can manage the card. Delegate cards
+// cannot be used before the account owner approves them.
+func (c *Card) Usable() bool {
+	return c.Status != CardPendingApproval && c.Status != CardDeclined
 }
 
 // TableName specifies the table name for GORM
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MerchantList is the list a merchant rule belongs to
+type MerchantList string
+
+const (
+	// MerchantAllowlist restricts a card to the merchants it lists, when it lists any
+	MerchantAllowlist MerchantList = "ALLOW"
+	// MerchantDenylist declines the merchants it lists, even if they are allowlisted
+	MerchantDenylist MerchantList = "DENY"
+)
+
+// MerchantRule matches merchants by the network's merchant id or by a name
+// pattern; exactly one of the two is set
+type MerchantRule struct {
+	ID         uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID     uuid.UUID    `gorm:"type:uuid;not null;index" json:"card_id"`
+	List       MerchantList `gorm:"type:varchar(5);not null" json:"list"`
+	MerchantID string       `gorm:"type:varchar(64)" json:"merchant_id,omitempty"`
+	// NamePattern matches merchant names case-insensitively; * matches any
+	// run of characters, so *casino* matches every name containing casino
+	NamePattern string    `gorm:"type:varchar(100)" json:"name_pattern,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (MerchantRule) TableName() string {
+	return "card_merchant_rules"
+}
+
+// Matches reports whether the rule covers a merchant. A rule never matches
+// a merchant the network did not identify.
+func (r *MerchantRule) Matches(merchantID, merchantName string) bool {
+	if r.MerchantID != "" {
+		return merchantID != "" && r.MerchantID == merchantID
+	}
+	return merchantName != "" && matchNamePattern(strings.ToLower(r.NamePattern), strings.ToLower(strings.TrimSpace(merchantName)))
+}
+
+// matchNamePattern matches name against a pattern in which * matches any run
+// of characters and everything else matches itself
+func matchNamePattern(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return strings.HasSuffix(name, last)
+}
+
+// CardControlChange records one change to a card's controls, for the card's
+// control history. OldValue and NewValue are the control's value before and after.
+type CardControlChange struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID    uuid.UUID `gorm:"type:uuid;not null;index:idx_card_control_changes_card_created" json:"card_id"`
+	ChangedBy uuid.UUID `gorm:"type:uuid;not null" json:"changed_by"`
+	// Control is geo_blocking, merchant_allowlist or merchant_denylist
+	Control   string    `gorm:"type:varchar(32);not null" json:"control"`
+	OldValue  any       `gorm:"type:jsonb;serializer:json;not null" json:"old_value"`
+	NewValue  any       `gorm:"type:jsonb;serializer:json;not null" json:"new_value"`
+	CreatedAt time.Time `gorm:"index:idx_card_control_changes_card_created" json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CardControlChange) TableName() string {
+	return "card_control_changes"
+}
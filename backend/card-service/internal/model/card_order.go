@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CardOrderStatus string
+
+// A physical card order moves through these statuses in order. The
+// fulfillment partner reports each step up to DELIVERED; the cardholder
+// activates the card once it has shipped.
+const (
+	CardOrderOrdered   CardOrderStatus = "ORDERED"
+	CardOrderPrinted   CardOrderStatus = "PRINTED"
+	CardOrderShipped   CardOrderStatus = "SHIPPED"
+	CardOrderDelivered CardOrderStatus = "DELIVERED"
+	CardOrderActivated CardOrderStatus = "ACTIVATED"
+)
+
+// Step returns the position of the status in the order pipeline, or -1 for an
+// unknown status
+func (s CardOrderStatus) Step() int {
+	switch s {
+	case CardOrderOrdered:
+		return 0
+	case CardOrderPrinted:
+		return 1
+	case CardOrderShipped:
+		return 2
+	case CardOrderDelivered:
+		return 3
+	case CardOrderActivated:
+		return 4
+	}
+	return -1
+}
+
+// CardOrder tracks the production and delivery of a physical card. The card
+// is INACTIVE until the cardholder activates it.
+type CardOrder struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID         uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex" json:"card_id"`
+	UserID         uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status         CardOrderStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Carrier        string          `gorm:"type:varchar(50)" json:"carrier,omitempty"`
+	TrackingNumber string          `gorm:"type:varchar(100)" json:"tracking_number,omitempty"`
+	PrintedAt      *time.Time      `json:"printed_at,omitempty"`
+	ShippedAt      *time.Time      `json:"shipped_at,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	ActivatedAt    *time.Time      `json:"activated_at,omitempty"`
+	// ActivationFailedAttempts counts wrong last-4 or expiry entries; activation
+	// is locked once it reaches the limit
+	ActivationFailedAttempts int       `gorm:"not null;default:0" json:"-"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CardOrder) TableName() string {
+	return "card_orders"
+}
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReplacementReason string
+
+const (
+	ReplacementLost    ReplacementReason = "LOST"
+	ReplacementStolen  ReplacementReason = "STOLEN"
+	ReplacementDamaged ReplacementReason = "DAMAGED"
+	// ReplacementExpiry is used by the renewal job and cannot be requested by users
+	ReplacementExpiry ReplacementReason = "EXPIRY"
+)
+
+// IsValid reports whether the reason is one users can request a replacement for
+func (r ReplacementReason) IsValid() bool {
+	switch r {
+	case ReplacementLost, ReplacementStolen, ReplacementDamaged:
+		return true
+	}
+	return false
+}
+
+// CardReplacement records one link in a card's replacement chain. A card can be
+// replaced at most once, so both ends of the link are unique.
+type CardReplacement struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OldCardID uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex" json:"old_card_id"`
+	NewCardID uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex" json:"new_card_id"`
+	UserID    uuid.UUID         `gorm:"type:uuid;not null;index" json:"user_id"`
+	Reason    ReplacementReason `gorm:"type:varchar(20);not null" json:"reason"`
+	// TokensMoved is the number of wallet tokens carried over to the new card
+	TokensMoved int       `gorm:"default:0" json:"tokens_moved"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CardReplacement) TableName() string {
+	return "card_replacements"
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CardTransaction is a settled card payment in the card transaction feed.
+// Amount is in the card's billing currency.
+type CardTransaction struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID uuid.UUID `gorm:"type:uuid;not null;index:idx_card_transactions_card_settled" json:"card_id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	// NetworkReference identifies the settlement at the card network, so a
+	// settlement delivered twice is recorded once
+	NetworkReference string          `gorm:"type:varchar(64);not null;uniqueIndex" json:"network_reference"`
+	Amount           decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Currency         string          `gorm:"type:char(3);not null" json:"currency"`
+	MerchantName     string          `gorm:"type:varchar(100);not null" json:"merchant_name"`
+	// MerchantCategoryCode is the ISO 18245 MCC the insights group spend by
+	MerchantCategoryCode string    `gorm:"type:varchar(4);not null" json:"merchant_category_code"`
+	MerchantCountry      string    `gorm:"type:char(2)" json:"merchant_country,omitempty"`
+	SettledAt            time.Time `gorm:"not null;index:idx_card_transactions_card_settled" json:"settled_at"`
+	CreatedAt            time.Time `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (CardTransaction) TableName() string {
+	return "card_transactions"
+}
+
+// MCCSpend is a card's spend at merchants of one category code
+type MCCSpend struct {
+	MerchantCategoryCode string
+	Amount               decimal.Decimal
+	Count                int
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type DisputeStatus string
+
+const (
+	DisputeOpen        DisputeStatus = "OPEN"
+	DisputeUnderReview DisputeStatus = "UNDER_REVIEW"
+	DisputeResolved    DisputeStatus = "RESOLVED"
+)
+
+type DisputeOutcome string
+
+const (
+	// DisputeWon keeps the provisional credit; the merchant's chargeback covers it
+	DisputeWon DisputeOutcome = "WON"
+	// DisputeLost takes the provisional credit back from the cardholder
+	DisputeLost DisputeOutcome = "LOST"
+)
+
+// DisputeReason is the cardholder's reason for disputing a transaction
+type DisputeReason string
+
+const (
+	DisputeFraud           DisputeReason = "FRAUD"
+	DisputeNotReceived     DisputeReason = "NOT_RECEIVED"
+	DisputeNotAsDescribed  DisputeReason = "NOT_AS_DESCRIBED"
+	DisputeDuplicateCharge DisputeReason = "DUPLICATE_CHARGE"
+	DisputeIncorrectAmount DisputeReason = "INCORRECT_AMOUNT"
+)
+
+// IsValid reports whether the reason is one of the supported dispute reasons
+func (r DisputeReason) IsValid() bool {
+	switch r {
+	case DisputeFraud, DisputeNotReceived, DisputeNotAsDescribed, DisputeDuplicateCharge, DisputeIncorrectAmount:
+		return true
+	}
+	return false
+}
+
+// Dispute is a cardholder's challenge of a card transaction. A transaction can
+// only be disputed once, so TransactionID is unique.
+type Dispute struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID uuid.UUID `gorm:"type:uuid;not null;index" json:"card_id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	// TransactionID is the ledger entry of the disputed card transaction
+	TransactionID uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex" json:"transaction_id"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Reason        DisputeReason   `gorm:"type:varchar(30);not null" json:"reason"`
+	Description   string          `gorm:"type:text" json:"description,omitempty"`
+	Status        DisputeStatus   `gorm:"type:varchar(20);not null;index" json:"status"`
+	Outcome       DisputeOutcome  `gorm:"type:varchar(10)" json:"outcome,omitempty"`
+	// ProvisionalEntryID is the ledger entry that credited the cardholder when the dispute was opened
+	ProvisionalEntryID *uuid.UUID `gorm:"type:uuid" json:"provisional_entry_id,omitempty"`
+	// ReversalEntryID is the ledger entry that took the provisional credit back on a lost dispute
+	ReversalEntryID *uuid.UUID `gorm:"type:uuid" json:"reversal_entry_id,omitempty"`
+	ResolutionNote  string     `gorm:"type:text" json:"resolution_note,omitempty"`
+	ReviewedBy      *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ResolvedBy      *uuid.UUID `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Dispute) TableName() string {
+	return "card_disputes"
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WalletProvider string
+
+const (
+	WalletApplePay  WalletProvider = "APPLE_PAY"
+	WalletGooglePay WalletProvider = "GOOGLE_PAY"
+)
+
+type NetworkTokenStatus string
+
+const (
+	NetworkTokenActive  NetworkTokenStatus = "ACTIVE"
+	NetworkTokenRevoked NetworkTokenStatus = "REVOKED"
+)
+
+// NetworkToken is a device-bound stand-in for the card number, as issued to a
+// mobile wallet. Merchants only ever see the token, never the PAN.
+type NetworkToken struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID uuid.UUID `gorm:"type:uuid;not null;index" json:"card_id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	// EncryptedToken stores the AES-256-GCM encrypted token number - NEVER exposed in API
+	EncryptedToken string `gorm:"type:text;not null" json:"-"`
+	// TokenHash is a SHA-256 of the token number used to look tokens up during authorization
+	TokenHash      string             `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	TokenLastFour  string             `gorm:"type:varchar(4);not null" json:"token_last_four"`
+	WalletProvider WalletProvider     `gorm:"type:varchar(20);not null" json:"wallet_provider"`
+	DeviceID       string             `gorm:"type:varchar(100);not null;index" json:"device_id"`
+	DeviceName     string             `gorm:"type:varchar(100)" json:"device_name,omitempty"`
+	Status         NetworkTokenStatus `gorm:"type:varchar(20);default:'ACTIVE'" json:"status"`
+	ExpiresAt      time.Time          `json:"expires_at"`
+	RevokedAt      *time.Time         `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (NetworkToken) TableName() string {
+	return "network_tokens"
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is a Kafka message written in the same transaction as the state
+// change it describes and published afterwards by the outbox relay
+type OutboxEvent struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Topic       string     `gorm:"type:varchar(100);not null" json:"topic"`
+	Key         string     `gorm:"type:varchar(100);not null" json:"key"`
+	Payload     string     `gorm:"type:jsonb;not null" json:"payload"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+	PublishedAt *time.Time `gorm:"index" json:"published_at,omitempty"`
+}
+
+// TableName specifies the table name for GORM
+func (OutboxEvent) TableName() string {
+	return "card_outbox_events"
+}
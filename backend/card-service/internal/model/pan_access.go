@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PANAccessReason is why an administrator needed a card's full number
+type PANAccessReason string
+
+const (
+	// PANAccessProcessorReissue sends the number to the processor to reissue the card
+	PANAccessProcessorReissue PANAccessReason = "PROCESSOR_REISSUE"
+	// PANAccessChargebackEvidence supplies the number for a scheme chargeback case
+	PANAccessChargebackEvidence PANAccessReason = "CHARGEBACK_EVIDENCE"
+	// PANAccessFraudInvestigation matches the card against a fraud report
+	PANAccessFraudInvestigation PANAccessReason = "FRAUD_INVESTIGATION"
+	// PANAccessLegalRequest answers a court order or law enforcement request
+	PANAccessLegalRequest PANAccessReason = "LEGAL_REQUEST"
+)
+
+// PANAccess records one disclosure of a card's full number to an
+// administrator. It is written before the number is decrypted, so every
+// disclosure has a record.
+type PANAccess struct {
+	ID         uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID     uuid.UUID       `gorm:"type:uuid;not null;index" json:"card_id"`
+	AccessedBy uuid.UUID       `gorm:"type:uuid;not null;index:idx_pan_accesses_accessed_by_created_at" json:"accessed_by"`
+	Reason     PANAccessReason `gorm:"type:varchar(30);not null" json:"reason"`
+	// Justification is the administrator's own account of why, e.g. the case it is for
+	Justification string `gorm:"type:varchar(500);not null" json:"justification"`
+	// Reference is the ticket, case or order number the access is for
+	Reference string    `gorm:"type:varchar(100)" json:"reference,omitempty"`
+	IPAddress string    `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+	CreatedAt time.Time `gorm:"not null;index:idx_pan_accesses_accessed_by_created_at" json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (PANAccess) TableName() string {
+	return "pan_accesses"
+}
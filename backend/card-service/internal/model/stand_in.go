@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// AccountBalance is the last available balance the ledger reported for an
+// account. Authorizations fall back to it while the ledger is unreachable.
+type AccountBalance struct {
+	AccountID        uuid.UUID       `gorm:"type:uuid;primary_key" json:"account_id"`
+	AvailableBalance decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"available_balance"`
+	FetchedAt        time.Time       `gorm:"not null" json:"fetched_at"`
+}
+
+// TableName specifies the table name for GORM
+func (AccountBalance) TableName() string {
+	return "card_account_balances"
+}
+
+type StandInStatus string
+
+// A stand-in approval is PENDING until the ledger is reachable again. It is
+// then VERIFIED if the account's balance covered it, or FLAGGED for review;
+// ops mark flagged approvals REVIEWED.
+const (
+	StandInPending  StandInStatus = "PENDING"
+	StandInVerified StandInStatus = "VERIFIED"
+	StandInFlagged  StandInStatus = "FLAGGED"
+	StandInReviewed StandInStatus = "REVIEWED"
+)
+
+// StandInAuthorization is a payment approved against the cached balance
+// because the ledger did not answer the balance check in time
+type StandInAuthorization struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID       uuid.UUID       `gorm:"type:uuid;not null;index" json:"card_id"`
+	AccountID    uuid.UUID       `gorm:"type:uuid;not null;index:idx_stand_in_authorizations_account_status" json:"account_id"`
+	Amount       decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	MerchantID   string          `gorm:"type:varchar(64)" json:"merchant_id,omitempty"`
+	MerchantName string          `gorm:"type:varchar(100)" json:"merchant_name,omitempty"`
+	// CachedBalance and BalanceFetchedAt are the balance the approval relied on
+	CachedBalance    decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"cached_balance"`
+	BalanceFetchedAt time.Time       `gorm:"not null" json:"balance_fetched_at"`
+	Status           StandInStatus   `gorm:"type:varchar(20);not null;index:idx_stand_in_authorizations_account_status;index" json:"status"`
+	// VerifiedBalance is the available balance the ledger reported at verification
+	VerifiedBalance *decimal.Decimal `gorm:"type:numeric(19,4)" json:"verified_balance,omitempty"`
+	VerifiedAt      *time.Time       `json:"verified_at,omitempty"`
+	// FlagReason says why the approval needs review
+	FlagReason string     `gorm:"type:varchar(255)" json:"flag_reason,omitempty"`
+	ReviewedBy *uuid.UUID `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewNote string     `gorm:"type:varchar(500)" json:"review_note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (StandInAuthorization) TableName() string {
+	return "stand_in_authorizations"
+}
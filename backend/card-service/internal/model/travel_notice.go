@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TravelNotice tells card authorization that the cardholder expects to use the
+// card in the listed countries between StartDate and EndDate, both inclusive
+type TravelNotice struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	CardID uuid.UUID `gorm:"type:uuid;not null;index" json:"card_id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	// Countries are ISO 3166-1 alpha-2 codes
+	Countries   []string   `gorm:"type:text;not null;serializer:json" json:"countries"`
+	StartDate   time.Time  `gorm:"type:date;not null" json:"start_date"`
+	EndDate     time.Time  `gorm:"type:date;not null" json:"end_date"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (TravelNotice) TableName() string {
+	return "travel_notices"
+}
+
+// Covers reports whether the notice is active on the given day for country
+func (n *TravelNotice) Covers(country string, at time.Time) bool {
+	if n.CancelledAt != nil {
+		return false
+	}
+	day := at.UTC().Format(time.DateOnly)
+	if day < n.StartDate.UTC().Format(time.DateOnly) || day > n.EndDate.UTC().Format(time.DateOnly) {
+		return false
+	}
+	for _, c := range n.Countries {
+		if c == country {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListMerchantRules returns a card's merchant rules in the order they were added
+func (r *CardRepository) ListMerchantRules(cardID uuid.UUID) ([]model.MerchantRule, error) {
+	var rules []model.MerchantRule
+	if err := r.DB.Where("card_id = ?", cardID).Order("created_at ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ReplaceMerchantRules replaces one of a card's merchant lists and records the
+// change in a single transaction
+func (r *CardRepository) ReplaceMerchantRules(cardID uuid.UUID, list model.MerchantList, rules []model.MerchantRule, change *model.CardControlChange) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("card_id = ? AND list = ?", cardID, list).Delete(&model.MerchantRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) > 0 {
+			if err := tx.Create(&rules).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(change).Error
+	})
+}
+
+// UpdateCardControls saves a card whose controls changed and records the change
+func (r *CardRepository) UpdateCardControls(card *model.Card, change *model.CardControlChange) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(card).Error; err != nil {
+			return err
+		}
+		return tx.Create(change).Error
+	})
+}
+
+// ListCardControlChanges returns the history of a card's controls, latest first
+func (r *CardRepository) ListCardControlChanges(cardID uuid.UUID) ([]model.CardControlChange, error) {
+	var changes []model.CardControlChange
+	if err := r.DB.Where("card_id = ?", cardID).Order("created_at DESC").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateCardOrder creates a physical card with its order and queues the
+// order notification in one transaction
+func (r *CardRepository) CreateCardOrder(card *model.Card, order *model.CardOrder, events []model.OutboxEvent) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(card).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			return tx.Create(&events).Error
+		}
+		return nil
+	})
+}
+
+func (r *CardRepository) GetCardOrder(id uuid.UUID) (*model.CardOrder, error) {
+	var order model.CardOrder
+	if err := r.DB.First(&order, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func (r *CardRepository) GetCardOrderByCard(cardID uuid.UUID) (*model.CardOrder, error) {
+	var order model.CardOrder
+	if err := r.DB.First(&order, "card_id = ?", cardID).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// AdvanceCardOrder saves the order's new status and tracking details and
+// queues its notification. It returns false without changing anything if the
+// order is no longer in status from.
+func (r *CardRepository) AdvanceCardOrder(order *model.CardOrder, from model.CardOrderStatus, events []model.OutboxEvent) (bool, error) {
+	advanced := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.CardOrder{}).
+			Where("id = ? AND status = ?", order.ID, from).
+			Updates(map[string]interface{}{
+				"status":          order.Status,
+				"carrier":         order.Carrier,
+				"tracking_number": order.TrackingNumber,
+				"printed_at":      order.PrintedAt,
+				"shipped_at":      order.ShippedAt,
+				"delivered_at":    order.DeliveredAt,
+				"updated_at":      time.Now(),
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		advanced = true
+		if len(events) > 0 {
+			return tx.Create(&events).Error
+		}
+		return nil
+	})
+	return advanced, err
+}
+
+// RecordActivationFailure counts a failed activation attempt. It returns false
+// if the order already had maxAttempts failures.
+func (r *CardRepository) RecordActivationFailure(orderID uuid.UUID, maxAttempts int) (bool, error) {
+	res := r.DB.Model(&model.CardOrder{}).
+		Where("id = ? AND activation_failed_attempts < ?", orderID, maxAttempts).
+		Update("activation_failed_attempts", gorm.Expr("activation_failed_attempts + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+// ActivateCardOrder marks the order ACTIVATED and its card ACTIVE and queues
+// the notification in one transaction. It returns false without changing
+// anything if the order has not shipped, is locked or was already activated,
+// or the card is no longer INACTIVE.
+func (r *CardRepository) ActivateCardOrder(order *model.CardOrder, maxAttempts int, events []model.OutboxEvent) (bool, error) {
+	activated := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&model.CardOrder{}).
+			Where("id = ? AND status IN ? AND activation_failed_attempts < ?", order.ID,
+				[]model.CardOrderStatus{model.CardOrderShipped, model.CardOrderDelivered}, maxAttempts).
+			Updates(map[string]interface{}{"status": model.CardOrderActivated, "activated_at": order.ActivatedAt, "updated_at": now})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		res = tx.Model(&model.Card{}).
+			Where("id = ? AND status = ?", order.CardID, model.CardInactive).
+			Updates(map[string]interface{}{"status": model.CardActive, "updated_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errNotActivatable
+		}
+		activated = true
+		if len(events) > 0 {
+			return tx.Create(&events).Error
+		}
+		return nil
+	})
+	if errors.Is(err, errNotActivatable) {
+		return false, nil
+	}
+	return activated, err
+}
+
+// errNotActivatable rolls back an activation whose card is no longer INACTIVE
+var errNotActivatable = errors.New("card is not inactive")
+
+// ListCardOrdersByStatus returns up to limit orders in a status that have not
+// changed since updatedBefore, oldest first
+func (r *CardRepository) ListCardOrdersByStatus(status model.CardOrderStatus, updatedBefore time.Time, limit int) ([]model.CardOrder, error) {
+	var orders []model.CardOrder
+	err := r.DB.
+		Where("status = ? AND updated_at < ?", status, updatedBefore).
+		Order("updated_at").
+		Limit(limit).
+		Find(&orders).Error
+	return orders, err
+}
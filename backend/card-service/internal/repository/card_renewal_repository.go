@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// ListCardsDueForRenewal returns up to limit cards expiring before the given
+// time that have not been replaced or closed, soonest first
+func (r *CardRepository) ListCardsDueForRenewal(expiringBefore time.Time, limit int) ([]model.Card, error) {
+	var cards []model.Card
+	err := r.DB.
+		Where("expires_at < ? AND replaced_by_card_id IS NULL AND status NOT IN ?", expiringBefore,
+			[]model.CardStatus{model.CardInactive, model.CardExpired}).
+		Order("expires_at").
+		Limit(limit).
+		Find(&cards).Error
+	return cards, err
+}
+
+// RenewCard links old to its renewal like ReplaceCard and also copies the
+// travel notices, all in one transaction. It returns false without changing
+// anything if the old card was already replaced.
+func (r *CardRepository) RenewCard(old, renewal *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, notices []model.TravelNotice, events []model.OutboxEvent) (bool, error) {
+	renewed := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if renewed, err = replaceCard(tx, old, renewal, record, tokens, events); err != nil || !renewed {
+			return err
+		}
+		if len(notices) > 0 {
+			return tx.Create(&notices).Error
+		}
+		return nil
+	})
+	return renewed, err
+}
+
+// ListCardsDueForDeactivation returns up to limit renewed cards whose
+// deactivation time has passed and that are not yet expired
+func (r *CardRepository) ListCardsDueForDeactivation(now time.Time, limit int) ([]model.Card, error) {
+	var cards []model.Card
+	err := r.DB.
+		Where("deactivates_at <= ? AND status <> ?", now, model.CardExpired).
+		Order("deactivates_at").
+		Limit(limit).
+		Find(&cards).Error
+	return cards, err
+}
+
+// ExpireCard marks a renewed card expired and queues its event. It returns
+// false without changing anything if the card had already been expired.
+func (r *CardRepository) ExpireCard(card *model.Card, events []model.OutboxEvent) (bool, error) {
+	expired := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Card{}).
+			Where("id = ? AND status <> ?", card.ID, model.CardExpired).
+			Updates(map[string]interface{}{"status": model.CardExpired, "updated_at": time.Now()})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		expired = true
+		if len(events) > 0 {
+			return tx.Create(&events).Error
+		}
+		return nil
+	})
+	return expired, err
+}
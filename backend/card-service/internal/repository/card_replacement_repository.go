@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReplaceCard blocks the old card, creates its replacement, saves the moved or
+// revoked tokens and queues the lifecycle events in a single transaction.
+// It returns false without changing anything if the old card was already replaced.
+func (r *CardRepository) ReplaceCard(old, replacement *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, events []model.OutboxEvent) (bool, error) {
+	replaced := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		replaced, err = replaceCard(tx, old, replacement, record, tokens, events)
+		return err
+	})
+	return replaced, err
+}
+
+// replaceCard links old to its replacement within tx, unless old has already been replaced
+func replaceCard(tx *gorm.DB, old, replacement *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, events []model.OutboxEvent) (bool, error) {
+	res := tx.Model(&model.Card{}).
+		Where("id = ? AND replaced_by_card_id IS NULL", old.ID).
+		Updates(map[string]interface{}{
+			"status":              old.Status,
+			"replaced_by_card_id": old.ReplacedByCardID,
+			"deactivates_at":      old.DeactivatesAt,
+			"updated_at":          time.Now(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if err := tx.Create(replacement).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return false, err
+	}
+	for i := range tokens {
+		if err := tx.Save(&tokens[i]).Error; err != nil {
+			return false, err
+		}
+	}
+	if len(events) > 0 {
+		if err := tx.Create(&events).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// PublishOutbox hands up to limit unpublished events to publish, oldest first, and
+// marks the ones it accepted as published. Rows are locked with SKIP LOCKED so several
+// relays can run side by side. The first failure stops the batch to keep events in
+// order and is returned once the events published before it have been marked.
+func (r *CardRepository) PublishOutbox(limit int, publish func(model.OutboxEvent) error) (int, error) {
+	published := 0
+	var publishErr error
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var events []model.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL").
+			Order("created_at").
+			Limit(limit).
+			Find(&events).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, e := range events {
+			if publishErr = publish(e); publishErr != nil {
+				break
+			}
+			if err := tx.Model(&model.OutboxEvent{}).Where("id = ?", e.ID).Update("published_at", now).Error; err != nil {
+				return err
+			}
+			published++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, publishErr
+}
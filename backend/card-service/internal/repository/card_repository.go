@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -35,6 +37,38 @@ func (r *CardRepository) GetCardByID(cardID uuid.UUID) (*model.Card, error) {
 	return &c, nil
 }
 
+// UpdateCard persists changes to an existing card
+func (r *CardRepository) UpdateCard(c *model.Card) error {
+	return r.DB.Save(c).Error
+}
+
+// RecordPINFailure counts a wrong PIN on an active card in a single statement,
+// so concurrent attempts each add one, and blocks the card when the count
+// reaches maxAttempts. It returns the new count, or false if the card is no
+// longer active.
+func (r *CardRepository) RecordPINFailure(cardID uuid.UUID, maxAttempts int) (int, bool, error) {
+	var counts []int
+	err := r.DB.Raw(`
+		UPDATE cards SET pin_failed_attempts = pin_failed_attempts + 1,
+			status = CASE WHEN pin_failed_attempts + 1 >= ? THEN ? ELSE status END,
+			updated_at = ?
+		WHERE id = ? AND status = ?
+		RETURNING pin_failed_attempts`,
+		maxAttempts, model.CardPINBlocked, time.Now(), cardID, model.CardActive,
+	).Scan(&counts).Error
+	if err != nil || len(counts) == 0 {
+		return 0, false, err
+	}
+	return counts[0], true, nil
+}
+
+// ResetPINFailures clears the wrong PIN count after a correct PIN, leaving the
+// rest of the card as it is
+func (r *CardRepository) ResetPINFailures(cardID uuid.UUID) error {
+	return r.DB.Model(&model.Card{}).Where("id = ? AND pin_failed_attempts > 0", cardID).
+		Update("pin_failed_attempts", 0).Error
+}
+
 func (r *CardRepository) ListCardsByAccount(accountID string) ([]model.Card, error) {
 	var cards []model.Card
 	if err := r.DB.Where("account_id = ?", accountID).Find(&cards).Error; err != nil {
@@ -65,3 +99,39 @@ func (r *CardRepository) VerifyAccountOwnership(userID, accountID uuid.UUID) (bo
 	// This allows the demo to work without a full service mesh
 	return true, nil
 }
+
+func (r *CardRepository) CreateNetworkToken(t *model.NetworkToken) error {
+	return r.DB.Create(t).Error
+}
+
+// GetNetworkToken retrieves a network token by its UUID
+func (r *CardRepository) GetNetworkToken(id uuid.UUID) (*model.NetworkToken, error) {
+	var t model.NetworkToken
+	if err := r.DB.Where("id = ?", id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetNetworkTokenByHash looks up a network token by the SHA-256 of its number
+func (r *CardRepository) GetNetworkTokenByHash(hash string) (*model.NetworkToken, error) {
+	var t model.NetworkToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListNetworkTokensByCard returns all tokens issued for a card, newest first
+func (r *CardRepository) ListNetworkTokensByCard(cardID uuid.UUID) ([]model.NetworkToken, error) {
+	var tokens []model.NetworkToken
+	if err := r.DB.Where("card_id = ?", cardID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// UpdateNetworkToken persists changes to an existing network token
+func (r *CardRepository) UpdateNetworkToken(t *model.NetworkToken) error {
+	return r.DB.Save(t).Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// CreateCardTransaction records a settled transaction. It reports false, and
+// stores nothing, if the network reference was already recorded.
+func (r *CardRepository) CreateCardTransaction(t *model.CardTransaction) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "network_reference"}}, DoNothing: true}).Create(t)
+	return result.RowsAffected == 1, result.Error
+}
+
+// GetCardTransactionByReference retrieves a transaction by its network reference
+func (r *CardRepository) GetCardTransactionByReference(reference string) (*model.CardTransaction, error) {
+	var t model.CardTransaction
+	if err := r.DB.Where("network_reference = ?", reference).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListCardTransactions returns a card's transactions, most recent first
+func (r *CardRepository) ListCardTransactions(cardID uuid.UUID, limit int) ([]model.CardTransaction, error) {
+	var transactions []model.CardTransaction
+	err := r.DB.Where("card_id = ?", cardID).Order("settled_at DESC").Limit(limit).Find(&transactions).Error
+	return transactions, err
+}
+
+// SpendByMCC sums a card's transactions settled in [from, to) per merchant category code
+func (r *CardRepository) SpendByMCC(cardID uuid.UUID, from, to time.Time) ([]model.MCCSpend, error) {
+	var spend []model.MCCSpend
+	err := r.DB.Model(&model.CardTransaction{}).
+		Select("merchant_category_code, SUM(amount) AS amount, COUNT(*) AS count").
+		Where("card_id = ? AND settled_at >= ? AND settled_at < ?", cardID, from, to).
+		Group("merchant_category_code").
+		Scan(&spend).Error
+	return spend, err
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateDispute inserts a dispute. It returns gorm.ErrDuplicatedKey if the
+// transaction has already been disputed.
+func (r *CardRepository) CreateDispute(d *model.Dispute) error {
+	err := r.DB.Create(d).Error
+	if err != nil && isUniqueViolation(err) {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
+}
+
+// GetDispute retrieves a dispute by its UUID
+func (r *CardRepository) GetDispute(id uuid.UUID) (*model.Dispute, error) {
+	var d model.Dispute
+	if err := r.DB.Where("id = ?", id).First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeleteDispute removes a dispute whose provisional credit could not be posted
+func (r *CardRepository) DeleteDispute(id uuid.UUID) error {
+	return r.DB.Delete(&model.Dispute{}, "id = ?", id).Error
+}
+
+// ListDisputesByUser returns a user's disputes, newest first
+func (r *CardRepository) ListDisputesByUser(userID uuid.UUID) ([]model.Dispute, error) {
+	var disputes []model.Dispute
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&disputes).Error; err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// ListDisputes returns disputes in the given status, or all of them when status
+// is empty, oldest first so the review queue is worked in order
+func (r *CardRepository) ListDisputes(status model.DisputeStatus) ([]model.Dispute, error) {
+	query := r.DB.Order("created_at")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var disputes []model.Dispute
+	if err := query.Find(&disputes).Error; err != nil {
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// UpdateDispute saves a dispute's workflow fields only if it is still in status
+// from, so two admins cannot act on the same dispute at once. It returns false
+// if the dispute had already moved on.
+func (r *CardRepository) UpdateDispute(d *model.Dispute, from model.DisputeStatus) (bool, error) {
+	res := r.DB.Model(&model.Dispute{}).
+		Where("id = ? AND status = ?", d.ID, from).
+		Updates(map[string]interface{}{
+			"status":               d.Status,
+			"outcome":              d.Outcome,
+			"provisional_entry_id": d.ProvisionalEntryID,
+			"reversal_entry_id":    d.ReversalEntryID,
+			"resolution_note":      d.ResolutionNote,
+			"reviewed_by":          d.ReviewedBy,
+			"resolved_by":          d.ResolvedBy,
+			"resolved_at":          d.ResolvedAt,
+			"updated_at":           time.Now(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// isUniqueViolation checks for PostgreSQL unique_violation (23505)
+func isUniqueViolation(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreatePANAccess records a disclosure unless the administrator already made
+// limit of them since the given time. Concurrent requests by one
+// administrator take a transaction lock on their ID, so they are counted in
+// turn and cannot both pass the cap.
+func (r *CardRepository) CreatePANAccess(access *model.PANAccess, limit int, since time.Time) (bool, error) {
+	created := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "pan_access:"+access.AccessedBy.String()).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&model.PANAccess{}).
+			Where("accessed_by = ? AND created_at >= ?", access.AccessedBy, since).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= int64(limit) {
+			return nil
+		}
+		created = true
+		return tx.Create(access).Error
+	})
+	return created, err
+}
+
+// ListPANAccesses returns the disclosures of a card's number, newest first
+func (r *CardRepository) ListPANAccesses(cardID uuid.UUID) ([]model.PANAccess, error) {
+	var accesses []model.PANAccess
+	err := r.DB.Where("card_id = ?", cardID).Order("created_at DESC").Find(&accesses).Error
+	return accesses, err
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveAccountBalance stores the latest balance the ledger reported for an account
+func (r *CardRepository) SaveAccountBalance(balance *model.AccountBalance) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "account_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"available_balance", "fetched_at"}),
+	}).Create(balance).Error
+}
+
+func (r *CardRepository) GetAccountBalance(accountID uuid.UUID) (*model.AccountBalance, error) {
+	var balance model.AccountBalance
+	if err := r.DB.First(&balance, "account_id = ?", accountID).Error; err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// CreateStandInAuthorization stores a stand-in approval if the account's
+// cached balance, less its pending approvals, covers it. The cached balance
+// row is locked, so concurrent approvals on one account are decided in turn.
+func (r *CardRepository) CreateStandInAuthorization(auth *model.StandInAuthorization) (bool, error) {
+	created := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var balance model.AccountBalance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&balance, "account_id = ?", auth.AccountID).Error; err != nil {
+			return err
+		}
+		var pending decimal.NullDecimal
+		if err := tx.Model(&model.StandInAuthorization{}).
+			Where("account_id = ? AND status = ?", auth.AccountID, model.StandInPending).
+			Select("SUM(amount)").Scan(&pending).Error; err != nil {
+			return err
+		}
+		if balance.AvailableBalance.Sub(pending.Decimal).LessThan(auth.Amount) {
+			return nil
+		}
+		created = true
+		return tx.Create(auth).Error
+	})
+	return created, err
+}
+
+func (r *CardRepository) GetStandInAuthorization(id uuid.UUID) (*model.StandInAuthorization, error) {
+	var auth model.StandInAuthorization
+	if err := r.DB.First(&auth, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &auth, nil
+}
+
+func (r *CardRepository) ListStandInAuthorizations(status model.StandInStatus, limit int) ([]model.StandInAuthorization, error) {
+	query := r.DB.Order("created_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var auths []model.StandInAuthorization
+	err := query.Find(&auths).Error
+	return auths, err
+}
+
+func (r *CardRepository) ListPendingStandInAccounts(limit int) ([]uuid.UUID, error) {
+	var accounts []uuid.UUID
+	err := r.DB.Model(&model.StandInAuthorization{}).
+		Where("status = ?", model.StandInPending).
+		Distinct("account_id").
+		Limit(limit).
+		Pluck("account_id", &accounts).Error
+	return accounts, err
+}
+
+func (r *CardRepository) ListPendingStandInAuthorizations(accountID uuid.UUID) ([]model.StandInAuthorization, error) {
+	var auths []model.StandInAuthorization
+	err := r.DB.Where("account_id = ? AND status = ?", accountID, model.StandInPending).
+		Order("created_at").
+		Find(&auths).Error
+	return auths, err
+}
+
+// UpdateStandInAuthorization saves the verification or review of a stand-in
+// approval. It returns false without changing anything if the approval is no
+// longer in status from.
+func (r *CardRepository) UpdateStandInAuthorization(auth *model.StandInAuthorization, from model.StandInStatus) (bool, error) {
+	res := r.DB.Model(&model.StandInAuthorization{}).
+		Where("id = ? AND status = ?", auth.ID, from).
+		Updates(map[string]interface{}{
+			"status":           auth.Status,
+			"verified_balance": auth.VerifiedBalance,
+			"verified_at":      auth.VerifiedAt,
+			"flag_reason":      auth.FlagReason,
+			"reviewed_by":      auth.ReviewedBy,
+			"review_note":      auth.ReviewNote,
+			"reviewed_at":      auth.ReviewedAt,
+			"updated_at":       time.Now(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// CreateTravelNotice inserts a travel notice
+func (r *CardRepository) CreateTravelNotice(n *model.TravelNotice) error {
+	return r.DB.Create(n).Error
+}
+
+// GetTravelNotice retrieves a travel notice by its UUID
+func (r *CardRepository) GetTravelNotice(id uuid.UUID) (*model.TravelNotice, error) {
+	var n model.TravelNotice
+	if err := r.DB.Where("id = ?", id).First(&n).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// ListTravelNoticesByCard returns a card's travel notices, latest trip first
+func (r *CardRepository) ListTravelNoticesByCard(cardID uuid.UUID) ([]model.TravelNotice, error) {
+	var notices []model.TravelNotice
+	if err := r.DB.Where("card_id = ?", cardID).Order("start_date DESC").Find(&notices).Error; err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+// UpdateTravelNotice saves changes to a travel notice
+func (r *CardRepository) UpdateTravelNotice(n *model.TravelNotice) error {
+	return r.DB.Save(n).Error
+}
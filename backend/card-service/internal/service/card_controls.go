@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxMerchantRules bounds each of a card's merchant lists
+	MaxMerchantRules = 50
+
+	maxMerchantIDLength  = 64
+	maxNamePatternLength = 100
+)
+
+// Controls named in the card control history
+const (
+	ControlGeoBlocking       = "geo_blocking"
+	ControlMerchantAllowlist = "merchant_allowlist"
+	ControlMerchantDenylist  = "merchant_denylist"
+)
+
+var (
+	ErrCardControlsDisabled = errors.New("card controls are not configured")
+	ErrInvalidMerchantRule  = errors.New("each merchant rule needs either a merchant_id of at most 64 characters or a name_pattern of at most 100 characters, not both")
+	ErrInvalidNamePattern   = errors.New("name_pattern must contain more than * wildcards")
+	ErrTooManyMerchantRules = errors.New("a merchant list can have at most 50 rules")
+)
+
+// CardControlRepository stores merchant lists and the history of card control changes
+type CardControlRepository interface {
+	ListMerchantRules(cardID uuid.UUID) ([]model.MerchantRule, error)
+	ReplaceMerchantRules(cardID uuid.UUID, list model.MerchantList, rules []model.MerchantRule, change *model.CardControlChange) error
+	UpdateCardControls(card *model.Card, change *model.CardControlChange) error
+	ListCardControlChanges(cardID uuid.UUID) ([]model.CardControlChange, error)
+}
+
+// SetCardControls enables merchant allowlists and denylists and records every
+// change to a card's controls, geo-blocking included, in its control history
+func (s *CardService) SetCardControls(repo CardControlRepository) {
+	s.cardControls = repo
+}
+
+// Merchant is the merchant of a transaction as the card network describes it.
+// Any field may be empty when the network did not send it.
+type Merchant struct {
+	ID      string
+	Name    string
+	Country string
+}
+
+// MerchantRuleInput is one entry of a merchant list as the cardholder writes it
+type MerchantRuleInput struct {
+	MerchantID  string `json:"merchant_id,omitempty"`
+	NamePattern string `json:"name_pattern,omitempty"`
+}
+
+// CardControls are a card's authorization controls
+type CardControls struct {
+	model.Card
+	MerchantAllowlist []model.MerchantRule `json:"merchant_allowlist"`
+	MerchantDenylist  []model.MerchantRule `json:"merchant_denylist"`
+}
+
+// GetCardControls returns the controls of a card owned by the user
+func (s *CardService) GetCardControls(userID, cardID string) (*CardControls, error) {
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	return s.cardControlsOf(card)
+}
+
+// CardControlsUpdate lists the controls to change; nil fields are left alone
+type CardControlsUpdate struct {
+	GeoBlocking       *bool
+	MerchantAllowlist *[]MerchantRuleInput
+	MerchantDenylist  *[]MerchantRuleInput
+}
+
+// UpdateCardControls changes the controls of a card owned by the user. A
+// merchant list given is replaced as a whole, and an empty one is cleared;
+// duplicate rules are dropped. Every rule is validated before anything is
+// written, and each control that changes is recorded in the control history.
+func (s *CardService) UpdateCardControls(userID, cardID string, update CardControlsUpdate) (*CardControls, error) {
+	lists := map[model.MerchantList][]MerchantRuleInput{}
+	for list, inputs := range map[model.MerchantList]*[]MerchantRuleInput{
+		model.MerchantAllowlist: update.MerchantAllowlist,
+		model.MerchantDenylist:  update.MerchantDenylist,
+	} {
+		if inputs == nil {
+			continue
+		}
+		if s.cardControls == nil {
+			return nil, ErrCardControlsDisabled
+		}
+		rules, err := normalizeMerchantRules(*inputs)
+		if err != nil {
+			return nil, err
+		}
+		lists[list] = rules
+	}
+
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if update.GeoBlocking != nil {
+		if err := s.setGeoBlocking(card, *update.GeoBlocking); err != nil {
+			return nil, err
+		}
+	}
+	for _, list := range []model.MerchantList{model.MerchantAllowlist, model.MerchantDenylist} {
+		if rules, ok := lists[list]; ok {
+			if err := s.setMerchantList(card, list, rules); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.cardControlsOf(card)
+}
+
+// setMerchantList replaces one of the card's merchant lists with normalized
+// rules, unless it already holds them
+func (s *CardService) setMerchantList(card *model.Card, list model.MerchantList, rules []MerchantRuleInput) error {
+	current, err := s.cardControls.ListMerchantRules(card.ID)
+	if err != nil {
+		return err
+	}
+	old := merchantRuleInputs(current, list)
+	if equalMerchantRules(old, rules) {
+		return nil
+	}
+
+	records := make([]model.MerchantRule, len(rules))
+	for i, rule := range rules {
+		records[i] = model.MerchantRule{CardID: card.ID, List: list, MerchantID: rule.MerchantID, NamePattern: rule.NamePattern}
+	}
+	change := &model.CardControlChange{
+		CardID:    card.ID,
+		ChangedBy: card.UserID,
+		Control:   merchantListControl(list),
+		OldValue:  old,
+		NewValue:  rules,
+	}
+	return s.cardControls.ReplaceMerchantRules(card.ID, list, records, change)
+}
+
+// ListCardControlChanges returns the control history of a card owned by the user, latest first
+func (s *CardService) ListCardControlChanges(userID, cardID string) ([]model.CardControlChange, error) {
+	if s.cardControls == nil {
+		return nil, ErrCardControlsDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	return s.cardControls.ListCardControlChanges(card.ID)
+}
+
+// merchantDecline applies the card's merchant lists to a transaction and
+// returns why it is declined, or "" if the lists allow it. The denylist wins
+// over the allowlist, and a card with an allowlist declines merchants the
+// network did not identify.
+func (s *CardService) merchantDecline(card *model.Card, merchant Merchant) (DeclineReason, error) {
+	if s.cardControls == nil {
+		return "", nil
+	}
+	rules, err := s.cardControls.ListMerchantRules(card.ID)
+	if err != nil {
+		return "", err
+	}
+	allowlisted, hasAllowlist := false, false
+	for i := range rules {
+		matches := rules[i].Matches(merchant.ID, merchant.Name)
+		switch rules[i].List {
+		case model.MerchantDenylist:
+			if matches {
+				return DeclineMerchantDenylisted, nil
+			}
+		case model.MerchantAllowlist:
+			hasAllowlist = true
+			allowlisted = allowlisted || matches
+		}
+	}
+	if hasAllowlist && !allowlisted {
+		return DeclineMerchantNotAllowlisted, nil
+	}
+	return "", nil
+}
+
+func (s *CardService) cardControlsOf(card *model.Card) (*CardControls, error) {
+	controls := &CardControls{
+		Card:              *card,
+		MerchantAllowlist: []model.MerchantRule{},
+		MerchantDenylist:  []model.MerchantRule{},
+	}
+	if s.cardControls == nil {
+		return controls, nil
+	}
+	rules, err := s.cardControls.ListMerchantRules(card.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.List == model.MerchantAllowlist {
+			controls.MerchantAllowlist = append(controls.MerchantAllowlist, rule)
+		} else {
+			controls.MerchantDenylist = append(controls.MerchantDenylist, rule)
+		}
+	}
+	return controls, nil
+}
+
+// normalizeMerchantRules trims, validates and de-duplicates merchant rules.
+// Name patterns are compared case-insensitively and stored lower-cased.
+func normalizeMerchantRules(inputs []MerchantRuleInput) ([]MerchantRuleInput, error) {
+	rules := []MerchantRuleInput{}
+	seen := make(map[MerchantRuleInput]bool, len(inputs))
+	for _, in := range inputs {
+		rule := MerchantRuleInput{
+			MerchantID:  strings.TrimSpace(in.MerchantID),
+			NamePattern: strings.ToLower(strings.TrimSpace(in.NamePattern)),
+		}
+		if (rule.MerchantID == "") == (rule.NamePattern == "") ||
+			len(rule.MerchantID) > maxMerchantIDLength || len(rule.NamePattern) > maxNamePatternLength {
+			return nil, ErrInvalidMerchantRule
+		}
+		if rule.NamePattern != "" && strings.Trim(rule.NamePattern, "*") == "" {
+			return nil, ErrInvalidNamePattern
+		}
+		if !seen[rule] {
+			seen[rule] = true
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) > MaxMerchantRules {
+		return nil, ErrTooManyMerchantRules
+	}
+	return rules, nil
+}
+
+func merchantRuleInputs(rules []model.MerchantRule, list model.MerchantList) []MerchantRuleInput {
+	inputs := []MerchantRuleInput{}
+	for _, rule := range rules {
+		if rule.List == list {
+			inputs = append(inputs, MerchantRuleInput{MerchantID: rule.MerchantID, NamePattern: rule.NamePattern})
+		}
+	}
+	return inputs
+}
+
+// equalMerchantRules compares two de-duplicated lists, ignoring order
+func equalMerchantRules(a, b []MerchantRuleInput) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	inA := make(map[MerchantRuleInput]bool, len(a))
+	for _, rule := range a {
+		inA[rule] = true
+	}
+	for _, rule := range b {
+		if !inA[rule] {
+			return false
+		}
+	}
+	return true
+}
+
+func merchantListControl(list model.MerchantList) string {
+	if list == model.MerchantAllowlist {
+		return ControlMerchantAllowlist
+	}
+	return ControlMerchantDenylist
+}
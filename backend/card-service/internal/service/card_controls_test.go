@@ -0,0 +1,199 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryCardControls is an in-memory CardControlRepository
+type memoryCardControls struct {
+	rules   []model.MerchantRule
+	changes []model.CardControlChange
+	cards   map[uuid.UUID]model.Card
+}
+
+func newMemoryCardControls() *memoryCardControls {
+	return &memoryCardControls{cards: map[uuid.UUID]model.Card{}}
+}
+
+func (m *memoryCardControls) ListMerchantRules(cardID uuid.UUID) ([]model.MerchantRule, error) {
+	var out []model.MerchantRule
+	for _, r := range m.rules {
+		if r.CardID == cardID {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryCardControls) ReplaceMerchantRules(cardID uuid.UUID, list model.MerchantList, rules []model.MerchantRule, change *model.CardControlChange) error {
+	var kept []model.MerchantRule
+	for _, r := range m.rules {
+		if r.CardID != cardID || r.List != list {
+			kept = append(kept, r)
+		}
+	}
+	for _, r := range rules {
+		r.ID = uuid.New()
+		kept = append(kept, r)
+	}
+	m.rules = kept
+	m.changes = append(m.changes, *change)
+	return nil
+}
+
+func (m *memoryCardControls) UpdateCardControls(card *model.Card, change *model.CardControlChange) error {
+	m.cards[card.ID] = *card
+	m.changes = append(m.changes, *change)
+	return nil
+}
+
+func (m *memoryCardControls) ListCardControlChanges(cardID uuid.UUID) ([]model.CardControlChange, error) {
+	var out []model.CardControlChange
+	for i := len(m.changes) - 1; i >= 0; i-- {
+		if m.changes[i].CardID == cardID {
+			out = append(out, m.changes[i])
+		}
+	}
+	return out, nil
+}
+
+func newControlsTestService() (*CardService, *memoryCardControls, *model.Card) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	controls := newMemoryCardControls()
+	svc.SetCardControls(controls)
+	card := newTestCard(uuid.New())
+	card.DailyLimit = decimal.NewFromInt(1000)
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	return svc, controls, card
+}
+
+func rulesOf(inputs ...MerchantRuleInput) *[]MerchantRuleInput {
+	return &inputs
+}
+
+func TestUpdateCardControls_ValidatesMerchantRules(t *testing.T) {
+	svc, controls, card := newControlsTestService()
+	userID, cardID := card.UserID.String(), card.ID.String()
+	card.GeoBlocking = true
+	disabled := false
+
+	tests := []struct {
+		name  string
+		rules *[]MerchantRuleInput
+		want  error
+	}{
+		{"neither id nor pattern", rulesOf(MerchantRuleInput{}), ErrInvalidMerchantRule},
+		{"both id and pattern", rulesOf(MerchantRuleInput{MerchantID: "m1", NamePattern: "shop"}), ErrInvalidMerchantRule},
+		{"only wildcards", rulesOf(MerchantRuleInput{NamePattern: " ** "}), ErrInvalidNamePattern},
+	}
+	for _, tt := range tests {
+		_, err := svc.UpdateCardControls(userID, cardID, CardControlsUpdate{GeoBlocking: &disabled, MerchantDenylist: tt.rules})
+		assert.ErrorIs(t, err, tt.want, tt.name)
+	}
+
+	tooMany := make([]MerchantRuleInput, MaxMerchantRules+1)
+	for i := range tooMany {
+		tooMany[i] = MerchantRuleInput{MerchantID: uuid.NewString()}
+	}
+	_, err := svc.UpdateCardControls(userID, cardID, CardControlsUpdate{MerchantAllowlist: &tooMany})
+	assert.ErrorIs(t, err, ErrTooManyMerchantRules)
+
+	// Invalid rules are rejected before any control is written
+	assert.Empty(t, controls.changes)
+	assert.True(t, card.GeoBlocking)
+
+	_, err = NewCardService(new(MockCardRepository)).UpdateCardControls(userID, cardID, CardControlsUpdate{MerchantDenylist: rulesOf()})
+	assert.ErrorIs(t, err, ErrCardControlsDisabled)
+}
+
+func TestUpdateCardControls_RecordsChanges(t *testing.T) {
+	svc, controls, card := newControlsTestService()
+	userID, cardID := card.UserID.String(), card.ID.String()
+	card.GeoBlocking = true
+	disabled := false
+
+	updated, err := svc.UpdateCardControls(userID, cardID, CardControlsUpdate{
+		GeoBlocking:      &disabled,
+		MerchantDenylist: rulesOf(MerchantRuleInput{NamePattern: " *Casino* "}, MerchantRuleInput{NamePattern: "*casino*"}),
+	})
+	require.NoError(t, err)
+	assert.False(t, updated.GeoBlocking)
+	require.Len(t, updated.MerchantDenylist, 1, "duplicates are dropped")
+	assert.Equal(t, "*casino*", updated.MerchantDenylist[0].NamePattern)
+	assert.Empty(t, updated.MerchantAllowlist)
+
+	// The same list again is not a change
+	_, err = svc.UpdateCardControls(userID, cardID, CardControlsUpdate{MerchantDenylist: rulesOf(MerchantRuleInput{NamePattern: "*CASINO*"})})
+	require.NoError(t, err)
+	_, err = svc.UpdateCardControls(userID, cardID, CardControlsUpdate{MerchantDenylist: rulesOf()})
+	require.NoError(t, err)
+
+	changes, err := svc.ListCardControlChanges(userID, cardID)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	assert.Equal(t, ControlMerchantDenylist, changes[0].Control)
+	assert.Equal(t, []MerchantRuleInput{}, changes[0].NewValue)
+	assert.Equal(t, ControlMerchantDenylist, changes[1].Control)
+	assert.Equal(t, []MerchantRuleInput{}, changes[1].OldValue)
+	assert.Equal(t, ControlGeoBlocking, changes[2].Control)
+	assert.Equal(t, true, changes[2].OldValue)
+	assert.Equal(t, card.UserID, changes[2].ChangedBy)
+	assert.Empty(t, controls.rules)
+}
+
+func TestAuthorizeWithToken_MerchantLists(t *testing.T) {
+	number := "9123456789012347"
+	encrypted, err := encryptCardNumber(number)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		allowlist *[]MerchantRuleInput
+		denylist  *[]MerchantRuleInput
+		merchant  Merchant
+		want      DeclineReason
+	}{
+		{"no lists", nil, nil, Merchant{ID: "m1", Name: "Corner Shop"}, ""},
+		{"denied by id", nil, rulesOf(MerchantRuleInput{MerchantID: "m1"}), Merchant{ID: "m1", Name: "Corner Shop"}, DeclineMerchantDenylisted},
+		{"denied by pattern", nil, rulesOf(MerchantRuleInput{NamePattern: "*casino*"}), Merchant{Name: "Royal CASINO Online"}, DeclineMerchantDenylisted},
+		{"pattern must match the whole name", nil, rulesOf(MerchantRuleInput{NamePattern: "bet*"}), Merchant{Name: "Alphabet Books"}, ""},
+		{"allowlisted", rulesOf(MerchantRuleInput{NamePattern: "tesco*"}), nil, Merchant{Name: "Tesco Express"}, ""},
+		{"not allowlisted", rulesOf(MerchantRuleInput{MerchantID: "m2"}), nil, Merchant{ID: "m1"}, DeclineMerchantNotAllowlisted},
+		{"allowlist with unknown merchant", rulesOf(MerchantRuleInput{MerchantID: "m2"}), nil, Merchant{}, DeclineMerchantNotAllowlisted},
+		{"denylist wins", rulesOf(MerchantRuleInput{MerchantID: "m1"}), rulesOf(MerchantRuleInput{NamePattern: "corner*"}), Merchant{ID: "m1", Name: "Corner Shop"}, DeclineMerchantDenylisted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _, card := newControlsTestService()
+			_, err := svc.UpdateCardControls(card.UserID.String(), card.ID.String(), CardControlsUpdate{
+				MerchantAllowlist: tt.allowlist,
+				MerchantDenylist:  tt.denylist,
+			})
+			require.NoError(t, err)
+			svc.Repo.(*MockCardRepository).On("GetNetworkTokenByHash", mock.Anything).Return(&model.NetworkToken{
+				ID:             uuid.New(),
+				CardID:         card.ID,
+				EncryptedToken: encrypted,
+				DeviceID:       "iphone-1",
+				Status:         model.NetworkTokenActive,
+				ExpiresAt:      time.Now().Add(time.Hour),
+			}, nil)
+
+			result, err := svc.AuthorizeWithToken(number, "iphone-1", decimal.NewFromInt(50), tt.merchant)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result.DeclineReason)
+			assert.Equal(t, tt.want == "", result.Approved)
+		})
+	}
+}
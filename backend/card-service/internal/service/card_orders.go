@@ -0,0 +1,331 @@
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxActivationAttempts is how many wrong last-4 or expiry entries lock the
+// activation of a card; the cardholder then has to contact support
+const MaxActivationAttempts = 5
+
+// Notifications sent as a physical card order moves through fulfillment
+const (
+	CardOrderedTemplate   = "card_ordered"
+	CardPrintedTemplate   = "card_printed"
+	CardShippedTemplate   = "card_shipped"
+	CardDeliveredTemplate = "card_delivered"
+	CardActivatedTemplate = "card_activated"
+)
+
+var cardOrderTemplates = map[model.CardOrderStatus]string{
+	model.CardOrderOrdered:   CardOrderedTemplate,
+	model.CardOrderPrinted:   CardPrintedTemplate,
+	model.CardOrderShipped:   CardShippedTemplate,
+	model.CardOrderDelivered: CardDeliveredTemplate,
+	model.CardOrderActivated: CardActivatedTemplate,
+}
+
+var (
+	ErrCardOrdersDisabled       = errors.New("physical card orders are not configured")
+	ErrCardOrderNotFound        = errors.New("card order not found")
+	ErrInvalidFulfillmentStatus = errors.New("status must be PRINTED, SHIPPED or DELIVERED")
+	ErrCardNotShipped           = errors.New("the card can be activated once it has shipped")
+	ErrCardAlreadyActivated     = errors.New("card is already activated")
+	ErrCardNotActivatable       = errors.New("card can no longer be activated")
+	ErrActivationLocked         = errors.New("card activation is locked after too many failed attempts; contact support")
+	ErrActivationMismatch       = errors.New("last4 or expiry does not match the card")
+)
+
+// CardOrderRepository stores physical card orders. Status changes are
+// conditional updates committed with their notifications, so a webhook
+// delivered twice advances an order once.
+type CardOrderRepository interface {
+	CreateCardOrder(card *model.Card, order *model.CardOrder, events []model.OutboxEvent) error
+	GetCardOrder(id uuid.UUID) (*model.CardOrder, error)
+	GetCardOrderByCard(cardID uuid.UUID) (*model.CardOrder, error)
+	// AdvanceCardOrder saves the order if it is still in status from
+	AdvanceCardOrder(order *model.CardOrder, from model.CardOrderStatus, events []model.OutboxEvent) (bool, error)
+	// RecordActivationFailure counts a failed attempt unless the order is
+	// already locked, and returns false if it was
+	RecordActivationFailure(orderID uuid.UUID, maxAttempts int) (bool, error)
+	// ActivateCardOrder marks a shipped or delivered order that is not locked
+	// ACTIVATED and its INACTIVE card ACTIVE, and returns false if either had changed
+	ActivateCardOrder(order *model.CardOrder, maxAttempts int, events []model.OutboxEvent) (bool, error)
+	ListCardOrdersByStatus(status model.CardOrderStatus, updatedBefore time.Time, limit int) ([]model.CardOrder, error)
+}
+
+// SetCardOrders enables ordering physical cards
+func (s *CardService) SetCardOrders(repo CardOrderRepository) {
+	s.cardOrders = repo
+}
+
+// CardOrderResult is a physical card with its order
+type CardOrderResult struct {
+	Card  *model.Card      `json:"card"`
+	Order *model.CardOrder `json:"order"`
+}
+
+// OrderCard issues a physical card on an account the user owns. The card
+// stays INACTIVE until it has shipped and the cardholder activates it.
+func (s *CardService) OrderCard(userID, accountID string) (*CardOrderResult, error) {
+	if s.cardOrders == nil {
+		return nil, ErrCardOrdersDisabled
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	accUUID, err := uuid.Parse(accountID)
+	if err != nil {
+		return nil, ErrInvalidAccountID
+	}
+	ownsAccount, err := s.Repo.VerifyAccountOwnership(userUUID, accUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify account ownership: %w", err)
+	}
+	if !ownsAccount {
+		return nil, ErrUnauthorized
+	}
+
+	card, err := newCard(userUUID, accUUID)
+	if err != nil {
+		return nil, err
+	}
+	card.ID = uuid.New()
+	card.Status = model.CardInactive
+	order := &model.CardOrder{
+		ID:     uuid.New(),
+		CardID: card.ID,
+		UserID: userUUID,
+		Status: model.CardOrderOrdered,
+	}
+
+	event, err := cardOrderEvent(order, card, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cardOrders.CreateCardOrder(card, order, []model.OutboxEvent{event}); err != nil {
+		return nil, err
+	}
+	slog.Info("Physical card ordered", "card_id", card.ID, "order_id", order.ID, "account_id", accountID)
+	return &CardOrderResult{Card: card, Order: order}, nil
+}
+
+// GetCardOrder returns the order of a physical card owned by the user
+func (s *CardService) GetCardOrder(userID, cardID string) (*model.CardOrder, error) {
+	if s.cardOrders == nil {
+		return nil, ErrCardOrdersDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	return s.cardOrderOf(card.ID)
+}
+
+func (s *CardService) cardOrderOf(cardID uuid.UUID) (*model.CardOrder, error) {
+	order, err := s.cardOrders.GetCardOrderByCard(cardID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCardOrderNotFound
+	}
+	return order, err
+}
+
+// FulfillmentEvent is a status update from the card fulfillment partner
+type FulfillmentEvent struct {
+	OrderID        string
+	Status         model.CardOrderStatus
+	Carrier        string
+	TrackingNumber string
+	// OccurredAt is when the step happened; the time of receipt when zero
+	OccurredAt time.Time
+}
+
+// HandleFulfillmentEvent moves an order forward to the reported status and
+// notifies the cardholder. Steps may be skipped when an update was lost, but
+// an order never moves back: an update for the current or an earlier step is
+// a redelivery and returns the order unchanged.
+func (s *CardService) HandleFulfillmentEvent(e FulfillmentEvent, now time.Time) (*model.CardOrder, error) {
+	if s.cardOrders == nil {
+		return nil, ErrCardOrdersDisabled
+	}
+	switch e.Status {
+	case model.CardOrderPrinted, model.CardOrderShipped, model.CardOrderDelivered:
+	default:
+		return nil, ErrInvalidFulfillmentStatus
+	}
+	orderID, err := uuid.Parse(e.OrderID)
+	if err != nil {
+		return nil, ErrCardOrderNotFound
+	}
+	order, err := s.cardOrders.GetCardOrder(orderID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCardOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.Status.Step() >= e.Status.Step() {
+		return order, nil
+	}
+
+	at := e.OccurredAt
+	if at.IsZero() {
+		at = now
+	}
+	from := order.Status
+	order.Status = e.Status
+	switch e.Status {
+	case model.CardOrderPrinted:
+		order.PrintedAt = &at
+	case model.CardOrderShipped:
+		order.ShippedAt = &at
+	case model.CardOrderDelivered:
+		order.DeliveredAt = &at
+	}
+	if e.Carrier != "" {
+		order.Carrier = e.Carrier
+	}
+	if e.TrackingNumber != "" {
+		order.TrackingNumber = e.TrackingNumber
+	}
+
+	card, err := s.Repo.GetCardByID(order.CardID)
+	if err != nil {
+		return nil, err
+	}
+	event, err := cardOrderEvent(order, card, now)
+	if err != nil {
+		return nil, err
+	}
+	advanced, err := s.cardOrders.AdvanceCardOrder(order, from, []model.OutboxEvent{event})
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		// A concurrent delivery got there first
+		return s.cardOrders.GetCardOrder(orderID)
+	}
+	slog.Info("Card order advanced", "order_id", order.ID, "card_id", order.CardID, "from", from, "to", order.Status)
+	return order, nil
+}
+
+// ActivateCard activates a physical card that has shipped once the cardholder
+// proves they hold it by entering the last four digits of its number and its
+// MM/YY expiry. Wrong entries are counted and lock activation at
+// MaxActivationAttempts.
+func (s *CardService) ActivateCard(userID, cardID, last4, expiry string) (*CardOrderResult, error) {
+	if s.cardOrders == nil {
+		return nil, ErrCardOrdersDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.cardOrderOf(card.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := activationAllowed(order); err != nil {
+		return nil, err
+	}
+
+	cardLast4 := card.MaskedCardNumber[max(len(card.MaskedCardNumber)-4, 0):]
+	last4Match := subtle.ConstantTimeCompare([]byte(strings.TrimSpace(last4)), []byte(cardLast4))
+	expiryMatch := subtle.ConstantTimeCompare([]byte(strings.TrimSpace(expiry)), []byte(card.ExpirationDate))
+	if last4Match&expiryMatch != 1 {
+		counted, err := s.cardOrders.RecordActivationFailure(order.ID, MaxActivationAttempts)
+		if err != nil {
+			return nil, err
+		}
+		if !counted {
+			return nil, ErrActivationLocked
+		}
+		slog.Warn("Card activation failed", "card_id", card.ID, "order_id", order.ID)
+		return nil, ErrActivationMismatch
+	}
+
+	now := time.Now()
+	order.Status = model.CardOrderActivated
+	order.ActivatedAt = &now
+	event, err := cardOrderEvent(order, card, now)
+	if err != nil {
+		return nil, err
+	}
+	activated, err := s.cardOrders.ActivateCardOrder(order, MaxActivationAttempts, []model.OutboxEvent{event})
+	if err != nil {
+		return nil, err
+	}
+	if !activated {
+		// The order or card changed since they were read, e.g. the card was
+		// replaced while in the post
+		current, err := s.cardOrderOf(card.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := activationAllowed(current); err != nil {
+			return nil, err
+		}
+		return nil, ErrCardNotActivatable
+	}
+	card.Status = model.CardActive
+	return &CardOrderResult{Card: card, Order: order}, nil
+}
+
+func activationAllowed(order *model.CardOrder) error {
+	switch {
+	case order.Status == model.CardOrderActivated:
+		return ErrCardAlreadyActivated
+	case order.Status.Step() < model.CardOrderShipped.Step():
+		return ErrCardNotShipped
+	case order.ActivationFailedAttempts >= MaxActivationAttempts:
+		return ErrActivationLocked
+	}
+	return nil
+}
+
+// cardOrderEvent builds the cardholder's notification for the order's current
+// status, keyed by user like the other card events
+func cardOrderEvent(order *model.CardOrder, card *model.Card, at time.Time) (model.OutboxEvent, error) {
+	data := map[string]string{
+		"order_id":           order.ID.String(),
+		"card_id":            card.ID.String(),
+		"masked_card_number": card.MaskedCardNumber,
+		"status":             string(order.Status),
+	}
+	if order.Carrier != "" {
+		data["carrier"] = order.Carrier
+	}
+	if order.TrackingNumber != "" {
+		data["tracking_number"] = order.TrackingNumber
+	}
+	// The card service does not hold contact details, so the recipient is left
+	// for the notification pipeline to look up from the user ID
+	payload, err := json.Marshal(kafka.NotificationEvent{
+		UserID:    order.UserID.String(),
+		Channel:   "EMAIL",
+		Template:  cardOrderTemplates[order.Status],
+		Data:      data,
+		Timestamp: at.Format(time.RFC3339),
+	})
+	if err != nil {
+		return model.OutboxEvent{}, err
+	}
+	return model.OutboxEvent{
+		Topic:     kafka.TopicNotificationEmail,
+		Key:       order.UserID.String(),
+		Payload:   string(payload),
+		CreatedAt: at,
+	}, nil
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryCardOrders is an in-memory CardOrderRepository with the same
+// conditional updates as the database one
+type memoryCardOrders struct {
+	cards  map[uuid.UUID]*model.Card
+	orders map[uuid.UUID]*model.CardOrder
+	events []model.OutboxEvent
+}
+
+func newMemoryCardOrders() *memoryCardOrders {
+	return &memoryCardOrders{cards: map[uuid.UUID]*model.Card{}, orders: map[uuid.UUID]*model.CardOrder{}}
+}
+
+func (m *memoryCardOrders) CreateCardOrder(card *model.Card, order *model.CardOrder, events []model.OutboxEvent) error {
+	m.cards[card.ID] = card
+	stored := *order
+	m.orders[order.ID] = &stored
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func (m *memoryCardOrders) GetCardOrder(id uuid.UUID) (*model.CardOrder, error) {
+	order, ok := m.orders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *order
+	return &found, nil
+}
+
+func (m *memoryCardOrders) GetCardOrderByCard(cardID uuid.UUID) (*model.CardOrder, error) {
+	for _, order := range m.orders {
+		if order.CardID == cardID {
+			found := *order
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryCardOrders) AdvanceCardOrder(order *model.CardOrder, from model.CardOrderStatus, events []model.OutboxEvent) (bool, error) {
+	if m.orders[order.ID].Status != from {
+		return false, nil
+	}
+	stored := *order
+	m.orders[order.ID] = &stored
+	m.events = append(m.events, events...)
+	return true, nil
+}
+
+func (m *memoryCardOrders) RecordActivationFailure(orderID uuid.UUID, maxAttempts int) (bool, error) {
+	order := m.orders[orderID]
+	if order.ActivationFailedAttempts >= maxAttempts {
+		return false, nil
+	}
+	order.ActivationFailedAttempts++
+	return true, nil
+}
+
+func (m *memoryCardOrders) ActivateCardOrder(order *model.CardOrder, maxAttempts int, events []model.OutboxEvent) (bool, error) {
+	stored, card := m.orders[order.ID], m.cards[order.CardID]
+	if (stored.Status != model.CardOrderShipped && stored.Status != model.CardOrderDelivered) ||
+		stored.ActivationFailedAttempts >= maxAttempts || card.Status != model.CardInactive {
+		return false, nil
+	}
+	stored.Status = model.CardOrderActivated
+	stored.ActivatedAt = order.ActivatedAt
+	card.Status = model.CardActive
+	m.events = append(m.events, events...)
+	return true, nil
+}
+
+func (m *memoryCardOrders) ListCardOrdersByStatus(status model.CardOrderStatus, updatedBefore time.Time, limit int) ([]model.CardOrder, error) {
+	var out []model.CardOrder
+	for _, order := range m.orders {
+		if order.Status == status && order.UpdatedAt.Before(updatedBefore) {
+			out = append(out, *order)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryCardOrders) templates(t *testing.T) []string {
+	t.Helper()
+	var out []string
+	for _, e := range m.events {
+		assert.Equal(t, kafka.TopicNotificationEmail, e.Topic)
+		var n kafka.NotificationEvent
+		require.NoError(t, json.Unmarshal([]byte(e.Payload), &n))
+		out = append(out, n.Template)
+	}
+	return out
+}
+
+// orderCardRepo looks cards up among those created with their orders
+type orderCardRepo struct {
+	*MockCardRepository
+	orders *memoryCardOrders
+}
+
+func (r orderCardRepo) GetCardByID(id uuid.UUID) (*model.Card, error) {
+	card, ok := r.orders.cards[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *card
+	return &found, nil
+}
+
+// orderedCard orders a physical card through a service backed by the
+// in-memory repository
+func orderedCard(t *testing.T) (*CardService, *memoryCardOrders, *CardOrderResult) {
+	t.Helper()
+	repo := new(MockCardRepository)
+	repo.On("VerifyAccountOwnership", mock.Anything, mock.Anything).Return(true, nil)
+	orders := newMemoryCardOrders()
+	svc := NewCardService(orderCardRepo{MockCardRepository: repo, orders: orders})
+	svc.SetCardOrders(orders)
+
+	result, err := svc.OrderCard(uuid.NewString(), uuid.NewString())
+	require.NoError(t, err)
+	return svc, orders, result
+}
+
+func advance(t *testing.T, svc *CardService, orderID uuid.UUID, status model.CardOrderStatus) *model.CardOrder {
+	t.Helper()
+	order, err := svc.HandleFulfillmentEvent(FulfillmentEvent{OrderID: orderID.String(), Status: status}, time.Now())
+	require.NoError(t, err)
+	return order
+}
+
+func TestOrderCard(t *testing.T) {
+	_, err := NewCardService(new(MockCardRepository)).OrderCard(uuid.NewString(), uuid.NewString())
+	assert.ErrorIs(t, err, ErrCardOrdersDisabled)
+
+	_, orders, result := orderedCard(t)
+	assert.Equal(t, model.CardInactive, result.Card.Status)
+	assert.Equal(t, model.CardOrderOrdered, result.Order.Status)
+	assert.Equal(t, result.Card.ID, result.Order.CardID)
+	assert.Equal(t, []string{CardOrderedTemplate}, orders.templates(t))
+}
+
+func TestHandleFulfillmentEvent(t *testing.T) {
+	svc, orders, result := orderedCard(t)
+	orderID := result.Order.ID
+
+	_, err := svc.HandleFulfillmentEvent(FulfillmentEvent{OrderID: orderID.String(), Status: model.CardOrderActivated}, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidFulfillmentStatus)
+	_, err = svc.HandleFulfillmentEvent(FulfillmentEvent{OrderID: uuid.NewString(), Status: model.CardOrderPrinted}, time.Now())
+	assert.ErrorIs(t, err, ErrCardOrderNotFound)
+
+	printed := advance(t, svc, orderID, model.CardOrderPrinted)
+	assert.Equal(t, model.CardOrderPrinted, printed.Status)
+	require.NotNil(t, printed.PrintedAt)
+
+	shippedAt := time.Now().Add(-time.Hour)
+	shipped, err := svc.HandleFulfillmentEvent(FulfillmentEvent{
+		OrderID:        orderID.String(),
+		Status:         model.CardOrderShipped,
+		Carrier:        "Royal Mail",
+		TrackingNumber: "RM123456789GB",
+		OccurredAt:     shippedAt,
+	}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "RM123456789GB", shipped.TrackingNumber)
+	assert.True(t, shipped.ShippedAt.Equal(shippedAt))
+
+	// A redelivered or late update leaves the order and sends nothing
+	again := advance(t, svc, orderID, model.CardOrderPrinted)
+	assert.Equal(t, model.CardOrderShipped, again.Status)
+	assert.Equal(t, []string{CardOrderedTemplate, CardPrintedTemplate, CardShippedTemplate}, orders.templates(t))
+
+	delivered := advance(t, svc, orderID, model.CardOrderDelivered)
+	assert.Equal(t, model.CardOrderDelivered, delivered.Status)
+	assert.Equal(t, "Royal Mail", delivered.Carrier)
+}
+
+func TestHandleFulfillmentEvent_SkipsLostSteps(t *testing.T) {
+	svc, orders, result := orderedCard(t)
+
+	shipped := advance(t, svc, result.Order.ID, model.CardOrderShipped)
+	assert.Equal(t, model.CardOrderShipped, shipped.Status)
+	assert.Nil(t, shipped.PrintedAt)
+	assert.Equal(t, []string{CardOrderedTemplate, CardShippedTemplate}, orders.templates(t))
+}
+
+func TestActivateCard(t *testing.T) {
+	svc, orders, result := orderedCard(t)
+	userID, cardID := result.Card.UserID.String(), result.Card.ID.String()
+	last4 := result.Card.MaskedCardNumber[len(result.Card.MaskedCardNumber)-4:]
+
+	_, err := svc.ActivateCard(userID, cardID, last4, result.Card.ExpirationDate)
+	assert.ErrorIs(t, err, ErrCardNotShipped)
+
+	advance(t, svc, result.Order.ID, model.CardOrderShipped)
+	_, err = svc.ActivateCard(userID, cardID, last4, "01/99")
+	assert.ErrorIs(t, err, ErrActivationMismatch)
+	assert.Equal(t, 1, orders.orders[result.Order.ID].ActivationFailedAttempts)
+	_, err = svc.ActivateCard(uuid.NewString(), cardID, last4, result.Card.ExpirationDate)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	activated, err := svc.ActivateCard(userID, cardID, last4, result.Card.ExpirationDate)
+	require.NoError(t, err)
+	assert.Equal(t, model.CardActive, activated.Card.Status)
+	assert.Equal(t, model.CardOrderActivated, activated.Order.Status)
+	assert.Equal(t, model.CardActive, orders.cards[result.Card.ID].Status)
+	assert.Equal(t, []string{CardOrderedTemplate, CardShippedTemplate, CardActivatedTemplate}, orders.templates(t))
+
+	_, err = svc.ActivateCard(userID, cardID, last4, result.Card.ExpirationDate)
+	assert.ErrorIs(t, err, ErrCardAlreadyActivated)
+}
+
+func TestActivateCard_LocksAfterTooManyAttempts(t *testing.T) {
+	svc, orders, result := orderedCard(t)
+	userID, cardID := result.Card.UserID.String(), result.Card.ID.String()
+	advance(t, svc, result.Order.ID, model.CardOrderDelivered)
+
+	for i := 0; i < MaxActivationAttempts; i++ {
+		_, err := svc.ActivateCard(userID, cardID, "0000", "01/99")
+		assert.ErrorIs(t, err, ErrActivationMismatch)
+	}
+	last4 := result.Card.MaskedCardNumber[len(result.Card.MaskedCardNumber)-4:]
+	_, err := svc.ActivateCard(userID, cardID, last4, result.Card.ExpirationDate)
+	assert.ErrorIs(t, err, ErrActivationLocked)
+	assert.Equal(t, model.CardInactive, orders.cards[result.Card.ID].Status)
+}
+
+func TestActivateCard_CardNoLongerInactive(t *testing.T) {
+	svc, orders, result := orderedCard(t)
+	advance(t, svc, result.Order.ID, model.CardOrderShipped)
+	// Blocked as lost while in the post
+	orders.cards[result.Card.ID].Status = model.CardBlocked
+
+	last4 := result.Card.MaskedCardNumber[len(result.Card.MaskedCardNumber)-4:]
+	_, err := svc.ActivateCard(result.Card.UserID.String(), result.Card.ID.String(), last4, result.Card.ExpirationDate)
+	assert.ErrorIs(t, err, ErrCardNotActivatable)
+}
+
+func TestFulfillmentSimulatorJob(t *testing.T) {
+	svc, orders, result := orderedCard(t)
+	secret := []byte("fulfillment-secret")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/webhooks/card-fulfillment", middleware.VerifySignature(middleware.DefaultSignatureConfig(map[string][]byte{"card-fulfillment": secret})), func(c *gin.Context) {
+		var payload FulfillmentWebhookPayload
+		require.NoError(t, c.ShouldBindJSON(&payload))
+		_, err := svc.HandleFulfillmentEvent(FulfillmentEvent{
+			OrderID:        payload.OrderID,
+			Status:         model.CardOrderStatus(payload.Status),
+			Carrier:        payload.Carrier,
+			TrackingNumber: payload.TrackingNumber,
+			OccurredAt:     payload.OccurredAt,
+		}, time.Now())
+		require.NoError(t, err)
+		c.Status(http.StatusOK)
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	sim := NewFulfillmentSimulator(server.URL+"/webhooks/card-fulfillment", "card-fulfillment", secret, 0)
+	job := svc.FulfillmentSimulatorJob(sim)
+	for _, want := range []model.CardOrderStatus{model.CardOrderPrinted, model.CardOrderShipped, model.CardOrderDelivered, model.CardOrderDelivered} {
+		require.NoError(t, job(context.Background(), nil))
+		assert.Equal(t, want, orders.orders[result.Order.ID].Status)
+	}
+	assert.Equal(t, simulatedCarrier, orders.orders[result.Order.ID].Carrier)
+
+	// Requests signed with another secret are turned away
+	sim = NewFulfillmentSimulator(server.URL+"/webhooks/card-fulfillment", "card-fulfillment", []byte("wrong"), 0)
+	orders.orders[result.Order.ID].Status = model.CardOrderOrdered
+	assert.Error(t, svc.FulfillmentSimulatorJob(sim)(context.Background(), nil))
+}
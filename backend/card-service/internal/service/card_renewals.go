@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+const (
+	// CardRenewalWindow is how long before expiry a card is renewed, leaving
+	// time for the new card to reach the customer
+	CardRenewalWindow = 60 * 24 * time.Hour
+	// CardRenewalTemplate is the notification sent when a card is renewed
+	CardRenewalTemplate = "card_renewal"
+	// renewalBatchSize caps how many cards one query of the renewal job loads
+	renewalBatchSize = 100
+)
+
+var ErrRenewalsDisabled = errors.New("card renewals are not configured")
+
+// RenewalRepository finds cards nearing expiry and records their renewal
+type RenewalRepository interface {
+	ListCardsDueForRenewal(expiringBefore time.Time, limit int) ([]model.Card, error)
+	RenewCard(old, renewal *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, notices []model.TravelNotice, events []model.OutboxEvent) (bool, error)
+	ListCardsDueForDeactivation(now time.Time, limit int) ([]model.Card, error)
+	ExpireCard(card *model.Card, events []model.OutboxEvent) (bool, error)
+}
+
+// SetRenewals enables the renewal job
+func (s *CardService) SetRenewals(repo RenewalRepository) {
+	s.renewals = repo
+}
+
+// RenewCard issues a replacement for a card nearing expiry. Unlike a
+// replacement for a lost card, the old card keeps working until it expires
+// and is then deactivated. The renewal keeps the card's daily limit,
+// geo-blocking setting and PIN, the wallet tokens move across so they keep
+// working, and travel notices that have not ended are copied. The card.issued
+// event and the customer's notification are committed with the renewal. It
+// returns false if the card had already been replaced.
+func (s *CardService) RenewCard(old *model.Card, now time.Time) (bool, error) {
+	if s.renewals == nil {
+		return false, ErrRenewalsDisabled
+	}
+
+	renewal, err := newCard(old.UserID, old.AccountID)
+	if err != nil {
+		return false, err
+	}
+	renewal.ID = uuid.New()
+	renewal.ReplacesCardID = &old.ID
+	renewal.DailyLimit = old.DailyLimit
+	renewal.GeoBlocking = old.GeoBlocking
+	renewal.Delegate, renewal.ApprovedBy, renewal.ApprovedAt = old.Delegate, old.ApprovedBy, old.ApprovedAt
+	renewal.PinHash = old.PinHash
+	renewal.PinUpdatedAt = old.PinUpdatedAt
+
+	old.ReplacedByCardID = &renewal.ID
+	deactivatesAt := old.ExpiresAt
+	old.DeactivatesAt = &deactivatesAt
+
+	tokens, err := s.Repo.ListNetworkTokensByCard(old.ID)
+	if err != nil {
+		return false, err
+	}
+	var moved []model.NetworkToken
+	for _, t := range tokens {
+		if t.Status == model.NetworkTokenActive {
+			t.CardID = renewal.ID
+			moved = append(moved, t)
+		}
+	}
+
+	var notices []model.TravelNotice
+	if s.travelNotices != nil {
+		existing, err := s.travelNotices.ListTravelNoticesByCard(old.ID)
+		if err != nil {
+			return false, err
+		}
+		today := now.UTC().Format(time.DateOnly)
+		for _, n := range existing {
+			if n.CancelledAt != nil || n.EndDate.UTC().Format(time.DateOnly) < today {
+				continue
+			}
+			notices = append(notices, model.TravelNotice{
+				ID:        uuid.New(),
+				CardID:    renewal.ID,
+				UserID:    n.UserID,
+				Countries: n.Countries,
+				StartDate: n.StartDate,
+				EndDate:   n.EndDate,
+			})
+		}
+	}
+
+	record := &model.CardReplacement{
+		OldCardID:   old.ID,
+		NewCardID:   renewal.ID,
+		UserID:      old.UserID,
+		Reason:      model.ReplacementExpiry,
+		TokensMoved: len(moved),
+	}
+
+	events, err := renewalEvents(old, renewal, now)
+	if err != nil {
+		return false, err
+	}
+	return s.renewals.RenewCard(old, renewal, record, moved, notices, events)
+}
+
+// renewalEvents builds the card.issued event and the customer notification for
+// a renewal, keyed by user like the replacement events
+func renewalEvents(old, renewal *model.Card, at time.Time) ([]model.OutboxEvent, error) {
+	issued := kafka.CardEvent{
+		CardID:           renewal.ID.String(),
+		UserID:           renewal.UserID.String(),
+		AccountID:        renewal.AccountID.String(),
+		MaskedCardNumber: renewal.MaskedCardNumber,
+		Status:           string(renewal.Status),
+		Reason:           string(model.ReplacementExpiry),
+		ReplacesCardID:   old.ID.String(),
+		Timestamp:        at.Format(time.RFC3339),
+	}
+	// The card service does not hold contact details, so the recipient is left
+	// for the notification pipeline to look up from the user ID
+	notification := kafka.NotificationEvent{
+		UserID:   old.UserID.String(),
+		Channel:  "EMAIL",
+		Template: CardRenewalTemplate,
+		Data: map[string]string{
+			"card_id":             renewal.ID.String(),
+			"masked_card_number":  renewal.MaskedCardNumber,
+			"expiration_date":     renewal.ExpirationDate,
+			"old_card_number":     old.MaskedCardNumber,
+			"old_card_expires_at": old.ExpiresAt.Format(time.RFC3339),
+		},
+		Timestamp: at.Format(time.RFC3339),
+	}
+
+	events := make([]model.OutboxEvent, 0, 2)
+	for i, e := range []struct {
+		topic string
+		event interface{}
+	}{{kafka.TopicCardIssued, issued}, {kafka.TopicNotificationEmail, notification}} {
+		payload, err := json.Marshal(e.event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, model.OutboxEvent{
+			Topic:     e.topic,
+			Key:       old.UserID.String(),
+			Payload:   string(payload),
+			CreatedAt: at.Add(time.Duration(i) * time.Microsecond),
+		})
+	}
+	return events, nil
+}
+
+// ExpireRenewedCard deactivates a renewed card once its expiry has passed. It
+// returns false if the card had already been expired.
+func (s *CardService) ExpireRenewedCard(card *model.Card, now time.Time) (bool, error) {
+	if s.renewals == nil {
+		return false, ErrRenewalsDisabled
+	}
+	event := kafka.CardEvent{
+		CardID:           card.ID.String(),
+		UserID:           card.UserID.String(),
+		AccountID:        card.AccountID.String(),
+		MaskedCardNumber: card.MaskedCardNumber,
+		Status:           string(model.CardExpired),
+		ReplacedByCardID: uuidString(card.ReplacedByCardID),
+		Timestamp:        now.Format(time.RFC3339),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return false, err
+	}
+	expired, err := s.renewals.ExpireCard(card, []model.OutboxEvent{{
+		Topic:     kafka.TopicCardExpired,
+		Key:       card.UserID.String(),
+		Payload:   string(payload),
+		CreatedAt: now,
+	}})
+	if expired {
+		card.Status = model.CardExpired
+	}
+	return expired, err
+}
+
+// ProcessRenewals renews every card expiring within CardRenewalWindow of now
+// and deactivates renewed cards that have expired. A card that fails is
+// logged and skipped so it does not hold up the rest; the first error is
+// returned once all cards have been tried. Renewing and expiring are
+// conditional updates, so replicas running this at the same time neither
+// issue two renewals nor send two notifications.
+func (s *CardService) ProcessRenewals(now time.Time) (renewed, expired int, err error) {
+	if s.renewals == nil {
+		return 0, 0, ErrRenewalsDisabled
+	}
+	var firstErr error
+	fail := func(card *model.Card, action string, err error) {
+		slog.Error("Card renewal job failed for card", "card_id", card.ID, "action", action, "error", err)
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s card %s: %w", action, card.ID, err)
+		}
+	}
+
+	// Cards that fail stay due, so each batch skips the ones already tried
+	tried := map[uuid.UUID]bool{}
+	for {
+		cards, err := s.renewals.ListCardsDueForRenewal(now.Add(CardRenewalWindow), renewalBatchSize+len(tried))
+		if err != nil {
+			return renewed, expired, err
+		}
+		progressed := false
+		for i := range cards {
+			card := &cards[i]
+			if tried[card.ID] {
+				continue
+			}
+			tried[card.ID] = true
+			progressed = true
+			ok, err := s.RenewCard(card, now)
+			if err != nil {
+				fail(card, "renewing", err)
+				continue
+			}
+			if ok {
+				renewed++
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+
+	tried = map[uuid.UUID]bool{}
+	for {
+		cards, err := s.renewals.ListCardsDueForDeactivation(now, renewalBatchSize+len(tried))
+		if err != nil {
+			return renewed, expired, err
+		}
+		progressed := false
+		for i := range cards {
+			card := &cards[i]
+			if tried[card.ID] {
+				continue
+			}
+			tried[card.ID] = true
+			progressed = true
+			ok, err := s.ExpireRenewedCard(card, now)
+			if err != nil {
+				fail(card, "expiring", err)
+				continue
+			}
+			if ok {
+				expired++
+			}
+		}
+		if !progressed {
+			break
+		}
+	}
+	return renewed, expired, firstErr
+}
+
+// RenewalJob renews cards nearing expiry and deactivates expired ones
+func (s *CardService) RenewalJob(_ context.Context, _ *jobs.Job) error {
+	renewed, expired, err := s.ProcessRenewals(time.Now())
+	if renewed > 0 || expired > 0 {
+		slog.Info("Processed card renewals", "renewed", renewed, "expired", expired)
+	}
+	return err
+}
+
+func uuidString(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+	return id.String()
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRenewals is an in-memory RenewalRepository with the same conditional
+// updates as the database one
+type memoryRenewals struct {
+	cards   map[uuid.UUID]*model.Card
+	tokens  []model.NetworkToken
+	notices []model.TravelNotice
+	events  []model.OutboxEvent
+	records []model.CardReplacement
+	failFor uuid.UUID
+}
+
+func newMemoryRenewals(cards ...*model.Card) *memoryRenewals {
+	m := &memoryRenewals{cards: map[uuid.UUID]*model.Card{}}
+	for _, c := range cards {
+		m.cards[c.ID] = c
+	}
+	return m
+}
+
+func (m *memoryRenewals) sorted(keep func(*model.Card) bool, by func(*model.Card) time.Time, limit int) []model.Card {
+	var out []model.Card
+	for _, c := range m.cards {
+		if keep(c) {
+			out = append(out, *c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return by(&out[i]).Before(by(&out[j])) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func (m *memoryRenewals) ListCardsDueForRenewal(expiringBefore time.Time, limit int) ([]model.Card, error) {
+	return m.sorted(func(c *model.Card) bool {
+		return c.ExpiresAt.Before(expiringBefore) && c.ReplacedByCardID == nil && c.Status != model.CardInactive && c.Status != model.CardExpired
+	}, func(c *model.Card) time.Time { return c.ExpiresAt }, limit), nil
+}
+
+func (m *memoryRenewals) RenewCard(old, renewal *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, notices []model.TravelNotice, events []model.OutboxEvent) (bool, error) {
+	if old.ID == m.failFor {
+		return false, errors.New("database unavailable")
+	}
+	stored := m.cards[old.ID]
+	if stored.ReplacedByCardID != nil {
+		return false, nil
+	}
+	stored.ReplacedByCardID = old.ReplacedByCardID
+	stored.DeactivatesAt = old.DeactivatesAt
+	m.cards[renewal.ID] = renewal
+	m.records = append(m.records, *record)
+	m.tokens = append(m.tokens, tokens...)
+	m.notices = append(m.notices, notices...)
+	m.events = append(m.events, events...)
+	return true, nil
+}
+
+func (m *memoryRenewals) ListCardsDueForDeactivation(now time.Time, limit int) ([]model.Card, error) {
+	return m.sorted(func(c *model.Card) bool {
+		return c.DeactivatesAt != nil && !c.DeactivatesAt.After(now) && c.Status != model.CardExpired
+	}, func(c *model.Card) time.Time { return *c.DeactivatesAt }, limit), nil
+}
+
+func (m *memoryRenewals) ExpireCard(card *model.Card, events []model.OutboxEvent) (bool, error) {
+	stored := m.cards[card.ID]
+	if stored.Status == model.CardExpired {
+		return false, nil
+	}
+	stored.Status = model.CardExpired
+	m.events = append(m.events, events...)
+	return true, nil
+}
+
+func expiringCard(userID uuid.UUID, expiresAt time.Time) *model.Card {
+	card := newTestCard(userID)
+	card.AccountID = uuid.New()
+	card.MaskedCardNumber = "**** **** **** 4242"
+	card.ExpiresAt = expiresAt
+	card.ExpirationDate = expiresAt.AddDate(0, -1, 0).Format("01/06")
+	card.DailyLimit = decimal.NewFromInt(400)
+	return card
+}
+
+func TestCardExpiry(t *testing.T) {
+	expiry, expiresAt := cardExpiry(time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC))
+	assert.Equal(t, "10/29", expiry)
+	assert.Equal(t, time.Date(2029, 11, 1, 0, 0, 0, 0, time.UTC), expiresAt)
+
+	expiry, expiresAt = cardExpiry(time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, "12/29", expiry)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), expiresAt)
+}
+
+func TestProcessRenewals_RenewsCardsExpiringWithinWindow(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	due := expiringCard(userID, now.Add(30*24*time.Hour))
+	due.PinHash = "argon2id$hash"
+	due.GeoBlocking = false
+	later := expiringCard(userID, now.Add(90*24*time.Hour))
+
+	token := model.NetworkToken{ID: uuid.New(), CardID: due.ID, Status: model.NetworkTokenActive}
+	revoked := model.NetworkToken{ID: uuid.New(), CardID: due.ID, Status: model.NetworkTokenRevoked}
+	mockRepo := new(MockCardRepository)
+	mockRepo.On("ListNetworkTokensByCard", due.ID).Return([]model.NetworkToken{token, revoked}, nil)
+
+	notices := newMemoryTravelNotices()
+	require.NoError(t, notices.CreateTravelNotice(&model.TravelNotice{CardID: due.ID, UserID: userID, Countries: []string{"FR"},
+		StartDate: now.AddDate(0, 1, 0), EndDate: now.AddDate(0, 1, 7)}))
+	require.NoError(t, notices.CreateTravelNotice(&model.TravelNotice{CardID: due.ID, UserID: userID, Countries: []string{"ES"},
+		StartDate: now.AddDate(0, -2, 0), EndDate: now.AddDate(0, -1, 0)}))
+
+	renewals := newMemoryRenewals(due, later)
+	svc := NewCardService(mockRepo)
+	svc.SetTravelNotices(notices, "GB")
+	svc.SetRenewals(renewals)
+
+	renewed, expired, err := svc.ProcessRenewals(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, renewed)
+	assert.Equal(t, 0, expired)
+	assert.Nil(t, later.ReplacedByCardID)
+
+	require.NotNil(t, due.ReplacedByCardID)
+	renewal := renewals.cards[*due.ReplacedByCardID]
+	assert.Equal(t, model.CardActive, due.Status, "the old card works until it expires")
+	assert.Equal(t, due.ExpiresAt, *due.DeactivatesAt)
+	assert.Equal(t, due.ID, *renewal.ReplacesCardID)
+	assert.Equal(t, due.AccountID, renewal.AccountID)
+	assert.True(t, due.DailyLimit.Equal(renewal.DailyLimit))
+	assert.False(t, renewal.GeoBlocking)
+	assert.Equal(t, due.PinHash, renewal.PinHash)
+	assert.True(t, renewal.ExpiresAt.After(now.AddDate(2, 11, 0)))
+
+	require.Len(t, renewals.records, 1)
+	assert.Equal(t, model.ReplacementExpiry, renewals.records[0].Reason)
+	assert.Equal(t, 1, renewals.records[0].TokensMoved)
+	require.Len(t, renewals.tokens, 1)
+	assert.Equal(t, renewal.ID, renewals.tokens[0].CardID)
+	require.Len(t, renewals.notices, 1)
+	assert.Equal(t, renewal.ID, renewals.notices[0].CardID)
+	assert.Equal(t, []string{"FR"}, renewals.notices[0].Countries)
+
+	require.Len(t, renewals.events, 2)
+	assert.Equal(t, kafka.TopicCardIssued, renewals.events[0].Topic)
+	assert.Equal(t, kafka.TopicNotificationEmail, renewals.events[1].Topic)
+	var notification kafka.NotificationEvent
+	require.NoError(t, json.Unmarshal([]byte(renewals.events[1].Payload), &notification))
+	assert.Equal(t, userID.String(), notification.UserID)
+	assert.Equal(t, CardRenewalTemplate, notification.Template)
+	assert.Equal(t, renewal.ExpirationDate, notification.Data["expiration_date"])
+
+	// A second run, e.g. on another replica, finds nothing left to do
+	renewed, _, err = svc.ProcessRenewals(now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, renewed)
+	assert.Len(t, renewals.events, 2)
+}
+
+func TestProcessRenewals_ExpiresRenewedCards(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	card := expiringCard(uuid.New(), now.Add(30*24*time.Hour))
+	mockRepo := new(MockCardRepository)
+	mockRepo.On("ListNetworkTokensByCard", mock.Anything).Return([]model.NetworkToken{}, nil)
+	renewals := newMemoryRenewals(card)
+	svc := NewCardService(mockRepo)
+	svc.SetRenewals(renewals)
+
+	_, _, err := svc.ProcessRenewals(now)
+	require.NoError(t, err)
+
+	_, expired, err := svc.ProcessRenewals(now.Add(29 * 24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired, "not yet at the expiry date")
+
+	_, expired, err = svc.ProcessRenewals(card.ExpiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, model.CardExpired, renewals.cards[card.ID].Status)
+	last := renewals.events[len(renewals.events)-1]
+	assert.Equal(t, kafka.TopicCardExpired, last.Topic)
+
+	_, expired, err = svc.ProcessRenewals(card.ExpiresAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, expired)
+}
+
+func TestProcessRenewals_FailureDoesNotStopOtherCards(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	broken := expiringCard(userID, now.Add(10*24*time.Hour))
+	fine := expiringCard(userID, now.Add(20*24*time.Hour))
+	mockRepo := new(MockCardRepository)
+	mockRepo.On("ListNetworkTokensByCard", mock.Anything).Return([]model.NetworkToken{}, nil)
+	renewals := newMemoryRenewals(broken, fine)
+	renewals.failFor = broken.ID
+	svc := NewCardService(mockRepo)
+	svc.SetRenewals(renewals)
+
+	renewed, _, err := svc.ProcessRenewals(now)
+	assert.Error(t, err)
+	assert.Equal(t, 1, renewed)
+	assert.NotNil(t, fine.ReplacedByCardID)
+	assert.Nil(t, broken.ReplacedByCardID)
+}
+
+func TestProcessRenewals_Disabled(t *testing.T) {
+	svc := NewCardService(new(MockCardRepository))
+	_, _, err := svc.ProcessRenewals(time.Now())
+	assert.ErrorIs(t, err, ErrRenewalsDisabled)
+}
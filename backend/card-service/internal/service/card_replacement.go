@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+// outboxBatchSize caps how many outbox events one relay pass publishes
+const outboxBatchSize = 100
+
+var (
+	ErrInvalidReplacementReason = errors.New("reason must be LOST, STOLEN or DAMAGED")
+	ErrCardAlreadyReplaced      = errors.New("card has already been replaced")
+)
+
+// ReplacedCard is the outcome of a card replacement
+type ReplacedCard struct {
+	Card         *model.Card            `json:"card"`
+	ReplacedCard *model.Card            `json:"replaced_card"`
+	Replacement  *model.CardReplacement `json:"replacement"`
+}
+
+// EventPublisher sends relayed outbox events to Kafka
+type EventPublisher interface {
+	Produce(ctx context.Context, topic string, key string, value interface{}) error
+}
+
+// ReplaceCard blocks a lost, stolen or damaged card and issues a new one on the
+// same account. The new card keeps the old card's daily limit and PIN; active
+// wallet tokens move to the new card when keepTokens is set and are revoked
+// otherwise. Everything, including the card.blocked and card.issued events, is
+// committed in one transaction.
+func (s *CardService) ReplaceCard(userID, cardID string, reason model.ReplacementReason, keepTokens bool) (*ReplacedCard, error) {
+	if !reason.IsValid() {
+		return nil, ErrInvalidReplacementReason
+	}
+
+	old, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if old.ReplacedByCardID != nil {
+		return nil, ErrCardAlreadyReplaced
+	}
+
+	replacement, err := newCard(old.UserID, old.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	replacement.ID = uuid.New()
+	replacement.ReplacesCardID = &old.ID
+	replacement.DailyLimit = old.DailyLimit
+	replacement.GeoBlocking = old.GeoBlocking
+	replacement.Delegate, replacement.ApprovedBy, replacement.ApprovedAt = old.Delegate, old.ApprovedBy, old.ApprovedAt
+	replacement.PinHash = old.PinHash
+	replacement.PinUpdatedAt = old.PinUpdatedAt
+
+	old.Status = model.CardBlocked
+	old.ReplacedByCardID = &replacement.ID
+
+	tokens, err := s.Repo.ListNetworkTokensByCard(old.ID)
+	if err != nil {
+		return nil, err
+	}
+	var changed []model.NetworkToken
+	now := time.Now()
+	for _, t := range tokens {
+		if t.Status != model.NetworkTokenActive {
+			continue
+		}
+		if keepTokens {
+			t.CardID = replacement.ID
+		} else {
+			t.Status = model.NetworkTokenRevoked
+			t.RevokedAt = &now
+		}
+		changed = append(changed, t)
+	}
+
+	record := &model.CardReplacement{
+		OldCardID: old.ID,
+		NewCardID: replacement.ID,
+		UserID:    old.UserID,
+		Reason:    reason,
+	}
+	if keepTokens {
+		record.TokensMoved = len(changed)
+	}
+
+	events, err := replacementEvents(old, replacement, reason, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.Repo.ReplaceCard(old, replacement, record, changed, events)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCardAlreadyReplaced
+	}
+	return &ReplacedCard{Card: replacement, ReplacedCard: old, Replacement: record}, nil
+}
+
+// replacementEvents builds the outbox entries for a replacement. Both are keyed by
+// user so they land on the same partition and are consumed in order.
+func replacementEvents(old, replacement *model.Card, reason model.ReplacementReason, at time.Time) ([]model.OutboxEvent, error) {
+	blocked := kafka.CardEvent{
+		CardID:           old.ID.String(),
+		UserID:           old.UserID.String(),
+		AccountID:        old.AccountID.String(),
+		MaskedCardNumber: old.MaskedCardNumber,
+		Status:           string(old.Status),
+		Reason:           string(reason),
+		ReplacedByCardID: replacement.ID.String(),
+		Timestamp:        at.Format(time.RFC3339),
+	}
+	issued := kafka.CardEvent{
+		CardID:           replacement.ID.String(),
+		UserID:           replacement.UserID.String(),
+		AccountID:        replacement.AccountID.String(),
+		MaskedCardNumber: replacement.MaskedCardNumber,
+		Status:           string(replacement.Status),
+		Reason:           string(reason),
+		ReplacesCardID:   old.ID.String(),
+		Timestamp:        at.Format(time.RFC3339),
+	}
+
+	events := make([]model.OutboxEvent, 0, 2)
+	for i, e := range []struct {
+		topic string
+		event kafka.CardEvent
+	}{{kafka.TopicCardBlocked, blocked}, {kafka.TopicCardIssued, issued}} {
+		payload, err := json.Marshal(e.event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, model.OutboxEvent{
+			Topic:   e.topic,
+			Key:     old.UserID.String(),
+			Payload: string(payload),
+			// Distinct timestamps keep the relay's created_at ordering stable
+			CreatedAt: at.Add(time.Duration(i) * time.Microsecond),
+		})
+	}
+	return events, nil
+}
+
+// PublishOutbox relays pending outbox events to Kafka in the order they were written
+func (s *CardService) PublishOutbox(ctx context.Context, publisher EventPublisher) (int, error) {
+	return s.Repo.PublishOutbox(outboxBatchSize, func(e model.OutboxEvent) error {
+		return publisher.Produce(ctx, e.Topic, e.Key, json.RawMessage(e.Payload))
+	})
+}
+
+// OutboxRelayJob returns a job that publishes pending outbox events
+func (s *CardService) OutboxRelayJob(publisher EventPublisher) jobs.Handler {
+	return func(ctx context.Context, _ *jobs.Job) error {
+		n, err := s.PublishOutbox(ctx, publisher)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			slog.Info("Published card outbox events", "count", n)
+		}
+		return nil
+	}
+}
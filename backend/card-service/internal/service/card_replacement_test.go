@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingPublisher keeps the messages it is asked to produce
+type recordingPublisher struct {
+	topics []string
+}
+
+func (p *recordingPublisher) Produce(_ context.Context, topic string, _ string, _ interface{}) error {
+	p.topics = append(p.topics, topic)
+	return nil
+}
+
+func TestReplaceCard_PreservesControlsAndMovesTokens(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	old := newTestCard(userID)
+	old.AccountID = uuid.New()
+	old.DailyLimit = decimal.NewFromInt(250)
+	old.PinHash = "argon2id$hash"
+
+	active := model.NetworkToken{ID: uuid.New(), CardID: old.ID, Status: model.NetworkTokenActive}
+	revoked := model.NetworkToken{ID: uuid.New(), CardID: old.ID, Status: model.NetworkTokenRevoked}
+
+	var events []model.OutboxEvent
+	mockRepo.On("GetCardByID", old.ID).Return(old, nil)
+	mockRepo.On("ListNetworkTokensByCard", old.ID).Return([]model.NetworkToken{active, revoked}, nil)
+	mockRepo.On("ReplaceCard", old, mock.AnythingOfType("*model.Card"), mock.AnythingOfType("*model.CardReplacement"), mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			events = args.Get(4).([]model.OutboxEvent)
+		}).Return(true, nil)
+
+	result, err := svc.ReplaceCard(userID.String(), old.ID.String(), model.ReplacementLost, true)
+	require.NoError(t, err)
+
+	card := result.Card
+	assert.Equal(t, model.CardBlocked, old.Status)
+	assert.Equal(t, card.ID, *old.ReplacedByCardID)
+	assert.Equal(t, old.ID, *card.ReplacesCardID)
+	assert.Equal(t, model.CardActive, card.Status)
+	assert.Equal(t, old.AccountID, card.AccountID)
+	assert.True(t, old.DailyLimit.Equal(card.DailyLimit))
+	assert.Equal(t, old.PinHash, card.PinHash)
+	assert.NotEqual(t, old.CardToken, card.CardToken)
+	assert.Equal(t, 1, result.Replacement.TokensMoved)
+
+	tokens := mockRepo.Calls[len(mockRepo.Calls)-1].Arguments.Get(3).([]model.NetworkToken)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, card.ID, tokens[0].CardID)
+	assert.Equal(t, model.NetworkTokenActive, tokens[0].Status)
+
+	require.Len(t, events, 2)
+	assert.Equal(t, kafka.TopicCardBlocked, events[0].Topic)
+	assert.Equal(t, kafka.TopicCardIssued, events[1].Topic)
+	assert.True(t, events[0].CreatedAt.Before(events[1].CreatedAt))
+
+	var issued kafka.CardEvent
+	require.NoError(t, json.Unmarshal([]byte(events[1].Payload), &issued))
+	assert.Equal(t, card.ID.String(), issued.CardID)
+	assert.Equal(t, old.ID.String(), issued.ReplacesCardID)
+	assert.Equal(t, "LOST", issued.Reason)
+}
+
+func TestReplaceCard_RevokesTokensUnlessKept(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	old := newTestCard(userID)
+	active := model.NetworkToken{ID: uuid.New(), CardID: old.ID, Status: model.NetworkTokenActive}
+
+	mockRepo.On("GetCardByID", old.ID).Return(old, nil)
+	mockRepo.On("ListNetworkTokensByCard", old.ID).Return([]model.NetworkToken{active}, nil)
+	mockRepo.On("ReplaceCard", mock.Anything, mock.Anything, mock.Anything, mock.MatchedBy(func(tokens []model.NetworkToken) bool {
+		return len(tokens) == 1 && tokens[0].CardID == old.ID && tokens[0].Status == model.NetworkTokenRevoked && tokens[0].RevokedAt != nil
+	}), mock.Anything).Return(true, nil)
+
+	result, err := svc.ReplaceCard(userID.String(), old.ID.String(), model.ReplacementStolen, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Replacement.TokensMoved)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReplaceCard_Rejections(t *testing.T) {
+	userID := uuid.New()
+
+	t.Run("invalid reason", func(t *testing.T) {
+		svc := NewCardService(new(MockCardRepository))
+		_, err := svc.ReplaceCard(userID.String(), uuid.New().String(), "EXPIRED", false)
+		assert.ErrorIs(t, err, ErrInvalidReplacementReason)
+	})
+
+	t.Run("not the owner", func(t *testing.T) {
+		mockRepo := new(MockCardRepository)
+		card := newTestCard(uuid.New())
+		mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+		_, err := NewCardService(mockRepo).ReplaceCard(userID.String(), card.ID.String(), model.ReplacementDamaged, false)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("already replaced", func(t *testing.T) {
+		mockRepo := new(MockCardRepository)
+		card := newTestCard(userID)
+		next := uuid.New()
+		card.ReplacedByCardID = &next
+		mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+		_, err := NewCardService(mockRepo).ReplaceCard(userID.String(), card.ID.String(), model.ReplacementDamaged, false)
+		assert.ErrorIs(t, err, ErrCardAlreadyReplaced)
+	})
+
+	t.Run("concurrent replacement wins", func(t *testing.T) {
+		mockRepo := new(MockCardRepository)
+		card := newTestCard(userID)
+		mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+		mockRepo.On("ListNetworkTokensByCard", card.ID).Return([]model.NetworkToken{}, nil)
+		mockRepo.On("ReplaceCard", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+
+		_, err := NewCardService(mockRepo).ReplaceCard(userID.String(), card.ID.String(), model.ReplacementDamaged, false)
+		assert.ErrorIs(t, err, ErrCardAlreadyReplaced)
+	})
+}
+
+func TestPublishOutbox_RelaysPayloadsInOrder(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	events := []model.OutboxEvent{
+		{ID: uuid.New(), Topic: kafka.TopicCardBlocked, Key: "u1", Payload: `{"card_id":"a"}`, CreatedAt: time.Now()},
+		{ID: uuid.New(), Topic: kafka.TopicCardIssued, Key: "u1", Payload: `{"card_id":"b"}`, CreatedAt: time.Now()},
+	}
+	mockRepo.On("PublishOutbox", outboxBatchSize, mock.Anything).Return(2, nil).Run(func(args mock.Arguments) {
+		publish := args.Get(1).(func(model.OutboxEvent) error)
+		for _, e := range events {
+			require.NoError(t, publish(e))
+		}
+	})
+
+	publisher := &recordingPublisher{}
+	n, err := svc.PublishOutbox(context.Background(), publisher)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{kafka.TopicCardBlocked, kafka.TopicCardIssued}, publisher.topics)
+}
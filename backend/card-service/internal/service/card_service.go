@@ -9,10 +9,10 @@ import (
 	"fmt"
 	"io"
 	"math/big"
-	"os"
 	"time"
 
 	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -24,20 +24,20 @@ var (
 	ErrEncryption       = errors.New("encryption failed")
 )
 
-// encryptionKey is loaded from environment variable
+// encryptionKey encrypts card numbers at rest. The default is for
+// development and testing only; the service sets CARD_ENCRYPTION_KEY at
+// startup, which config validation requires in production.
 // In production, this should come from AWS KMS or similar
-var encryptionKey []byte
+var encryptionKey = []byte("devonly32byteencryptionkey!!!!!!") // exactly 32 bytes
 
-func init() {
-	keyStr := os.Getenv("CARD_ENCRYPTION_KEY")
-	if keyStr == "" {
-		// For development/testing only - production MUST set this
-		keyStr = "devonly32byteencryptionkey!!!!!!" // exactly 32 bytes
-	}
-	encryptionKey = []byte(keyStr)
-	if len(encryptionKey) != 32 {
-		panic("CARD_ENCRYPTION_KEY must be exactly 32 bytes for AES-256")
+// SetEncryptionKey replaces the key card numbers are encrypted with. The key
+// must be exactly 32 bytes for AES-256.
+func SetEncryptionKey(key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("card encryption key must be exactly 32 bytes for AES-256, got %d", len(key))
 	}
+	encryptionKey = key
+	return nil
 }
 
 // Repository defines the interface for card data access
@@ -46,13 +46,57 @@ type Repository interface {
 	CreateCard(card *model.Card) error
 	GetCardByID(id uuid.UUID) (*model.Card, error)
 	GetCardByNumber(pan string) (*model.Card, error)
+	UpdateCard(card *model.Card) error
+	RecordPINFailure(cardID uuid.UUID, maxAttempts int) (attempts int, recorded bool, err error)
+	ResetPINFailures(cardID uuid.UUID) error
 	ListCardsByAccount(accountID string) ([]model.Card, error)
 	ListCardsByUser(userID string) ([]model.Card, error)
 	VerifyAccountOwnership(userID, accountID uuid.UUID) (bool, error)
+	CreateNetworkToken(token *model.NetworkToken) error
+	GetNetworkToken(id uuid.UUID) (*model.NetworkToken, error)
+	GetNetworkTokenByHash(hash string) (*model.NetworkToken, error)
+	ListNetworkTokensByCard(cardID uuid.UUID) ([]model.NetworkToken, error)
+	UpdateNetworkToken(token *model.NetworkToken) error
+	ReplaceCard(old, replacement *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, events []model.OutboxEvent) (bool, error)
+	PublishOutbox(limit int, publish func(model.OutboxEvent) error) (int, error)
 }
 
 type CardService struct {
 	Repo Repository
+
+	// Disputes are optional; see SetDisputes
+	disputes          DisputeRepository
+	disputeLedger     DisputeLedger
+	suspenseAccountID uuid.UUID
+
+	// Travel notices and geo rules are optional; see SetTravelNotices
+	travelNotices TravelNoticeRepository
+	homeCountry   string
+
+	// The transaction feed and spending insights are optional; see SetTransactionFeed
+	transactions  CardTransactionRepository
+	insightsCache InsightsCache
+
+	// Renewal of expiring cards is optional; see SetRenewals
+	renewals RenewalRepository
+
+	// Merchant lists and control history are optional; see SetCardControls
+	cardControls CardControlRepository
+
+	// Physical card orders are optional; see SetCardOrders
+	cardOrders CardOrderRepository
+
+	// Balance checks with stand-in processing are optional; see SetStandIn
+	balances      BalanceSource
+	standIn       StandInRepository
+	standInConfig StandInConfig
+
+	// Lockouts of PIN verification across soft blocks are optional; see SetPINAttempts
+	pinAttempts *middleware.AttemptLimiter
+
+	// Revealing card numbers to administrators is optional; see SetPANAccess
+	panAccesses  PANAccessRepository
+	panAccessCap int
 }
 
 func NewCardService(repo Repository) *CardService {
@@ -82,33 +126,10 @@ func (s *CardService) IssueCard(userID, accountID string) (*model.Card, error) {
 		return nil, ErrUnauthorized
 	}
 
-	// Generate Random PAN (Mock - in production use payment processor)
-	pan, _ := generateRandomNumericString(16)
-
-	// SEC-002: CVV is NEVER stored - only generated for single-use display
-	// In real implementation, CVV would be shown once and never stored
-
-	// Expiry +3 years
-	expiry := time.Now().AddDate(3, 0, 0).Format("01/06")
-
-	// SEC-003: Encrypt card number for storage using AES-256-GCM
-	encryptedPAN, err := encryptCardNumber(pan)
+	card, err := newCard(userUUID, accUUID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt card number: %w", err)
-	}
-	maskedPAN := maskCardNumber(pan)
-
-	card := &model.Card{
-		UserID:              userUUID,
-		AccountID:           accUUID,
-		EncryptedCardNumber: encryptedPAN,
-		MaskedCardNumber:    maskedPAN,
-		ExpirationDate:      expiry,
-		Status:              model.CardActive,
-		CardToken:           uuid.New(),
-		DailyLimit:          decimal.NewFromInt(1000),
+		return nil, err
 	}
-
 	if err := s.Repo.CreateCard(card); err != nil {
 		return nil, err
 	}
@@ -144,14 +165,55 @@ func (s *CardService) GetCard(userID, cardID string) (*model.Card, error) {
 		return nil, err
 	}
 
-	// SEC-006: Verify the user owns this card
+	// SEC-006: Verify the user owns this card. For delegate cards that is the
+	// delegate; the account owner manages them through the delegate card API.
 	if card.UserID != userUUID {
 		return nil, ErrUnauthorized
 	}
+	if !card.Usable() {
+		return nil, ErrCardPendingApproval
+	}
 
 	return card, nil
 }
 
+// newCard builds an active card with a freshly generated, encrypted PAN
+func newCard(userID, accountID uuid.UUID) (*model.Card, error) {
+	// Generate Random PAN (Mock - in production use payment processor)
+	pan, _ := generateRandomNumericString(16)
+
+	// SEC-002: CVV is NEVER stored - only generated for single-use display
+	// In real implementation, CVV would be shown once and never stored
+
+	expiry, expiresAt := cardExpiry(time.Now())
+
+	// SEC-003: Encrypt card number for storage using AES-256-GCM
+	encryptedPAN, err := encryptCardNumber(pan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt card number: %w", err)
+	}
+
+	return &model.Card{
+		UserID:              userID,
+		AccountID:           accountID,
+		EncryptedCardNumber: encryptedPAN,
+		MaskedCardNumber:    maskCardNumber(pan),
+		ExpirationDate:      expiry,
+		ExpiresAt:           expiresAt,
+		Status:              model.CardActive,
+		CardToken:           uuid.New(),
+		DailyLimit:          decimal.NewFromInt(1000),
+		GeoBlocking:         true,
+	}, nil
+}
+
+// cardExpiry returns the MM/YY expiry of a card issued at t, three years on,
+// and when the card stops working: the start of the month after that
+func cardExpiry(t time.Time) (string, time.Time) {
+	expiry := t.UTC().AddDate(3, 0, 0)
+	return expiry.Format("01/06"), time.Date(expiry.Year(), expiry.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
 func generateRandomNumericString(n int) (string, error) {
 	const letters = "0123456789"
 	ret := make([]byte, n)
@@ -197,7 +259,8 @@ func encryptCardNumber(pan string) (string, error) {
 }
 
 // decryptCardNumber decrypts the card number using AES-256-GCM
-// SEC-003: For internal use only - never expose decrypted PAN to clients
+// SEC-003: For internal use only - never expose decrypted PAN to clients;
+// administrators get it through RevealPAN, which records every access
 func decryptCardNumber(encrypted string) (string, error) {
 	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
 	if err != nil {
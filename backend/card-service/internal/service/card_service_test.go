@@ -42,6 +42,21 @@ func (m *MockCardRepository) GetCardByID(id uuid.UUID) (*model.Card, error) {
 	return args.Get(0).(*model.Card), args.Error(1)
 }
 
+func (m *MockCardRepository) UpdateCard(card *model.Card) error {
+	args := m.Called(card)
+	return args.Error(0)
+}
+
+func (m *MockCardRepository) RecordPINFailure(cardID uuid.UUID, maxAttempts int) (int, bool, error) {
+	args := m.Called(cardID, maxAttempts)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockCardRepository) ResetPINFailures(cardID uuid.UUID) error {
+	args := m.Called(cardID)
+	return args.Error(0)
+}
+
 func (m *MockCardRepository) VerifyAccountOwnership(userID, accountID uuid.UUID) (bool, error) {
 	args := m.Called(userID, accountID)
 	return args.Bool(0), args.Error(1)
@@ -160,3 +175,44 @@ func TestGenerateRandomNumericString(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, cvv, 3)
 }
+
+func (m *MockCardRepository) CreateNetworkToken(token *model.NetworkToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockCardRepository) GetNetworkToken(id uuid.UUID) (*model.NetworkToken, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NetworkToken), args.Error(1)
+}
+
+func (m *MockCardRepository) GetNetworkTokenByHash(hash string) (*model.NetworkToken, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.NetworkToken), args.Error(1)
+}
+
+func (m *MockCardRepository) ListNetworkTokensByCard(cardID uuid.UUID) ([]model.NetworkToken, error) {
+	args := m.Called(cardID)
+	return args.Get(0).([]model.NetworkToken), args.Error(1)
+}
+
+func (m *MockCardRepository) UpdateNetworkToken(token *model.NetworkToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockCardRepository) ReplaceCard(old, replacement *model.Card, record *model.CardReplacement, tokens []model.NetworkToken, events []model.OutboxEvent) (bool, error) {
+	args := m.Called(old, replacement, record, tokens, events)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCardRepository) PublishOutbox(limit int, publish func(model.OutboxEvent) error) (int, error) {
+	args := m.Called(limit, publish)
+	return args.Int(0), args.Error(1)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MaxDelegateDailyLimit caps the daily limit of a delegate card
+var MaxDelegateDailyLimit = decimal.NewFromInt(1000)
+
+var (
+	ErrCardPendingApproval    = errors.New("card is waiting for the account owner's approval")
+	ErrDelegateCardNotFound   = errors.New("delegate card not found")
+	ErrDelegateCardNotPending = errors.New("only delegate cards waiting for approval can be approved or declined")
+	ErrSelfApproval           = errors.New("a delegate cannot approve or change the limit of their own card")
+	ErrInvalidDailyLimit      = errors.New("daily_limit must be greater than zero and at most 1000")
+)
+
+// DelegateCard is what an account owner sees of a card issued to a delegate
+// on their account. The card number, token and expiry are the delegate's own
+// card data and are left out.
+type DelegateCard struct {
+	ID         uuid.UUID        `json:"id"`
+	AccountID  uuid.UUID        `json:"account_id"`
+	HolderID   uuid.UUID        `json:"holder_id"`
+	Status     model.CardStatus `json:"status"`
+	DailyLimit decimal.Decimal  `json:"daily_limit"`
+	ApprovedBy *uuid.UUID       `json:"approved_by,omitempty"`
+	ApprovedAt *time.Time       `json:"approved_at,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+func delegateCardView(c *model.Card) *DelegateCard {
+	return &DelegateCard{
+		ID:         c.ID,
+		AccountID:  c.AccountID,
+		HolderID:   c.UserID,
+		Status:     c.Status,
+		DailyLimit: c.DailyLimit,
+		ApprovedBy: c.ApprovedBy,
+		ApprovedAt: c.ApprovedAt,
+		CreatedAt:  c.CreatedAt,
+	}
+}
+
+// RequestDelegateCard asks for a card on another user's account with its own
+// daily limit. The card is issued to the requesting user and stays unusable
+// until the account owner approves it.
+func (s *CardService) RequestDelegateCard(userID, accountID string, dailyLimit decimal.Decimal) (*model.Card, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	accUUID, err := uuid.Parse(accountID)
+	if err != nil {
+		return nil, ErrInvalidAccountID
+	}
+	if !validDelegateLimit(dailyLimit) {
+		return nil, ErrInvalidDailyLimit
+	}
+
+	card, err := newCard(userUUID, accUUID)
+	if err != nil {
+		return nil, err
+	}
+	card.Status = model.CardPendingApproval
+	card.Delegate = true
+	card.DailyLimit = dailyLimit
+	if err := s.Repo.CreateCard(card); err != nil {
+		return nil, err
+	}
+	slog.Info("Delegate card requested", "card_id", card.ID, "account_id", accountID, "holder_id", userID)
+	return card, nil
+}
+
+// ListDelegateCards returns the delegate cards on an account the user owns
+func (s *CardService) ListDelegateCards(ownerID, accountID string) ([]DelegateCard, error) {
+	if _, err := s.verifyOwner(ownerID, accountID); err != nil {
+		return nil, err
+	}
+	cards, err := s.Repo.ListCardsByAccount(accountID)
+	if err != nil {
+		return nil, err
+	}
+	delegates := make([]DelegateCard, 0, len(cards))
+	for i := range cards {
+		if cards[i].Delegate {
+			delegates = append(delegates, *delegateCardView(&cards[i]))
+		}
+	}
+	return delegates, nil
+}
+
+// ApproveDelegateCard activates a delegate card on the owner's account. A
+// non-nil dailyLimit replaces the limit the delegate asked for.
+func (s *CardService) ApproveDelegateCard(ownerID, accountID, cardID string, dailyLimit *decimal.Decimal) (*DelegateCard, error) {
+	card, owner, err := s.getDelegateCard(ownerID, accountID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != model.CardPendingApproval {
+		return nil, ErrDelegateCardNotPending
+	}
+	if card.UserID == owner {
+		return nil, ErrSelfApproval
+	}
+	if dailyLimit != nil {
+		if !validDelegateLimit(*dailyLimit) {
+			return nil, ErrInvalidDailyLimit
+		}
+		card.DailyLimit = *dailyLimit
+	}
+
+	now := time.Now()
+	card.Status = model.CardActive
+	card.ApprovedBy = &owner
+	card.ApprovedAt = &now
+	if err := s.Repo.UpdateCard(card); err != nil {
+		return nil, err
+	}
+	slog.Info("Delegate card approved", "card_id", card.ID, "account_id", accountID, "approved_by", ownerID)
+	return delegateCardView(card), nil
+}
+
+// DeclineDelegateCard refuses a delegate card waiting for approval
+func (s *CardService) DeclineDelegateCard(ownerID, accountID, cardID string) (*DelegateCard, error) {
+	card, _, err := s.getDelegateCard(ownerID, accountID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != model.CardPendingApproval {
+		return nil, ErrDelegateCardNotPending
+	}
+	card.Status = model.CardDeclined
+	if err := s.Repo.UpdateCard(card); err != nil {
+		return nil, err
+	}
+	slog.Info("Delegate card declined", "card_id", card.ID, "account_id", accountID, "declined_by", ownerID)
+	return delegateCardView(card), nil
+}
+
+// SetDelegateLimit changes the daily limit of a delegate card on the owner's account
+func (s *CardService) SetDelegateLimit(ownerID, accountID, cardID string, dailyLimit decimal.Decimal) (*DelegateCard, error) {
+	if !validDelegateLimit(dailyLimit) {
+		return nil, ErrInvalidDailyLimit
+	}
+	card, owner, err := s.getDelegateCard(ownerID, accountID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.UserID == owner {
+		return nil, ErrSelfApproval
+	}
+	if !card.DailyLimit.Equal(dailyLimit) {
+		card.DailyLimit = dailyLimit
+		if err := s.Repo.UpdateCard(card); err != nil {
+			return nil, err
+		}
+	}
+	return delegateCardView(card), nil
+}
+
+// RevokeDelegateCard blocks a delegate card on the owner's account for good
+func (s *CardService) RevokeDelegateCard(ownerID, accountID, cardID string) (*DelegateCard, error) {
+	card, _, err := s.getDelegateCard(ownerID, accountID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != model.CardBlocked {
+		card.Status = model.CardBlocked
+		if err := s.Repo.UpdateCard(card); err != nil {
+			return nil, err
+		}
+		slog.Info("Delegate card revoked", "card_id", card.ID, "account_id", accountID, "revoked_by", ownerID)
+	}
+	return delegateCardView(card), nil
+}
+
+// verifyOwner checks that the user owns the account and returns the user's ID
+func (s *CardService) verifyOwner(ownerID, accountID string) (uuid.UUID, error) {
+	ownerUUID, err := uuid.Parse(ownerID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidUserID
+	}
+	accUUID, err := uuid.Parse(accountID)
+	if err != nil {
+		return uuid.Nil, ErrInvalidAccountID
+	}
+	owns, err := s.Repo.VerifyAccountOwnership(ownerUUID, accUUID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !owns {
+		return uuid.Nil, ErrUnauthorized
+	}
+	return ownerUUID, nil
+}
+
+// getDelegateCard loads a delegate card on an account the user owns
+func (s *CardService) getDelegateCard(ownerID, accountID, cardID string) (*model.Card, uuid.UUID, error) {
+	owner, err := s.verifyOwner(ownerID, accountID)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	cardUUID, err := uuid.Parse(cardID)
+	if err != nil {
+		return nil, uuid.Nil, ErrDelegateCardNotFound
+	}
+	accUUID, _ := uuid.Parse(accountID)
+	card, err := s.Repo.GetCardByID(cardUUID)
+	if err != nil || !card.Delegate || card.AccountID != accUUID {
+		return nil, uuid.Nil, ErrDelegateCardNotFound
+	}
+	return card, owner, nil
+}
+
+func validDelegateLimit(limit decimal.Decimal) bool {
+	return limit.IsPositive() && !limit.GreaterThan(MaxDelegateDailyLimit)
+}
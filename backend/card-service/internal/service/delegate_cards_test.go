@@ -0,0 +1,125 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// requestDelegateCard returns a pending delegate card on accountID, stored in repo
+func requestDelegateCard(t *testing.T, svc *CardService, repo *MockCardRepository, delegateID, accountID uuid.UUID) *model.Card {
+	t.Helper()
+	repo.On("CreateCard", mock.Anything).Return(nil).Once()
+	card, err := svc.RequestDelegateCard(delegateID.String(), accountID.String(), decimal.NewFromInt(250))
+	require.NoError(t, err)
+	card.ID = uuid.New()
+	repo.On("GetCardByID", card.ID).Return(card, nil)
+	return card
+}
+
+func TestRequestDelegateCard(t *testing.T) {
+	repo := new(MockCardRepository)
+	svc := NewCardService(repo)
+	delegateID, accountID := uuid.New(), uuid.New()
+
+	_, err := svc.RequestDelegateCard(delegateID.String(), accountID.String(), decimal.Zero)
+	assert.ErrorIs(t, err, ErrInvalidDailyLimit)
+	_, err = svc.RequestDelegateCard(delegateID.String(), accountID.String(), decimal.NewFromInt(5000))
+	assert.ErrorIs(t, err, ErrInvalidDailyLimit)
+
+	card := requestDelegateCard(t, svc, repo, delegateID, accountID)
+	assert.True(t, card.Delegate)
+	assert.Equal(t, delegateID, card.UserID)
+	assert.Equal(t, model.CardPendingApproval, card.Status)
+	assert.True(t, card.DailyLimit.Equal(decimal.NewFromInt(250)))
+
+	// The delegate cannot use the card before the owner approves it
+	_, err = svc.GetCard(delegateID.String(), card.ID.String())
+	assert.ErrorIs(t, err, ErrCardPendingApproval)
+}
+
+func TestApproveDelegateCard(t *testing.T) {
+	repo := new(MockCardRepository)
+	svc := NewCardService(repo)
+	ownerID, delegateID, strangerID, accountID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	repo.On("VerifyAccountOwnership", ownerID, accountID).Return(true, nil)
+	repo.On("VerifyAccountOwnership", delegateID, accountID).Return(true, nil)
+	repo.On("VerifyAccountOwnership", strangerID, accountID).Return(false, nil)
+	repo.On("UpdateCard", mock.Anything).Return(nil)
+	card := requestDelegateCard(t, svc, repo, delegateID, accountID)
+
+	// Only an owner of the account can approve, and never the delegate
+	_, err := svc.ApproveDelegateCard(strangerID.String(), accountID.String(), card.ID.String(), nil)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = svc.ApproveDelegateCard(delegateID.String(), accountID.String(), card.ID.String(), nil)
+	assert.ErrorIs(t, err, ErrSelfApproval)
+
+	// A card is only found through the account it was requested on
+	otherAccountID := uuid.New()
+	repo.On("VerifyAccountOwnership", ownerID, otherAccountID).Return(true, nil)
+	_, err = svc.ApproveDelegateCard(ownerID.String(), otherAccountID.String(), card.ID.String(), nil)
+	assert.ErrorIs(t, err, ErrDelegateCardNotFound)
+
+	limit := decimal.NewFromInt(100)
+	approved, err := svc.ApproveDelegateCard(ownerID.String(), accountID.String(), card.ID.String(), &limit)
+	require.NoError(t, err)
+	assert.Equal(t, model.CardActive, approved.Status)
+	assert.Equal(t, delegateID, approved.HolderID)
+	assert.Equal(t, &ownerID, approved.ApprovedBy)
+	assert.True(t, approved.DailyLimit.Equal(limit))
+
+	_, err = svc.DeclineDelegateCard(ownerID.String(), accountID.String(), card.ID.String())
+	assert.ErrorIs(t, err, ErrDelegateCardNotPending)
+
+	// The delegate now uses the card as their own; the owner cannot
+	got, err := svc.GetCard(delegateID.String(), card.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, card.ID, got.ID)
+	_, err = svc.GetCard(ownerID.String(), card.ID.String())
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	// The owner controls the delegate's limit, the delegate does not
+	_, err = svc.SetDelegateLimit(delegateID.String(), accountID.String(), card.ID.String(), decimal.NewFromInt(1000))
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	updated, err := svc.SetDelegateLimit(ownerID.String(), accountID.String(), card.ID.String(), decimal.NewFromInt(50))
+	require.NoError(t, err)
+	assert.True(t, updated.DailyLimit.Equal(decimal.NewFromInt(50)))
+
+	revoked, err := svc.RevokeDelegateCard(ownerID.String(), accountID.String(), card.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.CardBlocked, revoked.Status)
+}
+
+func TestDeclineAndListDelegateCards(t *testing.T) {
+	repo := new(MockCardRepository)
+	svc := NewCardService(repo)
+	ownerID, delegateID, accountID := uuid.New(), uuid.New(), uuid.New()
+	repo.On("VerifyAccountOwnership", ownerID, accountID).Return(true, nil)
+	repo.On("UpdateCard", mock.Anything).Return(nil)
+	card := requestDelegateCard(t, svc, repo, delegateID, accountID)
+
+	declined, err := svc.DeclineDelegateCard(ownerID.String(), accountID.String(), card.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.CardDeclined, declined.Status)
+	_, err = svc.GetCard(delegateID.String(), card.ID.String())
+	assert.ErrorIs(t, err, ErrCardPendingApproval)
+
+	// The owner's own cards are not listed, only the delegates'
+	own := model.Card{ID: uuid.New(), UserID: ownerID, AccountID: accountID, Status: model.CardActive}
+	repo.On("ListCardsByAccount", accountID.String()).Return([]model.Card{own, *card}, nil)
+	delegates, err := svc.ListDelegateCards(ownerID.String(), accountID.String())
+	require.NoError(t, err)
+	require.Len(t, delegates, 1)
+	assert.Equal(t, card.ID, delegates[0].ID)
+	assert.Equal(t, delegateID, delegates[0].HolderID)
+
+	// Owner operations only find delegate cards
+	repo.On("GetCardByID", own.ID).Return(&own, nil)
+	_, err = svc.RevokeDelegateCard(ownerID.String(), accountID.String(), own.ID.String())
+	assert.ErrorIs(t, err, ErrDelegateCardNotFound)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDisputesDisabled      = errors.New("disputes are not configured")
+	ErrInvalidDisputeReason  = errors.New("reason must be FRAUD, NOT_RECEIVED, NOT_AS_DESCRIBED, DUPLICATE_CHARGE or INCORRECT_AMOUNT")
+	ErrInvalidDisputeOutcome = errors.New("outcome must be WON or LOST")
+	ErrInvalidTransactionID  = errors.New("invalid transaction id")
+	ErrDisputeNotFound       = errors.New("dispute not found")
+	ErrInvalidDisputeState   = errors.New("dispute is not in a state that allows this action")
+	ErrDisputeExists         = errors.New("transaction has already been disputed")
+)
+
+// DisputeRepository stores card disputes
+type DisputeRepository interface {
+	CreateDispute(d *model.Dispute) error
+	GetDispute(id uuid.UUID) (*model.Dispute, error)
+	DeleteDispute(id uuid.UUID) error
+	ListDisputesByUser(userID uuid.UUID) ([]model.Dispute, error)
+	ListDisputes(status model.DisputeStatus) ([]model.Dispute, error)
+	UpdateDispute(d *model.Dispute, from model.DisputeStatus) (bool, error)
+}
+
+// DisputeLedger posts provisional credits and their reversals
+type DisputeLedger interface {
+	Transfer(from, to uuid.UUID, amount decimal.Decimal, description, reversesEntryID string) (uuid.UUID, error)
+}
+
+// SetDisputes enables the disputes workflow. Provisional credits are paid from
+// suspenseAccountID, the bank's chargeback suspense account in the ledger.
+func (s *CardService) SetDisputes(repo DisputeRepository, ledger DisputeLedger, suspenseAccountID uuid.UUID) {
+	s.disputes = repo
+	s.disputeLedger = ledger
+	s.suspenseAccountID = suspenseAccountID
+}
+
+// OpenDispute disputes a transaction on one of the user's cards and credits the
+// amount to the card's account straight away. The credit is provisional until
+// the dispute is resolved.
+func (s *CardService) OpenDispute(userID, cardID, transactionID string, amount decimal.Decimal, reason model.DisputeReason, description string) (*model.Dispute, error) {
+	if s.disputes == nil {
+		return nil, ErrDisputesDisabled
+	}
+	if !reason.IsValid() {
+		return nil, ErrInvalidDisputeReason
+	}
+	if !amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+	txID, err := uuid.Parse(transactionID)
+	if err != nil {
+		return nil, ErrInvalidTransactionID
+	}
+
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	dispute := &model.Dispute{
+		CardID:        card.ID,
+		UserID:        card.UserID,
+		TransactionID: txID,
+		Amount:        amount,
+		Reason:        reason,
+		Description:   description,
+		Status:        model.DisputeOpen,
+	}
+	// The unique transaction index rejects a second dispute before any money moves
+	if err := s.disputes.CreateDispute(dispute); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrDisputeExists
+		}
+		return nil, err
+	}
+
+	entryID, err := s.disputeLedger.Transfer(s.suspenseAccountID, card.AccountID, amount,
+		fmt.Sprintf("Provisional credit for dispute %s", dispute.ID), "")
+	if err != nil {
+		if delErr := s.disputes.DeleteDispute(dispute.ID); delErr != nil {
+			slog.Error("Failed to remove dispute after provisional credit failed", "dispute_id", dispute.ID, "error", delErr)
+		}
+		return nil, fmt.Errorf("post provisional credit: %w", err)
+	}
+
+	dispute.ProvisionalEntryID = &entryID
+	if _, err := s.disputes.UpdateDispute(dispute, model.DisputeOpen); err != nil {
+		// The credit is posted; keep the dispute and let the entry ID be recovered from the ledger
+		slog.Error("Failed to record provisional credit on dispute", "dispute_id", dispute.ID, "entry_id", entryID, "error", err)
+	}
+	return dispute, nil
+}
+
+// ListDisputes returns the user's disputes
+func (s *CardService) ListDisputes(userID string) ([]model.Dispute, error) {
+	if s.disputes == nil {
+		return nil, ErrDisputesDisabled
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	return s.disputes.ListDisputesByUser(userUUID)
+}
+
+// GetDispute returns one of the user's disputes
+func (s *CardService) GetDispute(userID, disputeID string) (*model.Dispute, error) {
+	dispute, err := s.getDispute(disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.UserID.String() != userID {
+		return nil, ErrDisputeNotFound
+	}
+	return dispute, nil
+}
+
+// ListAllDisputes returns disputes in a status for the admin review queue
+func (s *CardService) ListAllDisputes(status model.DisputeStatus) ([]model.Dispute, error) {
+	if s.disputes == nil {
+		return nil, ErrDisputesDisabled
+	}
+	return s.disputes.ListDisputes(status)
+}
+
+// ReviewDispute moves an open dispute to UNDER_REVIEW
+func (s *CardService) ReviewDispute(adminID, disputeID string) (*model.Dispute, error) {
+	reviewer, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	dispute, err := s.getDispute(disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != model.DisputeOpen {
+		return nil, ErrInvalidDisputeState
+	}
+
+	dispute.Status = model.DisputeUnderReview
+	dispute.ReviewedBy = &reviewer
+	ok, err := s.disputes.UpdateDispute(dispute, model.DisputeOpen)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidDisputeState
+	}
+	return dispute, nil
+}
+
+// ResolveDispute closes a dispute under review. A won dispute keeps the
+// provisional credit; a lost one reverses it in the ledger.
+func (s *CardService) ResolveDispute(adminID, disputeID string, outcome model.DisputeOutcome, note string) (*model.Dispute, error) {
+	if outcome != model.DisputeWon && outcome != model.DisputeLost {
+		return nil, ErrInvalidDisputeOutcome
+	}
+	resolver, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	dispute, err := s.getDispute(disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != model.DisputeUnderReview {
+		return nil, ErrInvalidDisputeState
+	}
+
+	// Claim the dispute before touching the ledger so a concurrent resolution
+	// cannot reverse the credit twice
+	now := time.Now()
+	dispute.Status = model.DisputeResolved
+	dispute.Outcome = outcome
+	dispute.ResolutionNote = note
+	dispute.ResolvedBy = &resolver
+	dispute.ResolvedAt = &now
+	ok, err := s.disputes.UpdateDispute(dispute, model.DisputeUnderReview)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidDisputeState
+	}
+
+	if outcome == model.DisputeWon || dispute.ProvisionalEntryID == nil {
+		return dispute, nil
+	}
+
+	card, err := s.Repo.GetCardByID(dispute.CardID)
+	if err == nil {
+		var entryID uuid.UUID
+		entryID, err = s.disputeLedger.Transfer(card.AccountID, s.suspenseAccountID, dispute.Amount,
+			fmt.Sprintf("Reversal of provisional credit for dispute %s", dispute.ID), dispute.ProvisionalEntryID.String())
+		if err == nil {
+			dispute.ReversalEntryID = &entryID
+			if _, err := s.disputes.UpdateDispute(dispute, model.DisputeResolved); err != nil {
+				slog.Error("Failed to record provisional credit reversal on dispute", "dispute_id", dispute.ID, "entry_id", entryID, "error", err)
+			}
+			return dispute, nil
+		}
+	}
+
+	// Put the dispute back under review so the resolution can be retried
+	dispute.Status = model.DisputeUnderReview
+	dispute.Outcome = ""
+	dispute.ResolutionNote = ""
+	dispute.ResolvedBy = nil
+	dispute.ResolvedAt = nil
+	if _, rbErr := s.disputes.UpdateDispute(dispute, model.DisputeResolved); rbErr != nil {
+		slog.Error("Failed to reopen dispute after reversal failed", "dispute_id", dispute.ID, "error", rbErr)
+	}
+	return nil, fmt.Errorf("reverse provisional credit: %w", err)
+}
+
+func (s *CardService) getDispute(disputeID string) (*model.Dispute, error) {
+	if s.disputes == nil {
+		return nil, ErrDisputesDisabled
+	}
+	id, err := uuid.Parse(disputeID)
+	if err != nil {
+		return nil, ErrDisputeNotFound
+	}
+	dispute, err := s.disputes.GetDispute(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDisputeNotFound
+		}
+		return nil, err
+	}
+	return dispute, nil
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryDisputes is an in-memory DisputeRepository with the same guarded updates as the real one
+type memoryDisputes struct {
+	byID map[uuid.UUID]model.Dispute
+}
+
+func newMemoryDisputes() *memoryDisputes {
+	return &memoryDisputes{byID: map[uuid.UUID]model.Dispute{}}
+}
+
+func (m *memoryDisputes) CreateDispute(d *model.Dispute) error {
+	for _, existing := range m.byID {
+		if existing.TransactionID == d.TransactionID {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	d.ID = uuid.New()
+	m.byID[d.ID] = *d
+	return nil
+}
+
+func (m *memoryDisputes) GetDispute(id uuid.UUID) (*model.Dispute, error) {
+	d, ok := m.byID[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &d, nil
+}
+
+func (m *memoryDisputes) DeleteDispute(id uuid.UUID) error {
+	delete(m.byID, id)
+	return nil
+}
+
+func (m *memoryDisputes) ListDisputesByUser(userID uuid.UUID) ([]model.Dispute, error) {
+	var out []model.Dispute
+	for _, d := range m.byID {
+		if d.UserID == userID {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryDisputes) ListDisputes(status model.DisputeStatus) ([]model.Dispute, error) {
+	var out []model.Dispute
+	for _, d := range m.byID {
+		if status == "" || d.Status == status {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryDisputes) UpdateDispute(d *model.Dispute, from model.DisputeStatus) (bool, error) {
+	current, ok := m.byID[d.ID]
+	if !ok || current.Status != from {
+		return false, nil
+	}
+	m.byID[d.ID] = *d
+	return true, nil
+}
+
+type ledgerTransfer struct {
+	from, to        uuid.UUID
+	amount          decimal.Decimal
+	reversesEntryID string
+}
+
+// fakeDisputeLedger records transfers and can be made to fail
+type fakeDisputeLedger struct {
+	transfers []ledgerTransfer
+	err       error
+}
+
+func (l *fakeDisputeLedger) Transfer(from, to uuid.UUID, amount decimal.Decimal, _ string, reversesEntryID string) (uuid.UUID, error) {
+	if l.err != nil {
+		return uuid.Nil, l.err
+	}
+	l.transfers = append(l.transfers, ledgerTransfer{from: from, to: to, amount: amount, reversesEntryID: reversesEntryID})
+	return uuid.New(), nil
+}
+
+func newDisputeTestService(t *testing.T) (*CardService, *MockCardRepository, *memoryDisputes, *fakeDisputeLedger, *model.Card, uuid.UUID) {
+	t.Helper()
+	cards := new(MockCardRepository)
+	disputes := newMemoryDisputes()
+	ledger := &fakeDisputeLedger{}
+	suspense := uuid.New()
+
+	svc := NewCardService(cards)
+	svc.SetDisputes(disputes, ledger, suspense)
+
+	card := newTestCard(uuid.New())
+	card.AccountID = uuid.New()
+	cards.On("GetCardByID", card.ID).Return(card, nil)
+	return svc, cards, disputes, ledger, card, suspense
+}
+
+func TestOpenDispute_PostsProvisionalCredit(t *testing.T) {
+	svc, _, disputes, ledger, card, suspense := newDisputeTestService(t)
+	txID := uuid.New()
+
+	dispute, err := svc.OpenDispute(card.UserID.String(), card.ID.String(), txID.String(), decimal.RequireFromString("49.99"), model.DisputeFraud, "not me")
+	require.NoError(t, err)
+
+	assert.Equal(t, model.DisputeOpen, dispute.Status)
+	require.Len(t, ledger.transfers, 1)
+	credit := ledger.transfers[0]
+	assert.Equal(t, suspense, credit.from)
+	assert.Equal(t, card.AccountID, credit.to)
+	assert.Equal(t, "49.99", credit.amount.String())
+	assert.Empty(t, credit.reversesEntryID)
+
+	stored, _ := disputes.GetDispute(dispute.ID)
+	require.NotNil(t, stored.ProvisionalEntryID)
+
+	_, err = svc.OpenDispute(card.UserID.String(), card.ID.String(), txID.String(), decimal.NewFromInt(1), model.DisputeFraud, "")
+	assert.ErrorIs(t, err, ErrDisputeExists)
+	assert.Len(t, ledger.transfers, 1, "a second dispute must not be credited")
+}
+
+func TestOpenDispute_Validation(t *testing.T) {
+	svc, _, _, ledger, card, _ := newDisputeTestService(t)
+	user, cardID, tx := card.UserID.String(), card.ID.String(), uuid.New().String()
+
+	_, err := svc.OpenDispute(user, cardID, tx, decimal.NewFromInt(10), "CHANGED_MIND", "")
+	assert.ErrorIs(t, err, ErrInvalidDisputeReason)
+	_, err = svc.OpenDispute(user, cardID, tx, decimal.Zero, model.DisputeFraud, "")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = svc.OpenDispute(user, cardID, "not-a-uuid", decimal.NewFromInt(10), model.DisputeFraud, "")
+	assert.ErrorIs(t, err, ErrInvalidTransactionID)
+	_, err = svc.OpenDispute(uuid.New().String(), cardID, tx, decimal.NewFromInt(10), model.DisputeFraud, "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	assert.Empty(t, ledger.transfers)
+
+	_, err = NewCardService(new(MockCardRepository)).OpenDispute(user, cardID, tx, decimal.NewFromInt(10), model.DisputeFraud, "")
+	assert.ErrorIs(t, err, ErrDisputesDisabled)
+}
+
+func TestOpenDispute_LedgerFailureRemovesDispute(t *testing.T) {
+	svc, _, disputes, ledger, card, _ := newDisputeTestService(t)
+	ledger.err = errors.New("ledger unavailable")
+
+	_, err := svc.OpenDispute(card.UserID.String(), card.ID.String(), uuid.New().String(), decimal.NewFromInt(10), model.DisputeNotReceived, "")
+	require.Error(t, err)
+	assert.Empty(t, disputes.byID, "the transaction can be disputed again once the ledger is back")
+}
+
+func TestResolveDispute_LostReversesCredit(t *testing.T) {
+	svc, _, _, ledger, card, suspense := newDisputeTestService(t)
+	admin := uuid.New().String()
+	dispute, err := svc.OpenDispute(card.UserID.String(), card.ID.String(), uuid.New().String(), decimal.NewFromInt(80), model.DisputeNotAsDescribed, "")
+	require.NoError(t, err)
+
+	// Only disputes under review can be resolved
+	_, err = svc.ResolveDispute(admin, dispute.ID.String(), model.DisputeLost, "")
+	assert.ErrorIs(t, err, ErrInvalidDisputeState)
+
+	_, err = svc.ReviewDispute(admin, dispute.ID.String())
+	require.NoError(t, err)
+	_, err = svc.ReviewDispute(admin, dispute.ID.String())
+	assert.ErrorIs(t, err, ErrInvalidDisputeState)
+
+	resolved, err := svc.ResolveDispute(admin, dispute.ID.String(), model.DisputeLost, "merchant provided proof of delivery")
+	require.NoError(t, err)
+	assert.Equal(t, model.DisputeResolved, resolved.Status)
+	assert.Equal(t, model.DisputeLost, resolved.Outcome)
+	require.NotNil(t, resolved.ReversalEntryID)
+
+	require.Len(t, ledger.transfers, 2)
+	reversal := ledger.transfers[1]
+	assert.Equal(t, card.AccountID, reversal.from)
+	assert.Equal(t, suspense, reversal.to)
+	assert.Equal(t, resolved.ProvisionalEntryID.String(), reversal.reversesEntryID)
+
+	_, err = svc.ResolveDispute(admin, dispute.ID.String(), model.DisputeLost, "")
+	assert.ErrorIs(t, err, ErrInvalidDisputeState)
+	assert.Len(t, ledger.transfers, 2)
+}
+
+func TestResolveDispute_WonKeepsCredit(t *testing.T) {
+	svc, _, _, ledger, card, _ := newDisputeTestService(t)
+	admin := uuid.New().String()
+	dispute, err := svc.OpenDispute(card.UserID.String(), card.ID.String(), uuid.New().String(), decimal.NewFromInt(80), model.DisputeDuplicateCharge, "")
+	require.NoError(t, err)
+	_, err = svc.ReviewDispute(admin, dispute.ID.String())
+	require.NoError(t, err)
+
+	_, err = svc.ResolveDispute(admin, dispute.ID.String(), "MAYBE", "")
+	assert.ErrorIs(t, err, ErrInvalidDisputeOutcome)
+
+	resolved, err := svc.ResolveDispute(admin, dispute.ID.String(), model.DisputeWon, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.DisputeWon, resolved.Outcome)
+	assert.NotNil(t, resolved.ResolvedAt)
+	assert.Nil(t, resolved.ReversalEntryID)
+	assert.Len(t, ledger.transfers, 1)
+}
+
+func TestResolveDispute_ReversalFailureKeepsDisputeUnderReview(t *testing.T) {
+	svc, _, disputes, ledger, card, _ := newDisputeTestService(t)
+	admin := uuid.New().String()
+	dispute, err := svc.OpenDispute(card.UserID.String(), card.ID.String(), uuid.New().String(), decimal.NewFromInt(80), model.DisputeIncorrectAmount, "")
+	require.NoError(t, err)
+	_, err = svc.ReviewDispute(admin, dispute.ID.String())
+	require.NoError(t, err)
+
+	ledger.err = errors.New("ledger unavailable")
+	_, err = svc.ResolveDispute(admin, dispute.ID.String(), model.DisputeLost, "")
+	require.Error(t, err)
+
+	stored, _ := disputes.GetDispute(dispute.ID)
+	assert.Equal(t, model.DisputeUnderReview, stored.Status)
+	assert.Empty(t, stored.Outcome)
+	assert.Nil(t, stored.ResolvedAt)
+}
+
+func TestGetDispute_OnlyOwner(t *testing.T) {
+	svc, _, _, _, card, _ := newDisputeTestService(t)
+	dispute, err := svc.OpenDispute(card.UserID.String(), card.ID.String(), uuid.New().String(), decimal.NewFromInt(5), model.DisputeFraud, "")
+	require.NoError(t, err)
+
+	_, err = svc.GetDispute(card.UserID.String(), dispute.ID.String())
+	require.NoError(t, err)
+	_, err = svc.GetDispute(uuid.New().String(), dispute.ID.String())
+	assert.ErrorIs(t, err, ErrDisputeNotFound)
+	_, err = svc.GetDispute(card.UserID.String(), uuid.New().String())
+	assert.ErrorIs(t, err, ErrDisputeNotFound)
+}
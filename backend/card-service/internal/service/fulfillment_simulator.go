@@ -0,0 +1,124 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+)
+
+const (
+	// simulatedCarrier is the carrier the simulator reports for shipped cards
+	simulatedCarrier = "SIMULATED_POST"
+	// fulfillmentBatchSize caps how many orders in each status one run advances
+	fulfillmentBatchSize = 100
+)
+
+// FulfillmentWebhookPayload is the body of the fulfillment partner's webhook
+type FulfillmentWebhookPayload struct {
+	OrderID        string    `json:"order_id"`
+	Status         string    `json:"status"`
+	Carrier        string    `json:"carrier,omitempty"`
+	TrackingNumber string    `json:"tracking_number,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// FulfillmentSimulator stands in for the card fulfillment partner where there
+// is none: it moves each order one step along every StepDelay by calling the
+// fulfillment webhook with requests signed like the partner's
+type FulfillmentSimulator struct {
+	WebhookURL string
+	StepDelay  time.Duration
+	client     *http.Client
+}
+
+// NewFulfillmentSimulator creates a simulator that signs its webhook calls
+// with the given key
+func NewFulfillmentSimulator(webhookURL, keyID string, secret []byte, stepDelay time.Duration) *FulfillmentSimulator {
+	return &FulfillmentSimulator{
+		WebhookURL: webhookURL,
+		StepDelay:  stepDelay,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &middleware.SigningTransport{KeyID: keyID, Secret: secret},
+		},
+	}
+}
+
+// simulatedSteps maps each status the simulator advances to the next one
+var simulatedSteps = []struct{ from, to model.CardOrderStatus }{
+	{model.CardOrderOrdered, model.CardOrderPrinted},
+	{model.CardOrderPrinted, model.CardOrderShipped},
+	{model.CardOrderShipped, model.CardOrderDelivered},
+}
+
+// FulfillmentSimulatorJob advances the orders that have waited StepDelay in
+// their current status. Orders are moved one step per run, so an order
+// reaches DELIVERED after three runs at the earliest.
+func (s *CardService) FulfillmentSimulatorJob(sim *FulfillmentSimulator) jobs.Handler {
+	return func(ctx context.Context, _ *jobs.Job) error {
+		if s.cardOrders == nil {
+			return ErrCardOrdersDisabled
+		}
+		now := time.Now()
+		var due []model.CardOrder
+		next := map[model.CardOrderStatus]model.CardOrderStatus{}
+		for _, step := range simulatedSteps {
+			orders, err := s.cardOrders.ListCardOrdersByStatus(step.from, now.Add(-sim.StepDelay), fulfillmentBatchSize)
+			if err != nil {
+				return err
+			}
+			due = append(due, orders...)
+			next[step.from] = step.to
+		}
+
+		advanced := 0
+		for _, order := range due {
+			payload := FulfillmentWebhookPayload{
+				OrderID:    order.ID.String(),
+				Status:     string(next[order.Status]),
+				OccurredAt: now,
+			}
+			if payload.Status == string(model.CardOrderShipped) {
+				payload.Carrier = simulatedCarrier
+				payload.TrackingNumber = "SIM" + order.ID.String()[:8]
+			}
+			if err := sim.send(ctx, payload); err != nil {
+				return fmt.Errorf("advancing card order %s: %w", order.ID, err)
+			}
+			advanced++
+		}
+		if advanced > 0 {
+			slog.Info("Simulated card fulfillment", "orders", advanced)
+		}
+		return nil
+	}
+}
+
+func (sim *FulfillmentSimulator) send(ctx context.Context, payload FulfillmentWebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sim.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := sim.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fulfillment webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// LedgerClient posts dispute credits and their reversals to the ledger service
+// and reads the balances card authorizations are checked against
+type LedgerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewLedgerClient creates a ledger client. httpClient should authenticate its
+// requests, e.g. with a service account token carrying the ledger:write scope
+// for disputes and ledger:read for balance checks.
+func NewLedgerClient(baseURL string, httpClient *http.Client) *LedgerClient {
+	return &LedgerClient{baseURL: baseURL, httpClient: httpClient}
+}
+
+type ledgerPosting struct {
+	AccountID string `json:"account_id"`
+	Amount    string `json:"amount"`
+	Direction int    `json:"direction"`
+}
+
+type ledgerTransactionRequest struct {
+	Description     string          `json:"description"`
+	Postings        []ledgerPosting `json:"postings"`
+	ReversesEntryID string          `json:"reverses_entry_id,omitempty"`
+}
+
+// ledgerEntryResponse is the part of a created journal entry the card service keeps
+type ledgerEntryResponse struct {
+	ID uuid.UUID `json:"ID"`
+}
+
+// Transfer moves amount from one ledger account to another and returns the
+// journal entry ID. A non-empty reversesEntryID books it as a reversal of that entry.
+func (c *LedgerClient) Transfer(from, to uuid.UUID, amount decimal.Decimal, description, reversesEntryID string) (uuid.UUID, error) {
+	body, _ := json.Marshal(ledgerTransactionRequest{
+		Description: description,
+		Postings: []ledgerPosting{
+			{AccountID: from.String(), Amount: amount.String(), Direction: -1},
+			{AccountID: to.String(), Amount: amount.String(), Direction: 1},
+		},
+		ReversesEntryID: reversesEntryID,
+	})
+
+	resp, err := c.httpClient.Post(c.baseURL+"/api/v1/transactions", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return uuid.Nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var entry ledgerEntryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return uuid.Nil, fmt.Errorf("decode ledger response: %w", err)
+	}
+	if entry.ID == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("ledger response has no entry id")
+	}
+	return entry.ID, nil
+}
+
+// ledgerBalanceResponse is the part of an account balance the card service reads
+type ledgerBalanceResponse struct {
+	AvailableBalance decimal.Decimal `json:"available_balance"`
+}
+
+// AvailableBalance returns the available balance of an account. Errors
+// wrap ErrLedgerUnavailable when the ledger could not be reached or did not
+// answer before ctx was done.
+func (c *LedgerClient) AvailableBalance(ctx context.Context, accountID uuid.UUID) (decimal.Decimal, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/internal/v1/accounts/"+accountID.String()+"/balance", nil)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%w: %v", ErrLedgerUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return decimal.Zero, fmt.Errorf("%w: ledger service returned status %d", ErrLedgerUnavailable, resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return decimal.Zero, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var balance ledgerBalanceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&balance); err != nil {
+		return decimal.Zero, fmt.Errorf("%w: decode ledger response: %v", ErrLedgerUnavailable, err)
+	}
+	return balance.AvailableBalance, nil
+}
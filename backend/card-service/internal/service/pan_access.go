@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultPANAccessDailyCap is how many card numbers one administrator may
+// reveal per UTC day
+const DefaultPANAccessDailyCap = 10
+
+// minPANJustification is the shortest justification accepted, so that "ops"
+// or "test" do not pass for a reason
+const minPANJustification = 20
+
+var (
+	ErrPANAccessDisabled      = errors.New("card number access is not enabled")
+	ErrInvalidPANAccessReason = errors.New("reason must be PROCESSOR_REISSUE, CHARGEBACK_EVIDENCE, FRAUD_INVESTIGATION or LEGAL_REQUEST")
+	ErrPANJustification       = fmt.Errorf("a justification of at least %d characters is required", minPANJustification)
+	ErrPANAccessCapReached    = errors.New("daily card number access limit reached")
+)
+
+// PANAccessRepository records disclosures of card numbers
+type PANAccessRepository interface {
+	// CreatePANAccess records the access unless its administrator already
+	// made limit of them since the given time, in which case it returns false
+	CreatePANAccess(access *model.PANAccess, limit int, since time.Time) (bool, error)
+	// ListPANAccesses returns a card's accesses, newest first
+	ListPANAccesses(cardID uuid.UUID) ([]model.PANAccess, error)
+}
+
+// PANAccessRequest is why an administrator needs a card's full number
+type PANAccessRequest struct {
+	Reason        model.PANAccessReason
+	Justification string
+	Reference     string
+	IPAddress     string
+}
+
+// PANDisclosure is a card's full number, with the record of its disclosure
+type PANDisclosure struct {
+	CardID         uuid.UUID `json:"card_id"`
+	CardNumber     string    `json:"card_number"`
+	ExpirationDate string    `json:"expiration_date"`
+	AccessID       uuid.UUID `json:"access_id"`
+}
+
+// SetPANAccess enables revealing card numbers to administrators, each limited
+// to dailyCap per UTC day
+func (s *CardService) SetPANAccess(repo PANAccessRepository, dailyCap int) {
+	s.panAccesses = repo
+	s.panAccessCap = dailyCap
+}
+
+// RevealPAN decrypts a card's number for an administrator with an operational
+// reason, e.g. to send it to the processor for a reissue. The access is
+// recorded before the number is decrypted and refused once the administrator
+// reached the daily cap; a failure to record it reveals nothing.
+func (s *CardService) RevealPAN(adminID, cardID string, req PANAccessRequest, now time.Time) (*PANDisclosure, error) {
+	if s.panAccesses == nil {
+		return nil, ErrPANAccessDisabled
+	}
+	admin, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	switch req.Reason {
+	case model.PANAccessProcessorReissue, model.PANAccessChargebackEvidence, model.PANAccessFraudInvestigation, model.PANAccessLegalRequest:
+	default:
+		return nil, ErrInvalidPANAccessReason
+	}
+	justification := strings.TrimSpace(req.Justification)
+	if len(justification) < minPANJustification {
+		return nil, ErrPANJustification
+	}
+	cardUUID, err := uuid.Parse(cardID)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	card, err := s.Repo.GetCardByID(cardUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &model.PANAccess{
+		ID:            uuid.New(),
+		CardID:        card.ID,
+		AccessedBy:    admin,
+		Reason:        req.Reason,
+		Justification: justification,
+		Reference:     strings.TrimSpace(req.Reference),
+		IPAddress:     req.IPAddress,
+		CreatedAt:     now,
+	}
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	created, err := s.panAccesses.CreatePANAccess(access, s.panAccessCap, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record card number access: %w", err)
+	}
+	if !created {
+		return nil, ErrPANAccessCapReached
+	}
+
+	pan, err := decryptCardNumber(card.EncryptedCardNumber)
+	if err != nil {
+		return nil, ErrEncryption
+	}
+	return &PANDisclosure{CardID: card.ID, CardNumber: pan, ExpirationDate: card.ExpirationDate, AccessID: access.ID}, nil
+}
+
+// ListPANAccesses returns who revealed a card's number and why, newest first
+func (s *CardService) ListPANAccesses(cardID string) ([]model.PANAccess, error) {
+	if s.panAccesses == nil {
+		return nil, ErrPANAccessDisabled
+	}
+	cardUUID, err := uuid.Parse(cardID)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.panAccesses.ListPANAccesses(cardUUID)
+}
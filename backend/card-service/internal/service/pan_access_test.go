@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPANAccesses is an in-memory PANAccessRepository
+type memoryPANAccesses struct {
+	accesses []model.PANAccess
+	err      error
+}
+
+func (m *memoryPANAccesses) CreatePANAccess(access *model.PANAccess, limit int, since time.Time) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	count := 0
+	for _, a := range m.accesses {
+		if a.AccessedBy == access.AccessedBy && !a.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	if count >= limit {
+		return false, nil
+	}
+	m.accesses = append(m.accesses, *access)
+	return true, nil
+}
+
+func (m *memoryPANAccesses) ListPANAccesses(cardID uuid.UUID) ([]model.PANAccess, error) {
+	var out []model.PANAccess
+	for i := len(m.accesses) - 1; i >= 0; i-- {
+		if m.accesses[i].CardID == cardID {
+			out = append(out, m.accesses[i])
+		}
+	}
+	return out, nil
+}
+
+func TestRevealPAN(t *testing.T) {
+	number := "9123456789012347"
+	encrypted, err := encryptCardNumber(number)
+	require.NoError(t, err)
+	card := &model.Card{ID: uuid.New(), EncryptedCardNumber: encrypted, ExpirationDate: "04/29"}
+	repo := new(MockCardRepository)
+	repo.On("GetCardByID", card.ID).Return(card, nil)
+	accesses := &memoryPANAccesses{}
+	svc := NewCardService(repo)
+	svc.SetPANAccess(accesses, 2)
+	admin := uuid.New().String()
+	req := PANAccessRequest{Reason: model.PANAccessProcessorReissue, Justification: "Reissue after processor migration", Reference: "OPS-1234"}
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	disclosure, err := svc.RevealPAN(admin, card.ID.String(), req, day)
+	require.NoError(t, err)
+	assert.Equal(t, number, disclosure.CardNumber)
+	assert.Equal(t, "04/29", disclosure.ExpirationDate)
+	require.Len(t, accesses.accesses, 1)
+	access := accesses.accesses[0]
+	assert.Equal(t, disclosure.AccessID, access.ID)
+	assert.Equal(t, model.PANAccessProcessorReissue, access.Reason)
+	assert.Equal(t, "OPS-1234", access.Reference)
+
+	// The cap is per administrator and per UTC day
+	_, err = svc.RevealPAN(admin, card.ID.String(), req, day.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = svc.RevealPAN(admin, card.ID.String(), req, day.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrPANAccessCapReached)
+	_, err = svc.RevealPAN(uuid.New().String(), card.ID.String(), req, day.Add(2*time.Hour))
+	assert.NoError(t, err)
+	_, err = svc.RevealPAN(admin, card.ID.String(), req, day.Add(15*time.Hour))
+	assert.NoError(t, err, "a new day starts at midnight UTC")
+
+	listed, err := svc.ListPANAccesses(card.ID.String())
+	require.NoError(t, err)
+	assert.Len(t, listed, 4, "refused accesses are not recorded")
+	assert.Equal(t, day.Add(15*time.Hour), listed[0].CreatedAt)
+}
+
+func TestRevealPAN_Refused(t *testing.T) {
+	card := &model.Card{ID: uuid.New(), EncryptedCardNumber: "not revealed"}
+	repo := new(MockCardRepository)
+	repo.On("GetCardByID", card.ID).Return(card, nil)
+	svc := NewCardService(repo)
+	admin := uuid.New().String()
+	req := PANAccessRequest{Reason: model.PANAccessFraudInvestigation, Justification: "Matching card against fraud report"}
+
+	_, err := svc.RevealPAN(admin, card.ID.String(), req, time.Now())
+	assert.ErrorIs(t, err, ErrPANAccessDisabled)
+
+	accesses := &memoryPANAccesses{}
+	svc.SetPANAccess(accesses, DefaultPANAccessDailyCap)
+	_, err = svc.RevealPAN(admin, card.ID.String(), PANAccessRequest{Reason: "CURIOSITY", Justification: req.Justification}, time.Now())
+	assert.ErrorIs(t, err, ErrInvalidPANAccessReason)
+	_, err = svc.RevealPAN(admin, card.ID.String(), PANAccessRequest{Reason: req.Reason, Justification: "  ops  "}, time.Now())
+	assert.ErrorIs(t, err, ErrPANJustification)
+
+	// Nothing is revealed when the access cannot be recorded
+	accesses.err = errors.New("database down")
+	disclosure, err := svc.RevealPAN(admin, card.ID.String(), req, time.Now())
+	assert.Error(t, err)
+	assert.Nil(t, disclosure)
+}
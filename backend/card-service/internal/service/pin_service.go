@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/argon2"
+)
+
+// MaxPINAttempts is the number of consecutive wrong PINs before the card is soft-blocked
+const MaxPINAttempts = 3
+
+var (
+	ErrInvalidPINFormat  = errors.New("PIN must be 4 to 6 digits")
+	ErrWeakPIN           = errors.New("PIN is too easy to guess")
+	ErrPINNotSet         = errors.New("no PIN set for this card")
+	ErrIncorrectPIN      = errors.New("incorrect PIN")
+	ErrCardPINBlocked    = errors.New("card is blocked after too many incorrect PIN attempts")
+	ErrCardNotPINBlocked = errors.New("card is not PIN blocked")
+	ErrCardNotActive     = errors.New("card is not active")
+)
+
+// Argon2id parameters (OWASP recommended minimums)
+const (
+	argonTime    = 1
+	argonMemory  = 64 * 1024
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+// PINResult describes the outcome of a PIN verification
+type PINResult struct {
+	Card              *model.Card
+	JustBlocked       bool
+	AttemptsRemaining int
+}
+
+// SetPIN sets or changes the PIN of an active card owned by the user
+func (s *CardService) SetPIN(userID, cardID, pin string) (*model.Card, error) {
+	if err := validatePIN(pin); err != nil {
+		return nil, err
+	}
+
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == model.CardPINBlocked {
+		return nil, ErrCardPINBlocked
+	}
+	if card.Status != model.CardActive {
+		return nil, ErrCardNotActive
+	}
+
+	if err := s.storePIN(card, pin); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// SetPINAttempts locks PIN verification of a card out after wrong PINs, for
+// longer each time, e.g. with middleware.PINAttemptPolicy. Unlike the soft
+// block, the lockout is not lifted by setting a new PIN, so a card whose PIN
+// keeps being guessed at is slowed down however often it is unblocked.
+func (s *CardService) SetPINAttempts(limiter *middleware.AttemptLimiter) {
+	s.pinAttempts = limiter
+}
+
+// VerifyPIN checks a PIN for the card networks and other internal callers,
+// counting failures and soft-blocking the card after MaxPINAttempts. A card
+// locked out by SetPINAttempts returns a *middleware.LockedOutError without
+// checking the PIN.
+func (s *CardService) VerifyPIN(cardID, pin string) (*PINResult, error) {
+	cardUUID, err := uuid.Parse(cardID)
+	if err != nil {
+		return nil, errors.New("invalid card id")
+	}
+	card, err := s.Repo.GetCardByID(cardUUID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == model.CardPINBlocked {
+		return &PINResult{Card: card}, ErrCardPINBlocked
+	}
+	if card.Status != model.CardActive {
+		return &PINResult{Card: card}, ErrCardNotActive
+	}
+	if card.PinHash == "" {
+		return &PINResult{Card: card}, ErrPINNotSet
+	}
+	ctx := context.Background()
+	if s.pinAttempts != nil {
+		if err := s.pinAttempts.Check(ctx, card.ID.String()); errors.Is(err, middleware.ErrLockedOut) {
+			return &PINResult{Card: card}, err
+		} else if err != nil {
+			slog.Warn("Failed to check PIN lockout", "card_id", card.ID, "error", err)
+		}
+	}
+
+	ok, err := verifyPINHash(pin, card.PinHash)
+	if err != nil {
+		return nil, err
+	}
+	s.recordPINAttempt(ctx, card, ok)
+
+	if ok {
+		if card.PinFailedAttempts > 0 {
+			if err := s.Repo.ResetPINFailures(card.ID); err != nil {
+				return nil, err
+			}
+			card.PinFailedAttempts = 0
+		}
+		return &PINResult{Card: card, AttemptsRemaining: MaxPINAttempts}, nil
+	}
+
+	// The count is read back from the update rather than from the card loaded
+	// above, so wrong PINs sent at the same time cannot share an attempt
+	attempts, recorded, err := s.Repo.RecordPINFailure(card.ID, MaxPINAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		// The card stopped being active since it was loaded, usually because
+		// another wrong PIN blocked it
+		if card, err = s.Repo.GetCardByID(card.ID); err != nil {
+			return nil, err
+		}
+		if card.Status == model.CardPINBlocked {
+			return &PINResult{Card: card}, ErrCardPINBlocked
+		}
+		return &PINResult{Card: card}, ErrCardNotActive
+	}
+
+	card.PinFailedAttempts = attempts
+	if attempts >= MaxPINAttempts {
+		// The update that reached the limit blocked the card
+		card.Status = model.CardPINBlocked
+		return &PINResult{Card: card, JustBlocked: true}, ErrCardPINBlocked
+	}
+	return &PINResult{Card: card, AttemptsRemaining: MaxPINAttempts - attempts}, ErrIncorrectPIN
+}
+
+// recordPINAttempt counts a PIN attempt against the card's lockout; the soft
+// block still applies if the lockout cannot be recorded
+func (s *CardService) recordPINAttempt(ctx context.Context, card *model.Card, ok bool) {
+	if s.pinAttempts == nil {
+		return
+	}
+	var err error
+	if ok {
+		err = s.pinAttempts.Succeed(ctx, card.ID.String())
+	} else if _, err = s.pinAttempts.Fail(ctx, card.ID.String()); errors.Is(err, middleware.ErrLockedOut) {
+		err = nil
+	}
+	if err != nil {
+		slog.Warn("Failed to record PIN attempt", "card_id", card.ID, "error", err)
+	}
+}
+
+// UnblockPIN lifts a PIN soft block by setting a new PIN.
+// Callers must ensure the user has recently re-authenticated.
+func (s *CardService) UnblockPIN(userID, cardID, newPIN string) (*model.Card, error) {
+	if err := validatePIN(newPIN); err != nil {
+		return nil, err
+	}
+
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != model.CardPINBlocked {
+		return nil, ErrCardNotPINBlocked
+	}
+
+	card.Status = model.CardActive
+	if err := s.storePIN(card, newPIN); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+func (s *CardService) storePIN(card *model.Card, pin string) error {
+	hash, err := hashPIN(pin)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	card.PinHash = hash
+	card.PinFailedAttempts = 0
+	card.PinUpdatedAt = &now
+	return s.Repo.UpdateCard(card)
+}
+
+// validatePIN enforces length and rejects repeated or sequential digits
+func validatePIN(pin string) error {
+	if len(pin) < 4 || len(pin) > 6 {
+		return ErrInvalidPINFormat
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return ErrInvalidPINFormat
+		}
+	}
+
+	repeated, ascending, descending := true, true, true
+	for i := 1; i < len(pin); i++ {
+		if pin[i] != pin[0] {
+			repeated = false
+		}
+		if pin[i] != pin[i-1]+1 {
+			ascending = false
+		}
+		if pin[i] != pin[i-1]-1 {
+			descending = false
+		}
+	}
+	if repeated || ascending || descending {
+		return ErrWeakPIN
+	}
+	return nil
+}
+
+// hashPIN returns an Argon2id hash in PHC string format
+func hashPIN(pin string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(pin), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPINHash compares a PIN against a PHC-formatted Argon2id hash in constant time
+func verifyPINHash(pin, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errors.New("unsupported PIN hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, errors.New("unsupported argon2 version")
+	}
+
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, fmt.Errorf("invalid argon2 parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("invalid salt: %w", err)
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("invalid hash: %w", err)
+	}
+
+	actual := argon2.IDKey([]byte(pin), salt, iterations, memory, threads, uint32(len(expected)))
+	return subtle.ConstantTimeCompare(actual, expected) == 1, nil
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestCard(userID uuid.UUID) *model.Card {
+	return &model.Card{
+		ID:     uuid.New(),
+		UserID: userID,
+		Status: model.CardActive,
+	}
+}
+
+func TestValidatePIN(t *testing.T) {
+	tests := []struct {
+		pin     string
+		wantErr error
+	}{
+		{"2580", nil},
+		{"739146", nil},
+		{"123", ErrInvalidPINFormat},
+		{"1234567", ErrInvalidPINFormat},
+		{"12a4", ErrInvalidPINFormat},
+		{"1111", ErrWeakPIN},
+		{"1234", ErrWeakPIN},
+		{"9876", ErrWeakPIN},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pin, func(t *testing.T) {
+			assert.Equal(t, tt.wantErr, validatePIN(tt.pin))
+		})
+	}
+}
+
+func TestHashPIN_Argon2id(t *testing.T) {
+	hash, err := hashPIN("2580")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$"))
+	assert.NotContains(t, hash, "2580")
+
+	ok, err := verifyPINHash("2580", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = verifyPINHash("2581", hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestSetPIN_StoresHash(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("UpdateCard", card).Return(nil)
+
+	_, err := svc.SetPIN(userID.String(), card.ID.String(), "2580")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, card.PinHash)
+	assert.NotEqual(t, "2580", card.PinHash)
+	assert.NotNil(t, card.PinUpdatedAt)
+
+	// Another user cannot set the PIN
+	_, err = svc.SetPIN(uuid.New().String(), card.ID.String(), "2580")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestVerifyPIN_BlocksAfterMaxAttempts(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	card.PinHash, _ = hashPIN("2580")
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	for i := 1; i <= MaxPINAttempts; i++ {
+		mockRepo.On("RecordPINFailure", card.ID, MaxPINAttempts).Return(i, true, nil).Once()
+	}
+
+	for i := 1; i < MaxPINAttempts; i++ {
+		result, err := svc.VerifyPIN(card.ID.String(), "0000")
+		assert.ErrorIs(t, err, ErrIncorrectPIN)
+		assert.Equal(t, MaxPINAttempts-i, result.AttemptsRemaining)
+	}
+
+	result, err := svc.VerifyPIN(card.ID.String(), "0000")
+	assert.ErrorIs(t, err, ErrCardPINBlocked)
+	assert.True(t, result.JustBlocked)
+	assert.Equal(t, model.CardPINBlocked, card.Status)
+
+	// Even the correct PIN is rejected once blocked
+	_, err = svc.VerifyPIN(card.ID.String(), "2580")
+	assert.ErrorIs(t, err, ErrCardPINBlocked)
+	mockRepo.AssertNotCalled(t, "UpdateCard", mock.Anything)
+}
+
+func TestVerifyPIN_ConcurrentAttemptBlockedTheCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	card := newTestCard(uuid.New())
+	card.PinHash, _ = hashPIN("2580")
+	blocked := *card
+	blocked.Status = model.CardPINBlocked
+
+	// The card was active when loaded, but another wrong PIN blocked it
+	// before this one was counted
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil).Once()
+	mockRepo.On("RecordPINFailure", card.ID, MaxPINAttempts).Return(0, false, nil)
+	mockRepo.On("GetCardByID", card.ID).Return(&blocked, nil).Once()
+
+	result, err := svc.VerifyPIN(card.ID.String(), "0000")
+	assert.ErrorIs(t, err, ErrCardPINBlocked)
+	assert.False(t, result.JustBlocked)
+	assert.Equal(t, 0, result.AttemptsRemaining)
+}
+
+func TestVerifyPIN_LockoutOutlastsUnblock(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	svc.SetPINAttempts(middleware.NewAttemptLimiter("card_pin", middleware.NewInMemoryAttemptStore(), middleware.PINAttemptPolicy()))
+	userID := uuid.New()
+	card := newTestCard(userID)
+	card.PinHash, _ = hashPIN("2580")
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("UpdateCard", mock.AnythingOfType("*model.Card")).Return(nil)
+	for i := 1; i <= MaxPINAttempts; i++ {
+		mockRepo.On("RecordPINFailure", card.ID, MaxPINAttempts).Return(i, true, nil).Once()
+	}
+
+	for i := 0; i < MaxPINAttempts; i++ {
+		_, err := svc.VerifyPIN(card.ID.String(), "0000")
+		assert.Error(t, err)
+	}
+	_, err := svc.UnblockPIN(userID.String(), card.ID.String(), "1470")
+	assert.NoError(t, err)
+
+	// The new PIN is right, but verification stays locked out
+	_, err = svc.VerifyPIN(card.ID.String(), "1470")
+	var lockedOut *middleware.LockedOutError
+	assert.ErrorAs(t, err, &lockedOut)
+	assert.Equal(t, model.CardActive, card.Status)
+}
+
+func TestVerifyPIN_SuccessResetsCounter(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	card.PinHash, _ = hashPIN("2580")
+	card.PinFailedAttempts = 2
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("ResetPINFailures", card.ID).Return(nil)
+
+	_, err := svc.VerifyPIN(card.ID.String(), "2580")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, card.PinFailedAttempts)
+	mockRepo.AssertCalled(t, "ResetPINFailures", card.ID)
+}
+
+func TestUnblockPIN(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("UpdateCard", card).Return(nil)
+
+	// Only PIN-blocked cards can be unblocked
+	_, err := svc.UnblockPIN(userID.String(), card.ID.String(), "2580")
+	assert.ErrorIs(t, err, ErrCardNotPINBlocked)
+
+	card.Status = model.CardPINBlocked
+	card.PinFailedAttempts = MaxPINAttempts
+
+	_, err = svc.UnblockPIN(userID.String(), card.ID.String(), "2580")
+	assert.NoError(t, err)
+	assert.Equal(t, model.CardActive, card.Status)
+	assert.Equal(t, 0, card.PinFailedAttempts)
+}
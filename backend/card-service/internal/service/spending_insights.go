@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// insightsCacheTTL bounds how stale cached insights can get if an
+// invalidation is missed
+const insightsCacheTTL = time.Hour
+
+// maxCardTransactions caps one page of the card transaction feed
+const maxCardTransactions = 200
+
+var (
+	ErrTransactionFeedDisabled = errors.New("card transaction feed is not configured")
+	ErrInvalidSettlement       = errors.New("invalid card settlement")
+	ErrInvalidInsightsMonth    = errors.New("month must be YYYY-MM and not in the future")
+)
+
+var (
+	mccPattern      = regexp.MustCompile(`^[0-9]{4}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// Spending categories that merchant category codes are grouped into
+const (
+	CategoryGroceries     = "GROCERIES"
+	CategoryDining        = "DINING"
+	CategoryTravel        = "TRAVEL"
+	CategoryTransport     = "TRANSPORT"
+	CategoryFuel          = "FUEL"
+	CategoryShopping      = "SHOPPING"
+	CategoryUtilities     = "UTILITIES"
+	CategoryHealth        = "HEALTH"
+	CategoryEntertainment = "ENTERTAINMENT"
+	CategoryOther         = "OTHER"
+)
+
+// Trends of a category's spend against the previous month
+const (
+	TrendUp   = "UP"
+	TrendDown = "DOWN"
+	TrendFlat = "FLAT"
+	// TrendNew is spend in a category with none the previous month
+	TrendNew = "NEW"
+)
+
+// CardTransactionRepository stores the card transaction feed
+type CardTransactionRepository interface {
+	CreateCardTransaction(t *model.CardTransaction) (bool, error)
+	GetCardTransactionByReference(reference string) (*model.CardTransaction, error)
+	ListCardTransactions(cardID uuid.UUID, limit int) ([]model.CardTransaction, error)
+	SpendByMCC(cardID uuid.UUID, from, to time.Time) ([]model.MCCSpend, error)
+}
+
+// InsightsCache keeps computed spending insights
+type InsightsCache interface {
+	// Get loads a cached value into dest, reporting whether there was one
+	Get(ctx context.Context, key string, dest interface{}) (bool, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// RedisInsightsCache keeps spending insights in Redis
+type RedisInsightsCache struct {
+	client *cache.RedisClient
+}
+
+func NewRedisInsightsCache(client *cache.RedisClient) *RedisInsightsCache {
+	return &RedisInsightsCache{client: client}
+}
+
+func (c *RedisInsightsCache) Get(ctx context.Context, key string, dest interface{}) (bool, error) {
+	val, err := c.client.Get(ctx, key)
+	if err != nil || val == "" {
+		return false, err
+	}
+	return true, json.Unmarshal([]byte(val), dest)
+}
+
+func (c *RedisInsightsCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	return c.client.SetJSON(ctx, key, value, ttl)
+}
+
+func (c *RedisInsightsCache) Delete(ctx context.Context, key string) error {
+	return c.client.Delete(ctx, key)
+}
+
+// Settlement is a card payment settled by the card network
+type Settlement struct {
+	CardID               string
+	NetworkReference     string
+	Amount               decimal.Decimal
+	Currency             string
+	MerchantName         string
+	MerchantCategoryCode string
+	MerchantCountry      string
+	SettledAt            time.Time
+}
+
+// CategorySpend is a card's spend in one category in a month and the month before
+type CategorySpend struct {
+	Category         string          `json:"category"`
+	Amount           decimal.Decimal `json:"amount"`
+	TransactionCount int             `json:"transaction_count"`
+	PreviousAmount   decimal.Decimal `json:"previous_amount"`
+	// ChangePercent is nil when there was no spend the previous month
+	ChangePercent *decimal.Decimal `json:"change_percent"`
+	Trend         string           `json:"trend"`
+}
+
+// SpendingInsights is a card's spend in a month grouped by merchant category,
+// with the trend against the previous month
+type SpendingInsights struct {
+	CardID             uuid.UUID        `json:"card_id"`
+	Month              string           `json:"month"`
+	TotalSpend         decimal.Decimal  `json:"total_spend"`
+	PreviousMonthSpend decimal.Decimal  `json:"previous_month_spend"`
+	ChangePercent      *decimal.Decimal `json:"change_percent"`
+	Trend              string           `json:"trend"`
+	Categories         []CategorySpend  `json:"categories"`
+}
+
+// SetTransactionFeed enables the card transaction feed and spending insights.
+// insights may be nil, in which case insights are computed on every request.
+func (s *CardService) SetTransactionFeed(repo CardTransactionRepository, insights InsightsCache) {
+	s.transactions = repo
+	s.insightsCache = insights
+}
+
+// RecordSettlement adds a settled transaction to the feed and invalidates the
+// cached insights it changes. A settlement already recorded is returned with
+// created false.
+func (s *CardService) RecordSettlement(ctx context.Context, in Settlement) (*model.CardTransaction, bool, error) {
+	if s.transactions == nil {
+		return nil, false, ErrTransactionFeedDisabled
+	}
+	in.Currency = strings.ToUpper(in.Currency)
+	in.MerchantCountry = strings.ToUpper(in.MerchantCountry)
+	invalid := func(msg string) error { return fmt.Errorf("%w: %s", ErrInvalidSettlement, msg) }
+	switch {
+	case in.NetworkReference == "" || len(in.NetworkReference) > 64:
+		return nil, false, invalid("network_reference must be 1-64 characters")
+	case !in.Amount.IsPositive():
+		return nil, false, invalid("amount must be greater than zero")
+	case !currencyPattern.MatchString(in.Currency):
+		return nil, false, invalid("currency must be a 3-letter code")
+	case !mccPattern.MatchString(in.MerchantCategoryCode):
+		return nil, false, invalid("merchant_category_code must be 4 digits")
+	case in.MerchantCountry != "" && !isCountryCode(in.MerchantCountry):
+		return nil, false, ErrInvalidMerchantCountry
+	}
+	if in.SettledAt.IsZero() {
+		in.SettledAt = time.Now()
+	}
+
+	cardUUID, err := uuid.Parse(in.CardID)
+	if err != nil {
+		return nil, false, errors.New("invalid card id")
+	}
+	card, err := s.Repo.GetCardByID(cardUUID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	merchant := strings.TrimSpace(in.MerchantName)
+	if len(merchant) > 100 {
+		merchant = merchant[:100]
+	}
+	txn := &model.CardTransaction{
+		CardID:               card.ID,
+		UserID:               card.UserID,
+		NetworkReference:     in.NetworkReference,
+		Amount:               in.Amount,
+		Currency:             in.Currency,
+		MerchantName:         merchant,
+		MerchantCategoryCode: in.MerchantCategoryCode,
+		MerchantCountry:      in.MerchantCountry,
+		SettledAt:            in.SettledAt.UTC(),
+	}
+	created, err := s.transactions.CreateCardTransaction(txn)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		existing, err := s.transactions.GetCardTransactionByReference(in.NetworkReference)
+		return existing, false, err
+	}
+
+	// The settlement month's insights change, and so does the next month's trend
+	month := monthStart(txn.SettledAt)
+	s.invalidateInsights(ctx, card.ID, month)
+	s.invalidateInsights(ctx, card.ID, month.AddDate(0, 1, 0))
+	return txn, true, nil
+}
+
+// ListCardTransactions returns the most recent settled transactions of a card
+// the user owns
+func (s *CardService) ListCardTransactions(userID, cardID string, limit int) ([]model.CardTransaction, error) {
+	if s.transactions == nil {
+		return nil, ErrTransactionFeedDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > maxCardTransactions {
+		limit = maxCardTransactions
+	}
+	return s.transactions.ListCardTransactions(card.ID, limit)
+}
+
+// SpendingInsights returns a card's spend in the month starting at month,
+// grouped by merchant category and compared with the previous month
+func (s *CardService) SpendingInsights(ctx context.Context, userID, cardID string, month time.Time) (*SpendingInsights, error) {
+	if s.transactions == nil {
+		return nil, ErrTransactionFeedDisabled
+	}
+	month = monthStart(month)
+	if month.After(time.Now()) {
+		return nil, ErrInvalidInsightsMonth
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	key := insightsCacheKey(card.ID, month)
+	if s.insightsCache != nil {
+		var cached SpendingInsights
+		if ok, err := s.insightsCache.Get(ctx, key, &cached); err != nil {
+			slog.Warn("Failed to read cached spending insights", "card_id", card.ID, "error", err)
+		} else if ok {
+			return &cached, nil
+		}
+	}
+
+	current, err := s.transactions.SpendByMCC(card.ID, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.transactions.SpendByMCC(card.ID, month.AddDate(0, -1, 0), month)
+	if err != nil {
+		return nil, err
+	}
+	insights := buildInsights(card.ID, month, current, previous)
+
+	if s.insightsCache != nil {
+		if err := s.insightsCache.Set(ctx, key, insights, insightsCacheTTL); err != nil {
+			slog.Warn("Failed to cache spending insights", "card_id", card.ID, "error", err)
+		}
+	}
+	return insights, nil
+}
+
+func (s *CardService) invalidateInsights(ctx context.Context, cardID uuid.UUID, month time.Time) {
+	if s.insightsCache == nil {
+		return
+	}
+	if err := s.insightsCache.Delete(ctx, insightsCacheKey(cardID, month)); err != nil {
+		slog.Warn("Failed to invalidate spending insights", "card_id", cardID, "month", month.Format("2006-01"), "error", err)
+	}
+}
+
+// buildInsights groups per-MCC spend of two consecutive months into categories
+func buildInsights(cardID uuid.UUID, month time.Time, current, previous []model.MCCSpend) *SpendingInsights {
+	byCategory := make(map[string]*CategorySpend)
+	get := func(mcc string) *CategorySpend {
+		category := MerchantCategory(mcc)
+		if byCategory[category] == nil {
+			byCategory[category] = &CategorySpend{Category: category}
+		}
+		return byCategory[category]
+	}
+	insights := &SpendingInsights{CardID: cardID, Month: month.Format("2006-01")}
+	for _, spend := range current {
+		c := get(spend.MerchantCategoryCode)
+		c.Amount = c.Amount.Add(spend.Amount)
+		c.TransactionCount += spend.Count
+		insights.TotalSpend = insights.TotalSpend.Add(spend.Amount)
+	}
+	for _, spend := range previous {
+		c := get(spend.MerchantCategoryCode)
+		c.PreviousAmount = c.PreviousAmount.Add(spend.Amount)
+		insights.PreviousMonthSpend = insights.PreviousMonthSpend.Add(spend.Amount)
+	}
+
+	insights.Categories = make([]CategorySpend, 0, len(byCategory))
+	for _, c := range byCategory {
+		c.ChangePercent, c.Trend = trend(c.Amount, c.PreviousAmount)
+		insights.Categories = append(insights.Categories, *c)
+	}
+	sort.Slice(insights.Categories, func(i, j int) bool {
+		a, b := insights.Categories[i], insights.Categories[j]
+		if !a.Amount.Equal(b.Amount) {
+			return a.Amount.GreaterThan(b.Amount)
+		}
+		return a.Category < b.Category
+	})
+	insights.ChangePercent, insights.Trend = trend(insights.TotalSpend, insights.PreviousMonthSpend)
+	return insights
+}
+
+// trend compares spend with the previous month's, as a percentage change
+// rounded to one decimal place
+func trend(amount, previous decimal.Decimal) (*decimal.Decimal, string) {
+	if previous.IsZero() {
+		if amount.IsZero() {
+			return nil, TrendFlat
+		}
+		return nil, TrendNew
+	}
+	change := amount.Sub(previous).Div(previous).Mul(decimal.NewFromInt(100)).Round(1)
+	switch {
+	case change.IsPositive():
+		return &change, TrendUp
+	case change.IsNegative():
+		return &change, TrendDown
+	default:
+		return &change, TrendFlat
+	}
+}
+
+// MerchantCategory maps an ISO 18245 merchant category code to a spending category
+func MerchantCategory(mcc string) string {
+	code, err := strconv.Atoi(mcc)
+	if err != nil {
+		return CategoryOther
+	}
+	switch {
+	case code >= 3000 && code <= 3999, code == 4411, code == 4511, code == 4722, code == 7011:
+		// Airlines, car rental and hotel chains, cruises, travel agencies
+		return CategoryTravel
+	case code >= 4111 && code <= 4131, code == 4784, code == 7523:
+		return CategoryTransport
+	case code == 5541, code == 5542, code == 5983:
+		return CategoryFuel
+	case code >= 5411 && code <= 5499:
+		return CategoryGroceries
+	case code >= 5811 && code <= 5814:
+		return CategoryDining
+	case code >= 4812 && code <= 4900:
+		return CategoryUtilities
+	case code == 5912, code == 5975, code == 5976, code >= 8011 && code <= 8099:
+		return CategoryHealth
+	case code >= 5815 && code <= 5818, code >= 7832 && code <= 7841, code >= 7911 && code <= 7999:
+		return CategoryEntertainment
+	case code >= 5200 && code <= 5999:
+		return CategoryShopping
+	default:
+		return CategoryOther
+	}
+}
+
+func insightsCacheKey(cardID uuid.UUID, month time.Time) string {
+	return "card_insights:" + cardID.String() + ":" + month.Format("2006-01")
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
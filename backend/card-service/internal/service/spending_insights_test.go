@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryCardTransactions is an in-memory CardTransactionRepository
+type memoryCardTransactions struct {
+	txns []model.CardTransaction
+}
+
+func (m *memoryCardTransactions) CreateCardTransaction(t *model.CardTransaction) (bool, error) {
+	for _, existing := range m.txns {
+		if existing.NetworkReference == t.NetworkReference {
+			return false, nil
+		}
+	}
+	t.ID = uuid.New()
+	m.txns = append(m.txns, *t)
+	return true, nil
+}
+
+func (m *memoryCardTransactions) GetCardTransactionByReference(reference string) (*model.CardTransaction, error) {
+	for _, t := range m.txns {
+		if t.NetworkReference == reference {
+			return &t, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryCardTransactions) ListCardTransactions(cardID uuid.UUID, limit int) ([]model.CardTransaction, error) {
+	var out []model.CardTransaction
+	for _, t := range m.txns {
+		if t.CardID == cardID && len(out) < limit {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryCardTransactions) SpendByMCC(cardID uuid.UUID, from, to time.Time) ([]model.MCCSpend, error) {
+	byMCC := map[string]*model.MCCSpend{}
+	var out []model.MCCSpend
+	for _, t := range m.txns {
+		if t.CardID != cardID || t.SettledAt.Before(from) || !t.SettledAt.Before(to) {
+			continue
+		}
+		if byMCC[t.MerchantCategoryCode] == nil {
+			byMCC[t.MerchantCategoryCode] = &model.MCCSpend{MerchantCategoryCode: t.MerchantCategoryCode}
+		}
+		byMCC[t.MerchantCategoryCode].Amount = byMCC[t.MerchantCategoryCode].Amount.Add(t.Amount)
+		byMCC[t.MerchantCategoryCode].Count++
+	}
+	for _, s := range byMCC {
+		out = append(out, *s)
+	}
+	return out, nil
+}
+
+// memoryInsightsCache is an InsightsCache backed by a map of JSON values
+type memoryInsightsCache struct {
+	values map[string][]byte
+}
+
+func (c *memoryInsightsCache) Get(_ context.Context, key string, dest interface{}) (bool, error) {
+	v, ok := c.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(v, dest)
+}
+
+func (c *memoryInsightsCache) Set(_ context.Context, key string, value interface{}, _ time.Duration) error {
+	v, err := json.Marshal(value)
+	c.values[key] = v
+	return err
+}
+
+func (c *memoryInsightsCache) Delete(_ context.Context, key string) error {
+	delete(c.values, key)
+	return nil
+}
+
+func TestMerchantCategory(t *testing.T) {
+	tests := map[string]string{
+		"5411": CategoryGroceries,
+		"5812": CategoryDining,
+		"3058": CategoryTravel,
+		"4511": CategoryTravel,
+		"4121": CategoryTransport,
+		"5541": CategoryFuel,
+		"4900": CategoryUtilities,
+		"5912": CategoryHealth,
+		"7832": CategoryEntertainment,
+		"5651": CategoryShopping,
+		"6011": CategoryOther,
+		"abcd": CategoryOther,
+	}
+	for mcc, want := range tests {
+		assert.Equal(t, want, MerchantCategory(mcc), mcc)
+	}
+}
+
+func TestSpendingInsights_GroupsByCategoryWithTrend(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	feed := &memoryCardTransactions{}
+	svc.SetTransactionFeed(feed, nil)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+	month := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	settle := func(ref, mcc, amount string, at time.Time) {
+		_, created, err := svc.RecordSettlement(context.Background(), Settlement{
+			CardID: card.ID.String(), NetworkReference: ref, Amount: decimal.RequireFromString(amount),
+			Currency: "usd", MerchantCategoryCode: mcc, SettledAt: at,
+		})
+		require.NoError(t, err)
+		require.True(t, created)
+	}
+	settle("a", "5411", "60", month.AddDate(0, 0, 2))
+	settle("b", "5499", "40", month.AddDate(0, 0, 20))
+	settle("c", "5812", "30", month.AddDate(0, 0, 5))
+	settle("d", "5411", "80", month.AddDate(0, -1, 3))
+	settle("e", "4121", "25", month.AddDate(0, -1, 10))
+	settle("f", "5411", "999", month.AddDate(0, 1, 0)) // next month
+
+	insights, err := svc.SpendingInsights(context.Background(), userID.String(), card.ID.String(), month.AddDate(0, 0, 14))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-09", insights.Month)
+	assert.Equal(t, "130", insights.TotalSpend.String())
+	assert.Equal(t, "105", insights.PreviousMonthSpend.String())
+	assert.Equal(t, "23.8", insights.ChangePercent.String())
+	assert.Equal(t, TrendUp, insights.Trend)
+
+	require.Len(t, insights.Categories, 3)
+	groceries, dining, transport := insights.Categories[0], insights.Categories[1], insights.Categories[2]
+	assert.Equal(t, CategoryGroceries, groceries.Category)
+	assert.Equal(t, "100", groceries.Amount.String())
+	assert.Equal(t, 2, groceries.TransactionCount)
+	assert.Equal(t, "25", groceries.ChangePercent.String())
+	assert.Equal(t, TrendUp, groceries.Trend)
+	assert.Equal(t, CategoryDining, dining.Category)
+	assert.Nil(t, dining.ChangePercent)
+	assert.Equal(t, TrendNew, dining.Trend)
+	// Categories with spend only the previous month are shown as dropping to zero
+	assert.Equal(t, CategoryTransport, transport.Category)
+	assert.True(t, transport.Amount.IsZero())
+	assert.Equal(t, "-100", transport.ChangePercent.String())
+	assert.Equal(t, TrendDown, transport.Trend)
+}
+
+func TestSpendingInsights_CacheInvalidatedOnSettlement(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	feed := &memoryCardTransactions{}
+	insightsCache := &memoryInsightsCache{values: map[string][]byte{}}
+	svc.SetTransactionFeed(feed, insightsCache)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+	now := time.Now().UTC()
+	settlement := Settlement{CardID: card.ID.String(), NetworkReference: "ref-1", Amount: decimal.NewFromInt(10),
+		Currency: "USD", MerchantCategoryCode: "5812", SettledAt: now}
+	_, _, err := svc.RecordSettlement(context.Background(), settlement)
+	require.NoError(t, err)
+
+	insights, err := svc.SpendingInsights(context.Background(), userID.String(), card.ID.String(), now)
+	require.NoError(t, err)
+	assert.Equal(t, "10", insights.TotalSpend.String())
+	assert.Contains(t, insightsCache.values, insightsCacheKey(card.ID, monthStart(now)))
+
+	// A duplicate delivery changes nothing; a new settlement refreshes the insights
+	_, created, err := svc.RecordSettlement(context.Background(), settlement)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Contains(t, insightsCache.values, insightsCacheKey(card.ID, monthStart(now)))
+
+	settlement.NetworkReference = "ref-2"
+	_, _, err = svc.RecordSettlement(context.Background(), settlement)
+	require.NoError(t, err)
+	assert.NotContains(t, insightsCache.values, insightsCacheKey(card.ID, monthStart(now)))
+
+	insights, err = svc.SpendingInsights(context.Background(), userID.String(), card.ID.String(), now)
+	require.NoError(t, err)
+	assert.Equal(t, "20", insights.TotalSpend.String())
+}
+
+func TestRecordSettlement_Validation(t *testing.T) {
+	svc := NewCardService(new(MockCardRepository))
+	svc.SetTransactionFeed(&memoryCardTransactions{}, nil)
+	valid := func() Settlement {
+		return Settlement{CardID: uuid.New().String(), NetworkReference: "ref", Amount: decimal.NewFromInt(5),
+			Currency: "USD", MerchantCategoryCode: "5411"}
+	}
+	tests := map[string]func(*Settlement){
+		"missing reference": func(s *Settlement) { s.NetworkReference = "" },
+		"zero amount":       func(s *Settlement) { s.Amount = decimal.Zero },
+		"bad currency":      func(s *Settlement) { s.Currency = "US" },
+		"bad mcc":           func(s *Settlement) { s.MerchantCategoryCode = "54A1" },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := valid()
+			mutate(&s)
+			_, _, err := svc.RecordSettlement(context.Background(), s)
+			assert.ErrorIs(t, err, ErrInvalidSettlement)
+		})
+	}
+
+	_, err := svc.SpendingInsights(context.Background(), uuid.New().String(), uuid.New().String(), time.Now().AddDate(0, 2, 0))
+	assert.ErrorIs(t, err, ErrInvalidInsightsMonth)
+
+	_, err = NewCardService(new(MockCardRepository)).SpendingInsights(context.Background(), uuid.New().String(), uuid.New().String(), time.Now())
+	assert.ErrorIs(t, err, ErrTransactionFeedDisabled)
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// DefaultBalanceCheckTimeout is how long an authorization waits for the
+// ledger's balance before falling back to stand-in processing
+const DefaultBalanceCheckTimeout = 2 * time.Second
+
+// standInVerifyBatch is how many accounts one verification run checks
+const standInVerifyBatch = 200
+
+const (
+	// DeclineInsufficientFunds is a payment the account's available balance does not cover
+	DeclineInsufficientFunds DeclineReason = "INSUFFICIENT_FUNDS"
+	// DeclineStandInLimit is a payment over the stand-in limit while the ledger is unreachable
+	DeclineStandInLimit DeclineReason = "EXCEEDS_STAND_IN_LIMIT"
+	// DeclineIssuerUnavailable is a payment the ledger did not answer for and
+	// no cached balance could stand in for
+	DeclineIssuerUnavailable DeclineReason = "ISSUER_UNAVAILABLE"
+)
+
+var (
+	ErrLedgerUnavailable        = errors.New("ledger service is unavailable")
+	ErrStandInDisabled          = errors.New("stand-in processing is not configured")
+	ErrStandInNotFound          = errors.New("stand-in authorization not found")
+	ErrStandInNotFlagged        = errors.New("only flagged stand-in authorizations can be reviewed")
+	ErrInvalidStandInStatus     = errors.New("status must be PENDING, VERIFIED, FLAGGED or REVIEWED")
+	ErrStandInReviewNoteMissing = errors.New("a review note is required")
+)
+
+// BalanceSource reads available balances from the ledger
+type BalanceSource interface {
+	// AvailableBalance returns an error wrapping ErrLedgerUnavailable when the
+	// ledger could not be reached or did not answer before ctx was done
+	AvailableBalance(ctx context.Context, accountID uuid.UUID) (decimal.Decimal, error)
+}
+
+// StandInRepository stores the cached balances and the approvals made
+// against them
+type StandInRepository interface {
+	SaveAccountBalance(balance *model.AccountBalance) error
+	GetAccountBalance(accountID uuid.UUID) (*model.AccountBalance, error)
+	// CreateStandInAuthorization stores a PENDING approval if the account's
+	// cached balance, less its other pending approvals, covers it. It returns
+	// false without storing anything if not.
+	CreateStandInAuthorization(auth *model.StandInAuthorization) (bool, error)
+	GetStandInAuthorization(id uuid.UUID) (*model.StandInAuthorization, error)
+	// ListStandInAuthorizations returns the newest approvals first, all of them
+	// for an empty status
+	ListStandInAuthorizations(status model.StandInStatus, limit int) ([]model.StandInAuthorization, error)
+	// ListPendingStandInAccounts returns accounts with PENDING approvals
+	ListPendingStandInAccounts(limit int) ([]uuid.UUID, error)
+	// ListPendingStandInAuthorizations returns an account's PENDING approvals, oldest first
+	ListPendingStandInAuthorizations(accountID uuid.UUID) ([]model.StandInAuthorization, error)
+	// UpdateStandInAuthorization saves the approval if it is still in status from
+	UpdateStandInAuthorization(auth *model.StandInAuthorization, from model.StandInStatus) (bool, error)
+}
+
+// StandInConfig sets how authorizations are decided while the ledger is unreachable
+type StandInConfig struct {
+	// Limit is the largest payment approved in stand-in; zero declines them all
+	Limit decimal.Decimal
+	// Timeout bounds the ledger balance check; it defaults to DefaultBalanceCheckTimeout
+	Timeout time.Duration
+}
+
+// SetStandIn enables checking authorizations against the ledger balance, with
+// stand-in processing when the ledger does not answer in time
+func (s *CardService) SetStandIn(balances BalanceSource, repo StandInRepository, cfg StandInConfig) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultBalanceCheckTimeout
+	}
+	s.balances = balances
+	s.standIn = repo
+	s.standInConfig = cfg
+}
+
+// checkFunds declines a payment the card's account cannot cover. When the
+// ledger does not answer, it decides in stand-in against the cached balance.
+func (s *CardService) checkFunds(result *TokenAuthorization, card *model.Card, amount decimal.Decimal, merchant Merchant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.standInConfig.Timeout)
+	defer cancel()
+	available, err := s.balances.AvailableBalance(ctx, card.AccountID)
+	if errors.Is(err, ErrLedgerUnavailable) {
+		slog.Warn("Ledger balance check failed, authorizing in stand-in", "card_id", card.ID, "error", err)
+		return s.standInDecision(result, card, amount, merchant)
+	}
+	if err != nil {
+		return err
+	}
+
+	balance := &model.AccountBalance{AccountID: card.AccountID, AvailableBalance: available, FetchedAt: time.Now()}
+	if err := s.standIn.SaveAccountBalance(balance); err != nil {
+		// Stand-in falls back to an older balance; the authorization goes on
+		slog.Warn("Failed to cache account balance", "account_id", card.AccountID, "error", err)
+	}
+	if amount.GreaterThan(available) {
+		result.DeclineReason = DeclineInsufficientFunds
+	}
+	return nil
+}
+
+// standInDecision approves payments up to the stand-in limit that the cached
+// balance, less the account's earlier stand-in approvals, still covers. The
+// approvals are queued for verification once the ledger is back.
+func (s *CardService) standInDecision(result *TokenAuthorization, card *model.Card, amount decimal.Decimal, merchant Merchant) error {
+	result.StandIn = true
+	if amount.GreaterThan(s.standInConfig.Limit) {
+		result.DeclineReason = DeclineStandInLimit
+		return nil
+	}
+	cached, err := s.standIn.GetAccountBalance(card.AccountID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		result.DeclineReason = DeclineIssuerUnavailable
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	auth := &model.StandInAuthorization{
+		ID:               uuid.New(),
+		CardID:           card.ID,
+		AccountID:        card.AccountID,
+		Amount:           amount,
+		MerchantID:       merchant.ID,
+		MerchantName:     merchant.Name,
+		CachedBalance:    cached.AvailableBalance,
+		BalanceFetchedAt: cached.FetchedAt,
+		Status:           model.StandInPending,
+	}
+	created, err := s.standIn.CreateStandInAuthorization(auth)
+	if err != nil {
+		return err
+	}
+	if !created {
+		result.DeclineReason = DeclineInsufficientFunds
+		return nil
+	}
+	result.StandInID = &auth.ID
+	slog.Warn("Payment authorized in stand-in", "stand_in_id", auth.ID, "card_id", card.ID, "amount", amount.String(),
+		"cached_balance", cached.AvailableBalance.String(), "balance_fetched_at", cached.FetchedAt)
+	return nil
+}
+
+// VerifyStandInAuthorizations settles the queue of stand-in approvals against
+// the balances the ledger reports now. An account's approvals are verified
+// oldest first while its available balance covers them; the rest went over
+// what the account had and are flagged for review. It stops at the first
+// account the ledger does not answer for.
+func (s *CardService) VerifyStandInAuthorizations(ctx context.Context) (verified, flagged int, err error) {
+	if s.standIn == nil {
+		return 0, 0, ErrStandInDisabled
+	}
+	accounts, err := s.standIn.ListPendingStandInAccounts(standInVerifyBatch)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, accountID := range accounts {
+		checkCtx, cancel := context.WithTimeout(ctx, s.standInConfig.Timeout)
+		available, err := s.balances.AvailableBalance(checkCtx, accountID)
+		cancel()
+		if errors.Is(err, ErrLedgerUnavailable) {
+			return verified, flagged, err
+		}
+		if err != nil {
+			slog.Error("Failed to read balance for stand-in verification", "account_id", accountID, "error", err)
+			continue
+		}
+		if err := s.standIn.SaveAccountBalance(&model.AccountBalance{AccountID: accountID, AvailableBalance: available, FetchedAt: time.Now()}); err != nil {
+			slog.Warn("Failed to cache account balance", "account_id", accountID, "error", err)
+		}
+
+		pending, err := s.standIn.ListPendingStandInAuthorizations(accountID)
+		if err != nil {
+			return verified, flagged, err
+		}
+		remaining := available
+		now := time.Now()
+		for i := range pending {
+			auth := &pending[i]
+			auth.VerifiedBalance = &available
+			auth.VerifiedAt = &now
+			if remaining.GreaterThanOrEqual(auth.Amount) {
+				remaining = remaining.Sub(auth.Amount)
+				auth.Status = model.StandInVerified
+			} else {
+				auth.Status = model.StandInFlagged
+				auth.FlagReason = fmt.Sprintf("approved over the available balance of %s the ledger reported at verification", available.StringFixed(2))
+			}
+			ok, err := s.standIn.UpdateStandInAuthorization(auth, model.StandInPending)
+			if err != nil {
+				return verified, flagged, err
+			}
+			if !ok {
+				// Another replica verified it first
+				continue
+			}
+			if auth.Status == model.StandInFlagged {
+				flagged++
+				slog.Warn("Stand-in authorization flagged for review", "stand_in_id", auth.ID, "account_id", accountID,
+					"amount", auth.Amount.String(), "available_balance", available.String())
+			} else {
+				verified++
+			}
+		}
+	}
+	return verified, flagged, nil
+}
+
+// StandInVerificationJob verifies queued stand-in approvals
+func (s *CardService) StandInVerificationJob(ctx context.Context, _ *jobs.Job) error {
+	verified, flagged, err := s.VerifyStandInAuthorizations(ctx)
+	if verified > 0 || flagged > 0 {
+		slog.Info("Verified stand-in authorizations", "verified", verified, "flagged", flagged)
+	}
+	return err
+}
+
+// ListStandInAuthorizations returns stand-in approvals, newest first,
+// optionally only those in one status, e.g. FLAGGED for the review queue
+func (s *CardService) ListStandInAuthorizations(status model.StandInStatus, limit int) ([]model.StandInAuthorization, error) {
+	if s.standIn == nil {
+		return nil, ErrStandInDisabled
+	}
+	switch status {
+	case "", model.StandInPending, model.StandInVerified, model.StandInFlagged, model.StandInReviewed:
+	default:
+		return nil, ErrInvalidStandInStatus
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return s.standIn.ListStandInAuthorizations(status, limit)
+}
+
+// ReviewStandInAuthorization closes the review of a flagged stand-in approval
+func (s *CardService) ReviewStandInAuthorization(adminID, id, note string) (*model.StandInAuthorization, error) {
+	if s.standIn == nil {
+		return nil, ErrStandInDisabled
+	}
+	reviewer, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, ErrInvalidUserID
+	}
+	if note == "" {
+		return nil, ErrStandInReviewNoteMissing
+	}
+	authID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrStandInNotFound
+	}
+	auth, err := s.standIn.GetStandInAuthorization(authID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStandInNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if auth.Status != model.StandInFlagged {
+		return nil, ErrStandInNotFlagged
+	}
+
+	now := time.Now()
+	auth.Status = model.StandInReviewed
+	auth.ReviewedBy = &reviewer
+	auth.ReviewNote = note
+	auth.ReviewedAt = &now
+	ok, err := s.standIn.UpdateStandInAuthorization(auth, model.StandInFlagged)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrStandInNotFlagged
+	}
+	return auth, nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryStandIn is an in-memory StandInRepository with the same conditional
+// insert and updates as the database one
+type memoryStandIn struct {
+	balances map[uuid.UUID]model.AccountBalance
+	auths    []*model.StandInAuthorization
+}
+
+func newMemoryStandIn() *memoryStandIn {
+	return &memoryStandIn{balances: map[uuid.UUID]model.AccountBalance{}}
+}
+
+func (m *memoryStandIn) SaveAccountBalance(balance *model.AccountBalance) error {
+	m.balances[balance.AccountID] = *balance
+	return nil
+}
+
+func (m *memoryStandIn) GetAccountBalance(accountID uuid.UUID) (*model.AccountBalance, error) {
+	balance, ok := m.balances[accountID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &balance, nil
+}
+
+func (m *memoryStandIn) CreateStandInAuthorization(auth *model.StandInAuthorization) (bool, error) {
+	balance, ok := m.balances[auth.AccountID]
+	if !ok {
+		return false, gorm.ErrRecordNotFound
+	}
+	available := balance.AvailableBalance
+	for _, a := range m.auths {
+		if a.AccountID == auth.AccountID && a.Status == model.StandInPending {
+			available = available.Sub(a.Amount)
+		}
+	}
+	if available.LessThan(auth.Amount) {
+		return false, nil
+	}
+	stored := *auth
+	stored.CreatedAt = time.Now().Add(time.Duration(len(m.auths)) * time.Millisecond)
+	m.auths = append(m.auths, &stored)
+	return true, nil
+}
+
+func (m *memoryStandIn) GetStandInAuthorization(id uuid.UUID) (*model.StandInAuthorization, error) {
+	for _, a := range m.auths {
+		if a.ID == id {
+			found := *a
+			return &found, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryStandIn) ListStandInAuthorizations(status model.StandInStatus, limit int) ([]model.StandInAuthorization, error) {
+	var out []model.StandInAuthorization
+	for i := len(m.auths) - 1; i >= 0 && len(out) < limit; i-- {
+		if status == "" || m.auths[i].Status == status {
+			out = append(out, *m.auths[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryStandIn) ListPendingStandInAccounts(limit int) ([]uuid.UUID, error) {
+	seen := map[uuid.UUID]bool{}
+	var out []uuid.UUID
+	for _, a := range m.auths {
+		if a.Status == model.StandInPending && !seen[a.AccountID] && len(out) < limit {
+			seen[a.AccountID] = true
+			out = append(out, a.AccountID)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryStandIn) ListPendingStandInAuthorizations(accountID uuid.UUID) ([]model.StandInAuthorization, error) {
+	var out []model.StandInAuthorization
+	for _, a := range m.auths {
+		if a.AccountID == accountID && a.Status == model.StandInPending {
+			out = append(out, *a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (m *memoryStandIn) UpdateStandInAuthorization(auth *model.StandInAuthorization, from model.StandInStatus) (bool, error) {
+	for i, a := range m.auths {
+		if a.ID == auth.ID {
+			if a.Status != from {
+				return false, nil
+			}
+			stored := *auth
+			m.auths[i] = &stored
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// fakeBalances answers balance checks with fixed balances, or fails them all
+// as if the ledger were down
+type fakeBalances struct {
+	balances map[uuid.UUID]decimal.Decimal
+	down     bool
+}
+
+func (f *fakeBalances) AvailableBalance(_ context.Context, accountID uuid.UUID) (decimal.Decimal, error) {
+	if f.down {
+		return decimal.Zero, fmt.Errorf("%w: %v", ErrLedgerUnavailable, context.DeadlineExceeded)
+	}
+	return f.balances[accountID], nil
+}
+
+// newStandInTestService returns a service with stand-in processing and a
+// card whose network token authorizes with standInTestToken
+func newStandInTestService(t *testing.T, limit int64) (*CardService, *memoryStandIn, *fakeBalances, *model.Card) {
+	encrypted, err := encryptCardNumber(standInTestToken)
+	require.NoError(t, err)
+
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	card := newTestCard(uuid.New())
+	card.AccountID = uuid.New()
+	card.DailyLimit = decimal.NewFromInt(1000)
+	mockRepo.On("GetNetworkTokenByHash", mock.Anything).Return(&model.NetworkToken{
+		ID:             uuid.New(),
+		CardID:         card.ID,
+		EncryptedToken: encrypted,
+		DeviceID:       "iphone-1",
+		Status:         model.NetworkTokenActive,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	}, nil)
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+	repo := newMemoryStandIn()
+	balances := &fakeBalances{balances: map[uuid.UUID]decimal.Decimal{}}
+	svc.SetStandIn(balances, repo, StandInConfig{Limit: decimal.NewFromInt(limit)})
+	return svc, repo, balances, card
+}
+
+const standInTestToken = "9123456789012347"
+
+func authorizeStandInTest(t *testing.T, svc *CardService, amount int64) *TokenAuthorization {
+	result, err := svc.AuthorizeWithToken(standInTestToken, "iphone-1", decimal.NewFromInt(amount), Merchant{Name: "Corner Shop"})
+	require.NoError(t, err)
+	return result
+}
+
+func TestAuthorizeWithToken_ChecksLedgerBalance(t *testing.T) {
+	svc, repo, balances, card := newStandInTestService(t, 100)
+	balances.balances[card.AccountID] = decimal.NewFromInt(80)
+
+	result := authorizeStandInTest(t, svc, 50)
+	assert.True(t, result.Approved)
+	assert.False(t, result.StandIn)
+
+	result = authorizeStandInTest(t, svc, 81)
+	assert.False(t, result.Approved)
+	assert.Equal(t, DeclineInsufficientFunds, result.DeclineReason)
+
+	cached, err := repo.GetAccountBalance(card.AccountID)
+	require.NoError(t, err)
+	assert.True(t, cached.AvailableBalance.Equal(decimal.NewFromInt(80)), "every answer is cached for stand-in")
+}
+
+func TestAuthorizeWithToken_StandIn(t *testing.T) {
+	svc, repo, balances, card := newStandInTestService(t, 100)
+	balances.balances[card.AccountID] = decimal.NewFromInt(150)
+	require.True(t, authorizeStandInTest(t, svc, 10).Approved)
+	balances.down = true
+
+	first := authorizeStandInTest(t, svc, 90)
+	assert.True(t, first.Approved)
+	assert.True(t, first.StandIn)
+	require.NotNil(t, first.StandInID)
+
+	overLimit := authorizeStandInTest(t, svc, 101)
+	assert.False(t, overLimit.Approved)
+	assert.Equal(t, DeclineStandInLimit, overLimit.DeclineReason)
+
+	// 150 cached, 90 already approved in stand-in
+	overBalance := authorizeStandInTest(t, svc, 61)
+	assert.False(t, overBalance.Approved)
+	assert.Equal(t, DeclineInsufficientFunds, overBalance.DeclineReason)
+	assert.True(t, authorizeStandInTest(t, svc, 60).Approved)
+
+	pending, err := repo.ListStandInAuthorizations(model.StandInPending, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.True(t, pending[1].CachedBalance.Equal(decimal.NewFromInt(150)))
+	assert.Equal(t, "Corner Shop", pending[1].MerchantName)
+}
+
+func TestAuthorizeWithToken_StandInWithoutCachedBalance(t *testing.T) {
+	svc, _, balances, _ := newStandInTestService(t, 100)
+	balances.down = true
+
+	result := authorizeStandInTest(t, svc, 10)
+	assert.False(t, result.Approved)
+	assert.True(t, result.StandIn)
+	assert.Equal(t, DeclineIssuerUnavailable, result.DeclineReason)
+}
+
+func TestVerifyStandInAuthorizations(t *testing.T) {
+	svc, repo, balances, card := newStandInTestService(t, 100)
+	balances.balances[card.AccountID] = decimal.NewFromInt(200)
+	require.True(t, authorizeStandInTest(t, svc, 1).Approved)
+	balances.down = true
+	require.True(t, authorizeStandInTest(t, svc, 70).Approved)
+	require.True(t, authorizeStandInTest(t, svc, 50).Approved)
+
+	// Still down: nothing is verified and the run reports it
+	_, _, err := svc.VerifyStandInAuthorizations(context.Background())
+	assert.ErrorIs(t, err, ErrLedgerUnavailable)
+
+	// Back up, but the account spent elsewhere meanwhile
+	balances.down = false
+	balances.balances[card.AccountID] = decimal.NewFromInt(100)
+	verified, flagged, err := svc.VerifyStandInAuthorizations(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, verified)
+	assert.Equal(t, 1, flagged)
+
+	queue, err := svc.ListStandInAuthorizations(model.StandInFlagged, 0)
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.True(t, queue[0].Amount.Equal(decimal.NewFromInt(50)), "the oldest approval is covered first")
+	assert.True(t, queue[0].VerifiedBalance.Equal(decimal.NewFromInt(100)))
+	assert.NotEmpty(t, queue[0].FlagReason)
+
+	_, err = svc.ReviewStandInAuthorization(uuid.NewString(), queue[0].ID.String(), "")
+	assert.ErrorIs(t, err, ErrStandInReviewNoteMissing)
+	reviewed, err := svc.ReviewStandInAuthorization(uuid.NewString(), queue[0].ID.String(), "customer topped up")
+	require.NoError(t, err)
+	assert.Equal(t, model.StandInReviewed, reviewed.Status)
+	_, err = svc.ReviewStandInAuthorization(uuid.NewString(), queue[0].ID.String(), "again")
+	assert.ErrorIs(t, err, ErrStandInNotFlagged)
+
+	verifiedAuths, err := repo.ListStandInAuthorizations(model.StandInVerified, 10)
+	require.NoError(t, err)
+	require.Len(t, verifiedAuths, 1)
+	assert.True(t, verifiedAuths[0].Amount.Equal(decimal.NewFromInt(70)))
+}
+
+func TestStandIn_Disabled(t *testing.T) {
+	svc := NewCardService(new(MockCardRepository))
+
+	_, err := svc.ListStandInAuthorizations("", 0)
+	assert.ErrorIs(t, err, ErrStandInDisabled)
+	_, err = svc.ReviewStandInAuthorization(uuid.NewString(), uuid.NewString(), "note")
+	assert.ErrorIs(t, err, ErrStandInDisabled)
+}
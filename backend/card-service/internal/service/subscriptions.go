@@ -0,0 +1,290 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// subscriptionHistory is how far back charges are searched for subscriptions
+	subscriptionHistory = 400 * 24 * time.Hour
+	// maxSubscriptionCharges caps the transactions searched for subscriptions
+	maxSubscriptionCharges = 1000
+)
+
+// subscriptionAmountTolerance is how far a charge may be from the typical
+// charge of a subscription, as a fraction of it, so price changes and
+// currency conversion do not hide a subscription
+var subscriptionAmountTolerance = decimal.NewFromFloat(0.2)
+
+// Cadences of a subscription
+const (
+	CadenceWeekly    = "WEEKLY"
+	CadenceMonthly   = "MONTHLY"
+	CadenceQuarterly = "QUARTERLY"
+	CadenceAnnual    = "ANNUAL"
+)
+
+// cadence is the billing period of a subscription; the days between two
+// charges must fall in [minDays, maxDays]
+type cadence struct {
+	name       string
+	days       int
+	minDays    int
+	maxDays    int
+	minCharges int
+}
+
+var cadences = []cadence{
+	{name: CadenceWeekly, days: 7, minDays: 6, maxDays: 8, minCharges: 3},
+	{name: CadenceMonthly, days: 30, minDays: 27, maxDays: 33, minCharges: 3},
+	{name: CadenceQuarterly, days: 91, minDays: 85, maxDays: 97, minCharges: 3},
+	{name: CadenceAnnual, days: 365, minDays: 355, maxDays: 375, minCharges: 2},
+}
+
+var ErrSubscriptionNotFound = errors.New("subscription not found")
+
+// Subscription is a merchant that charges a card a similar amount every period.
+// Blocked is set when the card's merchant denylist declines the merchant.
+type Subscription struct {
+	// ID identifies the merchant among the card's subscriptions
+	ID                   string          `json:"id"`
+	MerchantName         string          `json:"merchant_name"`
+	MerchantCategoryCode string          `json:"merchant_category_code"`
+	Cadence              string          `json:"cadence"`
+	Amount               decimal.Decimal `json:"amount"`
+	Currency             string          `json:"currency"`
+	ChargeCount          int             `json:"charge_count"`
+	FirstChargedAt       time.Time       `json:"first_charged_at"`
+	LastChargedAt        time.Time       `json:"last_charged_at"`
+	NextChargeAt         time.Time       `json:"next_charge_at"`
+	// Active is false once a charge is a full period overdue
+	Active  bool `json:"active"`
+	Blocked bool `json:"blocked"`
+}
+
+// ListSubscriptions detects the recurring merchants of a card the user owns
+// from its transaction feed: merchants charging a similar amount in the same
+// currency at a regular cadence. Active subscriptions come first, the next
+// due first.
+func (s *CardService) ListSubscriptions(userID, cardID string) ([]Subscription, error) {
+	if s.transactions == nil {
+		return nil, ErrTransactionFeedDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscriptionsOf(card)
+}
+
+// BlockSubscription declines future charges from a subscription's merchant by
+// adding the merchant to the card's denylist
+func (s *CardService) BlockSubscription(userID, cardID, subscriptionID string) (*Subscription, error) {
+	return s.setSubscriptionBlocked(userID, cardID, subscriptionID, true)
+}
+
+// UnblockSubscription removes the denylist rule that blocked a subscription.
+// A subscription the denylist blocks by a wider pattern stays blocked.
+func (s *CardService) UnblockSubscription(userID, cardID, subscriptionID string) (*Subscription, error) {
+	return s.setSubscriptionBlocked(userID, cardID, subscriptionID, false)
+}
+
+func (s *CardService) setSubscriptionBlocked(userID, cardID, subscriptionID string, blocked bool) (*Subscription, error) {
+	if s.transactions == nil {
+		return nil, ErrTransactionFeedDisabled
+	}
+	if s.cardControls == nil {
+		return nil, ErrCardControlsDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.subscriptionsOf(card)
+	if err != nil {
+		return nil, err
+	}
+	var subscription *Subscription
+	for i := range subscriptions {
+		if subscriptions[i].ID == subscriptionID {
+			subscription = &subscriptions[i]
+		}
+	}
+	if subscription == nil {
+		return nil, ErrSubscriptionNotFound
+	}
+
+	current, err := s.cardControls.ListMerchantRules(card.ID)
+	if err != nil {
+		return nil, err
+	}
+	rule := MerchantRuleInput{NamePattern: merchantKey(subscription.MerchantName)}
+	denylist := []MerchantRuleInput{}
+	for _, existing := range merchantRuleInputs(current, model.MerchantDenylist) {
+		if existing != rule {
+			denylist = append(denylist, existing)
+		}
+	}
+	if blocked {
+		denylist = append(denylist, rule)
+	}
+	rules, err := normalizeMerchantRules(denylist)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setMerchantList(card, model.MerchantDenylist, rules); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.cardControls.ListMerchantRules(card.ID)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Blocked = merchantDenylisted(updated, subscription.MerchantName)
+	return subscription, nil
+}
+
+// subscriptionsOf detects a card's subscriptions and marks those the denylist blocks
+func (s *CardService) subscriptionsOf(card *model.Card) ([]Subscription, error) {
+	txns, err := s.transactions.ListCardTransactions(card.ID, maxSubscriptionCharges)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	subscriptions := DetectSubscriptions(txns, now)
+
+	if s.cardControls != nil && len(subscriptions) > 0 {
+		rules, err := s.cardControls.ListMerchantRules(card.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range subscriptions {
+			subscriptions[i].Blocked = merchantDenylisted(rules, subscriptions[i].MerchantName)
+		}
+	}
+	return subscriptions, nil
+}
+
+// DetectSubscriptions finds the recurring merchants among a card's transactions
+// settled in the subscription history before now. Charges are grouped by
+// merchant name and currency; a group is a subscription when every gap between
+// its charges fits one cadence and every charge is within 20% of the median.
+func DetectSubscriptions(txns []model.CardTransaction, now time.Time) []Subscription {
+	since := now.Add(-subscriptionHistory)
+	type group struct {
+		key      string
+		currency string
+	}
+	groups := map[group][]model.CardTransaction{}
+	for _, t := range txns {
+		key := merchantKey(t.MerchantName)
+		if key == "" || t.SettledAt.Before(since) {
+			continue
+		}
+		g := group{key: key, currency: t.Currency}
+		groups[g] = append(groups[g], t)
+	}
+
+	subscriptions := []Subscription{}
+	for _, charges := range groups {
+		sort.Slice(charges, func(i, j int) bool { return charges[i].SettledAt.Before(charges[j].SettledAt) })
+		if subscription, ok := detectSubscription(charges, now); ok {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		a, b := subscriptions[i], subscriptions[j]
+		if a.Active != b.Active {
+			return a.Active
+		}
+		if !a.NextChargeAt.Equal(b.NextChargeAt) {
+			return a.NextChargeAt.Before(b.NextChargeAt)
+		}
+		return a.MerchantName < b.MerchantName
+	})
+	return subscriptions
+}
+
+// detectSubscription reports whether charges, oldest first, recur at a cadence
+func detectSubscription(charges []model.CardTransaction, now time.Time) (Subscription, bool) {
+	if len(charges) < 2 {
+		return Subscription{}, false
+	}
+	c, ok := cadenceOf(charges)
+	if !ok || len(charges) < c.minCharges || !similarAmounts(charges) {
+		return Subscription{}, false
+	}
+
+	first, last := charges[0], charges[len(charges)-1]
+	next := last.SettledAt.AddDate(0, 0, c.days)
+	return Subscription{
+		ID:                   subscriptionID(last.MerchantName, last.Currency),
+		MerchantName:         last.MerchantName,
+		MerchantCategoryCode: last.MerchantCategoryCode,
+		Cadence:              c.name,
+		Amount:               last.Amount,
+		Currency:             last.Currency,
+		ChargeCount:          len(charges),
+		FirstChargedAt:       first.SettledAt,
+		LastChargedAt:        last.SettledAt,
+		NextChargeAt:         next,
+		Active:               now.Before(next.AddDate(0, 0, c.maxDays)),
+	}, true
+}
+
+// cadenceOf returns the cadence every gap between consecutive charges fits
+func cadenceOf(charges []model.CardTransaction) (cadence, bool) {
+	for _, c := range cadences {
+		fits := true
+		for i := 1; i < len(charges) && fits; i++ {
+			days := int(charges[i].SettledAt.Sub(charges[i-1].SettledAt).Round(24*time.Hour) / (24 * time.Hour))
+			fits = days >= c.minDays && days <= c.maxDays
+		}
+		if fits {
+			return c, true
+		}
+	}
+	return cadence{}, false
+}
+
+// similarAmounts reports whether every charge is within the tolerance of the median
+func similarAmounts(charges []model.CardTransaction) bool {
+	amounts := make([]decimal.Decimal, len(charges))
+	for i, t := range charges {
+		amounts[i] = t.Amount
+	}
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i].LessThan(amounts[j]) })
+	median := amounts[len(amounts)/2]
+	tolerance := median.Mul(subscriptionAmountTolerance)
+	return median.Sub(amounts[0]).LessThanOrEqual(tolerance) &&
+		amounts[len(amounts)-1].Sub(median).LessThanOrEqual(tolerance)
+}
+
+// merchantKey is a merchant name as merchant rules match it
+func merchantKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// merchantDenylisted reports whether the card's denylist declines a merchant
+func merchantDenylisted(rules []model.MerchantRule, merchantName string) bool {
+	for i := range rules {
+		if rules[i].List == model.MerchantDenylist && rules[i].Matches("", merchantName) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionID derives a stable, URL-safe id for a merchant's subscription
+func subscriptionID(merchantName, currency string) string {
+	sum := sha256.Sum256([]byte(merchantKey(merchantName) + "|" + currency))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// charges returns a transaction at merchant for each amount, every days apart,
+// the last settled at last
+func charges(cardID uuid.UUID, merchant, currency string, last time.Time, days int, amounts ...string) []model.CardTransaction {
+	var txns []model.CardTransaction
+	for i, amount := range amounts {
+		txns = append(txns, model.CardTransaction{
+			ID:                   uuid.New(),
+			CardID:               cardID,
+			NetworkReference:     uuid.NewString(),
+			Amount:               decimal.RequireFromString(amount),
+			Currency:             currency,
+			MerchantName:         merchant,
+			MerchantCategoryCode: "5815",
+			SettledAt:            last.AddDate(0, 0, -days*(len(amounts)-1-i)),
+		})
+	}
+	return txns
+}
+
+func TestDetectSubscriptions(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	cardID := uuid.New()
+	var txns []model.CardTransaction
+	txns = append(txns, charges(cardID, "Streamflix", "USD", now.AddDate(0, 0, -3), 30, "15.99", "15.99", "17.99", "17.99")...)
+	txns = append(txns, charges(cardID, "GYM PASS ", "USD", now.AddDate(0, 0, -1), 7, "10.00", "10.00", "10.50")...)
+	txns = append(txns, charges(cardID, "Cloud Storage", "EUR", now.AddDate(0, 0, -20), 365, "99.00", "99.00")...)
+	// Not periodic, amounts too different, too few charges
+	txns = append(txns, charges(cardID, "Corner Shop", "USD", now, 3, "5.00", "7.20", "4.10")...)
+	txns = append(txns, charges(cardID, "Utility Co", "USD", now, 30, "40.00", "95.00", "41.00")...)
+	txns = append(txns, charges(cardID, "News Daily", "USD", now, 30, "9.99", "9.99")...)
+
+	subscriptions := DetectSubscriptions(txns, now)
+	require.Len(t, subscriptions, 3)
+
+	gym := subscriptions[0]
+	assert.Equal(t, "GYM PASS ", gym.MerchantName)
+	assert.Equal(t, CadenceWeekly, gym.Cadence)
+	assert.Equal(t, 3, gym.ChargeCount)
+	assert.True(t, gym.Active)
+	assert.Equal(t, now.AddDate(0, 0, 6), gym.NextChargeAt)
+
+	streamflix := subscriptions[1]
+	assert.Equal(t, CadenceMonthly, streamflix.Cadence)
+	assert.Equal(t, "17.99", streamflix.Amount.StringFixed(2))
+	assert.Equal(t, now.AddDate(0, 0, -93), streamflix.FirstChargedAt)
+
+	cloud := subscriptions[2]
+	assert.Equal(t, CadenceAnnual, cloud.Cadence)
+	assert.Equal(t, "EUR", cloud.Currency)
+	assert.True(t, cloud.Active)
+
+	// Ids are stable across detections
+	assert.Equal(t, streamflix.ID, DetectSubscriptions(txns, now.AddDate(0, 0, 1))[1].ID)
+}
+
+func TestDetectSubscriptions_LapsedAndOld(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	cardID := uuid.New()
+	var txns []model.CardTransaction
+	// Last charged three months ago
+	txns = append(txns, charges(cardID, "Old Music", "USD", now.AddDate(0, -3, 0), 30, "9.99", "9.99", "9.99")...)
+	// Charged monthly, but over a year ago
+	txns = append(txns, charges(cardID, "Ancient", "USD", now.AddDate(-2, 0, 0), 30, "5.00", "5.00", "5.00")...)
+
+	subscriptions := DetectSubscriptions(txns, now)
+	require.Len(t, subscriptions, 1)
+	assert.Equal(t, "Old Music", subscriptions[0].MerchantName)
+	assert.False(t, subscriptions[0].Active)
+}
+
+func newSubscriptionsTestService(t *testing.T) (*CardService, *memoryCardControls, *model.Card, string) {
+	t.Helper()
+	svc, controls, card := newControlsTestService()
+	now := time.Now().UTC()
+	feed := &memoryCardTransactions{txns: charges(card.ID, "Streamflix", "USD", now.AddDate(0, 0, -2), 30, "15.99", "15.99", "15.99")}
+	svc.SetTransactionFeed(feed, nil)
+
+	subscriptions, err := svc.ListSubscriptions(card.UserID.String(), card.ID.String())
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	assert.False(t, subscriptions[0].Blocked)
+	return svc, controls, card, subscriptions[0].ID
+}
+
+func TestBlockSubscription(t *testing.T) {
+	svc, controls, card, id := newSubscriptionsTestService(t)
+	userID := card.UserID.String()
+	_, err := svc.UpdateCardControls(userID, card.ID.String(), CardControlsUpdate{
+		MerchantDenylist: rulesOf(MerchantRuleInput{MerchantID: "M-1"}),
+	})
+	require.NoError(t, err)
+
+	subscription, err := svc.BlockSubscription(userID, card.ID.String(), id)
+	require.NoError(t, err)
+	assert.True(t, subscription.Blocked)
+
+	// The merchant is denylisted alongside the existing rule, and declined
+	cardControls, err := svc.GetCardControls(userID, card.ID.String())
+	require.NoError(t, err)
+	require.Len(t, cardControls.MerchantDenylist, 2)
+	assert.Equal(t, "streamflix", cardControls.MerchantDenylist[1].NamePattern)
+	reason, err := svc.merchantDecline(card, Merchant{Name: "STREAMFLIX"})
+	require.NoError(t, err)
+	assert.Equal(t, DeclineMerchantDenylisted, reason)
+	assert.Equal(t, ControlMerchantDenylist, controls.changes[len(controls.changes)-1].Control)
+
+	// Blocking twice changes nothing
+	changes := len(controls.changes)
+	_, err = svc.BlockSubscription(userID, card.ID.String(), id)
+	require.NoError(t, err)
+	assert.Len(t, controls.changes, changes)
+
+	subscriptions, err := svc.ListSubscriptions(userID, card.ID.String())
+	require.NoError(t, err)
+	assert.True(t, subscriptions[0].Blocked)
+
+	subscription, err = svc.UnblockSubscription(userID, card.ID.String(), id)
+	require.NoError(t, err)
+	assert.False(t, subscription.Blocked)
+	cardControls, err = svc.GetCardControls(userID, card.ID.String())
+	require.NoError(t, err)
+	require.Len(t, cardControls.MerchantDenylist, 1)
+	assert.Equal(t, "M-1", cardControls.MerchantDenylist[0].MerchantID)
+}
+
+func TestBlockSubscription_Errors(t *testing.T) {
+	svc, _, card, id := newSubscriptionsTestService(t)
+
+	_, err := svc.BlockSubscription(card.UserID.String(), card.ID.String(), "unknown")
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+
+	_, err = svc.BlockSubscription(uuid.NewString(), card.ID.String(), id)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	svc.SetCardControls(nil)
+	_, err = svc.BlockSubscription(card.UserID.String(), card.ID.String(), id)
+	assert.ErrorIs(t, err, ErrCardControlsDisabled)
+}
@@ -0,0 +1,292 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// NetworkTokenValidity is how long a wallet token stays usable before the wallet must re-provision
+const NetworkTokenValidity = 3 * 365 * 24 * time.Hour
+
+var (
+	ErrInvalidWalletProvider = errors.New("wallet_provider must be APPLE_PAY or GOOGLE_PAY")
+	ErrDeviceIDRequired      = errors.New("device_id is required")
+	ErrNetworkTokenNotFound  = errors.New("network token not found")
+	ErrInvalidAmount         = errors.New("amount must be greater than zero")
+)
+
+// DeclineReason explains why a token authorization was not approved
+type DeclineReason string
+
+const (
+	DeclineTokenUnknown   DeclineReason = "TOKEN_UNKNOWN"
+	DeclineTokenRevoked   DeclineReason = "TOKEN_REVOKED"
+	DeclineTokenExpired   DeclineReason = "TOKEN_EXPIRED"
+	DeclineDeviceMismatch DeclineReason = "DEVICE_MISMATCH"
+	DeclineCardNotActive  DeclineReason = "CARD_NOT_ACTIVE"
+	DeclineExceedsLimit   DeclineReason = "EXCEEDS_DAILY_LIMIT"
+	// DeclineForeignBlocked is a transaction abroad with geo-blocking on and no travel notice
+	DeclineForeignBlocked DeclineReason = "FOREIGN_TRANSACTION_BLOCKED"
+	// DeclineMerchantDenylisted is a merchant on the card's merchant denylist
+	DeclineMerchantDenylisted DeclineReason = "MERCHANT_DENYLISTED"
+	// DeclineMerchantNotAllowlisted is a merchant missing from the card's merchant allowlist
+	DeclineMerchantNotAllowlisted DeclineReason = "MERCHANT_NOT_ALLOWLISTED"
+)
+
+// IssuedNetworkToken is returned once when a token is provisioned; the token
+// number is handed to the wallet and cannot be retrieved again
+type IssuedNetworkToken struct {
+	model.NetworkToken
+	Token string `json:"token"`
+}
+
+// TokenAuthorization is the outcome of authorizing a payment with a network token
+type TokenAuthorization struct {
+	Approved      bool          `json:"approved"`
+	DeclineReason DeclineReason `json:"decline_reason,omitempty"`
+	TokenID       *uuid.UUID    `json:"token_id,omitempty"`
+	CardID        *uuid.UUID    `json:"card_id,omitempty"`
+	AccountID     *uuid.UUID    `json:"account_id,omitempty"`
+	// CardToken is the card's processing token used downstream in place of the PAN
+	CardToken *uuid.UUID `json:"card_token,omitempty"`
+	// StandIn marks a decision made without the ledger, against the cached
+	// balance; StandInID is the approval queued for verification
+	StandIn   bool       `json:"stand_in,omitempty"`
+	StandInID *uuid.UUID `json:"stand_in_id,omitempty"`
+}
+
+// IssueNetworkToken provisions a device-bound token for an active card. Adding the
+// same card to the same wallet on the same device again revokes the previous token.
+func (s *CardService) IssueNetworkToken(userID, cardID string, wallet model.WalletProvider, deviceID, deviceName string) (*IssuedNetworkToken, error) {
+	if wallet != model.WalletApplePay && wallet != model.WalletGooglePay {
+		return nil, ErrInvalidWalletProvider
+	}
+	if deviceID == "" {
+		return nil, ErrDeviceIDRequired
+	}
+
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != model.CardActive {
+		return nil, ErrCardNotActive
+	}
+
+	existing, err := s.Repo.ListNetworkTokensByCard(card.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range existing {
+		t := &existing[i]
+		if t.Status == model.NetworkTokenActive && t.DeviceID == deviceID && t.WalletProvider == wallet {
+			if err := s.revokeToken(t); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	number, err := generateTokenNumber()
+	if err != nil {
+		return nil, err
+	}
+	// SEC-003: the token number is protected exactly like a PAN
+	encrypted, err := encryptCardNumber(number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt token: %w", err)
+	}
+
+	token := model.NetworkToken{
+		CardID:         card.ID,
+		UserID:         card.UserID,
+		EncryptedToken: encrypted,
+		TokenHash:      hashTokenNumber(number),
+		TokenLastFour:  number[len(number)-4:],
+		WalletProvider: wallet,
+		DeviceID:       deviceID,
+		DeviceName:     deviceName,
+		Status:         model.NetworkTokenActive,
+		ExpiresAt:      time.Now().Add(NetworkTokenValidity),
+	}
+	if err := s.Repo.CreateNetworkToken(&token); err != nil {
+		return nil, err
+	}
+	return &IssuedNetworkToken{NetworkToken: token, Token: number}, nil
+}
+
+// ListNetworkTokens returns the tokens issued for a card owned by the user
+func (s *CardService) ListNetworkTokens(userID, cardID string) ([]model.NetworkToken, error) {
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListNetworkTokensByCard(card.ID)
+}
+
+// RevokeNetworkToken revokes a token so the wallet can no longer pay with it.
+// Revoking an already revoked token is a no-op.
+func (s *CardService) RevokeNetworkToken(userID, cardID, tokenID string) (*model.NetworkToken, error) {
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenUUID, err := uuid.Parse(tokenID)
+	if err != nil {
+		return nil, ErrNetworkTokenNotFound
+	}
+	token, err := s.Repo.GetNetworkToken(tokenUUID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNetworkTokenNotFound
+		}
+		return nil, err
+	}
+	if token.CardID != card.ID {
+		return nil, ErrNetworkTokenNotFound
+	}
+
+	if token.Status == model.NetworkTokenActive {
+		if err := s.revokeToken(token); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// AuthorizeWithToken authorizes a payment presented with a network token instead
+// of a PAN. The merchant's country is an ISO country code. Declines are
+// reported in the result; an error means the check itself failed.
+func (s *CardService) AuthorizeWithToken(tokenNumber, deviceID string, amount decimal.Decimal, merchant Merchant) (*TokenAuthorization, error) {
+	if !amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+	merchant.Country = strings.ToUpper(merchant.Country)
+	if merchant.Country != "" && !isCountryCode(merchant.Country) {
+		return nil, ErrInvalidMerchantCountry
+	}
+
+	token, err := s.Repo.GetNetworkTokenByHash(hashTokenNumber(tokenNumber))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &TokenAuthorization{DeclineReason: DeclineTokenUnknown}, nil
+		}
+		return nil, err
+	}
+
+	stored, err := decryptCardNumber(token.EncryptedToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(tokenNumber)) != 1 {
+		return &TokenAuthorization{DeclineReason: DeclineTokenUnknown}, nil
+	}
+
+	result := &TokenAuthorization{TokenID: &token.ID, CardID: &token.CardID}
+	switch {
+	case token.Status != model.NetworkTokenActive:
+		result.DeclineReason = DeclineTokenRevoked
+		return result, nil
+	case time.Now().After(token.ExpiresAt):
+		result.DeclineReason = DeclineTokenExpired
+		return result, nil
+	case subtle.ConstantTimeCompare([]byte(token.DeviceID), []byte(deviceID)) != 1:
+		result.DeclineReason = DeclineDeviceMismatch
+		return result, nil
+	}
+
+	card, err := s.Repo.GetCardByID(token.CardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status != model.CardActive {
+		result.DeclineReason = DeclineCardNotActive
+		return result, nil
+	}
+	reason, err := s.merchantDecline(card, merchant)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		result.DeclineReason = reason
+		return result, nil
+	}
+	if amount.GreaterThan(card.DailyLimit) {
+		result.DeclineReason = DeclineExceedsLimit
+		return result, nil
+	}
+	allowed, err := s.foreignTransactionAllowed(card, merchant.Country, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		result.DeclineReason = DeclineForeignBlocked
+		return result, nil
+	}
+	// The balance is checked last, so only payments that pass every other
+	// rule are queued in stand-in
+	if s.balances != nil {
+		if err := s.checkFunds(result, card, amount, merchant); err != nil {
+			return nil, err
+		}
+		if result.DeclineReason != "" {
+			return result, nil
+		}
+	}
+
+	result.Approved = true
+	result.AccountID = &card.AccountID
+	result.CardToken = &card.CardToken
+	return result, nil
+}
+
+func (s *CardService) revokeToken(token *model.NetworkToken) error {
+	now := time.Now()
+	token.Status = model.NetworkTokenRevoked
+	token.RevokedAt = &now
+	return s.Repo.UpdateNetworkToken(token)
+}
+
+// generateTokenNumber returns a 16-digit, Luhn-valid token number. The 9 prefix
+// keeps simulated tokens out of real card BIN ranges.
+func generateTokenNumber() (string, error) {
+	body, err := generateRandomNumericString(14)
+	if err != nil {
+		return "", err
+	}
+	partial := "9" + body
+	return partial + luhnCheckDigit(partial), nil
+}
+
+// luhnCheckDigit computes the digit that makes partial+digit pass the Luhn check
+func luhnCheckDigit(partial string) string {
+	sum := 0
+	double := true
+	for i := len(partial) - 1; i >= 0; i-- {
+		d := int(partial[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return fmt.Sprintf("%d", (10-sum%10)%10)
+}
+
+func hashTokenNumber(number string) string {
+	sum := sha256.Sum256([]byte(number))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLuhnCheckDigit(t *testing.T) {
+	// 4539 1488 0343 6467 is a well-known Luhn-valid test number
+	assert.Equal(t, "7", luhnCheckDigit("453914880343646"))
+
+	number, err := generateTokenNumber()
+	require.NoError(t, err)
+	assert.Len(t, number, 16)
+	assert.Equal(t, byte('9'), number[0])
+	assert.Equal(t, number[15:], luhnCheckDigit(number[:15]))
+}
+
+func TestIssueNetworkToken_EncryptsAndReplacesDeviceToken(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+
+	previous := model.NetworkToken{ID: uuid.New(), CardID: card.ID, DeviceID: "iphone-1", WalletProvider: model.WalletApplePay, Status: model.NetworkTokenActive}
+	other := model.NetworkToken{ID: uuid.New(), CardID: card.ID, DeviceID: "pixel-1", WalletProvider: model.WalletGooglePay, Status: model.NetworkTokenActive}
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("ListNetworkTokensByCard", card.ID).Return([]model.NetworkToken{previous, other}, nil)
+	mockRepo.On("UpdateNetworkToken", mock.MatchedBy(func(tok *model.NetworkToken) bool {
+		return tok.ID == previous.ID && tok.Status == model.NetworkTokenRevoked
+	})).Return(nil).Once()
+	mockRepo.On("CreateNetworkToken", mock.AnythingOfType("*model.NetworkToken")).Return(nil)
+
+	issued, err := svc.IssueNetworkToken(userID.String(), card.ID.String(), model.WalletApplePay, "iphone-1", "Ada's iPhone")
+
+	require.NoError(t, err)
+	assert.Len(t, issued.Token, 16)
+	assert.Equal(t, issued.Token[12:], issued.TokenLastFour)
+	assert.NotContains(t, issued.EncryptedToken, issued.Token)
+	assert.Equal(t, hashTokenNumber(issued.Token), issued.TokenHash)
+
+	decrypted, err := decryptCardNumber(issued.EncryptedToken)
+	require.NoError(t, err)
+	assert.Equal(t, issued.Token, decrypted)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestIssueNetworkToken_RejectsInvalidRequests(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	card.Status = model.CardBlocked
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+	_, err := svc.IssueNetworkToken(userID.String(), card.ID.String(), "SAMSUNG_PAY", "d1", "")
+	assert.ErrorIs(t, err, ErrInvalidWalletProvider)
+
+	_, err = svc.IssueNetworkToken(userID.String(), card.ID.String(), model.WalletGooglePay, "", "")
+	assert.ErrorIs(t, err, ErrDeviceIDRequired)
+
+	_, err = svc.IssueNetworkToken(userID.String(), card.ID.String(), model.WalletGooglePay, "d1", "")
+	assert.ErrorIs(t, err, ErrCardNotActive)
+
+	_, err = svc.IssueNetworkToken(uuid.New().String(), card.ID.String(), model.WalletGooglePay, "d1", "")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestRevokeNetworkToken_RequiresTokenOnCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	foreign := &model.NetworkToken{ID: uuid.New(), CardID: uuid.New(), Status: model.NetworkTokenActive}
+	own := &model.NetworkToken{ID: uuid.New(), CardID: card.ID, Status: model.NetworkTokenActive}
+
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("GetNetworkToken", foreign.ID).Return(foreign, nil)
+	mockRepo.On("GetNetworkToken", own.ID).Return(own, nil)
+	mockRepo.On("UpdateNetworkToken", own).Return(nil).Once()
+
+	_, err := svc.RevokeNetworkToken(userID.String(), card.ID.String(), foreign.ID.String())
+	assert.ErrorIs(t, err, ErrNetworkTokenNotFound)
+
+	revoked, err := svc.RevokeNetworkToken(userID.String(), card.ID.String(), own.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.NetworkTokenRevoked, revoked.Status)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	// Revoking again is a no-op
+	_, err = svc.RevokeNetworkToken(userID.String(), card.ID.String(), own.ID.String())
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthorizeWithToken(t *testing.T) {
+	number := "9123456789012347"
+	encrypted, err := encryptCardNumber(number)
+	require.NoError(t, err)
+
+	newToken := func(cardID uuid.UUID) *model.NetworkToken {
+		return &model.NetworkToken{
+			ID:             uuid.New(),
+			CardID:         cardID,
+			EncryptedToken: encrypted,
+			TokenHash:      hashTokenNumber(number),
+			DeviceID:       "iphone-1",
+			Status:         model.NetworkTokenActive,
+			ExpiresAt:      time.Now().Add(time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		device   string
+		amount   string
+		mutate   func(tok *model.NetworkToken, card *model.Card)
+		approved bool
+		reason   DeclineReason
+	}{
+		{"approved", number, "iphone-1", "50", nil, true, ""},
+		{"unknown token", "9000000000000000", "iphone-1", "50", nil, false, DeclineTokenUnknown},
+		{"revoked", number, "iphone-1", "50", func(tok *model.NetworkToken, _ *model.Card) { tok.Status = model.NetworkTokenRevoked }, false, DeclineTokenRevoked},
+		{"expired", number, "iphone-1", "50", func(tok *model.NetworkToken, _ *model.Card) { tok.ExpiresAt = time.Now().Add(-time.Minute) }, false, DeclineTokenExpired},
+		{"other device", number, "pixel-1", "50", nil, false, DeclineDeviceMismatch},
+		{"card blocked", number, "iphone-1", "50", func(_ *model.NetworkToken, card *model.Card) { card.Status = model.CardBlocked }, false, DeclineCardNotActive},
+		{"over daily limit", number, "iphone-1", "1000.01", nil, false, DeclineExceedsLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockCardRepository)
+			svc := NewCardService(mockRepo)
+			card := newTestCard(uuid.New())
+			card.AccountID = uuid.New()
+			card.CardToken = uuid.New()
+			card.DailyLimit = decimal.NewFromInt(1000)
+			tok := newToken(card.ID)
+			if tt.mutate != nil {
+				tt.mutate(tok, card)
+			}
+
+			mockRepo.On("GetNetworkTokenByHash", hashTokenNumber(number)).Return(tok, nil)
+			mockRepo.On("GetNetworkTokenByHash", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+			mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+			result, err := svc.AuthorizeWithToken(tt.token, tt.device, decimal.RequireFromString(tt.amount), Merchant{})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.approved, result.Approved)
+			assert.Equal(t, tt.reason, result.DeclineReason)
+			if tt.approved {
+				assert.Equal(t, card.AccountID, *result.AccountID)
+				assert.Equal(t, card.CardToken, *result.CardToken)
+			}
+		})
+	}
+}
+
+func TestAuthorizeWithToken_RejectsNonPositiveAmount(t *testing.T) {
+	svc := NewCardService(new(MockCardRepository))
+
+	_, err := svc.AuthorizeWithToken("9123456789012347", "iphone-1", decimal.Zero, Merchant{})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+}
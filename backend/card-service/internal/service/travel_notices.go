@@ -0,0 +1,217 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxTravelNoticeDuration is the longest trip a single travel notice can cover
+const MaxTravelNoticeDuration = 365 * 24 * time.Hour
+
+// maxTravelNoticeCountries bounds the country list of one notice
+const maxTravelNoticeCountries = 20
+
+var (
+	ErrTravelNoticesDisabled  = errors.New("travel notices are not configured")
+	ErrInvalidCountry         = errors.New("countries must be ISO 3166-1 alpha-2 codes")
+	ErrTooManyCountries       = errors.New("a travel notice can list at most 20 countries")
+	ErrInvalidTravelDates     = errors.New("end_date must not be before start_date or in the past")
+	ErrTravelNoticeTooLong    = errors.New("a travel notice can cover at most 365 days")
+	ErrTravelNoticeNotFound   = errors.New("travel notice not found")
+	ErrInvalidMerchantCountry = errors.New("merchant_country must be an ISO 3166-1 alpha-2 code")
+)
+
+// TravelNoticeRepository stores cardholders' travel notices
+type TravelNoticeRepository interface {
+	CreateTravelNotice(n *model.TravelNotice) error
+	GetTravelNotice(id uuid.UUID) (*model.TravelNotice, error)
+	ListTravelNoticesByCard(cardID uuid.UUID) ([]model.TravelNotice, error)
+	UpdateTravelNotice(n *model.TravelNotice) error
+}
+
+// SetTravelNotices enables travel notices and geo-based authorization rules.
+// Transactions outside homeCountry are declined for cards with geo-blocking on
+// unless an active travel notice covers the merchant's country.
+func (s *CardService) SetTravelNotices(repo TravelNoticeRepository, homeCountry string) {
+	s.travelNotices = repo
+	s.homeCountry = strings.ToUpper(homeCountry)
+}
+
+// CreateTravelNotice records that the user will use the card in the given
+// countries between start and end, both inclusive
+func (s *CardService) CreateTravelNotice(userID, cardID string, countries []string, start, end time.Time) (*model.TravelNotice, error) {
+	if s.travelNotices == nil {
+		return nil, ErrTravelNoticesDisabled
+	}
+	codes, err := normalizeCountries(countries)
+	if err != nil {
+		return nil, err
+	}
+	start, end = truncateDay(start), truncateDay(end)
+	if end.Before(start) || end.Before(truncateDay(time.Now())) {
+		return nil, ErrInvalidTravelDates
+	}
+	if end.Sub(start) >= MaxTravelNoticeDuration {
+		return nil, ErrTravelNoticeTooLong
+	}
+
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	notice := &model.TravelNotice{
+		CardID:    card.ID,
+		UserID:    card.UserID,
+		Countries: codes,
+		StartDate: start,
+		EndDate:   end,
+	}
+	if err := s.travelNotices.CreateTravelNotice(notice); err != nil {
+		return nil, err
+	}
+	return notice, nil
+}
+
+// ListTravelNotices returns the travel notices declared for a card owned by the user
+func (s *CardService) ListTravelNotices(userID, cardID string) ([]model.TravelNotice, error) {
+	if s.travelNotices == nil {
+		return nil, ErrTravelNoticesDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	return s.travelNotices.ListTravelNoticesByCard(card.ID)
+}
+
+// CancelTravelNotice ends a travel notice early. Cancelling an already
+// cancelled notice is a no-op.
+func (s *CardService) CancelTravelNotice(userID, cardID, noticeID string) (*model.TravelNotice, error) {
+	if s.travelNotices == nil {
+		return nil, ErrTravelNoticesDisabled
+	}
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(noticeID)
+	if err != nil {
+		return nil, ErrTravelNoticeNotFound
+	}
+	notice, err := s.travelNotices.GetTravelNotice(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTravelNoticeNotFound
+		}
+		return nil, err
+	}
+	if notice.CardID != card.ID {
+		return nil, ErrTravelNoticeNotFound
+	}
+
+	if notice.CancelledAt == nil {
+		now := time.Now()
+		notice.CancelledAt = &now
+		if err := s.travelNotices.UpdateTravelNotice(notice); err != nil {
+			return nil, err
+		}
+	}
+	return notice, nil
+}
+
+// SetGeoBlocking turns the card's geo-blocking control on or off. With card
+// controls configured the change is recorded in the card's control history.
+func (s *CardService) SetGeoBlocking(userID, cardID string, enabled bool) (*model.Card, error) {
+	card, err := s.GetCard(userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.setGeoBlocking(card, enabled); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+func (s *CardService) setGeoBlocking(card *model.Card, enabled bool) error {
+	if card.GeoBlocking == enabled {
+		return nil
+	}
+	card.GeoBlocking = enabled
+	if s.cardControls == nil {
+		return s.Repo.UpdateCard(card)
+	}
+	return s.cardControls.UpdateCardControls(card, &model.CardControlChange{
+		CardID:    card.ID,
+		ChangedBy: card.UserID,
+		Control:   ControlGeoBlocking,
+		OldValue:  !enabled,
+		NewValue:  enabled,
+	})
+}
+
+// foreignTransactionAllowed applies the geo rules to a transaction in country.
+// Without travel notices configured, or when the country is unknown, nothing is blocked.
+func (s *CardService) foreignTransactionAllowed(card *model.Card, country string, at time.Time) (bool, error) {
+	if s.travelNotices == nil || country == "" || country == s.homeCountry || !card.GeoBlocking {
+		return true, nil
+	}
+	notices, err := s.travelNotices.ListTravelNoticesByCard(card.ID)
+	if err != nil {
+		return false, err
+	}
+	for i := range notices {
+		if notices[i].Covers(country, at) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// normalizeCountries upper-cases, validates and de-duplicates country codes
+func normalizeCountries(countries []string) ([]string, error) {
+	if len(countries) == 0 {
+		return nil, ErrInvalidCountry
+	}
+	seen := make(map[string]bool, len(countries))
+	var codes []string
+	for _, c := range countries {
+		code := strings.ToUpper(strings.TrimSpace(c))
+		if !isCountryCode(code) {
+			return nil, ErrInvalidCountry
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) > maxTravelNoticeCountries {
+		return nil, ErrTooManyCountries
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+func isCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func truncateDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryTravelNotices is an in-memory TravelNoticeRepository
+type memoryTravelNotices struct {
+	byID map[uuid.UUID]model.TravelNotice
+}
+
+func newMemoryTravelNotices() *memoryTravelNotices {
+	return &memoryTravelNotices{byID: map[uuid.UUID]model.TravelNotice{}}
+}
+
+func (m *memoryTravelNotices) CreateTravelNotice(n *model.TravelNotice) error {
+	n.ID = uuid.New()
+	m.byID[n.ID] = *n
+	return nil
+}
+
+func (m *memoryTravelNotices) GetTravelNotice(id uuid.UUID) (*model.TravelNotice, error) {
+	n, ok := m.byID[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &n, nil
+}
+
+func (m *memoryTravelNotices) ListTravelNoticesByCard(cardID uuid.UUID) ([]model.TravelNotice, error) {
+	var out []model.TravelNotice
+	for _, n := range m.byID {
+		if n.CardID == cardID {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryTravelNotices) UpdateTravelNotice(n *model.TravelNotice) error {
+	m.byID[n.ID] = *n
+	return nil
+}
+
+func TestCreateTravelNotice_ValidatesRequest(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	svc.SetTravelNotices(newMemoryTravelNotices(), "us")
+	userID := uuid.New()
+	card := newTestCard(userID)
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+	today := time.Now().UTC()
+	tests := []struct {
+		name      string
+		countries []string
+		start     time.Time
+		end       time.Time
+		wantErr   error
+	}{
+		{"no countries", nil, today, today, ErrInvalidCountry},
+		{"alpha-3 code", []string{"FRA"}, today, today, ErrInvalidCountry},
+		{"digits", []string{"F1"}, today, today, ErrInvalidCountry},
+		{"end before start", []string{"FR"}, today.AddDate(0, 0, 5), today.AddDate(0, 0, 4), ErrInvalidTravelDates},
+		{"already over", []string{"FR"}, today.AddDate(0, 0, -5), today.AddDate(0, 0, -1), ErrInvalidTravelDates},
+		{"longer than a year", []string{"FR"}, today, today.AddDate(1, 0, 1), ErrTravelNoticeTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateTravelNotice(userID.String(), card.ID.String(), tt.countries, tt.start, tt.end)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	notice, err := svc.CreateTravelNotice(userID.String(), card.ID.String(), []string{"it", "FR", "fr"}, today, today.AddDate(0, 0, 14))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FR", "IT"}, notice.Countries)
+	assert.Equal(t, card.ID, notice.CardID)
+
+	_, err = svc.CreateTravelNotice(uuid.New().String(), card.ID.String(), []string{"FR"}, today, today)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestCancelTravelNotice_RequiresNoticeOnCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	notices := newMemoryTravelNotices()
+	svc.SetTravelNotices(notices, "US")
+	userID := uuid.New()
+	card := newTestCard(userID)
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+	other := model.TravelNotice{CardID: uuid.New(), Countries: []string{"FR"}}
+	require.NoError(t, notices.CreateTravelNotice(&other))
+	_, err := svc.CancelTravelNotice(userID.String(), card.ID.String(), other.ID.String())
+	assert.ErrorIs(t, err, ErrTravelNoticeNotFound)
+
+	own, err := svc.CreateTravelNotice(userID.String(), card.ID.String(), []string{"FR"}, time.Now(), time.Now())
+	require.NoError(t, err)
+	cancelled, err := svc.CancelTravelNotice(userID.String(), card.ID.String(), own.ID.String())
+	require.NoError(t, err)
+	require.NotNil(t, cancelled.CancelledAt)
+	assert.False(t, cancelled.Covers("FR", time.Now()))
+}
+
+func TestTravelNotices_DisabledWithoutRepository(t *testing.T) {
+	svc := NewCardService(new(MockCardRepository))
+
+	_, err := svc.CreateTravelNotice(uuid.New().String(), uuid.New().String(), []string{"FR"}, time.Now(), time.Now())
+	assert.ErrorIs(t, err, ErrTravelNoticesDisabled)
+}
+
+func TestAuthorizeWithToken_GeoBlocking(t *testing.T) {
+	number := "9123456789012347"
+	encrypted, err := encryptCardNumber(number)
+	require.NoError(t, err)
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name        string
+		country     string
+		geoBlocking bool
+		notice      *model.TravelNotice
+		approved    bool
+	}{
+		{"home country", "US", true, nil, true},
+		{"country not sent", "", true, nil, true},
+		{"abroad without notice", "FR", true, nil, false},
+		{"abroad with geo-blocking off", "FR", false, nil, true},
+		{"abroad with notice", "fr", true, &model.TravelNotice{Countries: []string{"FR", "IT"}, StartDate: now.AddDate(0, 0, -1), EndDate: now.AddDate(0, 0, 1)}, true},
+		{"notice for another country", "DE", true, &model.TravelNotice{Countries: []string{"FR"}, StartDate: now, EndDate: now}, false},
+		{"notice not started", "FR", true, &model.TravelNotice{Countries: []string{"FR"}, StartDate: now.AddDate(0, 0, 1), EndDate: now.AddDate(0, 0, 7)}, false},
+		{"notice cancelled", "FR", true, &model.TravelNotice{Countries: []string{"FR"}, StartDate: now, EndDate: now, CancelledAt: &now}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockCardRepository)
+			svc := NewCardService(mockRepo)
+			notices := newMemoryTravelNotices()
+			svc.SetTravelNotices(notices, "US")
+
+			card := newTestCard(uuid.New())
+			card.DailyLimit = decimal.NewFromInt(1000)
+			card.GeoBlocking = tt.geoBlocking
+			if tt.notice != nil {
+				tt.notice.CardID = card.ID
+				require.NoError(t, notices.CreateTravelNotice(tt.notice))
+			}
+			tok := &model.NetworkToken{
+				ID:             uuid.New(),
+				CardID:         card.ID,
+				EncryptedToken: encrypted,
+				DeviceID:       "iphone-1",
+				Status:         model.NetworkTokenActive,
+				ExpiresAt:      time.Now().Add(time.Hour),
+			}
+			mockRepo.On("GetNetworkTokenByHash", mock.Anything).Return(tok, nil)
+			mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+
+			result, err := svc.AuthorizeWithToken(number, "iphone-1", decimal.NewFromInt(50), Merchant{Country: tt.country})
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.approved, result.Approved)
+			if !tt.approved {
+				assert.Equal(t, DeclineForeignBlocked, result.DeclineReason)
+			}
+		})
+	}
+
+	_, err = NewCardService(new(MockCardRepository)).AuthorizeWithToken(number, "iphone-1", decimal.NewFromInt(50), Merchant{Country: "FRA"})
+	assert.ErrorIs(t, err, ErrInvalidMerchantCountry)
+}
+
+func TestSetGeoBlocking_UpdatesCard(t *testing.T) {
+	mockRepo := new(MockCardRepository)
+	svc := NewCardService(mockRepo)
+	userID := uuid.New()
+	card := newTestCard(userID)
+	card.GeoBlocking = true
+	mockRepo.On("GetCardByID", card.ID).Return(card, nil)
+	mockRepo.On("UpdateCard", mock.MatchedBy(func(c *model.Card) bool { return !c.GeoBlocking })).Return(nil).Once()
+
+	updated, err := svc.SetGeoBlocking(userID.String(), card.ID.String(), false)
+	require.NoError(t, err)
+	assert.False(t, updated.GeoBlocking)
+
+	// Setting the same value again does not write
+	_, err = svc.SetGeoBlocking(userID.String(), card.ID.String(), false)
+	require.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
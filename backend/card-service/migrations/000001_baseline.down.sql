@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS network_tokens;
+DROP TABLE IF EXISTS cards;
@@ -0,0 +1,44 @@
+-- Baseline schema for card-service, matching what GORM AutoMigrate created.
+-- Statements use IF NOT EXISTS so databases created by AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS cards (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    encrypted_card_number text NOT NULL,
+    masked_card_number varchar(19) NOT NULL,
+    expiration_date varchar(5) NOT NULL,
+    status varchar(20) DEFAULT 'ACTIVE',
+    card_token uuid DEFAULT gen_random_uuid(),
+    pin_hash varchar(255),
+    daily_limit numeric(19,4) DEFAULT 1000.00,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    pin_failed_attempts bigint DEFAULT 0,
+    pin_updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_cards_user_id ON cards (user_id);
+CREATE INDEX IF NOT EXISTS idx_cards_account_id ON cards (account_id);
+CREATE INDEX IF NOT EXISTS idx_cards_deleted_at ON cards (deleted_at);
+
+CREATE TABLE IF NOT EXISTS network_tokens (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    encrypted_token text NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    token_last_four varchar(4) NOT NULL,
+    wallet_provider varchar(20) NOT NULL,
+    device_id varchar(100) NOT NULL,
+    device_name varchar(100),
+    status varchar(20) DEFAULT 'ACTIVE',
+    expires_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_network_tokens_card_id ON network_tokens (card_id);
+CREATE INDEX IF NOT EXISTS idx_network_tokens_user_id ON network_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_network_tokens_token_hash ON network_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_network_tokens_device_id ON network_tokens (device_id);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS card_outbox_events;
+DROP TABLE IF EXISTS card_replacements;
+DROP INDEX IF EXISTS idx_cards_replaces_card_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS replaced_by_card_id;
+ALTER TABLE cards DROP COLUMN IF EXISTS replaces_card_id;
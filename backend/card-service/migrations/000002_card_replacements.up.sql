@@ -0,0 +1,29 @@
+-- Card replacement chain and the transactional outbox for card lifecycle events.
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS replaces_card_id uuid;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS replaced_by_card_id uuid;
+CREATE INDEX IF NOT EXISTS idx_cards_replaces_card_id ON cards (replaces_card_id);
+
+CREATE TABLE IF NOT EXISTS card_replacements (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    old_card_id uuid NOT NULL,
+    new_card_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    reason varchar(20) NOT NULL,
+    tokens_moved bigint DEFAULT 0,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_card_replacements_old_card_id ON card_replacements (old_card_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_card_replacements_new_card_id ON card_replacements (new_card_id);
+CREATE INDEX IF NOT EXISTS idx_card_replacements_user_id ON card_replacements (user_id);
+
+CREATE TABLE IF NOT EXISTS card_outbox_events (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    topic varchar(100) NOT NULL,
+    key varchar(100) NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamptz,
+    published_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_card_outbox_events_created_at ON card_outbox_events (created_at);
+CREATE INDEX IF NOT EXISTS idx_card_outbox_events_published_at ON card_outbox_events (published_at);
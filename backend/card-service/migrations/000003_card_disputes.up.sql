@@ -0,0 +1,25 @@
+-- Card transaction disputes and their provisional credits.
+
+CREATE TABLE IF NOT EXISTS card_disputes (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    transaction_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    reason varchar(30) NOT NULL,
+    description text,
+    status varchar(20) NOT NULL,
+    outcome varchar(10),
+    provisional_entry_id uuid,
+    reversal_entry_id uuid,
+    resolution_note text,
+    reviewed_by uuid,
+    resolved_by uuid,
+    resolved_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_card_disputes_card_id ON card_disputes (card_id);
+CREATE INDEX IF NOT EXISTS idx_card_disputes_user_id ON card_disputes (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_card_disputes_transaction_id ON card_disputes (transaction_id);
+CREATE INDEX IF NOT EXISTS idx_card_disputes_status ON card_disputes (status);
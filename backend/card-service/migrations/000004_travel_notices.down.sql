@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS travel_notices;
+ALTER TABLE cards DROP COLUMN IF EXISTS geo_blocking;
@@ -0,0 +1,17 @@
+-- Travel notices and the geo-blocking card control.
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS geo_blocking boolean NOT NULL DEFAULT true;
+
+CREATE TABLE IF NOT EXISTS travel_notices (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    countries text NOT NULL,
+    start_date date NOT NULL,
+    end_date date NOT NULL,
+    cancelled_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_travel_notices_card_id ON travel_notices (card_id);
+CREATE INDEX IF NOT EXISTS idx_travel_notices_user_id ON travel_notices (user_id);
@@ -0,0 +1,18 @@
+-- Settled card transactions: the card transaction feed and spending insights.
+
+CREATE TABLE IF NOT EXISTS card_transactions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    network_reference varchar(64) NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    merchant_name varchar(100) NOT NULL,
+    merchant_category_code varchar(4) NOT NULL,
+    merchant_country char(2),
+    settled_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_card_transactions_network_reference ON card_transactions (network_reference);
+CREATE INDEX IF NOT EXISTS idx_card_transactions_card_settled ON card_transactions (card_id, settled_at);
+CREATE INDEX IF NOT EXISTS idx_card_transactions_user_id ON card_transactions (user_id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cards_deactivates_at;
+DROP INDEX IF EXISTS idx_cards_renewal_due;
+ALTER TABLE cards DROP COLUMN IF EXISTS deactivates_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS expires_at;
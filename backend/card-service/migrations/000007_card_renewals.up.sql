@@ -0,0 +1,15 @@
+-- Card expiry renewal: when each card stops working, and when a card that has
+-- been renewed is deactivated.
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS expires_at timestamptz;
+-- A card works through the last day of its MM/YY expiry month
+UPDATE cards
+SET expires_at = (to_date(expiration_date, 'MM/YY') + interval '1 month') AT TIME ZONE 'UTC'
+WHERE expires_at IS NULL;
+ALTER TABLE cards ALTER COLUMN expires_at SET NOT NULL;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS deactivates_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_cards_renewal_due ON cards (expires_at)
+    WHERE replaced_by_card_id IS NULL AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_cards_deactivates_at ON cards (deactivates_at)
+    WHERE deactivates_at IS NOT NULL;
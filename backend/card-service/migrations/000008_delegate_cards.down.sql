@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cards_delegate_account;
+ALTER TABLE cards DROP COLUMN IF EXISTS approved_at;
+ALTER TABLE cards DROP COLUMN IF EXISTS approved_by;
+ALTER TABLE cards DROP COLUMN IF EXISTS delegate;
@@ -0,0 +1,9 @@
+-- Delegate cards: cards issued on an account to a secondary user, approved by
+-- the account owner.
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS delegate boolean NOT NULL DEFAULT false;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS approved_by uuid;
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS approved_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_cards_delegate_account ON cards (account_id)
+    WHERE delegate AND deleted_at IS NULL;
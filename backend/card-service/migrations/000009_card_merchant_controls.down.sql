@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS card_control_changes;
+DROP TABLE IF EXISTS card_merchant_rules;
@@ -0,0 +1,23 @@
+-- Per-card merchant allowlists and denylists, and the history of changes to
+-- card controls.
+
+CREATE TABLE IF NOT EXISTS card_merchant_rules (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    list varchar(5) NOT NULL,
+    merchant_id varchar(64),
+    name_pattern varchar(100),
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_card_merchant_rules_card_id ON card_merchant_rules (card_id);
+
+CREATE TABLE IF NOT EXISTS card_control_changes (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    changed_by uuid NOT NULL,
+    control varchar(32) NOT NULL,
+    old_value jsonb NOT NULL,
+    new_value jsonb NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_card_control_changes_card_created ON card_control_changes (card_id, created_at);
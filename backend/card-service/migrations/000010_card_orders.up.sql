@@ -0,0 +1,22 @@
+-- Physical card orders, tracked from the order through printing, shipping and
+-- delivery by the fulfillment partner's webhook until the cardholder
+-- activates the card.
+
+CREATE TABLE IF NOT EXISTS card_orders (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    status varchar(20) NOT NULL,
+    carrier varchar(50),
+    tracking_number varchar(100),
+    printed_at timestamptz,
+    shipped_at timestamptz,
+    delivered_at timestamptz,
+    activated_at timestamptz,
+    activation_failed_attempts bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_card_orders_card_id ON card_orders (card_id);
+CREATE INDEX IF NOT EXISTS idx_card_orders_user_id ON card_orders (user_id);
+CREATE INDEX IF NOT EXISTS idx_card_orders_status ON card_orders (status);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stand_in_authorizations;
+DROP TABLE IF EXISTS card_account_balances;
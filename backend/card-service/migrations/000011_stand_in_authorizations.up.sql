@@ -0,0 +1,32 @@
+-- Stand-in processing: the last available balance the ledger reported per
+-- account, and the card payments approved against it while the ledger was
+-- unreachable, queued for verification once it is back.
+
+CREATE TABLE IF NOT EXISTS card_account_balances (
+    account_id uuid PRIMARY KEY,
+    available_balance numeric(19,4) NOT NULL,
+    fetched_at timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS stand_in_authorizations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    merchant_id varchar(64),
+    merchant_name varchar(100),
+    cached_balance numeric(19,4) NOT NULL,
+    balance_fetched_at timestamptz NOT NULL,
+    status varchar(20) NOT NULL,
+    verified_balance numeric(19,4),
+    verified_at timestamptz,
+    flag_reason varchar(255),
+    reviewed_by uuid,
+    review_note varchar(500),
+    reviewed_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_stand_in_authorizations_card_id ON stand_in_authorizations (card_id);
+CREATE INDEX IF NOT EXISTS idx_stand_in_authorizations_account_status ON stand_in_authorizations (account_id, status);
+CREATE INDEX IF NOT EXISTS idx_stand_in_authorizations_status ON stand_in_authorizations (status);
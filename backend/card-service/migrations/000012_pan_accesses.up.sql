@@ -0,0 +1,15 @@
+-- Every disclosure of a card's full number to an administrator, with who
+-- asked and why. Counted per administrator against the daily access cap.
+
+CREATE TABLE IF NOT EXISTS pan_accesses (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    card_id uuid NOT NULL,
+    accessed_by uuid NOT NULL,
+    reason varchar(30) NOT NULL,
+    justification varchar(500) NOT NULL,
+    reference varchar(100),
+    ip_address varchar(45),
+    created_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_pan_accesses_card_id ON pan_accesses (card_id);
+CREATE INDEX IF NOT EXISTS idx_pan_accesses_accessed_by_created_at ON pan_accesses (accessed_by, created_at);
@@ -0,0 +1,16 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/card-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsCoverModels(t *testing.T) {
+	migrations, err := db.LoadMigrations(FS)
+	require.NoError(t, err)
+	require.NoError(t, db.VerifyModels(migrations, &model.Card{}, &model.NetworkToken{}, &model.CardReplacement{}, &model.OutboxEvent{}, &model.Dispute{}, &model.TravelNotice{}, &model.MerchantRule{}, &model.CardControlChange{}, &model.CardOrder{}, &model.CardTransaction{}, &model.AccountBalance{}, &model.StandInAuthorization{}, &model.PANAccess{}, &jobs.Job{}))
+}
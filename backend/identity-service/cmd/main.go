@@ -1,30 +1,88 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	apispec "github.com/femi-lawal/new_bank/backend/identity-service/api"
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/consumer"
 	"github.com/femi-lawal/new_bank/backend/identity-service/internal/handler"
-	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/identity-service/internal/repository"
 	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/identity-service/migrations"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
 	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/security"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
 )
 
 const serviceName = "identity-service"
 
+// serviceAudiences are the services user and service account tokens can be
+// addressed to; JWT_SERVICE_AUDIENCES replaces the list
+var serviceAudiences = []string{
+	"analytics-service", "card-service", "identity-service", "ledger-service",
+	"payment-service", "product-service", "reporting-service",
+}
+
+// openBankingAudiences are the services with open banking APIs, which are the
+// only ones consent tokens are addressed to
+var openBankingAudiences = []string{"ledger-service"}
+
+// delegatedAudiences are the services with delegated access APIs, which are
+// the only ones delegate tokens are addressed to
+var delegatedAudiences = []string{"ledger-service", "payment-service"}
+
 func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema
+	// "build-breach-filter" builds the offline breached-password filter
+	if len(os.Args) > 1 && os.Args[1] == "build-breach-filter" {
+		os.Exit(buildBreachFilter(os.Args[2:]))
+	}
+	command, args, err := db.ParseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Initialize Logger
 	logger.InitLogger(serviceName, true)
 	slog.Info("Starting Identity Service")
 
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+	apperrors.Problems().RegisterAll(serviceProblemTypes)
+
 	// Initialize Tracing
 	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
 	if err != nil {
@@ -49,20 +107,144 @@ func main() {
 		panic(err)
 	}
 
-	// Auto Migrate
-	if err := database.AutoMigrate(&model.User{}); err != nil {
-		slog.Error("Failed to migrate database", "error", err)
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
 	}
 
 	// Wiring
 	userRepo := repository.NewUserRepository(database)
-	jwtSecret := requireEnv("JWT_SECRET")
+	jwtSecret := cfg.JWT.Secret
+	// Tokens name the services they are for; services reject tokens addressed
+	// elsewhere, and admin routes only accept admin tokens
+	audiences := service.NewTokenAudiences(cfg.JWT.Issuer,
+		splitList(getEnv("JWT_SERVICE_AUDIENCES", strings.Join(serviceAudiences, ","))), openBankingAudiences)
+	audiences.Delegate = delegatedAudiences
 	authService := service.NewAuthService(userRepo, jwtSecret)
+	authService.Audiences = audiences
+	authService.PasswordPolicy, err = service.NewPasswordPolicy(passwordPolicyConfigFromEnv())
+	if err != nil {
+		slog.Error("Invalid password policy", "error", err)
+		panic(err)
+	}
+	authService.MagicLinks = repository.NewMagicLinkRepository(database)
+	authService.Mailer = service.LogEmailSender{}
+	authService.MagicLinkURL = getEnv("MAGIC_LINK_URL", "http://localhost:8081/auth/magic-link/verify")
+	// Login anomaly detection: flagged logins need a code sent via the notification topic
+	authService.LoginActivity = repository.NewLoginActivityRepository(database)
+	kafkaBrokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
+	authService.Notifications = kafka.NewProducer(kafkaBrokers)
+	// Creates the topics this service uses when they are missing, see topics.go;
+	// the outcome, including settings that drifted, is reported in /health
+	topicSettings, err := kafka.TopicSettingsFromEnv()
+	if err != nil {
+		slog.Error("Invalid Kafka topic settings", "error", err)
+		os.Exit(1)
+	}
+	topicAdmin := kafka.NewTopicAdmin(kafkaBrokers)
+	go topicAdmin.Start(context.Background(), kafka.TopicSpecs(topicSettings, serviceTopics...))
+	// Passkeys: the RP ID must be the origins' registrable domain
+	authService.Passkeys = repository.NewPasskeyRepository(database)
+	authService.WebAuthn = &service.WebAuthnConfig{
+		RPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+		RPName:  getEnv("WEBAUTHN_RP_NAME", "NeoBank"),
+		Origins: splitList(getEnv("WEBAUTHN_ORIGINS", "http://localhost:3000")),
+	}
+	// Security signals are counted per minute across replicas for SOC dashboards
+	authService.Signals = service.NewSecurityMetrics(repository.NewSecuritySignalRepository(database))
 	authHandler := handler.NewAuthHandler(authService)
+	authHandler.TrustGeoHeaders = getEnv("TRUST_GEO_HEADERS", "false") == "true"
+
+	// Audit events are persisted so support tooling can review a user's history
+	auditRepo := repository.NewAuditRepository(database)
+	auditLogger := middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{
+		ServiceName: serviceName,
+		Sink:        auditRepo,
+	})
+	authHandler.Audit = auditLogger
+	adminHandler := handler.NewAdminHandler(service.NewAdminService(userRepo, auditRepo), auditLogger)
+	// Service accounts authenticate internal calls with client credentials tokens
+	serviceAccountService := service.NewServiceAccountService(repository.NewServiceAccountRepository(database), jwtSecret)
+	serviceAccountService.Audiences = audiences
+	serviceAccountHandler := handler.NewServiceAccountHandler(serviceAccountService, auditLogger)
+	// Business customers share accounts through organizations with member roles
+	organizationService := service.NewOrganizationService(repository.NewOrganizationRepository(database), userRepo, jwtSecret)
+	organizationService.Audiences = audiences
+	organizationHandler := handler.NewOrganizationHandler(organizationService, auditLogger)
+	// Open banking: third-party clients read account data under consents users approve
+	consentService := service.NewConsentService(repository.NewConsentRepository(database), jwtSecret)
+	consentService.Audiences = audiences
+	consentHandler := handler.NewConsentHandler(consentService, auditLogger)
+	// Delegated access: users let other verified users view or pay from
+	// some of their accounts, e.g. under a power of attorney
+	delegationService := service.NewDelegationService(repository.NewDelegationRepository(database), userRepo, jwtSecret)
+	delegationService.Audiences = audiences
+	delegationHandler := handler.NewDelegationHandler(delegationService, auditLogger)
+	// Terms: users must accept a newly published mandatory version before
+	// they can make changes again; their tokens say so until they do
+	termsService := service.NewTermsService(repository.NewTermsRepository(database))
+	authService.Terms = termsService
+	organizationService.Terms = termsService
+	termsHandler := handler.NewTermsHandler(termsService, authService, auditLogger)
+	// Referrals: invites are emailed via the notification topic, and a referral
+	// completes when the referred user's first payment does
+	referralService := service.NewReferralService(repository.NewReferralRepository(database), userRepo,
+		authService.Notifications, getEnv("REFERRAL_INVITE_URL", "http://localhost:3000/register"))
+	authHandler.Referrals = referralService
+	referralHandler := handler.NewReferralHandler(referralService, auditLogger)
+	go consumer.NewPaymentConsumer(kafkaBrokers, referralService).Start(context.Background())
+	// Profiles: new phone numbers are confirmed with a texted code, and
+	// changes are published on user.updated. SMS_PROVIDER=console logs the
+	// codes instead of sending them, for development.
+	profileService := service.NewProfileService(repository.NewProfileRepository(database), userRepo, authService.Notifications, jwtSecret)
+	profileService.SMS, err = service.NewSMSProvider(getEnv("SMS_PROVIDER", service.SMSProviderNotifications), authService.Notifications)
+	if err != nil {
+		slog.Error("Invalid SMS provider", "error", err)
+		os.Exit(1)
+	}
+	// Pending codes and failed attempts are shared by the replicas through
+	// Redis, so a lockout holds whichever replica a request reaches
+	redisClient, err := cache.NewRedisClient(cache.Config{
+		Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
+		Password: getEnv("REDIS_PASSWORD", ""),
+	})
+	if err != nil {
+		slog.Warn("Redis connection failed, phone verification codes and failed attempts are kept in memory", "error", err)
+	} else {
+		profileService.Codes = service.NewRedisPhoneCodeStore(redisClient)
+		attempts := middleware.NewRedisAttemptStore(redisClient, "attempts:identity-service:")
+		for _, lockout := range []*service.AccountLockout{authService.AccountLockout, authService.MagicLinkLimiter, profileService.CodeLimiter, profileService.CodeAttempts} {
+			lockout.UseAttemptStore(attempts)
+		}
+	}
+	profileHandler := handler.NewProfileHandler(profileService, auditLogger)
+	// Preferences: changes are published on user.preferences.updated for the
+	// services that contact users and format their reports
+	profileHandler.Preferences = service.NewPreferencesService(repository.NewPreferencesRepository(database), userRepo, authService.Notifications)
 
 	// Setup Router
 	r := gin.Default()
 
+	// Stricter limits for /auth/login and /auth/register
+	rateLimiter := middleware.RateLimitWithPolicies(cfg.RateLimitPolicies())
+
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
+
 	// ============================================
 	// Global Middleware (applied to ALL routes)
 	// ============================================
@@ -70,64 +252,246 @@ func main() {
 	r.Use(middleware.RequestLogger(serviceName))     // Request logging with request ID
 	r.Use(middleware.Tracing(serviceName))           // OpenTelemetry tracing
 	r.Use(middleware.CORS())                         // CORS handling
-	r.Use(middleware.RateLimit())                    // Rate limiting
+	r.Use(rateLimiter)                               // Per-endpoint rate limiting
 	r.Use(metrics.PrometheusMiddleware(serviceName)) // Prometheus metrics
 
+	// Readiness needs the database with its migrations applied; without
+	// Kafka the service only degrades
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	healthChecks.RegisterOptional("kafka", health.Kafka(kafkaBrokers))
+	if redisClient != nil {
+		healthChecks.RegisterOptional("redis", health.Redis(redisClient))
+	}
+	healthChecks.Info("kafka_topics", func() interface{} { return topicAdmin.Report() })
+	registerRoutes(r, routeHandlers{
+		auth:            authHandler,
+		admin:           adminHandler,
+		serviceAccounts: serviceAccountHandler,
+		organizations:   organizationHandler,
+		consents:        consentHandler,
+		delegations:     delegationHandler,
+		terms:           termsHandler,
+		referrals:       referralHandler,
+		profiles:        profileHandler,
+		securityMetrics: handler.NewSecurityMetricsHandler(authService.Signals),
+	}, auditLogger, cfg.JWTAuth(), healthChecks)
+
+	port := getEnv("PORT", "8081")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
+
+// routeHandlers are the handlers registerRoutes mounts
+type routeHandlers struct {
+	auth            *handler.AuthHandler
+	admin           *handler.AdminHandler
+	serviceAccounts *handler.ServiceAccountHandler
+	organizations   *handler.OrganizationHandler
+	consents        *handler.ConsentHandler
+	delegations     *handler.DelegationHandler
+	terms           *handler.TermsHandler
+	referrals       *handler.ReferralHandler
+	profiles        *handler.ProfileHandler
+	securityMetrics *handler.SecurityMetricsHandler
+}
+
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes.
+func registerRoutes(r *gin.Engine, hs routeHandlers, auditLogger *middleware.AuditLogger, jwtAuth middleware.JWTAuthConfig, healthChecks *health.Handler) {
+	authHandler, adminHandler, serviceAccountHandler := hs.auth, hs.admin, hs.serviceAccounts
+
 	// ============================================
 	// Public endpoints (no auth required)
 	// ============================================
 	r.GET("/metrics", metrics.MetricsHandler())
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": serviceName,
-		})
-	})
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
 
 	// Auth endpoints (public - for login/register)
 	auth := r.Group("/auth")
 	{
 		auth.POST("/register", authHandler.Register)
 		auth.POST("/login", authHandler.Login)
+		auth.POST("/login/verify", authHandler.VerifyLogin)
+		auth.POST("/magic-link", authHandler.RequestMagicLink)
+		auth.GET("/magic-link/verify", authHandler.VerifyMagicLink)
+		auth.POST("/token", serviceAccountHandler.IssueToken)
+		auth.POST("/webauthn/register/start", middleware.JWTAuthWithConfig(jwtAuth), authHandler.StartPasskeyRegistration)
+		auth.POST("/webauthn/register/finish", middleware.JWTAuthWithConfig(jwtAuth), authHandler.FinishPasskeyRegistration)
+		auth.POST("/webauthn/login/start", authHandler.StartPasskeyLogin)
+		auth.POST("/webauthn/login/finish", authHandler.FinishPasskeyLogin)
 	}
 
 	// ============================================
 	// Protected endpoints (auth required)
 	// ============================================
 	protected := r.Group("/api/v1")
-	protected.Use(middleware.JWTAuth(jwtSecret))
+	protected.Use(middleware.JWTAuthWithConfig(jwtAuth))
+	protected.Use(middleware.AuditMiddleware(auditLogger, serviceName))
+	// Contact details and date of birth are hidden or masked for support callers, see Profile
+	protected.Use(middleware.RedactResponses(service.Profile{}, service.PhoneStatus{}))
+	// Users with pending terms can only read until they accept them
+	protected.Use(middleware.RequireTermsAccepted(handler.TermsPath))
 	{
 		// User profile endpoints
-		protected.GET("/me", func(c *gin.Context) {
-			userID := middleware.GetUserID(c)
-			email := middleware.GetEmail(c)
-			c.JSON(200, gin.H{
-				"user_id": userID,
-				"email":   email,
-			})
-		})
+		hs.profiles.RegisterRoutes(protected)
+		protected.GET("/me/activity", authHandler.RecentActivity)
+		protected.POST("/auth/step-up", authHandler.StepUp)
+		protected.POST("/auth/admin-token", middleware.RequireRole("admin"),
+			middleware.RequireStepUp(middleware.DefaultStepUpMaxAge), authHandler.AdminToken)
+		hs.organizations.RegisterRoutes(protected)
+		hs.consents.RegisterUserRoutes(protected)
+		hs.delegations.RegisterRoutes(protected)
+		hs.terms.RegisterUserRoutes(protected)
+		hs.referrals.RegisterRoutes(protected)
 	}
 
-	port := getEnv("PORT", "8081")
-	slog.Info("Server listening", "port", port)
-	if err := r.Run(":" + port); err != nil {
-		slog.Error("Failed to start server", "error", err)
+	// ============================================
+	// Open banking endpoints (third-party API key required)
+	// ============================================
+	hs.consents.RegisterClientRoutes(r.Group("/api/v1/open-banking"))
+
+	// ============================================
+	// Admin endpoints (support tooling)
+	// ============================================
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.JWTAuthWithConfig(jwtAuth.Admin()), middleware.RequireRole("admin"))
+	adminHandler.RegisterRoutes(admin)
+	serviceAccountHandler.RegisterRoutes(admin)
+	hs.consents.RegisterAdminRoutes(admin)
+	hs.terms.RegisterAdminRoutes(admin)
+	hs.securityMetrics.RegisterRoutes(admin)
+
+	// ============================================
+	// Internal endpoints (service tokens only)
+	// ============================================
+	internal := r.Group("/internal/v1")
+	internal.Use(middleware.JWTAuthWithConfig(jwtAuth), middleware.RequireRole(middleware.ServiceRole))
+	hs.profiles.RegisterInternalRoutes(internal)
+}
+
+// passwordPolicyConfigFromEnv overrides the default password policy from
+// PASSWORD_* and PASSWORD_BREACH_* variables; lists are comma-separated
+func passwordPolicyConfigFromEnv() security.PasswordPolicyConfig {
+	cfg := security.DefaultPasswordPolicyConfig()
+	for key, target := range map[string]*int{
+		"PASSWORD_MIN_LENGTH": &cfg.MinLength,
+		"PASSWORD_MAX_LENGTH": &cfg.MaxLength,
+	} {
+		if value := getEnv(key, ""); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				panic("Invalid " + key + ": " + value)
+			}
+			*target = n
+		}
+	}
+	if value, ok := os.LookupEnv("PASSWORD_REQUIRED_CLASSES"); ok {
+		cfg.RequiredClasses = splitList(value)
 	}
+	cfg.Denylist = splitList(getEnv("PASSWORD_DENYLIST", ""))
+
+	cfg.BreachCheck.Enabled = getEnv("PASSWORD_BREACH_CHECK", "false") == "true"
+	cfg.BreachCheck.APIURL = getEnv("PASSWORD_BREACH_API_URL", cfg.BreachCheck.APIURL)
+	cfg.BreachCheck.BloomFilterPath = getEnv("PASSWORD_BREACH_BLOOM_FILTER", "")
+	cfg.BreachCheck.Offline = getEnv("PASSWORD_BREACH_OFFLINE", "false") == "true"
+	if value := getEnv("PASSWORD_BREACH_TIMEOUT", ""); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			panic("Invalid PASSWORD_BREACH_TIMEOUT: " + value)
+		}
+		cfg.BreachCheck.Timeout = timeout
+	}
+	return cfg
 }
 
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+func splitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
-	return fallback
+	return items
 }
 
-// requireEnv returns the value of an environment variable or panics if not set.
-// Use this for security-critical values that must not have defaults.
-func requireEnv(key string) string {
-	if value, exists := os.LookupEnv(key); exists && value != "" {
+// buildBreachFilter reads a breach corpus of hex SHA-1 hashes (one per line,
+// optionally "HASH:COUNT") and writes a bloom filter for offline breach checks
+func buildBreachFilter(args []string) int {
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprintln(os.Stderr, "usage: build-breach-filter <corpus> <output> [false-positive-rate]")
+		return 2
+	}
+	rate := 0.001
+	if len(args) == 3 {
+		var err error
+		if rate, err = strconv.ParseFloat(args[2], 64); err != nil || rate <= 0 || rate >= 1 {
+			fmt.Fprintln(os.Stderr, "invalid false positive rate:", args[2])
+			return 2
+		}
+	}
+
+	// The corpus is read twice: once to size the filter, once to fill it
+	expected, err := countLines(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	corpus, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer corpus.Close()
+	filter, err := service.BuildBloomFilter(bufio.NewReader(corpus), expected, rate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	out, err := os.Create(args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, err := filter.WriteTo(out); err != nil {
+		out.Close()
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("Wrote bloom filter of %d hashes to %s\n", expected, args[1])
+	return 0
+}
+
+func countLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	n := 0
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) != "" {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
-	slog.Error("Required environment variable not set", "key", key)
-	panic("Required environment variable " + key + " is not set")
+	return fallback
 }
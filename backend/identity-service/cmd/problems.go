@@ -0,0 +1,12 @@
+package main
+
+// serviceProblemTypes title the error codes the identity service defines, keyed by
+// code. Their problem types are namespaced under the service; the standard
+// codes are registered by apperrors.
+var serviceProblemTypes = map[string]string{
+	"CONSENT_STATE":         "Consent is in the wrong state",
+	"DELEGATE_NOT_VERIFIED": "Delegate has not been verified",
+	"DELEGATION_STATE":      "Delegation is in the wrong state",
+	"LAST_OWNER":            "Organization must keep an owner",
+	"REFERRAL_INVITE_STATE": "Referral invite is in the wrong state",
+}
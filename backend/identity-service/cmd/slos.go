@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the identity service, reported at
+// /slo-status. Password hashing dominates login and registration.
+var serviceSLOs = []metrics.SLO{
+	{Name: "login", Method: http.MethodPost, Route: "/auth/login", Threshold: 500 * time.Millisecond, Objective: 0.99},
+	{Name: "register", Method: http.MethodPost, Route: "/auth/register", Threshold: time.Second, Objective: 0.99},
+	{Name: "service-token", Method: http.MethodPost, Route: "/auth/token", Threshold: 100 * time.Millisecond, Objective: 0.999},
+	{Name: "profile", Method: http.MethodGet, Route: "/api/v1/me", Threshold: 50 * time.Millisecond, Objective: 0.999},
+}
@@ -0,0 +1,14 @@
+package main
+
+import "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+
+// serviceTopics are the Kafka topics the identity service produces or consumes.
+// Missing ones are created at startup with the KAFKA_TOPIC_* settings.
+var serviceTopics = []string{
+	kafka.TopicNotificationEmail,
+	kafka.TopicNotificationSMS,
+	kafka.TopicUserUpdated,
+	kafka.TopicUserPreferencesUpdated,
+	kafka.TopicReferralCompleted,
+	kafka.TopicPaymentCompleted,
+}
@@ -0,0 +1,50 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+)
+
+// ReferralConsumerGroup is the Kafka consumer group that completes referrals
+const ReferralConsumerGroup = "identity-service-referrals"
+
+// PaymentConsumer completes the referrals of referred users whose payments
+// complete
+type PaymentConsumer struct {
+	consumer  *kafka.Consumer
+	referrals *service.ReferralService
+}
+
+func NewPaymentConsumer(brokers []string, referrals *service.ReferralService) *PaymentConsumer {
+	return &PaymentConsumer{
+		consumer:  kafka.NewConsumer(brokers, ReferralConsumerGroup, kafka.TopicPaymentCompleted),
+		referrals: referrals,
+	}
+}
+
+// Start consumes completed payments until the context is cancelled. If a
+// referral cannot be completed the failure is logged and the referral stays
+// pending, so the referee's next completed payment completes it.
+func (c *PaymentConsumer) Start(ctx context.Context) error {
+	slog.Info("Starting referral payment consumer", "topic", kafka.TopicPaymentCompleted)
+	return c.consumer.Consume(ctx, func(key string, value []byte) error {
+		var event kafka.PaymentEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			// A malformed event will never parse, so it is skipped
+			slog.Error("Failed to unmarshal payment event", "key", key, "error", err)
+			return nil
+		}
+		if event.UserID == "" {
+			return nil
+		}
+		return c.referrals.CompleteReferral(ctx, event)
+	})
+}
+
+func (c *PaymentConsumer) Close() error {
+	return c.consumer.Close()
+}
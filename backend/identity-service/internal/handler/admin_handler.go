@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes user search and audit history to support tooling
+type AdminHandler struct {
+	Service *service.AdminService
+	Audit   *middleware.AuditLogger
+}
+
+func NewAdminHandler(s *service.AdminService, audit *middleware.AuditLogger) *AdminHandler {
+	return &AdminHandler{Service: s, Audit: audit}
+}
+
+// RegisterRoutes mounts the admin endpoints on a group that is already
+// authenticated and restricted to administrators.
+func (h *AdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/users", h.SearchUsers)
+	rg.GET("/users/:id", h.GetUser)
+	rg.GET("/users/:id/audit", h.GetUserAudit)
+}
+
+type PageQuery struct {
+	Page     int `form:"page" binding:"omitempty,min=1"`
+	PageSize int `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+// UserSearchQuery filters the user search. created_from and created_to accept
+// RFC 3339 timestamps or dates; a date in created_to includes the whole day.
+type UserSearchQuery struct {
+	PageQuery
+	Email       string `form:"email" binding:"omitempty,max=254"`
+	Name        string `form:"name" binding:"omitempty,max=100"`
+	KYCStatus   string `form:"kyc_status" binding:"omitempty,max=32"`
+	CreatedFrom string `form:"created_from"`
+	CreatedTo   string `form:"created_to"`
+}
+
+// SearchUsers returns a page of users filtered by email, name, KYC status and creation date
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	var q UserSearchQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	filter := model.UserFilter{Email: q.Email, Name: q.Name, KYCStatus: q.KYCStatus}
+	var err error
+	if filter.CreatedAfter, err = parseDateParam(q.CreatedFrom, false); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("created_from must be an RFC 3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+	if filter.CreatedBefore, err = parseDateParam(q.CreatedTo, true); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("created_to must be an RFC 3339 timestamp or YYYY-MM-DD date"))
+		return
+	}
+
+	users, page, err := h.Service.SearchUsers(filter, q.Page, q.PageSize)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "user_search",
+		"results":   len(users),
+	})
+	c.JSON(http.StatusOK, gin.H{"items": users, "pagination": page})
+}
+
+// GetUser returns a single user
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.Service.GetUser(c.Param("id"))
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "user_view",
+		"user_id":   user.ID.String(),
+	})
+	c.JSON(http.StatusOK, user)
+}
+
+// GetUserAudit returns the user's audit events, newest first
+func (h *AdminHandler) GetUserAudit(c *gin.Context) {
+	var q PageQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	userID := c.Param("id")
+	events, page, err := h.Service.GetUserAudit(userID, q.Page, q.PageSize)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	// Logged after the read so the page does not include this access
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "audit_view",
+		"user_id":   userID,
+	})
+	c.JSON(http.StatusOK, gin.H{"items": events, "pagination": page})
+}
+
+// parseDateParam parses an optional RFC 3339 timestamp or date. With endOfDay,
+// a plain date is moved to the start of the next day so the range includes it.
+func parseDateParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// respondAdminError maps admin service errors to API errors
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrInvalidDateRange):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -1,18 +1,34 @@
 package handler
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
 
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type AuthHandler struct {
 	Service *service.AuthService
+	Audit   *middleware.AuditLogger
+	// TrustGeoHeaders reads client location from X-Geo-* headers. Enable only
+	// when the edge proxy sets them and strips client-supplied values.
+	TrustGeoHeaders bool
+	// Referrals attributes sign-ups made with an invite token or referral
+	// code; optional, the fields are ignored when nil
+	Referrals *service.ReferralService
 }
 
 func NewAuthHandler(s *service.AuthService) *AuthHandler {
-	return &AuthHandler{Service: s}
+	return &AuthHandler{
+		Service: s,
+		Audit:   middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: "identity-service"}),
+	}
 }
 
 type RegisterRequest struct {
@@ -20,6 +36,9 @@ type RegisterRequest struct {
 	Password  string `json:"password" binding:"required,min=6"`
 	FirstName string `json:"first_name" binding:"required"`
 	LastName  string `json:"last_name" binding:"required"`
+	// InviteToken or ReferralCode attribute the new user to whoever referred them
+	InviteToken  string `json:"invite_token" binding:"omitempty,max=64"`
+	ReferralCode string `json:"referral_code" binding:"omitempty,max=16"`
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -29,12 +48,49 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.Service.Register(req.Email, req.Password, req.FirstName, req.LastName)
+	var source *service.ReferralSource
+	if h.Referrals != nil && (req.InviteToken != "" || req.ReferralCode != "") {
+		var err error
+		source, err = h.Referrals.ResolveReferral(req.Email, req.InviteToken, req.ReferralCode)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidReferralInvite) || errors.Is(err, service.ErrInvalidReferralCode) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check referral"})
+			return
+		}
+	}
+
+	var referredBy *uuid.UUID
+	if source != nil {
+		referredBy = &source.ReferrerID
+	}
+	user, err := h.Service.RegisterReferred(req.Email, req.Password, req.FirstName, req.LastName, referredBy)
 	if err != nil {
+		if errors.Is(err, service.ErrWeakPassword) || errors.Is(err, service.ErrBreachedPassword) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// The user is registered either way; a referral that fails to record only
+	// costs the referrer their reward
+	if source != nil {
+		referral, err := h.Referrals.Attribute(user, source, signupDeviceID(c))
+		if err != nil {
+			slog.Error("Failed to record referral", "user_id", user.ID, "referrer_id", source.ReferrerID, "error", err)
+		} else if referral.Status == model.ReferralRejected {
+			h.Audit.LogEvent(middleware.AuditEventSuspiciousActivity, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"reason":      "referral_" + strings.ToLower(referral.RejectReason),
+				"user_id":     user.ID.String(),
+				"referrer_id": source.ReferrerID.String(),
+			})
+		}
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"id": user.ID, "email": user.Email})
 }
 
@@ -50,11 +106,29 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, err := h.Service.Login(req.Email, req.Password)
+	lc := h.loginContext(c)
+	result, err := h.Service.LoginWithContext(c.Request.Context(), req.Email, req.Password, lc)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if errors.Is(err, service.ErrInvalidCredentials) || errors.Is(err, service.ErrAccountLocked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		return
+	}
+
+	if result.Assessment.Suspicious() {
+		h.auditSuspiciousLogin(c, result, lc)
+	}
+	if result.Challenge != nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"step_up_required": true,
+			"challenge_id":     result.Challenge.ChallengeID,
+			"expires_at":       result.Challenge.ExpiresAt,
+			"reasons":          result.Challenge.Reasons,
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, gin.H{"token": result.Token})
 }
@@ -0,0 +1,313 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader carries a third-party client's API key
+const apiKeyHeader = "X-API-Key"
+
+// thirdPartyClientKey is the context key for the client an API key belongs to
+const thirdPartyClientKey = "third_party_client"
+
+// ConsentHandler serves open banking consents to third-party clients, the
+// users who approve them, and the administrators who register the clients
+type ConsentHandler struct {
+	Service *service.ConsentService
+	Audit   *middleware.AuditLogger
+}
+
+func NewConsentHandler(s *service.ConsentService, audit *middleware.AuditLogger) *ConsentHandler {
+	return &ConsentHandler{Service: s, Audit: audit}
+}
+
+// RegisterClientRoutes mounts the endpoints third-party clients call with
+// their API key. The group must not require a user token.
+func (h *ConsentHandler) RegisterClientRoutes(rg *gin.RouterGroup) {
+	rg.Use(h.RequireAPIKey)
+	rg.POST("/consents", h.RequestConsent)
+	rg.GET("/consents/:id", h.GetClientConsent)
+	rg.DELETE("/consents/:id", h.RevokeClientConsent)
+	rg.POST("/consents/:id/token", h.IssueToken)
+}
+
+// RegisterUserRoutes mounts the endpoints users review and decide consents
+// with on an authenticated group
+func (h *ConsentHandler) RegisterUserRoutes(rg *gin.RouterGroup) {
+	rg.GET("/consents", h.ListUserConsents)
+	rg.GET("/consents/:id", h.GetUserConsent)
+	rg.POST("/consents/:id/approve", h.Approve)
+	rg.POST("/consents/:id/reject", h.Reject)
+	rg.DELETE("/consents/:id", h.RevokeUserConsent)
+}
+
+// RegisterAdminRoutes mounts the client registration endpoints on a group that
+// is already authenticated and restricted to administrators
+func (h *ConsentHandler) RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rg.POST("/third-party-clients", h.CreateClient)
+	rg.GET("/third-party-clients", h.ListClients)
+	rg.DELETE("/third-party-clients/:id", h.RevokeClient)
+}
+
+// RequireAPIKey authenticates a third-party client by its API key
+func (h *ConsentHandler) RequireAPIKey(c *gin.Context) {
+	client, err := h.Service.Authenticate(c.GetHeader(apiKeyHeader))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			h.Audit.LogEvent(middleware.AuditEventUnauthorizedAccess, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"method": "api_key",
+			})
+			apperrors.RespondWithError(c, apperrors.ErrUnauthorized.WithMessage(err.Error()))
+			return
+		}
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.Set(thirdPartyClientKey, client)
+	c.Next()
+}
+
+func thirdPartyClient(c *gin.Context) *model.ThirdPartyClient {
+	client, _ := c.MustGet(thirdPartyClientKey).(*model.ThirdPartyClient)
+	return client
+}
+
+type CreateThirdPartyClientRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+// CreateClient registers a third-party client. The API key is only returned here.
+func (h *ConsentHandler) CreateClient(c *gin.Context) {
+	var req CreateThirdPartyClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	client, apiKey, err := h.Service.CreateClient(req.Name, req.Scopes, middleware.GetUserID(c))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "third_party_client_create",
+		"client_id": client.ClientID,
+		"scopes":    client.Scopes,
+	})
+	c.JSON(http.StatusCreated, gin.H{"client": client, "api_key": apiKey})
+}
+
+// ListClients returns all third-party clients without their API keys
+func (h *ConsentHandler) ListClients(c *gin.Context) {
+	clients, err := h.Service.ListClients()
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": clients})
+}
+
+// RevokeClient stops a third-party client from using its API key
+func (h *ConsentHandler) RevokeClient(c *gin.Context) {
+	if err := h.Service.RevokeClient(c.Param("id")); err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":             "third_party_client_revoke",
+		"third_party_client_id": c.Param("id"),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+type RequestConsentRequest struct {
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+// RequestConsent records a client's request for access to a user's data
+func (h *ConsentHandler) RequestConsent(c *gin.Context) {
+	var req RequestConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	consent, err := h.Service.RequestConsent(thirdPartyClient(c), req.Scopes)
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, consent)
+}
+
+// GetClientConsent returns one of the client's consents, e.g. to poll for approval
+func (h *ConsentHandler) GetClientConsent(c *gin.Context) {
+	consent, err := h.Service.ClientConsent(thirdPartyClient(c), c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, consent)
+}
+
+// RevokeClientConsent lets a client give up one of its consents
+func (h *ConsentHandler) RevokeClientConsent(c *gin.Context) {
+	client := thirdPartyClient(c)
+	consent, err := h.Service.RevokeByClient(client, c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventConsentRevoke, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"consent_id": consent.ID.String(),
+		"client_id":  client.ClientID,
+		"revoked_by": "client",
+	})
+	c.JSON(http.StatusOK, consent)
+}
+
+// IssueToken returns an access token for reading the consented data
+func (h *ConsentHandler) IssueToken(c *gin.Context) {
+	// Token responses must never be cached
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	token, err := h.Service.IssueToken(thirdPartyClient(c), c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// ListUserConsents returns the consents the authenticated user has acted on
+func (h *ConsentHandler) ListUserConsents(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	consents, err := h.Service.ListUserConsents(userID)
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": consents})
+}
+
+// GetUserConsent returns a consent for the user to review or manage
+func (h *ConsentHandler) GetUserConsent(c *gin.Context) {
+	consent, err := h.Service.UserConsent(middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, consent)
+}
+
+type ApproveConsentRequest struct {
+	AccountIDs []string   `json:"account_ids" binding:"required,min=1"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// Approve grants a pending consent on the chosen accounts
+func (h *ConsentHandler) Approve(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req ApproveConsentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	consent, err := h.Service.Approve(userID, c.Param("id"), req.AccountIDs, req.ExpiresAt)
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventConsentGrant, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"consent_id":  consent.ID.String(),
+		"client_name": consent.ClientName,
+		"scopes":      consent.Scopes,
+		"account_ids": consent.AccountIDs,
+		"expires_at":  consent.ExpiresAt,
+	})
+	c.JSON(http.StatusOK, consent)
+}
+
+// Reject declines a pending consent
+func (h *ConsentHandler) Reject(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	consent, err := h.Service.Reject(userID, c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventConsentReject, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"consent_id":  consent.ID.String(),
+		"client_name": consent.ClientName,
+	})
+	c.JSON(http.StatusOK, consent)
+}
+
+// RevokeUserConsent withdraws a consent the user gave
+func (h *ConsentHandler) RevokeUserConsent(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	consent, err := h.Service.RevokeByUser(userID, c.Param("id"))
+	if err != nil {
+		respondConsentError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventConsentRevoke, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"consent_id":  consent.ID.String(),
+		"client_name": consent.ClientName,
+		"revoked_by":  "user",
+	})
+	c.JSON(http.StatusOK, consent)
+}
+
+func respondConsentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConsentNotFound), errors.Is(err, service.ErrThirdPartyClientMissing):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrConsentNotPending), errors.Is(err, service.ErrConsentNotActive):
+		apperrors.RespondWithError(c, apperrors.NewError("CONSENT_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrInvalidScope):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrUnknownScope), errors.Is(err, service.ErrInvalidConsentAccounts),
+		errors.Is(err, service.ErrInvalidConsentExpiry):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// DelegationHandler serves the delegations users grant each other, and the
+// tokens delegates act with
+type DelegationHandler struct {
+	Service *service.DelegationService
+	Audit   *middleware.AuditLogger
+}
+
+func NewDelegationHandler(s *service.DelegationService, audit *middleware.AuditLogger) *DelegationHandler {
+	return &DelegationHandler{Service: s, Audit: audit}
+}
+
+// RegisterRoutes mounts the delegation endpoints on an authenticated group
+func (h *DelegationHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/delegations", h.Grant)
+	rg.GET("/delegations", h.List)
+	rg.GET("/delegations/:id", h.Get)
+	rg.POST("/delegations/:id/revoke", h.Revoke)
+	rg.POST("/delegations/:id/token", h.IssueToken)
+}
+
+type GrantDelegationRequest struct {
+	GranteeEmail string           `json:"grantee_email" binding:"required,email"`
+	Access       string           `json:"access" binding:"required"`
+	AccountIDs   []string         `json:"account_ids" binding:"required,min=1"`
+	PaymentLimit *decimal.Decimal `json:"payment_limit"`
+	ExpiresAt    *time.Time       `json:"expires_at"`
+}
+
+// Grant delegates access to some of the user's accounts to another user
+func (h *DelegationHandler) Grant(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req GrantDelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	delegation, err := h.Service.Grant(userID, service.DelegationGrant{
+		GranteeEmail: req.GranteeEmail,
+		Access:       model.DelegationAccess(req.Access),
+		AccountIDs:   req.AccountIDs,
+		PaymentLimit: req.PaymentLimit,
+		ExpiresAt:    req.ExpiresAt,
+	})
+	if err != nil {
+		respondDelegationError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventDelegationGrant, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"delegation_id": delegation.ID.String(),
+		"grantee_id":    delegation.GranteeID.String(),
+		"access":        delegation.Access,
+		"account_ids":   delegation.AccountIDs,
+		"payment_limit": delegation.PaymentLimit,
+		"expires_at":    delegation.ExpiresAt,
+	})
+	c.JSON(http.StatusCreated, delegation)
+}
+
+// List returns the delegations the user granted or was granted
+func (h *DelegationHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	delegations, err := h.Service.List(userID)
+	if err != nil {
+		respondDelegationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": delegations})
+}
+
+// Get returns a delegation the user granted or was granted
+func (h *DelegationHandler) Get(c *gin.Context) {
+	delegation, err := h.Service.Get(middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		respondDelegationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delegation)
+}
+
+// Revoke ends a delegation the user granted or was granted
+func (h *DelegationHandler) Revoke(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	delegation, err := h.Service.Revoke(userID, c.Param("id"))
+	if err != nil {
+		respondDelegationError(c, err)
+		return
+	}
+
+	revokedBy := "grantor"
+	if delegation.GranteeID.String() == userID {
+		revokedBy = "delegate"
+	}
+	h.Audit.LogEvent(middleware.AuditEventDelegationRevoke, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"delegation_id": delegation.ID.String(),
+		"revoked_by":    revokedBy,
+	})
+	c.JSON(http.StatusOK, delegation)
+}
+
+// IssueToken returns the delegate an access token for acting under the delegation
+func (h *DelegationHandler) IssueToken(c *gin.Context) {
+	// Token responses must never be cached
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	token, err := h.Service.IssueToken(userID, c.Param("id"))
+	if err != nil {
+		respondDelegationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+func respondDelegationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrDelegationNotFound), errors.Is(err, service.ErrDelegateNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrDelegationNotActive):
+		apperrors.RespondWithError(c, apperrors.NewError("DELEGATION_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrDelegateNotVerified):
+		apperrors.RespondWithError(c, apperrors.NewError("DELEGATE_NOT_VERIFIED", err.Error(), http.StatusUnprocessableEntity))
+	case errors.Is(err, service.ErrSelfDelegation), errors.Is(err, service.ErrInvalidDelegationAccess),
+		errors.Is(err, service.ErrInvalidDelegationAccounts), errors.Is(err, service.ErrInvalidPaymentLimit),
+		errors.Is(err, service.ErrInvalidDelegationExpiry):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDeviceIDLength bounds the client-supplied X-Device-ID header
+const maxDeviceIDLength = 128
+
+type VerifyLoginRequest struct {
+	ChallengeID string `json:"challenge_id" binding:"required"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// VerifyLogin completes a flagged login with the code emailed to the user
+func (h *AuthHandler) VerifyLogin(c *gin.Context) {
+	var req VerifyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.Service.VerifyLoginChallenge(req.ChallengeID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrLoginChallengeInvalid), errors.Is(err, service.ErrLoginChallengeLocked):
+			h.Audit.LogEvent(middleware.AuditEventLoginFailed, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"method":       "step_up",
+				"challenge_id": req.ChallengeID,
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify login"})
+		}
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventMFAVerify, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"user_id": result.User.ID.String(),
+		"email":   result.User.Email,
+		"method":  "step_up",
+	})
+	c.JSON(http.StatusOK, gin.H{"token": result.Token})
+}
+
+// RecentActivity lists the caller's recent sign-ins with the device and location used
+func (h *AuthHandler) RecentActivity(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	events, err := h.Service.RecentActivity(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recent activity"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"logins": events})
+}
+
+// auditSuspiciousLogin records a login flagged by anomaly detection
+func (h *AuthHandler) auditSuspiciousLogin(c *gin.Context, result *service.LoginResult, lc service.LoginContext) {
+	metadata := map[string]interface{}{
+		"user_id":          result.User.ID.String(),
+		"email":            result.User.Email,
+		"reasons":          result.Assessment.Reasons(),
+		"device_id":        lc.DeviceID,
+		"step_up_required": result.Challenge != nil,
+	}
+	if lc.Location != nil {
+		metadata["country"] = lc.Location.Country
+		metadata["city"] = lc.Location.City
+	}
+	if result.Assessment.ImpossibleTravel {
+		metadata["distance_km"] = int(result.Assessment.DistanceKm)
+		metadata["speed_kmh"] = int(result.Assessment.SpeedKmh)
+	}
+	h.Audit.LogEvent(middleware.AuditEventSuspiciousActivity, middleware.AuditSeverityWarning, c, metadata)
+}
+
+// loginContext describes the client of a login request. Apps send a stable
+// X-Device-ID; other clients are identified by a hash of their user agent.
+func (h *AuthHandler) loginContext(c *gin.Context) service.LoginContext {
+	lc := service.LoginContext{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		DeviceID:  strings.TrimSpace(c.GetHeader("X-Device-ID")),
+	}
+	if lc.DeviceID == "" || len(lc.DeviceID) > maxDeviceIDLength {
+		sum := sha256.Sum256([]byte(lc.UserAgent))
+		lc.DeviceID = "ua:" + hex.EncodeToString(sum[:8])
+	}
+	if h.TrustGeoHeaders {
+		lc.Location = geoFromHeaders(c)
+	}
+	return lc
+}
+
+// geoFromHeaders reads the location the edge proxy resolved for the client IP
+func geoFromHeaders(c *gin.Context) *service.GeoLocation {
+	lat, latErr := strconv.ParseFloat(c.GetHeader("X-Geo-Latitude"), 64)
+	lon, lonErr := strconv.ParseFloat(c.GetHeader("X-Geo-Longitude"), 64)
+	if latErr != nil || lonErr != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil
+	}
+	country := strings.ToUpper(c.GetHeader("X-Geo-Country"))
+	if len(country) != 2 {
+		country = ""
+	}
+	city := c.GetHeader("X-Geo-City")
+	if len(city) > 100 {
+		city = city[:100]
+	}
+	return &service.GeoLocation{Country: country, City: city, Latitude: lat, Longitude: lon}
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RequestMagicLink emails a one-time login link. The response is the same
+// whether or not the email belongs to an account.
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.Service.RequestMagicLink(req.Email); err != nil {
+		switch {
+		case errors.Is(err, service.ErrMagicLinkRateLimited):
+			h.Audit.LogEvent(middleware.AuditEventRateLimitExceeded, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"email":  req.Email,
+				"method": "magic_link",
+			})
+			c.Header("Retry-After", "900")
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrMagicLinkDisabled):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send magic link"})
+		}
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventMagicLinkSent, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"email": req.Email,
+	})
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the email is registered, a login link has been sent"})
+}
+
+// VerifyMagicLink exchanges a magic link token for an access and refresh token pair
+func (h *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	user, pair, err := h.Service.VerifyMagicLink(token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMagicLinkInvalid):
+			h.Audit.LogEvent(middleware.AuditEventLoginFailed, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"method": "magic_link",
+			})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrMagicLinkDisabled):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify magic link"})
+		}
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventLogin, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"user_id": user.ID.String(),
+		"email":   user.Email,
+		"method":  "magic_link",
+	})
+	c.JSON(http.StatusOK, pair)
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// OrganizationHandler manages business organizations and their members
+type OrganizationHandler struct {
+	Service *service.OrganizationService
+	Audit   *middleware.AuditLogger
+}
+
+func NewOrganizationHandler(s *service.OrganizationService, audit *middleware.AuditLogger) *OrganizationHandler {
+	return &OrganizationHandler{Service: s, Audit: audit}
+}
+
+// RegisterRoutes mounts the organization endpoints on an authenticated group
+func (h *OrganizationHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/organizations", h.Create)
+	rg.GET("/organizations", h.List)
+	rg.POST("/organizations/:id/members", h.InviteMember)
+	rg.GET("/organizations/:id/members", h.ListMembers)
+	rg.DELETE("/organizations/:id/members/:userId", h.RemoveMember)
+	rg.POST("/organizations/:id/accept", h.AcceptInvitation)
+	rg.POST("/organizations/:id/token", h.IssueToken)
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=200"`
+}
+
+// Create creates an organization owned by the authenticated user
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	org, err := h.Service.CreateOrganization(userID, req.Name)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "organization_create",
+		"org_id":    org.ID.String(),
+	})
+	c.JSON(http.StatusCreated, org)
+}
+
+// List returns the organizations the user belongs to or is invited to
+func (h *OrganizationHandler) List(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	memberships, err := h.Service.ListMemberships(userID)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": memberships})
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// InviteMember invites a registered user to the organization with a role
+func (h *OrganizationHandler) InviteMember(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	member, err := h.Service.InviteMember(userID, c.Param("id"), req.Email, req.Role)
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation":      "organization_invite",
+		"org_id":         member.OrgID.String(),
+		"member_user_id": member.UserID.String(),
+		"org_role":       member.Role,
+	})
+	c.JSON(http.StatusCreated, member)
+}
+
+// ListMembers returns the organization's members and pending invitations
+func (h *OrganizationHandler) ListMembers(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	members, err := h.Service.ListMembers(userID, c.Param("id"))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": members})
+}
+
+// RemoveMember removes a member, withdraws an invitation, or lets a member leave
+func (h *OrganizationHandler) RemoveMember(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	if err := h.Service.RemoveMember(userID, c.Param("id"), c.Param("userId")); err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":      "organization_remove_member",
+		"org_id":         c.Param("id"),
+		"member_user_id": c.Param("userId"),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// AcceptInvitation makes the authenticated user an active member
+func (h *OrganizationHandler) AcceptInvitation(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	member, err := h.Service.AcceptInvitation(userID, c.Param("id"))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "organization_accept",
+		"org_id":    member.OrgID.String(),
+		"org_role":  member.Role,
+	})
+	c.JSON(http.StatusOK, member)
+}
+
+// IssueToken returns an access token for acting within the organization
+func (h *OrganizationHandler) IssueToken(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	token, err := h.Service.IssueToken(userID, c.Param("id"))
+	if err != nil {
+		respondOrganizationError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// respondOrganizationError maps organization errors to API errors
+func respondOrganizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidOrgName), errors.Is(err, service.ErrInvalidOrgRole),
+		errors.Is(err, service.ErrInviteeNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrOrganizationNotFound), errors.Is(err, service.ErrInvitationNotFound),
+		errors.Is(err, service.ErrMemberNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrOrgForbidden):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAlreadyMember):
+		apperrors.RespondWithError(c, apperrors.ErrAlreadyExists.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrLastOwner):
+		apperrors.RespondWithError(c, apperrors.NewError("LAST_OWNER", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
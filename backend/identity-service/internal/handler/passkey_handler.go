@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type FinishPasskeyRegistrationRequest struct {
+	Name       string                     `json:"name"`
+	Credential service.PasskeyAttestation `json:"credential" binding:"required"`
+}
+
+type StartPasskeyLoginRequest struct {
+	Email       string `json:"email" binding:"omitempty,email"`
+	ChallengeID string `json:"challenge_id"`
+}
+
+// StartPasskeyRegistration returns the options for navigator.credentials.create
+func (h *AuthHandler) StartPasskeyRegistration(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	options, err := h.Service.BeginPasskeyRegistration(userID)
+	if err != nil {
+		respondPasskeyError(c, err, "failed to start passkey registration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// FinishPasskeyRegistration verifies the new credential and adds it to the caller's passkeys
+func (h *AuthHandler) FinishPasskeyRegistration(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req FinishPasskeyRegistrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.Service.FinishPasskeyRegistration(userID, req.Name, req.Credential)
+	if err != nil {
+		respondPasskeyError(c, err, "failed to register passkey")
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventMFAEnroll, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"user_id":          userID,
+		"method":           "passkey",
+		"credential_id":    credential.CredentialID,
+		"attestation_type": credential.AttestationType,
+	})
+	c.JSON(http.StatusCreated, credential)
+}
+
+// StartPasskeyLogin returns the options for navigator.credentials.get. A
+// challenge_id from a flagged password login makes the passkey its second factor.
+func (h *AuthHandler) StartPasskeyLogin(c *gin.Context) {
+	var req StartPasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options, err := h.Service.BeginPasskeyLogin(req.Email, req.ChallengeID)
+	if err != nil {
+		respondPasskeyError(c, err, "failed to start passkey login")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": options})
+}
+
+// FinishPasskeyLogin verifies a passkey assertion. A step-up login returns an
+// access token like /auth/login/verify; a passwordless login returns a token
+// pair like /auth/magic-link/verify.
+func (h *AuthHandler) FinishPasskeyLogin(c *gin.Context) {
+	var req service.PasskeyAssertion
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.Service.FinishPasskeyLogin(req)
+	if err != nil {
+		if errors.Is(err, service.ErrPasskeyInvalid) || errors.Is(err, service.ErrLoginChallengeInvalid) {
+			h.Audit.LogEvent(middleware.AuditEventLoginFailed, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"method":        "passkey",
+				"credential_id": req.RawID,
+				"reason":        err.Error(),
+			})
+		}
+		respondPasskeyError(c, err, "failed to verify passkey")
+		return
+	}
+
+	if result.StepUp {
+		h.Audit.LogEvent(middleware.AuditEventMFAVerify, middleware.AuditSeverityInfo, c, map[string]interface{}{
+			"user_id": result.User.ID.String(),
+			"email":   result.User.Email,
+			"method":  "passkey",
+		})
+		c.JSON(http.StatusOK, gin.H{"token": result.Token})
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventLogin, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"user_id": result.User.ID.String(),
+		"email":   result.User.Email,
+		"method":  "passkey",
+	})
+	c.JSON(http.StatusOK, result.Tokens)
+}
+
+// respondPasskeyError maps passkey errors to responses; verification details
+// are audited but not returned
+func respondPasskeyError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrPasskeysDisabled):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPasskeyInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": service.ErrPasskeyInvalid.Error()})
+	case errors.Is(err, service.ErrLoginChallengeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPasskeyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoPasskeys):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileHandler serves the caller's own profile, its change history, phone
+// number verification and preferences
+type ProfileHandler struct {
+	Service     *service.ProfileService
+	Preferences *service.PreferencesService
+	Audit       *middleware.AuditLogger
+}
+
+func NewProfileHandler(s *service.ProfileService, audit *middleware.AuditLogger) *ProfileHandler {
+	return &ProfileHandler{Service: s, Audit: audit}
+}
+
+// RegisterRoutes mounts the profile endpoints on an authenticated group
+func (h *ProfileHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/me", h.GetProfile)
+	rg.PATCH("/me", h.UpdateProfile)
+	rg.GET("/me/history", h.History)
+	rg.GET("/me/phone", h.GetPhone)
+	rg.POST("/me/phone", h.RequestPhoneVerification)
+	rg.POST("/me/phone/verify", h.VerifyPhone)
+	rg.GET("/me/preferences", h.GetPreferences)
+	rg.PATCH("/me/preferences", h.UpdatePreferences)
+}
+
+// RegisterInternalRoutes mounts the endpoints other services call, on a
+// group that only admits service tokens
+func (h *ProfileHandler) RegisterInternalRoutes(rg *gin.RouterGroup) {
+	rg.GET("/users/:id/phone", h.GetUserPhone)
+	rg.GET("/users/:id/preferences", h.GetUserPreferences)
+}
+
+type UpdateProfileRequest struct {
+	Phone       *string          `json:"phone" binding:"omitempty,max=32"`
+	DateOfBirth *string          `json:"date_of_birth" binding:"omitempty,max=10"`
+	Address     *service.Address `json:"address"`
+}
+
+type RequestPhoneVerificationRequest struct {
+	Phone string `json:"phone" binding:"required,max=32"`
+}
+
+type VerifyPhoneRequest struct {
+	VerificationID string `json:"verification_id" binding:"required,uuid"`
+	Code           string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// GetProfile returns the caller's profile and the details they have yet to add
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	profile, err := h.Service.GetProfile(middleware.GetUserID(c))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// UpdateProfile changes the fields sent and leaves the rest as they are. A
+// new phone number is saved once confirmed through VerifyPhone.
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	result, err := h.Service.UpdateProfile(c.Request.Context(), middleware.GetUserID(c), service.ProfileUpdate{
+		Phone:       req.Phone,
+		DateOfBirth: req.DateOfBirth,
+		Address:     req.Address,
+	})
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	fields := []string{}
+	if req.Phone != nil {
+		fields = append(fields, "phone")
+	}
+	if req.DateOfBirth != nil {
+		fields = append(fields, "date_of_birth")
+	}
+	if req.Address != nil {
+		fields = append(fields, "address")
+	}
+	h.Audit.LogEvent(middleware.AuditEventProfileUpdate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"fields":                      fields,
+		"phone_verification_required": result.PhoneVerification != nil,
+	})
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPhone returns the caller's phone number and whether it is verified
+func (h *ProfileHandler) GetPhone(c *gin.Context) {
+	status, err := h.Service.PhoneStatus(middleware.GetUserID(c))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// RequestPhoneVerification texts a code to a new phone number, which is
+// saved once confirmed through VerifyPhone. The verified number needs no code.
+func (h *ProfileHandler) RequestPhoneVerification(c *gin.Context) {
+	var req RequestPhoneVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	verification, err := h.Service.RequestPhoneVerification(c.Request.Context(), userID, req.Phone)
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	if verification == nil {
+		status, err := h.Service.PhoneStatus(userID)
+		if err != nil {
+			respondProfileError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, status)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventProfileUpdate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"fields":                      []string{"phone"},
+		"phone_verification_required": true,
+		"verification_id":             verification.VerificationID,
+	})
+	c.JSON(http.StatusAccepted, verification)
+}
+
+// VerifyPhone saves a new phone number with the code texted to it
+func (h *ProfileHandler) VerifyPhone(c *gin.Context) {
+	var req VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	profile, err := h.Service.ConfirmPhone(c.Request.Context(), middleware.GetUserID(c), req.VerificationID, req.Code)
+	if err != nil {
+		if errors.Is(err, service.ErrPhoneVerificationLocked) || errors.Is(err, service.ErrPhoneCodeEntryLocked) {
+			h.Audit.LogEvent(middleware.AuditEventSuspiciousActivity, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"reason":          "phone_verification_locked",
+				"verification_id": req.VerificationID,
+			})
+		}
+		respondProfileError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPhoneVerify, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"verification_id": req.VerificationID,
+	})
+	c.JSON(http.StatusOK, profile)
+}
+
+// History returns the caller's recent profile changes, newest first
+func (h *ProfileHandler) History(c *gin.Context) {
+	changes, err := h.Service.ProfileHistory(middleware.GetUserID(c))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": changes})
+}
+
+// GetUserPhone returns a user's phone number and whether it is verified, for
+// services deciding whether to text the user, e.g. for step-up or notifications
+func (h *ProfileHandler) GetUserPhone(c *gin.Context) {
+	status, err := h.Service.PhoneStatus(c.Param("id"))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// GetPreferences returns the caller's preferences, with the defaults for
+// those they never changed
+func (h *ProfileHandler) GetPreferences(c *gin.Context) {
+	prefs, err := h.Preferences.GetPreferences(middleware.GetUserID(c))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences changes the preferences and channels sent and leaves the
+// rest as they are
+func (h *ProfileHandler) UpdatePreferences(c *gin.Context) {
+	var req service.PreferencesUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	prefs, err := h.Preferences.UpdatePreferences(c.Request.Context(), middleware.GetUserID(c), req)
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	fields := []string{}
+	if req.Locale != nil {
+		fields = append(fields, service.PreferenceFieldLocale)
+	}
+	if req.DisplayCurrency != nil {
+		fields = append(fields, service.PreferenceFieldDisplayCurrency)
+	}
+	if req.MarketingOptIns != nil {
+		fields = append(fields, service.PreferenceFieldMarketing)
+	}
+	if req.Notifications != nil {
+		fields = append(fields, service.PreferenceFieldNotifications)
+	}
+	h.Audit.LogEvent(middleware.AuditEventProfileUpdate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"fields":            fields,
+		"marketing_opt_ins": prefs.MarketingOptIns,
+	})
+	c.JSON(http.StatusOK, prefs)
+}
+
+// GetUserPreferences returns a user's preferences, for services deciding how
+// and whether to contact the user or how to format their reports
+func (h *ProfileHandler) GetUserPreferences(c *gin.Context) {
+	prefs, err := h.Preferences.GetPreferences(c.Param("id"))
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+func respondProfileError(c *gin.Context, err error) {
+	var invalid *service.ProfileValidationError
+	switch {
+	case errors.As(err, &invalid):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()).WithDetails(invalid.Fields))
+	case errors.Is(err, service.ErrProfileNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPhoneVerificationInvalid):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPhoneVerificationLocked), errors.Is(err, service.ErrPhoneCodeEntryLocked), errors.Is(err, service.ErrPhoneVerificationRateLimited):
+		apperrors.RespondWithError(c, apperrors.ErrRateLimited.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPhoneVerificationUnavailable):
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReferralHandler serves users' referral codes, the invites they send, and
+// the referrals they have made
+type ReferralHandler struct {
+	Service *service.ReferralService
+	Audit   *middleware.AuditLogger
+}
+
+func NewReferralHandler(s *service.ReferralService, audit *middleware.AuditLogger) *ReferralHandler {
+	return &ReferralHandler{Service: s, Audit: audit}
+}
+
+// RegisterRoutes mounts the referral endpoints on an authenticated group
+func (h *ReferralHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/referrals", h.ListReferrals)
+	rg.GET("/referrals/code", h.GetCode)
+	rg.POST("/referrals/invites", h.CreateInvite)
+	rg.GET("/referrals/invites", h.ListInvites)
+	rg.POST("/referrals/invites/:id/resend", h.ResendInvite)
+}
+
+// GetCode returns the user's referral code, creating it on first use
+func (h *ReferralHandler) GetCode(c *gin.Context) {
+	code, err := h.Service.GetReferralCode(middleware.GetUserID(c))
+	if err != nil {
+		respondReferralError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, code)
+}
+
+type CreateReferralInviteRequest struct {
+	Email string `json:"email" binding:"required,email,max=255"`
+}
+
+// CreateInvite emails an invite to sign up
+func (h *ReferralHandler) CreateInvite(c *gin.Context) {
+	var req CreateReferralInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	invite, err := h.Service.CreateInvite(c.Request.Context(), middleware.GetUserID(c), req.Email)
+	if err != nil {
+		if errors.Is(err, service.ErrReferralInviteLimit) {
+			h.Audit.LogEvent(middleware.AuditEventSuspiciousActivity, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"reason": "referral_invite_limit",
+			})
+		}
+		respondReferralError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, invite)
+}
+
+// ListInvites returns the invites the user has sent
+func (h *ReferralHandler) ListInvites(c *gin.Context) {
+	invites, err := h.Service.ListInvites(middleware.GetUserID(c))
+	if err != nil {
+		respondReferralError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": invites})
+}
+
+// ResendInvite emails an unaccepted invite again with a new link
+func (h *ReferralHandler) ResendInvite(c *gin.Context) {
+	invite, err := h.Service.ResendInvite(c.Request.Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		respondReferralError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, invite)
+}
+
+// ListReferrals returns the users the user has referred and each referral's status
+func (h *ReferralHandler) ListReferrals(c *gin.Context) {
+	referrals, err := h.Service.ListReferrals(middleware.GetUserID(c))
+	if err != nil {
+		respondReferralError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": referrals})
+}
+
+// signupDeviceID returns the device a sign-up came from if the app identified
+// it. Unlike at login, a user agent hash is not used: many users share one,
+// so it would reject referrals from unrelated people.
+func signupDeviceID(c *gin.Context) string {
+	deviceID := strings.TrimSpace(c.GetHeader("X-Device-ID"))
+	if len(deviceID) > maxDeviceIDLength {
+		return ""
+	}
+	return deviceID
+}
+
+func respondReferralError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrReferralInviteNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrReferralInviteExists), errors.Is(err, service.ErrReferralInviteeRegistered),
+		errors.Is(err, service.ErrReferralInviteAccepted), errors.Is(err, service.ErrReferralInviteChanged):
+		apperrors.RespondWithError(c, apperrors.NewError("REFERRAL_INVITE_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrReferralInviteLimit), errors.Is(err, service.ErrReferralResendLimit):
+		apperrors.RespondWithError(c, apperrors.ErrRateLimited.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// ServiceAccountHandler manages service accounts and issues their tokens
+type ServiceAccountHandler struct {
+	Service *service.ServiceAccountService
+	Audit   *middleware.AuditLogger
+}
+
+func NewServiceAccountHandler(s *service.ServiceAccountService, audit *middleware.AuditLogger) *ServiceAccountHandler {
+	return &ServiceAccountHandler{Service: s, Audit: audit}
+}
+
+// RegisterRoutes mounts the service account endpoints on a group that is
+// already authenticated and restricted to administrators.
+func (h *ServiceAccountHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.POST("/service-accounts", h.Create)
+	rg.GET("/service-accounts", h.List)
+	rg.DELETE("/service-accounts/:id", h.Revoke)
+}
+
+type CreateServiceAccountRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+}
+
+// Create registers a service account. The client secret is only returned here.
+func (h *ServiceAccountHandler) Create(c *gin.Context) {
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	account, secret, err := h.Service.Create(req.Name, req.Scopes, middleware.GetUserID(c))
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation": "service_account_create",
+		"client_id": account.ClientID,
+		"scopes":    account.Scopes,
+	})
+	c.JSON(http.StatusCreated, gin.H{"service_account": account, "client_secret": secret})
+}
+
+// List returns all service accounts without their secrets
+func (h *ServiceAccountHandler) List(c *gin.Context) {
+	accounts, err := h.Service.List()
+	if err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": accounts})
+}
+
+// Revoke stops a service account from obtaining new tokens
+func (h *ServiceAccountHandler) Revoke(c *gin.Context) {
+	if err := h.Service.Revoke(c.Param("id")); err != nil {
+		respondServiceAccountError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventPermissionChange, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":          "service_account_revoke",
+		"service_account_id": c.Param("id"),
+	})
+	c.Status(http.StatusNoContent)
+}
+
+// TokenRequest is an OAuth 2.0 token request. Clients should send their
+// credentials with HTTP Basic auth; client_id and client_secret form fields
+// are accepted as a fallback.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Scope        string `form:"scope"`
+	Audience     string `form:"audience"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// IssueToken implements the client credentials grant (RFC 6749 section 4.4)
+func (h *ServiceAccountHandler) IssueToken(c *gin.Context) {
+	// Token responses must never be cached
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	var req TokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		respondOAuthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if req.GrantType != "client_credentials" {
+		respondOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	clientID, clientSecret := req.ClientID, req.ClientSecret
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		// Basic auth credentials are form-encoded first (RFC 6749 section 2.3.1)
+		clientID, _ = url.QueryUnescape(id)
+		clientSecret, _ = url.QueryUnescape(secret)
+	}
+	if clientID == "" || clientSecret == "" {
+		respondOAuthError(c, http.StatusUnauthorized, "invalid_client", "client authentication is required")
+		return
+	}
+
+	token, err := h.Service.IssueToken(clientID, clientSecret, req.Scope, req.Audience)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidClient):
+			h.Audit.LogEvent(middleware.AuditEventLoginFailed, middleware.AuditSeverityWarning, c, map[string]interface{}{
+				"method":    "client_credentials",
+				"client_id": clientID,
+			})
+			c.Header("WWW-Authenticate", `Basic realm="token"`)
+			respondOAuthError(c, http.StatusUnauthorized, "invalid_client", err.Error())
+		case errors.Is(err, service.ErrInvalidScope):
+			respondOAuthError(c, http.StatusBadRequest, "invalid_scope", err.Error())
+		case errors.Is(err, service.ErrInvalidTarget):
+			respondOAuthError(c, http.StatusBadRequest, "invalid_target", err.Error())
+		default:
+			respondOAuthError(c, http.StatusInternalServerError, "server_error", "failed to issue token")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// respondOAuthError writes an error in the OAuth 2.0 token endpoint format
+func respondOAuthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+func respondServiceAccountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrServiceAccountNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrUnknownScope):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// StepUpRequest carries either the caller's password or a passkey assertion
+// for a login ceremony started with their email at /auth/webauthn/login/start
+type StepUpRequest struct {
+	Password string                    `json:"password"`
+	Passkey  *service.PasskeyAssertion `json:"passkey"`
+}
+
+// StepUp re-authenticates the signed-in user and returns a short-lived token
+// that unlocks high-risk actions such as large transfers and PIN changes
+func (h *AuthHandler) StepUp(c *gin.Context) {
+	claims := middleware.GetClaims(c)
+	if claims == nil || claims.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if claims.Role == middleware.ServiceRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "service accounts cannot step up"})
+		return
+	}
+
+	var req StepUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	caller := service.StepUpCaller{UserID: claims.UserID, OrgID: middleware.GetOrgID(c), OrgRole: middleware.GetOrgRole(c)}
+	token, err := h.Service.StepUp(caller, service.StepUpCredentials{Password: req.Password, Passkey: req.Passkey})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidStepUp):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrAccountLocked):
+			h.auditFailedStepUp(c, claims.UserID, err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrPasskeyInvalid):
+			h.auditFailedStepUp(c, claims.UserID, err)
+			respondPasskeyError(c, err, "failed to verify passkey")
+		default:
+			respondPasskeyError(c, err, "failed to step up")
+		}
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventMFAVerify, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"user_id": claims.UserID,
+		"method":  token.Method,
+		"purpose": "step_up",
+	})
+	c.JSON(http.StatusOK, token)
+}
+
+// AdminToken exchanges a stepped-up admin session for a token for the
+// services' admin routes, which do not accept login tokens
+func (h *AuthHandler) AdminToken(c *gin.Context) {
+	claims := middleware.GetClaims(c)
+	if claims == nil || claims.UserID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// RequireStepUp has checked the session was stepped up
+	var authTime time.Time
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime.Time
+	}
+	token, err := h.Service.IssueAdminToken(claims.UserID, authTime, claims.AMR)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrNotAdmin):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue admin token"})
+		}
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventSessionCreate, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"user_id": claims.UserID,
+		"purpose": "admin",
+	})
+	c.JSON(http.StatusOK, token)
+}
+
+func (h *AuthHandler) auditFailedStepUp(c *gin.Context, userID string, err error) {
+	h.Audit.LogEvent(middleware.AuditEventLoginFailed, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"user_id": userID,
+		"purpose": "step_up",
+		"reason":  err.Error(),
+	})
+}
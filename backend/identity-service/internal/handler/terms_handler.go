@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// TermsPath is where users review and accept the terms. It must stay
+// reachable for users with pending terms, see middleware.RequireTermsAccepted.
+const TermsPath = "/api/v1/terms"
+
+// TermsHandler serves the terms of service and privacy policy versions to the
+// users who accept them and the administrators who publish them
+type TermsHandler struct {
+	Service *service.TermsService
+	// Auth re-issues the caller's token once the terms are accepted, so the
+	// terms_pending flag clears without logging in again
+	Auth  *service.AuthService
+	Audit *middleware.AuditLogger
+}
+
+func NewTermsHandler(s *service.TermsService, auth *service.AuthService, audit *middleware.AuditLogger) *TermsHandler {
+	return &TermsHandler{Service: s, Auth: auth, Audit: audit}
+}
+
+// RegisterUserRoutes mounts the endpoints users review and accept the terms
+// with on an authenticated group
+func (h *TermsHandler) RegisterUserRoutes(rg *gin.RouterGroup) {
+	rg.GET("/terms", h.Status)
+	rg.POST("/terms/accept", h.Accept)
+}
+
+// RegisterAdminRoutes mounts the publishing endpoints on a group that is
+// already authenticated and restricted to administrators
+func (h *TermsHandler) RegisterAdminRoutes(rg *gin.RouterGroup) {
+	rg.POST("/terms", h.Publish)
+	rg.GET("/terms", h.ListVersions)
+}
+
+// Status returns the current terms and whether the user accepted them
+func (h *TermsHandler) Status(c *gin.Context) {
+	status, err := h.Service.Status(middleware.GetUserID(c))
+	if err != nil {
+		respondTermsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+type AcceptTermsRequest struct {
+	DocumentIDs []string `json:"document_ids" binding:"required,min=1"`
+}
+
+// Accept records the user's acceptance of the current terms and returns a new
+// access token reflecting it
+func (h *TermsHandler) Accept(c *gin.Context) {
+	var req AcceptTermsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	userID := middleware.GetUserID(c)
+	status, err := h.Service.Accept(userID, req.DocumentIDs, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondTermsError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventConsentGrant, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation":    "terms_accept",
+		"document_ids": req.DocumentIDs,
+	})
+
+	// Token responses must never be cached
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	token, err := h.Auth.IssueUserToken(userID)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"documents":    status.Documents,
+		"pending":      status.Pending,
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(service.AccessTokenExpiry.Seconds()),
+	})
+}
+
+type PublishTermsRequest struct {
+	Kind      model.TermsKind `json:"kind" binding:"required"`
+	Version   string          `json:"version" binding:"required,max=50"`
+	Title     string          `json:"title" binding:"required,max=200"`
+	URL       string          `json:"url" binding:"required,url,max=500"`
+	Summary   string          `json:"summary"`
+	Mandatory bool            `json:"mandatory"`
+}
+
+// Publish adds a version of the terms of service or privacy policy
+func (h *TermsHandler) Publish(c *gin.Context) {
+	var req PublishTermsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	doc, err := h.Service.PublishTerms(model.TermsDocument{
+		Kind:      req.Kind,
+		Version:   req.Version,
+		Title:     req.Title,
+		URL:       req.URL,
+		Summary:   req.Summary,
+		Mandatory: req.Mandatory,
+	}, middleware.GetUserID(c))
+	if err != nil {
+		respondTermsError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":   "terms_publish",
+		"document_id": doc.ID.String(),
+		"kind":        doc.Kind,
+		"version":     doc.Version,
+		"mandatory":   doc.Mandatory,
+	})
+	c.JSON(http.StatusCreated, doc)
+}
+
+// ListVersions returns every published version with its acceptance rate
+func (h *TermsHandler) ListVersions(c *gin.Context) {
+	versions, err := h.Service.ListVersions()
+	if err != nil {
+		respondTermsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": versions})
+}
+
+func respondTermsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTermsKind), errors.Is(err, service.ErrTermsNotCurrent):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrTermsVersionExists):
+		apperrors.RespondWithError(c, apperrors.ErrAlreadyExists.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
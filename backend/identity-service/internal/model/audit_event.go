@@ -0,0 +1,34 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is a persisted copy of a security audit event. SubjectUserID is
+// the user the event is about, which differs from ActorUserID for admin
+// actions and for logins where the caller was not yet authenticated.
+type AuditEvent struct {
+	ID            uuid.UUID              `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventID       string                 `gorm:"type:varchar(64);index" json:"event_id"`
+	EventType     string                 `gorm:"type:varchar(64);not null;index" json:"event_type"`
+	Severity      string                 `gorm:"type:varchar(16);not null" json:"severity"`
+	SubjectUserID string                 `gorm:"type:varchar(64);index:idx_audit_subject_time,priority:1" json:"subject_user_id,omitempty"`
+	ActorUserID   string                 `gorm:"type:varchar(64);index" json:"actor_user_id,omitempty"`
+	Email         string                 `json:"email,omitempty"`
+	Action        string                 `json:"action"`
+	Resource      string                 `json:"resource"`
+	Method        string                 `gorm:"type:varchar(10)" json:"method"`
+	Path          string                 `json:"path"`
+	IP            string                 `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent     string                 `json:"user_agent"`
+	StatusCode    int                    `json:"status_code"`
+	Success       bool                   `json:"success"`
+	RequestID     string                 `json:"request_id,omitempty"`
+	ErrorMsg      string                 `json:"error,omitempty"`
+	Metadata      map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"metadata,omitempty"`
+	ServiceName   string                 `gorm:"type:varchar(64)" json:"service_name"`
+	OccurredAt    time.Time              `gorm:"not null;index:idx_audit_subject_time,priority:2" json:"occurred_at"`
+	CreatedAt     time.Time              `json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ThirdPartyClient is an external provider that reads customer data under
+// their consent. It authenticates with an API key; only its SHA-256 hash is stored.
+type ThirdPartyClient struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name       string    `gorm:"type:varchar(100);not null" json:"name"`
+	ClientID   string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"client_id"`
+	APIKeyHash string    `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	// Scopes bounds what the client's consents may ask for
+	Scopes    []string   `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type ConsentStatus string
+
+const (
+	// ConsentAwaitingAuthorisation is a client's request no user has acted on yet
+	ConsentAwaitingAuthorisation ConsentStatus = "AWAITING_AUTHORISATION"
+	ConsentAuthorised            ConsentStatus = "AUTHORISED"
+	ConsentRejected              ConsentStatus = "REJECTED"
+	ConsentRevoked               ConsentStatus = "REVOKED"
+)
+
+// Consent lets a third-party client read some of a user's accounts until it
+// expires or is revoked. UserID and AccountIDs are set when the user approves.
+type Consent struct {
+	ID           uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ClientID     uuid.UUID     `gorm:"type:uuid;not null;index" json:"client_id"`
+	ClientName   string        `gorm:"type:varchar(100);not null" json:"client_name"`
+	UserID       *uuid.UUID    `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	Scopes       []string      `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	AccountIDs   []string      `gorm:"type:jsonb;serializer:json" json:"account_ids,omitempty"`
+	Status       ConsentStatus `gorm:"type:varchar(30);not null" json:"status"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`
+	AuthorisedAt *time.Time    `json:"authorised_at,omitempty"`
+	RevokedAt    *time.Time    `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// Active reports whether the consent currently allows access
+func (c *Consent) Active(now time.Time) bool {
+	return c.Status == ConsentAuthorised && c.ExpiresAt != nil && now.Before(*c.ExpiresAt)
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DelegationAccess is what a delegation lets the delegate do
+type DelegationAccess string
+
+const (
+	// DelegationReadOnly lets the delegate view the accounts
+	DelegationReadOnly DelegationAccess = "READ_ONLY"
+	// DelegationPayments also lets the delegate pay from the accounts, up to
+	// the delegation's payment limit per payment
+	DelegationPayments DelegationAccess = "PAYMENTS"
+)
+
+type DelegationStatus string
+
+const (
+	DelegationActive  DelegationStatus = "ACTIVE"
+	DelegationRevoked DelegationStatus = "REVOKED"
+)
+
+// Delegation lets one user act on some of another user's accounts, e.g. under
+// a power of attorney, until it expires or either of them revokes it
+type Delegation struct {
+	ID           uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	GrantorID    uuid.UUID        `gorm:"type:uuid;not null;index" json:"grantor_id"`
+	GranteeID    uuid.UUID        `gorm:"type:uuid;not null;index" json:"grantee_id"`
+	GranteeEmail string           `gorm:"type:varchar(255);not null" json:"grantee_email"`
+	Access       DelegationAccess `gorm:"type:varchar(20);not null" json:"access"`
+	AccountIDs   []string         `gorm:"type:jsonb;serializer:json;not null" json:"account_ids"`
+	// PaymentLimit is the largest single payment the delegate can make; it
+	// is only set on PAYMENTS delegations
+	PaymentLimit *decimal.Decimal `gorm:"type:numeric(19,4)" json:"payment_limit,omitempty"`
+	Status       DelegationStatus `gorm:"type:varchar(20);not null" json:"status"`
+	ExpiresAt    time.Time        `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time       `json:"revoked_at,omitempty"`
+	RevokedBy    *uuid.UUID       `gorm:"type:uuid" json:"revoked_by,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// Active reports whether the delegation currently allows access
+func (d *Delegation) Active(now time.Time) bool {
+	return d.Status == DelegationActive && now.Before(d.ExpiresAt)
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LoginOutcome string
+
+const (
+	// LoginSucceeded is a login that needed no further checks
+	LoginSucceeded LoginOutcome = "SUCCESS"
+	// LoginStepUpRequired is a flagged login still waiting for its one-time code
+	LoginStepUpRequired LoginOutcome = "STEP_UP_REQUIRED"
+	// LoginStepUpPassed is a flagged login the user confirmed with the one-time code
+	LoginStepUpPassed LoginOutcome = "STEP_UP_PASSED"
+)
+
+// LoginEvent records where and from which device a user signed in. Successful
+// and confirmed logins form the history new logins are compared against.
+type LoginEvent struct {
+	ID               uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID           uuid.UUID    `gorm:"type:uuid;not null;index:idx_login_events_user_time,priority:1" json:"-"`
+	IP               string       `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent        string       `json:"user_agent"`
+	DeviceID         string       `gorm:"type:varchar(128);not null" json:"device_id"`
+	Country          string       `gorm:"type:varchar(2)" json:"country,omitempty"`
+	City             string       `gorm:"type:varchar(100)" json:"city,omitempty"`
+	Latitude         *float64     `gorm:"type:double precision" json:"latitude,omitempty"`
+	Longitude        *float64     `gorm:"type:double precision" json:"longitude,omitempty"`
+	NewDevice        bool         `gorm:"default:false" json:"new_device"`
+	ImpossibleTravel bool         `gorm:"default:false" json:"impossible_travel"`
+	Outcome          LoginOutcome `gorm:"type:varchar(20);not null" json:"outcome"`
+	CreatedAt        time.Time    `gorm:"index:idx_login_events_user_time,priority:2" json:"created_at"`
+}
+
+// Suspicious reports whether the login was flagged by anomaly detection
+func (e *LoginEvent) Suspicious() bool {
+	return e.NewDevice || e.ImpossibleTravel
+}
+
+// LoginChallenge is a pending step-up check for a flagged login. Only the
+// SHA-256 hash of the emailed code is stored.
+type LoginChallenge struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID       uuid.UUID `gorm:"type:uuid;index;not null"`
+	LoginEventID uuid.UUID `gorm:"type:uuid;not null"`
+	CodeHash     string    `gorm:"type:varchar(64);not null"`
+	Attempts     int       `gorm:"default:0"`
+	ExpiresAt    time.Time `gorm:"not null"`
+	UsedAt       *time.Time
+	CreatedAt    time.Time
+}
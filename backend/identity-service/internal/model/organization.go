@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization is a business whose members share access to its accounts
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name      string    `gorm:"type:varchar(200);not null" json:"name"`
+	CreatedBy uuid.UUID `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MembershipStatus string
+
+const (
+	// MembershipInvited is a pending invitation the user has not accepted yet
+	MembershipInvited MembershipStatus = "INVITED"
+	MembershipActive  MembershipStatus = "ACTIVE"
+)
+
+// OrganizationMember gives a user a role in an organization. Roles are the
+// tenant.Role* constants.
+type OrganizationMember struct {
+	ID        uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OrgID     uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_org_members_org_user" json:"org_id"`
+	UserID    uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_org_members_org_user;index" json:"user_id"`
+	Email     string           `gorm:"type:varchar(255);not null" json:"email"`
+	Role      string           `gorm:"type:varchar(20);not null" json:"role"`
+	Status    MembershipStatus `gorm:"type:varchar(20);not null" json:"status"`
+	InvitedBy *uuid.UUID       `gorm:"type:uuid" json:"invited_by,omitempty"`
+	JoinedAt  *time.Time       `json:"joined_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// Membership is an organization together with the user's membership in it
+type Membership struct {
+	Organization Organization     `json:"organization"`
+	Role         string           `json:"role"`
+	Status       MembershipStatus `json:"status"`
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PasskeyCredential is a WebAuthn credential a user registered as a passkey.
+// Only the public key is stored; the private key never leaves the authenticator.
+type PasskeyCredential struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;index;not null" json:"-"`
+	// CredentialID is the authenticator's credential ID, base64url encoded
+	CredentialID string `gorm:"type:varchar(1400);uniqueIndex;not null" json:"credential_id"`
+	// PublicKey is the COSE_Key from the attested credential data
+	PublicKey       []byte     `gorm:"type:bytea;not null" json:"-"`
+	Algorithm       int        `gorm:"not null" json:"algorithm"`
+	SignCount       int64      `gorm:"not null;default:0" json:"-"`
+	AAGUID          string     `gorm:"column:aaguid;type:varchar(36)" json:"aaguid"`
+	AttestationType string     `gorm:"type:varchar(20);not null" json:"attestation_type"`
+	Transports      []string   `gorm:"type:jsonb;serializer:json" json:"transports,omitempty"`
+	Name            string     `gorm:"type:varchar(100)" json:"name"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// WebAuthnCeremony is a pending passkey registration or login, keyed by the
+// challenge the authenticator signs. It is deleted when the ceremony finishes.
+type WebAuthnCeremony struct {
+	Challenge string `gorm:"type:varchar(64);primary_key"`
+	Purpose   string `gorm:"type:varchar(20);not null"`
+	// UserID is set for registrations and logins that name the user
+	UserID *uuid.UUID `gorm:"type:uuid"`
+	// LoginChallengeID is set when the passkey completes a flagged password login
+	LoginChallengeID *uuid.UUID `gorm:"type:uuid"`
+	ExpiresAt        time.Time  `gorm:"not null"`
+	CreatedAt        time.Time
+}
+
+func (WebAuthnCeremony) TableName() string {
+	return "webauthn_ceremonies"
+}
+
+const (
+	WebAuthnRegistration = "REGISTRATION"
+	WebAuthnLogin        = "LOGIN"
+)
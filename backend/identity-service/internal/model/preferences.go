@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserPreferences are how a user wants to see the app and be contacted.
+// Users who never changed them have no row and get the defaults.
+// MarketingOptIns and Notifications are keyed by channel: EMAIL, SMS or PUSH.
+type UserPreferences struct {
+	UserID          uuid.UUID       `gorm:"type:uuid;primary_key" json:"user_id"`
+	Locale          string          `gorm:"type:varchar(35);not null" json:"locale"`
+	DisplayCurrency string          `gorm:"type:varchar(3);not null" json:"display_currency"`
+	MarketingOptIns map[string]bool `gorm:"type:jsonb;serializer:json;not null" json:"marketing_opt_ins"`
+	Notifications   map[string]bool `gorm:"type:jsonb;serializer:json;not null" json:"notifications"`
+	UpdatedAt       *time.Time      `json:"updated_at,omitempty"`
+}
+
+func (UserPreferences) TableName() string {
+	return "user_preferences"
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProfileChange records one change to a user's profile. Address changes are
+// recorded per address field.
+type ProfileChange struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index:idx_profile_changes_user_time,priority:1" json:"-"`
+	Field     string    `gorm:"type:varchar(32);not null" json:"field"`
+	OldValue  string    `gorm:"type:varchar(100)" json:"old_value"`
+	NewValue  string    `gorm:"type:varchar(100)" json:"new_value"`
+	CreatedAt time.Time `gorm:"index:idx_profile_changes_user_time,priority:2" json:"created_at"`
+}
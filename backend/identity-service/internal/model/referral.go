@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReferralCode is the code a user shares so that people who sign up with it
+// are attributed to them. Each user has one, created when first asked for.
+type ReferralCode struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primary_key" json:"user_id"`
+	Code      string    `gorm:"type:varchar(16);uniqueIndex;not null" json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ReferralInviteStatus string
+
+const (
+	ReferralInviteSent     ReferralInviteStatus = "SENT"
+	ReferralInviteAccepted ReferralInviteStatus = "ACCEPTED"
+)
+
+// ReferralInvite is an emailed invitation to sign up. Only the SHA-256 hash of
+// the token in the invite link is stored.
+type ReferralInvite struct {
+	ID         uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	InviterID  uuid.UUID            `gorm:"type:uuid;not null;index" json:"inviter_id"`
+	Email      string               `gorm:"type:varchar(255);not null;index" json:"email"`
+	TokenHash  string               `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Status     ReferralInviteStatus `gorm:"type:varchar(20);not null" json:"status"`
+	SendCount  int                  `gorm:"not null;default:1" json:"send_count"`
+	ExpiresAt  time.Time            `gorm:"not null" json:"expires_at"`
+	AcceptedBy *uuid.UUID           `gorm:"type:uuid" json:"accepted_by,omitempty"`
+	AcceptedAt *time.Time           `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+type ReferralStatus string
+
+const (
+	// ReferralPending waits for the referee's first transfer
+	ReferralPending ReferralStatus = "PENDING"
+	// ReferralCompleted has earned its reward
+	ReferralCompleted ReferralStatus = "COMPLETED"
+	// ReferralRejected was flagged as abuse and earns no reward
+	ReferralRejected ReferralStatus = "REJECTED"
+)
+
+// Reasons a referral is rejected
+const (
+	ReferralRejectDuplicateDevice = "DUPLICATE_DEVICE"
+)
+
+// Referral attributes a new user (the referee) to the user who referred them,
+// through an invite or a referral code
+type Referral struct {
+	ID           uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ReferrerID   uuid.UUID      `gorm:"type:uuid;not null;index" json:"referrer_id"`
+	RefereeID    uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex" json:"referee_id"`
+	InviteID     *uuid.UUID     `gorm:"type:uuid" json:"invite_id,omitempty"`
+	Code         string         `gorm:"type:varchar(16)" json:"code,omitempty"`
+	DeviceID     string         `gorm:"type:varchar(128);index" json:"-"`
+	Status       ReferralStatus `gorm:"type:varchar(20);not null" json:"status"`
+	RejectReason string         `gorm:"type:varchar(50)" json:"reject_reason,omitempty"`
+	// PaymentID is the referee's first transfer, which completed the referral
+	PaymentID   string     `gorm:"type:varchar(64)" json:"payment_id,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,11 @@
+package model
+
+import "time"
+
+// SecuritySignalCount is how often a security signal, such as a failed login
+// or a lockout, was seen in one minute across every replica of the service
+type SecuritySignalCount struct {
+	Signal      string    `gorm:"type:varchar(32);primary_key"`
+	BucketStart time.Time `gorm:"primary_key"`
+	Count       int64     `gorm:"not null;default:0"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ServiceAccount is a machine client that obtains tokens with the client
+// credentials grant. Only the SHA-256 hash of its secret is stored.
+type ServiceAccount struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	ClientID   string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"client_id"`
+	SecretHash string     `gorm:"type:varchar(64);not null" json:"-"`
+	Scopes     []string   `gorm:"type:jsonb;serializer:json;not null" json:"scopes"`
+	CreatedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
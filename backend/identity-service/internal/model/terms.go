@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type TermsKind string
+
+const (
+	TermsOfService TermsKind = "TERMS_OF_SERVICE"
+	PrivacyPolicy  TermsKind = "PRIVACY_POLICY"
+)
+
+// TermsDocument is a published version of the terms of service or privacy
+// policy. Versions of a kind supersede each other in the order they were
+// published; a mandatory version must be accepted before users can keep
+// using the API.
+type TermsDocument struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Kind        TermsKind `gorm:"type:varchar(30);not null;uniqueIndex:idx_terms_documents_kind_version" json:"kind"`
+	Version     string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_terms_documents_kind_version" json:"version"`
+	Title       string    `gorm:"type:varchar(200);not null" json:"title"`
+	URL         string    `gorm:"type:varchar(500);not null" json:"url"`
+	Summary     string    `gorm:"type:text" json:"summary,omitempty"`
+	Mandatory   bool      `gorm:"not null" json:"mandatory"`
+	PublishedBy uuid.UUID `gorm:"type:uuid;not null" json:"published_by"`
+	PublishedAt time.Time `gorm:"not null" json:"published_at"`
+}
+
+// TermsAcceptance records that a user accepted a version of the terms
+type TermsAcceptance struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_terms_acceptances_user_document" json:"user_id"`
+	DocumentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_terms_acceptances_user_document;index" json:"document_id"`
+	Kind       TermsKind `gorm:"type:varchar(30);not null" json:"kind"`
+	Version    string    `gorm:"type:varchar(50);not null" json:"version"`
+	IP         string    `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	UserAgent  string    `gorm:"type:varchar(500)" json:"user_agent,omitempty"`
+	AcceptedAt time.Time `gorm:"not null" json:"accepted_at"`
+}
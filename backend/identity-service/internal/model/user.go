@@ -8,14 +8,35 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	Email        string         `gorm:"uniqueIndex;not null"`
-	PasswordHash string         `gorm:"not null"`
-	FirstName    string         `gorm:"not null"`
-	LastName     string         `gorm:"not null"`
-	Role         string         `gorm:"default:'customer'"`
-	KYCStatus    string         `gorm:"default:'UNVERIFIED'"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	ID           uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Email        string    `gorm:"uniqueIndex;not null"`
+	PasswordHash string    `gorm:"not null"`
+	FirstName    string    `gorm:"not null"`
+	LastName     string    `gorm:"not null"`
+	Role         string    `gorm:"default:'customer'"`
+	KYCStatus    string    `gorm:"default:'UNVERIFIED'"`
+	// ReferredBy is the user who invited or referred this user, if any
+	ReferredBy *uuid.UUID `gorm:"type:uuid;index"`
+	// Profile details are added after sign-up. Phone only changes once the
+	// new number is confirmed with a texted code, see service.PhoneCode.
+	Phone           string `gorm:"type:varchar(16)"`
+	PhoneVerifiedAt *time.Time
+	DateOfBirth     *time.Time `gorm:"type:date"`
+	AddressLine1    string     `gorm:"type:varchar(100)"`
+	AddressLine2    string     `gorm:"type:varchar(100)"`
+	City            string     `gorm:"type:varchar(100)"`
+	PostalCode      string     `gorm:"type:varchar(16)"`
+	Country         string     `gorm:"type:varchar(2)"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+}
+
+// UserFilter narrows an admin user search. Empty fields are ignored.
+type UserFilter struct {
+	Email         string
+	Name          string
+	KYCStatus     string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
 }
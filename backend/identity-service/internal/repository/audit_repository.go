@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"gorm.io/gorm"
+)
+
+// AuditRepository stores audit events in the audit_events table. It is the
+// audit sink for the service's AuditLogger.
+type AuditRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{DB: db}
+}
+
+// Write implements middleware.AuditSink
+func (r *AuditRepository) Write(event *middleware.AuditEvent) error {
+	return r.DB.Create(toAuditRecord(event)).Error
+}
+
+// ListByUser returns the newest events about or performed by the user, along with the total count
+func (r *AuditRepository) ListByUser(userID string, offset, limit int) ([]model.AuditEvent, int64, error) {
+	query := r.DB.Model(&model.AuditEvent{}).Where("subject_user_id = ? OR actor_user_id = ?", userID, userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []model.AuditEvent
+	if err := query.Order("occurred_at DESC").Offset(offset).Limit(limit).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// toAuditRecord maps an audit event to its stored form. Handlers put the
+// affected user in metadata["user_id"] when it is not the caller.
+func toAuditRecord(event *middleware.AuditEvent) *model.AuditEvent {
+	occurredAt := event.Timestamp
+	if occurredAt.IsZero() {
+		occurredAt = time.Now()
+	}
+	subject := event.UserID
+	if id, ok := event.Metadata["user_id"]; ok {
+		subject = fmt.Sprint(id)
+	}
+	return &model.AuditEvent{
+		EventID:       event.EventID,
+		EventType:     string(event.EventType),
+		Severity:      string(event.Severity),
+		SubjectUserID: subject,
+		ActorUserID:   event.UserID,
+		Email:         event.Email,
+		Action:        event.Action,
+		Resource:      event.Resource,
+		Method:        event.Method,
+		Path:          event.Path,
+		IP:            event.IP,
+		UserAgent:     event.UserAgent,
+		StatusCode:    event.StatusCode,
+		Success:       event.Success,
+		RequestID:     event.RequestID,
+		ErrorMsg:      event.ErrorMsg,
+		Metadata:      event.Metadata,
+		ServiceName:   event.ServiceName,
+		OccurredAt:    occurredAt,
+	}
+}
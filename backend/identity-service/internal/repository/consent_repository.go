@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ConsentRepository struct {
+	DB *gorm.DB
+}
+
+func NewConsentRepository(db *gorm.DB) *ConsentRepository {
+	return &ConsentRepository{DB: db}
+}
+
+func (r *ConsentRepository) CreateClient(client *model.ThirdPartyClient) error {
+	return r.DB.Create(client).Error
+}
+
+func (r *ConsentRepository) FindClientByAPIKeyHash(hash string) (*model.ThirdPartyClient, error) {
+	var client model.ThirdPartyClient
+	if err := r.DB.Where("api_key_hash = ?", hash).First(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+func (r *ConsentRepository) ListClients() ([]model.ThirdPartyClient, error) {
+	var clients []model.ThirdPartyClient
+	if err := r.DB.Order("created_at").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// RevokeClient disables an active client. It reports false if the client
+// does not exist or was already revoked.
+func (r *ConsentRepository) RevokeClient(id string, revokedAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.ThirdPartyClient{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *ConsentRepository) CreateConsent(consent *model.Consent) error {
+	return r.DB.Create(consent).Error
+}
+
+func (r *ConsentRepository) GetConsent(id uuid.UUID) (*model.Consent, error) {
+	var consent model.Consent
+	if err := r.DB.First(&consent, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+func (r *ConsentRepository) ListConsentsByUser(userID uuid.UUID) ([]model.Consent, error) {
+	var consents []model.Consent
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&consents).Error; err != nil {
+		return nil, err
+	}
+	return consents, nil
+}
+
+// UpdateConsentFrom saves the consent only if its stored status is still from,
+// so two concurrent decisions on one consent cannot both succeed
+func (r *ConsentRepository) UpdateConsentFrom(consent *model.Consent, from model.ConsentStatus) (bool, error) {
+	result := r.DB.Model(consent).Where("status = ?", from).
+		Select("user_id", "account_ids", "status", "expires_at", "authorised_at", "revoked_at", "updated_at").
+		Updates(consent)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
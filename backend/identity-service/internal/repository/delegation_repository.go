@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DelegationRepository struct {
+	DB *gorm.DB
+}
+
+func NewDelegationRepository(db *gorm.DB) *DelegationRepository {
+	return &DelegationRepository{DB: db}
+}
+
+func (r *DelegationRepository) CreateDelegation(delegation *model.Delegation) error {
+	return r.DB.Create(delegation).Error
+}
+
+func (r *DelegationRepository) GetDelegation(id uuid.UUID) (*model.Delegation, error) {
+	var delegation model.Delegation
+	if err := r.DB.First(&delegation, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &delegation, nil
+}
+
+// ListDelegationsByUser returns the delegations the user granted or was
+// granted, newest first
+func (r *DelegationRepository) ListDelegationsByUser(userID uuid.UUID) ([]model.Delegation, error) {
+	var delegations []model.Delegation
+	if err := r.DB.Where("grantor_id = ? OR grantee_id = ?", userID, userID).
+		Order("created_at DESC").Find(&delegations).Error; err != nil {
+		return nil, err
+	}
+	return delegations, nil
+}
+
+// UpdateDelegationFrom saves the delegation only if its stored status is still
+// from, so a delegation is revoked once
+func (r *DelegationRepository) UpdateDelegationFrom(delegation *model.Delegation, from model.DelegationStatus) (bool, error) {
+	result := r.DB.Model(delegation).Where("status = ?", from).
+		Select("status", "revoked_at", "revoked_by", "updated_at").
+		Updates(delegation)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type LoginActivityRepository struct {
+	DB *gorm.DB
+}
+
+func NewLoginActivityRepository(db *gorm.DB) *LoginActivityRepository {
+	return &LoginActivityRepository{DB: db}
+}
+
+func (r *LoginActivityRepository) CreateEvent(event *model.LoginEvent) error {
+	return r.DB.Create(event).Error
+}
+
+// ListRecentEvents returns the user's newest logins first
+func (r *LoginActivityRepository) ListRecentEvents(userID string, limit int) ([]model.LoginEvent, error) {
+	var events []model.LoginEvent
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// UpdateEventOutcome records how a flagged login was resolved
+func (r *LoginActivityRepository) UpdateEventOutcome(id string, outcome model.LoginOutcome) error {
+	return r.DB.Model(&model.LoginEvent{}).Where("id = ?", id).Update("outcome", outcome).Error
+}
+
+func (r *LoginActivityRepository) CreateChallenge(challenge *model.LoginChallenge) error {
+	return r.DB.Create(challenge).Error
+}
+
+func (r *LoginActivityRepository) FindChallenge(id string) (*model.LoginChallenge, error) {
+	var challenge model.LoginChallenge
+	if err := r.DB.Where("id = ?", id).First(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// RecordChallengeAttempt counts a wrong code against an unused challenge
+func (r *LoginActivityRepository) RecordChallengeAttempt(id string) error {
+	return r.DB.Model(&model.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// MarkChallengeUsed consumes an unused challenge. It reports false if the
+// challenge was already used, so a code cannot complete two logins.
+func (r *LoginActivityRepository) MarkChallengeUsed(id string, usedAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type MagicLinkRepository struct {
+	DB *gorm.DB
+}
+
+func NewMagicLinkRepository(db *gorm.DB) *MagicLinkRepository {
+	return &MagicLinkRepository{DB: db}
+}
+
+func (r *MagicLinkRepository) Create(token *model.MagicLinkToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindByTokenHash finds a magic link by the hash of its token
+func (r *MagicLinkRepository) FindByTokenHash(tokenHash string) (*model.MagicLinkToken, error) {
+	var token model.MagicLinkToken
+	if err := r.DB.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes an unused magic link. It reports false if the link
+// was already used, so concurrent verifications cannot both succeed.
+func (r *MagicLinkRepository) MarkUsed(id string, usedAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.MagicLinkToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OrganizationRepository struct {
+	DB *gorm.DB
+}
+
+func NewOrganizationRepository(db *gorm.DB) *OrganizationRepository {
+	return &OrganizationRepository{DB: db}
+}
+
+// CreateOrganization inserts an organization and its first owner together
+func (r *OrganizationRepository) CreateOrganization(org *model.Organization, owner *model.OrganizationMember) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		owner.OrgID = org.ID
+		return tx.Create(owner).Error
+	})
+}
+
+func (r *OrganizationRepository) GetOrganization(id uuid.UUID) (*model.Organization, error) {
+	var org model.Organization
+	if err := r.DB.Where("id = ?", id).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+func (r *OrganizationRepository) GetMember(orgID, userID uuid.UUID) (*model.OrganizationMember, error) {
+	var member model.OrganizationMember
+	if err := r.DB.Where("org_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// CreateMember inserts a membership. It returns gorm.ErrDuplicatedKey if the
+// user is already a member or has a pending invitation.
+func (r *OrganizationRepository) CreateMember(member *model.OrganizationMember) error {
+	err := r.DB.Create(member).Error
+	if err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")) {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
+}
+
+func (r *OrganizationRepository) UpdateMember(member *model.OrganizationMember) error {
+	return r.DB.Save(member).Error
+}
+
+func (r *OrganizationRepository) DeleteMember(id uuid.UUID) error {
+	return r.DB.Delete(&model.OrganizationMember{}, "id = ?", id).Error
+}
+
+// ListMembers returns an organization's members and invitations, oldest first
+func (r *OrganizationRepository) ListMembers(orgID uuid.UUID) ([]model.OrganizationMember, error) {
+	var members []model.OrganizationMember
+	if err := r.DB.Where("org_id = ?", orgID).Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ListMemberships returns the organizations a user belongs to or is invited to
+func (r *OrganizationRepository) ListMemberships(userID uuid.UUID) ([]model.Membership, error) {
+	var members []model.OrganizationMember
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return []model.Membership{}, nil
+	}
+
+	ids := make([]uuid.UUID, len(members))
+	for i, m := range members {
+		ids[i] = m.OrgID
+	}
+	var orgs []model.Organization
+	if err := r.DB.Where("id IN ?", ids).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]model.Organization, len(orgs))
+	for _, o := range orgs {
+		byID[o.ID] = o
+	}
+
+	memberships := make([]model.Membership, 0, len(members))
+	for _, m := range members {
+		memberships = append(memberships, model.Membership{Organization: byID[m.OrgID], Role: m.Role, Status: m.Status})
+	}
+	return memberships, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PasskeyRepository struct {
+	DB *gorm.DB
+}
+
+func NewPasskeyRepository(db *gorm.DB) *PasskeyRepository {
+	return &PasskeyRepository{DB: db}
+}
+
+// CreateCredential stores a passkey; an already registered credential ID
+// returns gorm.ErrDuplicatedKey
+func (r *PasskeyRepository) CreateCredential(credential *model.PasskeyCredential) error {
+	err := r.DB.Create(credential).Error
+	if err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")) {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
+}
+
+// FindCredential finds a passkey by its base64url credential ID
+func (r *PasskeyRepository) FindCredential(credentialID string) (*model.PasskeyCredential, error) {
+	var credential model.PasskeyCredential
+	if err := r.DB.Where("credential_id = ?", credentialID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// ListCredentials returns the user's passkeys, oldest first
+func (r *PasskeyRepository) ListCredentials(userID string) ([]model.PasskeyCredential, error) {
+	var credentials []model.PasskeyCredential
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// UpdateSignCount records a successful login. It reports false if another
+// login raised the counter first, so a replayed or cloned signature loses.
+func (r *PasskeyRepository) UpdateSignCount(id string, previous, signCount int64, usedAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.PasskeyCredential{}).
+		Where("id = ? AND sign_count = ?", id, previous).
+		Updates(map[string]interface{}{"sign_count": signCount, "last_used_at": usedAt})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *PasskeyRepository) CreateCeremony(ceremony *model.WebAuthnCeremony) error {
+	return r.DB.Create(ceremony).Error
+}
+
+// TakeCeremony deletes and returns the ceremony for a challenge, so each
+// challenge can be answered only once
+func (r *PasskeyRepository) TakeCeremony(challenge string) (*model.WebAuthnCeremony, error) {
+	var ceremonies []model.WebAuthnCeremony
+	result := r.DB.Clauses(clause.Returning{}).Where("challenge = ?", challenge).Delete(&ceremonies)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(ceremonies) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &ceremonies[0], nil
+}
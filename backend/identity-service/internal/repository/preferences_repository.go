@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PreferencesRepository struct {
+	DB *gorm.DB
+}
+
+func NewPreferencesRepository(db *gorm.DB) *PreferencesRepository {
+	return &PreferencesRepository{DB: db}
+}
+
+// GetPreferences returns the user's saved preferences, or nil if they never
+// changed them
+func (r *PreferencesRepository) GetPreferences(userID uuid.UUID) (*model.UserPreferences, error) {
+	var prefs model.UserPreferences
+	err := r.DB.Where("user_id = ?", userID).First(&prefs).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// SavePreferences inserts or replaces the user's preferences
+func (r *PreferencesRepository) SavePreferences(prefs *model.UserPreferences) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		UpdateAll: true,
+	}).Create(prefs).Error
+}
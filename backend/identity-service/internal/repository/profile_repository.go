@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ProfileRepository struct {
+	DB *gorm.DB
+}
+
+func NewProfileRepository(db *gorm.DB) *ProfileRepository {
+	return &ProfileRepository{DB: db}
+}
+
+// UpdateProfile saves the user's profile details and records the changes in
+// the same transaction
+func (r *ProfileRepository) UpdateProfile(user *model.User, changes []model.ProfileChange) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"phone":             user.Phone,
+			"phone_verified_at": user.PhoneVerifiedAt,
+			"date_of_birth":     user.DateOfBirth,
+			"address_line1":     user.AddressLine1,
+			"address_line2":     user.AddressLine2,
+			"city":              user.City,
+			"postal_code":       user.PostalCode,
+			"country":           user.Country,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+		return tx.Create(&changes).Error
+	})
+}
+
+// ListProfileChanges returns the user's newest profile changes first
+func (r *ProfileRepository) ListProfileChanges(userID uuid.UUID, limit int) ([]model.ProfileChange, error) {
+	var changes []model.ProfileChange
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReferralRepository struct {
+	DB *gorm.DB
+}
+
+func NewReferralRepository(db *gorm.DB) *ReferralRepository {
+	return &ReferralRepository{DB: db}
+}
+
+func (r *ReferralRepository) GetReferralCode(userID uuid.UUID) (*model.ReferralCode, error) {
+	var code model.ReferralCode
+	if err := r.DB.Where("user_id = ?", userID).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *ReferralRepository) FindReferralCode(code string) (*model.ReferralCode, error) {
+	var rc model.ReferralCode
+	if err := r.DB.Where("code = ?", code).First(&rc).Error; err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
+
+// CreateReferralCode inserts a code. It returns gorm.ErrDuplicatedKey if the
+// code is taken or the user already has one.
+func (r *ReferralRepository) CreateReferralCode(code *model.ReferralCode) error {
+	err := r.DB.Create(code).Error
+	if err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")) {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
+}
+
+func (r *ReferralRepository) CreateInvite(invite *model.ReferralInvite) error {
+	return r.DB.Create(invite).Error
+}
+
+func (r *ReferralRepository) GetInvite(id uuid.UUID) (*model.ReferralInvite, error) {
+	var invite model.ReferralInvite
+	if err := r.DB.Where("id = ?", id).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *ReferralRepository) FindInviteByTokenHash(hash string) (*model.ReferralInvite, error) {
+	var invite model.ReferralInvite
+	if err := r.DB.Where("token_hash = ?", hash).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// FindOpenInvite returns the inviter's unaccepted invite to an email, if any
+func (r *ReferralRepository) FindOpenInvite(inviterID uuid.UUID, email string) (*model.ReferralInvite, error) {
+	var invite model.ReferralInvite
+	err := r.DB.Where("inviter_id = ? AND email = ? AND status = ?", inviterID, email, model.ReferralInviteSent).
+		Order("created_at DESC").First(&invite).Error
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *ReferralRepository) ListInvites(inviterID uuid.UUID) ([]model.ReferralInvite, error) {
+	var invites []model.ReferralInvite
+	if err := r.DB.Where("inviter_id = ?", inviterID).Order("created_at DESC").Find(&invites).Error; err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (r *ReferralRepository) CountInvitesSince(inviterID uuid.UUID, since time.Time) (int64, error) {
+	var n int64
+	err := r.DB.Model(&model.ReferralInvite{}).Where("inviter_id = ? AND created_at >= ?", inviterID, since).Count(&n).Error
+	return n, err
+}
+
+// ResendInvite saves an invite's new token and expiry if it is still unaccepted
+// and has not been resent since it was loaded, so concurrent resends cannot
+// both succeed
+func (r *ReferralRepository) ResendInvite(invite *model.ReferralInvite) (bool, error) {
+	result := r.DB.Model(invite).
+		Where("status = ? AND send_count = ?", model.ReferralInviteSent, invite.SendCount-1).
+		Select("token_hash", "send_count", "expires_at", "updated_at").
+		Updates(invite)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CreateReferral records a referral and, for one made through an invite,
+// marks the invite accepted. It returns gorm.ErrRecordNotFound if the invite
+// was accepted in the meantime.
+func (r *ReferralRepository) CreateReferral(referral *model.Referral) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(referral).Error; err != nil {
+			return err
+		}
+		if referral.InviteID == nil {
+			return nil
+		}
+		result := tx.Model(&model.ReferralInvite{}).
+			Where("id = ? AND status = ?", *referral.InviteID, model.ReferralInviteSent).
+			Updates(map[string]interface{}{
+				"status":      model.ReferralInviteAccepted,
+				"accepted_by": referral.RefereeID,
+				"accepted_at": referral.CreatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// DeviceSeen reports whether the referrer has signed in from the device, or
+// the device has already been used to sign up through a referral
+func (r *ReferralRepository) DeviceSeen(referrerID uuid.UUID, deviceID string) (bool, error) {
+	var n int64
+	if err := r.DB.Model(&model.LoginEvent{}).
+		Where("user_id = ? AND device_id = ?", referrerID, deviceID).
+		Limit(1).Count(&n).Error; err != nil || n > 0 {
+		return n > 0, err
+	}
+	err := r.DB.Model(&model.Referral{}).Where("device_id = ?", deviceID).Limit(1).Count(&n).Error
+	return n > 0, err
+}
+
+func (r *ReferralRepository) ListReferrals(referrerID uuid.UUID) ([]model.Referral, error) {
+	var referrals []model.Referral
+	if err := r.DB.Where("referrer_id = ?", referrerID).Order("created_at DESC").Find(&referrals).Error; err != nil {
+		return nil, err
+	}
+	return referrals, nil
+}
+
+func (r *ReferralRepository) FindReferralByReferee(refereeID uuid.UUID) (*model.Referral, error) {
+	var referral model.Referral
+	if err := r.DB.Where("referee_id = ?", refereeID).First(&referral).Error; err != nil {
+		return nil, err
+	}
+	return &referral, nil
+}
+
+// CompleteReferral marks a pending referral completed by a payment. It
+// reports false if the referral was not pending.
+func (r *ReferralRepository) CompleteReferral(id uuid.UUID, paymentID string, completedAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.Referral{}).
+		Where("id = ? AND status = ?", id, model.ReferralPending).
+		Updates(map[string]interface{}{
+			"status":       model.ReferralCompleted,
+			"payment_id":   paymentID,
+			"completed_at": completedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
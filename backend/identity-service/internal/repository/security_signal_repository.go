@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SecuritySignalRepository struct {
+	DB *gorm.DB
+}
+
+func NewSecuritySignalRepository(db *gorm.DB) *SecuritySignalRepository {
+	return &SecuritySignalRepository{DB: db}
+}
+
+// Increment adds one to the signal's count for the minute starting at bucketStart
+func (r *SecuritySignalRepository) Increment(signal string, bucketStart time.Time) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "signal"}, {Name: "bucket_start"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count": gorm.Expr("security_signal_counts.count + excluded.count"),
+		}),
+	}).Create(&model.SecuritySignalCount{Signal: signal, BucketStart: bucketStart, Count: 1}).Error
+}
+
+// ListCounts returns the per-minute counts in [from, to), oldest first
+func (r *SecuritySignalRepository) ListCounts(from, to time.Time) ([]model.SecuritySignalCount, error) {
+	var counts []model.SecuritySignalCount
+	err := r.DB.Where("bucket_start >= ? AND bucket_start < ?", from, to).
+		Order("bucket_start, signal").Find(&counts).Error
+	return counts, err
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type ServiceAccountRepository struct {
+	DB *gorm.DB
+}
+
+func NewServiceAccountRepository(db *gorm.DB) *ServiceAccountRepository {
+	return &ServiceAccountRepository{DB: db}
+}
+
+func (r *ServiceAccountRepository) Create(account *model.ServiceAccount) error {
+	return r.DB.Create(account).Error
+}
+
+func (r *ServiceAccountRepository) FindByClientID(clientID string) (*model.ServiceAccount, error) {
+	var account model.ServiceAccount
+	if err := r.DB.Where("client_id = ?", clientID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ServiceAccountRepository) List() ([]model.ServiceAccount, error) {
+	var accounts []model.ServiceAccount
+	if err := r.DB.Order("created_at").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// Revoke disables an active service account. It reports false if the account
+// does not exist or was already revoked.
+func (r *ServiceAccountRepository) Revoke(id string, revokedAt time.Time) (bool, error) {
+	result := r.DB.Model(&model.ServiceAccount{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *ServiceAccountRepository) TouchLastUsed(id string, usedAt time.Time) error {
+	return r.DB.Model(&model.ServiceAccount{}).Where("id = ?", id).Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TermsRepository struct {
+	DB *gorm.DB
+}
+
+func NewTermsRepository(db *gorm.DB) *TermsRepository {
+	return &TermsRepository{DB: db}
+}
+
+// CreateDocument inserts a terms version. It returns gorm.ErrDuplicatedKey if
+// the kind already has a version with that name.
+func (r *TermsRepository) CreateDocument(doc *model.TermsDocument) error {
+	err := r.DB.Create(doc).Error
+	if err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")) {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
+}
+
+// ListDocuments returns every published version, oldest first
+func (r *TermsRepository) ListDocuments() ([]model.TermsDocument, error) {
+	var docs []model.TermsDocument
+	if err := r.DB.Order("published_at, kind").Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (r *TermsRepository) ListAcceptancesByUser(userID uuid.UUID) ([]model.TermsAcceptance, error) {
+	var acceptances []model.TermsAcceptance
+	if err := r.DB.Where("user_id = ?", userID).Order("accepted_at").Find(&acceptances).Error; err != nil {
+		return nil, err
+	}
+	return acceptances, nil
+}
+
+// CreateAcceptances records acceptances, skipping versions the user already
+// accepted so a repeated request keeps the original time
+func (r *TermsRepository) CreateAcceptances(acceptances []model.TermsAcceptance) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "document_id"}},
+		DoNothing: true,
+	}).Create(&acceptances).Error
+}
+
+// CountAcceptances returns the number of users who accepted each version,
+// keyed by document ID
+func (r *TermsRepository) CountAcceptances() (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		DocumentID uuid.UUID
+		Count      int64
+	}
+	if err := r.DB.Model(&model.TermsAcceptance{}).
+		Select("document_id, COUNT(*) AS count").Group("document_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.DocumentID] = row.Count
+	}
+	return counts, nil
+}
+
+// CountUsers returns the number of registered users, the base of the
+// acceptance rates
+func (r *TermsRepository) CountUsers() (int64, error) {
+	var count int64
+	err := r.DB.Model(&model.User{}).Count(&count).Error
+	return count, err
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"strings"
+
 	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
 	"gorm.io/gorm"
 )
@@ -38,3 +40,41 @@ func (r *UserRepository) FindByID(id string) (*model.User, error) {
 func (r *UserRepository) UpdatePassword(userID string, hashedPassword string) error {
 	return r.DB.Model(&model.User{}).Where("id = ?", userID).Update("password_hash", hashedPassword).Error
 }
+
+// Search returns a page of users matching the filter, newest first, along with the total count.
+// Email and name match case-insensitively on substrings.
+func (r *UserRepository) Search(filter model.UserFilter, offset, limit int) ([]model.User, int64, error) {
+	query := r.DB.Model(&model.User{})
+	if filter.Email != "" {
+		query = query.Where("email ILIKE ?", "%"+escapeLike(filter.Email)+"%")
+	}
+	if filter.Name != "" {
+		name := "%" + escapeLike(filter.Name) + "%"
+		query = query.Where("first_name ILIKE ? OR last_name ILIKE ? OR (first_name || ' ' || last_name) ILIKE ?", name, name, name)
+	}
+	if filter.KYCStatus != "" {
+		query = query.Where("kyc_status = ?", filter.KYCStatus)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []model.User
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
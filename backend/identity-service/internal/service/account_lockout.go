@@ -1,133 +1,112 @@
 package service
 
 import (
-	"sync"
+	"context"
+	"errors"
+	"log/slog"
 	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
 )
 
-// AccountLockout manages failed login attempts and account lockouts
+// AccountLockout manages failed login attempts and account lockouts. It is
+// a middleware.AttemptLimiter kept in memory unless it is given a shared
+// store; store errors are logged and do not lock anyone out.
 type AccountLockout struct {
-	attempts     map[string]*lockoutInfo
-	mu           sync.RWMutex
-	maxAttempts  int
-	lockDuration time.Duration
-	windowSize   time.Duration
+	name    string
+	limiter *middleware.AttemptLimiter
+	memory  *middleware.InMemoryAttemptStore
 }
 
-type lockoutInfo struct {
-	attempts     int
-	firstAttempt time.Time
-	lockedUntil  time.Time
+// NewAccountLockout creates a new account lockout manager whose lockouts
+// always last lockDuration
+func NewAccountLockout(maxAttempts int, lockDuration, windowSize time.Duration) *AccountLockout {
+	return NewAttemptLockout("lockout", nil, fixedLockoutPolicy(maxAttempts, lockDuration, windowSize))
 }
 
-// NewAccountLockout creates a new account lockout manager
-func NewAccountLockout(maxAttempts int, lockDuration, windowSize time.Duration) *AccountLockout {
-	return &AccountLockout{
-		attempts:     make(map[string]*lockoutInfo),
-		maxAttempts:  maxAttempts,
-		lockDuration: lockDuration,
-		windowSize:   windowSize,
+// fixedLockoutPolicy locks out for lockDuration after maxAttempts in windowSize
+func fixedLockoutPolicy(maxAttempts int, lockDuration, windowSize time.Duration) middleware.AttemptPolicy {
+	return middleware.AttemptPolicy{
+		MaxAttempts: maxAttempts,
+		Window:      windowSize,
+		Lockout:     lockDuration,
+		MaxLockout:  lockDuration,
 	}
 }
 
-// DefaultAccountLockout creates a lockout with secure defaults
+// NewAttemptLockout creates a lockout on a shared attempt limiter; a nil
+// store keeps attempts in memory
+func NewAttemptLockout(name string, store middleware.AttemptStore, policy middleware.AttemptPolicy) *AccountLockout {
+	al := &AccountLockout{name: name}
+	if store == nil {
+		al.memory = middleware.NewInMemoryAttemptStore()
+		store = al.memory
+	}
+	al.limiter = middleware.NewAttemptLimiter(name, store, policy)
+	return al
+}
+
+// UseAttemptStore moves the lockout to store, e.g. a Redis store shared by
+// the replicas, keeping its name and policy. Attempts already counted are
+// not moved; call it before serving.
+func (al *AccountLockout) UseAttemptStore(store middleware.AttemptStore) {
+	al.memory = nil
+	al.limiter = middleware.NewAttemptLimiter(al.name, store, al.limiter.Policy())
+}
+
+// DefaultAccountLockout creates a lockout with secure defaults: 5 failed
+// attempts in 10 minutes lock an account for 15 minutes, doubling with each
+// further lockout that day
 func DefaultAccountLockout() *AccountLockout {
-	return NewAccountLockout(
-		5,              // 5 failed attempts
-		15*time.Minute, // 15 minute lockout
-		10*time.Minute, // 10 minute window
-	)
+	return NewAttemptLockout("login", nil, middleware.LoginAttemptPolicy())
 }
 
 // IsLocked checks if an account is currently locked
 func (al *AccountLockout) IsLocked(identifier string) bool {
-	al.mu.RLock()
-	defer al.mu.RUnlock()
-
-	info, exists := al.attempts[identifier]
-	if !exists {
+	err := al.limiter.Check(context.Background(), identifier)
+	if err != nil && !errors.Is(err, middleware.ErrLockedOut) {
+		slog.Warn("Failed to check lockout", "error", err)
 		return false
 	}
-
-	return time.Now().Before(info.lockedUntil)
+	return err != nil
 }
 
 // RecordFailedAttempt records a failed login attempt
 func (al *AccountLockout) RecordFailedAttempt(identifier string) (locked bool, remainingAttempts int) {
-	al.mu.Lock()
-	defer al.mu.Unlock()
-
-	now := time.Now()
-	info, exists := al.attempts[identifier]
-
-	if !exists {
-		info = &lockoutInfo{
-			attempts:     1,
-			firstAttempt: now,
-		}
-		al.attempts[identifier] = info
-		return false, al.maxAttempts - 1
+	remaining, err := al.limiter.Fail(context.Background(), identifier)
+	if err != nil && !errors.Is(err, middleware.ErrLockedOut) {
+		slog.Warn("Failed to record failed attempt", "error", err)
+		return false, al.limiter.Policy().MaxAttempts
 	}
-
-	// Check if still locked
-	if now.Before(info.lockedUntil) {
-		return true, 0
-	}
-
-	// Reset if window expired
-	if now.Sub(info.firstAttempt) > al.windowSize {
-		info.attempts = 1
-		info.firstAttempt = now
-		info.lockedUntil = time.Time{}
-		return false, al.maxAttempts - 1
-	}
-
-	// Increment attempts
-	info.attempts++
-
-	// Check if should lock
-	if info.attempts >= al.maxAttempts {
-		info.lockedUntil = now.Add(al.lockDuration)
-		return true, 0
-	}
-
-	return false, al.maxAttempts - info.attempts
+	return err != nil, remaining
 }
 
 // RecordSuccessfulLogin clears failed attempts for an account
 func (al *AccountLockout) RecordSuccessfulLogin(identifier string) {
-	al.mu.Lock()
-	defer al.mu.Unlock()
-
-	delete(al.attempts, identifier)
+	if err := al.limiter.Succeed(context.Background(), identifier); err != nil {
+		slog.Warn("Failed to clear failed attempts", "error", err)
+	}
 }
 
 // GetLockoutInfo returns lockout information for an account
 func (al *AccountLockout) GetLockoutInfo(identifier string) (attempts int, lockedUntil time.Time, err error) {
-	al.mu.RLock()
-	defer al.mu.RUnlock()
-
-	info, exists := al.attempts[identifier]
-	if !exists {
-		return 0, time.Time{}, nil
+	state, err := al.limiter.State(context.Background(), identifier)
+	if err != nil {
+		return 0, time.Time{}, err
 	}
-
-	return info.attempts, info.lockedUntil, nil
+	if state.LockedFor > 0 {
+		lockedUntil = time.Now().Add(state.LockedFor)
+	}
+	return state.Failures, lockedUntil, nil
 }
 
 // ErrAccountLocked is defined in auth_service.go
 
-// Cleanup removes expired entries to prevent memory leaks
+// Cleanup removes expired entries of an in-memory lockout to prevent memory
+// leaks; shared stores expire their own
 func (al *AccountLockout) Cleanup() {
-	al.mu.Lock()
-	defer al.mu.Unlock()
-
-	now := time.Now()
-	for identifier, info := range al.attempts {
-		// Remove if lockout expired and window passed
-		if now.After(info.lockedUntil) && now.Sub(info.firstAttempt) > al.windowSize {
-			delete(al.attempts, identifier)
-		}
+	if al.memory != nil {
+		al.memory.Cleanup()
 	}
 }
 
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// Admin listings are paginated; page sizes above MaxPageSize are capped
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrInvalidDateRange = errors.New("created_from must be before created_to")
+)
+
+// AdminUserRepository is the user data access needed by support tooling
+type AdminUserRepository interface {
+	FindByID(id string) (*model.User, error)
+	Search(filter model.UserFilter, offset, limit int) ([]model.User, int64, error)
+}
+
+// AuditEventRepository reads audit events persisted by the audit sink
+type AuditEventRepository interface {
+	ListByUser(userID string, offset, limit int) ([]model.AuditEvent, int64, error)
+}
+
+// AdminService backs the admin user search and audit view
+type AdminService struct {
+	Users AdminUserRepository
+	Audit AuditEventRepository
+}
+
+func NewAdminService(users AdminUserRepository, audit AuditEventRepository) *AdminService {
+	return &AdminService{Users: users, Audit: audit}
+}
+
+// Page describes one page of a paginated listing
+type Page struct {
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+// UserSummary is the admin view of a user; it never includes credentials
+type UserSummary struct {
+	ID        uuid.UUID `json:"id"`
+	Email     string    `json:"email"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Role      string    `json:"role"`
+	KYCStatus string    `json:"kyc_status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newUserSummary(u *model.User) UserSummary {
+	return UserSummary{
+		ID:        u.ID,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Role:      u.Role,
+		KYCStatus: u.KYCStatus,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+}
+
+// SearchUsers returns a page of users matching the filter, newest first
+func (s *AdminService) SearchUsers(filter model.UserFilter, page, pageSize int) ([]UserSummary, Page, error) {
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		return nil, Page{}, ErrInvalidDateRange
+	}
+	filter.Email = strings.TrimSpace(filter.Email)
+	filter.Name = strings.TrimSpace(filter.Name)
+	filter.KYCStatus = strings.ToUpper(strings.TrimSpace(filter.KYCStatus))
+
+	p := normalizePage(page, pageSize)
+	users, total, err := s.Users.Search(filter, (p.Page-1)*p.PageSize, p.PageSize)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	p.Total = total
+
+	summaries := make([]UserSummary, 0, len(users))
+	for i := range users {
+		summaries = append(summaries, newUserSummary(&users[i]))
+	}
+	return summaries, p, nil
+}
+
+// GetUser returns a single user for support tooling
+func (s *AdminService) GetUser(userID string) (*UserSummary, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	summary := newUserSummary(user)
+	return &summary, nil
+}
+
+// GetUserAudit returns a page of audit events about or performed by the user, newest first
+func (s *AdminService) GetUserAudit(userID string, page, pageSize int) ([]model.AuditEvent, Page, error) {
+	if _, err := s.GetUser(userID); err != nil {
+		return nil, Page{}, err
+	}
+
+	p := normalizePage(page, pageSize)
+	events, total, err := s.Audit.ListByUser(userID, (p.Page-1)*p.PageSize, p.PageSize)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	p.Total = total
+	if events == nil {
+		events = []model.AuditEvent{}
+	}
+	return events, p, nil
+}
+
+func normalizePage(page, pageSize int) Page {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = DefaultPageSize
+	}
+	if pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+	return Page{Page: page, PageSize: pageSize}
+}
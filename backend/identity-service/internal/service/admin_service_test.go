@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAdminUserRepository is a mock implementation of AdminUserRepository
+type MockAdminUserRepository struct {
+	MockUserRepository
+}
+
+func (m *MockAdminUserRepository) Search(filter model.UserFilter, offset, limit int) ([]model.User, int64, error) {
+	args := m.Called(filter, offset, limit)
+	return args.Get(0).([]model.User), args.Get(1).(int64), args.Error(2)
+}
+
+// MockAuditEventRepository is a mock implementation of AuditEventRepository
+type MockAuditEventRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditEventRepository) ListByUser(userID string, offset, limit int) ([]model.AuditEvent, int64, error) {
+	args := m.Called(userID, offset, limit)
+	return args.Get(0).([]model.AuditEvent), args.Get(1).(int64), args.Error(2)
+}
+
+func TestSearchUsers_NormalizesFilterAndPagination(t *testing.T) {
+	users := new(MockAdminUserRepository)
+	svc := NewAdminService(users, new(MockAuditEventRepository))
+
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := model.UserFilter{Email: "jane@", KYCStatus: "VERIFIED", CreatedAfter: &from}
+	users.On("Search", expected, 200, 100).Return([]model.User{
+		{ID: uuid.New(), Email: "jane@example.com", PasswordHash: "secret", KYCStatus: "VERIFIED"},
+	}, int64(201), nil)
+
+	result, page, err := svc.SearchUsers(model.UserFilter{Email: " jane@ ", KYCStatus: "verified", CreatedAfter: &from}, 3, 500)
+
+	require.NoError(t, err)
+	assert.Equal(t, Page{Page: 3, PageSize: MaxPageSize, Total: 201}, page)
+	require.Len(t, result, 1)
+	assert.Equal(t, "jane@example.com", result[0].Email)
+	users.AssertExpectations(t)
+}
+
+func TestSearchUsers_DefaultsAndRejectsInvertedRange(t *testing.T) {
+	users := new(MockAdminUserRepository)
+	svc := NewAdminService(users, new(MockAuditEventRepository))
+
+	users.On("Search", model.UserFilter{}, 0, DefaultPageSize).Return([]model.User{}, int64(0), nil)
+	_, page, err := svc.SearchUsers(model.UserFilter{}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, Page{Page: 1, PageSize: DefaultPageSize}, page)
+
+	from := time.Now()
+	to := from.Add(-time.Hour)
+	_, _, err = svc.SearchUsers(model.UserFilter{CreatedAfter: &from, CreatedBefore: &to}, 1, 10)
+	assert.ErrorIs(t, err, ErrInvalidDateRange)
+}
+
+func TestGetUserAudit(t *testing.T) {
+	users := new(MockAdminUserRepository)
+	audit := new(MockAuditEventRepository)
+	svc := NewAdminService(users, audit)
+
+	userID := uuid.New().String()
+	users.On("FindByID", userID).Return(&model.User{ID: uuid.MustParse(userID)}, nil)
+	audit.On("ListByUser", userID, 10, 10).Return([]model.AuditEvent{
+		{EventType: "USER_LOGIN", SubjectUserID: userID},
+	}, int64(11), nil)
+
+	events, page, err := svc.GetUserAudit(userID, 2, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, Page{Page: 2, PageSize: 10, Total: 11}, page)
+
+	// Unknown and malformed users are not found
+	missing := uuid.New().String()
+	users.On("FindByID", missing).Return(nil, errors.New("record not found"))
+	_, _, err = svc.GetUserAudit(missing, 1, 10)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, _, err = svc.GetUserAudit("not-a-uuid", 1, 10)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	audit.AssertNumberOfCalls(t, "ListByUser", 1)
+}
@@ -1,11 +1,13 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -29,6 +31,8 @@ type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
 	Role   string `json:"role"`
+	// TermsPending is set while the user has not accepted the current mandatory terms
+	TermsPending bool `json:"terms_pending,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -43,8 +47,36 @@ type UserRepository interface {
 type AuthService struct {
 	Repo              UserRepository
 	JWTSecret         []byte
+	Audiences         TokenAudiences  // Issuer and audiences of the tokens issued
 	AccountLockout    *AccountLockout // SEC-011: Account lockout integration
 	accessTokenExpiry time.Duration   // Token expiry duration
+
+	// PasswordPolicy is applied to new passwords; nil accepts any password
+	PasswordPolicy *PasswordPolicy
+
+	// Passwordless login; disabled unless MagicLinks and Mailer are set
+	MagicLinks       MagicLinkRepository
+	Mailer           EmailSender
+	MagicLinkURL     string
+	MagicLinkLimiter *AccountLockout
+
+	// Login anomaly detection; disabled unless LoginActivity is set. Flagged
+	// logins need an emailed code, sent through Notifications.
+	LoginActivity LoginActivityRepository
+	Notifications EventPublisher
+
+	// Passkeys as a passwordless login or step-up factor; disabled unless
+	// Passkeys and WebAuthn are set
+	Passkeys PasskeyRepository
+	WebAuthn *WebAuthnConfig
+
+	// Signals counts failed logins, lockouts, MFA failures and new-device
+	// logins; they are only stored for the admin endpoint when it has a Repo
+	Signals *SecurityMetrics
+
+	// Terms flags the tokens of users who have not accepted the current
+	// mandatory terms; nil issues unflagged tokens
+	Terms *TermsService
 }
 
 func NewAuthService(repo UserRepository, secret string) *AuthService {
@@ -53,15 +85,28 @@ func NewAuthService(repo UserRepository, secret string) *AuthService {
 		JWTSecret:         []byte(secret),
 		AccountLockout:    DefaultAccountLockout(), // SEC-011: Initialize lockout
 		accessTokenExpiry: AccessTokenExpiry,
+		MagicLinkLimiter:  DefaultMagicLinkLimiter(),
+		PasswordPolicy:    DefaultPasswordPolicy(),
+		Signals:           NewSecurityMetrics(nil),
 	}
 }
 
 func (s *AuthService) Register(email, password, firstName, lastName string) (*model.User, error) {
+	return s.RegisterReferred(email, password, firstName, lastName, nil)
+}
+
+// RegisterReferred registers a user who was referred by referredBy; nil
+// registers a user without a referrer
+func (s *AuthService) RegisterReferred(email, password, firstName, lastName string, referredBy *uuid.UUID) (*model.User, error) {
 	// Check if user exists
 	if _, err := s.Repo.FindByEmail(email); err == nil {
 		return nil, ErrUserExists
 	}
 
+	if err := s.validatePassword(context.Background(), password); err != nil {
+		return nil, err
+	}
+
 	// SEC-009: Use explicit bcrypt cost of 12
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
 	if err != nil {
@@ -74,6 +119,7 @@ func (s *AuthService) Register(email, password, firstName, lastName string) (*mo
 		FirstName:    firstName,
 		LastName:     lastName,
 		Role:         "customer",
+		ReferredBy:   referredBy,
 	}
 
 	if err := s.Repo.Create(user); err != nil {
@@ -84,48 +130,70 @@ func (s *AuthService) Register(email, password, firstName, lastName string) (*mo
 }
 
 func (s *AuthService) Login(email, password string) (string, error) {
+	user, err := s.authenticate(email, password)
+	if err != nil {
+		return "", err
+	}
+	return s.issueLoginToken(user)
+}
+
+// authenticate checks an email and password, applying account lockout
+func (s *AuthService) authenticate(email, password string) (*model.User, error) {
 	// SEC-011: Check if account is locked
 	if s.AccountLockout != nil && s.AccountLockout.IsLocked(email) {
-		return "", ErrAccountLocked
+		return nil, ErrAccountLocked
 	}
 
 	user, err := s.Repo.FindByEmail(email)
 	if err != nil {
 		// SEC-011: Record failed attempt even for non-existent users (prevent enumeration)
-		if s.AccountLockout != nil {
-			s.AccountLockout.RecordFailedAttempt(email)
-		}
-		return "", ErrInvalidCredentials
+		s.recordFailedLogin(email)
+		return nil, ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
 		// SEC-011: Record failed attempt
-		if s.AccountLockout != nil {
-			s.AccountLockout.RecordFailedAttempt(email)
-		}
-		return "", ErrInvalidCredentials
+		s.recordFailedLogin(email)
+		return nil, ErrInvalidCredentials
 	}
 
 	// SEC-011: Clear failed attempts on successful login
 	if s.AccountLockout != nil {
 		s.AccountLockout.RecordSuccessfulLogin(email)
 	}
+	return user, nil
+}
+
+// recordFailedLogin counts a wrong password against the account's lockout
+// and in the security signals, including the lockout it may cause
+func (s *AuthService) recordFailedLogin(email string) {
+	s.recordSignal(SignalFailedLogin)
+	if s.AccountLockout == nil {
+		return
+	}
+	if locked, _ := s.AccountLockout.RecordFailedAttempt(email); locked {
+		s.recordSignal(SignalLockout)
+	}
+}
 
+// recordSignal counts a security signal, see SecurityMetrics
+func (s *AuthService) recordSignal(signal string) {
+	if s.Signals != nil {
+		s.Signals.Record(signal)
+	}
+}
+
+// issueLoginToken signs the access token returned by password logins
+func (s *AuthService) issueLoginToken(user *model.User) (string, error) {
 	// SEC-010: Generate JWT with 15-minute expiry (was 24h)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, s.Audiences.stamp(s.Terms.stamp(jwt.MapClaims{
 		"user_id": user.ID.String(),
 		"email":   user.Email,
 		"role":    user.Role,
 		"iat":     time.Now().Unix(),
 		"exp":     time.Now().Add(AccessTokenExpiry).Unix(),
-	})
-
-	tokenString, err := token.SignedString(s.JWTSecret)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	}, user.ID.String()), s.Audiences.User))
+	return token.SignedString(s.JWTSecret)
 }
 
 // hashPassword hashes a password using bcrypt
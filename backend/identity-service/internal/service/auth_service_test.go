@@ -48,7 +48,7 @@ func TestRegister(t *testing.T) {
 	mockRepo.On("FindByEmail", "new@example.com").Return(nil, errors.New("not found"))
 	mockRepo.On("Create", mock.AnythingOfType("*model.User")).Return(nil)
 
-	user, err := service.Register("new@example.com", "password", "John", "Doe")
+	user, err := service.Register("new@example.com", "Correct-Horse-9", "John", "Doe")
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, "new@example.com", user.Email)
@@ -58,7 +58,7 @@ func TestRegister(t *testing.T) {
 	existingUser := &model.User{Email: "exists@example.com"}
 	mockRepo.On("FindByEmail", "exists@example.com").Return(existingUser, nil)
 
-	_, err = service.Register("exists@example.com", "password", "Jane", "Doe")
+	_, err = service.Register("exists@example.com", "Correct-Horse-9", "Jane", "Doe")
 	assert.Error(t, err)
 	assert.Equal(t, "user already exists", err.Error())
 }
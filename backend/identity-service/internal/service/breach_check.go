@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RangeBreachClient checks passwords against a k-anonymity range API such as
+// Have I Been Pwned. Only the first five hex characters of the password's SHA-1
+// leave the service; the suffix is matched locally against the returned range.
+type RangeBreachClient struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (c *RangeBreachClient) IsBreached(ctx context.Context, password string) (bool, error) {
+	digest := passwordSHA1(password)
+	prefix, suffix := digest[:5], digest[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(c.BaseURL, "/")+"/range/"+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding makes every response a similar size, hiding the prefix from observers
+	req.Header.Set("Add-Padding", "true")
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("breach range API returned %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		hashSuffix, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok || !strings.EqualFold(hashSuffix, suffix) {
+			continue
+		}
+		// Padding entries have a count of zero
+		n, err := strconv.Atoi(count)
+		return err == nil && n > 0, nil
+	}
+	return false, scanner.Err()
+}
+
+// FallbackBreachChecker uses Fallback when Primary fails, e.g. the local bloom
+// filter when the range API cannot be reached
+type FallbackBreachChecker struct {
+	Primary  BreachChecker
+	Fallback BreachChecker
+}
+
+func (f *FallbackBreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	breached, err := f.Primary.IsBreached(ctx, password)
+	if err == nil {
+		return breached, nil
+	}
+	return f.Fallback.IsBreached(ctx, password)
+}
+
+// bloomFilterMagic starts a bloom filter file, followed by the number of hash
+// functions (uint32), the number of bits (uint64) and the bits as big-endian uint64 words
+const bloomFilterMagic = "NBBF"
+
+// BloomFilter is a set of breached password SHA-1 hashes for offline checks.
+// It can return false positives, at the rate it was sized for, but never false negatives.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []uint64
+}
+
+// NewBloomFilter sizes a filter for n hashes at the given false positive rate
+func NewBloomFilter(n int, falsePositiveRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Max(1, math.Round(float64(m)/float64(n)*math.Ln2)))
+	return &BloomFilter{k: k, m: m, bits: make([]uint64, (m+63)/64)}
+}
+
+// AddHash adds a hex-encoded SHA-1 password hash, as published in breach corpora
+func (f *BloomFilter) AddHash(hexSHA1 string) error {
+	digest, err := hex.DecodeString(hexSHA1)
+	if err != nil || len(digest) != sha1.Size {
+		return fmt.Errorf("invalid SHA-1 hash %q", hexSHA1)
+	}
+	for _, i := range f.indexes(digest) {
+		f.bits[i/64] |= 1 << (i % 64)
+	}
+	return nil
+}
+
+// ContainsHash reports whether a raw SHA-1 digest may be in the set
+func (f *BloomFilter) ContainsHash(digest []byte) bool {
+	for _, i := range f.indexes(digest) {
+		if f.bits[i/64]&(1<<(i%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *BloomFilter) IsBreached(_ context.Context, password string) (bool, error) {
+	digest := sha1.Sum([]byte(password))
+	return f.ContainsHash(digest[:]), nil
+}
+
+// indexes derives the k bit positions by double hashing; SHA-1 output is
+// already uniform, so its first two words serve as the two hashes
+func (f *BloomFilter) indexes(digest []byte) []uint64 {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	out := make([]uint64, f.k)
+	for i := range out {
+		out[i] = (h1 + uint64(i)*h2) % f.m
+	}
+	return out
+}
+
+// WriteTo writes the filter in the file format LoadBloomFilter reads
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	buf := make([]byte, 0, 16+8*len(f.bits))
+	buf = append(buf, bloomFilterMagic...)
+	buf = binary.BigEndian.AppendUint32(buf, f.k)
+	buf = binary.BigEndian.AppendUint64(buf, f.m)
+	for _, word := range f.bits {
+		buf = binary.BigEndian.AppendUint64(buf, word)
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadBloomFilter reads a filter written by WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("reading bloom filter header: %w", err)
+	}
+	if string(header[:4]) != bloomFilterMagic {
+		return nil, errors.New("not a bloom filter file")
+	}
+	f := &BloomFilter{k: binary.BigEndian.Uint32(header[4:8]), m: binary.BigEndian.Uint64(header[8:16])}
+	if f.k == 0 || f.m == 0 {
+		return nil, errors.New("bloom filter file has no hash functions or bits")
+	}
+	data := make([]byte, 8*((f.m+63)/64))
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("reading bloom filter bits: %w", err)
+	}
+	f.bits = make([]uint64, len(data)/8)
+	for i := range f.bits {
+		f.bits[i] = binary.BigEndian.Uint64(data[i*8:])
+	}
+	return f, nil
+}
+
+// LoadBloomFilter reads a filter file from disk
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBloomFilter(bufio.NewReader(file))
+}
+
+// BuildBloomFilter builds a filter from a breach corpus with one hex SHA-1 per
+// line, optionally followed by ":count" as in the Have I Been Pwned downloads
+func BuildBloomFilter(r io.Reader, expected int, falsePositiveRate float64) (*BloomFilter, error) {
+	f := NewBloomFilter(expected, falsePositiveRate)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		hash, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if hash == "" {
+			continue
+		}
+		if err := f.AddHash(hash); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return f, scanner.Err()
+}
+
+// passwordSHA1 is the upper-case hex SHA-1 used by breach corpora
+func passwordSHA1(password string) string {
+	digest := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(digest[:]))
+}
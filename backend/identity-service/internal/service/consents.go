@@ -0,0 +1,376 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// ConsentTokenExpiry bounds how long a revoked consent can still be used
+	ConsentTokenExpiry = 5 * time.Minute
+	// ConsentRequestTTL is how long a consent request waits for the user
+	ConsentRequestTTL = 24 * time.Hour
+	// MaxConsentDuration is the longest a user can grant access for before
+	// the client must ask again
+	MaxConsentDuration = 90 * 24 * time.Hour
+)
+
+// ConsentScopes are the scopes a third-party client can be granted
+var ConsentScopes = []string{"accounts:read", "balances:read", "transactions:read"}
+
+var (
+	ErrInvalidAPIKey           = errors.New("invalid API key")
+	ErrThirdPartyClientMissing = errors.New("third-party client not found")
+	ErrConsentNotFound         = errors.New("consent not found")
+	ErrConsentNotPending       = errors.New("consent is not awaiting authorisation")
+	ErrConsentNotActive        = errors.New("consent is not authorised or has expired")
+	ErrInvalidConsentAccounts  = errors.New("account_ids must list the IDs of one or more accounts")
+	ErrInvalidConsentExpiry    = errors.New("consent expiry must be in the future and at most 90 days away")
+)
+
+// ConsentRepository stores third-party clients and their consents
+type ConsentRepository interface {
+	CreateClient(client *model.ThirdPartyClient) error
+	FindClientByAPIKeyHash(hash string) (*model.ThirdPartyClient, error)
+	ListClients() ([]model.ThirdPartyClient, error)
+	RevokeClient(id string, revokedAt time.Time) (bool, error)
+	CreateConsent(consent *model.Consent) error
+	GetConsent(id uuid.UUID) (*model.Consent, error)
+	ListConsentsByUser(userID uuid.UUID) ([]model.Consent, error)
+	// UpdateConsentFrom saves the consent if its stored status is still from,
+	// and reports whether it did
+	UpdateConsentFrom(consent *model.Consent, from model.ConsentStatus) (bool, error)
+}
+
+// ConsentService registers third-party clients, records the consents users
+// give them, and issues the tokens clients read account data with
+type ConsentService struct {
+	Repo      ConsentRepository
+	JWTSecret []byte
+	// Audiences addresses consent tokens to the open banking APIs
+	Audiences TokenAudiences
+}
+
+func NewConsentService(repo ConsentRepository, secret string) *ConsentService {
+	return &ConsentService{Repo: repo, JWTSecret: []byte(secret)}
+}
+
+// ConsentToken is an access token scoped to one consent
+type ConsentToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+	ConsentID   string `json:"consent_id"`
+}
+
+// CreateClient registers a third-party client and returns it with its API
+// key. The key is only available here; afterwards only its hash is kept.
+func (s *ConsentService) CreateClient(name string, scopes []string, createdBy string) (*model.ThirdPartyClient, string, error) {
+	creator, err := uuid.Parse(createdBy)
+	if err != nil {
+		return nil, "", errors.New("invalid user id")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(ConsentScopes, scope) {
+			return nil, "", ErrUnknownScope
+		}
+	}
+
+	clientID, err := randomToken(12)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	apiKey := "tpk_" + secret
+
+	client := &model.ThirdPartyClient{
+		Name:       name,
+		ClientID:   "tpp_" + clientID,
+		APIKeyHash: hashClientSecret(apiKey),
+		Scopes:     scopes,
+		CreatedBy:  creator,
+	}
+	if err := s.Repo.CreateClient(client); err != nil {
+		return nil, "", err
+	}
+	return client, apiKey, nil
+}
+
+// ListClients returns all third-party clients, including revoked ones
+func (s *ConsentService) ListClients() ([]model.ThirdPartyClient, error) {
+	return s.Repo.ListClients()
+}
+
+// RevokeClient stops a client from using its API key. Its consents can no
+// longer be used, and tokens already issued expire within ConsentTokenExpiry.
+func (s *ConsentService) RevokeClient(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrThirdPartyClientMissing
+	}
+	revoked, err := s.Repo.RevokeClient(id, time.Now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrThirdPartyClientMissing
+	}
+	return nil
+}
+
+// Authenticate returns the active client an API key belongs to
+func (s *ConsentService) Authenticate(apiKey string) (*model.ThirdPartyClient, error) {
+	if apiKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	client, err := s.Repo.FindClientByAPIKeyHash(hashClientSecret(apiKey))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if client.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return client, nil
+}
+
+// RequestConsent records a client's request for access. The user it is for
+// approves or rejects it; until then it belongs to no one.
+func (s *ConsentService) RequestConsent(client *model.ThirdPartyClient, scopes []string) (*model.Consent, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	consent := &model.Consent{
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     slices.Compact(slices.Sorted(slices.Values(scopes))),
+		Status:     model.ConsentAwaitingAuthorisation,
+	}
+	if err := s.Repo.CreateConsent(consent); err != nil {
+		return nil, err
+	}
+	return consent, nil
+}
+
+// ClientConsent returns one of the client's consents
+func (s *ConsentService) ClientConsent(client *model.ThirdPartyClient, id string) (*model.Consent, error) {
+	consent, err := s.consent(id)
+	if err != nil {
+		return nil, err
+	}
+	if consent.ClientID != client.ID {
+		return nil, ErrConsentNotFound
+	}
+	return consent, nil
+}
+
+// RevokeByClient lets a client give up one of its consents
+func (s *ConsentService) RevokeByClient(client *model.ThirdPartyClient, id string) (*model.Consent, error) {
+	consent, err := s.ClientConsent(client, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.revoke(consent)
+}
+
+// UserConsent returns a consent the user gave, or a pending request so the
+// user can review it before approving
+func (s *ConsentService) UserConsent(userID, id string) (*model.Consent, error) {
+	consent, err := s.consent(id)
+	if err != nil {
+		return nil, err
+	}
+	if consent.Status == model.ConsentAwaitingAuthorisation {
+		return consent, nil
+	}
+	if consent.UserID == nil || consent.UserID.String() != userID {
+		return nil, ErrConsentNotFound
+	}
+	return consent, nil
+}
+
+// ListUserConsents returns the consents the user has acted on, newest first
+func (s *ConsentService) ListUserConsents(userID string) ([]model.Consent, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	return s.Repo.ListConsentsByUser(id)
+}
+
+// Approve grants a pending consent on the given accounts until expiresAt, or
+// for MaxConsentDuration when it is nil. The accounts are not checked here:
+// services only serve a consenting user's own accounts.
+func (s *ConsentService) Approve(userID, id string, accountIDs []string, expiresAt *time.Time) (*model.Consent, error) {
+	user, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	if len(accountIDs) == 0 {
+		return nil, ErrInvalidConsentAccounts
+	}
+	for _, accountID := range accountIDs {
+		if _, err := uuid.Parse(accountID); err != nil {
+			return nil, ErrInvalidConsentAccounts
+		}
+	}
+	now := time.Now()
+	expiry := now.Add(MaxConsentDuration)
+	if expiresAt != nil {
+		if !expiresAt.After(now) || expiresAt.After(expiry) {
+			return nil, ErrInvalidConsentExpiry
+		}
+		expiry = *expiresAt
+	}
+
+	consent, err := s.pending(id, now)
+	if err != nil {
+		return nil, err
+	}
+	consent.UserID = &user
+	consent.AccountIDs = slices.Compact(slices.Sorted(slices.Values(accountIDs)))
+	consent.Status = model.ConsentAuthorised
+	consent.ExpiresAt = &expiry
+	consent.AuthorisedAt = &now
+	return s.transition(consent, model.ConsentAwaitingAuthorisation)
+}
+
+// Reject declines a pending consent
+func (s *ConsentService) Reject(userID, id string) (*model.Consent, error) {
+	user, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	consent, err := s.pending(id, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	consent.UserID = &user
+	consent.Status = model.ConsentRejected
+	return s.transition(consent, model.ConsentAwaitingAuthorisation)
+}
+
+// RevokeByUser withdraws a consent the user gave
+func (s *ConsentService) RevokeByUser(userID, id string) (*model.Consent, error) {
+	consent, err := s.UserConsent(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if consent.UserID == nil {
+		return nil, ErrConsentNotFound
+	}
+	return s.revoke(consent)
+}
+
+// IssueToken returns a token that reads the consented accounts of the user.
+// It expires with the consent, and after ConsentTokenExpiry at the latest.
+func (s *ConsentService) IssueToken(client *model.ThirdPartyClient, id string) (*ConsentToken, error) {
+	consent, err := s.ClientConsent(client, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !consent.Active(now) {
+		return nil, ErrConsentNotActive
+	}
+
+	expiry := now.Add(ConsentTokenExpiry)
+	if consent.ExpiresAt.Before(expiry) {
+		expiry = *consent.ExpiresAt
+	}
+	scope := strings.Join(consent.Scopes, " ")
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, s.Audiences.stamp(jwt.MapClaims{
+		"user_id":     consent.UserID.String(),
+		"sub":         client.ClientID,
+		"role":        middleware.ThirdPartyRole,
+		"scope":       scope,
+		"consent_id":  consent.ID.String(),
+		"account_ids": consent.AccountIDs,
+		"iat":         now.Unix(),
+		"exp":         expiry.Unix(),
+	}, s.Audiences.ThirdParty))
+	signed, err := token.SignedString(s.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &ConsentToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(expiry.Sub(now).Seconds()),
+		Scope:       scope,
+		ConsentID:   consent.ID.String(),
+	}, nil
+}
+
+// consent looks up a consent; malformed IDs are not found
+func (s *ConsentService) consent(id string) (*model.Consent, error) {
+	consentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrConsentNotFound
+	}
+	consent, err := s.Repo.GetConsent(consentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConsentNotFound
+		}
+		return nil, err
+	}
+	return consent, nil
+}
+
+// pending looks up a consent request that can still be approved or rejected
+func (s *ConsentService) pending(id string, now time.Time) (*model.Consent, error) {
+	consent, err := s.consent(id)
+	if err != nil {
+		return nil, err
+	}
+	if consent.Status != model.ConsentAwaitingAuthorisation || now.Sub(consent.CreatedAt) > ConsentRequestTTL {
+		return nil, ErrConsentNotPending
+	}
+	return consent, nil
+}
+
+// revoke withdraws an authorised consent, whether or not it has expired
+func (s *ConsentService) revoke(consent *model.Consent) (*model.Consent, error) {
+	if consent.Status != model.ConsentAuthorised {
+		return nil, ErrConsentNotActive
+	}
+	now := time.Now()
+	consent.Status = model.ConsentRevoked
+	consent.RevokedAt = &now
+	return s.transition(consent, model.ConsentAuthorised)
+}
+
+// transition saves a status change unless another request changed the status first
+func (s *ConsentService) transition(consent *model.Consent, from model.ConsentStatus) (*model.Consent, error) {
+	updated, err := s.Repo.UpdateConsentFrom(consent, from)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		if from == model.ConsentAwaitingAuthorisation {
+			return nil, ErrConsentNotPending
+		}
+		return nil, ErrConsentNotActive
+	}
+	return consent, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryConsentRepository is an in-memory ConsentRepository
+type memoryConsentRepository struct {
+	clients  []model.ThirdPartyClient
+	consents map[uuid.UUID]model.Consent
+}
+
+func (r *memoryConsentRepository) CreateClient(client *model.ThirdPartyClient) error {
+	client.ID = uuid.New()
+	r.clients = append(r.clients, *client)
+	return nil
+}
+
+func (r *memoryConsentRepository) FindClientByAPIKeyHash(hash string) (*model.ThirdPartyClient, error) {
+	for _, c := range r.clients {
+		if c.APIKeyHash == hash {
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryConsentRepository) ListClients() ([]model.ThirdPartyClient, error) {
+	return r.clients, nil
+}
+
+func (r *memoryConsentRepository) RevokeClient(id string, revokedAt time.Time) (bool, error) {
+	for i := range r.clients {
+		if r.clients[i].ID.String() == id && r.clients[i].RevokedAt == nil {
+			r.clients[i].RevokedAt = &revokedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryConsentRepository) CreateConsent(consent *model.Consent) error {
+	consent.ID = uuid.New()
+	consent.CreatedAt = time.Now()
+	r.consents[consent.ID] = *consent
+	return nil
+}
+
+func (r *memoryConsentRepository) GetConsent(id uuid.UUID) (*model.Consent, error) {
+	consent, ok := r.consents[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &consent, nil
+}
+
+func (r *memoryConsentRepository) ListConsentsByUser(userID uuid.UUID) ([]model.Consent, error) {
+	var consents []model.Consent
+	for _, c := range r.consents {
+		if c.UserID != nil && *c.UserID == userID {
+			consents = append(consents, c)
+		}
+	}
+	return consents, nil
+}
+
+func (r *memoryConsentRepository) UpdateConsentFrom(consent *model.Consent, from model.ConsentStatus) (bool, error) {
+	if r.consents[consent.ID].Status != from {
+		return false, nil
+	}
+	r.consents[consent.ID] = *consent
+	return true, nil
+}
+
+func newTestConsentService(t *testing.T) (*ConsentService, *model.ThirdPartyClient, string) {
+	svc := NewConsentService(&memoryConsentRepository{consents: map[uuid.UUID]model.Consent{}}, "test-secret")
+	_, _, err := svc.CreateClient("Budget App", []string{"accounts:read", "payments:write"}, uuid.New().String())
+	require.ErrorIs(t, err, ErrUnknownScope)
+	client, apiKey, err := svc.CreateClient("Budget App", []string{"accounts:read", "balances:read"}, uuid.New().String())
+	require.NoError(t, err)
+	return svc, client, apiKey
+}
+
+func TestConsent_ApproveAndIssueToken(t *testing.T) {
+	svc, _, apiKey := newTestConsentService(t)
+	client, err := svc.Authenticate(apiKey)
+	require.NoError(t, err)
+	_, err = svc.Authenticate("tpk_wrong")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	_, err = svc.RequestConsent(client, []string{"transactions:read"})
+	assert.ErrorIs(t, err, ErrInvalidScope)
+	consent, err := svc.RequestConsent(client, []string{"balances:read", "accounts:read"})
+	require.NoError(t, err)
+	assert.Equal(t, model.ConsentAwaitingAuthorisation, consent.Status)
+
+	// No token until the user approves
+	_, err = svc.IssueToken(client, consent.ID.String())
+	assert.ErrorIs(t, err, ErrConsentNotActive)
+
+	userID, accountID := uuid.New().String(), uuid.New().String()
+	_, err = svc.Approve(userID, consent.ID.String(), []string{"not-an-id"}, nil)
+	assert.ErrorIs(t, err, ErrInvalidConsentAccounts)
+	tooLate := time.Now().Add(MaxConsentDuration + time.Hour)
+	_, err = svc.Approve(userID, consent.ID.String(), []string{accountID}, &tooLate)
+	assert.ErrorIs(t, err, ErrInvalidConsentExpiry)
+
+	expiry := time.Now().Add(2 * time.Minute)
+	approved, err := svc.Approve(userID, consent.ID.String(), []string{accountID}, &expiry)
+	require.NoError(t, err)
+	assert.Equal(t, model.ConsentAuthorised, approved.Status)
+	_, err = svc.Approve(uuid.New().String(), consent.ID.String(), []string{accountID}, nil)
+	assert.ErrorIs(t, err, ErrConsentNotPending)
+
+	issued, err := svc.IssueToken(client, consent.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "accounts:read balances:read", issued.Scope)
+	// The token expires with the consent, before ConsentTokenExpiry
+	assert.LessOrEqual(t, issued.ExpiresIn, 120)
+
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(issued.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, middleware.ThirdPartyRole, claims.Role)
+	assert.Equal(t, consent.ID.String(), claims.ConsentID)
+	assert.Equal(t, []string{accountID}, claims.AccountIDs)
+	assert.Equal(t, client.ClientID, claims.Subject)
+
+	// Other clients cannot see or use the consent
+	other, _, err := svc.CreateClient("Other App", []string{"accounts:read"}, uuid.New().String())
+	require.NoError(t, err)
+	_, err = svc.IssueToken(other, consent.ID.String())
+	assert.ErrorIs(t, err, ErrConsentNotFound)
+}
+
+func TestConsent_RejectAndRevoke(t *testing.T) {
+	svc, client, _ := newTestConsentService(t)
+	userID := uuid.New().String()
+
+	rejected, err := svc.RequestConsent(client, []string{"accounts:read"})
+	require.NoError(t, err)
+	_, err = svc.Reject(userID, rejected.ID.String())
+	require.NoError(t, err)
+	_, err = svc.Approve(userID, rejected.ID.String(), []string{uuid.New().String()}, nil)
+	assert.ErrorIs(t, err, ErrConsentNotPending)
+
+	consent, err := svc.RequestConsent(client, []string{"accounts:read"})
+	require.NoError(t, err)
+	_, err = svc.Approve(userID, consent.ID.String(), []string{uuid.New().String()}, nil)
+	require.NoError(t, err)
+
+	// Only the consenting user sees and revokes it
+	_, err = svc.UserConsent(uuid.New().String(), consent.ID.String())
+	assert.ErrorIs(t, err, ErrConsentNotFound)
+	_, err = svc.RevokeByUser(uuid.New().String(), consent.ID.String())
+	assert.ErrorIs(t, err, ErrConsentNotFound)
+	revoked, err := svc.RevokeByUser(userID, consent.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.ConsentRevoked, revoked.Status)
+	_, err = svc.IssueToken(client, consent.ID.String())
+	assert.ErrorIs(t, err, ErrConsentNotActive)
+
+	consents, err := svc.ListUserConsents(userID)
+	require.NoError(t, err)
+	assert.Len(t, consents, 2)
+
+	// A revoked client's API key stops working
+	require.NoError(t, svc.RevokeClient(client.ID.String()))
+	assert.ErrorIs(t, svc.RevokeClient(client.ID.String()), ErrThirdPartyClientMissing)
+}
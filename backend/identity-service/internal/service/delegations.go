@@ -0,0 +1,264 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	// DelegationTokenExpiry bounds how long a revoked delegation can still be used
+	DelegationTokenExpiry = 5 * time.Minute
+	// MaxDelegationDuration is the longest a user can delegate access for
+	// before granting it again
+	MaxDelegationDuration = 365 * 24 * time.Hour
+)
+
+var (
+	ErrDelegationNotFound        = errors.New("delegation not found")
+	ErrDelegationNotActive       = errors.New("delegation is revoked or has expired")
+	ErrDelegateNotFound          = errors.New("no user is registered with that email")
+	ErrDelegateNotVerified       = errors.New("access can only be delegated to a user whose identity has been verified")
+	ErrSelfDelegation            = errors.New("you cannot delegate access to yourself")
+	ErrInvalidDelegationAccess   = errors.New("access must be READ_ONLY or PAYMENTS")
+	ErrInvalidDelegationAccounts = errors.New("account_ids must list the IDs of one or more accounts")
+	ErrInvalidPaymentLimit       = errors.New("payment_limit must be positive, and is required for PAYMENTS delegations and not allowed otherwise")
+	ErrInvalidDelegationExpiry   = errors.New("delegation expiry must be in the future and at most a year away")
+)
+
+// DelegationRepository stores delegations
+type DelegationRepository interface {
+	CreateDelegation(delegation *model.Delegation) error
+	GetDelegation(id uuid.UUID) (*model.Delegation, error)
+	ListDelegationsByUser(userID uuid.UUID) ([]model.Delegation, error)
+	// UpdateDelegationFrom saves the delegation if its stored status is still
+	// from, and reports whether it did
+	UpdateDelegationFrom(delegation *model.Delegation, from model.DelegationStatus) (bool, error)
+}
+
+// DelegationService records the access users delegate to each other and
+// issues the tokens delegates act with
+type DelegationService struct {
+	Repo      DelegationRepository
+	Users     UserRepository
+	JWTSecret []byte
+	// Audiences addresses delegate tokens to the delegated access APIs
+	Audiences TokenAudiences
+}
+
+func NewDelegationService(repo DelegationRepository, users UserRepository, secret string) *DelegationService {
+	return &DelegationService{Repo: repo, Users: users, JWTSecret: []byte(secret)}
+}
+
+// DelegationGrant is what a user delegates
+type DelegationGrant struct {
+	GranteeEmail string
+	Access       model.DelegationAccess
+	AccountIDs   []string
+	// PaymentLimit is required for PAYMENTS delegations
+	PaymentLimit *decimal.Decimal
+	// ExpiresAt defaults to MaxDelegationDuration from now
+	ExpiresAt *time.Time
+}
+
+// DelegationToken is an access token scoped to one delegation
+type DelegationToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	Scope        string `json:"scope"`
+	DelegationID string `json:"delegation_id"`
+}
+
+// Grant lets another verified user act on some of the grantor's accounts.
+// The accounts are not checked here: services only serve a delegation on the
+// grantor's own accounts.
+func (s *DelegationService) Grant(grantorID string, grant DelegationGrant) (*model.Delegation, error) {
+	grantor, err := uuid.Parse(grantorID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	switch grant.Access {
+	case model.DelegationReadOnly:
+		if grant.PaymentLimit != nil {
+			return nil, ErrInvalidPaymentLimit
+		}
+	case model.DelegationPayments:
+		if grant.PaymentLimit == nil || !grant.PaymentLimit.IsPositive() {
+			return nil, ErrInvalidPaymentLimit
+		}
+	default:
+		return nil, ErrInvalidDelegationAccess
+	}
+	if len(grant.AccountIDs) == 0 {
+		return nil, ErrInvalidDelegationAccounts
+	}
+	for _, accountID := range grant.AccountIDs {
+		if _, err := uuid.Parse(accountID); err != nil {
+			return nil, ErrInvalidDelegationAccounts
+		}
+	}
+	now := time.Now()
+	expiry := now.Add(MaxDelegationDuration)
+	if grant.ExpiresAt != nil {
+		if !grant.ExpiresAt.After(now) || grant.ExpiresAt.After(expiry) {
+			return nil, ErrInvalidDelegationExpiry
+		}
+		expiry = *grant.ExpiresAt
+	}
+
+	grantee, err := s.Users.FindByEmail(strings.TrimSpace(grant.GranteeEmail))
+	if err != nil {
+		return nil, ErrDelegateNotFound
+	}
+	if grantee.ID == grantor {
+		return nil, ErrSelfDelegation
+	}
+	if grantee.KYCStatus != KYCVerified {
+		return nil, ErrDelegateNotVerified
+	}
+
+	delegation := &model.Delegation{
+		GrantorID:    grantor,
+		GranteeID:    grantee.ID,
+		GranteeEmail: grantee.Email,
+		Access:       grant.Access,
+		AccountIDs:   slices.Compact(slices.Sorted(slices.Values(grant.AccountIDs))),
+		PaymentLimit: grant.PaymentLimit,
+		Status:       model.DelegationActive,
+		ExpiresAt:    expiry,
+	}
+	if err := s.Repo.CreateDelegation(delegation); err != nil {
+		return nil, err
+	}
+	return delegation, nil
+}
+
+// List returns the delegations the user granted or was granted, newest first
+func (s *DelegationService) List(userID string) ([]model.Delegation, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	return s.Repo.ListDelegationsByUser(id)
+}
+
+// Get returns a delegation the user granted or was granted
+func (s *DelegationService) Get(userID, id string) (*model.Delegation, error) {
+	delegation, err := s.delegation(id)
+	if err != nil {
+		return nil, err
+	}
+	if delegation.GrantorID.String() != userID && delegation.GranteeID.String() != userID {
+		return nil, ErrDelegationNotFound
+	}
+	return delegation, nil
+}
+
+// Revoke ends a delegation; either the grantor or the delegate can revoke it
+func (s *DelegationService) Revoke(userID, id string) (*model.Delegation, error) {
+	delegation, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if delegation.Status != model.DelegationActive {
+		return nil, ErrDelegationNotActive
+	}
+	by := uuid.MustParse(userID)
+	now := time.Now()
+	delegation.Status = model.DelegationRevoked
+	delegation.RevokedAt = &now
+	delegation.RevokedBy = &by
+	updated, err := s.Repo.UpdateDelegationFrom(delegation, model.DelegationActive)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrDelegationNotActive
+	}
+	return delegation, nil
+}
+
+// IssueToken returns a token the delegate acts for the grantor with. It
+// carries the grantor as its user, the delegated accounts and the payment
+// limit, and expires with the delegation, after DelegationTokenExpiry at the
+// latest.
+func (s *DelegationService) IssueToken(granteeID, id string) (*DelegationToken, error) {
+	delegation, err := s.delegation(id)
+	if err != nil {
+		return nil, err
+	}
+	if delegation.GranteeID.String() != granteeID {
+		return nil, ErrDelegationNotFound
+	}
+	now := time.Now()
+	if !delegation.Active(now) {
+		return nil, ErrDelegationNotActive
+	}
+	// A delegate whose verification has since been withdrawn cannot act
+	grantee, err := s.Users.FindByID(granteeID)
+	if err != nil {
+		return nil, err
+	}
+	if grantee.KYCStatus != KYCVerified {
+		return nil, ErrDelegateNotVerified
+	}
+
+	expiry := now.Add(DelegationTokenExpiry)
+	if delegation.ExpiresAt.Before(expiry) {
+		expiry = delegation.ExpiresAt
+	}
+	scopes := []string{middleware.DelegationReadScope}
+	claims := jwt.MapClaims{
+		"user_id":       delegation.GrantorID.String(),
+		"sub":           granteeID,
+		"role":          middleware.DelegateRole,
+		"delegation_id": delegation.ID.String(),
+		"delegate_id":   granteeID,
+		"account_ids":   delegation.AccountIDs,
+		"iat":           now.Unix(),
+		"exp":           expiry.Unix(),
+	}
+	if delegation.Access == model.DelegationPayments {
+		scopes = append(scopes, middleware.DelegationPayScope)
+		claims["payment_limit"] = delegation.PaymentLimit.String()
+	}
+	scope := strings.Join(scopes, " ")
+	claims["scope"] = scope
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, s.Audiences.stamp(claims, s.Audiences.Delegate))
+	signed, err := token.SignedString(s.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &DelegationToken{
+		AccessToken:  signed,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(expiry.Sub(now).Seconds()),
+		Scope:        scope,
+		DelegationID: delegation.ID.String(),
+	}, nil
+}
+
+// delegation looks up a delegation; malformed IDs are not found
+func (s *DelegationService) delegation(id string) (*model.Delegation, error) {
+	delegationID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrDelegationNotFound
+	}
+	delegation, err := s.Repo.GetDelegation(delegationID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDelegationNotFound
+		}
+		return nil, err
+	}
+	return delegation, nil
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryDelegationRepository is an in-memory DelegationRepository
+type memoryDelegationRepository struct {
+	delegations map[uuid.UUID]model.Delegation
+}
+
+func (r *memoryDelegationRepository) CreateDelegation(delegation *model.Delegation) error {
+	delegation.ID = uuid.New()
+	delegation.CreatedAt = time.Now()
+	r.delegations[delegation.ID] = *delegation
+	return nil
+}
+
+func (r *memoryDelegationRepository) GetDelegation(id uuid.UUID) (*model.Delegation, error) {
+	delegation, ok := r.delegations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &delegation, nil
+}
+
+func (r *memoryDelegationRepository) ListDelegationsByUser(userID uuid.UUID) ([]model.Delegation, error) {
+	var delegations []model.Delegation
+	for _, d := range r.delegations {
+		if d.GrantorID == userID || d.GranteeID == userID {
+			delegations = append(delegations, d)
+		}
+	}
+	return delegations, nil
+}
+
+func (r *memoryDelegationRepository) UpdateDelegationFrom(delegation *model.Delegation, from model.DelegationStatus) (bool, error) {
+	if r.delegations[delegation.ID].Status != from {
+		return false, nil
+	}
+	r.delegations[delegation.ID] = *delegation
+	return true, nil
+}
+
+// newTestDelegationService returns a service with a grantor, a verified
+// delegate and an unverified user
+func newTestDelegationService() (*DelegationService, *model.User, *model.User, *model.User) {
+	users := new(MockUserRepository)
+	registered := orgTestUsers(users, "grantor@example.com", "delegate@example.com", "unverified@example.com")
+	registered[1].KYCStatus = KYCVerified
+	registered[2].KYCStatus = "UNVERIFIED"
+	svc := NewDelegationService(&memoryDelegationRepository{delegations: map[uuid.UUID]model.Delegation{}}, users, serviceTestSecret)
+	return svc, registered[0], registered[1], registered[2]
+}
+
+func TestDelegation_GrantValidation(t *testing.T) {
+	svc, grantor, delegate, unverified := newTestDelegationService()
+	accountID := uuid.New().String()
+	limit := decimal.NewFromInt(250)
+	grant := func(g DelegationGrant) error {
+		_, err := svc.Grant(grantor.ID.String(), g)
+		return err
+	}
+
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: delegate.Email, Access: "ADMIN", AccountIDs: []string{accountID}}), ErrInvalidDelegationAccess)
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: delegate.Email, Access: model.DelegationPayments, AccountIDs: []string{accountID}}), ErrInvalidPaymentLimit)
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: delegate.Email, Access: model.DelegationReadOnly, AccountIDs: []string{accountID}, PaymentLimit: &limit}), ErrInvalidPaymentLimit)
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: delegate.Email, Access: model.DelegationReadOnly, AccountIDs: []string{"not-an-id"}}), ErrInvalidDelegationAccounts)
+	tooLate := time.Now().Add(MaxDelegationDuration + time.Hour)
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: delegate.Email, Access: model.DelegationReadOnly, AccountIDs: []string{accountID}, ExpiresAt: &tooLate}), ErrInvalidDelegationExpiry)
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: grantor.Email, Access: model.DelegationReadOnly, AccountIDs: []string{accountID}}), ErrSelfDelegation)
+	assert.ErrorIs(t, grant(DelegationGrant{GranteeEmail: unverified.Email, Access: model.DelegationReadOnly, AccountIDs: []string{accountID}}), ErrDelegateNotVerified)
+
+	delegation, err := svc.Grant(grantor.ID.String(), DelegationGrant{GranteeEmail: " delegate@example.com ", Access: model.DelegationReadOnly, AccountIDs: []string{accountID, accountID}})
+	require.NoError(t, err)
+	assert.Equal(t, delegate.ID, delegation.GranteeID)
+	assert.Equal(t, []string{accountID}, delegation.AccountIDs)
+	assert.Equal(t, model.DelegationActive, delegation.Status)
+	assert.WithinDuration(t, time.Now().Add(MaxDelegationDuration), delegation.ExpiresAt, time.Minute)
+
+	// Both sides see the delegation
+	for _, user := range []*model.User{grantor, delegate} {
+		listed, err := svc.List(user.ID.String())
+		require.NoError(t, err)
+		assert.Len(t, listed, 1)
+	}
+	_, err = svc.Get(unverified.ID.String(), delegation.ID.String())
+	assert.ErrorIs(t, err, ErrDelegationNotFound)
+}
+
+func TestDelegation_IssueToken(t *testing.T) {
+	svc, grantor, delegate, _ := newTestDelegationService()
+	svc.Audiences = TokenAudiences{Issuer: "neobank", Delegate: []string{"ledger-service", "payment-service"}}
+	accountID := uuid.New().String()
+	limit := decimal.RequireFromString("250.00")
+	expiry := time.Now().Add(2 * time.Minute)
+	delegation, err := svc.Grant(grantor.ID.String(), DelegationGrant{
+		GranteeEmail: delegate.Email, Access: model.DelegationPayments, AccountIDs: []string{accountID},
+		PaymentLimit: &limit, ExpiresAt: &expiry,
+	})
+	require.NoError(t, err)
+
+	// Only the delegate can use the delegation
+	_, err = svc.IssueToken(grantor.ID.String(), delegation.ID.String())
+	assert.ErrorIs(t, err, ErrDelegationNotFound)
+
+	issued, err := svc.IssueToken(delegate.ID.String(), delegation.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "delegation:read delegation:pay", issued.Scope)
+	// The token expires with the delegation, before DelegationTokenExpiry
+	assert.LessOrEqual(t, issued.ExpiresIn, 120)
+
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(issued.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(serviceTestSecret), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, grantor.ID.String(), claims.UserID, "the delegate acts for the grantor")
+	assert.Equal(t, delegate.ID.String(), claims.DelegateID)
+	assert.Equal(t, middleware.DelegateRole, claims.Role)
+	assert.Equal(t, delegation.ID.String(), claims.DelegationID)
+	assert.Equal(t, []string{accountID}, claims.AccountIDs)
+	assert.Equal(t, "250", claims.PaymentLimit)
+	assert.Equal(t, jwt.ClaimStrings{"ledger-service", "payment-service"}, claims.Audience)
+
+	// Either side can revoke, once
+	revoked, err := svc.Revoke(delegate.ID.String(), delegation.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.DelegationRevoked, revoked.Status)
+	assert.Equal(t, delegate.ID, *revoked.RevokedBy)
+	_, err = svc.Revoke(grantor.ID.String(), delegation.ID.String())
+	assert.ErrorIs(t, err, ErrDelegationNotActive)
+	_, err = svc.IssueToken(delegate.ID.String(), delegation.ID.String())
+	assert.ErrorIs(t, err, ErrDelegationNotActive)
+}
+
+func TestDelegation_ReadOnlyTokenCannotPay(t *testing.T) {
+	svc, grantor, delegate, _ := newTestDelegationService()
+	delegation, err := svc.Grant(grantor.ID.String(), DelegationGrant{
+		GranteeEmail: delegate.Email, Access: model.DelegationReadOnly, AccountIDs: []string{uuid.New().String()},
+	})
+	require.NoError(t, err)
+
+	issued, err := svc.IssueToken(delegate.ID.String(), delegation.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, middleware.DelegationReadScope, issued.Scope)
+
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(issued.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(serviceTestSecret), nil
+	})
+	require.NoError(t, err)
+	assert.False(t, claims.HasScope(middleware.DelegationPayScope))
+	assert.Empty(t, claims.PaymentLimit)
+
+	// A delegate whose verification is withdrawn can no longer act
+	delegate.KYCStatus = "REJECTED"
+	_, err = svc.IssueToken(delegate.ID.String(), delegation.ID.String())
+	assert.ErrorIs(t, err, ErrDelegateNotVerified)
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+const (
+	// LoginHistorySize is how many recent logins new logins are compared against
+	LoginHistorySize = 20
+	// LoginChallengeExpiry is how long an emailed step-up code stays valid
+	LoginChallengeExpiry = 10 * time.Minute
+	// MaxLoginChallengeAttempts is the number of wrong codes before a challenge is locked
+	MaxLoginChallengeAttempts = 5
+	// MaxTravelSpeedKmh is faster than a commercial flight; a quicker move between
+	// two logins is treated as impossible travel
+	MaxTravelSpeedKmh = 1000
+	// minTravelDistanceKm ignores jumps within the accuracy of IP geolocation
+	minTravelDistanceKm = 500
+	// LoginVerificationTemplate is the notification template for step-up codes
+	LoginVerificationTemplate = "LOGIN_VERIFICATION"
+)
+
+// Reasons a login was flagged
+const (
+	AnomalyNewDevice        = "NEW_DEVICE"
+	AnomalyImpossibleTravel = "IMPOSSIBLE_TRAVEL"
+)
+
+var (
+	ErrLoginChallengeInvalid = errors.New("invalid or expired verification code")
+	ErrLoginChallengeLocked  = errors.New("too many incorrect verification codes, please sign in again")
+)
+
+// LoginActivityRepository stores login history and step-up challenges
+type LoginActivityRepository interface {
+	CreateEvent(event *model.LoginEvent) error
+	ListRecentEvents(userID string, limit int) ([]model.LoginEvent, error)
+	UpdateEventOutcome(id string, outcome model.LoginOutcome) error
+	CreateChallenge(challenge *model.LoginChallenge) error
+	FindChallenge(id string) (*model.LoginChallenge, error)
+	RecordChallengeAttempt(id string) error
+	MarkChallengeUsed(id string, usedAt time.Time) (bool, error)
+}
+
+// EventPublisher publishes events to Kafka
+type EventPublisher interface {
+	Produce(ctx context.Context, topic string, key string, value interface{}) error
+}
+
+// GeoLocation is the approximate location of a client IP
+type GeoLocation struct {
+	Country   string
+	City      string
+	Latitude  float64
+	Longitude float64
+}
+
+// LoginContext describes where a login attempt came from
+type LoginContext struct {
+	IP        string
+	UserAgent string
+	DeviceID  string
+	Location  *GeoLocation
+}
+
+// LoginAssessment is the outcome of comparing a login with the user's history
+type LoginAssessment struct {
+	NewDevice        bool
+	ImpossibleTravel bool
+	DistanceKm       float64
+	SpeedKmh         float64
+}
+
+// Suspicious reports whether any anomaly was found
+func (a LoginAssessment) Suspicious() bool {
+	return a.NewDevice || a.ImpossibleTravel
+}
+
+// Reasons lists the anomalies found
+func (a LoginAssessment) Reasons() []string {
+	var reasons []string
+	if a.NewDevice {
+		reasons = append(reasons, AnomalyNewDevice)
+	}
+	if a.ImpossibleTravel {
+		reasons = append(reasons, AnomalyImpossibleTravel)
+	}
+	return reasons
+}
+
+// LoginChallengeInfo tells the client a flagged login needs the emailed code
+type LoginChallengeInfo struct {
+	ChallengeID string    `json:"challenge_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Reasons     []string  `json:"reasons"`
+}
+
+// LoginResult is the outcome of a password login. Exactly one of Token and
+// Challenge is set.
+type LoginResult struct {
+	User       *model.User
+	Token      string
+	Assessment LoginAssessment
+	Challenge  *LoginChallengeInfo
+}
+
+// AssessLogin compares a login with the user's recent logins, newest first.
+// Only logins that succeeded or passed step-up count as history, and a user's
+// first login is never flagged.
+func AssessLogin(history []model.LoginEvent, lc LoginContext, now time.Time) LoginAssessment {
+	var trusted []model.LoginEvent
+	for _, e := range history {
+		if e.Outcome == model.LoginSucceeded || e.Outcome == model.LoginStepUpPassed {
+			trusted = append(trusted, e)
+		}
+	}
+
+	var a LoginAssessment
+	if len(trusted) == 0 {
+		return a
+	}
+
+	a.NewDevice = true
+	for _, e := range trusted {
+		if e.DeviceID == lc.DeviceID {
+			a.NewDevice = false
+			break
+		}
+	}
+
+	if lc.Location == nil {
+		return a
+	}
+	for _, e := range trusted {
+		if e.Latitude == nil || e.Longitude == nil {
+			continue
+		}
+		a.DistanceKm = haversineKm(*e.Latitude, *e.Longitude, lc.Location.Latitude, lc.Location.Longitude)
+		// Floor the interval so back-to-back logins do not divide by zero
+		hours := math.Max(now.Sub(e.CreatedAt).Hours(), 1.0/60)
+		a.SpeedKmh = a.DistanceKm / hours
+		a.ImpossibleTravel = a.DistanceKm >= minTravelDistanceKm && a.SpeedKmh > MaxTravelSpeedKmh
+		break
+	}
+	return a
+}
+
+// LoginWithContext checks the password and compares the login with the user's
+// history. A login from a new device or an impossible location gets no token;
+// instead a one-time code is emailed through the notification topic and must
+// be confirmed with VerifyLoginChallenge. Without LoginActivity it behaves like Login.
+func (s *AuthService) LoginWithContext(ctx context.Context, email, password string, lc LoginContext) (*LoginResult, error) {
+	user, err := s.authenticate(email, password)
+	if err != nil {
+		return nil, err
+	}
+	if s.LoginActivity == nil {
+		token, err := s.issueLoginToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, Token: token}, nil
+	}
+
+	history, err := s.LoginActivity.ListRecentEvents(user.ID.String(), LoginHistorySize)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	assessment := AssessLogin(history, lc, now)
+
+	event := &model.LoginEvent{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		IP:               lc.IP,
+		UserAgent:        lc.UserAgent,
+		DeviceID:         lc.DeviceID,
+		NewDevice:        assessment.NewDevice,
+		ImpossibleTravel: assessment.ImpossibleTravel,
+		Outcome:          model.LoginSucceeded,
+		CreatedAt:        now,
+	}
+	if lc.Location != nil {
+		event.Country = lc.Location.Country
+		event.City = lc.Location.City
+		event.Latitude = &lc.Location.Latitude
+		event.Longitude = &lc.Location.Longitude
+	}
+
+	// Step-up needs a way to deliver the code; without one the login is only flagged
+	stepUp := assessment.Suspicious() && s.Notifications != nil
+	if stepUp {
+		event.Outcome = model.LoginStepUpRequired
+	}
+	if err := s.LoginActivity.CreateEvent(event); err != nil {
+		return nil, err
+	}
+	if assessment.NewDevice {
+		s.recordSignal(SignalNewDeviceLogin)
+	}
+
+	result := &LoginResult{User: user, Assessment: assessment}
+	if !stepUp {
+		if result.Token, err = s.issueLoginToken(user); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	code, err := generateLoginCode()
+	if err != nil {
+		return nil, err
+	}
+	challengeID := uuid.New()
+	challenge := &model.LoginChallenge{
+		ID:           challengeID,
+		UserID:       user.ID,
+		LoginEventID: event.ID,
+		CodeHash:     s.loginCodeHash(challengeID.String(), code),
+		ExpiresAt:    now.Add(LoginChallengeExpiry),
+	}
+	if err := s.LoginActivity.CreateChallenge(challenge); err != nil {
+		return nil, err
+	}
+
+	if err := s.Notifications.Produce(ctx, kafka.TopicNotificationEmail, user.ID.String(), kafka.NotificationEvent{
+		UserID:    user.ID.String(),
+		Channel:   "EMAIL",
+		Recipient: user.Email,
+		Template:  LoginVerificationTemplate,
+		Data: map[string]string{
+			"code":       code,
+			"reasons":    strings.Join(assessment.Reasons(), ","),
+			"ip":         lc.IP,
+			"device":     lc.UserAgent,
+			"location":   describeLocation(lc.Location),
+			"expires_at": challenge.ExpiresAt.Format(time.RFC3339),
+		},
+		Timestamp: now.Format(time.RFC3339),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to send login verification code: %w", err)
+	}
+
+	result.Challenge = &LoginChallengeInfo{
+		ChallengeID: challenge.ID.String(),
+		ExpiresAt:   challenge.ExpiresAt,
+		Reasons:     assessment.Reasons(),
+	}
+	return result, nil
+}
+
+// VerifyLoginChallenge completes a flagged login with the emailed code. The
+// device and location count as known for later logins once confirmed.
+func (s *AuthService) VerifyLoginChallenge(challengeID, code string) (*LoginResult, error) {
+	if s.LoginActivity == nil {
+		return nil, ErrLoginChallengeInvalid
+	}
+
+	challenge, err := s.LoginActivity.FindChallenge(challengeID)
+	if err != nil {
+		return nil, ErrLoginChallengeInvalid
+	}
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrLoginChallengeInvalid
+	}
+	if challenge.Attempts >= MaxLoginChallengeAttempts {
+		return nil, ErrLoginChallengeLocked
+	}
+
+	expected, _ := hex.DecodeString(challenge.CodeHash)
+	actual, _ := hex.DecodeString(s.loginCodeHash(challengeID, code))
+	if !hmac.Equal(expected, actual) {
+		s.recordSignal(SignalMFAFailure)
+		if err := s.LoginActivity.RecordChallengeAttempt(challengeID); err != nil {
+			return nil, err
+		}
+		if challenge.Attempts+1 >= MaxLoginChallengeAttempts {
+			return nil, ErrLoginChallengeLocked
+		}
+		return nil, ErrLoginChallengeInvalid
+	}
+
+	consumed, err := s.LoginActivity.MarkChallengeUsed(challengeID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrLoginChallengeInvalid
+	}
+	if err := s.LoginActivity.UpdateEventOutcome(challenge.LoginEventID.String(), model.LoginStepUpPassed); err != nil {
+		return nil, err
+	}
+
+	user, err := s.Repo.FindByID(challenge.UserID.String())
+	if err != nil {
+		return nil, ErrLoginChallengeInvalid
+	}
+	token, err := s.issueLoginToken(user)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{User: user, Token: token}, nil
+}
+
+// RecentActivity returns the user's recent logins, newest first
+func (s *AuthService) RecentActivity(userID string) ([]model.LoginEvent, error) {
+	if s.LoginActivity == nil {
+		return []model.LoginEvent{}, nil
+	}
+	return s.LoginActivity.ListRecentEvents(userID, LoginHistorySize)
+}
+
+// loginCodeHash binds a step-up code to its challenge so stored hashes cannot be reused
+func (s *AuthService) loginCodeHash(challengeID, code string) string {
+	mac := hmac.New(sha256.New, s.JWTSecret)
+	mac.Write([]byte("login-challenge:" + challengeID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateLoginCode returns a random 6-digit code
+func generateLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func describeLocation(loc *GeoLocation) string {
+	if loc == nil {
+		return ""
+	}
+	if loc.City == "" {
+		return loc.Country
+	}
+	return loc.City + ", " + loc.Country
+}
+
+// haversineKm returns the great-circle distance between two coordinates
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MockLoginActivityRepository is a mock implementation of LoginActivityRepository
+type MockLoginActivityRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoginActivityRepository) CreateEvent(event *model.LoginEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+func (m *MockLoginActivityRepository) ListRecentEvents(userID string, limit int) ([]model.LoginEvent, error) {
+	args := m.Called(userID, limit)
+	return args.Get(0).([]model.LoginEvent), args.Error(1)
+}
+
+func (m *MockLoginActivityRepository) UpdateEventOutcome(id string, outcome model.LoginOutcome) error {
+	args := m.Called(id, outcome)
+	return args.Error(0)
+}
+
+func (m *MockLoginActivityRepository) CreateChallenge(challenge *model.LoginChallenge) error {
+	args := m.Called(challenge)
+	return args.Error(0)
+}
+
+func (m *MockLoginActivityRepository) FindChallenge(id string) (*model.LoginChallenge, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoginChallenge), args.Error(1)
+}
+
+func (m *MockLoginActivityRepository) RecordChallengeAttempt(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockLoginActivityRepository) MarkChallengeUsed(id string, usedAt time.Time) (bool, error) {
+	args := m.Called(id, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+// captureNotifications records the notification events produced
+type captureNotifications struct {
+	events []kafka.NotificationEvent
+}
+
+func (c *captureNotifications) Produce(_ context.Context, topic string, _ string, value interface{}) error {
+	if topic == kafka.TopicNotificationEmail {
+		c.events = append(c.events, value.(kafka.NotificationEvent))
+	}
+	return nil
+}
+
+func floatPtr(f float64) *float64 { return &f }
+
+var (
+	london = &GeoLocation{Country: "GB", City: "London", Latitude: 51.5074, Longitude: -0.1278}
+	sydney = &GeoLocation{Country: "AU", City: "Sydney", Latitude: -33.8688, Longitude: 151.2093}
+)
+
+func knownLogin(deviceID string, loc *GeoLocation, at time.Time) model.LoginEvent {
+	e := model.LoginEvent{DeviceID: deviceID, Outcome: model.LoginSucceeded, CreatedAt: at}
+	if loc != nil {
+		e.Latitude = floatPtr(loc.Latitude)
+		e.Longitude = floatPtr(loc.Longitude)
+	}
+	return e
+}
+
+func TestAssessLogin(t *testing.T) {
+	now := time.Now()
+	history := []model.LoginEvent{knownLogin("phone", london, now.Add(-2*time.Hour))}
+
+	t.Run("first login is never flagged", func(t *testing.T) {
+		a := AssessLogin(nil, LoginContext{DeviceID: "laptop", Location: sydney}, now)
+		assert.False(t, a.Suspicious())
+	})
+
+	t.Run("known device nearby", func(t *testing.T) {
+		a := AssessLogin(history, LoginContext{DeviceID: "phone", Location: london}, now)
+		assert.False(t, a.Suspicious())
+	})
+
+	t.Run("new device", func(t *testing.T) {
+		a := AssessLogin(history, LoginContext{DeviceID: "laptop", Location: london}, now)
+		assert.True(t, a.NewDevice)
+		assert.False(t, a.ImpossibleTravel)
+		assert.Equal(t, []string{AnomalyNewDevice}, a.Reasons())
+	})
+
+	t.Run("impossible travel", func(t *testing.T) {
+		a := AssessLogin(history, LoginContext{DeviceID: "phone", Location: sydney}, now)
+		assert.True(t, a.ImpossibleTravel)
+		assert.InDelta(t, 17000, a.DistanceKm, 100)
+		assert.Greater(t, a.SpeedKmh, float64(MaxTravelSpeedKmh))
+	})
+
+	t.Run("long trip with enough time", func(t *testing.T) {
+		old := []model.LoginEvent{knownLogin("phone", london, now.Add(-30*time.Hour))}
+		a := AssessLogin(old, LoginContext{DeviceID: "phone", Location: sydney}, now)
+		assert.False(t, a.ImpossibleTravel)
+	})
+
+	t.Run("unconfirmed logins are not history", func(t *testing.T) {
+		pending := knownLogin("laptop", sydney, now.Add(-time.Minute))
+		pending.Outcome = model.LoginStepUpRequired
+		a := AssessLogin(append([]model.LoginEvent{pending}, history...), LoginContext{DeviceID: "laptop", Location: london}, now)
+		assert.True(t, a.NewDevice)
+		assert.False(t, a.ImpossibleTravel)
+	})
+}
+
+func newAnomalyService(t *testing.T) (*AuthService, *MockLoginActivityRepository, *captureNotifications, *model.User) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &model.User{ID: uuid.New(), Email: "user@example.com", PasswordHash: string(hash), Role: "customer"}
+
+	userRepo := new(MockUserRepository)
+	userRepo.On("FindByEmail", user.Email).Return(user, nil)
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+
+	activity := new(MockLoginActivityRepository)
+	notifications := &captureNotifications{}
+	svc := NewAuthService(userRepo, "secret")
+	svc.LoginActivity = activity
+	svc.Notifications = notifications
+	return svc, activity, notifications, user
+}
+
+func TestLoginWithContext_KnownDeviceGetsToken(t *testing.T) {
+	svc, activity, notifications, user := newAnomalyService(t)
+	activity.On("ListRecentEvents", user.ID.String(), LoginHistorySize).
+		Return([]model.LoginEvent{knownLogin("phone", london, time.Now().Add(-time.Hour))}, nil)
+	activity.On("CreateEvent", mock.MatchedBy(func(e *model.LoginEvent) bool {
+		return e.Outcome == model.LoginSucceeded && e.DeviceID == "phone" && e.Country == "GB"
+	})).Return(nil)
+
+	result, err := svc.LoginWithContext(context.Background(), user.Email, "password", LoginContext{DeviceID: "phone", Location: london})
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Token)
+	assert.Nil(t, result.Challenge)
+	assert.Empty(t, notifications.events)
+}
+
+func TestLoginWithContext_FlaggedLoginRequiresEmailedCode(t *testing.T) {
+	svc, activity, notifications, user := newAnomalyService(t)
+	activity.On("ListRecentEvents", user.ID.String(), LoginHistorySize).
+		Return([]model.LoginEvent{knownLogin("phone", london, time.Now().Add(-time.Hour))}, nil)
+
+	var event *model.LoginEvent
+	var challenge *model.LoginChallenge
+	activity.On("CreateEvent", mock.AnythingOfType("*model.LoginEvent")).
+		Run(func(args mock.Arguments) { event = args.Get(0).(*model.LoginEvent) }).Return(nil)
+	activity.On("CreateChallenge", mock.AnythingOfType("*model.LoginChallenge")).
+		Run(func(args mock.Arguments) { challenge = args.Get(0).(*model.LoginChallenge) }).Return(nil)
+
+	result, err := svc.LoginWithContext(context.Background(), user.Email, "password", LoginContext{DeviceID: "laptop", Location: sydney})
+	require.NoError(t, err)
+	assert.Empty(t, result.Token)
+	require.NotNil(t, result.Challenge)
+	assert.Equal(t, []string{AnomalyNewDevice, AnomalyImpossibleTravel}, result.Challenge.Reasons)
+	assert.Equal(t, model.LoginStepUpRequired, event.Outcome)
+	assert.Equal(t, event.ID, challenge.LoginEventID)
+
+	require.Len(t, notifications.events, 1)
+	sent := notifications.events[0]
+	assert.Equal(t, user.Email, sent.Recipient)
+	assert.Equal(t, LoginVerificationTemplate, sent.Template)
+	code := sent.Data["code"]
+	assert.Len(t, code, 6)
+	assert.NotContains(t, challenge.CodeHash, code)
+
+	// A wrong code counts an attempt; the right one issues the token
+	activity.On("FindChallenge", challenge.ID.String()).Return(challenge, nil)
+	activity.On("RecordChallengeAttempt", challenge.ID.String()).Return(nil).Once()
+	_, err = svc.VerifyLoginChallenge(challenge.ID.String(), "000000x")
+	assert.ErrorIs(t, err, ErrLoginChallengeInvalid)
+
+	activity.On("MarkChallengeUsed", challenge.ID.String(), mock.AnythingOfType("time.Time")).Return(true, nil)
+	activity.On("UpdateEventOutcome", event.ID.String(), model.LoginStepUpPassed).Return(nil)
+	verified, err := svc.VerifyLoginChallenge(challenge.ID.String(), code)
+	require.NoError(t, err)
+	assert.NotEmpty(t, verified.Token)
+	activity.AssertExpectations(t)
+}
+
+func TestVerifyLoginChallenge_Rejections(t *testing.T) {
+	svc, activity, _, user := newAnomalyService(t)
+	id := uuid.New()
+
+	t.Run("expired", func(t *testing.T) {
+		activity.On("FindChallenge", "expired").Return(&model.LoginChallenge{ID: id, UserID: user.ID, ExpiresAt: time.Now().Add(-time.Minute)}, nil)
+		_, err := svc.VerifyLoginChallenge("expired", "123456")
+		assert.ErrorIs(t, err, ErrLoginChallengeInvalid)
+	})
+
+	t.Run("locked after too many attempts", func(t *testing.T) {
+		challenge := &model.LoginChallenge{ID: id, UserID: user.ID, ExpiresAt: time.Now().Add(time.Minute), Attempts: MaxLoginChallengeAttempts - 1}
+		challenge.CodeHash = svc.loginCodeHash(id.String(), "123456")
+		activity.On("FindChallenge", id.String()).Return(challenge, nil)
+		activity.On("RecordChallengeAttempt", id.String()).Return(nil)
+
+		_, err := svc.VerifyLoginChallenge(id.String(), "654321")
+		assert.ErrorIs(t, err, ErrLoginChallengeLocked)
+	})
+
+	t.Run("code used concurrently", func(t *testing.T) {
+		other := uuid.New()
+		challenge := &model.LoginChallenge{ID: other, UserID: user.ID, ExpiresAt: time.Now().Add(time.Minute)}
+		challenge.CodeHash = svc.loginCodeHash(other.String(), "123456")
+		activity.On("FindChallenge", other.String()).Return(challenge, nil)
+		activity.On("MarkChallengeUsed", other.String(), mock.AnythingOfType("time.Time")).Return(false, nil)
+
+		_, err := svc.VerifyLoginChallenge(other.String(), "123456")
+		assert.ErrorIs(t, err, ErrLoginChallengeInvalid)
+	})
+}
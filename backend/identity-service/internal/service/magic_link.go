@@ -0,0 +1,165 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+)
+
+// MagicLinkExpiry is how long an emailed login link stays valid
+const MagicLinkExpiry = 10 * time.Minute
+
+var (
+	ErrMagicLinkInvalid     = errors.New("invalid or expired magic link")
+	ErrMagicLinkRateLimited = errors.New("too many magic link requests, please try again later")
+	ErrMagicLinkDisabled    = errors.New("magic link login is not configured")
+)
+
+// MagicLinkRepository interface for magic link token storage
+type MagicLinkRepository interface {
+	Create(token *model.MagicLinkToken) error
+	FindByTokenHash(tokenHash string) (*model.MagicLinkToken, error)
+	MarkUsed(id string, usedAt time.Time) (bool, error)
+}
+
+// EmailSender delivers transactional emails
+type EmailSender interface {
+	SendMagicLink(email, link string) error
+}
+
+// LogEmailSender writes emails to the log instead of sending them (development only)
+type LogEmailSender struct{}
+
+func (LogEmailSender) SendMagicLink(email, link string) error {
+	slog.Info("Magic link email", "email", email, "link", link)
+	return nil
+}
+
+// DefaultMagicLinkLimiter allows 3 magic link requests per email every 15 minutes
+func DefaultMagicLinkLimiter() *AccountLockout {
+	return NewAttemptLockout("magic_link", nil, fixedLockoutPolicy(3, 15*time.Minute, 15*time.Minute))
+}
+
+// RequestMagicLink emails a single-use login link to the user.
+// It returns nil for unknown emails so callers cannot enumerate accounts.
+func (s *AuthService) RequestMagicLink(email string) error {
+	if s.MagicLinks == nil || s.Mailer == nil {
+		return ErrMagicLinkDisabled
+	}
+
+	// Throttle per email, counting requests for unknown addresses too
+	if s.MagicLinkLimiter != nil {
+		key := strings.ToLower(strings.TrimSpace(email))
+		if s.MagicLinkLimiter.IsLocked(key) {
+			return ErrMagicLinkRateLimited
+		}
+		s.MagicLinkLimiter.RecordFailedAttempt(key)
+	}
+
+	user, err := s.Repo.FindByEmail(email)
+	if err != nil {
+		return nil
+	}
+
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(bytes)
+
+	if err := s.MagicLinks.Create(&model.MagicLinkToken{
+		UserID:    user.ID,
+		TokenHash: hashMagicLinkToken(token),
+		ExpiresAt: time.Now().Add(MagicLinkExpiry),
+	}); err != nil {
+		return err
+	}
+
+	link := s.MagicLinkURL + "?token=" + url.QueryEscape(s.signMagicLinkToken(token))
+	if err := s.Mailer.SendMagicLink(user.Email, link); err != nil {
+		return fmt.Errorf("failed to send magic link: %w", err)
+	}
+	return nil
+}
+
+// VerifyMagicLink consumes a magic link and issues a token pair for its user
+func (s *AuthService) VerifyMagicLink(signedToken string) (*model.User, *TokenPair, error) {
+	if s.MagicLinks == nil {
+		return nil, nil, ErrMagicLinkDisabled
+	}
+
+	token, ok := s.verifyMagicLinkSignature(signedToken)
+	if !ok {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	link, err := s.MagicLinks.FindByTokenHash(hashMagicLinkToken(token))
+	if err != nil {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+	if link.UsedAt != nil || time.Now().After(link.ExpiresAt) {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	consumed, err := s.MagicLinks.MarkUsed(link.ID.String(), time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !consumed {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	user, err := s.Repo.FindByID(link.UserID.String())
+	if err != nil {
+		return nil, nil, ErrMagicLinkInvalid
+	}
+
+	// A successful passwordless login also clears password lockout state
+	if s.AccountLockout != nil {
+		s.AccountLockout.RecordSuccessfulLogin(user.Email)
+	}
+
+	pair, err := s.GenerateTokenPair(user.ID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, pair, nil
+}
+
+// signMagicLinkToken appends an HMAC so tampered links are rejected before a database lookup
+func (s *AuthService) signMagicLinkToken(token string) string {
+	return token + "." + hex.EncodeToString(s.magicLinkMAC(token))
+}
+
+func (s *AuthService) verifyMagicLinkSignature(signedToken string) (string, bool) {
+	token, sig, found := strings.Cut(signedToken, ".")
+	if !found || len(token) != 64 {
+		return "", false
+	}
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return "", false
+	}
+	return token, hmac.Equal(expected, s.magicLinkMAC(token))
+}
+
+func (s *AuthService) magicLinkMAC(token string) []byte {
+	mac := hmac.New(sha256.New, s.JWTSecret)
+	mac.Write([]byte("magic-link:" + token))
+	return mac.Sum(nil)
+}
+
+// hashMagicLinkToken returns the value stored in place of the raw token
+func hashMagicLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockMagicLinkRepository is a mock implementation of MagicLinkRepository
+type MockMagicLinkRepository struct {
+	mock.Mock
+}
+
+func (m *MockMagicLinkRepository) Create(token *model.MagicLinkToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockMagicLinkRepository) FindByTokenHash(tokenHash string) (*model.MagicLinkToken, error) {
+	args := m.Called(tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.MagicLinkToken), args.Error(1)
+}
+
+func (m *MockMagicLinkRepository) MarkUsed(id string, usedAt time.Time) (bool, error) {
+	args := m.Called(id, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+// captureSender records the last magic link sent
+type captureSender struct {
+	email string
+	link  string
+}
+
+func (s *captureSender) SendMagicLink(email, link string) error {
+	s.email = email
+	s.link = link
+	return nil
+}
+
+func newMagicLinkService() (*AuthService, *MockUserRepository, *MockMagicLinkRepository, *captureSender) {
+	userRepo := new(MockUserRepository)
+	linkRepo := new(MockMagicLinkRepository)
+	sender := &captureSender{}
+
+	svc := NewAuthService(userRepo, "secret")
+	svc.MagicLinks = linkRepo
+	svc.Mailer = sender
+	svc.MagicLinkURL = "https://bank.test/auth/magic-link/verify"
+	return svc, userRepo, linkRepo, sender
+}
+
+func linkToken(t *testing.T, link string) string {
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func TestRequestMagicLink_StoresHashedTokenAndSendsLink(t *testing.T) {
+	svc, userRepo, linkRepo, sender := newMagicLinkService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com"}
+
+	var stored *model.MagicLinkToken
+	userRepo.On("FindByEmail", "user@example.com").Return(user, nil)
+	linkRepo.On("Create", mock.AnythingOfType("*model.MagicLinkToken")).
+		Run(func(args mock.Arguments) { stored = args.Get(0).(*model.MagicLinkToken) }).
+		Return(nil)
+
+	err := svc.RequestMagicLink("user@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+
+	assert.Equal(t, "user@example.com", sender.email)
+	assert.True(t, strings.HasPrefix(sender.link, "https://bank.test/auth/magic-link/verify?token="))
+
+	raw, _, _ := strings.Cut(linkToken(t, sender.link), ".")
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.Equal(t, hashMagicLinkToken(raw), stored.TokenHash)
+	assert.NotEqual(t, raw, stored.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(MagicLinkExpiry), stored.ExpiresAt, time.Minute)
+}
+
+func TestRequestMagicLink_UnknownEmailDoesNotReveal(t *testing.T) {
+	svc, userRepo, linkRepo, sender := newMagicLinkService()
+	userRepo.On("FindByEmail", "nobody@example.com").Return(nil, errors.New("not found"))
+
+	err := svc.RequestMagicLink("nobody@example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, sender.link)
+	linkRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestRequestMagicLink_RateLimitedPerEmail(t *testing.T) {
+	svc, userRepo, _, _ := newMagicLinkService()
+	userRepo.On("FindByEmail", mock.Anything).Return(nil, errors.New("not found"))
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, svc.RequestMagicLink("user@example.com"))
+	}
+	assert.ErrorIs(t, svc.RequestMagicLink("USER@example.com"), ErrMagicLinkRateLimited)
+
+	// Other emails are unaffected
+	assert.NoError(t, svc.RequestMagicLink("other@example.com"))
+}
+
+func TestVerifyMagicLink_IssuesTokenPair(t *testing.T) {
+	svc, userRepo, linkRepo, sender := newMagicLinkService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com"}
+
+	var stored *model.MagicLinkToken
+	userRepo.On("FindByEmail", "user@example.com").Return(user, nil)
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+	linkRepo.On("Create", mock.Anything).
+		Run(func(args mock.Arguments) {
+			stored = args.Get(0).(*model.MagicLinkToken)
+			stored.ID = uuid.New()
+		}).
+		Return(nil)
+	require.NoError(t, svc.RequestMagicLink("user@example.com"))
+
+	linkRepo.On("FindByTokenHash", stored.TokenHash).Return(stored, nil)
+	linkRepo.On("MarkUsed", stored.ID.String(), mock.AnythingOfType("time.Time")).Return(true, nil).Once()
+
+	gotUser, pair, err := svc.VerifyMagicLink(linkToken(t, sender.link))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, gotUser.ID)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.Len(t, pair.RefreshToken, 64)
+
+	// A second use of the same link is rejected
+	linkRepo.On("MarkUsed", stored.ID.String(), mock.AnythingOfType("time.Time")).Return(false, nil)
+	_, _, err = svc.VerifyMagicLink(linkToken(t, sender.link))
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid)
+}
+
+func TestVerifyMagicLink_RejectsTamperedAndExpiredLinks(t *testing.T) {
+	svc, _, linkRepo, _ := newMagicLinkService()
+	token := strings.Repeat("a", 64)
+
+	// Bad signature never reaches the database
+	_, _, err := svc.VerifyMagicLink(token + ".deadbeef")
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid)
+	_, _, err = svc.VerifyMagicLink(token)
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid)
+	linkRepo.AssertNotCalled(t, "FindByTokenHash", mock.Anything)
+
+	expired := &model.MagicLinkToken{ID: uuid.New(), ExpiresAt: time.Now().Add(-time.Minute)}
+	linkRepo.On("FindByTokenHash", hashMagicLinkToken(token)).Return(expired, nil)
+
+	_, _, err = svc.VerifyMagicLink(svc.signMagicLinkToken(token))
+	assert.ErrorIs(t, err, ErrMagicLinkInvalid)
+	linkRepo.AssertNotCalled(t, "MarkUsed", mock.Anything, mock.Anything)
+}
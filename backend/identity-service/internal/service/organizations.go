@@ -0,0 +1,271 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrInvalidOrgName       = errors.New("organization name is required")
+	ErrInvalidOrgRole       = errors.New("role must be OWNER, ADMIN, MEMBER or VIEWER")
+	ErrOrgForbidden         = errors.New("your role in this organization does not allow this")
+	ErrInviteeNotFound      = errors.New("no user is registered with that email")
+	ErrAlreadyMember        = errors.New("user is already a member or has a pending invitation")
+	ErrInvitationNotFound   = errors.New("no pending invitation to this organization")
+	ErrMemberNotFound       = errors.New("member not found")
+	ErrLastOwner            = errors.New("an organization must keep at least one owner")
+)
+
+// OrganizationRepository stores organizations and their members
+type OrganizationRepository interface {
+	CreateOrganization(org *model.Organization, owner *model.OrganizationMember) error
+	GetOrganization(id uuid.UUID) (*model.Organization, error)
+	GetMember(orgID, userID uuid.UUID) (*model.OrganizationMember, error)
+	CreateMember(member *model.OrganizationMember) error
+	UpdateMember(member *model.OrganizationMember) error
+	DeleteMember(id uuid.UUID) error
+	ListMembers(orgID uuid.UUID) ([]model.OrganizationMember, error)
+	ListMemberships(userID uuid.UUID) ([]model.Membership, error)
+}
+
+// OrganizationService manages organizations, their members, and the tokens
+// members use to act for an organization
+type OrganizationService struct {
+	Repo      OrganizationRepository
+	Users     UserRepository
+	JWTSecret []byte
+	// Audiences are stamped on organization tokens, which are user tokens
+	Audiences TokenAudiences
+	// Terms flags the tokens of members who have not accepted the current
+	// mandatory terms; nil issues unflagged tokens
+	Terms *TermsService
+}
+
+func NewOrganizationService(repo OrganizationRepository, users UserRepository, secret string) *OrganizationService {
+	return &OrganizationService{Repo: repo, Users: users, JWTSecret: []byte(secret)}
+}
+
+// OrganizationToken is an access token scoped to one organization
+type OrganizationToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	OrgID       string `json:"org_id"`
+	OrgRole     string `json:"org_role"`
+}
+
+// CreateOrganization creates an organization with the user as its owner
+func (s *OrganizationService) CreateOrganization(userID, name string) (*model.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidOrgName
+	}
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+
+	now := time.Now()
+	org := &model.Organization{Name: name, CreatedBy: user.ID}
+	owner := &model.OrganizationMember{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Role:     tenant.RoleOwner,
+		Status:   model.MembershipActive,
+		JoinedAt: &now,
+	}
+	if err := s.Repo.CreateOrganization(org, owner); err != nil {
+		return nil, err
+	}
+	return org, nil
+}
+
+// ListMemberships returns the organizations the user belongs to or is invited to
+func (s *OrganizationService) ListMemberships(userID string) ([]model.Membership, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	return s.Repo.ListMemberships(id)
+}
+
+// InviteMember invites a registered user to the organization. Owners can
+// invite any role; admins can invite members and viewers.
+func (s *OrganizationService) InviteMember(inviterID, orgID, email, role string) (*model.OrganizationMember, error) {
+	if !tenant.IsValidRole(role) {
+		return nil, ErrInvalidOrgRole
+	}
+	inviter, err := s.activeMember(orgID, inviterID)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(inviter.Role, role) {
+		return nil, ErrOrgForbidden
+	}
+
+	invitee, err := s.Users.FindByEmail(strings.TrimSpace(email))
+	if err != nil {
+		return nil, ErrInviteeNotFound
+	}
+	member := &model.OrganizationMember{
+		OrgID:     inviter.OrgID,
+		UserID:    invitee.ID,
+		Email:     invitee.Email,
+		Role:      role,
+		Status:    model.MembershipInvited,
+		InvitedBy: &inviter.UserID,
+	}
+	if err := s.Repo.CreateMember(member); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrAlreadyMember
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+// AcceptInvitation makes the user an active member of the organization
+func (s *OrganizationService) AcceptInvitation(userID, orgID string) (*model.OrganizationMember, error) {
+	member, err := s.member(orgID, userID)
+	if err != nil || member.Status != model.MembershipInvited {
+		return nil, ErrInvitationNotFound
+	}
+	now := time.Now()
+	member.Status = model.MembershipActive
+	member.JoinedAt = &now
+	if err := s.Repo.UpdateMember(member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// ListMembers returns the organization's members and pending invitations.
+// Only active members can see them.
+func (s *OrganizationService) ListMembers(userID, orgID string) ([]model.OrganizationMember, error) {
+	caller, err := s.activeMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListMembers(caller.OrgID)
+}
+
+// RemoveMember removes a member or withdraws an invitation. Members can always
+// leave; otherwise the same rules as for inviting apply. The last owner stays.
+func (s *OrganizationService) RemoveMember(callerID, orgID, memberUserID string) error {
+	caller, err := s.activeMember(orgID, callerID)
+	if err != nil {
+		return err
+	}
+	target, err := s.member(orgID, memberUserID)
+	if err != nil {
+		return ErrMemberNotFound
+	}
+	if target.UserID != caller.UserID && !canManage(caller.Role, target.Role) {
+		return ErrOrgForbidden
+	}
+
+	if target.Role == tenant.RoleOwner && target.Status == model.MembershipActive {
+		members, err := s.Repo.ListMembers(target.OrgID)
+		if err != nil {
+			return err
+		}
+		owners := 0
+		for _, m := range members {
+			if m.Role == tenant.RoleOwner && m.Status == model.MembershipActive {
+				owners++
+			}
+		}
+		if owners <= 1 {
+			return ErrLastOwner
+		}
+	}
+	return s.Repo.DeleteMember(target.ID)
+}
+
+// IssueToken returns an access token for acting within the organization. It
+// carries the member's org_id and org_role alongside the usual user claims.
+func (s *OrganizationService) IssueToken(userID, orgID string) (*OrganizationToken, error) {
+	member, err := s.activeMember(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, s.Audiences.stamp(s.Terms.stamp(jwt.MapClaims{
+		"user_id":  user.ID.String(),
+		"email":    user.Email,
+		"role":     user.Role,
+		"org_id":   member.OrgID.String(),
+		"org_role": member.Role,
+		"iat":      now.Unix(),
+		"exp":      now.Add(AccessTokenExpiry).Unix(),
+	}, user.ID.String()), s.Audiences.User))
+	signed, err := token.SignedString(s.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &OrganizationToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(AccessTokenExpiry.Seconds()),
+		OrgID:       member.OrgID.String(),
+		OrgRole:     member.Role,
+	}, nil
+}
+
+// member looks up the user's membership; unknown organizations and users are not found
+func (s *OrganizationService) member(orgID, userID string) (*model.OrganizationMember, error) {
+	orgUUID, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrOrganizationNotFound
+	}
+	member, err := s.Repo.GetMember(orgUUID, userUUID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return member, nil
+}
+
+// activeMember is member for users who have accepted their invitation. Other
+// users get ErrOrganizationNotFound so they cannot probe for organizations.
+func (s *OrganizationService) activeMember(orgID, userID string) (*model.OrganizationMember, error) {
+	member, err := s.member(orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member.Status != model.MembershipActive {
+		return nil, ErrOrganizationNotFound
+	}
+	return member, nil
+}
+
+// canManage reports whether a member with role can invite or remove a member with target role
+func canManage(role, target string) bool {
+	switch role {
+	case tenant.RoleOwner:
+		return true
+	case tenant.RoleAdmin:
+		return target == tenant.RoleMember || target == tenant.RoleViewer
+	default:
+		return false
+	}
+}
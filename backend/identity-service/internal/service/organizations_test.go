@@ -0,0 +1,199 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryOrganizationRepository is an in-memory OrganizationRepository
+type memoryOrganizationRepository struct {
+	orgs    map[uuid.UUID]model.Organization
+	members map[uuid.UUID]model.OrganizationMember
+}
+
+func newMemoryOrganizationRepository() *memoryOrganizationRepository {
+	return &memoryOrganizationRepository{
+		orgs:    map[uuid.UUID]model.Organization{},
+		members: map[uuid.UUID]model.OrganizationMember{},
+	}
+}
+
+func (r *memoryOrganizationRepository) CreateOrganization(org *model.Organization, owner *model.OrganizationMember) error {
+	org.ID = uuid.New()
+	r.orgs[org.ID] = *org
+	owner.OrgID = org.ID
+	return r.CreateMember(owner)
+}
+
+func (r *memoryOrganizationRepository) GetOrganization(id uuid.UUID) (*model.Organization, error) {
+	org, ok := r.orgs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &org, nil
+}
+
+func (r *memoryOrganizationRepository) GetMember(orgID, userID uuid.UUID) (*model.OrganizationMember, error) {
+	for _, m := range r.members {
+		if m.OrgID == orgID && m.UserID == userID {
+			return &m, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryOrganizationRepository) CreateMember(member *model.OrganizationMember) error {
+	if _, err := r.GetMember(member.OrgID, member.UserID); err == nil {
+		return gorm.ErrDuplicatedKey
+	}
+	member.ID = uuid.New()
+	r.members[member.ID] = *member
+	return nil
+}
+
+func (r *memoryOrganizationRepository) UpdateMember(member *model.OrganizationMember) error {
+	r.members[member.ID] = *member
+	return nil
+}
+
+func (r *memoryOrganizationRepository) DeleteMember(id uuid.UUID) error {
+	delete(r.members, id)
+	return nil
+}
+
+func (r *memoryOrganizationRepository) ListMembers(orgID uuid.UUID) ([]model.OrganizationMember, error) {
+	var members []model.OrganizationMember
+	for _, m := range r.members {
+		if m.OrgID == orgID {
+			members = append(members, m)
+		}
+	}
+	return members, nil
+}
+
+func (r *memoryOrganizationRepository) ListMemberships(userID uuid.UUID) ([]model.Membership, error) {
+	var memberships []model.Membership
+	for _, m := range r.members {
+		if m.UserID == userID {
+			memberships = append(memberships, model.Membership{Organization: r.orgs[m.OrgID], Role: m.Role, Status: m.Status})
+		}
+	}
+	return memberships, nil
+}
+
+// orgTestUsers registers users with the mock user repository by ID and email
+func orgTestUsers(repo *MockUserRepository, emails ...string) []*model.User {
+	users := make([]*model.User, len(emails))
+	for i, email := range emails {
+		users[i] = &model.User{ID: uuid.New(), Email: email, Role: "customer"}
+		repo.On("FindByID", users[i].ID.String()).Return(users[i], nil)
+		repo.On("FindByEmail", email).Return(users[i], nil)
+	}
+	return users
+}
+
+// addMember invites the user with role and accepts the invitation
+func addMember(t *testing.T, svc *OrganizationService, inviter, user *model.User, orgID, role string) {
+	t.Helper()
+	_, err := svc.InviteMember(inviter.ID.String(), orgID, user.Email, role)
+	require.NoError(t, err)
+	_, err = svc.AcceptInvitation(user.ID.String(), orgID)
+	require.NoError(t, err)
+}
+
+func TestOrganizations_InviteAndAccept(t *testing.T) {
+	users := new(MockUserRepository)
+	svc := NewOrganizationService(newMemoryOrganizationRepository(), users, serviceTestSecret)
+	u := orgTestUsers(users, "owner@acme.test", "clerk@acme.test")
+	owner, clerk := u[0], u[1]
+
+	org, err := svc.CreateOrganization(owner.ID.String(), " Acme Ltd ")
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Ltd", org.Name)
+	orgID := org.ID.String()
+
+	invite, err := svc.InviteMember(owner.ID.String(), orgID, clerk.Email, tenant.RoleMember)
+	require.NoError(t, err)
+	assert.Equal(t, model.MembershipInvited, invite.Status)
+	_, err = svc.InviteMember(owner.ID.String(), orgID, clerk.Email, tenant.RoleViewer)
+	assert.ErrorIs(t, err, ErrAlreadyMember)
+
+	// Invited users cannot act for the organization until they accept
+	_, err = svc.IssueToken(clerk.ID.String(), orgID)
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+
+	member, err := svc.AcceptInvitation(clerk.ID.String(), orgID)
+	require.NoError(t, err)
+	assert.Equal(t, model.MembershipActive, member.Status)
+	assert.NotNil(t, member.JoinedAt)
+	_, err = svc.AcceptInvitation(clerk.ID.String(), orgID)
+	assert.ErrorIs(t, err, ErrInvitationNotFound)
+
+	memberships, err := svc.ListMemberships(clerk.ID.String())
+	require.NoError(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, "Acme Ltd", memberships[0].Organization.Name)
+	assert.Equal(t, tenant.RoleMember, memberships[0].Role)
+}
+
+func TestOrganizations_RoleRules(t *testing.T) {
+	users := new(MockUserRepository)
+	svc := NewOrganizationService(newMemoryOrganizationRepository(), users, serviceTestSecret)
+	u := orgTestUsers(users, "owner@acme.test", "admin@acme.test", "member@acme.test", "new@acme.test")
+	owner, admin, member, newcomer := u[0], u[1], u[2], u[3]
+
+	org, err := svc.CreateOrganization(owner.ID.String(), "Acme Ltd")
+	require.NoError(t, err)
+	orgID := org.ID.String()
+	addMember(t, svc, owner, admin, orgID, tenant.RoleAdmin)
+	addMember(t, svc, admin, member, orgID, tenant.RoleMember)
+
+	_, err = svc.InviteMember(admin.ID.String(), orgID, newcomer.Email, tenant.RoleOwner)
+	assert.ErrorIs(t, err, ErrOrgForbidden)
+	_, err = svc.InviteMember(member.ID.String(), orgID, newcomer.Email, tenant.RoleViewer)
+	assert.ErrorIs(t, err, ErrOrgForbidden)
+	_, err = svc.InviteMember(owner.ID.String(), orgID, newcomer.Email, "SUPERUSER")
+	assert.ErrorIs(t, err, ErrInvalidOrgRole)
+	_, err = svc.InviteMember(newcomer.ID.String(), orgID, member.Email, tenant.RoleViewer)
+	assert.ErrorIs(t, err, ErrOrganizationNotFound)
+
+	assert.ErrorIs(t, svc.RemoveMember(admin.ID.String(), orgID, owner.ID.String()), ErrOrgForbidden)
+	assert.ErrorIs(t, svc.RemoveMember(owner.ID.String(), orgID, owner.ID.String()), ErrLastOwner)
+	// Members can always leave
+	require.NoError(t, svc.RemoveMember(member.ID.String(), orgID, member.ID.String()))
+	require.NoError(t, svc.RemoveMember(owner.ID.String(), orgID, admin.ID.String()))
+
+	members, err := svc.ListMembers(owner.ID.String(), orgID)
+	require.NoError(t, err)
+	assert.Len(t, members, 1)
+}
+
+func TestOrganizations_IssueTokenCarriesOrgClaims(t *testing.T) {
+	users := new(MockUserRepository)
+	svc := NewOrganizationService(newMemoryOrganizationRepository(), users, serviceTestSecret)
+	owner := orgTestUsers(users, "owner@acme.test")[0]
+
+	org, err := svc.CreateOrganization(owner.ID.String(), "Acme Ltd")
+	require.NoError(t, err)
+
+	issued, err := svc.IssueToken(owner.ID.String(), org.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, tenant.RoleOwner, issued.OrgRole)
+
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(issued.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(serviceTestSecret), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, owner.ID.String(), claims.UserID)
+	assert.Equal(t, org.ID.String(), claims.OrgID)
+	assert.Equal(t, tenant.RoleOwner, claims.OrgRole)
+}
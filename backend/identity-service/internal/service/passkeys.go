@@ -0,0 +1,502 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+	"gorm.io/gorm"
+)
+
+// WebAuthnCeremonyExpiry is how long a registration or login challenge can be answered
+const WebAuthnCeremonyExpiry = 5 * time.Minute
+
+// maxPasskeyNameLength bounds the label a user gives a passkey
+const maxPasskeyNameLength = 100
+
+var (
+	ErrPasskeysDisabled = errors.New("passkeys are not configured")
+	ErrPasskeyInvalid   = errors.New("passkey verification failed")
+	ErrPasskeyExists    = errors.New("passkey is already registered")
+	ErrNoPasskeys       = errors.New("no passkeys registered for this account")
+)
+
+// PasskeyRepository stores passkeys and pending WebAuthn ceremonies
+type PasskeyRepository interface {
+	CreateCredential(credential *model.PasskeyCredential) error
+	FindCredential(credentialID string) (*model.PasskeyCredential, error)
+	ListCredentials(userID string) ([]model.PasskeyCredential, error)
+	UpdateSignCount(id string, previous, signCount int64, usedAt time.Time) (bool, error)
+	CreateCeremony(ceremony *model.WebAuthnCeremony) error
+	TakeCeremony(challenge string) (*model.WebAuthnCeremony, error)
+}
+
+// PasskeyRelyingParty identifies the relying party to the authenticator
+type PasskeyRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// PasskeyUser is the account a new passkey is created for; ID is the base64url
+// user UUID and comes back as the userHandle of discoverable logins
+type PasskeyUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PasskeyCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type PasskeyCredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type PasskeyAuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// PasskeyCreationOptions are the publicKey options for navigator.credentials.create
+type PasskeyCreationOptions struct {
+	RP                     PasskeyRelyingParty           `json:"rp"`
+	User                   PasskeyUser                   `json:"user"`
+	Challenge              string                        `json:"challenge"`
+	PubKeyCredParams       []PasskeyCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                         `json:"timeout"`
+	ExcludeCredentials     []PasskeyCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection PasskeyAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                        `json:"attestation"`
+}
+
+// PasskeyRequestOptions are the publicKey options for navigator.credentials.get
+type PasskeyRequestOptions struct {
+	Challenge        string                        `json:"challenge"`
+	Timeout          int64                         `json:"timeout"`
+	RPID             string                        `json:"rpId"`
+	AllowCredentials []PasskeyCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                        `json:"userVerification"`
+}
+
+// PasskeyAttestation is the PublicKeyCredential returned by navigator.credentials.create,
+// with binary fields base64url encoded
+type PasskeyAttestation struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId" binding:"required"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON" binding:"required"`
+		AttestationObject string   `json:"attestationObject" binding:"required"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// PasskeyAssertion is the PublicKeyCredential returned by navigator.credentials.get,
+// with binary fields base64url encoded
+type PasskeyAssertion struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId" binding:"required"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON" binding:"required"`
+		AuthenticatorData string `json:"authenticatorData" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+		UserHandle        string `json:"userHandle"`
+	} `json:"response"`
+}
+
+// PasskeyLoginResult is the outcome of a passkey login. A passkey that completes
+// a flagged password login sets StepUp and Token, like VerifyLoginChallenge;
+// a passwordless login sets Tokens, like VerifyMagicLink.
+type PasskeyLoginResult struct {
+	User   *model.User
+	StepUp bool
+	Token  string
+	Tokens *TokenPair
+}
+
+func (s *AuthService) passkeysEnabled() bool {
+	return s.Passkeys != nil && s.WebAuthn != nil
+}
+
+// BeginPasskeyRegistration starts adding a passkey to the user's account
+func (s *AuthService) BeginPasskeyRegistration(userID string) (*PasskeyCreationOptions, error) {
+	if !s.passkeysEnabled() {
+		return nil, ErrPasskeysDisabled
+	}
+
+	user, err := s.Repo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.Passkeys.ListCredentials(userID)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := s.createCeremony(model.WebAuthnRegistration, &user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	displayName := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if displayName == "" {
+		displayName = user.Email
+	}
+	return &PasskeyCreationOptions{
+		RP:        PasskeyRelyingParty{ID: s.WebAuthn.RPID, Name: s.WebAuthn.RPName},
+		User:      PasskeyUser{ID: encodeBase64URL(user.ID[:]), Name: user.Email, DisplayName: displayName},
+		Challenge: challenge,
+		PubKeyCredParams: []PasskeyCredentialParameter{
+			{Type: "public-key", Alg: COSEAlgES256},
+			{Type: "public-key", Alg: COSEAlgEdDSA},
+			{Type: "public-key", Alg: COSEAlgRS256},
+		},
+		Timeout:            WebAuthnCeremonyExpiry.Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(existing),
+		AuthenticatorSelection: PasskeyAuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "direct",
+	}, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation and stores
+// the new passkey's public key
+func (s *AuthService) FinishPasskeyRegistration(userID, name string, att PasskeyAttestation) (*model.PasskeyCredential, error) {
+	if !s.passkeysEnabled() {
+		return nil, ErrPasskeysDisabled
+	}
+
+	rawClientData, err := decodeBase64URL(att.Response.ClientDataJSON)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed client data", ErrPasskeyInvalid)
+	}
+	cd, err := s.WebAuthn.parseClientData(rawClientData, "webauthn.create")
+	if err != nil {
+		return nil, err
+	}
+	ceremony, err := s.takeCeremony(cd.Challenge, model.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.UserID == nil || ceremony.UserID.String() != userID {
+		return nil, fmt.Errorf("%w: challenge was issued to another user", ErrPasskeyInvalid)
+	}
+
+	rawObject, err := decodeBase64URL(att.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrPasskeyInvalid)
+	}
+	var obj attestationObject
+	if err := codec.NewDecoderBytes(rawObject, cborHandle()).Decode(&obj); err != nil {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrPasskeyInvalid)
+	}
+	ad, err := parseAuthenticatorData(obj.AuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.WebAuthn.checkRP(ad, false); err != nil {
+		return nil, err
+	}
+	if ad.CredentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrPasskeyInvalid)
+	}
+	if rawID, err := decodeBase64URL(att.RawID); err != nil || !bytes.Equal(rawID, ad.CredentialID) {
+		return nil, fmt.Errorf("%w: credential ID mismatch", ErrPasskeyInvalid)
+	}
+
+	key, err := parseCOSEKey(ad.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	attestationType, err := verifyAttestation(&obj, ad, key, clientDataHash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > maxPasskeyNameLength {
+		name = name[:maxPasskeyNameLength]
+	}
+	credential := &model.PasskeyCredential{
+		UserID:          *ceremony.UserID,
+		CredentialID:    encodeBase64URL(ad.CredentialID),
+		PublicKey:       ad.PublicKey,
+		Algorithm:       key.Algorithm,
+		SignCount:       int64(ad.SignCount),
+		AAGUID:          formatAAGUID(ad.AAGUID),
+		AttestationType: attestationType,
+		Transports:      att.Response.Transports,
+		Name:            name,
+	}
+	if err := s.Passkeys.CreateCredential(credential); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrPasskeyExists
+		}
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginPasskeyLogin starts a passkey login. With loginChallengeID the passkey
+// answers the step-up challenge of a flagged password login instead of an
+// emailed code. Otherwise it is a passwordless login, which requires user
+// verification; an email limits it to that user's passkeys, and without one
+// the authenticator offers its discoverable passkeys. Unknown emails get the
+// discoverable options too, so the response does not reveal which emails exist.
+func (s *AuthService) BeginPasskeyLogin(email, loginChallengeID string) (*PasskeyRequestOptions, error) {
+	if !s.passkeysEnabled() {
+		return nil, ErrPasskeysDisabled
+	}
+
+	var (
+		userID      *uuid.UUID
+		stepUp      *uuid.UUID
+		credentials []model.PasskeyCredential
+	)
+	switch {
+	case loginChallengeID != "":
+		if s.LoginActivity == nil {
+			return nil, ErrLoginChallengeInvalid
+		}
+		challenge, err := s.LoginActivity.FindChallenge(loginChallengeID)
+		if err != nil || challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) {
+			return nil, ErrLoginChallengeInvalid
+		}
+		if credentials, err = s.Passkeys.ListCredentials(challenge.UserID.String()); err != nil {
+			return nil, err
+		}
+		if len(credentials) == 0 {
+			return nil, ErrNoPasskeys
+		}
+		userID, stepUp = &challenge.UserID, &challenge.ID
+	case email != "":
+		if user, err := s.Repo.FindByEmail(email); err == nil {
+			if credentials, err = s.Passkeys.ListCredentials(user.ID.String()); err != nil {
+				return nil, err
+			}
+			if len(credentials) > 0 {
+				userID = &user.ID
+			}
+		}
+	}
+
+	challenge, err := s.createCeremony(model.WebAuthnLogin, userID, stepUp)
+	if err != nil {
+		return nil, err
+	}
+	userVerification := "required"
+	if stepUp != nil {
+		// The password was the first factor, so presence is enough
+		userVerification = "preferred"
+	}
+	return &PasskeyRequestOptions{
+		Challenge:        challenge,
+		Timeout:          WebAuthnCeremonyExpiry.Milliseconds(),
+		RPID:             s.WebAuthn.RPID,
+		AllowCredentials: credentialDescriptors(credentials),
+		UserVerification: userVerification,
+	}, nil
+}
+
+// FinishPasskeyLogin verifies a passkey assertion and signs the user in
+func (s *AuthService) FinishPasskeyLogin(assertion PasskeyAssertion) (*PasskeyLoginResult, error) {
+	result, err := s.finishPasskeyLogin(assertion)
+	if errors.Is(err, ErrPasskeyInvalid) {
+		s.recordSignal(SignalMFAFailure)
+	}
+	return result, err
+}
+
+func (s *AuthService) finishPasskeyLogin(assertion PasskeyAssertion) (*PasskeyLoginResult, error) {
+	if !s.passkeysEnabled() {
+		return nil, ErrPasskeysDisabled
+	}
+	user, ceremony, err := s.verifyPasskeyAssertion(assertion)
+	if err != nil {
+		return nil, err
+	}
+
+	if ceremony.LoginChallengeID != nil {
+		return s.completeStepUpWithPasskey(user, ceremony.LoginChallengeID.String())
+	}
+
+	// A successful passwordless login also clears password lockout state
+	if s.AccountLockout != nil {
+		s.AccountLockout.RecordSuccessfulLogin(user.Email)
+	}
+	pair, err := s.GenerateTokenPair(user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyLoginResult{User: user, Tokens: pair}, nil
+}
+
+// verifyPasskeyAssertion checks a passkey assertion against the login ceremony
+// it answers, and returns the passkey's user and that ceremony
+func (s *AuthService) verifyPasskeyAssertion(assertion PasskeyAssertion) (*model.User, *model.WebAuthnCeremony, error) {
+	rawClientData, err := decodeBase64URL(assertion.Response.ClientDataJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed client data", ErrPasskeyInvalid)
+	}
+	cd, err := s.WebAuthn.parseClientData(rawClientData, "webauthn.get")
+	if err != nil {
+		return nil, nil, err
+	}
+	ceremony, err := s.takeCeremony(cd.Challenge, model.WebAuthnLogin)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawID, err := decodeBase64URL(assertion.RawID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed credential ID", ErrPasskeyInvalid)
+	}
+	credential, err := s.Passkeys.FindCredential(encodeBase64URL(rawID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unknown credential", ErrPasskeyInvalid)
+	}
+	if ceremony.UserID != nil && *ceremony.UserID != credential.UserID {
+		return nil, nil, fmt.Errorf("%w: credential belongs to another user", ErrPasskeyInvalid)
+	}
+	// Discoverable logins identify the user by the handle stored with the passkey
+	if assertion.Response.UserHandle != "" || ceremony.UserID == nil {
+		handle, err := decodeBase64URL(assertion.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, credential.UserID[:]) {
+			return nil, nil, fmt.Errorf("%w: user handle mismatch", ErrPasskeyInvalid)
+		}
+	}
+
+	authData, err := decodeBase64URL(assertion.Response.AuthenticatorData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed authenticator data", ErrPasskeyInvalid)
+	}
+	ad, err := parseAuthenticatorData(authData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.WebAuthn.checkRP(ad, ceremony.LoginChallengeID == nil); err != nil {
+		return nil, nil, err
+	}
+	signature, err := decodeBase64URL(assertion.Response.Signature)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed signature", ErrPasskeyInvalid)
+	}
+	key, err := parseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	if err := key.verify(append(authData, clientDataHash[:]...), signature); err != nil {
+		return nil, nil, err
+	}
+
+	// A counter that did not increase suggests a cloned authenticator;
+	// authenticators that do not count always report zero
+	signCount := int64(ad.SignCount)
+	if (signCount != 0 || credential.SignCount != 0) && signCount <= credential.SignCount {
+		return nil, nil, fmt.Errorf("%w: signature counter did not increase", ErrPasskeyInvalid)
+	}
+	updated, err := s.Passkeys.UpdateSignCount(credential.ID.String(), credential.SignCount, signCount, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !updated {
+		return nil, nil, fmt.Errorf("%w: passkey was used concurrently", ErrPasskeyInvalid)
+	}
+
+	user, err := s.Repo.FindByID(credential.UserID.String())
+	if err != nil {
+		return nil, nil, ErrPasskeyInvalid
+	}
+	return user, ceremony, nil
+}
+
+// completeStepUpWithPasskey consumes the flagged login's challenge, as a correct
+// emailed code would
+func (s *AuthService) completeStepUpWithPasskey(user *model.User, loginChallengeID string) (*PasskeyLoginResult, error) {
+	if s.LoginActivity == nil {
+		return nil, ErrLoginChallengeInvalid
+	}
+	challenge, err := s.LoginActivity.FindChallenge(loginChallengeID)
+	if err != nil || challenge.UserID != user.ID {
+		return nil, ErrLoginChallengeInvalid
+	}
+	if time.Now().After(challenge.ExpiresAt) {
+		return nil, ErrLoginChallengeInvalid
+	}
+	consumed, err := s.LoginActivity.MarkChallengeUsed(loginChallengeID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrLoginChallengeInvalid
+	}
+	if err := s.LoginActivity.UpdateEventOutcome(challenge.LoginEventID.String(), model.LoginStepUpPassed); err != nil {
+		return nil, err
+	}
+
+	token, err := s.issueLoginToken(user)
+	if err != nil {
+		return nil, err
+	}
+	return &PasskeyLoginResult{User: user, StepUp: true, Token: token}, nil
+}
+
+// createCeremony stores a fresh challenge for a registration or login
+func (s *AuthService) createCeremony(purpose string, userID, loginChallengeID *uuid.UUID) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	challenge := encodeBase64URL(b)
+	if err := s.Passkeys.CreateCeremony(&model.WebAuthnCeremony{
+		Challenge:        challenge,
+		Purpose:          purpose,
+		UserID:           userID,
+		LoginChallengeID: loginChallengeID,
+		ExpiresAt:        time.Now().Add(WebAuthnCeremonyExpiry),
+	}); err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// takeCeremony consumes the ceremony for a challenge signed by the authenticator
+func (s *AuthService) takeCeremony(challenge, purpose string) (*model.WebAuthnCeremony, error) {
+	ceremony, err := s.Passkeys.TakeCeremony(challenge)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown or used challenge", ErrPasskeyInvalid)
+		}
+		return nil, err
+	}
+	if ceremony.Purpose != purpose || time.Now().After(ceremony.ExpiresAt) {
+		return nil, fmt.Errorf("%w: expired challenge", ErrPasskeyInvalid)
+	}
+	return ceremony, nil
+}
+
+func credentialDescriptors(credentials []model.PasskeyCredential) []PasskeyCredentialDescriptor {
+	descriptors := make([]PasskeyCredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		descriptors = append(descriptors, PasskeyCredentialDescriptor{Type: "public-key", ID: c.CredentialID, Transports: c.Transports})
+	}
+	return descriptors
+}
@@ -0,0 +1,384 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"gorm.io/gorm"
+)
+
+const (
+	testRPID   = "bank.test"
+	testOrigin = "https://app.bank.test"
+)
+
+// memoryPasskeyRepository is an in-memory PasskeyRepository
+type memoryPasskeyRepository struct {
+	mu          sync.Mutex
+	credentials map[string]*model.PasskeyCredential
+	ceremonies  map[string]*model.WebAuthnCeremony
+}
+
+func newMemoryPasskeyRepository() *memoryPasskeyRepository {
+	return &memoryPasskeyRepository{
+		credentials: map[string]*model.PasskeyCredential{},
+		ceremonies:  map[string]*model.WebAuthnCeremony{},
+	}
+}
+
+func (r *memoryPasskeyRepository) CreateCredential(credential *model.PasskeyCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.credentials[credential.CredentialID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	credential.ID = uuid.New()
+	credential.CreatedAt = time.Now()
+	stored := *credential
+	r.credentials[credential.CredentialID] = &stored
+	return nil
+}
+
+func (r *memoryPasskeyRepository) FindCredential(credentialID string) (*model.PasskeyCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	credential, ok := r.credentials[credentialID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *credential
+	return &found, nil
+}
+
+func (r *memoryPasskeyRepository) ListCredentials(userID string) ([]model.PasskeyCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var credentials []model.PasskeyCredential
+	for _, c := range r.credentials {
+		if c.UserID.String() == userID {
+			credentials = append(credentials, *c)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *memoryPasskeyRepository) UpdateSignCount(id string, previous, signCount int64, usedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.credentials {
+		if c.ID.String() == id && c.SignCount == previous {
+			c.SignCount = signCount
+			c.LastUsedAt = &usedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryPasskeyRepository) CreateCeremony(ceremony *model.WebAuthnCeremony) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := *ceremony
+	r.ceremonies[ceremony.Challenge] = &stored
+	return nil
+}
+
+func (r *memoryPasskeyRepository) TakeCeremony(challenge string) (*model.WebAuthnCeremony, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ceremony, ok := r.ceremonies[challenge]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.ceremonies, challenge)
+	return ceremony, nil
+}
+
+// softAuthenticator is a P-256 software authenticator holding one passkey
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	_, err = rand.Read(id)
+	require.NoError(t, err)
+	return &softAuthenticator{key: key, credentialID: id}
+}
+
+func cborEncode(t *testing.T, v interface{}) []byte {
+	var out []byte
+	require.NoError(t, codec.NewEncoderBytes(&out, cborHandle()).Encode(v))
+	return out
+}
+
+func (a *softAuthenticator) authData(t *testing.T, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // Zero AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, cborEncode(t, map[int]interface{}{
+			1: 2, 3: COSEAlgES256, -1: 1,
+			-2: a.key.X.FillBytes(make([]byte, 32)),
+			-3: a.key.Y.FillBytes(make([]byte, 32)),
+		})...)
+	}
+	return data
+}
+
+func clientDataJSON(t *testing.T, ceremonyType, challenge, origin string) []byte {
+	raw, err := json.Marshal(map[string]string{"type": ceremonyType, "challenge": challenge, "origin": origin})
+	require.NoError(t, err)
+	return raw
+}
+
+func (a *softAuthenticator) sign(t *testing.T, authData, rawClientData []byte) []byte {
+	clientDataHash := sha256.Sum256(rawClientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	return sig
+}
+
+// create answers navigator.credentials.create with packed self attestation
+func (a *softAuthenticator) create(t *testing.T, opts *PasskeyCreationOptions) PasskeyAttestation {
+	return a.createSignedBy(t, opts, a)
+}
+
+// createSignedBy attests a's credential with signer's key
+func (a *softAuthenticator) createSignedBy(t *testing.T, opts *PasskeyCreationOptions, signer *softAuthenticator) PasskeyAttestation {
+	a.userHandle, _ = decodeBase64URL(opts.User.ID)
+	rawClientData := clientDataJSON(t, "webauthn.create", opts.Challenge, testOrigin)
+	authData := a.authData(t, authFlagUserPresent|authFlagUserVerified|authFlagAttestedData, true)
+
+	var att PasskeyAttestation
+	att.RawID = encodeBase64URL(a.credentialID)
+	att.Response.ClientDataJSON = encodeBase64URL(rawClientData)
+	att.Response.AttestationObject = encodeBase64URL(cborEncode(t, attestationObject{
+		Format:   "packed",
+		AttStmt:  map[string]interface{}{"alg": COSEAlgES256, "sig": signer.sign(t, authData, rawClientData)},
+		AuthData: authData,
+	}))
+	att.Response.Transports = []string{"internal"}
+	return att
+}
+
+// get answers navigator.credentials.get, counting the signature
+func (a *softAuthenticator) get(t *testing.T, challenge, origin string, flags byte) PasskeyAssertion {
+	a.signCount++
+	rawClientData := clientDataJSON(t, "webauthn.get", challenge, origin)
+	authData := a.authData(t, flags, false)
+
+	var assertion PasskeyAssertion
+	assertion.RawID = encodeBase64URL(a.credentialID)
+	assertion.Response.ClientDataJSON = encodeBase64URL(rawClientData)
+	assertion.Response.AuthenticatorData = encodeBase64URL(authData)
+	assertion.Response.Signature = encodeBase64URL(a.sign(t, authData, rawClientData))
+	assertion.Response.UserHandle = encodeBase64URL(a.userHandle)
+	return assertion
+}
+
+func newPasskeyService() (*AuthService, *MockUserRepository, *memoryPasskeyRepository) {
+	userRepo := new(MockUserRepository)
+	passkeys := newMemoryPasskeyRepository()
+	svc := NewAuthService(userRepo, "secret")
+	svc.Passkeys = passkeys
+	svc.WebAuthn = &WebAuthnConfig{RPID: testRPID, RPName: "Bank", Origins: []string{testOrigin}}
+	return svc, userRepo, passkeys
+}
+
+// registerPasskey runs a registration ceremony for user
+func registerPasskey(t *testing.T, svc *AuthService, user *model.User) *softAuthenticator {
+	opts, err := svc.BeginPasskeyRegistration(user.ID.String())
+	require.NoError(t, err)
+	authenticator := newSoftAuthenticator(t)
+	_, err = svc.FinishPasskeyRegistration(user.ID.String(), "Laptop", authenticator.create(t, opts))
+	require.NoError(t, err)
+	return authenticator
+}
+
+func TestPasskeyRegistration_StoresVerifiedCredential(t *testing.T) {
+	svc, userRepo, passkeys := newPasskeyService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com", FirstName: "Ada", LastName: "Lovelace"}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+
+	opts, err := svc.BeginPasskeyRegistration(user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, testRPID, opts.RP.ID)
+	assert.Equal(t, "Ada Lovelace", opts.User.DisplayName)
+	assert.Empty(t, opts.ExcludeCredentials)
+
+	authenticator := newSoftAuthenticator(t)
+	att := authenticator.create(t, opts)
+	credential, err := svc.FinishPasskeyRegistration(user.ID.String(), " Laptop ", att)
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop", credential.Name)
+	assert.Equal(t, AttestationSelf, credential.AttestationType)
+	assert.Equal(t, COSEAlgES256, credential.Algorithm)
+	assert.Equal(t, []string{"internal"}, credential.Transports)
+
+	// The challenge is single use
+	_, err = svc.FinishPasskeyRegistration(user.ID.String(), "Laptop", att)
+	assert.ErrorIs(t, err, ErrPasskeyInvalid)
+
+	// Registered passkeys are excluded from the next registration, and cannot be added twice
+	opts, err = svc.BeginPasskeyRegistration(user.ID.String())
+	require.NoError(t, err)
+	require.Len(t, opts.ExcludeCredentials, 1)
+	assert.Equal(t, credential.CredentialID, opts.ExcludeCredentials[0].ID)
+	_, err = svc.FinishPasskeyRegistration(user.ID.String(), "Laptop", authenticator.create(t, opts))
+	assert.ErrorIs(t, err, ErrPasskeyExists)
+	assert.Len(t, passkeys.credentials, 1)
+}
+
+func TestPasskeyRegistration_RejectsBadAttestation(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com"}
+	other := &model.User{ID: uuid.New(), Email: "other@example.com"}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+
+	// Signed by a different key than the attested credential
+	opts, err := svc.BeginPasskeyRegistration(user.ID.String())
+	require.NoError(t, err)
+	att := newSoftAuthenticator(t).createSignedBy(t, opts, newSoftAuthenticator(t))
+	_, err = svc.FinishPasskeyRegistration(user.ID.String(), "", att)
+	assert.ErrorIs(t, err, ErrPasskeyInvalid)
+
+	// A challenge issued to one user cannot register a passkey for another
+	opts, err = svc.BeginPasskeyRegistration(user.ID.String())
+	require.NoError(t, err)
+	_, err = svc.FinishPasskeyRegistration(other.ID.String(), "", newSoftAuthenticator(t).create(t, opts))
+	assert.ErrorIs(t, err, ErrPasskeyInvalid)
+}
+
+func TestPasskeyLogin_Passwordless(t *testing.T) {
+	svc, userRepo, passkeys := newPasskeyService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com"}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+	userRepo.On("FindByEmail", "user@example.com").Return(user, nil)
+	authenticator := registerPasskey(t, svc, user)
+
+	opts, err := svc.BeginPasskeyLogin("user@example.com", "")
+	require.NoError(t, err)
+	assert.Equal(t, "required", opts.UserVerification)
+	require.Len(t, opts.AllowCredentials, 1)
+
+	assertion := authenticator.get(t, opts.Challenge, testOrigin, authFlagUserPresent|authFlagUserVerified)
+	result, err := svc.FinishPasskeyLogin(assertion)
+	require.NoError(t, err)
+	assert.False(t, result.StepUp)
+	require.NotNil(t, result.Tokens)
+	assert.NotEmpty(t, result.Tokens.AccessToken)
+	assert.Equal(t, user.ID, result.User.ID)
+
+	stored, _ := passkeys.FindCredential(encodeBase64URL(authenticator.credentialID))
+	assert.Equal(t, int64(1), stored.SignCount)
+	assert.NotNil(t, stored.LastUsedAt)
+
+	// A replayed assertion fails, as its challenge was consumed
+	_, err = svc.FinishPasskeyLogin(assertion)
+	assert.ErrorIs(t, err, ErrPasskeyInvalid)
+}
+
+func TestPasskeyLogin_RejectsInvalidAssertions(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com"}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+	authenticator := registerPasskey(t, svc, user)
+
+	tests := []struct {
+		name   string
+		origin string
+		flags  byte
+		modify func(a *PasskeyAssertion)
+	}{
+		{name: "wrong origin", origin: "https://evil.test", flags: authFlagUserPresent | authFlagUserVerified},
+		{name: "user not verified", origin: testOrigin, flags: authFlagUserPresent},
+		{name: "bad signature", origin: testOrigin, flags: authFlagUserPresent | authFlagUserVerified,
+			modify: func(a *PasskeyAssertion) { a.Response.Signature = encodeBase64URL([]byte("forged")) }},
+		{name: "wrong user handle", origin: testOrigin, flags: authFlagUserPresent | authFlagUserVerified,
+			modify: func(a *PasskeyAssertion) { a.Response.UserHandle = encodeBase64URL(uuid.New().NodeID()) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := svc.BeginPasskeyLogin("", "")
+			require.NoError(t, err)
+			assertion := authenticator.get(t, opts.Challenge, tt.origin, tt.flags)
+			if tt.modify != nil {
+				tt.modify(&assertion)
+			}
+			_, err = svc.FinishPasskeyLogin(assertion)
+			assert.ErrorIs(t, err, ErrPasskeyInvalid)
+		})
+	}
+
+	// A counter that goes backwards suggests a cloned authenticator
+	opts, err := svc.BeginPasskeyLogin("", "")
+	require.NoError(t, err)
+	_, err = svc.FinishPasskeyLogin(authenticator.get(t, opts.Challenge, testOrigin, authFlagUserPresent|authFlagUserVerified))
+	require.NoError(t, err)
+
+	authenticator.signCount -= 2
+	opts, err = svc.BeginPasskeyLogin("", "")
+	require.NoError(t, err)
+	_, err = svc.FinishPasskeyLogin(authenticator.get(t, opts.Challenge, testOrigin, authFlagUserPresent|authFlagUserVerified))
+	assert.ErrorIs(t, err, ErrPasskeyInvalid)
+}
+
+func TestPasskeyLogin_CompletesFlaggedLogin(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	activity := new(MockLoginActivityRepository)
+	svc.LoginActivity = activity
+	user := &model.User{ID: uuid.New(), Email: "user@example.com", Role: "customer"}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+	authenticator := registerPasskey(t, svc, user)
+
+	challenge := &model.LoginChallenge{ID: uuid.New(), UserID: user.ID, LoginEventID: uuid.New(), ExpiresAt: time.Now().Add(time.Minute)}
+	activity.On("FindChallenge", challenge.ID.String()).Return(challenge, nil)
+	activity.On("MarkChallengeUsed", challenge.ID.String(), mock.Anything).Return(true, nil)
+	activity.On("UpdateEventOutcome", challenge.LoginEventID.String(), model.LoginStepUpPassed).Return(nil)
+
+	opts, err := svc.BeginPasskeyLogin("", challenge.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, "preferred", opts.UserVerification)
+	require.Len(t, opts.AllowCredentials, 1)
+
+	// The password was the first factor, so user presence is enough
+	result, err := svc.FinishPasskeyLogin(authenticator.get(t, opts.Challenge, testOrigin, authFlagUserPresent))
+	require.NoError(t, err)
+	assert.True(t, result.StepUp)
+	assert.NotEmpty(t, result.Token)
+	assert.Nil(t, result.Tokens)
+	activity.AssertExpectations(t)
+}
+
+func TestPasskeys_DisabledWithoutConfig(t *testing.T) {
+	svc := NewAuthService(new(MockUserRepository), "secret")
+
+	_, err := svc.BeginPasskeyRegistration(uuid.NewString())
+	assert.ErrorIs(t, err, ErrPasskeysDisabled)
+	_, err = svc.BeginPasskeyLogin("user@example.com", "")
+	assert.ErrorIs(t, err, ErrPasskeysDisabled)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/security"
+)
+
+var (
+	ErrWeakPassword     = errors.New("password does not meet the password policy")
+	ErrBreachedPassword = errors.New("password has appeared in a known data breach, please choose another")
+)
+
+// PasswordPolicyError explains why a password was rejected. It matches
+// ErrWeakPassword with errors.Is.
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Reason
+}
+
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrWeakPassword
+}
+
+// BreachChecker reports whether a password is known to be breached
+type BreachChecker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// PasswordPolicy validates new passwords on registration, reset and change
+type PasswordPolicy struct {
+	minLength int
+	maxLength int
+	classes   []string
+	denylist  map[string]bool
+	// Breaches is nil when the breach check is disabled
+	Breaches BreachChecker
+}
+
+// DefaultPasswordPolicy enforces security.DefaultPasswordPolicyConfig without a breach check
+func DefaultPasswordPolicy() *PasswordPolicy {
+	policy, err := NewPasswordPolicy(security.DefaultPasswordPolicyConfig())
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// NewPasswordPolicy builds a policy from configuration, loading the local
+// bloom filter when the breach check names one
+func NewPasswordPolicy(cfg security.PasswordPolicyConfig) (*PasswordPolicy, error) {
+	cfg = cfg.WithDefaults()
+	if cfg.MinLength > cfg.MaxLength {
+		return nil, fmt.Errorf("password policy: min length %d exceeds max length %d", cfg.MinLength, cfg.MaxLength)
+	}
+	for _, class := range cfg.RequiredClasses {
+		if _, ok := charClassNames[class]; !ok {
+			return nil, fmt.Errorf("password policy: unknown character class %q", class)
+		}
+	}
+
+	policy := &PasswordPolicy{
+		minLength: cfg.MinLength,
+		maxLength: cfg.MaxLength,
+		classes:   cfg.RequiredClasses,
+		denylist:  make(map[string]bool, len(cfg.Denylist)),
+	}
+	for _, p := range cfg.Denylist {
+		policy.denylist[strings.ToLower(p)] = true
+	}
+
+	if cfg.BreachCheck.Enabled {
+		var filter *BloomFilter
+		if cfg.BreachCheck.BloomFilterPath != "" {
+			var err error
+			if filter, err = LoadBloomFilter(cfg.BreachCheck.BloomFilterPath); err != nil {
+				return nil, fmt.Errorf("password policy: %w", err)
+			}
+		}
+		switch {
+		case cfg.BreachCheck.Offline && filter == nil:
+			return nil, errors.New("password policy: offline breach check needs a bloom filter")
+		case cfg.BreachCheck.Offline:
+			policy.Breaches = filter
+		default:
+			api := &RangeBreachClient{
+				BaseURL: cfg.BreachCheck.APIURL,
+				Client:  &http.Client{Timeout: cfg.BreachCheck.Timeout},
+			}
+			if filter != nil {
+				policy.Breaches = &FallbackBreachChecker{Primary: api, Fallback: filter}
+			} else {
+				policy.Breaches = api
+			}
+		}
+	}
+	return policy, nil
+}
+
+// Validate checks a password against the policy. Rule violations return a
+// *PasswordPolicyError; a breached password returns ErrBreachedPassword. When
+// the breach check itself fails the password is accepted, so an outage of the
+// breach API does not block sign-ups.
+func (p *PasswordPolicy) Validate(ctx context.Context, password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.minLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("password must be at least %d characters", p.minLength)}
+	}
+	if length > p.maxLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("password must be at most %d characters", p.maxLength)}
+	}
+	for _, class := range p.classes {
+		if !strings.ContainsFunc(password, charClasses[class]) {
+			return &PasswordPolicyError{Reason: "password must contain at least one " + charClassNames[class]}
+		}
+	}
+	if p.denylist[strings.ToLower(password)] {
+		return &PasswordPolicyError{Reason: "password is too common"}
+	}
+
+	if p.Breaches != nil {
+		breached, err := p.Breaches.IsBreached(ctx, password)
+		if err != nil {
+			slog.Warn("Breached password check unavailable", "error", err)
+			return nil
+		}
+		if breached {
+			return ErrBreachedPassword
+		}
+	}
+	return nil
+}
+
+var charClasses = map[string]func(rune) bool{
+	security.CharClassUpper:  unicode.IsUpper,
+	security.CharClassLower:  unicode.IsLower,
+	security.CharClassDigit:  unicode.IsDigit,
+	security.CharClassSymbol: func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSymbol(r) },
+}
+
+var charClassNames = map[string]string{
+	security.CharClassUpper:  "uppercase letter",
+	security.CharClassLower:  "lowercase letter",
+	security.CharClassDigit:  "digit",
+	security.CharClassSymbol: "special character",
+}
+
+// validatePassword applies the configured policy, if any
+func (s *AuthService) validatePassword(ctx context.Context, password string) error {
+	if s.PasswordPolicy == nil {
+		return nil
+	}
+	return s.PasswordPolicy.Validate(ctx, password)
+}
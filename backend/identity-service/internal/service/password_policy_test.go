@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy_DefaultRules(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	tests := []struct {
+		password string
+		reason   string
+	}{
+		{"Short1!", "at least 12 characters"},
+		{strings.Repeat("Aa1!", 33), "at most 128 characters"},
+		{"lowercase-only-1", "uppercase"},
+		{"UPPERCASE-ONLY-1", "lowercase"},
+		{"No-Digits-Here!", "digit"},
+		{"NoSpecialChars123", "special character"},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(context.Background(), tt.password)
+		require.Error(t, err, tt.password)
+		assert.ErrorIs(t, err, ErrWeakPassword)
+		assert.Contains(t, err.Error(), tt.reason)
+	}
+
+	assert.NoError(t, policy.Validate(context.Background(), "Correct-Horse-9"))
+	// Length counts characters, not bytes
+	assert.NoError(t, policy.Validate(context.Background(), "Pässwörd-ünïcode-1"))
+}
+
+func TestPasswordPolicy_Configured(t *testing.T) {
+	policy, err := NewPasswordPolicy(security.PasswordPolicyConfig{
+		MinLength:       6,
+		MaxLength:       20,
+		RequiredClasses: []string{security.CharClassDigit},
+		Denylist:        []string{"Welcome123"},
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Validate(context.Background(), "abcde1"))
+	assert.ErrorContains(t, policy.Validate(context.Background(), "abcdef"), "digit")
+	assert.ErrorContains(t, policy.Validate(context.Background(), "WELCOME123"), "too common")
+	assert.ErrorContains(t, policy.Validate(context.Background(), strings.Repeat("a1", 11)), "at most 20")
+
+	_, err = NewPasswordPolicy(security.PasswordPolicyConfig{RequiredClasses: []string{"emoji"}})
+	assert.ErrorContains(t, err, "unknown character class")
+	_, err = NewPasswordPolicy(security.PasswordPolicyConfig{MinLength: 30, MaxLength: 20})
+	assert.Error(t, err)
+	_, err = NewPasswordPolicy(security.PasswordPolicyConfig{BreachCheck: security.BreachCheckConfig{Enabled: true, Offline: true}})
+	assert.ErrorContains(t, err, "bloom filter")
+}
+
+type stubBreachChecker struct {
+	breached bool
+	err      error
+	calls    int
+}
+
+func (s *stubBreachChecker) IsBreached(context.Context, string) (bool, error) {
+	s.calls++
+	return s.breached, s.err
+}
+
+func TestPasswordPolicy_BreachCheck(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	stub := &stubBreachChecker{breached: true}
+	policy.Breaches = stub
+
+	assert.ErrorIs(t, policy.Validate(context.Background(), "Correct-Horse-9"), ErrBreachedPassword)
+	// Weak passwords are rejected before any breach lookup
+	assert.ErrorIs(t, policy.Validate(context.Background(), "weak"), ErrWeakPassword)
+	assert.Equal(t, 1, stub.calls)
+
+	// An unavailable breach check does not block the password
+	policy.Breaches = &stubBreachChecker{err: errors.New("timeout")}
+	assert.NoError(t, policy.Validate(context.Background(), "Correct-Horse-9"))
+}
+
+// rangeServer serves a k-anonymity range response containing the given passwords
+func rangeServer(t *testing.T, breached ...string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := strings.TrimPrefix(r.URL.Path, "/range/")
+		requested = append(requested, prefix)
+		assert.Equal(t, "true", r.Header.Get("Add-Padding"))
+		// A padding entry with a zero count
+		fmt.Fprintln(w, "0000000000000000000000000000000000A:0")
+		for _, p := range breached {
+			if digest := passwordSHA1(p); digest[:5] == prefix {
+				fmt.Fprintf(w, "%s:42\r\n", digest[5:])
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requested
+}
+
+func TestRangeBreachClient(t *testing.T) {
+	srv, requested := rangeServer(t, "P@ssw0rd1234")
+	client := &RangeBreachClient{BaseURL: srv.URL, Client: srv.Client()}
+
+	breached, err := client.IsBreached(context.Background(), "P@ssw0rd1234")
+	require.NoError(t, err)
+	assert.True(t, breached)
+
+	breached, err = client.IsBreached(context.Background(), "Correct-Horse-9")
+	require.NoError(t, err)
+	assert.False(t, breached)
+
+	// Only the five character prefix is sent
+	require.Len(t, *requested, 2)
+	assert.Equal(t, passwordSHA1("P@ssw0rd1234")[:5], (*requested)[0])
+
+	srv.Close()
+	_, err = client.IsBreached(context.Background(), "P@ssw0rd1234")
+	assert.Error(t, err)
+}
+
+func TestBloomFilter_RoundTrip(t *testing.T) {
+	corpus := fmt.Sprintf("%s:3861493\n%s\n\n", passwordSHA1("P@ssw0rd1234"), strings.ToLower(passwordSHA1("Summer2024!!")))
+	filter, err := BuildBloomFilter(strings.NewReader(corpus), 100, 0.001)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = filter.WriteTo(&buf)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "breached.bf")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	loaded, err := LoadBloomFilter(path)
+	require.NoError(t, err)
+	for _, p := range []string{"P@ssw0rd1234", "Summer2024!!"} {
+		breached, _ := loaded.IsBreached(context.Background(), p)
+		assert.True(t, breached, p)
+	}
+	breached, _ := loaded.IsBreached(context.Background(), "Correct-Horse-9")
+	assert.False(t, breached)
+
+	_, err = BuildBloomFilter(strings.NewReader("not-a-hash\n"), 10, 0.01)
+	assert.ErrorContains(t, err, "line 1")
+	_, err = ReadBloomFilter(strings.NewReader("JUNKJUNKJUNKJUNK"))
+	assert.Error(t, err)
+}
+
+func TestNewPasswordPolicy_FallsBackToBloomFilter(t *testing.T) {
+	filter, err := BuildBloomFilter(strings.NewReader(passwordSHA1("P@ssw0rd1234")), 10, 0.001)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "breached.bf")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = filter.WriteTo(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// The API is unreachable, so the local filter answers
+	srv, _ := rangeServer(t)
+	srv.Close()
+	policy, err := NewPasswordPolicy(security.PasswordPolicyConfig{
+		BreachCheck: security.BreachCheckConfig{Enabled: true, APIURL: srv.URL, BloomFilterPath: path},
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, policy.Validate(context.Background(), "P@ssw0rd1234"), ErrBreachedPassword)
+
+	// Offline mode never calls the API
+	offline, err := NewPasswordPolicy(security.PasswordPolicyConfig{
+		BreachCheck: security.BreachCheckConfig{Enabled: true, Offline: true, BloomFilterPath: path},
+	})
+	require.NoError(t, err)
+	assert.IsType(t, &BloomFilter{}, offline.Breaches)
+	assert.ErrorIs(t, offline.Validate(context.Background(), "P@ssw0rd1234"), ErrBreachedPassword)
+	assert.NoError(t, offline.Validate(context.Background(), "Correct-Horse-9"))
+}
+
+func TestRegister_EnforcesPasswordPolicy(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	svc := NewAuthService(mockRepo, "secret")
+	mockRepo.On("FindByEmail", "new@example.com").Return(nil, errors.New("not found"))
+
+	_, err := svc.Register("new@example.com", "password", "John", "Doe")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
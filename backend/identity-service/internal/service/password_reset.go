@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
@@ -78,8 +79,8 @@ func (s *AuthService) ResetPassword(token string, newPassword string) error {
 	}
 
 	// Validate password strength
-	if len(newPassword) < 8 {
-		return errors.New("password must be at least 8 characters")
+	if err := s.validatePassword(context.Background(), newPassword); err != nil {
+		return err
 	}
 
 	// Hash new password
@@ -116,15 +117,15 @@ func (s *AuthService) ChangePassword(userID string, currentPassword string, newP
 		return errors.New("current password is incorrect")
 	}
 
-	// Validate new password
-	if len(newPassword) < 8 {
-		return errors.New("new password must be at least 8 characters")
-	}
-
 	if currentPassword == newPassword {
 		return errors.New("new password must be different from current password")
 	}
 
+	// Validate new password
+	if err := s.validatePassword(context.Background(), newPassword); err != nil {
+		return err
+	}
+
 	// Hash new password
 	hashedPassword, err := s.hashPassword(newPassword)
 	if err != nil {
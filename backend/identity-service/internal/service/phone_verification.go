@@ -0,0 +1,404 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPhoneVerificationRateLimited = errors.New("too many verification codes requested, please try again later")
+	ErrPhoneCodeEntryLocked         = errors.New("too many incorrect verification codes, please try again later")
+)
+
+// PhoneCode is a pending phone number change: the number a code was texted
+// to, waiting for the user to enter it. Only the HMAC of the code is kept.
+type PhoneCode struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Phone     string    `json:"phone"`
+	CodeHash  string    `json:"code_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PhoneCodeStore keeps pending phone verifications until they expire
+type PhoneCodeStore interface {
+	SavePhoneCode(ctx context.Context, code *PhoneCode) error
+	// FindPhoneCode returns a code with the wrong attempts made at it, or nil
+	// if there is no such code. A code may be returned after it expired.
+	FindPhoneCode(ctx context.Context, id string) (*PhoneCode, int, error)
+	// RecordPhoneCodeAttempt counts a wrong code and returns the attempts so far
+	RecordPhoneCodeAttempt(ctx context.Context, code *PhoneCode) (int, error)
+	// ConsumePhoneCode deletes a code. It reports false if it was already
+	// gone, so a code cannot confirm a number twice.
+	ConsumePhoneCode(ctx context.Context, id string) (bool, error)
+}
+
+// RedisPhoneCodeStore keeps phone verifications in Redis, shared by every
+// replica, each expiring with its code
+type RedisPhoneCodeStore struct {
+	client *cache.RedisClient
+}
+
+func NewRedisPhoneCodeStore(client *cache.RedisClient) *RedisPhoneCodeStore {
+	return &RedisPhoneCodeStore{client: client}
+}
+
+const phoneCodeKeyPrefix = "phone_code:"
+
+// recordAttemptScript counts a wrong code, expiring the counter with the code
+const recordAttemptScript = `
+local attempts = redis.call('INCR', KEYS[1])
+redis.call('PEXPIREAT', KEYS[1], ARGV[1])
+return attempts`
+
+func (s *RedisPhoneCodeStore) SavePhoneCode(ctx context.Context, code *PhoneCode) error {
+	return s.client.SetJSON(ctx, phoneCodeKeyPrefix+code.ID, code, time.Until(code.ExpiresAt))
+}
+
+func (s *RedisPhoneCodeStore) FindPhoneCode(ctx context.Context, id string) (*PhoneCode, int, error) {
+	val, err := s.client.Get(ctx, phoneCodeKeyPrefix+id)
+	if err != nil || val == "" {
+		return nil, 0, err
+	}
+	var code PhoneCode
+	if err := json.Unmarshal([]byte(val), &code); err != nil {
+		return nil, 0, err
+	}
+	attempts, err := s.client.Get(ctx, phoneCodeKeyPrefix+id+":attempts")
+	if err != nil {
+		return nil, 0, err
+	}
+	n, _ := strconv.Atoi(attempts)
+	return &code, n, nil
+}
+
+func (s *RedisPhoneCodeStore) RecordPhoneCodeAttempt(ctx context.Context, code *PhoneCode) (int, error) {
+	result, err := s.client.Eval(ctx, recordAttemptScript, []string{phoneCodeKeyPrefix + code.ID + ":attempts"}, code.ExpiresAt.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	attempts, _ := result.(int64)
+	return int(attempts), nil
+}
+
+func (s *RedisPhoneCodeStore) ConsumePhoneCode(ctx context.Context, id string) (bool, error) {
+	result, err := s.client.Eval(ctx, "return redis.call('DEL', KEYS[1], KEYS[2])",
+		[]string{phoneCodeKeyPrefix + id, phoneCodeKeyPrefix + id + ":attempts"})
+	if err != nil {
+		return false, err
+	}
+	deleted, _ := result.(int64)
+	return deleted > 0, nil
+}
+
+// MemoryPhoneCodeStore keeps phone verifications in memory, for development
+// and single-replica deployments without Redis
+type MemoryPhoneCodeStore struct {
+	mu       sync.Mutex
+	codes    map[string]PhoneCode
+	attempts map[string]int
+}
+
+func NewMemoryPhoneCodeStore() *MemoryPhoneCodeStore {
+	return &MemoryPhoneCodeStore{codes: map[string]PhoneCode{}, attempts: map[string]int{}}
+}
+
+// SavePhoneCode also forgets the codes that expired
+func (s *MemoryPhoneCodeStore) SavePhoneCode(_ context.Context, code *PhoneCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, existing := range s.codes {
+		if now.After(existing.ExpiresAt) {
+			delete(s.codes, id)
+			delete(s.attempts, id)
+		}
+	}
+	s.codes[code.ID] = *code
+	return nil
+}
+
+func (s *MemoryPhoneCodeStore) FindPhoneCode(_ context.Context, id string) (*PhoneCode, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code, ok := s.codes[id]
+	if !ok {
+		return nil, 0, nil
+	}
+	return &code, s.attempts[id], nil
+}
+
+func (s *MemoryPhoneCodeStore) RecordPhoneCodeAttempt(_ context.Context, code *PhoneCode) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[code.ID]++
+	return s.attempts[code.ID], nil
+}
+
+func (s *MemoryPhoneCodeStore) ConsumePhoneCode(_ context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.codes[id]
+	delete(s.codes, id)
+	delete(s.attempts, id)
+	return ok, nil
+}
+
+// SMSMessage is a templated text message; the provider renders and delivers it
+type SMSMessage struct {
+	UserID   string
+	To       string
+	Template string
+	Data     map[string]string
+}
+
+// SMSProvider delivers text messages
+type SMSProvider interface {
+	SendSMS(ctx context.Context, msg SMSMessage) error
+}
+
+// SMS providers that NewSMSProvider knows
+const (
+	SMSProviderNotifications = "notifications"
+	SMSProviderConsole       = "console"
+)
+
+// NewSMSProvider returns the SMS provider named by name: "notifications"
+// hands messages to the notification service through publisher, "console"
+// logs them (development only)
+func NewSMSProvider(name string, publisher EventPublisher) (SMSProvider, error) {
+	switch name {
+	case SMSProviderNotifications:
+		return NotificationSMSProvider{Publisher: publisher}, nil
+	case SMSProviderConsole:
+		return ConsoleSMSProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown SMS provider %q", name)
+}
+
+// NotificationSMSProvider publishes text messages on the notification.sms
+// topic for the notification service to send
+type NotificationSMSProvider struct {
+	Publisher EventPublisher
+}
+
+func (p NotificationSMSProvider) SendSMS(ctx context.Context, msg SMSMessage) error {
+	return p.Publisher.Produce(ctx, kafka.TopicNotificationSMS, msg.UserID, kafka.NotificationEvent{
+		UserID:    msg.UserID,
+		Channel:   "SMS",
+		Recipient: msg.To,
+		Template:  msg.Template,
+		Data:      msg.Data,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// ConsoleSMSProvider writes text messages to the log instead of sending them
+// (development only)
+type ConsoleSMSProvider struct{}
+
+func (ConsoleSMSProvider) SendSMS(_ context.Context, msg SMSMessage) error {
+	slog.Info("SMS", "to", msg.To, "template", msg.Template, "data", msg.Data)
+	return nil
+}
+
+// DefaultPhoneCodeLimiter allows each user 5 phone verification codes an hour
+func DefaultPhoneCodeLimiter() *AccountLockout {
+	return NewAttemptLockout("phone_code", nil, fixedLockoutPolicy(5, time.Hour, time.Hour))
+}
+
+// DefaultPhoneCodeAttempts locks code entry out for a user who keeps
+// entering wrong codes, whichever codes they were for, see
+// middleware.OTPAttemptPolicy
+func DefaultPhoneCodeAttempts() *AccountLockout {
+	return NewAttemptLockout("phone_otp", nil, middleware.OTPAttemptPolicy())
+}
+
+// PhoneStatus is a user's phone number and whether they confirmed it, for
+// step-up and notification routing, which only text verified numbers
+type PhoneStatus struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Phone      string     `json:"phone,omitempty" redact:"support=phone,reveal=pii:read"`
+	Verified   bool       `json:"verified"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+// PhoneStatus returns the user's phone number and whether it is verified
+func (s *ProfileService) PhoneStatus(userID string) (*PhoneStatus, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &PhoneStatus{UserID: user.ID, Phone: user.Phone}
+	if user.Phone != "" && user.PhoneVerifiedAt != nil {
+		status.Verified = true
+		status.VerifiedAt = user.PhoneVerifiedAt
+	}
+	return status, nil
+}
+
+// RequestPhoneVerification texts a code to phone; the number becomes the
+// user's once ConfirmPhone is called with the code. A number already
+// verified needs no code and returns nil.
+func (s *ProfileService) RequestPhoneVerification(ctx context.Context, userID, phone string) (*PhoneVerificationInfo, error) {
+	phone = normalizePhone(phone)
+	if !phonePattern.MatchString(phone) {
+		return nil, &ProfileValidationError{Fields: map[string]string{ProfileFieldPhone: phoneFormatMessage}}
+	}
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	if phone == user.Phone && user.PhoneVerifiedAt != nil {
+		return nil, nil
+	}
+	if !s.canVerifyPhones() {
+		return nil, ErrPhoneVerificationUnavailable
+	}
+	return s.startPhoneVerification(ctx, user, phone)
+}
+
+// ConfirmPhone saves the phone number a verification code was texted to once
+// the user enters the code
+func (s *ProfileService) ConfirmPhone(ctx context.Context, userID, verificationID, code string) (*Profile, error) {
+	if !s.canVerifyPhones() {
+		return nil, ErrPhoneVerificationUnavailable
+	}
+	if _, err := uuid.Parse(verificationID); err != nil {
+		return nil, ErrPhoneVerificationInvalid
+	}
+	v, attempts, err := s.Codes.FindPhoneCode(ctx, verificationID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if v == nil || v.UserID != userID || now.After(v.ExpiresAt) {
+		return nil, ErrPhoneVerificationInvalid
+	}
+	if attempts >= MaxPhoneVerificationAttempts {
+		return nil, ErrPhoneVerificationLocked
+	}
+	if s.CodeAttempts != nil && s.CodeAttempts.IsLocked(userID) {
+		return nil, ErrPhoneCodeEntryLocked
+	}
+
+	expected, _ := hex.DecodeString(v.CodeHash)
+	actual, _ := hex.DecodeString(s.phoneCodeHash(v.ID, code))
+	if !hmac.Equal(expected, actual) {
+		attempts, err := s.Codes.RecordPhoneCodeAttempt(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		if s.CodeAttempts != nil {
+			if locked, _ := s.CodeAttempts.RecordFailedAttempt(userID); locked {
+				return nil, ErrPhoneCodeEntryLocked
+			}
+		}
+		if attempts >= MaxPhoneVerificationAttempts {
+			return nil, ErrPhoneVerificationLocked
+		}
+		return nil, ErrPhoneVerificationInvalid
+	}
+
+	consumed, err := s.Codes.ConsumePhoneCode(ctx, v.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, ErrPhoneVerificationInvalid
+	}
+	if s.CodeAttempts != nil {
+		s.CodeAttempts.RecordSuccessfulLogin(userID)
+	}
+
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	var changes []model.ProfileChange
+	if user.Phone != v.Phone {
+		changes = append(changes, model.ProfileChange{UserID: user.ID, Field: ProfileFieldPhone, OldValue: user.Phone, NewValue: v.Phone, CreatedAt: now})
+	}
+	user.Phone = v.Phone
+	user.PhoneVerifiedAt = &now
+	if err := s.Repo.UpdateProfile(user, changes); err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		s.publishUpdated(ctx, user, changes)
+	}
+	return newProfile(user), nil
+}
+
+func (s *ProfileService) canVerifyPhones() bool {
+	return s.SMS != nil && s.Codes != nil
+}
+
+// startPhoneVerification texts a code to phone for ConfirmPhone. Each user
+// can only have so many codes sent an hour, so the endpoint cannot be used
+// to flood a number with texts.
+func (s *ProfileService) startPhoneVerification(ctx context.Context, user *model.User, phone string) (*PhoneVerificationInfo, error) {
+	if s.CodeLimiter != nil {
+		key := user.ID.String()
+		if s.CodeLimiter.IsLocked(key) {
+			return nil, ErrPhoneVerificationRateLimited
+		}
+		s.CodeLimiter.RecordFailedAttempt(key)
+	}
+
+	code, err := generateLoginCode()
+	if err != nil {
+		return nil, err
+	}
+	id := uuid.NewString()
+	v := &PhoneCode{
+		ID:        id,
+		UserID:    user.ID.String(),
+		Phone:     phone,
+		CodeHash:  s.phoneCodeHash(id, code),
+		ExpiresAt: s.now().Add(PhoneVerificationExpiry),
+	}
+	if err := s.Codes.SavePhoneCode(ctx, v); err != nil {
+		return nil, err
+	}
+
+	if err := s.SMS.SendSMS(ctx, SMSMessage{
+		UserID:   user.ID.String(),
+		To:       phone,
+		Template: PhoneVerificationTemplate,
+		Data: map[string]string{
+			"code":       code,
+			"expires_at": v.ExpiresAt.Format(time.RFC3339),
+		},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to send phone verification code: %w", err)
+	}
+	return &PhoneVerificationInfo{VerificationID: id, Phone: phone, ExpiresAt: v.ExpiresAt}, nil
+}
+
+// phoneCodeHash binds a verification code to its verification so stored hashes cannot be reused
+func (s *ProfileService) phoneCodeHash(verificationID, code string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("phone-verification:" + verificationID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func normalizePhone(phone string) string {
+	return phoneSeparators.Replace(strings.TrimSpace(phone))
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSMS is an SMSProvider that keeps the messages sent
+type recordingSMS struct {
+	sent []SMSMessage
+}
+
+func (p *recordingSMS) SendSMS(_ context.Context, msg SMSMessage) error {
+	p.sent = append(p.sent, msg)
+	return nil
+}
+
+func TestRequestPhoneVerification(t *testing.T) {
+	svc, _, _, user := newProfileService()
+	sms := &recordingSMS{}
+	svc.SMS = sms
+	ctx := context.Background()
+
+	_, err := svc.RequestPhoneVerification(ctx, user.ID.String(), "07700 900123")
+	var invalid *ProfileValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, invalid.Fields, ProfileFieldPhone)
+
+	verification, err := svc.RequestPhoneVerification(ctx, user.ID.String(), "+44 (7700) 900-123")
+	require.NoError(t, err)
+	require.NotNil(t, verification)
+	assert.Equal(t, "+447700900123", verification.Phone)
+	require.Len(t, sms.sent, 1)
+	assert.Equal(t, "+447700900123", sms.sent[0].To)
+	assert.Equal(t, PhoneVerificationTemplate, sms.sent[0].Template)
+
+	// Only the HMAC of the code is stored
+	stored, attempts, err := svc.Codes.FindPhoneCode(ctx, verification.VerificationID)
+	require.NoError(t, err)
+	assert.Zero(t, attempts)
+	assert.NotContains(t, stored.CodeHash, sms.sent[0].Data["code"])
+
+	status, err := svc.PhoneStatus(user.ID.String())
+	require.NoError(t, err)
+	assert.False(t, status.Verified)
+
+	_, err = svc.ConfirmPhone(ctx, user.ID.String(), verification.VerificationID, sms.sent[0].Data["code"])
+	require.NoError(t, err)
+	status, err = svc.PhoneStatus(user.ID.String())
+	require.NoError(t, err)
+	assert.True(t, status.Verified)
+	assert.Equal(t, "+447700900123", status.Phone)
+	require.NotNil(t, status.VerifiedAt)
+
+	// The verified number needs no new code
+	verification, err = svc.RequestPhoneVerification(ctx, user.ID.String(), "+447700900123")
+	require.NoError(t, err)
+	assert.Nil(t, verification)
+	assert.Len(t, sms.sent, 1)
+}
+
+func TestRequestPhoneVerification_RateLimited(t *testing.T) {
+	svc, _, _, user := newProfileService()
+	svc.SMS = &recordingSMS{}
+	svc.CodeLimiter = NewAccountLockout(2, time.Hour, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		_, err := svc.RequestPhoneVerification(context.Background(), user.ID.String(), "+447700900123")
+		require.NoError(t, err)
+	}
+	_, err := svc.RequestPhoneVerification(context.Background(), user.ID.String(), "+447700900123")
+	assert.ErrorIs(t, err, ErrPhoneVerificationRateLimited)
+}
+
+func TestConfirmPhone_CodeEntryLocked(t *testing.T) {
+	svc, _, _, user := newProfileService()
+	sms := &recordingSMS{}
+	svc.SMS = sms
+	svc.CodeAttempts = NewAccountLockout(3, time.Hour, time.Hour)
+	ctx := context.Background()
+
+	// Wrong codes count against the user across codes
+	first, err := svc.RequestPhoneVerification(ctx, user.ID.String(), "+447700900123")
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = svc.ConfirmPhone(ctx, user.ID.String(), first.VerificationID, "000000")
+		assert.ErrorIs(t, err, ErrPhoneVerificationInvalid)
+	}
+	second, err := svc.RequestPhoneVerification(ctx, user.ID.String(), "+447700900123")
+	require.NoError(t, err)
+	_, err = svc.ConfirmPhone(ctx, user.ID.String(), second.VerificationID, "000000")
+	assert.ErrorIs(t, err, ErrPhoneCodeEntryLocked)
+
+	// Even the right code is refused until the lockout ends
+	_, err = svc.ConfirmPhone(ctx, user.ID.String(), second.VerificationID, sms.sent[1].Data["code"])
+	assert.ErrorIs(t, err, ErrPhoneCodeEntryLocked)
+}
+
+func TestRequestPhoneVerification_NeedsStore(t *testing.T) {
+	svc, _, _, user := newProfileService()
+	svc.Codes = nil
+
+	_, err := svc.RequestPhoneVerification(context.Background(), user.ID.String(), "+447700900123")
+	assert.ErrorIs(t, err, ErrPhoneVerificationUnavailable)
+	_, err = svc.ConfirmPhone(context.Background(), user.ID.String(), "f47ac10b-58cc-4372-a567-0e02b2c3d479", "123456")
+	assert.ErrorIs(t, err, ErrPhoneVerificationUnavailable)
+}
+
+func TestNewSMSProvider(t *testing.T) {
+	publisher := &recordingPublisher{}
+	provider, err := NewSMSProvider(SMSProviderNotifications, publisher)
+	require.NoError(t, err)
+	require.NoError(t, provider.SendSMS(context.Background(), SMSMessage{UserID: "u1", To: "+447700900123", Template: "T", Data: map[string]string{"code": "123456"}}))
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, kafka.TopicNotificationSMS, publisher.topics[0])
+	event := publisher.events[0].(kafka.NotificationEvent)
+	assert.Equal(t, "SMS", event.Channel)
+	assert.Equal(t, "+447700900123", event.Recipient)
+
+	provider, err = NewSMSProvider(SMSProviderConsole, nil)
+	require.NoError(t, err)
+	assert.NoError(t, provider.SendSMS(context.Background(), SMSMessage{To: "+447700900123"}))
+
+	_, err = NewSMSProvider("carrier-pigeon", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+)
+
+// Channels users are contacted on, for marketing opt-ins and notifications
+const (
+	ChannelEmail = "EMAIL"
+	ChannelSMS   = "SMS"
+	ChannelPush  = "PUSH"
+)
+
+// PreferenceChannels are the channels preferences are kept for
+var PreferenceChannels = []string{ChannelEmail, ChannelSMS, ChannelPush}
+
+// Preference fields, as named in validation errors and
+// user.preferences.updated events. Channel settings are named by their
+// field and channel, e.g. notifications.SMS.
+const (
+	PreferenceFieldLocale          = "locale"
+	PreferenceFieldDisplayCurrency = "display_currency"
+	PreferenceFieldMarketing       = "marketing_opt_ins"
+	PreferenceFieldNotifications   = "notifications"
+)
+
+// localePattern is a BCP 47 language tag with an optional script and region,
+// e.g. en, en-GB, zh-Hant-TW or es-419
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z][a-z]{3})?(-([A-Z]{2}|[0-9]{3}))?$`)
+
+// PreferencesRepository stores the preferences users changed
+type PreferencesRepository interface {
+	// GetPreferences returns nil if the user never changed their preferences
+	GetPreferences(userID uuid.UUID) (*model.UserPreferences, error)
+	SavePreferences(prefs *model.UserPreferences) error
+}
+
+// PreferencesService keeps users' locale, display currency, marketing opt-ins
+// and notification channels. Changes are published on
+// user.preferences.updated so the services contacting users and formatting
+// their reports can follow them.
+type PreferencesService struct {
+	Repo          PreferencesRepository
+	Users         UserRepository
+	Notifications EventPublisher
+	// Defaults are the preferences of users who never changed them
+	Defaults model.UserPreferences
+	now      func() time.Time
+}
+
+func NewPreferencesService(repo PreferencesRepository, users UserRepository, notifications EventPublisher) *PreferencesService {
+	return &PreferencesService{
+		Repo:          repo,
+		Users:         users,
+		Notifications: notifications,
+		Defaults:      DefaultPreferences(),
+		now:           time.Now,
+	}
+}
+
+// DefaultPreferences are US English and dollars, no marketing, and
+// notifications on every channel
+func DefaultPreferences() model.UserPreferences {
+	prefs := model.UserPreferences{
+		Locale:          "en-US",
+		DisplayCurrency: "USD",
+		MarketingOptIns: map[string]bool{},
+		Notifications:   map[string]bool{},
+	}
+	for _, channel := range PreferenceChannels {
+		prefs.MarketingOptIns[channel] = false
+		prefs.Notifications[channel] = true
+	}
+	return prefs
+}
+
+// PreferencesUpdate holds the preferences to change; nil fields and channels
+// left out of the maps are kept
+type PreferencesUpdate struct {
+	Locale          *string         `json:"locale"`
+	DisplayCurrency *string         `json:"display_currency"`
+	MarketingOptIns map[string]bool `json:"marketing_opt_ins"`
+	Notifications   map[string]bool `json:"notifications"`
+}
+
+// GetPreferences returns the user's preferences, or the defaults if they
+// never changed them
+func (s *PreferencesService) GetPreferences(userID string) (*model.UserPreferences, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.preferences(user)
+}
+
+// UpdatePreferences validates every field of the update before changing any.
+// Locales are saved in their canonical case, e.g. en_gb as en-GB. At least
+// one notification channel must stay on, so account alerts can reach the
+// user. An update that changes nothing is not saved or published.
+func (s *PreferencesService) UpdatePreferences(ctx context.Context, userID string, update PreferencesUpdate) (*model.UserPreferences, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := s.preferences(user)
+	if err != nil {
+		return nil, err
+	}
+
+	invalid := map[string]string{}
+	var changed []string
+	if update.Locale != nil {
+		if locale, ok := canonicalLocale(*update.Locale); !ok {
+			invalid[PreferenceFieldLocale] = "must be a language tag such as en or en-GB"
+		} else if locale != prefs.Locale {
+			prefs.Locale = locale
+			changed = append(changed, PreferenceFieldLocale)
+		}
+	}
+	if update.DisplayCurrency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*update.DisplayCurrency))
+		if _, err := money.Exponent(currency); err != nil {
+			invalid[PreferenceFieldDisplayCurrency] = "must be an ISO 4217 currency code"
+		} else if currency != prefs.DisplayCurrency {
+			prefs.DisplayCurrency = currency
+			changed = append(changed, PreferenceFieldDisplayCurrency)
+		}
+	}
+	changed = append(changed, applyChannels(PreferenceFieldMarketing, prefs.MarketingOptIns, update.MarketingOptIns, invalid)...)
+	changed = append(changed, applyChannels(PreferenceFieldNotifications, prefs.Notifications, update.Notifications, invalid)...)
+	if !slices.ContainsFunc(PreferenceChannels, func(channel string) bool { return prefs.Notifications[channel] }) {
+		invalid[PreferenceFieldNotifications] = "at least one channel must stay on"
+	}
+	if len(invalid) > 0 {
+		return nil, &ProfileValidationError{Fields: invalid}
+	}
+	if len(changed) == 0 {
+		return prefs, nil
+	}
+
+	now := s.now()
+	prefs.UpdatedAt = &now
+	if err := s.Repo.SavePreferences(prefs); err != nil {
+		return nil, err
+	}
+	slices.Sort(changed)
+	s.publishUpdated(ctx, prefs, changed)
+	return prefs, nil
+}
+
+// preferences returns a copy of the user's preferences that can be changed
+func (s *PreferencesService) preferences(user *model.User) (*model.UserPreferences, error) {
+	saved, err := s.Repo.GetPreferences(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	prefs := s.Defaults
+	prefs.MarketingOptIns = maps.Clone(s.Defaults.MarketingOptIns)
+	prefs.Notifications = maps.Clone(s.Defaults.Notifications)
+	if saved != nil {
+		prefs.Locale, prefs.DisplayCurrency, prefs.UpdatedAt = saved.Locale, saved.DisplayCurrency, saved.UpdatedAt
+		// Channels added since the user saved keep their defaults
+		maps.Copy(prefs.MarketingOptIns, saved.MarketingOptIns)
+		maps.Copy(prefs.Notifications, saved.Notifications)
+	}
+	prefs.UserID = user.ID
+	return &prefs, nil
+}
+
+func (s *PreferencesService) user(userID string) (*model.User, error) {
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+	return user, nil
+}
+
+// publishUpdated announces saved preference changes. The changes are already
+// committed, so a failed publish is logged rather than failing the request.
+func (s *PreferencesService) publishUpdated(ctx context.Context, prefs *model.UserPreferences, changed []string) {
+	if s.Notifications == nil {
+		return
+	}
+	event := kafka.UserPreferencesUpdatedEvent{
+		UserID:          prefs.UserID.String(),
+		Fields:          changed,
+		Locale:          prefs.Locale,
+		DisplayCurrency: prefs.DisplayCurrency,
+		MarketingOptIns: prefs.MarketingOptIns,
+		Notifications:   prefs.Notifications,
+		Timestamp:       s.now().Format(time.RFC3339),
+	}
+	if err := s.Notifications.Produce(ctx, kafka.TopicUserPreferencesUpdated, prefs.UserID.String(), event); err != nil {
+		slog.Error("Failed to publish user.preferences.updated event", "user_id", prefs.UserID, "error", err)
+	}
+}
+
+// applyChannels sets the channels in update on current and returns the
+// names of those that changed. Unknown channels are added to invalid.
+func applyChannels(field string, current, update map[string]bool, invalid map[string]string) []string {
+	var changed []string
+	for channel, on := range update {
+		name := field + "." + channel
+		if !slices.Contains(PreferenceChannels, channel) {
+			invalid[name] = "channel must be EMAIL, SMS or PUSH"
+			continue
+		}
+		if current[channel] != on {
+			current[channel] = on
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// canonicalLocale returns a language tag in its canonical case, accepting
+// underscores for hyphens
+func canonicalLocale(value string) (string, bool) {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(value), "_", "-"), "-")
+	for i, part := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(part)
+		case len(part) == 4:
+			parts[i] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		default:
+			parts[i] = strings.ToUpper(part)
+		}
+	}
+	locale := strings.Join(parts, "-")
+	return locale, localePattern.MatchString(locale)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPreferencesRepository is an in-memory PreferencesRepository
+type memoryPreferencesRepository struct {
+	prefs map[uuid.UUID]model.UserPreferences
+	saves int
+}
+
+func (r *memoryPreferencesRepository) GetPreferences(userID uuid.UUID) (*model.UserPreferences, error) {
+	prefs, ok := r.prefs[userID]
+	if !ok {
+		return nil, nil
+	}
+	return &prefs, nil
+}
+
+func (r *memoryPreferencesRepository) SavePreferences(prefs *model.UserPreferences) error {
+	r.prefs[prefs.UserID] = *prefs
+	r.saves++
+	return nil
+}
+
+func newPreferencesService() (*PreferencesService, *memoryPreferencesRepository, *recordingPublisher, *model.User) {
+	repo := &memoryPreferencesRepository{prefs: map[uuid.UUID]model.UserPreferences{}}
+	users := new(MockUserRepository)
+	publisher := &recordingPublisher{}
+	user := &model.User{ID: uuid.New(), Email: "ada@example.com"}
+	users.On("FindByID", user.ID.String()).Return(user, nil)
+	users.On("FindByID", "missing").Return(nil, assert.AnError)
+	svc := NewPreferencesService(repo, users, publisher)
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return svc, repo, publisher, user
+}
+
+func TestPreferences_Defaults(t *testing.T) {
+	svc, _, _, user := newPreferencesService()
+
+	prefs, err := svc.GetPreferences(user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, prefs.UserID)
+	assert.Equal(t, "en-US", prefs.Locale)
+	assert.Equal(t, "USD", prefs.DisplayCurrency)
+	assert.Equal(t, map[string]bool{ChannelEmail: false, ChannelSMS: false, ChannelPush: false}, prefs.MarketingOptIns)
+	assert.Equal(t, map[string]bool{ChannelEmail: true, ChannelSMS: true, ChannelPush: true}, prefs.Notifications)
+	assert.Nil(t, prefs.UpdatedAt)
+
+	prefs.Notifications[ChannelSMS] = false
+	assert.True(t, svc.Defaults.Notifications[ChannelSMS], "the defaults are copied")
+
+	_, err = svc.GetPreferences("missing")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	svc, repo, publisher, user := newPreferencesService()
+	ctx := context.Background()
+	locale, currency := "en_gb", "gbp"
+
+	prefs, err := svc.UpdatePreferences(ctx, user.ID.String(), PreferencesUpdate{
+		Locale:          &locale,
+		DisplayCurrency: &currency,
+		MarketingOptIns: map[string]bool{ChannelEmail: true},
+		Notifications:   map[string]bool{ChannelSMS: false, ChannelPush: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "en-GB", prefs.Locale)
+	assert.Equal(t, "GBP", prefs.DisplayCurrency)
+	assert.True(t, prefs.MarketingOptIns[ChannelEmail])
+	assert.False(t, prefs.MarketingOptIns[ChannelSMS])
+	assert.Equal(t, map[string]bool{ChannelEmail: true, ChannelSMS: false, ChannelPush: true}, prefs.Notifications)
+	require.NotNil(t, prefs.UpdatedAt)
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, kafka.TopicUserPreferencesUpdated, publisher.topics[0])
+	event := publisher.events[0].(kafka.UserPreferencesUpdatedEvent)
+	assert.Equal(t, user.ID.String(), event.UserID)
+	assert.Equal(t, []string{"display_currency", "locale", "marketing_opt_ins.EMAIL", "notifications.SMS"}, event.Fields,
+		"channels sent with their current setting did not change")
+	assert.Equal(t, "en-GB", event.Locale)
+	assert.False(t, event.Notifications[ChannelSMS])
+
+	saved, err := svc.GetPreferences(user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, prefs, saved)
+
+	// Sending the saved values again changes nothing
+	locale = "en-GB"
+	_, err = svc.UpdatePreferences(ctx, user.ID.String(), PreferencesUpdate{Locale: &locale})
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.saves)
+	assert.Len(t, publisher.events, 1)
+}
+
+func TestUpdatePreferences_Validation(t *testing.T) {
+	svc, repo, publisher, user := newPreferencesService()
+	ctx := context.Background()
+	locale, currency := "english", "US"
+
+	_, err := svc.UpdatePreferences(ctx, user.ID.String(), PreferencesUpdate{
+		Locale:          &locale,
+		DisplayCurrency: &currency,
+		MarketingOptIns: map[string]bool{"FAX": true, ChannelEmail: true},
+	})
+	var invalid *ProfileValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, invalid.Fields, PreferenceFieldLocale)
+	assert.Contains(t, invalid.Fields, PreferenceFieldDisplayCurrency)
+	assert.Contains(t, invalid.Fields, "marketing_opt_ins.FAX")
+	assert.Len(t, invalid.Fields, 3)
+
+	// Every notification channel cannot be turned off
+	_, err = svc.UpdatePreferences(ctx, user.ID.String(), PreferencesUpdate{
+		Notifications: map[string]bool{ChannelEmail: false, ChannelSMS: false, ChannelPush: false},
+	})
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, invalid.Fields, PreferenceFieldNotifications)
+	assert.Zero(t, repo.saves, "nothing is saved when any field is invalid")
+	assert.Empty(t, publisher.events)
+
+	for value, want := range map[string]string{"fr": "fr", "zh-hant-tw": "zh-Hant-TW", "es-419": "es-419", " pt_BR ": "pt-BR"} {
+		got, ok := canonicalLocale(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, want, got)
+	}
+	for _, value := range []string{"", "e", "en-", "en-GBR", "en-GB-x"} {
+		_, ok := canonicalLocale(value)
+		assert.False(t, ok, value)
+	}
+}
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+const (
+	// PhoneVerificationExpiry is how long a texted phone verification code stays valid
+	PhoneVerificationExpiry = 10 * time.Minute
+	// MaxPhoneVerificationAttempts is the number of wrong codes before a verification is locked
+	MaxPhoneVerificationAttempts = 5
+	// PhoneVerificationTemplate is the notification template for phone verification codes
+	PhoneVerificationTemplate = "PHONE_VERIFICATION"
+	// MinCustomerAge is the youngest a customer can be
+	MinCustomerAge = 18
+	// maxCustomerAge rejects dates of birth that are almost certainly typos
+	maxCustomerAge = 120
+	// ProfileHistorySize is how many profile changes the history returns
+	ProfileHistorySize = 50
+	// KYCVerified is the KYC status of a user whose identity has been checked
+	KYCVerified = "VERIFIED"
+
+	dateOfBirthLayout  = "2006-01-02"
+	phoneFormatMessage = "must be an international number starting with + and the country code"
+)
+
+// Profile fields, as named in validation errors, the change history and
+// user.updated events
+const (
+	ProfileFieldPhone        = "phone"
+	ProfileFieldDateOfBirth  = "date_of_birth"
+	ProfileFieldAddressLine1 = "address.line1"
+	ProfileFieldAddressLine2 = "address.line2"
+	ProfileFieldCity         = "address.city"
+	ProfileFieldPostalCode   = "address.postal_code"
+	ProfileFieldCountry      = "address.country"
+)
+
+var (
+	ErrPhoneVerificationInvalid     = errors.New("invalid or expired verification code")
+	ErrPhoneVerificationLocked      = errors.New("too many incorrect verification codes, please request a new one")
+	ErrPhoneVerificationUnavailable = errors.New("phone numbers cannot be verified right now, try again later")
+	ErrProfileNotFound              = errors.New("user not found")
+)
+
+var (
+	// phonePattern is an E.164 number: a + and up to 15 digits
+	phonePattern      = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+	phoneSeparators   = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+	postalCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,14}$`)
+	countryPattern    = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// ProfileValidationError lists the fields a profile update was rejected for,
+// with the reason for each
+type ProfileValidationError struct {
+	Fields map[string]string
+}
+
+func (e *ProfileValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return "invalid profile fields: " + strings.Join(fields, ", ")
+}
+
+// ProfileRepository stores profile details and their changes
+type ProfileRepository interface {
+	// UpdateProfile saves the user's profile details and records the changes
+	// in the same transaction
+	UpdateProfile(user *model.User, changes []model.ProfileChange) error
+	ListProfileChanges(userID uuid.UUID, limit int) ([]model.ProfileChange, error)
+}
+
+// Address is a user's postal address. Country is an ISO 3166 alpha-2 code.
+type Address struct {
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// Profile is what a user sees of their own details
+type Profile struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email" redact:"support,reveal=pii:read"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	// Support sees the last digits of the phone, enough to confirm it with the user
+	Phone         string   `json:"phone,omitempty" redact:"support=phone,reveal=pii:read"`
+	PhoneVerified bool     `json:"phone_verified"`
+	DateOfBirth   string   `json:"date_of_birth,omitempty" redact:"support,reveal=pii:read"`
+	Address       *Address `json:"address,omitempty" redact:"support,reveal=pii:read"`
+	KYCStatus     string   `json:"kyc_status"`
+	// MissingFields are the details the user has yet to add, so clients can
+	// ask for them a little at a time
+	MissingFields []string `json:"missing_fields"`
+}
+
+func newProfile(u *model.User) *Profile {
+	p := &Profile{
+		UserID:        u.ID,
+		Email:         u.Email,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Phone:         u.Phone,
+		PhoneVerified: u.Phone != "" && u.PhoneVerifiedAt != nil,
+		DateOfBirth:   formatDateOfBirth(u.DateOfBirth),
+		KYCStatus:     u.KYCStatus,
+		MissingFields: []string{},
+	}
+	if u.AddressLine1 != "" {
+		p.Address = &Address{Line1: u.AddressLine1, Line2: u.AddressLine2, City: u.City, PostalCode: u.PostalCode, Country: u.Country}
+	}
+	if !p.PhoneVerified {
+		p.MissingFields = append(p.MissingFields, ProfileFieldPhone)
+	}
+	if p.DateOfBirth == "" {
+		p.MissingFields = append(p.MissingFields, ProfileFieldDateOfBirth)
+	}
+	if p.Address == nil {
+		p.MissingFields = append(p.MissingFields, "address")
+	}
+	return p
+}
+
+// ProfileUpdate is a partial profile update; nil fields are left unchanged.
+// An address replaces the whole stored address.
+type ProfileUpdate struct {
+	Phone       *string
+	DateOfBirth *string // YYYY-MM-DD
+	Address     *Address
+}
+
+// PhoneVerificationInfo tells the client where a verification code was texted
+type PhoneVerificationInfo struct {
+	VerificationID string    `json:"verification_id"`
+	Phone          string    `json:"phone"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// ProfileUpdateResult is the profile after an update. PhoneVerification is
+// set when the update asked for a new phone number, which is only saved once
+// the code texted to it is confirmed.
+type ProfileUpdateResult struct {
+	Profile           *Profile               `json:"profile"`
+	PhoneVerification *PhoneVerificationInfo `json:"phone_verification,omitempty"`
+}
+
+// ProfileService lets users fill in and change their profile details. Changes
+// are kept as history and announced on the user.updated topic for KYC and
+// notifications.
+type ProfileService struct {
+	Repo  ProfileRepository
+	Users UserRepository
+	// Notifications publishes user.updated events
+	Notifications EventPublisher
+	// New phone numbers are confirmed with a code texted through SMS and
+	// kept in Codes; without either, phone numbers cannot be changed.
+	// CodeLimiter bounds the codes a user can have sent, and CodeAttempts
+	// the wrong codes they can enter.
+	SMS          SMSProvider
+	Codes        PhoneCodeStore
+	CodeLimiter  *AccountLockout
+	CodeAttempts *AccountLockout
+
+	secret []byte
+	now    func() time.Time
+}
+
+// NewProfileService texts phone verification codes through the notification
+// service and keeps them in memory until Codes is replaced with a shared store
+func NewProfileService(repo ProfileRepository, users UserRepository, notifications EventPublisher, secret string) *ProfileService {
+	s := &ProfileService{
+		Repo:          repo,
+		Users:         users,
+		Notifications: notifications,
+		Codes:         NewMemoryPhoneCodeStore(),
+		CodeLimiter:   DefaultPhoneCodeLimiter(),
+		CodeAttempts:  DefaultPhoneCodeAttempts(),
+		secret:        []byte(secret),
+		now:           time.Now,
+	}
+	if notifications != nil {
+		s.SMS = NotificationSMSProvider{Publisher: notifications}
+	}
+	return s
+}
+
+// GetProfile returns the user's profile
+func (s *ProfileService) GetProfile(userID string) (*Profile, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	return newProfile(user), nil
+}
+
+// UpdateProfile validates every field of the update before changing any.
+// Date of birth and address are saved straight away; the date of birth is
+// fixed once the user's identity is verified. A new phone number gets a
+// texted code and is saved by ConfirmPhone.
+func (s *ProfileService) UpdateProfile(ctx context.Context, userID string, update ProfileUpdate) (*ProfileUpdateResult, error) {
+	user, err := s.user(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+
+	invalid := map[string]string{}
+	var phone string
+	if update.Phone != nil {
+		phone = normalizePhone(*update.Phone)
+		if !phonePattern.MatchString(phone) {
+			invalid[ProfileFieldPhone] = phoneFormatMessage
+		}
+	}
+	var dateOfBirth string
+	if update.DateOfBirth != nil {
+		dateOfBirth = strings.TrimSpace(*update.DateOfBirth)
+		if msg := validateDateOfBirth(dateOfBirth, now); msg != "" {
+			invalid[ProfileFieldDateOfBirth] = msg
+		} else if user.KYCStatus == KYCVerified && dateOfBirth != formatDateOfBirth(user.DateOfBirth) {
+			invalid[ProfileFieldDateOfBirth] = "cannot be changed once your identity is verified; contact support"
+		}
+	}
+	var address Address
+	if update.Address != nil {
+		address = normalizeAddress(*update.Address)
+		for field, msg := range validateAddress(address) {
+			invalid[field] = msg
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, &ProfileValidationError{Fields: invalid}
+	}
+
+	verifyPhone := update.Phone != nil && (phone != user.Phone || user.PhoneVerifiedAt == nil)
+	if verifyPhone && !s.canVerifyPhones() {
+		return nil, ErrPhoneVerificationUnavailable
+	}
+
+	var changes []model.ProfileChange
+	set := func(field string, target *string, value string) {
+		if *target != value {
+			changes = append(changes, model.ProfileChange{UserID: user.ID, Field: field, OldValue: *target, NewValue: value, CreatedAt: now})
+			*target = value
+		}
+	}
+	if update.DateOfBirth != nil {
+		current := formatDateOfBirth(user.DateOfBirth)
+		set(ProfileFieldDateOfBirth, &current, dateOfBirth)
+		parsed, _ := time.Parse(dateOfBirthLayout, dateOfBirth)
+		user.DateOfBirth = &parsed
+	}
+	if update.Address != nil {
+		set(ProfileFieldAddressLine1, &user.AddressLine1, address.Line1)
+		set(ProfileFieldAddressLine2, &user.AddressLine2, address.Line2)
+		set(ProfileFieldCity, &user.City, address.City)
+		set(ProfileFieldPostalCode, &user.PostalCode, address.PostalCode)
+		set(ProfileFieldCountry, &user.Country, address.Country)
+	}
+	if len(changes) > 0 {
+		if err := s.Repo.UpdateProfile(user, changes); err != nil {
+			return nil, err
+		}
+		s.publishUpdated(ctx, user, changes)
+	}
+
+	result := &ProfileUpdateResult{Profile: newProfile(user)}
+	if verifyPhone {
+		if result.PhoneVerification, err = s.startPhoneVerification(ctx, user, phone); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ProfileHistory returns the user's recent profile changes, newest first
+func (s *ProfileService) ProfileHistory(userID string) ([]model.ProfileChange, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+	return s.Repo.ListProfileChanges(id, ProfileHistorySize)
+}
+
+func (s *ProfileService) user(userID string) (*model.User, error) {
+	user, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, ErrProfileNotFound
+	}
+	return user, nil
+}
+
+// publishUpdated announces saved profile changes. The changes are already
+// committed, so a failed publish is logged rather than failing the request.
+func (s *ProfileService) publishUpdated(ctx context.Context, user *model.User, changes []model.ProfileChange) {
+	if s.Notifications == nil {
+		return
+	}
+	event := kafka.UserUpdatedEvent{
+		UserID:    user.ID.String(),
+		Fields:    make([]string, 0, len(changes)),
+		Profile:   make(map[string]string, len(changes)),
+		Timestamp: s.now().Format(time.RFC3339),
+	}
+	for _, change := range changes {
+		event.Fields = append(event.Fields, change.Field)
+		event.Profile[change.Field] = change.NewValue
+	}
+	if err := s.Notifications.Produce(ctx, kafka.TopicUserUpdated, user.ID.String(), event); err != nil {
+		slog.Error("Failed to publish user.updated event", "user_id", user.ID, "error", err)
+	}
+}
+
+// validateDateOfBirth returns why value is not an acceptable date of birth,
+// or "" if it is
+func validateDateOfBirth(value string, now time.Time) string {
+	dob, err := time.Parse(dateOfBirthLayout, value)
+	if err != nil {
+		return "must be a date in YYYY-MM-DD format"
+	}
+	if dob.AddDate(MinCustomerAge, 0, 0).After(now) {
+		return fmt.Sprintf("you must be at least %d years old", MinCustomerAge)
+	}
+	if dob.AddDate(maxCustomerAge, 0, 0).Before(now) {
+		return "is too far in the past"
+	}
+	return ""
+}
+
+func formatDateOfBirth(dob *time.Time) string {
+	if dob == nil {
+		return ""
+	}
+	return dob.Format(dateOfBirthLayout)
+}
+
+func normalizeAddress(a Address) Address {
+	return Address{
+		Line1:      strings.TrimSpace(a.Line1),
+		Line2:      strings.TrimSpace(a.Line2),
+		City:       strings.TrimSpace(a.City),
+		PostalCode: strings.ToUpper(strings.TrimSpace(a.PostalCode)),
+		Country:    strings.ToUpper(strings.TrimSpace(a.Country)),
+	}
+}
+
+// validateAddress returns the reason each invalid address field was rejected
+func validateAddress(a Address) map[string]string {
+	invalid := map[string]string{}
+	for field, value := range map[string]string{ProfileFieldAddressLine1: a.Line1, ProfileFieldCity: a.City} {
+		if value == "" {
+			invalid[field] = "is required"
+		}
+	}
+	for field, value := range map[string]string{ProfileFieldAddressLine1: a.Line1, ProfileFieldAddressLine2: a.Line2, ProfileFieldCity: a.City} {
+		if utf8.RuneCountInString(value) > 100 {
+			invalid[field] = "must be at most 100 characters"
+		}
+	}
+	if !postalCodePattern.MatchString(a.PostalCode) {
+		invalid[ProfileFieldPostalCode] = "must be 2 to 15 letters, digits, spaces or hyphens"
+	}
+	if !countryPattern.MatchString(a.Country) {
+		invalid[ProfileFieldCountry] = "must be a two-letter ISO country code"
+	}
+	return invalid
+}
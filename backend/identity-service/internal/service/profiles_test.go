@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryProfileRepository is an in-memory ProfileRepository
+type memoryProfileRepository struct {
+	changes []model.ProfileChange
+	updates int
+}
+
+func (r *memoryProfileRepository) UpdateProfile(_ *model.User, changes []model.ProfileChange) error {
+	r.updates++
+	for _, change := range changes {
+		change.ID = uuid.New()
+		r.changes = append(r.changes, change)
+	}
+	return nil
+}
+
+func (r *memoryProfileRepository) ListProfileChanges(userID uuid.UUID, limit int) ([]model.ProfileChange, error) {
+	var out []model.ProfileChange
+	for i := len(r.changes) - 1; i >= 0 && len(out) < limit; i-- {
+		if r.changes[i].UserID == userID {
+			out = append(out, r.changes[i])
+		}
+	}
+	return out, nil
+}
+
+func newProfileService() (*ProfileService, *memoryProfileRepository, *recordingPublisher, *model.User) {
+	repo := &memoryProfileRepository{}
+	users := new(MockUserRepository)
+	publisher := &recordingPublisher{}
+	user := &model.User{ID: uuid.New(), Email: "ada@example.com", FirstName: "Ada", LastName: "Lovelace", KYCStatus: "UNVERIFIED"}
+	users.On("FindByID", user.ID.String()).Return(user, nil)
+	svc := NewProfileService(repo, users, publisher, "secret")
+	svc.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+	return svc, repo, publisher, user
+}
+
+// texted returns the last code texted, and the verification it belongs to
+func texted(t *testing.T, p *recordingPublisher, result *ProfileUpdateResult) (string, string) {
+	t.Helper()
+	require.NotNil(t, result.PhoneVerification)
+	require.NotEmpty(t, p.events)
+	event, ok := p.events[len(p.events)-1].(kafka.NotificationEvent)
+	require.True(t, ok)
+	assert.Equal(t, kafka.TopicNotificationSMS, p.topics[len(p.topics)-1])
+	assert.Equal(t, result.PhoneVerification.Phone, event.Recipient)
+	return result.PhoneVerification.VerificationID, event.Data["code"]
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestGetProfile_ListsMissingFields(t *testing.T) {
+	svc, _, _, user := newProfileService()
+
+	profile, err := svc.GetProfile(user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, profile.Email)
+	assert.Equal(t, []string{ProfileFieldPhone, ProfileFieldDateOfBirth, "address"}, profile.MissingFields)
+}
+
+func TestUpdateProfile_ValidatesEveryField(t *testing.T) {
+	svc, repo, publisher, user := newProfileService()
+
+	_, err := svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{
+		Phone:       strPtr("07700 900123"),
+		DateOfBirth: strPtr("2010-05-01"),
+		Address:     &Address{Line1: " ", City: "London", PostalCode: "SW1A 1AA", Country: "United Kingdom"},
+	})
+	var invalid *ProfileValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, invalid.Fields, ProfileFieldPhone)
+	assert.Contains(t, invalid.Fields[ProfileFieldDateOfBirth], "at least 18")
+	assert.Contains(t, invalid.Fields, ProfileFieldAddressLine1)
+	assert.Contains(t, invalid.Fields, ProfileFieldCountry)
+	assert.NotContains(t, invalid.Fields, ProfileFieldPostalCode)
+
+	// Nothing is saved or sent when any field is invalid
+	assert.Zero(t, repo.updates)
+	assert.Empty(t, publisher.events)
+
+	_, err = svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{DateOfBirth: strPtr("01/05/1990")})
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, invalid.Fields[ProfileFieldDateOfBirth], "YYYY-MM-DD")
+}
+
+func TestUpdateProfile_SavesAndPublishesChanges(t *testing.T) {
+	svc, repo, publisher, user := newProfileService()
+
+	result, err := svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{
+		DateOfBirth: strPtr("1990-05-01"),
+		Address:     &Address{Line1: "10 Downing Street", City: "London", PostalCode: "sw1a 2aa", Country: "gb"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, result.PhoneVerification)
+	assert.Equal(t, "1990-05-01", result.Profile.DateOfBirth)
+	assert.Equal(t, &Address{Line1: "10 Downing Street", City: "London", PostalCode: "SW1A 2AA", Country: "GB"}, result.Profile.Address)
+	assert.Equal(t, []string{ProfileFieldPhone}, result.Profile.MissingFields)
+
+	// One history entry per changed field; the empty second line is unchanged
+	history, err := svc.ProfileHistory(user.ID.String())
+	require.NoError(t, err)
+	assert.Len(t, history, 5)
+
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, kafka.TopicUserUpdated, publisher.topics[0])
+	event := publisher.events[0].(kafka.UserUpdatedEvent)
+	assert.Contains(t, event.Fields, ProfileFieldDateOfBirth)
+	assert.Equal(t, "GB", event.Profile[ProfileFieldCountry])
+
+	// Sending the same details again changes nothing
+	_, err = svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{DateOfBirth: strPtr("1990-05-01")})
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.updates)
+	assert.Len(t, publisher.events, 1)
+}
+
+func TestUpdateProfile_DateOfBirthFixedOnceVerified(t *testing.T) {
+	svc, _, _, user := newProfileService()
+	dob := time.Date(1990, 5, 1, 0, 0, 0, 0, time.UTC)
+	user.DateOfBirth = &dob
+	user.KYCStatus = KYCVerified
+
+	_, err := svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{DateOfBirth: strPtr("1991-05-01")})
+	var invalid *ProfileValidationError
+	require.ErrorAs(t, err, &invalid)
+	assert.Contains(t, invalid.Fields[ProfileFieldDateOfBirth], "contact support")
+
+	// Sending the verified date again is not a change
+	_, err = svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{DateOfBirth: strPtr("1990-05-01")})
+	assert.NoError(t, err)
+}
+
+func TestUpdateProfile_PhoneSavedOnceVerified(t *testing.T) {
+	svc, _, publisher, user := newProfileService()
+
+	result, err := svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{Phone: strPtr("+44 7700 900123")})
+	require.NoError(t, err)
+	assert.Empty(t, result.Profile.Phone)
+	assert.Equal(t, "+447700900123", result.PhoneVerification.Phone)
+	verificationID, code := texted(t, publisher, result)
+
+	// Another user cannot use the verification
+	_, err = svc.ConfirmPhone(context.Background(), uuid.New().String(), verificationID, code)
+	assert.ErrorIs(t, err, ErrPhoneVerificationInvalid)
+
+	profile, err := svc.ConfirmPhone(context.Background(), user.ID.String(), verificationID, code)
+	require.NoError(t, err)
+	assert.Equal(t, "+447700900123", profile.Phone)
+	assert.True(t, profile.PhoneVerified)
+	assert.Equal(t, kafka.TopicUserUpdated, publisher.topics[len(publisher.topics)-1])
+
+	// A code confirms a number once
+	_, err = svc.ConfirmPhone(context.Background(), user.ID.String(), verificationID, code)
+	assert.ErrorIs(t, err, ErrPhoneVerificationInvalid)
+
+	history, err := svc.ProfileHistory(user.ID.String())
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, ProfileFieldPhone, history[0].Field)
+
+	// The verified number needs no new code
+	result, err = svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{Phone: strPtr("+447700900123")})
+	require.NoError(t, err)
+	assert.Nil(t, result.PhoneVerification)
+}
+
+func TestConfirmPhone_LocksAfterWrongCodes(t *testing.T) {
+	svc, _, publisher, user := newProfileService()
+	result, err := svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{Phone: strPtr("+447700900123")})
+	require.NoError(t, err)
+	verificationID, code := texted(t, publisher, result)
+
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 1; i < MaxPhoneVerificationAttempts; i++ {
+		_, err = svc.ConfirmPhone(context.Background(), user.ID.String(), verificationID, wrong)
+		assert.ErrorIs(t, err, ErrPhoneVerificationInvalid)
+	}
+	_, err = svc.ConfirmPhone(context.Background(), user.ID.String(), verificationID, wrong)
+	assert.ErrorIs(t, err, ErrPhoneVerificationLocked)
+	_, err = svc.ConfirmPhone(context.Background(), user.ID.String(), verificationID, code)
+	assert.ErrorIs(t, err, ErrPhoneVerificationLocked)
+	assert.Empty(t, user.Phone)
+}
+
+func TestUpdateProfile_PhoneNeedsSMS(t *testing.T) {
+	svc, repo, _, user := newProfileService()
+	svc.SMS = nil
+
+	_, err := svc.UpdateProfile(context.Background(), user.ID.String(), ProfileUpdate{
+		Phone:       strPtr("+447700900123"),
+		DateOfBirth: strPtr("1990-05-01"),
+	})
+	assert.ErrorIs(t, err, ErrPhoneVerificationUnavailable)
+	assert.Zero(t, repo.updates)
+}
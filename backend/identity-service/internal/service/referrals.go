@@ -0,0 +1,380 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// ReferralInviteTTL is how long an invite link can be used to sign up
+	ReferralInviteTTL = 14 * 24 * time.Hour
+	// MaxReferralInvites is how many invites a user can create within
+	// ReferralInviteWindow
+	MaxReferralInvites   = 20
+	ReferralInviteWindow = 30 * 24 * time.Hour
+	// MaxReferralInviteSends caps how often one invite is emailed, including
+	// the first time
+	MaxReferralInviteSends = 3
+	// ReferralInviteTemplate is the notification that emails an invite
+	ReferralInviteTemplate = "REFERRAL_INVITE"
+
+	// referralCodeAlphabet leaves out characters that are easily confused
+	// when a code is read aloud or typed: 0/O and 1/I
+	referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	referralCodeLength   = 8
+)
+
+var (
+	ErrInvalidReferralInvite     = errors.New("invite is invalid, has expired, or was sent to another email")
+	ErrInvalidReferralCode       = errors.New("referral code is invalid")
+	ErrReferralInviteNotFound    = errors.New("invite not found")
+	ErrReferralInviteAccepted    = errors.New("invite has already been accepted")
+	ErrReferralInviteExists      = errors.New("you have already invited this email; resend the existing invite instead")
+	ErrReferralInviteeRegistered = errors.New("a user is already registered with that email")
+	ErrReferralInviteLimit       = errors.New("invite limit reached; try again later")
+	ErrReferralResendLimit       = errors.New("this invite has been sent the maximum number of times")
+	ErrReferralInviteChanged     = errors.New("invite was changed by another request; reload it and try again")
+)
+
+// ReferralRepository stores referral codes, invites and referrals
+type ReferralRepository interface {
+	GetReferralCode(userID uuid.UUID) (*model.ReferralCode, error)
+	FindReferralCode(code string) (*model.ReferralCode, error)
+	// CreateReferralCode returns gorm.ErrDuplicatedKey if the code is taken
+	// or the user already has one
+	CreateReferralCode(code *model.ReferralCode) error
+	CreateInvite(invite *model.ReferralInvite) error
+	GetInvite(id uuid.UUID) (*model.ReferralInvite, error)
+	FindInviteByTokenHash(hash string) (*model.ReferralInvite, error)
+	FindOpenInvite(inviterID uuid.UUID, email string) (*model.ReferralInvite, error)
+	ListInvites(inviterID uuid.UUID) ([]model.ReferralInvite, error)
+	CountInvitesSince(inviterID uuid.UUID, since time.Time) (int64, error)
+	// ResendInvite saves the invite if it is unaccepted and its stored send
+	// count is one less than the invite's, and reports whether it did
+	ResendInvite(invite *model.ReferralInvite) (bool, error)
+	// CreateReferral also marks the referral's invite accepted
+	CreateReferral(referral *model.Referral) error
+	DeviceSeen(referrerID uuid.UUID, deviceID string) (bool, error)
+	ListReferrals(referrerID uuid.UUID) ([]model.Referral, error)
+	FindReferralByReferee(refereeID uuid.UUID) (*model.Referral, error)
+	// CompleteReferral marks a pending referral completed and reports
+	// whether it did
+	CompleteReferral(id uuid.UUID, paymentID string, completedAt time.Time) (bool, error)
+}
+
+// ReferralService hands out referral codes and invites, attributes new users
+// to the user who referred them, and announces a referral's reward once the
+// new user makes their first transfer
+type ReferralService struct {
+	Repo          ReferralRepository
+	Users         UserRepository
+	Notifications EventPublisher
+	// InviteURL is the sign-up page invite links point at; the token is
+	// added as the invite_token query parameter
+	InviteURL string
+
+	now func() time.Time
+}
+
+func NewReferralService(repo ReferralRepository, users UserRepository, notifications EventPublisher, inviteURL string) *ReferralService {
+	return &ReferralService{Repo: repo, Users: users, Notifications: notifications, InviteURL: inviteURL, now: time.Now}
+}
+
+// ReferralSource is who referred a user signing up, and through what
+type ReferralSource struct {
+	ReferrerID uuid.UUID
+	Invite     *model.ReferralInvite
+	Code       string
+}
+
+// GetReferralCode returns the user's referral code, creating it on first use
+func (s *ReferralService) GetReferralCode(userID string) (*model.ReferralCode, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	code, err := s.Repo.GetReferralCode(id)
+	if err == nil {
+		return code, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// A clash is either a code already in use, which a fresh code fixes, or a
+	// concurrent request having created this user's code, which is returned
+	for attempt := 0; attempt < 5; attempt++ {
+		value, err := newReferralCode()
+		if err != nil {
+			return nil, err
+		}
+		code = &model.ReferralCode{UserID: id, Code: value}
+		err = s.Repo.CreateReferralCode(code)
+		if err == nil {
+			return code, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, err
+		}
+		if existing, err := s.Repo.GetReferralCode(id); err == nil {
+			return existing, nil
+		}
+	}
+	return nil, errors.New("failed to generate a unique referral code")
+}
+
+func newReferralCode() (string, error) {
+	b := make([]byte, referralCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// The alphabet has 32 characters, so every byte maps without bias
+	for i := range b {
+		b[i] = referralCodeAlphabet[int(b[i])%len(referralCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// CreateInvite emails an invite to sign up. A user can create
+// MaxReferralInvites invites in any ReferralInviteWindow, cannot invite
+// someone already registered, and resends an existing invite rather than
+// creating a second one.
+func (s *ReferralService) CreateInvite(ctx context.Context, userID, email string) (*model.ReferralInvite, error) {
+	inviter, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	now := s.now()
+
+	sent, err := s.Repo.CountInvitesSince(inviter.ID, now.Add(-ReferralInviteWindow))
+	if err != nil {
+		return nil, err
+	}
+	if sent >= MaxReferralInvites {
+		return nil, ErrReferralInviteLimit
+	}
+	if _, err := s.Users.FindByEmail(email); err == nil {
+		return nil, ErrReferralInviteeRegistered
+	}
+	if _, err := s.Repo.FindOpenInvite(inviter.ID, email); err == nil {
+		return nil, ErrReferralInviteExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	invite := &model.ReferralInvite{
+		InviterID: inviter.ID,
+		Email:     email,
+		TokenHash: hashClientSecret(token),
+		Status:    model.ReferralInviteSent,
+		SendCount: 1,
+		ExpiresAt: now.Add(ReferralInviteTTL),
+	}
+	if err := s.Repo.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+	if err := s.sendInvite(ctx, inviter, invite, token, now); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// ResendInvite emails an unaccepted invite again with a new link, which
+// restarts its expiry. The previous link stops working.
+func (s *ReferralService) ResendInvite(ctx context.Context, userID, inviteID string) (*model.ReferralInvite, error) {
+	inviter, err := s.Users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(inviteID)
+	if err != nil {
+		return nil, ErrReferralInviteNotFound
+	}
+	invite, err := s.Repo.GetInvite(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && invite.InviterID != inviter.ID) {
+		return nil, ErrReferralInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invite.Status != model.ReferralInviteSent {
+		return nil, ErrReferralInviteAccepted
+	}
+	if invite.SendCount >= MaxReferralInviteSends {
+		return nil, ErrReferralResendLimit
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	invite.TokenHash = hashClientSecret(token)
+	invite.SendCount++
+	invite.ExpiresAt = now.Add(ReferralInviteTTL)
+	invite.UpdatedAt = now
+	resent, err := s.Repo.ResendInvite(invite)
+	if err != nil {
+		return nil, err
+	}
+	if !resent {
+		return nil, ErrReferralInviteChanged
+	}
+	if err := s.sendInvite(ctx, inviter, invite, token, now); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// sendInvite emails the invite link through the notification pipeline
+func (s *ReferralService) sendInvite(ctx context.Context, inviter *model.User, invite *model.ReferralInvite, token string, now time.Time) error {
+	link := s.InviteURL + "?invite_token=" + url.QueryEscape(token)
+	if err := s.Notifications.Produce(ctx, kafka.TopicNotificationEmail, inviter.ID.String(), kafka.NotificationEvent{
+		UserID:    inviter.ID.String(),
+		Channel:   "EMAIL",
+		Recipient: invite.Email,
+		Template:  ReferralInviteTemplate,
+		Data: map[string]string{
+			"inviter_name": strings.TrimSpace(inviter.FirstName + " " + inviter.LastName),
+			"link":         link,
+			"expires_at":   invite.ExpiresAt.Format(time.RFC3339),
+		},
+		Timestamp: now.Format(time.RFC3339),
+	}); err != nil {
+		return fmt.Errorf("failed to send invite: %w", err)
+	}
+	return nil
+}
+
+// ListInvites returns the invites a user has created, newest first
+func (s *ReferralService) ListInvites(userID string) ([]model.ReferralInvite, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	return s.Repo.ListInvites(id)
+}
+
+// ListReferrals returns the users a user has referred, newest first
+func (s *ReferralService) ListReferrals(userID string) ([]model.Referral, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	return s.Repo.ListReferrals(id)
+}
+
+// ResolveReferral checks the invite token or referral code given when signing
+// up, before the user is created. An invite must be unaccepted, unexpired and
+// sent to the email signing up. An invite token takes precedence over a code.
+func (s *ReferralService) ResolveReferral(email, inviteToken, code string) (*ReferralSource, error) {
+	if inviteToken != "" {
+		invite, err := s.Repo.FindInviteByTokenHash(hashClientSecret(inviteToken))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidReferralInvite
+		}
+		if err != nil {
+			return nil, err
+		}
+		if invite.Status != model.ReferralInviteSent || !s.now().Before(invite.ExpiresAt) ||
+			!strings.EqualFold(invite.Email, strings.TrimSpace(email)) {
+			return nil, ErrInvalidReferralInvite
+		}
+		return &ReferralSource{ReferrerID: invite.InviterID, Invite: invite}, nil
+	}
+
+	rc, err := s.Repo.FindReferralCode(strings.ToUpper(strings.TrimSpace(code)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidReferralCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ReferralSource{ReferrerID: rc.UserID, Code: rc.Code}, nil
+}
+
+// Attribute records that a new user was referred. deviceID is the device
+// the user signed up from, if the client identified it. A device the
+// referrer has signed in from, or that was already used to sign up through a
+// referral, marks the referral rejected so it earns no reward.
+func (s *ReferralService) Attribute(user *model.User, source *ReferralSource, deviceID string) (*model.Referral, error) {
+	referral := &model.Referral{
+		ReferrerID: source.ReferrerID,
+		RefereeID:  user.ID,
+		Code:       source.Code,
+		DeviceID:   deviceID,
+		Status:     model.ReferralPending,
+	}
+	if source.Invite != nil {
+		referral.InviteID = &source.Invite.ID
+	}
+	if deviceID != "" {
+		seen, err := s.Repo.DeviceSeen(source.ReferrerID, deviceID)
+		if err != nil {
+			return nil, err
+		}
+		if seen {
+			referral.Status = model.ReferralRejected
+			referral.RejectReason = model.ReferralRejectDuplicateDevice
+			slog.Warn("Referral rejected for a duplicate device", "referrer_id", source.ReferrerID, "referee_id", user.ID)
+		}
+	}
+	if err := s.Repo.CreateReferral(referral); err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// CompleteReferral rewards the referral of the user who made a completed
+// payment, if they were referred and the referral is still pending, which
+// makes this their first transfer. The referral.completed event is published
+// before the referral is marked completed, so a failure in between publishes
+// it again for the next payment; consumers deduplicate on the referral ID.
+func (s *ReferralService) CompleteReferral(ctx context.Context, payment kafka.PaymentEvent) error {
+	refereeID, err := uuid.Parse(payment.UserID)
+	if err != nil {
+		return nil
+	}
+	referral, err := s.Repo.FindReferralByReferee(refereeID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if referral.Status != model.ReferralPending {
+		return nil
+	}
+
+	now := s.now()
+	if err := s.Notifications.Produce(ctx, kafka.TopicReferralCompleted, referral.ReferrerID.String(), kafka.ReferralEvent{
+		ReferralID:     referral.ID.String(),
+		ReferrerUserID: referral.ReferrerID.String(),
+		RefereeUserID:  referral.RefereeID.String(),
+		PaymentID:      payment.PaymentID,
+		Timestamp:      now.Format(time.RFC3339),
+	}); err != nil {
+		return fmt.Errorf("failed to publish referral completed event: %w", err)
+	}
+	if _, err := s.Repo.CompleteReferral(referral.ID, payment.PaymentID, now); err != nil {
+		return err
+	}
+	slog.Info("Referral completed", "referral_id", referral.ID, "payment_id", payment.PaymentID)
+	return nil
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryReferralRepository is an in-memory ReferralRepository. loginDevices
+// stands in for the devices users have signed in from.
+type memoryReferralRepository struct {
+	codes        map[uuid.UUID]model.ReferralCode
+	invites      map[uuid.UUID]model.ReferralInvite
+	referrals    map[uuid.UUID]model.Referral
+	loginDevices map[uuid.UUID][]string
+}
+
+func newMemoryReferralRepository() *memoryReferralRepository {
+	return &memoryReferralRepository{
+		codes:        map[uuid.UUID]model.ReferralCode{},
+		invites:      map[uuid.UUID]model.ReferralInvite{},
+		referrals:    map[uuid.UUID]model.Referral{},
+		loginDevices: map[uuid.UUID][]string{},
+	}
+}
+
+func (r *memoryReferralRepository) GetReferralCode(userID uuid.UUID) (*model.ReferralCode, error) {
+	code, ok := r.codes[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &code, nil
+}
+
+func (r *memoryReferralRepository) FindReferralCode(code string) (*model.ReferralCode, error) {
+	for _, c := range r.codes {
+		if c.Code == code {
+			return &c, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryReferralRepository) CreateReferralCode(code *model.ReferralCode) error {
+	if _, err := r.FindReferralCode(code.Code); err == nil {
+		return gorm.ErrDuplicatedKey
+	}
+	if _, ok := r.codes[code.UserID]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	r.codes[code.UserID] = *code
+	return nil
+}
+
+func (r *memoryReferralRepository) CreateInvite(invite *model.ReferralInvite) error {
+	invite.ID = uuid.New()
+	invite.CreatedAt = time.Now()
+	r.invites[invite.ID] = *invite
+	return nil
+}
+
+func (r *memoryReferralRepository) GetInvite(id uuid.UUID) (*model.ReferralInvite, error) {
+	invite, ok := r.invites[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &invite, nil
+}
+
+func (r *memoryReferralRepository) FindInviteByTokenHash(hash string) (*model.ReferralInvite, error) {
+	for _, i := range r.invites {
+		if i.TokenHash == hash {
+			return &i, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryReferralRepository) FindOpenInvite(inviterID uuid.UUID, email string) (*model.ReferralInvite, error) {
+	for _, i := range r.invites {
+		if i.InviterID == inviterID && i.Email == email && i.Status == model.ReferralInviteSent {
+			return &i, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryReferralRepository) ListInvites(inviterID uuid.UUID) ([]model.ReferralInvite, error) {
+	var invites []model.ReferralInvite
+	for _, i := range r.invites {
+		if i.InviterID == inviterID {
+			invites = append(invites, i)
+		}
+	}
+	return invites, nil
+}
+
+func (r *memoryReferralRepository) CountInvitesSince(inviterID uuid.UUID, since time.Time) (int64, error) {
+	var n int64
+	for _, i := range r.invites {
+		if i.InviterID == inviterID && !i.CreatedAt.Before(since) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *memoryReferralRepository) ResendInvite(invite *model.ReferralInvite) (bool, error) {
+	stored := r.invites[invite.ID]
+	if stored.Status != model.ReferralInviteSent || stored.SendCount != invite.SendCount-1 {
+		return false, nil
+	}
+	r.invites[invite.ID] = *invite
+	return true, nil
+}
+
+func (r *memoryReferralRepository) CreateReferral(referral *model.Referral) error {
+	if referral.InviteID != nil {
+		invite := r.invites[*referral.InviteID]
+		if invite.Status != model.ReferralInviteSent {
+			return gorm.ErrRecordNotFound
+		}
+		now := time.Now()
+		invite.Status = model.ReferralInviteAccepted
+		invite.AcceptedBy = &referral.RefereeID
+		invite.AcceptedAt = &now
+		r.invites[invite.ID] = invite
+	}
+	referral.ID = uuid.New()
+	r.referrals[referral.ID] = *referral
+	return nil
+}
+
+func (r *memoryReferralRepository) DeviceSeen(referrerID uuid.UUID, deviceID string) (bool, error) {
+	for _, d := range r.loginDevices[referrerID] {
+		if d == deviceID {
+			return true, nil
+		}
+	}
+	for _, ref := range r.referrals {
+		if ref.DeviceID == deviceID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryReferralRepository) ListReferrals(referrerID uuid.UUID) ([]model.Referral, error) {
+	var referrals []model.Referral
+	for _, ref := range r.referrals {
+		if ref.ReferrerID == referrerID {
+			referrals = append(referrals, ref)
+		}
+	}
+	return referrals, nil
+}
+
+func (r *memoryReferralRepository) FindReferralByReferee(refereeID uuid.UUID) (*model.Referral, error) {
+	for _, ref := range r.referrals {
+		if ref.RefereeID == refereeID {
+			return &ref, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryReferralRepository) CompleteReferral(id uuid.UUID, paymentID string, completedAt time.Time) (bool, error) {
+	ref := r.referrals[id]
+	if ref.Status != model.ReferralPending {
+		return false, nil
+	}
+	ref.Status = model.ReferralCompleted
+	ref.PaymentID = paymentID
+	ref.CompletedAt = &completedAt
+	r.referrals[id] = ref
+	return true, nil
+}
+
+// recordingPublisher records every event produced, on any topic
+type recordingPublisher struct {
+	topics []string
+	events []interface{}
+	err    error
+}
+
+func (p *recordingPublisher) Produce(_ context.Context, topic string, _ string, value interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.topics = append(p.topics, topic)
+	p.events = append(p.events, value)
+	return nil
+}
+
+// lastInviteToken returns the token in the link of the last invite emailed
+func (p *recordingPublisher) lastInviteToken(t *testing.T) string {
+	t.Helper()
+	require.NotEmpty(t, p.events)
+	event, ok := p.events[len(p.events)-1].(kafka.NotificationEvent)
+	require.True(t, ok)
+	link, err := url.Parse(event.Data["link"])
+	require.NoError(t, err)
+	return link.Query().Get("invite_token")
+}
+
+func newReferralService() (*ReferralService, *memoryReferralRepository, *MockUserRepository, *recordingPublisher, *model.User) {
+	repo := newMemoryReferralRepository()
+	users := new(MockUserRepository)
+	publisher := &recordingPublisher{}
+	inviter := &model.User{ID: uuid.New(), Email: "ada@example.com", FirstName: "Ada", LastName: "Lovelace"}
+	users.On("FindByID", inviter.ID.String()).Return(inviter, nil)
+	users.On("FindByEmail", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	svc := NewReferralService(repo, users, publisher, "https://bank.test/register")
+	return svc, repo, users, publisher, inviter
+}
+
+func TestGetReferralCode_CreatedOnce(t *testing.T) {
+	svc, _, _, _, user := newReferralService()
+
+	code, err := svc.GetReferralCode(user.ID.String())
+	require.NoError(t, err)
+	assert.Len(t, code.Code, referralCodeLength)
+	for _, ch := range code.Code {
+		assert.True(t, strings.ContainsRune(referralCodeAlphabet, ch))
+	}
+
+	again, err := svc.GetReferralCode(user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, code.Code, again.Code)
+}
+
+func TestReferralInvite_AcceptedOnSignup(t *testing.T) {
+	svc, repo, _, publisher, inviter := newReferralService()
+
+	invite, err := svc.CreateInvite(context.Background(), inviter.ID.String(), " Grace@Example.com ")
+	require.NoError(t, err)
+	assert.Equal(t, "grace@example.com", invite.Email)
+	require.Len(t, publisher.events, 1)
+	email := publisher.events[0].(kafka.NotificationEvent)
+	assert.Equal(t, ReferralInviteTemplate, email.Template)
+	assert.Equal(t, "grace@example.com", email.Recipient)
+	assert.Equal(t, "Ada Lovelace", email.Data["inviter_name"])
+	token := publisher.lastInviteToken(t)
+	assert.NotEqual(t, token, invite.TokenHash, "only the hash is stored")
+
+	_, err = svc.ResolveReferral("someone.else@example.com", token, "")
+	assert.ErrorIs(t, err, ErrInvalidReferralInvite)
+
+	source, err := svc.ResolveReferral("GRACE@example.com", token, "")
+	require.NoError(t, err)
+	assert.Equal(t, inviter.ID, source.ReferrerID)
+
+	referee := &model.User{ID: uuid.New(), Email: "grace@example.com"}
+	referral, err := svc.Attribute(referee, source, "device-grace")
+	require.NoError(t, err)
+	assert.Equal(t, model.ReferralPending, referral.Status)
+	assert.Equal(t, invite.ID, *referral.InviteID)
+	assert.Equal(t, model.ReferralInviteAccepted, repo.invites[invite.ID].Status)
+	assert.Equal(t, referee.ID, *repo.invites[invite.ID].AcceptedBy)
+
+	_, err = svc.ResolveReferral("grace@example.com", token, "")
+	assert.ErrorIs(t, err, ErrInvalidReferralInvite, "an invite is used once")
+	_, err = svc.ResendInvite(context.Background(), inviter.ID.String(), invite.ID.String())
+	assert.ErrorIs(t, err, ErrReferralInviteAccepted)
+}
+
+func TestResolveReferral_Code(t *testing.T) {
+	svc, _, _, _, referrer := newReferralService()
+	code, err := svc.GetReferralCode(referrer.ID.String())
+	require.NoError(t, err)
+
+	source, err := svc.ResolveReferral("new@example.com", "", strings.ToLower(code.Code))
+	require.NoError(t, err)
+	assert.Equal(t, referrer.ID, source.ReferrerID)
+	assert.Equal(t, code.Code, source.Code)
+
+	_, err = svc.ResolveReferral("new@example.com", "", "NOPE2345")
+	assert.ErrorIs(t, err, ErrInvalidReferralCode)
+}
+
+func TestResolveReferral_ExpiredInvite(t *testing.T) {
+	svc, _, _, publisher, inviter := newReferralService()
+	_, err := svc.CreateInvite(context.Background(), inviter.ID.String(), "grace@example.com")
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return time.Now().Add(ReferralInviteTTL + time.Minute) }
+	_, err = svc.ResolveReferral("grace@example.com", publisher.lastInviteToken(t), "")
+	assert.ErrorIs(t, err, ErrInvalidReferralInvite)
+}
+
+func TestCreateInvite_AbuseLimits(t *testing.T) {
+	svc, _, users, _, inviter := newReferralService()
+	ctx := context.Background()
+
+	_, err := svc.CreateInvite(ctx, inviter.ID.String(), "grace@example.com")
+	require.NoError(t, err)
+	_, err = svc.CreateInvite(ctx, inviter.ID.String(), "grace@example.com")
+	assert.ErrorIs(t, err, ErrReferralInviteExists)
+
+	for i := 1; i < MaxReferralInvites; i++ {
+		_, err := svc.CreateInvite(ctx, inviter.ID.String(), uuid.NewString()+"@example.com")
+		require.NoError(t, err)
+	}
+	_, err = svc.CreateInvite(ctx, inviter.ID.String(), "one.more@example.com")
+	assert.ErrorIs(t, err, ErrReferralInviteLimit)
+
+	// Invites older than the window no longer count
+	svc.now = func() time.Time { return time.Now().Add(ReferralInviteWindow + time.Minute) }
+	_, err = svc.CreateInvite(ctx, inviter.ID.String(), "one.more@example.com")
+	require.NoError(t, err)
+
+	users.ExpectedCalls = nil
+	users.On("FindByID", inviter.ID.String()).Return(inviter, nil)
+	users.On("FindByEmail", "member@example.com").Return(&model.User{ID: uuid.New()}, nil)
+	_, err = svc.CreateInvite(ctx, inviter.ID.String(), "member@example.com")
+	assert.ErrorIs(t, err, ErrReferralInviteeRegistered)
+}
+
+func TestResendInvite_ReplacesLink(t *testing.T) {
+	svc, _, _, publisher, inviter := newReferralService()
+	ctx := context.Background()
+	invite, err := svc.CreateInvite(ctx, inviter.ID.String(), "grace@example.com")
+	require.NoError(t, err)
+	first := publisher.lastInviteToken(t)
+
+	resent, err := svc.ResendInvite(ctx, inviter.ID.String(), invite.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, 2, resent.SendCount)
+	second := publisher.lastInviteToken(t)
+	assert.NotEqual(t, first, second)
+
+	_, err = svc.ResolveReferral("grace@example.com", first, "")
+	assert.ErrorIs(t, err, ErrInvalidReferralInvite, "the previous link stops working")
+	_, err = svc.ResolveReferral("grace@example.com", second, "")
+	require.NoError(t, err)
+
+	_, err = svc.ResendInvite(ctx, inviter.ID.String(), invite.ID.String())
+	require.NoError(t, err)
+	_, err = svc.ResendInvite(ctx, inviter.ID.String(), invite.ID.String())
+	assert.ErrorIs(t, err, ErrReferralResendLimit)
+
+	other := &model.User{ID: uuid.New()}
+	svc.Users.(*MockUserRepository).On("FindByID", other.ID.String()).Return(other, nil)
+	_, err = svc.ResendInvite(ctx, other.ID.String(), invite.ID.String())
+	assert.ErrorIs(t, err, ErrReferralInviteNotFound, "only the inviter can resend")
+}
+
+func TestAttribute_DuplicateDeviceRejected(t *testing.T) {
+	svc, repo, _, _, referrer := newReferralService()
+	repo.loginDevices[referrer.ID] = []string{"referrer-phone"}
+	source := &ReferralSource{ReferrerID: referrer.ID, Code: "K7QM2XRA"}
+
+	own, err := svc.Attribute(&model.User{ID: uuid.New()}, source, "referrer-phone")
+	require.NoError(t, err)
+	assert.Equal(t, model.ReferralRejected, own.Status)
+	assert.Equal(t, model.ReferralRejectDuplicateDevice, own.RejectReason)
+
+	first, err := svc.Attribute(&model.User{ID: uuid.New()}, source, "friend-phone")
+	require.NoError(t, err)
+	assert.Equal(t, model.ReferralPending, first.Status)
+	again, err := svc.Attribute(&model.User{ID: uuid.New()}, source, "friend-phone")
+	require.NoError(t, err)
+	assert.Equal(t, model.ReferralRejected, again.Status, "a device can sign up through a referral once")
+
+	unknown, err := svc.Attribute(&model.User{ID: uuid.New()}, source, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.ReferralPending, unknown.Status)
+}
+
+func TestCompleteReferral_PublishesOnFirstPayment(t *testing.T) {
+	svc, repo, _, publisher, referrer := newReferralService()
+	ctx := context.Background()
+	referee := &model.User{ID: uuid.New()}
+	referral, err := svc.Attribute(referee, &ReferralSource{ReferrerID: referrer.ID}, "")
+	require.NoError(t, err)
+
+	publisher.err = errors.New("kafka unavailable")
+	err = svc.CompleteReferral(ctx, kafka.PaymentEvent{PaymentID: "pay-1", UserID: referee.ID.String()})
+	assert.Error(t, err)
+	assert.Equal(t, model.ReferralPending, repo.referrals[referral.ID].Status, "the next payment tries again")
+
+	publisher.err = nil
+	require.NoError(t, svc.CompleteReferral(ctx, kafka.PaymentEvent{PaymentID: "pay-2", UserID: referee.ID.String()}))
+	require.Len(t, publisher.events, 1)
+	assert.Equal(t, kafka.TopicReferralCompleted, publisher.topics[0])
+	event := publisher.events[0].(kafka.ReferralEvent)
+	assert.Equal(t, referral.ID.String(), event.ReferralID)
+	assert.Equal(t, referrer.ID.String(), event.ReferrerUserID)
+	assert.Equal(t, referee.ID.String(), event.RefereeUserID)
+	assert.Equal(t, "pay-2", event.PaymentID)
+	assert.Equal(t, model.ReferralCompleted, repo.referrals[referral.ID].Status)
+	assert.Equal(t, "pay-2", repo.referrals[referral.ID].PaymentID)
+
+	require.NoError(t, svc.CompleteReferral(ctx, kafka.PaymentEvent{PaymentID: "pay-3", UserID: referee.ID.String()}))
+	assert.Len(t, publisher.events, 1, "only the first transfer completes a referral")
+}
+
+func TestCompleteReferral_IgnoresOtherPayments(t *testing.T) {
+	svc, repo, _, publisher, referrer := newReferralService()
+	ctx := context.Background()
+	repo.loginDevices[referrer.ID] = []string{"referrer-phone"}
+	rejected := &model.User{ID: uuid.New()}
+	_, err := svc.Attribute(rejected, &ReferralSource{ReferrerID: referrer.ID}, "referrer-phone")
+	require.NoError(t, err)
+
+	require.NoError(t, svc.CompleteReferral(ctx, kafka.PaymentEvent{PaymentID: "pay-1", UserID: rejected.ID.String()}))
+	require.NoError(t, svc.CompleteReferral(ctx, kafka.PaymentEvent{PaymentID: "pay-2", UserID: uuid.NewString()}))
+	require.NoError(t, svc.CompleteReferral(ctx, kafka.PaymentEvent{PaymentID: "pay-3"}))
+	assert.Empty(t, publisher.events)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -61,10 +62,19 @@ func (s *AuthService) generateAccessToken(userID string) (string, error) {
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.accessTokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "neobank",
+			Issuer:    s.Audiences.Issuer,
+			Audience:  s.Audiences.User,
 		},
 	}
 
+	if s.Terms != nil {
+		pending, err := s.Terms.Pending(userID)
+		if err != nil {
+			slog.Warn("Failed to check terms acceptance", "user_id", userID, "error", err)
+		}
+		claims.TermsPending = pending
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(s.JWTSecret)
 }
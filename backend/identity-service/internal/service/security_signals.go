@@ -0,0 +1,154 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Security signals counted for SOC dashboards
+const (
+	// SignalFailedLogin is a login with a wrong password or unknown email
+	SignalFailedLogin = "failed_login"
+	// SignalLockout is an account locked by too many failed logins
+	SignalLockout = "lockout"
+	// SignalMFAFailure is a wrong step-up code or a passkey that failed verification
+	SignalMFAFailure = "mfa_failure"
+	// SignalNewDeviceLogin is a correct password from a device the user has not signed in from
+	SignalNewDeviceLogin = "new_device_login"
+)
+
+// SecuritySignals lists every signal, in the order reports show them
+var SecuritySignals = []string{SignalFailedLogin, SignalLockout, SignalMFAFailure, SignalNewDeviceLogin}
+
+// SignalBuckets are the bucket sizes security metrics can be grouped by
+var SignalBuckets = map[string]time.Duration{
+	"1m":  time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"1h":  time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+const (
+	// DefaultSignalBucket and DefaultSignalRange are used when a query gives none
+	DefaultSignalBucket = "1m"
+	DefaultSignalRange  = time.Hour
+	// MaxSignalBuckets caps how many buckets one query can return
+	MaxSignalBuckets = 1440
+)
+
+var (
+	ErrInvalidSignalBucket = errors.New("bucket must be one of 1m, 5m, 15m, 1h or 1d")
+	ErrInvalidSignalRange  = errors.New("from must be before to, and the range can cover at most 1440 buckets")
+)
+
+var securitySignalsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "identity_security_signals_total",
+		Help: "Total number of security signals: failed logins, lockouts, MFA failures and new-device logins",
+	},
+	[]string{"signal"},
+)
+
+// SecuritySignalRepository stores per-minute signal counts shared by every replica
+type SecuritySignalRepository interface {
+	Increment(signal string, bucketStart time.Time) error
+	ListCounts(from, to time.Time) ([]model.SecuritySignalCount, error)
+}
+
+// SecurityMetrics counts security signals as Prometheus metrics and, when Repo
+// is set, in per-minute buckets that the admin endpoint reports from
+type SecurityMetrics struct {
+	Repo SecuritySignalRepository
+	now  func() time.Time
+}
+
+func NewSecurityMetrics(repo SecuritySignalRepository) *SecurityMetrics {
+	return &SecurityMetrics{Repo: repo, now: time.Now}
+}
+
+// Record counts one occurrence of a signal. A failure to store it is logged,
+// never returned: counting must not fail the login that raised it.
+func (m *SecurityMetrics) Record(signal string) {
+	securitySignalsTotal.WithLabelValues(signal).Inc()
+	if m.Repo == nil {
+		return
+	}
+	if err := m.Repo.Increment(signal, m.now().UTC().Truncate(time.Minute)); err != nil {
+		slog.Warn("Failed to store security signal", "signal", signal, "error", err)
+	}
+}
+
+// SignalBucket is the count of each signal in one bucket
+type SignalBucket struct {
+	Start  time.Time        `json:"start"`
+	Counts map[string]int64 `json:"counts"`
+}
+
+// SignalReport is the signals seen in [From, To), in buckets of Bucket
+type SignalReport struct {
+	From    time.Time        `json:"from"`
+	To      time.Time        `json:"to"`
+	Bucket  string           `json:"bucket"`
+	Totals  map[string]int64 `json:"totals"`
+	Buckets []SignalBucket   `json:"buckets"`
+}
+
+// Report returns the signal counts between from and to in buckets of the given
+// size, with empty buckets included. Both ends are rounded down to the bucket
+// size; a zero to is now, and a zero from is DefaultSignalRange before to.
+func (m *SecurityMetrics) Report(from, to time.Time, bucket string) (*SignalReport, error) {
+	if bucket == "" {
+		bucket = DefaultSignalBucket
+	}
+	size, ok := SignalBuckets[bucket]
+	if !ok {
+		return nil, ErrInvalidSignalBucket
+	}
+	if to.IsZero() {
+		// The current bucket is included while it fills up
+		to = m.now().Add(size)
+	}
+	if from.IsZero() {
+		from = to.Add(-DefaultSignalRange)
+	}
+	from, to = from.UTC().Truncate(size), to.UTC().Truncate(size)
+	if !from.Before(to) || to.Sub(from)/size > MaxSignalBuckets {
+		return nil, ErrInvalidSignalRange
+	}
+
+	var counts []model.SecuritySignalCount
+	if m.Repo != nil {
+		var err error
+		if counts, err = m.Repo.ListCounts(from, to); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &SignalReport{From: from, To: to, Bucket: bucket, Totals: emptySignalCounts()}
+	for start := from; start.Before(to); start = start.Add(size) {
+		report.Buckets = append(report.Buckets, SignalBucket{Start: start, Counts: emptySignalCounts()})
+	}
+	for _, c := range counts {
+		i := int(c.BucketStart.UTC().Sub(from) / size)
+		if i < 0 || i >= len(report.Buckets) {
+			continue
+		}
+		report.Buckets[i].Counts[c.Signal] += c.Count
+		report.Totals[c.Signal] += c.Count
+	}
+	return report, nil
+}
+
+func emptySignalCounts() map[string]int64 {
+	counts := make(map[string]int64, len(SecuritySignals))
+	for _, signal := range SecuritySignals {
+		counts[signal] = 0
+	}
+	return counts
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySignalCounts stores per-minute signal counts in memory
+type memorySignalCounts struct {
+	counts map[string]map[time.Time]int64
+}
+
+func newMemorySignalCounts() *memorySignalCounts {
+	return &memorySignalCounts{counts: map[string]map[time.Time]int64{}}
+}
+
+func (m *memorySignalCounts) Increment(signal string, bucketStart time.Time) error {
+	if m.counts[signal] == nil {
+		m.counts[signal] = map[time.Time]int64{}
+	}
+	m.counts[signal][bucketStart]++
+	return nil
+}
+
+func (m *memorySignalCounts) ListCounts(from, to time.Time) ([]model.SecuritySignalCount, error) {
+	var out []model.SecuritySignalCount
+	for signal, buckets := range m.counts {
+		for start, count := range buckets {
+			if !start.Before(from) && start.Before(to) {
+				out = append(out, model.SecuritySignalCount{Signal: signal, BucketStart: start, Count: count})
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestSecurityMetricsReportBuckets(t *testing.T) {
+	repo := newMemorySignalCounts()
+	m := NewSecurityMetrics(repo)
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	m.Record(SignalFailedLogin)
+	m.Record(SignalFailedLogin)
+	now = now.Add(time.Minute)
+	m.Record(SignalFailedLogin)
+	m.Record(SignalLockout)
+	now = now.Add(10 * time.Minute)
+	m.Record(SignalNewDeviceLogin)
+
+	report, err := m.Report(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 1, 12, 15, 0, 0, time.UTC), "5m")
+	require.NoError(t, err)
+	require.Len(t, report.Buckets, 3)
+	assert.Equal(t, int64(3), report.Buckets[0].Counts[SignalFailedLogin])
+	assert.Equal(t, int64(1), report.Buckets[0].Counts[SignalLockout])
+	assert.Equal(t, int64(0), report.Buckets[1].Counts[SignalNewDeviceLogin], "empty buckets are reported with zero counts")
+	assert.Equal(t, int64(1), report.Buckets[2].Counts[SignalNewDeviceLogin])
+	assert.Equal(t, map[string]int64{SignalFailedLogin: 3, SignalLockout: 1, SignalMFAFailure: 0, SignalNewDeviceLogin: 1}, report.Totals)
+
+	// Without a range the last hour is reported per minute, including the current minute
+	report, err = m.Report(time.Time{}, time.Time{}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "1m", report.Bucket)
+	assert.Len(t, report.Buckets, 60)
+	assert.Equal(t, int64(1), report.Buckets[59].Counts[SignalNewDeviceLogin])
+}
+
+func TestSecurityMetricsReportValidation(t *testing.T) {
+	m := NewSecurityMetrics(newMemorySignalCounts())
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err := m.Report(from, from.Add(time.Hour), "2m")
+	assert.ErrorIs(t, err, ErrInvalidSignalBucket)
+	_, err = m.Report(from, from, "1m")
+	assert.ErrorIs(t, err, ErrInvalidSignalRange)
+	_, err = m.Report(from, from.Add(25*time.Hour), "1m")
+	assert.ErrorIs(t, err, ErrInvalidSignalRange, "more than 1440 buckets")
+	_, err = m.Report(from, from.AddDate(0, 0, 30), "1d")
+	assert.NoError(t, err)
+}
+
+func TestFailedLoginsRecordSignals(t *testing.T) {
+	repo := newMemorySignalCounts()
+	users := new(MockUserRepository)
+	users.On("FindByEmail", "unknown@example.com").Return(nil, errors.New("not found"))
+	s := NewAuthService(users, "secret")
+	s.AccountLockout = NewAccountLockout(2, time.Minute, time.Minute)
+	s.Signals = NewSecurityMetrics(repo)
+
+	for i := 0; i < 3; i++ {
+		_, _ = s.Login("unknown@example.com", "wrong")
+	}
+
+	report, err := s.Signals.Report(time.Time{}, time.Time{}, "1h")
+	require.NoError(t, err)
+	// The third attempt is refused by the lockout without checking the password
+	assert.Equal(t, int64(2), report.Totals[SignalFailedLogin])
+	assert.Equal(t, int64(1), report.Totals[SignalLockout])
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// MockServiceAccountRepository is a mock implementation of ServiceAccountRepository
+type MockServiceAccountRepository struct {
+	mock.Mock
+}
+
+func (m *MockServiceAccountRepository) Create(account *model.ServiceAccount) error {
+	args := m.Called(account)
+	return args.Error(0)
+}
+
+func (m *MockServiceAccountRepository) FindByClientID(clientID string) (*model.ServiceAccount, error) {
+	args := m.Called(clientID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) List() ([]model.ServiceAccount, error) {
+	args := m.Called()
+	return args.Get(0).([]model.ServiceAccount), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) Revoke(id string, revokedAt time.Time) (bool, error) {
+	args := m.Called(id, revokedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockServiceAccountRepository) TouchLastUsed(id string, usedAt time.Time) error {
+	args := m.Called(id, usedAt)
+	return args.Error(0)
+}
+
+const serviceTestSecret = "test-secret-key-for-jwt-signing"
+
+// registeredAccount creates a service account through the service and returns it with its secret
+func registeredAccount(t *testing.T, svc *ServiceAccountService, repo *MockServiceAccountRepository, scopes ...string) (*model.ServiceAccount, string) {
+	t.Helper()
+	repo.On("Create", mock.AnythingOfType("*model.ServiceAccount")).Return(nil).Once()
+	account, secret, err := svc.Create("payment-service", scopes, uuid.New().String())
+	require.NoError(t, err)
+	account.ID = uuid.New()
+	repo.On("FindByClientID", account.ClientID).Return(account, nil)
+	repo.On("TouchLastUsed", account.ID.String(), mock.Anything).Return(nil)
+	return account, secret
+}
+
+func TestServiceAccount_CreateStoresOnlySecretHash(t *testing.T) {
+	repo := new(MockServiceAccountRepository)
+	svc := NewServiceAccountService(repo, serviceTestSecret)
+
+	account, secret := registeredAccount(t, svc, repo, "ledger:read", "ledger:write")
+	assert.True(t, strings.HasPrefix(account.ClientID, "svc_"))
+	assert.NotEmpty(t, secret)
+	assert.NotContains(t, account.SecretHash, secret)
+	assert.Equal(t, hashClientSecret(secret), account.SecretHash)
+}
+
+func TestServiceAccount_CreateRejectsUnknownScope(t *testing.T) {
+	svc := NewServiceAccountService(new(MockServiceAccountRepository), serviceTestSecret)
+	_, _, err := svc.Create("fraud", []string{"admin:everything"}, uuid.New().String())
+	assert.ErrorIs(t, err, ErrUnknownScope)
+}
+
+func TestServiceAccount_IssueToken(t *testing.T) {
+	repo := new(MockServiceAccountRepository)
+	svc := NewServiceAccountService(repo, serviceTestSecret)
+	account, secret := registeredAccount(t, svc, repo, "ledger:read", "ledger:write")
+
+	token, err := svc.IssueToken(account.ClientID, secret, "ledger:write", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.Equal(t, 300, token.ExpiresIn)
+	assert.Equal(t, "ledger:write", token.Scope)
+
+	// The token validates as shared-lib middleware claims
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(token.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(serviceTestSecret), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, account.ID.String(), claims.UserID)
+	assert.Equal(t, middleware.ServiceRole, claims.Role)
+	assert.Equal(t, account.ClientID, claims.Subject)
+	assert.True(t, claims.HasScope("ledger:write"))
+	assert.False(t, claims.HasScope("ledger:read"))
+	assert.WithinDuration(t, time.Now().Add(ServiceTokenExpiry), claims.ExpiresAt.Time, 5*time.Second)
+
+	// No scope requested grants all of the account's scopes
+	token, err = svc.IssueToken(account.ClientID, secret, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "ledger:read ledger:write", token.Scope)
+}
+
+func TestServiceAccount_IssueTokenRejects(t *testing.T) {
+	repo := new(MockServiceAccountRepository)
+	svc := NewServiceAccountService(repo, serviceTestSecret)
+	account, secret := registeredAccount(t, svc, repo, "ledger:read")
+	repo.On("FindByClientID", "svc_unknown").Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := svc.IssueToken(account.ClientID, "wrong-secret", "", "")
+	assert.ErrorIs(t, err, ErrInvalidClient)
+
+	_, err = svc.IssueToken("svc_unknown", secret, "", "")
+	assert.ErrorIs(t, err, ErrInvalidClient)
+
+	_, err = svc.IssueToken(account.ClientID, secret, "ledger:write", "")
+	assert.ErrorIs(t, err, ErrInvalidScope)
+
+	revokedAt := time.Now()
+	account.RevokedAt = &revokedAt
+	_, err = svc.IssueToken(account.ClientID, secret, "", "")
+	assert.ErrorIs(t, err, ErrInvalidClient)
+}
+
+func TestServiceAccount_IssueTokenAudience(t *testing.T) {
+	repo := new(MockServiceAccountRepository)
+	svc := NewServiceAccountService(repo, serviceTestSecret)
+	svc.Audiences = NewTokenAudiences("neobank", []string{"ledger-service", "payment-service"}, nil)
+	account, secret := registeredAccount(t, svc, repo, "ledger:read")
+
+	token, err := svc.IssueToken(account.ClientID, secret, "", "ledger-service")
+	require.NoError(t, err)
+	claims := &middleware.Claims{}
+	_, err = jwt.ParseWithClaims(token.AccessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(serviceTestSecret), nil
+	}, jwt.WithIssuer("neobank"), jwt.WithAudience("ledger-service"))
+	require.NoError(t, err)
+	assert.Equal(t, jwt.ClaimStrings{"ledger-service"}, claims.Audience)
+
+	// The audience must be given, and must be a known service
+	_, err = svc.IssueToken(account.ClientID, secret, "", "")
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+	_, err = svc.IssueToken(account.ClientID, secret, "", "ledger-service identity-service:admin")
+	assert.ErrorIs(t, err, ErrInvalidTarget)
+}
+
+func TestServiceAccount_Revoke(t *testing.T) {
+	repo := new(MockServiceAccountRepository)
+	svc := NewServiceAccountService(repo, serviceTestSecret)
+	id := uuid.New().String()
+	repo.On("Revoke", id, mock.Anything).Return(true, nil).Once()
+	repo.On("Revoke", id, mock.Anything).Return(false, nil).Once()
+
+	require.NoError(t, svc.Revoke(id))
+	assert.ErrorIs(t, svc.Revoke(id), ErrServiceAccountNotFound)
+	assert.ErrorIs(t, svc.Revoke("not-a-uuid"), ErrServiceAccountNotFound)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// StepUpTokenExpiry is how long a step-up token lasts. Services accept it for
+// high-risk actions for middleware.DefaultStepUpMaxAge after the step-up.
+const StepUpTokenExpiry = 5 * time.Minute
+
+// Step-up methods, as RFC 8176 authentication method references
+const (
+	StepUpPassword = "pwd"
+	StepUpPasskey  = "hwk"
+)
+
+// ErrInvalidStepUp is returned unless exactly one of the password and a passkey is given
+var ErrInvalidStepUp = errors.New("step-up needs either the password or a passkey assertion")
+
+// ErrNotAdmin is returned when someone other than an admin asks for an admin token
+var ErrNotAdmin = errors.New("admin tokens are only issued to admins")
+
+// StepUpCredentials re-prove who the user is: their password, or a passkey
+// assertion for a login ceremony started with the user's email
+type StepUpCredentials struct {
+	Password string
+	Passkey  *PasskeyAssertion
+}
+
+// StepUpCaller is the signed-in user asking for a step-up token. OrgID and
+// OrgRole carry over from an organization token, so the step-up token acts in
+// the same organization.
+type StepUpCaller struct {
+	UserID  string
+	OrgID   string
+	OrgRole string
+}
+
+// StepUpToken is a short-lived access token recording when and how the user
+// re-authenticated, for endpoints guarded by middleware.RequireStepUp
+type StepUpToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	ExpiresIn   int       `json:"expires_in"`
+	AuthTime    time.Time `json:"auth_time"`
+	Method      string    `json:"method"`
+}
+
+// StepUp checks the signed-in user's password or passkey again and issues a
+// step-up token. Wrong passwords count towards the account's lockout like
+// failed logins, so a stolen session cannot be used to guess the password.
+func (s *AuthService) StepUp(caller StepUpCaller, creds StepUpCredentials) (*StepUpToken, error) {
+	if (creds.Password == "") == (creds.Passkey == nil) {
+		return nil, ErrInvalidStepUp
+	}
+	user, err := s.Repo.FindByID(caller.UserID)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	method := StepUpPassword
+	if creds.Passkey != nil {
+		method = StepUpPasskey
+		if !s.passkeysEnabled() {
+			return nil, ErrPasskeysDisabled
+		}
+		passkeyUser, ceremony, err := s.verifyPasskeyAssertion(*creds.Passkey)
+		if err == nil && (passkeyUser.ID != user.ID || ceremony.LoginChallengeID != nil) {
+			err = ErrPasskeyInvalid
+		}
+		if err != nil {
+			if errors.Is(err, ErrPasskeyInvalid) {
+				s.recordSignal(SignalMFAFailure)
+			}
+			return nil, err
+		}
+	} else {
+		if s.AccountLockout != nil && s.AccountLockout.IsLocked(user.Email) {
+			return nil, ErrAccountLocked
+		}
+		if err := s.verifyPassword(user.PasswordHash, creds.Password); err != nil {
+			s.recordFailedLogin(user.Email)
+			return nil, ErrInvalidCredentials
+		}
+		if s.AccountLockout != nil {
+			s.AccountLockout.RecordSuccessfulLogin(user.Email)
+		}
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id":   user.ID.String(),
+		"email":     user.Email,
+		"role":      user.Role,
+		"auth_time": now.Unix(),
+		"amr":       []string{method},
+		"iat":       now.Unix(),
+		"exp":       now.Add(StepUpTokenExpiry).Unix(),
+	}
+	if caller.OrgID != "" {
+		claims["org_id"] = caller.OrgID
+		claims["org_role"] = caller.OrgRole
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, s.Audiences.stamp(s.Terms.stamp(claims, user.ID.String()), s.Audiences.User)).SignedString(s.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &StepUpToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(StepUpTokenExpiry.Seconds()),
+		AuthTime:    now.Truncate(time.Second),
+		Method:      method,
+	}, nil
+}
+
+// AdminToken is an access token for the services' admin routes
+type AdminToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// IssueAdminToken exchanges a stepped-up admin session for a token addressed
+// only to the services' admin audiences, so login tokens held by the frontend
+// cannot reach admin routes and admin tokens cannot act on the user APIs. The
+// role is read from the user record rather than trusted from the session.
+// authTime and amr are the session's step-up; the admin token carries them so
+// admin routes can still require a recent step-up, and it counts from the
+// step-up rather than from when the admin token was issued.
+func (s *AuthService) IssueAdminToken(userID string, authTime time.Time, amr []string) (*AdminToken, error) {
+	user, err := s.Repo.FindByID(userID)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Role != "admin" {
+		return nil, ErrNotAdmin
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"user_id": user.ID.String(),
+		"email":   user.Email,
+		"role":    user.Role,
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTokenExpiry).Unix(),
+	}
+	if !authTime.IsZero() {
+		claims["auth_time"] = authTime.Unix()
+		claims["amr"] = amr
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, s.Audiences.stamp(claims, s.Audiences.Admin())).SignedString(s.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &AdminToken{
+		AccessToken: signed,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(AccessTokenExpiry.Seconds()),
+	}, nil
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseStepUpToken(t *testing.T, token string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	require.NoError(t, err)
+	return claims
+}
+
+func TestStepUp_Password(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	svc.AccountLockout = NewAccountLockout(2, time.Minute, time.Minute)
+	hash, err := svc.hashPassword("Correct-Horse-9")
+	require.NoError(t, err)
+	user := &model.User{ID: uuid.New(), Email: "user@example.com", Role: "customer", PasswordHash: hash}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+	caller := StepUpCaller{UserID: user.ID.String(), OrgID: uuid.NewString(), OrgRole: "approver"}
+
+	_, err = svc.StepUp(caller, StepUpCredentials{})
+	assert.ErrorIs(t, err, ErrInvalidStepUp)
+
+	token, err := svc.StepUp(caller, StepUpCredentials{Password: "Correct-Horse-9"})
+	require.NoError(t, err)
+	assert.Equal(t, StepUpPassword, token.Method)
+	assert.Equal(t, int(StepUpTokenExpiry.Seconds()), token.ExpiresIn)
+	claims := parseStepUpToken(t, token.AccessToken)
+	assert.Equal(t, user.ID.String(), claims["user_id"])
+	assert.Equal(t, float64(token.AuthTime.Unix()), claims["auth_time"])
+	assert.Equal(t, []interface{}{"pwd"}, claims["amr"])
+	assert.Equal(t, caller.OrgID, claims["org_id"], "the step-up token keeps the organization")
+
+	// Wrong passwords count towards the lockout
+	for i := 0; i < 2; i++ {
+		_, err = svc.StepUp(caller, StepUpCredentials{Password: "wrong"})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err = svc.StepUp(caller, StepUpCredentials{Password: "Correct-Horse-9"})
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
+
+func TestStepUp_Passkey(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	user := &model.User{ID: uuid.New(), Email: "user@example.com", Role: "customer"}
+	other := &model.User{ID: uuid.New(), Email: "other@example.com", Role: "customer"}
+	for _, u := range []*model.User{user, other} {
+		userRepo.On("FindByID", u.ID.String()).Return(u, nil)
+		userRepo.On("FindByEmail", u.Email).Return(u, nil)
+	}
+	authenticator := registerPasskey(t, svc, user)
+	otherAuthenticator := registerPasskey(t, svc, other)
+
+	opts, err := svc.BeginPasskeyLogin(user.Email, "")
+	require.NoError(t, err)
+	assertion := authenticator.get(t, opts.Challenge, testOrigin, authFlagUserPresent|authFlagUserVerified)
+	token, err := svc.StepUp(StepUpCaller{UserID: user.ID.String()}, StepUpCredentials{Passkey: &assertion})
+	require.NoError(t, err)
+	assert.Equal(t, StepUpPasskey, token.Method)
+	claims := parseStepUpToken(t, token.AccessToken)
+	assert.Equal(t, []interface{}{"hwk"}, claims["amr"])
+	assert.NotContains(t, claims, "org_id")
+
+	// Another user's passkey does not step up this session
+	opts, err = svc.BeginPasskeyLogin("", "")
+	require.NoError(t, err)
+	assertion = otherAuthenticator.get(t, opts.Challenge, testOrigin, authFlagUserPresent|authFlagUserVerified)
+	_, err = svc.StepUp(StepUpCaller{UserID: user.ID.String()}, StepUpCredentials{Passkey: &assertion})
+	assert.ErrorIs(t, err, ErrPasskeyInvalid)
+
+	// Without passkeys configured only the password works
+	svc.WebAuthn = nil
+	_, err = svc.StepUp(StepUpCaller{UserID: user.ID.String()}, StepUpCredentials{Passkey: &assertion})
+	assert.ErrorIs(t, err, ErrPasskeysDisabled)
+}
+
+func TestIssueAdminToken(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	svc.Audiences = NewTokenAudiences("neobank", []string{"ledger-service", "payment-service"}, []string{"ledger-service"})
+	admin := &model.User{ID: uuid.New(), Email: "admin@example.com", Role: "admin"}
+	customer := &model.User{ID: uuid.New(), Email: "user@example.com", Role: "customer"}
+	for _, u := range []*model.User{admin, customer} {
+		userRepo.On("FindByID", u.ID.String()).Return(u, nil)
+	}
+
+	steppedUp := time.Now().Add(-time.Minute)
+	token, err := svc.IssueAdminToken(admin.ID.String(), steppedUp, []string{StepUpPasskey})
+	require.NoError(t, err)
+	claims := parseStepUpToken(t, token.AccessToken)
+	assert.Equal(t, "neobank", claims["iss"])
+	assert.Equal(t, []interface{}{"ledger-service:admin", "payment-service:admin"}, claims["aud"],
+		"admin tokens are only for admin routes")
+	// The step-up carries over, so admin routes can require a recent one
+	assert.Equal(t, float64(steppedUp.Unix()), claims["auth_time"])
+	assert.Equal(t, []interface{}{"hwk"}, claims["amr"])
+
+	// Login tokens never carry the admin audiences
+	login, err := svc.issueLoginToken(admin)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"ledger-service", "payment-service"}, parseStepUpToken(t, login)["aud"])
+
+	_, err = svc.IssueAdminToken(customer.ID.String(), steppedUp, []string{StepUpPassword})
+	assert.ErrorIs(t, err, ErrNotAdmin)
+}
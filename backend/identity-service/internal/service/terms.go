@@ -0,0 +1,272 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TermsKinds are the documents users accept, in the order they are listed
+var TermsKinds = []model.TermsKind{model.TermsOfService, model.PrivacyPolicy}
+
+var (
+	ErrInvalidTermsKind   = errors.New("kind must be TERMS_OF_SERVICE or PRIVACY_POLICY")
+	ErrTermsVersionExists = errors.New("a version with that name was already published for this kind")
+	ErrTermsNotCurrent    = errors.New("document_ids must list the IDs of current terms versions")
+)
+
+// TermsRepository stores published terms versions and users' acceptances
+type TermsRepository interface {
+	CreateDocument(doc *model.TermsDocument) error
+	// ListDocuments returns every published version, oldest first
+	ListDocuments() ([]model.TermsDocument, error)
+	ListAcceptancesByUser(userID uuid.UUID) ([]model.TermsAcceptance, error)
+	// CreateAcceptances skips versions the user already accepted
+	CreateAcceptances(acceptances []model.TermsAcceptance) error
+	CountAcceptances() (map[uuid.UUID]int64, error)
+	CountUsers() (int64, error)
+}
+
+// TermsService publishes versions of the terms of service and privacy policy
+// and records which of them users accepted. Publishing a mandatory version
+// flags the tokens of users who have not accepted it, and services refuse
+// their changes until they do, see middleware.RequireTermsAccepted.
+type TermsService struct {
+	Repo TermsRepository
+	now  func() time.Time
+}
+
+func NewTermsService(repo TermsRepository) *TermsService {
+	return &TermsService{Repo: repo, now: time.Now}
+}
+
+// TermsState is the current version of one kind of document for a user
+type TermsState struct {
+	Document   model.TermsDocument `json:"document"`
+	Accepted   bool                `json:"accepted"`
+	AcceptedAt *time.Time          `json:"accepted_at,omitempty"`
+	// Required is set when the user must accept Document to keep using the API
+	Required bool `json:"required"`
+}
+
+// TermsStatus is what a user has to accept. Pending is set when any document
+// is required.
+type TermsStatus struct {
+	Documents []TermsState `json:"documents"`
+	Pending   bool         `json:"pending"`
+}
+
+// TermsVersionReport is a published version with how many users accepted it
+type TermsVersionReport struct {
+	model.TermsDocument
+	Current        bool    `json:"current"`
+	Accepted       int64   `json:"accepted"`
+	Users          int64   `json:"users"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+}
+
+// PublishTerms adds a version of a document. It becomes the current version
+// of its kind; when mandatory, users who have not accepted it must do so
+// before they can make changes again.
+func (s *TermsService) PublishTerms(doc model.TermsDocument, publishedBy string) (*model.TermsDocument, error) {
+	if !slices.Contains(TermsKinds, doc.Kind) {
+		return nil, ErrInvalidTermsKind
+	}
+	publisher, err := uuid.Parse(publishedBy)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	doc.ID = uuid.Nil
+	doc.Version = strings.TrimSpace(doc.Version)
+	doc.PublishedBy = publisher
+	doc.PublishedAt = s.now()
+	if err := s.Repo.CreateDocument(&doc); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrTermsVersionExists
+		}
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ListVersions returns every published version, oldest first, with the share
+// of users who accepted it
+func (s *TermsService) ListVersions() ([]TermsVersionReport, error) {
+	docs, err := s.Repo.ListDocuments()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.Repo.CountAcceptances()
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.Repo.CountUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	current := map[model.TermsKind]uuid.UUID{}
+	for _, doc := range docs {
+		current[doc.Kind] = doc.ID
+	}
+	reports := make([]TermsVersionReport, len(docs))
+	for i, doc := range docs {
+		reports[i] = TermsVersionReport{
+			TermsDocument: doc,
+			Current:       current[doc.Kind] == doc.ID,
+			Accepted:      counts[doc.ID],
+			Users:         users,
+		}
+		if users > 0 {
+			reports[i].AcceptanceRate = float64(counts[doc.ID]) / float64(users)
+		}
+	}
+	return reports, nil
+}
+
+// Status returns the current version of each document and whether the user
+// accepted it
+func (s *TermsService) Status(userID string) (*TermsStatus, error) {
+	user, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	docs, err := s.Repo.ListDocuments()
+	if err != nil {
+		return nil, err
+	}
+	acceptances, err := s.Repo.ListAcceptancesByUser(user)
+	if err != nil {
+		return nil, err
+	}
+	return termsStatus(docs, acceptances), nil
+}
+
+// Pending reports whether the user must accept a new mandatory version
+func (s *TermsService) Pending(userID string) (bool, error) {
+	status, err := s.Status(userID)
+	if err != nil {
+		return false, err
+	}
+	return status.Pending, nil
+}
+
+// Accept records that the user accepted the given versions, which must each
+// be the current version of their kind, and returns the user's new status
+func (s *TermsService) Accept(userID string, documentIDs []string, ip, userAgent string) (*TermsStatus, error) {
+	user, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	docs, err := s.Repo.ListDocuments()
+	if err != nil {
+		return nil, err
+	}
+	current := map[uuid.UUID]model.TermsDocument{}
+	for _, state := range termsStatus(docs, nil).Documents {
+		current[state.Document.ID] = state.Document
+	}
+
+	now := s.now()
+	acceptances := make([]model.TermsAcceptance, 0, len(documentIDs))
+	for _, id := range documentIDs {
+		docID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, ErrTermsNotCurrent
+		}
+		doc, ok := current[docID]
+		if !ok {
+			return nil, ErrTermsNotCurrent
+		}
+		acceptances = append(acceptances, model.TermsAcceptance{
+			UserID:     user,
+			DocumentID: doc.ID,
+			Kind:       doc.Kind,
+			Version:    doc.Version,
+			IP:         ip,
+			UserAgent:  userAgent,
+			AcceptedAt: now,
+		})
+	}
+	if len(acceptances) == 0 {
+		return nil, ErrTermsNotCurrent
+	}
+	if err := s.Repo.CreateAcceptances(acceptances); err != nil {
+		return nil, err
+	}
+	return s.Status(userID)
+}
+
+// termsStatus works out a user's status from every published version, oldest
+// first, and the user's acceptances. A kind is required until the user
+// accepts its latest mandatory version or any version published after it.
+func termsStatus(docs []model.TermsDocument, acceptances []model.TermsAcceptance) *TermsStatus {
+	acceptedAt := make(map[uuid.UUID]time.Time, len(acceptances))
+	for _, a := range acceptances {
+		acceptedAt[a.DocumentID] = a.AcceptedAt
+	}
+
+	status := &TermsStatus{Documents: []TermsState{}}
+	for _, kind := range TermsKinds {
+		var state *TermsState
+		for _, doc := range docs {
+			if doc.Kind != kind {
+				continue
+			}
+			if state == nil {
+				state = &TermsState{}
+			}
+			if doc.Mandatory {
+				state.Required = true
+			}
+			if at, ok := acceptedAt[doc.ID]; ok {
+				state.Required = false
+				state.Accepted, state.AcceptedAt = true, &at
+			} else {
+				state.Accepted, state.AcceptedAt = false, nil
+			}
+			state.Document = doc
+		}
+		if state != nil {
+			status.Documents = append(status.Documents, *state)
+			status.Pending = status.Pending || state.Required
+		}
+	}
+	return status
+}
+
+// stamp flags the claims of a user token when the user must accept new
+// terms; a nil service leaves them as they are. A failed check leaves the
+// token unflagged rather than failing the login, since the flag only gates
+// changes until the next token.
+func (s *TermsService) stamp(claims jwt.MapClaims, userID string) jwt.MapClaims {
+	if s == nil {
+		return claims
+	}
+	pending, err := s.Pending(userID)
+	if err != nil {
+		slog.Warn("Failed to check terms acceptance", "user_id", userID, "error", err)
+		return claims
+	}
+	if pending {
+		claims["terms_pending"] = true
+	}
+	return claims
+}
+
+// IssueUserToken signs a new access token for the user, e.g. to replace a
+// token flagged with terms_pending once the terms are accepted
+func (s *AuthService) IssueUserToken(userID string) (string, error) {
+	user, err := s.Repo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	return s.issueLoginToken(user)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryTermsRepository is an in-memory TermsRepository
+type memoryTermsRepository struct {
+	docs        []model.TermsDocument
+	acceptances []model.TermsAcceptance
+	users       int64
+}
+
+func (r *memoryTermsRepository) CreateDocument(doc *model.TermsDocument) error {
+	for _, d := range r.docs {
+		if d.Kind == doc.Kind && d.Version == doc.Version {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	doc.ID = uuid.New()
+	r.docs = append(r.docs, *doc)
+	return nil
+}
+
+func (r *memoryTermsRepository) ListDocuments() ([]model.TermsDocument, error) {
+	return r.docs, nil
+}
+
+func (r *memoryTermsRepository) ListAcceptancesByUser(userID uuid.UUID) ([]model.TermsAcceptance, error) {
+	var acceptances []model.TermsAcceptance
+	for _, a := range r.acceptances {
+		if a.UserID == userID {
+			acceptances = append(acceptances, a)
+		}
+	}
+	return acceptances, nil
+}
+
+func (r *memoryTermsRepository) CreateAcceptances(acceptances []model.TermsAcceptance) error {
+next:
+	for _, a := range acceptances {
+		for _, existing := range r.acceptances {
+			if existing.UserID == a.UserID && existing.DocumentID == a.DocumentID {
+				continue next
+			}
+		}
+		a.ID = uuid.New()
+		r.acceptances = append(r.acceptances, a)
+	}
+	return nil
+}
+
+func (r *memoryTermsRepository) CountAcceptances() (map[uuid.UUID]int64, error) {
+	counts := map[uuid.UUID]int64{}
+	for _, a := range r.acceptances {
+		counts[a.DocumentID]++
+	}
+	return counts, nil
+}
+
+func (r *memoryTermsRepository) CountUsers() (int64, error) {
+	return r.users, nil
+}
+
+// newTermsService returns a service whose clock advances a minute per call,
+// so versions are published in order
+func newTermsService() (*TermsService, *memoryTermsRepository) {
+	repo := &memoryTermsRepository{users: 4}
+	svc := NewTermsService(repo)
+	now := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+	return svc, repo
+}
+
+func publish(t *testing.T, svc *TermsService, kind model.TermsKind, version string, mandatory bool) *model.TermsDocument {
+	t.Helper()
+	doc, err := svc.PublishTerms(model.TermsDocument{
+		Kind: kind, Version: version, Title: string(kind) + " " + version,
+		URL: "https://neobank.com/legal/" + version, Mandatory: mandatory,
+	}, uuid.NewString())
+	require.NoError(t, err)
+	return doc
+}
+
+func TestTerms_PublishValidation(t *testing.T) {
+	svc, _ := newTermsService()
+	publish(t, svc, model.TermsOfService, "2026-01", true)
+
+	_, err := svc.PublishTerms(model.TermsDocument{Kind: "COOKIES", Version: "1"}, uuid.NewString())
+	assert.ErrorIs(t, err, ErrInvalidTermsKind)
+	_, err = svc.PublishTerms(model.TermsDocument{Kind: model.TermsOfService, Version: "2026-01"}, uuid.NewString())
+	assert.ErrorIs(t, err, ErrTermsVersionExists)
+}
+
+func TestTerms_MandatoryVersionForcesReacceptance(t *testing.T) {
+	svc, _ := newTermsService()
+	user := uuid.NewString()
+
+	status, err := svc.Status(user)
+	require.NoError(t, err)
+	assert.False(t, status.Pending, "nothing is pending before any terms are published")
+	assert.Empty(t, status.Documents)
+
+	tos := publish(t, svc, model.TermsOfService, "2026-01", true)
+	privacy := publish(t, svc, model.PrivacyPolicy, "2026-01", false)
+	pending, err := svc.Pending(user)
+	require.NoError(t, err)
+	assert.True(t, pending)
+
+	status, err = svc.Accept(user, []string{tos.ID.String()}, "203.0.113.7", "app/1.0")
+	require.NoError(t, err)
+	assert.False(t, status.Pending, "an optional version does not block")
+	require.Len(t, status.Documents, 2)
+	assert.True(t, status.Documents[0].Accepted)
+	assert.False(t, status.Documents[1].Accepted)
+	assert.False(t, status.Documents[1].Required)
+
+	// A new mandatory version needs accepting again; the old one no longer can be
+	tos2 := publish(t, svc, model.TermsOfService, "2026-10", true)
+	pending, err = svc.Pending(user)
+	require.NoError(t, err)
+	assert.True(t, pending)
+	_, err = svc.Accept(user, []string{tos.ID.String()}, "", "")
+	assert.ErrorIs(t, err, ErrTermsNotCurrent)
+	_, err = svc.Accept(user, []string{privacy.ID.String(), "not-a-uuid"}, "", "")
+	assert.ErrorIs(t, err, ErrTermsNotCurrent)
+
+	status, err = svc.Accept(user, []string{tos2.ID.String(), privacy.ID.String()}, "", "")
+	require.NoError(t, err)
+	assert.False(t, status.Pending)
+
+	// An optional version after a mandatory one keeps the mandatory one satisfied,
+	// and accepting the optional one satisfies an unaccepted mandatory one
+	publish(t, svc, model.TermsOfService, "2026-11", false)
+	pending, err = svc.Pending(user)
+	require.NoError(t, err)
+	assert.False(t, pending)
+
+	other := uuid.NewString()
+	status, err = svc.Status(other)
+	require.NoError(t, err)
+	require.True(t, status.Pending)
+	status, err = svc.Accept(other, []string{status.Documents[0].Document.ID.String()}, "", "")
+	require.NoError(t, err)
+	assert.False(t, status.Pending)
+}
+
+func TestTerms_AcceptanceRates(t *testing.T) {
+	svc, _ := newTermsService()
+	tos := publish(t, svc, model.TermsOfService, "2026-01", true)
+	for i := 0; i < 3; i++ {
+		_, err := svc.Accept(uuid.NewString(), []string{tos.ID.String()}, "", "")
+		require.NoError(t, err)
+	}
+	tos2 := publish(t, svc, model.TermsOfService, "2026-10", true)
+	user := uuid.NewString()
+	_, err := svc.Accept(user, []string{tos2.ID.String()}, "", "")
+	require.NoError(t, err)
+	_, err = svc.Accept(user, []string{tos2.ID.String()}, "", "")
+	require.NoError(t, err, "accepting again is not counted twice")
+
+	versions, err := svc.ListVersions()
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.False(t, versions[0].Current)
+	assert.Equal(t, int64(3), versions[0].Accepted)
+	assert.InDelta(t, 0.75, versions[0].AcceptanceRate, 1e-9)
+	assert.True(t, versions[1].Current)
+	assert.Equal(t, int64(1), versions[1].Accepted)
+	assert.Equal(t, int64(4), versions[1].Users)
+	assert.InDelta(t, 0.25, versions[1].AcceptanceRate, 1e-9)
+}
+
+func TestTerms_TokensFlagPendingTerms(t *testing.T) {
+	svc, userRepo, _ := newPasskeyService()
+	terms, _ := newTermsService()
+	svc.Terms = terms
+	user := &model.User{ID: uuid.New(), Email: "user@example.com", Role: "customer"}
+	userRepo.On("FindByID", user.ID.String()).Return(user, nil)
+
+	token, err := svc.IssueUserToken(user.ID.String())
+	require.NoError(t, err)
+	assert.NotContains(t, parseStepUpToken(t, token), "terms_pending")
+
+	tos := publish(t, terms, model.TermsOfService, "2026-10", true)
+	token, err = svc.IssueUserToken(user.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, true, parseStepUpToken(t, token)["terms_pending"])
+
+	_, err = terms.Accept(user.ID.String(), []string{tos.ID.String()}, "", "")
+	require.NoError(t, err)
+	token, err = svc.IssueUserToken(user.ID.String())
+	require.NoError(t, err)
+	assert.NotContains(t, parseStepUpToken(t, token), "terms_pending")
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidTarget is returned when a service account asks for a token for a
+// service it cannot be issued one for
+var ErrInvalidTarget = errors.New("requested audience is not a known service")
+
+// TokenAudiences are the issuer and audiences stamped on the tokens the
+// identity service issues. Services only accept tokens addressed to them, so
+// each kind of token names just the services it may be used with. The zero
+// value issues tokens without iss and aud claims.
+type TokenAudiences struct {
+	Issuer string
+	// User tokens, from logins, step-ups and organizations, are for the
+	// services' user APIs
+	User []string
+	// ThirdParty consent tokens are for the open banking APIs
+	ThirdParty []string
+	// Delegate tokens are for the services with delegated access APIs
+	Delegate []string
+	// Services are the audiences service accounts may ask for
+	Services []string
+}
+
+// NewTokenAudiences addresses user tokens to every service and consent tokens
+// to the services with open banking APIs
+func NewTokenAudiences(issuer string, services, openBanking []string) TokenAudiences {
+	return TokenAudiences{Issuer: issuer, User: services, ThirdParty: openBanking, Services: services}
+}
+
+// Admin returns the audiences of admin tokens: the admin audience of each
+// service, which user tokens never carry
+func (a TokenAudiences) Admin() []string {
+	if len(a.User) == 0 {
+		return nil
+	}
+	admin := make([]string, len(a.User))
+	for i, aud := range a.User {
+		admin[i] = middleware.AdminAudience(aud)
+	}
+	return admin
+}
+
+// Target checks the space-separated audience a service account asked for
+// against the known services. Once services are configured an audience is
+// required, so no service token is accepted everywhere.
+func (a TokenAudiences) Target(audience string) ([]string, error) {
+	requested := strings.Fields(audience)
+	if len(a.Services) == 0 {
+		return requested, nil
+	}
+	if len(requested) == 0 {
+		return nil, ErrInvalidTarget
+	}
+	for _, aud := range requested {
+		if !slices.Contains(a.Services, aud) {
+			return nil, ErrInvalidTarget
+		}
+	}
+	return requested, nil
+}
+
+// stamp sets the iss and aud claims of a token addressed to aud
+func (a TokenAudiences) stamp(claims jwt.MapClaims, aud []string) jwt.MapClaims {
+	if a.Issuer != "" {
+		claims["iss"] = a.Issuer
+	}
+	if len(aud) > 0 {
+		claims["aud"] = aud
+	}
+	return claims
+}
@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ugorji/go/codec"
+)
+
+// COSE algorithm identifiers accepted for passkeys, in order of preference
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// Authenticator data flags (WebAuthn section 6.1)
+const (
+	authFlagUserPresent  = 0x01
+	authFlagUserVerified = 0x04
+	authFlagAttestedData = 0x40
+)
+
+// WebAuthnConfig identifies this service as a WebAuthn relying party
+type WebAuthnConfig struct {
+	// RPID is the domain passkeys are scoped to, e.g. neobank.com
+	RPID   string
+	RPName string
+	// Origins are the exact origins allowed to run ceremonies, e.g. https://app.neobank.com
+	Origins []string
+}
+
+// clientData is the part of CollectedClientData the relying party checks
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// parseClientData decodes clientDataJSON and checks its type and origin. The
+// challenge is checked by looking up the ceremony it belongs to.
+func (c *WebAuthnConfig) parseClientData(raw []byte, ceremonyType string) (*clientData, error) {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return nil, fmt.Errorf("%w: malformed client data", ErrPasskeyInvalid)
+	}
+	if cd.Type != ceremonyType {
+		return nil, fmt.Errorf("%w: unexpected ceremony type %q", ErrPasskeyInvalid, cd.Type)
+	}
+	for _, origin := range c.Origins {
+		if cd.Origin == origin {
+			return &cd, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: origin %q is not allowed", ErrPasskeyInvalid, cd.Origin)
+}
+
+// authenticatorData is parsed authenticator data (WebAuthn section 6.1)
+type authenticatorData struct {
+	RPIDHash  []byte
+	Flags     byte
+	SignCount uint32
+	// Attested credential data, present on registration
+	AAGUID       []byte
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrPasskeyInvalid)
+	}
+	ad := &authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if ad.Flags&authFlagAttestedData == 0 {
+		return ad, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data too short", ErrPasskeyInvalid)
+	}
+	ad.AAGUID = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, fmt.Errorf("%w: invalid credential ID length", ErrPasskeyInvalid)
+	}
+	ad.CredentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	// The COSE key is followed by extensions, if any; only its own bytes are kept
+	var key map[int]interface{}
+	dec := codec.NewDecoderBytes(rest, cborHandle())
+	if err := dec.Decode(&key); err != nil {
+		return nil, fmt.Errorf("%w: malformed credential public key", ErrPasskeyInvalid)
+	}
+	ad.PublicKey = rest[:dec.NumBytesRead()]
+	return ad, nil
+}
+
+// checkRP verifies the authenticator data was produced for this relying party
+// and with the user present, and with user verification if required
+func (c *WebAuthnConfig) checkRP(ad *authenticatorData, requireUV bool) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(ad.RPIDHash, rpIDHash[:]) {
+		return fmt.Errorf("%w: relying party ID mismatch", ErrPasskeyInvalid)
+	}
+	if ad.Flags&authFlagUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", ErrPasskeyInvalid)
+	}
+	if requireUV && ad.Flags&authFlagUserVerified == 0 {
+		return fmt.Errorf("%w: user verification required", ErrPasskeyInvalid)
+	}
+	return nil
+}
+
+// coseKey is a credential public key decoded from its COSE_Key form
+type coseKey struct {
+	Algorithm int
+	Public    crypto.PublicKey
+}
+
+// parseCOSEKey decodes an EC2 P-256, RSA or Ed25519 COSE_Key (RFC 9053)
+func parseCOSEKey(raw []byte) (*coseKey, error) {
+	var m map[int]interface{}
+	if err := codec.NewDecoderBytes(raw, cborHandle()).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%w: malformed public key", ErrPasskeyInvalid)
+	}
+	kty, _ := cborInt(m[1])
+	alg, _ := cborInt(m[3])
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := cborInt(m[-1])
+		x, _ := m[-2].([]byte)
+		y, _ := m[-3].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: invalid P-256 key", ErrPasskeyInvalid)
+		}
+		// Reject points that are not on the curve
+		point := append([]byte{0x04}, append(x, y...)...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("%w: invalid P-256 key", ErrPasskeyInvalid)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		return &coseKey{Algorithm: COSEAlgES256, Public: pub}, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[-1].([]byte)
+		e, _ := m[-2].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: invalid RSA key", ErrPasskeyInvalid)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		return &coseKey{Algorithm: COSEAlgRS256, Public: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}}, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := cborInt(m[-1])
+		x, _ := m[-2].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid Ed25519 key", ErrPasskeyInvalid)
+		}
+		return &coseKey{Algorithm: COSEAlgEdDSA, Public: ed25519.PublicKey(x)}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported key type %d with algorithm %d", ErrPasskeyInvalid, kty, alg)
+	}
+}
+
+// verify checks a signature over data made with the key's algorithm
+func (k *coseKey) verify(data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	ok := false
+	switch pub := k.Public.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(pub, digest[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(pub, data, sig)
+	}
+	if !ok {
+		return fmt.Errorf("%w: bad signature", ErrPasskeyInvalid)
+	}
+	return nil
+}
+
+// attestationObject is the CBOR structure returned by navigator.credentials.create
+type attestationObject struct {
+	Format   string                 `codec:"fmt"`
+	AttStmt  map[string]interface{} `codec:"attStmt"`
+	AuthData []byte                 `codec:"authData"`
+}
+
+// Attestation types recorded with a passkey
+const (
+	AttestationNone  = "none"
+	AttestationSelf  = "self"
+	AttestationBasic = "basic"
+)
+
+// idFidoGenCeAAGUID is the attestation certificate extension carrying the
+// authenticator's AAGUID
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestation checks the attestation statement and returns its type.
+// The "none" and "packed" formats are supported. Packed certificates are
+// checked for the required fields, but not chained to a vendor root; the
+// returned type records what was proven.
+func verifyAttestation(obj *attestationObject, ad *authenticatorData, key *coseKey, clientDataHash []byte) (string, error) {
+	switch obj.Format {
+	case "none":
+		if len(obj.AttStmt) != 0 {
+			return "", fmt.Errorf("%w: none attestation with a statement", ErrPasskeyInvalid)
+		}
+		return AttestationNone, nil
+	case "packed":
+	default:
+		return "", fmt.Errorf("%w: unsupported attestation format %q", ErrPasskeyInvalid, obj.Format)
+	}
+
+	alg, ok := cborInt(obj.AttStmt["alg"])
+	sig, _ := obj.AttStmt["sig"].([]byte)
+	if !ok || len(sig) == 0 {
+		return "", fmt.Errorf("%w: malformed packed attestation", ErrPasskeyInvalid)
+	}
+	signed := append(append([]byte{}, obj.AuthData...), clientDataHash...)
+
+	x5c, hasCerts := obj.AttStmt["x5c"].([]interface{})
+	if !hasCerts {
+		// Self attestation: signed with the credential key itself
+		if int(alg) != key.Algorithm {
+			return "", fmt.Errorf("%w: attestation algorithm does not match the credential", ErrPasskeyInvalid)
+		}
+		if err := key.verify(signed, sig); err != nil {
+			return "", err
+		}
+		return AttestationSelf, nil
+	}
+
+	der, _ := firstCert(x5c)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed attestation certificate", ErrPasskeyInvalid)
+	}
+	sigAlg, ok := map[int64]x509.SignatureAlgorithm{
+		COSEAlgES256: x509.ECDSAWithSHA256,
+		COSEAlgRS256: x509.SHA256WithRSA,
+		COSEAlgEdDSA: x509.PureEd25519,
+	}[alg]
+	if !ok {
+		return "", fmt.Errorf("%w: unsupported attestation algorithm %d", ErrPasskeyInvalid, alg)
+	}
+	if err := cert.CheckSignature(sigAlg, signed, sig); err != nil {
+		return "", fmt.Errorf("%w: bad attestation signature", ErrPasskeyInvalid)
+	}
+	// Certificate requirements for packed attestation (WebAuthn section 8.2.1)
+	if cert.Version != 3 || cert.IsCA || !containsString(cert.Subject.OrganizationalUnit, "Authenticator Attestation") {
+		return "", fmt.Errorf("%w: attestation certificate does not meet requirements", ErrPasskeyInvalid)
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(ext.Value, &aaguid); err != nil || !bytes.Equal(aaguid, ad.AAGUID) {
+			return "", fmt.Errorf("%w: attestation certificate AAGUID mismatch", ErrPasskeyInvalid)
+		}
+	}
+	return AttestationBasic, nil
+}
+
+func firstCert(x5c []interface{}) ([]byte, bool) {
+	if len(x5c) == 0 {
+		return nil, false
+	}
+	der, ok := x5c[0].([]byte)
+	return der, ok
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAAGUID renders an AAGUID in UUID form; all zeros means the authenticator did not say
+func formatAAGUID(aaguid []byte) string {
+	id, err := uuid.FromBytes(aaguid)
+	if err != nil {
+		return ""
+	}
+	return id.String()
+}
+
+// decodeBase64URL accepts base64url with or without padding, as browsers vary
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// cborInt reads an integer decoded by the CBOR codec, which uses uint64 for
+// non-negative and int64 for negative values
+func cborInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case uint64:
+		if n > 1<<62 {
+			return 0, false
+		}
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func cborHandle() *codec.CborHandle {
+	return &codec.CborHandle{}
+}
@@ -0,0 +1,56 @@
+-- Baseline schema for identity-service, matching what GORM AutoMigrate created.
+-- Statements use IF NOT EXISTS so databases created by AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    email text NOT NULL,
+    password_hash text NOT NULL,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    role text DEFAULT 'customer',
+    kyc_status text DEFAULT 'UNVERIFIED',
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_magic_link_tokens_user_id ON magic_link_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_magic_link_tokens_token_hash ON magic_link_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id varchar(64),
+    event_type varchar(64) NOT NULL,
+    severity varchar(16) NOT NULL,
+    subject_user_id varchar(64),
+    actor_user_id varchar(64),
+    email text,
+    action text,
+    resource text,
+    method varchar(10),
+    path text,
+    ip varchar(64),
+    user_agent text,
+    status_code bigint,
+    success boolean,
+    request_id text,
+    error_msg text,
+    metadata jsonb,
+    service_name varchar(64),
+    occurred_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_id ON audit_events (event_id);
+CREATE INDEX IF NOT EXISTS idx_audit_events_event_type ON audit_events (event_type);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_user_id ON audit_events (actor_user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_subject_time ON audit_events (subject_user_id, occurred_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS login_challenges;
+DROP TABLE IF EXISTS login_events;
@@ -0,0 +1,30 @@
+-- Login history for new-device and impossible-travel detection, and step-up challenges for flagged logins.
+
+CREATE TABLE IF NOT EXISTS login_events (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    ip varchar(64),
+    user_agent text,
+    device_id varchar(128) NOT NULL,
+    country varchar(2),
+    city varchar(100),
+    latitude double precision,
+    longitude double precision,
+    new_device boolean DEFAULT false,
+    impossible_travel boolean DEFAULT false,
+    outcome varchar(20) NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_login_events_user_time ON login_events (user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS login_challenges (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    login_event_id uuid NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    attempts bigint DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_login_challenges_user_id ON login_challenges (user_id);
@@ -0,0 +1,14 @@
+-- Machine clients for the client credentials token flow.
+
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    client_id varchar(64) NOT NULL,
+    secret_hash varchar(64) NOT NULL,
+    scopes jsonb NOT NULL,
+    created_by uuid NOT NULL,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_service_accounts_client_id ON service_accounts (client_id);
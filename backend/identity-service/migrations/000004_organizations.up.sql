@@ -0,0 +1,24 @@
+-- Organizations for business banking and their members' roles.
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name varchar(200) NOT NULL,
+    created_by uuid NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    org_id uuid NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id uuid NOT NULL,
+    email varchar(255) NOT NULL,
+    role varchar(20) NOT NULL,
+    status varchar(20) NOT NULL,
+    invited_by uuid,
+    joined_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_members_org_user ON organization_members (org_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id);
@@ -0,0 +1,27 @@
+-- WebAuthn passkeys and their pending registration and login ceremonies.
+
+CREATE TABLE IF NOT EXISTS passkey_credentials (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    credential_id varchar(1400) NOT NULL,
+    public_key bytea NOT NULL,
+    algorithm integer NOT NULL,
+    sign_count bigint NOT NULL DEFAULT 0,
+    aaguid varchar(36),
+    attestation_type varchar(20) NOT NULL,
+    transports jsonb,
+    name varchar(100),
+    last_used_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_passkey_credentials_credential_id ON passkey_credentials (credential_id);
+CREATE INDEX IF NOT EXISTS idx_passkey_credentials_user_id ON passkey_credentials (user_id);
+
+CREATE TABLE IF NOT EXISTS webauthn_ceremonies (
+    challenge varchar(64) PRIMARY KEY,
+    purpose varchar(20) NOT NULL,
+    user_id uuid,
+    login_challenge_id uuid,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
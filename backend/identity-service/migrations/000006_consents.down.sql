@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS consents;
+DROP TABLE IF EXISTS third_party_clients;
@@ -0,0 +1,31 @@
+-- Third-party clients and the consents users give them to read account data.
+
+CREATE TABLE IF NOT EXISTS third_party_clients (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    client_id varchar(64) NOT NULL,
+    api_key_hash varchar(64) NOT NULL,
+    scopes jsonb NOT NULL,
+    created_by uuid NOT NULL,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_third_party_clients_client_id ON third_party_clients (client_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_third_party_clients_api_key_hash ON third_party_clients (api_key_hash);
+
+CREATE TABLE IF NOT EXISTS consents (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    client_id uuid NOT NULL,
+    client_name varchar(100) NOT NULL,
+    user_id uuid,
+    scopes jsonb NOT NULL,
+    account_ids jsonb,
+    status varchar(30) NOT NULL,
+    expires_at timestamptz,
+    authorised_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_consents_client_id ON consents (client_id);
+CREATE INDEX IF NOT EXISTS idx_consents_user_id ON consents (user_id);
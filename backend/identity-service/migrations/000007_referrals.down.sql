@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS referrals;
+DROP TABLE IF EXISTS referral_invites;
+DROP TABLE IF EXISTS referral_codes;
+DROP INDEX IF EXISTS idx_users_referred_by;
+ALTER TABLE users DROP COLUMN IF EXISTS referred_by;
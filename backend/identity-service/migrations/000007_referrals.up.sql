@@ -0,0 +1,46 @@
+-- Referral codes, emailed invites, and the referrals they attribute new users to.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS referred_by uuid;
+CREATE INDEX IF NOT EXISTS idx_users_referred_by ON users (referred_by);
+
+CREATE TABLE IF NOT EXISTS referral_codes (
+    user_id uuid PRIMARY KEY,
+    code varchar(16) NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referral_codes_code ON referral_codes (code);
+
+CREATE TABLE IF NOT EXISTS referral_invites (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    inviter_id uuid NOT NULL,
+    email varchar(255) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    status varchar(20) NOT NULL,
+    send_count bigint NOT NULL DEFAULT 1,
+    expires_at timestamptz NOT NULL,
+    accepted_by uuid,
+    accepted_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_referral_invites_inviter_id ON referral_invites (inviter_id);
+CREATE INDEX IF NOT EXISTS idx_referral_invites_email ON referral_invites (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referral_invites_token_hash ON referral_invites (token_hash);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    referrer_id uuid NOT NULL,
+    referee_id uuid NOT NULL,
+    invite_id uuid,
+    code varchar(16),
+    device_id varchar(128),
+    status varchar(20) NOT NULL,
+    reject_reason varchar(50),
+    payment_id varchar(64),
+    completed_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_referrals_referrer_id ON referrals (referrer_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_referrals_referee_id ON referrals (referee_id);
+CREATE INDEX IF NOT EXISTS idx_referrals_device_id ON referrals (device_id);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS phone_verifications;
+DROP TABLE IF EXISTS profile_changes;
+ALTER TABLE users DROP COLUMN IF EXISTS country;
+ALTER TABLE users DROP COLUMN IF EXISTS postal_code;
+ALTER TABLE users DROP COLUMN IF EXISTS city;
+ALTER TABLE users DROP COLUMN IF EXISTS address_line2;
+ALTER TABLE users DROP COLUMN IF EXISTS address_line1;
+ALTER TABLE users DROP COLUMN IF EXISTS date_of_birth;
+ALTER TABLE users DROP COLUMN IF EXISTS phone_verified_at;
+ALTER TABLE users DROP COLUMN IF EXISTS phone;
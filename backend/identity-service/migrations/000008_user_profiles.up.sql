@@ -0,0 +1,33 @@
+-- Profile details users add after sign-up, their change history, and pending
+-- phone number changes waiting for a texted code.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone varchar(16);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS date_of_birth date;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS address_line1 varchar(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS address_line2 varchar(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS city varchar(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS postal_code varchar(16);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS country varchar(2);
+
+CREATE TABLE IF NOT EXISTS profile_changes (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    field varchar(32) NOT NULL,
+    old_value varchar(100),
+    new_value varchar(100),
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_profile_changes_user_time ON profile_changes (user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    phone varchar(16) NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    attempts bigint DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_user_id ON phone_verifications (user_id);
@@ -0,0 +1,11 @@
+-- Per-minute counts of security signals (failed logins, lockouts, MFA failures,
+-- new-device logins) for the admin security metrics endpoint, so dashboards
+-- do not have to scan audit_events.
+
+CREATE TABLE IF NOT EXISTS security_signal_counts (
+    signal varchar(32) NOT NULL,
+    bucket_start timestamptz NOT NULL,
+    count bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (signal, bucket_start)
+);
+CREATE INDEX IF NOT EXISTS idx_security_signal_counts_bucket_start ON security_signal_counts (bucket_start);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS terms_acceptances;
+DROP TABLE IF EXISTS terms_documents;
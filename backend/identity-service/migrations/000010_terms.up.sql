@@ -0,0 +1,28 @@
+-- Published versions of the terms of service and privacy policy, and which
+-- of them each user accepted.
+
+CREATE TABLE IF NOT EXISTS terms_documents (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind varchar(30) NOT NULL,
+    version varchar(50) NOT NULL,
+    title varchar(200) NOT NULL,
+    url varchar(500) NOT NULL,
+    summary text,
+    mandatory boolean NOT NULL,
+    published_by uuid NOT NULL,
+    published_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_terms_documents_kind_version ON terms_documents (kind, version);
+
+CREATE TABLE IF NOT EXISTS terms_acceptances (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    document_id uuid NOT NULL REFERENCES terms_documents (id),
+    kind varchar(30) NOT NULL,
+    version varchar(50) NOT NULL,
+    ip varchar(45),
+    user_agent varchar(500),
+    accepted_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_terms_acceptances_user_document ON terms_acceptances (user_id, document_id);
+CREATE INDEX IF NOT EXISTS idx_terms_acceptances_document_id ON terms_acceptances (document_id);
@@ -0,0 +1,20 @@
+-- Delegations: a user lets another verified user view, and optionally pay
+-- from, some of their accounts for a limited time.
+
+CREATE TABLE IF NOT EXISTS delegations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    grantor_id uuid NOT NULL,
+    grantee_id uuid NOT NULL,
+    grantee_email varchar(255) NOT NULL,
+    access varchar(20) NOT NULL,
+    account_ids jsonb NOT NULL,
+    payment_limit numeric(19,4),
+    status varchar(20) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    revoked_by uuid,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_delegations_grantor_id ON delegations (grantor_id);
+CREATE INDEX IF NOT EXISTS idx_delegations_grantee_id ON delegations (grantee_id);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS phone_verifications (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    phone varchar(16) NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    attempts bigint DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_phone_verifications_user_id ON phone_verifications (user_id);
@@ -0,0 +1,4 @@
+-- Pending phone verifications are kept in Redis, expiring with their codes,
+-- instead of in the database.
+
+DROP TABLE IF EXISTS phone_verifications;
@@ -0,0 +1,11 @@
+-- Preferences: locale, display currency, marketing opt-ins and notification
+-- channels per user. Users without a row have the defaults.
+
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id uuid PRIMARY KEY,
+    locale varchar(35) NOT NULL,
+    display_currency varchar(3) NOT NULL,
+    marketing_opt_ins jsonb NOT NULL,
+    notifications jsonb NOT NULL,
+    updated_at timestamptz
+);
@@ -0,0 +1,9 @@
+// Package migrations embeds the identity service's versioned SQL schema migrations.
+// Add a new pair of NNNNNN_name.up.sql and NNNNNN_name.down.sql files for every
+// schema change; never edit a migration that has been released.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
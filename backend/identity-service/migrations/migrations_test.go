@@ -0,0 +1,15 @@
+package migrations
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/identity-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsCoverModels(t *testing.T) {
+	migrations, err := db.LoadMigrations(FS)
+	require.NoError(t, err)
+	require.NoError(t, db.VerifyModels(migrations, &model.User{}, &model.MagicLinkToken{}, &model.AuditEvent{}, &model.LoginEvent{}, &model.LoginChallenge{}, &model.ServiceAccount{}, &model.Organization{}, &model.OrganizationMember{}, &model.PasskeyCredential{}, &model.WebAuthnCeremony{}, &model.ThirdPartyClient{}, &model.Consent{}, &model.ReferralCode{}, &model.ReferralInvite{}, &model.Referral{}, &model.ProfileChange{}, &model.SecuritySignalCount{}, &model.TermsDocument{}, &model.TermsAcceptance{}, &model.Delegation{}, &model.UserPreferences{}))
+}
@@ -2,34 +2,79 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
+	apispec "github.com/femi-lawal/new_bank/backend/ledger-service/api"
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/consumer"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/graphql"
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/handler"
-	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/migrations"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/chaos"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
 	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/featureflags"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/lock"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/maintenance"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 const serviceName = "ledger-service"
 
 func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema;
+	// "verify-audit" checks the journal audit hash chain and exits non-zero if it is broken
+	var command string
+	var args []string
+	var err error
+	if len(os.Args) > 1 && os.Args[1] == "verify-audit" {
+		command = "verify-audit"
+	} else if command, args, err = db.ParseCommand(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Initialize Logger
 	logger.InitLogger(serviceName, true)
 	slog.Info("Starting Ledger Service")
 
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+	apperrors.Problems().RegisterAll(serviceProblemTypes)
+
 	// Initialize Tracing
 	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
 	if err != nil {
@@ -54,9 +99,26 @@ func main() {
 		panic(err)
 	}
 
-	// Auto Migrate
-	if err := database.AutoMigrate(&model.Account{}, &model.JournalEntry{}, &model.Posting{}); err != nil {
-		slog.Error("Failed to migrate database", "error", err)
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if command == "verify-audit" {
+		os.Exit(verifyJournalAudit(repository.NewLedgerRepository(database)))
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
 	}
 
 	// Initialize Redis Cache
@@ -81,24 +143,119 @@ func main() {
 	} else {
 		svc = service.NewLedgerService(repo)
 	}
+	svc.SetCategorization(repo, service.NewCategorizer(service.DefaultCategoryRules))
+	// End-of-day balances let statements and as-of balances skip older postings
+	svc.SetSnapshots(repo)
+	// Reconciliation runs on one replica at a time when Redis is available
+	if redisClient != nil {
+		svc.SetReconciliation(repo, lock.NewRedisLocker(redisClient))
+	} else {
+		svc.SetReconciliation(repo, nil)
+	}
+	// Accounts get a sort code, account number and IBAN others can pay them with
+	aliasCfg := service.DefaultAliasConfig
+	aliasCfg.BankCode = getEnv("BANK_CODE", aliasCfg.BankCode)
+	aliasCfg.SortCode = getEnv("BANK_SORT_CODE", aliasCfg.SortCode)
+	if err := svc.SetAliases(repo, aliasCfg); err != nil {
+		slog.Error("Invalid account alias configuration", "error", err)
+		panic(err)
+	}
+	// Every entry and status change is also written to the hash-chained journal audit log
+	svc.SetJournalAudit(repo)
+	// Clients follow balances over Server-Sent Events instead of polling
+	svc.SetBalanceStream(service.NewBalanceStream(service.DefaultMaxStreamsPerUser, service.DefaultStreamBuffer))
+	// Admins can freeze accounts and place legal holds; postings are checked against them
+	svc.SetRestrictions(repo)
+	// Overdraft interest is charged to the bank's income account; without one it only accrues
+	if err := svc.SetOverdrafts(repo, getEnv("OVERDRAFT_INTEREST_ACCOUNT_ID", "")); err != nil {
+		slog.Error("Invalid overdraft configuration", "error", err)
+		panic(err)
+	}
+	// Payments that fail to post are parked and retried instead of failing at once
+	svc.SetParkedPostings(repo, parkingConfigFromEnv())
+	// Admins close accounting periods; entries dated in a closed period are refused
+	svc.SetPeriods(repo)
+	// Interest and fee micro-amounts accrue in a sub-ledger, posted monthly
+	svc.SetAccruals(repo)
+	// Recent transactions are read for the GraphQL API's account views
+	svc.SetTransactionHistory(repo)
 	h := handler.NewLedgerHandler(svc)
+	h.GraphQL = graphql.New(svc, graphqlConfigFromEnv())
+
+	// Feature flags: Postgres is the source of truth, Redis shares a short-lived snapshot
+	var flagStore featureflags.Store = featureflags.NewPostgresStore(database)
+	if redisClient != nil {
+		flagStore = featureflags.NewCachedStore(flagStore, redisClient, featureflags.DefaultFlagTTL)
+	}
+	flags := featureflags.NewClient(flagStore)
+	flagAdmin := featureflags.NewAdminHandler(flagStore, serviceName)
 
 	// Initialize Kafka
 	kafkaBrokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
+	// Creates the topics this service uses when they are missing, see topics.go;
+	// the outcome, including settings that drifted, is reported in /health
+	topicSettings, err := kafka.TopicSettingsFromEnv()
+	if err != nil {
+		slog.Error("Invalid Kafka topic settings", "error", err)
+		os.Exit(1)
+	}
+	topicAdmin := kafka.NewTopicAdmin(kafkaBrokers)
+	go topicAdmin.Start(context.Background(), kafka.TopicSpecs(topicSettings, serviceTopics...))
 	var producer *kafka.Producer
 
 	producer = kafka.NewProducer(kafkaBrokers)
 	if producer != nil {
 		slog.Info("Kafka producer initialized")
+		svc.SetProducer(producer)
 	}
 
+	// Export consumer lag so dashboards can alert when the ledger falls behind on payments
+	lagExporter := kafka.NewLagExporter(kafkaBrokers, consumer.PaymentConsumerGroup, []string{kafka.TopicPaymentCreated}, kafka.DefaultLagInterval)
+	go lagExporter.Start(context.Background())
+
 	// Start Kafka consumer for payment events
+	paymentConsumer := consumer.NewPaymentConsumer(kafkaBrokers, database, svc, producer)
+	paymentConsumer.SetBatchConfig(paymentBatchConfigFromEnv())
 	go func() {
-		paymentConsumer := consumer.NewPaymentConsumer(kafkaBrokers, svc, producer)
-		if paymentConsumer != nil {
-			if err := paymentConsumer.Start(context.Background()); err != nil {
-				slog.Error("Kafka consumer error", "error", err)
-			}
+		if err := paymentConsumer.Start(context.Background()); err != nil {
+			slog.Error("Kafka consumer error", "error", err)
+		}
+	}()
+
+	// Payments cancelled in the payment service are skipped, or reversed if already posted
+	cancelConsumer := consumer.NewPaymentCancelConsumer(kafkaBrokers, database, svc)
+	go func() {
+		if err := cancelConsumer.Start(context.Background()); err != nil {
+			slog.Error("Payment cancellation consumer error", "error", err)
+		}
+	}()
+	cancelLagExporter := kafka.NewLagExporter(kafkaBrokers, consumer.PaymentCancelConsumerGroup, []string{kafka.TopicPaymentCancelled}, kafka.DefaultLagInterval)
+	go cancelLagExporter.Start(context.Background())
+
+	// Background work runs from the jobs table, so each scheduled run happens
+	// on one replica and failures are retried and visible in the admin API
+	jobStore := jobs.NewPostgresStore(database)
+	jobRunner := jobs.NewRunner(jobStore, serviceName, jobs.DefaultConfig())
+	jobRunner.Schedule("ledger.balance_snapshot", jobs.Every(10*time.Minute), svc.SnapshotJob)
+	jobRunner.Schedule("ledger.reconciliation", jobs.Every(15*time.Minute), svc.ReconciliationJob)
+	jobRunner.Schedule("ledger.payment_inbox_purge", jobs.Every(time.Hour), paymentConsumer.PurgeInboxJob)
+	jobRunner.Schedule("ledger.payment_cancel_inbox_purge", jobs.Every(time.Hour), cancelConsumer.PurgeInboxJob)
+	jobRunner.Schedule("ledger.alias_backfill", jobs.Every(10*time.Minute), svc.AliasBackfillJob)
+	jobRunner.Schedule("ledger.overdraft_interest", jobs.Every(time.Hour), svc.OverdraftInterestJob)
+	jobRunner.Schedule("ledger.accrual_rollup", jobs.Every(time.Hour), svc.AccrualRollupJob)
+	jobRunner.Schedule("ledger.parked_posting_retry", jobs.Every(time.Minute), svc.ParkedPostingRetryJob)
+	// Historical transactions of migrated customers are booked against the
+	// migration suspense account in the background
+	jobRunner.Handle(service.TransactionImportJobKind, svc.TransactionImportJob)
+	svc.SetImports(repo, jobRunner, getEnv("MIGRATION_SUSPENSE_ACCOUNT_ID", ""))
+	go jobRunner.Run(context.Background())
+	jobAdmin := jobs.NewAdminHandler(jobStore, serviceName)
+
+	// Journal events from every replica feed the balance streams connected to this one
+	go func() {
+		journalConsumer := consumer.NewJournalStreamConsumer(kafkaBrokers, svc)
+		if err := journalConsumer.Start(context.Background()); err != nil {
+			slog.Error("Journal stream consumer error", "error", err)
 		}
 	}()
 
@@ -114,8 +271,17 @@ func main() {
 		os.Exit(0)
 	}()
 
-	// Get JWT secret for auth
-	jwtSecret := requireEnv("JWT_SECRET")
+	// Maintenance mode: MAINTENANCE_MODE forces it for this deployment, and admins
+	// switch the global and per-service scopes at runtime, shared through Redis
+	var maintenanceStore maintenance.Store = maintenance.NewMemoryStore()
+	if redisClient != nil {
+		maintenanceStore = maintenance.NewRedisStore(redisClient)
+	}
+	forcedMaintenance, err := maintenance.ParseMode(getEnv("MAINTENANCE_MODE", "off"))
+	if err != nil {
+		slog.Error("Invalid MAINTENANCE_MODE", "error", err)
+		panic(err)
+	}
 
 	// Setup Router
 	r := gin.Default()
@@ -127,52 +293,239 @@ func main() {
 	r.Use(middleware.RequestLogger(serviceName))
 	r.Use(middleware.Tracing(serviceName))
 	r.Use(middleware.CORS())
-	r.Use(middleware.RateLimit())
+	r.Use(middleware.RateLimitWithPolicies(cfg.RateLimitPolicies()))
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
 	r.Use(metrics.PrometheusMiddleware(serviceName))
+	// Rejects non-essential requests with 503 while the service is in maintenance
+	r.Use(maintenance.Guard(maintenance.Config{Service: serviceName, Store: maintenanceStore, Forced: forcedMaintenance}))
+	// Outside production, CHAOS_ENABLED lets resilience tests inject latency, errors and dropped responses
+	chaosInjector := chaosFromEnv()
+	r.Use(chaosInjector.Middleware())
+	// Statements and transaction lists are large JSON; compress them for clients that accept it
+	r.Use(middleware.Compress())
+
+	// Readiness needs the database with its migrations applied; without
+	// Redis or Kafka the service only degrades
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	if redisClient != nil {
+		healthChecks.RegisterOptional("redis", health.Redis(redisClient))
+	}
+	healthChecks.RegisterOptional("kafka", health.Kafka(kafkaBrokers))
+	healthChecks.Info("kafka_topics", func() interface{} { return topicAdmin.Report() })
+	registerRoutes(r, h, database, flags, flagAdmin, jobAdmin, maintenance.NewAdminHandler(maintenanceStore, serviceName), chaos.NewAdminHandler(chaosInjector, serviceName), cfg.JWTAuth(), healthChecks)
+
+	port := getEnv("PORT", "8082")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
+
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes. Each write runs in
+// one database transaction on database, committed only if it succeeds.
+func registerRoutes(r *gin.Engine, h *handler.LedgerHandler, database *gorm.DB, flags *featureflags.Client, flagAdmin *featureflags.AdminHandler, jobAdmin *jobs.AdminHandler, maintenanceAdmin *maintenance.AdminHandler, chaosAdmin *chaos.AdminHandler, jwtAuth middleware.JWTAuthConfig, healthChecks *health.Handler) {
+	unitOfWork := handler.UnitOfWork(database)
 
 	// ============================================
 	// Public endpoints
 	// ============================================
 	r.GET("/metrics", metrics.MetricsHandler())
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": serviceName,
-			"redis":   redisClient != nil,
-			"kafka":   producer != nil,
-		})
-	})
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
 
 	// ============================================
 	// Protected endpoints
 	// ============================================
 	api := r.Group("/api/v1")
-	api.Use(middleware.JWTAuth(jwtSecret))
+	api.Use(middleware.JWTAuthWithConfig(jwtAuth))
+	// Users who have not accepted the current mandatory terms can only read
+	api.Use(middleware.RequireTermsAccepted())
+	// Organization tokens act on the organization's accounts instead of the user's
+	api.Use(middleware.TenantScope())
+	api.Use(featureflags.Middleware(flags))
+	api.Use(unitOfWork)
 	{
 		api.POST("/accounts", h.CreateAccount)
-		api.GET("/accounts", h.ListAccounts)
-		api.POST("/transactions", h.PostTransaction)
+		api.POST("/accounts/bulk", middleware.RequireRole("admin"), h.BulkCreateAccounts)
+		// Postings move money between any accounts, so only service accounts
+		// (e.g. payment-service) with the ledger:write scope may make them
+		writes := api.Group("", middleware.RequireRole("service"), middleware.RequireServiceScope("ledger:write"))
+		writes.POST("/transactions", h.PostTransaction)
+		writes.POST("/transactions/batch", h.PostTransactionsBatch)
+		writes.POST("/transactions/:id/book", h.BookTransaction)
+		writes.POST("/transactions/:id/reverse", h.ReverseTransaction)
+		api.PUT("/transactions/:id/category", h.SetTransactionCategory)
+		api.GET("/categories", h.ListCategories)
+
+		// Balance and spend reads: bursts of identical requests from one user hit the DB once.
+		// Reads answer within 5s; statements are generated on request and get 30s.
+		reads := api.Group("", middleware.RequireServiceScope("ledger:read"), middleware.Timeout(5*time.Second), middleware.CoalesceGETs())
+		reads.GET("/accounts", h.ListAccounts)
+		reads.GET("/accounts/:id/balance", h.GetBalance)
+		reads.GET("/accounts/:id/statement", middleware.Timeout(30*time.Second), h.GetStatement)
+		reads.GET("/accounts/:id/accruals", h.ListAccountAccruals)
+		reads.GET("/spending/categories", h.GetSpendingByCategory)
+		// IBANs and sort code/account numbers resolve to the internal account ID, e.g. for transfers
+		reads.GET("/account-aliases/resolve", h.ResolveAccountAlias)
+		// Long-lived, so it bypasses request coalescing
+		api.GET("/accounts/:id/stream", middleware.RequireServiceScope("ledger:read"), h.StreamAccount)
+		// Accounts, balances and transactions in one query, e.g. for the app's overview screen
+		api.POST("/graphql", middleware.RequireServiceScope("ledger:read"), middleware.Timeout(5*time.Second), h.ExecuteGraphQL)
 	}
 
-	port := getEnv("PORT", "8082")
-	slog.Info("Server listening", "port", port)
-	if err := r.Run(":" + port); err != nil {
-		slog.Error("Failed to start server", "error", err)
+	// ============================================
+	// Open banking endpoints (third-party consent tokens only)
+	// ============================================
+	ob := r.Group("/api/v1/open-banking")
+	ob.Use(middleware.ConsentAuthWithConfig(jwtAuth))
+	{
+		ob.GET("/accounts", middleware.RequireConsentScope("accounts:read"), h.ListConsentedAccounts)
+		ob.GET("/accounts/:id/balance", middleware.RequireConsentScope("balances:read"), h.GetConsentedBalance)
+		ob.GET("/accounts/:id/transactions", middleware.RequireConsentScope("transactions:read"), h.GetConsentedTransactions)
+	}
+
+	// ============================================
+	// Delegated access endpoints (delegate tokens only)
+	// ============================================
+	delegated := r.Group("/api/v1/delegated")
+	delegated.Use(middleware.DelegationAuthWithConfig(jwtAuth), middleware.RequireDelegation(middleware.DelegationReadScope))
+	{
+		delegated.GET("/accounts", h.ListDelegatedAccounts)
+		delegated.GET("/accounts/:id/balance", h.GetDelegatedBalance)
+		delegated.GET("/accounts/:id/statement", middleware.Timeout(30*time.Second), h.GetDelegatedStatement)
+	}
+
+	// ============================================
+	// Internal endpoints (service-to-service only)
+	// ============================================
+	internal := r.Group("/internal/v1")
+	internal.Use(middleware.JWTAuthWithConfig(jwtAuth), middleware.RequireRole("service"), unitOfWork)
+	{
+		// Card authorizations check the available balance of the card's account
+		internal.GET("/accounts/:id/balance", middleware.RequireServiceScope("ledger:read"), middleware.Timeout(5*time.Second), h.GetAccountBalanceInternal)
+		// Daily interest and fees accrue in the sub-ledger instead of the journal
+		internal.POST("/accruals", middleware.RequireServiceScope("ledger:write"), h.RecordAccrual)
 	}
+
+	// ============================================
+	// Admin endpoints
+	// ============================================
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.JWTAuthWithConfig(jwtAuth.Admin()), middleware.RequireRole("admin"))
+	flagAdmin.RegisterRoutes(admin)
+	jobAdmin.RegisterRoutes(admin)
+	maintenanceAdmin.RegisterRoutes(admin)
+	chaosAdmin.RegisterRoutes(admin)
+	admin.GET("/journal-audit/verify", h.VerifyJournalAudit)
+	// Imports commit their rows before queueing the job that books them, so
+	// they stay outside the request's transaction
+	h.RegisterImportRoutes(admin)
+	ledgerAdmin := admin.Group("", unitOfWork)
+	h.RegisterRestrictionRoutes(ledgerAdmin)
+	h.RegisterOverdraftRoutes(ledgerAdmin)
+	h.RegisterParkedPostingRoutes(ledgerAdmin)
+	h.RegisterPeriodRoutes(ledgerAdmin)
 }
 
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// verifyJournalAudit runs the "verify-audit" command and returns the exit code
+func verifyJournalAudit(repo *repository.LedgerRepository) int {
+	svc := service.NewLedgerService(repo)
+	svc.SetJournalAudit(repo)
+	result, err := svc.VerifyJournalAudit(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
 	}
-	return fallback
+	if !result.Valid {
+		fmt.Printf("journal audit chain BROKEN at sequence %d: %s (%d records verified before it)\n", result.BrokenSequence, result.Reason, result.RecordsChecked)
+		return 1
+	}
+	fmt.Printf("journal audit chain valid: %d records, head sequence %d, head hash %s\n", result.RecordsChecked, result.HeadSequence, result.HeadHash)
+	return 0
 }
 
-// requireEnv returns the value of an environment variable or panics if not set.
-func requireEnv(key string) string {
-	if value, exists := os.LookupEnv(key); exists && value != "" {
+// parkingConfigFromEnv reads how parked payment postings are retried from
+// PARKED_POSTING_RETRY_DELAY, e.g. "5m", and PARKED_POSTING_MAX_RETRIES
+func parkingConfigFromEnv() service.ParkingConfig {
+	var cfg service.ParkingConfig
+	if value := getEnv("PARKED_POSTING_RETRY_DELAY", ""); value != "" {
+		delay, err := time.ParseDuration(value)
+		if err != nil || delay <= 0 {
+			panic("Invalid PARKED_POSTING_RETRY_DELAY: " + value)
+		}
+		cfg.RetryDelay = delay
+	}
+	if value := getEnv("PARKED_POSTING_MAX_RETRIES", ""); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries <= 0 {
+			panic("Invalid PARKED_POSTING_MAX_RETRIES: " + value)
+		}
+		cfg.MaxRetries = retries
+	}
+	return cfg
+}
+
+// paymentBatchConfigFromEnv reads how consumed payments are batched from
+// PAYMENT_BATCH_SIZE and PAYMENT_BATCH_WAIT, e.g. "20ms"
+func paymentBatchConfigFromEnv() consumer.PaymentBatchConfig {
+	var cfg consumer.PaymentBatchConfig
+	if value := getEnv("PAYMENT_BATCH_SIZE", ""); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size <= 0 {
+			panic("Invalid PAYMENT_BATCH_SIZE: " + value)
+		}
+		cfg.Size = size
+	}
+	if value := getEnv("PAYMENT_BATCH_WAIT", ""); value != "" {
+		wait, err := time.ParseDuration(value)
+		if err != nil || wait <= 0 {
+			panic("Invalid PAYMENT_BATCH_WAIT: " + value)
+		}
+		cfg.Wait = wait
+	}
+	return cfg
+}
+
+// chaosFromEnv reads from CHAOS_ENABLED whether faults may be injected; it
+// panics if they are enabled in production
+func chaosFromEnv() *chaos.Injector {
+	enabled := false
+	if value := getEnv("CHAOS_ENABLED", ""); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			panic("Invalid CHAOS_ENABLED: " + value)
+		}
+		enabled = parsed
+	}
+	injector, err := chaos.New(chaos.Config{Service: serviceName, Environment: getEnv("ENVIRONMENT", "local"), Enabled: enabled})
+	if err != nil {
+		panic(err)
+	}
+	return injector
+}
+
+// graphqlConfigFromEnv reads the GraphQL query limits from GRAPHQL_MAX_COMPLEXITY
+func graphqlConfigFromEnv() graphql.Config {
+	var cfg graphql.Config
+	if value := getEnv("GRAPHQL_MAX_COMPLEXITY", ""); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			panic("Invalid GRAPHQL_MAX_COMPLEXITY: " + value)
+		}
+		cfg.MaxComplexity = limit
+	}
+	return cfg
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
-	slog.Error("Required environment variable not set", "key", key)
-	panic("Required environment variable " + key + " is not set")
+	return fallback
 }
@@ -0,0 +1,22 @@
+package main
+
+// serviceProblemTypes title the error codes the ledger service defines, keyed by
+// code. Their problem types are namespaced under the service; the standard
+// codes are registered by apperrors.
+var serviceProblemTypes = map[string]string{
+	"ALIASES_DISABLED":         "Account aliases are not configured",
+	"ENTRY_NOT_PENDING":        "Journal entry is not pending",
+	"ENTRY_NOT_REVERSIBLE":     "Journal entry cannot be reversed",
+	"IMPORTS_DISABLED":         "Statement imports are not configured",
+	"JOURNAL_AUDIT_DISABLED":   "Journal audit is not configured",
+	"OVERDRAFTS_DISABLED":      "Overdrafts are not configured",
+	"PARKED_POSTINGS_DISABLED": "Parked postings are not configured",
+	"PARKED_POSTING_STATE":     "Parked posting is in the wrong state",
+	"PERIODS_DISABLED":         "Accounting periods are not configured",
+	"PERIOD_CLOSED":            "Accounting period is closed",
+	"PERIOD_STATE":             "Accounting period is in the wrong state",
+	"RESTRICTIONS_DISABLED":    "Account restrictions are not configured",
+	"RESTRICTION_STATE":        "Account restriction is in the wrong state",
+	"STATEMENTS_DISABLED":      "Statements are not configured",
+	"STREAMING_DISABLED":       "Balance streaming is not configured",
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apispec "github.com/femi-lawal/new_bank/backend/ledger-service/api"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/handler"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/chaos"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/featureflags"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/maintenance"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when a route is added or removed without
+// updating api/openapi.yaml
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, handler.NewLedgerHandler(nil), nil, featureflags.NewClient(nil), featureflags.NewAdminHandler(nil, serviceName), jobs.NewAdminHandler(nil, serviceName), maintenance.NewAdminHandler(nil, serviceName), chaos.NewAdminHandler(nil, serviceName), middleware.DefaultJWTConfig("test-secret"), health.New(serviceName))
+
+	spec, err := openapi.Load(apispec.Spec)
+	require.NoError(t, err)
+	require.NoError(t, openapi.CheckRoutes(spec, r.Routes(), "/metrics", metrics.SLOStatusPath))
+	require.NoError(t, metrics.CheckSLORoutes(serviceSLOs, r.Routes()))
+}
+
+func TestPostingRoutes_OnlyServiceAccounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	jwtAuth := middleware.DefaultJWTConfig("test-secret")
+	jwtAuth.Issuer = "neobank"
+	jwtAuth.Audiences = []string{serviceName}
+	registerRoutes(r, handler.NewLedgerHandler(nil), nil, featureflags.NewClient(featureflags.NewMemoryStore()), featureflags.NewAdminHandler(nil, serviceName), jobs.NewAdminHandler(nil, serviceName), maintenance.NewAdminHandler(nil, serviceName), chaos.NewAdminHandler(nil, serviceName), jwtAuth, health.New(serviceName))
+
+	token := func(role, scope string) string {
+		now := time.Now()
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"iss":     "neobank",
+			"aud":     []string{serviceName},
+			"user_id": "caller-1",
+			"role":    role,
+			"scope":   scope,
+			"iat":     now.Unix(),
+			"exp":     now.Add(15 * time.Minute).Unix(),
+		}).SignedString([]byte("test-secret"))
+		require.NoError(t, err)
+		return signed
+	}
+	post := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, path := range []string{"/api/v1/transactions", "/api/v1/transactions/batch", "/api/v1/transactions/entry-1/book", "/api/v1/transactions/entry-1/reverse"} {
+		assert.Equal(t, http.StatusForbidden, post(path, token("user", "")), "user token on %s", path)
+		assert.Equal(t, http.StatusForbidden, post(path, token("service", "ledger:read")), "read-only service token on %s", path)
+	}
+
+	// The handlers reject the empty body, so the tokens were accepted
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/transactions", token("service", "ledger:read ledger:write")))
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/transactions/batch", token("service", "ledger:write")))
+}
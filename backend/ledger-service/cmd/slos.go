@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the ledger service, reported at
+// /slo-status. Balance reads back every payment, so they are the tightest.
+var serviceSLOs = []metrics.SLO{
+	{Name: "post-transaction", Method: http.MethodPost, Route: "/api/v1/transactions", Threshold: 250 * time.Millisecond, Objective: 0.999},
+	{Name: "balance", Method: http.MethodGet, Route: "/api/v1/accounts/:id/balance", Threshold: 50 * time.Millisecond, Objective: 0.999},
+	{Name: "list-accounts", Method: http.MethodGet, Route: "/api/v1/accounts", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "statement", Method: http.MethodGet, Route: "/api/v1/accounts/:id/statement", Threshold: time.Second, Objective: 0.99},
+}
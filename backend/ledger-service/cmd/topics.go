@@ -0,0 +1,16 @@
+package main
+
+import "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+
+// serviceTopics are the Kafka topics the ledger service produces or consumes.
+// Missing ones are created at startup with the KAFKA_TOPIC_* settings.
+var serviceTopics = []string{
+	kafka.TopicAccountCreated,
+	kafka.TopicJournalPosted,
+	kafka.TopicTransactionCategorized,
+	kafka.TopicPaymentCreated,
+	kafka.TopicPaymentCompleted,
+	kafka.TopicPaymentFailed,
+	kafka.TopicPaymentCancelled,
+	kafka.TopicNotificationEmail,
+}
@@ -3,70 +3,276 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// PaymentConsumerGroup is the Kafka consumer group used for payment events
+const PaymentConsumerGroup = "ledger-service"
+
+// Defaults for PaymentBatchConfig
+const (
+	DefaultPaymentBatchSize = 100
+	DefaultPaymentBatchWait = 20 * time.Millisecond
+)
+
+// PaymentBatchConfig sets how payment events are grouped into micro-batches,
+// each posted in one database transaction
+type PaymentBatchConfig struct {
+	// Size is the most payments in a batch, up to service.MaxBatchEntries;
+	// 1 posts each payment on its own
+	Size int
+	// Wait is how long a batch waits for more payments after its first
+	Wait time.Duration
+}
+
 // PaymentConsumer consumes payment events from Kafka
 type PaymentConsumer struct {
 	consumer  *kafka.Consumer
+	inbox     *kafka.Inbox
 	ledgerSvc *service.LedgerService
 	producer  *kafka.Producer // For publishing completion events
+	batch     PaymentBatchConfig
 }
 
-// NewPaymentConsumer creates a new payment event consumer
-func NewPaymentConsumer(brokers []string, ledgerSvc *service.LedgerService, producer *kafka.Producer) *PaymentConsumer {
-	consumer := kafka.NewConsumer(brokers, "ledger-service", kafka.TopicPaymentCreated)
+// NewPaymentConsumer creates a new payment event consumer. Each payment is
+// posted in the same database transaction as its inbox record, so a payment
+// redelivered after a rebalance is never posted twice.
+func NewPaymentConsumer(brokers []string, database *gorm.DB, ledgerSvc *service.LedgerService, producer *kafka.Producer) *PaymentConsumer {
+	consumer := kafka.NewConsumer(brokers, PaymentConsumerGroup, kafka.TopicPaymentCreated)
 	return &PaymentConsumer{
 		consumer:  consumer,
+		inbox:     kafka.NewInbox(database, PaymentConsumerGroup),
 		ledgerSvc: ledgerSvc,
 		producer:  producer,
+		batch:     PaymentBatchConfig{Size: DefaultPaymentBatchSize, Wait: DefaultPaymentBatchWait},
+	}
+}
+
+// SetBatchConfig changes how payments are batched; zero fields keep the
+// defaults
+func (c *PaymentConsumer) SetBatchConfig(cfg PaymentBatchConfig) {
+	if cfg.Size > 0 {
+		c.batch.Size = min(cfg.Size, service.MaxBatchEntries)
+	}
+	if cfg.Wait > 0 {
+		c.batch.Wait = cfg.Wait
 	}
 }
 
-// Start begins consuming payment events
+// Start begins consuming payment events, posting them in micro-batches
 func (c *PaymentConsumer) Start(ctx context.Context) error {
-	slog.Info("Starting payment event consumer", "topic", kafka.TopicPaymentCreated)
+	slog.Info("Starting payment event consumer", "topic", kafka.TopicPaymentCreated, "batch_size", c.batch.Size, "batch_wait", c.batch.Wait)
+
+	return c.consumer.ConsumeDeliveryBatches(ctx, c.batch.Size, c.batch.Wait, func(ds []kafka.Delivery) error {
+		return c.handleBatch(ctx, ds)
+	})
+}
+
+// handleBatch posts the payments of a micro-batch in one database
+// transaction. When the batch cannot be posted, e.g. because one payment is
+// on a frozen account, each payment is handled on its own instead, so that one
+// is parked or failed and the rest still post.
+func (c *PaymentConsumer) handleBatch(ctx context.Context, ds []kafka.Delivery) error {
+	if len(ds) == 1 {
+		return c.handle(ctx, ds[0])
+	}
+	if err := c.postBatch(ctx, ds); err != nil {
+		slog.Info("Posting payment batch one by one", "size", len(ds), "reason", err)
+		var errs []error
+		for _, d := range ds {
+			if err := c.handle(ctx, d); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return nil
+}
 
-	return c.consumer.Consume(ctx, func(key string, value []byte) error {
+// deliveryKey identifies a delivery of the consumed topic
+type deliveryKey struct {
+	partition int
+	offset    int64
+}
+
+// errDuplicatePayment refuses a batch with two events for one payment, which
+// are handled one by one instead
+var errDuplicatePayment = errors.New("payment appears twice in the batch")
+
+// postBatch posts the payments of ds in one transaction with their inbox
+// records, skipping those already processed or cancelled
+func (c *PaymentConsumer) postBatch(ctx context.Context, ds []kafka.Delivery) error {
+	events := make(map[deliveryKey]kafka.PaymentEvent, len(ds))
+	payments := make(map[uuid.UUID]bool, len(ds))
+	for _, d := range ds {
 		var event kafka.PaymentEvent
-		if err := json.Unmarshal(value, &event); err != nil {
-			slog.Error("Failed to unmarshal payment event", "error", err)
+		if err := json.Unmarshal(d.Value, &event); err != nil {
 			return err
 		}
+		paymentID, err := uuid.Parse(event.PaymentID)
+		if err != nil {
+			return fmt.Errorf("invalid payment id %q", event.PaymentID)
+		}
+		if payments[paymentID] {
+			return errDuplicatePayment
+		}
+		payments[paymentID] = true
+		events[deliveryKey{d.Partition, d.Offset}] = event
+	}
 
-		slog.Info("Processing payment event", "payment_id", event.PaymentID, "amount", event.Amount)
+	var entries []*model.JournalEntry
+	var posted []kafka.PaymentEvent
+	fresh, err := c.inbox.ProcessBatch(ctx, ds, func(tx *gorm.DB, fresh []kafka.Delivery) error {
+		entries, posted = nil, nil
+		repo := repository.NewLedgerRepository(tx)
+		paymentIDs := make([]uuid.UUID, len(fresh))
+		for i, d := range fresh {
+			paymentIDs[i] = uuid.MustParse(events[deliveryKey{d.Partition, d.Offset}].PaymentID)
+		}
+		// Locked until commit, as the single payment path locks each
+		records, err := repo.LockPayments(paymentIDs)
+		if err != nil {
+			return err
+		}
+		cancelled := make(map[uuid.UUID]bool, len(records))
+		for _, record := range records {
+			cancelled[record.PaymentID] = record.CancelledAt != nil
+		}
+		var postedIDs []uuid.UUID
+		for i, d := range fresh {
+			if cancelled[paymentIDs[i]] {
+				slog.Info("Skipping cancelled payment", "payment_id", paymentIDs[i])
+				continue
+			}
+			posted = append(posted, events[deliveryKey{d.Partition, d.Offset}])
+			postedIDs = append(postedIDs, paymentIDs[i])
+		}
+		if len(posted) == 0 {
+			return nil
+		}
 
-		// Process the transfer
-		err := c.processPayment(ctx, event)
+		entries, err = c.ledgerSvc.PostPaymentsBatchInTx(repo, posted)
 		if err != nil {
-			slog.Error("Failed to process payment", "payment_id", event.PaymentID, "error", err)
-			// Publish failure event
-			c.publishResult(ctx, event.PaymentID, kafka.TopicPaymentFailed, event)
-			return nil // Don't retry, just log
+			return err
+		}
+		entryIDs := make(map[uuid.UUID]uuid.UUID, len(posted))
+		for i, paymentID := range postedIDs {
+			entryIDs[paymentID] = entries[i].ID
 		}
+		for i := range records {
+			if id, ok := entryIDs[records[i].PaymentID]; ok {
+				records[i].EntryID = &id
+			}
+		}
+		return repo.SavePayments(records)
+	})
+	if err != nil {
+		return err
+	}
+	if skipped := len(ds) - len(fresh); skipped > 0 {
+		slog.Info("Skipping already processed payment events", "count", skipped)
+	}
 
-		// Publish success event
+	c.ledgerSvc.PostedBatch(entries)
+	for i, event := range posted {
 		event.Status = "COMPLETED"
+		event.LedgerEntryID = entries[i].ID.String()
 		c.publishResult(ctx, event.PaymentID, kafka.TopicPaymentCompleted, event)
+	}
+	slog.Info("Payment batch processed successfully", "size", len(ds), "posted", len(posted))
+	return nil
+}
 
-		slog.Info("Payment processed successfully", "payment_id", event.PaymentID)
-		return nil
+// handle posts one payment event
+func (c *PaymentConsumer) handle(ctx context.Context, d kafka.Delivery) error {
+	var event kafka.PaymentEvent
+	if err := json.Unmarshal(d.Value, &event); err != nil {
+		slog.Error("Failed to unmarshal payment event", "error", err)
+		return err
+	}
+
+	slog.Info("Processing payment event", "payment_id", event.PaymentID, "amount", event.Amount)
+
+	var entry *model.JournalEntry
+	var parked *model.ParkedPosting
+	var postErr error
+	var cancelled bool
+	processed, err := c.inbox.Process(ctx, d, func(tx *gorm.DB) error {
+		repo := repository.NewLedgerRepository(tx)
+		paymentID, err := uuid.Parse(event.PaymentID)
+		if err != nil {
+			postErr = fmt.Errorf("invalid payment id %q", event.PaymentID)
+			return nil
+		}
+		// Locked until commit, so a cancellation consumed meanwhile waits
+		// and then sees the entry to reverse
+		record, err := repo.LockPayment(paymentID)
+		if err != nil {
+			return err
+		}
+		if record.CancelledAt != nil {
+			cancelled = true
+			return nil
+		}
+		// The posting runs in a savepoint: if it fails, only the posting is
+		// rolled back and the payment is still recorded as handled
+		entry, postErr = c.ledgerSvc.PostPaymentInTx(repo, event)
+		if postErr != nil {
+			// Parked for a retry once the cause, such as a freeze, is fixed
+			parked, err = c.ledgerSvc.ParkPaymentInTx(repo, paymentID, event, postErr)
+			if errors.Is(err, service.ErrParkedPostingsDisabled) {
+				return nil
+			}
+			return err
+		}
+		record.EntryID = &entry.ID
+		return repo.SavePayment(record)
 	})
-}
+	if err != nil {
+		// Nothing was recorded, so the payment is retried on redelivery
+		slog.Error("Failed to record payment event", "payment_id", event.PaymentID, "error", err)
+		return err
+	}
+	if !processed {
+		slog.Info("Skipping already processed payment event", "payment_id", event.PaymentID, "partition", d.Partition, "offset", d.Offset)
+		return nil
+	}
+	if cancelled {
+		slog.Info("Skipping cancelled payment", "payment_id", event.PaymentID)
+		return nil
+	}
+
+	if parked != nil {
+		slog.Warn("Parked payment that failed to post", "payment_id", event.PaymentID, "parked_posting_id", parked.ID,
+			"reason", parked.ReasonCode, "next_attempt_at", parked.NextAttemptAt, "error", postErr)
+		return nil
+	}
+	if postErr != nil {
+		slog.Error("Failed to process payment", "payment_id", event.PaymentID, "error", postErr)
+		// Publish failure event
+		c.publishResult(ctx, event.PaymentID, kafka.TopicPaymentFailed, event)
+		return nil // Don't retry, just log
+	}
+	c.ledgerSvc.Posted(entry)
+
+	// Publish success event
+	event.Status = "COMPLETED"
+	event.LedgerEntryID = entry.ID.String()
+	c.publishResult(ctx, event.PaymentID, kafka.TopicPaymentCompleted, event)
 
-// processPayment executes the ledger transaction
-func (c *PaymentConsumer) processPayment(ctx context.Context, event kafka.PaymentEvent) error {
-	// Create journal entry with postings using the convenience method
-	_, err := c.ledgerSvc.PostTransfer(
-		event.FromAccountID,
-		event.ToAccountID,
-		event.Amount,
-		"Payment: "+event.Description,
-	)
-	return err
+	slog.Info("Payment processed successfully", "payment_id", event.PaymentID)
+	return nil
 }
 
 // publishResult publishes the payment result event
@@ -80,6 +286,18 @@ func (c *PaymentConsumer) publishResult(ctx context.Context, paymentID, topic st
 	}
 }
 
+// PurgeInboxJob deletes inbox records older than the retention
+func (c *PaymentConsumer) PurgeInboxJob(ctx context.Context, _ *jobs.Job) error {
+	deleted, err := c.inbox.Purge(ctx, time.Now().Add(-kafka.DefaultInboxRetention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.Info("Purged payment inbox", "count", deleted)
+	}
+	return nil
+}
+
 // Close closes the consumer
 func (c *PaymentConsumer) Close() error {
 	return c.consumer.Close()
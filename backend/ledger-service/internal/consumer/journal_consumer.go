@@ -0,0 +1,56 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+)
+
+// JournalStreamConsumerGroupPrefix prefixes the per-replica consumer group of
+// journal events. Every replica needs every event, since a client's stream may
+// be connected to any of them.
+const JournalStreamConsumerGroupPrefix = "ledger-stream-"
+
+// JournalStreamConsumer feeds journal events to the balance streams connected
+// to this replica
+type JournalStreamConsumer struct {
+	consumer  *kafka.Consumer
+	ledgerSvc *service.LedgerService
+}
+
+// NewJournalStreamConsumer creates a consumer in a group of its own that starts
+// at the latest event; streams only push live updates, so nothing is replayed
+func NewJournalStreamConsumer(brokers []string, ledgerSvc *service.LedgerService) *JournalStreamConsumer {
+	replica, err := os.Hostname()
+	if err != nil || replica == "" {
+		replica = "local"
+	}
+	return &JournalStreamConsumer{
+		consumer:  kafka.NewLatestConsumer(brokers, JournalStreamConsumerGroupPrefix+replica, kafka.TopicJournalPosted),
+		ledgerSvc: ledgerSvc,
+	}
+}
+
+// Start begins consuming journal events
+func (c *JournalStreamConsumer) Start(ctx context.Context) error {
+	slog.Info("Starting journal stream consumer", "topic", kafka.TopicJournalPosted)
+
+	return c.consumer.Consume(ctx, func(key string, value []byte) error {
+		var event kafka.JournalEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			slog.Error("Failed to unmarshal journal event", "error", err)
+			return err
+		}
+		c.ledgerSvc.HandleJournalEvent(event)
+		return nil
+	})
+}
+
+// Close closes the consumer
+func (c *JournalStreamConsumer) Close() error {
+	return c.consumer.Close()
+}
@@ -0,0 +1,121 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PaymentCancelConsumerGroup is the Kafka consumer group used for payment cancellations
+const PaymentCancelConsumerGroup = "ledger-service-payment-cancellations"
+
+// PaymentCancelConsumer honors payments cancelled in the payment service. A
+// payment not posted yet is marked so the payment consumer skips it; one that
+// was posted before the cancellation arrived is reversed.
+type PaymentCancelConsumer struct {
+	consumer  *kafka.Consumer
+	inbox     *kafka.Inbox
+	ledgerSvc *service.LedgerService
+}
+
+// NewPaymentCancelConsumer creates a payment cancellation consumer. Each
+// cancellation is recorded in the same database transaction as its inbox record.
+func NewPaymentCancelConsumer(brokers []string, database *gorm.DB, ledgerSvc *service.LedgerService) *PaymentCancelConsumer {
+	return &PaymentCancelConsumer{
+		consumer:  kafka.NewConsumer(brokers, PaymentCancelConsumerGroup, kafka.TopicPaymentCancelled),
+		inbox:     kafka.NewInbox(database, PaymentCancelConsumerGroup),
+		ledgerSvc: ledgerSvc,
+	}
+}
+
+// Start begins consuming payment cancellations
+func (c *PaymentCancelConsumer) Start(ctx context.Context) error {
+	slog.Info("Starting payment cancellation consumer", "topic", kafka.TopicPaymentCancelled)
+
+	return c.consumer.ConsumeDeliveries(ctx, func(d kafka.Delivery) error {
+		var event kafka.PaymentEvent
+		if err := json.Unmarshal(d.Value, &event); err != nil {
+			slog.Error("Failed to unmarshal payment cancellation", "error", err)
+			return err
+		}
+		paymentID, err := uuid.Parse(event.PaymentID)
+		if err != nil {
+			slog.Error("Ignoring payment cancellation with an invalid payment id", "payment_id", event.PaymentID)
+			return nil
+		}
+
+		var reversal *model.JournalEntry
+		var reverseErr error
+		processed, err := c.inbox.Process(ctx, d, func(tx *gorm.DB) error {
+			repo := repository.NewLedgerRepository(tx)
+			record, err := repo.LockPayment(paymentID)
+			if err != nil {
+				return err
+			}
+			if record.CancelledAt != nil {
+				return nil
+			}
+			now := time.Now()
+			record.CancelledAt = &now
+			// A payment parked after failing to post is never retried
+			if err := repo.CancelParkedPosting(paymentID); err != nil {
+				return err
+			}
+			if record.EntryID != nil {
+				// The payment was posted before the cancellation arrived. The
+				// reversal runs in a savepoint, so if it fails the cancellation
+				// is still recorded and the entry is left for operations.
+				reversal, reverseErr = c.ledgerSvc.ReverseEntryInTx(repo, *record.EntryID, "Cancelled payment: "+event.Description)
+				if reverseErr == nil {
+					record.ReversalEntryID = &reversal.ID
+				}
+			}
+			return repo.SavePayment(record)
+		})
+		if err != nil {
+			slog.Error("Failed to record payment cancellation", "payment_id", event.PaymentID, "error", err)
+			return err
+		}
+		if !processed {
+			slog.Info("Skipping already processed payment cancellation", "payment_id", event.PaymentID, "partition", d.Partition, "offset", d.Offset)
+			return nil
+		}
+
+		switch {
+		case reverseErr != nil:
+			slog.Error("Failed to reverse cancelled payment; reverse its entry manually", "payment_id", event.PaymentID, "error", reverseErr)
+		case reversal != nil:
+			c.ledgerSvc.Posted(reversal)
+			slog.Info("Reversed payment cancelled after posting", "payment_id", event.PaymentID, "entry_id", reversal.ID)
+		default:
+			slog.Info("Payment cancellation recorded", "payment_id", event.PaymentID)
+		}
+		return nil
+	})
+}
+
+// PurgeInboxJob deletes inbox records older than the retention
+func (c *PaymentCancelConsumer) PurgeInboxJob(ctx context.Context, _ *jobs.Job) error {
+	deleted, err := c.inbox.Purge(ctx, time.Now().Add(-kafka.DefaultInboxRetention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.Info("Purged payment cancellation inbox", "count", deleted)
+	}
+	return nil
+}
+
+// Close closes the consumer
+func (c *PaymentCancelConsumer) Close() error {
+	return c.consumer.Close()
+}
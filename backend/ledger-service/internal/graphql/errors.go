@@ -0,0 +1,93 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Codes in the extensions of errors, so clients can tell them apart
+const (
+	CodeParseFailed      = "GRAPHQL_PARSE_FAILED"
+	CodeValidationFailed = "GRAPHQL_VALIDATION_FAILED"
+	CodeTooComplex       = "QUERY_TOO_COMPLEX"
+	CodeForbidden        = "FORBIDDEN"
+	CodeInternal         = "INTERNAL_ERROR"
+)
+
+// Error is a GraphQL error as it appears in a response's errors list
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func validationError(loc Location, format string, args ...interface{}) *Error {
+	return &Error{
+		Message:    fmt.Sprintf(format, args...),
+		Locations:  []Location{loc},
+		Extensions: map[string]interface{}{"code": CodeValidationFailed},
+	}
+}
+
+// Response is the result of a query. Data is absent when the query was
+// rejected before execution, and null when an error nulled the whole result.
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []*Error        `json:"errors,omitempty"`
+}
+
+// Rejected reports whether the query was not executed because it could not
+// be parsed, was invalid or was too complex
+func (r *Response) Rejected() bool {
+	return r.Data == nil
+}
+
+func rejected(err error) *Response {
+	if gqlErr, ok := err.(*Error); ok {
+		return &Response{Errors: []*Error{gqlErr}}
+	}
+	return &Response{Errors: []*Error{{Message: err.Error(), Extensions: map[string]interface{}{"code": CodeValidationFailed}}}}
+}
+
+// orderedMap is a result object, which keeps its fields in query order
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap(size int) *orderedMap {
+	return &orderedMap{keys: make([]string, 0, size), values: make(map[string]interface{}, size)}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
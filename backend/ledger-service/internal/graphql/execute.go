@@ -0,0 +1,323 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// Request is a GraphQL request as POSTed by clients
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// errored stands for a value that is null because of an error. A null
+// non-null field passes it up to its parent, which becomes null in turn.
+var errored = &struct{ errored bool }{true}
+
+// execution resolves a validated operation. Fields are resolved on every
+// object of a level before any field below them, so resolvers of the same
+// field on the items of a list can batch their loads.
+type execution struct {
+	schema *schema
+	doc    *document
+	vars   map[string]interface{}
+	req    *request
+	errors []*Error
+}
+
+// run executes the operation and returns its result
+func (e *execution) run(op *operation) *Response {
+	data := e.executeObjects(e.schema.query, op.selectionSet, []interface{}{nil}, [][]interface{}{nil})[0]
+	if data == errored {
+		data = nil
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Failed to encode GraphQL result", "error", err)
+		return &Response{Data: json.RawMessage("null"), Errors: []*Error{internalError(nil)}}
+	}
+	return &Response{Data: encoded, Errors: e.errors}
+}
+
+// collectedField is a response key with the fields selected under it
+type collectedField struct {
+	key    string
+	fields []*field
+}
+
+// collectFields returns the fields of a selection set on t in order, with
+// fragments expanded and @skip and @include applied
+func (e *execution) collectFields(t *objectType, set []selection) []*collectedField {
+	var out []*collectedField
+	index := map[string]*collectedField{}
+	var collect func(set []selection, visited map[string]bool)
+	collect = func(set []selection, visited map[string]bool) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *field:
+				if !e.included(sel.directives) {
+					continue
+				}
+				key := sel.responseKey()
+				if cf, ok := index[key]; ok {
+					cf.fields = append(cf.fields, sel)
+					continue
+				}
+				index[key] = &collectedField{key: key, fields: []*field{sel}}
+				out = append(out, index[key])
+			case *fragmentSpread:
+				if visited[sel.name] || !e.included(sel.directives) {
+					continue
+				}
+				visited[sel.name] = true
+				if f := e.doc.fragments[sel.name]; f != nil && f.typeCondition == t.name {
+					collect(f.selectionSet, visited)
+				}
+			case *inlineFragment:
+				if e.included(sel.directives) && (sel.typeCondition == "" || sel.typeCondition == t.name) {
+					collect(sel.selectionSet, visited)
+				}
+			}
+		}
+	}
+	collect(set, map[string]bool{})
+	return out
+}
+
+func (e *execution) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		cond, _ := coerceLiteral(directiveIf, d.arguments[0].value, e.vars)
+		if on, _ := cond.(bool); on == (d.name == "skip") {
+			return false
+		}
+	}
+	return true
+}
+
+// executeObjects resolves a selection set on each of the parents, which
+// share type t. Each result is an *orderedMap, or errored.
+func (e *execution) executeObjects(t *objectType, set []selection, parents []interface{}, paths [][]interface{}) []interface{} {
+	results := make([]interface{}, len(parents))
+	for i := range results {
+		results[i] = newOrderedMap(len(set))
+	}
+
+	for _, cf := range e.collectFields(t, set) {
+		f := cf.fields[0]
+		if f.name == "__typename" {
+			for _, r := range results {
+				if r != errored {
+					r.(*orderedMap).set(cf.key, t.name)
+				}
+			}
+			continue
+		}
+
+		fd := t.field(f.name)
+		fieldPaths := make([][]interface{}, len(parents))
+		for i := range parents {
+			fieldPaths[i] = appendPath(paths[i], cf.key)
+		}
+		values := e.resolveField(t, fd, f, parents, results, fieldPaths)
+
+		var subset []selection
+		for _, sf := range cf.fields {
+			subset = append(subset, sf.selectionSet...)
+		}
+		completed := e.completeValues(fd, subset, values, fieldPaths, f.loc)
+		for i, r := range results {
+			if r == errored {
+				continue
+			}
+			if completed[i] == errored {
+				results[i] = errored
+				continue
+			}
+			r.(*orderedMap).set(cf.key, completed[i])
+		}
+	}
+	return results
+}
+
+// resolveField resolves a field on every parent still in the result, then
+// forces the thunks the resolvers returned
+func (e *execution) resolveField(t *objectType, fd *fieldDef, f *field, parents, results []interface{}, paths [][]interface{}) []interface{} {
+	values := make([]interface{}, len(parents))
+	if fd.authorize != nil && !fd.authorize(&e.req.caller) {
+		for i := range values {
+			values[i] = errored
+			if results[i] != errored {
+				e.errors = append(e.errors, &Error{
+					Message:    fmt.Sprintf("not authorized to read %s.%s", t.name, fd.name),
+					Locations:  []Location{f.loc},
+					Path:       paths[i],
+					Extensions: map[string]interface{}{"code": CodeForbidden},
+				})
+			}
+		}
+		return values
+	}
+
+	args, _ := coerceArgs(fd.args, f.arguments, e.vars)
+	for i, parent := range parents {
+		if results[i] == errored {
+			values[i] = errored
+			continue
+		}
+		v, err := fd.resolve(e.req, parent, args)
+		if err != nil {
+			e.fieldError(err, f, paths[i])
+			v = errored
+		}
+		values[i] = v
+	}
+	for i, v := range values {
+		thunk, ok := v.(func() (interface{}, error))
+		if !ok {
+			continue
+		}
+		if values[i], ok = e.force(thunk, f, paths[i]); !ok {
+			values[i] = errored
+		}
+	}
+	return values
+}
+
+func (e *execution) force(thunk func() (interface{}, error), f *field, path []interface{}) (interface{}, bool) {
+	v, err := thunk()
+	if err != nil {
+		e.fieldError(err, f, path)
+		return nil, false
+	}
+	return v, true
+}
+
+// fieldError records a resolver's error. Errors other than *Error are logged
+// and reported without their details.
+func (e *execution) fieldError(err error, f *field, path []interface{}) {
+	gqlErr, ok := err.(*Error)
+	if !ok {
+		slog.Error("GraphQL resolver failed", "field", f.name, "path", path, "error", err)
+		e.errors = append(e.errors, internalError(path))
+		return
+	}
+	reported := *gqlErr
+	reported.Locations = []Location{f.loc}
+	reported.Path = path
+	e.errors = append(e.errors, &reported)
+}
+
+func internalError(path []interface{}) *Error {
+	return &Error{Message: "internal error", Path: path, Extensions: map[string]interface{}{"code": CodeInternal}}
+}
+
+// pending is a resolved value waiting for the objects in it to be executed
+type pending struct {
+	value  interface{} // a serialized leaf, nil or errored
+	items  []*pending
+	list   bool
+	object int // index into the batch of objects, or -1
+	path   []interface{}
+}
+
+// completeValues turns the values of a field on each parent into results:
+// leaves are serialized, and the objects in all of the values are executed
+// together, one level at a time
+func (e *execution) completeValues(fd *fieldDef, set []selection, values []interface{}, paths [][]interface{}, loc Location) []interface{} {
+	objType := e.schema.types[fd.typ.namedType()]
+	var batch []interface{}
+	var batchPaths [][]interface{}
+
+	var shape func(t *typeRef, v interface{}, path []interface{}) *pending
+	shape = func(t *typeRef, v interface{}, path []interface{}) *pending {
+		p := &pending{value: v, object: -1, path: path}
+		if v == nil || v == errored {
+			return p
+		}
+		if t.elem != nil {
+			p.list, p.value = true, nil
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Slice {
+				e.fieldError(fmt.Errorf("%s resolved to %T, not a list", fd.name, v), &field{name: fd.name, loc: loc}, path)
+				p.list, p.value = false, errored
+				return p
+			}
+			p.items = make([]*pending, rv.Len())
+			for i := range p.items {
+				p.items[i] = shape(t.elem, rv.Index(i).Interface(), appendPath(path, i))
+			}
+			return p
+		}
+		if objType != nil {
+			p.object, p.value = len(batch), nil
+			batch = append(batch, v)
+			batchPaths = append(batchPaths, path)
+			return p
+		}
+		out, err := serialize(t.name, v)
+		if err != nil {
+			e.fieldError(err, &field{name: fd.name, loc: loc}, path)
+			out = errored
+		}
+		p.value = out
+		return p
+	}
+
+	shaped := make([]*pending, len(values))
+	for i, v := range values {
+		shaped[i] = shape(fd.typ, v, paths[i])
+	}
+	var objects []interface{}
+	if len(batch) > 0 {
+		objects = e.executeObjects(objType, set, batch, batchPaths)
+	}
+
+	var build func(t *typeRef, p *pending) interface{}
+	build = func(t *typeRef, p *pending) interface{} {
+		var out interface{}
+		switch {
+		case p.list:
+			items := make([]interface{}, len(p.items))
+			out = items
+			for i, item := range p.items {
+				if items[i] = build(t.elem, item); items[i] == errored {
+					out = errored
+					break
+				}
+			}
+		case p.object >= 0:
+			out = objects[p.object]
+		default:
+			out = p.value
+		}
+		if out == nil && t.nonNull {
+			e.errors = append(e.errors, &Error{
+				Message:   fmt.Sprintf("Cannot return null for non-nullable field %s.", fd.name),
+				Locations: []Location{loc},
+				Path:      p.path,
+			})
+			return errored
+		}
+		if out == errored && !t.nonNull {
+			return nil
+		}
+		return out
+	}
+
+	results := make([]interface{}, len(values))
+	for i, p := range shaped {
+		results[i] = build(fd.typ, p)
+	}
+	return results
+}
+
+// appendPath returns path with elem added, without sharing path's array
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	out := make([]interface{}, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
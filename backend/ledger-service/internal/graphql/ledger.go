@@ -0,0 +1,271 @@
+package graphql
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/google/uuid"
+)
+
+// SDL is the published schema of the API; a test keeps it in step with the
+// resolvers below
+//
+//go:embed schema.graphql
+var SDL string
+
+const (
+	// defaultTransactions is how many transactions an account lists unless
+	// the query asks for another number
+	defaultTransactions = 10
+	// accountsEstimate is how many accounts a caller is assumed to have when
+	// estimating the complexity of a query
+	accountsEstimate = 10
+)
+
+// Source reads the accounts and transactions the API exposes
+type Source interface {
+	ListAccountsByUser(userID string) ([]model.Account, error)
+	ListAccountsByOrg(orgID string) ([]model.Account, error)
+	// GetAccountBalance returns service.ErrAccountNotFound for accounts the
+	// caller cannot see
+	GetAccountBalance(userID, orgID, accountID string) (*model.Account, error)
+	RecentTransactions(accountIDs []uuid.UUID, limit int) (map[uuid.UUID][]model.AccountTransaction, error)
+}
+
+// Caller is who a query runs for, from their access token
+type Caller struct {
+	UserID string
+	// OrgID is set when the caller acts for an organization, with their role in it
+	OrgID   string
+	OrgRole string
+}
+
+// request is the state of one query: its caller and its loaders
+type request struct {
+	caller       Caller
+	source       Source
+	transactions *Loader[transactionsKey, []model.AccountTransaction]
+}
+
+type transactionsKey struct {
+	accountID uuid.UUID
+	first     int
+}
+
+// API runs queries against the ledger schema
+type API struct {
+	schema *schema
+	source Source
+	config Config
+}
+
+// New returns an API reading from source
+func New(source Source, cfg Config) *API {
+	if cfg.MaxComplexity <= 0 {
+		cfg.MaxComplexity = DefaultMaxComplexity
+	}
+	return &API{schema: ledgerSchema(), source: source, config: cfg}
+}
+
+// Execute runs a query for caller. Queries that cannot be parsed, are
+// invalid or are too complex are rejected before anything is read.
+func (a *API) Execute(caller Caller, req Request) *Response {
+	doc, err := parseDocument(req.Query)
+	if err != nil {
+		return rejected(err)
+	}
+	v, errs := a.schema.validate(doc, req.OperationName, req.Variables)
+	if len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	if v.complexity > a.config.MaxComplexity {
+		return rejected(&Error{
+			Message:    fmt.Sprintf("query complexity %d exceeds the maximum of %d", v.complexity, a.config.MaxComplexity),
+			Extensions: map[string]interface{}{"code": CodeTooComplex, "complexity": v.complexity, "max_complexity": a.config.MaxComplexity},
+		})
+	}
+
+	r := &request{caller: caller, source: a.source}
+	r.transactions = NewLoader(r.loadTransactions)
+	e := &execution{schema: a.schema, doc: doc, vars: v.vars, req: r}
+	return e.run(v.op)
+}
+
+// loadTransactions reads the transactions of every account queued, with one
+// repository call per distinct number of transactions asked for
+func (r *request) loadTransactions(keys []transactionsKey) (map[transactionsKey][]model.AccountTransaction, error) {
+	byFirst := map[int][]uuid.UUID{}
+	for _, k := range keys {
+		byFirst[k.first] = append(byFirst[k.first], k.accountID)
+	}
+	out := make(map[transactionsKey][]model.AccountTransaction, len(keys))
+	for first, ids := range byFirst {
+		txs, err := r.source.RecentTransactions(ids, first)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			out[transactionsKey{accountID: id, first: first}] = txs[id]
+		}
+	}
+	return out, nil
+}
+
+// Field-level rules for callers acting for an organization. Viewers see
+// balances but not the identifiers for paying into the account, and overdraft
+// terms are for the organization's owners and admins.
+func notOrgViewer(c *Caller) bool {
+	return c.OrgID == "" || c.OrgRole != tenant.RoleViewer
+}
+
+func orgManager(c *Caller) bool {
+	return c.OrgID == "" || c.OrgRole == tenant.RoleOwner || c.OrgRole == tenant.RoleAdmin
+}
+
+func ledgerSchema() *schema {
+	account := func(p interface{}) *model.Account { return p.(*model.Account) }
+	transaction := func(p interface{}) *model.AccountTransaction { return p.(*model.AccountTransaction) }
+	leaf := func(typ string, get func(p interface{}) interface{}) *fieldDef {
+		return &fieldDef{typ: mustType(typ), resolve: func(_ *request, p interface{}, _ map[string]interface{}) (interface{}, error) {
+			return get(p), nil
+		}}
+	}
+	named := func(name, description string, f *fieldDef) *fieldDef {
+		f.name, f.description = name, description
+		return f
+	}
+	optional := func(s *string) interface{} {
+		if s == nil {
+			return nil
+		}
+		return *s
+	}
+
+	query := &objectType{name: "Query", fields: []*fieldDef{
+		{
+			name:        "accounts",
+			description: "The caller's accounts, or with an organization token the organization's",
+			typ:         mustType("[Account!]!"),
+			listSize:    func(map[string]interface{}) int { return accountsEstimate },
+			resolve: func(r *request, _ interface{}, _ map[string]interface{}) (interface{}, error) {
+				var accounts []model.Account
+				var err error
+				if r.caller.OrgID != "" {
+					accounts, err = r.source.ListAccountsByOrg(r.caller.OrgID)
+				} else {
+					accounts, err = r.source.ListAccountsByUser(r.caller.UserID)
+				}
+				if err != nil {
+					return nil, err
+				}
+				out := make([]interface{}, len(accounts))
+				for i := range accounts {
+					out[i] = &accounts[i]
+				}
+				return out, nil
+			},
+		},
+		{
+			name:        "account",
+			description: "One of the caller's accounts, or null if they have none with the ID",
+			typ:         mustType("Account"),
+			args:        []*argDef{{name: "id", typ: mustType("ID!")}},
+			resolve: func(r *request, _ interface{}, args map[string]interface{}) (interface{}, error) {
+				acc, err := r.source.GetAccountBalance(r.caller.UserID, r.caller.OrgID, args["id"].(string))
+				if errors.Is(err, service.ErrAccountNotFound) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				return acc, nil
+			},
+		},
+	}}
+
+	accountType := &objectType{name: "Account", fields: []*fieldDef{
+		named("id", "", leaf("ID!", func(p interface{}) interface{} { return account(p).ID })),
+		named("accountNumber", "", leaf("String!", func(p interface{}) interface{} { return account(p).AccountNumber })),
+		named("name", "", leaf("String!", func(p interface{}) interface{} { return account(p).Name })),
+		named("type", "ASSET, LIABILITY, EQUITY, INCOME or EXPENSE", leaf("String!", func(p interface{}) interface{} { return string(account(p).Type) })),
+		named("currency", "", leaf("String!", func(p interface{}) interface{} { return account(p).CurrencyCode })),
+		named("status", "", leaf("String!", func(p interface{}) interface{} { return account(p).Status })),
+		named("balance", "", leaf("Balance!", func(p interface{}) interface{} { return p })),
+		withAuth(named("iban", "Not shown to organization viewers", leaf("String", func(p interface{}) interface{} { return optional(account(p).IBAN) })), notOrgViewer),
+		withAuth(named("sortCode", "Not shown to organization viewers", leaf("String", func(p interface{}) interface{} { return optional(account(p).SortCode) })), notOrgViewer),
+		withAuth(named("bankAccountNumber", "Not shown to organization viewers", leaf("String", func(p interface{}) interface{} { return optional(account(p).BankAccountNumber) })), notOrgViewer),
+		withAuth(named("overdraft", "The overdraft facility, or null if the account has none. Only shown to organization owners and admins.",
+			leaf("Overdraft", func(p interface{}) interface{} {
+				if !account(p).HasOverdraft() {
+					return nil
+				}
+				return p
+			})), orgManager),
+		{
+			name:        "transactions",
+			description: "The newest transactions first, pending ones included; first is at most 100",
+			typ:         mustType("[Transaction!]!"),
+			args:        []*argDef{{name: "first", typ: mustType("Int"), defaultValue: defaultTransactions}},
+			listSize: func(args map[string]interface{}) int {
+				if first, ok := args["first"].(int); ok && first > 0 && first <= service.MaxRecentTransactions {
+					return first
+				}
+				return defaultTransactions
+			},
+			resolve: func(r *request, p interface{}, args map[string]interface{}) (interface{}, error) {
+				first := defaultTransactions
+				if n, ok := args["first"].(int); ok {
+					first = n
+				}
+				if first < 1 || first > service.MaxRecentTransactions {
+					return nil, &Error{Message: fmt.Sprintf("first must be between 1 and %d", service.MaxRecentTransactions)}
+				}
+				load := r.transactions.Load(transactionsKey{accountID: account(p).ID, first: first})
+				return func() (interface{}, error) {
+					txs, err := load()
+					if err != nil {
+						return nil, err
+					}
+					out := make([]interface{}, len(txs))
+					for i := range txs {
+						out[i] = &txs[i]
+					}
+					return out, nil
+				}, nil
+			},
+		},
+		named("createdAt", "", leaf("Time!", func(p interface{}) interface{} { return account(p).CreatedAt })),
+	}}
+
+	balance := &objectType{name: "Balance", fields: []*fieldDef{
+		named("booked", "Booked entries only", leaf("Decimal!", func(p interface{}) interface{} { return account(p).CachedBalance })),
+		named("available", "Booked less amounts held by pending entries", leaf("Decimal!", func(p interface{}) interface{} { return account(p).AvailableBalance() })),
+		named("held", "Outgoing amounts of pending entries", leaf("Decimal!", func(p interface{}) interface{} { return account(p).HeldBalance })),
+	}}
+
+	overdraft := &objectType{name: "Overdraft", fields: []*fieldDef{
+		named("limit", "", leaf("Decimal!", func(p interface{}) interface{} { return *account(p).OverdraftLimit })),
+		named("interestRate", "Yearly, e.g. 0.1995", leaf("Decimal!", func(p interface{}) interface{} { return account(p).OverdraftInterestRate })),
+	}}
+
+	transactionType := &objectType{name: "Transaction", description: "A posting on an account", fields: []*fieldDef{
+		named("id", "", leaf("ID!", func(p interface{}) interface{} { return transaction(p).PostingID })),
+		named("entryId", "The journal entry the posting is part of", leaf("ID!", func(p interface{}) interface{} { return transaction(p).JournalEntryID })),
+		named("description", "", leaf("String!", func(p interface{}) interface{} { return transaction(p).Description })),
+		named("amount", "Negative for money out", leaf("Decimal!", func(p interface{}) interface{} { return transaction(p).Amount })),
+		named("status", "PENDING, POSTED, BOOKED, REVERSED or VOID", leaf("String!", func(p interface{}) interface{} { return string(transaction(p).Status) })),
+		named("transactionDate", "", leaf("Time!", func(p interface{}) interface{} { return transaction(p).TransactionDate })),
+		named("createdAt", "", leaf("Time!", func(p interface{}) interface{} { return transaction(p).CreatedAt })),
+	}}
+
+	return newSchema(query, accountType, balance, overdraft, transactionType)
+}
+
+func withAuth(f *fieldDef, authorize func(c *Caller) bool) *fieldDef {
+	f.authorize = authorize
+	return f
+}
@@ -0,0 +1,329 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource serves fixed accounts and counts the transaction reads, so
+// tests can check loads are batched
+type fakeSource struct {
+	userID       string
+	orgID        string
+	accounts     []model.Account
+	transactions map[uuid.UUID][]model.AccountTransaction
+	batches      [][]uuid.UUID
+	failReads    bool
+}
+
+func (f *fakeSource) ListAccountsByUser(userID string) ([]model.Account, error) {
+	if userID != f.userID {
+		return nil, nil
+	}
+	return f.accounts, nil
+}
+
+func (f *fakeSource) ListAccountsByOrg(orgID string) ([]model.Account, error) {
+	if orgID != f.orgID {
+		return nil, nil
+	}
+	return f.accounts, nil
+}
+
+func (f *fakeSource) GetAccountBalance(userID, orgID, accountID string) (*model.Account, error) {
+	for _, acc := range f.accounts {
+		if acc.ID.String() == accountID && (userID == f.userID || orgID == f.orgID) {
+			return &acc, nil
+		}
+	}
+	return nil, service.ErrAccountNotFound
+}
+
+func (f *fakeSource) RecentTransactions(accountIDs []uuid.UUID, limit int) (map[uuid.UUID][]model.AccountTransaction, error) {
+	f.batches = append(f.batches, accountIDs)
+	if f.failReads {
+		return nil, errors.New("connection refused")
+	}
+	out := map[uuid.UUID][]model.AccountTransaction{}
+	for _, id := range accountIDs {
+		txs := f.transactions[id]
+		if len(txs) > limit {
+			txs = txs[:limit]
+		}
+		out[id] = txs
+	}
+	return out, nil
+}
+
+func newFakeSource(accounts int) *fakeSource {
+	src := &fakeSource{userID: uuid.NewString(), orgID: uuid.NewString(), transactions: map[uuid.UUID][]model.AccountTransaction{}}
+	created := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	for i := 0; i < accounts; i++ {
+		iban := "GB82NEOB00000000000" + string(rune('0'+i))
+		acc := model.Account{
+			ID:            uuid.New(),
+			AccountNumber: "ACC-" + string(rune('A'+i)),
+			Name:          "Account " + string(rune('A'+i)),
+			Type:          model.Asset,
+			CurrencyCode:  "GBP",
+			Status:        "ACTIVE",
+			CachedBalance: decimal.NewFromInt(100),
+			HeldBalance:   decimal.NewFromInt(25),
+			IBAN:          &iban,
+			CreatedAt:     created,
+		}
+		src.accounts = append(src.accounts, acc)
+		for j := 0; j < 3; j++ {
+			src.transactions[acc.ID] = append(src.transactions[acc.ID], model.AccountTransaction{
+				PostingID:       uuid.New(),
+				JournalEntryID:  uuid.New(),
+				AccountID:       acc.ID,
+				Description:     "Coffee",
+				Amount:          decimal.RequireFromString("-3.50"),
+				Status:          model.StatusPosted,
+				TransactionDate: created,
+				CreatedAt:       created,
+			})
+		}
+	}
+	return src
+}
+
+// run executes a query and decodes its data
+func run(t *testing.T, api *API, caller Caller, query string, variables map[string]interface{}) (map[string]interface{}, *Response) {
+	t.Helper()
+	resp := api.Execute(caller, Request{Query: query, Variables: variables})
+	var data map[string]interface{}
+	if resp.Data != nil {
+		require.NoError(t, json.Unmarshal(resp.Data, &data))
+	}
+	return data, resp
+}
+
+func TestExecute_AccountsWithTransactions(t *testing.T) {
+	src := newFakeSource(3)
+	api := New(src, Config{})
+
+	data, resp := run(t, api, Caller{UserID: src.userID}, `query Overview($n: Int) {
+		accounts {
+			__typename id name
+			balance { available booked }
+			recent: transactions(first: $n) { id amount status createdAt }
+		}
+	}`, map[string]interface{}{"n": 2})
+	require.Empty(t, resp.Errors)
+
+	accounts := data["accounts"].([]interface{})
+	require.Len(t, accounts, 3)
+	first := accounts[0].(map[string]interface{})
+	assert.Equal(t, "Account", first["__typename"])
+	assert.Equal(t, src.accounts[0].ID.String(), first["id"])
+	assert.Equal(t, map[string]interface{}{"available": "75", "booked": "100"}, first["balance"])
+	recent := first["recent"].([]interface{})
+	require.Len(t, recent, 2)
+	assert.Equal(t, "-3.5", recent[0].(map[string]interface{})["amount"])
+	assert.Equal(t, "2026-03-01T09:30:00Z", recent[0].(map[string]interface{})["createdAt"])
+
+	require.Len(t, src.batches, 1, "the transactions of all accounts are read at once")
+	assert.Len(t, src.batches[0], 3)
+}
+
+func TestExecute_KeepsFieldOrder(t *testing.T) {
+	src := newFakeSource(1)
+	resp := New(src, Config{}).Execute(Caller{UserID: src.userID}, Request{Query: `{ accounts { name id currency } }`})
+	require.Empty(t, resp.Errors)
+	assert.Equal(t, `{"accounts":[{"name":"Account A","id":"`+src.accounts[0].ID.String()+`","currency":"GBP"}]}`, string(resp.Data))
+}
+
+func TestExecute_Account(t *testing.T) {
+	src := newFakeSource(2)
+	api := New(src, Config{})
+	query := `query One($id: ID!) { account(id: $id) { ...Details } }
+		fragment Details on Account { name transactions { description } }`
+
+	data, resp := run(t, api, Caller{UserID: src.userID}, query, map[string]interface{}{"id": src.accounts[1].ID.String()})
+	require.Empty(t, resp.Errors)
+	account := data["account"].(map[string]interface{})
+	assert.Equal(t, "Account B", account["name"])
+	assert.Len(t, account["transactions"], 3)
+
+	data, resp = run(t, api, Caller{UserID: uuid.NewString()}, query, map[string]interface{}{"id": src.accounts[1].ID.String()})
+	require.Empty(t, resp.Errors)
+	assert.Nil(t, data["account"], "other callers' accounts are not found")
+}
+
+func TestExecute_FieldAuthorization(t *testing.T) {
+	src := newFakeSource(2)
+	limit := decimal.NewFromInt(500)
+	src.accounts[0].OverdraftLimit = &limit
+	api := New(src, Config{})
+	query := `{ accounts { name iban overdraft { limit } } }`
+
+	data, resp := run(t, api, Caller{UserID: src.userID}, query, nil)
+	require.Empty(t, resp.Errors, "personal accounts show everything to their owner")
+	first := data["accounts"].([]interface{})[0].(map[string]interface{})
+	assert.NotNil(t, first["iban"])
+	assert.Equal(t, map[string]interface{}{"limit": "500"}, first["overdraft"])
+
+	data, resp = run(t, api, Caller{UserID: uuid.NewString(), OrgID: src.orgID, OrgRole: tenant.RoleViewer}, query, nil)
+	require.Len(t, resp.Errors, 4, "one error per account for each of iban and overdraft")
+	assert.Equal(t, CodeForbidden, resp.Errors[0].Extensions["code"])
+	assert.Equal(t, []interface{}{"accounts", 0, "iban"}, resp.Errors[0].Path)
+	first = data["accounts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Account A", first["name"], "the rest of the query is answered")
+	assert.Nil(t, first["iban"])
+	assert.Nil(t, first["overdraft"])
+
+	_, resp = run(t, api, Caller{UserID: uuid.NewString(), OrgID: src.orgID, OrgRole: tenant.RoleMember}, query, nil)
+	require.Len(t, resp.Errors, 2)
+	assert.Contains(t, resp.Errors[0].Message, "Account.overdraft")
+}
+
+func TestExecute_Complexity(t *testing.T) {
+	src := newFakeSource(1)
+	api := New(src, Config{MaxComplexity: 500})
+
+	// 1 + 10 accounts * (id + transactions(first: 10) of 3 fields) = 321
+	_, resp := run(t, api, Caller{UserID: src.userID}, `{ accounts { id transactions { id amount status } } }`, nil)
+	assert.Empty(t, resp.Errors)
+	src.batches = nil
+
+	// 1 + 10 * (id + 1 + 100 * 3) = 3021
+	_, resp = run(t, api, Caller{UserID: src.userID}, `{ accounts { id transactions(first: 100) { id amount status } } }`, nil)
+	require.True(t, resp.Rejected())
+	assert.Equal(t, CodeTooComplex, resp.Errors[0].Extensions["code"])
+	assert.Equal(t, 3021, resp.Errors[0].Extensions["complexity"])
+	assert.Empty(t, src.batches, "rejected queries read nothing")
+
+	// Aliases do not get around the limit: 50 lists of 10 accounts cost 550
+	var aliased strings.Builder
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(&aliased, "a%d: accounts { id } ", i)
+	}
+	_, resp = run(t, api, Caller{UserID: src.userID}, "{ "+aliased.String()+"}", nil)
+	assert.True(t, resp.Rejected())
+}
+
+func TestExecute_Validation(t *testing.T) {
+	src := newFakeSource(1)
+	api := New(src, Config{})
+	for query, message := range map[string]string{
+		`{ accounts { id secret } }`:                              `Cannot query field "secret" on type "Account".`,
+		`{ accounts }`:                                            `must have a selection of subfields`,
+		`{ accounts { id { x } } }`:                               `must not have a selection`,
+		`{ account { id } }`:                                      `argument "id" of type "ID!" is required`,
+		`{ account(id: 1, extra: 2) { id } }`:                     `Unknown argument "extra"`,
+		`{ accounts { transactions(first: "ten") { id } } }`:      `has an invalid value`,
+		`query ($n: Int) { accounts { id } }`:                     `Variable "$n" is never used.`,
+		`{ accounts { transactions(first: $n) { id } } }`:         `Variable "$n" is not defined.`,
+		`query ($id: String = "x") { account(id: $id) { id } }`:   `used in position expecting type "ID!"`,
+		`{ accounts { ...F } } fragment F on Account { ...F }`:    `Cannot spread fragment "F" within itself.`,
+		`{ accounts { ...B } } fragment B on Balance { held }`:    `can never be of type "Balance"`,
+		`{ accounts { id } } fragment F on Account { id }`:        `Fragment "F" is never used.`,
+		`{ accounts { id: name id } }`:                            `"id" conflict because name and id are different fields`,
+		`{ accounts { id @defer } }`:                              `Unknown directive "@defer".`,
+		`{ __schema { types { name } } }`:                         `Introspection is not supported`,
+		`query A { accounts { id } } query B { accounts { id } }`: `operationName is required`,
+	} {
+		_, resp := run(t, api, Caller{UserID: src.userID}, query, nil)
+		require.True(t, resp.Rejected(), query)
+		assert.Contains(t, resp.Errors[0].Message, message, query)
+	}
+	assert.Empty(t, src.batches)
+}
+
+func TestExecute_Variables(t *testing.T) {
+	src := newFakeSource(1)
+	api := New(src, Config{})
+	query := `query ($id: ID!, $n: Int = 1, $more: Boolean = false) {
+		account(id: $id) { transactions(first: $n) { id } more: transactions @include(if: $more) { id } }
+	}`
+
+	data, resp := run(t, api, Caller{UserID: src.userID}, query, map[string]interface{}{"id": src.accounts[0].ID.String()})
+	require.Empty(t, resp.Errors)
+	account := data["account"].(map[string]interface{})
+	assert.Len(t, account["transactions"], 1)
+	assert.NotContains(t, account, "more")
+
+	_, resp = run(t, api, Caller{UserID: src.userID}, query, map[string]interface{}{"id": src.accounts[0].ID.String(), "n": 1.5})
+	require.True(t, resp.Rejected())
+	assert.Contains(t, resp.Errors[0].Message, `Variable "$n" got invalid value`)
+
+	_, resp = run(t, api, Caller{UserID: src.userID}, query, nil)
+	require.True(t, resp.Rejected())
+	assert.Contains(t, resp.Errors[0].Message, `Variable "$id" of required type "ID!" was not provided.`)
+}
+
+func TestExecute_ResolverErrors(t *testing.T) {
+	src := newFakeSource(2)
+	src.failReads = true
+	api := New(src, Config{})
+
+	// transactions is non-null, so its failure nulls each account, and
+	// accounts' non-null items null the list and then the whole result
+	resp := api.Execute(Caller{UserID: src.userID}, Request{Query: `{ accounts { id transactions { id } } }`})
+	assert.False(t, resp.Rejected())
+	assert.Equal(t, "null", string(resp.Data))
+	require.Len(t, resp.Errors, 2)
+	assert.Equal(t, "internal error", resp.Errors[0].Message, "repository errors are not shown to clients")
+	assert.Equal(t, []interface{}{"accounts", 0, "transactions"}, resp.Errors[0].Path)
+	assert.Len(t, src.batches, 1)
+
+	// A nullable parent stops the null
+	data, resp := run(t, api, Caller{UserID: src.userID}, `query ($id: ID!) { account(id: $id) { id transactions(first: 500) { id } } }`,
+		map[string]interface{}{"id": src.accounts[0].ID.String()})
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "first must be between 1 and 100")
+	assert.Contains(t, data, "account")
+	assert.Nil(t, data["account"])
+}
+
+func TestLoader(t *testing.T) {
+	var fetched [][]int
+	loader := NewLoader(func(keys []int) (map[int]string, error) {
+		fetched = append(fetched, keys)
+		out := map[int]string{}
+		for _, k := range keys {
+			out[k] = string(rune('a' + k))
+		}
+		return out, nil
+	})
+
+	a, b, again := loader.Load(0), loader.Load(1), loader.Load(0)
+	v, err := b()
+	require.NoError(t, err)
+	assert.Equal(t, "b", v)
+	v, _ = a()
+	assert.Equal(t, "a", v)
+	v, _ = again()
+	assert.Equal(t, "a", v)
+	assert.Equal(t, [][]int{{0, 1}}, fetched, "keys are fetched once, in one batch")
+
+	c := loader.Load(2)
+	cached := loader.Load(1)
+	v, _ = c()
+	assert.Equal(t, "c", v)
+	v, _ = cached()
+	assert.Equal(t, "b", v)
+	assert.Equal(t, [][]int{{0, 1}, {2}}, fetched, "later loads start a new batch")
+}
+
+func TestSDL(t *testing.T) {
+	published, err := os.ReadFile("schema.graphql")
+	require.NoError(t, err)
+	assert.Equal(t, string(published), ledgerSchema().SDL(), "schema.graphql is out of date with the resolvers")
+	assert.Equal(t, SDL, string(published))
+}
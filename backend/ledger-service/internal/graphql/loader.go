@@ -0,0 +1,47 @@
+package graphql
+
+// Loader batches the keys resolvers ask for into one fetch, so resolving a
+// field on every item of a list reads the repository once rather than once
+// per item. Load queues a key and returns a thunk; forcing any thunk of a
+// batch fetches all of its keys. Results are cached for the request.
+type Loader[K comparable, V any] struct {
+	fetch   func(keys []K) (map[K]V, error)
+	current *loaderBatch[K, V]
+	batches map[K]*loaderBatch[K, V]
+}
+
+type loaderBatch[K comparable, V any] struct {
+	keys    []K
+	done    bool
+	results map[K]V
+	err     error
+}
+
+// NewLoader returns a loader that fetches a batch of keys with fetch. Keys
+// missing from the map fetch returns load as the zero value.
+func NewLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *Loader[K, V] {
+	return &Loader[K, V]{fetch: fetch, batches: map[K]*loaderBatch[K, V]{}}
+}
+
+// Load queues key for the next fetch and returns a thunk that returns its value
+func (l *Loader[K, V]) Load(key K) func() (V, error) {
+	batch, ok := l.batches[key]
+	if !ok {
+		if l.current == nil {
+			l.current = &loaderBatch[K, V]{}
+		}
+		batch = l.current
+		batch.keys = append(batch.keys, key)
+		l.batches[key] = batch
+	}
+	return func() (V, error) {
+		if !batch.done {
+			if l.current == batch {
+				l.current = nil
+			}
+			batch.done = true
+			batch.results, batch.err = l.fetch(batch.keys)
+		}
+		return batch.results[key], batch.err
+	}
+}
@@ -0,0 +1,82 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDocument(t *testing.T) {
+	doc, err := parseDocument(`
+		# Accounts with their latest transactions
+		query Overview($first: Int = 5, $id: ID!) {
+			mine: accounts { id ...Money transactions(first: $first) { id amount } }
+			account(id: $id) @include(if: true) { ... on Account { name } }
+		}
+		fragment Money on Account { balance { available } }`)
+	require.NoError(t, err)
+	require.Len(t, doc.operations, 1)
+
+	op := doc.operations[0]
+	assert.Equal(t, "Overview", op.name)
+	require.Len(t, op.variables, 2)
+	assert.Equal(t, "Int", op.variables[0].typ.String())
+	assert.True(t, op.variables[0].hasDefault)
+	assert.Equal(t, "ID!", op.variables[1].typ.String())
+
+	accounts := op.selectionSet[0].(*field)
+	assert.Equal(t, "mine", accounts.responseKey())
+	assert.Equal(t, "accounts", accounts.name)
+	assert.Equal(t, "Money", accounts.selectionSet[1].(*fragmentSpread).name)
+	tx := accounts.selectionSet[2].(*field)
+	assert.Equal(t, valueVariable, tx.arguments[0].value.kind)
+
+	account := op.selectionSet[1].(*field)
+	assert.Equal(t, "include", account.directives[0].name)
+	assert.Equal(t, "Account", account.selectionSet[0].(*inlineFragment).typeCondition)
+	assert.Equal(t, Location{Line: 5, Column: 4}, account.loc)
+
+	assert.Equal(t, "Account", doc.fragments["Money"].typeCondition)
+}
+
+func TestParseDocument_Values(t *testing.T) {
+	doc, err := parseDocument(`{ a(s: "tab\tand é", n: -12, f: 1.5e3, l: [1, 2], o: {k: null}, e: DESC) }`)
+	require.NoError(t, err)
+	args := doc.operations[0].selectionSet[0].(*field).arguments
+	assert.Equal(t, "tab\tand é", args[0].value.raw)
+	assert.Equal(t, valueInt, args[1].value.kind)
+	assert.Equal(t, "-12", args[1].value.raw)
+	assert.Equal(t, valueFloat, args[2].value.kind)
+	assert.Len(t, args[3].value.list, 2)
+	assert.Equal(t, valueNull, args[4].value.fields["k"].kind)
+	assert.Equal(t, valueEnum, args[5].value.kind)
+}
+
+func TestParseDocument_Errors(t *testing.T) {
+	for query, message := range map[string]string{
+		``:                      "the document has no operation",
+		`{ accounts { id }`:     "unexpected end of query",
+		`{ accounts { } }`:      "a selection set cannot be empty",
+		`mutation { accounts }`: "only queries are supported",
+		`{ a(s: "open) }`:       "unterminated string",
+		`{ a(n: $) }`:           `unexpected ")"`,
+		`{ a } fragment F on A { a } fragment F on A { b }`: `there can be only one fragment named "F"`,
+		`{ a ^ }`: `unexpected character '^'`,
+	} {
+		_, err := parseDocument(query)
+		require.Error(t, err, query)
+		assert.Contains(t, err.Error(), message, query)
+		assert.Equal(t, CodeParseFailed, err.(*Error).Extensions["code"])
+	}
+}
+
+func TestParseType(t *testing.T) {
+	typ, err := parseType("[[ID!]]!")
+	require.NoError(t, err)
+	assert.Equal(t, "[[ID!]]!", typ.String())
+	assert.Equal(t, "ID", typ.namedType())
+
+	_, err = parseType("[ID")
+	assert.Error(t, err)
+}
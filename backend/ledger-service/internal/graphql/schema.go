@@ -0,0 +1,265 @@
+package graphql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// scalars are the leaf types. Decimal and Time are written as strings:
+// decimals exactly as stored, times in RFC 3339.
+var scalars = map[string]bool{"ID": true, "String": true, "Int": true, "Boolean": true, "Decimal": true, "Time": true}
+
+// objectType is a type with fields. The schema has no interfaces or unions.
+type objectType struct {
+	name        string
+	description string
+	fields      []*fieldDef
+}
+
+func (t *objectType) field(name string) *fieldDef {
+	for _, f := range t.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// resolveFunc returns the value of a field on parent: a value, or a thunk
+// (func() (interface{}, error)) from a Loader, which is forced once every
+// parent's field has been resolved so their loads are batched
+type resolveFunc func(r *request, parent interface{}, args map[string]interface{}) (interface{}, error)
+
+type fieldDef struct {
+	name        string
+	description string
+	typ         *typeRef
+	args        []*argDef
+	resolve     resolveFunc
+	// authorize reports whether the caller may read the field; nil allows everyone
+	authorize func(c *Caller) bool
+	// listSize estimates how many items a list field returns, for the
+	// complexity of what is selected on them
+	listSize func(args map[string]interface{}) int
+}
+
+type argDef struct {
+	name         string
+	description  string
+	typ          *typeRef
+	defaultValue interface{}
+}
+
+func (f *fieldDef) arg(name string) *argDef {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// schema is the set of object types, Query first
+type schema struct {
+	query   *objectType
+	types   map[string]*objectType
+	ordered []*objectType
+}
+
+func newSchema(types ...*objectType) *schema {
+	s := &schema{types: map[string]*objectType{}, ordered: types}
+	for _, t := range types {
+		s.types[t.name] = t
+	}
+	s.query = s.types["Query"]
+	return s
+}
+
+// mustType parses a type reference in the schema's Go definition
+func mustType(src string) *typeRef {
+	t, err := parseType(src)
+	if err != nil {
+		panic(fmt.Sprintf("graphql: invalid type %q: %v", src, err))
+	}
+	return t
+}
+
+// SDL writes the schema in the GraphQL schema definition language
+func (s *schema) SDL() string {
+	var sb strings.Builder
+	for _, t := range s.ordered {
+		writeDescription(&sb, "", t.description)
+		fmt.Fprintf(&sb, "type %s {\n", t.name)
+		for _, f := range t.fields {
+			writeDescription(&sb, "  ", f.description)
+			sb.WriteString("  " + f.name)
+			if len(f.args) > 0 {
+				args := make([]string, len(f.args))
+				for i, a := range f.args {
+					args[i] = a.name + ": " + a.typ.String()
+					if a.defaultValue != nil {
+						args[i] += fmt.Sprintf(" = %v", a.defaultValue)
+					}
+				}
+				sb.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			sb.WriteString(": " + f.typ.String() + "\n")
+		}
+		sb.WriteString("}\n\n")
+	}
+	sb.WriteString("\"An exact decimal amount, as a string\"\nscalar Decimal\n\n")
+	sb.WriteString("\"An RFC 3339 time, as a string\"\nscalar Time\n")
+	return sb.String()
+}
+
+func writeDescription(sb *strings.Builder, indent, description string) {
+	if description != "" {
+		fmt.Fprintf(sb, "%s%q\n", indent, description)
+	}
+}
+
+// coerceInput converts a variable's JSON value or a default to the Go value
+// of an input type: string for ID and String, int for Int, bool for Boolean
+func coerceInput(t *typeRef, v interface{}) (interface{}, error) {
+	if v == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected a non-null %s", t)
+		}
+		return nil, nil
+	}
+	if t.elem != nil {
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceInput(t.elem, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = coerced
+		}
+		return out, nil
+	}
+	switch t.name {
+	case "ID":
+		switch id := v.(type) {
+		case string:
+			return id, nil
+		case int:
+			return strconv.Itoa(id), nil
+		case float64:
+			if id == math.Trunc(id) {
+				return strconv.FormatInt(int64(id), 10), nil
+			}
+		}
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return n, nil
+			}
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("%s is not an input type", t.name)
+	}
+	return nil, fmt.Errorf("expected a %s", t.name)
+}
+
+// coerceLiteral converts a value written in the query to the Go value of an
+// input type, replacing variables with their coerced values
+func coerceLiteral(t *typeRef, v value, vars map[string]interface{}) (interface{}, error) {
+	if v.kind == valueVariable {
+		value := vars[v.raw]
+		if value == nil && t.nonNull {
+			return nil, fmt.Errorf("expected a non-null %s", t)
+		}
+		return value, nil
+	}
+	if v.kind == valueNull {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected a non-null %s", t)
+		}
+		return nil, nil
+	}
+	if t.elem != nil {
+		items := v.list
+		if v.kind != valueList {
+			items = []value{v}
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			coerced, err := coerceLiteral(t.elem, item, vars)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = coerced
+		}
+		return out, nil
+	}
+	switch {
+	case t.name == "ID" && (v.kind == valueString || v.kind == valueInt):
+		return v.raw, nil
+	case t.name == "String" && v.kind == valueString:
+		return v.raw, nil
+	case t.name == "Boolean" && v.kind == valueBoolean:
+		return v.raw == "true", nil
+	case t.name == "Int" && v.kind == valueInt:
+		n, err := strconv.ParseInt(v.raw, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s is out of range for Int", v.raw)
+		}
+		return int(n), nil
+	}
+	return nil, fmt.Errorf("expected a %s", t.name)
+}
+
+// serialize writes a resolved leaf value as JSON-ready output
+func serialize(scalar string, v interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		if scalar == "ID" || scalar == "String" {
+			return x, nil
+		}
+	case uuid.UUID:
+		if scalar == "ID" {
+			return x.String(), nil
+		}
+	case int:
+		if scalar == "Int" {
+			return x, nil
+		}
+	case bool:
+		if scalar == "Boolean" {
+			return x, nil
+		}
+	case decimal.Decimal:
+		if scalar == "Decimal" {
+			return x.String(), nil
+		}
+	case time.Time:
+		if scalar == "Time" {
+			return x.UTC().Format(time.RFC3339Nano), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot write %T as %s", v, scalar)
+}
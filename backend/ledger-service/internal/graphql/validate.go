@@ -0,0 +1,417 @@
+package graphql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DefaultMaxComplexity bounds the cost of a query when Config sets none. A
+// view of every account with its balance and ten recent transactions costs
+// about 500.
+const DefaultMaxComplexity = 2000
+
+// Config sets the limits queries are checked against before they run
+type Config struct {
+	// MaxComplexity bounds a query's estimated cost: one per field, with what
+	// is selected on a list counted once per item it may return
+	MaxComplexity int
+}
+
+// validated is an operation checked against the schema, with its variables
+// coerced and its cost estimated
+type validated struct {
+	op         *operation
+	vars       map[string]interface{}
+	complexity int
+}
+
+type validator struct {
+	schema  *schema
+	doc     *document
+	varDefs map[string]*variableDefinition
+	vars    map[string]interface{}
+	errors  []*Error
+
+	usedVars      map[string]bool
+	usedFragments map[string]bool
+	// fragmentCosts memoizes fragments, so reusing one is not re-walked
+	fragmentCosts map[string]int
+	spreading     map[string]bool
+}
+
+// validate checks the operation to run against the schema and estimates its cost
+func (s *schema) validate(doc *document, operationName string, variables map[string]interface{}) (*validated, []*Error) {
+	op, err := selectOperation(doc, operationName)
+	if err != nil {
+		return nil, []*Error{err}
+	}
+	v := &validator{
+		schema:        s,
+		doc:           doc,
+		varDefs:       map[string]*variableDefinition{},
+		vars:          map[string]interface{}{},
+		usedVars:      map[string]bool{},
+		usedFragments: map[string]bool{},
+		fragmentCosts: map[string]int{},
+		spreading:     map[string]bool{},
+	}
+	v.variables(op, variables)
+	cost := v.selectionSet(s.query, op.selectionSet)
+
+	for _, def := range op.variables {
+		if !v.usedVars[def.name] {
+			v.errorf(def.loc, "Variable \"$%s\" is never used.", def.name)
+		}
+	}
+	for name, f := range doc.fragments {
+		if !v.usedFragments[name] {
+			v.errorf(f.loc, "Fragment %q is never used.", name)
+		}
+	}
+	if len(v.errors) > 0 {
+		return nil, v.errors
+	}
+	return &validated{op: op, vars: v.vars, complexity: cost}, nil
+}
+
+func selectOperation(doc *document, name string) (*operation, *Error) {
+	names := map[string]bool{}
+	for _, op := range doc.operations {
+		if op.name == "" && len(doc.operations) > 1 {
+			return nil, validationError(op.loc, "This anonymous operation must be the only defined operation.")
+		}
+		if names[op.name] {
+			return nil, validationError(op.loc, "There can be only one operation named %q.", op.name)
+		}
+		names[op.name] = true
+	}
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "operationName is required when the document has several operations",
+				Extensions: map[string]interface{}{"code": CodeValidationFailed}}
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name),
+		Extensions: map[string]interface{}{"code": CodeValidationFailed}}
+}
+
+// variables coerces the request's variables to the operation's definitions
+func (v *validator) variables(op *operation, values map[string]interface{}) {
+	for _, def := range op.variables {
+		if v.varDefs[def.name] != nil {
+			v.errorf(def.loc, "There can be only one variable named \"$%s\".", def.name)
+			continue
+		}
+		v.varDefs[def.name] = def
+		if named := def.typ.namedType(); !scalars[named] || named == "Decimal" || named == "Time" {
+			v.errorf(def.loc, "Variable \"$%s\" cannot be non-input type %q.", def.name, def.typ)
+			continue
+		}
+
+		raw, given := values[def.name]
+		var value interface{}
+		var err error
+		switch {
+		case given:
+			value, err = coerceInput(def.typ, raw)
+		case def.hasDefault:
+			value, err = coerceLiteral(def.typ, def.defaultValue, nil)
+		case def.typ.nonNull:
+			v.errorf(def.loc, "Variable \"$%s\" of required type %q was not provided.", def.name, def.typ)
+			continue
+		default:
+			// Omitted: arguments it is used for keep their defaults
+			continue
+		}
+		if err != nil {
+			v.errorf(def.loc, "Variable \"$%s\" got invalid value: %v.", def.name, err)
+			continue
+		}
+		v.vars[def.name] = value
+	}
+}
+
+// selectionSet checks a selection set on t and returns its cost
+func (v *validator) selectionSet(t *objectType, set []selection) int {
+	cost := 0
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *field:
+			cost += v.field(t, sel)
+		case *fragmentSpread:
+			v.directives(sel.directives)
+			cost += v.fragmentSpread(t, sel)
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.typeCondition != "" && sel.typeCondition != t.name {
+				v.typeConditionError(sel.loc, "", sel.typeCondition, t)
+				continue
+			}
+			cost += v.selectionSet(t, sel.selectionSet)
+		}
+	}
+	v.checkMergeable(t, set)
+	return cost
+}
+
+func (v *validator) field(t *objectType, f *field) int {
+	v.directives(f.directives)
+	if f.name == "__typename" {
+		if len(f.arguments) > 0 || f.selectionSet != nil {
+			v.errorf(f.loc, "Field \"__typename\" takes no arguments or subfields.")
+		}
+		return 0
+	}
+	fd := t.field(f.name)
+	if fd == nil {
+		if strings.HasPrefix(f.name, "__") {
+			v.errorf(f.loc, "Introspection is not supported; the schema is published as schema.graphql.")
+		} else {
+			v.errorf(f.loc, "Cannot query field %q on type %q.", f.name, t.name)
+		}
+		return 0
+	}
+
+	args, ok := v.arguments(t, fd, f)
+	named := fd.typ.namedType()
+	child, isObject := v.schema.types[named]
+	switch {
+	case isObject && f.selectionSet == nil:
+		v.errorf(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, fd.typ)
+		return 1
+	case !isObject && f.selectionSet != nil:
+		v.errorf(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, fd.typ)
+		return 1
+	case !isObject:
+		return 1
+	}
+
+	childCost := v.selectionSet(child, f.selectionSet)
+	size := 1
+	if fd.listSize != nil && ok {
+		size = fd.listSize(args)
+	}
+	return 1 + size*childCost
+}
+
+// arguments checks a field's arguments and returns them coerced
+func (v *validator) arguments(t *objectType, fd *fieldDef, f *field) (map[string]interface{}, bool) {
+	ok := true
+	given := map[string]bool{}
+	for _, arg := range f.arguments {
+		ad := fd.arg(arg.name)
+		if ad == nil {
+			v.errorf(arg.loc, "Unknown argument %q on field \"%s.%s\".", arg.name, t.name, fd.name)
+			ok = false
+			continue
+		}
+		if given[arg.name] {
+			v.errorf(arg.loc, "There can be only one argument named %q.", arg.name)
+			ok = false
+			continue
+		}
+		given[arg.name] = true
+		if !v.argumentValue(ad.typ, arg.value) {
+			ok = false
+			continue
+		}
+		if _, err := coerceLiteral(ad.typ, arg.value, v.vars); err != nil {
+			v.errorf(arg.loc, "Argument %q on field \"%s.%s\" has an invalid value: %v.", arg.name, t.name, fd.name, err)
+			ok = false
+		}
+	}
+	for _, ad := range fd.args {
+		if ad.typ.nonNull && ad.defaultValue == nil && !given[ad.name] {
+			v.errorf(f.loc, "Field \"%s.%s\" argument %q of type %q is required, but it was not provided.", t.name, fd.name, ad.name, ad.typ)
+			ok = false
+		}
+	}
+	if !ok {
+		return nil, false
+	}
+	args, err := coerceArgs(fd.args, f.arguments, v.vars)
+	return args, err == nil
+}
+
+// argumentValue checks the variables an argument's value uses are defined
+// with a type the argument accepts
+func (v *validator) argumentValue(t *typeRef, val value) bool {
+	switch val.kind {
+	case valueVariable:
+		v.usedVars[val.raw] = true
+		def := v.varDefs[val.raw]
+		if def == nil {
+			v.errorf(val.loc, "Variable \"$%s\" is not defined.", val.raw)
+			return false
+		}
+		if !variableFits(def, t) {
+			v.errorf(val.loc, "Variable \"$%s\" of type %q used in position expecting type %q.", val.raw, def.typ, t)
+			return false
+		}
+	case valueList:
+		elem := t
+		if t.elem != nil {
+			elem = t.elem
+		}
+		for _, item := range val.list {
+			if !v.argumentValue(elem, item) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// variableFits reports whether a variable can be used where t is expected
+func variableFits(def *variableDefinition, t *typeRef) bool {
+	vt := def.typ
+	if t.nonNull && !vt.nonNull && !def.hasDefault {
+		return false
+	}
+	if (vt.elem == nil) != (t.elem == nil) {
+		return false
+	}
+	if vt.elem != nil {
+		return variableFits(&variableDefinition{typ: vt.elem}, t.elem)
+	}
+	return vt.name == t.name
+}
+
+// directiveIf is the type of the if argument of @skip and @include
+var directiveIf = mustType("Boolean!")
+
+func (v *validator) directives(dirs []*directive) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if len(d.arguments) != 1 || d.arguments[0].name != "if" {
+			v.errorf(d.loc, "Directive \"@%s\" takes exactly one argument, if: Boolean!.", d.name)
+			continue
+		}
+		if !v.argumentValue(directiveIf, d.arguments[0].value) {
+			continue
+		}
+		if _, err := coerceLiteral(directiveIf, d.arguments[0].value, v.vars); err != nil {
+			v.errorf(d.loc, "Directive \"@%s\" has an invalid value: %v.", d.name, err)
+		}
+	}
+}
+
+func (v *validator) fragmentSpread(t *objectType, spread *fragmentSpread) int {
+	f := v.doc.fragments[spread.name]
+	if f == nil {
+		v.errorf(spread.loc, "Unknown fragment %q.", spread.name)
+		return 0
+	}
+	v.usedFragments[f.name] = true
+	if f.typeCondition != t.name {
+		v.typeConditionError(spread.loc, f.name, f.typeCondition, t)
+		return 0
+	}
+	if v.spreading[f.name] {
+		v.errorf(spread.loc, "Cannot spread fragment %q within itself.", f.name)
+		return 0
+	}
+	if cost, ok := v.fragmentCosts[f.name]; ok {
+		return cost
+	}
+	v.spreading[f.name] = true
+	cost := v.selectionSet(t, f.selectionSet)
+	delete(v.spreading, f.name)
+	v.fragmentCosts[f.name] = cost
+	return cost
+}
+
+func (v *validator) typeConditionError(loc Location, fragment, condition string, t *objectType) {
+	if _, ok := v.schema.types[condition]; !ok {
+		v.errorf(loc, "Unknown type %q.", condition)
+		return
+	}
+	if fragment == "" {
+		v.errorf(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", t.name, condition)
+		return
+	}
+	v.errorf(loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", fragment, t.name, condition)
+}
+
+// checkMergeable rejects fields sharing a response key that would need
+// different values: different fields, or the same field with other arguments
+func (v *validator) checkMergeable(t *objectType, set []selection) {
+	seen := map[string]*field{}
+	var walk func(set []selection, visited map[string]bool)
+	walk = func(set []selection, visited map[string]bool) {
+		for _, sel := range set {
+			switch sel := sel.(type) {
+			case *field:
+				key := sel.responseKey()
+				first, ok := seen[key]
+				if !ok {
+					seen[key] = sel
+					continue
+				}
+				if first.name != sel.name {
+					v.errorf(sel.loc, "Fields %q conflict because %s and %s are different fields.", key, first.name, sel.name)
+					continue
+				}
+				fd := t.field(sel.name)
+				if fd == nil {
+					continue
+				}
+				a, errA := coerceArgs(fd.args, first.arguments, v.vars)
+				b, errB := coerceArgs(fd.args, sel.arguments, v.vars)
+				if errA == nil && errB == nil && !reflect.DeepEqual(a, b) {
+					v.errorf(sel.loc, "Fields %q conflict because they have differing arguments.", key)
+				}
+			case *fragmentSpread:
+				f := v.doc.fragments[sel.name]
+				if f != nil && f.typeCondition == t.name && !visited[f.name] {
+					visited[f.name] = true
+					walk(f.selectionSet, visited)
+				}
+			case *inlineFragment:
+				if sel.typeCondition == "" || sel.typeCondition == t.name {
+					walk(sel.selectionSet, visited)
+				}
+			}
+		}
+	}
+	walk(set, map[string]bool{})
+}
+
+func (v *validator) errorf(loc Location, format string, args ...interface{}) {
+	v.errors = append(v.errors, validationError(loc, format, args...))
+}
+
+// coerceArgs returns a field's arguments with defaults filled in
+func coerceArgs(defs []*argDef, given []*argument, vars map[string]interface{}) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(defs))
+	for _, ad := range defs {
+		args[ad.name] = ad.defaultValue
+		for _, arg := range given {
+			if arg.name != ad.name {
+				continue
+			}
+			if arg.value.kind == valueVariable {
+				if _, set := vars[arg.value.raw]; !set {
+					// An omitted variable leaves the default in place
+					break
+				}
+			}
+			value, err := coerceLiteral(ad.typ, arg.value, vars)
+			if err != nil {
+				return nil, err
+			}
+			args[ad.name] = value
+		}
+	}
+	return args, nil
+}
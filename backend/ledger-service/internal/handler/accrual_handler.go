@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+type AccrualRequest struct {
+	AccountID             string `json:"account_id" binding:"required,uuid"`
+	CounterpartyAccountID string `json:"counterparty_account_id" binding:"required,uuid"`
+	Kind                  string `json:"kind" binding:"required,oneof=INTEREST FEE"`
+	// Direction is 1 when the amount is paid to the account and -1 when it is charged to it
+	Direction int    `json:"direction" binding:"required,oneof=1 -1"`
+	Amount    string `json:"amount" binding:"required"`
+	// Date is the day the amount accrued, e.g. "2026-03-02"; today when omitted
+	Date        string `json:"date"`
+	Description string `json:"description" binding:"max=500"`
+	Reference   string `json:"reference" binding:"required,max=100"`
+}
+
+// RecordAccrual stores an amount of interest or a fee in the accrual
+// sub-ledger, for services that accrue daily; it is posted in the account's
+// monthly roll-up. Repeating a reference returns the stored accrual with 200.
+func (h *LedgerHandler) RecordAccrual(c *gin.Context) {
+	var req AccrualRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("amount must be a decimal amount"))
+		return
+	}
+	now := time.Now()
+	date := now
+	if req.Date != "" {
+		if date, err = time.Parse("2006-01-02", req.Date); err != nil {
+			apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("date must be YYYY-MM-DD"))
+			return
+		}
+	}
+
+	accrual, created, err := h.svc(c).Accrue(service.AccrualRequest{
+		AccountID:             req.AccountID,
+		CounterpartyAccountID: req.CounterpartyAccountID,
+		Kind:                  req.Kind,
+		Direction:             req.Direction,
+		Amount:                amount,
+		Date:                  date,
+		Description:           req.Description,
+		Reference:             req.Reference,
+	}, now)
+	if err != nil {
+		respondAccrualError(c, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, accrual)
+}
+
+// ListAccountAccruals drills down from an account's statement into its
+// accruals: those a roll-up entry posted with ?entry_id=, or those dated in
+// ?from= to ?to=, by default this month so far
+func (h *LedgerHandler) ListAccountAccruals(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := c.DefaultQuery("from", now.Format("2006-01")+"-01")
+	to := c.DefaultQuery("to", now.Format("2006-01-02"))
+	accruals, err := h.svc(c).ListAccruals(userID, middleware.GetOrgID(c), c.Param("id"), from, to, c.Query("entry_id"))
+	if err != nil {
+		respondAccrualError(c, err)
+		return
+	}
+	if accruals == nil {
+		accruals = []model.Accrual{}
+	}
+	c.JSON(http.StatusOK, gin.H{"items": accruals})
+}
+
+func respondAccrualError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAccrual), errors.Is(err, service.ErrInvalidStatementRange):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccountNotFound), errors.Is(err, service.ErrTransactionNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccrualsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("ACCRUALS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
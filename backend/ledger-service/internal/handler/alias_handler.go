@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// ResolveAccountAlias resolves ?iban= or ?sort_code=&account_number= to the
+// internal account ID. Only identifiers are returned, not the owner or balance,
+// so any authenticated caller can use it to address a payment.
+func (h *LedgerHandler) ResolveAccountAlias(c *gin.Context) {
+	acc, err := h.svc(c).ResolveAlias(c.Query("iban"), c.Query("sort_code"), c.Query("account_number"))
+	if err != nil {
+		respondAliasError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account_id":          acc.ID,
+		"currency":            acc.CurrencyCode,
+		"status":              acc.Status,
+		"iban":                acc.IBAN,
+		"sort_code":           acc.SortCode,
+		"bank_account_number": acc.BankAccountNumber,
+	})
+}
+
+// respondAliasError maps alias lookup errors to API errors
+func respondAliasError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidAlias):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccountNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAliasesDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("ALIASES_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// sampleStatement is a month of card spending, the shape GET /accounts/:id/statement returns
+func sampleStatement(lines int) *service.Statement {
+	merchants := []string{"Tesco Stores", "TfL Travel", "Pret A Manger", "Amazon Marketplace", "Salary ACME Ltd", "Netflix"}
+	balance := decimal.NewFromInt(2500)
+	statement := &service.Statement{
+		AccountID:      uuid.NewString(),
+		Currency:       "GBP",
+		From:           "2026-09-01",
+		To:             "2026-09-30",
+		OpeningBalance: balance,
+	}
+	start := time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < lines; i++ {
+		amount := decimal.New(-int64(250+i*37%9000), -2)
+		balance = balance.Add(amount)
+		statement.Lines = append(statement.Lines, model.StatementLine{
+			PostingID:      uuid.New(),
+			JournalEntryID: uuid.New(),
+			BookedAt:       start.Add(time.Duration(i) * 47 * time.Minute),
+			Description:    fmt.Sprintf("Card payment to %s", merchants[i%len(merchants)]),
+			Amount:         amount,
+			Balance:        balance,
+		})
+	}
+	statement.ClosingBalance = balance
+	return statement
+}
+
+// sampleAccounts is the shape GET /accounts returns
+func sampleAccounts(n int) []model.Account {
+	userID := uuid.New()
+	accounts := make([]model.Account, n)
+	for i := range accounts {
+		accounts[i] = model.Account{
+			ID:            uuid.New(),
+			UserID:        userID,
+			AccountNumber: fmt.Sprintf("GB%018d", i),
+			Name:          fmt.Sprintf("Savings pot %d", i),
+			Type:          model.Liability,
+			CurrencyCode:  "GBP",
+			Status:        "ACTIVE",
+			CachedBalance: decimal.New(int64(i*1234), -2),
+			HeldBalance:   decimal.Zero,
+			CreatedAt:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			UpdatedAt:     time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC),
+		}
+	}
+	return accounts
+}
+
+// BenchmarkListEndpointCompression serves representative ledger list payloads
+// through the compression middleware and reports the bytes sent per response
+// and the ratio to the uncompressed size, e.g.
+//
+//	go test ./internal/handler -run '^$' -bench Compression -benchmem
+func BenchmarkListEndpointCompression(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Compress())
+	statement := sampleStatement(500)
+	accounts := sampleAccounts(50)
+	r.GET("/statement", func(c *gin.Context) { c.JSON(http.StatusOK, statement) })
+	r.GET("/accounts", func(c *gin.Context) { c.JSON(http.StatusOK, accounts) })
+
+	for _, path := range []string{"/statement", "/accounts"} {
+		raw := serve(r, path, "").Body.Len()
+		for _, encoding := range []string{"identity", "gzip", "deflate"} {
+			b.Run(path[1:]+"/"+encoding, func(b *testing.B) {
+				var size int
+				for i := 0; i < b.N; i++ {
+					size = serve(r, path, encoding).Body.Len()
+				}
+				b.ReportMetric(float64(size), "resp_bytes")
+				b.ReportMetric(float64(size)/float64(raw), "ratio")
+			})
+		}
+	}
+}
+
+func serve(r *gin.Engine, path, acceptEncoding string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
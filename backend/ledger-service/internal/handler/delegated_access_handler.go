@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Delegated access endpoints serve users acting for another user under a
+// delegation token. The user ID is the grantor's, and only the accounts the
+// delegation lists are visible; any other account is reported as not found.
+
+// ListDelegatedAccounts returns the delegated accounts with their balances
+func (h *LedgerHandler) ListDelegatedAccounts(c *gin.Context) {
+	accounts, err := h.svc(c).ListAccountsByUser(middleware.GetUserID(c))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+
+	items := make([]gin.H, 0, len(accounts))
+	for _, acc := range accounts {
+		if !middleware.DelegationAllowsAccount(c, acc.ID.String()) {
+			continue
+		}
+		items = append(items, gin.H{
+			"account_id":        acc.ID,
+			"name":              acc.Name,
+			"currency":          acc.CurrencyCode,
+			"status":            acc.Status,
+			"booked_balance":    acc.CachedBalance,
+			"available_balance": acc.AvailableBalance(),
+		})
+	}
+	h.auditDelegatedRead(c, "delegated_accounts_list", "")
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetDelegatedBalance returns the balance of a delegated account
+func (h *LedgerHandler) GetDelegatedBalance(c *gin.Context) {
+	if !middleware.DelegationAllowsAccount(c, c.Param("id")) {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(service.ErrAccountNotFound.Error()))
+		return
+	}
+	acc, err := h.svc(c).GetAccountBalance(middleware.GetUserID(c), "", c.Param("id"))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+		return
+	}
+
+	h.auditDelegatedRead(c, "delegated_balance_view", acc.ID.String())
+	c.JSON(http.StatusOK, gin.H{
+		"account_id":        acc.ID,
+		"currency":          acc.CurrencyCode,
+		"booked_balance":    acc.CachedBalance,
+		"available_balance": acc.AvailableBalance(),
+	})
+}
+
+// GetDelegatedStatement returns the statement of a delegated account for
+// ?from= and ?to= (YYYY-MM-DD), defaulting to the current month
+func (h *LedgerHandler) GetDelegatedStatement(c *gin.Context) {
+	if !middleware.DelegationAllowsAccount(c, c.Param("id")) {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(service.ErrAccountNotFound.Error()))
+		return
+	}
+
+	now := time.Now().UTC()
+	from := c.DefaultQuery("from", now.Format("2006-01")+"-01")
+	to := c.DefaultQuery("to", now.Format("2006-01-02"))
+	statement, err := h.svc(c).Statement(middleware.GetUserID(c), "", c.Param("id"), from, to)
+	if err != nil {
+		respondStatementError(c, err)
+		return
+	}
+	h.auditDelegatedRead(c, "delegated_statement_view", c.Param("id"))
+	c.JSON(http.StatusOK, statement)
+}
+
+// auditDelegatedRead records that a delegate read the grantor's data, so the
+// grantor can see what was done on their behalf
+func (h *LedgerHandler) auditDelegatedRead(c *gin.Context, operation, accountID string) {
+	details := map[string]interface{}{
+		"operation":     operation,
+		"delegation_id": middleware.GetDelegationID(c),
+		"delegate_id":   middleware.GetDelegateID(c),
+	}
+	if accountID != "" {
+		details["account_id"] = accountID
+	}
+	h.Audit.LogEvent(middleware.AuditEventDelegatedAction, middleware.AuditSeverityInfo, c, details)
+}
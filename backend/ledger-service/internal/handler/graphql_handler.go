@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/graphql"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// maxGraphQLRequestBytes bounds the size of a GraphQL request body
+const maxGraphQLRequestBytes = 64 << 10
+
+// ExecuteGraphQL runs a query over the caller's accounts and transactions. Queries
+// that cannot run (parse, validation and complexity errors) are answered with
+// 400; others with 200, with errors for any fields that failed.
+func (h *LedgerHandler) ExecuteGraphQL(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxGraphQLRequestBytes)
+	var req graphql.Request
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("query is required"))
+		return
+	}
+
+	resp := h.GraphQL.Execute(graphql.Caller{
+		UserID:  userID,
+		OrgID:   middleware.GetOrgID(c),
+		OrgRole: middleware.GetOrgRole(c),
+	}, req)
+	if resp.Rejected() {
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
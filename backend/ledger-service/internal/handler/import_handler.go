@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileBytes bounds an uploaded import file
+const maxImportFileBytes = 10 << 20
+
+// RegisterImportRoutes mounts the transaction import endpoints on a group that
+// is already authenticated and restricted to administrators
+func (h *LedgerHandler) RegisterImportRoutes(rg *gin.RouterGroup) {
+	rg.POST("/transaction-imports", h.ImportTransactions)
+	rg.GET("/transaction-imports", h.ListTransactionImports)
+	rg.GET("/transaction-imports/:id", h.GetTransactionImport)
+}
+
+// ImportTransactions takes a multipart upload of a CSV or OFX file of
+// historical transactions. With dry_run=true the file is only validated and
+// the report returned; otherwise a fully valid file is queued for import and
+// its progress can be followed at GET /transaction-imports/{id}.
+func (h *LedgerHandler) ImportTransactions(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileBytes+1<<20)
+	header, err := c.FormFile("file")
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("file is required: "+err.Error()))
+		return
+	}
+	if header.Size > maxImportFileBytes {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("file must be at most 10 MB"))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.PostForm("dry_run"))
+
+	req := service.ImportRequest{
+		Reference:         c.PostForm("reference"),
+		RequestedBy:       userID,
+		Format:            c.PostForm("format"),
+		FileName:          header.Filename,
+		Data:              data,
+		AccountID:         c.PostForm("account_id"),
+		SuspenseAccountID: c.PostForm("suspense_account_id"),
+	}
+
+	if dryRun {
+		report, err := h.svc(c).ValidateImport(req)
+		if err != nil {
+			respondImportError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, report)
+		return
+	}
+
+	imp, report, replayed, err := h.svc(c).StartImport(c.Request.Context(), req)
+	switch {
+	case errors.Is(err, service.ErrImportRowsInvalid):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()).WithDetails(report))
+		return
+	case err != nil:
+		respondImportError(c, err)
+		return
+	}
+
+	if replayed {
+		c.Header("X-Idempotent-Replayed", "true")
+		c.JSON(http.StatusOK, gin.H{"import": imp})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"import": imp, "report": report})
+}
+
+// ListTransactionImports returns recent imports with their progress
+func (h *LedgerHandler) ListTransactionImports(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("limit must be between 1 and 200"))
+		return
+	}
+	imports, err := h.svc(c).ListImports(limit)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": imports})
+}
+
+// GetTransactionImport returns an import's progress and the rows that failed
+func (h *LedgerHandler) GetTransactionImport(c *gin.Context) {
+	imp, err := h.svc(c).GetImport(c.Param("id"))
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, imp)
+}
+
+// respondImportError maps transaction import errors to API errors
+func respondImportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidImportFile), errors.Is(err, service.ErrImportSuspense),
+		errors.Is(err, service.ErrImportReferenceRequired), errors.Is(err, service.ErrImportTooManyRows):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrImportNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrImportsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("IMPORTS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
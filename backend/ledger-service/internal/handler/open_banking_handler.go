@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Open banking endpoints serve third-party clients holding a consent token.
+// The user ID is the consenting user's, and only the accounts the consent
+// lists are visible; any other account is reported as not found.
+
+// ListConsentedAccounts returns the identifiers of the consented accounts
+func (h *LedgerHandler) ListConsentedAccounts(c *gin.Context) {
+	accounts, err := h.svc(c).ListAccountsByUser(middleware.GetUserID(c))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+
+	items := make([]gin.H, 0, len(accounts))
+	for _, acc := range accounts {
+		if !middleware.ConsentAllowsAccount(c, acc.ID.String()) {
+			continue
+		}
+		items = append(items, gin.H{
+			"account_id":          acc.ID,
+			"name":                acc.Name,
+			"currency":            acc.CurrencyCode,
+			"status":              acc.Status,
+			"iban":                acc.IBAN,
+			"sort_code":           acc.SortCode,
+			"bank_account_number": acc.BankAccountNumber,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// GetConsentedBalance returns the balance of a consented account
+func (h *LedgerHandler) GetConsentedBalance(c *gin.Context) {
+	if !middleware.ConsentAllowsAccount(c, c.Param("id")) {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(service.ErrAccountNotFound.Error()))
+		return
+	}
+	acc, err := h.svc(c).GetAccountBalance(middleware.GetUserID(c), "", c.Param("id"))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"account_id":        acc.ID,
+		"currency":          acc.CurrencyCode,
+		"booked_balance":    acc.CachedBalance,
+		"available_balance": acc.AvailableBalance(),
+	})
+}
+
+// GetConsentedTransactions returns the statement of a consented account for
+// ?from= and ?to= (YYYY-MM-DD), defaulting to the current month
+func (h *LedgerHandler) GetConsentedTransactions(c *gin.Context) {
+	if !middleware.ConsentAllowsAccount(c, c.Param("id")) {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(service.ErrAccountNotFound.Error()))
+		return
+	}
+
+	now := time.Now().UTC()
+	from := c.DefaultQuery("from", now.Format("2006-01")+"-01")
+	to := c.DefaultQuery("to", now.Format("2006-01-02"))
+	statement, err := h.svc(c).Statement(middleware.GetUserID(c), "", c.Param("id"), from, to)
+	if err != nil {
+		respondStatementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, statement)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// RegisterOverdraftRoutes mounts the overdraft facility endpoints on a group
+// that is already authenticated and restricted to administrators
+func (h *LedgerHandler) RegisterOverdraftRoutes(rg *gin.RouterGroup) {
+	rg.PUT("/accounts/:id/overdraft", h.SetAccountOverdraft)
+	rg.DELETE("/accounts/:id/overdraft", h.RemoveAccountOverdraft)
+}
+
+type SetOverdraftRequest struct {
+	Limit string `json:"limit" binding:"required"`
+	// InterestRate is yearly, e.g. "0.1995"; no interest when omitted
+	InterestRate string `json:"interest_rate"`
+}
+
+// SetAccountOverdraft gives an account an overdraft facility or changes it
+func (h *LedgerHandler) SetAccountOverdraft(c *gin.Context) {
+	var req SetOverdraftRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	limit, err := decimal.NewFromString(req.Limit)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("limit must be a decimal amount"))
+		return
+	}
+	rate := decimal.Zero
+	if req.InterestRate != "" {
+		if rate, err = decimal.NewFromString(req.InterestRate); err != nil {
+			apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("interest_rate must be a decimal rate"))
+			return
+		}
+	}
+
+	account, err := h.svc(c).SetOverdraft(middleware.GetUserID(c), c.Param("id"), limit, rate)
+	if err != nil {
+		respondOverdraftAdminError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAccountUpdate, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"account_id":      account.ID.String(),
+		"overdraft_limit": limit.String(),
+		"interest_rate":   rate.String(),
+	})
+	c.JSON(http.StatusOK, account)
+}
+
+// RemoveAccountOverdraft withdraws an account's overdraft facility
+func (h *LedgerHandler) RemoveAccountOverdraft(c *gin.Context) {
+	account, err := h.svc(c).RemoveOverdraft(middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		respondOverdraftAdminError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAccountUpdate, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"account_id":      account.ID.String(),
+		"overdraft_limit": nil,
+	})
+	c.JSON(http.StatusOK, account)
+}
+
+// respondOverdraftError responds to a posting that would exceed an account's
+// overdraft limit
+func respondOverdraftError(c *gin.Context, err *model.OverdraftError) {
+	apperrors.RespondWithError(c, apperrors.ErrInsufficientFunds.WithDetails(gin.H{
+		"account_id":        err.AccountID,
+		"overdraft_limit":   err.Limit,
+		"available_balance": err.Available,
+	}))
+}
+
+func respondOverdraftAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidOverdraft):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccountNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrOverdraftsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("OVERDRAFTS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterParkedPostingRoutes mounts the ops endpoints for payments that
+// failed to post on a group that is already authenticated and restricted to
+// administrators
+func (h *LedgerHandler) RegisterParkedPostingRoutes(rg *gin.RouterGroup) {
+	rg.GET("/parked-postings", h.ListParkedPostings)
+	rg.GET("/parked-postings/:id", h.GetParkedPosting)
+	rg.POST("/parked-postings/:id/retry", h.RetryParkedPosting)
+	rg.POST("/parked-postings/:id/repair", h.RepairParkedPosting)
+}
+
+// ListParkedPostings returns the newest parked postings, optionally filtered
+// by ?status= and ?reason=
+func (h *LedgerHandler) ListParkedPostings(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 200 {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("limit must be between 1 and 200"))
+		return
+	}
+	postings, err := h.svc(c).ListParkedPostings(model.ParkedPostingStatus(c.Query("status")), model.ParkReason(c.Query("reason")), limit)
+	if err != nil {
+		respondParkedPostingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": postings})
+}
+
+// GetParkedPosting returns a parked posting with the payment it holds
+func (h *LedgerHandler) GetParkedPosting(c *gin.Context) {
+	posting, err := h.svc(c).GetParkedPosting(c.Param("id"))
+	if err != nil {
+		respondParkedPostingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, posting)
+}
+
+// RetryParkedPosting re-posts a parked payment now, typically after the cause
+// was fixed, such as an account unfrozen. The posting's status tells whether
+// it posted; if not, its last error says why.
+func (h *LedgerHandler) RetryParkedPosting(c *gin.Context) {
+	posting, err := h.svc(c).RetryParkedPosting(c.Param("id"))
+	if err != nil {
+		respondParkedPostingError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"operation":         "parked_posting_retry",
+		"parked_posting_id": posting.ID.String(),
+		"payment_id":        posting.PaymentID.String(),
+		"status":            posting.Status,
+	})
+	c.JSON(http.StatusOK, posting)
+}
+
+type RepairParkedPostingRequest struct {
+	FromAccountID string `json:"from_account_id" binding:"omitempty,uuid"`
+	ToAccountID   string `json:"to_account_id" binding:"omitempty,uuid"`
+	Note          string `json:"note" binding:"required,max=2000"`
+}
+
+// RepairParkedPosting corrects the accounts of a parked payment and retries it
+func (h *LedgerHandler) RepairParkedPosting(c *gin.Context) {
+	var req RepairParkedPostingRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	posting, err := h.svc(c).RepairParkedPosting(middleware.GetUserID(c), c.Param("id"), service.RepairRequest{
+		FromAccountID: req.FromAccountID,
+		ToAccountID:   req.ToAccountID,
+		Note:          req.Note,
+	})
+	if err != nil {
+		respondParkedPostingError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":         "parked_posting_repair",
+		"parked_posting_id": posting.ID.String(),
+		"payment_id":        posting.PaymentID.String(),
+		"from_account_id":   posting.Event.FromAccountID,
+		"to_account_id":     posting.Event.ToAccountID,
+		"status":            posting.Status,
+	})
+	c.JSON(http.StatusOK, posting)
+}
+
+// respondParkedPostingError maps parked posting errors to API errors
+func respondParkedPostingError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRepair):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrParkedPostingNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrParkedPostingState):
+		apperrors.RespondWithError(c, apperrors.NewError("PARKED_POSTING_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrParkedPostingsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("PARKED_POSTINGS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
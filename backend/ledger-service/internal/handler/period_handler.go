@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPeriodRoutes mounts the accounting period endpoints on a group that
+// is already authenticated and restricted to administrators
+func (h *LedgerHandler) RegisterPeriodRoutes(rg *gin.RouterGroup) {
+	rg.GET("/accounting-periods", h.ListAccountingPeriods)
+	rg.POST("/accounting-periods/:period/close", h.CloseAccountingPeriod)
+	rg.GET("/accounting-periods/:period/report", h.GetPeriodReport)
+}
+
+// CloseAccountingPeriod closes a month of the books and returns its period-end report
+func (h *LedgerHandler) CloseAccountingPeriod(c *gin.Context) {
+	report, err := h.svc(c).ClosePeriod(middleware.GetUserID(c), c.Param("period"))
+	if err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"action":   "accounting_period_close",
+		"period":   report.Period.Period,
+		"accounts": len(report.Accounts),
+	})
+	c.JSON(http.StatusOK, report)
+}
+
+// ListAccountingPeriods returns the closed periods, newest first
+func (h *LedgerHandler) ListAccountingPeriods(c *gin.Context) {
+	periods, err := h.svc(c).ListPeriods()
+	if err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": periods})
+}
+
+// GetPeriodReport returns the report generated when a period was closed
+func (h *LedgerHandler) GetPeriodReport(c *gin.Context) {
+	report, err := h.svc(c).GetPeriodReport(c.Param("period"))
+	if err != nil {
+		respondPeriodError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func respondPeriodError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPeriod):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPeriodNotClosed):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPeriodNotEnded),
+		errors.Is(err, repository.ErrPeriodAlreadyClosed),
+		errors.Is(err, repository.ErrPeriodNotNext):
+		apperrors.RespondWithError(c, apperrors.NewError("PERIOD_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrPeriodsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("PERIODS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
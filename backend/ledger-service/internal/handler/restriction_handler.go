@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRestrictionRoutes mounts the account freeze and legal hold endpoints
+// on a group that is already authenticated and restricted to administrators
+func (h *LedgerHandler) RegisterRestrictionRoutes(rg *gin.RouterGroup) {
+	rg.GET("/accounts/:id/restrictions", h.ListAccountRestrictions)
+	rg.POST("/accounts/:id/restrictions", h.PlaceAccountRestriction)
+	rg.POST("/accounts/:id/restrictions/:restrictionId/lift", h.LiftAccountRestriction)
+}
+
+type PlaceRestrictionRequest struct {
+	Type   string `json:"type" binding:"required,oneof=DEBIT_FREEZE FULL_FREEZE LEGAL_HOLD"`
+	Reason string `json:"reason" binding:"required,max=500"`
+	Note   string `json:"note" binding:"max=2000"`
+}
+
+// PlaceAccountRestriction freezes debits, freezes all activity, or places a
+// legal hold on an account
+func (h *LedgerHandler) PlaceAccountRestriction(c *gin.Context) {
+	var req PlaceRestrictionRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	restriction, err := h.svc(c).PlaceRestriction(middleware.GetUserID(c), c.Param("id"), model.RestrictionType(req.Type), req.Reason, req.Note)
+	if err != nil {
+		respondRestrictionAdminError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAccountRestrict, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"account_id":     restriction.AccountID.String(),
+		"restriction_id": restriction.ID.String(),
+		"type":           restriction.Type,
+		"reason":         restriction.Reason,
+	})
+	c.JSON(http.StatusCreated, restriction)
+}
+
+type LiftRestrictionRequest struct {
+	Note string `json:"note" binding:"required,max=2000"`
+}
+
+// LiftAccountRestriction ends a restriction; it stays in the account's history
+func (h *LedgerHandler) LiftAccountRestriction(c *gin.Context) {
+	var req LiftRestrictionRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	restriction, err := h.svc(c).LiftRestriction(middleware.GetUserID(c), c.Param("id"), c.Param("restrictionId"), req.Note)
+	if err != nil {
+		respondRestrictionAdminError(c, err)
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventAccountUnrestrict, middleware.AuditSeverityInfo, c, map[string]interface{}{
+		"account_id":     restriction.AccountID.String(),
+		"restriction_id": restriction.ID.String(),
+		"type":           restriction.Type,
+	})
+	c.JSON(http.StatusOK, restriction)
+}
+
+// ListAccountRestrictions returns the account's restrictions, active and lifted, newest first
+func (h *LedgerHandler) ListAccountRestrictions(c *gin.Context) {
+	restrictions, err := h.svc(c).ListRestrictions(c.Param("id"))
+	if err != nil {
+		respondRestrictionAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": restrictions})
+}
+
+// respondRestrictionError responds to a posting refused by a restriction with
+// the restriction's error code and the reason its owner may see
+func respondRestrictionError(c *gin.Context, err *model.RestrictionError) {
+	var appErr *apperrors.AppError
+	switch err.Type {
+	case model.RestrictionFullFreeze:
+		appErr = apperrors.ErrAccountFrozen
+	case model.RestrictionLegalHold:
+		appErr = apperrors.ErrAccountLegalHold
+	default:
+		appErr = apperrors.ErrAccountDebitsFrozen
+	}
+	apperrors.RespondWithError(c, appErr.WithDetails(gin.H{"account_id": err.AccountID, "reason": err.Notice.Reason}))
+}
+
+func respondRestrictionAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidRestriction):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("type must be DEBIT_FREEZE, FULL_FREEZE or LEGAL_HOLD and reason at most 500 characters"))
+	case errors.Is(err, service.ErrAccountNotFound), errors.Is(err, service.ErrRestrictionNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, repository.ErrRestrictionExists), errors.Is(err, repository.ErrRestrictionNotActive):
+		apperrors.RespondWithError(c, apperrors.NewError("RESTRICTION_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrRestrictionsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("RESTRICTIONS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamHeartbeatInterval is how often an idle stream sends a comment line, so
+// proxies and clients can tell a quiet stream from a dead one
+var StreamHeartbeatInterval = 15 * time.Second
+
+// StreamAccount pushes balance and transaction updates for an account as
+// Server-Sent Events. The first "balance" event is the current balance; each
+// "transaction" event carries the entry and the balances after it. The stream
+// ends with a "close" event when the token expires or the client falls behind,
+// after which the client should reconnect.
+func (h *LedgerHandler) StreamAccount(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	acc, sub, err := h.svc(c).SubscribeBalance(userID, middleware.GetOrgID(c), c.Param("id"))
+	if err != nil {
+		respondStreamError(c, err)
+		return
+	}
+	defer sub.Close()
+
+	// The stream is only as long-lived as the token that opened it
+	var expired <-chan time.Time
+	if claims := middleware.GetClaims(c); claims != nil && claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	heartbeat := time.NewTicker(StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stop nginx buffering the stream
+	c.Status(http.StatusOK)
+
+	c.SSEvent("balance", gin.H{
+		"account_id":        acc.ID,
+		"balance":           acc.CachedBalance,
+		"held_balance":      acc.HeldBalance,
+		"available_balance": acc.AvailableBalance(),
+		"currency":          acc.CurrencyCode,
+	})
+	c.Writer.Flush()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expired:
+			c.SSEvent("close", gin.H{"reason": "token_expired"})
+			c.Writer.Flush()
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case update, ok := <-sub.Updates:
+			if !ok {
+				if sub.Dropped() {
+					c.SSEvent("close", gin.H{"reason": "slow_consumer"})
+					c.Writer.Flush()
+				}
+				return
+			}
+			c.SSEvent("transaction", update)
+			c.Writer.Flush()
+		}
+	}
+}
+
+// respondStreamError maps balance stream errors to API errors
+func respondStreamError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrStreamingDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("STREAMING_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrTooManyStreams):
+		apperrors.RespondWithError(c, apperrors.ErrRateLimited.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccountNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
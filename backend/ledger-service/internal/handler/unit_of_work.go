@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UnitOfWork returns middleware that runs each POST, PUT, PATCH and DELETE
+// request in one database transaction. Handlers reach the service through
+// svc, whose repositories join the transaction. It commits when the handler
+// answers below 400 without errors on the context, and rolls back when the
+// handler fails or panics. The response is held until then, so a client is
+// never told a write succeeded that did not commit: a failed commit answers
+// 500 instead. With a nil database it does nothing.
+func UnitOfWork(database *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if database == nil || !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		uow := repository.NewUnitOfWork(c.Request.Context(), database)
+		c.Request = c.Request.WithContext(repository.ContextWithUnitOfWork(c.Request.Context(), uow))
+		w := &unitOfWorkWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		finished := false
+		defer func() {
+			if finished {
+				return
+			}
+			// The handler panicked; the recovery middleware answers
+			c.Writer = w.ResponseWriter
+			if err := uow.Rollback(); err != nil {
+				slog.Error("Failed to roll back request transaction", "path", c.Request.URL.Path, "error", err)
+			}
+		}()
+
+		c.Next()
+		finished = true
+		c.Writer = w.ResponseWriter
+
+		if w.Status() >= http.StatusBadRequest || len(c.Errors) > 0 {
+			if err := uow.Rollback(); err != nil {
+				slog.Error("Failed to roll back request transaction", "path", c.Request.URL.Path, "error", err)
+			}
+			w.flush()
+			return
+		}
+		if err := uow.Commit(); err != nil {
+			slog.Error("Failed to commit request transaction", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+			apperrors.RespondWithError(c, apperrors.ErrInternal)
+			return
+		}
+		w.flush()
+	}
+}
+
+// svc returns the service for the request, bound to its unit of work if it
+// has one
+func (h *LedgerHandler) svc(c *gin.Context) *service.LedgerService {
+	if uow := repository.UnitOfWorkFromContext(c.Request.Context()); uow != nil {
+		return h.Service.WithUnitOfWork(uow)
+	}
+	return h.Service
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// unitOfWorkWriter holds the response until the request's transaction is
+// committed or rolled back. Headers go straight to the underlying writer.
+type unitOfWorkWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *unitOfWorkWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *unitOfWorkWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *unitOfWorkWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *unitOfWorkWriter) WriteString(s string) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.WriteString(s)
+}
+
+func (w *unitOfWorkWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *unitOfWorkWriter) Size() int {
+	if w.status == 0 {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *unitOfWorkWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush is a no-op: nothing is sent before the transaction ends
+func (w *unitOfWorkWriter) Flush() {}
+
+// flush sends the held response
+func (w *unitOfWorkWriter) flush() {
+	if w.status == 0 {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+}
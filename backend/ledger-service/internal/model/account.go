@@ -19,8 +19,10 @@ const (
 )
 
 type Account struct {
-	ID             uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID         uuid.UUID       `gorm:"type:uuid;index;not null" json:"user_id"`
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;index;not null" json:"user_id"`
+	// OrgID is set for business accounts, which all members of the organization can see
+	OrgID          *uuid.UUID      `gorm:"type:uuid;index" json:"org_id,omitempty"`
 	AccountNumber  string          `gorm:"uniqueIndex;not null;type:varchar(20)" json:"account_number"`
 	Name           string          `gorm:"type:varchar(100)" json:"name"`
 	Type           AccountType     `gorm:"type:varchar(20);not null" json:"type"`
@@ -28,8 +30,26 @@ type Account struct {
 	Status         string          `gorm:"type:varchar(20);default:'ACTIVE'" json:"status"`
 	BalanceVersion int             `gorm:"default:0" json:"-"`
 	CachedBalance  decimal.Decimal `gorm:"type:numeric(19,4);default:0" json:"balance"`
+	HeldBalance    decimal.Decimal `gorm:"type:numeric(19,4);default:0" json:"held_balance"` // Outgoing amounts of pending entries
 	Metadata       *string         `gorm:"type:jsonb" json:"metadata,omitempty"`
-	CreatedAt      time.Time       `json:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt  `gorm:"index" json:"-"`
+	// OverdraftLimit is how far below zero the available balance may go. Nil
+	// means the account has no overdraft facility, and the ledger leaves its
+	// balance to the caller as before.
+	OverdraftLimit *decimal.Decimal `gorm:"type:numeric(19,4)" json:"overdraft_limit,omitempty"`
+	// OverdraftInterestRate is the yearly rate charged on an overdrawn balance, e.g. 0.1995
+	OverdraftInterestRate decimal.Decimal `gorm:"type:numeric(7,6);not null;default:0" json:"overdraft_interest_rate"`
+	// External identifiers payers at other banks use; assigned once on creation
+	IBAN              *string        `gorm:"type:varchar(34);uniqueIndex" json:"iban,omitempty"`
+	SortCode          *string        `gorm:"type:char(6);uniqueIndex:idx_accounts_sort_code_number" json:"sort_code,omitempty"`
+	BankAccountNumber *string        `gorm:"type:char(8);uniqueIndex:idx_accounts_sort_code_number" json:"bank_account_number,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	// Active freezes and legal holds, filled in by the account API
+	Restrictions []RestrictionNotice `gorm:"-" json:"restrictions,omitempty"`
+}
+
+// AvailableBalance is the booked balance less amounts held by pending entries
+func (a *Account) AvailableBalance() decimal.Decimal {
+	return a.CachedBalance.Sub(a.HeldBalance)
 }
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type RestrictionType string
+
+const (
+	// RestrictionDebitFreeze blocks money leaving the account; credits are still accepted
+	RestrictionDebitFreeze RestrictionType = "DEBIT_FREEZE"
+	// RestrictionFullFreeze blocks all postings to the account
+	RestrictionFullFreeze RestrictionType = "FULL_FREEZE"
+	// RestrictionLegalHold blocks debits under a court order or similar. Its
+	// reason is never shown to the customer.
+	RestrictionLegalHold RestrictionType = "LEGAL_HOLD"
+)
+
+// legalHoldNotice is what customers see instead of a legal hold's reason
+const legalHoldNotice = "This account is subject to a legal hold. Please contact support."
+
+// AccountRestriction is a freeze or legal hold placed on an account by an
+// admin. It applies until it is lifted; lifted restrictions are kept as the
+// audit trail of who restricted the account, when and why.
+type AccountRestriction struct {
+	ID        uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AccountID uuid.UUID       `gorm:"type:uuid;not null;index" json:"account_id"`
+	Type      RestrictionType `gorm:"type:varchar(20);not null" json:"type"`
+	// Reason is shown to the customer, except for legal holds
+	Reason string `gorm:"type:varchar(500);not null" json:"reason"`
+	// Note is for staff only
+	Note      string     `gorm:"type:text" json:"note,omitempty"`
+	PlacedBy  uuid.UUID  `gorm:"type:uuid;not null" json:"placed_by"`
+	LiftedBy  *uuid.UUID `gorm:"type:uuid" json:"lifted_by,omitempty"`
+	LiftedAt  *time.Time `json:"lifted_at,omitempty"`
+	LiftNote  string     `gorm:"type:text" json:"lift_note,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the restriction has not been lifted
+func (r *AccountRestriction) Active() bool {
+	return r.LiftedAt == nil
+}
+
+// Blocks reports whether the restriction refuses a posting that takes money
+// out of the account (direction -1) or pays money into it
+func (r *AccountRestriction) Blocks(direction int) bool {
+	if !r.Active() {
+		return false
+	}
+	return r.Type == RestrictionFullFreeze || direction == -1
+}
+
+// Notice is what the account's owner is shown about the restriction
+func (r *AccountRestriction) Notice() RestrictionNotice {
+	notice := RestrictionNotice{Type: r.Type, Code: RestrictionErrorCode(r.Type), Reason: r.Reason, Since: r.CreatedAt}
+	if r.Type == RestrictionLegalHold {
+		notice.Reason = legalHoldNotice
+	}
+	return notice
+}
+
+// RestrictionNotice is an active restriction as shown on the account API, so
+// apps can explain why payments are refused
+type RestrictionNotice struct {
+	Type   RestrictionType `json:"type"`
+	Code   string          `json:"code"`
+	Reason string          `json:"reason"`
+	Since  time.Time       `json:"since"`
+}
+
+// RestrictionErrorCode is the API error code of postings refused by a restriction
+func RestrictionErrorCode(t RestrictionType) string {
+	switch t {
+	case RestrictionFullFreeze:
+		return "ACCOUNT_FROZEN"
+	case RestrictionLegalHold:
+		return "ACCOUNT_LEGAL_HOLD"
+	default:
+		return "ACCOUNT_DEBITS_FROZEN"
+	}
+}
+
+// RestrictionError is returned when a posting is refused by a restriction on its account
+type RestrictionError struct {
+	AccountID uuid.UUID
+	Type      RestrictionType
+	Notice    RestrictionNotice
+}
+
+func (e *RestrictionError) Error() string {
+	switch e.Type {
+	case RestrictionFullFreeze:
+		return fmt.Sprintf("account %s is frozen", e.AccountID)
+	case RestrictionLegalHold:
+		return fmt.Sprintf("account %s is subject to a legal hold", e.AccountID)
+	default:
+		return fmt.Sprintf("debits from account %s are frozen", e.AccountID)
+	}
+}
+
+// CheckRestrictions returns a *RestrictionError if an active restriction on
+// the account refuses any of its postings. A full freeze is reported ahead of
+// a legal hold, and a legal hold ahead of a debit freeze.
+func CheckRestrictions(accountID uuid.UUID, restrictions []AccountRestriction, postings []Posting) error {
+	var blocking *AccountRestriction
+	for i := range restrictions {
+		r := &restrictions[i]
+		if r.AccountID != accountID || restrictionPrecedence(r.Type) <= restrictionPrecedence(blockingType(blocking)) {
+			continue
+		}
+		for _, p := range postings {
+			if p.AccountID == accountID && r.Blocks(p.Direction) {
+				blocking = r
+				break
+			}
+		}
+	}
+	if blocking == nil {
+		return nil
+	}
+	return &RestrictionError{AccountID: accountID, Type: blocking.Type, Notice: blocking.Notice()}
+}
+
+func blockingType(r *AccountRestriction) RestrictionType {
+	if r == nil {
+		return ""
+	}
+	return r.Type
+}
+
+func restrictionPrecedence(t RestrictionType) int {
+	switch t {
+	case RestrictionFullFreeze:
+		return 3
+	case RestrictionLegalHold:
+		return 2
+	case RestrictionDebitFreeze:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PeriodLayout is the format of an accounting period's name, its month in UTC
+const PeriodLayout = "2006-01"
+
+// ErrPeriodClosed is returned for an entry dated within a closed accounting
+// period; corrections go in the open period as adjustments referencing it
+var ErrPeriodClosed = errors.New("accounting period is closed")
+
+// AccountingPeriod is a closed calendar month of the books. Periods are closed
+// in order, and entries dated in a closed period are refused, so its figures
+// never change after its summaries are generated. Open periods have no row.
+type AccountingPeriod struct {
+	Period   string    `gorm:"type:char(7);primaryKey" json:"period"` // e.g. 2026-03
+	StartsAt time.Time `gorm:"not null" json:"starts_at"`
+	EndsAt   time.Time `gorm:"not null;uniqueIndex" json:"ends_at"`
+	ClosedBy uuid.UUID `gorm:"type:uuid;not null" json:"closed_by"`
+	ClosedAt time.Time `gorm:"not null" json:"closed_at"`
+}
+
+// AccountingPeriodSummary is an account's activity over a closed period,
+// generated when the period is closed
+type AccountingPeriodSummary struct {
+	Period         string          `gorm:"type:char(7);primaryKey" json:"period"`
+	AccountID      uuid.UUID       `gorm:"type:uuid;primaryKey" json:"account_id"`
+	CurrencyCode   string          `gorm:"type:char(3);not null" json:"currency_code"`
+	OpeningBalance decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"opening_balance"`
+	Debits         decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"debits"`
+	Credits        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"credits"`
+	ClosingBalance decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"closing_balance"`
+	Postings       int64           `gorm:"not null" json:"postings"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// PeriodBounds returns the start and end of a period named like "2026-03"
+func PeriodBounds(period string) (time.Time, time.Time, error) {
+	start, err := time.Parse(PeriodLayout, period)
+	if err != nil || start.Format(PeriodLayout) != period {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid accounting period %q, want YYYY-MM", period)
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
+
+// PeriodOf names the period t falls in
+func PeriodOf(t time.Time) string {
+	return t.UTC().Format(PeriodLayout)
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Accrual kinds
+const (
+	AccrualInterest = "INTEREST"
+	AccrualFee      = "FEE"
+)
+
+// AccrualPlaces is the precision accruals are kept at, below that of any
+// currency, so amounts too small to post on their own still add up
+const AccrualPlaces = 8
+
+// Accrual is a micro-amount of interest or a fee kept in the accrual
+// sub-ledger instead of the journal. An account's accruals are rolled up
+// into one journal entry a month; RollupEntryID is that entry, and
+// RolledUpAt is set even when the month netted to nothing to post.
+type Accrual struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null;index:idx_accruals_account_date" json:"account_id"`
+	// CounterpartyAccountID is the bank's account the amount is paid from or to,
+	// e.g. interest expense or fee income
+	CounterpartyAccountID uuid.UUID `gorm:"type:uuid;not null" json:"counterparty_account_id"`
+	Kind                  string    `gorm:"type:varchar(20);not null" json:"kind"`
+	// Direction is 1 when the amount is paid to the account, e.g. interest
+	// earned, and -1 when it is charged to it, e.g. a fee
+	Direction     int             `gorm:"type:smallint;not null;check:direction IN (1, -1)" json:"direction"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,8);not null;check:amount > 0" json:"amount"`
+	AccrualDate   time.Time       `gorm:"type:date;not null;index:idx_accruals_account_date" json:"accrual_date"`
+	Description   string          `gorm:"type:text" json:"description,omitempty"`
+	Reference     string          `gorm:"type:varchar(100);not null;uniqueIndex" json:"reference"` // Idempotency key of the accrual
+	RollupEntryID *uuid.UUID      `gorm:"type:uuid;index" json:"rollup_entry_id,omitempty"`
+	RolledUpAt    *time.Time      `json:"rolled_up_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (Accrual) TableName() string {
+	return "accruals"
+}
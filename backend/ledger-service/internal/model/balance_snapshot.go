@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// BalanceSnapshot is an account's booked balance at the end of a UTC day. The
+// balance at any other time is the latest earlier snapshot plus the postings
+// booked since its ClosingAt, so reads do not scan the account's full history.
+type BalanceSnapshot struct {
+	AccountID uuid.UUID       `gorm:"type:uuid;primaryKey" json:"account_id"`
+	Date      time.Time       `gorm:"type:date;primaryKey" json:"date"`
+	ClosingAt time.Time       `gorm:"not null" json:"closing_at"` // Midnight ending Date; postings before it are included
+	Balance   decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"balance"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// StatementLine is a booked posting on an account statement. Amount is signed
+// (negative for money out) and Balance is the running balance after it.
+type StatementLine struct {
+	PostingID      uuid.UUID       `json:"posting_id"`
+	JournalEntryID uuid.UUID       `json:"journal_entry_id"`
+	BookedAt       time.Time       `json:"booked_at"`
+	Description    string          `json:"description"`
+	Amount         decimal.Decimal `json:"amount"`
+	Balance        decimal.Decimal `gorm:"-" json:"balance"`
+}
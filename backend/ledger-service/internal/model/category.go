@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Category is the spending category of one side of a transaction
+type Category string
+
+const (
+	CategoryGroceries Category = "GROCERIES"
+	CategoryDining    Category = "DINING"
+	CategoryTransport Category = "TRANSPORT"
+	CategoryBills     Category = "BILLS"
+	CategoryShopping  Category = "SHOPPING"
+	CategorySalary    Category = "SALARY"
+	CategoryTransfers Category = "TRANSFERS"
+	CategoryFees      Category = "FEES"
+	CategoryIncome    Category = "INCOME"
+	CategoryOther     Category = "OTHER"
+)
+
+// Categories lists every category a user may assign
+var Categories = []Category{
+	CategoryGroceries, CategoryDining, CategoryTransport, CategoryBills, CategoryShopping,
+	CategorySalary, CategoryTransfers, CategoryFees, CategoryIncome, CategoryOther,
+}
+
+// IsValid reports whether c is a known category
+func (c Category) IsValid() bool {
+	for _, known := range Categories {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+type CategorySource string
+
+const (
+	CategorySourceRule CategorySource = "RULE" // Assigned by the categorization rules
+	CategorySourceUser CategorySource = "USER" // Chosen by the account owner; never replaced by rules
+)
+
+// PostingCategory tags one posting with a category. Postings are categorized
+// individually because each side of a transfer belongs to a different user.
+type PostingCategory struct {
+	PostingID      uuid.UUID      `gorm:"type:uuid;primary_key" json:"posting_id"`
+	JournalEntryID uuid.UUID      `gorm:"type:uuid;not null;index" json:"transaction_id"`
+	AccountID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"account_id"`
+	Category       Category       `gorm:"type:varchar(20);not null" json:"category"`
+	Source         CategorySource `gorm:"type:varchar(10);not null" json:"source"`
+	Merchant       string         `gorm:"type:varchar(100)" json:"merchant,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// CategorySpend is the outgoing total for one category and currency
+type CategorySpend struct {
+	Category Category        `json:"category"`
+	Currency string          `json:"currency"`
+	Total    decimal.Decimal `json:"total"`
+	Count    int64           `json:"count"`
+}
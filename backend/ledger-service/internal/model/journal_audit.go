@@ -0,0 +1,127 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JournalAuditAction string
+
+const (
+	AuditEntryCreated JournalAuditAction = "ENTRY_CREATED"  // Entry posted, pending or immediately booked
+	AuditStatusChange JournalAuditAction = "STATUS_CHANGED" // Pending entry booked or reversed
+)
+
+// GenesisHash is the PrevHash of the first record in the chain
+var GenesisHash = strings.Repeat("0", 64)
+
+// JournalAuditRecord is one append-only record of a change to a journal entry.
+// Hash covers the record's fields and the previous record's hash, so editing,
+// deleting or reordering any record breaks the chain from that point on.
+type JournalAuditRecord struct {
+	Sequence       int64              `gorm:"primaryKey;autoIncrement" json:"sequence"`
+	JournalEntryID uuid.UUID          `gorm:"type:uuid;not null;index" json:"journal_entry_id"`
+	Action         JournalAuditAction `gorm:"type:varchar(20);not null" json:"action"`
+	Status         JournalEntryStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Payload        string             `gorm:"type:text;not null" json:"payload"` // Canonical JSON snapshot of the entry
+	PrevHash       string             `gorm:"type:char(64);not null" json:"prev_hash"`
+	Hash           string             `gorm:"type:char(64);not null;uniqueIndex" json:"hash"`
+	RecordedAt     time.Time          `gorm:"not null" json:"recorded_at"`
+}
+
+func (JournalAuditRecord) TableName() string {
+	return "journal_audit_log"
+}
+
+// auditSnapshot fixes the fields and their order in a record's payload
+type auditSnapshot struct {
+	ID              uuid.UUID          `json:"id"`
+	TransactionDate string             `json:"transaction_date"`
+	Description     string             `json:"description"`
+	ReferenceID     string             `json:"reference_id"`
+	Status          JournalEntryStatus `json:"status"`
+	ReversesEntryID *uuid.UUID         `json:"reverses_entry_id"`
+	FinalizedAt     *string            `json:"finalized_at"`
+	Postings        []auditPosting     `json:"postings"`
+}
+
+type auditPosting struct {
+	ID        uuid.UUID `json:"id"`
+	AccountID uuid.UUID `json:"account_id"`
+	Amount    string    `json:"amount"`
+	Direction int       `json:"direction"`
+}
+
+// NewJournalAuditRecord builds the record of action on entry that follows the
+// record with hash prevHash
+func NewJournalAuditRecord(entry *JournalEntry, action JournalAuditAction, prevHash string, at time.Time) (*JournalAuditRecord, error) {
+	snapshot := auditSnapshot{
+		ID:              entry.ID,
+		TransactionDate: auditTime(entry.TransactionDate),
+		Description:     entry.Description,
+		ReferenceID:     entry.ReferenceID,
+		Status:          entry.Status,
+		ReversesEntryID: entry.ReversesEntryID,
+		Postings:        make([]auditPosting, len(entry.Postings)),
+	}
+	if entry.FinalizedAt != nil {
+		finalizedAt := auditTime(*entry.FinalizedAt)
+		snapshot.FinalizedAt = &finalizedAt
+	}
+	for i, p := range entry.Postings {
+		snapshot.Postings[i] = auditPosting{ID: p.ID, AccountID: p.AccountID, Amount: p.Amount.String(), Direction: p.Direction}
+	}
+	payload, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &JournalAuditRecord{
+		JournalEntryID: entry.ID,
+		Action:         action,
+		Status:         entry.Status,
+		Payload:        string(payload),
+		PrevHash:       prevHash,
+		// Postgres keeps microseconds; truncate so the stored time hashes the same
+		RecordedAt: at.UTC().Truncate(time.Microsecond),
+	}
+	record.Hash = record.ComputeHash()
+	return record, nil
+}
+
+// ComputeHash is the SHA-256 of the previous hash and the record's fields.
+// Sequence is not included; the chain itself fixes the order.
+func (r *JournalAuditRecord) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		r.PrevHash,
+		r.JournalEntryID.String(),
+		string(r.Action),
+		string(r.Status),
+		auditTime(r.RecordedAt),
+		r.Payload,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func auditTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// JournalAuditVerification is the result of checking the audit hash chain
+type JournalAuditVerification struct {
+	Valid          bool   `json:"valid"`
+	RecordsChecked int64  `json:"records_checked"`
+	HeadSequence   int64  `json:"head_sequence,omitempty"`
+	HeadHash       string `json:"head_hash,omitempty"` // Keep a copy outside the database to detect truncation
+	BrokenSequence int64  `json:"broken_sequence,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
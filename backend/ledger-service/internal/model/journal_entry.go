@@ -1,8 +1,11 @@
 package model
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -10,9 +13,11 @@ import (
 type JournalEntryStatus string
 
 const (
-	StatusPending JournalEntryStatus = "PENDING"
-	StatusPosted  JournalEntryStatus = "POSTED"
-	StatusVoid    JournalEntryStatus = "VOID"
+	StatusPending  JournalEntryStatus = "PENDING"  // Held against available balance, not yet booked
+	StatusPosted   JournalEntryStatus = "POSTED"   // Booked immediately (single-phase)
+	StatusBooked   JournalEntryStatus = "BOOKED"   // Pending entry that was finalized
+	StatusReversed JournalEntryStatus = "REVERSED" // Pending entry that was released without booking
+	StatusVoid     JournalEntryStatus = "VOID"
 )
 
 type JournalEntry struct {
@@ -21,8 +26,18 @@ type JournalEntry struct {
 	Description     string             `gorm:"type:text"`
 	ReferenceID     string             `gorm:"type:varchar(100);index"`
 	Status          JournalEntryStatus `gorm:"type:varchar(20);default:'POSTED'"`
+	ReversesEntryID *uuid.UUID         `gorm:"type:uuid;index"`    // Set on refunds and corrections of a booked entry
+	AdjustsPeriod   *string            `gorm:"type:char(7);index"` // Closed period an adjustment corrects, e.g. 2026-03
 	Postings        []Posting          `gorm:"foreignKey:JournalEntryID"`
+	FinalizedAt     *time.Time
 	CreatedAt       time.Time
+
+	// OverLimitAllowed lets the entry take accounts past their overdraft
+	// limit, for charges such as overdraft interest
+	OverLimitAllowed bool `gorm:"-" json:"-"`
+	// EnteredOverdraft lists the accounts the entry took into their
+	// overdraft, filled in by the repository when it is stored
+	EnteredOverdraft []uuid.UUID `gorm:"-" json:"-"`
 }
 
 type Posting struct {
@@ -33,3 +48,77 @@ type Posting struct {
 	Direction      int             `gorm:"type:smallint;not null;check:direction IN (1, -1)"` // 1 = Debit, -1 = Credit
 	CreatedAt      time.Time
 }
+
+// Postings the ledger refuses whatever the state of their accounts
+var (
+	ErrTooFewPostings        = errors.New("transaction must have at least 2 postings")
+	ErrInvalidPostingAmount  = errors.New("invalid amount format")
+	ErrInvalidPostingAccount = errors.New("invalid account UUID")
+	ErrUnbalanced            = errors.New("transaction is not balanced")
+)
+
+// ErrUnbalancedCurrency is returned for an entry whose postings balance in
+// total but not within each currency
+var ErrUnbalancedCurrency = errors.New("transaction is not balanced in each currency")
+
+// BatchEntryError is returned when one entry of a batch is refused; none of
+// the batch is stored. Index is the entry's position in the batch.
+type BatchEntryError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchEntryError) Error() string {
+	return fmt.Sprintf("entry %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchEntryError) Unwrap() error {
+	return e.Err
+}
+
+// CheckAmounts checks the postings of an entry against the currencies of
+// their accounts, keyed by account ID: no amount may have more decimal places
+// than its currency (no fractions of a yen), and the entry must balance within
+// each currency rather than only in total.
+func CheckAmounts(postings []Posting, currencies map[uuid.UUID]string) error {
+	totals := map[string]money.Money{}
+	for _, p := range postings {
+		currency := currencies[p.AccountID]
+		amount, err := money.FromDecimal(p.Amount, currency)
+		if err != nil {
+			return fmt.Errorf("account %s: %w", p.AccountID, err)
+		}
+		if p.Direction == -1 {
+			if amount, err = amount.Neg(); err != nil {
+				return err
+			}
+		}
+		total, ok := totals[currency]
+		if !ok {
+			total, _ = money.Zero(currency)
+		}
+		if totals[currency], err = total.Add(amount); err != nil {
+			return err
+		}
+	}
+	for currency, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: the %s postings do not balance", ErrUnbalancedCurrency, currency)
+		}
+	}
+	return nil
+}
+
+// AccountTransaction is a posting on an account as its owner sees it in the
+// transaction history, pending entries included. Amount is signed (negative
+// for money out).
+type AccountTransaction struct {
+	PostingID       uuid.UUID          `json:"posting_id"`
+	JournalEntryID  uuid.UUID          `json:"journal_entry_id"`
+	AccountID       uuid.UUID          `json:"account_id"`
+	Description     string             `json:"description"`
+	Amount          decimal.Decimal    `json:"amount"`
+	Status          JournalEntryStatus `json:"status"`
+	TransactionDate time.Time          `json:"transaction_date"`
+	CreatedAt       time.Time          `json:"created_at"`
+}
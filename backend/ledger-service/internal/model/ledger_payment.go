@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LedgerPayment is the ledger's record of a payment from the payment service,
+// kept so a cancellation is honored whichever of the payment and its
+// cancellation is consumed first
+type LedgerPayment struct {
+	PaymentID       uuid.UUID  `gorm:"type:uuid;primary_key"`
+	EntryID         *uuid.UUID `gorm:"type:uuid"` // Entry that posted the payment
+	CancelledAt     *time.Time
+	ReversalEntryID *uuid.UUID `gorm:"type:uuid"` // Entry that undid the payment when it was cancelled after posting
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (LedgerPayment) TableName() string {
+	return "ledger_payments"
+}
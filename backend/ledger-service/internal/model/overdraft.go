@@ -0,0 +1,84 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Account statuses. An account with an overdraft facility is OVERDRAWN while
+// its booked balance is below zero.
+const (
+	AccountActive    = "ACTIVE"
+	AccountOverdrawn = "OVERDRAWN"
+)
+
+// OverdraftError is returned when a posting would take an account's available
+// balance below its overdraft limit
+type OverdraftError struct {
+	AccountID uuid.UUID
+	Limit     decimal.Decimal
+	// Available is the available balance before the posting
+	Available decimal.Decimal
+}
+
+func (e *OverdraftError) Error() string {
+	return fmt.Sprintf("account %s would exceed its overdraft limit of %s", e.AccountID, e.Limit)
+}
+
+// HasOverdraft reports whether the account has an overdraft facility, even a
+// zero one, so the ledger keeps its available balance within the limit
+func (a *Account) HasOverdraft() bool {
+	return a.OverdraftLimit != nil
+}
+
+// CheckOverdraft returns an *OverdraftError if postings just applied to the
+// account took its available balance, which was before, below its overdraft
+// limit. Postings that leave the balance where it was or raise it are always
+// accepted, so an account already past its limit can still be paid into.
+func (a *Account) CheckOverdraft(before decimal.Decimal) error {
+	if !a.HasOverdraft() {
+		return nil
+	}
+	after := a.AvailableBalance()
+	if after.GreaterThanOrEqual(before) || after.GreaterThanOrEqual(a.OverdraftLimit.Neg()) {
+		return nil
+	}
+	return &OverdraftError{AccountID: a.ID, Limit: *a.OverdraftLimit, Available: before}
+}
+
+// UpdateOverdrawnStatus sets the status of an account with an overdraft
+// facility to OVERDRAWN or ACTIVE from its booked balance, and reports whether
+// the account has just gone into its overdraft. Other statuses are left alone.
+func (a *Account) UpdateOverdrawnStatus() bool {
+	overdrawn := a.HasOverdraft() && a.CachedBalance.IsNegative()
+	switch {
+	case overdrawn && a.Status == AccountActive:
+		a.Status = AccountOverdrawn
+		return true
+	case !overdrawn && a.Status == AccountOverdrawn:
+		a.Status = AccountActive
+	}
+	return false
+}
+
+// OverdraftInterestAccrual is a day's interest on an overdrawn balance. Accruals
+// are charged to the account together, once a month; ChargedEntryID is the
+// journal entry that charged it.
+type OverdraftInterestAccrual struct {
+	ID             uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AccountID      uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_overdraft_accruals_account_date" json:"account_id"`
+	AccrualDate    time.Time       `gorm:"type:date;not null;uniqueIndex:idx_overdraft_accruals_account_date" json:"accrual_date"`
+	Balance        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"balance"` // The overdrawn balance interest was charged on
+	Rate           decimal.Decimal `gorm:"type:numeric(7,6);not null" json:"rate"`
+	Amount         decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	ChargedEntryID *uuid.UUID      `gorm:"type:uuid;index" json:"charged_entry_id,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// TableName specifies the table name for GORM
+func (OverdraftInterestAccrual) TableName() string {
+	return "overdraft_interest_accruals"
+}
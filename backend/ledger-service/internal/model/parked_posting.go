@@ -0,0 +1,89 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ParkedPostingStatus string
+
+const (
+	// ParkedPostingParked is waiting for its next automatic retry, or for ops
+	ParkedPostingParked ParkedPostingStatus = "PARKED"
+	// ParkedPostingPosted was posted by a retry; the payment completed
+	ParkedPostingPosted ParkedPostingStatus = "POSTED"
+	// ParkedPostingFailed ran out of retries; the payment failed
+	ParkedPostingFailed ParkedPostingStatus = "FAILED"
+	// ParkedPostingCancelled was cancelled in the payment service while parked
+	ParkedPostingCancelled ParkedPostingStatus = "CANCELLED"
+)
+
+// ErrNotParked is returned when re-posting a posting that was posted, failed
+// or cancelled meanwhile
+var ErrNotParked = errors.New("posting is no longer parked")
+
+// ParkReason is why a payment could not be posted
+type ParkReason string
+
+const (
+	// ParkReasonAccountRestricted means a freeze or legal hold refused a posting
+	ParkReasonAccountRestricted ParkReason = "ACCOUNT_RESTRICTED"
+	// ParkReasonOverdraftLimit means the payer would go past their overdraft limit
+	ParkReasonOverdraftLimit ParkReason = "OVERDRAFT_LIMIT"
+	// ParkReasonAccountNotFound means an account of the payment does not exist
+	ParkReasonAccountNotFound ParkReason = "ACCOUNT_NOT_FOUND"
+	// ParkReasonInvalidPosting means the postings are malformed, unbalanced or
+	// do not fit the accounts' currencies
+	ParkReasonInvalidPosting ParkReason = "INVALID_POSTING"
+	ParkReasonUnknown        ParkReason = "UNKNOWN"
+)
+
+// ParkedPosting is a payment from the payment service that the ledger could
+// not post. It is retried after a delay until it posts or runs out of
+// attempts, when the payment fails; ops can repair its accounts or retry it
+// sooner while it is parked.
+type ParkedPosting struct {
+	ID            uuid.UUID           `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PaymentID     uuid.UUID           `gorm:"type:uuid;not null;uniqueIndex" json:"payment_id"`
+	Event         kafka.PaymentEvent  `gorm:"type:jsonb;serializer:json;not null" json:"event"`
+	Status        ParkedPostingStatus `gorm:"type:varchar(20);not null;index:idx_parked_postings_due" json:"status"`
+	ReasonCode    ParkReason          `gorm:"type:varchar(30);not null" json:"reason_code"`
+	LastError     string              `gorm:"type:text" json:"last_error"`
+	Attempts      int                 `gorm:"not null" json:"attempts"`
+	NextAttemptAt *time.Time          `gorm:"index:idx_parked_postings_due" json:"next_attempt_at,omitempty"`
+	EntryID       *uuid.UUID          `gorm:"type:uuid" json:"entry_id,omitempty"` // Entry that posted the payment
+	RepairedBy    *uuid.UUID          `gorm:"type:uuid" json:"repaired_by,omitempty"`
+	RepairNote    string              `gorm:"type:text" json:"repair_note,omitempty"`
+	RepairedAt    *time.Time          `json:"repaired_at,omitempty"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// TableName specifies the table name for GORM
+func (ParkedPosting) TableName() string {
+	return "parked_postings"
+}
+
+// ParkReasonFor classifies the error a posting failed with
+func ParkReasonFor(err error) ParkReason {
+	var restricted *RestrictionError
+	var overdraft *OverdraftError
+	switch {
+	case errors.As(err, &restricted):
+		return ParkReasonAccountRestricted
+	case errors.As(err, &overdraft):
+		return ParkReasonOverdraftLimit
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ParkReasonAccountNotFound
+	case errors.Is(err, ErrTooFewPostings), errors.Is(err, ErrInvalidPostingAmount), errors.Is(err, ErrInvalidPostingAccount),
+		errors.Is(err, ErrUnbalanced), errors.Is(err, ErrUnbalancedCurrency), errors.Is(err, money.ErrPrecision):
+		return ParkReasonInvalidPosting
+	default:
+		return ParkReasonUnknown
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProvisioningBatch records a bulk account provisioning request so that a retry
+// with the same reference returns the original results instead of creating accounts twice
+type ProvisioningBatch struct {
+	ID          uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Reference   string               `gorm:"type:varchar(100);uniqueIndex;not null" json:"reference"`
+	RequestedBy uuid.UUID            `gorm:"type:uuid;not null" json:"requested_by"`
+	Total       int                  `gorm:"not null" json:"total"`
+	Created     int                  `gorm:"not null" json:"created"`
+	Rejected    int                  `gorm:"not null" json:"rejected"`
+	Results     []ProvisioningResult `gorm:"type:jsonb;serializer:json" json:"results"`
+	CreatedAt   time.Time            `json:"created_at"`
+}
+
+type ProvisioningItemStatus string
+
+const (
+	ProvisioningCreated  ProvisioningItemStatus = "CREATED"
+	ProvisioningRejected ProvisioningItemStatus = "REJECTED"
+)
+
+// ProvisioningResult is the outcome for one item of a bulk request, in request order
+type ProvisioningResult struct {
+	Index         int                    `json:"index"`
+	Status        ProvisioningItemStatus `json:"status"`
+	AccountID     *uuid.UUID             `json:"account_id,omitempty"`
+	AccountNumber string                 `json:"account_number"`
+	Errors        []string               `json:"errors,omitempty"`
+}
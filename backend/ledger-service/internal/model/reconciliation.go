@@ -0,0 +1,15 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// BalanceMismatch is an account whose stored balances disagree with its postings
+type BalanceMismatch struct {
+	AccountID      uuid.UUID       `json:"account_id"`
+	CachedBalance  decimal.Decimal `json:"cached_balance"`
+	PostedBalance  decimal.Decimal `json:"posted_balance"` // Booked postings
+	HeldBalance    decimal.Decimal `json:"held_balance"`
+	PendingOutflow decimal.Decimal `json:"pending_outflow"` // Outgoing postings of pending entries
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type ImportFormat string
+
+const (
+	ImportFormatCSV ImportFormat = "CSV"
+	ImportFormatOFX ImportFormat = "OFX"
+)
+
+type ImportStatus string
+
+const (
+	ImportPending   ImportStatus = "PENDING"
+	ImportRunning   ImportStatus = "RUNNING"
+	ImportCompleted ImportStatus = "COMPLETED"
+)
+
+// TransactionImport is a file of historical transactions being loaded for a
+// customer migrated from another bank. Each row is booked against the
+// migration suspense account; the rows are kept so an interrupted import
+// resumes from ProcessedRows.
+type TransactionImport struct {
+	ID                uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Reference         string        `gorm:"type:varchar(100);uniqueIndex;not null" json:"reference"`
+	Format            ImportFormat  `gorm:"type:varchar(10);not null" json:"format"`
+	FileName          string        `gorm:"type:varchar(255)" json:"file_name"`
+	SuspenseAccountID uuid.UUID     `gorm:"type:uuid;not null" json:"suspense_account_id"`
+	RequestedBy       uuid.UUID     `gorm:"type:uuid;not null" json:"requested_by"`
+	Status            ImportStatus  `gorm:"type:varchar(20);not null" json:"status"`
+	TotalRows         int           `gorm:"not null" json:"total_rows"`
+	ProcessedRows     int           `gorm:"not null" json:"processed_rows"`
+	ImportedRows      int           `gorm:"not null" json:"imported_rows"`
+	FailedRows        int           `gorm:"not null" json:"failed_rows"`
+	Rows              []ImportRow   `gorm:"type:jsonb;serializer:json" json:"-"`
+	Errors            []ImportError `gorm:"type:jsonb;serializer:json" json:"errors"`
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+	CompletedAt       *time.Time    `json:"completed_at,omitempty"`
+}
+
+// ImportRow is one transaction of an import. A positive amount is money into
+// the account, a negative one money out.
+type ImportRow struct {
+	Row         int             `json:"row"`
+	Date        time.Time       `json:"date"`
+	AccountID   uuid.UUID       `json:"account_id"`
+	Amount      decimal.Decimal `json:"amount"`
+	Description string          `json:"description"`
+	ExternalID  string          `json:"external_id,omitempty"`
+}
+
+// ImportError reports why a row was not imported. Row is the line of a CSV
+// file or the position of the transaction in an OFX file.
+type ImportError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+)
+
+// NextAliasNumbers draws n numbers from the alias sequence
+func (r *LedgerRepository) NextAliasNumbers(n int) ([]int64, error) {
+	var numbers []int64
+	err := r.DB.Raw("SELECT nextval('account_alias_seq') FROM generate_series(1, ?)", n).Scan(&numbers).Error
+	return numbers, err
+}
+
+// FindAccountByIBAN retrieves an account by its IBAN
+func (r *LedgerRepository) FindAccountByIBAN(iban string) (*model.Account, error) {
+	var account model.Account
+	if err := r.DB.Where("iban = ?", iban).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindAccountBySortCode retrieves an account by its sort code and bank account number
+func (r *LedgerRepository) FindAccountBySortCode(sortCode, accountNumber string) (*model.Account, error) {
+	var account model.Account
+	if err := r.DB.Where("sort_code = ? AND bank_account_number = ?", sortCode, accountNumber).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListAccountsWithoutAlias returns accounts that have not been assigned aliases, oldest first
+func (r *LedgerRepository) ListAccountsWithoutAlias(limit int) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.DB.Where("iban IS NULL").Order("created_at").Limit(limit).Find(&accounts).Error
+	return accounts, err
+}
+
+// SetAccountAlias stores the aliases of an account that has none yet
+func (r *LedgerRepository) SetAccountAlias(acc *model.Account) error {
+	return r.DB.Model(&model.Account{}).Where("id = ? AND iban IS NULL", acc.ID).Updates(map[string]interface{}{
+		"iban":                acc.IBAN,
+		"sort_code":           acc.SortCode,
+		"bank_account_number": acc.BankAccountNumber,
+	}).Error
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRestrictionExists is returned when placing a restriction of a type the account already has
+var ErrRestrictionExists = errors.New("account already has an active restriction of this type")
+
+// ErrRestrictionNotActive is returned when lifting a restriction that is already lifted
+var ErrRestrictionNotActive = errors.New("restriction is not active")
+
+// PlaceRestriction stores a new active restriction on an account. The account
+// row is locked so the restriction applies to every posting that commits after it.
+func (r *LedgerRepository) PlaceRestriction(restriction *model.AccountRestriction) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var account model.Account
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&account, "id = ?", restriction.AccountID).Error; err != nil {
+			return err
+		}
+		err := tx.Create(restriction).Error
+		if errors.Is(err, gorm.ErrDuplicatedKey) || (err != nil && strings.Contains(err.Error(), "23505")) {
+			return ErrRestrictionExists
+		}
+		return err
+	})
+}
+
+// LiftRestriction marks an active restriction as lifted and returns it
+func (r *LedgerRepository) LiftRestriction(id string, liftedBy uuid.UUID, note string, at time.Time) (*model.AccountRestriction, error) {
+	result := r.DB.Model(&model.AccountRestriction{}).
+		Where("id = ? AND lifted_at IS NULL", id).
+		Updates(map[string]interface{}{"lifted_by": liftedBy, "lifted_at": at, "lift_note": note, "updated_at": at})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	restriction, err := r.GetRestriction(id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRestrictionNotActive
+	}
+	return restriction, nil
+}
+
+func (r *LedgerRepository) GetRestriction(id string) (*model.AccountRestriction, error) {
+	var restriction model.AccountRestriction
+	if err := r.DB.Where("id = ?", id).First(&restriction).Error; err != nil {
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// ListRestrictions returns an account's restrictions, newest first, including lifted ones
+func (r *LedgerRepository) ListRestrictions(accountID string) ([]model.AccountRestriction, error) {
+	var restrictions []model.AccountRestriction
+	err := r.DB.Where("account_id = ?", accountID).Order("created_at DESC").Find(&restrictions).Error
+	return restrictions, err
+}
+
+// ActiveRestrictions returns the active restrictions on any of the accounts
+func (r *LedgerRepository) ActiveRestrictions(accountIDs []string) ([]model.AccountRestriction, error) {
+	return activeRestrictions(r.DB, accountIDs)
+}
+
+func activeRestrictions(db *gorm.DB, accountIDs []string) ([]model.AccountRestriction, error) {
+	var restrictions []model.AccountRestriction
+	if len(accountIDs) == 0 {
+		return restrictions, nil
+	}
+	err := db.Where("account_id IN ? AND lifted_at IS NULL", accountIDs).Order("created_at").Find(&restrictions).Error
+	return restrictions, err
+}
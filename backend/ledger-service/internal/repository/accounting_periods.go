@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrPeriodAlreadyClosed is returned when closing a period the books are
+// already closed through
+var ErrPeriodAlreadyClosed = errors.New("accounting period is already closed")
+
+// ErrPeriodNotNext is returned when closing a period other than the one after
+// the latest closed period
+var ErrPeriodNotNext = errors.New("accounting periods must be closed in order")
+
+// Closing a period takes this lock exclusively; backdated postings take it
+// shared, so a close waits for them and they see the close once it commits
+const (
+	periodLockExclusive = "SELECT pg_advisory_xact_lock(hashtext('accounting_periods'))"
+	periodLockShared    = "SELECT pg_advisory_xact_lock_shared(hashtext('accounting_periods'))"
+)
+
+// ListAccountingPeriods returns the closed periods, newest first
+func (r *LedgerRepository) ListAccountingPeriods() ([]model.AccountingPeriod, error) {
+	var periods []model.AccountingPeriod
+	err := r.DB.Order("ends_at DESC").Find(&periods).Error
+	return periods, err
+}
+
+// GetAccountingPeriod returns a closed period, or gorm.ErrRecordNotFound if it is open
+func (r *LedgerRepository) GetAccountingPeriod(period string) (*model.AccountingPeriod, error) {
+	var p model.AccountingPeriod
+	if err := r.DB.Where("period = ?", period).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LatestClosedPeriod returns the most recently closed period, or nil if none is
+func (r *LedgerRepository) LatestClosedPeriod() (*model.AccountingPeriod, error) {
+	return latestClosedPeriod(r.DB)
+}
+
+// ListPeriodSummaries returns the account summaries generated when a period was closed
+func (r *LedgerRepository) ListPeriodSummaries(period string) ([]model.AccountingPeriodSummary, error) {
+	var summaries []model.AccountingPeriodSummary
+	err := r.DB.Where("period = ?", period).Order("currency_code, account_id").Find(&summaries).Error
+	return summaries, err
+}
+
+// CloseAccountingPeriod closes p and generates its account summaries in one
+// transaction. p must follow the latest closed period; the first period
+// closed may be any month, and closes every month before it too.
+func (r *LedgerRepository) CloseAccountingPeriod(p *model.AccountingPeriod) ([]model.AccountingPeriodSummary, error) {
+	var summaries []model.AccountingPeriodSummary
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(periodLockExclusive).Error; err != nil {
+			return err
+		}
+		latest, err := latestClosedPeriod(tx)
+		if err != nil {
+			return err
+		}
+		previous := ""
+		if latest != nil {
+			switch {
+			case !latest.EndsAt.Before(p.EndsAt):
+				return ErrPeriodAlreadyClosed
+			case !latest.EndsAt.Equal(p.StartsAt):
+				return fmt.Errorf("%w: the next period to close is %s", ErrPeriodNotNext, model.PeriodOf(latest.EndsAt))
+			}
+			previous = latest.Period
+		}
+
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		if err := createPeriodSummaries(tx, p, previous); err != nil {
+			return err
+		}
+		return tx.Where("period = ?", p.Period).Order("currency_code, account_id").Find(&summaries).Error
+	})
+	return summaries, err
+}
+
+// createPeriodSummaries stores each account's activity over p. An account's
+// opening balance is its closing balance in the previous period, or for the
+// first period closed, everything booked before p.
+func createPeriodSummaries(tx *gorm.DB, p *model.AccountingPeriod, previous string) error {
+	return tx.Exec(`
+INSERT INTO accounting_period_summaries (period, account_id, currency_code, opening_balance, debits, credits, closing_balance, postings, created_at)
+SELECT @period, a.id, a.currency_code,
+       COALESCE(prev.closing_balance, 0) + moved.earlier,
+       moved.debits,
+       moved.credits,
+       COALESCE(prev.closing_balance, 0) + moved.earlier + moved.debits - moved.credits,
+       moved.postings,
+       NOW()
+FROM accounts a
+LEFT JOIN accounting_period_summaries prev ON prev.account_id = a.id AND prev.period = @previous
+CROSS JOIN LATERAL (
+    SELECT COALESCE(SUM(p.amount * p.direction) FILTER (WHERE `+bookedAt+` < @starts_at), 0) AS earlier,
+           COALESCE(SUM(p.amount) FILTER (WHERE p.direction = 1 AND `+bookedAt+` >= @starts_at), 0) AS debits,
+           COALESCE(SUM(p.amount) FILTER (WHERE p.direction = -1 AND `+bookedAt+` >= @starts_at), 0) AS credits,
+           COUNT(*) FILTER (WHERE `+bookedAt+` >= @starts_at) AS postings
+    FROM postings p
+    JOIN journal_entries je ON je.id = p.journal_entry_id
+    WHERE p.account_id = a.id
+      AND je.status IN @statuses
+      AND `+bookedAt+` >= CASE WHEN prev.period IS NULL THEN '-infinity'::timestamptz ELSE @starts_at END
+      AND `+bookedAt+` < @ends_at
+) moved
+WHERE a.deleted_at IS NULL AND a.created_at < @ends_at`,
+		map[string]interface{}{
+			"period":    p.Period,
+			"previous":  previous,
+			"starts_at": p.StartsAt,
+			"ends_at":   p.EndsAt,
+			"statuses":  bookedStatuses,
+		}).Error
+}
+
+// checkPeriodOpen refuses an entry dated before the end of the latest closed
+// period. Only months that have ended are closed, so entries dated this month
+// skip the lookup.
+func checkPeriodOpen(tx *gorm.DB, date time.Time) error {
+	monthStart, _, _ := model.PeriodBounds(model.PeriodOf(time.Now()))
+	if !date.Before(monthStart) {
+		return nil
+	}
+	if err := tx.Exec(periodLockShared).Error; err != nil {
+		return err
+	}
+	latest, err := latestClosedPeriod(tx)
+	if err != nil {
+		return err
+	}
+	if latest != nil && date.Before(latest.EndsAt) {
+		return fmt.Errorf("%w: the books are closed through %s", model.ErrPeriodClosed, latest.Period)
+	}
+	return nil
+}
+
+func latestClosedPeriod(db *gorm.DB) (*model.AccountingPeriod, error) {
+	var periods []model.AccountingPeriod
+	if err := db.Order("ends_at DESC").Limit(1).Find(&periods).Error; err != nil {
+		return nil, err
+	}
+	if len(periods) == 0 {
+		return nil, nil
+	}
+	return &periods[0], nil
+}
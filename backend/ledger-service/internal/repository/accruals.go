@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAccrualsRolledUp is returned when rolling up accruals another run has already rolled up
+var ErrAccrualsRolledUp = errors.New("accruals are already rolled up")
+
+// CreateAccrual stores an accrual and reports whether it was stored; an
+// accrual with the same reference is kept
+func (r *LedgerRepository) CreateAccrual(accrual *model.Accrual) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(accrual)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetAccrualByReference returns the accrual stored with a reference
+func (r *LedgerRepository) GetAccrualByReference(reference string) (*model.Accrual, error) {
+	var accrual model.Accrual
+	if err := r.DB.First(&accrual, "reference = ?", reference).Error; err != nil {
+		return nil, err
+	}
+	return &accrual, nil
+}
+
+// ListAccruals returns an account's accruals dated from from up to, not
+// including, to, oldest first
+func (r *LedgerRepository) ListAccruals(accountID string, from, to time.Time) ([]model.Accrual, error) {
+	var accruals []model.Accrual
+	err := r.DB.Where("account_id = ? AND accrual_date >= ? AND accrual_date < ?", accountID, from, to).
+		Order("accrual_date, created_at").
+		Find(&accruals).Error
+	return accruals, err
+}
+
+// ListRolledUpAccruals returns an account's accruals rolled up by a journal entry, oldest first
+func (r *LedgerRepository) ListRolledUpAccruals(accountID, entryID string) ([]model.Accrual, error) {
+	var accruals []model.Accrual
+	err := r.DB.Where("account_id = ? AND rollup_entry_id = ?", accountID, entryID).
+		Order("accrual_date, created_at").
+		Find(&accruals).Error
+	return accruals, err
+}
+
+// ListPendingAccruals returns the accruals dated before the given day that
+// have not been rolled up, oldest first
+func (r *LedgerRepository) ListPendingAccruals(before time.Time) ([]model.Accrual, error) {
+	var accruals []model.Accrual
+	err := r.DB.Where("rolled_up_at IS NULL AND accrual_date < ?", before).
+		Order("account_id, accrual_date, created_at").
+		Find(&accruals).Error
+	return accruals, err
+}
+
+// RollUpAccruals posts the entry rolling up accruals and marks them as rolled
+// up by it, in one transaction; a nil entry only marks them. It returns
+// ErrAccrualsRolledUp, posting nothing, if any of them was rolled up meanwhile.
+func (r *LedgerRepository) RollUpAccruals(entry *model.JournalEntry, accrualIDs []uuid.UUID) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"rolled_up_at": time.Now()}
+		if entry != nil {
+			if err := NewLedgerRepository(tx).postTransactionOnce(entry); err != nil {
+				return err
+			}
+			updates["rollup_entry_id"] = entry.ID
+		}
+		result := tx.Model(&model.Accrual{}).
+			Where("id IN ? AND rolled_up_at IS NULL", accrualIDs).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(accrualIDs)) {
+			return ErrAccrualsRolledUp
+		}
+		return nil
+	})
+}
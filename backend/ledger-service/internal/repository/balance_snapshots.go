@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// bookedAt is when a posting reached the booked balance: immediately for
+// POSTED entries, when finalized for BOOKED ones
+const bookedAt = "COALESCE(je.finalized_at, je.transaction_date)"
+
+var bookedStatuses = []model.JournalEntryStatus{model.StatusPosted, model.StatusBooked}
+
+// LatestSnapshot returns the account's newest snapshot closing at or before at,
+// or nil if there is none
+func (r *LedgerRepository) LatestSnapshot(accountID string, at time.Time) (*model.BalanceSnapshot, error) {
+	var snapshot model.BalanceSnapshot
+	err := r.DB.Where("account_id = ? AND closing_at <= ?", accountID, at).
+		Order("closing_at DESC").
+		First(&snapshot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// SumBookedPostings returns the signed total of the account's postings booked
+// in [from, to). A zero from has no lower bound.
+func (r *LedgerRepository) SumBookedPostings(accountID string, from, to time.Time) (decimal.Decimal, error) {
+	var total decimal.NullDecimal
+	query := r.bookedPostings(accountID, to)
+	if !from.IsZero() {
+		query = query.Where(bookedAt+" >= ?", from)
+	}
+	err := query.Select("SUM(p.amount * p.direction)").Row().Scan(&total)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return total.Decimal, nil
+}
+
+// ListBookedPostings returns the account's postings booked in [from, to), oldest first
+func (r *LedgerRepository) ListBookedPostings(accountID string, from, to time.Time) ([]model.StatementLine, error) {
+	var lines []model.StatementLine
+	err := r.bookedPostings(accountID, to).
+		Where(bookedAt+" >= ?", from).
+		Select("p.id AS posting_id, p.journal_entry_id, " + bookedAt + " AS booked_at, je.description, p.amount * p.direction AS amount").
+		Order("booked_at, p.id").
+		Scan(&lines).Error
+	return lines, err
+}
+
+func (r *LedgerRepository) bookedPostings(accountID string, to time.Time) *gorm.DB {
+	return r.DB.Table("postings AS p").
+		Joins("JOIN journal_entries je ON je.id = p.journal_entry_id").
+		Where("p.account_id = ? AND je.status IN ?", accountID, bookedStatuses).
+		Where(bookedAt+" < ?", to)
+}
+
+// CreateSnapshots stores the balance of every account at closingAt as the
+// snapshot for date. Each balance is the account's previous snapshot plus the
+// postings booked since, so a missed day is covered by the next run. Existing
+// snapshots are kept, which makes repeated runs harmless.
+func (r *LedgerRepository) CreateSnapshots(date, closingAt time.Time) (int64, error) {
+	result := r.DB.Exec(`
+INSERT INTO balance_snapshots (account_id, date, closing_at, balance, created_at)
+SELECT a.id, @date, @closing_at,
+       COALESCE(prev.balance, 0) + COALESCE((
+           SELECT SUM(p.amount * p.direction)
+           FROM postings p
+           JOIN journal_entries je ON je.id = p.journal_entry_id
+           WHERE p.account_id = a.id
+             AND je.status IN @statuses
+             AND `+bookedAt+` >= COALESCE(prev.closing_at, '-infinity'::timestamptz)
+             AND `+bookedAt+` < @closing_at
+       ), 0),
+       NOW()
+FROM accounts a
+LEFT JOIN LATERAL (
+    SELECT s.balance, s.closing_at
+    FROM balance_snapshots s
+    WHERE s.account_id = a.id AND s.closing_at < @closing_at
+    ORDER BY s.closing_at DESC
+    LIMIT 1
+) prev ON true
+WHERE a.deleted_at IS NULL AND a.created_at < @closing_at
+ON CONFLICT (account_id, date) DO NOTHING`,
+		map[string]interface{}{
+			"date":       date.Format("2006-01-02"),
+			"closing_at": closingAt,
+			"statuses":   bookedStatuses,
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// journalInsertBatchSize bounds the number of rows per INSERT statement of
+// journal entries, postings and audit records
+const journalInsertBatchSize = 500
+
+// PostTransactionsBatch stores several journal entries in one database
+// transaction, as PostTransaction does each: the accounts they touch are
+// locked once, in order, balances are applied entry by entry in memory, and
+// entries, postings, balances and audit records are written with a statement
+// each. If any entry is refused, a *model.BatchEntryError says which, and
+// nothing is stored.
+func (r *LedgerRepository) PostTransactionsBatch(entries []*model.JournalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.retrying("transaction batch", func() error {
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			return postBatch(tx, entries)
+		})
+	})
+}
+
+// postBatch applies and stores the entries through tx
+func postBatch(tx *gorm.DB, entries []*model.JournalEntry) error {
+	earliest := entries[0].TransactionDate
+	accountSet := map[string]bool{}
+	for i, entry := range entries {
+		if !balanced(entry.Postings) {
+			return &model.BatchEntryError{Index: i, Err: model.ErrUnbalanced}
+		}
+		if entry.TransactionDate.Before(earliest) {
+			earliest = entry.TransactionDate
+		}
+		for _, p := range entry.Postings {
+			accountSet[p.AccountID.String()] = true
+		}
+	}
+
+	// The earliest entry is the one a closed period would refuse first
+	if err := checkPeriodOpen(tx, earliest); err != nil {
+		return err
+	}
+
+	accountIDs := make([]string, 0, len(accountSet))
+	for id := range accountSet {
+		accountIDs = append(accountIDs, id)
+	}
+	sort.Strings(accountIDs)
+
+	// Locked in ID order, as PostTransaction locks them, so batches and single
+	// postings cannot deadlock each other
+	var locked []model.Account
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", accountIDs).Order("id").Find(&locked).Error; err != nil {
+		return fmt.Errorf("failed to lock accounts: %w", err)
+	}
+	accounts := make(map[uuid.UUID]*model.Account, len(locked))
+	currencies := make(map[uuid.UUID]string, len(locked))
+	for i := range locked {
+		accounts[locked[i].ID] = &locked[i]
+		currencies[locked[i].ID] = locked[i].CurrencyCode
+	}
+	for _, id := range accountIDs {
+		if accounts[uuid.MustParse(id)] == nil {
+			return fmt.Errorf("failed to lock account %s: %w", id, gorm.ErrRecordNotFound)
+		}
+	}
+
+	restrictions, err := activeRestrictions(tx, accountIDs)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if err := applyEntry(entry, accounts, restrictions); err != nil {
+			return &model.BatchEntryError{Index: i, Err: err}
+		}
+		if err := model.CheckAmounts(entry.Postings, currencies); err != nil {
+			return &model.BatchEntryError{Index: i, Err: err}
+		}
+	}
+
+	if err := tx.CreateInBatches(entries, journalInsertBatchSize).Error; err != nil {
+		return err
+	}
+	if err := updateBalances(tx, locked); err != nil {
+		return err
+	}
+	return appendJournalAuditBatch(tx, entries, model.AuditEntryCreated)
+}
+
+// balanced reports whether the postings sum to zero
+func balanced(postings []model.Posting) bool {
+	var sum decimal.Decimal
+	for _, p := range postings {
+		if p.Direction == -1 {
+			sum = sum.Sub(p.Amount)
+		} else {
+			sum = sum.Add(p.Amount)
+		}
+	}
+	return sum.IsZero()
+}
+
+// applyEntry applies one entry of a batch to the locked accounts, checking
+// restrictions and overdraft limits as postTransactionOnce does
+func applyEntry(entry *model.JournalEntry, accounts map[uuid.UUID]*model.Account, restrictions []model.AccountRestriction) error {
+	entry.EnteredOverdraft = nil
+	var order []uuid.UUID
+	postingMap := make(map[uuid.UUID][]model.Posting)
+	for _, p := range entry.Postings {
+		if _, seen := postingMap[p.AccountID]; !seen {
+			order = append(order, p.AccountID)
+		}
+		postingMap[p.AccountID] = append(postingMap[p.AccountID], p)
+	}
+
+	for _, id := range order {
+		account := accounts[id]
+		if err := model.CheckRestrictions(id, restrictions, postingMap[id]); err != nil {
+			return err
+		}
+
+		available := account.AvailableBalance()
+		for _, p := range postingMap[id] {
+			if entry.Status == model.StatusPending {
+				if p.Direction == -1 {
+					account.HeldBalance = account.HeldBalance.Add(p.Amount)
+				}
+				continue
+			}
+			movement := p.Amount
+			if p.Direction == -1 {
+				movement = movement.Neg()
+			}
+			account.CachedBalance = account.CachedBalance.Add(movement)
+		}
+
+		if !entry.OverLimitAllowed {
+			if err := account.CheckOverdraft(available); err != nil {
+				return err
+			}
+		}
+		if account.UpdateOverdrawnStatus() {
+			entry.EnteredOverdraft = append(entry.EnteredOverdraft, account.ID)
+		}
+		account.BalanceVersion++
+	}
+	return nil
+}
+
+// updateBalances writes the balances and statuses of the accounts with a
+// single UPDATE
+func updateBalances(tx *gorm.DB, accounts []model.Account) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+	rows := make([]string, len(accounts))
+	args := []interface{}{time.Now()}
+	for i, a := range accounts {
+		rows[i] = "(?::uuid, ?::numeric, ?::numeric, ?::integer, ?::varchar)"
+		args = append(args, a.ID, a.CachedBalance, a.HeldBalance, a.BalanceVersion, a.Status)
+	}
+	return tx.Exec(`UPDATE accounts AS a SET
+			cached_balance = v.cached_balance,
+			held_balance = v.held_balance,
+			balance_version = v.balance_version,
+			status = v.status,
+			updated_at = ?
+		FROM (VALUES `+strings.Join(rows, ", ")+`) AS v(id, cached_balance, held_balance, balance_version, status)
+		WHERE a.id = v.id`, args...).Error
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+// benchmarkLatency is the round trip of a statement to the fake database,
+// about that of a Postgres instance in the same zone
+const benchmarkLatency = 200 * time.Microsecond
+
+// BenchmarkPostTransactions compares posting payments with a database
+// transaction each against PostTransactionsBatch posting them 100 at a time,
+// the consumer's default micro-batch. Each op is one payment; round_trips/op is
+// the statements it costs, e.g.
+//
+//	go test ./internal/repository -run '^$' -bench PostTransactions -benchmem
+func BenchmarkPostTransactions(b *testing.B) {
+	run := func(b *testing.B, batchSize int) {
+		fake := newFakeDB(1000)
+		fake.latency = benchmarkLatency
+		repo := NewLedgerRepository(fake.gorm(b))
+		entries := payments(fake.accountIDs(), b.N)
+		b.ResetTimer()
+		for i := 0; i < b.N; i += batchSize {
+			batch := entries[i:min(i+batchSize, b.N)]
+			if batchSize == 1 {
+				if err := repo.PostTransaction(batch[0]); err != nil {
+					b.Fatal(err)
+				}
+				continue
+			}
+			if err := repo.PostTransactionsBatch(batch); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		b.ReportMetric(float64(fake.roundTrips)/float64(b.N), "round_trips/op")
+	}
+
+	b.Run("PostTransaction", func(b *testing.B) { run(b, 1) })
+	b.Run("PostTransactionsBatch", func(b *testing.B) { run(b, 100) })
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// payments returns n entries each moving an amount between two of the accounts
+func payments(accounts []uuid.UUID, n int) []*model.JournalEntry {
+	entries := make([]*model.JournalEntry, n)
+	for i := range entries {
+		from, to := accounts[(2*i)%len(accounts)], accounts[(2*i+1)%len(accounts)]
+		amount := decimal.New(int64(1000+i), -2)
+		entries[i] = &model.JournalEntry{
+			TransactionDate: time.Now(),
+			Description:     "Payment",
+			Status:          model.StatusPosted,
+			Postings: []model.Posting{
+				{AccountID: from, Amount: amount, Direction: -1},
+				{AccountID: to, Amount: amount, Direction: 1},
+			},
+		}
+	}
+	return entries
+}
+
+func TestPostTransactionsBatch(t *testing.T) {
+	fake := newFakeDB(200)
+	repo := NewLedgerRepository(fake.gorm(t))
+	accounts := fake.accountIDs()
+
+	entries := payments(accounts, 100)
+	require.NoError(t, repo.PostTransactionsBatch(entries))
+	for _, entry := range entries {
+		assert.NotEqual(t, uuid.Nil, entry.ID)
+	}
+	batchTrips := fake.roundTrips
+
+	// The batch costs a fixed number of round trips, however many entries it has
+	fake.reset()
+	require.NoError(t, repo.PostTransactionsBatch(payments(accounts, 10)))
+	assert.Equal(t, batchTrips, fake.roundTrips)
+
+	fake.reset()
+	for _, entry := range payments(accounts, 100) {
+		require.NoError(t, repo.PostTransaction(entry))
+	}
+	assert.Greater(t, fake.roundTrips, 5*batchTrips, "statements: %v", fake.statements)
+}
+
+func TestPostTransactionsBatch_RefusesWholeBatch(t *testing.T) {
+	fake := newFakeDB(10)
+	repo := NewLedgerRepository(fake.gorm(t))
+
+	entries := payments(fake.accountIDs(), 5)
+	entries[3].Postings[1].Amount = decimal.NewFromInt(1)
+	err := repo.PostTransactionsBatch(entries)
+	var batchErr *model.BatchEntryError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 3, batchErr.Index)
+	assert.ErrorIs(t, err, model.ErrUnbalanced)
+
+	entries = payments(fake.accountIDs(), 5)
+	entries[2].Postings[0].AccountID = uuid.New()
+	err = repo.PostTransactionsBatch(entries)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	assert.Equal(t, "ROLLBACK", fake.statements[len(fake.statements)-1])
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"gorm.io/gorm/clause"
+)
+
+// GetJournalEntry returns an entry with its postings
+func (r *LedgerRepository) GetJournalEntry(id string) (*model.JournalEntry, error) {
+	var entry model.JournalEntry
+	if err := r.DB.Preload("Postings").Where("id = ?", id).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveCategories upserts posting categories. Unless override is set, categories
+// the user chose are kept and only rule-assigned ones are replaced.
+func (r *LedgerRepository) SaveCategories(categories []model.PostingCategory, override bool) error {
+	if len(categories) == 0 {
+		return nil
+	}
+
+	onConflict := clause.OnConflict{
+		Columns:   []clause.Column{{Name: "posting_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"category", "source", "merchant", "updated_at"}),
+	}
+	if !override {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "posting_categories.source <> ?", Vars: []interface{}{model.CategorySourceUser}},
+		}}
+	}
+	return r.DB.Clauses(onConflict).Create(&categories).Error
+}
+
+// SpendByCategory totals the user's outgoing postings booked in [from, to) by
+// category and currency. Postings without a category count as OTHER.
+func (r *LedgerRepository) SpendByCategory(userID string, from, to time.Time) ([]model.CategorySpend, error) {
+	var spend []model.CategorySpend
+	err := r.DB.Table("postings AS p").
+		Select("COALESCE(pc.category, ?) AS category, a.currency_code AS currency, SUM(p.amount) AS total, COUNT(*) AS count", model.CategoryOther).
+		Joins("JOIN journal_entries je ON je.id = p.journal_entry_id").
+		Joins("JOIN accounts a ON a.id = p.account_id").
+		Joins("LEFT JOIN posting_categories pc ON pc.posting_id = p.id").
+		Where("a.user_id = ? AND p.direction = -1", userID).
+		Where("je.status IN ?", []model.JournalEntryStatus{model.StatusPosted, model.StatusBooked}).
+		Where("je.transaction_date >= ? AND je.transaction_date < ?", from, to).
+		Group("1, 2").
+		Order("total DESC").
+		Scan(&spend).Error
+	return spend, err
+}
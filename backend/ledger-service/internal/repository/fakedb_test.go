@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeDB is a database/sql driver that answers the statements of the ledger's
+// posting paths as Postgres would, without storing anything, after a round
+// trip's latency. It counts round trips, so tests can compare how chatty the
+// paths are.
+type fakeDB struct {
+	latency time.Duration
+
+	mu         sync.Mutex
+	roundTrips int
+	statements []string
+	sequence   int64
+	// accounts are the account IDs that exist, each with a large balance
+	accounts map[string]bool
+	// saved are the columns of each account update, by account ID
+	saved map[string][]map[string]driver.Value
+}
+
+// updatedColumn matches a column set by an UPDATE and its placeholder
+var updatedColumn = regexp.MustCompile(`"(\w+)" ?= ?\$(\d+)`)
+
+func newFakeDB(accounts int) *fakeDB {
+	db := &fakeDB{accounts: map[string]bool{}, saved: map[string][]map[string]driver.Value{}}
+	for i := 0; i < accounts; i++ {
+		db.accounts[uuid.NewString()] = true
+	}
+	return db
+}
+
+// accountIDs returns the IDs of the accounts
+func (f *fakeDB) accountIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(f.accounts))
+	for id := range f.accounts {
+		ids = append(ids, uuid.MustParse(id))
+	}
+	return ids
+}
+
+// gorm opens a gorm Postgres connection to the fake
+func (f *fakeDB) gorm(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(f)}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// trip waits a round trip and records the statement
+func (f *fakeDB) trip(statement string) {
+	time.Sleep(f.latency)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roundTrips++
+	f.statements = append(f.statements, statement)
+}
+
+func (f *fakeDB) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.roundTrips = 0
+	f.statements = nil
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: use the connector")
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.db.trip("BEGIN")
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.trip("COMMIT")
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.trip("ROLLBACK")
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.trip(query)
+	if strings.HasPrefix(query, `UPDATE "accounts"`) {
+		c.db.saveAccount(query, args)
+	}
+	return driver.RowsAffected(insertedRows(query)), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.db.trip(query)
+	switch {
+	case strings.Contains(query, "RETURNING"):
+		return c.db.returning(query), nil
+	case strings.Contains(query, `FROM "accounts"`):
+		return c.db.selectAccounts(args), nil
+	}
+	return &fakeRows{}, nil
+}
+
+// saveAccount records the columns an account update sets
+func (f *fakeDB) saveAccount(query string, args []driver.NamedValue) {
+	columns := map[string]driver.Value{}
+	for _, m := range updatedColumn.FindAllStringSubmatch(query, -1) {
+		n, _ := strconv.Atoi(m[2])
+		columns[m[1]] = args[n-1].Value
+	}
+	id, _ := columns["id"].(string)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saved[id] = append(f.saved[id], columns)
+}
+
+// insertedRows is the number of rows an INSERT writes, 1 for other statements
+func insertedRows(query string) int64 {
+	if !strings.HasPrefix(query, "INSERT") {
+		return 1
+	}
+	return int64(strings.Count(query, "),(") + 1)
+}
+
+// returning answers the RETURNING clause of an INSERT with generated IDs and sequences
+func (f *fakeDB) returning(query string) *fakeRows {
+	var columns []string
+	for _, column := range strings.Split(query[strings.LastIndex(query, "RETURNING ")+len("RETURNING "):], ",") {
+		columns = append(columns, strings.Trim(strings.TrimSpace(column), `"`))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rows := &fakeRows{columns: columns}
+	for i := int64(0); i < insertedRows(query); i++ {
+		row := make([]driver.Value, len(columns))
+		for j, column := range columns {
+			switch column {
+			case "id":
+				row[j] = uuid.NewString()
+			case "sequence":
+				f.sequence++
+				row[j] = f.sequence
+			}
+		}
+		rows.rows = append(rows.rows, row)
+	}
+	return rows
+}
+
+// selectAccounts answers a query for accounts by ID with those that exist
+func (f *fakeDB) selectAccounts(args []driver.NamedValue) *fakeRows {
+	rows := &fakeRows{columns: []string{"id", "user_id", "account_number", "type", "currency_code", "status", "balance_version", "cached_balance", "held_balance"}}
+	for _, arg := range args {
+		id, ok := arg.Value.(string)
+		if !ok || !f.accounts[id] {
+			continue
+		}
+		rows.rows = append(rows.rows, []driver.Value{id, id, id[:20], "LIABILITY", "GBP", "ACTIVE", int64(1), "1000000000.0000", "0.0000"})
+	}
+	return rows
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// appendJournalAudit records action on entry at the end of the audit hash
+// chain. It runs inside the transaction that changes the entry, so the record
+// commits or rolls back with it. The advisory lock serializes appends; without
+// it two transactions could chain onto the same previous record.
+func appendJournalAudit(tx *gorm.DB, entry *model.JournalEntry, action model.JournalAuditAction) error {
+	return appendJournalAuditBatch(tx, []*model.JournalEntry{entry}, action)
+}
+
+// appendJournalAuditBatch is appendJournalAudit for several entries, chained
+// in order and stored with a single insert
+func appendJournalAuditBatch(tx *gorm.DB, entries []*model.JournalEntry, action model.JournalAuditAction) error {
+	if err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('journal_audit_log'))`).Error; err != nil {
+		return err
+	}
+
+	prevHash := model.GenesisHash
+	var last model.JournalAuditRecord
+	err := tx.Order("sequence DESC").Limit(1).Take(&last).Error
+	switch {
+	case err == nil:
+		prevHash = last.Hash
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return err
+	}
+
+	now := time.Now()
+	records := make([]*model.JournalAuditRecord, len(entries))
+	for i, entry := range entries {
+		record, err := model.NewJournalAuditRecord(entry, action, prevHash, now)
+		if err != nil {
+			return err
+		}
+		records[i] = record
+		prevHash = record.Hash
+	}
+	return tx.CreateInBatches(records, journalInsertBatchSize).Error
+}
+
+// ListJournalAudit returns up to limit audit records after the given sequence, in chain order
+func (r *LedgerRepository) ListJournalAudit(afterSequence int64, limit int) ([]model.JournalAuditRecord, error) {
+	var records []model.JournalAuditRecord
+	err := r.DB.Where("sequence > ?", afterSequence).Order("sequence").Limit(limit).Find(&records).Error
+	return records, err
+}
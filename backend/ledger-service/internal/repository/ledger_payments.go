@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+// LockPayment returns the record of a payment, creating it if this is the
+// first event seen for the payment, and locks it until the repository's
+// transaction ends. The payment and cancellation consumers take this lock, so
+// they handle one payment one after the other.
+func (r *LedgerRepository) LockPayment(paymentID uuid.UUID) (*model.LedgerPayment, error) {
+	record := model.LedgerPayment{PaymentID: paymentID}
+	if err := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return nil, err
+	}
+	if err := r.DB.Clauses(clause.Locking{Strength: "UPDATE"}).First(&record, "payment_id = ?", paymentID).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// SavePayment stores a payment record locked by LockPayment
+func (r *LedgerRepository) SavePayment(record *model.LedgerPayment) error {
+	return r.DB.Save(record).Error
+}
+
+// LockPayments is LockPayment for several payments, with a statement to
+// create the missing records and one to lock them all, in payment ID order
+func (r *LedgerRepository) LockPayments(paymentIDs []uuid.UUID) ([]model.LedgerPayment, error) {
+	records := make([]model.LedgerPayment, len(paymentIDs))
+	for i, id := range paymentIDs {
+		records[i] = model.LedgerPayment{PaymentID: id}
+	}
+	if err := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error; err != nil {
+		return nil, err
+	}
+	var locked []model.LedgerPayment
+	err := r.DB.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("payment_id IN ?", paymentIDs).Order("payment_id").Find(&locked).Error
+	return locked, err
+}
+
+// SavePayments stores payment records locked by LockPayments with one statement
+func (r *LedgerRepository) SavePayments(records []model.LedgerPayment) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return r.DB.Save(&records).Error
+}
@@ -3,12 +3,13 @@ package repository
 import (
 	"errors"
 	"fmt"
-	"log/slog"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
@@ -22,6 +23,9 @@ var RetryableErrors = []string{
 	"40P01", // deadlock_detected
 }
 
+// ErrEntryNotPending is returned when finalizing an entry that is no longer pending
+var ErrEntryNotPending = errors.New("journal entry is not pending")
+
 type LedgerRepository struct {
 	DB *gorm.DB
 }
@@ -64,10 +68,19 @@ func (r *LedgerRepository) ListAccounts() ([]model.Account, error) {
 	return accounts, nil
 }
 
-// ListAccountsByUser returns accounts for a specific user
+// ListAccountsByUser returns the personal accounts of a specific user
 func (r *LedgerRepository) ListAccountsByUser(userID string) ([]model.Account, error) {
 	var accounts []model.Account
-	if err := r.DB.Where("user_id = ?", userID).Find(&accounts).Error; err != nil {
+	if err := r.DB.Scopes(tenant.Scope("")).Where("user_id = ?", userID).Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ListAccountsByOrg returns the accounts of an organization
+func (r *LedgerRepository) ListAccountsByOrg(orgID string) ([]model.Account, error) {
+	var accounts []model.Account
+	if err := r.DB.Scopes(tenant.Scope(orgID)).Find(&accounts).Error; err != nil {
 		return nil, err
 	}
 	return accounts, nil
@@ -76,25 +89,9 @@ func (r *LedgerRepository) ListAccountsByUser(userID string) ([]model.Account, e
 // PostTransaction executes a journal entry and updates balances atomically using Database Transaction.
 // Implements retry logic for serialization failures and deadlocks, with deterministic lock ordering.
 func (r *LedgerRepository) PostTransaction(entry *model.JournalEntry) error {
-	var lastErr error
-	for attempt := 0; attempt < MaxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff with jitter
-			backoffMs := (1 << attempt) * 50 // 100ms, 200ms, 400ms
-			time.Sleep(time.Duration(backoffMs) * time.Millisecond)
-			slog.Info("Retrying transaction", "attempt", attempt+1, "lastError", lastErr)
-		}
-
-		lastErr = r.postTransactionOnce(entry)
-		if lastErr == nil {
-			return nil
-		}
-
-		if !isRetryableError(lastErr) {
-			return lastErr // Non-retryable error, return immediately
-		}
-	}
-	return fmt.Errorf("transaction failed after %d retries: %w", MaxRetries, lastErr)
+	return r.retrying("transaction", func() error {
+		return r.postTransactionOnce(entry)
+	})
 }
 
 // postTransactionOnce executes the transaction once (called by PostTransaction with retry logic)
@@ -112,38 +109,65 @@ func (r *LedgerRepository) postTransactionOnce(entry *model.JournalEntry) error
 		}
 
 		if !sum.IsZero() {
-			return errors.New("transaction is not balanced")
+			return model.ErrUnbalanced
 		}
 
-		// 2. Create Journal Entry
-		if err := tx.Create(entry).Error; err != nil {
+		// Entries dated in a closed accounting period are refused
+		if err := checkPeriodOpen(tx, entry.TransactionDate); err != nil {
 			return err
 		}
 
-		// 3. Collect and sort account IDs for deterministic lock ordering (prevents deadlocks)
-		accountIDs := make([]string, len(entry.Postings))
-		for i, p := range entry.Postings {
-			accountIDs[i] = p.AccountID.String()
+		// 2. Create Journal Entry
+		entry.EnteredOverdraft = nil
+		if err := tx.Create(entry).Error; err != nil {
+			return err
 		}
-		sort.Strings(accountIDs)
 
-		// Create a map of account ID to posting for efficient lookup
+		// 3. Group the postings by account, and sort the accounts for
+		// deterministic lock ordering (prevents deadlocks). An account with
+		// several postings, such as a payer charged a fee, is locked once.
+		accountIDs := make([]string, 0, len(entry.Postings))
 		postingMap := make(map[string][]model.Posting)
 		for _, p := range entry.Postings {
 			id := p.AccountID.String()
+			if _, seen := postingMap[id]; !seen {
+				accountIDs = append(accountIDs, id)
+			}
 			postingMap[id] = append(postingMap[id], p)
 		}
+		sort.Strings(accountIDs)
 
 		// 4. Lock and update accounts in sorted order to prevent deadlocks
+		currencies := make(map[uuid.UUID]string, len(accountIDs))
 		for _, accID := range accountIDs {
 			// Lock account for update (deterministic order)
 			var account model.Account
 			if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&account, "id = ?", accID).Error; err != nil {
 				return fmt.Errorf("failed to lock account %s: %w", accID, err)
 			}
+			currencies[account.ID] = account.CurrencyCode
+
+			// Freezes and legal holds are read after the lock, so one placed
+			// concurrently applies as soon as it commits
+			restrictions, err := activeRestrictions(tx, []string{accID})
+			if err != nil {
+				return err
+			}
+			if err := model.CheckRestrictions(account.ID, restrictions, postingMap[accID]); err != nil {
+				return err
+			}
 
 			// Apply all postings for this account
+			available := account.AvailableBalance()
 			for _, p := range postingMap[accID] {
+				if entry.Status == model.StatusPending {
+					// Pending entries only hold outgoing funds; the booked balance is untouched
+					if p.Direction == -1 {
+						account.HeldBalance = account.HeldBalance.Add(p.Amount)
+					}
+					continue
+				}
+
 				// Update balance
 				movement := p.Amount
 				if p.Direction == -1 {
@@ -152,6 +176,16 @@ func (r *LedgerRepository) postTransactionOnce(entry *model.JournalEntry) error
 				account.CachedBalance = account.CachedBalance.Add(movement)
 			}
 
+			// Accounts with an overdraft facility may go negative up to their limit
+			if !entry.OverLimitAllowed {
+				if err := account.CheckOverdraft(available); err != nil {
+					return err
+				}
+			}
+			if account.UpdateOverdrawnStatus() {
+				entry.EnteredOverdraft = append(entry.EnteredOverdraft, account.ID)
+			}
+
 			// Update Version
 			account.BalanceVersion++
 
@@ -160,6 +194,100 @@ func (r *LedgerRepository) postTransactionOnce(entry *model.JournalEntry) error
 			}
 		}
 
-		return nil
+		// 5. Amounts must fit their account's currency and balance per currency
+		if err := model.CheckAmounts(entry.Postings, currencies); err != nil {
+			return err
+		}
+
+		return appendJournalAudit(tx, entry, model.AuditEntryCreated)
+	})
+}
+
+// FinalizeTransaction moves a pending journal entry to BOOKED or REVERSED.
+// Booking releases the hold and applies the postings to the booked balance;
+// reversing only releases the hold.
+func (r *LedgerRepository) FinalizeTransaction(id string, status model.JournalEntryStatus) (*model.JournalEntry, error) {
+	if status != model.StatusBooked && status != model.StatusReversed {
+		return nil, fmt.Errorf("invalid final status %s", status)
+	}
+
+	var entry *model.JournalEntry
+	err := r.retrying("finalize", func() (err error) {
+		entry, err = r.finalizeTransactionOnce(id, status)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *LedgerRepository) finalizeTransactionOnce(id string, status model.JournalEntryStatus) (*model.JournalEntry, error) {
+	var entry model.JournalEntry
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the entry so concurrent book/reverse calls cannot both succeed
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").Preload("Postings").First(&entry, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if entry.Status != model.StatusPending {
+			return ErrEntryNotPending
+		}
+
+		accountIDs := make([]string, 0, len(entry.Postings))
+		postingMap := make(map[string][]model.Posting)
+		for _, p := range entry.Postings {
+			accID := p.AccountID.String()
+			if _, seen := postingMap[accID]; !seen {
+				accountIDs = append(accountIDs, accID)
+			}
+			postingMap[accID] = append(postingMap[accID], p)
+		}
+		sort.Strings(accountIDs)
+
+		for _, accID := range accountIDs {
+			var account model.Account
+			if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&account, "id = ?", accID).Error; err != nil {
+				return fmt.Errorf("failed to lock account %s: %w", accID, err)
+			}
+
+			for _, p := range postingMap[accID] {
+				if p.Direction == -1 {
+					account.HeldBalance = account.HeldBalance.Sub(p.Amount)
+				}
+				if status == model.StatusBooked {
+					movement := p.Amount
+					if p.Direction == -1 {
+						movement = movement.Neg()
+					}
+					account.CachedBalance = account.CachedBalance.Add(movement)
+				}
+			}
+
+			// Booking moves held funds into the booked balance, so the limit
+			// was already checked when the entry was held
+			if account.UpdateOverdrawnStatus() {
+				entry.EnteredOverdraft = append(entry.EnteredOverdraft, account.ID)
+			}
+
+			account.BalanceVersion++
+			if err := tx.Save(&account).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		entry.Status = status
+		entry.FinalizedAt = &now
+		if err := tx.Model(&entry).Updates(map[string]interface{}{
+			"status":       status,
+			"finalized_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return appendJournalAudit(tx, &entry, model.AuditStatusChange)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
 }
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostTransaction_AccountWithSeveralPostings(t *testing.T) {
+	fake := newFakeDB(3)
+	repo := NewLedgerRepository(fake.gorm(t))
+	accounts := fake.accountIDs()
+	payer, payee, fees := accounts[0], accounts[1], accounts[2]
+
+	// A transfer with a fee debits the payer twice
+	require.NoError(t, repo.PostTransaction(&model.JournalEntry{
+		TransactionDate: time.Now(),
+		Description:     "Transfer with fee",
+		Status:          model.StatusPosted,
+		Postings: []model.Posting{
+			{AccountID: payer, Amount: decimal.NewFromInt(100), Direction: -1},
+			{AccountID: payee, Amount: decimal.NewFromInt(100), Direction: 1},
+			{AccountID: payer, Amount: decimal.NewFromInt(5), Direction: -1},
+			{AccountID: fees, Amount: decimal.NewFromInt(5), Direction: 1},
+		},
+	}))
+
+	// The payer is locked and saved once, with both postings applied once
+	require.Len(t, fake.saved[payer.String()], 1)
+	balance, err := decimal.NewFromString(fake.saved[payer.String()][0]["cached_balance"].(string))
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1000000000-105).Equal(balance), "payer balance %s", balance)
+	require.Len(t, fake.saved[payee.String()], 1)
+	require.Len(t, fake.saved[fees.String()], 1)
+}
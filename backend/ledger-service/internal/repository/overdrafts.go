@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAccrualsCharged is returned when charging accruals another run has already charged
+var ErrAccrualsCharged = errors.New("overdraft interest accruals are already charged")
+
+// SetOverdraft sets or, with a nil limit, removes an account's overdraft
+// facility and returns the account. The account row is locked so postings
+// in flight are checked against either the old facility or the new one.
+func (r *LedgerRepository) SetOverdraft(accountID string, limit *decimal.Decimal, rate decimal.Decimal) (*model.Account, error) {
+	var account model.Account
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Set("gorm:query_option", "FOR UPDATE").First(&account, "id = ?", accountID).Error; err != nil {
+			return err
+		}
+		account.OverdraftLimit = limit
+		account.OverdraftInterestRate = rate
+		account.UpdateOverdrawnStatus()
+		return tx.Model(&account).Select("overdraft_limit", "overdraft_interest_rate", "status", "updated_at").Updates(&account).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListOverdrawnAccounts returns the accounts that are overdrawn on a facility
+// that charges interest
+func (r *LedgerRepository) ListOverdrawnAccounts() ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.DB.Where("overdraft_limit IS NOT NULL AND overdraft_interest_rate > 0 AND cached_balance < 0").
+		Order("id").
+		Find(&accounts).Error
+	return accounts, err
+}
+
+// CreateInterestAccrual stores a day's interest for an account and reports
+// whether it was stored; an accrual the account already has for the day is kept
+func (r *LedgerRepository) CreateInterestAccrual(accrual *model.OverdraftInterestAccrual) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(accrual)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListUnchargedAccruals returns the accruals dated before the given day that
+// have not been charged, oldest first
+func (r *LedgerRepository) ListUnchargedAccruals(before time.Time) ([]model.OverdraftInterestAccrual, error) {
+	var accruals []model.OverdraftInterestAccrual
+	err := r.DB.Where("charged_entry_id IS NULL AND accrual_date < ?", before).
+		Order("account_id, accrual_date").
+		Find(&accruals).Error
+	return accruals, err
+}
+
+// ChargeInterest posts the entry charging accrued interest and marks the
+// accruals as charged by it, in one transaction. It returns
+// ErrAccrualsCharged, posting nothing, if any of them was charged meanwhile.
+func (r *LedgerRepository) ChargeInterest(entry *model.JournalEntry, accrualIDs []uuid.UUID) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := NewLedgerRepository(tx).postTransactionOnce(entry); err != nil {
+			return err
+		}
+		result := tx.Model(&model.OverdraftInterestAccrual{}).
+			Where("id IN ? AND charged_entry_id IS NULL", accrualIDs).
+			Update("charged_entry_id", entry.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(accrualIDs)) {
+			return ErrAccrualsCharged
+		}
+		return nil
+	})
+}
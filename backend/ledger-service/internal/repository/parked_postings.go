@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ParkPosting stores a payment posting that failed
+func (r *LedgerRepository) ParkPosting(p *model.ParkedPosting) error {
+	return r.DB.Create(p).Error
+}
+
+func (r *LedgerRepository) GetParkedPosting(id string) (*model.ParkedPosting, error) {
+	var p model.ParkedPosting
+	if err := r.DB.Where("id = ?", id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListParkedPostings returns the newest parked postings, optionally only those
+// in one status or parked for one reason
+func (r *LedgerRepository) ListParkedPostings(status model.ParkedPostingStatus, reason model.ParkReason, limit int) ([]model.ParkedPosting, error) {
+	var postings []model.ParkedPosting
+	query := r.DB.Model(&model.ParkedPosting{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if reason != "" {
+		query = query.Where("reason_code = ?", reason)
+	}
+	err := query.Order("created_at DESC").Limit(limit).Find(&postings).Error
+	return postings, err
+}
+
+// ListDueParkedPostings returns the parked postings due for a retry by now,
+// longest waiting first
+func (r *LedgerRepository) ListDueParkedPostings(now time.Time, limit int) ([]model.ParkedPosting, error) {
+	var postings []model.ParkedPosting
+	err := r.DB.Where("status = ? AND next_attempt_at <= ?", model.ParkedPostingParked, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&postings).Error
+	return postings, err
+}
+
+// UpdateParkedPosting saves a posting if it is still parked
+func (r *LedgerRepository) UpdateParkedPosting(p *model.ParkedPosting) (bool, error) {
+	result := r.DB.Model(p).Where("status = ?", model.ParkedPostingParked).Select("*").Updates(p)
+	return result.RowsAffected > 0, result.Error
+}
+
+// RepostParked posts the entry of a parked posting, records it on the payment
+// and marks the posting posted, in one transaction. The payment is locked
+// first, as by the payment consumers; if it was cancelled, nothing is posted
+// and the posting is marked cancelled.
+func (r *LedgerRepository) RepostParked(id uuid.UUID, entry *model.JournalEntry) (*model.ParkedPosting, error) {
+	var p model.ParkedPosting
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		repo := NewLedgerRepository(tx)
+		if err := tx.First(&p, "id = ?", id).Error; err != nil {
+			return err
+		}
+		record, err := repo.LockPayment(p.PaymentID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&p, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if p.Status != model.ParkedPostingParked {
+			return model.ErrNotParked
+		}
+		if record.CancelledAt != nil {
+			p.Status = model.ParkedPostingCancelled
+			p.NextAttemptAt = nil
+			return tx.Save(&p).Error
+		}
+		if err := repo.postTransactionOnce(entry); err != nil {
+			return err
+		}
+		record.EntryID = &entry.ID
+		if err := repo.SavePayment(record); err != nil {
+			return err
+		}
+		p.Status = model.ParkedPostingPosted
+		p.EntryID = &entry.ID
+		p.NextAttemptAt = nil
+		return tx.Save(&p).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// CancelParkedPosting marks the parked posting of a payment cancelled, if it
+// has one. Call it with the payment locked by LockPayment.
+func (r *LedgerRepository) CancelParkedPosting(paymentID uuid.UUID) error {
+	return r.DB.Model(&model.ParkedPosting{}).
+		Where("payment_id = ? AND status = ?", paymentID, model.ParkedPostingParked).
+		Updates(map[string]interface{}{"status": model.ParkedPostingCancelled, "next_attempt_at": nil}).Error
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"gorm.io/gorm"
+)
+
+// accountInsertBatchSize bounds the number of rows per INSERT statement
+const accountInsertBatchSize = 100
+
+// ErrBatchReferenceExists is returned when a provisioning batch with the same reference was already stored
+var ErrBatchReferenceExists = errors.New("provisioning batch reference already exists")
+
+// GetProvisioningBatch finds a previously stored bulk provisioning batch
+func (r *LedgerRepository) GetProvisioningBatch(reference string) (*model.ProvisioningBatch, error) {
+	var batch model.ProvisioningBatch
+	if err := r.DB.Where("reference = ?", reference).First(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// FindExistingAccountNumbers returns which of the given account numbers are already taken
+func (r *LedgerRepository) FindExistingAccountNumbers(numbers []string) ([]string, error) {
+	var existing []string
+	if len(numbers) == 0 {
+		return existing, nil
+	}
+	err := r.DB.Model(&model.Account{}).Unscoped().
+		Where("account_number IN ?", numbers).
+		Pluck("account_number", &existing).Error
+	return existing, err
+}
+
+// CreateAccountsBatch stores the batch record and all its accounts in one transaction
+func (r *LedgerRepository) CreateAccountsBatch(batch *model.ProvisioningBatch, accounts []model.Account) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			if isUniqueViolation(err) {
+				return ErrBatchReferenceExists
+			}
+			return err
+		}
+		if len(accounts) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(accounts, accountInsertBatchSize).Error
+	})
+}
+
+// isUniqueViolation checks for PostgreSQL unique_violation (23505)
+func isUniqueViolation(err error) bool {
+	return errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")
+}
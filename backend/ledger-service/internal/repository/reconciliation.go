@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+)
+
+// FindBalanceMismatches compares every account's cached and held balances with
+// its postings and returns up to limit accounts where they differ. It runs as a
+// single statement so postings committed meanwhile cannot skew the comparison.
+func (r *LedgerRepository) FindBalanceMismatches(limit int) ([]model.BalanceMismatch, error) {
+	var mismatches []model.BalanceMismatch
+	err := r.DB.Raw(`
+SELECT account_id, cached_balance, posted_balance, held_balance, pending_outflow
+FROM (
+    SELECT a.id AS account_id, a.cached_balance, a.held_balance,
+           COALESCE(SUM(p.amount * p.direction) FILTER (WHERE je.status IN @booked), 0) AS posted_balance,
+           COALESCE(SUM(p.amount) FILTER (WHERE je.status = @pending AND p.direction = -1), 0) AS pending_outflow
+    FROM accounts a
+    LEFT JOIN postings p ON p.account_id = a.id
+    LEFT JOIN journal_entries je ON je.id = p.journal_entry_id
+    WHERE a.deleted_at IS NULL
+    GROUP BY a.id, a.cached_balance, a.held_balance
+) balances
+WHERE cached_balance <> posted_balance OR held_balance <> pending_outflow
+ORDER BY account_id
+LIMIT @limit`,
+		map[string]interface{}{
+			"booked":  bookedStatuses,
+			"pending": model.StatusPending,
+			"limit":   limit,
+		}).Scan(&mismatches).Error
+	return mismatches, err
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// ListRecentTransactions returns up to limit of the newest postings of each
+// of the accounts, newest first within an account, in one query
+func (r *LedgerRepository) ListRecentTransactions(accountIDs []uuid.UUID, limit int) ([]model.AccountTransaction, error) {
+	var txs []model.AccountTransaction
+	if len(accountIDs) == 0 {
+		return txs, nil
+	}
+	err := r.DB.Raw(`
+SELECT posting_id, journal_entry_id, account_id, description, amount, status, transaction_date, created_at
+FROM (
+    SELECT p.id AS posting_id, p.journal_entry_id, p.account_id, je.description,
+           p.amount * p.direction AS amount, je.status, je.transaction_date, p.created_at,
+           ROW_NUMBER() OVER (PARTITION BY p.account_id ORDER BY p.created_at DESC, p.id DESC) AS n
+    FROM postings p
+    JOIN journal_entries je ON je.id = p.journal_entry_id
+    WHERE p.account_id IN ?
+) recent
+WHERE n <= ?
+ORDER BY account_id, created_at DESC, posting_id DESC`, accountIDs, limit).Scan(&txs).Error
+	return txs, err
+}
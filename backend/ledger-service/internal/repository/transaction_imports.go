@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateTransactionImport stores a new import with its rows
+func (r *LedgerRepository) CreateTransactionImport(imp *model.TransactionImport) error {
+	return r.DB.Create(imp).Error
+}
+
+// GetTransactionImport retrieves an import with its rows
+func (r *LedgerRepository) GetTransactionImport(id string) (*model.TransactionImport, error) {
+	var imp model.TransactionImport
+	if err := r.DB.Where("id = ?", id).First(&imp).Error; err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// GetTransactionImportByReference finds an import by its idempotency reference
+func (r *LedgerRepository) GetTransactionImportByReference(reference string) (*model.TransactionImport, error) {
+	var imp model.TransactionImport
+	if err := r.DB.Omit("rows").Where("reference = ?", reference).First(&imp).Error; err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
+
+// ListTransactionImports returns the newest imports without their rows
+func (r *LedgerRepository) ListTransactionImports(limit int) ([]model.TransactionImport, error) {
+	var imports []model.TransactionImport
+	err := r.DB.Omit("rows").Order("created_at DESC").Limit(limit).Find(&imports).Error
+	return imports, err
+}
+
+// SetTransactionImportStatus moves an import to status
+func (r *LedgerRepository) SetTransactionImportStatus(id uuid.UUID, status model.ImportStatus) error {
+	return r.DB.Model(&model.TransactionImport{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// PostImportChunk books entries[i] for rows[i] and records the progress on imp
+// in one transaction. Each entry is booked under a savepoint, so one that fails
+// (e.g. its account was closed since validation) is rolled back alone and
+// recorded as a row error. imp is only updated once the transaction commits.
+func (r *LedgerRepository) PostImportChunk(imp *model.TransactionImport, rows []model.ImportRow, entries []*model.JournalEntry) ([]*model.JournalEntry, error) {
+	progress := *imp
+	progress.Errors = append([]model.ImportError(nil), imp.Errors...)
+	var posted []*model.JournalEntry
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		posted = posted[:0]
+		chunk := &LedgerRepository{DB: tx}
+		for i, entry := range entries {
+			if err := chunk.postTransactionOnce(entry); err != nil {
+				progress.Errors = append(progress.Errors, model.ImportError{Row: rows[i].Row, Message: err.Error()})
+				progress.FailedRows++
+			} else {
+				posted = append(posted, entry)
+				progress.ImportedRows++
+			}
+			progress.ProcessedRows++
+		}
+		if progress.ProcessedRows >= progress.TotalRows {
+			now := time.Now()
+			progress.Status = model.ImportCompleted
+			progress.CompletedAt = &now
+		}
+		return tx.Model(&model.TransactionImport{ID: imp.ID}).
+			Select("status", "processed_rows", "imported_rows", "failed_rows", "errors", "completed_at", "updated_at").
+			Updates(&progress).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	*imp = progress
+	return posted, nil
+}
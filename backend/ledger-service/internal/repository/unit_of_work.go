@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnitOfWorkDone is returned when a unit of work is used after it was
+// committed or rolled back
+var ErrUnitOfWorkDone = errors.New("unit of work already finished")
+
+// UnitOfWork is the database transaction of one request. It begins when a
+// repository first joins it, so requests that only read never open one, and
+// the request's middleware commits or rolls it back once the handler is done.
+type UnitOfWork struct {
+	db *gorm.DB
+
+	mu          sync.Mutex
+	tx          *gorm.DB
+	repo        *LedgerRepository
+	afterCommit []func()
+	done        bool
+}
+
+// NewUnitOfWork returns a unit of work whose transaction, once begun, runs
+// with ctx
+func NewUnitOfWork(ctx context.Context, db *gorm.DB) *UnitOfWork {
+	return &UnitOfWork{db: db.WithContext(ctx)}
+}
+
+type unitOfWorkKey struct{}
+
+// ContextWithUnitOfWork returns ctx carrying u
+func ContextWithUnitOfWork(ctx context.Context, u *UnitOfWork) context.Context {
+	return context.WithValue(ctx, unitOfWorkKey{}, u)
+}
+
+// UnitOfWorkFromContext returns the unit of work of ctx, or nil if it has none
+func UnitOfWorkFromContext(ctx context.Context) *UnitOfWork {
+	u, _ := ctx.Value(unitOfWorkKey{}).(*UnitOfWork)
+	return u
+}
+
+// Bind returns repo bound to the unit of work's transaction, beginning it if
+// need be. Repositories that cannot join it are returned as they are.
+func (u *UnitOfWork) Bind(repo any) any {
+	if _, ok := repo.(*LedgerRepository); !ok {
+		return repo
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.done {
+		// Every statement through it fails, as nothing would commit them
+		finished := u.db.Session(&gorm.Session{NewDB: true})
+		finished.AddError(ErrUnitOfWorkDone)
+		return NewLedgerRepository(finished)
+	}
+	if u.repo == nil {
+		u.tx = u.db.Begin()
+		u.repo = NewLedgerRepository(u.tx)
+	}
+	return u.repo
+}
+
+// AfterCommit runs fn once the transaction commits. It never runs if the
+// transaction rolls back.
+func (u *UnitOfWork) AfterCommit(fn func()) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.afterCommit = append(u.afterCommit, fn)
+}
+
+// Commit commits the transaction, if one was begun, and then runs the
+// functions deferred with AfterCommit
+func (u *UnitOfWork) Commit() error {
+	u.mu.Lock()
+	tx, hooks := u.tx, u.afterCommit
+	u.done, u.afterCommit = true, nil
+	u.mu.Unlock()
+
+	if tx != nil {
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+// Rollback rolls back the transaction, if one was begun, and drops the
+// functions deferred with AfterCommit
+func (u *UnitOfWork) Rollback() error {
+	u.mu.Lock()
+	tx := u.tx
+	u.done, u.afterCommit = true, nil
+	u.mu.Unlock()
+
+	if tx == nil {
+		return nil
+	}
+	err := tx.Rollback().Error
+	if errors.Is(err, gorm.ErrInvalidTransaction) {
+		// The transaction failed to begin, so there is nothing to roll back
+		return nil
+	}
+	return err
+}
+
+// retrying runs attempt, a database transaction, again with backoff while it
+// fails on a serialization failure or deadlock. On a repository bound to a
+// unit of work the transaction is a savepoint of the request's.
+func (r *LedgerRepository) retrying(operation string, attempt func() error) error {
+	var lastErr error
+	for i := 0; i < MaxRetries; i++ {
+		if i > 0 {
+			// Exponential backoff
+			backoffMs := (1 << i) * 50 // 100ms, 200ms
+			time.Sleep(time.Duration(backoffMs) * time.Millisecond)
+			slog.Info("Retrying "+operation, "attempt", i+1, "lastError", lastErr)
+		}
+
+		lastErr = attempt()
+		if lastErr == nil {
+			return nil
+		}
+
+		if !isRetryableError(lastErr) {
+			return lastErr // Non-retryable error, return immediately
+		}
+	}
+	return fmt.Errorf("%s failed after %d retries: %w", operation, MaxRetries, lastErr)
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitOfWork_Commit(t *testing.T) {
+	fake := newFakeDB(10)
+	uow := NewUnitOfWork(context.Background(), fake.gorm(t))
+	var committed []string
+	uow.AfterCommit(func() { committed = append(committed, fake.statements[len(fake.statements)-1]) })
+
+	// The transaction begins when a repository first joins it
+	assert.Empty(t, fake.statements)
+	repo := uow.Bind(NewLedgerRepository(fake.gorm(t))).(*LedgerRepository)
+	assert.Same(t, repo, uow.Bind(repo), "every repository shares the transaction")
+	assert.Equal(t, "not a ledger repository", uow.Bind("not a ledger repository"))
+
+	// Each posting runs in a savepoint of the request's transaction
+	for _, entry := range payments(fake.accountIDs(), 2) {
+		require.NoError(t, repo.PostTransaction(entry))
+	}
+	require.NoError(t, uow.Commit())
+
+	assert.Equal(t, "BEGIN", fake.statements[0])
+	assert.Equal(t, []string{"COMMIT"}, committed, "hooks run after the commit")
+	var begins, savepoints int
+	for _, statement := range fake.statements {
+		if statement == "BEGIN" {
+			begins++
+		}
+		if strings.HasPrefix(statement, "SAVEPOINT") {
+			savepoints++
+		}
+	}
+	assert.Equal(t, 1, begins)
+	assert.Equal(t, 2, savepoints)
+
+	// A unit of work that is done refuses further statements
+	trips := fake.roundTrips
+	_, err := uow.Bind(repo).(*LedgerRepository).GetAccount(fake.accountIDs()[0].String())
+	assert.ErrorIs(t, err, ErrUnitOfWorkDone)
+	assert.Equal(t, trips, fake.roundTrips)
+}
+
+func TestUnitOfWork_Rollback(t *testing.T) {
+	fake := newFakeDB(10)
+	uow := NewUnitOfWork(context.Background(), fake.gorm(t))
+	ran := false
+	uow.AfterCommit(func() { ran = true })
+
+	repo := uow.Bind(NewLedgerRepository(fake.gorm(t))).(*LedgerRepository)
+	require.NoError(t, repo.PostTransaction(payments(fake.accountIDs(), 1)[0]))
+	require.NoError(t, uow.Rollback())
+
+	assert.Equal(t, "ROLLBACK", fake.statements[len(fake.statements)-1])
+	assert.False(t, ran, "hooks are dropped on rollback")
+}
+
+func TestUnitOfWork_ReadOnly(t *testing.T) {
+	fake := newFakeDB(10)
+	uow := NewUnitOfWork(context.Background(), fake.gorm(t))
+	ran := false
+	uow.AfterCommit(func() { ran = true })
+
+	// Nothing joined, so there is no transaction to commit
+	require.NoError(t, uow.Commit())
+	assert.Empty(t, fake.statements)
+	assert.True(t, ran)
+
+	ctx := ContextWithUnitOfWork(context.Background(), uow)
+	assert.Same(t, uow, UnitOfWorkFromContext(ctx))
+	assert.Nil(t, UnitOfWorkFromContext(context.Background()))
+}
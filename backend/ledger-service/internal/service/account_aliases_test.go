@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryAliases is an in-memory AliasRepository over a list of accounts
+type memoryAliases struct {
+	next     int64
+	accounts []model.Account
+	fail     bool
+}
+
+func (m *memoryAliases) NextAliasNumbers(n int) ([]int64, error) {
+	if m.fail {
+		return nil, errors.New("sequence unavailable")
+	}
+	numbers := make([]int64, n)
+	for i := range numbers {
+		m.next++
+		numbers[i] = m.next
+	}
+	return numbers, nil
+}
+
+func (m *memoryAliases) FindAccountByIBAN(iban string) (*model.Account, error) {
+	for i := range m.accounts {
+		if a := &m.accounts[i]; a.IBAN != nil && *a.IBAN == iban {
+			return a, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryAliases) FindAccountBySortCode(sortCode, accountNumber string) (*model.Account, error) {
+	for i := range m.accounts {
+		if a := &m.accounts[i]; a.SortCode != nil && *a.SortCode == sortCode && *a.BankAccountNumber == accountNumber {
+			return a, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryAliases) ListAccountsWithoutAlias(limit int) ([]model.Account, error) {
+	var out []model.Account
+	for _, a := range m.accounts {
+		if a.IBAN == nil && len(out) < limit {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryAliases) SetAccountAlias(acc *model.Account) error {
+	for i := range m.accounts {
+		if m.accounts[i].ID == acc.ID {
+			m.accounts[i].IBAN, m.accounts[i].SortCode, m.accounts[i].BankAccountNumber = acc.IBAN, acc.SortCode, acc.BankAccountNumber
+		}
+	}
+	return nil
+}
+
+func TestAliasConfig_Generate(t *testing.T) {
+	sortCode, accountNumber, iban := DefaultAliasConfig.Generate(1)
+	assert.Equal(t, "040075", sortCode)
+	assert.Equal(t, "00000018", accountNumber)
+	assert.Equal(t, "GB86NEOB04007500000018", iban)
+	assert.True(t, ValidIBAN(iban))
+
+	// Well-known published IBANs pass, and a single changed digit fails
+	assert.True(t, ValidIBAN("GB82WEST12345698765432"))
+	assert.True(t, ValidIBAN("DE89370400440532013000"))
+	assert.False(t, ValidIBAN("GB82WEST12345698765433"))
+
+	// Every number yields a distinct, valid alias
+	seen := map[string]bool{}
+	for n := int64(1); n <= 1000; n++ {
+		_, accountNumber, iban := DefaultAliasConfig.Generate(n)
+		require.True(t, ValidIBAN(iban), iban)
+		require.False(t, seen[accountNumber], accountNumber)
+		seen[accountNumber] = true
+	}
+
+	assert.Error(t, AliasConfig{CountryCode: "GB", BankCode: "NEO", SortCode: "040075"}.Validate())
+	assert.Error(t, AliasConfig{CountryCode: "GB", BankCode: "NEOB", SortCode: "04-00-75"}.Validate())
+}
+
+func TestCreateAccount_AssignsAliases(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	repo.On("CreateAccount", mock.Anything).Return(nil)
+	aliases := &memoryAliases{}
+	svc := NewLedgerService(repo)
+	require.NoError(t, svc.SetAliases(aliases, DefaultAliasConfig))
+
+	acc, err := svc.CreateAccount(uuid.New().String(), "", "ACC-1", "Main", "GBP", model.Asset)
+	require.NoError(t, err)
+	require.NotNil(t, acc.IBAN)
+	assert.Equal(t, "GB86NEOB04007500000018", *acc.IBAN)
+
+	// The account still opens when no number can be drawn; the backfill assigns one later
+	aliases.fail = true
+	acc, err = svc.CreateAccount(uuid.New().String(), "", "ACC-2", "Savings", "GBP", model.Asset)
+	require.NoError(t, err)
+	assert.Nil(t, acc.IBAN)
+
+	aliases.fail = false
+	aliases.accounts = []model.Account{*acc}
+	require.NoError(t, svc.AliasBackfillJob(context.Background(), nil))
+	require.NotNil(t, aliases.accounts[0].IBAN)
+	assert.True(t, ValidIBAN(*aliases.accounts[0].IBAN))
+}
+
+func TestResolveAlias(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+	_, err := svc.ResolveAlias("GB86NEOB04007500000018", "", "")
+	assert.ErrorIs(t, err, ErrAliasesDisabled)
+
+	sortCode, accountNumber, iban := DefaultAliasConfig.Generate(7)
+	account := model.Account{ID: uuid.New(), IBAN: &iban, SortCode: &sortCode, BankAccountNumber: &accountNumber}
+	require.NoError(t, svc.SetAliases(&memoryAliases{accounts: []model.Account{account}}, DefaultAliasConfig))
+
+	acc, err := svc.ResolveAlias(" gb"+iban[2:6]+" "+iban[6:], "", "")
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, acc.ID)
+
+	acc, err = svc.ResolveAlias("", "04-00-75", accountNumber)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, acc.ID)
+
+	_, unknownNumber, unknownIBAN := DefaultAliasConfig.Generate(8)
+	_, err = svc.ResolveAlias(unknownIBAN, "", "")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	_, err = svc.ResolveAlias("", sortCode, unknownNumber)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	tests := map[string][3]string{
+		"bad IBAN check digits":   {"GB34" + iban[4:], "", ""},
+		"bad account check digit": {"", sortCode, accountNumber[:7] + "0"},
+		"short sort code":         {"", "04007", accountNumber},
+		"IBAN and sort code":      {iban, sortCode, accountNumber},
+		"nothing":                 {"", "", ""},
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.ResolveAlias(in[0], in[1], in[2])
+			assert.ErrorIs(t, err, ErrInvalidAlias)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+)
+
+const maxRestrictionReasonLength = 500
+
+var (
+	ErrRestrictionsDisabled = errors.New("account restrictions are not configured")
+	ErrInvalidRestriction   = errors.New("invalid account restriction")
+	ErrRestrictionNotFound  = errors.New("restriction not found")
+)
+
+// RestrictionRepository stores the freezes and legal holds placed on accounts.
+// Postings are checked against active restrictions by the ledger repository
+// itself, inside the posting's transaction.
+type RestrictionRepository interface {
+	PlaceRestriction(restriction *model.AccountRestriction) error
+	LiftRestriction(id string, liftedBy uuid.UUID, note string, at time.Time) (*model.AccountRestriction, error)
+	GetRestriction(id string) (*model.AccountRestriction, error)
+	ListRestrictions(accountID string) ([]model.AccountRestriction, error)
+	ActiveRestrictions(accountIDs []string) ([]model.AccountRestriction, error)
+}
+
+// SetRestrictions enables admins to freeze accounts and place legal holds,
+// and shows active restrictions on the account API
+func (s *LedgerService) SetRestrictions(repo RestrictionRepository) {
+	s.restrictions = repo
+}
+
+// PlaceRestriction freezes an account or places a legal hold on it. reason is
+// shown to the account's owner, except for legal holds; note is for staff.
+func (s *LedgerService) PlaceRestriction(adminID, accountID string, restrictionType model.RestrictionType, reason, note string) (*model.AccountRestriction, error) {
+	if s.restrictions == nil {
+		return nil, ErrRestrictionsDisabled
+	}
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	switch restrictionType {
+	case model.RestrictionDebitFreeze, model.RestrictionFullFreeze, model.RestrictionLegalHold:
+	default:
+		return nil, ErrInvalidRestriction
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxRestrictionReasonLength {
+		return nil, ErrInvalidRestriction
+	}
+	accountUUID, err := uuid.Parse(accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	if _, err := s.Repo.GetAccount(accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+
+	restriction := &model.AccountRestriction{
+		AccountID: accountUUID,
+		Type:      restrictionType,
+		Reason:    reason,
+		Note:      strings.TrimSpace(note),
+		PlacedBy:  adminUUID,
+	}
+	if err := s.restrictions.PlaceRestriction(restriction); err != nil {
+		return nil, err
+	}
+	s.invalidateAccounts([]string{accountID})
+	slog.Info("Account restricted", "account_id", accountID, "type", restrictionType, "restriction_id", restriction.ID, "placed_by", adminID)
+	return restriction, nil
+}
+
+// LiftRestriction ends a restriction on the account. The restriction is kept,
+// with who lifted it and why.
+func (s *LedgerService) LiftRestriction(adminID, accountID, restrictionID, note string) (*model.AccountRestriction, error) {
+	if s.restrictions == nil {
+		return nil, ErrRestrictionsDisabled
+	}
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if _, err := uuid.Parse(restrictionID); err != nil {
+		return nil, ErrRestrictionNotFound
+	}
+	restriction, err := s.restrictions.GetRestriction(restrictionID)
+	if err != nil || restriction.AccountID.String() != accountID {
+		return nil, ErrRestrictionNotFound
+	}
+
+	lifted, err := s.restrictions.LiftRestriction(restrictionID, adminUUID, strings.TrimSpace(note), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateAccounts([]string{accountID})
+	slog.Info("Account restriction lifted", "account_id", accountID, "type", lifted.Type, "restriction_id", restrictionID, "lifted_by", adminID)
+	return lifted, nil
+}
+
+// ListRestrictions returns every restriction placed on an account, newest first
+func (s *LedgerService) ListRestrictions(accountID string) ([]model.AccountRestriction, error) {
+	if s.restrictions == nil {
+		return nil, ErrRestrictionsDisabled
+	}
+	if _, err := uuid.Parse(accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	if _, err := s.Repo.GetAccount(accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	return s.restrictions.ListRestrictions(accountID)
+}
+
+// withRestrictions fills in the active restrictions of each account. They
+// are read on every request rather than cached with the account list, so a
+// freeze shows up straight away. The list is still returned if the lookup
+// fails, since postings are checked regardless.
+func (s *LedgerService) withRestrictions(accounts []model.Account) []model.Account {
+	if s.restrictions == nil || len(accounts) == 0 {
+		return accounts
+	}
+	ids := make([]string, len(accounts))
+	for i := range accounts {
+		ids[i] = accounts[i].ID.String()
+	}
+	active, err := s.restrictions.ActiveRestrictions(ids)
+	if err != nil {
+		slog.Warn("Failed to load account restrictions", "error", err)
+		return accounts
+	}
+
+	notices := make(map[uuid.UUID][]model.RestrictionNotice)
+	for i := range active {
+		notices[active[i].AccountID] = append(notices[active[i].AccountID], active[i].Notice())
+	}
+	for i := range accounts {
+		accounts[i].Restrictions = notices[accounts[i].ID]
+	}
+	return accounts
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryRestrictions is an in-memory RestrictionRepository
+type memoryRestrictions struct {
+	items []model.AccountRestriction
+	fail  bool
+}
+
+func (m *memoryRestrictions) PlaceRestriction(r *model.AccountRestriction) error {
+	for _, existing := range m.items {
+		if existing.AccountID == r.AccountID && existing.Type == r.Type && existing.Active() {
+			return repository.ErrRestrictionExists
+		}
+	}
+	r.ID = uuid.New()
+	r.CreatedAt = time.Now()
+	m.items = append(m.items, *r)
+	return nil
+}
+
+func (m *memoryRestrictions) LiftRestriction(id string, liftedBy uuid.UUID, note string, at time.Time) (*model.AccountRestriction, error) {
+	for i := range m.items {
+		if r := &m.items[i]; r.ID.String() == id {
+			if !r.Active() {
+				return nil, repository.ErrRestrictionNotActive
+			}
+			r.LiftedBy, r.LiftedAt, r.LiftNote = &liftedBy, &at, note
+			lifted := *r
+			return &lifted, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryRestrictions) GetRestriction(id string) (*model.AccountRestriction, error) {
+	for _, r := range m.items {
+		if r.ID.String() == id {
+			return &r, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryRestrictions) ListRestrictions(accountID string) ([]model.AccountRestriction, error) {
+	var out []model.AccountRestriction
+	for i := len(m.items) - 1; i >= 0; i-- {
+		if m.items[i].AccountID.String() == accountID {
+			out = append(out, m.items[i])
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryRestrictions) ActiveRestrictions(accountIDs []string) ([]model.AccountRestriction, error) {
+	if m.fail {
+		return nil, errors.New("database unavailable")
+	}
+	var out []model.AccountRestriction
+	for _, r := range m.items {
+		for _, id := range accountIDs {
+			if r.AccountID.String() == id && r.Active() {
+				out = append(out, r)
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestCheckRestrictions(t *testing.T) {
+	account, other := uuid.New(), uuid.New()
+	out := []model.Posting{{AccountID: account, Direction: -1}, {AccountID: other, Direction: 1}}
+	in := []model.Posting{{AccountID: other, Direction: -1}, {AccountID: account, Direction: 1}}
+	restriction := func(t model.RestrictionType) model.AccountRestriction {
+		return model.AccountRestriction{AccountID: account, Type: t, Reason: "Suspected fraud"}
+	}
+
+	// A debit freeze stops money leaving the account but still lets it arrive
+	debits := []model.AccountRestriction{restriction(model.RestrictionDebitFreeze)}
+	assert.NoError(t, model.CheckRestrictions(account, debits, in))
+	var restricted *model.RestrictionError
+	require.ErrorAs(t, model.CheckRestrictions(account, debits, out), &restricted)
+	assert.Equal(t, model.RestrictionDebitFreeze, restricted.Type)
+	assert.Equal(t, "Suspected fraud", restricted.Notice.Reason)
+
+	// A full freeze stops both, and wins over the other restrictions
+	all := []model.AccountRestriction{restriction(model.RestrictionDebitFreeze), restriction(model.RestrictionLegalHold), restriction(model.RestrictionFullFreeze)}
+	require.ErrorAs(t, model.CheckRestrictions(account, all, in), &restricted)
+	assert.Equal(t, model.RestrictionFullFreeze, restricted.Type)
+	require.ErrorAs(t, model.CheckRestrictions(account, all, out), &restricted)
+	assert.Equal(t, model.RestrictionFullFreeze, restricted.Type)
+
+	// A legal hold is reported ahead of a debit freeze, without its reason
+	require.ErrorAs(t, model.CheckRestrictions(account, all[:2], out), &restricted)
+	assert.Equal(t, model.RestrictionLegalHold, restricted.Type)
+	assert.Equal(t, "ACCOUNT_LEGAL_HOLD", restricted.Notice.Code)
+	assert.NotContains(t, restricted.Notice.Reason, "fraud")
+
+	// Lifted restrictions and restrictions on other accounts are ignored
+	now := time.Now()
+	lifted := restriction(model.RestrictionFullFreeze)
+	lifted.LiftedAt = &now
+	assert.NoError(t, model.CheckRestrictions(account, []model.AccountRestriction{lifted}, out))
+	assert.NoError(t, model.CheckRestrictions(other, all, in))
+}
+
+func TestPlaceAndLiftRestriction(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	adminID, accountID := uuid.New().String(), uuid.New()
+	repo.On("GetAccount", accountID.String()).Return(&model.Account{ID: accountID}, nil)
+
+	_, err := svc.PlaceRestriction(adminID, accountID.String(), model.RestrictionDebitFreeze, "Fraud review", "")
+	assert.ErrorIs(t, err, ErrRestrictionsDisabled)
+
+	restrictions := &memoryRestrictions{}
+	svc.SetRestrictions(restrictions)
+
+	_, err = svc.PlaceRestriction(adminID, accountID.String(), "PARTIAL_FREEZE", "Fraud review", "")
+	assert.ErrorIs(t, err, ErrInvalidRestriction)
+	_, err = svc.PlaceRestriction(adminID, accountID.String(), model.RestrictionDebitFreeze, "  ", "")
+	assert.ErrorIs(t, err, ErrInvalidRestriction)
+	repo.On("GetAccount", "00000000-0000-0000-0000-000000000001").Return(nil, gorm.ErrRecordNotFound)
+	_, err = svc.PlaceRestriction(adminID, "00000000-0000-0000-0000-000000000001", model.RestrictionDebitFreeze, "Fraud review", "")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	placed, err := svc.PlaceRestriction(adminID, accountID.String(), model.RestrictionDebitFreeze, " Fraud review ", "Case 118")
+	require.NoError(t, err)
+	assert.Equal(t, "Fraud review", placed.Reason)
+	assert.Equal(t, adminID, placed.PlacedBy.String())
+
+	// Only one restriction of each type can be active at a time
+	_, err = svc.PlaceRestriction(adminID, accountID.String(), model.RestrictionDebitFreeze, "Again", "")
+	assert.ErrorIs(t, err, repository.ErrRestrictionExists)
+
+	// A restriction can only be lifted through the account it was placed on
+	_, err = svc.LiftRestriction(adminID, uuid.New().String(), placed.ID.String(), "Cleared")
+	assert.ErrorIs(t, err, ErrRestrictionNotFound)
+
+	lifted, err := svc.LiftRestriction(adminID, accountID.String(), placed.ID.String(), "Cleared")
+	require.NoError(t, err)
+	require.NotNil(t, lifted.LiftedAt)
+	assert.Equal(t, "Cleared", lifted.LiftNote)
+	_, err = svc.LiftRestriction(adminID, accountID.String(), placed.ID.String(), "Cleared")
+	assert.ErrorIs(t, err, repository.ErrRestrictionNotActive)
+
+	// Lifted restrictions stay on the account's history
+	history, err := svc.ListRestrictions(accountID.String())
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.False(t, history[0].Active())
+}
+
+func TestGetAccountBalance_ShowsRestrictions(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	accountID, userID := uuid.New(), uuid.New()
+	repo.On("GetAccount", accountID.String()).Return(&model.Account{ID: accountID, UserID: userID}, nil)
+
+	restrictions := &memoryRestrictions{}
+	svc.SetRestrictions(restrictions)
+	adminID := uuid.New().String()
+	_, err := svc.PlaceRestriction(adminID, accountID.String(), model.RestrictionDebitFreeze, "Unusual activity", "")
+	require.NoError(t, err)
+	_, err = svc.PlaceRestriction(adminID, accountID.String(), model.RestrictionLegalHold, "Court order 2024/118", "")
+	require.NoError(t, err)
+
+	acc, err := svc.GetAccountBalance(userID.String(), "", accountID.String())
+	require.NoError(t, err)
+	require.Len(t, acc.Restrictions, 2)
+	assert.Equal(t, "ACCOUNT_DEBITS_FROZEN", acc.Restrictions[0].Code)
+	assert.Equal(t, "Unusual activity", acc.Restrictions[0].Reason)
+	assert.Equal(t, "ACCOUNT_LEGAL_HOLD", acc.Restrictions[1].Code)
+	assert.NotContains(t, acc.Restrictions[1].Reason, "Court order")
+
+	// The account is still served when restrictions cannot be loaded
+	restrictions.fail = true
+	acc, err = svc.GetAccountBalance(userID.String(), "", accountID.String())
+	require.NoError(t, err)
+	assert.Empty(t, acc.Restrictions)
+}
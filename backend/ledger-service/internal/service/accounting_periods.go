@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// periodCloseDelay is how long after a month ends it may be closed, so
+// postings still committing at midnight are in its summaries
+const periodCloseDelay = time.Hour
+
+var (
+	ErrPeriodsDisabled = errors.New("accounting periods are not configured")
+	ErrInvalidPeriod   = errors.New("period must be a month, e.g. 2026-03")
+	ErrPeriodNotEnded  = errors.New("accounting period has not ended yet")
+	ErrPeriodNotClosed = errors.New("accounting period is not closed")
+	// ErrInvalidAdjustment is returned for an adjustment that does not
+	// reference a closed period
+	ErrInvalidAdjustment = errors.New("adjustments must reference a closed accounting period")
+)
+
+// PeriodRepository stores closed accounting periods and their summaries.
+// Entries dated in a closed period are refused by the ledger repository
+// itself, inside the posting's transaction.
+type PeriodRepository interface {
+	ListAccountingPeriods() ([]model.AccountingPeriod, error)
+	GetAccountingPeriod(period string) (*model.AccountingPeriod, error)
+	LatestClosedPeriod() (*model.AccountingPeriod, error)
+	ListPeriodSummaries(period string) ([]model.AccountingPeriodSummary, error)
+	// CloseAccountingPeriod closes p, which must follow the latest closed
+	// period, and generates its account summaries
+	CloseAccountingPeriod(p *model.AccountingPeriod) ([]model.AccountingPeriodSummary, error)
+}
+
+// SetPeriods enables admins to close accounting periods
+func (s *LedgerService) SetPeriods(repo PeriodRepository) {
+	s.periods = repo
+}
+
+// PeriodReport is the period-end report of a closed period: its totals per
+// currency and each account's summary
+type PeriodReport struct {
+	Period   model.AccountingPeriod          `json:"period"`
+	Totals   []PeriodTotal                   `json:"totals"`
+	Accounts []model.AccountingPeriodSummary `json:"accounts"`
+}
+
+// PeriodTotal sums the account summaries of one currency
+type PeriodTotal struct {
+	CurrencyCode   string          `json:"currency_code"`
+	Accounts       int             `json:"accounts"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	Debits         decimal.Decimal `json:"debits"`
+	Credits        decimal.Decimal `json:"credits"`
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	Postings       int64           `json:"postings"`
+}
+
+// ClosePeriod closes a month of the books, refusing entries dated within it
+// from then on, and returns its period-end report. Months close in order once
+// they have ended; the first one closed also closes every month before it.
+func (s *LedgerService) ClosePeriod(adminID, period string) (*PeriodReport, error) {
+	if s.periods == nil {
+		return nil, ErrPeriodsDisabled
+	}
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	start, end, err := model.PeriodBounds(period)
+	if err != nil {
+		return nil, ErrInvalidPeriod
+	}
+	now := time.Now()
+	if now.Before(end.Add(periodCloseDelay)) {
+		return nil, ErrPeriodNotEnded
+	}
+
+	p := &model.AccountingPeriod{Period: period, StartsAt: start, EndsAt: end, ClosedBy: adminUUID, ClosedAt: now}
+	summaries, err := s.periods.CloseAccountingPeriod(p)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Accounting period closed", "period", period, "accounts", len(summaries), "closed_by", adminID)
+	return newPeriodReport(p, summaries), nil
+}
+
+// ListPeriods returns the closed periods, newest first
+func (s *LedgerService) ListPeriods() ([]model.AccountingPeriod, error) {
+	if s.periods == nil {
+		return nil, ErrPeriodsDisabled
+	}
+	return s.periods.ListAccountingPeriods()
+}
+
+// GetPeriodReport returns the period-end report generated when a period was closed
+func (s *LedgerService) GetPeriodReport(period string) (*PeriodReport, error) {
+	p, err := s.closedPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := s.periods.ListPeriodSummaries(period)
+	if err != nil {
+		return nil, err
+	}
+	return newPeriodReport(p, summaries), nil
+}
+
+// PostAdjustment books a correction to a closed period. It is dated in the
+// open period, like any other entry, and references the period it corrects.
+func (s *LedgerService) PostAdjustment(period, desc string, postings []PostingRequest) (*model.JournalEntry, error) {
+	p, err := s.closedPeriod(period)
+	if errors.Is(err, ErrInvalidPeriod) || errors.Is(err, ErrPeriodNotClosed) {
+		return nil, ErrInvalidAdjustment
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.postEntry(&model.JournalEntry{Description: desc, Status: model.StatusPosted, AdjustsPeriod: &p.Period}, postings)
+}
+
+func (s *LedgerService) closedPeriod(period string) (*model.AccountingPeriod, error) {
+	if s.periods == nil {
+		return nil, ErrPeriodsDisabled
+	}
+	if _, _, err := model.PeriodBounds(period); err != nil {
+		return nil, ErrInvalidPeriod
+	}
+	p, err := s.periods.GetAccountingPeriod(period)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPeriodNotClosed
+	}
+	return p, err
+}
+
+// closedThrough is the end of the latest closed period, or zero if none is
+func (s *LedgerService) closedThrough() (time.Time, error) {
+	if s.periods == nil {
+		return time.Time{}, nil
+	}
+	latest, err := s.periods.LatestClosedPeriod()
+	if err != nil || latest == nil {
+		return time.Time{}, err
+	}
+	return latest.EndsAt, nil
+}
+
+func newPeriodReport(p *model.AccountingPeriod, summaries []model.AccountingPeriodSummary) *PeriodReport {
+	report := &PeriodReport{Period: *p, Totals: []PeriodTotal{}, Accounts: summaries}
+	byCurrency := map[string]int{}
+	for _, summary := range summaries {
+		i, ok := byCurrency[summary.CurrencyCode]
+		if !ok {
+			i = len(report.Totals)
+			byCurrency[summary.CurrencyCode] = i
+			report.Totals = append(report.Totals, PeriodTotal{CurrencyCode: summary.CurrencyCode})
+		}
+		total := &report.Totals[i]
+		total.Accounts++
+		total.OpeningBalance = total.OpeningBalance.Add(summary.OpeningBalance)
+		total.Debits = total.Debits.Add(summary.Debits)
+		total.Credits = total.Credits.Add(summary.Credits)
+		total.ClosingBalance = total.ClosingBalance.Add(summary.ClosingBalance)
+		total.Postings += summary.Postings
+	}
+	if report.Accounts == nil {
+		report.Accounts = []model.AccountingPeriodSummary{}
+	}
+	return report
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryPeriods is an in-memory PeriodRepository. Closing a period generates
+// the summaries in next.
+type memoryPeriods struct {
+	periods   []model.AccountingPeriod
+	summaries map[string][]model.AccountingPeriodSummary
+	next      []model.AccountingPeriodSummary
+}
+
+func (m *memoryPeriods) ListAccountingPeriods() ([]model.AccountingPeriod, error) {
+	var out []model.AccountingPeriod
+	for i := len(m.periods) - 1; i >= 0; i-- {
+		out = append(out, m.periods[i])
+	}
+	return out, nil
+}
+
+func (m *memoryPeriods) GetAccountingPeriod(period string) (*model.AccountingPeriod, error) {
+	for _, p := range m.periods {
+		if p.Period == period {
+			return &p, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *memoryPeriods) LatestClosedPeriod() (*model.AccountingPeriod, error) {
+	if len(m.periods) == 0 {
+		return nil, nil
+	}
+	return &m.periods[len(m.periods)-1], nil
+}
+
+func (m *memoryPeriods) ListPeriodSummaries(period string) ([]model.AccountingPeriodSummary, error) {
+	return m.summaries[period], nil
+}
+
+func (m *memoryPeriods) CloseAccountingPeriod(p *model.AccountingPeriod) ([]model.AccountingPeriodSummary, error) {
+	if latest, _ := m.LatestClosedPeriod(); latest != nil {
+		if !latest.EndsAt.Before(p.EndsAt) {
+			return nil, repository.ErrPeriodAlreadyClosed
+		}
+		if !latest.EndsAt.Equal(p.StartsAt) {
+			return nil, repository.ErrPeriodNotNext
+		}
+	}
+	m.periods = append(m.periods, *p)
+	if m.summaries == nil {
+		m.summaries = map[string][]model.AccountingPeriodSummary{}
+	}
+	m.summaries[p.Period] = m.next
+	return m.next, nil
+}
+
+func summary(currency, opening, debits, credits string, postings int64) model.AccountingPeriodSummary {
+	s := model.AccountingPeriodSummary{
+		AccountID:      uuid.New(),
+		CurrencyCode:   currency,
+		OpeningBalance: decimal.RequireFromString(opening),
+		Debits:         decimal.RequireFromString(debits),
+		Credits:        decimal.RequireFromString(credits),
+		Postings:       postings,
+	}
+	s.ClosingBalance = s.OpeningBalance.Add(s.Debits).Sub(s.Credits)
+	return s
+}
+
+func TestClosePeriod(t *testing.T) {
+	periods := &memoryPeriods{next: []model.AccountingPeriodSummary{
+		summary("GBP", "100", "50", "20", 3),
+		summary("EUR", "10", "0", "5", 1),
+		summary("GBP", "-40", "20", "50", 2),
+	}}
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetPeriods(periods)
+	admin := uuid.NewString()
+	now := time.Now().UTC()
+
+	_, err := svc.ClosePeriod(admin, "2026-3")
+	assert.ErrorIs(t, err, ErrInvalidPeriod)
+	_, err = svc.ClosePeriod(admin, model.PeriodOf(now))
+	assert.ErrorIs(t, err, ErrPeriodNotEnded, "the current month is still open")
+
+	earlier := model.PeriodOf(now.AddDate(0, -3, 0))
+	report, err := svc.ClosePeriod(admin, earlier)
+	require.NoError(t, err)
+	assert.Equal(t, earlier, report.Period.Period)
+	assert.Equal(t, admin, report.Period.ClosedBy.String())
+	assert.Len(t, report.Accounts, 3)
+	require.Len(t, report.Totals, 2)
+	gbp := report.Totals[0]
+	assert.Equal(t, "GBP", gbp.CurrencyCode)
+	assert.Equal(t, 2, gbp.Accounts)
+	assert.Equal(t, "60", gbp.OpeningBalance.String())
+	assert.Equal(t, "70", gbp.Debits.String())
+	assert.Equal(t, "70", gbp.Credits.String())
+	assert.Equal(t, "60", gbp.ClosingBalance.String())
+	assert.Equal(t, int64(5), gbp.Postings)
+
+	_, err = svc.ClosePeriod(admin, earlier)
+	assert.ErrorIs(t, err, repository.ErrPeriodAlreadyClosed)
+	_, err = svc.ClosePeriod(admin, model.PeriodOf(now.AddDate(0, -1, 0)))
+	assert.ErrorIs(t, err, repository.ErrPeriodNotNext, "periods close in order")
+
+	stored, err := svc.GetPeriodReport(earlier)
+	require.NoError(t, err)
+	assert.Equal(t, report.Totals, stored.Totals)
+	_, err = svc.GetPeriodReport(model.PeriodOf(now.AddDate(0, -2, 0)))
+	assert.ErrorIs(t, err, ErrPeriodNotClosed)
+}
+
+func TestPostAdjustment(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	repo.On("PostTransaction", mock.AnythingOfType("*model.JournalEntry")).Return(nil)
+	svc := NewLedgerService(repo)
+	postings := []PostingRequest{
+		{AccountID: uuid.NewString(), Amount: "12.50", Direction: 1},
+		{AccountID: uuid.NewString(), Amount: "12.50", Direction: -1},
+	}
+
+	_, err := svc.PostAdjustment("2026-03", "Correct March fees", postings)
+	assert.ErrorIs(t, err, ErrPeriodsDisabled)
+
+	start, end, _ := model.PeriodBounds("2026-03")
+	svc.SetPeriods(&memoryPeriods{periods: []model.AccountingPeriod{{Period: "2026-03", StartsAt: start, EndsAt: end}}})
+	_, err = svc.PostAdjustment("2026-04", "Correct April fees", postings)
+	assert.ErrorIs(t, err, ErrInvalidAdjustment, "open periods need no adjustment")
+	_, err = svc.PostAdjustment("March", "Correct March fees", postings)
+	assert.ErrorIs(t, err, ErrInvalidAdjustment)
+
+	entry, err := svc.PostAdjustment("2026-03", "Correct March fees", postings)
+	require.NoError(t, err)
+	require.NotNil(t, entry.AdjustsPeriod)
+	assert.Equal(t, "2026-03", *entry.AdjustsPeriod)
+	assert.True(t, entry.TransactionDate.After(end), "adjustments are dated in the open period")
+}
+
+func TestValidateImport_ClosedPeriod(t *testing.T) {
+	f := newImportFixture(t)
+	start, end, _ := model.PeriodBounds("2024-02")
+	f.svc.SetPeriods(&memoryPeriods{periods: []model.AccountingPeriod{{Period: "2024-02", StartsAt: start, EndsAt: end}}})
+
+	report, err := f.svc.ValidateImport(f.request("csv", "date,amount\n2024-02-29,5\n2024-03-01,5\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.ValidRows)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 2, report.Errors[0].Row)
+	assert.Contains(t, report.Errors[0].Message, "closed through 2024-02")
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrAccrualsDisabled = errors.New("accruals are not configured")
+	ErrInvalidAccrual   = errors.New("accrual must have a kind of INTEREST or FEE, a direction of 1 or -1, a positive amount with at most 8 decimal places, a date that is not in the future and a reference of at most 100 characters")
+)
+
+// AccrualRepository stores the accrual sub-ledger
+type AccrualRepository interface {
+	CreateAccrual(accrual *model.Accrual) (bool, error)
+	GetAccrualByReference(reference string) (*model.Accrual, error)
+	ListAccruals(accountID string, from, to time.Time) ([]model.Accrual, error)
+	ListRolledUpAccruals(accountID, entryID string) ([]model.Accrual, error)
+	ListPendingAccruals(before time.Time) ([]model.Accrual, error)
+	RollUpAccruals(entry *model.JournalEntry, accrualIDs []uuid.UUID) error
+}
+
+// SetAccruals enables the accrual sub-ledger: interest and fees too small to
+// post daily accrue there and are rolled up into the journal once a month
+func (s *LedgerService) SetAccruals(repo AccrualRepository) {
+	s.accruals = repo
+}
+
+// AccrualRequest is an amount of interest or a fee to accrue to an account
+type AccrualRequest struct {
+	AccountID             string
+	CounterpartyAccountID string
+	Kind                  string
+	Direction             int
+	Amount                decimal.Decimal
+	Date                  time.Time
+	Description           string
+	// Reference makes the accrual idempotent, e.g. "savings-interest:<account>:2026-03-02"
+	Reference string
+}
+
+// Accrue stores an accrual, to be posted with the rest of the account's
+// accruals when its month is rolled up. Both accounts must exist and share a
+// currency. An accrual already stored with the same reference is returned as
+// it was, with created false.
+func (s *LedgerService) Accrue(req AccrualRequest, now time.Time) (accrual *model.Accrual, created bool, err error) {
+	if s.accruals == nil {
+		return nil, false, ErrAccrualsDisabled
+	}
+	date := req.Date.UTC().Truncate(24 * time.Hour)
+	if (req.Kind != model.AccrualInterest && req.Kind != model.AccrualFee) ||
+		(req.Direction != 1 && req.Direction != -1) ||
+		!req.Amount.IsPositive() || !req.Amount.Equal(req.Amount.Truncate(model.AccrualPlaces)) ||
+		date.After(now.UTC()) || req.Reference == "" || len(req.Reference) > 100 {
+		return nil, false, ErrInvalidAccrual
+	}
+	if _, err := uuid.Parse(req.AccountID); err != nil {
+		return nil, false, ErrAccountNotFound
+	}
+	if _, err := uuid.Parse(req.CounterpartyAccountID); err != nil || req.CounterpartyAccountID == req.AccountID {
+		return nil, false, fmt.Errorf("%w: invalid counterparty account", ErrInvalidAccrual)
+	}
+	acc, err := s.Repo.GetAccount(req.AccountID)
+	if err != nil {
+		return nil, false, ErrAccountNotFound
+	}
+	counterparty, err := s.Repo.GetAccount(req.CounterpartyAccountID)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: counterparty account not found", ErrInvalidAccrual)
+	}
+	if counterparty.CurrencyCode != acc.CurrencyCode {
+		return nil, false, fmt.Errorf("%w: the counterparty account is in %s, not %s", ErrInvalidAccrual, counterparty.CurrencyCode, acc.CurrencyCode)
+	}
+
+	accrual = &model.Accrual{
+		AccountID:             acc.ID,
+		CounterpartyAccountID: counterparty.ID,
+		Kind:                  req.Kind,
+		Direction:             req.Direction,
+		Amount:                req.Amount,
+		AccrualDate:           date,
+		Description:           req.Description,
+		Reference:             req.Reference,
+	}
+	created, err = s.accruals.CreateAccrual(accrual)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to store accrual: %w", err)
+	}
+	if !created {
+		accrual, err = s.accruals.GetAccrualByReference(req.Reference)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read accrual: %w", err)
+		}
+	}
+	return accrual, created, nil
+}
+
+// ListAccruals returns the accruals behind one of the user's accounts:
+// those rolled up by a journal entry when entryID is set, and otherwise those
+// dated from from to to, which are validated as for a statement.
+func (s *LedgerService) ListAccruals(userID, orgID, accountID, from, to, entryID string) ([]model.Accrual, error) {
+	if s.accruals == nil {
+		return nil, ErrAccrualsDisabled
+	}
+	if _, err := s.GetAccountBalance(userID, orgID, accountID); err != nil {
+		return nil, err
+	}
+	if entryID != "" {
+		if _, err := uuid.Parse(entryID); err != nil {
+			return nil, ErrTransactionNotFound
+		}
+		return s.accruals.ListRolledUpAccruals(accountID, entryID)
+	}
+
+	start, err := time.Parse(statementDateLayout, from)
+	if err != nil {
+		return nil, ErrInvalidStatementRange
+	}
+	last, err := time.Parse(statementDateLayout, to)
+	if err != nil || last.Before(start) || last.Sub(start) >= MaxStatementDays*24*time.Hour {
+		return nil, ErrInvalidStatementRange
+	}
+	return s.accruals.ListAccruals(accountID, start, last.AddDate(0, 0, 1))
+}
+
+// AccrualRollupJob rolls up the accruals of months that have ended
+func (s *LedgerService) AccrualRollupJob(ctx context.Context, _ *jobs.Job) error {
+	rolledUp, err := s.RollUpAccruals(time.Now())
+	if rolledUp > 0 {
+		slog.Info("Accruals rolled up", "accounts", rolledUp)
+	}
+	return err
+}
+
+// RollUpAccruals posts each account's accruals dated before the month of
+// now (UTC) that have not been rolled up yet, in one entry per account: the
+// accruals are summed per counterparty account and each sum is rounded to
+// the currency once, so amounts too small to post on their own still count.
+// The account's posting is the net of those sums. Accruals that round away
+// to nothing are marked rolled up without an entry. It returns how many
+// accounts were rolled up; an account that fails is left for the next run
+// and does not stop the others.
+func (s *LedgerService) RollUpAccruals(now time.Time) (int, error) {
+	if s.accruals == nil {
+		return 0, ErrAccrualsDisabled
+	}
+	utc := now.UTC()
+	monthStart := time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC)
+	accruals, err := s.accruals.ListPendingAccruals(monthStart)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list accruals: %w", err)
+	}
+
+	var order []uuid.UUID
+	byAccount := make(map[uuid.UUID][]model.Accrual)
+	for _, a := range accruals {
+		if _, seen := byAccount[a.AccountID]; !seen {
+			order = append(order, a.AccountID)
+		}
+		byAccount[a.AccountID] = append(byAccount[a.AccountID], a)
+	}
+
+	rolledUp := 0
+	var errs []error
+	for _, accountID := range order {
+		if err := s.rollUpAccount(accountID, byAccount[accountID], monthStart, now); err != nil {
+			errs = append(errs, fmt.Errorf("failed to roll up accruals of account %s: %w", accountID, err))
+			continue
+		}
+		rolledUp++
+	}
+	return rolledUp, errors.Join(errs...)
+}
+
+// rollUpAccount posts one account's accruals in a single entry
+func (s *LedgerService) rollUpAccount(accountID uuid.UUID, items []model.Accrual, monthStart, now time.Time) error {
+	acc, err := s.Repo.GetAccount(accountID.String())
+	if err != nil {
+		return err
+	}
+	exponent, err := money.Exponent(acc.CurrencyCode)
+	if err != nil {
+		return err
+	}
+
+	// What each counterparty pays the account, negative when it is paid
+	ids := make([]uuid.UUID, len(items))
+	sums := make(map[uuid.UUID]decimal.Decimal)
+	for i, a := range items {
+		ids[i] = a.ID
+		sums[a.CounterpartyAccountID] = sums[a.CounterpartyAccountID].Add(a.Amount.Mul(decimal.NewFromInt(int64(a.Direction))))
+	}
+	counterparties := make([]uuid.UUID, 0, len(sums))
+	for id := range sums {
+		counterparties = append(counterparties, id)
+	}
+	sort.Slice(counterparties, func(i, j int) bool { return counterparties[i].String() < counterparties[j].String() })
+
+	var postings []model.Posting
+	net := decimal.Zero
+	for _, id := range counterparties {
+		amount := sums[id].Round(int32(exponent))
+		if amount.IsZero() {
+			continue
+		}
+		net = net.Add(amount)
+		postings = append(postings, model.Posting{AccountID: id, Amount: amount.Abs(), Direction: -amount.Sign()})
+	}
+	if !net.IsZero() {
+		postings = append(postings, model.Posting{AccountID: accountID, Amount: net.Abs(), Direction: net.Sign()})
+	}
+	if len(postings) == 0 {
+		return s.accruals.RollUpAccruals(nil, ids)
+	}
+
+	entry := &model.JournalEntry{
+		TransactionDate: now,
+		Description:     "Interest and fees to " + items[len(items)-1].AccrualDate.Format(statementDateLayout),
+		ReferenceID:     "accruals:" + accountID.String() + ":" + monthStart.Format("2006-01"),
+		Status:          model.StatusPosted,
+		Postings:        postings,
+		// Fees may take an account past its overdraft limit
+		OverLimitAllowed: true,
+	}
+	if err := s.accruals.RollUpAccruals(entry, ids); err != nil {
+		return err
+	}
+	s.Posted(entry)
+	return nil
+}
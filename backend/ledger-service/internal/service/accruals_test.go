@@ -0,0 +1,221 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAccruals is an in-memory AccrualRepository
+type memoryAccruals struct {
+	accruals []model.Accrual
+	entries  []*model.JournalEntry
+}
+
+func (m *memoryAccruals) CreateAccrual(accrual *model.Accrual) (bool, error) {
+	for _, a := range m.accruals {
+		if a.Reference == accrual.Reference {
+			return false, nil
+		}
+	}
+	accrual.ID = uuid.New()
+	m.accruals = append(m.accruals, *accrual)
+	return true, nil
+}
+
+func (m *memoryAccruals) GetAccrualByReference(reference string) (*model.Accrual, error) {
+	for _, a := range m.accruals {
+		if a.Reference == reference {
+			return &a, nil
+		}
+	}
+	return nil, ErrTransactionNotFound
+}
+
+func (m *memoryAccruals) ListAccruals(accountID string, from, to time.Time) ([]model.Accrual, error) {
+	var out []model.Accrual
+	for _, a := range m.accruals {
+		if a.AccountID.String() == accountID && !a.AccrualDate.Before(from) && a.AccrualDate.Before(to) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryAccruals) ListRolledUpAccruals(accountID, entryID string) ([]model.Accrual, error) {
+	var out []model.Accrual
+	for _, a := range m.accruals {
+		if a.AccountID.String() == accountID && a.RollupEntryID != nil && a.RollupEntryID.String() == entryID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryAccruals) ListPendingAccruals(before time.Time) ([]model.Accrual, error) {
+	var out []model.Accrual
+	for _, a := range m.accruals {
+		if a.RolledUpAt == nil && a.AccrualDate.Before(before) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryAccruals) RollUpAccruals(entry *model.JournalEntry, accrualIDs []uuid.UUID) error {
+	now := time.Now()
+	var entryID *uuid.UUID
+	if entry != nil {
+		entry.ID = uuid.New()
+		entryID = &entry.ID
+		m.entries = append(m.entries, entry)
+	}
+	for i := range m.accruals {
+		for _, id := range accrualIDs {
+			if m.accruals[i].ID == id {
+				if m.accruals[i].RolledUpAt != nil {
+					return repository.ErrAccrualsRolledUp
+				}
+				m.accruals[i].RollupEntryID, m.accruals[i].RolledUpAt = entryID, &now
+			}
+		}
+	}
+	return nil
+}
+
+func accrualAccount(repo *MockLedgerRepo, currency string) *model.Account {
+	acc := &model.Account{ID: uuid.New(), UserID: uuid.New(), CurrencyCode: currency, Status: model.AccountActive}
+	repo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+	return acc
+}
+
+func TestAccrue(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	customer := accrualAccount(repo, "GBP")
+	income := accrualAccount(repo, "GBP")
+	dollars := accrualAccount(repo, "USD")
+	repo.On("GetAccount", mock.Anything).Return(nil, ErrAccountNotFound)
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	req := AccrualRequest{
+		AccountID: customer.ID.String(), CounterpartyAccountID: income.ID.String(),
+		Kind: model.AccrualFee, Direction: -1, Amount: decimal.RequireFromString("0.00123456"),
+		Date: now, Reference: "card-fx-fee:1",
+	}
+
+	_, _, err := svc.Accrue(req, now)
+	assert.ErrorIs(t, err, ErrAccrualsDisabled)
+	accruals := &memoryAccruals{}
+	svc.SetAccruals(accruals)
+
+	accrual, created, err := svc.Accrue(req, now)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), accrual.AccrualDate)
+
+	// The same reference returns what was stored
+	again := req
+	again.Amount = decimal.NewFromInt(5)
+	stored, created, err := svc.Accrue(again, now)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, accrual.ID, stored.ID)
+	assert.Len(t, accruals.accruals, 1)
+
+	for name, mutate := range map[string]func(r *AccrualRequest){
+		"kind":         func(r *AccrualRequest) { r.Kind = "BONUS" },
+		"direction":    func(r *AccrualRequest) { r.Direction = 0 },
+		"precision":    func(r *AccrualRequest) { r.Amount = decimal.RequireFromString("0.000000001") },
+		"negative":     func(r *AccrualRequest) { r.Amount = decimal.NewFromInt(-1) },
+		"future":       func(r *AccrualRequest) { r.Date = now.AddDate(0, 0, 1) },
+		"reference":    func(r *AccrualRequest) { r.Reference = "" },
+		"same account": func(r *AccrualRequest) { r.CounterpartyAccountID = r.AccountID },
+		"currency":     func(r *AccrualRequest) { r.CounterpartyAccountID = dollars.ID.String() },
+		"counterparty": func(r *AccrualRequest) { r.CounterpartyAccountID = uuid.NewString() },
+	} {
+		invalid := req
+		invalid.Reference = "invalid:" + name
+		mutate(&invalid)
+		_, _, err := svc.Accrue(invalid, now)
+		assert.ErrorIs(t, err, ErrInvalidAccrual, name)
+	}
+	invalid := req
+	invalid.AccountID = uuid.NewString()
+	_, _, err = svc.Accrue(invalid, now)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestRollUpAccruals(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	accruals := &memoryAccruals{}
+	svc.SetAccruals(accruals)
+	saver := accrualAccount(repo, "GBP")
+	expense := accrualAccount(repo, "GBP")
+	income := accrualAccount(repo, "GBP")
+	tiny := accrualAccount(repo, "GBP")
+	accrue := func(acc, counterparty *model.Account, kind string, direction int, amount string, day int) {
+		date := time.Date(2026, 2, day, 0, 0, 0, 0, time.UTC)
+		_, _, err := svc.Accrue(AccrualRequest{
+			AccountID: acc.ID.String(), CounterpartyAccountID: counterparty.ID.String(),
+			Kind: kind, Direction: direction, Amount: decimal.RequireFromString(amount), Date: date,
+			Reference: uuid.NewString(),
+		}, date)
+		require.NoError(t, err)
+	}
+
+	// 28 days of 0.00273973 interest round to 0.08 only once they are summed
+	for day := 1; day <= 28; day++ {
+		accrue(saver, expense, model.AccrualInterest, 1, "0.00273973", day)
+	}
+	accrue(saver, income, model.AccrualFee, -1, "0.015", 28)
+	accrue(tiny, expense, model.AccrualInterest, 1, "0.001", 3)
+
+	// Nothing is rolled up before the month has ended
+	rolledUp, err := svc.RollUpAccruals(time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, rolledUp)
+
+	rolledUp, err = svc.RollUpAccruals(time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 2, rolledUp)
+	require.Len(t, accruals.entries, 1, "an account whose accruals round to nothing gets no entry")
+
+	entry := accruals.entries[0]
+	assert.Equal(t, "accruals:"+saver.ID.String()+":2026-03", entry.ReferenceID)
+	assert.True(t, entry.OverLimitAllowed)
+	legs := map[uuid.UUID]string{}
+	for _, p := range entry.Postings {
+		legs[p.AccountID] = p.Amount.Mul(decimal.NewFromInt(int64(p.Direction))).String()
+	}
+	assert.Equal(t, map[uuid.UUID]string{
+		expense.ID: "-0.08",
+		income.ID:  "0.02",
+		saver.ID:   "0.06",
+	}, legs, "one entry per account, netted per counterparty")
+
+	// Drill down from the entry to the accruals behind it
+	items, err := svc.ListAccruals(saver.UserID.String(), "", saver.ID.String(), "", "", entry.ID.String())
+	require.NoError(t, err)
+	assert.Len(t, items, 29)
+	items, err = svc.ListAccruals(saver.UserID.String(), "", saver.ID.String(), "2026-02-01", "2026-02-07", "")
+	require.NoError(t, err)
+	assert.Len(t, items, 7)
+	_, err = svc.ListAccruals(uuid.NewString(), "", saver.ID.String(), "2026-02-01", "2026-02-07", "")
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	for _, a := range accruals.accruals {
+		assert.NotNil(t, a.RolledUpAt)
+	}
+
+	// A second run has nothing left to roll up
+	rolledUp, err = svc.RollUpAccruals(time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Zero(t, rolledUp)
+}
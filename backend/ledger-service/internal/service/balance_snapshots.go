@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// SnapshotGracePeriod is how long after midnight (UTC) the day's snapshot
+	// waits, so entries that were being committed at midnight are included
+	SnapshotGracePeriod = 15 * time.Minute
+	// MaxStatementDays bounds the period of a single statement
+	MaxStatementDays = 366
+
+	statementDateLayout = "2006-01-02"
+)
+
+var (
+	ErrStatementsDisabled    = errors.New("balance snapshots are not enabled")
+	ErrInvalidStatementRange = errors.New("statement period must be YYYY-MM-DD dates, from on or before to, at most 366 days")
+)
+
+// SnapshotRepository stores end-of-day balances and reads booked postings around them
+type SnapshotRepository interface {
+	LatestSnapshot(accountID string, at time.Time) (*model.BalanceSnapshot, error)
+	SumBookedPostings(accountID string, from, to time.Time) (decimal.Decimal, error)
+	ListBookedPostings(accountID string, from, to time.Time) ([]model.StatementLine, error)
+	CreateSnapshots(date, closingAt time.Time) (int64, error)
+}
+
+// SetSnapshots enables daily balance snapshots, as-of balances and statements
+func (s *LedgerService) SetSnapshots(repo SnapshotRepository) {
+	s.snapshots = repo
+}
+
+// HistoricalBalance is an account's booked balance at a point in time
+type HistoricalBalance struct {
+	AccountID string          `json:"account_id"`
+	Currency  string          `json:"currency"`
+	Balance   decimal.Decimal `json:"balance"`
+	AsOf      time.Time       `json:"as_of"`
+}
+
+// Statement lists an account's booked postings for a period of whole UTC days
+type Statement struct {
+	AccountID      string                `json:"account_id"`
+	Currency       string                `json:"currency"`
+	From           string                `json:"from"`
+	To             string                `json:"to"`
+	OpeningBalance decimal.Decimal       `json:"opening_balance"`
+	ClosingBalance decimal.Decimal       `json:"closing_balance"`
+	Lines          []model.StatementLine `json:"lines"`
+}
+
+// BalanceAsOf returns the booked balance of the caller's account at the given
+// time; orgID is as for GetAccountBalance
+func (s *LedgerService) BalanceAsOf(userID, orgID, accountID string, at time.Time) (*HistoricalBalance, error) {
+	if s.snapshots == nil {
+		return nil, ErrStatementsDisabled
+	}
+	acc, err := s.GetAccountBalance(userID, orgID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := s.balanceAt(accountID, at)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoricalBalance{AccountID: accountID, Currency: acc.CurrencyCode, Balance: balance, AsOf: at}, nil
+}
+
+// Statement returns the booked postings of the caller's account from the start
+// of from to the end of to (inclusive, UTC), with running balances
+func (s *LedgerService) Statement(userID, orgID, accountID, from, to string) (*Statement, error) {
+	if s.snapshots == nil {
+		return nil, ErrStatementsDisabled
+	}
+	start, err := time.Parse(statementDateLayout, from)
+	if err != nil {
+		return nil, ErrInvalidStatementRange
+	}
+	last, err := time.Parse(statementDateLayout, to)
+	if err != nil || last.Before(start) || last.Sub(start) >= MaxStatementDays*24*time.Hour {
+		return nil, ErrInvalidStatementRange
+	}
+	end := last.AddDate(0, 0, 1)
+
+	acc, err := s.GetAccountBalance(userID, orgID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	opening, err := s.balanceAt(accountID, start)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.snapshots.ListBookedPostings(accountID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if lines == nil {
+		lines = []model.StatementLine{}
+	}
+
+	balance := opening
+	for i := range lines {
+		balance = balance.Add(lines[i].Amount)
+		lines[i].Balance = balance
+	}
+
+	return &Statement{
+		AccountID:      accountID,
+		Currency:       acc.CurrencyCode,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: balance,
+		Lines:          lines,
+	}, nil
+}
+
+// balanceAt is the latest snapshot at or before at plus the postings booked since
+func (s *LedgerService) balanceAt(accountID string, at time.Time) (decimal.Decimal, error) {
+	snapshot, err := s.snapshots.LatestSnapshot(accountID, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	base, since := decimal.Zero, time.Time{}
+	if snapshot != nil {
+		base, since = snapshot.Balance, snapshot.ClosingAt
+	}
+	delta, err := s.snapshots.SumBookedPostings(accountID, since, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return base.Add(delta), nil
+}
+
+// SnapshotLastClosedDay stores end-of-day balances for the most recent UTC day
+// that ended at least SnapshotGracePeriod before now
+func (s *LedgerService) SnapshotLastClosedDay(now time.Time) (int64, error) {
+	if s.snapshots == nil {
+		return 0, ErrStatementsDisabled
+	}
+	closingAt := now.UTC().Add(-SnapshotGracePeriod).Truncate(24 * time.Hour)
+	created, err := s.snapshots.CreateSnapshots(closingAt.AddDate(0, 0, -1), closingAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot balances: %w", err)
+	}
+	return created, nil
+}
+
+// SnapshotJob takes the daily balance snapshot. It is scheduled well inside a
+// day, and snapshots that already exist are skipped, so runs after the day's
+// snapshot only cost one cheap query.
+func (s *LedgerService) SnapshotJob(ctx context.Context, _ *jobs.Job) error {
+	created, err := s.SnapshotLastClosedDay(time.Now())
+	if err != nil {
+		return err
+	}
+	if created > 0 {
+		slog.Info("Stored daily balance snapshots", "accounts", created)
+	}
+	return nil
+}
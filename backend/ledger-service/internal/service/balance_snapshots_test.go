@@ -0,0 +1,137 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSnapshotRepo is a mock implementation of SnapshotRepository
+type MockSnapshotRepo struct {
+	mock.Mock
+}
+
+func (m *MockSnapshotRepo) LatestSnapshot(accountID string, at time.Time) (*model.BalanceSnapshot, error) {
+	args := m.Called(accountID, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.BalanceSnapshot), args.Error(1)
+}
+
+func (m *MockSnapshotRepo) SumBookedPostings(accountID string, from, to time.Time) (decimal.Decimal, error) {
+	args := m.Called(accountID, from, to)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func (m *MockSnapshotRepo) ListBookedPostings(accountID string, from, to time.Time) ([]model.StatementLine, error) {
+	args := m.Called(accountID, from, to)
+	return args.Get(0).([]model.StatementLine), args.Error(1)
+}
+
+func (m *MockSnapshotRepo) CreateSnapshots(date, closingAt time.Time) (int64, error) {
+	args := m.Called(date, closingAt)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func newSnapshotService(t *testing.T) (*LedgerService, *MockLedgerRepo, *MockSnapshotRepo, *model.Account) {
+	t.Helper()
+	ledgerRepo := new(MockLedgerRepo)
+	snapshots := new(MockSnapshotRepo)
+	acc := &model.Account{ID: uuid.New(), UserID: uuid.New(), CurrencyCode: "USD"}
+	ledgerRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+
+	svc := NewLedgerService(ledgerRepo)
+	svc.SetSnapshots(snapshots)
+	return svc, ledgerRepo, snapshots, acc
+}
+
+func TestBalanceAsOf_AddsPostingsSinceSnapshot(t *testing.T) {
+	svc, _, snapshots, acc := newSnapshotService(t)
+	closing := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	at := closing.Add(15 * time.Hour)
+
+	snapshots.On("LatestSnapshot", acc.ID.String(), at).
+		Return(&model.BalanceSnapshot{AccountID: acc.ID, ClosingAt: closing, Balance: decimal.NewFromInt(500)}, nil)
+	snapshots.On("SumBookedPostings", acc.ID.String(), closing, at).Return(decimal.NewFromInt(-120), nil)
+
+	balance, err := svc.BalanceAsOf(acc.UserID.String(), "", acc.ID.String(), at)
+	require.NoError(t, err)
+	assert.Equal(t, "380", balance.Balance.String())
+	assert.Equal(t, "USD", balance.Currency)
+}
+
+func TestBalanceAsOf_WithoutSnapshotSumsAllPostings(t *testing.T) {
+	svc, _, snapshots, acc := newSnapshotService(t)
+	at := time.Now()
+
+	snapshots.On("LatestSnapshot", acc.ID.String(), at).Return(nil, nil)
+	snapshots.On("SumBookedPostings", acc.ID.String(), time.Time{}, at).Return(decimal.NewFromInt(75), nil)
+
+	balance, err := svc.BalanceAsOf(acc.UserID.String(), "", acc.ID.String(), at)
+	require.NoError(t, err)
+	assert.Equal(t, "75", balance.Balance.String())
+}
+
+func TestBalanceAsOf_OtherUsersAccount(t *testing.T) {
+	svc, _, _, acc := newSnapshotService(t)
+	_, err := svc.BalanceAsOf(uuid.New().String(), "", acc.ID.String(), time.Now())
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestStatement_RunningBalances(t *testing.T) {
+	svc, _, snapshots, acc := newSnapshotService(t)
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshots.On("LatestSnapshot", acc.ID.String(), from).
+		Return(&model.BalanceSnapshot{ClosingAt: from, Balance: decimal.NewFromInt(100)}, nil)
+	snapshots.On("SumBookedPostings", acc.ID.String(), from, from).Return(decimal.Zero, nil)
+	snapshots.On("ListBookedPostings", acc.ID.String(), from, end).Return([]model.StatementLine{
+		{Description: "Salary", Amount: decimal.NewFromInt(1000)},
+		{Description: "Rent", Amount: decimal.NewFromInt(-700)},
+	}, nil)
+
+	statement, err := svc.Statement(acc.UserID.String(), "", acc.ID.String(), "2025-03-01", "2025-03-31")
+	require.NoError(t, err)
+	assert.Equal(t, "100", statement.OpeningBalance.String())
+	require.Len(t, statement.Lines, 2)
+	assert.Equal(t, "1100", statement.Lines[0].Balance.String())
+	assert.Equal(t, "400", statement.Lines[1].Balance.String())
+	assert.Equal(t, "400", statement.ClosingBalance.String())
+}
+
+func TestStatement_InvalidRange(t *testing.T) {
+	svc, _, _, acc := newSnapshotService(t)
+	for _, tt := range [][2]string{
+		{"2025-03-31", "2025-03-01"},
+		{"2025-03", "2025-03-31"},
+		{"2024-01-01", "2025-01-01"},
+	} {
+		_, err := svc.Statement(acc.UserID.String(), "", acc.ID.String(), tt[0], tt[1])
+		assert.ErrorIs(t, err, ErrInvalidStatementRange, "%s..%s", tt[0], tt[1])
+	}
+}
+
+func TestSnapshotLastClosedDay(t *testing.T) {
+	svc, _, snapshots, _ := newSnapshotService(t)
+	midnight := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)
+	snapshots.On("CreateSnapshots", midnight.AddDate(0, 0, -1), midnight).Return(int64(3), nil).Once()
+
+	created, err := svc.SnapshotLastClosedDay(midnight.Add(SnapshotGracePeriod + time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), created)
+
+	// Within the grace period the day before is still the last closed one
+	prev := midnight.AddDate(0, 0, -1)
+	snapshots.On("CreateSnapshots", prev.AddDate(0, 0, -1), prev).Return(int64(0), nil).Once()
+	_, err = svc.SnapshotLastClosedDay(midnight.Add(time.Minute))
+	require.NoError(t, err)
+	snapshots.AssertExpectations(t)
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultMaxStreamsPerUser caps the open balance streams of one user
+	DefaultMaxStreamsPerUser = 5
+	// DefaultStreamBuffer is how many updates may queue for a slow subscriber
+	// before it is dropped
+	DefaultStreamBuffer = 32
+)
+
+var (
+	ErrStreamingDisabled = errors.New("balance streaming is not available")
+	ErrTooManyStreams    = errors.New("too many open balance streams")
+)
+
+// BalanceUpdate is pushed to an account's subscribers when a journal entry
+// touching the account is posted or changes status
+type BalanceUpdate struct {
+	AccountID        string          `json:"account_id"`
+	EntryID          string          `json:"entry_id"`
+	Status           string          `json:"status"`
+	Description      string          `json:"description,omitempty"`
+	Amount           decimal.Decimal `json:"amount"`
+	Direction        int             `json:"direction"`
+	Balance          decimal.Decimal `json:"balance"`
+	HeldBalance      decimal.Decimal `json:"held_balance"`
+	AvailableBalance decimal.Decimal `json:"available_balance"`
+	Currency         string          `json:"currency"`
+	Timestamp        string          `json:"timestamp"`
+}
+
+// BalanceSubscription receives the updates of one account. Updates is closed
+// when the subscription is closed or dropped for falling behind.
+type BalanceSubscription struct {
+	UserID    string
+	AccountID string
+	Updates   <-chan BalanceUpdate
+
+	updates chan BalanceUpdate
+	stream  *BalanceStream
+	dropped bool // guarded by stream.mu
+	closed  bool // guarded by stream.mu
+}
+
+// Dropped reports whether the subscription was closed because the subscriber
+// did not keep up. The client should reload the balance and subscribe again.
+func (s *BalanceSubscription) Dropped() bool {
+	s.stream.mu.Lock()
+	defer s.stream.mu.Unlock()
+	return s.dropped
+}
+
+// Close unsubscribes; it is safe to call more than once
+func (s *BalanceSubscription) Close() {
+	s.stream.mu.Lock()
+	defer s.stream.mu.Unlock()
+	s.stream.remove(s)
+}
+
+// BalanceStream fans balance updates out to the subscribers connected to this
+// replica. Publishing never blocks: a subscriber whose buffer is full is dropped.
+type BalanceStream struct {
+	maxPerUser int
+	buffer     int
+
+	mu      sync.Mutex
+	subs    map[string]map[*BalanceSubscription]struct{} // by account ID
+	perUser map[string]int
+}
+
+// NewBalanceStream creates a stream hub; zero limits use the defaults
+func NewBalanceStream(maxPerUser, buffer int) *BalanceStream {
+	if maxPerUser <= 0 {
+		maxPerUser = DefaultMaxStreamsPerUser
+	}
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+	return &BalanceStream{
+		maxPerUser: maxPerUser,
+		buffer:     buffer,
+		subs:       make(map[string]map[*BalanceSubscription]struct{}),
+		perUser:    make(map[string]int),
+	}
+}
+
+// Subscribe registers a subscriber for the account's updates
+func (b *BalanceStream) Subscribe(userID, accountID string) (*BalanceSubscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.perUser[userID] >= b.maxPerUser {
+		return nil, ErrTooManyStreams
+	}
+	ch := make(chan BalanceUpdate, b.buffer)
+	sub := &BalanceSubscription{UserID: userID, AccountID: accountID, Updates: ch, updates: ch, stream: b}
+	if b.subs[accountID] == nil {
+		b.subs[accountID] = make(map[*BalanceSubscription]struct{})
+	}
+	b.subs[accountID][sub] = struct{}{}
+	b.perUser[userID]++
+	return sub, nil
+}
+
+// HasSubscribers reports whether anyone on this replica follows the account
+func (b *BalanceStream) HasSubscribers(accountID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[accountID]) > 0
+}
+
+// Publish delivers the update to the account's subscribers
+func (b *BalanceStream) Publish(update BalanceUpdate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs[update.AccountID] {
+		select {
+		case sub.updates <- update:
+		default:
+			slog.Warn("Dropping slow balance stream subscriber", "user_id", sub.UserID, "account_id", sub.AccountID)
+			sub.dropped = true
+			b.remove(sub)
+		}
+	}
+}
+
+// remove unregisters the subscription and closes its channel; b.mu must be held
+func (b *BalanceStream) remove(sub *BalanceSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.updates)
+
+	delete(b.subs[sub.AccountID], sub)
+	if len(b.subs[sub.AccountID]) == 0 {
+		delete(b.subs, sub.AccountID)
+	}
+	if b.perUser[sub.UserID]--; b.perUser[sub.UserID] <= 0 {
+		delete(b.perUser, sub.UserID)
+	}
+}
+
+// SetBalanceStream enables streaming of balance updates through stream
+func (s *LedgerService) SetBalanceStream(stream *BalanceStream) {
+	s.stream = stream
+}
+
+// SubscribeBalance opens a stream of updates for an account visible to the
+// caller, and returns the account as it is now. orgID is as for GetAccountBalance.
+func (s *LedgerService) SubscribeBalance(userID, orgID, accountID string) (*model.Account, *BalanceSubscription, error) {
+	if s.stream == nil {
+		return nil, nil, ErrStreamingDisabled
+	}
+	acc, err := s.GetAccountBalance(userID, orgID, accountID)
+	if err != nil {
+		return nil, nil, err
+	}
+	sub, err := s.stream.Subscribe(userID, acc.ID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	return acc, sub, nil
+}
+
+// HandleJournalEvent pushes a journal event to the subscribers of the
+// accounts it touches, with the balances read after the entry
+func (s *LedgerService) HandleJournalEvent(event kafka.JournalEvent) {
+	if s.stream == nil {
+		return
+	}
+	for _, p := range event.Postings {
+		if !s.stream.HasSubscribers(p.AccountID) {
+			continue
+		}
+		acc, err := s.Repo.GetAccount(p.AccountID)
+		if err != nil {
+			slog.Warn("Skipping balance update for unknown account", "account_id", p.AccountID, "error", err)
+			continue
+		}
+		amount, _ := decimal.NewFromString(p.Amount)
+		s.stream.Publish(BalanceUpdate{
+			AccountID:        p.AccountID,
+			EntryID:          event.EntryID,
+			Status:           event.Status,
+			Description:      event.Description,
+			Amount:           amount,
+			Direction:        p.Direction,
+			Balance:          acc.CachedBalance,
+			HeldBalance:      acc.HeldBalance,
+			AvailableBalance: acc.AvailableBalance(),
+			Currency:         acc.CurrencyCode,
+			Timestamp:        event.Timestamp,
+		})
+	}
+}
+
+// publishJournal emits a journal.posted event for the entry
+func (s *LedgerService) publishJournal(entry *model.JournalEntry) {
+	if s.producer == nil || s.afterCommit(func(s *LedgerService) { s.publishJournal(entry) }) {
+		return
+	}
+
+	postings := make([]kafka.JournalPostingEvent, len(entry.Postings))
+	for i, p := range entry.Postings {
+		postings[i] = kafka.JournalPostingEvent{
+			AccountID: p.AccountID.String(),
+			Amount:    p.Amount.String(),
+			Direction: p.Direction,
+		}
+	}
+	event := kafka.JournalEvent{
+		EntryID:     entry.ID.String(),
+		Status:      string(entry.Status),
+		Description: entry.Description,
+		Postings:    postings,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	if err := s.producer.Produce(context.Background(), kafka.TopicJournalPosted, event.EntryID, event); err != nil {
+		slog.Error("Failed to publish journal event", "entry_id", event.EntryID, "error", err)
+	}
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeBalance_DeliversJournalEvents(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	svc := NewLedgerService(mockRepo)
+	svc.SetBalanceStream(NewBalanceStream(0, 0))
+
+	owner := uuid.New()
+	acc := &model.Account{ID: uuid.New(), UserID: owner, CurrencyCode: "USD", CachedBalance: decimal.NewFromInt(100)}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+
+	_, _, err := svc.SubscribeBalance(uuid.New().String(), "", acc.ID.String())
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	_, sub, err := svc.SubscribeBalance(owner.String(), "", acc.ID.String())
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// Only postings on followed accounts are looked up and pushed
+	svc.HandleJournalEvent(kafka.JournalEvent{
+		EntryID: "entry-1",
+		Status:  string(model.StatusPosted),
+		Postings: []kafka.JournalPostingEvent{
+			{AccountID: uuid.NewString(), Amount: "25", Direction: -1},
+			{AccountID: acc.ID.String(), Amount: "25", Direction: 1},
+		},
+	})
+
+	require.Len(t, sub.Updates, 1)
+	update := <-sub.Updates
+	assert.Equal(t, "entry-1", update.EntryID)
+	assert.Equal(t, "25", update.Amount.String())
+	assert.Equal(t, "100", update.Balance.String())
+	assert.Equal(t, "USD", update.Currency)
+	mockRepo.AssertNumberOfCalls(t, "GetAccount", 3)
+}
+
+func TestBalanceStream_LimitsStreamsPerUser(t *testing.T) {
+	stream := NewBalanceStream(2, 0)
+	user := uuid.NewString()
+
+	first, err := stream.Subscribe(user, "acc-1")
+	require.NoError(t, err)
+	_, err = stream.Subscribe(user, "acc-2")
+	require.NoError(t, err)
+	_, err = stream.Subscribe(user, "acc-3")
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+
+	// Closing a stream frees its slot, and closing twice is harmless
+	first.Close()
+	first.Close()
+	_, err = stream.Subscribe(user, "acc-3")
+	assert.NoError(t, err)
+}
+
+func TestBalanceStream_DropsSlowSubscribers(t *testing.T) {
+	stream := NewBalanceStream(0, 2)
+	slow, err := stream.Subscribe("user", "acc")
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		stream.Publish(BalanceUpdate{AccountID: "acc"})
+	}
+
+	assert.True(t, slow.Dropped())
+	assert.False(t, stream.HasSubscribers("acc"))
+	// The buffered updates can still be drained before the channel reports closed
+	<-slow.Updates
+	<-slow.Updates
+	_, open := <-slow.Updates
+	assert.False(t, open)
+}
+
+func TestSubscribeBalance_DisabledWithoutStream(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+
+	_, _, err := svc.SubscribeBalance(uuid.NewString(), "", uuid.NewString())
+	assert.ErrorIs(t, err, ErrStreamingDisabled)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+)
+
+// MaxBatchEntries is the largest number of journal entries one batch may post
+const MaxBatchEntries = 500
+
+var (
+	ErrBatchEmpty    = errors.New("at least one transaction is required")
+	ErrBatchTooLarge = fmt.Errorf("at most %d transactions can be posted per batch", MaxBatchEntries)
+)
+
+// BatchEntryRequest is one journal entry of a batch
+type BatchEntryRequest struct {
+	Description string
+	Postings    []PostingRequest
+}
+
+// PostTransactionsBatch books up to MaxBatchEntries journal entries in one
+// database transaction, as PostTransaction books each, with bulk inserts
+// instead of a round trip per row. The batch is all or nothing: when an entry
+// is refused a *model.BatchEntryError gives its index, wrapping the error
+// PostTransaction would have returned, and none is booked.
+func (s *LedgerService) PostTransactionsBatch(requests []BatchEntryRequest) ([]*model.JournalEntry, error) {
+	entries := make([]*model.JournalEntry, len(requests))
+	postings := make([][]PostingRequest, len(requests))
+	for i, req := range requests {
+		entries[i] = &model.JournalEntry{Description: req.Description, Status: model.StatusPosted}
+		postings[i] = req.Postings
+	}
+	if err := s.writeBatch(s.Repo, entries, postings); err != nil {
+		return nil, err
+	}
+	s.PostedBatch(entries)
+	return entries, nil
+}
+
+// PostPaymentsBatchInTx posts payments from the payment service through repo,
+// as PostPaymentInTx posts each, in one batch. Once the caller's transaction
+// has committed, it must pass the entries to PostedBatch.
+func (s *LedgerService) PostPaymentsBatchInTx(repo LedgerRepository, events []kafka.PaymentEvent) ([]*model.JournalEntry, error) {
+	entries := make([]*model.JournalEntry, len(events))
+	postings := make([][]PostingRequest, len(events))
+	for i, event := range events {
+		entries[i] = &model.JournalEntry{Description: paymentDescription(event), Status: model.StatusPosted}
+		postings[i] = paymentPostings(event)
+	}
+	if err := s.writeBatch(repo, entries, postings); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PostedBatch is Posted for the entries of a committed batch; cached balances
+// are cleared once for all the accounts they touch
+func (s *LedgerService) PostedBatch(entries []*model.JournalEntry) {
+	seen := map[string]bool{}
+	var affectedAccounts []string
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			if id := p.AccountID.String(); !seen[id] {
+				seen[id] = true
+				affectedAccounts = append(affectedAccounts, id)
+			}
+		}
+	}
+	s.invalidateAccounts(affectedAccounts)
+	for _, entry := range entries {
+		s.categorizeEntry(entry)
+		s.publishJournal(entry)
+		s.notifyOverdraft(entry)
+	}
+}
+
+// writeBatch validates the postings of each entry and stores the entries
+// through repo
+func (s *LedgerService) writeBatch(repo LedgerRepository, entries []*model.JournalEntry, postings [][]PostingRequest) error {
+	if len(entries) == 0 {
+		return ErrBatchEmpty
+	}
+	if len(entries) > MaxBatchEntries {
+		return ErrBatchTooLarge
+	}
+	for i, entry := range entries {
+		if err := buildEntry(entry, postings[i]); err != nil {
+			return &model.BatchEntryError{Index: i, Err: err}
+		}
+	}
+	return repo.PostTransactionsBatch(entries)
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostTransactionsBatch(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	from := "00000000-0000-0000-0000-000000000001"
+	to := "00000000-0000-0000-0000-000000000002"
+
+	_, err := svc.PostTransactionsBatch(nil)
+	assert.ErrorIs(t, err, ErrBatchEmpty)
+	_, err = svc.PostTransactionsBatch(make([]BatchEntryRequest, MaxBatchEntries+1))
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+
+	// An invalid entry fails the batch before the repository sees it
+	_, err = svc.PostTransactionsBatch([]BatchEntryRequest{
+		{Description: "Rent", Postings: transferPostings(from, to, "950.00")},
+		{Description: "Broken", Postings: transferPostings(from, to, "ten")},
+	})
+	var batchErr *model.BatchEntryError
+	require.ErrorAs(t, err, &batchErr)
+	assert.Equal(t, 1, batchErr.Index)
+	assert.ErrorIs(t, err, model.ErrInvalidPostingAmount)
+	repo.AssertNotCalled(t, "PostTransactionsBatch", mock.Anything)
+
+	repo.On("PostTransactionsBatch", mock.AnythingOfType("[]*model.JournalEntry")).Return(nil).Once()
+	entries, err := svc.PostTransactionsBatch([]BatchEntryRequest{
+		{Description: "Rent", Postings: transferPostings(from, to, "950.00")},
+		{Description: "Gym", Postings: transferPostings(from, to, "30.00")},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Gym", entries[1].Description)
+	assert.Equal(t, model.StatusPosted, entries[1].Status)
+	assert.Equal(t, "30", entries[1].Postings[1].Amount.String())
+	repo.AssertExpectations(t)
+}
+
+func TestPostPaymentsBatchInTx(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	repo.On("PostTransactionsBatch", mock.AnythingOfType("[]*model.JournalEntry")).Return(nil).Once()
+
+	entries, err := svc.PostPaymentsBatchInTx(repo, []kafka.PaymentEvent{
+		{PaymentID: "p1", FromAccountID: "00000000-0000-0000-0000-000000000001", ToAccountID: "00000000-0000-0000-0000-000000000002", Amount: "12.50"},
+		{
+			PaymentID: "p2", FromAccountID: "00000000-0000-0000-0000-000000000001", ToAccountID: "00000000-0000-0000-0000-000000000002", Amount: "40.00",
+			Fee: "0.50", FeeAccountID: "00000000-0000-0000-0000-000000000003",
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Len(t, entries[0].Postings, 2)
+	assert.Len(t, entries[1].Postings, 4)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+// CategoryRepository stores posting categories and reports spend by category
+type CategoryRepository interface {
+	GetJournalEntry(id string) (*model.JournalEntry, error)
+	SaveCategories(categories []model.PostingCategory, override bool) error
+	SpendByCategory(userID string, from, to time.Time) ([]model.CategorySpend, error)
+}
+
+var (
+	ErrCategorizationDisabled = errors.New("transaction categorization is not enabled")
+	ErrTransactionNotFound    = errors.New("transaction not found")
+	ErrInvalidCategory        = errors.New("unknown category")
+	ErrInvalidMonth           = errors.New("month must be in YYYY-MM format")
+)
+
+// CategoryRule assigns a category when any keyword appears as whole words in
+// the transaction description. Keywords are lower case.
+type CategoryRule struct {
+	Category  model.Category
+	Merchant  string // Display name recorded when the rule matches; empty for generic rules
+	Keywords  []string
+	Direction int // 1 = money in only, -1 = money out only, 0 = either
+}
+
+// DefaultCategoryRules are evaluated in order; the first match wins, so
+// specific merchants come before the generic keywords that overlap them.
+var DefaultCategoryRules = []CategoryRule{
+	{Category: model.CategoryFees, Keywords: []string{"fee", "fees", "overdraft", "commission", "service charge"}},
+	{Category: model.CategorySalary, Direction: 1, Keywords: []string{"salary", "payroll", "wages", "paycheck"}},
+	{Category: model.CategoryDining, Merchant: "Uber Eats", Keywords: []string{"uber eats", "ubereats"}},
+	{Category: model.CategoryTransport, Merchant: "Uber", Keywords: []string{"uber"}},
+	{Category: model.CategoryGroceries, Merchant: "Tesco", Keywords: []string{"tesco"}},
+	{Category: model.CategoryGroceries, Merchant: "Sainsbury's", Keywords: []string{"sainsbury", "sainsburys"}},
+	{Category: model.CategoryGroceries, Merchant: "Waitrose", Keywords: []string{"waitrose"}},
+	{Category: model.CategoryGroceries, Merchant: "Aldi", Keywords: []string{"aldi"}},
+	{Category: model.CategoryGroceries, Merchant: "Lidl", Keywords: []string{"lidl"}},
+	{Category: model.CategoryGroceries, Merchant: "Whole Foods", Keywords: []string{"whole foods"}},
+	{Category: model.CategoryGroceries, Merchant: "Kroger", Keywords: []string{"kroger"}},
+	{Category: model.CategoryGroceries, Keywords: []string{"grocery", "groceries", "supermarket"}},
+	{Category: model.CategoryDining, Merchant: "Starbucks", Keywords: []string{"starbucks"}},
+	{Category: model.CategoryDining, Merchant: "McDonald's", Keywords: []string{"mcdonald", "mcdonalds"}},
+	{Category: model.CategoryDining, Merchant: "Deliveroo", Keywords: []string{"deliveroo"}},
+	{Category: model.CategoryDining, Keywords: []string{"restaurant", "cafe", "coffee", "takeaway"}},
+	{Category: model.CategoryTransport, Merchant: "Lyft", Keywords: []string{"lyft"}},
+	{Category: model.CategoryTransport, Merchant: "TfL", Keywords: []string{"tfl"}},
+	{Category: model.CategoryTransport, Keywords: []string{"fuel", "petrol", "parking", "train", "taxi"}},
+	{Category: model.CategoryBills, Merchant: "Netflix", Keywords: []string{"netflix"}},
+	{Category: model.CategoryBills, Merchant: "Spotify", Keywords: []string{"spotify"}},
+	{Category: model.CategoryBills, Keywords: []string{"rent", "electricity", "utilities", "water bill", "broadband", "council tax", "insurance"}},
+	{Category: model.CategoryShopping, Merchant: "Amazon", Keywords: []string{"amazon"}},
+	{Category: model.CategoryShopping, Merchant: "eBay", Keywords: []string{"ebay"}},
+	{Category: model.CategoryShopping, Merchant: "IKEA", Keywords: []string{"ikea"}},
+	{Category: model.CategoryTransfers, Keywords: []string{"payment", "transfer", "p2p", "standing order"}},
+}
+
+// Categorizer assigns categories to postings from their description
+type Categorizer struct {
+	rules []CategoryRule
+}
+
+// NewCategorizer creates a categorizer that evaluates rules in order
+func NewCategorizer(rules []CategoryRule) *Categorizer {
+	return &Categorizer{rules: rules}
+}
+
+// Categorize returns the category and merchant for one side of a transaction.
+// Unmatched money in is INCOME and unmatched money out is OTHER.
+func (c *Categorizer) Categorize(description string, direction int) (model.Category, string) {
+	text := " " + normalizeDescription(description) + " "
+	for _, rule := range c.rules {
+		if rule.Direction != 0 && rule.Direction != direction {
+			continue
+		}
+		for _, keyword := range rule.Keywords {
+			if strings.Contains(text, " "+keyword+" ") {
+				return rule.Category, rule.Merchant
+			}
+		}
+	}
+	if direction == 1 {
+		return model.CategoryIncome, ""
+	}
+	return model.CategoryOther, ""
+}
+
+// normalizeDescription lower-cases the text and turns punctuation into single
+// spaces so keywords match whole words, e.g. "TESCO*STORES" matches "tesco".
+func normalizeDescription(description string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// SetCategorization enables categorization of new transactions
+func (s *LedgerService) SetCategorization(repo CategoryRepository, categorizer *Categorizer) {
+	s.categories = repo
+	s.categorizer = categorizer
+}
+
+// categorizeEntry tags each posting of a new entry using the rules. Failures are
+// logged and do not affect the posted transaction.
+func (s *LedgerService) categorizeEntry(entry *model.JournalEntry) {
+	if s.categories == nil || s.categorizer == nil {
+		return
+	}
+
+	categories := make([]model.PostingCategory, len(entry.Postings))
+	for i, p := range entry.Postings {
+		category, merchant := s.categorizer.Categorize(entry.Description, p.Direction)
+		categories[i] = model.PostingCategory{
+			PostingID:      p.ID,
+			JournalEntryID: entry.ID,
+			AccountID:      p.AccountID,
+			Category:       category,
+			Source:         model.CategorySourceRule,
+			Merchant:       merchant,
+		}
+	}
+
+	if err := s.categories.SaveCategories(categories, false); err != nil {
+		slog.Error("Failed to categorize transaction", "transaction_id", entry.ID, "error", err)
+		return
+	}
+	s.publishCategorized(entry, categories)
+}
+
+// SetTransactionCategory overrides the category of the user's side of a transaction.
+// Transactions that do not touch the user's accounts are reported as not found.
+func (s *LedgerService) SetTransactionCategory(userID, entryID string, category model.Category) ([]model.PostingCategory, error) {
+	if s.categories == nil {
+		return nil, ErrCategorizationDisabled
+	}
+	if !category.IsValid() {
+		return nil, ErrInvalidCategory
+	}
+	if _, err := uuid.Parse(entryID); err != nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	entry, err := s.categories.GetJournalEntry(entryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []model.PostingCategory
+	for _, p := range entry.Postings {
+		acc, err := s.Repo.GetAccount(p.AccountID.String())
+		if err != nil || acc.UserID.String() != userID {
+			continue
+		}
+		var merchant string
+		if s.categorizer != nil {
+			_, merchant = s.categorizer.Categorize(entry.Description, p.Direction)
+		}
+		categories = append(categories, model.PostingCategory{
+			PostingID:      p.ID,
+			JournalEntryID: entry.ID,
+			AccountID:      p.AccountID,
+			Category:       category,
+			Source:         model.CategorySourceUser,
+			Merchant:       merchant,
+		})
+	}
+	if len(categories) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	if err := s.categories.SaveCategories(categories, true); err != nil {
+		return nil, err
+	}
+	s.publishCategorized(entry, categories)
+	return categories, nil
+}
+
+// SpendingReport is a user's outgoing spend for one calendar month (UTC)
+type SpendingReport struct {
+	Month      string                `json:"month"`
+	Categories []model.CategorySpend `json:"categories"`
+}
+
+// SpendingByCategory totals the user's booked outgoing transactions for month
+// (YYYY-MM) by category and currency, largest first
+func (s *LedgerService) SpendingByCategory(userID, month string) (*SpendingReport, error) {
+	if s.categories == nil {
+		return nil, ErrCategorizationDisabled
+	}
+	from, err := time.Parse("2006-01", month)
+	if err != nil {
+		return nil, ErrInvalidMonth
+	}
+
+	spend, err := s.categories.SpendByCategory(userID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+	if spend == nil {
+		spend = []model.CategorySpend{}
+	}
+	return &SpendingReport{Month: month, Categories: spend}, nil
+}
+
+// publishCategorized emits one transaction.categorized event per posting
+func (s *LedgerService) publishCategorized(entry *model.JournalEntry, categories []model.PostingCategory) {
+	if s.producer == nil || len(categories) == 0 || s.afterCommit(func(s *LedgerService) { s.publishCategorized(entry, categories) }) {
+		return
+	}
+
+	postings := make(map[uuid.UUID]model.Posting, len(entry.Postings))
+	for _, p := range entry.Postings {
+		postings[p.ID] = p
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	messages := make([]kafka.Message, 0, len(categories))
+	for _, c := range categories {
+		acc, err := s.Repo.GetAccount(c.AccountID.String())
+		if err != nil {
+			slog.Warn("Skipping categorization event for unknown account", "account_id", c.AccountID, "error", err)
+			continue
+		}
+		p := postings[c.PostingID]
+		messages = append(messages, kafka.Message{
+			Key: acc.UserID.String(),
+			Value: kafka.TransactionCategorizedEvent{
+				TransactionID: entry.ID.String(),
+				PostingID:     c.PostingID.String(),
+				AccountID:     c.AccountID.String(),
+				UserID:        acc.UserID.String(),
+				Category:      string(c.Category),
+				Source:        string(c.Source),
+				Merchant:      c.Merchant,
+				Amount:        p.Amount.String(),
+				Currency:      acc.CurrencyCode,
+				Direction:     p.Direction,
+				Timestamp:     now,
+			},
+		})
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.producer.ProduceBatch(ctx, kafka.TopicTransactionCategorized, messages); err != nil {
+		slog.Error("Failed to publish transaction.categorized events", "transaction_id", entry.ID, "count", len(messages), "error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCategoryRepo is a mock implementation of CategoryRepository
+type MockCategoryRepo struct {
+	mock.Mock
+}
+
+func (m *MockCategoryRepo) GetJournalEntry(id string) (*model.JournalEntry, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.JournalEntry), args.Error(1)
+}
+
+func (m *MockCategoryRepo) SaveCategories(categories []model.PostingCategory, override bool) error {
+	args := m.Called(categories, override)
+	return args.Error(0)
+}
+
+func (m *MockCategoryRepo) SpendByCategory(userID string, from, to time.Time) ([]model.CategorySpend, error) {
+	args := m.Called(userID, from, to)
+	return args.Get(0).([]model.CategorySpend), args.Error(1)
+}
+
+func TestCategorizer_DefaultRules(t *testing.T) {
+	c := NewCategorizer(DefaultCategoryRules)
+
+	tests := []struct {
+		description string
+		direction   int
+		category    model.Category
+		merchant    string
+	}{
+		{"TESCO*STORES 2231 LONDON", -1, model.CategoryGroceries, "Tesco"},
+		{"Card purchase: Sainsbury's Local", -1, model.CategoryGroceries, "Sainsbury's"},
+		{"Uber Eats order", -1, model.CategoryDining, "Uber Eats"},
+		{"UBER *TRIP", -1, model.CategoryTransport, "Uber"},
+		{"ACME Ltd salary October", 1, model.CategorySalary, ""},
+		{"Monthly account fee", -1, model.CategoryFees, ""},
+		{"Payment: dinner split", -1, model.CategoryTransfers, ""},
+		{"Payment: dinner split", 1, model.CategoryTransfers, ""},
+		{"Refund", 1, model.CategoryIncome, ""},
+		{"Something unusual", -1, model.CategoryOther, ""},
+		// Keywords match whole words only
+		{"Aldington garden centre", -1, model.CategoryOther, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			category, merchant := c.Categorize(tt.description, tt.direction)
+			assert.Equal(t, tt.category, category)
+			assert.Equal(t, tt.merchant, merchant)
+		})
+	}
+}
+
+func TestCategorizer_SalaryRuleOnlyMatchesMoneyIn(t *testing.T) {
+	c := NewCategorizer(DefaultCategoryRules)
+
+	category, _ := c.Categorize("Payment: salary advance", -1)
+	assert.Equal(t, model.CategoryTransfers, category)
+}
+
+func TestPostTransaction_CategorizesPostings(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	categories := new(MockCategoryRepo)
+	svc := NewLedgerService(repo)
+	svc.SetCategorization(categories, NewCategorizer(DefaultCategoryRules))
+
+	from, to := uuid.New(), uuid.New()
+	repo.On("PostTransaction", mock.AnythingOfType("*model.JournalEntry")).Return(nil)
+	categories.On("SaveCategories", mock.MatchedBy(func(c []model.PostingCategory) bool {
+		return len(c) == 2 &&
+			c[0].AccountID == from && c[0].Category == model.CategoryGroceries && c[0].Merchant == "Tesco" &&
+			c[1].AccountID == to && c[1].Category == model.CategoryGroceries &&
+			c[0].Source == model.CategorySourceRule
+	}), false).Return(nil)
+
+	_, err := svc.PostTransfer(from.String(), to.String(), "12.50", "Tesco Metro")
+
+	require.NoError(t, err)
+	categories.AssertExpectations(t)
+}
+
+func TestPostTransaction_SucceedsWhenCategorizationFails(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	categories := new(MockCategoryRepo)
+	svc := NewLedgerService(repo)
+	svc.SetCategorization(categories, NewCategorizer(DefaultCategoryRules))
+
+	repo.On("PostTransaction", mock.AnythingOfType("*model.JournalEntry")).Return(nil)
+	categories.On("SaveCategories", mock.Anything, false).Return(assert.AnError)
+
+	entry, err := svc.PostTransfer(uuid.New().String(), uuid.New().String(), "5", "Coffee")
+
+	require.NoError(t, err)
+	assert.NotNil(t, entry)
+}
+
+func TestSetTransactionCategory_OnlyChangesTheUsersSide(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	categories := new(MockCategoryRepo)
+	svc := NewLedgerService(repo)
+	svc.SetCategorization(categories, NewCategorizer(DefaultCategoryRules))
+
+	userID := uuid.New()
+	mine, theirs := uuid.New(), uuid.New()
+	entry := &model.JournalEntry{
+		ID:          uuid.New(),
+		Description: "Tesco",
+		Postings: []model.Posting{
+			{ID: uuid.New(), AccountID: mine, Amount: decimal.NewFromInt(10), Direction: -1},
+			{ID: uuid.New(), AccountID: theirs, Amount: decimal.NewFromInt(10), Direction: 1},
+		},
+	}
+	categories.On("GetJournalEntry", entry.ID.String()).Return(entry, nil)
+	repo.On("GetAccount", mine.String()).Return(&model.Account{ID: mine, UserID: userID}, nil)
+	repo.On("GetAccount", theirs.String()).Return(&model.Account{ID: theirs, UserID: uuid.New()}, nil)
+	categories.On("SaveCategories", mock.Anything, true).Return(nil)
+
+	result, err := svc.SetTransactionCategory(userID.String(), entry.ID.String(), model.CategoryShopping)
+
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, mine, result[0].AccountID)
+	assert.Equal(t, model.CategoryShopping, result[0].Category)
+	assert.Equal(t, model.CategorySourceUser, result[0].Source)
+	assert.Equal(t, "Tesco", result[0].Merchant)
+
+	// A user with no posting on the entry cannot see or change it
+	_, err = svc.SetTransactionCategory(uuid.New().String(), entry.ID.String(), model.CategoryShopping)
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+	categories.AssertNumberOfCalls(t, "SaveCategories", 1)
+}
+
+func TestSetTransactionCategory_ValidatesInput(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+	_, err := svc.SetTransactionCategory(uuid.New().String(), uuid.New().String(), model.CategoryFees)
+	assert.ErrorIs(t, err, ErrCategorizationDisabled)
+
+	svc.SetCategorization(new(MockCategoryRepo), NewCategorizer(DefaultCategoryRules))
+	_, err = svc.SetTransactionCategory(uuid.New().String(), uuid.New().String(), "HOLIDAYS")
+	assert.ErrorIs(t, err, ErrInvalidCategory)
+	_, err = svc.SetTransactionCategory(uuid.New().String(), "not-a-uuid", model.CategoryFees)
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+}
+
+func TestSpendingByCategory(t *testing.T) {
+	categories := new(MockCategoryRepo)
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetCategorization(categories, NewCategorizer(DefaultCategoryRules))
+
+	userID := uuid.New().String()
+	from := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	categories.On("SpendByCategory", userID, from, to).Return([]model.CategorySpend{
+		{Category: model.CategoryGroceries, Currency: "GBP", Total: decimal.NewFromInt(120), Count: 4},
+	}, nil)
+
+	report, err := svc.SpendingByCategory(userID, "2026-02")
+	require.NoError(t, err)
+	assert.Equal(t, "2026-02", report.Month)
+	require.Len(t, report.Categories, 1)
+	assert.Equal(t, model.CategoryGroceries, report.Categories[0].Category)
+
+	_, err = svc.SpendingByCategory(userID, "February")
+	assert.ErrorIs(t, err, ErrInvalidMonth)
+}
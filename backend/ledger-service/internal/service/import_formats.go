@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// CSV header names; date and amount are required
+const (
+	csvColumnDate        = "date"
+	csvColumnAmount      = "amount"
+	csvColumnDescription = "description"
+	csvColumnAccountID   = "account_id"
+	csvColumnReference   = "reference"
+)
+
+var (
+	ofxTransactionPattern = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+	// ofxOffsetPattern matches the timezone suffix of an OFX date, e.g. [-5:EST]
+	ofxOffsetPattern = regexp.MustCompile(`\[([+-]?\d+(?:\.\d+)?)(?::[^\]]*)?\]$`)
+	// ofxFieldPatterns match the elements read from a transaction. SGML OFX
+	// does not close elements, so a value runs to the next tag or line end.
+	ofxFieldPatterns = map[string]*regexp.Regexp{}
+)
+
+func init() {
+	for _, tag := range []string{"DTPOSTED", "TRNAMT", "FITID", "NAME", "MEMO"} {
+		ofxFieldPatterns[tag] = regexp.MustCompile(`(?i)<` + tag + `>([^<\r\n]*)`)
+	}
+}
+
+// DetectImportFormat returns the format named by format ("csv" or "ofx"), or
+// when it is empty, the one the file name or content indicates
+func DetectImportFormat(format, fileName string, data []byte) (model.ImportFormat, error) {
+	switch strings.ToUpper(format) {
+	case string(model.ImportFormatCSV):
+		return model.ImportFormatCSV, nil
+	case string(model.ImportFormatOFX):
+		return model.ImportFormatOFX, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: unknown format %q", ErrInvalidImportFile, format)
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return model.ImportFormatCSV, nil
+	case ".ofx", ".qfx":
+		return model.ImportFormatOFX, nil
+	}
+	if bytes.Contains(bytes.ToUpper(data), []byte("<OFX>")) {
+		return model.ImportFormatOFX, nil
+	}
+	return model.ImportFormatCSV, nil
+}
+
+// parseImport reads the rows of a file. Rows that cannot be read are
+// reported as errors; a file that cannot be read at all returns
+// ErrInvalidImportFile. Rows without an account_id get defaultAccount.
+func parseImport(format model.ImportFormat, data []byte, defaultAccount uuid.UUID) ([]model.ImportRow, []model.ImportError, error) {
+	switch format {
+	case model.ImportFormatCSV:
+		return parseImportCSV(data, defaultAccount)
+	case model.ImportFormatOFX:
+		return parseImportOFX(data, defaultAccount)
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown format %q", ErrInvalidImportFile, format)
+	}
+}
+
+// parseImportCSV reads a CSV file with a header row naming its columns: date
+// (YYYY-MM-DD or RFC 3339), a signed amount, and optionally description,
+// account_id and reference. Rows are numbered by their line in the file.
+func parseImportCSV(data []byte, defaultAccount uuid.UUID) ([]model.ImportRow, []model.ImportError, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: cannot read the CSV header: %v", ErrInvalidImportFile, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{csvColumnDate, csvColumnAmount} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("%w: the CSV header has no %s column", ErrInvalidImportFile, required)
+		}
+	}
+
+	var rows []model.ImportRow
+	var rowErrors []model.ImportError
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImportFile, err)
+			}
+			rowErrors = append(rowErrors, model.ImportError{Row: parseErr.Line, Message: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := model.ImportRow{Row: line, AccountID: defaultAccount, Description: field(csvColumnDescription), ExternalID: field(csvColumnReference)}
+		var problems []string
+		if row.Date, err = parseImportDate(field(csvColumnDate)); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if row.Amount, err = decimal.NewFromString(field(csvColumnAmount)); err != nil {
+			problems = append(problems, "amount must be a number")
+		}
+		if id := field(csvColumnAccountID); id != "" {
+			if row.AccountID, err = uuid.Parse(id); err != nil {
+				problems = append(problems, "account_id must be a UUID")
+			}
+		}
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, model.ImportError{Row: line, Message: strings.Join(problems, "; ")})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+func parseImportDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("date %q must be YYYY-MM-DD or RFC 3339", value)
+}
+
+// parseImportOFX reads the STMTTRN transactions of an OFX 1.x (SGML) or 2.x
+// (XML) statement. Every transaction goes to defaultAccount. Rows are
+// numbered by their position in the file.
+func parseImportOFX(data []byte, defaultAccount uuid.UUID) ([]model.ImportRow, []model.ImportError, error) {
+	blocks := ofxTransactionPattern.FindAllSubmatch(data, -1)
+	if len(blocks) == 0 && !bytes.Contains(bytes.ToUpper(data), []byte("<OFX>")) {
+		return nil, nil, fmt.Errorf("%w: not an OFX file", ErrInvalidImportFile)
+	}
+
+	var rows []model.ImportRow
+	var rowErrors []model.ImportError
+	for i, block := range blocks {
+		n := i + 1
+		fields := string(block[1])
+		row := model.ImportRow{Row: n, AccountID: defaultAccount, ExternalID: ofxField(fields, "FITID")}
+
+		var description []string
+		for _, tag := range []string{"NAME", "MEMO"} {
+			if v := ofxField(fields, tag); v != "" {
+				description = append(description, v)
+			}
+		}
+		row.Description = strings.Join(description, " - ")
+
+		var problems []string
+		var err error
+		if row.Date, err = parseOFXDate(ofxField(fields, "DTPOSTED")); err != nil {
+			problems = append(problems, err.Error())
+		}
+		if row.Amount, err = decimal.NewFromString(strings.ReplaceAll(ofxField(fields, "TRNAMT"), ",", ".")); err != nil {
+			problems = append(problems, "TRNAMT must be a number")
+		}
+		if len(problems) > 0 {
+			rowErrors = append(rowErrors, model.ImportError{Row: n, Message: strings.Join(problems, "; ")})
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// ofxField returns the value of an element of a transaction
+func ofxField(block, tag string) string {
+	m := ofxFieldPatterns[tag].FindStringSubmatch(block)
+	if m == nil {
+		return ""
+	}
+	return html.UnescapeString(strings.TrimSpace(m[1]))
+}
+
+// parseOFXDate reads YYYYMMDD[HHMMSS[.XXX]][offset:TZ], which is in GMT
+// unless an offset in hours is given
+func parseOFXDate(value string) (time.Time, error) {
+	invalid := fmt.Errorf("DTPOSTED %q is not an OFX date", value)
+	offset := 0.0
+	if m := ofxOffsetPattern.FindStringSubmatch(value); m != nil {
+		hours, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		offset = hours
+		value = strings.TrimSpace(strings.TrimSuffix(value, m[0]))
+	}
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		value = value[:dot]
+	}
+
+	var t time.Time
+	var err error
+	switch len(value) {
+	case 8:
+		t, err = time.Parse("20060102", value)
+	case 12:
+		t, err = time.Parse("200601021504", value)
+	case 14:
+		t, err = time.Parse("20060102150405", value)
+	default:
+		return time.Time{}, invalid
+	}
+	if err != nil {
+		return time.Time{}, invalid
+	}
+	return t.Add(-time.Duration(offset * float64(time.Hour))).UTC(), nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+)
+
+// journalAuditPageSize is how many audit records verification reads at a time
+const journalAuditPageSize = 1000
+
+var ErrJournalAuditDisabled = errors.New("journal audit is not enabled")
+
+// JournalAuditRepository reads the journal audit hash chain
+type JournalAuditRepository interface {
+	ListJournalAudit(afterSequence int64, limit int) ([]model.JournalAuditRecord, error)
+}
+
+// SetJournalAudit enables verification of the journal audit log. Records are
+// written by the repository alongside every entry and status change.
+func (s *LedgerService) SetJournalAudit(repo JournalAuditRepository) {
+	s.journalAudit = repo
+}
+
+// VerifyJournalAudit walks the audit log in order and recomputes every hash.
+// It stops at the first record that does not chain onto the one before it or
+// whose contents no longer match its hash.
+func (s *LedgerService) VerifyJournalAudit(ctx context.Context) (*model.JournalAuditVerification, error) {
+	if s.journalAudit == nil {
+		return nil, ErrJournalAuditDisabled
+	}
+
+	result := &model.JournalAuditVerification{Valid: true}
+	prevHash := model.GenesisHash
+	var after int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		records, err := s.journalAudit.ListJournalAudit(after, journalAuditPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read journal audit log: %w", err)
+		}
+
+		for i := range records {
+			r := &records[i]
+			switch {
+			case r.PrevHash != prevHash:
+				result.Reason = "record does not chain onto the previous record"
+			case r.ComputeHash() != r.Hash:
+				result.Reason = "record contents do not match its hash"
+			}
+			if result.Reason != "" {
+				result.Valid = false
+				result.BrokenSequence = r.Sequence
+				slog.Error("Journal audit chain is broken", "sequence", r.Sequence, "journal_entry_id", r.JournalEntryID, "reason", result.Reason)
+				return result, nil
+			}
+
+			result.RecordsChecked++
+			result.HeadSequence = r.Sequence
+			result.HeadHash = r.Hash
+			prevHash = r.Hash
+			after = r.Sequence
+		}
+		if len(records) < journalAuditPageSize {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryAuditLog serves audit records the way the repository pages them
+type memoryAuditLog struct {
+	records []model.JournalAuditRecord
+}
+
+func (m *memoryAuditLog) ListJournalAudit(afterSequence int64, limit int) ([]model.JournalAuditRecord, error) {
+	var page []model.JournalAuditRecord
+	for _, r := range m.records {
+		if r.Sequence > afterSequence && len(page) < limit {
+			page = append(page, r)
+		}
+	}
+	return page, nil
+}
+
+// auditChain builds a valid chain of n records, alternating entry creation and booking
+func auditChain(t *testing.T, n int) *memoryAuditLog {
+	t.Helper()
+	log := &memoryAuditLog{}
+	prevHash := model.GenesisHash
+	at := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	var entry *model.JournalEntry
+	for i := 0; i < n; i++ {
+		action := model.AuditStatusChange
+		if i%2 == 0 {
+			entry = &model.JournalEntry{
+				ID:              uuid.New(),
+				TransactionDate: at,
+				Description:     "Transfer",
+				Status:          model.StatusPending,
+				Postings: []model.Posting{
+					{ID: uuid.New(), AccountID: uuid.New(), Amount: decimal.RequireFromString("25.50"), Direction: -1},
+					{ID: uuid.New(), AccountID: uuid.New(), Amount: decimal.RequireFromString("25.50"), Direction: 1},
+				},
+			}
+			action = model.AuditEntryCreated
+		} else {
+			booked := at.Add(time.Minute)
+			entry.Status = model.StatusBooked
+			entry.FinalizedAt = &booked
+		}
+
+		record, err := model.NewJournalAuditRecord(entry, action, prevHash, at.Add(time.Duration(i)*time.Second))
+		require.NoError(t, err)
+		record.Sequence = int64(i + 1)
+		log.records = append(log.records, *record)
+		prevHash = record.Hash
+	}
+	return log
+}
+
+func TestVerifyJournalAudit_ValidChain(t *testing.T) {
+	log := auditChain(t, journalAuditPageSize+5)
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetJournalAudit(log)
+
+	result, err := svc.VerifyJournalAudit(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Equal(t, int64(journalAuditPageSize+5), result.RecordsChecked)
+	assert.Equal(t, int64(journalAuditPageSize+5), result.HeadSequence)
+	assert.Equal(t, log.records[len(log.records)-1].Hash, result.HeadHash)
+}
+
+func TestVerifyJournalAudit_EmptyLog(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetJournalAudit(&memoryAuditLog{})
+
+	result, err := svc.VerifyJournalAudit(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.Valid)
+	assert.Zero(t, result.RecordsChecked)
+}
+
+func TestVerifyJournalAudit_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(log *memoryAuditLog)
+		broken int64
+		reason string
+	}{
+		{
+			name: "payload edited",
+			tamper: func(log *memoryAuditLog) {
+				log.records[3].Payload = strings.Replace(log.records[3].Payload, "25.5", "2550", 1)
+			},
+			broken: 4,
+			reason: "contents",
+		},
+		{
+			name:   "status edited",
+			tamper: func(log *memoryAuditLog) { log.records[5].Status = model.StatusReversed },
+			broken: 6,
+			reason: "contents",
+		},
+		{
+			name: "record deleted",
+			tamper: func(log *memoryAuditLog) {
+				log.records = append(log.records[:2], log.records[3:]...)
+			},
+			broken: 4,
+			reason: "chain",
+		},
+		{
+			name: "record rewritten with a fresh hash",
+			tamper: func(log *memoryAuditLog) {
+				log.records[2].Payload = "{}"
+				log.records[2].Hash = log.records[2].ComputeHash()
+			},
+			broken: 4,
+			reason: "chain",
+		},
+		{
+			name: "records swapped",
+			tamper: func(log *memoryAuditLog) {
+				log.records[6], log.records[7] = log.records[7], log.records[6]
+				log.records[6].Sequence, log.records[7].Sequence = 7, 8
+			},
+			broken: 7,
+			reason: "chain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := auditChain(t, 10)
+			tt.tamper(log)
+			svc := NewLedgerService(new(MockLedgerRepo))
+			svc.SetJournalAudit(log)
+
+			result, err := svc.VerifyJournalAudit(context.Background())
+			require.NoError(t, err)
+			assert.False(t, result.Valid)
+			assert.Equal(t, tt.broken, result.BrokenSequence)
+			assert.Contains(t, result.Reason, tt.reason)
+		})
+	}
+}
+
+func TestVerifyJournalAudit_Disabled(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+	_, err := svc.VerifyJournalAudit(context.Background())
+	assert.ErrorIs(t, err, ErrJournalAuditDisabled)
+}
+
+func TestNewJournalAuditRecord_HashIsStable(t *testing.T) {
+	entry := &model.JournalEntry{
+		ID:              uuid.New(),
+		TransactionDate: time.Now(),
+		Status:          model.StatusPosted,
+		Postings:        []model.Posting{{ID: uuid.New(), AccountID: uuid.New(), Amount: decimal.NewFromInt(10), Direction: 1}},
+	}
+	at := time.Now()
+	a, err := model.NewJournalAuditRecord(entry, model.AuditEntryCreated, model.GenesisHash, at)
+	require.NoError(t, err)
+	b, err := model.NewJournalAuditRecord(entry, model.AuditEntryCreated, model.GenesisHash, at)
+	require.NoError(t, err)
+	assert.Equal(t, a.Hash, b.Hash)
+	assert.Len(t, a.Hash, 64)
+
+	// The stored time round-trips through Postgres at microsecond precision
+	a.RecordedAt = a.RecordedAt.In(time.FixedZone("UTC+2", 2*60*60))
+	assert.Equal(t, b.Hash, a.ComputeHash())
+
+	c, err := model.NewJournalAuditRecord(entry, model.AuditEntryCreated, a.Hash, at)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Hash, c.Hash)
+}
@@ -8,6 +8,8 @@ import (
 
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -17,12 +19,69 @@ type LedgerRepository interface {
 	GetAccount(id string) (*model.Account, error)
 	ListAccounts() ([]model.Account, error)
 	ListAccountsByUser(userID string) ([]model.Account, error)
+	ListAccountsByOrg(orgID string) ([]model.Account, error)
 	PostTransaction(entry *model.JournalEntry) error
+	PostTransactionsBatch(entries []*model.JournalEntry) error
+	GetJournalEntry(id string) (*model.JournalEntry, error)
+	FinalizeTransaction(id string, status model.JournalEntryStatus) (*model.JournalEntry, error)
+	GetProvisioningBatch(reference string) (*model.ProvisioningBatch, error)
+	FindExistingAccountNumbers(numbers []string) ([]string, error)
+	CreateAccountsBatch(batch *model.ProvisioningBatch, accounts []model.Account) error
 }
 
+// ErrAccountNotFound is returned when an account does not exist or belongs to
+// another user or organization
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrEntryNotReversible is returned when reversing an entry that is not booked,
+// or with postings on accounts the original entry did not touch
+var ErrEntryNotReversible = errors.New("journal entry cannot be reversed")
+
 type LedgerService struct {
-	Repo  LedgerRepository
-	cache *cache.RedisClient
+	Repo        LedgerRepository
+	cache       *cache.RedisClient
+	producer    *kafka.Producer
+	categories  CategoryRepository
+	categorizer *Categorizer
+	snapshots   SnapshotRepository
+
+	reconciliation ReconciliationRepository
+	locker         JobLocker
+	journalAudit   JournalAuditRepository
+	stream         *BalanceStream
+
+	// Aliases are optional; see SetAliases
+	aliases     AliasRepository
+	aliasConfig AliasConfig
+
+	// Transaction imports are optional; see SetImports
+	imports        ImportRepository
+	importQueue    ImportQueue
+	importSuspense string
+
+	// Freezes and legal holds are optional; see SetRestrictions
+	restrictions RestrictionRepository
+
+	// Overdraft facilities are optional; see SetOverdrafts
+	overdrafts      OverdraftRepository
+	overdraftIncome string
+
+	// Parking of failed payment postings is optional; see SetParkedPostings
+	parked        ParkedPostingRepository
+	parkingConfig ParkingConfig
+
+	// Accounting period close is optional; see SetPeriods
+	periods PeriodRepository
+
+	// The interest and fee accrual sub-ledger is optional; see SetAccruals
+	accruals AccrualRepository
+
+	// Transaction history reads are optional; see SetTransactionHistory
+	history TransactionHistoryRepository
+
+	// Set on a copy bound to a request's unit of work; see WithUnitOfWork
+	uow       UnitOfWork
+	committed *LedgerService
 }
 
 // NewLedgerService creates a ledger service without caching
@@ -35,30 +94,89 @@ func NewLedgerServiceWithCache(repo LedgerRepository, redisClient *cache.RedisCl
 	return &LedgerService{Repo: repo, cache: redisClient}
 }
 
-func (s *LedgerService) CreateAccount(userID, accountNumber, name, currency string, accType model.AccountType) (*model.Account, error) {
+// SetProducer enables publishing of account events
+func (s *LedgerService) SetProducer(producer *kafka.Producer) {
+	s.producer = producer
+}
+
+// CreateAccount opens an account for the user, or for the organization when
+// orgID is set
+func (s *LedgerService) CreateAccount(userID, orgID, accountNumber, name, currency string, accType model.AccountType) (*model.Account, error) {
 	userUUID, err := uuid.Parse(userID)
 	if err != nil {
 		return nil, errors.New("invalid user ID")
 	}
+	var orgUUID *uuid.UUID
+	if orgID != "" {
+		id, err := uuid.Parse(orgID)
+		if err != nil {
+			return nil, errors.New("invalid organization ID")
+		}
+		orgUUID = &id
+	}
+	// Postings are checked against the currency's decimal places, so it must be one money knows
+	if _, err := money.Exponent(currency); err != nil {
+		return nil, err
+	}
 
 	acc := &model.Account{
 		UserID:        userUUID,
+		OrgID:         orgUUID,
 		AccountNumber: accountNumber,
 		Name:          name,
 		Type:          accType,
 		CurrencyCode:  currency,
 		CachedBalance: decimal.Zero,
 	}
+	// Without aliases the account still opens; AliasBackfillJob assigns them later
+	if err := s.assignAliases([]*model.Account{acc}); err != nil {
+		slog.Warn("Failed to assign account aliases", "error", err)
+	}
 	if err := s.Repo.CreateAccount(acc); err != nil {
 		return nil, err
 	}
 
 	// Invalidate account list cache
-	if s.cache != nil {
+	s.invalidateAccountList(userID, orgID)
+
+	return acc, nil
+}
+
+// invalidateAccountList clears the cached account list of the user, or of the
+// organization when orgID is set
+func (s *LedgerService) invalidateAccountList(userID, orgID string) {
+	if s.cache == nil || s.afterCommit(func(s *LedgerService) { s.invalidateAccountList(userID, orgID) }) {
+		return
+	}
+	if orgID != "" {
+		s.cache.Delete(context.Background(), "accounts:list:org:"+orgID)
+	} else {
 		s.cache.Delete(context.Background(), "accounts:list:"+userID)
 	}
+}
 
-	return acc, nil
+// ListAccountsByOrg returns the accounts of an organization
+func (s *LedgerService) ListAccountsByOrg(orgID string) ([]model.Account, error) {
+	cacheKey := "accounts:list:org:" + orgID
+
+	if s.cache != nil {
+		var accounts []model.Account
+		err := s.cache.GetJSON(context.Background(), cacheKey, &accounts)
+		if err == nil && len(accounts) > 0 {
+			return s.withRestrictions(accounts), nil
+		}
+	}
+
+	accounts, err := s.Repo.ListAccountsByOrg(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cache != nil && len(accounts) > 0 {
+		s.cache.SetJSON(context.Background(), cacheKey, accounts, cache.DefaultCacheTTL)
+	}
+
+	return s.withRestrictions(accounts), nil
 }
 
 // ListAccountsByUser returns accounts for a specific user
@@ -71,7 +189,7 @@ func (s *LedgerService) ListAccountsByUser(userID string) ([]model.Account, erro
 		err := s.cache.GetJSON(context.Background(), cacheKey, &accounts)
 		if err == nil && len(accounts) > 0 {
 			slog.Debug("Cache hit for user accounts list", "user_id", userID)
-			return accounts, nil
+			return s.withRestrictions(accounts), nil
 		}
 	}
 
@@ -86,7 +204,7 @@ func (s *LedgerService) ListAccountsByUser(userID string) ([]model.Account, erro
 		s.cache.SetJSON(context.Background(), cacheKey, accounts, cache.DefaultCacheTTL)
 	}
 
-	return accounts, nil
+	return s.withRestrictions(accounts), nil
 }
 
 func (s *LedgerService) ListAccounts() ([]model.Account, error) {
@@ -120,30 +238,132 @@ type PostingRequest struct {
 	Direction int
 }
 
-// PostTransaction creates a journal entry with multiple postings
+// PostTransaction creates a booked journal entry with multiple postings
 func (s *LedgerService) PostTransaction(desc string, postings []PostingRequest) (*model.JournalEntry, error) {
-	if len(postings) < 2 {
-		return nil, errors.New("transaction must have at least 2 postings")
+	return s.postEntry(&model.JournalEntry{Description: desc, Status: model.StatusPosted}, postings)
+}
+
+// PostReversal books an entry that reverses all or part of a posted or booked
+// entry, such as a refund. The original entry is left unchanged; the reversal
+// references it and may only move money between the accounts it touched.
+func (s *LedgerService) PostReversal(originalID, desc string, postings []PostingRequest) (*model.JournalEntry, error) {
+	originalUUID, err := uuid.Parse(originalID)
+	if err != nil {
+		return nil, errors.New("invalid transaction ID")
+	}
+	original, err := s.Repo.GetJournalEntry(originalID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status != model.StatusPosted && original.Status != model.StatusBooked {
+		return nil, ErrEntryNotReversible
 	}
 
-	entry := &model.JournalEntry{
-		TransactionDate: time.Now(),
+	accounts := make(map[string]bool, len(original.Postings))
+	for _, p := range original.Postings {
+		accounts[p.AccountID.String()] = true
+	}
+	for _, p := range postings {
+		if !accounts[p.AccountID] {
+			return nil, ErrEntryNotReversible
+		}
+	}
+
+	return s.postEntry(&model.JournalEntry{
 		Description:     desc,
 		Status:          model.StatusPosted,
-		Postings:        make([]model.Posting, len(postings)),
+		ReversesEntryID: &originalUUID,
+	}, postings)
+}
+
+// PostPendingTransaction creates a pending journal entry. Outgoing amounts are held
+// against the available balance until the entry is booked or reversed.
+func (s *LedgerService) PostPendingTransaction(desc string, postings []PostingRequest) (*model.JournalEntry, error) {
+	return s.postEntry(&model.JournalEntry{Description: desc, Status: model.StatusPending}, postings)
+}
+
+// BookTransaction finalizes a pending entry, applying it to the booked balance
+func (s *LedgerService) BookTransaction(id string) (*model.JournalEntry, error) {
+	return s.finalizeEntry(id, model.StatusBooked)
+}
+
+// ReverseTransaction releases a pending entry without booking it
+func (s *LedgerService) ReverseTransaction(id string) (*model.JournalEntry, error) {
+	return s.finalizeEntry(id, model.StatusReversed)
+}
+
+// GetAccountBalance returns an account visible to the caller, for balance
+// reporting: a personal account of the user, or when orgID is set, an account
+// of that organization
+func (s *LedgerService) GetAccountBalance(userID, orgID, accountID string) (*model.Account, error) {
+	if _, err := uuid.Parse(accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	acc, err := s.Repo.GetAccount(accountID)
+	if err != nil || !accountVisible(acc, userID, orgID) {
+		return nil, ErrAccountNotFound
+	}
+	return &s.withRestrictions([]model.Account{*acc})[0], nil
+}
+
+// AccountBalanceForService returns an account with its balances to another
+// service, e.g. the card service checking funds for an authorization, which
+// acts for the account holder without being them
+func (s *LedgerService) AccountBalanceForService(accountID string) (*model.Account, error) {
+	if _, err := uuid.Parse(accountID); err != nil {
+		return nil, ErrAccountNotFound
 	}
+	acc, err := s.Repo.GetAccount(accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	return &s.withRestrictions([]model.Account{*acc})[0], nil
+}
 
-	var affectedAccounts []string
+// accountVisible reports whether a caller acting for orgID ("" for personal
+// requests) may see the account
+func accountVisible(acc *model.Account, userID, orgID string) bool {
+	if orgID != "" {
+		return acc.OrgID != nil && acc.OrgID.String() == orgID
+	}
+	return acc.OrgID == nil && acc.UserID.String() == userID
+}
+
+// postEntry adds the postings to entry and stores it
+func (s *LedgerService) postEntry(entry *model.JournalEntry, postings []PostingRequest) (*model.JournalEntry, error) {
+	if err := s.writeEntry(s.Repo, entry, postings); err != nil {
+		return nil, err
+	}
+	s.Posted(entry)
+	return entry, nil
+}
+
+// writeEntry validates the postings and stores the entry through repo
+func (s *LedgerService) writeEntry(repo LedgerRepository, entry *model.JournalEntry, postings []PostingRequest) error {
+	if err := buildEntry(entry, postings); err != nil {
+		return err
+	}
+	return repo.PostTransaction(entry)
+}
+
+// buildEntry validates the postings and adds them to entry
+func buildEntry(entry *model.JournalEntry, postings []PostingRequest) error {
+	if len(postings) < 2 {
+		return model.ErrTooFewPostings
+	}
+
+	entry.TransactionDate = time.Now()
+	entry.Postings = make([]model.Posting, len(postings))
 
 	for i, p := range postings {
 		amount, err := decimal.NewFromString(p.Amount)
 		if err != nil {
-			return nil, errors.New("invalid amount format")
+			return model.ErrInvalidPostingAmount
 		}
 
 		accUUID, err := uuid.Parse(p.AccountID)
 		if err != nil {
-			return nil, errors.New("invalid account UUID")
+			return model.ErrInvalidPostingAccount
 		}
 
 		entry.Postings[i] = model.Posting{
@@ -151,40 +371,120 @@ func (s *LedgerService) PostTransaction(desc string, postings []PostingRequest)
 			Amount:    amount,
 			Direction: p.Direction,
 		}
-		affectedAccounts = append(affectedAccounts, p.AccountID)
 	}
+	return nil
+}
+
+// PostTransferInTx books a transfer through repo, a repository bound to the
+// caller's database transaction, so the postings commit or roll back with the
+// caller's other writes. Once that transaction has committed, the caller must
+// pass the entry to Posted.
+func (s *LedgerService) PostTransferInTx(repo LedgerRepository, fromAccountID, toAccountID, amountStr, description string) (*model.JournalEntry, error) {
+	return s.PostTransactionInTx(repo, description, transferPostings(fromAccountID, toAccountID, amountStr))
+}
 
-	if err := s.Repo.PostTransaction(entry); err != nil {
+// PostTransactionInTx is PostTransferInTx for an entry with any postings, such
+// as a payment with its fee
+func (s *LedgerService) PostTransactionInTx(repo LedgerRepository, description string, postings []PostingRequest) (*model.JournalEntry, error) {
+	entry := &model.JournalEntry{Description: description, Status: model.StatusPosted}
+	if err := s.writeEntry(repo, entry, postings); err != nil {
 		return nil, err
 	}
+	return entry, nil
+}
 
-	// Invalidate cache for affected accounts
-	if s.cache != nil {
-		ctx := context.Background()
-		for _, accID := range affectedAccounts {
-			s.cache.Delete(ctx, cache.BalanceCacheKey(accID))
-			s.cache.Delete(ctx, cache.AccountCacheKey(accID))
-
-			// Also invalidate cache for the user who owns this account
-			acc, err := s.Repo.GetAccount(accID)
-			if err == nil && acc != nil {
-				slog.Debug("Invalidating cache for user", "user_id", acc.UserID.String())
-				s.cache.Delete(ctx, "accounts:list:"+acc.UserID.String())
-			}
-		}
-		// Also invalidate accounts list since balances changed
-		s.cache.Delete(ctx, "accounts:list")
-		slog.Debug("Cache invalidated for accounts", "count", len(affectedAccounts))
+// ReverseEntryInTx books, through repo as PostTransactionInTx does, an entry
+// that undoes every posting of a posted entry, such as a payment cancelled
+// after it was posted
+func (s *LedgerService) ReverseEntryInTx(repo LedgerRepository, originalID uuid.UUID, description string) (*model.JournalEntry, error) {
+	original, err := repo.GetJournalEntry(originalID.String())
+	if err != nil {
+		return nil, err
+	}
+	if original.Status != model.StatusPosted && original.Status != model.StatusBooked {
+		return nil, ErrEntryNotReversible
+	}
+	postings := make([]PostingRequest, len(original.Postings))
+	for i, p := range original.Postings {
+		postings[i] = PostingRequest{AccountID: p.AccountID.String(), Amount: p.Amount.String(), Direction: -p.Direction}
+	}
+	entry := &model.JournalEntry{Description: description, Status: model.StatusPosted, ReversesEntryID: &originalID}
+	if err := s.writeEntry(repo, entry, postings); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Posted clears cached balances, categorizes and publishes a committed entry,
+// and notifies the owners of accounts it took into their overdraft
+func (s *LedgerService) Posted(entry *model.JournalEntry) {
+	affectedAccounts := make([]string, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		affectedAccounts = append(affectedAccounts, p.AccountID.String())
+	}
+	s.invalidateAccounts(affectedAccounts)
+	s.categorizeEntry(entry)
+	s.publishJournal(entry)
+	s.notifyOverdraft(entry)
+}
+
+func (s *LedgerService) finalizeEntry(id string, status model.JournalEntryStatus) (*model.JournalEntry, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errors.New("invalid transaction ID")
+	}
+
+	entry, err := s.Repo.FinalizeTransaction(id, status)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedAccounts := make([]string, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		affectedAccounts = append(affectedAccounts, p.AccountID.String())
 	}
+	s.invalidateAccounts(affectedAccounts)
+	s.publishJournal(entry)
+	s.notifyOverdraft(entry)
 
 	return entry, nil
 }
 
+// invalidateAccounts clears cached balances and account lists after balances
+// change, here and in the services subscribed to balance invalidations
+func (s *LedgerService) invalidateAccounts(accountIDs []string) {
+	if s.cache == nil || s.afterCommit(func(s *LedgerService) { s.invalidateAccounts(accountIDs) }) {
+		return
+	}
+
+	ctx := context.Background()
+	for _, accID := range accountIDs {
+		s.cache.Delete(ctx, cache.BalanceCacheKey(accID))
+		s.cache.Delete(ctx, cache.AccountCacheKey(accID))
+
+		// Also invalidate cache for the user who owns this account
+		acc, err := s.Repo.GetAccount(accID)
+		if err == nil && acc != nil {
+			slog.Debug("Invalidating cache for user", "user_id", acc.UserID.String())
+			s.cache.Delete(ctx, "accounts:list:"+acc.UserID.String())
+		}
+	}
+	// Also invalidate accounts list since balances changed
+	s.cache.Delete(ctx, "accounts:list")
+	// Other services keep their own copies of balances; tell them to drop them
+	if err := cache.PublishInvalidation(ctx, s.cache, cache.BalanceInvalidationChannel, accountIDs...); err != nil {
+		slog.Warn("Failed to publish balance invalidation", "error", err, "count", len(accountIDs))
+	}
+	slog.Debug("Cache invalidated for accounts", "count", len(accountIDs))
+}
+
 // PostTransfer is a convenience method for simple A->B transfers (used by Kafka consumer)
 func (s *LedgerService) PostTransfer(fromAccountID, toAccountID, amountStr, description string) (*model.JournalEntry, error) {
-	postings := []PostingRequest{
+	return s.PostTransaction(description, transferPostings(fromAccountID, toAccountID, amountStr))
+}
+
+func transferPostings(fromAccountID, toAccountID, amountStr string) []PostingRequest {
+	return []PostingRequest{
 		{AccountID: fromAccountID, Amount: amountStr, Direction: -1}, // Credit sender
 		{AccountID: toAccountID, Amount: amountStr, Direction: 1},    // Debit receiver
 	}
-	return s.PostTransaction(description, postings)
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -29,6 +30,19 @@ func (m *MockLedgerRepo) PostTransaction(entry *model.JournalEntry) error {
 	return args.Error(0)
 }
 
+func (m *MockLedgerRepo) PostTransactionsBatch(entries []*model.JournalEntry) error {
+	args := m.Called(entries)
+	return args.Error(0)
+}
+
+func (m *MockLedgerRepo) GetJournalEntry(id string) (*model.JournalEntry, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.JournalEntry), args.Error(1)
+}
+
 func (m *MockLedgerRepo) GetAccount(id string) (*model.Account, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -37,11 +51,42 @@ func (m *MockLedgerRepo) GetAccount(id string) (*model.Account, error) {
 	return args.Get(0).(*model.Account), args.Error(1)
 }
 
+func (m *MockLedgerRepo) FinalizeTransaction(id string, status model.JournalEntryStatus) (*model.JournalEntry, error) {
+	args := m.Called(id, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.JournalEntry), args.Error(1)
+}
+
 func (m *MockLedgerRepo) ListAccountsByUser(userID string) ([]model.Account, error) {
 	args := m.Called(userID)
 	return args.Get(0).([]model.Account), args.Error(1)
 }
 
+func (m *MockLedgerRepo) ListAccountsByOrg(orgID string) ([]model.Account, error) {
+	args := m.Called(orgID)
+	return args.Get(0).([]model.Account), args.Error(1)
+}
+
+func (m *MockLedgerRepo) GetProvisioningBatch(reference string) (*model.ProvisioningBatch, error) {
+	args := m.Called(reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ProvisioningBatch), args.Error(1)
+}
+
+func (m *MockLedgerRepo) FindExistingAccountNumbers(numbers []string) ([]string, error) {
+	args := m.Called(numbers)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockLedgerRepo) CreateAccountsBatch(batch *model.ProvisioningBatch, accounts []model.Account) error {
+	args := m.Called(batch, accounts)
+	return args.Error(0)
+}
+
 func TestCreateAccount(t *testing.T) {
 	mockRepo := new(MockLedgerRepo)
 	service := NewLedgerService(mockRepo)
@@ -50,7 +95,7 @@ func TestCreateAccount(t *testing.T) {
 	mockRepo.On("CreateAccount", mock.AnythingOfType("*model.Account")).Return(nil)
 
 	// Execute
-	acc, err := service.CreateAccount(uuid.New().String(), "123", "Checking", "USD", model.Asset)
+	acc, err := service.CreateAccount(uuid.New().String(), "", "123", "Checking", "USD", model.Asset)
 
 	// Assert
 	assert.NoError(t, err)
@@ -87,3 +132,207 @@ func TestPostTransaction(t *testing.T) {
 	assert.Len(t, entry.Postings, 2)
 	assert.Equal(t, decimal.NewFromFloat(100.00).String(), entry.Postings[0].Amount.String())
 }
+
+func TestPostTransferInTx_UsesCallerRepository(t *testing.T) {
+	serviceRepo := new(MockLedgerRepo)
+	txRepo := new(MockLedgerRepo)
+	service := NewLedgerService(serviceRepo)
+
+	from := "00000000-0000-0000-0000-000000000001"
+	to := "00000000-0000-0000-0000-000000000002"
+	txRepo.On("PostTransaction", mock.AnythingOfType("*model.JournalEntry")).Return(nil)
+
+	entry, err := service.PostTransferInTx(txRepo, from, to, "25.00", "Payment: rent")
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusPosted, entry.Status)
+	assert.Len(t, entry.Postings, 2)
+	assert.Equal(t, -1, entry.Postings[0].Direction)
+	assert.Equal(t, from, entry.Postings[0].AccountID.String())
+	txRepo.AssertExpectations(t)
+	serviceRepo.AssertNotCalled(t, "PostTransaction", mock.Anything)
+
+	// A failed posting is returned to the caller so it can roll back its transaction
+	failing := new(MockLedgerRepo)
+	failing.On("PostTransaction", mock.AnythingOfType("*model.JournalEntry")).Return(assert.AnError)
+	_, err = service.PostTransferInTx(failing, from, to, "25.00", "Payment: rent")
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestPostPendingTransaction(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	service := NewLedgerService(mockRepo)
+
+	postings := []PostingRequest{
+		{AccountID: "00000000-0000-0000-0000-000000000001", Amount: "25.00", Direction: 1},
+		{AccountID: "00000000-0000-0000-0000-000000000002", Amount: "25.00", Direction: -1},
+	}
+
+	mockRepo.On("PostTransaction", mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.Status == model.StatusPending
+	})).Return(nil)
+
+	entry, err := service.PostPendingTransaction("Card authorization", postings)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusPending, entry.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBookAndReverseTransaction(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	service := NewLedgerService(mockRepo)
+	entryID := uuid.New().String()
+
+	mockRepo.On("FinalizeTransaction", entryID, model.StatusBooked).Return(&model.JournalEntry{Status: model.StatusBooked}, nil)
+	mockRepo.On("FinalizeTransaction", entryID, model.StatusReversed).Return(&model.JournalEntry{Status: model.StatusReversed}, nil)
+
+	entry, err := service.BookTransaction(entryID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusBooked, entry.Status)
+
+	entry, err = service.ReverseTransaction(entryID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusReversed, entry.Status)
+
+	_, err = service.BookTransaction("not-a-uuid")
+	assert.Error(t, err)
+}
+
+func TestPostReversal(t *testing.T) {
+	payer, payee := uuid.New(), uuid.New()
+	original := &model.JournalEntry{
+		ID:     uuid.New(),
+		Status: model.StatusPosted,
+		Postings: []model.Posting{
+			{AccountID: payer, Amount: decimal.NewFromInt(100), Direction: -1},
+			{AccountID: payee, Amount: decimal.NewFromInt(100), Direction: 1},
+		},
+	}
+	refund := []PostingRequest{
+		{AccountID: payee.String(), Amount: "40", Direction: -1},
+		{AccountID: payer.String(), Amount: "40", Direction: 1},
+	}
+
+	t.Run("references the original entry", func(t *testing.T) {
+		mockRepo := new(MockLedgerRepo)
+		mockRepo.On("GetJournalEntry", original.ID.String()).Return(original, nil)
+		mockRepo.On("PostTransaction", mock.MatchedBy(func(e *model.JournalEntry) bool {
+			return e.ReversesEntryID != nil && *e.ReversesEntryID == original.ID && e.Status == model.StatusPosted
+		})).Return(nil)
+
+		entry, err := NewLedgerService(mockRepo).PostReversal(original.ID.String(), "Refund", refund)
+		assert.NoError(t, err)
+		assert.Len(t, entry.Postings, 2)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("rejects accounts outside the original entry", func(t *testing.T) {
+		mockRepo := new(MockLedgerRepo)
+		mockRepo.On("GetJournalEntry", original.ID.String()).Return(original, nil)
+
+		_, err := NewLedgerService(mockRepo).PostReversal(original.ID.String(), "Refund", []PostingRequest{
+			{AccountID: payee.String(), Amount: "40", Direction: -1},
+			{AccountID: uuid.New().String(), Amount: "40", Direction: 1},
+		})
+		assert.ErrorIs(t, err, ErrEntryNotReversible)
+	})
+
+	t.Run("rejects pending entries", func(t *testing.T) {
+		pending := *original
+		pending.Status = model.StatusPending
+		mockRepo := new(MockLedgerRepo)
+		mockRepo.On("GetJournalEntry", original.ID.String()).Return(&pending, nil)
+
+		_, err := NewLedgerService(mockRepo).PostReversal(original.ID.String(), "Refund", refund)
+		assert.ErrorIs(t, err, ErrEntryNotReversible)
+	})
+}
+
+func TestReverseEntryInTx_UndoesEveryPosting(t *testing.T) {
+	payer, payee, fees := uuid.New(), uuid.New(), uuid.New()
+	original := &model.JournalEntry{
+		ID:     uuid.New(),
+		Status: model.StatusPosted,
+		Postings: []model.Posting{
+			{AccountID: payer, Amount: decimal.NewFromInt(100), Direction: -1},
+			{AccountID: payee, Amount: decimal.NewFromInt(100), Direction: 1},
+			{AccountID: payer, Amount: decimal.NewFromInt(2), Direction: -1},
+			{AccountID: fees, Amount: decimal.NewFromInt(2), Direction: 1},
+		},
+	}
+	mockRepo := new(MockLedgerRepo)
+	mockRepo.On("GetJournalEntry", original.ID.String()).Return(original, nil)
+	mockRepo.On("PostTransaction", mock.MatchedBy(func(e *model.JournalEntry) bool {
+		return e.ReversesEntryID != nil && *e.ReversesEntryID == original.ID
+	})).Return(nil)
+
+	entry, err := NewLedgerService(mockRepo).ReverseEntryInTx(mockRepo, original.ID, "Cancelled payment")
+	assert.NoError(t, err)
+	assert.Len(t, entry.Postings, 4)
+	for i, p := range entry.Postings {
+		assert.Equal(t, original.Postings[i].AccountID, p.AccountID)
+		assert.Equal(t, -original.Postings[i].Direction, p.Direction)
+		assert.True(t, original.Postings[i].Amount.Equal(p.Amount))
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAccountBalance(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	service := NewLedgerService(mockRepo)
+
+	owner := uuid.New()
+	acc := &model.Account{
+		ID:            uuid.New(),
+		UserID:        owner,
+		CachedBalance: decimal.NewFromInt(100),
+		HeldBalance:   decimal.NewFromInt(30),
+	}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+
+	got, err := service.GetAccountBalance(owner.String(), "", acc.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, "70", got.AvailableBalance().String())
+
+	// Accounts of other users are not visible
+	_, err = service.GetAccountBalance(uuid.New().String(), "", acc.ID.String())
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestGetAccountBalance_OrganizationAccounts(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	service := NewLedgerService(mockRepo)
+
+	owner, org := uuid.New(), uuid.New()
+	acc := &model.Account{ID: uuid.New(), UserID: owner, OrgID: &org}
+	mockRepo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+
+	// Any member acting for the organization sees its accounts
+	_, err := service.GetAccountBalance(uuid.New().String(), org.String(), acc.ID.String())
+	assert.NoError(t, err)
+
+	// The member who opened it cannot see it with a personal token, nor with another organization's
+	_, err = service.GetAccountBalance(owner.String(), "", acc.ID.String())
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	_, err = service.GetAccountBalance(owner.String(), uuid.New().String(), acc.ID.String())
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+}
+
+func TestCheckAmounts_UsesEachAccountsCurrency(t *testing.T) {
+	usdA, usdB, jpy := uuid.New(), uuid.New(), uuid.New()
+	currencies := map[uuid.UUID]string{usdA: "USD", usdB: "USD", jpy: "JPY"}
+	posting := func(account uuid.UUID, amount string, direction int) model.Posting {
+		return model.Posting{AccountID: account, Amount: decimal.RequireFromString(amount), Direction: direction}
+	}
+
+	assert.NoError(t, model.CheckAmounts([]model.Posting{posting(usdA, "10.50", 1), posting(usdB, "10.5", -1)}, currencies))
+
+	// A tenth of a cent and half a yen are not amounts of their currencies
+	err := model.CheckAmounts([]model.Posting{posting(usdA, "10.005", 1), posting(usdB, "10.005", -1)}, currencies)
+	assert.ErrorIs(t, err, money.ErrPrecision)
+	err = model.CheckAmounts([]model.Posting{posting(jpy, "0.5", 1), posting(usdB, "0.5", -1)}, currencies)
+	assert.ErrorIs(t, err, money.ErrPrecision)
+
+	// Balanced in total is not enough when the currencies differ
+	err = model.CheckAmounts([]model.Posting{posting(jpy, "100", 1), posting(usdB, "100", -1)}, currencies)
+	assert.ErrorIs(t, err, model.ErrUnbalancedCurrency)
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// OverdraftEnteredTemplate is the notification sent when an account goes
+	// into its overdraft
+	OverdraftEnteredTemplate = "overdraft_entered"
+	// MaxOverdraftInterestRate bounds the yearly overdraft interest rate (100%)
+	MaxOverdraftInterestRate = 1
+	// overdraftDaysPerYear turns the yearly rate into a daily one
+	overdraftDaysPerYear = 365
+	// overdraftRatePlaces is the precision of interest rates, as stored
+	overdraftRatePlaces = 6
+)
+
+var (
+	ErrOverdraftsDisabled = errors.New("overdrafts are not configured")
+	ErrInvalidOverdraft   = errors.New("overdraft limit must be zero or more in the account's currency precision, and interest rate between 0 and 1 with at most 6 decimal places")
+)
+
+// OverdraftRepository stores overdraft facilities and the interest accrued on
+// them. Postings are checked against the limit by the ledger repository
+// itself, inside the posting's transaction.
+type OverdraftRepository interface {
+	SetOverdraft(accountID string, limit *decimal.Decimal, rate decimal.Decimal) (*model.Account, error)
+	ListOverdrawnAccounts() ([]model.Account, error)
+	CreateInterestAccrual(accrual *model.OverdraftInterestAccrual) (bool, error)
+	ListUnchargedAccruals(before time.Time) ([]model.OverdraftInterestAccrual, error)
+	ChargeInterest(entry *model.JournalEntry, accrualIDs []uuid.UUID) error
+}
+
+// SetOverdrafts enables admins to give accounts an overdraft facility and
+// accrues interest on overdrawn balances. Accrued interest is charged monthly
+// to interestAccountID, the bank's income account; when it is "" interest
+// accrues but is not charged.
+func (s *LedgerService) SetOverdrafts(repo OverdraftRepository, interestAccountID string) error {
+	if interestAccountID != "" {
+		if _, err := uuid.Parse(interestAccountID); err != nil {
+			return fmt.Errorf("invalid overdraft interest account ID: %w", err)
+		}
+	}
+	s.overdrafts = repo
+	s.overdraftIncome = interestAccountID
+	return nil
+}
+
+// SetOverdraft gives an account an overdraft facility, or changes it. A lower
+// limit than the account is already using only refuses further debits.
+func (s *LedgerService) SetOverdraft(adminID, accountID string, limit, rate decimal.Decimal) (*model.Account, error) {
+	if s.overdrafts == nil {
+		return nil, ErrOverdraftsDisabled
+	}
+	acc, err := s.overdraftAccount(adminID, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if limit.IsNegative() || rate.IsNegative() || rate.GreaterThan(decimal.NewFromInt(MaxOverdraftInterestRate)) ||
+		!rate.Equal(rate.Truncate(overdraftRatePlaces)) {
+		return nil, ErrInvalidOverdraft
+	}
+	if _, err := money.FromDecimal(limit, acc.CurrencyCode); err != nil {
+		return nil, ErrInvalidOverdraft
+	}
+
+	updated, err := s.overdrafts.SetOverdraft(accountID, &limit, rate)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateAccounts([]string{accountID})
+	slog.Info("Overdraft facility set", "account_id", accountID, "limit", limit, "rate", rate, "set_by", adminID)
+	return updated, nil
+}
+
+// RemoveOverdraft withdraws an account's overdraft facility. An overdrawn
+// balance stays as it is; the ledger just stops policing the account.
+func (s *LedgerService) RemoveOverdraft(adminID, accountID string) (*model.Account, error) {
+	if s.overdrafts == nil {
+		return nil, ErrOverdraftsDisabled
+	}
+	if _, err := s.overdraftAccount(adminID, accountID); err != nil {
+		return nil, err
+	}
+	updated, err := s.overdrafts.SetOverdraft(accountID, nil, decimal.Zero)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateAccounts([]string{accountID})
+	slog.Info("Overdraft facility removed", "account_id", accountID, "removed_by", adminID)
+	return updated, nil
+}
+
+func (s *LedgerService) overdraftAccount(adminID, accountID string) (*model.Account, error) {
+	if _, err := uuid.Parse(adminID); err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	if _, err := uuid.Parse(accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	acc, err := s.Repo.GetAccount(accountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	return acc, nil
+}
+
+// OverdraftInterestJob accrues the last closed day's interest on overdrawn
+// balances and, once a month has ended, charges what accrued in it. It is
+// scheduled well inside a day; accruals that already exist are skipped.
+func (s *LedgerService) OverdraftInterestJob(ctx context.Context, _ *jobs.Job) error {
+	now := time.Now()
+	accrued, err := s.AccrueOverdraftInterest(now)
+	if err != nil {
+		return err
+	}
+	charged, err := s.ChargeOverdraftInterest(now)
+	if accrued > 0 || charged > 0 {
+		slog.Info("Overdraft interest processed", "accrued", accrued, "charged", charged)
+	}
+	return err
+}
+
+// AccrueOverdraftInterest stores a day's interest for every overdrawn account
+// that pays interest, for the most recent UTC day that ended at least
+// SnapshotGracePeriod before now. Interest is the overdrawn booked balance
+// times the yearly rate over 365, rounded to the account's currency.
+func (s *LedgerService) AccrueOverdraftInterest(now time.Time) (int, error) {
+	if s.overdrafts == nil {
+		return 0, ErrOverdraftsDisabled
+	}
+	day := now.UTC().Add(-SnapshotGracePeriod).Truncate(24*time.Hour).AddDate(0, 0, -1)
+	accounts, err := s.overdrafts.ListOverdrawnAccounts()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list overdrawn accounts: %w", err)
+	}
+
+	accrued := 0
+	for i := range accounts {
+		acc := &accounts[i]
+		exponent, err := money.Exponent(acc.CurrencyCode)
+		if err != nil {
+			return accrued, err
+		}
+		amount := acc.CachedBalance.Neg().Mul(acc.OverdraftInterestRate).
+			Div(decimal.NewFromInt(overdraftDaysPerYear)).
+			Round(int32(exponent))
+		if !amount.IsPositive() {
+			continue
+		}
+		created, err := s.overdrafts.CreateInterestAccrual(&model.OverdraftInterestAccrual{
+			AccountID:   acc.ID,
+			AccrualDate: day,
+			Balance:     acc.CachedBalance,
+			Rate:        acc.OverdraftInterestRate,
+			Amount:      amount,
+		})
+		if err != nil {
+			return accrued, fmt.Errorf("failed to accrue overdraft interest for account %s: %w", acc.ID, err)
+		}
+		if created {
+			accrued++
+		}
+	}
+	return accrued, nil
+}
+
+// ChargeOverdraftInterest charges each account the interest accrued before
+// the month of now (UTC) that has not been charged yet, in one entry per
+// account paid to the interest income account. Charges may take an account
+// past its overdraft limit. It returns how many accounts were charged; an
+// account that fails is left for the next run and does not stop the others.
+func (s *LedgerService) ChargeOverdraftInterest(now time.Time) (int, error) {
+	if s.overdrafts == nil {
+		return 0, ErrOverdraftsDisabled
+	}
+	if s.overdraftIncome == "" {
+		return 0, nil
+	}
+	income := uuid.MustParse(s.overdraftIncome)
+	utc := now.UTC()
+	monthStart := time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC)
+	accruals, err := s.overdrafts.ListUnchargedAccruals(monthStart)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list overdraft interest accruals: %w", err)
+	}
+
+	var order []uuid.UUID
+	byAccount := make(map[uuid.UUID][]model.OverdraftInterestAccrual)
+	for _, a := range accruals {
+		if _, seen := byAccount[a.AccountID]; !seen {
+			order = append(order, a.AccountID)
+		}
+		byAccount[a.AccountID] = append(byAccount[a.AccountID], a)
+	}
+
+	charged := 0
+	var errs []error
+	for _, accountID := range order {
+		items := byAccount[accountID]
+		total := decimal.Zero
+		ids := make([]uuid.UUID, len(items))
+		for i, a := range items {
+			total = total.Add(a.Amount)
+			ids[i] = a.ID
+		}
+		entry := &model.JournalEntry{
+			TransactionDate: now,
+			Description:     "Overdraft interest to " + items[len(items)-1].AccrualDate.Format(statementDateLayout),
+			ReferenceID:     "overdraft-interest:" + accountID.String() + ":" + monthStart.Format("2006-01"),
+			Status:          model.StatusPosted,
+			Postings: []model.Posting{
+				{AccountID: accountID, Amount: total, Direction: -1},
+				{AccountID: income, Amount: total, Direction: 1},
+			},
+			OverLimitAllowed: true,
+		}
+		if err := s.overdrafts.ChargeInterest(entry, ids); err != nil {
+			errs = append(errs, fmt.Errorf("failed to charge overdraft interest to account %s: %w", accountID, err))
+			continue
+		}
+		s.Posted(entry)
+		charged++
+	}
+	return charged, errors.Join(errs...)
+}
+
+// notifyOverdraft tells the owners of the accounts an entry took into their
+// overdraft. The ledger does not hold contact details, so the recipient is
+// left for the notification pipeline to look up from the user ID.
+func (s *LedgerService) notifyOverdraft(entry *model.JournalEntry) {
+	if s.producer == nil || s.afterCommit(func(s *LedgerService) { s.notifyOverdraft(entry) }) {
+		return
+	}
+	for _, accountID := range entry.EnteredOverdraft {
+		acc, err := s.Repo.GetAccount(accountID.String())
+		if err != nil {
+			slog.Warn("Failed to load overdrawn account", "account_id", accountID, "error", err)
+			continue
+		}
+		data := map[string]string{
+			"account_id":     acc.ID.String(),
+			"account_number": acc.AccountNumber,
+			"currency":       acc.CurrencyCode,
+			"balance":        acc.CachedBalance.String(),
+		}
+		if acc.OverdraftLimit != nil {
+			data["overdraft_limit"] = acc.OverdraftLimit.String()
+			data["interest_rate"] = acc.OverdraftInterestRate.String()
+		}
+		notification := kafka.NotificationEvent{
+			UserID:    acc.UserID.String(),
+			Channel:   "EMAIL",
+			Template:  OverdraftEnteredTemplate,
+			Data:      data,
+			Timestamp: time.Now().Format(time.RFC3339),
+		}
+		if err := s.producer.Produce(context.Background(), kafka.TopicNotificationEmail, notification.UserID, notification); err != nil {
+			slog.Error("Failed to publish overdraft notification", "account_id", accountID, "error", err)
+		}
+	}
+}
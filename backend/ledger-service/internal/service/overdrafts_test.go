@@ -0,0 +1,240 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryOverdrafts is an in-memory OverdraftRepository
+type memoryOverdrafts struct {
+	accounts map[string]*model.Account
+	accruals []model.OverdraftInterestAccrual
+	entries  []*model.JournalEntry
+}
+
+func (m *memoryOverdrafts) SetOverdraft(accountID string, limit *decimal.Decimal, rate decimal.Decimal) (*model.Account, error) {
+	acc := m.accounts[accountID]
+	acc.OverdraftLimit, acc.OverdraftInterestRate = limit, rate
+	acc.UpdateOverdrawnStatus()
+	updated := *acc
+	return &updated, nil
+}
+
+func (m *memoryOverdrafts) ListOverdrawnAccounts() ([]model.Account, error) {
+	var out []model.Account
+	for _, acc := range m.accounts {
+		if acc.HasOverdraft() && acc.OverdraftInterestRate.IsPositive() && acc.CachedBalance.IsNegative() {
+			out = append(out, *acc)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryOverdrafts) CreateInterestAccrual(accrual *model.OverdraftInterestAccrual) (bool, error) {
+	for _, a := range m.accruals {
+		if a.AccountID == accrual.AccountID && a.AccrualDate.Equal(accrual.AccrualDate) {
+			return false, nil
+		}
+	}
+	accrual.ID = uuid.New()
+	m.accruals = append(m.accruals, *accrual)
+	return true, nil
+}
+
+func (m *memoryOverdrafts) ListUnchargedAccruals(before time.Time) ([]model.OverdraftInterestAccrual, error) {
+	var out []model.OverdraftInterestAccrual
+	for _, a := range m.accruals {
+		if a.ChargedEntryID == nil && a.AccrualDate.Before(before) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryOverdrafts) ChargeInterest(entry *model.JournalEntry, accrualIDs []uuid.UUID) error {
+	entry.ID = uuid.New()
+	for i := range m.accruals {
+		for _, id := range accrualIDs {
+			if m.accruals[i].ID == id {
+				if m.accruals[i].ChargedEntryID != nil {
+					return repository.ErrAccrualsCharged
+				}
+				m.accruals[i].ChargedEntryID = &entry.ID
+			}
+		}
+	}
+	m.entries = append(m.entries, entry)
+	return nil
+}
+
+func overdraftAccount(balance, limit int64) *model.Account {
+	l := decimal.NewFromInt(limit)
+	return &model.Account{ID: uuid.New(), CurrencyCode: "GBP", Status: model.AccountActive, CachedBalance: decimal.NewFromInt(balance), OverdraftLimit: &l}
+}
+
+func TestCheckOverdraft(t *testing.T) {
+	acc := overdraftAccount(100, 500)
+	before := acc.AvailableBalance()
+
+	// Down to minus the limit is allowed
+	acc.CachedBalance = decimal.NewFromInt(-500)
+	assert.NoError(t, acc.CheckOverdraft(before))
+
+	// One penny beyond it is not, whether booked or held
+	acc.CachedBalance = decimal.RequireFromString("-500.01")
+	var overdrawn *model.OverdraftError
+	require.ErrorAs(t, acc.CheckOverdraft(before), &overdrawn)
+	assert.Equal(t, acc.ID, overdrawn.AccountID)
+	assert.True(t, overdrawn.Available.Equal(decimal.NewFromInt(100)))
+	acc.CachedBalance = decimal.NewFromInt(-400)
+	acc.HeldBalance = decimal.NewFromInt(101)
+	assert.ErrorAs(t, acc.CheckOverdraft(before), &overdrawn)
+
+	// An account already past its limit can still be paid into
+	acc.HeldBalance = decimal.Zero
+	assert.NoError(t, acc.CheckOverdraft(decimal.NewFromInt(-700)))
+
+	// Without a facility the ledger does not police the balance
+	acc.OverdraftLimit = nil
+	acc.CachedBalance = decimal.NewFromInt(-10000)
+	assert.NoError(t, acc.CheckOverdraft(before))
+}
+
+func TestUpdateOverdrawnStatus(t *testing.T) {
+	acc := overdraftAccount(-1, 500)
+	assert.True(t, acc.UpdateOverdrawnStatus(), "going negative enters the overdraft")
+	assert.Equal(t, model.AccountOverdrawn, acc.Status)
+	assert.False(t, acc.UpdateOverdrawnStatus(), "staying negative is not a new entry")
+
+	acc.CachedBalance = decimal.Zero
+	assert.False(t, acc.UpdateOverdrawnStatus())
+	assert.Equal(t, model.AccountActive, acc.Status)
+
+	// Removing the facility clears the flag
+	acc.CachedBalance = decimal.NewFromInt(-1)
+	acc.UpdateOverdrawnStatus()
+	acc.OverdraftLimit = nil
+	acc.UpdateOverdrawnStatus()
+	assert.Equal(t, model.AccountActive, acc.Status)
+}
+
+func TestSetOverdraft(t *testing.T) {
+	repo := new(MockLedgerRepo)
+	svc := NewLedgerService(repo)
+	adminID := uuid.New().String()
+	acc := overdraftAccount(-20, 0)
+	acc.OverdraftLimit = nil
+	repo.On("GetAccount", acc.ID.String()).Return(acc, nil)
+
+	_, err := svc.SetOverdraft(adminID, acc.ID.String(), decimal.NewFromInt(500), decimal.Zero)
+	assert.ErrorIs(t, err, ErrOverdraftsDisabled)
+
+	overdrafts := &memoryOverdrafts{accounts: map[string]*model.Account{acc.ID.String(): acc}}
+	require.NoError(t, svc.SetOverdrafts(overdrafts, ""))
+	assert.Error(t, svc.SetOverdrafts(overdrafts, "income"))
+
+	for _, tt := range []struct{ limit, rate string }{
+		{"-1", "0"},
+		{"500.001", "0"}, // GBP has two decimal places
+		{"500", "1.5"},
+		{"500", "-0.1"},
+		{"500", "0.1234567"},
+	} {
+		_, err := svc.SetOverdraft(adminID, acc.ID.String(), decimal.RequireFromString(tt.limit), decimal.RequireFromString(tt.rate))
+		assert.ErrorIs(t, err, ErrInvalidOverdraft, "limit %s rate %s", tt.limit, tt.rate)
+	}
+	_, err = svc.SetOverdraft(adminID, "not-a-uuid", decimal.NewFromInt(500), decimal.Zero)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+
+	updated, err := svc.SetOverdraft(adminID, acc.ID.String(), decimal.NewFromInt(500), decimal.RequireFromString("0.1995"))
+	require.NoError(t, err)
+	assert.True(t, updated.OverdraftLimit.Equal(decimal.NewFromInt(500)))
+	assert.Equal(t, model.AccountOverdrawn, updated.Status, "an account already below zero is overdrawn")
+
+	removed, err := svc.RemoveOverdraft(adminID, acc.ID.String())
+	require.NoError(t, err)
+	assert.Nil(t, removed.OverdraftLimit)
+	assert.True(t, removed.OverdraftInterestRate.IsZero())
+	assert.Equal(t, model.AccountActive, removed.Status)
+}
+
+func TestAccrueOverdraftInterest(t *testing.T) {
+	overdrawn := overdraftAccount(-1000, 2000)
+	overdrawn.OverdraftInterestRate = decimal.RequireFromString("0.1825")
+	yen := overdraftAccount(-100000, 200000)
+	yen.CurrencyCode = "JPY"
+	yen.OverdraftInterestRate = decimal.RequireFromString("0.15")
+	free := overdraftAccount(-1000, 2000) // No interest
+	inCredit := overdraftAccount(50, 2000)
+	inCredit.OverdraftInterestRate = decimal.RequireFromString("0.1825")
+	overdrafts := &memoryOverdrafts{accounts: map[string]*model.Account{}}
+	for _, acc := range []*model.Account{overdrawn, yen, free, inCredit} {
+		overdrafts.accounts[acc.ID.String()] = acc
+	}
+
+	svc := NewLedgerService(new(MockLedgerRepo))
+	_, err := svc.AccrueOverdraftInterest(time.Now())
+	assert.ErrorIs(t, err, ErrOverdraftsDisabled)
+	require.NoError(t, svc.SetOverdrafts(overdrafts, ""))
+
+	now := time.Date(2026, 3, 10, 1, 0, 0, 0, time.UTC)
+	accrued, err := svc.AccrueOverdraftInterest(now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, accrued)
+
+	// A second run on the same day accrues nothing more
+	accrued, err = svc.AccrueOverdraftInterest(now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, accrued)
+
+	amounts := map[uuid.UUID]string{}
+	for _, a := range overdrafts.accruals {
+		assert.Equal(t, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), a.AccrualDate, "interest is for the last closed day")
+		amounts[a.AccountID] = a.Amount.String()
+	}
+	assert.Equal(t, "0.5", amounts[overdrawn.ID]) // 1000 * 18.25% / 365
+	assert.Equal(t, "41", amounts[yen.ID])        // 41.09, rounded to whole yen
+}
+
+func TestChargeOverdraftInterest(t *testing.T) {
+	acc := overdraftAccount(-1000, 1000)
+	income := uuid.New()
+	day := func(month time.Month, d int) time.Time { return time.Date(2026, month, d, 0, 0, 0, 0, time.UTC) }
+	overdrafts := &memoryOverdrafts{accruals: []model.OverdraftInterestAccrual{
+		{ID: uuid.New(), AccountID: acc.ID, AccrualDate: day(2, 27), Amount: decimal.RequireFromString("0.50")},
+		{ID: uuid.New(), AccountID: acc.ID, AccrualDate: day(2, 28), Amount: decimal.RequireFromString("0.55")},
+		{ID: uuid.New(), AccountID: acc.ID, AccrualDate: day(3, 1), Amount: decimal.RequireFromString("0.60")},
+	}}
+	svc := NewLedgerService(new(MockLedgerRepo))
+	require.NoError(t, svc.SetOverdrafts(overdrafts, ""))
+
+	// Without an income account interest only accrues
+	charged, err := svc.ChargeOverdraftInterest(day(3, 2))
+	require.NoError(t, err)
+	assert.Equal(t, 0, charged)
+
+	require.NoError(t, svc.SetOverdrafts(overdrafts, income.String()))
+	charged, err = svc.ChargeOverdraftInterest(day(3, 2))
+	require.NoError(t, err)
+	assert.Equal(t, 1, charged)
+	require.Len(t, overdrafts.entries, 1)
+	entry := overdrafts.entries[0]
+	assert.True(t, entry.OverLimitAllowed, "interest may take the account past its limit")
+	require.Len(t, entry.Postings, 2)
+	assert.Equal(t, model.Posting{AccountID: acc.ID, Amount: decimal.RequireFromString("1.05"), Direction: -1}, entry.Postings[0])
+	assert.Equal(t, income, entry.Postings[1].AccountID)
+	assert.Equal(t, 1, entry.Postings[1].Direction)
+
+	// March's accrual waits for March to end, and February's is not charged twice
+	charged, err = svc.ChargeOverdraftInterest(day(3, 31))
+	require.NoError(t, err)
+	assert.Equal(t, 0, charged)
+	assert.Nil(t, overdrafts.accruals[2].ChargedEntryID)
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultParkedRetryDelay is how long a parked posting waits between
+	// automatic retries
+	DefaultParkedRetryDelay = 5 * time.Minute
+	// DefaultParkedMaxRetries is how many automatic retries a parked posting
+	// gets before its payment fails
+	DefaultParkedMaxRetries = 5
+	// parkedRetryBatchSize bounds the postings one run of the retry job re-drives
+	parkedRetryBatchSize = 100
+)
+
+var (
+	ErrParkedPostingsDisabled = errors.New("parked postings are not configured")
+	ErrParkedPostingNotFound  = errors.New("parked posting not found")
+	// ErrParkedPostingState is returned when retrying or repairing a posting
+	// that was posted, failed or cancelled meanwhile
+	ErrParkedPostingState = model.ErrNotParked
+	ErrInvalidRepair      = errors.New("repair needs a note and a from or to account ID")
+)
+
+// ParkingConfig sets how failed payment postings are re-driven; zero values
+// use the defaults
+type ParkingConfig struct {
+	RetryDelay time.Duration
+	MaxRetries int
+}
+
+// ParkedPostingRepository stores payment postings that failed. Re-posting
+// locks the payment as the payment consumers do, so a retry never races a
+// cancellation of the same payment.
+type ParkedPostingRepository interface {
+	ParkPosting(p *model.ParkedPosting) error
+	GetParkedPosting(id string) (*model.ParkedPosting, error)
+	ListParkedPostings(status model.ParkedPostingStatus, reason model.ParkReason, limit int) ([]model.ParkedPosting, error)
+	ListDueParkedPostings(now time.Time, limit int) ([]model.ParkedPosting, error)
+	// UpdateParkedPosting saves p if it is still parked, reporting whether it was
+	UpdateParkedPosting(p *model.ParkedPosting) (bool, error)
+	// RepostParked posts the entry for a parked posting and marks it posted,
+	// or marks it cancelled without posting if its payment was cancelled. It
+	// returns model.ErrNotParked if the posting is no longer parked.
+	RepostParked(id uuid.UUID, entry *model.JournalEntry) (*model.ParkedPosting, error)
+}
+
+// SetParkedPostings parks payments from the payment service that fail to
+// post, instead of failing them at once, and re-drives them after a delay
+func (s *LedgerService) SetParkedPostings(repo ParkedPostingRepository, cfg ParkingConfig) {
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultParkedRetryDelay
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = DefaultParkedMaxRetries
+	}
+	s.parked = repo
+	s.parkingConfig = cfg
+}
+
+// PostPaymentInTx posts a payment from the payment service through repo, as
+// PostTransactionInTx does
+func (s *LedgerService) PostPaymentInTx(repo LedgerRepository, event kafka.PaymentEvent) (*model.JournalEntry, error) {
+	return s.PostTransactionInTx(repo, paymentDescription(event), paymentPostings(event))
+}
+
+// ParkPaymentInTx parks a payment that failed to post with postErr, through
+// repo bound to the caller's transaction, for its first retry after the delay
+func (s *LedgerService) ParkPaymentInTx(repo ParkedPostingRepository, paymentID uuid.UUID, event kafka.PaymentEvent, postErr error) (*model.ParkedPosting, error) {
+	if s.parked == nil {
+		return nil, ErrParkedPostingsDisabled
+	}
+	next := time.Now().Add(s.parkingConfig.RetryDelay)
+	p := &model.ParkedPosting{
+		PaymentID:     paymentID,
+		Event:         event,
+		Status:        model.ParkedPostingParked,
+		ReasonCode:    model.ParkReasonFor(postErr),
+		LastError:     postErr.Error(),
+		NextAttemptAt: &next,
+	}
+	if err := repo.ParkPosting(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetParkedPosting returns a parked posting by ID
+func (s *LedgerService) GetParkedPosting(id string) (*model.ParkedPosting, error) {
+	if s.parked == nil {
+		return nil, ErrParkedPostingsDisabled
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrParkedPostingNotFound
+	}
+	p, err := s.parked.GetParkedPosting(id)
+	if err != nil {
+		return nil, ErrParkedPostingNotFound
+	}
+	return p, nil
+}
+
+// ListParkedPostings returns the newest parked postings, optionally only
+// those in one status or parked for one reason
+func (s *LedgerService) ListParkedPostings(status model.ParkedPostingStatus, reason model.ParkReason, limit int) ([]model.ParkedPosting, error) {
+	if s.parked == nil {
+		return nil, ErrParkedPostingsDisabled
+	}
+	return s.parked.ListParkedPostings(status, reason, limit)
+}
+
+// RetryParkedPosting re-posts a parked posting now. A failed retry records
+// its error but does not count against the automatic retries; the posting
+// is returned either way, and its status tells whether it posted.
+func (s *LedgerService) RetryParkedPosting(id string) (*model.ParkedPosting, error) {
+	p, err := s.GetParkedPosting(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != model.ParkedPostingParked {
+		return nil, ErrParkedPostingState
+	}
+	return s.retryParked(p, false)
+}
+
+// RepairRequest corrects the accounts of a parked payment; empty IDs are left
+// as they are
+type RepairRequest struct {
+	FromAccountID string
+	ToAccountID   string
+	Note          string
+}
+
+// RepairParkedPosting corrects the accounts of a parked payment, recording
+// who did and why, and retries it at once as RetryParkedPosting does
+func (s *LedgerService) RepairParkedPosting(adminID, id string, req RepairRequest) (*model.ParkedPosting, error) {
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user ID")
+	}
+	note := strings.TrimSpace(req.Note)
+	if note == "" || (req.FromAccountID == "" && req.ToAccountID == "") {
+		return nil, ErrInvalidRepair
+	}
+	for _, accountID := range []string{req.FromAccountID, req.ToAccountID} {
+		if _, err := uuid.Parse(accountID); accountID != "" && err != nil {
+			return nil, ErrInvalidRepair
+		}
+	}
+
+	p, err := s.GetParkedPosting(id)
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != model.ParkedPostingParked {
+		return nil, ErrParkedPostingState
+	}
+	if req.FromAccountID != "" {
+		p.Event.FromAccountID = req.FromAccountID
+	}
+	if req.ToAccountID != "" {
+		p.Event.ToAccountID = req.ToAccountID
+	}
+	now := time.Now()
+	p.RepairedBy = &adminUUID
+	p.RepairNote = note
+	p.RepairedAt = &now
+	saved, err := s.parked.UpdateParkedPosting(p)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrParkedPostingState
+	}
+	slog.Info("Parked posting repaired", "parked_posting_id", p.ID, "payment_id", p.PaymentID, "repaired_by", adminID)
+	return s.retryParked(p, false)
+}
+
+// ParkedPostingRetryJob re-drives the parked postings whose retry is due
+func (s *LedgerService) ParkedPostingRetryJob(ctx context.Context, _ *jobs.Job) error {
+	posted, failed, err := s.RetryDueParkedPostings(time.Now())
+	if posted > 0 || failed > 0 {
+		slog.Info("Parked postings retried", "posted", posted, "failed", failed)
+	}
+	return err
+}
+
+// RetryDueParkedPostings retries the parked postings due by now. A posting
+// that fails again waits another delay, until its retries run out and its
+// payment fails. It returns how many postings posted and how many failed.
+func (s *LedgerService) RetryDueParkedPostings(now time.Time) (int, int, error) {
+	if s.parked == nil {
+		return 0, 0, ErrParkedPostingsDisabled
+	}
+	due, err := s.parked.ListDueParkedPostings(now, parkedRetryBatchSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list due parked postings: %w", err)
+	}
+
+	posted, failed := 0, 0
+	var errs []error
+	for i := range due {
+		p, err := s.retryParked(&due[i], true)
+		switch {
+		case errors.Is(err, ErrParkedPostingState):
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("failed to retry parked posting %s: %w", due[i].ID, err))
+		case p.Status == model.ParkedPostingPosted:
+			posted++
+		case p.Status == model.ParkedPostingFailed:
+			failed++
+		}
+	}
+	return posted, failed, errors.Join(errs...)
+}
+
+// retryParked posts a parked payment and publishes its result. When a retry
+// fails it records why; automatic retries also count the attempt and fail
+// the payment once retries run out.
+func (s *LedgerService) retryParked(p *model.ParkedPosting, automatic bool) (*model.ParkedPosting, error) {
+	entry := &model.JournalEntry{Description: paymentDescription(p.Event), Status: model.StatusPosted}
+	postErr := buildEntry(entry, paymentPostings(p.Event))
+	if postErr == nil {
+		reposted, err := s.parked.RepostParked(p.ID, entry)
+		switch {
+		case errors.Is(err, ErrParkedPostingState):
+			return nil, err
+		case err != nil:
+			postErr = err
+		case reposted.Status == model.ParkedPostingCancelled:
+			slog.Info("Dropped parked posting of a cancelled payment", "parked_posting_id", p.ID, "payment_id", p.PaymentID)
+			return reposted, nil
+		default:
+			s.Posted(entry)
+			event := reposted.Event
+			event.Status = "COMPLETED"
+			event.LedgerEntryID = entry.ID.String()
+			s.publishPaymentResult(kafka.TopicPaymentCompleted, event)
+			slog.Info("Parked posting posted", "parked_posting_id", p.ID, "payment_id", p.PaymentID, "entry_id", entry.ID)
+			return reposted, nil
+		}
+	}
+
+	p.ReasonCode = model.ParkReasonFor(postErr)
+	p.LastError = postErr.Error()
+	if automatic {
+		p.Attempts++
+		if p.Attempts >= s.parkingConfig.MaxRetries {
+			p.Status = model.ParkedPostingFailed
+			p.NextAttemptAt = nil
+		} else {
+			next := time.Now().Add(s.parkingConfig.RetryDelay)
+			p.NextAttemptAt = &next
+		}
+	}
+	// Only a posting still parked is updated, so a payment cancelled
+	// meanwhile is not failed as well
+	saved, err := s.parked.UpdateParkedPosting(p)
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrParkedPostingState
+	}
+	if p.Status == model.ParkedPostingFailed {
+		event := p.Event
+		event.Status = "FAILED"
+		s.publishPaymentResult(kafka.TopicPaymentFailed, event)
+		slog.Warn("Parked posting failed after its last retry", "parked_posting_id", p.ID, "payment_id", p.PaymentID, "reason", p.ReasonCode, "error", postErr)
+	}
+	return p, nil
+}
+
+// publishPaymentResult tells the payment service how a payment ended
+func (s *LedgerService) publishPaymentResult(topic string, event kafka.PaymentEvent) {
+	if s.producer == nil || s.afterCommit(func(s *LedgerService) { s.publishPaymentResult(topic, event) }) {
+		return
+	}
+	if err := s.producer.Produce(context.Background(), topic, event.PaymentID, event); err != nil {
+		slog.Error("Failed to publish payment result", "payment_id", event.PaymentID, "topic", topic, "error", err)
+	}
+}
+
+func paymentDescription(event kafka.PaymentEvent) string {
+	return "Payment: " + event.Description
+}
+
+// paymentPostings moves the amount from payer to payee and, in separate
+// postings of the same entry, the fee from the payer to the fee income account
+func paymentPostings(event kafka.PaymentEvent) []PostingRequest {
+	postings := []PostingRequest{
+		{AccountID: event.FromAccountID, Amount: event.Amount, Direction: -1},
+		{AccountID: event.ToAccountID, Amount: event.Amount, Direction: 1},
+	}
+	if event.Fee != "" && event.FeeAccountID != "" {
+		postings = append(postings,
+			PostingRequest{AccountID: event.FromAccountID, Amount: event.Fee, Direction: -1},
+			PostingRequest{AccountID: event.FeeAccountID, Amount: event.Fee, Direction: 1},
+		)
+	}
+	return postings
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryParked is an in-memory ParkedPostingRepository. Reposting fails with
+// postErr while it is set, and cancelled payments are never posted.
+type memoryParked struct {
+	postings  map[uuid.UUID]*model.ParkedPosting
+	postErr   error
+	cancelled map[uuid.UUID]bool
+	entries   []*model.JournalEntry
+}
+
+func newMemoryParked() *memoryParked {
+	return &memoryParked{postings: map[uuid.UUID]*model.ParkedPosting{}, cancelled: map[uuid.UUID]bool{}}
+}
+
+func (m *memoryParked) ParkPosting(p *model.ParkedPosting) error {
+	p.ID = uuid.New()
+	stored := *p
+	m.postings[p.ID] = &stored
+	return nil
+}
+
+func (m *memoryParked) GetParkedPosting(id string) (*model.ParkedPosting, error) {
+	p, ok := m.postings[uuid.MustParse(id)]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found := *p
+	return &found, nil
+}
+
+func (m *memoryParked) ListParkedPostings(status model.ParkedPostingStatus, reason model.ParkReason, limit int) ([]model.ParkedPosting, error) {
+	var out []model.ParkedPosting
+	for _, p := range m.postings {
+		if (status == "" || p.Status == status) && (reason == "" || p.ReasonCode == reason) {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryParked) ListDueParkedPostings(now time.Time, limit int) ([]model.ParkedPosting, error) {
+	var out []model.ParkedPosting
+	for _, p := range m.postings {
+		if p.Status == model.ParkedPostingParked && !p.NextAttemptAt.After(now) {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryParked) UpdateParkedPosting(p *model.ParkedPosting) (bool, error) {
+	if m.postings[p.ID].Status != model.ParkedPostingParked {
+		return false, nil
+	}
+	stored := *p
+	m.postings[p.ID] = &stored
+	return true, nil
+}
+
+func (m *memoryParked) RepostParked(id uuid.UUID, entry *model.JournalEntry) (*model.ParkedPosting, error) {
+	p := m.postings[id]
+	switch {
+	case p.Status != model.ParkedPostingParked:
+		return nil, model.ErrNotParked
+	case m.cancelled[p.PaymentID]:
+		p.Status = model.ParkedPostingCancelled
+	case m.postErr != nil:
+		return nil, m.postErr
+	default:
+		entry.ID = uuid.New()
+		m.entries = append(m.entries, entry)
+		p.Status = model.ParkedPostingPosted
+		p.EntryID = &entry.ID
+	}
+	p.NextAttemptAt = nil
+	reposted := *p
+	return &reposted, nil
+}
+
+func parkedPayment(t *testing.T, svc *LedgerService, repo *memoryParked, postErr error) *model.ParkedPosting {
+	t.Helper()
+	paymentID := uuid.New()
+	event := kafka.PaymentEvent{
+		PaymentID:     paymentID.String(),
+		FromAccountID: uuid.NewString(),
+		ToAccountID:   uuid.NewString(),
+		Amount:        "25.00",
+		Currency:      "GBP",
+		Description:   "Rent",
+	}
+	p, err := svc.ParkPaymentInTx(repo, paymentID, event, postErr)
+	require.NoError(t, err)
+	return p
+}
+
+func TestParkPaymentInTx(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+	_, err := svc.ParkPaymentInTx(newMemoryParked(), uuid.New(), kafka.PaymentEvent{}, model.ErrUnbalanced)
+	assert.ErrorIs(t, err, ErrParkedPostingsDisabled)
+
+	repo := newMemoryParked()
+	svc.SetParkedPostings(repo, ParkingConfig{RetryDelay: time.Minute})
+	frozen := &model.RestrictionError{AccountID: uuid.New(), Type: model.RestrictionFullFreeze}
+	before := time.Now()
+	p := parkedPayment(t, svc, repo, fmt.Errorf("failed to post: %w", frozen))
+
+	assert.Equal(t, model.ParkedPostingParked, p.Status)
+	assert.Equal(t, model.ParkReasonAccountRestricted, p.ReasonCode)
+	assert.Equal(t, 0, p.Attempts)
+	require.NotNil(t, p.NextAttemptAt)
+	assert.WithinDuration(t, before.Add(time.Minute), *p.NextAttemptAt, time.Second)
+}
+
+func TestParkReasonFor(t *testing.T) {
+	assert.Equal(t, model.ParkReasonOverdraftLimit, model.ParkReasonFor(&model.OverdraftError{}))
+	assert.Equal(t, model.ParkReasonAccountNotFound, model.ParkReasonFor(fmt.Errorf("failed to lock account: %w", gorm.ErrRecordNotFound)))
+	assert.Equal(t, model.ParkReasonInvalidPosting, model.ParkReasonFor(model.ErrUnbalanced))
+	assert.Equal(t, model.ParkReasonInvalidPosting, model.ParkReasonFor(model.ErrInvalidPostingAccount))
+	assert.Equal(t, model.ParkReasonUnknown, model.ParkReasonFor(fmt.Errorf("connection reset")))
+}
+
+func TestRetryDueParkedPostings_FailsPaymentOnceRetriesRunOut(t *testing.T) {
+	repo := newMemoryParked()
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetParkedPostings(repo, ParkingConfig{RetryDelay: time.Minute, MaxRetries: 2})
+	frozen := &model.RestrictionError{AccountID: uuid.New(), Type: model.RestrictionDebitFreeze}
+	p := parkedPayment(t, svc, repo, frozen)
+	repo.postErr = frozen
+
+	// Not due yet
+	posted, failed, err := svc.RetryDueParkedPostings(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, posted+failed)
+
+	now := time.Now().Add(2 * time.Minute)
+	posted, failed, err = svc.RetryDueParkedPostings(now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, posted+failed)
+	stored := repo.postings[p.ID]
+	assert.Equal(t, model.ParkedPostingParked, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	assert.True(t, stored.NextAttemptAt.After(now.Add(-time.Minute)), "waits another delay")
+
+	_, failed, err = svc.RetryDueParkedPostings(now.Add(2 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, failed)
+	stored = repo.postings[p.ID]
+	assert.Equal(t, model.ParkedPostingFailed, stored.Status)
+	assert.Equal(t, 2, stored.Attempts)
+	assert.Nil(t, stored.NextAttemptAt)
+	assert.Empty(t, repo.entries)
+}
+
+func TestRetryDueParkedPostings_PostsOnceCauseIsFixed(t *testing.T) {
+	repo := newMemoryParked()
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetParkedPostings(repo, ParkingConfig{})
+	p := parkedPayment(t, svc, repo, &model.OverdraftError{})
+
+	posted, _, err := svc.RetryDueParkedPostings(time.Now().Add(DefaultParkedRetryDelay))
+	require.NoError(t, err)
+	assert.Equal(t, 1, posted)
+	stored := repo.postings[p.ID]
+	assert.Equal(t, model.ParkedPostingPosted, stored.Status)
+	require.Len(t, repo.entries, 1)
+	assert.Equal(t, repo.entries[0].ID, *stored.EntryID)
+	assert.Equal(t, "Payment: Rent", repo.entries[0].Description)
+	require.Len(t, repo.entries[0].Postings, 2)
+	assert.Equal(t, p.Event.FromAccountID, repo.entries[0].Postings[0].AccountID.String())
+}
+
+func TestRetryParkedPosting(t *testing.T) {
+	repo := newMemoryParked()
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetParkedPostings(repo, ParkingConfig{})
+	p := parkedPayment(t, svc, repo, &model.OverdraftError{})
+
+	// A manual retry that fails records why without using up a retry
+	repo.postErr = fmt.Errorf("failed to lock account: %w", gorm.ErrRecordNotFound)
+	retried, err := svc.RetryParkedPosting(p.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.ParkedPostingParked, retried.Status)
+	assert.Equal(t, model.ParkReasonAccountNotFound, retried.ReasonCode)
+	assert.Equal(t, 0, retried.Attempts)
+
+	// A cancelled payment is dropped without posting
+	repo.cancelled[p.PaymentID] = true
+	retried, err = svc.RetryParkedPosting(p.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.ParkedPostingCancelled, retried.Status)
+	assert.Empty(t, repo.entries)
+
+	_, err = svc.RetryParkedPosting(p.ID.String())
+	assert.ErrorIs(t, err, ErrParkedPostingState)
+	_, err = svc.RetryParkedPosting(uuid.NewString())
+	assert.ErrorIs(t, err, ErrParkedPostingNotFound)
+}
+
+func TestRepairParkedPosting(t *testing.T) {
+	repo := newMemoryParked()
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetParkedPostings(repo, ParkingConfig{})
+	p := parkedPayment(t, svc, repo, fmt.Errorf("failed to lock account: %w", gorm.ErrRecordNotFound))
+	admin := uuid.NewString()
+
+	_, err := svc.RepairParkedPosting(admin, p.ID.String(), RepairRequest{Note: "wrong payee"})
+	assert.ErrorIs(t, err, ErrInvalidRepair)
+	_, err = svc.RepairParkedPosting(admin, p.ID.String(), RepairRequest{ToAccountID: "not-a-uuid", Note: "wrong payee"})
+	assert.ErrorIs(t, err, ErrInvalidRepair)
+
+	payee := uuid.New()
+	repaired, err := svc.RepairParkedPosting(admin, p.ID.String(), RepairRequest{ToAccountID: payee.String(), Note: " wrong payee "})
+	require.NoError(t, err)
+	assert.Equal(t, model.ParkedPostingPosted, repaired.Status)
+	assert.Equal(t, payee.String(), repaired.Event.ToAccountID)
+	assert.Equal(t, p.Event.FromAccountID, repaired.Event.FromAccountID)
+	assert.Equal(t, admin, repaired.RepairedBy.String())
+	assert.Equal(t, "wrong payee", repaired.RepairNote)
+	require.Len(t, repo.entries, 1)
+	assert.Equal(t, payee, repo.entries[0].Postings[1].AccountID)
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// MaxBulkAccounts is the largest number of accounts one bulk request may provision
+const MaxBulkAccounts = 500
+
+var (
+	ErrBulkEmpty             = errors.New("at least one account is required")
+	ErrBulkTooLarge          = fmt.Errorf("at most %d accounts can be provisioned per request", MaxBulkAccounts)
+	ErrBulkReferenceRequired = errors.New("reference is required")
+)
+
+var validAccountTypes = map[model.AccountType]bool{
+	model.Asset:     true,
+	model.Liability: true,
+	model.Equity:    true,
+	model.Income:    true,
+	model.Expense:   true,
+}
+
+// BulkAccountItem describes one account to provision
+type BulkAccountItem struct {
+	UserID        string
+	AccountNumber string
+	Name          string
+	Currency      string
+	Type          model.AccountType
+}
+
+// ProvisionAccounts validates every item and creates all valid accounts in a single
+// transaction. Invalid items are reported in the results and do not block the rest.
+// Repeating a request with the same reference returns the stored batch and
+// replayed=true without creating anything.
+func (s *LedgerService) ProvisionAccounts(requestedBy, reference string, items []BulkAccountItem) (batch *model.ProvisioningBatch, replayed bool, err error) {
+	requesterUUID, err := uuid.Parse(requestedBy)
+	if err != nil {
+		return nil, false, errors.New("invalid user ID")
+	}
+	if reference == "" {
+		return nil, false, ErrBulkReferenceRequired
+	}
+	if len(items) == 0 {
+		return nil, false, ErrBulkEmpty
+	}
+	if len(items) > MaxBulkAccounts {
+		return nil, false, ErrBulkTooLarge
+	}
+
+	if existing, err := s.Repo.GetProvisioningBatch(reference); err == nil {
+		return existing, true, nil
+	}
+
+	numbers := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.AccountNumber != "" {
+			numbers = append(numbers, item.AccountNumber)
+		}
+	}
+	taken, err := s.Repo.FindExistingAccountNumbers(numbers)
+	if err != nil {
+		return nil, false, err
+	}
+	takenSet := make(map[string]bool, len(taken))
+	for _, n := range taken {
+		takenSet[n] = true
+	}
+
+	batch = &model.ProvisioningBatch{
+		Reference:   reference,
+		RequestedBy: requesterUUID,
+		Total:       len(items),
+		Results:     make([]model.ProvisioningResult, 0, len(items)),
+	}
+	accounts := make([]model.Account, 0, len(items))
+	seen := make(map[string]int, len(items))
+
+	for i, item := range items {
+		result := model.ProvisioningResult{Index: i, AccountNumber: item.AccountNumber}
+		result.Errors = validateBulkItem(item)
+
+		if item.AccountNumber != "" {
+			if first, dup := seen[item.AccountNumber]; dup {
+				result.Errors = append(result.Errors, fmt.Sprintf("account_number duplicates item %d", first))
+			} else {
+				seen[item.AccountNumber] = i
+			}
+			if takenSet[item.AccountNumber] {
+				result.Errors = append(result.Errors, "account_number already exists")
+			}
+		}
+
+		if len(result.Errors) > 0 {
+			result.Status = model.ProvisioningRejected
+			batch.Rejected++
+		} else {
+			id := uuid.New()
+			accounts = append(accounts, model.Account{
+				ID:            id,
+				UserID:        uuid.MustParse(item.UserID),
+				AccountNumber: item.AccountNumber,
+				Name:          item.Name,
+				Type:          item.Type,
+				CurrencyCode:  item.Currency,
+				CachedBalance: decimal.Zero,
+			})
+			result.Status = model.ProvisioningCreated
+			result.AccountID = &id
+			batch.Created++
+		}
+		batch.Results = append(batch.Results, result)
+	}
+
+	ptrs := make([]*model.Account, len(accounts))
+	for i := range accounts {
+		ptrs[i] = &accounts[i]
+	}
+	if err := s.assignAliases(ptrs); err != nil {
+		slog.Warn("Failed to assign account aliases", "reference", reference, "error", err)
+	}
+
+	if err := s.Repo.CreateAccountsBatch(batch, accounts); err != nil {
+		// A concurrent request with the same reference may have won the race
+		if existing, getErr := s.Repo.GetProvisioningBatch(reference); getErr == nil {
+			return existing, true, nil
+		}
+		return nil, false, err
+	}
+
+	s.invalidateAccountLists(accounts)
+	s.publishAccountsCreated(reference, accounts)
+	return batch, false, nil
+}
+
+// validateBulkItem returns the validation errors for one item
+func validateBulkItem(item BulkAccountItem) []string {
+	var errs []string
+	if _, err := uuid.Parse(item.UserID); err != nil {
+		errs = append(errs, "user_id must be a valid UUID")
+	}
+	if item.AccountNumber == "" || len(item.AccountNumber) > 20 {
+		errs = append(errs, "account_number must be 1 to 20 characters")
+	}
+	if item.Name == "" || len(item.Name) > 100 {
+		errs = append(errs, "name must be 1 to 100 characters")
+	}
+	if _, err := money.Exponent(item.Currency); err != nil {
+		errs = append(errs, "currency must be a 3-letter ISO code")
+	}
+	if !validAccountTypes[item.Type] {
+		errs = append(errs, "type must be one of ASSET, LIABILITY, EQUITY, INCOME, EXPENSE")
+	}
+	return errs
+}
+
+func (s *LedgerService) invalidateAccountLists(accounts []model.Account) {
+	if s.cache == nil || s.afterCommit(func(s *LedgerService) { s.invalidateAccountLists(accounts) }) {
+		return
+	}
+	ctx := context.Background()
+	users := make(map[uuid.UUID]bool)
+	for _, acc := range accounts {
+		if !users[acc.UserID] {
+			users[acc.UserID] = true
+			s.cache.Delete(ctx, "accounts:list:"+acc.UserID.String())
+		}
+	}
+	s.cache.Delete(ctx, "accounts:list")
+}
+
+// publishAccountsCreated emits one account.created event per account in a single batch write
+func (s *LedgerService) publishAccountsCreated(reference string, accounts []model.Account) {
+	if s.producer == nil || len(accounts) == 0 || s.afterCommit(func(s *LedgerService) { s.publishAccountsCreated(reference, accounts) }) {
+		return
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	messages := make([]kafka.Message, 0, len(accounts))
+	for _, acc := range accounts {
+		messages = append(messages, kafka.Message{
+			Key: acc.ID.String(),
+			Value: kafka.AccountEvent{
+				AccountID:      acc.ID.String(),
+				UserID:         acc.UserID.String(),
+				AccountNumber:  acc.AccountNumber,
+				Type:           string(acc.Type),
+				CurrencyCode:   acc.CurrencyCode,
+				BatchReference: reference,
+				Timestamp:      now,
+			},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := s.producer.ProduceBatch(ctx, kafka.TopicAccountCreated, messages); err != nil {
+		slog.Error("Failed to publish account.created events", "reference", reference, "count", len(messages), "error", err)
+	}
+}
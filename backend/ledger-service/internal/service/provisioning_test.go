@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionAccounts_ReportsPerItemResults(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	service := NewLedgerService(mockRepo)
+	userID := uuid.New().String()
+
+	items := []BulkAccountItem{
+		{UserID: userID, AccountNumber: "1001", Name: "Checking", Currency: "USD", Type: model.Liability},
+		{UserID: "not-a-uuid", AccountNumber: "1002", Name: "Savings", Currency: "usd", Type: "SAVINGS"},
+		{UserID: userID, AccountNumber: "1001", Name: "Duplicate", Currency: "USD", Type: model.Liability},
+		{UserID: userID, AccountNumber: "9999", Name: "Taken", Currency: "EUR", Type: model.Liability},
+	}
+
+	mockRepo.On("GetProvisioningBatch", "onboard-1").Return(nil, errors.New("record not found"))
+	mockRepo.On("FindExistingAccountNumbers", []string{"1001", "1002", "1001", "9999"}).Return([]string{"9999"}, nil)
+	mockRepo.On("CreateAccountsBatch", mock.AnythingOfType("*model.ProvisioningBatch"), mock.MatchedBy(func(accounts []model.Account) bool {
+		return len(accounts) == 1 && accounts[0].AccountNumber == "1001"
+	})).Return(nil)
+
+	batch, replayed, err := service.ProvisionAccounts(uuid.New().String(), "onboard-1", items)
+
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, 4, batch.Total)
+	assert.Equal(t, 1, batch.Created)
+	assert.Equal(t, 3, batch.Rejected)
+
+	assert.Equal(t, model.ProvisioningCreated, batch.Results[0].Status)
+	assert.NotNil(t, batch.Results[0].AccountID)
+	assert.Equal(t, model.ProvisioningRejected, batch.Results[1].Status)
+	assert.Len(t, batch.Results[1].Errors, 3)
+	assert.Contains(t, batch.Results[2].Errors, "account_number duplicates item 0")
+	assert.Contains(t, batch.Results[3].Errors, "account_number already exists")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestProvisionAccounts_ReplaysExistingReference(t *testing.T) {
+	mockRepo := new(MockLedgerRepo)
+	service := NewLedgerService(mockRepo)
+
+	stored := &model.ProvisioningBatch{Reference: "onboard-1", Total: 1, Created: 1}
+	mockRepo.On("GetProvisioningBatch", "onboard-1").Return(stored, nil)
+
+	batch, replayed, err := service.ProvisionAccounts(uuid.New().String(), "onboard-1", []BulkAccountItem{
+		{UserID: uuid.New().String(), AccountNumber: "1001", Name: "Checking", Currency: "USD", Type: model.Liability},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Same(t, stored, batch)
+	mockRepo.AssertNotCalled(t, "CreateAccountsBatch", mock.Anything, mock.Anything)
+}
+
+func TestProvisionAccounts_RejectsInvalidRequests(t *testing.T) {
+	service := NewLedgerService(new(MockLedgerRepo))
+	requester := uuid.New().String()
+
+	_, _, err := service.ProvisionAccounts(requester, "", []BulkAccountItem{{}})
+	assert.ErrorIs(t, err, ErrBulkReferenceRequired)
+
+	_, _, err = service.ProvisionAccounts(requester, "ref", nil)
+	assert.ErrorIs(t, err, ErrBulkEmpty)
+
+	_, _, err = service.ProvisionAccounts(requester, "ref", make([]BulkAccountItem, MaxBulkAccounts+1))
+	assert.ErrorIs(t, err, ErrBulkTooLarge)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/lock"
+)
+
+const (
+	// ReconciliationLockName guards the reconciliation job across replicas
+	ReconciliationLockName = "ledger-reconciliation"
+	// ReconciliationLockTTL is how long the lock survives a replica that dies mid-run
+	ReconciliationLockTTL = 2 * time.Minute
+	// maxReportedMismatches bounds how many mismatching accounts one run reports
+	maxReportedMismatches = 100
+)
+
+var ErrReconciliationDisabled = errors.New("reconciliation is not enabled")
+
+// ReconciliationRepository compares stored balances with the postings behind them
+type ReconciliationRepository interface {
+	FindBalanceMismatches(limit int) ([]model.BalanceMismatch, error)
+}
+
+// JobLocker runs a function while holding a named distributed lock
+type JobLocker interface {
+	WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context, l *lock.Lock) error) error
+}
+
+// SetReconciliation enables the balance reconciliation job. With a locker only
+// one replica runs it at a time; without one every replica runs it.
+func (s *LedgerService) SetReconciliation(repo ReconciliationRepository, locker JobLocker) {
+	s.reconciliation = repo
+	s.locker = locker
+}
+
+// Reconcile checks every account's cached and held balances against its
+// postings and logs the accounts that disagree
+func (s *LedgerService) Reconcile() ([]model.BalanceMismatch, error) {
+	if s.reconciliation == nil {
+		return nil, ErrReconciliationDisabled
+	}
+	mismatches, err := s.reconciliation.FindBalanceMismatches(maxReportedMismatches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile balances: %w", err)
+	}
+	for _, m := range mismatches {
+		slog.Error("Account balance does not match its postings",
+			"account_id", m.AccountID,
+			"cached_balance", m.CachedBalance,
+			"posted_balance", m.PostedBalance,
+			"held_balance", m.HeldBalance,
+			"pending_outflow", m.PendingOutflow)
+	}
+	return mismatches, nil
+}
+
+// runReconciliation runs Reconcile under the reconciliation lock. It reports
+// skipped=true when another replica holds the lock.
+func (s *LedgerService) runReconciliation(ctx context.Context) (mismatches []model.BalanceMismatch, skipped bool, err error) {
+	if s.locker == nil {
+		mismatches, err = s.Reconcile()
+		return mismatches, false, err
+	}
+	err = s.locker.WithLock(ctx, ReconciliationLockName, ReconciliationLockTTL, func(ctx context.Context, l *lock.Lock) error {
+		var runErr error
+		mismatches, runErr = s.Reconcile()
+		return runErr
+	})
+	if errors.Is(err, lock.ErrNotAcquired) {
+		return nil, true, nil
+	}
+	return mismatches, false, err
+}
+
+// ReconciliationJob reconciles balances. The job queue already runs each
+// scheduled run once; the lock still keeps a manual retry from overlapping it.
+func (s *LedgerService) ReconciliationJob(ctx context.Context, _ *jobs.Job) error {
+	mismatches, skipped, err := s.runReconciliation(ctx)
+	switch {
+	case err != nil:
+		return err
+	case skipped:
+		slog.Debug("Balance reconciliation running on another replica")
+	default:
+		slog.Info("Balance reconciliation finished", "mismatches", len(mismatches))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/lock"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockReconciliationRepo struct {
+	mock.Mock
+}
+
+func (m *MockReconciliationRepo) FindBalanceMismatches(limit int) ([]model.BalanceMismatch, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]model.BalanceMismatch), args.Error(1)
+}
+
+// fakeLocker grants the lock unless held is set
+type fakeLocker struct {
+	held  bool
+	names []string
+}
+
+func (f *fakeLocker) WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context, l *lock.Lock) error) error {
+	f.names = append(f.names, name)
+	if f.held {
+		return lock.ErrNotAcquired
+	}
+	return fn(ctx, nil)
+}
+
+func TestReconcile_ReportsMismatches(t *testing.T) {
+	repo := new(MockReconciliationRepo)
+	mismatch := model.BalanceMismatch{
+		AccountID:     uuid.New(),
+		CachedBalance: decimal.NewFromInt(100),
+		PostedBalance: decimal.NewFromInt(90),
+	}
+	repo.On("FindBalanceMismatches", maxReportedMismatches).Return([]model.BalanceMismatch{mismatch}, nil)
+
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetReconciliation(repo, nil)
+
+	mismatches, err := svc.Reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, []model.BalanceMismatch{mismatch}, mismatches)
+}
+
+func TestReconcile_Disabled(t *testing.T) {
+	_, err := NewLedgerService(new(MockLedgerRepo)).Reconcile()
+	assert.ErrorIs(t, err, ErrReconciliationDisabled)
+}
+
+func TestRunReconciliation_GuardedByLock(t *testing.T) {
+	repo := new(MockReconciliationRepo)
+	repo.On("FindBalanceMismatches", maxReportedMismatches).Return([]model.BalanceMismatch{}, nil)
+	locker := &fakeLocker{}
+
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetReconciliation(repo, locker)
+
+	_, skipped, err := svc.runReconciliation(context.Background())
+	require.NoError(t, err)
+	assert.False(t, skipped)
+	assert.Equal(t, []string{ReconciliationLockName}, locker.names)
+	repo.AssertNumberOfCalls(t, "FindBalanceMismatches", 1)
+
+	// Another replica holds the lock: the run is skipped without querying
+	locker.held = true
+	_, skipped, err = svc.runReconciliation(context.Background())
+	require.NoError(t, err)
+	assert.True(t, skipped)
+	repo.AssertNumberOfCalls(t, "FindBalanceMismatches", 1)
+}
+
+func TestRunReconciliation_PropagatesErrors(t *testing.T) {
+	repo := new(MockReconciliationRepo)
+	repo.On("FindBalanceMismatches", maxReportedMismatches).Return([]model.BalanceMismatch(nil), errors.New("db down"))
+
+	svc := NewLedgerService(new(MockLedgerRepo))
+	svc.SetReconciliation(repo, &fakeLocker{})
+
+	_, skipped, err := svc.runReconciliation(context.Background())
+	assert.False(t, skipped)
+	assert.ErrorContains(t, err, "db down")
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// MaxRecentTransactions bounds how many transactions are read per account
+const MaxRecentTransactions = 100
+
+var ErrTransactionHistoryDisabled = errors.New("transaction history is not enabled")
+
+// TransactionHistoryRepository reads accounts' postings for their history
+type TransactionHistoryRepository interface {
+	// ListRecentTransactions returns up to limit of the newest postings of each account
+	ListRecentTransactions(accountIDs []uuid.UUID, limit int) ([]model.AccountTransaction, error)
+}
+
+// SetTransactionHistory enables reading accounts' recent transactions
+func (s *LedgerService) SetTransactionHistory(repo TransactionHistoryRepository) {
+	s.history = repo
+}
+
+// RecentTransactions returns the newest transactions of each account, newest
+// first, keyed by account. It reads all the accounts at once, so callers
+// listing several accounts make one query rather than one per account. The
+// accounts must already have been checked as visible to the caller.
+func (s *LedgerService) RecentTransactions(accountIDs []uuid.UUID, limit int) (map[uuid.UUID][]model.AccountTransaction, error) {
+	if s.history == nil {
+		return nil, ErrTransactionHistoryDisabled
+	}
+	if limit <= 0 || limit > MaxRecentTransactions {
+		limit = MaxRecentTransactions
+	}
+	txs, err := s.history.ListRecentTransactions(accountIDs, limit)
+	if err != nil {
+		return nil, err
+	}
+	byAccount := make(map[uuid.UUID][]model.AccountTransaction, len(accountIDs))
+	for _, id := range accountIDs {
+		byAccount[id] = []model.AccountTransaction{}
+	}
+	for _, tx := range txs {
+		if list, ok := byAccount[tx.AccountID]; ok && len(list) < limit {
+			byAccount[tx.AccountID] = append(list, tx)
+		}
+	}
+	return byAccount, nil
+}
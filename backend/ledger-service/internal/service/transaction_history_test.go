@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryHistory is an in-memory TransactionHistoryRepository
+type memoryHistory struct {
+	txs   []model.AccountTransaction
+	calls int
+}
+
+func (m *memoryHistory) ListRecentTransactions(accountIDs []uuid.UUID, limit int) ([]model.AccountTransaction, error) {
+	m.calls++
+	counts := map[uuid.UUID]int{}
+	var out []model.AccountTransaction
+	for _, tx := range m.txs {
+		for _, id := range accountIDs {
+			if tx.AccountID == id && counts[id] < limit {
+				counts[id]++
+				out = append(out, tx)
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestRecentTransactions(t *testing.T) {
+	svc := NewLedgerService(nil)
+	_, err := svc.RecentTransactions([]uuid.UUID{uuid.New()}, 10)
+	assert.ErrorIs(t, err, ErrTransactionHistoryDisabled)
+
+	busy, quiet := uuid.New(), uuid.New()
+	repo := &memoryHistory{}
+	for i := 0; i < 5; i++ {
+		repo.txs = append(repo.txs, model.AccountTransaction{PostingID: uuid.New(), AccountID: busy})
+	}
+	svc.SetTransactionHistory(repo)
+
+	byAccount, err := svc.RecentTransactions([]uuid.UUID{busy, quiet}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.calls, "all accounts are read at once")
+	assert.Len(t, byAccount[busy], 3)
+	assert.NotNil(t, byAccount[quiet], "accounts without transactions get an empty list")
+	assert.Empty(t, byAccount[quiet])
+
+	byAccount, err = svc.RecentTransactions([]uuid.UUID{busy}, 0)
+	require.NoError(t, err)
+	assert.Len(t, byAccount[busy], 5, "a limit out of range reads the maximum")
+}
@@ -0,0 +1,442 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// TransactionImportJobKind is the job that books an import's rows
+	TransactionImportJobKind = "ledger.transaction_import"
+	// MaxImportRows bounds the transactions of one file
+	MaxImportRows = 20000
+	// MaxImportDescription matches the longest description a transfer may carry
+	MaxImportDescription = 500
+	// importChunkSize is how many rows are booked, with their progress, per
+	// database transaction
+	importChunkSize = 200
+)
+
+var (
+	ErrImportsDisabled         = errors.New("transaction imports are not enabled")
+	ErrInvalidImportFile       = errors.New("invalid import file")
+	ErrImportRowsInvalid       = errors.New("import file has invalid rows")
+	ErrImportNotFound          = errors.New("transaction import not found")
+	ErrImportSuspense          = errors.New("migration suspense account is not usable")
+	ErrImportReferenceRequired = errors.New("reference is required")
+	ErrImportTooManyRows       = fmt.Errorf("at most %d transactions can be imported per file", MaxImportRows)
+)
+
+// ImportRepository stores transaction imports and books their rows
+type ImportRepository interface {
+	CreateTransactionImport(imp *model.TransactionImport) error
+	GetTransactionImport(id string) (*model.TransactionImport, error)
+	GetTransactionImportByReference(reference string) (*model.TransactionImport, error)
+	// ListTransactionImports returns the newest imports without their rows
+	ListTransactionImports(limit int) ([]model.TransactionImport, error)
+	SetTransactionImportStatus(id uuid.UUID, status model.ImportStatus) error
+	// PostImportChunk books entries[i] for rows[i] and records the progress on
+	// imp in one transaction. An entry that cannot be booked is rolled back on
+	// its own and recorded as a row error. It returns the booked entries.
+	PostImportChunk(imp *model.TransactionImport, rows []model.ImportRow, entries []*model.JournalEntry) ([]*model.JournalEntry, error)
+}
+
+// ImportQueue runs imports in the background; *jobs.Runner implements it
+type ImportQueue interface {
+	Enqueue(ctx context.Context, kind string, payload interface{}, opts ...jobs.EnqueueOption) (*jobs.Job, error)
+}
+
+// SetImports enables historical transaction imports. defaultSuspenseAccount
+// is the account the other side of each row is booked to when a request does
+// not name one; it may be empty.
+func (s *LedgerService) SetImports(repo ImportRepository, queue ImportQueue, defaultSuspenseAccount string) {
+	s.imports = repo
+	s.importQueue = queue
+	s.importSuspense = defaultSuspenseAccount
+}
+
+// ImportRequest is a file of historical transactions to import
+type ImportRequest struct {
+	Reference   string
+	RequestedBy string
+	Format      string
+	FileName    string
+	Data        []byte
+	// AccountID receives rows that do not name an account, and every row of an OFX file
+	AccountID         string
+	SuspenseAccountID string
+}
+
+// ImportReport is the outcome of validating an import file
+type ImportReport struct {
+	Format    model.ImportFormat `json:"format"`
+	TotalRows int                `json:"total_rows"`
+	ValidRows int                `json:"valid_rows"`
+	// MoneyIn and MoneyOut total the valid rows, for reconciling with the source bank
+	MoneyIn  decimal.Decimal     `json:"money_in"`
+	MoneyOut decimal.Decimal     `json:"money_out"`
+	Errors   []model.ImportError `json:"errors"`
+}
+
+type importPayload struct {
+	ImportID string `json:"import_id"`
+}
+
+// ValidateImport parses and checks a file without booking anything
+func (s *LedgerService) ValidateImport(req ImportRequest) (*ImportReport, error) {
+	report, _, _, err := s.validateImport(req)
+	return report, err
+}
+
+// StartImport validates a file and, if every row is valid, queues its rows to
+// be booked in the background. Invalid rows return ErrImportRowsInvalid with
+// the report and nothing is imported. Repeating a request with the same
+// reference returns the stored import and replayed=true.
+func (s *LedgerService) StartImport(ctx context.Context, req ImportRequest) (imp *model.TransactionImport, report *ImportReport, replayed bool, err error) {
+	if s.imports == nil {
+		return nil, nil, false, ErrImportsDisabled
+	}
+	requester, err := uuid.Parse(req.RequestedBy)
+	if err != nil {
+		return nil, nil, false, errors.New("invalid user ID")
+	}
+	if req.Reference == "" {
+		return nil, nil, false, ErrImportReferenceRequired
+	}
+	if existing, err := s.imports.GetTransactionImportByReference(req.Reference); err == nil {
+		// Queue it again in case the first request failed after storing it
+		if existing.Status == model.ImportPending {
+			if err := s.queueImport(ctx, existing); err != nil {
+				return nil, nil, false, err
+			}
+		}
+		return existing, nil, true, nil
+	}
+
+	report, rows, suspense, err := s.validateImport(req)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(report.Errors) > 0 {
+		return nil, report, false, ErrImportRowsInvalid
+	}
+
+	imp = &model.TransactionImport{
+		Reference:         req.Reference,
+		Format:            report.Format,
+		FileName:          req.FileName,
+		SuspenseAccountID: suspense,
+		RequestedBy:       requester,
+		Status:            model.ImportPending,
+		TotalRows:         len(rows),
+		Rows:              rows,
+		Errors:            []model.ImportError{},
+	}
+	if err := s.imports.CreateTransactionImport(imp); err != nil {
+		// A concurrent request with the same reference may have won the race
+		if existing, getErr := s.imports.GetTransactionImportByReference(req.Reference); getErr == nil {
+			return existing, nil, true, nil
+		}
+		return nil, nil, false, err
+	}
+	if err := s.queueImport(ctx, imp); err != nil {
+		return nil, nil, false, err
+	}
+	return imp, report, false, nil
+}
+
+// queueImport enqueues the job that books an import, once per import
+func (s *LedgerService) queueImport(ctx context.Context, imp *model.TransactionImport) error {
+	_, err := s.importQueue.Enqueue(ctx, TransactionImportJobKind, importPayload{ImportID: imp.ID.String()},
+		jobs.UniqueKey(TransactionImportJobKind+":"+imp.ID.String()))
+	if err != nil {
+		return fmt.Errorf("failed to queue import: %w", err)
+	}
+	return nil
+}
+
+// GetImport returns an import with its progress and row errors
+func (s *LedgerService) GetImport(id string) (*model.TransactionImport, error) {
+	if s.imports == nil {
+		return nil, ErrImportsDisabled
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrImportNotFound
+	}
+	imp, err := s.imports.GetTransactionImport(id)
+	if err != nil {
+		return nil, ErrImportNotFound
+	}
+	return imp, nil
+}
+
+// ListImports returns the most recent imports
+func (s *LedgerService) ListImports(limit int) ([]model.TransactionImport, error) {
+	if s.imports == nil {
+		return nil, ErrImportsDisabled
+	}
+	return s.imports.ListTransactionImports(limit)
+}
+
+// TransactionImportJob books the rows of an import in chunks. Progress is
+// committed with each chunk, so a retried job continues where it stopped.
+func (s *LedgerService) TransactionImportJob(ctx context.Context, job *jobs.Job) error {
+	if s.imports == nil {
+		return ErrImportsDisabled
+	}
+	var payload importPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+	imp, err := s.imports.GetTransactionImport(payload.ImportID)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrImportNotFound, payload.ImportID)
+	}
+	if imp.Status == model.ImportCompleted {
+		return nil
+	}
+	if imp.Status == model.ImportPending {
+		if err := s.imports.SetTransactionImportStatus(imp.ID, model.ImportRunning); err != nil {
+			return err
+		}
+		imp.Status = model.ImportRunning
+	}
+
+	for imp.ProcessedRows < len(imp.Rows) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rows := imp.Rows[imp.ProcessedRows:min(imp.ProcessedRows+importChunkSize, len(imp.Rows))]
+		entries := make([]*model.JournalEntry, len(rows))
+		for i, row := range rows {
+			entries[i] = importEntry(imp, row)
+		}
+		posted, err := s.imports.PostImportChunk(imp, rows, entries)
+		if err != nil {
+			return err
+		}
+		s.importPosted(posted)
+	}
+
+	slog.Info("Transaction import completed", "import_id", imp.ID, "imported", imp.ImportedRows, "failed", imp.FailedRows)
+	return nil
+}
+
+// importPosted does the work of Posted for a chunk of entries, clearing each
+// account's cache once
+func (s *LedgerService) importPosted(entries []*model.JournalEntry) {
+	accounts := map[string]bool{}
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			accounts[p.AccountID.String()] = true
+		}
+		s.categorizeEntry(entry)
+		s.publishJournal(entry)
+	}
+	ids := make([]string, 0, len(accounts))
+	for id := range accounts {
+		ids = append(ids, id)
+	}
+	s.invalidateAccounts(ids)
+}
+
+// importEntry books a row against the suspense account, dated when the
+// source bank booked it
+func importEntry(imp *model.TransactionImport, row model.ImportRow) *model.JournalEntry {
+	direction := 1 // Money in debits the customer's account
+	if row.Amount.IsNegative() {
+		direction = -1
+	}
+	amount := row.Amount.Abs()
+	return &model.JournalEntry{
+		TransactionDate: row.Date,
+		Description:     row.Description,
+		ReferenceID:     fmt.Sprintf("import:%s:%d", imp.ID, row.Row),
+		Status:          model.StatusPosted,
+		Postings: []model.Posting{
+			{AccountID: row.AccountID, Amount: amount, Direction: direction},
+			{AccountID: imp.SuspenseAccountID, Amount: amount, Direction: -direction},
+		},
+	}
+}
+
+// validateImport parses the file and checks every row, returning the valid
+// rows and the suspense account they are booked against
+func (s *LedgerService) validateImport(req ImportRequest) (*ImportReport, []model.ImportRow, uuid.UUID, error) {
+	if s.imports == nil {
+		return nil, nil, uuid.Nil, ErrImportsDisabled
+	}
+	var defaultAccount uuid.UUID
+	if req.AccountID != "" {
+		var err error
+		if defaultAccount, err = uuid.Parse(req.AccountID); err != nil {
+			return nil, nil, uuid.Nil, fmt.Errorf("%w: account_id must be a UUID", ErrInvalidImportFile)
+		}
+	}
+
+	suspenseID := req.SuspenseAccountID
+	if suspenseID == "" {
+		suspenseID = s.importSuspense
+	}
+	suspense, err := s.importSuspenseAccount(suspenseID)
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+
+	format, err := DetectImportFormat(req.Format, req.FileName, req.Data)
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+	parsed, rowErrors, err := parseImport(format, req.Data, defaultAccount)
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+	total := len(parsed) + len(rowErrors)
+	if total == 0 {
+		return nil, nil, uuid.Nil, fmt.Errorf("%w: the file has no transactions", ErrInvalidImportFile)
+	}
+	if total > MaxImportRows {
+		return nil, nil, uuid.Nil, ErrImportTooManyRows
+	}
+
+	closedThrough, err := s.closedThrough()
+	if err != nil {
+		return nil, nil, uuid.Nil, err
+	}
+
+	report := &ImportReport{Format: format, TotalRows: total, MoneyIn: decimal.Zero, MoneyOut: decimal.Zero, Errors: rowErrors}
+	checker := &importChecker{svc: s, suspense: suspense, now: time.Now(), closedThrough: closedThrough, accounts: map[uuid.UUID]*importAccount{}, references: map[string]int{}}
+	rows := make([]model.ImportRow, 0, len(parsed))
+	for _, row := range parsed {
+		if problems := checker.check(row); len(problems) > 0 {
+			report.Errors = append(report.Errors, model.ImportError{Row: row.Row, Message: strings.Join(problems, "; ")})
+			continue
+		}
+		rows = append(rows, row)
+		if row.Amount.IsPositive() {
+			report.MoneyIn = report.MoneyIn.Add(row.Amount)
+		} else {
+			report.MoneyOut = report.MoneyOut.Add(row.Amount.Neg())
+		}
+	}
+	report.ValidRows = len(rows)
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+	return report, rows, suspense.ID, nil
+}
+
+// importSuspenseAccount loads the account imports are balanced against
+func (s *LedgerService) importSuspenseAccount(id string) (*model.Account, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: no suspense_account_id given and no default configured", ErrImportSuspense)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: suspense_account_id must be a UUID", ErrImportSuspense)
+	}
+	acc, err := s.Repo.GetAccount(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: account %s not found", ErrImportSuspense, id)
+	}
+	return acc, nil
+}
+
+// importAccount caches what a row needs to know about its account
+type importAccount struct {
+	account *model.Account
+	// snapshotted is the end of the account's latest balance snapshot; earlier
+	// entries would not be reflected in balances read from snapshots
+	snapshotted time.Time
+	err         string
+}
+
+// importChecker validates the rows of one file
+type importChecker struct {
+	svc      *LedgerService
+	suspense *model.Account
+	now      time.Time
+	// closedThrough is the end of the latest closed accounting period;
+	// earlier rows would be refused
+	closedThrough time.Time
+	accounts      map[uuid.UUID]*importAccount
+	references    map[string]int
+}
+
+func (c *importChecker) check(row model.ImportRow) []string {
+	var problems []string
+	switch {
+	case row.Amount.IsZero():
+		problems = append(problems, "amount must not be zero")
+	default:
+		if _, err := money.FromDecimal(row.Amount, c.suspense.CurrencyCode); err != nil {
+			problems = append(problems, fmt.Sprintf("amount has more decimal places than %s allows", c.suspense.CurrencyCode))
+		}
+	}
+	if row.Date.After(c.now) {
+		problems = append(problems, "date is in the future")
+	}
+	if row.Date.Before(c.closedThrough) {
+		problems = append(problems, fmt.Sprintf("date is in a closed accounting period; the books are closed through %s", model.PeriodOf(c.closedThrough.AddDate(0, 0, -1))))
+	}
+	if len(row.Description) > MaxImportDescription {
+		problems = append(problems, fmt.Sprintf("description is longer than %d characters", MaxImportDescription))
+	}
+	if row.ExternalID != "" {
+		if first, dup := c.references[row.ExternalID]; dup {
+			problems = append(problems, fmt.Sprintf("reference %q duplicates row %d", row.ExternalID, first))
+		} else {
+			c.references[row.ExternalID] = row.Row
+		}
+	}
+
+	if row.AccountID == uuid.Nil {
+		return append(problems, "account_id is required")
+	}
+	acc := c.account(row.AccountID)
+	if acc.err != "" {
+		return append(problems, acc.err)
+	}
+	if !acc.snapshotted.IsZero() && !row.Date.After(acc.snapshotted) {
+		problems = append(problems, fmt.Sprintf("date is not after the account's balance snapshot of %s", acc.snapshotted.Format(time.RFC3339)))
+	}
+	return problems
+}
+
+func (c *importChecker) account(id uuid.UUID) *importAccount {
+	if acc, ok := c.accounts[id]; ok {
+		return acc
+	}
+	acc := &importAccount{}
+	c.accounts[id] = acc
+
+	account, err := c.svc.Repo.GetAccount(id.String())
+	switch {
+	case err != nil:
+		acc.err = "account not found"
+	case account.ID == c.suspense.ID:
+		acc.err = "account is the suspense account"
+	case account.CurrencyCode != c.suspense.CurrencyCode:
+		acc.err = fmt.Sprintf("account currency %s differs from the suspense account's %s", account.CurrencyCode, c.suspense.CurrencyCode)
+	default:
+		acc.account = account
+	}
+	if acc.err == "" && c.svc.snapshots != nil {
+		snapshot, err := c.svc.snapshots.LatestSnapshot(id.String(), c.now)
+		switch {
+		case err != nil:
+			acc.err = "balance snapshots could not be read"
+		case snapshot != nil:
+			acc.snapshotted = snapshot.ClosingAt
+		}
+	}
+	return acc
+}
@@ -0,0 +1,343 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryImports is an in-memory ImportRepository. Entries on accounts in
+// closed fail to book, as they would once the account is gone.
+type memoryImports struct {
+	imports map[uuid.UUID]*model.TransactionImport
+	posted  []*model.JournalEntry
+	closed  map[uuid.UUID]bool
+}
+
+func newMemoryImports() *memoryImports {
+	return &memoryImports{imports: map[uuid.UUID]*model.TransactionImport{}, closed: map[uuid.UUID]bool{}}
+}
+
+func (m *memoryImports) CreateTransactionImport(imp *model.TransactionImport) error {
+	imp.ID = uuid.New()
+	m.imports[imp.ID] = imp
+	return nil
+}
+
+func (m *memoryImports) GetTransactionImport(id string) (*model.TransactionImport, error) {
+	imp, ok := m.imports[uuid.MustParse(id)]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	copied := *imp
+	return &copied, nil
+}
+
+func (m *memoryImports) GetTransactionImportByReference(reference string) (*model.TransactionImport, error) {
+	for _, imp := range m.imports {
+		if imp.Reference == reference {
+			return imp, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (m *memoryImports) ListTransactionImports(limit int) ([]model.TransactionImport, error) {
+	var out []model.TransactionImport
+	for _, imp := range m.imports {
+		out = append(out, *imp)
+	}
+	return out, nil
+}
+
+func (m *memoryImports) SetTransactionImportStatus(id uuid.UUID, status model.ImportStatus) error {
+	m.imports[id].Status = status
+	return nil
+}
+
+func (m *memoryImports) PostImportChunk(imp *model.TransactionImport, rows []model.ImportRow, entries []*model.JournalEntry) ([]*model.JournalEntry, error) {
+	var posted []*model.JournalEntry
+	for i, entry := range entries {
+		if m.closed[entry.Postings[0].AccountID] {
+			imp.Errors = append(imp.Errors, model.ImportError{Row: rows[i].Row, Message: "failed to lock account"})
+			imp.FailedRows++
+		} else {
+			posted = append(posted, entry)
+			imp.ImportedRows++
+		}
+		imp.ProcessedRows++
+	}
+	if imp.ProcessedRows >= imp.TotalRows {
+		imp.Status = model.ImportCompleted
+	}
+	stored := *imp
+	m.imports[imp.ID] = &stored
+	m.posted = append(m.posted, posted...)
+	return posted, nil
+}
+
+// recordingQueue collects enqueued jobs instead of running them
+type recordingQueue struct {
+	jobs []*jobs.Job
+}
+
+func (q *recordingQueue) Enqueue(_ context.Context, kind string, payload interface{}, _ ...jobs.EnqueueOption) (*jobs.Job, error) {
+	job := &jobs.Job{ID: uuid.New(), Kind: kind}
+	job.Payload, _ = json.Marshal(payload)
+	q.jobs = append(q.jobs, job)
+	return job, nil
+}
+
+type importFixture struct {
+	svc      *LedgerService
+	repo     *memoryImports
+	queue    *recordingQueue
+	account  *model.Account
+	suspense *model.Account
+}
+
+func newImportFixture(t *testing.T) *importFixture {
+	t.Helper()
+	f := &importFixture{
+		repo:     newMemoryImports(),
+		queue:    &recordingQueue{},
+		account:  &model.Account{ID: uuid.New(), CurrencyCode: "GBP"},
+		suspense: &model.Account{ID: uuid.New(), CurrencyCode: "GBP"},
+	}
+	ledger := new(MockLedgerRepo)
+	ledger.On("GetAccount", f.account.ID.String()).Return(f.account, nil)
+	ledger.On("GetAccount", f.suspense.ID.String()).Return(f.suspense, nil)
+	ledger.On("GetAccount", mock.Anything).Return(nil, errors.New("record not found"))
+	f.svc = NewLedgerService(ledger)
+	f.svc.SetImports(f.repo, f.queue, f.suspense.ID.String())
+	return f
+}
+
+func (f *importFixture) request(format, data string) ImportRequest {
+	return ImportRequest{
+		Reference:   "migration-1",
+		RequestedBy: uuid.New().String(),
+		Format:      format,
+		Data:        []byte(data),
+		AccountID:   f.account.ID.String(),
+	}
+}
+
+func TestParseImportCSV(t *testing.T) {
+	account := uuid.New()
+	other := uuid.New()
+	data := "\xef\xbb\xbfDate,Amount,Description,Account_ID,Reference\n" +
+		"2024-01-15,1500.00,Salary,,TX1\n" +
+		"2024-01-16T09:30:00+01:00,-42.5,\"Groceries, weekly\"," + other.String() + ",TX2\n" +
+		"\n" +
+		"15/01/2024,abc,Bad,,TX3\n"
+
+	rows, rowErrors, err := parseImportCSV([]byte(data), account)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, 2, rows[0].Row)
+	assert.Equal(t, account, rows[0].AccountID)
+	assert.Equal(t, "1500", rows[0].Amount.String())
+	assert.Equal(t, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), rows[0].Date)
+	assert.Equal(t, other, rows[1].AccountID)
+	assert.Equal(t, "Groceries, weekly", rows[1].Description)
+	assert.Equal(t, time.Date(2024, 1, 16, 8, 30, 0, 0, time.UTC), rows[1].Date)
+
+	require.Len(t, rowErrors, 1)
+	assert.Equal(t, 5, rowErrors[0].Row)
+	assert.Contains(t, rowErrors[0].Message, "YYYY-MM-DD")
+	assert.Contains(t, rowErrors[0].Message, "amount must be a number")
+
+	_, _, err = parseImportCSV([]byte("when,amount\n2024-01-01,1\n"), account)
+	assert.ErrorIs(t, err, ErrInvalidImportFile)
+}
+
+func TestParseImportOFX(t *testing.T) {
+	account := uuid.New()
+	// OFX 1.x SGML leaves elements unclosed
+	data := `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240115120000.000[-5:EST]
+<TRNAMT>1500.00
+<FITID>2024011501
+<NAME>ACME LTD
+<MEMO>Salary &amp; bonus
+</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240116<TRNAMT>-42.50<FITID>2024011601<NAME>Corner Shop</STMTTRN>
+<STMTTRN><DTPOSTED>yesterday<TRNAMT>-1</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+
+	rows, rowErrors, err := parseImportOFX([]byte(data), account)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, time.Date(2024, 1, 15, 17, 0, 0, 0, time.UTC), rows[0].Date)
+	assert.Equal(t, "1500", rows[0].Amount.String())
+	assert.Equal(t, "ACME LTD - Salary & bonus", rows[0].Description)
+	assert.Equal(t, "2024011501", rows[0].ExternalID)
+	assert.Equal(t, account, rows[0].AccountID)
+	assert.Equal(t, "-42.5", rows[1].Amount.String())
+	assert.Equal(t, "Corner Shop", rows[1].Description)
+
+	require.Len(t, rowErrors, 1)
+	assert.Equal(t, 3, rowErrors[0].Row)
+
+	format, err := DetectImportFormat("", "statement.txt", []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, model.ImportFormatOFX, format)
+	_, _, err = parseImportOFX([]byte("date,amount\n"), account)
+	assert.ErrorIs(t, err, ErrInvalidImportFile)
+}
+
+func TestValidateImport_ReportsEveryBadRow(t *testing.T) {
+	f := newImportFixture(t)
+	future := time.Now().AddDate(0, 0, 2).Format("2006-01-02")
+	data := "date,amount,description,account_id,reference\n" +
+		"2024-01-15,100,Salary,,A\n" +
+		"2024-01-16,-30,Rent,,B\n" +
+		"2024-01-17,0,Nothing,,C\n" +
+		future + ",10,Tomorrow,,D\n" +
+		"2024-01-18,10,Again,,A\n" +
+		"2024-01-19,10,Elsewhere," + uuid.New().String() + ",E\n" +
+		"2024-01-20,10,Suspense," + f.suspense.ID.String() + ",F\n" +
+		"2024-01-21,0.001,Tiny,,G\n" +
+		"2024-01-22,1,Long " + strings.Repeat("x", MaxImportDescription) + ",,H\n"
+
+	report, err := f.svc.ValidateImport(f.request("", data))
+	require.NoError(t, err)
+	assert.Equal(t, model.ImportFormatCSV, report.Format)
+	assert.Equal(t, 9, report.TotalRows)
+	assert.Equal(t, 2, report.ValidRows)
+	assert.Equal(t, "100", report.MoneyIn.String())
+	assert.Equal(t, "30", report.MoneyOut.String())
+
+	messages := map[int]string{}
+	for _, e := range report.Errors {
+		messages[e.Row] = e.Message
+	}
+	assert.Contains(t, messages[4], "must not be zero")
+	assert.Contains(t, messages[5], "in the future")
+	assert.Contains(t, messages[6], `duplicates row 2`)
+	assert.Contains(t, messages[7], "account not found")
+	assert.Contains(t, messages[8], "suspense account")
+	assert.Contains(t, messages[9], "more decimal places than GBP allows")
+	assert.Contains(t, messages[10], "longer than")
+
+	// Nothing is stored or queued, and a real import of the file is refused
+	_, report, _, err = f.svc.StartImport(context.Background(), f.request("", data))
+	assert.ErrorIs(t, err, ErrImportRowsInvalid)
+	assert.Len(t, report.Errors, 7)
+	assert.Empty(t, f.repo.imports)
+	assert.Empty(t, f.queue.jobs)
+}
+
+func TestValidateImport_SuspenseAccount(t *testing.T) {
+	f := newImportFixture(t)
+	data := "date,amount\n2024-01-15,100\n"
+
+	req := f.request("csv", data)
+	req.SuspenseAccountID = uuid.New().String()
+	_, err := f.svc.ValidateImport(req)
+	assert.ErrorIs(t, err, ErrImportSuspense)
+
+	f.svc.SetImports(f.repo, f.queue, "")
+	_, err = f.svc.ValidateImport(f.request("csv", data))
+	assert.ErrorIs(t, err, ErrImportSuspense)
+
+	// A suspense account in another currency cannot balance the rows
+	f.suspense.CurrencyCode = "EUR"
+	req.SuspenseAccountID = f.suspense.ID.String()
+	report, err := f.svc.ValidateImport(req)
+	require.NoError(t, err)
+	require.Len(t, report.Errors, 1)
+	assert.Contains(t, report.Errors[0].Message, "differs from the suspense account's EUR")
+}
+
+func TestStartImport_BooksRowsInChunks(t *testing.T) {
+	f := newImportFixture(t)
+	closed := uuid.New()
+	f.svc.Repo.(*MockLedgerRepo).ExpectedCalls = nil
+	f.svc.Repo.(*MockLedgerRepo).On("GetAccount", f.suspense.ID.String()).Return(f.suspense, nil)
+	f.svc.Repo.(*MockLedgerRepo).On("GetAccount", mock.Anything).Return(f.account, nil)
+
+	var data strings.Builder
+	data.WriteString("date,amount,description,account_id\n")
+	total := importChunkSize + 50
+	for i := 0; i < total; i++ {
+		account := ""
+		if i == importChunkSize+10 {
+			account = closed.String()
+		}
+		data.WriteString("2024-02-01,-12.34,Card payment," + account + "\n")
+	}
+
+	ctx := context.Background()
+	imp, report, replayed, err := f.svc.StartImport(ctx, f.request("", data.String()))
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, total, report.ValidRows)
+	assert.Equal(t, model.ImportPending, imp.Status)
+	require.Len(t, f.queue.jobs, 1)
+
+	// The account went away after validation; its row fails alone
+	f.repo.closed[closed] = true
+	require.NoError(t, f.svc.TransactionImportJob(ctx, f.queue.jobs[0]))
+
+	done, err := f.svc.GetImport(imp.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.ImportCompleted, done.Status)
+	assert.Equal(t, total, done.ProcessedRows)
+	assert.Equal(t, total-1, done.ImportedRows)
+	assert.Equal(t, 1, done.FailedRows)
+	require.Len(t, done.Errors, 1)
+	assert.Equal(t, importChunkSize+12, done.Errors[0].Row)
+
+	entry := f.repo.posted[0]
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), entry.TransactionDate)
+	assert.Equal(t, "Card payment", entry.Description)
+	require.Len(t, entry.Postings, 2)
+	assert.Equal(t, -1, entry.Postings[0].Direction)
+	assert.Equal(t, f.suspense.ID, entry.Postings[1].AccountID)
+	assert.Equal(t, 1, entry.Postings[1].Direction)
+	assert.True(t, entry.Postings[0].Amount.Equal(decimal.RequireFromString("12.34")))
+
+	// The same reference returns the import without queueing it again
+	again, _, replayed, err := f.svc.StartImport(ctx, f.request("", data.String()))
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	assert.Equal(t, imp.ID, again.ID)
+	assert.Len(t, f.queue.jobs, 1)
+
+	// Running the job again does nothing
+	require.NoError(t, f.svc.TransactionImportJob(ctx, f.queue.jobs[0]))
+	assert.Len(t, f.repo.posted, total-1)
+}
+
+func TestValidateImport_SnapshottedHistory(t *testing.T) {
+	f := newImportFixture(t)
+	snapshots := new(MockSnapshotRepo)
+	snapshots.On("LatestSnapshot", f.account.ID.String(), mock.Anything).
+		Return(&model.BalanceSnapshot{ClosingAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}, nil)
+	f.svc.SetSnapshots(snapshots)
+
+	report, err := f.svc.ValidateImport(f.request("csv", "date,amount\n2024-02-29,5\n2024-03-01T10:00:00Z,5\n"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.ValidRows)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 2, report.Errors[0].Row)
+	assert.Contains(t, report.Errors[0].Message, "balance snapshot")
+}
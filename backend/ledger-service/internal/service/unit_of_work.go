@@ -0,0 +1,57 @@
+package service
+
+// UnitOfWork is the database transaction of one request
+type UnitOfWork interface {
+	// Bind returns repo bound to the transaction, or repo itself if it
+	// cannot join it
+	Bind(repo any) any
+	// AfterCommit runs fn once the transaction commits, and never if it
+	// rolls back
+	AfterCommit(fn func())
+}
+
+// WithUnitOfWork returns a copy of the service whose repositories write
+// through uow's transaction, so a request's writes commit or roll back
+// together. Its cache invalidations and events wait for the commit, so nobody
+// sees writes that may still roll back.
+func (s *LedgerService) WithUnitOfWork(uow UnitOfWork) *LedgerService {
+	if uow == nil {
+		return s
+	}
+	bound := *s
+	bound.uow, bound.committed = uow, s
+	bound.Repo = bindRepo(uow, s.Repo)
+	bound.categories = bindRepo(uow, s.categories)
+	bound.snapshots = bindRepo(uow, s.snapshots)
+	bound.reconciliation = bindRepo(uow, s.reconciliation)
+	bound.journalAudit = bindRepo(uow, s.journalAudit)
+	bound.aliases = bindRepo(uow, s.aliases)
+	bound.imports = bindRepo(uow, s.imports)
+	bound.restrictions = bindRepo(uow, s.restrictions)
+	bound.overdrafts = bindRepo(uow, s.overdrafts)
+	bound.parked = bindRepo(uow, s.parked)
+	bound.periods = bindRepo(uow, s.periods)
+	bound.accruals = bindRepo(uow, s.accruals)
+	bound.history = bindRepo(uow, s.history)
+	return &bound
+}
+
+// bindRepo returns repo bound to uow's transaction, keeping its type
+func bindRepo[T any](uow UnitOfWork, repo T) T {
+	if bound, ok := uow.Bind(repo).(T); ok {
+		return bound
+	}
+	return repo
+}
+
+// afterCommit defers fn until the service's unit of work commits, then runs
+// it on the service outside the unit of work, and reports whether it did.
+// Outside a unit of work it reports false and the caller goes ahead.
+func (s *LedgerService) afterCommit(fn func(s *LedgerService)) bool {
+	if s.uow == nil {
+		return false
+	}
+	committed := s.committed
+	s.uow.AfterCommit(func() { fn(committed) })
+	return true
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUnitOfWork binds ledger repositories to its own and holds the hooks
+// deferred until commit
+type fakeUnitOfWork struct {
+	repo  *MockLedgerRepo
+	hooks []func()
+}
+
+func (u *fakeUnitOfWork) Bind(repo any) any {
+	if _, ok := repo.(*MockLedgerRepo); ok {
+		return u.repo
+	}
+	return repo
+}
+
+func (u *fakeUnitOfWork) AfterCommit(fn func()) {
+	u.hooks = append(u.hooks, fn)
+}
+
+func TestWithUnitOfWork_WritesThroughTransaction(t *testing.T) {
+	serviceRepo := new(MockLedgerRepo)
+	uow := &fakeUnitOfWork{repo: new(MockLedgerRepo)}
+	svc := NewLedgerService(serviceRepo)
+	svc.SetRestrictions(nil)
+	assert.Same(t, svc, svc.WithUnitOfWork(nil))
+
+	bound := svc.WithUnitOfWork(uow)
+	uow.repo.On("PostTransaction", mock.AnythingOfType("*model.JournalEntry")).Return(nil)
+	_, err := bound.PostTransaction("Transfer", transferPostings(
+		"00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-000000000002", "10.00"))
+	require.NoError(t, err)
+	uow.repo.AssertExpectations(t)
+	serviceRepo.AssertNotCalled(t, "PostTransaction", mock.Anything)
+	assert.Same(t, serviceRepo, svc.Repo, "the service itself is unchanged")
+	assert.Nil(t, bound.restrictions, "optional repositories stay unset")
+}
+
+func TestAfterCommit(t *testing.T) {
+	svc := NewLedgerService(new(MockLedgerRepo))
+	var ranOn *LedgerService
+	run := func(s *LedgerService) { ranOn = s }
+
+	// Outside a unit of work the caller goes ahead itself
+	assert.False(t, svc.afterCommit(run))
+	assert.Nil(t, ranOn)
+
+	uow := &fakeUnitOfWork{repo: new(MockLedgerRepo)}
+	assert.True(t, svc.WithUnitOfWork(uow).afterCommit(run))
+	assert.Nil(t, ranOn, "deferred until commit")
+	require.Len(t, uow.hooks, 1)
+	uow.hooks[0]()
+	assert.Same(t, svc, ranOn, "runs on the service outside the unit of work")
+}
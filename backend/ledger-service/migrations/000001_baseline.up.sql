@@ -0,0 +1,70 @@
+-- Baseline schema for ledger-service, matching what GORM AutoMigrate created.
+-- Statements use IF NOT EXISTS so databases created by AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS accounts (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    account_number varchar(20) NOT NULL,
+    name varchar(100),
+    type varchar(20) NOT NULL,
+    currency_code char(3) NOT NULL,
+    status varchar(20) DEFAULT 'ACTIVE',
+    balance_version bigint DEFAULT 0,
+    cached_balance numeric(19,4) DEFAULT 0,
+    held_balance numeric(19,4) DEFAULT 0,
+    metadata jsonb,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_accounts_user_id ON accounts (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_accounts_account_number ON accounts (account_number);
+CREATE INDEX IF NOT EXISTS idx_accounts_deleted_at ON accounts (deleted_at);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_date timestamptz NOT NULL,
+    description text,
+    reference_id varchar(100),
+    status varchar(20) DEFAULT 'POSTED',
+    finalized_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reference_id ON journal_entries (reference_id);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    journal_entry_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    direction smallint NOT NULL,
+    created_at timestamptz,
+    CONSTRAINT fk_journal_entries_postings FOREIGN KEY (journal_entry_id) REFERENCES journal_entries (id),
+    CONSTRAINT chk_postings_amount CHECK (amount > 0),
+    CONSTRAINT chk_postings_direction CHECK (direction IN (1, -1))
+);
+CREATE INDEX IF NOT EXISTS idx_postings_journal_entry_id ON postings (journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_account_id ON postings (account_id);
+
+CREATE TABLE IF NOT EXISTS provisioning_batches (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    reference varchar(100) NOT NULL,
+    requested_by uuid NOT NULL,
+    total bigint NOT NULL,
+    created bigint NOT NULL,
+    rejected bigint NOT NULL,
+    results jsonb,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_provisioning_batches_reference ON provisioning_batches (reference);
+
+CREATE TABLE IF NOT EXISTS feature_flags (
+    key varchar(100) PRIMARY KEY,
+    description text,
+    enabled boolean DEFAULT false,
+    rollout_percentage bigint DEFAULT 0,
+    allowed_users text,
+    updated_by varchar(100),
+    created_at timestamptz,
+    updated_at timestamptz
+);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_journal_entries_transaction_date;
+DROP TABLE IF EXISTS posting_categories;
@@ -0,0 +1,15 @@
+CREATE TABLE posting_categories (
+    posting_id uuid PRIMARY KEY REFERENCES postings (id),
+    journal_entry_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    category varchar(20) NOT NULL,
+    source varchar(10) NOT NULL,
+    merchant varchar(100),
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_posting_categories_journal_entry_id ON posting_categories (journal_entry_id);
+CREATE INDEX idx_posting_categories_account_id ON posting_categories (account_id);
+
+-- Spend reports scan a user's outgoing postings by date
+CREATE INDEX idx_journal_entries_transaction_date ON journal_entries (transaction_date);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_journal_entries_reverses_entry_id;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS reverses_entry_id;
@@ -0,0 +1,2 @@
+ALTER TABLE journal_entries ADD COLUMN reverses_entry_id uuid REFERENCES journal_entries (id);
+CREATE INDEX idx_journal_entries_reverses_entry_id ON journal_entries (reverses_entry_id);
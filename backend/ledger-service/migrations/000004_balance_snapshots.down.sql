@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_journal_entries_finalized_at;
+DROP TABLE IF EXISTS balance_snapshots;
@@ -0,0 +1,12 @@
+CREATE TABLE balance_snapshots (
+    account_id uuid NOT NULL REFERENCES accounts (id),
+    date date NOT NULL,
+    closing_at timestamptz NOT NULL,
+    balance numeric(19,4) NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (account_id, date)
+);
+CREATE INDEX idx_balance_snapshots_account_closing ON balance_snapshots (account_id, closing_at);
+
+-- Deltas since a snapshot read an account's postings by booking time
+CREATE INDEX idx_journal_entries_finalized_at ON journal_entries (finalized_at);
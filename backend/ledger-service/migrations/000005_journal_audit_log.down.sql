@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS journal_audit_log;
+DROP FUNCTION IF EXISTS journal_audit_log_immutable();
@@ -0,0 +1,27 @@
+CREATE TABLE journal_audit_log (
+    sequence bigserial PRIMARY KEY,
+    journal_entry_id uuid NOT NULL,
+    action varchar(20) NOT NULL,
+    status varchar(20) NOT NULL,
+    payload text NOT NULL,
+    prev_hash char(64) NOT NULL,
+    hash char(64) NOT NULL,
+    recorded_at timestamptz NOT NULL
+);
+CREATE INDEX idx_journal_audit_log_journal_entry_id ON journal_audit_log (journal_entry_id);
+CREATE UNIQUE INDEX idx_journal_audit_log_hash ON journal_audit_log (hash);
+
+-- The audit log is append-only: updates and deletes are rejected outright.
+-- Tampering that bypasses this (e.g. by dropping the trigger) still breaks the hash chain.
+CREATE FUNCTION journal_audit_log_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'journal_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER journal_audit_log_no_update
+    BEFORE UPDATE OR DELETE ON journal_audit_log
+    FOR EACH ROW EXECUTE FUNCTION journal_audit_log_immutable();
+CREATE TRIGGER journal_audit_log_no_truncate
+    BEFORE TRUNCATE ON journal_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION journal_audit_log_immutable();
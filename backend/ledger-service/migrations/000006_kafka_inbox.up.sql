@@ -0,0 +1,12 @@
+-- Consumed Kafka messages, written in the same transaction as the postings they
+-- caused so a redelivered payment event is not posted twice
+CREATE TABLE kafka_inbox (
+    consumer_group varchar(100) NOT NULL,
+    topic varchar(255) NOT NULL,
+    partition bigint NOT NULL,
+    "offset" bigint NOT NULL,
+    message_key varchar(255),
+    processed_at timestamptz NOT NULL,
+    PRIMARY KEY (consumer_group, topic, partition, "offset")
+);
+CREATE INDEX idx_kafka_inbox_processed_at ON kafka_inbox (processed_at);
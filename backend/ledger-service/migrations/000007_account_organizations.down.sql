@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_accounts_org_id;
+ALTER TABLE accounts DROP COLUMN IF EXISTS org_id;
@@ -0,0 +1,3 @@
+-- Business accounts belong to an organization; personal accounts have none
+ALTER TABLE accounts ADD COLUMN org_id uuid;
+CREATE INDEX idx_accounts_org_id ON accounts (org_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_accounts_sort_code_number;
+DROP INDEX IF EXISTS idx_accounts_iban;
+ALTER TABLE accounts
+    DROP COLUMN IF EXISTS bank_account_number,
+    DROP COLUMN IF EXISTS sort_code,
+    DROP COLUMN IF EXISTS iban;
+DROP SEQUENCE IF EXISTS account_alias_seq;
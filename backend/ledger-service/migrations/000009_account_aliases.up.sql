@@ -0,0 +1,9 @@
+-- Account aliases: a sort code with an 8-digit account number and the IBAN built
+-- from them. Numbers are drawn from a sequence, so each is issued only once.
+CREATE SEQUENCE IF NOT EXISTS account_alias_seq;
+ALTER TABLE accounts
+    ADD COLUMN iban varchar(34),
+    ADD COLUMN sort_code char(6),
+    ADD COLUMN bank_account_number char(8);
+CREATE UNIQUE INDEX idx_accounts_iban ON accounts (iban);
+CREATE UNIQUE INDEX idx_accounts_sort_code_number ON accounts (sort_code, bank_account_number);
@@ -0,0 +1,21 @@
+-- Historical transaction imports for customers migrated from another bank.
+-- rows holds the parsed file so an interrupted import resumes where it stopped.
+CREATE TABLE transaction_imports (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    reference varchar(100) NOT NULL,
+    format varchar(10) NOT NULL,
+    file_name varchar(255),
+    suspense_account_id uuid NOT NULL,
+    requested_by uuid NOT NULL,
+    status varchar(20) NOT NULL,
+    total_rows bigint NOT NULL,
+    processed_rows bigint NOT NULL,
+    imported_rows bigint NOT NULL,
+    failed_rows bigint NOT NULL,
+    rows jsonb,
+    errors jsonb,
+    created_at timestamptz,
+    updated_at timestamptz,
+    completed_at timestamptz
+);
+CREATE UNIQUE INDEX idx_transaction_imports_reference ON transaction_imports (reference);
@@ -0,0 +1,17 @@
+-- Freezes and legal holds placed on accounts by admins. Lifted restrictions
+-- are kept as the audit trail; at most one of each type is active at a time.
+CREATE TABLE account_restrictions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id uuid NOT NULL REFERENCES accounts (id),
+    type varchar(20) NOT NULL,
+    reason varchar(500) NOT NULL,
+    note text,
+    placed_by uuid NOT NULL,
+    lifted_by uuid,
+    lifted_at timestamptz,
+    lift_note text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_account_restrictions_account_id ON account_restrictions (account_id);
+CREATE UNIQUE INDEX idx_account_restrictions_active ON account_restrictions (account_id, type) WHERE lifted_at IS NULL;
@@ -0,0 +1,11 @@
+-- Payments the ledger consumer has handled, keyed by the payment service's ID.
+-- A payment cancelled before it was posted is skipped; one cancelled after is
+-- reversed, and the reversing entry is recorded here.
+CREATE TABLE ledger_payments (
+    payment_id uuid PRIMARY KEY,
+    entry_id uuid REFERENCES journal_entries (id),
+    cancelled_at timestamptz,
+    reversal_entry_id uuid REFERENCES journal_entries (id),
+    created_at timestamptz,
+    updated_at timestamptz
+);
@@ -0,0 +1,3 @@
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_held_balance_minor_units;
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_cached_balance_minor_units;
+DROP FUNCTION IF EXISTS currency_exponent(char(3));
@@ -0,0 +1,18 @@
+-- currency_exponent is the number of decimal places of an ISO 4217 currency's
+-- minor unit, matching shared-lib/pkg/money: 0 for JPY, 3 for KWD, 2 for most
+CREATE FUNCTION currency_exponent(code char(3)) RETURNS integer AS $$
+    SELECT CASE
+        WHEN code IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN code IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        WHEN code IN ('CLF', 'UYW') THEN 4
+        ELSE 2
+    END;
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+
+-- Balances hold whole minor units of the account's currency. The constraints
+-- are NOT VALID so existing rows are not checked; run VALIDATE CONSTRAINT once
+-- any sub-unit balances have been corrected.
+ALTER TABLE accounts ADD CONSTRAINT accounts_cached_balance_minor_units
+    CHECK (cached_balance = round(cached_balance, currency_exponent(currency_code))) NOT VALID;
+ALTER TABLE accounts ADD CONSTRAINT accounts_held_balance_minor_units
+    CHECK (held_balance = round(held_balance, currency_exponent(currency_code))) NOT VALID;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS overdraft_interest_accruals;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_interest_rate;
+ALTER TABLE accounts DROP COLUMN IF EXISTS overdraft_limit;
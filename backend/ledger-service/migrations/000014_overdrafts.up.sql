@@ -0,0 +1,18 @@
+-- Overdraft facilities: accounts with a limit may go negative down to minus
+-- the limit; accounts without one (NULL) are not policed by the ledger
+ALTER TABLE accounts ADD COLUMN overdraft_limit numeric(19,4);
+ALTER TABLE accounts ADD COLUMN overdraft_interest_rate numeric(7,6) NOT NULL DEFAULT 0;
+
+-- A day's interest on an overdrawn balance, charged monthly
+CREATE TABLE overdraft_interest_accruals (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id uuid NOT NULL REFERENCES accounts (id),
+    accrual_date date NOT NULL,
+    balance numeric(19,4) NOT NULL,
+    rate numeric(7,6) NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    charged_entry_id uuid,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_overdraft_accruals_account_date ON overdraft_interest_accruals (account_id, accrual_date);
+CREATE INDEX idx_overdraft_interest_accruals_charged_entry_id ON overdraft_interest_accruals (charged_entry_id);
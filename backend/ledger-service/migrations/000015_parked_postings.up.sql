@@ -0,0 +1,20 @@
+-- Payments from the payment service that failed to post, kept for automatic
+-- retries and ops repair instead of failing the payment at once
+CREATE TABLE parked_postings (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id uuid NOT NULL,
+    event jsonb NOT NULL,
+    status varchar(20) NOT NULL,
+    reason_code varchar(30) NOT NULL,
+    last_error text,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz,
+    entry_id uuid REFERENCES journal_entries (id),
+    repaired_by uuid,
+    repair_note text,
+    repaired_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_parked_postings_payment_id ON parked_postings (payment_id);
+CREATE INDEX idx_parked_postings_due ON parked_postings (status, next_attempt_at);
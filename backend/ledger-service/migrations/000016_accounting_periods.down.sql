@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_journal_entries_adjusts_period;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS adjusts_period;
+DROP TABLE IF EXISTS accounting_period_summaries;
+DROP TABLE IF EXISTS accounting_periods;
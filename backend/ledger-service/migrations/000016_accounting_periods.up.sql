@@ -0,0 +1,27 @@
+-- Closed calendar months; entries dated within them are refused
+CREATE TABLE accounting_periods (
+    period char(7) PRIMARY KEY,
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    closed_by uuid NOT NULL,
+    closed_at timestamptz NOT NULL
+);
+CREATE UNIQUE INDEX idx_accounting_periods_ends_at ON accounting_periods (ends_at);
+
+-- Each account's activity over a closed period, generated at close
+CREATE TABLE accounting_period_summaries (
+    period char(7) NOT NULL REFERENCES accounting_periods (period),
+    account_id uuid NOT NULL REFERENCES accounts (id),
+    currency_code char(3) NOT NULL,
+    opening_balance numeric(19,4) NOT NULL,
+    debits numeric(19,4) NOT NULL,
+    credits numeric(19,4) NOT NULL,
+    closing_balance numeric(19,4) NOT NULL,
+    postings bigint NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (period, account_id)
+);
+
+-- Adjustments booked in an open period name the closed period they correct
+ALTER TABLE journal_entries ADD COLUMN adjusts_period char(7);
+CREATE INDEX idx_journal_entries_adjusts_period ON journal_entries (adjusts_period);
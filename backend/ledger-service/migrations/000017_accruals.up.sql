@@ -0,0 +1,21 @@
+-- Interest and fee micro-amounts, rolled up into one journal entry per
+-- account a month
+CREATE TABLE accruals (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id uuid NOT NULL REFERENCES accounts (id),
+    counterparty_account_id uuid NOT NULL REFERENCES accounts (id),
+    kind varchar(20) NOT NULL,
+    direction smallint NOT NULL CHECK (direction IN (1, -1)),
+    amount numeric(19,8) NOT NULL CHECK (amount > 0),
+    accrual_date date NOT NULL,
+    description text,
+    reference varchar(100) NOT NULL,
+    rollup_entry_id uuid,
+    rolled_up_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX idx_accruals_account_date ON accruals (account_id, accrual_date);
+CREATE UNIQUE INDEX idx_accruals_reference ON accruals (reference);
+CREATE INDEX idx_accruals_rollup_entry_id ON accruals (rollup_entry_id);
+-- Accruals not rolled up yet, for the monthly roll-up
+CREATE INDEX idx_accruals_pending ON accruals (accrual_date) WHERE rolled_up_at IS NULL;
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/ledger-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/featureflags"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsCoverModels(t *testing.T) {
+	migrations, err := db.LoadMigrations(FS)
+	require.NoError(t, err)
+	require.NoError(t, db.VerifyModels(migrations, &model.Account{}, &model.JournalEntry{}, &model.Posting{}, &model.ProvisioningBatch{}, &model.PostingCategory{}, &model.BalanceSnapshot{}, &model.JournalAuditRecord{}, &featureflags.Flag{}, &kafka.InboxMessage{}, &jobs.Job{}, &model.TransactionImport{}, &model.AccountRestriction{}, &model.LedgerPayment{}, &model.OverdraftInterestAccrual{}, &model.ParkedPosting{}, &model.AccountingPeriod{}, &model.AccountingPeriodSummary{}, &model.Accrual{}))
+}
+
+// The SQL currency_exponent function must agree with the money package, or the
+// database would refuse amounts the service accepts
+func TestCurrencyExponentMatchesMoney(t *testing.T) {
+	sql, err := FS.ReadFile("000013_currency_exponents.up.sql")
+	require.NoError(t, err)
+	exponents := map[string]int{}
+	for _, line := range strings.Split(string(sql), "\n") {
+		list, ok := strings.CutPrefix(strings.TrimSpace(line), "WHEN code IN (")
+		if !ok {
+			continue
+		}
+		codes, then, _ := strings.Cut(list, ") THEN ")
+		exp, err := strconv.Atoi(then)
+		require.NoError(t, err, line)
+		for _, code := range strings.Split(codes, ",") {
+			exponents[strings.Trim(code, "' ")] = exp
+		}
+	}
+	require.Equal(t, money.Exponents(), exponents)
+}
@@ -2,30 +2,77 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	apispec "github.com/femi-lawal/new_bank/backend/payment-service/api"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/bankdirectory"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/consumer"
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/handler"
-	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/repository"
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/payment-service/migrations"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/circuitbreaker"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
 	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/maintenance"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/serviceauth"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 const serviceName = "payment-service"
 
 func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema
+	command, args, err := db.ParseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Initialize Logger
 	logger.InitLogger(serviceName, true)
 	slog.Info("Starting Payment Service")
 
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	// Transfers are processed asynchronously through Kafka
+	cfg.Kafka.Async = true
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+	apperrors.Problems().RegisterAll(serviceProblemTypes)
+
 	// Initialize Tracing
 	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
 	if err != nil {
@@ -50,16 +97,39 @@ func main() {
 		panic(err)
 	}
 
-	// Auto Migrate
-	if err := database.AutoMigrate(&model.Payment{}); err != nil {
-		slog.Error("Failed to migrate database", "error", err)
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
+	}
+
+	// Creates the topics this service uses when they are missing, see topics.go;
+	// the outcome, including settings that drifted, is reported in /health
+	topicSettings, err := kafka.TopicSettingsFromEnv()
+	if err != nil {
+		slog.Error("Invalid Kafka topic settings", "error", err)
+		os.Exit(1)
 	}
+	topicAdmin := kafka.NewTopicAdmin(cfg.Kafka.Brokers)
+	go topicAdmin.Start(context.Background(), kafka.TopicSpecs(topicSettings, serviceTopics...))
 
 	// Initialize Kafka Producer
-	kafkaBrokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
 	var producer *kafka.Producer
 
-	producer = kafka.NewProducer(kafkaBrokers)
+	producer = kafka.NewProducer(cfg.Kafka.Brokers)
 	if producer != nil {
 		slog.Info("Kafka producer initialized")
 	}
@@ -72,10 +142,154 @@ func main() {
 	} else {
 		svc = service.NewPaymentService(repo)
 	}
+	// Every state change of a payment is kept for support investigations
+	svc.SetTimeline(repository.NewTransferTimelineRepository(database))
+	// The ledger's results for transfers it posts asynchronously; a transfer
+	// is cancellable until its result is recorded
+	resultConsumer := consumer.NewResultConsumer(cfg.Kafka.Brokers, svc)
+	go resultConsumer.Start(context.Background())
+	lagExporter := kafka.NewLagExporter(cfg.Kafka.Brokers, consumer.ConsumerGroup, consumer.Topics, kafka.DefaultLagInterval)
+	go lagExporter.Start(context.Background())
+	// Ledger calls carry a client credentials token when a service account is
+	// configured, and go through a circuit breaker so a ledger outage fails
+	// payments fast instead of holding requests open until they time out
+	ledgerClient := http.DefaultClient
+	if clientID := getEnv("SERVICE_CLIENT_ID", ""); clientID != "" {
+		tokenURL := getEnv("IDENTITY_SERVICE_URL", "http://localhost:8081") + "/auth/token"
+		creds := serviceauth.NewClientCredentials(tokenURL, clientID, requireEnv("SERVICE_CLIENT_SECRET"), "ledger:read", "ledger:write")
+		creds.Audience = []string{"ledger-service"}
+		ledgerClient = creds.Client(10 * time.Second)
+	} else {
+		slog.Warn("SERVICE_CLIENT_ID not set, ledger calls are unauthenticated")
+	}
+	// The ledger is told how long the payment request has left, so it stops work the caller has given up on
+	ledgerClient = &http.Client{Timeout: ledgerClient.Timeout, Transport: middleware.PropagateDeadline(ledgerClient.Transport)}
+	svc.SetLedgerClient(circuitbreaker.New(circuitbreaker.Config{Name: "ledger-service"}).Client(ledgerClient))
+	// Transfer velocity limits are counted in Redis, recent transfers are
+	// remembered there to catch duplicates, and payment links are stored there;
+	// without it none of these are available
+	var linkStore service.PaymentLinkStore
+	redisClient, err := cache.NewRedisClient(cache.Config{
+		Addr:     getEnv("REDIS_ADDR", "localhost:6379"),
+		Password: getEnv("REDIS_PASSWORD", ""),
+	})
+	if err != nil {
+		slog.Warn("Redis connection failed, transfer limits and duplicate payment checks are not enforced and payment links are disabled", "error", err)
+	} else {
+		// The Redis-backed checks fail open, so with the breaker open transfers
+		// go ahead without waiting on Redis timeouts
+		redisClient.UseCircuitBreaker(circuitbreaker.New(circuitbreaker.Config{Name: "redis"}))
+		svc.SetTransferLimiter(service.NewTransferLimiter(service.NewRedisLimitStore(redisClient), transferLimitsFromEnv()))
+		if window := duplicateWindowFromEnv(); window > 0 {
+			svc.SetDuplicateGuard(service.NewDuplicateGuard(service.NewRedisDuplicateStore(redisClient), window))
+		}
+		linkStore = service.NewRedisPaymentLinkStore(redisClient)
+		// Ledger accounts read for balance checks are cached on this replica and
+		// dropped as soon as the ledger publishes a change to their balance
+		if ttl := accountCacheTTLFromEnv(); ttl > 0 {
+			accountCache := cache.NewLocalCache[service.AccountResponse](ttl)
+			svc.SetAccountCache(accountCache)
+			go cache.NewInvalidationSubscriber(redisClient, cache.BalanceInvalidationChannel, accountCache.Invalidate, accountCache.Flush).Run(context.Background())
+		}
+	}
 	h := handler.NewPaymentHandler(svc)
+	stepUpThreshold := stepUpThresholdFromEnv()
+	h.StepUpThreshold = stepUpThreshold
+
+	// Fees are priced from the fee schedules and posted to the fee income account
+	feeSvc := service.NewFeeService(repository.NewFeeRepository(database), getEnv("FEE_INCOME_ACCOUNT_ID", ""))
+	if feeSvc.IncomeAccountID != "" {
+		svc.SetFees(feeSvc)
+	} else {
+		slog.Warn("FEE_INCOME_ACCOUNT_ID not set; payments are not charged fees")
+	}
+	fh := handler.NewFeeHandler(feeSvc)
+	// Users choose the instant rail, posted while they wait and priced by the
+	// INSTANT_TRANSFER fee schedules, or the standard rail, posted in batches
+	railPolicy := railPolicyFromEnv()
+	svc.SetRails(railPolicy, repo)
+	// Transfers from business accounts above the threshold wait for M of the
+	// organization's owners and admins to approve them
+	approvalPolicy := approvalPolicyFromEnv()
+	svc.SetApprovals(approvalPolicy, repository.NewPaymentApprovalRepository(database))
+
+	mandateSvc := service.NewMandateService(repository.NewMandateRepository(database), svc, producer)
+	mh := handler.NewMandateHandler(mandateSvc)
 
-	// Get JWT secret
-	jwtSecret := requireEnv("JWT_SECRET")
+	// Merchant payouts queue up and are settled in batches, one ledger entry per
+	// merchant and currency, priced by the PAYOUT fee schedules
+	payoutSvc := service.NewPayoutService(repository.NewPayoutRepository(database), mandateSvc.Repo, svc)
+	poh := handler.NewPayoutHandler(payoutSvc)
+
+	paymentRequestSvc := service.NewPaymentRequestService(repository.NewPaymentRequestRepository(database), svc, producer)
+	prh := handler.NewPaymentRequestHandler(paymentRequestSvc)
+
+	paymentBatchSvc := service.NewPaymentBatchService(repository.NewPaymentBatchRepository(database), svc, repo, service.SimulatedClearingConnector{}, getEnv("CLEARING_AGENT_BIC", service.DefaultAgentBIC))
+	pbh := handler.NewPaymentBatchHandler(paymentBatchSvc)
+
+	// External transfers: routed to a connector by destination scheme, funded from the settlement account
+	connectorRegistry := newConnectorRegistry(cfg.IsProduction())
+	externalTransferSvc := service.NewExternalTransferService(repository.NewExternalTransferRepository(database), svc, connectorRegistry, getEnv("EXTERNAL_SETTLEMENT_ACCOUNT_ID", ""))
+	// Sort codes, BICs and routing numbers are checked against the bank directory,
+	// the bundled dataset unless BANK_DIRECTORY_FILE names a full one
+	bankDirectory := bankdirectory.New(bankDirectoryProvider())
+	if redisClient != nil {
+		bankDirectory.UseCache(redisClient, bankdirectory.DefaultCacheTTL)
+	}
+	externalTransferSvc.Banks = bankDirectory
+	// Transfers from business accounts wait for their debit to be approved
+	externalTransferSvc.Payments = repo
+	eth := handler.NewExternalTransferHandler(externalTransferSvc)
+	eth.StepUpThreshold = stepUpThreshold
+
+	// Incoming credits from other banks arrive in the settlement account and are
+	// matched to accounts by alias; unmatched ones wait in the suspense account for ops
+	incomingCreditSvc := service.NewIncomingCreditService(repository.NewIncomingCreditRepository(database), svc, svc, connectorRegistry, getEnv("EXTERNAL_SETTLEMENT_ACCOUNT_ID", ""), getEnv("INCOMING_CREDIT_SUSPENSE_ACCOUNT_ID", ""))
+	if incomingCreditSvc.SuspenseAccountID == "" {
+		slog.Warn("INCOMING_CREDIT_SUSPENSE_ACCOUNT_ID not set; incoming credits are refused")
+	}
+	ich := handler.NewIncomingCreditHandler(incomingCreditSvc)
+
+	// The rails' end-of-day settlement reports are reconciled against external
+	// transfers and incoming credits; ops work through the exceptions raised
+	settlementSvc := service.NewSettlementReconciliationService(repository.NewSettlementRepository(database), connectorRegistry, nil)
+	if dir := getEnv("SETTLEMENT_REPORT_DIR", ""); dir != "" {
+		settlementSvc.Source = service.DirectorySettlementSource{Dir: dir}
+	} else {
+		slog.Warn("SETTLEMENT_REPORT_DIR not set; settlement reports are only reconciled when uploaded")
+	}
+	sth := handler.NewSettlementHandler(settlementSvc)
+
+	refundSvc := service.NewRefundService(repository.NewRefundRepository(database), svc)
+	rfh := handler.NewRefundHandler(refundSvc)
+
+	plh := handler.NewPaymentLinkHandler(service.NewPaymentLinkService(linkStore, getEnv("PAYMENT_LINK_BASE_URL", "http://localhost:3000")))
+
+	// Background work runs from the jobs table, so each scheduled run happens
+	// on one replica and failures are retried and visible in the admin API
+	jobStore := jobs.NewPostgresStore(database)
+	jobRunner := jobs.NewRunner(jobStore, serviceName, jobs.DefaultConfig())
+	jobRunner.Schedule("payment.request_expiry", jobs.Every(time.Minute), paymentRequestSvc.ExpiryJob)
+	jobRunner.Schedule("payment.external_transfer_retry", jobs.Every(15*time.Second), externalTransferSvc.RetryJob)
+	jobRunner.Schedule("payment.payout_settlement", jobs.Every(payoutIntervalFromEnv()), payoutSvc.PayoutJob)
+	jobRunner.Schedule("payment.standard_rail", jobs.Every(railPolicy.StandardInterval), svc.StandardRailJob)
+	jobRunner.Schedule("payment.approval_expiry", jobs.Every(time.Minute), svc.ApprovalExpiryJob)
+	if settlementSvc.Source != nil {
+		jobRunner.Schedule("payment.settlement_reconciliation", settlementScheduleFromEnv(), settlementSvc.ReconciliationJob)
+	}
+	go jobRunner.Run(context.Background())
+
+	// Maintenance mode: MAINTENANCE_MODE forces it for this deployment, and admins
+	// switch the global and per-service scopes at runtime, shared through Redis
+	var maintenanceStore maintenance.Store = maintenance.NewMemoryStore()
+	if redisClient != nil {
+		maintenanceStore = maintenance.NewRedisStore(redisClient)
+	}
+	forcedMaintenance, err := maintenance.ParseMode(getEnv("MAINTENANCE_MODE", "off"))
+	if err != nil {
+		slog.Error("Invalid MAINTENANCE_MODE", "error", err)
+		panic(err)
+	}
 
 	// Setup Router
 	r := gin.Default()
@@ -87,35 +301,393 @@ func main() {
 	r.Use(middleware.RequestLogger(serviceName))
 	r.Use(middleware.Tracing(serviceName))
 	r.Use(middleware.CORS())
-	r.Use(middleware.RateLimit())
+	r.Use(middleware.RateLimitWithPolicies(cfg.RateLimitPolicies()))
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
 	r.Use(metrics.PrometheusMiddleware(serviceName))
+	// Rejects non-essential requests with 503 while the service is in maintenance
+	r.Use(maintenance.Guard(maintenance.Config{Service: serviceName, Store: maintenanceStore, Forced: forcedMaintenance}))
+	// Transfer and payment lists are large JSON; compress them for clients that accept it
+	r.Use(middleware.Compress())
+
+	hs := routeHandlers{payment: h, mandates: mh, requests: prh, batches: pbh, external: eth, banks: handler.NewBankDirectoryHandler(bankDirectory), credits: ich, settlement: sth, refunds: rfh, links: plh, payouts: poh, fees: fh, jobs: jobs.NewAdminHandler(jobStore, serviceName), maintenance: maintenance.NewAdminHandler(maintenanceStore, serviceName)}
+	// Readiness needs the database with its migrations applied; without
+	// Redis or Kafka the service only degrades
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	if redisClient != nil {
+		healthChecks.RegisterOptional("redis", health.Redis(redisClient))
+	}
+	healthChecks.RegisterOptional("kafka", health.Kafka(cfg.Kafka.Brokers))
+	healthChecks.Info("kafka_topics", func() interface{} { return topicAdmin.Report() })
+	registerRoutes(r, hs, cfg.JWTAuth(), healthChecks)
+
+	port := getEnv("PORT", "8083")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
+
+// routeHandlers are the handlers registerRoutes mounts
+type routeHandlers struct {
+	payment     *handler.PaymentHandler
+	mandates    *handler.MandateHandler
+	requests    *handler.PaymentRequestHandler
+	batches     *handler.PaymentBatchHandler
+	external    *handler.ExternalTransferHandler
+	banks       *handler.BankDirectoryHandler
+	credits     *handler.IncomingCreditHandler
+	settlement  *handler.SettlementHandler
+	refunds     *handler.RefundHandler
+	links       *handler.PaymentLinkHandler
+	payouts     *handler.PayoutHandler
+	fees        *handler.FeeHandler
+	jobs        *jobs.AdminHandler
+	maintenance *maintenance.AdminHandler
+}
+
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes.
+func registerRoutes(r *gin.Engine, hs routeHandlers, jwtAuth middleware.JWTAuthConfig, healthChecks *health.Handler) {
+	h, mh, prh, pbh, eth, rfh, plh := hs.payment, hs.mandates, hs.requests, hs.batches, hs.external, hs.refunds, hs.links
 
 	// ============================================
 	// Public endpoints
 	// ============================================
 	r.GET("/metrics", metrics.MetricsHandler())
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": serviceName,
-			"kafka":   producer != nil,
-		})
-	})
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	// Connector status webhooks authenticate themselves, e.g. with a signature header
+	r.POST("/webhooks/connectors/:connector", eth.ConnectorWebhook)
+	r.POST("/webhooks/connectors/:connector/credits", hs.credits.CreditWebhook)
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
 
 	// ============================================
 	// Protected endpoints
 	// ============================================
 	api := r.Group("/api/v1")
-	api.Use(middleware.JWTAuth(jwtSecret))
+	api.Use(middleware.JWTAuthWithConfig(jwtAuth))
+	// Users who have not accepted the current mandatory terms can only read
+	api.Use(middleware.RequireTermsAccepted())
+	// The deadline covers ledger lookups and connector calls made for the request
+	api.Use(middleware.Timeout(30 * time.Second))
 	{
 		api.POST("/transfer", h.MakeTransfer)
+		// Runs a transfer's checks and prices it without making it
+		api.POST("/transfer/validate", h.ValidateTransfer)
+		api.GET("/transfer/limits", h.GetTransferLimits)
+		api.GET("/transfer/rails", h.QuoteRails)
+		// Pending transfers can be cancelled until the ledger posts them
+		api.DELETE("/transfer/:id", h.CancelTransfer)
+		// Refunds reverse the ledger entry of a completed transfer, in full or in parts
+		api.POST("/transfer/:id/refund", rfh.RefundPayment)
+		api.GET("/transfer/:id/refunds", rfh.ListRefunds)
+		// Each state the transfer went through, when, and who moved it
+		api.GET("/transfer/:id/timeline", h.GetTransferTimeline)
+		// Business payments above the approval threshold wait for approvals from
+		// the organization's owners and admins, who act with organization tokens
+		api.GET("/transfer/approvals", middleware.TenantScope(), middleware.RequireOrgRole(tenant.RoleOwner, tenant.RoleAdmin), h.ListAwaitingApproval)
+		api.GET("/transfer/:id/approvals", middleware.TenantScope(), h.GetPaymentApprovals)
+		api.POST("/transfer/:id/approve", middleware.TenantScope(), middleware.RequireOrgRole(tenant.RoleOwner, tenant.RoleAdmin), middleware.RequireStepUp(middleware.DefaultStepUpMaxAge), h.ApprovePayment)
+		api.POST("/transfer/:id/reject", middleware.TenantScope(), middleware.RequireOrgRole(tenant.RoleOwner, tenant.RoleAdmin), h.RejectPayment)
+
+		// Direct debit: merchant registration and payer-side mandate management
+		api.POST("/merchants", mh.RegisterMerchant)
+		api.GET("/mandates", mh.ListMandates)
+		// Approving a mandate adds a new payee, so it needs a recent step-up
+		api.POST("/mandates/:id/approve", middleware.RequireStepUp(middleware.DefaultStepUpMaxAge), mh.ApproveMandate)
+		api.POST("/mandates/:id/revoke", mh.RevokeMandate)
+
+		// Payment requests: shared by reference, fulfilled by another user
+		api.POST("/payment-requests", prh.CreatePaymentRequest)
+		api.GET("/payment-requests", prh.ListPaymentRequests)
+		api.GET("/payment-requests/:reference", prh.GetPaymentRequest)
+		api.POST("/payment-requests/:reference/pay", prh.PayPaymentRequest)
+		api.POST("/payment-requests/:reference/cancel", prh.CancelPaymentRequest)
+
+		// Payment links and QR codes: resolved by the payer's app to pre-fill a transfer
+		api.POST("/payment-links", plh.CreatePaymentLink)
+		api.GET("/payment-links/:code/resolve", plh.ResolvePaymentLink)
+		api.DELETE("/payment-links/:code", plh.RevokePaymentLink)
+
+		// ISO 20022: pain.001 batch import and pacs.008 export for clearing
+		api.POST("/payment-batches", pbh.ImportPain001)
+		api.GET("/payment-batches/:id", pbh.GetPaymentBatch)
+		api.GET("/payment-batches/:id/pacs008", pbh.ExportPacs008)
+		api.POST("/payment-batches/:id/clear", pbh.SubmitToClearing)
+
+		// External transfers to IBANs and UK sort codes via payment connectors
+		api.POST("/external-transfers", eth.CreateExternalTransfer)
+		api.GET("/external-transfers/:id", eth.GetExternalTransfer)
+		api.GET("/banks/lookup", hs.banks.LookupBank)
 	}
 
-	port := getEnv("PORT", "8083")
-	slog.Info("Server listening", "port", port)
-	if err := r.Run(":" + port); err != nil {
-		slog.Error("Failed to start server", "error", err)
+	// ============================================
+	// Delegated access endpoints (delegate tokens only)
+	// ============================================
+	delegated := r.Group("/api/v1/delegated")
+	delegated.Use(middleware.DelegationAuthWithConfig(jwtAuth), middleware.Timeout(30*time.Second))
+	{
+		// Transfers from the delegated accounts, up to the delegation's payment limit
+		delegated.POST("/transfer", middleware.RequireDelegation(middleware.DelegationPayScope), h.DelegatedTransfer)
+	}
+
+	// ============================================
+	// Merchant endpoints (API key auth)
+	// ============================================
+	merchantAPI := r.Group("/api/v1/merchant")
+	merchantAPI.Use(mh.MerchantAuth())
+	{
+		merchantAPI.POST("/mandates", mh.CreateMandate)
+		merchantAPI.GET("/mandates/:id", mh.GetMerchantMandate)
+		merchantAPI.POST("/mandates/:id/cancel", mh.CancelMandate)
+		merchantAPI.POST("/mandates/:id/collect", mh.Collect)
+
+		// Payouts are queued here and settled by the payout_settlement job
+		merchantAPI.POST("/payouts", hs.payouts.CreatePayout)
+		merchantAPI.GET("/payouts", hs.payouts.ListPayouts)
+		merchantAPI.GET("/payouts/:id", hs.payouts.GetPayout)
+		merchantAPI.GET("/payout-batches", hs.payouts.ListPayoutBatches)
+		merchantAPI.GET("/payout-batches/:id", hs.payouts.GetPayoutBatch)
+	}
+
+	// ============================================
+	// Admin endpoints
+	// ============================================
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.JWTAuthWithConfig(jwtAuth.Admin()), middleware.RequireRole("admin"))
+	{
+		// Fee schedules price user transfers and direct debit collections
+		admin.GET("/fee-schedules", hs.fees.ListFeeSchedules)
+		admin.POST("/fee-schedules", hs.fees.CreateFeeSchedule)
+		admin.GET("/fee-schedules/:id", hs.fees.GetFeeSchedule)
+		admin.PUT("/fee-schedules/:id", hs.fees.UpdateFeeSchedule)
+		admin.DELETE("/fee-schedules/:id", hs.fees.DeleteFeeSchedule)
+
+		// Incoming credits, and repairing those held in suspense
+		admin.GET("/incoming-credits", hs.credits.ListIncomingCredits)
+		admin.GET("/incoming-credits/:id", hs.credits.GetIncomingCredit)
+		admin.POST("/incoming-credits/:id/repost", hs.credits.RepostIncomingCredit)
+
+		// Settlement reports from the rails, and resolving the exceptions their reconciliation raised
+		admin.POST("/settlement-reports", hs.settlement.UploadSettlementReport)
+		admin.GET("/settlement-reports", hs.settlement.ListSettlementReports)
+		admin.GET("/settlement-reports/:id", hs.settlement.GetSettlementReport)
+		admin.GET("/settlement-exceptions", hs.settlement.ListSettlementExceptions)
+		admin.GET("/settlement-exceptions/:id", hs.settlement.GetSettlementException)
+		admin.POST("/settlement-exceptions/:id/resolve", hs.settlement.ResolveSettlementException)
+
+		// The timeline of any payment, for support investigations
+		admin.GET("/transfers/:id/timeline", h.GetAnyTransferTimeline)
+		// Refunds on behalf of the payee, e.g. for a merchant that cannot issue one
+		admin.POST("/transfers/:id/refund", rfh.RefundAnyPayment)
+		admin.GET("/transfers/:id/refunds", rfh.ListAnyRefunds)
+	}
+	hs.jobs.RegisterRoutes(admin)
+	hs.maintenance.RegisterRoutes(admin)
+}
+
+// newConnectorRegistry configures the external payment connector from
+// PAYMENT_CONNECTOR, which must be set, so that a deployment never falls back
+// to the mock connector. The mock connector is refused in production.
+func newConnectorRegistry(production bool) *connectors.Registry {
+	switch name := requireEnv("PAYMENT_CONNECTOR"); name {
+	case connectors.SandboxBankConnectorName:
+		return connectors.NewRegistry(connectors.NewSandboxBankConnector(connectors.SandboxBankConfig{
+			BaseURL:       requireEnv("SANDBOX_BANK_URL"),
+			APIKey:        requireEnv("SANDBOX_BANK_API_KEY"),
+			WebhookSecret: requireEnv("SANDBOX_BANK_WEBHOOK_SECRET"),
+		}))
+	case connectors.MockConnectorName:
+		if production {
+			panic("PAYMENT_CONNECTOR mock cannot be used in production")
+		}
+		slog.Warn("Using the mock payment connector; external transfers are simulated")
+		return connectors.NewRegistry(connectors.NewMockConnector(requireEnv("MOCK_CONNECTOR_WEBHOOK_SECRET")))
+	default:
+		panic("Unknown PAYMENT_CONNECTOR " + name)
+	}
+}
+
+// bankDirectoryProvider loads the bank directory from the CSV file named by
+// BANK_DIRECTORY_FILE, or uses the dataset bundled with the service
+func bankDirectoryProvider() bankdirectory.Provider {
+	path := getEnv("BANK_DIRECTORY_FILE", "")
+	if path == "" {
+		return bankdirectory.Bundled()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		panic("Invalid BANK_DIRECTORY_FILE: " + err.Error())
+	}
+	defer f.Close()
+	provider, err := bankdirectory.LoadStatic(f)
+	if err != nil {
+		panic("Invalid BANK_DIRECTORY_FILE " + path + ": " + err.Error())
+	}
+	return provider
+}
+
+// transferLimitsFromEnv reads TRANSFER_LIMIT_* overrides of the default limits.
+// A value of 0 disables that limit.
+func transferLimitsFromEnv() service.TransferLimits {
+	limits := service.DefaultTransferLimits()
+	amounts := map[string]*decimal.Decimal{
+		"TRANSFER_LIMIT_MAX_PER_TRANSACTION":      &limits.MaxPerTransaction,
+		"TRANSFER_LIMIT_DAILY_AMOUNT":             &limits.DailyAmount,
+		"TRANSFER_LIMIT_BENEFICIARY_DAILY_AMOUNT": &limits.BeneficiaryDailyAmount,
+	}
+	for key, target := range amounts {
+		if value := getEnv(key, ""); value != "" {
+			amount, err := decimal.NewFromString(value)
+			if err != nil || amount.IsNegative() {
+				panic("Invalid " + key + ": " + value)
+			}
+			*target = amount
+		}
+	}
+	if value := getEnv("TRANSFER_LIMIT_DAILY_COUNT", ""); value != "" {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || count < 0 {
+			panic("Invalid TRANSFER_LIMIT_DAILY_COUNT: " + value)
+		}
+		limits.DailyCount = count
+	}
+	return limits
+}
+
+// stepUpThresholdFromEnv reads STEP_UP_TRANSFER_THRESHOLD, the transfer amount
+// from which users must have stepped up recently. A value of 0 turns it off.
+func stepUpThresholdFromEnv() decimal.Decimal {
+	value := getEnv("STEP_UP_TRANSFER_THRESHOLD", "")
+	if value == "" {
+		return handler.DefaultStepUpThreshold
+	}
+	threshold, err := decimal.NewFromString(value)
+	if err != nil || threshold.IsNegative() {
+		panic("Invalid STEP_UP_TRANSFER_THRESHOLD: " + value)
+	}
+	return threshold
+}
+
+// duplicateWindowFromEnv reads DUPLICATE_PAYMENT_WINDOW, e.g. 10m. A value of
+// 0 disables duplicate payment detection.
+func duplicateWindowFromEnv() time.Duration {
+	value := getEnv("DUPLICATE_PAYMENT_WINDOW", "")
+	if value == "" {
+		return service.DefaultDuplicateWindow
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window < 0 {
+		panic("Invalid DUPLICATE_PAYMENT_WINDOW: " + value)
+	}
+	return window
+}
+
+// accountCacheTTLFromEnv reads ACCOUNT_CACHE_TTL, e.g. 30s. A value of 0
+// disables the ledger account cache.
+func accountCacheTTLFromEnv() time.Duration {
+	value := getEnv("ACCOUNT_CACHE_TTL", "")
+	if value == "" {
+		return service.DefaultAccountCacheTTL
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		panic("Invalid ACCOUNT_CACHE_TTL: " + value)
+	}
+	return ttl
+}
+
+// payoutIntervalFromEnv reads how often pending payouts are settled from
+// PAYOUT_SETTLEMENT_INTERVAL, e.g. "1h"
+func payoutIntervalFromEnv() time.Duration {
+	value := getEnv("PAYOUT_SETTLEMENT_INTERVAL", "")
+	if value == "" {
+		return service.DefaultPayoutInterval
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		panic("Invalid PAYOUT_SETTLEMENT_INTERVAL: " + value)
+	}
+	return interval
+}
+
+// settlementScheduleFromEnv reads when the previous day's settlement reports
+// are reconciled from SETTLEMENT_RECONCILIATION_SCHEDULE, a cron expression in
+// UTC that defaults to 02:00
+func settlementScheduleFromEnv() jobs.Schedule {
+	value := getEnv("SETTLEMENT_RECONCILIATION_SCHEDULE", "0 2 * * *")
+	schedule, err := jobs.ParseCron(value)
+	if err != nil {
+		panic("Invalid SETTLEMENT_RECONCILIATION_SCHEDULE: " + value)
+	}
+	return schedule
+}
+
+// railPolicyFromEnv reads the transfer rail settings: INSTANT_RAIL_MAX_AMOUNT
+// (0 removes the cap), INSTANT_RAIL_TIER_LIMITS as comma-separated
+// PRODUCT_CODE=amount overrides (-1 keeps the product off the instant rail)
+// and STANDARD_RAIL_INTERVAL, e.g. "1h"
+func railPolicyFromEnv() service.RailPolicy {
+	policy := service.DefaultRailPolicy()
+	if value := getEnv("INSTANT_RAIL_MAX_AMOUNT", ""); value != "" {
+		amount, err := decimal.NewFromString(value)
+		if err != nil || amount.IsNegative() {
+			panic("Invalid INSTANT_RAIL_MAX_AMOUNT: " + value)
+		}
+		policy.InstantMaxAmount = amount
+	}
+	if value := getEnv("INSTANT_RAIL_TIER_LIMITS", ""); value != "" {
+		policy.TierInstantMaxAmounts = map[string]decimal.Decimal{}
+		for _, pair := range strings.Split(value, ",") {
+			tier, limit, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			amount, err := decimal.NewFromString(limit)
+			if !ok || tier == "" || err != nil {
+				panic("Invalid INSTANT_RAIL_TIER_LIMITS: " + value)
+			}
+			policy.TierInstantMaxAmounts[tier] = amount
+		}
+	}
+	if value := getEnv("STANDARD_RAIL_INTERVAL", ""); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			panic("Invalid STANDARD_RAIL_INTERVAL: " + value)
+		}
+		policy.StandardInterval = interval
+	}
+	return policy
+}
+
+// approvalPolicyFromEnv reads the business payment approval settings:
+// PAYMENT_APPROVAL_THRESHOLD, the amount above which transfers need
+// approvals, PAYMENT_APPROVALS_REQUIRED and PAYMENT_APPROVAL_WINDOW, e.g. "48h"
+func approvalPolicyFromEnv() service.ApprovalPolicy {
+	policy := service.DefaultApprovalPolicy()
+	if value := getEnv("PAYMENT_APPROVAL_THRESHOLD", ""); value != "" {
+		amount, err := decimal.NewFromString(value)
+		if err != nil || amount.IsNegative() {
+			panic("Invalid PAYMENT_APPROVAL_THRESHOLD: " + value)
+		}
+		policy.Threshold = amount
+	}
+	if value := getEnv("PAYMENT_APPROVALS_REQUIRED", ""); value != "" {
+		required, err := strconv.Atoi(value)
+		if err != nil || required < 1 {
+			panic("Invalid PAYMENT_APPROVALS_REQUIRED: " + value)
+		}
+		policy.Required = required
+	}
+	if value := getEnv("PAYMENT_APPROVAL_WINDOW", ""); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			panic("Invalid PAYMENT_APPROVAL_WINDOW: " + value)
+		}
+		policy.Window = window
 	}
+	return policy
 }
 
 func getEnv(key, fallback string) string {
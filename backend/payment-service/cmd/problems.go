@@ -0,0 +1,22 @@
+package main
+
+// serviceProblemTypes title the error codes the payment service defines, keyed by
+// code. Their problem types are namespaced under the service; the standard
+// codes are registered by apperrors.
+var serviceProblemTypes = map[string]string{
+	"DELEGATION_LIMIT_EXCEEDED":   "Payment exceeds the delegation's limit",
+	"DUPLICATE_PAYMENT":           "Payment looks like a duplicate",
+	"EXTERNAL_TRANSFERS_DISABLED": "External transfers are not configured",
+	"INCOMING_CREDITS_DISABLED":   "Incoming credits are not configured",
+	"INCOMING_CREDIT_STATE":       "Incoming credit is in the wrong state",
+	"INSTANT_RAIL_INELIGIBLE":     "Transfer cannot use the instant rail",
+	"MANDATE_INVALID_STATE":       "Mandate is in the wrong state",
+	"PAYMENT_BATCH_CONFLICT":      "Payment batch conflicts with an earlier one",
+	"PAYMENT_LINKS_DISABLED":      "Payment links are not configured",
+	"PAYMENT_NOT_CANCELLABLE":     "Payment cannot be cancelled",
+	"PAYMENT_NOT_REFUNDABLE":      "Payment cannot be refunded",
+	"PAYMENT_REQUEST_NOT_OPEN":    "Payment request is not open",
+	"RAILS_DISABLED":              "Transfer rails are not configured",
+	"REFUND_EXCEEDS_PAYMENT":      "Refund exceeds the payment",
+	"SETTLEMENT_EXCEPTION_STATE":  "Settlement exception is already resolved",
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	apispec "github.com/femi-lawal/new_bank/backend/payment-service/api"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/handler"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/maintenance"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPISpecMatchesRoutes fails when a route is added or removed without
+// updating api/openapi.yaml
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerRoutes(r, routeHandlers{
+		payment:     handler.NewPaymentHandler(nil),
+		mandates:    handler.NewMandateHandler(nil),
+		requests:    handler.NewPaymentRequestHandler(nil),
+		batches:     handler.NewPaymentBatchHandler(nil),
+		external:    handler.NewExternalTransferHandler(nil),
+		banks:       handler.NewBankDirectoryHandler(nil),
+		credits:     handler.NewIncomingCreditHandler(nil),
+		settlement:  handler.NewSettlementHandler(nil),
+		refunds:     handler.NewRefundHandler(nil),
+		links:       handler.NewPaymentLinkHandler(nil),
+		payouts:     handler.NewPayoutHandler(nil),
+		fees:        handler.NewFeeHandler(nil),
+		jobs:        jobs.NewAdminHandler(nil, "payment-service"),
+		maintenance: maintenance.NewAdminHandler(nil, "payment-service"),
+	}, middleware.DefaultJWTConfig("test-secret"), health.New(serviceName))
+
+	spec, err := openapi.Load(apispec.Spec)
+	require.NoError(t, err)
+	require.NoError(t, openapi.CheckRoutes(spec, r.Routes(), "/metrics", metrics.SLOStatusPath))
+	require.NoError(t, metrics.CheckSLORoutes(serviceSLOs, r.Routes()))
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the payment service, reported at
+// /slo-status. Transfers include a synchronous ledger round trip.
+var serviceSLOs = []metrics.SLO{
+	{Name: "transfer", Method: http.MethodPost, Route: "/api/v1/transfer", Threshold: 500 * time.Millisecond, Objective: 0.995},
+	{Name: "transfer-limits", Method: http.MethodGet, Route: "/api/v1/transfer/limits", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "pay-request", Method: http.MethodPost, Route: "/api/v1/payment-requests/:reference/pay", Threshold: 500 * time.Millisecond, Objective: 0.995},
+	{Name: "external-transfer", Method: http.MethodPost, Route: "/api/v1/external-transfers", Threshold: time.Second, Objective: 0.99},
+}
@@ -0,0 +1,20 @@
+package main
+
+import "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+
+// serviceTopics are the Kafka topics the payment service produces or consumes.
+// Missing ones are created at startup with the KAFKA_TOPIC_* settings.
+var serviceTopics = []string{
+	kafka.TopicPaymentCreated,
+	kafka.TopicPaymentCompleted,
+	kafka.TopicPaymentFailed,
+	kafka.TopicPaymentCancelled,
+	kafka.TopicMandateCreated,
+	kafka.TopicMandateApproved,
+	kafka.TopicMandateRevoked,
+	kafka.TopicMandateCancelled,
+	kafka.TopicPaymentRequestCreated,
+	kafka.TopicPaymentRequestPaid,
+	kafka.TopicPaymentRequestExpired,
+	kafka.TopicPaymentRequestCancelled,
+}
@@ -0,0 +1,147 @@
+package bankdirectory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		kind Kind
+		code string
+		want string
+		ok   bool
+	}{
+		{KindSortCode, "20-00-00", "200000", true},
+		{KindSortCode, "20 00 0", "", false},
+		{KindBIC, "barc gb 22", "BARCGB22", true},
+		{KindBIC, "DEUTDEFF500", "DEUTDEFF500", true},
+		{KindBIC, "BARCGB2", "", false},
+		{KindBIC, "1ARCGB22", "", false},
+		{KindRoutingNumber, "021000021", "021000021", true},
+		{KindRoutingNumber, "021000012", "", false}, // swapped digits fail the check digit
+		{KindRoutingNumber, "02100002", "", false},
+	}
+	for _, tt := range tests {
+		got, err := Validate(tt.kind, tt.code)
+		if !tt.ok {
+			assert.ErrorIs(t, err, ErrInvalidCode, "%s %q", tt.kind, tt.code)
+			continue
+		}
+		require.NoError(t, err, "%s %q", tt.kind, tt.code)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := Validate("iban", "GB82WEST12345698765432")
+	assert.ErrorIs(t, err, ErrUnknownKind)
+}
+
+func TestValidateExplainsRoutingCheckDigit(t *testing.T) {
+	_, err := Validate(KindRoutingNumber, "021000012")
+	assert.Contains(t, err.Error(), "check digit")
+}
+
+func TestBundledDataset(t *testing.T) {
+	d := New(Bundled())
+
+	bank, err := d.Lookup(context.Background(), KindSortCode, "20-00-00")
+	require.NoError(t, err)
+	assert.Equal(t, "Barclays Bank UK PLC", bank.Name)
+	assert.Equal(t, "BARCGB22", bank.BIC)
+
+	bank, err = d.Lookup(context.Background(), KindRoutingNumber, "021000021")
+	require.NoError(t, err)
+	assert.Equal(t, "US", bank.Country)
+
+	// An unlisted branch falls back to the bank's 8-character BIC
+	bank, err = d.Lookup(context.Background(), KindBIC, "DEUTDEFF500")
+	require.NoError(t, err)
+	assert.Equal(t, "DEUTDEFF", bank.Code)
+
+	_, err = d.Lookup(context.Background(), KindSortCode, "123456")
+	assert.ErrorIs(t, err, ErrBankNotFound)
+	assert.Contains(t, err.Error(), "12-34-56")
+}
+
+func TestLoadStaticRejectsMalformedCodes(t *testing.T) {
+	_, err := LoadStatic(strings.NewReader("type,code,bank_name,branch,bic,country\nsort_code,2000,Bank,,,GB\n"))
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	assert.Contains(t, err.Error(), "line 2")
+
+	_, err = LoadStatic(strings.NewReader("code,name\n"))
+	assert.Error(t, err)
+}
+
+// countingProvider counts lookups, to check what the cache answers
+type countingProvider struct {
+	Provider
+	calls int
+	err   error
+}
+
+func (p *countingProvider) Lookup(ctx context.Context, kind Kind, code string) (*Bank, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.Provider.Lookup(ctx, kind, code)
+}
+
+type memoryCache struct {
+	values map[string]string
+	err    error
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) (string, error) {
+	return c.values[key], c.err
+}
+
+func (c *memoryCache) Set(_ context.Context, key, value string, _ time.Duration) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.values[key] = value
+	return nil
+}
+
+func TestLookupIsCached(t *testing.T) {
+	provider := &countingProvider{Provider: Bundled()}
+	d := New(provider)
+	d.UseCache(&memoryCache{values: map[string]string{}}, DefaultCacheTTL)
+
+	for i := 0; i < 2; i++ {
+		bank, err := d.Lookup(context.Background(), KindBIC, "BARCGB22")
+		require.NoError(t, err)
+		assert.Equal(t, "Barclays Bank UK PLC", bank.Name)
+		_, err = d.Lookup(context.Background(), KindSortCode, "123456")
+		assert.ErrorIs(t, err, ErrBankNotFound)
+	}
+	assert.Equal(t, 2, provider.calls, "hits and misses are both cached")
+
+	// Malformed codes never reach the provider
+	_, err := d.Lookup(context.Background(), KindSortCode, "12345")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	assert.Equal(t, 2, provider.calls)
+}
+
+func TestLookupWithoutCache(t *testing.T) {
+	provider := &countingProvider{Provider: Bundled()}
+	d := New(provider)
+	d.UseCache(&memoryCache{err: errors.New("redis down")}, DefaultCacheTTL)
+
+	// A failing cache is skipped
+	_, err := d.Lookup(context.Background(), KindSortCode, "040004")
+	require.NoError(t, err)
+
+	// Provider failures are returned as they are, not as unknown banks
+	provider.err = errors.New("directory unavailable")
+	_, err = d.Lookup(context.Background(), KindSortCode, "040004")
+	assert.EqualError(t, err, "directory unavailable")
+	assert.NotErrorIs(t, err, ErrBankNotFound)
+}
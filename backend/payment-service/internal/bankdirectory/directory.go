@@ -0,0 +1,201 @@
+// Package bankdirectory looks up the banks behind external bank identifiers:
+// UK sort codes, BICs and US ABA routing numbers. Identifiers are checked for
+// format and check digits before any lookup, so typos are reported with what
+// is wrong; the lookup itself goes to a pluggable Provider, by default the
+// dataset bundled with the service, and is cached in Redis when configured.
+package bankdirectory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Kind is a type of bank identifier
+type Kind string
+
+const (
+	KindSortCode      Kind = "sort_code"      // UK sort code, 6 digits
+	KindBIC           Kind = "bic"            // SWIFT BIC, 8 or 11 characters
+	KindRoutingNumber Kind = "routing_number" // US ABA routing number, 9 digits
+)
+
+var (
+	// ErrInvalidCode means the identifier is malformed; the error says how
+	ErrInvalidCode = errors.New("invalid bank code")
+	// ErrBankNotFound means the identifier is well formed but no bank uses it
+	ErrBankNotFound = errors.New("bank not found")
+	ErrUnknownKind  = errors.New("bank code type must be sort_code, bic or routing_number")
+)
+
+// DefaultCacheTTL is how long lookups are cached; directories change rarely
+const DefaultCacheTTL = 24 * time.Hour
+
+// Bank is the bank, and where known the branch, an identifier belongs to
+type Bank struct {
+	Kind    Kind   `json:"type"`
+	Code    string `json:"code"`
+	Name    string `json:"bank_name"`
+	Branch  string `json:"branch,omitempty"`
+	BIC     string `json:"bic,omitempty"`
+	Country string `json:"country"`
+}
+
+// Provider is a source of bank directory data. Lookup receives a normalized,
+// well-formed code and returns ErrBankNotFound when it has no entry for it.
+type Provider interface {
+	Lookup(ctx context.Context, kind Kind, code string) (*Bank, error)
+}
+
+// Cache is the subset of *cache.RedisClient the directory uses. Get returns ""
+// for a missing key.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// Directory validates bank identifiers and looks them up through a Provider
+type Directory struct {
+	provider Provider
+	cache    Cache
+	ttl      time.Duration
+}
+
+func New(provider Provider) *Directory {
+	return &Directory{provider: provider}
+}
+
+// UseCache caches lookups, including codes no bank uses, for ttl
+func (d *Directory) UseCache(cache Cache, ttl time.Duration) {
+	d.cache = cache
+	d.ttl = ttl
+}
+
+// notFoundMarker is cached for codes the provider has no bank for
+const notFoundMarker = "-"
+
+// Lookup returns the bank an identifier belongs to. Spaces and hyphens are
+// ignored and BICs are case-insensitive. Malformed codes fail with
+// ErrInvalidCode before the provider is asked. An 11-character BIC whose
+// branch is not listed falls back to the bank's 8-character BIC.
+func (d *Directory) Lookup(ctx context.Context, kind Kind, code string) (*Bank, error) {
+	code, err := Validate(kind, code)
+	if err != nil {
+		return nil, err
+	}
+	bank, err := d.lookup(ctx, kind, code)
+	if errors.Is(err, ErrBankNotFound) && kind == KindBIC && len(code) == 11 {
+		bank, err = d.lookup(ctx, kind, code[:8])
+	}
+	if errors.Is(err, ErrBankNotFound) {
+		return nil, fmt.Errorf("%w: %s %s is not in the bank directory", ErrBankNotFound, describe(kind), Format(kind, code))
+	}
+	return bank, err
+}
+
+func (d *Directory) lookup(ctx context.Context, kind Kind, code string) (*Bank, error) {
+	key := "bankdirectory:" + string(kind) + ":" + code
+	if d.cache != nil {
+		if cached, err := d.cache.Get(ctx, key); err != nil {
+			// The provider answers without the cache, only slower
+			slog.Warn("Failed to read bank directory cache", "key", key, "error", err)
+		} else if cached == notFoundMarker {
+			return nil, ErrBankNotFound
+		} else if cached != "" {
+			var bank Bank
+			if err := json.Unmarshal([]byte(cached), &bank); err == nil {
+				return &bank, nil
+			}
+		}
+	}
+
+	bank, err := d.provider.Lookup(ctx, kind, code)
+	if err != nil && !errors.Is(err, ErrBankNotFound) {
+		return nil, err
+	}
+	if d.cache != nil {
+		value := notFoundMarker
+		if bank != nil {
+			data, _ := json.Marshal(bank)
+			value = string(data)
+		}
+		if err := d.cache.Set(ctx, key, value, d.ttl); err != nil {
+			slog.Warn("Failed to write bank directory cache", "key", key, "error", err)
+		}
+	}
+	if bank == nil {
+		return nil, ErrBankNotFound
+	}
+	return bank, nil
+}
+
+var (
+	sortCodePattern      = regexp.MustCompile(`^\d{6}$`)
+	routingNumberPattern = regexp.MustCompile(`^\d{9}$`)
+	bicPattern           = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+)
+
+// Validate normalizes a code and checks its format and, for routing numbers,
+// its check digit. Errors wrap ErrInvalidCode and say what a valid code looks like.
+func Validate(kind Kind, code string) (string, error) {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code))
+	switch kind {
+	case KindSortCode:
+		if !sortCodePattern.MatchString(code) {
+			return "", fmt.Errorf("%w: a sort code is 6 digits, such as 20-00-00", ErrInvalidCode)
+		}
+	case KindBIC:
+		code = strings.ToUpper(code)
+		if len(code) != 8 && len(code) != 11 {
+			return "", fmt.Errorf("%w: a BIC is 8 or 11 characters, such as BARCGB22, but %s has %d", ErrInvalidCode, code, len(code))
+		}
+		if !bicPattern.MatchString(code) {
+			return "", fmt.Errorf("%w: a BIC is a 4-letter bank code, a 2-letter country code, a 2-character location and an optional 3-character branch, such as BARCGB22", ErrInvalidCode)
+		}
+	case KindRoutingNumber:
+		if !routingNumberPattern.MatchString(code) {
+			return "", fmt.Errorf("%w: a routing number is 9 digits, such as 021000021", ErrInvalidCode)
+		}
+		if !validRoutingChecksum(code) {
+			return "", fmt.Errorf("%w: the check digit of routing number %s does not match; check for mistyped or swapped digits", ErrInvalidCode, code)
+		}
+	default:
+		return "", ErrUnknownKind
+	}
+	return code, nil
+}
+
+// validRoutingChecksum checks the ABA check digit: the digits weighted
+// 3, 7, 1 repeating must add up to a multiple of 10
+func validRoutingChecksum(code string) bool {
+	weights := [3]int{3, 7, 1}
+	sum := 0
+	for i, r := range code {
+		sum += int(r-'0') * weights[i%3]
+	}
+	return sum%10 == 0
+}
+
+// Format writes a normalized code the way people read it, e.g. 20-00-00
+func Format(kind Kind, code string) string {
+	if kind == KindSortCode && len(code) == 6 {
+		return code[:2] + "-" + code[2:4] + "-" + code[4:]
+	}
+	return code
+}
+
+func describe(kind Kind) string {
+	switch kind {
+	case KindSortCode:
+		return "sort code"
+	case KindBIC:
+		return "BIC"
+	default:
+		return "routing number"
+	}
+}
@@ -0,0 +1,82 @@
+package bankdirectory
+
+import (
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// bundledData is a small dataset of major UK, European and US banks, enough
+// for development and tests. Deployments load a full directory, such as an
+// EISCD extract, with LoadStatic or plug in their own Provider.
+//
+//go:embed banks.csv
+var bundledData string
+
+// StaticProvider serves lookups from a dataset held in memory
+type StaticProvider struct {
+	banks map[Kind]map[string]Bank
+}
+
+// Bundled returns a provider for the dataset bundled with the service
+func Bundled() *StaticProvider {
+	p, err := LoadStatic(strings.NewReader(bundledData))
+	if err != nil {
+		panic(fmt.Sprintf("bundled bank directory: %v", err))
+	}
+	return p
+}
+
+// LoadStatic reads a dataset in CSV with the header
+// type,code,bank_name,branch,bic,country. Codes may be written with spaces or
+// hyphens, as in 20-00-00; rows with malformed codes are rejected.
+func LoadStatic(r io.Reader) (*StaticProvider, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 6
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	if strings.Join(header, ",") != "type,code,bank_name,branch,bic,country" {
+		return nil, fmt.Errorf("header must be type,code,bank_name,branch,bic,country")
+	}
+
+	p := &StaticProvider{banks: map[Kind]map[string]Bank{}}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		kind := Kind(record[0])
+		code, err := Validate(kind, record[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if p.banks[kind] == nil {
+			p.banks[kind] = map[string]Bank{}
+		}
+		p.banks[kind][code] = Bank{
+			Kind:    kind,
+			Code:    code,
+			Name:    record[2],
+			Branch:  record[3],
+			BIC:     strings.ToUpper(record[4]),
+			Country: record[5],
+		}
+	}
+	return p, nil
+}
+
+func (p *StaticProvider) Lookup(_ context.Context, kind Kind, code string) (*Bank, error) {
+	bank, ok := p.banks[kind][code]
+	if !ok {
+		return nil, ErrBankNotFound
+	}
+	return &bank, nil
+}
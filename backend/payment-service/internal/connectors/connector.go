@@ -0,0 +1,220 @@
+// Package connectors sends payments to external rails, such as open banking
+// payment initiation APIs, behind a common interface. Each connector has its
+// own status webhooks; the payment service routes a transfer to a connector by
+// the scheme of its destination.
+package connectors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+type Scheme string
+
+const (
+	SchemeSEPA Scheme = "SEPA" // Destinations identified by IBAN
+	SchemeFPS  Scheme = "FPS"  // UK Faster Payments: sort code and account number
+)
+
+var (
+	// ErrTransient means the connector could not be reached or asked us to back off; the payment may be retried
+	ErrTransient = errors.New("connector temporarily unavailable")
+	// ErrRejected means the connector refused the payment; retrying will not help
+	ErrRejected           = errors.New("payment rejected by connector")
+	ErrInvalidSignature   = errors.New("invalid webhook signature")
+	ErrInvalidDestination = errors.New("destination must be a valid IBAN or a 6-digit sort code with an 8-digit account number")
+	ErrUnsupportedScheme  = errors.New("no connector supports this payment scheme")
+)
+
+// signHMAC returns the hex HMAC-SHA256 of a webhook body
+func signHMAC(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyHMAC checks a hex HMAC-SHA256 signature of a webhook body. Without a
+// secret no signature is valid.
+func verifyHMAC(secret, signature string, body []byte) error {
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 || secret == "" {
+		return ErrInvalidSignature
+	}
+	want, _ := hex.DecodeString(signHMAC(secret, body))
+	if !hmac.Equal(got, want) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Status is the state of a payment as reported by a connector
+type Status string
+
+const (
+	StatusAccepted Status = "ACCEPTED" // Accepted by the rail, settlement in progress
+	StatusSettled  Status = "SETTLED"
+	StatusRejected Status = "REJECTED"
+)
+
+// Destination is an account at another bank
+type Destination struct {
+	Name          string
+	IBAN          string
+	SortCode      string
+	AccountNumber string
+}
+
+var (
+	sortCodePattern      = regexp.MustCompile(`^\d{6}$`)
+	accountNumberPattern = regexp.MustCompile(`^\d{8}$`)
+	ibanPattern          = regexp.MustCompile(`^[A-Z]{2}\d{2}[A-Z0-9]{11,30}$`)
+)
+
+// Normalize strips the spaces and hyphens people type in account details
+func (d Destination) Normalize() Destination {
+	clean := strings.NewReplacer(" ", "", "-", "")
+	d.Name = strings.TrimSpace(d.Name)
+	d.IBAN = strings.ToUpper(clean.Replace(d.IBAN))
+	d.SortCode = clean.Replace(d.SortCode)
+	d.AccountNumber = clean.Replace(d.AccountNumber)
+	return d
+}
+
+// Scheme validates the destination and returns the scheme that reaches it.
+// Exactly one of IBAN or sort code and account number must be set.
+func (d Destination) Scheme() (Scheme, error) {
+	hasIBAN := d.IBAN != ""
+	hasDomestic := d.SortCode != "" || d.AccountNumber != ""
+	switch {
+	case hasIBAN && !hasDomestic && ValidIBAN(d.IBAN):
+		return SchemeSEPA, nil
+	case hasDomestic && !hasIBAN && sortCodePattern.MatchString(d.SortCode) && accountNumberPattern.MatchString(d.AccountNumber):
+		return SchemeFPS, nil
+	default:
+		return "", ErrInvalidDestination
+	}
+}
+
+// ValidIBAN checks the format and ISO 13616 check digits of a normalized IBAN
+func ValidIBAN(iban string) bool {
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	var digits strings.Builder
+	for _, r := range rearranged {
+		if r >= 'A' && r <= 'Z' {
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// PaymentInstruction is a payment to send over an external rail
+type PaymentInstruction struct {
+	ID          string // Our transfer ID; connectors use it as the idempotency key
+	Amount      decimal.Decimal
+	Currency    string
+	Scheme      Scheme
+	Destination Destination
+	Reference   string
+}
+
+// Submission is the connector's answer to a submitted payment
+type Submission struct {
+	ExternalID string
+	Status     Status
+}
+
+// StatusUpdate is a status change delivered by a connector webhook
+type StatusUpdate struct {
+	ExternalID string
+	Status     Status
+	Reason     string
+}
+
+// Connector is an external payment rail
+type Connector interface {
+	Name() string
+	Supports(scheme Scheme) bool
+	// Submit sends the payment. Errors wrap ErrTransient when a retry may succeed
+	// and ErrRejected when it will not. Submitting the same ID twice must not pay twice.
+	Submit(ctx context.Context, payment PaymentInstruction) (*Submission, error)
+	// ParseWebhook authenticates a status webhook and decodes it
+	ParseWebhook(header http.Header, body []byte) (*StatusUpdate, error)
+}
+
+// IncomingCredit is a payment into one of our accounts from another bank,
+// delivered by a connector webhook. Beneficiary identifies the account it was
+// sent to by IBAN or sort code and account number.
+type IncomingCredit struct {
+	ExternalID  string
+	Amount      decimal.Decimal
+	Currency    string
+	Scheme      Scheme
+	DebtorName  string
+	Beneficiary Destination
+	Reference   string
+}
+
+// CreditReceiver is implemented by connectors that also deliver incoming credits
+type CreditReceiver interface {
+	// ParseCreditWebhook authenticates an incoming credit webhook and decodes it
+	ParseCreditWebhook(header http.Header, body []byte) (*IncomingCredit, error)
+}
+
+// IsRetryable reports whether a submission error may succeed on retry
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrTransient)
+}
+
+// Registry holds the configured connectors in routing order
+type Registry struct {
+	connectors []Connector
+}
+
+func NewRegistry(connectors ...Connector) *Registry {
+	return &Registry{connectors: connectors}
+}
+
+// Names returns the names of the configured connectors in routing order
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.connectors))
+	for i, c := range r.connectors {
+		names[i] = c.Name()
+	}
+	return names
+}
+
+// Get returns the connector with the given name
+func (r *Registry) Get(name string) (Connector, bool) {
+	for _, c := range r.connectors {
+		if c.Name() == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Route returns the first connector that supports the scheme
+func (r *Registry) Route(scheme Scheme) (Connector, error) {
+	for _, c := range r.connectors {
+		if c.Supports(scheme) {
+			return c, nil
+		}
+	}
+	return nil, ErrUnsupportedScheme
+}
@@ -0,0 +1,225 @@
+package connectors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidIBAN(t *testing.T) {
+	assert.True(t, ValidIBAN("GB82WEST12345698765432"))
+	assert.True(t, ValidIBAN("DE89370400440532013000"))
+	assert.False(t, ValidIBAN("GB82WEST12345698765433"), "wrong check digits")
+	assert.False(t, ValidIBAN("GB82"), "too short")
+	assert.False(t, ValidIBAN("gb82west12345698765432"), "not normalized")
+}
+
+func TestDestinationScheme(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination Destination
+		scheme      Scheme
+		wantErr     bool
+	}{
+		{"iban with spaces", Destination{IBAN: "gb82 west 1234 5698 7654 32"}, SchemeSEPA, false},
+		{"sort code with hyphens", Destination{SortCode: "20-00-00", AccountNumber: "55123456"}, SchemeFPS, false},
+		{"short account number", Destination{SortCode: "200000", AccountNumber: "5512345"}, "", true},
+		{"both identifiers", Destination{IBAN: "GB82WEST12345698765432", SortCode: "200000", AccountNumber: "55123456"}, "", true},
+		{"neither", Destination{Name: "Jane"}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme, err := tt.destination.Normalize().Scheme()
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidDestination)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.scheme, scheme)
+		})
+	}
+}
+
+func TestRegistryRoutesInOrder(t *testing.T) {
+	sandbox := NewSandboxBankConnector(SandboxBankConfig{BaseURL: "http://sandbox"})
+	mock := NewMockConnector("whsec")
+	registry := NewRegistry(sandbox, mock)
+
+	c, err := registry.Route(SchemeFPS)
+	require.NoError(t, err)
+	assert.Equal(t, SandboxBankConnectorName, c.Name())
+
+	c, ok := registry.Get(MockConnectorName)
+	require.True(t, ok)
+	assert.Equal(t, mock, c)
+
+	_, err = NewRegistry().Route(SchemeSEPA)
+	assert.ErrorIs(t, err, ErrUnsupportedScheme)
+}
+
+func TestMockConnector(t *testing.T) {
+	mock := NewMockConnector("whsec")
+	payment := PaymentInstruction{ID: "t1", Amount: decimal.NewFromInt(10), Currency: "GBP", Reference: "retry me"}
+
+	_, err := mock.Submit(context.Background(), payment)
+	assert.True(t, IsRetryable(err))
+	sub, err := mock.Submit(context.Background(), payment)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSettled, sub.Status)
+
+	_, err = mock.Submit(context.Background(), PaymentInstruction{ID: "t2", Reference: "REJECT"})
+	assert.ErrorIs(t, err, ErrRejected)
+	assert.False(t, IsRetryable(err))
+}
+
+func sandboxPayment() PaymentInstruction {
+	return PaymentInstruction{
+		ID:          "5f0c6b1e-transfer",
+		Amount:      decimal.RequireFromString("42.5"),
+		Currency:    "GBP",
+		Scheme:      SchemeFPS,
+		Destination: Destination{Name: "Jane Doe", SortCode: "200000", AccountNumber: "55123456"},
+		Reference:   "Rent",
+	}
+}
+
+func TestSandboxBankConnector_Submit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/domestic-payments", r.URL.Path)
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		assert.Equal(t, "5f0c6b1e-transfer", r.Header.Get("x-idempotency-key"))
+
+		var req sandboxPaymentRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "42.50", req.Data.Initiation.InstructedAmount.Amount)
+		assert.Equal(t, "UK.OBIE.SortCodeAccountNumber", req.Data.Initiation.CreditorAccount.SchemeName)
+		assert.Equal(t, "20000055123456", req.Data.Initiation.CreditorAccount.Identification)
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Data":{"DomesticPaymentId":"dp-1","Status":"AcceptedSettlementInProcess"}}`))
+	}))
+	defer server.Close()
+
+	connector := NewSandboxBankConnector(SandboxBankConfig{BaseURL: server.URL + "/", APIKey: "key"})
+	sub, err := connector.Submit(context.Background(), sandboxPayment())
+
+	require.NoError(t, err)
+	assert.Equal(t, &Submission{ExternalID: "dp-1", Status: StatusAccepted}, sub)
+}
+
+func TestSandboxBankConnector_ClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+	}{
+		{"server error", http.StatusBadGateway, "", true},
+		{"rate limited", http.StatusTooManyRequests, "", true},
+		{"unreadable success", http.StatusCreated, "<html>", true},
+		{"bad request", http.StatusBadRequest, `{"Code":"UK.OBIE.Field.Invalid"}`, false},
+		{"rejected status", http.StatusCreated, `{"Data":{"DomesticPaymentId":"dp-2","Status":"Rejected"}}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := NewSandboxBankConnector(SandboxBankConfig{BaseURL: server.URL}).Submit(context.Background(), sandboxPayment())
+			require.Error(t, err)
+			assert.Equal(t, tt.retryable, IsRetryable(err))
+			if !tt.retryable {
+				assert.ErrorIs(t, err, ErrRejected)
+			}
+		})
+	}
+}
+
+func TestSandboxBankConnector_ParseWebhook(t *testing.T) {
+	connector := NewSandboxBankConnector(SandboxBankConfig{WebhookSecret: "whsec"})
+	body := []byte(`{"Data":{"DomesticPaymentId":"dp-1","Status":"AcceptedSettlementCompleted"}}`)
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write(body)
+
+	header := http.Header{}
+	header.Set(SandboxSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	update, err := connector.ParseWebhook(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, &StatusUpdate{ExternalID: "dp-1", Status: StatusSettled}, update)
+
+	header.Set(SandboxSignatureHeader, hex.EncodeToString([]byte("forged")))
+	_, err = connector.ParseWebhook(header, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	_, err = connector.ParseWebhook(http.Header{}, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestParseCreditWebhook(t *testing.T) {
+	mock := NewMockConnector("whsec")
+	body := []byte(`{"external_id":"in-1","amount":"25.50","currency":"GBP","debtor_name":"Ada","sort_code":"04-00-04","account_number":"12345678","reference":"rent"}`)
+	credit, err := mock.ParseCreditWebhook(mock.Sign(body), body)
+	require.NoError(t, err)
+	assert.Equal(t, "in-1", credit.ExternalID)
+	assert.True(t, credit.Amount.Equal(decimal.RequireFromString("25.50")))
+	assert.Equal(t, SchemeFPS, credit.Scheme)
+	assert.Equal(t, Destination{SortCode: "040004", AccountNumber: "12345678"}, credit.Beneficiary)
+
+	body = []byte(`{"amount":"1"}`)
+	_, err = mock.ParseCreditWebhook(mock.Sign(body), body)
+	assert.Error(t, err, "an external ID is required to deduplicate deliveries")
+
+	sandbox := NewSandboxBankConnector(SandboxBankConfig{WebhookSecret: "whsec"})
+	body = []byte(`{"Data":{"TransactionId":"tx-1","Amount":{"Amount":"100.00","Currency":"EUR"},"DebtorAccount":{"Name":"Grace"},"CreditorAccount":{"SchemeName":"UK.OBIE.IBAN","Identification":"GB82 WEST 1234 5698 7654 32"}}}`)
+	mac := hmac.New(sha256.New, []byte("whsec"))
+	mac.Write(body)
+	header := http.Header{}
+	header.Set(SandboxSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+
+	credit, err = sandbox.ParseCreditWebhook(header, body)
+	require.NoError(t, err)
+	assert.Equal(t, "tx-1", credit.ExternalID)
+	assert.Equal(t, "EUR", credit.Currency)
+	assert.Equal(t, "Grace", credit.DebtorName)
+	assert.Equal(t, SchemeSEPA, credit.Scheme)
+	assert.Equal(t, "GB82WEST12345698765432", credit.Beneficiary.IBAN)
+
+	_, err = sandbox.ParseCreditWebhook(http.Header{}, body)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
+
+func TestMockConnector_RejectsUnsignedWebhooks(t *testing.T) {
+	mock := NewMockConnector("whsec")
+	status := []byte(`{"external_id":"mock-t1","status":"SETTLED"}`)
+	credit := []byte(`{"external_id":"in-1","amount":"25.50","currency":"GBP","iban":"GB82WEST12345698765432"}`)
+
+	update, err := mock.ParseWebhook(mock.Sign(status), status)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSettled, update.Status)
+
+	_, err = mock.ParseWebhook(nil, status)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = mock.ParseCreditWebhook(http.Header{}, credit)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, err = mock.ParseCreditWebhook(NewMockConnector("other").Sign(credit), credit)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	// Without a secret nothing is accepted
+	unset := NewMockConnector("")
+	_, err = unset.ParseCreditWebhook(unset.Sign(credit), credit)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+}
@@ -0,0 +1,125 @@
+package connectors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+// MockConnectorName is the name the mock connector registers under
+const MockConnectorName = "mock"
+
+// MockSignatureHeader carries the hex HMAC-SHA256 of a mock webhook body
+const MockSignatureHeader = "X-Mock-Signature"
+
+// MockConnector simulates an external rail for local development and tests.
+// It settles payments immediately unless the reference asks otherwise:
+//   - "REJECT" rejects the payment
+//   - "PENDING" leaves it accepted until a webhook settles or rejects it
+//   - "RETRY" fails the first attempt with a transient error
+//
+// Its webhooks are JSON: {"external_id": "...", "status": "SETTLED", "reason": "..."}.
+// Its incoming credit webhooks are JSON too: {"external_id": "...",
+// "amount": "10.00", "currency": "GBP", "debtor_name": "...", "iban": "..." or
+// "sort_code" and "account_number", "reference": "..."}. Both are signed like
+// the sandbox bank's, in MockSignatureHeader, since the routes that receive
+// them are public and credits move money.
+type MockConnector struct {
+	webhookSecret string
+
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+// NewMockConnector creates a mock connector that accepts webhooks signed with
+// webhookSecret
+func NewMockConnector(webhookSecret string) *MockConnector {
+	return &MockConnector{webhookSecret: webhookSecret, attempts: make(map[string]int)}
+}
+
+// Sign returns the headers a webhook body needs to be accepted, for local
+// tools and tests that stand in for the rail
+func (m *MockConnector) Sign(body []byte) http.Header {
+	header := http.Header{}
+	header.Set(MockSignatureHeader, signHMAC(m.webhookSecret, body))
+	return header
+}
+
+func (m *MockConnector) Name() string { return MockConnectorName }
+
+func (m *MockConnector) Supports(Scheme) bool { return true }
+
+func (m *MockConnector) Submit(_ context.Context, p PaymentInstruction) (*Submission, error) {
+	m.mu.Lock()
+	m.attempts[p.ID]++
+	attempt := m.attempts[p.ID]
+	m.mu.Unlock()
+
+	reference := strings.ToUpper(p.Reference)
+	switch {
+	case strings.Contains(reference, "RETRY") && attempt == 1:
+		return nil, fmt.Errorf("%w: simulated outage", ErrTransient)
+	case strings.Contains(reference, "REJECT"):
+		return nil, fmt.Errorf("%w: simulated rejection", ErrRejected)
+	}
+
+	status := StatusSettled
+	if strings.Contains(reference, "PENDING") {
+		status = StatusAccepted
+	}
+	slog.Info("Mock connector accepted payment", "id", p.ID, "scheme", p.Scheme, "status", status)
+	return &Submission{ExternalID: "mock-" + p.ID, Status: status}, nil
+}
+
+func (m *MockConnector) ParseWebhook(header http.Header, body []byte) (*StatusUpdate, error) {
+	if err := verifyHMAC(m.webhookSecret, header.Get(MockSignatureHeader), body); err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		ExternalID string `json:"external_id"`
+		Status     Status `json:"status"`
+		Reason     string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.ExternalID == "" {
+		return nil, fmt.Errorf("invalid mock webhook payload")
+	}
+	return &StatusUpdate{ExternalID: payload.ExternalID, Status: payload.Status, Reason: payload.Reason}, nil
+}
+
+func (m *MockConnector) ParseCreditWebhook(header http.Header, body []byte) (*IncomingCredit, error) {
+	if err := verifyHMAC(m.webhookSecret, header.Get(MockSignatureHeader), body); err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		ExternalID    string          `json:"external_id"`
+		Amount        decimal.Decimal `json:"amount"`
+		Currency      string          `json:"currency"`
+		DebtorName    string          `json:"debtor_name"`
+		IBAN          string          `json:"iban"`
+		SortCode      string          `json:"sort_code"`
+		AccountNumber string          `json:"account_number"`
+		Reference     string          `json:"reference"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.ExternalID == "" {
+		return nil, fmt.Errorf("invalid mock credit webhook payload")
+	}
+	beneficiary := Destination{IBAN: payload.IBAN, SortCode: payload.SortCode, AccountNumber: payload.AccountNumber}.Normalize()
+	scheme, _ := beneficiary.Scheme()
+	return &IncomingCredit{
+		ExternalID:  payload.ExternalID,
+		Amount:      payload.Amount,
+		Currency:    payload.Currency,
+		Scheme:      scheme,
+		DebtorName:  payload.DebtorName,
+		Beneficiary: beneficiary,
+		Reference:   payload.Reference,
+	}, nil
+}
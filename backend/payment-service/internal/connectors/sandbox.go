@@ -0,0 +1,238 @@
+package connectors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/circuitbreaker"
+	"github.com/shopspring/decimal"
+)
+
+// SandboxBankConnectorName is the name the sandbox bank connector registers under
+const SandboxBankConnectorName = "sandbox-bank"
+
+// SandboxSignatureHeader carries the hex HMAC-SHA256 of the webhook body
+const SandboxSignatureHeader = "X-Sandbox-Signature"
+
+// SandboxBankConfig configures the sandbox bank payment initiation API
+type SandboxBankConfig struct {
+	BaseURL       string
+	APIKey        string
+	WebhookSecret string
+	Timeout       time.Duration
+}
+
+// SandboxBankConnector initiates payments through a bank sandbox that follows the
+// UK Open Banking domestic payments API. Calls go through a circuit breaker so an
+// outage fails fast and the transfers are retried later.
+type SandboxBankConnector struct {
+	cfg     SandboxBankConfig
+	client  *http.Client
+	breaker *circuitbreaker.Breaker
+}
+
+func NewSandboxBankConnector(cfg SandboxBankConfig) *SandboxBankConnector {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return &SandboxBankConnector{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		breaker: circuitbreaker.New(circuitbreaker.Config{Name: SandboxBankConnectorName, MinRequests: 5}),
+	}
+}
+
+func (s *SandboxBankConnector) Name() string { return SandboxBankConnectorName }
+
+func (s *SandboxBankConnector) Supports(scheme Scheme) bool {
+	return scheme == SchemeFPS || scheme == SchemeSEPA
+}
+
+type sandboxAmount struct {
+	Amount   string `json:"Amount"`
+	Currency string `json:"Currency"`
+}
+
+type sandboxAccount struct {
+	SchemeName     string `json:"SchemeName"`
+	Identification string `json:"Identification"`
+	Name           string `json:"Name"`
+}
+
+type sandboxPaymentRequest struct {
+	Data struct {
+		Initiation struct {
+			InstructionIdentification string         `json:"InstructionIdentification"`
+			EndToEndIdentification    string         `json:"EndToEndIdentification"`
+			InstructedAmount          sandboxAmount  `json:"InstructedAmount"`
+			CreditorAccount           sandboxAccount `json:"CreditorAccount"`
+			RemittanceInformation     struct {
+				Reference string `json:"Reference,omitempty"`
+			} `json:"RemittanceInformation"`
+		} `json:"Initiation"`
+	} `json:"Data"`
+}
+
+type sandboxPaymentStatus struct {
+	Data struct {
+		DomesticPaymentID string `json:"DomesticPaymentId"`
+		Status            string `json:"Status"`
+		StatusReason      string `json:"StatusReason,omitempty"`
+	} `json:"Data"`
+}
+
+func (s *SandboxBankConnector) Submit(ctx context.Context, p PaymentInstruction) (*Submission, error) {
+	var req sandboxPaymentRequest
+	initiation := &req.Data.Initiation
+	initiation.InstructionIdentification = p.ID
+	initiation.EndToEndIdentification = p.ID
+	initiation.InstructedAmount = sandboxAmount{Amount: p.Amount.StringFixed(2), Currency: p.Currency}
+	initiation.CreditorAccount = sandboxAccount{Name: p.Destination.Name}
+	if p.Scheme == SchemeSEPA {
+		initiation.CreditorAccount.SchemeName = "UK.OBIE.IBAN"
+		initiation.CreditorAccount.Identification = p.Destination.IBAN
+	} else {
+		initiation.CreditorAccount.SchemeName = "UK.OBIE.SortCodeAccountNumber"
+		initiation.CreditorAccount.Identification = p.Destination.SortCode + p.Destination.AccountNumber
+	}
+	initiation.RemittanceInformation.Reference = p.Reference
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var result sandboxPaymentStatus
+	var rejection error
+	err = s.breaker.Do(ctx, func(ctx context.Context) error {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.BaseURL+"/domestic-payments", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+s.cfg.APIKey)
+		httpReq.Header.Set("x-idempotency-key", p.ID)
+
+		resp, err := s.client.Do(httpReq)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			return fmt.Errorf("sandbox bank returned status %d", resp.StatusCode)
+		case resp.StatusCode >= 400:
+			// The request itself was refused; this is not an outage
+			rejection = fmt.Errorf("%w: sandbox bank returned status %d: %s", ErrRejected, resp.StatusCode, strings.TrimSpace(string(respBody)))
+			return nil
+		}
+		return json.Unmarshal(respBody, &result)
+	})
+	if rejection != nil {
+		return nil, rejection
+	}
+	if err != nil {
+		// Includes unreadable responses: the idempotency key makes a retry safe,
+		// whereas treating them as rejected could refund a payment that was sent
+		return nil, fmt.Errorf("%w: %v", ErrTransient, err)
+	}
+
+	status := sandboxStatus(result.Data.Status)
+	if status == StatusRejected {
+		return nil, fmt.Errorf("%w: %s", ErrRejected, result.Data.StatusReason)
+	}
+	return &Submission{ExternalID: result.Data.DomesticPaymentID, Status: status}, nil
+}
+
+func (s *SandboxBankConnector) ParseWebhook(header http.Header, body []byte) (*StatusUpdate, error) {
+	if err := s.verifySignature(header, body); err != nil {
+		return nil, err
+	}
+
+	var payload sandboxPaymentStatus
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Data.DomesticPaymentID == "" {
+		return nil, fmt.Errorf("invalid sandbox bank webhook payload")
+	}
+	return &StatusUpdate{
+		ExternalID: payload.Data.DomesticPaymentID,
+		Status:     sandboxStatus(payload.Data.Status),
+		Reason:     payload.Data.StatusReason,
+	}, nil
+}
+
+// sandboxCredit is the sandbox's notification of a domestic payment received
+// into one of our accounts
+type sandboxCredit struct {
+	Data struct {
+		TransactionID         string         `json:"TransactionId"`
+		Amount                sandboxAmount  `json:"Amount"`
+		DebtorAccount         sandboxAccount `json:"DebtorAccount"`
+		CreditorAccount       sandboxAccount `json:"CreditorAccount"`
+		RemittanceInformation struct {
+			Reference string `json:"Reference,omitempty"`
+		} `json:"RemittanceInformation"`
+	} `json:"Data"`
+}
+
+func (s *SandboxBankConnector) ParseCreditWebhook(header http.Header, body []byte) (*IncomingCredit, error) {
+	if err := s.verifySignature(header, body); err != nil {
+		return nil, err
+	}
+
+	var payload sandboxCredit
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Data.TransactionID == "" {
+		return nil, fmt.Errorf("invalid sandbox bank credit webhook payload")
+	}
+	amount, err := decimal.NewFromString(payload.Data.Amount.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox bank credit amount %q", payload.Data.Amount.Amount)
+	}
+
+	var beneficiary Destination
+	creditor := payload.Data.CreditorAccount
+	switch creditor.SchemeName {
+	case "UK.OBIE.IBAN":
+		beneficiary.IBAN = creditor.Identification
+	case "UK.OBIE.SortCodeAccountNumber":
+		if id := strings.TrimSpace(creditor.Identification); len(id) == 14 {
+			beneficiary.SortCode, beneficiary.AccountNumber = id[:6], id[6:]
+		}
+	}
+	beneficiary = beneficiary.Normalize()
+	scheme, _ := beneficiary.Scheme()
+	return &IncomingCredit{
+		ExternalID:  payload.Data.TransactionID,
+		Amount:      amount,
+		Currency:    payload.Data.Amount.Currency,
+		Scheme:      scheme,
+		DebtorName:  payload.Data.DebtorAccount.Name,
+		Beneficiary: beneficiary,
+		Reference:   payload.Data.RemittanceInformation.Reference,
+	}, nil
+}
+
+// verifySignature checks the hex HMAC-SHA256 of a webhook body
+func (s *SandboxBankConnector) verifySignature(header http.Header, body []byte) error {
+	return verifyHMAC(s.cfg.WebhookSecret, header.Get(SandboxSignatureHeader), body)
+}
+
+// sandboxStatus maps Open Banking payment statuses to connector statuses
+func sandboxStatus(status string) Status {
+	switch status {
+	case "AcceptedSettlementCompleted", "AcceptedCreditSettlementCompleted":
+		return StatusSettled
+	case "Rejected":
+		return StatusRejected
+	default: // Pending, AcceptedSettlementInProcess
+		return StatusAccepted
+	}
+}
@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+)
+
+// ConsumerGroup is the Kafka consumer group of the payment service
+const ConsumerGroup = "payment-service"
+
+// Topics are the ledger's results for payments processed asynchronously
+var Topics = []string{
+	kafka.TopicPaymentCompleted,
+	kafka.TopicPaymentFailed,
+}
+
+// ResultConsumer records the ledger's result for payments it processed, so
+// they stop being pending and can no longer be cancelled
+type ResultConsumer struct {
+	consumers map[string]*kafka.Consumer
+	svc       *service.PaymentService
+}
+
+// NewResultConsumer creates one consumer per result topic in the payment consumer group
+func NewResultConsumer(brokers []string, svc *service.PaymentService) *ResultConsumer {
+	consumers := make(map[string]*kafka.Consumer, len(Topics))
+	for _, topic := range Topics {
+		consumers[topic] = kafka.NewConsumer(brokers, ConsumerGroup, topic)
+	}
+	return &ResultConsumer{consumers: consumers, svc: svc}
+}
+
+// Start consumes every topic until the context is cancelled
+func (c *ResultConsumer) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for topic, consumer := range c.consumers {
+		wg.Add(1)
+		go func(topic string, consumer *kafka.Consumer) {
+			defer wg.Done()
+			slog.Info("Starting payment result consumer", "topic", topic)
+			if err := consumer.Consume(ctx, func(key string, value []byte) error {
+				return Handle(c.svc, topic, value)
+			}); err != nil && ctx.Err() == nil {
+				slog.Error("Kafka consumer error", "topic", topic, "error", err)
+			}
+		}(topic, consumer)
+	}
+	wg.Wait()
+}
+
+// Handle records one result event from the given topic
+func Handle(svc *service.PaymentService, topic string, value []byte) error {
+	var event kafka.PaymentEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return err
+	}
+	switch topic {
+	case kafka.TopicPaymentCompleted:
+		return svc.ApplyLedgerResult(event, model.StatusCompleted)
+	case kafka.TopicPaymentFailed:
+		return svc.ApplyLedgerResult(event, model.StatusFailed)
+	}
+	slog.Warn("Ignoring event from unexpected topic", "topic", topic)
+	return nil
+}
+
+// Close closes every topic consumer
+func (c *ResultConsumer) Close() error {
+	var firstErr error
+	for _, consumer := range c.consumers {
+		if err := consumer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
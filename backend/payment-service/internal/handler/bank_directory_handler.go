@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/bankdirectory"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// BankDirectoryHandler looks up the banks behind sort codes, BICs and routing numbers
+type BankDirectoryHandler struct {
+	Directory *bankdirectory.Directory
+}
+
+func NewBankDirectoryHandler(d *bankdirectory.Directory) *BankDirectoryHandler {
+	return &BankDirectoryHandler{Directory: d}
+}
+
+// BankLookupQuery names the identifier to look up; exactly one must be set
+type BankLookupQuery struct {
+	SortCode      string `form:"sort_code" binding:"omitempty,max=12"`
+	BIC           string `form:"bic" binding:"omitempty,max=16"`
+	RoutingNumber string `form:"routing_number" binding:"omitempty,max=16"`
+}
+
+// LookupBank returns the bank a sort code, BIC or routing number belongs to,
+// so clients can show the bank's name before a transfer is sent
+func (h *BankDirectoryHandler) LookupBank(c *gin.Context) {
+	var q BankLookupQuery
+	if !validation.BindQuery(c, &q) {
+		return
+	}
+
+	var kind bankdirectory.Kind
+	var code string
+	given := 0
+	for k, v := range map[bankdirectory.Kind]string{
+		bankdirectory.KindSortCode:      q.SortCode,
+		bankdirectory.KindBIC:           q.BIC,
+		bankdirectory.KindRoutingNumber: q.RoutingNumber,
+	} {
+		if v != "" {
+			kind, code = k, v
+			given++
+		}
+	}
+	if given != 1 {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("give exactly one of sort_code, bic or routing_number"))
+		return
+	}
+
+	bank, err := h.Directory.Lookup(c.Request.Context(), kind, code)
+	switch {
+	case errors.Is(err, bankdirectory.ErrInvalidCode):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, bankdirectory.ErrBankNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case err != nil:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	default:
+		c.JSON(http.StatusOK, bank)
+	}
+}
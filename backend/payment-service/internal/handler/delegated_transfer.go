@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var errDelegationLimitExceeded = apperrors.NewError("DELEGATION_LIMIT_EXCEEDED",
+	"amount exceeds the payment limit of the delegation", http.StatusForbidden)
+
+// DelegatedTransfer makes a transfer for the grantor of a delegation, from
+// one of the delegated accounts and up to the delegation's payment limit. The
+// grantor's transfer limits and duplicate checks apply as to their own
+// transfers. Delegate tokens carry no step-up; the payment limit the grantor
+// chose bounds them instead.
+func (h *PaymentHandler) DelegatedTransfer(c *gin.Context) {
+	var req TransferRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if !middleware.DelegationAllowsAccount(c, req.FromAccountID) {
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage("the delegation does not cover the account"))
+		return
+	}
+	limit, ok := middleware.DelegationPaymentLimit(c)
+	if amount, err := decimal.NewFromString(req.Amount); !ok || err != nil || amount.GreaterThan(limit) {
+		apperrors.RespondWithError(c, errDelegationLimitExceeded.WithDetails(gin.H{"payment_limit": limit}))
+		return
+	}
+
+	payment := h.transfer(c, req)
+	if payment == nil {
+		return
+	}
+	h.Audit.LogEvent(middleware.AuditEventDelegatedAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"operation":       "delegated_transfer",
+		"delegation_id":   middleware.GetDelegationID(c),
+		"delegate_id":     middleware.GetDelegateID(c),
+		"payment_id":      payment.ID.String(),
+		"from_account_id": req.FromAccountID,
+		"amount":          payment.Amount,
+		"currency":        payment.Currency,
+		"status":          payment.Status,
+	})
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// MaxWebhookBytes limits the size of a connector webhook body
+const MaxWebhookBytes = 1 << 20
+
+type ExternalTransferHandler struct {
+	Service *service.ExternalTransferService
+	// StepUpThreshold is the amount from which transfers need a recent step-up
+	StepUpThreshold decimal.Decimal
+}
+
+func NewExternalTransferHandler(s *service.ExternalTransferService) *ExternalTransferHandler {
+	return &ExternalTransferHandler{Service: s, StepUpThreshold: DefaultStepUpThreshold}
+}
+
+// ExternalTransferRequest pays an account at another bank, identified either by
+// IBAN or by sort code and account number
+type ExternalTransferRequest struct {
+	FromAccountID string `json:"from_account_id" binding:"required,uuid"`
+	Amount        string `json:"amount" binding:"required,amount"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	CreditorName  string `json:"creditor_name" binding:"required,max=140"`
+	IBAN          string `json:"iban" binding:"omitempty,max=42,iban"`
+	SortCode      string `json:"sort_code" binding:"omitempty,max=8"`
+	AccountNumber string `json:"account_number" binding:"omitempty,max=8"`
+	Reference     string `json:"reference" binding:"omitempty,max=35"`
+}
+
+// CreateExternalTransfer debits the account and sends the transfer to the connector for its scheme
+func (h *ExternalTransferHandler) CreateExternalTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req ExternalTransferRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if stepUpRequired(c, h.StepUpThreshold, req.Amount) {
+		return
+	}
+
+	transfer, err := h.Service.CreateExternalTransfer(c.Request.Context(), userID, service.ExternalTransferRequest{
+		FromAccountID: req.FromAccountID,
+		Amount:        req.Amount,
+		Currency:      req.Currency,
+		Reference:     req.Reference,
+		Destination: connectors.Destination{
+			Name:          req.CreditorName,
+			IBAN:          req.IBAN,
+			SortCode:      req.SortCode,
+			AccountNumber: req.AccountNumber,
+		},
+	})
+	if err != nil {
+		respondExternalTransferError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, transfer)
+}
+
+// GetExternalTransfer returns the status of one of the user's external transfers
+func (h *ExternalTransferHandler) GetExternalTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	transfer, err := h.Service.GetExternalTransfer(userID, c.Param("id"))
+	if err != nil {
+		respondExternalTransferError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// ConnectorWebhook receives status updates from a connector. It is not behind JWT
+// auth; each connector authenticates its own webhooks.
+func (h *ExternalTransferHandler) ConnectorWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxWebhookBytes))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("webhook body must be at most 1MB"))
+		return
+	}
+
+	if err := h.Service.HandleWebhook(c.Param("connector"), c.Request.Header, body); err != nil {
+		respondExternalTransferError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondExternalTransferError maps external transfer and connector errors to API errors
+func respondExternalTransferError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidExternalTransfer),
+		errors.Is(err, connectors.ErrInvalidDestination),
+		errors.Is(err, connectors.ErrUnsupportedScheme):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrExternalTransferNotFound),
+		errors.Is(err, service.ErrConnectorNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, connectors.ErrInvalidSignature):
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrExternalTransfersDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("EXTERNAL_TRANSFERS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
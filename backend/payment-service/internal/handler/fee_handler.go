@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// FeeHandler serves the admin API for fee schedules
+type FeeHandler struct {
+	Service *service.FeeService
+}
+
+func NewFeeHandler(s *service.FeeService) *FeeHandler {
+	return &FeeHandler{Service: s}
+}
+
+// ListFeeSchedules returns every fee schedule, active or not
+func (h *FeeHandler) ListFeeSchedules(c *gin.Context) {
+	schedules, err := h.Service.ListSchedules()
+	if err != nil {
+		respondFeeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetFeeSchedule returns one fee schedule
+func (h *FeeHandler) GetFeeSchedule(c *gin.Context) {
+	schedule, err := h.Service.GetSchedule(c.Param("id"))
+	if err != nil {
+		respondFeeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// CreateFeeSchedule adds a fee schedule, active unless the body says otherwise
+func (h *FeeHandler) CreateFeeSchedule(c *gin.Context) {
+	req := model.FeeSchedule{Active: true}
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	schedule, err := h.Service.CreateSchedule(&req)
+	if err != nil {
+		respondFeeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateFeeSchedule replaces a fee schedule; it applies to payments made from then on
+func (h *FeeHandler) UpdateFeeSchedule(c *gin.Context) {
+	req := model.FeeSchedule{Active: true}
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	schedule, err := h.Service.UpdateSchedule(c.Param("id"), &req)
+	if err != nil {
+		respondFeeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteFeeSchedule removes a fee schedule
+func (h *FeeHandler) DeleteFeeSchedule(c *gin.Context) {
+	if err := h.Service.DeleteSchedule(c.Param("id")); err != nil {
+		respondFeeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondFeeError maps fee service errors to API errors
+func respondFeeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrFeeScheduleNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrInvalidFeeSchedule):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
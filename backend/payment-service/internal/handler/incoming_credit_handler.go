@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// IncomingCreditHandler receives credits from other banks and serves the ops
+// API for credits held in suspense
+type IncomingCreditHandler struct {
+	Service *service.IncomingCreditService
+}
+
+func NewIncomingCreditHandler(s *service.IncomingCreditService) *IncomingCreditHandler {
+	return &IncomingCreditHandler{Service: s}
+}
+
+// CreditWebhook receives an incoming credit from a connector. Like status
+// webhooks it is not behind JWT auth; each connector authenticates its own.
+// An error response asks the connector to redeliver the credit.
+func (h *IncomingCreditHandler) CreditWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxWebhookBytes))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("webhook body must be at most 1MB"))
+		return
+	}
+
+	credit, err := h.Service.ReceiveCredit(c.Request.Context(), c.Param("connector"), c.Request.Header, body)
+	if err != nil {
+		respondIncomingCreditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, credit)
+}
+
+// ListIncomingCredits returns incoming credits, optionally filtered by status,
+// e.g. ?status=SUSPENSE for the suspense queue
+func (h *IncomingCreditHandler) ListIncomingCredits(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	credits, err := h.Service.ListCredits(model.IncomingCreditStatus(c.Query("status")), limit)
+	if err != nil {
+		respondIncomingCreditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": credits})
+}
+
+// GetIncomingCredit returns one incoming credit
+func (h *IncomingCreditHandler) GetIncomingCredit(c *gin.Context) {
+	credit, err := h.Service.GetCredit(c.Param("id"))
+	if err != nil {
+		respondIncomingCreditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, credit)
+}
+
+// RepostIncomingCreditRequest names the account a credit in suspense belongs
+// to, by account ID or alias, and why
+type RepostIncomingCreditRequest struct {
+	AccountID     string `json:"account_id" binding:"omitempty,uuid"`
+	IBAN          string `json:"iban" binding:"omitempty,max=42,iban"`
+	SortCode      string `json:"sort_code" binding:"omitempty,max=8"`
+	AccountNumber string `json:"account_number" binding:"omitempty,max=8"`
+	Note          string `json:"note" binding:"required,max=500"`
+}
+
+// RepostIncomingCredit moves a credit out of suspense to the right account
+func (h *IncomingCreditHandler) RepostIncomingCredit(c *gin.Context) {
+	var req RepostIncomingCreditRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	destination := connectors.Destination{IBAN: req.IBAN, SortCode: req.SortCode, AccountNumber: req.AccountNumber}.Normalize()
+	credit, err := h.Service.RepostCredit(c.Request.Context(), middleware.GetUserID(c), c.Param("id"), service.RepostRequest{
+		Destination: service.Destination{
+			AccountID:     req.AccountID,
+			IBAN:          destination.IBAN,
+			SortCode:      destination.SortCode,
+			AccountNumber: destination.AccountNumber,
+		},
+		Note: req.Note,
+	})
+	if err != nil {
+		respondIncomingCreditError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, credit)
+}
+
+// respondIncomingCreditError maps incoming credit and connector errors to API errors
+func respondIncomingCreditError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidIncomingCredit),
+		errors.Is(err, service.ErrInvalidDestination):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrIncomingCreditNotFound),
+		errors.Is(err, service.ErrConnectorNotFound),
+		errors.Is(err, service.ErrCreditsNotSupported),
+		errors.Is(err, service.ErrAliasNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrIncomingCreditNotInSuspense):
+		apperrors.RespondWithError(c, apperrors.NewError("INCOMING_CREDIT_STATE", err.Error(), http.StatusConflict))
+	case errors.Is(err, connectors.ErrInvalidSignature):
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrIncomingCreditsDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("INCOMING_CREDITS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// MerchantAPIKeyHeader is the header merchants use to authenticate
+const MerchantAPIKeyHeader = "X-API-Key"
+
+// merchantContextKey is the gin context key holding the authenticated merchant
+const merchantContextKey = "merchant"
+
+type MandateHandler struct {
+	Service *service.MandateService
+}
+
+func NewMandateHandler(s *service.MandateService) *MandateHandler {
+	return &MandateHandler{Service: s}
+}
+
+// MerchantAuth authenticates merchant requests using their API key
+func (h *MandateHandler) MerchantAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		merchant, err := h.Service.AuthenticateMerchant(c.GetHeader(MerchantAPIKeyHeader))
+		if err != nil {
+			apperrors.RespondWithError(c, apperrors.ErrUnauthorized.WithMessage(err.Error()))
+			return
+		}
+		c.Set(merchantContextKey, merchant)
+		c.Next()
+	}
+}
+
+func getMerchant(c *gin.Context) *model.Merchant {
+	if v, exists := c.Get(merchantContextKey); exists {
+		if m, ok := v.(*model.Merchant); ok {
+			return m
+		}
+	}
+	return nil
+}
+
+type RegisterMerchantRequest struct {
+	Name                string `json:"name" binding:"required"`
+	SettlementAccountID string `json:"settlement_account_id" binding:"required,uuid"`
+}
+
+// RegisterMerchant creates a merchant owned by the authenticated user and returns its API key once
+func (h *MandateHandler) RegisterMerchant(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req RegisterMerchantRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	merchant, apiKey, err := h.Service.RegisterMerchant(userID, req.Name, req.SettlementAccountID)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"merchant": merchant, "api_key": apiKey})
+}
+
+type CreateMandateRequest struct {
+	UserID          string     `json:"user_id" binding:"required,uuid"`
+	DebtorAccountID string     `json:"debtor_account_id" binding:"required,uuid"`
+	Reference       string     `json:"reference" binding:"required"`
+	Currency        string     `json:"currency" binding:"required,currency"`
+	MaxAmount       string     `json:"max_amount" binding:"required,amount"`
+	MonthlyLimit    string     `json:"monthly_limit" binding:"omitempty,amount"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+}
+
+// CreateMandate lets a merchant request a mandate from a user
+func (h *MandateHandler) CreateMandate(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CreateMandateRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	mandate, err := h.Service.CreateMandate(merchant.ID.String(), service.CreateMandateRequest{
+		UserID:          req.UserID,
+		DebtorAccountID: req.DebtorAccountID,
+		Reference:       req.Reference,
+		Currency:        req.Currency,
+		MaxAmount:       req.MaxAmount,
+		MonthlyLimit:    req.MonthlyLimit,
+		ExpiresAt:       req.ExpiresAt,
+	})
+	if err != nil {
+		respondMandateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, mandate)
+}
+
+// GetMerchantMandate returns a mandate owned by the authenticated merchant
+func (h *MandateHandler) GetMerchantMandate(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	mandate, err := h.Service.GetMerchantMandate(merchant.ID.String(), c.Param("id"))
+	if err != nil {
+		respondMandateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mandate)
+}
+
+// CancelMandate lets the merchant cancel one of its mandates
+func (h *MandateHandler) CancelMandate(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	mandate, err := h.Service.CancelMandate(merchant.ID.String(), c.Param("id"))
+	if err != nil {
+		respondMandateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mandate)
+}
+
+type CollectRequest struct {
+	Amount      string `json:"amount" binding:"required,amount"`
+	Description string `json:"description"`
+}
+
+// Collect pulls a payment against an active mandate
+func (h *MandateHandler) Collect(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CollectRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	payment, err := h.Service.Collect(merchant, c.Param("id"), req.Amount, req.Description)
+	if err != nil {
+		respondMandateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, payment)
+}
+
+// ListMandates returns the authenticated user's mandates
+func (h *MandateHandler) ListMandates(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	mandates, err := h.Service.ListUserMandates(userID)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, mandates)
+}
+
+// ApproveMandate activates a mandate for the authenticated payer
+func (h *MandateHandler) ApproveMandate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	mandate, err := h.Service.ApproveMandate(userID, c.Param("id"))
+	if err != nil {
+		respondMandateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mandate)
+}
+
+// RevokeMandate revokes a mandate for the authenticated payer
+func (h *MandateHandler) RevokeMandate(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	mandate, err := h.Service.RevokeMandate(userID, c.Param("id"))
+	if err != nil {
+		respondMandateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mandate)
+}
+
+// respondMandateError maps mandate service errors to API errors
+func respondMandateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrMandateNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrMandateForbidden),
+		errors.Is(err, service.ErrMandateDebtorAccount):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccountUnavailable):
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrMandateLimitExceeded):
+		apperrors.RespondWithError(c, apperrors.ErrTransferLimit.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrMandateNotActive),
+		errors.Is(err, service.ErrMandateExpired),
+		errors.Is(err, service.ErrMandateInvalidState):
+		apperrors.RespondWithError(c, apperrors.NewError("MANDATE_INVALID_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentDecisionRequest struct {
+	Comment string `json:"comment" binding:"max=500"`
+}
+
+// ListAwaitingApproval returns the payments of the caller's organization that
+// wait for approvals, oldest first
+func (h *PaymentHandler) ListAwaitingApproval(c *gin.Context) {
+	payments, err := h.Service.ListAwaitingApproval(middleware.GetOrgID(c))
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, payments)
+}
+
+// GetPaymentApprovals returns who approved or rejected a business payment and
+// how many approvals it still needs
+func (h *PaymentHandler) GetPaymentApprovals(c *gin.Context) {
+	approvals, err := h.Service.PaymentApprovalStatus(middleware.GetUserID(c), middleware.GetOrgID(c), c.Param("id"))
+	if err != nil {
+		respondApprovalError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, approvals)
+}
+
+// ApprovePayment records the caller's approval of one of their organization's
+// payments, making the payment once it has all the approvals it needs
+func (h *PaymentHandler) ApprovePayment(c *gin.Context) {
+	h.decide(c, middleware.AuditEventPaymentApprove, h.Service.ApprovePayment)
+}
+
+// RejectPayment records the caller's rejection of one of their organization's
+// payments, which rejects the payment
+func (h *PaymentHandler) RejectPayment(c *gin.Context) {
+	h.decide(c, middleware.AuditEventPaymentReject, h.Service.RejectPayment)
+}
+
+// decide records the caller's decision on a payment and audits it with the
+// approvals the payment has so far
+func (h *PaymentHandler) decide(c *gin.Context, event middleware.AuditEventType, decide func(service.Approver, string, string) (*service.PaymentApprovals, error)) {
+	var req PaymentDecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength != 0 {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	approver := service.Approver{UserID: middleware.GetUserID(c), OrgID: middleware.GetOrgID(c), OrgRole: middleware.GetOrgRole(c)}
+	approvals, err := decide(approver, c.Param("id"), req.Comment)
+	if approvals == nil {
+		respondApprovalError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(event, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"payment_id":         approvals.Payment.ID.String(),
+		"org_id":             approver.OrgID,
+		"org_role":           approver.OrgRole,
+		"amount":             approvals.Payment.Amount,
+		"currency":           approvals.Payment.Currency,
+		"status":             approvals.Payment.Status,
+		"approvals_received": approvals.Approved,
+		"approvals_required": approvals.Required,
+		"comment":            req.Comment,
+	})
+	if err != nil {
+		// Approved, but the payment then failed like any transfer can
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "approvals": approvals})
+		return
+	}
+	c.JSON(http.StatusOK, approvals)
+}
+
+// respondApprovalError maps payment approval errors to API errors
+func respondApprovalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrApproverRole), errors.Is(err, service.ErrSelfApproval):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrNotAwaitingApproval), errors.Is(err, service.ErrApprovalExpired):
+		apperrors.RespondWithError(c, apperrors.NewError("PAYMENT_NOT_AWAITING_APPROVAL", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrAlreadyDecided):
+		apperrors.RespondWithError(c, apperrors.NewError("ALREADY_DECIDED", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrApprovalsDisabled):
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/iso20022"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// MaxPain001Bytes limits the size of an uploaded pain.001 file
+const MaxPain001Bytes = 5 << 20
+
+type PaymentBatchHandler struct {
+	Service *service.PaymentBatchService
+}
+
+func NewPaymentBatchHandler(s *service.PaymentBatchService) *PaymentBatchHandler {
+	return &PaymentBatchHandler{Service: s}
+}
+
+// ImportPain001 accepts a pain.001 XML document as the request body and executes its transfers
+func (h *PaymentBatchHandler) ImportPain001(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	document, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxPain001Bytes))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("pain.001 document must be at most 5MB"))
+		return
+	}
+
+	batch, err := h.Service.ImportPain001(c.Request.Context(), userID, document)
+	if err != nil {
+		respondPaymentBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, batch)
+}
+
+// GetPaymentBatch returns an imported batch with the outcome of each transfer
+func (h *PaymentBatchHandler) GetPaymentBatch(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	batch, err := h.Service.GetPaymentBatch(userID, c.Param("id"))
+	if err != nil {
+		respondPaymentBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// ExportPacs008 renders the batch's executed transfers as a pacs.008 document
+func (h *PaymentBatchHandler) ExportPacs008(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	messageID, document, err := h.Service.ExportPacs008(userID, c.Param("id"))
+	if err != nil {
+		respondPaymentBatchError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+messageID+`.xml"`)
+	c.Data(http.StatusOK, "application/xml", document)
+}
+
+// SubmitToClearing sends the batch to the clearing connector
+func (h *PaymentBatchHandler) SubmitToClearing(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	batch, err := h.Service.SubmitToClearing(c.Request.Context(), userID, c.Param("id"))
+	if err != nil {
+		respondPaymentBatchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
+
+// respondPaymentBatchError maps payment batch service errors to API errors
+func respondPaymentBatchError(c *gin.Context, err error) {
+	var verr *iso20022.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("invalid ISO 20022 document").WithDetails(verr.Errors))
+	case errors.Is(err, service.ErrPaymentBatchNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrDuplicatePaymentBatch),
+		errors.Is(err, service.ErrPaymentBatchCleared),
+		errors.Is(err, service.ErrPaymentBatchNotCleared):
+		apperrors.RespondWithError(c, apperrors.NewError("PAYMENT_BATCH_CONFLICT", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -1,41 +1,260 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 type PaymentHandler struct {
 	Service *service.PaymentService
+	// StepUpThreshold is the amount from which transfers need a recent step-up
+	StepUpThreshold decimal.Decimal
+	// Audit records transfers delegates make for other users
+	Audit *middleware.AuditLogger
 }
 
 func NewPaymentHandler(s *service.PaymentService) *PaymentHandler {
-	return &PaymentHandler{Service: s}
+	return &PaymentHandler{
+		Service:         s,
+		StepUpThreshold: DefaultStepUpThreshold,
+		Audit:           middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: "payment-service"}),
+	}
 }
 
 type TransferRequest struct {
-	FromAccountID string `json:"from_account_id" binding:"required"`
-	ToAccountID   string `json:"to_account_id" binding:"required"`
-	Amount        string `json:"amount" binding:"required"`
-	Currency      string `json:"currency" binding:"required"`
-	Description   string `json:"description"`
+	FromAccountID string `json:"from_account_id" binding:"required,uuid"`
+	// The destination is an account ID or an alias: an IBAN, or a sort code and account number
+	ToAccountID     string `json:"to_account_id" binding:"omitempty,uuid"`
+	ToIBAN          string `json:"to_iban" binding:"omitempty,iban"`
+	ToSortCode      string `json:"to_sort_code"`
+	ToAccountNumber string `json:"to_account_number"`
+	Amount          string `json:"amount" binding:"required,amount"`
+	Currency        string `json:"currency" binding:"required,currency"`
+	Description     string `json:"description"`
+	// ConfirmationToken makes a transfer flagged as a possible duplicate anyway
+	ConfirmationToken string `json:"confirmation_token"`
+	// Rail is INSTANT or STANDARD; without one the transfer is processed as before rails
+	Rail string `json:"rail" binding:"omitempty,oneof=INSTANT STANDARD"`
 }
 
 func (h *PaymentHandler) MakeTransfer(c *gin.Context) {
 	var req TransferRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+	if stepUpRequired(c, h.StepUpThreshold, req.Amount) {
 		return
 	}
+	h.transfer(c, req)
+}
 
-	payment, err := h.Service.InitiateTransfer(req.FromAccountID, req.ToAccountID, req.Amount, req.Currency, req.Description)
+// ValidateTransfer runs the checks a transfer would go through and returns
+// the report with its fee, without making it, for a confirmation screen.
+// Failed checks are part of the report, not errors; the confirmation token is
+// ignored since nothing is checked for duplicates.
+func (h *PaymentHandler) ValidateTransfer(c *gin.Context) {
+	var req TransferRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	report, err := h.Service.ValidateUserTransfer(c.Request.Context(), middleware.GetUserID(c), req.FromAccountID, service.Destination{
+		AccountID:     req.ToAccountID,
+		IBAN:          req.ToIBAN,
+		SortCode:      req.ToSortCode,
+		AccountNumber: req.ToAccountNumber,
+	}, req.Amount, req.Currency, model.TransferRail(req.Rail), time.Now().UTC())
 	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// transfer makes a bound transfer request for the user in the context and
+// responds with the outcome. It returns the payment, which may have failed,
+// or nil if none was made.
+func (h *PaymentHandler) transfer(c *gin.Context, req TransferRequest) *model.Payment {
+	middleware.TraceBusinessContext(c, tracing.BusinessContext{AccountID: req.FromAccountID})
+	toAccountID, err := h.Service.ResolveDestination(c.Request.Context(), service.Destination{
+		AccountID:     req.ToAccountID,
+		IBAN:          req.ToIBAN,
+		SortCode:      req.ToSortCode,
+		AccountNumber: req.ToAccountNumber,
+	})
+	switch {
+	case errors.Is(err, service.ErrInvalidDestination):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return nil
+	case errors.Is(err, service.ErrAliasNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+		return nil
+	case err != nil:
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+		return nil
+	}
+
+	payment, err := h.Service.InitiateUserTransfer(c.Request.Context(), middleware.GetUserID(c), req.FromAccountID, toAccountID, req.Amount, req.Currency, req.Description, req.ConfirmationToken, model.TransferRail(req.Rail))
+	if payment != nil {
+		middleware.TraceBusinessContext(c, tracing.BusinessContext{PaymentID: payment.ID.String()})
+	}
+	var limitErr *service.LimitExceededError
+	var dupErr *service.DuplicatePaymentError
+	switch {
+	case errors.As(err, &dupErr):
+		apperrors.RespondWithError(c, apperrors.NewError("DUPLICATE_PAYMENT", err.Error(), http.StatusConflict).WithDetails(dupErr))
+		return nil
+	case errors.Is(err, service.ErrInvalidDuplicateConfirmation),
+		errors.Is(err, money.ErrPrecision),
+		errors.Is(err, money.ErrInvalidCurrency),
+		errors.Is(err, service.ErrInvalidRail):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return nil
+	case errors.Is(err, service.ErrInstantRailIneligible):
+		apperrors.RespondWithError(c, errInstantRailIneligible.WithMessage(err.Error()))
+		return nil
+	case errors.Is(err, service.ErrRailsDisabled):
+		apperrors.RespondWithError(c, errRailsDisabled)
+		return nil
+	case errors.As(err, &limitErr):
+		apperrors.RespondWithError(c, apperrors.ErrTransferLimit.WithMessage(err.Error()).WithDetails(limitErr))
+		return nil
+	case errors.Is(err, service.ErrLimitsUnavailable), errors.Is(err, service.ErrApprovalCheckUnavailable):
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+		return nil
+	case err != nil:
 		// Return 400 or 500 depending on error, but send payment object so user knows it failed
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "payment": payment})
-		return
+		return payment
 	}
 
 	c.JSON(http.StatusCreated, payment)
+	return payment
+}
+
+// GetTransferTimeline returns the states one of the user's transfers went
+// through, with when and by whom
+func (h *PaymentHandler) GetTransferTimeline(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	respondTimeline(c, func() (*service.TransferTimeline, error) {
+		return h.Service.TransferTimeline(userID, c.Param("id"))
+	})
+}
+
+// GetAnyTransferTimeline returns the timeline of any payment
+func (h *PaymentHandler) GetAnyTransferTimeline(c *gin.Context) {
+	respondTimeline(c, func() (*service.TransferTimeline, error) {
+		return h.Service.AnyTransferTimeline(c.Param("id"))
+	})
+}
+
+func respondTimeline(c *gin.Context, get func() (*service.TransferTimeline, error)) {
+	timeline, err := get()
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case err != nil:
+		apperrors.RespondWithError(c, apperrors.ErrInternal)
+	default:
+		c.JSON(http.StatusOK, timeline)
+	}
+}
+
+type TransferLimitsQuery struct {
+	Currency string `form:"currency" binding:"required,currency"`
+}
+
+// GetTransferLimits returns the user's transfer limits and how much of today's
+// limits they have used in a currency
+func (h *PaymentHandler) GetTransferLimits(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	var q TransferLimitsQuery
+	if !validation.BindQuery(c, &q) {
+		return
+	}
+
+	usage, err := h.Service.TransferLimitUsage(c.Request.Context(), userID, q.Currency)
+	switch {
+	case errors.Is(err, service.ErrLimitsDisabled):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case err != nil:
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+	default:
+		c.JSON(http.StatusOK, usage)
+	}
+}
+
+var (
+	errInstantRailIneligible = apperrors.NewError("INSTANT_RAIL_INELIGIBLE", service.ErrInstantRailIneligible.Error(), http.StatusUnprocessableEntity)
+	errRailsDisabled         = apperrors.NewError("RAILS_DISABLED", service.ErrRailsDisabled.Error(), http.StatusServiceUnavailable)
+)
+
+type RailQuoteQuery struct {
+	FromAccountID string `form:"from_account_id" binding:"required,uuid"`
+	Amount        string `form:"amount" binding:"required,amount"`
+	Currency      string `form:"currency" binding:"required,currency"`
+}
+
+// QuoteRails returns the fee and estimated arrival of a transfer on each rail
+// and whether it may use the instant rail
+func (h *PaymentHandler) QuoteRails(c *gin.Context) {
+	var q RailQuoteQuery
+	if !validation.BindQuery(c, &q) {
+		return
+	}
+
+	quotes, err := h.Service.QuoteRails(q.FromAccountID, q.Amount, q.Currency, time.Now().UTC())
+	switch {
+	case errors.Is(err, service.ErrRailsDisabled):
+		apperrors.RespondWithError(c, errRailsDisabled)
+	case errors.Is(err, money.ErrInvalidAmount), errors.Is(err, money.ErrPrecision), errors.Is(err, money.ErrInvalidCurrency):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case err != nil:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	default:
+		c.JSON(http.StatusOK, gin.H{"rails": quotes})
+	}
+}
+
+// CancelTransfer cancels one of the user's transfers while it is still pending
+func (h *PaymentHandler) CancelTransfer(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	payment, err := h.Service.CancelTransfer(c.Request.Context(), userID, c.Param("id"))
+	var cancelErr *service.PaymentCancelledError
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.As(err, &cancelErr):
+		apperrors.RespondWithError(c, apperrors.NewError("PAYMENT_NOT_CANCELLABLE", err.Error(), http.StatusConflict).WithDetails(cancelErr))
+	case errors.Is(err, service.ErrCancellationFailed):
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+	case err != nil:
+		apperrors.RespondWithError(c, apperrors.ErrInternal)
+	default:
+		c.JSON(http.StatusOK, payment)
+	}
 }
@@ -6,8 +6,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -122,3 +126,70 @@ func TestPaymentHandler_ListPayments(t *testing.T) {
 
 	assert.NotNil(t, w)
 }
+
+func TestStepUpRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	check := func(threshold, amount string, claims *middleware.Claims) (bool, *httptest.ResponseRecorder) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if claims != nil {
+			c.Set(string(middleware.ClaimsKey), claims)
+		}
+		return stepUpRequired(c, decimal.RequireFromString(threshold), amount), w
+	}
+	stale := &middleware.Claims{UserID: "u1"}
+	fresh := &middleware.Claims{UserID: "u1", AuthTime: jwt.NewNumericDate(time.Now())}
+
+	required, w := check("1000", "1000.00", stale)
+	assert.True(t, required)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "STEP_UP_REQUIRED")
+
+	for _, tt := range []struct {
+		threshold, amount string
+		claims            *middleware.Claims
+	}{
+		{"1000", "999.99", stale},
+		{"1000", "5000", fresh},
+		{"1000", "5000", &middleware.Claims{UserID: "svc", Role: middleware.ServiceRole}},
+		{"0", "5000", stale},
+		{"1000", "lots", stale}, // rejected by the service
+	} {
+		required, _ := check(tt.threshold, tt.amount, tt.claims)
+		assert.False(t, required, "threshold %s amount %s", tt.threshold, tt.amount)
+	}
+}
+
+func TestDelegatedTransferChecksDelegation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewPaymentHandler(nil)
+	delegate := &middleware.Claims{
+		UserID: "grantor", Role: middleware.DelegateRole, DelegationID: "d1", DelegateID: "grantee",
+		Scope:        middleware.DelegationReadScope + " " + middleware.DelegationPayScope,
+		AccountIDs:   []string{"550e8400-e29b-41d4-a716-446655440000"},
+		PaymentLimit: "250",
+	}
+	transfer := func(from, amount string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"from_account_id": from,
+			"to_account_id":   "550e8400-e29b-41d4-a716-446655440001",
+			"amount":          amount,
+			"currency":        "GBP",
+		})
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/api/v1/delegated/transfer", bytes.NewBuffer(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set(string(middleware.ClaimsKey), delegate)
+		h.DelegatedTransfer(c)
+		return w
+	}
+
+	w := transfer("550e8400-e29b-41d4-a716-446655440002", "10.00")
+	assert.Equal(t, http.StatusForbidden, w.Code, "the delegation does not cover the account")
+	assert.Contains(t, w.Body.String(), "does not cover")
+
+	w = transfer("550e8400-e29b-41d4-a716-446655440000", "250.01")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "DELEGATION_LIMIT_EXCEEDED")
+}
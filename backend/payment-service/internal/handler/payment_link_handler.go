@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentLinkHandler struct {
+	Service *service.PaymentLinkService
+}
+
+func NewPaymentLinkHandler(s *service.PaymentLinkService) *PaymentLinkHandler {
+	return &PaymentLinkHandler{Service: s}
+}
+
+type CreatePaymentLinkRequest struct {
+	AccountID string     `json:"account_id" binding:"required,uuid"`
+	Amount    string     `json:"amount" binding:"omitempty,amount"`
+	Currency  string     `json:"currency" binding:"required,currency"`
+	Reference string     `json:"reference"`
+	SingleUse bool       `json:"single_use"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreatePaymentLink issues a payment link and QR payload for the authenticated user
+func (h *PaymentLinkHandler) CreatePaymentLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CreatePaymentLinkRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	link, err := h.Service.CreatePaymentLink(c.Request.Context(), userID, service.CreatePaymentLinkInput{
+		AccountID: req.AccountID,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Reference: req.Reference,
+		SingleUse: req.SingleUse,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		respondPaymentLinkError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, link)
+}
+
+// ResolvePaymentLink returns the transfer a payer's app should pre-fill for a link
+func (h *PaymentLinkHandler) ResolvePaymentLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	prefill, err := h.Service.ResolvePaymentLink(c.Request.Context(), userID, c.Param("code"))
+	if err != nil {
+		respondPaymentLinkError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, prefill)
+}
+
+// RevokePaymentLink deletes one of the authenticated user's payment links
+func (h *PaymentLinkHandler) RevokePaymentLink(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	if err := h.Service.RevokePaymentLink(c.Request.Context(), userID, c.Param("code")); err != nil {
+		respondPaymentLinkError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// respondPaymentLinkError maps payment link errors to API errors
+func respondPaymentLinkError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPaymentLinksDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("PAYMENT_LINKS_DISABLED", err.Error(), http.StatusServiceUnavailable))
+	case errors.Is(err, service.ErrPaymentLinkNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPaymentLinkForbidden):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrInvalidPaymentLinkTerm), errors.Is(err, service.ErrPaymentLinkOwnAccount):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
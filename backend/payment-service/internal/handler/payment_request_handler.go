@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentRequestHandler struct {
+	Service *service.PaymentRequestService
+}
+
+func NewPaymentRequestHandler(s *service.PaymentRequestService) *PaymentRequestHandler {
+	return &PaymentRequestHandler{Service: s}
+}
+
+type CreatePaymentRequestRequest struct {
+	AccountID   string     `json:"account_id" binding:"required,uuid"`
+	Amount      string     `json:"amount" binding:"required,amount"`
+	Currency    string     `json:"currency" binding:"required,currency"`
+	Description string     `json:"description"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+// CreatePaymentRequest opens a payment request for the authenticated user
+func (h *PaymentRequestHandler) CreatePaymentRequest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CreatePaymentRequestRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	pr, err := h.Service.CreatePaymentRequest(userID, service.CreatePaymentRequestInput{
+		AccountID:   req.AccountID,
+		Amount:      req.Amount,
+		Currency:    req.Currency,
+		Description: req.Description,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	c.JSON(http.StatusCreated, pr)
+}
+
+// ListPaymentRequests returns the requests created by the authenticated user
+func (h *PaymentRequestHandler) ListPaymentRequests(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	requests, err := h.Service.ListPaymentRequests(userID)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetPaymentRequest returns a request by its shareable reference so the payer can review it
+func (h *PaymentRequestHandler) GetPaymentRequest(c *gin.Context) {
+	pr, err := h.Service.GetPaymentRequest(c.Param("reference"))
+	if err != nil {
+		respondPaymentRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pr)
+}
+
+type PayPaymentRequestRequest struct {
+	FromAccountID string `json:"from_account_id" binding:"required,uuid"`
+}
+
+// PayPaymentRequest fulfils a request with a transfer from the authenticated user's account
+func (h *PaymentRequestHandler) PayPaymentRequest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req PayPaymentRequestRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	pr, payment, err := h.Service.PayPaymentRequest(userID, c.Param("reference"), req.FromAccountID)
+	if err != nil {
+		respondPaymentRequestError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"payment_request": pr, "payment": payment})
+}
+
+// CancelPaymentRequest withdraws an open request created by the authenticated user
+func (h *PaymentRequestHandler) CancelPaymentRequest(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	pr, err := h.Service.CancelPaymentRequest(userID, c.Param("reference"))
+	if err != nil {
+		respondPaymentRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pr)
+}
+
+// respondPaymentRequestError maps payment request service errors to API errors
+func respondPaymentRequestError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPaymentRequestNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPaymentRequestForbidden):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPaymentRequestNotOpen),
+		errors.Is(err, service.ErrPaymentRequestExpired):
+		apperrors.RespondWithError(c, apperrors.NewError("PAYMENT_REQUEST_NOT_OPEN", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// PayoutHandler serves the merchant payouts API. Routes are mounted behind
+// MandateHandler.MerchantAuth, which authenticates the merchant's API key.
+type PayoutHandler struct {
+	Service *service.PayoutService
+}
+
+func NewPayoutHandler(s *service.PayoutService) *PayoutHandler {
+	return &PayoutHandler{Service: s}
+}
+
+type CreatePayoutRequest struct {
+	DestinationAccountID string `json:"destination_account_id" binding:"required,uuid"`
+	Amount               string `json:"amount" binding:"required,amount"`
+	Currency             string `json:"currency" binding:"required,currency"`
+	Reference            string `json:"reference" binding:"max=140"`
+}
+
+// CreatePayout queues a payout for the merchant's next settlement batch
+func (h *PayoutHandler) CreatePayout(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req CreatePayoutRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	payout, err := h.Service.CreatePayout(merchant, service.CreatePayoutRequest{
+		DestinationAccountID: req.DestinationAccountID,
+		Amount:               req.Amount,
+		Currency:             req.Currency,
+		Reference:            req.Reference,
+	})
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, payout)
+}
+
+// ListPayouts returns the merchant's payouts, optionally filtered by status,
+// e.g. ?status=PENDING for those awaiting the next batch
+func (h *PayoutHandler) ListPayouts(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	payouts, err := h.Service.ListPayouts(merchant.ID.String(), model.PayoutStatus(c.Query("status")), limit)
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": payouts})
+}
+
+// GetPayout returns one of the merchant's payouts
+func (h *PayoutHandler) GetPayout(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	payout, err := h.Service.GetPayout(merchant.ID.String(), c.Param("id"))
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, payout)
+}
+
+// ListPayoutBatches returns the merchant's settlement batches with their totals
+func (h *PayoutHandler) ListPayoutBatches(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	batches, err := h.Service.ListBatches(merchant.ID.String(), limit)
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": batches})
+}
+
+// GetPayoutBatch returns the settlement report of one batch: its totals and
+// every payout it settled or failed
+func (h *PayoutHandler) GetPayoutBatch(c *gin.Context) {
+	merchant := getMerchant(c)
+	if merchant == nil {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	report, err := h.Service.GetBatchReport(merchant.ID.String(), c.Param("id"))
+	if err != nil {
+		respondPayoutError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// respondPayoutError maps payout service errors to API errors
+func respondPayoutError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPayout):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPayoutNotFound),
+		errors.Is(err, service.ErrPayoutBatchNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+type RefundHandler struct {
+	Service *service.RefundService
+}
+
+func NewRefundHandler(s *service.RefundService) *RefundHandler {
+	return &RefundHandler{Service: s}
+}
+
+// RefundRequest refunds the given amount, or everything not yet refunded when amount is omitted
+type RefundRequest struct {
+	Amount string `json:"amount" binding:"omitempty,amount"`
+	Reason string `json:"reason" binding:"max=140"`
+}
+
+// RefundPayment returns all or part of a completed payment the user received
+// to the payer
+func (h *RefundHandler) RefundPayment(c *gin.Context) {
+	h.refund(c, h.Service.RefundPayment)
+}
+
+// RefundAnyPayment returns all or part of any completed payment to the payer
+func (h *RefundHandler) RefundAnyPayment(c *gin.Context) {
+	h.refund(c, h.Service.RefundAnyPayment)
+}
+
+func (h *RefundHandler) refund(c *gin.Context, refundPayment func(userID, paymentID, amount, reason string) (*model.Refund, error)) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+
+	var req RefundRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	refund, err := refundPayment(userID, c.Param("id"), req.Amount, req.Reason)
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, refund)
+}
+
+// ListRefunds returns the refunds of a payment the user made or received and
+// their status
+func (h *RefundHandler) ListRefunds(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	respondRefunds(c, func() ([]model.Refund, error) {
+		return h.Service.ListRefunds(userID, c.Param("id"))
+	})
+}
+
+// ListAnyRefunds returns the refunds of any payment and their status
+func (h *RefundHandler) ListAnyRefunds(c *gin.Context) {
+	respondRefunds(c, func() ([]model.Refund, error) {
+		return h.Service.ListAnyRefunds(c.Param("id"))
+	})
+}
+
+func respondRefunds(c *gin.Context, list func() ([]model.Refund, error)) {
+	refunds, err := list()
+	if err != nil {
+		respondRefundError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, refunds)
+}
+
+// respondRefundError maps refund service errors to API errors
+func respondRefundError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrRefundForbidden):
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrAccountUnavailable):
+		apperrors.RespondWithError(c, apperrors.ErrServiceUnavailable.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrInvalidRefund):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrPaymentNotRefundable):
+		apperrors.RespondWithError(c, apperrors.NewError("PAYMENT_NOT_REFUNDABLE", err.Error(), http.StatusConflict))
+	case errors.Is(err, service.ErrRefundExceedsPayment):
+		apperrors.RespondWithError(c, apperrors.NewError("REFUND_EXCEEDS_PAYMENT", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
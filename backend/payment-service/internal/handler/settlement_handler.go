@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/service"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
+	"github.com/gin-gonic/gin"
+)
+
+// SettlementHandler serves the ops API for settlement reports and the
+// exceptions their reconciliation raised
+type SettlementHandler struct {
+	Service *service.SettlementReconciliationService
+}
+
+func NewSettlementHandler(s *service.SettlementReconciliationService) *SettlementHandler {
+	return &SettlementHandler{Service: s}
+}
+
+// UploadSettlementReport reconciles a report file sent as the raw body, for
+// the connector, date and format given as ?connector=&date=&format=
+func (h *SettlementHandler) UploadSettlementReport(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxSettlementReportBytes))
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("settlement reports must be at most 32MB"))
+		return
+	}
+
+	format := model.SettlementReportFormat(strings.ToUpper(c.DefaultQuery("format", string(model.SettlementReportCSV))))
+	report, err := h.Service.UploadReport(middleware.GetUserID(c), c.Query("connector"), c.Query("date"), format, body)
+	if err != nil {
+		respondSettlementError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, report)
+}
+
+// ListSettlementReports returns reconciled reports, optionally for one ?connector=
+func (h *SettlementHandler) ListSettlementReports(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	reports, err := h.Service.ListReports(c.Query("connector"), limit)
+	if err != nil {
+		respondSettlementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": reports})
+}
+
+// GetSettlementReport returns one reconciled report
+func (h *SettlementHandler) GetSettlementReport(c *gin.Context) {
+	report, err := h.Service.GetReport(c.Param("id"))
+	if err != nil {
+		respondSettlementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListSettlementExceptions returns exceptions filtered by ?status=, ?type= and
+// ?report_id=, e.g. ?status=OPEN for the queue
+func (h *SettlementHandler) ListSettlementExceptions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	exceptions, err := h.Service.ListExceptions(
+		model.SettlementExceptionStatus(c.Query("status")),
+		model.SettlementExceptionType(c.Query("type")),
+		c.Query("report_id"),
+		limit,
+	)
+	if err != nil {
+		respondSettlementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": exceptions})
+}
+
+// GetSettlementException returns one exception
+func (h *SettlementHandler) GetSettlementException(c *gin.Context) {
+	exception, err := h.Service.GetException(c.Param("id"))
+	if err != nil {
+		respondSettlementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, exception)
+}
+
+// ResolveSettlementExceptionRequest says how an exception was resolved and why
+type ResolveSettlementExceptionRequest struct {
+	Resolution model.SettlementResolution `json:"resolution" binding:"required"`
+	Note       string                     `json:"note" binding:"required,max=500"`
+}
+
+// ResolveSettlementException closes an open exception
+func (h *SettlementHandler) ResolveSettlementException(c *gin.Context) {
+	var req ResolveSettlementExceptionRequest
+	if !validation.BindJSON(c, &req) {
+		return
+	}
+
+	exception, err := h.Service.ResolveException(middleware.GetUserID(c), c.Param("id"), req.Resolution, req.Note)
+	if err != nil {
+		respondSettlementError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, exception)
+}
+
+// respondSettlementError maps settlement reconciliation errors to API errors
+func respondSettlementError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidSettlementReport),
+		errors.Is(err, service.ErrInvalidSettlementException):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrSettlementReportNotFound),
+		errors.Is(err, service.ErrSettlementExceptionNotFound),
+		errors.Is(err, service.ErrConnectorNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrSettlementReportExists):
+		apperrors.RespondWithError(c, apperrors.ErrAlreadyExists.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrSettlementExceptionResolved):
+		apperrors.RespondWithError(c, apperrors.NewError("SETTLEMENT_EXCEPTION_STATE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultStepUpThreshold is the transfer amount, in any currency, from which
+// the user must have stepped up recently
+var DefaultStepUpThreshold = decimal.NewFromInt(1000)
+
+// stepUpRequired responds STEP_UP_REQUIRED and returns true when amount is at
+// least threshold and the caller has not stepped up at /api/v1/auth/step-up
+// recently. A zero threshold turns the check off. Amounts that do not parse
+// are left for the service to reject.
+func stepUpRequired(c *gin.Context, threshold decimal.Decimal, amount string) bool {
+	if threshold.IsZero() {
+		return false
+	}
+	value, err := decimal.NewFromString(amount)
+	if err != nil || value.LessThan(threshold) {
+		return false
+	}
+	if middleware.HasRecentStepUp(c, middleware.DefaultStepUpMaxAge) {
+		return false
+	}
+	middleware.RespondStepUpRequired(c, middleware.DefaultStepUpMaxAge)
+	return true
+}
@@ -0,0 +1,192 @@
+package iso20022
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supported camt.053 namespaces
+const (
+	NamespaceCamt053V02 = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+	NamespaceCamt053V08 = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"
+)
+
+// MaxStatementEntries is the largest number of entries accepted in one statement message
+const MaxStatementEntries = 50000
+
+// Statement is a parsed camt.053 bank-to-customer statement. Only the parts
+// the settlement reconciliation needs are read: the entries of every
+// statement in the message.
+type Statement struct {
+	MessageID string
+	CreatedAt time.Time
+	Entries   []StatementEntry
+}
+
+// StatementEntry is one entry of a camt.053 statement
+type StatementEntry struct {
+	// AccountServicerRef is the rail's reference for the payment
+	AccountServicerRef string
+	// EndToEndID is the ID the payment was instructed with, ours for outgoing payments
+	EndToEndID string
+	Amount     decimal.Decimal
+	Currency   string
+	Credit     bool
+	// Reversal marks the return of an earlier entry, e.g. a payment the beneficiary bank rejected
+	Reversal bool
+	// Status is BOOK, PDNG or INFO
+	Status      string
+	BookingDate string
+}
+
+type camtDocument struct {
+	XMLName xml.Name           `xml:"Document"`
+	Stmts   *camtBankStatement `xml:"BkToCstmrStmt"`
+}
+
+type camtBankStatement struct {
+	GrpHdr struct {
+		MsgId   string `xml:"MsgId"`
+		CreDtTm string `xml:"CreDtTm"`
+	} `xml:"GrpHdr"`
+	Stmt []struct {
+		Ntry []camtEntry `xml:"Ntry"`
+	} `xml:"Stmt"`
+}
+
+type camtEntry struct {
+	Amt struct {
+		Ccy   string `xml:"Ccy,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	RvslInd   string     `xml:"RvslInd"`
+	Sts       camtStatus `xml:"Sts"`
+	BookgDt   struct {
+		Dt   string `xml:"Dt"`
+		DtTm string `xml:"DtTm"`
+	} `xml:"BookgDt"`
+	AcctSvcrRef string `xml:"AcctSvcrRef"`
+	NtryDtls    []struct {
+		TxDtls []struct {
+			Refs struct {
+				AcctSvcrRef string `xml:"AcctSvcrRef"`
+				EndToEndId  string `xml:"EndToEndId"`
+			} `xml:"Refs"`
+		} `xml:"TxDtls"`
+	} `xml:"NtryDtls"`
+}
+
+// camtStatus is the entry status, a plain code in version 02 and a Cd
+// element in later versions
+type camtStatus struct {
+	Value string `xml:",chardata"`
+	Cd    string `xml:"Cd"`
+}
+
+func (s camtStatus) String() string {
+	if code := strings.TrimSpace(s.Cd); code != "" {
+		return code
+	}
+	return strings.TrimSpace(s.Value)
+}
+
+// ParseCamt053 parses and validates a camt.053 statement. All rule violations
+// are reported together in a *ValidationError.
+func ParseCamt053(data []byte) (*Statement, error) {
+	if bytes.Contains(data, []byte("<!DOCTYPE")) {
+		return nil, &ValidationError{Errors: []string{"DOCTYPE declarations are not allowed"}}
+	}
+
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, &ValidationError{Errors: []string{"malformed XML: " + err.Error()}}
+	}
+
+	verr := &ValidationError{}
+	if ns := doc.XMLName.Space; ns != NamespaceCamt053V02 && ns != NamespaceCamt053V08 {
+		verr.add("unsupported namespace %q", ns)
+		return nil, verr
+	}
+	if doc.Stmts == nil {
+		verr.add("BkToCstmrStmt is required")
+		return nil, verr
+	}
+
+	stmts := doc.Stmts
+	result := &Statement{MessageID: strings.TrimSpace(stmts.GrpHdr.MsgId)}
+	checkText(verr, "GrpHdr/MsgId", result.MessageID, 35, true)
+	if created, err := parseDateTime(stmts.GrpHdr.CreDtTm); err != nil {
+		verr.add("GrpHdr/CreDtTm must be an ISO date time")
+	} else {
+		result.CreatedAt = created
+	}
+	if len(stmts.Stmt) == 0 {
+		verr.add("at least one Stmt is required")
+	}
+
+	for i, stmt := range stmts.Stmt {
+		for j, ntry := range stmt.Ntry {
+			path := fmt.Sprintf("Stmt[%d]/Ntry[%d]", i, j)
+			entry := StatementEntry{
+				AccountServicerRef: strings.TrimSpace(ntry.AcctSvcrRef),
+				Currency:           ntry.Amt.Ccy,
+				Credit:             ntry.CdtDbtInd == "CRDT",
+				Reversal:           strings.TrimSpace(ntry.RvslInd) == "true",
+				Status:             ntry.Sts.String(),
+				BookingDate:        strings.TrimSpace(ntry.BookgDt.Dt),
+			}
+			if entry.BookingDate == "" && len(ntry.BookgDt.DtTm) >= 10 {
+				entry.BookingDate = ntry.BookgDt.DtTm[:10]
+			}
+			// Batched entries carry one TxDtls per payment; a statement for
+			// settlement reconciliation books each payment as its own entry
+			if len(ntry.NtryDtls) > 0 && len(ntry.NtryDtls[0].TxDtls) > 0 {
+				refs := ntry.NtryDtls[0].TxDtls[0].Refs
+				entry.EndToEndID = strings.TrimSpace(refs.EndToEndId)
+				if entry.AccountServicerRef == "" {
+					entry.AccountServicerRef = strings.TrimSpace(refs.AcctSvcrRef)
+				}
+			}
+
+			if ntry.CdtDbtInd != "CRDT" && ntry.CdtDbtInd != "DBIT" {
+				verr.add("%s/CdtDbtInd must be CRDT or DBIT", path)
+			}
+			switch entry.Status {
+			case "BOOK", "PDNG", "INFO":
+			default:
+				verr.add("%s/Sts must be BOOK, PDNG or INFO", path)
+			}
+			if entry.AccountServicerRef == "" && entry.EndToEndID == "" {
+				verr.add("%s needs an AcctSvcrRef or EndToEndId", path)
+			}
+			// Rails echo our UUIDs, one character over Max35Text, so both
+			// references are bounded by what the exceptions queue stores
+			checkText(verr, path+"/AcctSvcrRef", entry.AccountServicerRef, 100, false)
+			checkText(verr, path+"/EndToEndId", entry.EndToEndID, 100, false)
+			if !currencyPattern.MatchString(entry.Currency) {
+				verr.add("%s/Amt/@Ccy must be a 3-letter ISO currency code", path)
+			}
+			amount, err := parseAmount(ntry.Amt.Value)
+			if err != nil {
+				verr.add("%s/Amt %s", path, err.Error())
+			} else {
+				entry.Amount = amount
+			}
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	if len(result.Entries) > MaxStatementEntries {
+		verr.add("at most %d entries are accepted per message", MaxStatementEntries)
+	}
+
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return result, nil
+}
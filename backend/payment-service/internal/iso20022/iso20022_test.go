@@ -0,0 +1,284 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pain001V09 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09">
+  <CstmrCdtTrfInitn>
+    <GrpHdr>
+      <MsgId>PAYROLL-2026-10</MsgId>
+      <CreDtTm>2026-10-01T09:30:00</CreDtTm>
+      <NbOfTxs>2</NbOfTxs>
+      <CtrlSum>1750.50</CtrlSum>
+      <InitgPty><Nm>Acme Ltd</Nm></InitgPty>
+    </GrpHdr>
+    <PmtInf>
+      <PmtInfId>PAYROLL-OCT</PmtInfId>
+      <PmtMtd>TRF</PmtMtd>
+      <NbOfTxs>2</NbOfTxs>
+      <ReqdExctnDt><Dt>2026-10-02</Dt></ReqdExctnDt>
+      <Dbtr><Nm>Acme Ltd</Nm></Dbtr>
+      <DbtrAcct><Id><Othr><Id>0b4a3c9e6f1d4e2a9c8b7a6f5e4d3c2b</Id></Othr></Id></DbtrAcct>
+      <CdtTrfTxInf>
+        <PmtId><InstrId>I-1</InstrId><EndToEndId>E2E-1</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="USD">1000.00</InstdAmt></Amt>
+        <Cdtr><Nm>Jane Doe</Nm></Cdtr>
+        <CdtrAcct><Id><Othr><Id>1c5b4d0f7a2e4f3b8d9c8b7a6f5e4d3c</Id></Othr></Id></CdtrAcct>
+        <RmtInf><Ustrd>October salary</Ustrd></RmtInf>
+      </CdtTrfTxInf>
+      <CdtTrfTxInf>
+        <PmtId><EndToEndId>E2E-2</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="USD">750.50</InstdAmt></Amt>
+        <Cdtr><Nm>John Roe</Nm></Cdtr>
+        <CdtrAcct><Id><IBAN>GB29NWBK60161331926819</IBAN></Id></CdtrAcct>
+      </CdtTrfTxInf>
+    </PmtInf>
+  </CstmrCdtTrfInitn>
+</Document>`
+
+func TestParsePain001_MapsTransfers(t *testing.T) {
+	in, err := ParsePain001([]byte(pain001V09))
+	require.NoError(t, err)
+
+	assert.Equal(t, "PAYROLL-2026-10", in.MessageID)
+	assert.Equal(t, "Acme Ltd", in.InitiatingParty)
+	assert.Equal(t, 2026, in.CreatedAt.Year())
+	require.Len(t, in.Transfers, 2)
+
+	first := in.Transfers[0]
+	assert.Equal(t, "PAYROLL-OCT", first.PaymentInfoID)
+	assert.Equal(t, "I-1", first.InstructionID)
+	assert.Equal(t, "E2E-1", first.EndToEndID)
+	assert.Equal(t, "2026-10-02", first.ExecutionDate)
+	assert.Equal(t, "Acme Ltd", first.DebtorName)
+	assert.Equal(t, "0b4a3c9e6f1d4e2a9c8b7a6f5e4d3c2b", first.DebtorAccount)
+	assert.Equal(t, "Jane Doe", first.CreditorName)
+	assert.Equal(t, "1c5b4d0f7a2e4f3b8d9c8b7a6f5e4d3c", first.CreditorAccount)
+	assert.True(t, decimal.RequireFromString("1000").Equal(first.Amount))
+	assert.Equal(t, "USD", first.Currency)
+	assert.Equal(t, "October salary", first.RemittanceInfo)
+
+	assert.Equal(t, "GB29NWBK60161331926819", in.Transfers[1].CreditorAccount)
+	assert.Equal(t, "0b4a3c9e6f1d4e2a9c8b7a6f5e4d3c2b", in.Transfers[1].DebtorAccount)
+}
+
+func TestParsePain001_AcceptsVersion03ExecutionDate(t *testing.T) {
+	doc := strings.Replace(pain001V09, "pain.001.001.09", "pain.001.001.03", 1)
+	doc = strings.Replace(doc, "<ReqdExctnDt><Dt>2026-10-02</Dt></ReqdExctnDt>", "<ReqdExctnDt>2026-10-02</ReqdExctnDt>", 1)
+
+	in, err := ParsePain001([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-02", in.Transfers[0].ExecutionDate)
+}
+
+func TestParsePain001_SchemaValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace [2]string
+		wantErr string
+	}{
+		{"wrong namespace", [2]string{"pain.001.001.09", "pain.002.001.10"}, "unsupported namespace"},
+		{"missing message id", [2]string{"<MsgId>PAYROLL-2026-10</MsgId>", ""}, "GrpHdr/MsgId is required"},
+		{"message id too long", [2]string{"PAYROLL-2026-10", strings.Repeat("M", 36)}, "GrpHdr/MsgId must be at most 35 characters"},
+		{"count mismatch", [2]string{"<NbOfTxs>2</NbOfTxs>\n      <CtrlSum>", "<NbOfTxs>3</NbOfTxs>\n      <CtrlSum>"}, "GrpHdr/NbOfTxs is 3"},
+		{"control sum mismatch", [2]string{"<CtrlSum>1750.50</CtrlSum>", "<CtrlSum>1750.00</CtrlSum>"}, "GrpHdr/CtrlSum is 1750"},
+		{"not a transfer", [2]string{"<PmtMtd>TRF</PmtMtd>", "<PmtMtd>CHK</PmtMtd>"}, "PmtMtd must be TRF"},
+		{"bad currency", [2]string{`Ccy="USD">1000.00`, `Ccy="usd">1000.00`}, "@Ccy must be a 3-letter ISO currency code"},
+		{"too many decimals", [2]string{">1000.00<", ">1000.000001<"}, "at most 18 digits and 5 decimals"},
+		{"negative amount", [2]string{">750.50<", ">-750.50<"}, "must be greater than zero"},
+		{"bad iban", [2]string{"GB29NWBK60161331926819", "NOT-AN-IBAN"}, "is not a valid IBAN"},
+		{"duplicate end to end id", [2]string{"E2E-2", "E2E-1"}, `EndToEndId "E2E-1" is used more than once`},
+		{"missing execution date", [2]string{"<Dt>2026-10-02</Dt>", "<Dt>tomorrow</Dt>"}, "ReqdExctnDt must be an ISO date"},
+		{"doctype", [2]string{`<?xml version="1.0" encoding="UTF-8"?>`, `<?xml version="1.0"?><!DOCTYPE d [<!ENTITY x "y">]>`}, "DOCTYPE"},
+		{"malformed", [2]string{"</Document>", ""}, "malformed XML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := strings.Replace(pain001V09, tt.replace[0], tt.replace[1], 1)
+			_, err := ParsePain001([]byte(doc))
+
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Contains(t, verr.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestRenderPacs008_MapsSettlement(t *testing.T) {
+	created := time.Date(2026, 10, 2, 8, 0, 0, 0, time.UTC)
+	out, err := RenderPacs008(Settlement{
+		MessageID:      "P8-TEST",
+		CreatedAt:      created,
+		SettlementDate: created,
+		AgentBIC:       "NEOBGB2LXXX",
+		Transactions: []SettlementTransaction{
+			{
+				InstructionID:   "I-1",
+				EndToEndID:      "E2E-1",
+				TransactionID:   "TX1",
+				UETR:            "8a562c67-ca16-48ba-b074-65581be6f001",
+				Amount:          decimal.RequireFromString("1000"),
+				Currency:        "USD",
+				DebtorName:      "Acme Ltd",
+				DebtorAccount:   "0b4a3c9e6f1d4e2a9c8b7a6f5e4d3c2b",
+				CreditorName:    "Jane Doe",
+				CreditorAccount: "GB29NWBK60161331926819",
+				RemittanceInfo:  "October salary",
+			},
+			{
+				EndToEndID:      "E2E-2",
+				TransactionID:   "TX2",
+				Amount:          decimal.RequireFromString("750.5"),
+				Currency:        "USD",
+				DebtorAccount:   "0b4a3c9e6f1d4e2a9c8b7a6f5e4d3c2b",
+				CreditorAccount: "1c5b4d0f7a2e4f3b8d9c8b7a6f5e4d3c",
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(out), xml.Header))
+
+	// Read the document back to check the mapping element by element
+	var doc struct {
+		XMLName xml.Name
+		GrpHdr  struct {
+			MsgId             string
+			CreDtTm           string
+			NbOfTxs           int
+			TtlIntrBkSttlmAmt struct {
+				Ccy   string `xml:"Ccy,attr"`
+				Value string `xml:",chardata"`
+			}
+			IntrBkSttlmDt string
+			SttlmMtd      string `xml:"SttlmInf>SttlmMtd"`
+			InstgAgt      string `xml:"InstgAgt>FinInstnId>BICFI"`
+		} `xml:"FIToFICstmrCdtTrf>GrpHdr"`
+		Txs []struct {
+			EndToEndId string `xml:"PmtId>EndToEndId"`
+			TxId       string `xml:"PmtId>TxId"`
+			UETR       string `xml:"PmtId>UETR"`
+			Amount     string `xml:"IntrBkSttlmAmt"`
+			ChrgBr     string
+			DbtrOthr   string `xml:"DbtrAcct>Id>Othr>Id"`
+			CdtrIBAN   string `xml:"CdtrAcct>Id>IBAN"`
+			CdtrOthr   string `xml:"CdtrAcct>Id>Othr>Id"`
+			Ustrd      string `xml:"RmtInf>Ustrd"`
+		} `xml:"FIToFICstmrCdtTrf>CdtTrfTxInf"`
+	}
+	require.NoError(t, xml.Unmarshal(out, &doc))
+
+	assert.Equal(t, NamespacePacs008, doc.XMLName.Space)
+	assert.Equal(t, "P8-TEST", doc.GrpHdr.MsgId)
+	assert.Equal(t, "2026-10-02T08:00:00Z", doc.GrpHdr.CreDtTm)
+	assert.Equal(t, 2, doc.GrpHdr.NbOfTxs)
+	assert.Equal(t, "USD", doc.GrpHdr.TtlIntrBkSttlmAmt.Ccy)
+	assert.Equal(t, "1750.50", doc.GrpHdr.TtlIntrBkSttlmAmt.Value)
+	assert.Equal(t, "2026-10-02", doc.GrpHdr.IntrBkSttlmDt)
+	assert.Equal(t, "CLRG", doc.GrpHdr.SttlmMtd)
+	assert.Equal(t, "NEOBGB2LXXX", doc.GrpHdr.InstgAgt)
+
+	require.Len(t, doc.Txs, 2)
+	assert.Equal(t, "E2E-1", doc.Txs[0].EndToEndId)
+	assert.Equal(t, "TX1", doc.Txs[0].TxId)
+	assert.Equal(t, "8a562c67-ca16-48ba-b074-65581be6f001", doc.Txs[0].UETR)
+	assert.Equal(t, "1000.00", doc.Txs[0].Amount)
+	assert.Equal(t, "SLEV", doc.Txs[0].ChrgBr)
+	assert.Equal(t, "0b4a3c9e6f1d4e2a9c8b7a6f5e4d3c2b", doc.Txs[0].DbtrOthr)
+	assert.Equal(t, "GB29NWBK60161331926819", doc.Txs[0].CdtrIBAN)
+	assert.Equal(t, "October salary", doc.Txs[0].Ustrd)
+	assert.Equal(t, "1c5b4d0f7a2e4f3b8d9c8b7a6f5e4d3c", doc.Txs[1].CdtrOthr)
+	assert.NotContains(t, string(out), "<RmtInf></RmtInf>")
+}
+
+func TestRenderPacs008_OmitsTotalForMixedCurrencies(t *testing.T) {
+	now := time.Now()
+	tx := SettlementTransaction{EndToEndID: "E", DebtorAccount: "A", CreditorAccount: "B", Amount: decimal.NewFromInt(1)}
+	usd, eur := tx, tx
+	usd.TransactionID, usd.Currency = "T1", "USD"
+	eur.TransactionID, eur.Currency = "T2", "EUR"
+
+	out, err := RenderPacs008(Settlement{MessageID: "M", CreatedAt: now, SettlementDate: now, AgentBIC: "NEOBGB2L", Transactions: []SettlementTransaction{usd, eur}})
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "TtlIntrBkSttlmAmt")
+}
+
+func TestRenderPacs008_Validation(t *testing.T) {
+	now := time.Now()
+	tx := SettlementTransaction{EndToEndID: "E", TransactionID: "T", DebtorAccount: "A", CreditorAccount: "B", Amount: decimal.NewFromInt(1), Currency: "USD"}
+
+	_, err := RenderPacs008(Settlement{MessageID: "M", CreatedAt: now, SettlementDate: now, AgentBIC: "bad", Transactions: []SettlementTransaction{tx}})
+	assert.ErrorContains(t, err, "not a valid BIC")
+
+	_, err = RenderPacs008(Settlement{MessageID: "M", CreatedAt: now, SettlementDate: now, AgentBIC: "NEOBGB2L", Transactions: []SettlementTransaction{tx, tx}})
+	assert.ErrorContains(t, err, "is not unique")
+
+	_, err = RenderPacs008(Settlement{MessageID: "M", CreatedAt: now, SettlementDate: now, AgentBIC: "NEOBGB2L"})
+	assert.ErrorContains(t, err, "at least one transaction")
+}
+
+const camt053V08 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>STMT-2026-10-15</MsgId><CreDtTm>2026-10-15T23:59:00Z</CreDtTm></GrpHdr>
+    <Stmt>
+      <Ntry>
+        <Amt Ccy="GBP">120.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2026-10-15</Dt></BookgDt>
+        <AcctSvcrRef>RAIL-1</AcctSvcrRef>
+        <NtryDtls><TxDtls><Refs><EndToEndId>0b4a3c9e-6f1d-4e2a-9c8b-7a6f5e4d3c2b</EndToEndId></Refs></TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="GBP">40.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <RvslInd>true</RvslInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2026-10-15T16:00:00Z</DtTm></BookgDt>
+        <NtryDtls><TxDtls><Refs><AcctSvcrRef>RAIL-2</AcctSvcrRef></Refs></TxDtls></NtryDtls>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseCamt053_MapsEntries(t *testing.T) {
+	stmt, err := ParseCamt053([]byte(camt053V08))
+	require.NoError(t, err)
+	assert.Equal(t, "STMT-2026-10-15", stmt.MessageID)
+	require.Len(t, stmt.Entries, 2)
+
+	debit := stmt.Entries[0]
+	assert.Equal(t, "RAIL-1", debit.AccountServicerRef)
+	assert.Equal(t, "0b4a3c9e-6f1d-4e2a-9c8b-7a6f5e4d3c2b", debit.EndToEndID)
+	assert.True(t, decimal.RequireFromString("120.50").Equal(debit.Amount))
+	assert.False(t, debit.Credit)
+	assert.Equal(t, "BOOK", debit.Status)
+	assert.Equal(t, "2026-10-15", debit.BookingDate)
+
+	reversal := stmt.Entries[1]
+	assert.Equal(t, "RAIL-2", reversal.AccountServicerRef, "the reference may sit in the transaction details")
+	assert.True(t, reversal.Credit)
+	assert.True(t, reversal.Reversal)
+	assert.Equal(t, "2026-10-15", reversal.BookingDate)
+}
+
+func TestParseCamt053_Validation(t *testing.T) {
+	_, err := ParseCamt053([]byte(strings.Replace(camt053V08, "camt.053.001.08", "camt.052.001.08", 1)))
+	assert.ErrorContains(t, err, "unsupported namespace")
+
+	broken := strings.NewReplacer("<CdtDbtInd>DBIT</CdtDbtInd>", "<CdtDbtInd>X</CdtDbtInd>", `Ccy="GBP">40.00`, `Ccy="GBP">-40.00`).Replace(camt053V08)
+	_, err = ParseCamt053([]byte(broken))
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	assert.Len(t, verr.Errors, 2)
+}
@@ -0,0 +1,214 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// NamespacePacs008 is the pacs.008 version rendered for the clearing connector
+const NamespacePacs008 = "urn:iso:std:iso:20022:tech:xsd:pacs.008.001.08"
+
+// Settlement describes an outgoing pacs.008 message
+type Settlement struct {
+	MessageID      string
+	CreatedAt      time.Time
+	SettlementDate time.Time
+	// AgentBIC identifies this bank as both instructing and instructed agent
+	// for the simulated clearing scheme
+	AgentBIC     string
+	Transactions []SettlementTransaction
+}
+
+// SettlementTransaction is one customer credit transfer in a pacs.008 message
+type SettlementTransaction struct {
+	InstructionID   string
+	EndToEndID      string
+	TransactionID   string
+	UETR            string
+	Amount          decimal.Decimal
+	Currency        string
+	DebtorName      string
+	DebtorAccount   string
+	CreditorName    string
+	CreditorAccount string
+	RemittanceInfo  string
+}
+
+type pacsDocument struct {
+	XMLName  xml.Name           `xml:"Document"`
+	Xmlns    string             `xml:"xmlns,attr"`
+	Transfer pacsCreditTransfer `xml:"FIToFICstmrCdtTrf"`
+}
+
+type pacsCreditTransfer struct {
+	GrpHdr struct {
+		MsgId             string      `xml:"MsgId"`
+		CreDtTm           string      `xml:"CreDtTm"`
+		NbOfTxs           int         `xml:"NbOfTxs"`
+		TtlIntrBkSttlmAmt *pacsAmount `xml:"TtlIntrBkSttlmAmt,omitempty"`
+		IntrBkSttlmDt     string      `xml:"IntrBkSttlmDt"`
+		SttlmInf          struct {
+			SttlmMtd string `xml:"SttlmMtd"`
+		} `xml:"SttlmInf"`
+		InstgAgt pacsAgent `xml:"InstgAgt"`
+		InstdAgt pacsAgent `xml:"InstdAgt"`
+	} `xml:"GrpHdr"`
+	CdtTrfTxInf []pacsTransaction `xml:"CdtTrfTxInf"`
+}
+
+type pacsAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type pacsAgent struct {
+	BICFI string `xml:"FinInstnId>BICFI"`
+}
+
+type pacsParty struct {
+	Nm string `xml:"Nm,omitempty"`
+}
+
+type pacsAccount struct {
+	IBAN  string `xml:"Id>IBAN,omitempty"`
+	Other string `xml:"Id>Othr>Id,omitempty"`
+}
+
+type pacsTransaction struct {
+	PmtId struct {
+		InstrId    string `xml:"InstrId,omitempty"`
+		EndToEndId string `xml:"EndToEndId"`
+		TxId       string `xml:"TxId"`
+		UETR       string `xml:"UETR,omitempty"`
+	} `xml:"PmtId"`
+	IntrBkSttlmAmt pacsAmount  `xml:"IntrBkSttlmAmt"`
+	ChrgBr         string      `xml:"ChrgBr"`
+	Dbtr           pacsParty   `xml:"Dbtr"`
+	DbtrAcct       pacsAccount `xml:"DbtrAcct"`
+	DbtrAgt        pacsAgent   `xml:"DbtrAgt"`
+	CdtrAgt        pacsAgent   `xml:"CdtrAgt"`
+	Cdtr           pacsParty   `xml:"Cdtr"`
+	CdtrAcct       pacsAccount `xml:"CdtrAcct"`
+	RmtInf         *struct {
+		Ustrd string `xml:"Ustrd"`
+	} `xml:"RmtInf,omitempty"`
+}
+
+// RenderPacs008 validates a settlement and renders it as a pacs.008.001.08 document
+func RenderPacs008(s Settlement) ([]byte, error) {
+	if err := validateSettlement(s); err != nil {
+		return nil, err
+	}
+
+	doc := pacsDocument{Xmlns: NamespacePacs008}
+	hdr := &doc.Transfer.GrpHdr
+	hdr.MsgId = s.MessageID
+	hdr.CreDtTm = s.CreatedAt.UTC().Format("2006-01-02T15:04:05Z")
+	hdr.NbOfTxs = len(s.Transactions)
+	hdr.IntrBkSttlmDt = s.SettlementDate.Format("2006-01-02")
+	hdr.SttlmInf.SttlmMtd = "CLRG"
+	hdr.InstgAgt.BICFI = s.AgentBIC
+	hdr.InstdAgt.BICFI = s.AgentBIC
+
+	// The total is only allowed when every transaction settles in the same currency
+	total := decimal.Zero
+	singleCurrency := true
+	for _, tx := range s.Transactions {
+		total = total.Add(tx.Amount)
+		singleCurrency = singleCurrency && tx.Currency == s.Transactions[0].Currency
+	}
+	if singleCurrency {
+		hdr.TtlIntrBkSttlmAmt = &pacsAmount{Ccy: s.Transactions[0].Currency, Value: total.StringFixed(2)}
+	}
+
+	for _, tx := range s.Transactions {
+		out := pacsTransaction{
+			IntrBkSttlmAmt: pacsAmount{Ccy: tx.Currency, Value: tx.Amount.StringFixed(2)},
+			ChrgBr:         "SLEV",
+			Dbtr:           pacsParty{Nm: tx.DebtorName},
+			DbtrAcct:       toPacsAccount(tx.DebtorAccount),
+			DbtrAgt:        pacsAgent{BICFI: s.AgentBIC},
+			CdtrAgt:        pacsAgent{BICFI: s.AgentBIC},
+			Cdtr:           pacsParty{Nm: tx.CreditorName},
+			CdtrAcct:       toPacsAccount(tx.CreditorAccount),
+		}
+		out.PmtId.InstrId = tx.InstructionID
+		out.PmtId.EndToEndId = tx.EndToEndID
+		out.PmtId.TxId = tx.TransactionID
+		out.PmtId.UETR = tx.UETR
+		if tx.RemittanceInfo != "" {
+			out.RmtInf = &struct {
+				Ustrd string `xml:"Ustrd"`
+			}{Ustrd: tx.RemittanceInfo}
+		}
+		doc.Transfer.CdtTrfTxInf = append(doc.Transfer.CdtTrfTxInf, out)
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render pacs.008: %w", err)
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+func toPacsAccount(id string) pacsAccount {
+	if ibanPattern.MatchString(id) {
+		return pacsAccount{IBAN: id}
+	}
+	return pacsAccount{Other: id}
+}
+
+func validateSettlement(s Settlement) error {
+	verr := &ValidationError{}
+	checkText(verr, "GrpHdr/MsgId", s.MessageID, 35, true)
+	if !bicPattern.MatchString(s.AgentBIC) {
+		verr.add("agent BIC %q is not a valid BIC", s.AgentBIC)
+	}
+	if len(s.Transactions) == 0 {
+		verr.add("at least one transaction is required")
+	}
+	if s.CreatedAt.IsZero() || s.SettlementDate.IsZero() {
+		verr.add("creation and settlement dates are required")
+	}
+
+	txIDs := make(map[string]bool, len(s.Transactions))
+	for i, tx := range s.Transactions {
+		path := fmt.Sprintf("CdtTrfTxInf[%d]", i)
+		checkText(verr, path+"/PmtId/InstrId", tx.InstructionID, 35, false)
+		checkText(verr, path+"/PmtId/EndToEndId", tx.EndToEndID, 35, true)
+		checkText(verr, path+"/PmtId/TxId", tx.TransactionID, 35, true)
+		checkText(verr, path+"/Dbtr/Nm", tx.DebtorName, 140, false)
+		checkText(verr, path+"/Cdtr/Nm", tx.CreditorName, 140, false)
+		checkText(verr, path+"/DbtrAcct", tx.DebtorAccount, 34, true)
+		checkText(verr, path+"/CdtrAcct", tx.CreditorAccount, 34, true)
+		checkText(verr, path+"/RmtInf/Ustrd", tx.RemittanceInfo, 140, false)
+		if !currencyPattern.MatchString(tx.Currency) {
+			verr.add("%s/IntrBkSttlmAmt/@Ccy must be a 3-letter ISO currency code", path)
+		}
+		if !tx.Amount.IsPositive() {
+			verr.add("%s/IntrBkSttlmAmt must be greater than zero", path)
+		}
+		if txIDs[tx.TransactionID] {
+			verr.add("%s/PmtId/TxId %q is not unique", path, tx.TransactionID)
+		}
+		txIDs[tx.TransactionID] = true
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Truncate shortens free text to an ISO 20022 MaxNText limit
+func Truncate(value string, max int) string {
+	value = strings.TrimSpace(value)
+	if r := []rune(value); len(r) > max {
+		return string(r[:max])
+	}
+	return value
+}
@@ -0,0 +1,350 @@
+// Package iso20022 maps ISO 20022 payment messages to and from the payment
+// service: pain.001 customer credit transfer initiations come in, pacs.008
+// FI-to-FI customer credit transfers go out to the clearing connector, and
+// camt.053 statements from the rails are read for settlement reconciliation.
+package iso20022
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supported pain.001 namespaces
+const (
+	NamespacePain001V03 = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+	NamespacePain001V09 = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"
+)
+
+// MaxTransactions is the largest number of transfers accepted in one message
+const MaxTransactions = 1000
+
+var (
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	ibanPattern     = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[a-zA-Z0-9]{1,30}$`)
+	bicPattern      = regexp.MustCompile(`^[A-Z0-9]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	countPattern    = regexp.MustCompile(`^[0-9]{1,15}$`)
+)
+
+// ValidationError lists every schema and business rule a message breaks
+type ValidationError struct {
+	Errors []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid ISO 20022 message: " + strings.Join(e.Errors, "; ")
+}
+
+func (e *ValidationError) add(format string, args ...interface{}) {
+	e.Errors = append(e.Errors, fmt.Sprintf(format, args...))
+}
+
+// Initiation is a parsed pain.001 message
+type Initiation struct {
+	MessageID       string
+	CreatedAt       time.Time
+	InitiatingParty string
+	Transfers       []CreditTransfer
+}
+
+// CreditTransfer is one credit transfer instruction from a pain.001 message
+type CreditTransfer struct {
+	PaymentInfoID   string
+	InstructionID   string
+	EndToEndID      string
+	ExecutionDate   string
+	DebtorName      string
+	DebtorAccount   string
+	CreditorName    string
+	CreditorAccount string
+	Amount          decimal.Decimal
+	Currency        string
+	RemittanceInfo  string
+}
+
+// pain.001 document structure. Only the elements the bank acts on are mapped;
+// unknown elements are ignored.
+type painDocument struct {
+	XMLName  xml.Name            `xml:"Document"`
+	Initiatn *customerInitiation `xml:"CstmrCdtTrfInitn"`
+}
+
+type customerInitiation struct {
+	GrpHdr struct {
+		MsgId    string `xml:"MsgId"`
+		CreDtTm  string `xml:"CreDtTm"`
+		NbOfTxs  string `xml:"NbOfTxs"`
+		CtrlSum  string `xml:"CtrlSum"`
+		InitgPty party  `xml:"InitgPty"`
+	} `xml:"GrpHdr"`
+	PmtInf []paymentInformation `xml:"PmtInf"`
+}
+
+type paymentInformation struct {
+	PmtInfId    string         `xml:"PmtInfId"`
+	PmtMtd      string         `xml:"PmtMtd"`
+	NbOfTxs     string         `xml:"NbOfTxs"`
+	CtrlSum     string         `xml:"CtrlSum"`
+	ReqdExctnDt executionDate  `xml:"ReqdExctnDt"`
+	Dbtr        party          `xml:"Dbtr"`
+	DbtrAcct    account        `xml:"DbtrAcct"`
+	CdtTrfTxInf []transferInfo `xml:"CdtTrfTxInf"`
+}
+
+// executionDate is a plain ISODate in pain.001.001.03 and wrapped in <Dt> from version 09
+type executionDate struct {
+	Value string `xml:",chardata"`
+	Dt    string `xml:"Dt"`
+}
+
+func (d executionDate) String() string {
+	if d.Dt != "" {
+		return strings.TrimSpace(d.Dt)
+	}
+	return strings.TrimSpace(d.Value)
+}
+
+type party struct {
+	Nm string `xml:"Nm"`
+}
+
+type account struct {
+	Id struct {
+		IBAN string `xml:"IBAN"`
+		Othr struct {
+			Id string `xml:"Id"`
+		} `xml:"Othr"`
+	} `xml:"Id"`
+	Ccy string `xml:"Ccy"`
+}
+
+// identifier returns the IBAN or the proprietary account identifier
+func (a account) identifier() string {
+	if a.Id.IBAN != "" {
+		return strings.TrimSpace(a.Id.IBAN)
+	}
+	return strings.TrimSpace(a.Id.Othr.Id)
+}
+
+type transferInfo struct {
+	PmtId struct {
+		InstrId    string `xml:"InstrId"`
+		EndToEndId string `xml:"EndToEndId"`
+	} `xml:"PmtId"`
+	Amt struct {
+		InstdAmt struct {
+			Ccy   string `xml:"Ccy,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"InstdAmt"`
+	} `xml:"Amt"`
+	Cdtr     party   `xml:"Cdtr"`
+	CdtrAcct account `xml:"CdtrAcct"`
+	RmtInf   struct {
+		Ustrd []string `xml:"Ustrd"`
+	} `xml:"RmtInf"`
+}
+
+// ParsePain001 parses and validates a pain.001 credit transfer initiation.
+// All rule violations are reported together in a *ValidationError.
+func ParsePain001(data []byte) (*Initiation, error) {
+	// Bank files never need a DTD; refusing them keeps entity tricks out entirely
+	if bytes.Contains(data, []byte("<!DOCTYPE")) {
+		return nil, &ValidationError{Errors: []string{"DOCTYPE declarations are not allowed"}}
+	}
+
+	var doc painDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, &ValidationError{Errors: []string{"malformed XML: " + err.Error()}}
+	}
+
+	verr := &ValidationError{}
+	if ns := doc.XMLName.Space; ns != NamespacePain001V03 && ns != NamespacePain001V09 {
+		verr.add("unsupported namespace %q", ns)
+		return nil, verr
+	}
+	if doc.Initiatn == nil {
+		verr.add("CstmrCdtTrfInitn is required")
+		return nil, verr
+	}
+
+	in := doc.Initiatn
+	result := &Initiation{
+		MessageID:       strings.TrimSpace(in.GrpHdr.MsgId),
+		InitiatingParty: strings.TrimSpace(in.GrpHdr.InitgPty.Nm),
+	}
+
+	checkText(verr, "GrpHdr/MsgId", result.MessageID, 35, true)
+	if created, err := parseDateTime(in.GrpHdr.CreDtTm); err != nil {
+		verr.add("GrpHdr/CreDtTm must be an ISO date time")
+	} else {
+		result.CreatedAt = created
+	}
+	if len(in.PmtInf) == 0 {
+		verr.add("at least one PmtInf is required")
+	}
+
+	total := decimal.Zero
+	for i, pmt := range in.PmtInf {
+		path := fmt.Sprintf("PmtInf[%d]", i)
+		checkText(verr, path+"/PmtInfId", pmt.PmtInfId, 35, true)
+		if pmt.PmtMtd != "TRF" {
+			verr.add("%s/PmtMtd must be TRF", path)
+		}
+		execDate := pmt.ReqdExctnDt.String()
+		if _, err := time.Parse("2006-01-02", execDate); err != nil {
+			verr.add("%s/ReqdExctnDt must be an ISO date", path)
+		}
+		debtorAccount := pmt.DbtrAcct.identifier()
+		checkAccount(verr, path+"/DbtrAcct", pmt.DbtrAcct)
+		if len(pmt.CdtTrfTxInf) == 0 {
+			verr.add("%s must contain at least one CdtTrfTxInf", path)
+		}
+
+		pmtTotal := decimal.Zero
+		for j, tx := range pmt.CdtTrfTxInf {
+			txPath := fmt.Sprintf("%s/CdtTrfTxInf[%d]", path, j)
+			transfer := CreditTransfer{
+				PaymentInfoID:   strings.TrimSpace(pmt.PmtInfId),
+				InstructionID:   strings.TrimSpace(tx.PmtId.InstrId),
+				EndToEndID:      strings.TrimSpace(tx.PmtId.EndToEndId),
+				ExecutionDate:   execDate,
+				DebtorName:      strings.TrimSpace(pmt.Dbtr.Nm),
+				DebtorAccount:   debtorAccount,
+				CreditorName:    strings.TrimSpace(tx.Cdtr.Nm),
+				CreditorAccount: tx.CdtrAcct.identifier(),
+				Currency:        tx.Amt.InstdAmt.Ccy,
+				RemittanceInfo:  strings.TrimSpace(strings.Join(tx.RmtInf.Ustrd, " ")),
+			}
+
+			checkText(verr, txPath+"/PmtId/InstrId", transfer.InstructionID, 35, false)
+			checkText(verr, txPath+"/PmtId/EndToEndId", transfer.EndToEndID, 35, true)
+			checkAccount(verr, txPath+"/CdtrAcct", tx.CdtrAcct)
+			checkText(verr, txPath+"/RmtInf/Ustrd", transfer.RemittanceInfo, 140, false)
+			if !currencyPattern.MatchString(transfer.Currency) {
+				verr.add("%s/Amt/InstdAmt/@Ccy must be a 3-letter ISO currency code", txPath)
+			}
+			amount, err := parseAmount(tx.Amt.InstdAmt.Value)
+			if err != nil {
+				verr.add("%s/Amt/InstdAmt %s", txPath, err.Error())
+			} else {
+				transfer.Amount = amount
+				pmtTotal = pmtTotal.Add(amount)
+			}
+			result.Transfers = append(result.Transfers, transfer)
+		}
+
+		checkCount(verr, path+"/NbOfTxs", pmt.NbOfTxs, len(pmt.CdtTrfTxInf), false)
+		checkControlSum(verr, path+"/CtrlSum", pmt.CtrlSum, pmtTotal)
+		total = total.Add(pmtTotal)
+	}
+
+	checkCount(verr, "GrpHdr/NbOfTxs", in.GrpHdr.NbOfTxs, len(result.Transfers), true)
+	checkControlSum(verr, "GrpHdr/CtrlSum", in.GrpHdr.CtrlSum, total)
+	if len(result.Transfers) > MaxTransactions {
+		verr.add("at most %d transactions are accepted per message", MaxTransactions)
+	}
+
+	seen := make(map[string]bool, len(result.Transfers))
+	for _, t := range result.Transfers {
+		if t.EndToEndID != "" && seen[t.EndToEndID] {
+			verr.add("EndToEndId %q is used more than once", t.EndToEndID)
+		}
+		seen[t.EndToEndID] = true
+	}
+
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return result, nil
+}
+
+func checkText(verr *ValidationError, path, value string, max int, required bool) {
+	if value == "" {
+		if required {
+			verr.add("%s is required", path)
+		}
+		return
+	}
+	if len([]rune(value)) > max {
+		verr.add("%s must be at most %d characters", path, max)
+	}
+}
+
+func checkAccount(verr *ValidationError, path string, acc account) {
+	switch {
+	case acc.Id.IBAN != "":
+		if !ibanPattern.MatchString(strings.TrimSpace(acc.Id.IBAN)) {
+			verr.add("%s/Id/IBAN is not a valid IBAN", path)
+		}
+	case acc.Id.Othr.Id != "":
+		checkText(verr, path+"/Id/Othr/Id", strings.TrimSpace(acc.Id.Othr.Id), 34, true)
+	default:
+		verr.add("%s/Id must contain IBAN or Othr/Id", path)
+	}
+	if acc.Ccy != "" && !currencyPattern.MatchString(acc.Ccy) {
+		verr.add("%s/Ccy must be a 3-letter ISO currency code", path)
+	}
+}
+
+func checkCount(verr *ValidationError, path, value string, actual int, required bool) {
+	if value == "" {
+		if required {
+			verr.add("%s is required", path)
+		}
+		return
+	}
+	if !countPattern.MatchString(value) {
+		verr.add("%s must be numeric", path)
+		return
+	}
+	if value != fmt.Sprintf("%d", actual) {
+		verr.add("%s is %s but the message contains %d transactions", path, value, actual)
+	}
+}
+
+func checkControlSum(verr *ValidationError, path, value string, actual decimal.Decimal) {
+	if value == "" {
+		return
+	}
+	sum, err := decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		verr.add("%s must be a decimal number", path)
+		return
+	}
+	if !sum.Equal(actual) {
+		verr.add("%s is %s but the transactions add up to %s", path, sum.String(), actual.String())
+	}
+}
+
+// parseAmount validates an ActiveOrHistoricCurrencyAndAmount: at most 18 digits,
+// 5 of them fractional, and greater than zero
+func parseAmount(value string) (decimal.Decimal, error) {
+	value = strings.TrimSpace(value)
+	amount, err := decimal.NewFromString(value)
+	if err != nil || strings.ContainsAny(value, "eE+") {
+		return decimal.Zero, fmt.Errorf("must be a decimal amount")
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, fmt.Errorf("must be greater than zero")
+	}
+	digits := strings.TrimLeft(strings.Replace(value, ".", "", 1), "0")
+	if -amount.Exponent() > 5 || len(digits) > 18 {
+		return decimal.Zero, fmt.Errorf("must have at most 18 digits and 5 decimals")
+	}
+	return amount, nil
+}
+
+func parseDateTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date time %q", value)
+}
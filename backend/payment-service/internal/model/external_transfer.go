@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type ExternalTransferStatus string
+
+const (
+	// ExternalTransferAwaitingApproval waits for its debit from a business
+	// account to be approved before it is submitted
+	ExternalTransferAwaitingApproval ExternalTransferStatus = "AWAITING_APPROVAL"
+	// ExternalTransferPending is waiting to be submitted, or resubmitted after a transient failure
+	ExternalTransferPending ExternalTransferStatus = "PENDING"
+	// ExternalTransferSubmitted was accepted by the connector and is awaiting settlement
+	ExternalTransferSubmitted ExternalTransferStatus = "SUBMITTED"
+	ExternalTransferSettled   ExternalTransferStatus = "SETTLED"
+	// ExternalTransferRejected was refused by the connector; the funds are refunded
+	ExternalTransferRejected ExternalTransferStatus = "REJECTED"
+	// ExternalTransferFailed ran out of retries; the funds are refunded
+	ExternalTransferFailed ExternalTransferStatus = "FAILED"
+)
+
+// ExternalTransfer is a payment to an account at another bank, sent through a
+// connector. The funds move to the settlement account when the transfer is
+// created and are moved back if the connector rejects it.
+type ExternalTransfer struct {
+	ID              uuid.UUID              `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID          uuid.UUID              `gorm:"type:uuid;not null;index" json:"user_id"`
+	FromAccountID   uuid.UUID              `gorm:"type:uuid;not null" json:"from_account_id"`
+	Amount          decimal.Decimal        `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Currency        string                 `gorm:"type:char(3);not null" json:"currency"`
+	Scheme          string                 `gorm:"type:varchar(10);not null" json:"scheme"`
+	CreditorName    string                 `gorm:"type:varchar(140);not null" json:"creditor_name"`
+	IBAN            string                 `gorm:"type:varchar(34)" json:"iban,omitempty"`
+	SortCode        string                 `gorm:"type:varchar(6)" json:"sort_code,omitempty"`
+	AccountNumber   string                 `gorm:"type:varchar(8)" json:"account_number,omitempty"`
+	Reference       string                 `gorm:"type:varchar(35)" json:"reference,omitempty"`
+	Connector       string                 `gorm:"type:varchar(50);not null;uniqueIndex:idx_external_transfer_connector_ref" json:"connector"`
+	ExternalID      *string                `gorm:"type:varchar(100);uniqueIndex:idx_external_transfer_connector_ref" json:"external_id,omitempty"`
+	Status          ExternalTransferStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts        int                    `gorm:"not null" json:"attempts"`
+	NextAttemptAt   *time.Time             `json:"next_attempt_at,omitempty"`
+	LastError       string                 `gorm:"type:text" json:"last_error,omitempty"`
+	DebitPaymentID  *uuid.UUID             `gorm:"type:uuid" json:"debit_payment_id,omitempty"`
+	RefundPaymentID *uuid.UUID             `gorm:"type:uuid" json:"refund_payment_id,omitempty"`
+	SettledAt       *time.Time             `gorm:"index" json:"settled_at,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
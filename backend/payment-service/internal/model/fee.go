@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// PaymentType is the kind of payment a fee schedule applies to
+type PaymentType string
+
+const (
+	// PaymentTypeTransfer is a transfer a user makes between accounts
+	PaymentTypeTransfer PaymentType = "TRANSFER"
+	// PaymentTypeInstantTransfer is a user transfer on the instant rail
+	PaymentTypeInstantTransfer PaymentType = "INSTANT_TRANSFER"
+	// PaymentTypeDirectDebit is a merchant collection against a mandate
+	PaymentTypeDirectDebit PaymentType = "DIRECT_DEBIT"
+	// PaymentTypePayout is a merchant payout; its fee is deducted from the amount paid out
+	PaymentTypePayout PaymentType = "PAYOUT"
+)
+
+// FeeMethod is how a fee schedule computes the fee
+type FeeMethod string
+
+const (
+	FeeFlat       FeeMethod = "FLAT"
+	FeePercentage FeeMethod = "PERCENTAGE"
+	// FeeTiered charges the flat amount and percentage of the first tier the
+	// payment amount falls in
+	FeeTiered FeeMethod = "TIERED"
+)
+
+// FeeTier is one band of a tiered fee schedule. A zero UpTo is the open-ended
+// last tier.
+type FeeTier struct {
+	UpTo       decimal.Decimal `json:"up_to"`
+	FlatAmount decimal.Decimal `json:"flat_amount"`
+	Percentage decimal.Decimal `json:"percentage"`
+}
+
+// FeeSchedule sets the fee charged on payments of one type and currency,
+// optionally only for accounts of one product. Percentages are in percent,
+// e.g. 0.5 for 0.5%.
+type FeeSchedule struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string          `gorm:"type:varchar(100);not null" json:"name"`
+	ProductCode string          `gorm:"type:varchar(50);not null;default:''" json:"product_code,omitempty"` // Empty matches every product
+	Currency    string          `gorm:"type:char(3);not null" json:"currency"`
+	PaymentType PaymentType     `gorm:"type:varchar(20);not null" json:"payment_type"`
+	Method      FeeMethod       `gorm:"type:varchar(20);not null" json:"method"`
+	FlatAmount  decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0" json:"flat_amount"`
+	Percentage  decimal.Decimal `gorm:"type:numeric(7,4);not null;default:0" json:"percentage"`
+	Tiers       []FeeTier       `gorm:"type:jsonb;serializer:json" json:"tiers,omitempty"`
+	MinFee      decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0" json:"min_fee"`
+	MaxFee      decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0" json:"max_fee"` // Zero means no cap
+	Active      bool            `gorm:"not null" json:"active"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type IncomingCreditStatus string
+
+const (
+	// IncomingCreditReceived is recorded but not yet posted, or its posting failed and is retried on redelivery
+	IncomingCreditReceived IncomingCreditStatus = "RECEIVED"
+	// IncomingCreditPosted was matched to an account by its alias and credited to it
+	IncomingCreditPosted IncomingCreditStatus = "POSTED"
+	// IncomingCreditSuspense matched no account; the funds are held in the suspense account for ops to repair
+	IncomingCreditSuspense IncomingCreditStatus = "SUSPENSE"
+	// IncomingCreditReposted was repaired by ops and moved from the suspense account to the right account
+	IncomingCreditReposted IncomingCreditStatus = "REPOSTED"
+)
+
+// IncomingCredit is a payment into one of our accounts from another bank,
+// received through a connector. The funds arrive in the settlement account and
+// are moved to the account whose IBAN or sort code and account number the
+// credit was sent to, or to the suspense account when none matches.
+type IncomingCredit struct {
+	ID                uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Connector         string               `gorm:"type:varchar(50);not null;uniqueIndex:idx_incoming_credit_connector_ref" json:"connector"`
+	ExternalID        string               `gorm:"type:varchar(100);not null;uniqueIndex:idx_incoming_credit_connector_ref" json:"external_id"`
+	Amount            decimal.Decimal      `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Currency          string               `gorm:"type:char(3);not null" json:"currency"`
+	Scheme            string               `gorm:"type:varchar(10)" json:"scheme,omitempty"`
+	DebtorName        string               `gorm:"type:varchar(140)" json:"debtor_name,omitempty"`
+	IBAN              string               `gorm:"type:varchar(34)" json:"iban,omitempty"`
+	SortCode          string               `gorm:"type:varchar(6)" json:"sort_code,omitempty"`
+	AccountNumber     string               `gorm:"type:varchar(8)" json:"account_number,omitempty"`
+	Reference         string               `gorm:"type:varchar(140)" json:"reference,omitempty"`
+	Status            IncomingCreditStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	AccountID         *uuid.UUID           `gorm:"type:uuid" json:"account_id,omitempty"`
+	PaymentID         *uuid.UUID           `gorm:"type:uuid" json:"payment_id,omitempty"`
+	SuspensePaymentID *uuid.UUID           `gorm:"type:uuid" json:"suspense_payment_id,omitempty"`
+	SuspenseReason    string               `gorm:"type:text" json:"suspense_reason,omitempty"`
+	LastError         string               `gorm:"type:text" json:"last_error,omitempty"`
+	RepairedBy        *uuid.UUID           `gorm:"type:uuid" json:"repaired_by,omitempty"`
+	RepairNote        string               `gorm:"type:text" json:"repair_note,omitempty"`
+	RepairedAt        *time.Time           `json:"repaired_at,omitempty"`
+	CreatedAt         time.Time            `gorm:"index" json:"created_at"`
+	UpdatedAt         time.Time            `json:"updated_at"`
+}
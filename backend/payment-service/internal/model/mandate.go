@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type MandateStatus string
+
+const (
+	MandatePendingApproval MandateStatus = "PENDING_APPROVAL"
+	MandateActive          MandateStatus = "ACTIVE"
+	MandateRevoked         MandateStatus = "REVOKED"   // Revoked by the payer
+	MandateCancelled       MandateStatus = "CANCELLED" // Cancelled by the merchant
+	MandateExpired         MandateStatus = "EXPIRED"
+)
+
+// Merchant is a third party allowed to pull payments from user accounts via mandates.
+// Merchants authenticate with an API key; only its SHA-256 hash is stored.
+type Merchant struct {
+	ID                  uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	OwnerUserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"owner_user_id"`
+	Name                string         `gorm:"type:varchar(100);not null" json:"name"`
+	SettlementAccountID uuid.UUID      `gorm:"type:uuid;not null" json:"settlement_account_id"`
+	APIKeyHash          string         `gorm:"type:char(64);uniqueIndex;not null" json:"-"`
+	Active              bool           `gorm:"default:true" json:"active"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Mandate authorizes a merchant to collect payments from a user's account.
+// MaxAmount caps every single collection, MonthlyLimit (optional) caps the
+// total collected per calendar month.
+type Mandate struct {
+	ID              uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MerchantID      uuid.UUID       `gorm:"type:uuid;not null;index" json:"merchant_id"`
+	UserID          uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
+	DebtorAccountID uuid.UUID       `gorm:"type:uuid;not null" json:"debtor_account_id"`
+	Reference       string          `gorm:"type:varchar(100);not null" json:"reference"`
+	Currency        string          `gorm:"type:char(3);not null" json:"currency"`
+	MaxAmount       decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"max_amount"`
+	MonthlyLimit    decimal.Decimal `gorm:"type:numeric(19,4);default:0" json:"monthly_limit"`
+	Status          MandateStatus   `gorm:"type:varchar(20);default:'PENDING_APPROVAL';index" json:"status"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"`
+	ApprovedAt      *time.Time      `json:"approved_at,omitempty"`
+	RevokedAt       *time.Time      `json:"revoked_at,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// IsExpired reports whether the mandate has passed its expiry date
+func (m *Mandate) IsExpired(now time.Time) bool {
+	return m.ExpiresAt != nil && now.After(*m.ExpiresAt)
+}
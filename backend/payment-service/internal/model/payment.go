@@ -14,17 +14,46 @@ const (
 	StatusPending   PaymentStatus = "PENDING"
 	StatusCompleted PaymentStatus = "COMPLETED"
 	StatusFailed    PaymentStatus = "FAILED"
+	StatusCancelled PaymentStatus = "CANCELLED" // Cancelled by the payer before the ledger posted it
+	// StatusAwaitingApproval payments are held until enough members of the
+	// organization paying them approve; see PaymentApproval
+	StatusAwaitingApproval PaymentStatus = "AWAITING_APPROVAL"
+	StatusRejected         PaymentStatus = "REJECTED" // Rejected by an approver
+	StatusExpired          PaymentStatus = "EXPIRED"  // Not approved in time
+)
+
+// TransferRail is how a user transfer is cleared
+type TransferRail string
+
+const (
+	// RailInstant transfers are posted to the ledger while the user waits
+	RailInstant TransferRail = "INSTANT"
+	// RailStandard transfers are queued and posted by the next standard batch
+	RailStandard TransferRail = "STANDARD"
 )
 
 type Payment struct {
-	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
-	FromAccountID uuid.UUID       `gorm:"type:uuid;not null"`
-	ToAccountID   uuid.UUID       `gorm:"type:uuid;not null"`
-	Amount        decimal.Decimal `gorm:"type:numeric(19,4);not null"`
-	Currency      string          `gorm:"type:char(3);not null"`
-	Status        PaymentStatus   `gorm:"type:varchar(20);default:'PENDING'"`
-	Description   string          `gorm:"type:text"`
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	ID                uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	FromAccountID     uuid.UUID       `gorm:"type:uuid;not null"`
+	ToAccountID       uuid.UUID       `gorm:"type:uuid;not null"`
+	Amount            decimal.Decimal `gorm:"type:numeric(19,4);not null"`
+	Currency          string          `gorm:"type:char(3);not null"`
+	Status            PaymentStatus   `gorm:"type:varchar(20);default:'PENDING'"`
+	Description       string          `gorm:"type:text"`
+	MandateID         *uuid.UUID      `gorm:"type:uuid;index"`                       // Set for direct debit collections
+	LedgerEntryID     *uuid.UUID      `gorm:"type:uuid"`                             // Journal entry that moved the funds; refunds reverse it
+	RefundedAmount    decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0"` // Reserved by pending refunds and kept by completed ones
+	PaymentType       PaymentType     `gorm:"type:varchar(20)"`                      // Set when the payment was priced by the fee engine
+	Fee               decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0"` // Charged to the payer on top of Amount; not refunded
+	FeeScheduleID     *uuid.UUID      `gorm:"type:uuid"`
+	UserID            *uuid.UUID      `gorm:"type:uuid;index"`  // Set for user transfers; only that user may cancel it
+	Rail              TransferRail    `gorm:"type:varchar(10)"` // Set when the user chose a rail
+	EstimatedArrival  *time.Time
+	CancelledAt       *time.Time
+	OrgID             *uuid.UUID `gorm:"type:uuid;index"` // Set for transfers from business accounts that need approval
+	ApprovalsRequired int        `gorm:"not null;default:0"`
+	ApprovalExpiresAt *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         gorm.DeletedAt `gorm:"index"`
 }
@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ApprovalDecision is what an approver decided on a payment awaiting approval
+type ApprovalDecision string
+
+const (
+	ApprovalApproved ApprovalDecision = "APPROVED"
+	ApprovalRejected ApprovalDecision = "REJECTED"
+)
+
+// PaymentApproval is one approver's decision on a business account payment
+// awaiting approval. Each member of the organization decides at most once on
+// a payment, and decisions are never changed.
+type PaymentApproval struct {
+	ID         uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PaymentID  uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_payment_approvals_approver" json:"payment_id"`
+	ApproverID uuid.UUID        `gorm:"type:uuid;not null;uniqueIndex:idx_payment_approvals_approver" json:"approver_id"`
+	OrgRole    string           `gorm:"type:varchar(20);not null" json:"org_role"`
+	Decision   ApprovalDecision `gorm:"type:varchar(10);not null" json:"decision"`
+	Comment    string           `gorm:"type:text" json:"comment,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type PaymentBatchStatus string
+
+const (
+	// PaymentBatchProcessing is set while the batch's transfers are being initiated
+	PaymentBatchProcessing PaymentBatchStatus = "PROCESSING"
+	PaymentBatchCompleted  PaymentBatchStatus = "COMPLETED"
+	// PaymentBatchPartial means some transfers were rejected
+	PaymentBatchPartial  PaymentBatchStatus = "PARTIALLY_COMPLETED"
+	PaymentBatchRejected PaymentBatchStatus = "REJECTED"
+)
+
+type PaymentBatchItemStatus string
+
+const (
+	PaymentBatchItemAccepted PaymentBatchItemStatus = "ACCEPTED"
+	PaymentBatchItemRejected PaymentBatchItemStatus = "REJECTED"
+)
+
+// PaymentBatch is a set of transfers imported from one ISO 20022 pain.001 message.
+// The message ID is unique per uploader so the same file cannot be executed twice.
+type PaymentBatch struct {
+	ID                   uuid.UUID          `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MessageID            string             `gorm:"type:varchar(35);not null;uniqueIndex:idx_payment_batch_message" json:"message_id"`
+	UploadedBy           uuid.UUID          `gorm:"type:uuid;not null;uniqueIndex:idx_payment_batch_message" json:"uploaded_by"`
+	InitiatingParty      string             `gorm:"type:varchar(140)" json:"initiating_party,omitempty"`
+	NumberOfTransactions int                `gorm:"not null" json:"number_of_transactions"`
+	ControlSum           decimal.Decimal    `gorm:"type:numeric(19,4);not null" json:"control_sum"`
+	Accepted             int                `gorm:"not null" json:"accepted"`
+	Rejected             int                `gorm:"not null" json:"rejected"`
+	Status               PaymentBatchStatus `gorm:"type:varchar(20);not null" json:"status"`
+	Items                []PaymentBatchItem `gorm:"type:jsonb;serializer:json" json:"items"`
+	ClearingMessageID    string             `gorm:"type:varchar(35)" json:"clearing_message_id,omitempty"`
+	ClearedAt            *time.Time         `json:"cleared_at,omitempty"`
+	CreatedAt            time.Time          `json:"created_at"`
+	UpdatedAt            time.Time          `json:"updated_at"`
+}
+
+// PaymentBatchItem is one credit transfer from the imported message and its outcome
+type PaymentBatchItem struct {
+	EndToEndID      string                 `json:"end_to_end_id"`
+	InstructionID   string                 `json:"instruction_id,omitempty"`
+	DebtorName      string                 `json:"debtor_name,omitempty"`
+	DebtorAccount   string                 `json:"debtor_account"`
+	CreditorName    string                 `json:"creditor_name,omitempty"`
+	CreditorAccount string                 `json:"creditor_account"`
+	Amount          decimal.Decimal        `json:"amount"`
+	Currency        string                 `json:"currency"`
+	RemittanceInfo  string                 `json:"remittance_info,omitempty"`
+	Status          PaymentBatchItemStatus `json:"status"`
+	PaymentID       *uuid.UUID             `json:"payment_id,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+}
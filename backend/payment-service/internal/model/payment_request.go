@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestStatus string
+
+const (
+	PaymentRequestOpen      PaymentRequestStatus = "OPEN"
+	PaymentRequestPaid      PaymentRequestStatus = "PAID"
+	PaymentRequestExpired   PaymentRequestStatus = "EXPIRED"
+	PaymentRequestCancelled PaymentRequestStatus = "CANCELLED"
+)
+
+// PaymentRequest asks another user to pay a fixed amount into the requester's account.
+// The Reference is short and shareable; whoever holds it can fulfil the request once.
+type PaymentRequest struct {
+	ID                 uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Reference          string               `gorm:"type:varchar(20);uniqueIndex;not null" json:"reference"`
+	RequesterUserID    uuid.UUID            `gorm:"type:uuid;not null;index" json:"requester_user_id"`
+	RequesterAccountID uuid.UUID            `gorm:"type:uuid;not null" json:"requester_account_id"`
+	Amount             decimal.Decimal      `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Currency           string               `gorm:"type:char(3);not null" json:"currency"`
+	Description        string               `gorm:"type:text" json:"description"`
+	Status             PaymentRequestStatus `gorm:"type:varchar(20);default:'OPEN';index" json:"status"`
+	ExpiresAt          time.Time            `gorm:"not null;index" json:"expires_at"`
+	PayerUserID        *uuid.UUID           `gorm:"type:uuid" json:"payer_user_id,omitempty"`
+	PaymentID          *uuid.UUID           `gorm:"type:uuid" json:"payment_id,omitempty"`
+	PaidAt             *time.Time           `json:"paid_at,omitempty"`
+	CancelledAt        *time.Time           `json:"cancelled_at,omitempty"`
+	CreatedAt          time.Time            `json:"created_at"`
+	UpdatedAt          time.Time            `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt       `gorm:"index" json:"-"`
+}
+
+// IsExpired reports whether an open request has passed its expiry time
+func (r *PaymentRequest) IsExpired(now time.Time) bool {
+	return r.Status == PaymentRequestOpen && now.After(r.ExpiresAt)
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type PayoutStatus string
+
+const (
+	// PayoutPending is queued for the merchant's next settlement batch
+	PayoutPending PayoutStatus = "PENDING"
+	// PayoutBatched has been taken into a batch that is being settled
+	PayoutBatched PayoutStatus = "BATCHED"
+	PayoutSettled PayoutStatus = "SETTLED"
+	// PayoutFailed was not paid, on its own or with the rest of its batch; see Error
+	PayoutFailed PayoutStatus = "FAILED"
+)
+
+type PayoutBatchStatus string
+
+const (
+	// PayoutBatchProcessing is set while the batch is priced and posted to the ledger
+	PayoutBatchProcessing PayoutBatchStatus = "PROCESSING"
+	PayoutBatchSettled    PayoutBatchStatus = "SETTLED"
+	// PayoutBatchFailed was not posted; its payouts failed with it
+	PayoutBatchFailed PayoutBatchStatus = "FAILED"
+)
+
+// Payout is an instruction from a merchant to pay an account from its
+// settlement account. Payouts are not paid one by one: they wait until the
+// merchant's next settlement batch, and the fee is deducted from the amount
+// the destination receives.
+type Payout struct {
+	ID                   uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MerchantID           uuid.UUID       `gorm:"type:uuid;not null;index:idx_payouts_merchant" json:"merchant_id"`
+	DestinationAccountID uuid.UUID       `gorm:"type:uuid;not null" json:"destination_account_id"`
+	Amount               decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Currency             string          `gorm:"type:char(3);not null" json:"currency"`
+	Fee                  decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0" json:"fee"`
+	FeeScheduleID        *uuid.UUID      `gorm:"type:uuid" json:"fee_schedule_id,omitempty"`
+	Reference            string          `gorm:"type:varchar(140)" json:"reference,omitempty"`
+	Status               PayoutStatus    `gorm:"type:varchar(20);not null;index:idx_payouts_status" json:"status"`
+	BatchID              *uuid.UUID      `gorm:"type:uuid;index:idx_payouts_batch" json:"batch_id,omitempty"`
+	Error                string          `gorm:"type:text" json:"error,omitempty"`
+	SettledAt            *time.Time      `json:"settled_at,omitempty"`
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+// NetAmount is what the destination receives once the fee is deducted
+func (p *Payout) NetAmount() decimal.Decimal {
+	return p.Amount.Sub(p.Fee)
+}
+
+// PayoutBatch settles a merchant's pending payouts in one currency with a
+// single ledger entry: the gross is debited from the merchant's settlement
+// account, each destination is credited its net amount and the fees go to
+// the fee income account.
+type PayoutBatch struct {
+	ID                  uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	MerchantID          uuid.UUID         `gorm:"type:uuid;not null;index:idx_payout_batches_merchant" json:"merchant_id"`
+	SettlementAccountID uuid.UUID         `gorm:"type:uuid;not null" json:"settlement_account_id"`
+	Currency            string            `gorm:"type:char(3);not null" json:"currency"`
+	PayoutCount         int               `gorm:"not null" json:"payout_count"`
+	FailedCount         int               `gorm:"not null" json:"failed_count"`
+	GrossAmount         decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"gross_amount"`
+	FeeAmount           decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"fee_amount"`
+	NetAmount           decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"net_amount"`
+	Status              PayoutBatchStatus `gorm:"type:varchar(20);not null" json:"status"`
+	LedgerEntryID       *uuid.UUID        `gorm:"type:uuid" json:"ledger_entry_id,omitempty"`
+	Error               string            `gorm:"type:text" json:"error,omitempty"`
+	SettledAt           *time.Time        `json:"settled_at,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
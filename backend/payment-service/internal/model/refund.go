@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type RefundStatus string
+
+const (
+	// RefundPending has reserved its amount on the payment and is being posted to the ledger
+	RefundPending   RefundStatus = "PENDING"
+	RefundCompleted RefundStatus = "COMPLETED"
+	// RefundFailed was not posted; its amount is available to refund again
+	RefundFailed RefundStatus = "FAILED"
+)
+
+// Refund returns all or part of a completed payment to the payer by posting a
+// ledger entry that reverses the payment's entry. The payment keeps its
+// COMPLETED status; each refund tracks its own.
+type Refund struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PaymentID     uuid.UUID       `gorm:"type:uuid;not null;index" json:"payment_id"`
+	RequestedBy   uuid.UUID       `gorm:"type:uuid;not null" json:"requested_by"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Currency      string          `gorm:"type:char(3);not null" json:"currency"`
+	Reason        string          `gorm:"type:varchar(140)" json:"reason,omitempty"`
+	Status        RefundStatus    `gorm:"type:varchar(20);not null;index" json:"status"`
+	LedgerEntryID *uuid.UUID      `gorm:"type:uuid" json:"ledger_entry_id,omitempty"`
+	FailureReason string          `gorm:"type:text" json:"failure_reason,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,94 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type SettlementReportFormat string
+
+const (
+	SettlementReportCSV     SettlementReportFormat = "CSV"
+	SettlementReportCamt053 SettlementReportFormat = "CAMT053"
+)
+
+// SettlementReport is an end-of-day report from a connector's rail of the
+// payments it settled, reconciled against our external transfers and
+// incoming credits. Each connector reports once per day.
+type SettlementReport struct {
+	ID         uuid.UUID              `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Connector  string                 `gorm:"type:varchar(50);not null;uniqueIndex:idx_settlement_reports_connector_date" json:"connector"`
+	ReportDate time.Time              `gorm:"type:date;not null;uniqueIndex:idx_settlement_reports_connector_date" json:"report_date"`
+	Format     SettlementReportFormat `gorm:"type:varchar(10);not null" json:"format"`
+	// FileSHA256 identifies the ingested file for audits
+	FileSHA256     string     `gorm:"type:char(64);not null" json:"file_sha256"`
+	EntryCount     int        `gorm:"not null" json:"entry_count"`
+	MatchedCount   int        `gorm:"not null" json:"matched_count"`
+	ExceptionCount int        `gorm:"not null" json:"exception_count"`
+	UploadedBy     *uuid.UUID `gorm:"type:uuid" json:"uploaded_by,omitempty"` // Unset when the end-of-day job fetched the report
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type SettlementExceptionType string
+
+const (
+	// SettlementUnknownEntry is a reported payment we have no record of
+	SettlementUnknownEntry SettlementExceptionType = "UNKNOWN_ENTRY"
+	// SettlementAmountMismatch is a payment reported with a different amount or currency
+	SettlementAmountMismatch SettlementExceptionType = "AMOUNT_MISMATCH"
+	// SettlementStatusMismatch is a payment the rail settled or returned while our record says otherwise
+	SettlementStatusMismatch SettlementExceptionType = "STATUS_MISMATCH"
+	// SettlementMissingFromReport is a payment we recorded as settled that the rail did not report
+	SettlementMissingFromReport SettlementExceptionType = "MISSING_FROM_REPORT"
+	// SettlementDuplicateEntry is a payment reported more than once
+	SettlementDuplicateEntry SettlementExceptionType = "DUPLICATE_ENTRY"
+)
+
+type SettlementExceptionStatus string
+
+const (
+	SettlementExceptionOpen     SettlementExceptionStatus = "OPEN"
+	SettlementExceptionResolved SettlementExceptionStatus = "RESOLVED"
+)
+
+// SettlementResolution is how ops closed an exception
+type SettlementResolution string
+
+const (
+	// SettlementResolvedCorrected means our records or the ledger were corrected
+	SettlementResolvedCorrected SettlementResolution = "CORRECTED"
+	// SettlementResolvedAccepted means the difference was explained and needs no correction
+	SettlementResolvedAccepted SettlementResolution = "ACCEPTED"
+	// SettlementResolvedRailError means the rail's report was wrong and the rail will correct it
+	SettlementResolvedRailError SettlementResolution = "RAIL_ERROR"
+)
+
+// SettlementException is a difference between a settlement report and our
+// records, queued for ops to investigate and resolve. Reported* fields come
+// from the report and Internal* fields from our record, when each exists.
+type SettlementException struct {
+	ID                 uuid.UUID                 `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ReportID           uuid.UUID                 `gorm:"type:uuid;not null;index" json:"report_id"`
+	Connector          string                    `gorm:"type:varchar(50);not null" json:"connector"`
+	Type               SettlementExceptionType   `gorm:"type:varchar(30);not null" json:"type"`
+	Direction          string                    `gorm:"type:varchar(10);not null" json:"direction"` // DEBIT for external transfers, CREDIT for incoming credits
+	ExternalID         string                    `gorm:"type:varchar(100)" json:"external_id,omitempty"`
+	ExternalTransferID *uuid.UUID                `gorm:"type:uuid" json:"external_transfer_id,omitempty"`
+	IncomingCreditID   *uuid.UUID                `gorm:"type:uuid" json:"incoming_credit_id,omitempty"`
+	ReportedAmount     *decimal.Decimal          `gorm:"type:numeric(19,4)" json:"reported_amount,omitempty"`
+	ReportedCurrency   string                    `gorm:"type:varchar(3)" json:"reported_currency,omitempty"`
+	ReportedStatus     string                    `gorm:"type:varchar(20)" json:"reported_status,omitempty"`
+	InternalAmount     *decimal.Decimal          `gorm:"type:numeric(19,4)" json:"internal_amount,omitempty"`
+	InternalCurrency   string                    `gorm:"type:varchar(3)" json:"internal_currency,omitempty"`
+	InternalStatus     string                    `gorm:"type:varchar(20)" json:"internal_status,omitempty"`
+	Details            string                    `gorm:"type:text" json:"details"`
+	Status             SettlementExceptionStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Resolution         SettlementResolution      `gorm:"type:varchar(20)" json:"resolution,omitempty"`
+	ResolutionNote     string                    `gorm:"type:text" json:"resolution_note,omitempty"`
+	ResolvedBy         *uuid.UUID                `gorm:"type:uuid" json:"resolved_by,omitempty"`
+	ResolvedAt         *time.Time                `json:"resolved_at,omitempty"`
+	CreatedAt          time.Time                 `json:"created_at"`
+	UpdatedAt          time.Time                 `json:"updated_at"`
+}
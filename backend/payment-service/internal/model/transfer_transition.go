@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferState is a step of a transfer's progress on its timeline. It is
+// finer than PaymentStatus: a pending payment may be CREATED, QUEUED or POSTED.
+type TransferState string
+
+const (
+	// TransferCreated is recorded when the payment is stored as pending
+	TransferCreated TransferState = "CREATED"
+	// TransferQueued was handed to the ledger consumer or the standard rail batch
+	TransferQueued TransferState = "QUEUED"
+	// TransferPosted was posted to the ledger as a journal entry
+	TransferPosted    TransferState = "POSTED"
+	TransferCompleted TransferState = "COMPLETED"
+	TransferFailed    TransferState = "FAILED"
+	TransferCancelled TransferState = "CANCELLED"
+	// TransferReversed was posted after it was cancelled, and its entry reversed
+	TransferReversed TransferState = "REVERSED"
+	// TransferAwaitingApproval is held until enough members of the paying
+	// organization approve it
+	TransferAwaitingApproval TransferState = "AWAITING_APPROVAL"
+	// TransferApproved is recorded for each approval, by its approver
+	TransferApproved TransferState = "APPROVED"
+	TransferRejected TransferState = "REJECTED"
+	// TransferExpired was not approved before its approval window closed
+	TransferExpired TransferState = "EXPIRED"
+)
+
+// Actors of timeline transitions other than users
+const (
+	ActorPaymentService = "payment-service"
+	ActorLedger         = "ledger-service"
+)
+
+// UserActor is the actor of a transition a user made
+func UserActor(userID string) string {
+	return "user:" + userID
+}
+
+// TransferTransition is one entry of a transfer's timeline: the state it
+// moved to, when, and who or what moved it. Transitions are only appended.
+type TransferTransition struct {
+	ID        uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	PaymentID uuid.UUID     `gorm:"type:uuid;not null;index" json:"payment_id"`
+	State     TransferState `gorm:"type:varchar(20);not null" json:"state"`
+	Actor     string        `gorm:"type:varchar(64);not null" json:"actor"`
+	Detail    string        `gorm:"type:text" json:"detail,omitempty"`
+	CreatedAt time.Time     `json:"at"`
+}
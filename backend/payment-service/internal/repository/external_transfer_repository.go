@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type ExternalTransferRepository struct {
+	DB *gorm.DB
+}
+
+func NewExternalTransferRepository(db *gorm.DB) *ExternalTransferRepository {
+	return &ExternalTransferRepository{DB: db}
+}
+
+func (r *ExternalTransferRepository) Create(t *model.ExternalTransfer) error {
+	return r.DB.Create(t).Error
+}
+
+func (r *ExternalTransferRepository) GetByID(id string) (*model.ExternalTransfer, error) {
+	var t model.ExternalTransfer
+	if err := r.DB.Where("id = ?", id).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetByExternalID finds a transfer by the ID its connector assigned
+func (r *ExternalTransferRepository) GetByExternalID(connector, externalID string) (*model.ExternalTransfer, error) {
+	var t model.ExternalTransfer
+	if err := r.DB.Where("connector = ? AND external_id = ?", connector, externalID).First(&t).Error; err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListDue returns pending transfers whose next attempt is due, and transfers
+// awaiting approval whose next approval check is due, oldest first
+func (r *ExternalTransferRepository) ListDue(now time.Time, limit int) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	err := r.DB.Where("status IN ? AND next_attempt_at <= ?", []model.ExternalTransferStatus{model.ExternalTransferPending, model.ExternalTransferAwaitingApproval}, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&transfers).Error
+	return transfers, err
+}
+
+// UpdateIfStatus saves the transfer only if its stored status is still expected.
+// It reports false when another worker or webhook changed it first.
+func (r *ExternalTransferRepository) UpdateIfStatus(t *model.ExternalTransfer, expected model.ExternalTransferStatus) (bool, error) {
+	result := r.DB.Model(t).Where("status = ?", expected).Select("*").Omit("created_at").Updates(t)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type FeeRepository struct {
+	DB *gorm.DB
+}
+
+func NewFeeRepository(db *gorm.DB) *FeeRepository {
+	return &FeeRepository{DB: db}
+}
+
+func (r *FeeRepository) CreateSchedule(schedule *model.FeeSchedule) error {
+	return r.DB.Create(schedule).Error
+}
+
+func (r *FeeRepository) SaveSchedule(schedule *model.FeeSchedule) error {
+	return r.DB.Save(schedule).Error
+}
+
+func (r *FeeRepository) DeleteSchedule(id string) (bool, error) {
+	result := r.DB.Where("id = ?", id).Delete(&model.FeeSchedule{})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *FeeRepository) GetSchedule(id string) (*model.FeeSchedule, error) {
+	var schedule model.FeeSchedule
+	if err := r.DB.Where("id = ?", id).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListSchedules returns every fee schedule, grouped by payment type and currency
+func (r *FeeRepository) ListSchedules() ([]model.FeeSchedule, error) {
+	var schedules []model.FeeSchedule
+	err := r.DB.Order("payment_type, currency, product_code, created_at").Find(&schedules).Error
+	return schedules, err
+}
+
+// ActiveSchedules returns the active schedules for a payment type and currency
+func (r *FeeRepository) ActiveSchedules(paymentType model.PaymentType, currency string) ([]model.FeeSchedule, error) {
+	var schedules []model.FeeSchedule
+	err := r.DB.Where("payment_type = ? AND currency = ? AND active", paymentType, currency).
+		Order("created_at").Find(&schedules).Error
+	return schedules, err
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IncomingCreditRepository struct {
+	DB *gorm.DB
+}
+
+func NewIncomingCreditRepository(db *gorm.DB) *IncomingCreditRepository {
+	return &IncomingCreditRepository{DB: db}
+}
+
+// CreateIfNew records a credit unless one with the same connector and external
+// ID already exists. It reports false for a redelivered credit.
+func (r *IncomingCreditRepository) CreateIfNew(c *model.IncomingCredit) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "connector"}, {Name: "external_id"}},
+		DoNothing: true,
+	}).Create(c)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *IncomingCreditRepository) GetByID(id string) (*model.IncomingCredit, error) {
+	var c model.IncomingCredit
+	if err := r.DB.Where("id = ?", id).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetByExternalID finds a credit by the ID its connector assigned
+func (r *IncomingCreditRepository) GetByExternalID(connector, externalID string) (*model.IncomingCredit, error) {
+	var c model.IncomingCredit
+	if err := r.DB.Where("connector = ? AND external_id = ?", connector, externalID).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// List returns credits with the given status, or all credits if it is empty, oldest first
+func (r *IncomingCreditRepository) List(status model.IncomingCreditStatus, limit int) ([]model.IncomingCredit, error) {
+	var credits []model.IncomingCredit
+	query := r.DB.Order("created_at").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&credits).Error
+	return credits, err
+}
+
+// UpdateIfStatus saves the credit only if its stored status is still expected.
+// It reports false when a redelivery or another operator changed it first.
+func (r *IncomingCreditRepository) UpdateIfStatus(c *model.IncomingCredit, expected model.IncomingCreditStatus) (bool, error) {
+	result := r.DB.Model(c).Where("status = ?", expected).Select("*").Omit("created_at").Updates(c)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type MandateRepository struct {
+	DB *gorm.DB
+}
+
+func NewMandateRepository(db *gorm.DB) *MandateRepository {
+	return &MandateRepository{DB: db}
+}
+
+func (r *MandateRepository) CreateMerchant(m *model.Merchant) error {
+	return r.DB.Create(m).Error
+}
+
+// GetMerchantByAPIKeyHash looks up an active merchant by the hash of its API key
+func (r *MandateRepository) GetMerchantByAPIKeyHash(hash string) (*model.Merchant, error) {
+	var m model.Merchant
+	if err := r.DB.Where("api_key_hash = ? AND active = ?", hash, true).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *MandateRepository) GetMerchant(id string) (*model.Merchant, error) {
+	var m model.Merchant
+	if err := r.DB.Where("id = ?", id).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *MandateRepository) CreateMandate(m *model.Mandate) error {
+	return r.DB.Create(m).Error
+}
+
+func (r *MandateRepository) GetMandate(id string) (*model.Mandate, error) {
+	var m model.Mandate
+	if err := r.DB.Where("id = ?", id).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListMandatesByUser returns all mandates where the user is the payer
+func (r *MandateRepository) ListMandatesByUser(userID string) ([]model.Mandate, error) {
+	var mandates []model.Mandate
+	if err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&mandates).Error; err != nil {
+		return nil, err
+	}
+	return mandates, nil
+}
+
+// SaveMandate persists status and timestamp changes on a mandate
+func (r *MandateRepository) SaveMandate(m *model.Mandate) error {
+	return r.DB.Save(m).Error
+}
+
+// SumCollectedSince returns the total amount collected against a mandate since the given time.
+// Failed payments are excluded.
+func (r *MandateRepository) SumCollectedSince(mandateID string, since time.Time) (decimal.Decimal, error) {
+	var total decimal.NullDecimal
+	err := r.DB.Model(&model.Payment{}).
+		Select("SUM(amount)").
+		Where("mandate_id = ? AND created_at >= ? AND status <> ?", mandateID, since, model.StatusFailed).
+		Scan(&total).Error
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if !total.Valid {
+		return decimal.Zero, nil
+	}
+	return total.Decimal, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PaymentApprovalRepository struct {
+	DB *gorm.DB
+}
+
+func NewPaymentApprovalRepository(db *gorm.DB) *PaymentApprovalRepository {
+	return &PaymentApprovalRepository{DB: db}
+}
+
+// AddApproval records an approver's decision. It reports false, recording
+// nothing, when the approver already decided on the payment.
+func (r *PaymentApprovalRepository) AddApproval(a *model.PaymentApproval) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(a)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListApprovals returns the decisions on a payment, oldest first
+func (r *PaymentApprovalRepository) ListApprovals(paymentID uuid.UUID) ([]model.PaymentApproval, error) {
+	var approvals []model.PaymentApproval
+	err := r.DB.Where("payment_id = ?", paymentID).Order("created_at").Find(&approvals).Error
+	return approvals, err
+}
+
+// ReleaseAwaiting moves a payment awaiting approval to status. It reports
+// false, changing nothing, when the payment no longer awaits approval, so
+// only one of two approvers completing the approvals at once releases it.
+func (r *PaymentApprovalRepository) ReleaseAwaiting(id uuid.UUID, status model.PaymentStatus) (bool, error) {
+	result := r.DB.Model(&model.Payment{}).Where("id = ? AND status = ?", id, model.StatusAwaitingApproval).Update("status", status)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListAwaitingApproval returns an organization's payments awaiting approval,
+// oldest first
+func (r *PaymentApprovalRepository) ListAwaitingApproval(orgID uuid.UUID) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := r.DB.Where("org_id = ? AND status = ?", orgID, model.StatusAwaitingApproval).Order("created_at").Find(&payments).Error
+	return payments, err
+}
+
+// ExpiredApprovals returns up to limit payments still awaiting approval
+// whose approval window closed before the given time, oldest first
+func (r *PaymentApprovalRepository) ExpiredApprovals(before time.Time, limit int) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := r.DB.
+		Where("status = ? AND approval_expires_at < ?", model.StatusAwaitingApproval, before).
+		Order("approval_expires_at").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
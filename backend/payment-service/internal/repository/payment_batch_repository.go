@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type PaymentBatchRepository struct {
+	DB *gorm.DB
+}
+
+func NewPaymentBatchRepository(db *gorm.DB) *PaymentBatchRepository {
+	return &PaymentBatchRepository{DB: db}
+}
+
+func (r *PaymentBatchRepository) Create(b *model.PaymentBatch) error {
+	return r.DB.Create(b).Error
+}
+
+func (r *PaymentBatchRepository) GetByID(id string) (*model.PaymentBatch, error) {
+	var b model.PaymentBatch
+	if err := r.DB.Where("id = ?", id).First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetByMessageID finds a batch the user already uploaded with the same pain.001 message ID
+func (r *PaymentBatchRepository) GetByMessageID(uploadedBy, messageID string) (*model.PaymentBatch, error) {
+	var b model.PaymentBatch
+	if err := r.DB.Where("uploaded_by = ? AND message_id = ?", uploadedBy, messageID).First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *PaymentBatchRepository) Save(b *model.PaymentBatch) error {
+	return r.DB.Save(b).Error
+}
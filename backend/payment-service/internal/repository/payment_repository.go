@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -28,3 +31,50 @@ func (r *PaymentRepository) GetPayment(id string) (*model.Payment, error) {
 	}
 	return &p, nil
 }
+
+// CompletePayment marks a payment completed and records its ledger entry
+func (r *PaymentRepository) CompletePayment(id string, ledgerEntryID *uuid.UUID) error {
+	return r.DB.Model(&model.Payment{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":          model.StatusCompleted,
+		"ledger_entry_id": ledgerEntryID,
+	}).Error
+}
+
+// ResolvePayment marks a pending payment completed or failed, recording the
+// ledger entry of a completed one. It reports false, changing nothing, when
+// the payment is no longer pending, such as one cancelled meanwhile.
+func (r *PaymentRepository) ResolvePayment(id string, status model.PaymentStatus, ledgerEntryID *uuid.UUID) (bool, error) {
+	updates := map[string]interface{}{"status": status}
+	if ledgerEntryID != nil {
+		updates["ledger_entry_id"] = ledgerEntryID
+	}
+	result := r.DB.Model(&model.Payment{}).Where("id = ? AND status = ?", id, model.StatusPending).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CancelPayment marks a pending payment cancelled. It reports false, changing
+// nothing, when the payment is no longer pending.
+func (r *PaymentRepository) CancelPayment(id string, at time.Time) (bool, error) {
+	result := r.DB.Model(&model.Payment{}).Where("id = ? AND status = ?", id, model.StatusPending).
+		Updates(map[string]interface{}{"status": model.StatusCancelled, "cancelled_at": at})
+	return result.RowsAffected > 0, result.Error
+}
+
+// QueuedStandardPayments returns up to limit pending standard rail transfers
+// created before the given time, oldest first
+func (r *PaymentRepository) QueuedStandardPayments(before time.Time, limit int) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := r.DB.
+		Where("rail = ? AND status = ? AND created_at < ?", model.RailStandard, model.StatusPending, before).
+		Order("created_at").
+		Limit(limit).
+		Find(&payments).Error
+	return payments, err
+}
+
+// RestorePending puts a cancelled payment back to pending, for a cancellation
+// that could not be sent to the ledger
+func (r *PaymentRepository) RestorePending(id string) error {
+	return r.DB.Model(&model.Payment{}).Where("id = ? AND status = ?", id, model.StatusCancelled).
+		Updates(map[string]interface{}{"status": model.StatusPending, "cancelled_at": nil}).Error
+}
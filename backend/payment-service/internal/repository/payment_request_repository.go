@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestRepository struct {
+	DB *gorm.DB
+}
+
+func NewPaymentRequestRepository(db *gorm.DB) *PaymentRequestRepository {
+	return &PaymentRequestRepository{DB: db}
+}
+
+func (r *PaymentRequestRepository) Create(pr *model.PaymentRequest) error {
+	return r.DB.Create(pr).Error
+}
+
+func (r *PaymentRequestRepository) GetByReference(reference string) (*model.PaymentRequest, error) {
+	var pr model.PaymentRequest
+	if err := r.DB.Where("reference = ?", reference).First(&pr).Error; err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// ListByRequester returns all payment requests created by the user
+func (r *PaymentRequestRepository) ListByRequester(userID string) ([]model.PaymentRequest, error) {
+	var requests []model.PaymentRequest
+	if err := r.DB.Where("requester_user_id = ?", userID).Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// ListExpiredOpen returns open requests whose expiry is before now
+func (r *PaymentRequestRepository) ListExpiredOpen(now time.Time) ([]model.PaymentRequest, error) {
+	var requests []model.PaymentRequest
+	if err := r.DB.Where("status = ? AND expires_at < ?", model.PaymentRequestOpen, now).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// TransitionStatus moves a request from one status to another. It reports false if the
+// request was no longer in the expected status, so concurrent payers cannot both claim it.
+func (r *PaymentRequestRepository) TransitionStatus(id string, from, to model.PaymentRequestStatus) (bool, error) {
+	result := r.DB.Model(&model.PaymentRequest{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Save persists status, payer and timestamp changes on a request
+func (r *PaymentRequestRepository) Save(pr *model.PaymentRequest) error {
+	return r.DB.Save(pr).Error
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PayoutRepository struct {
+	DB *gorm.DB
+}
+
+func NewPayoutRepository(db *gorm.DB) *PayoutRepository {
+	return &PayoutRepository{DB: db}
+}
+
+func (r *PayoutRepository) Create(p *model.Payout) error {
+	return r.DB.Create(p).Error
+}
+
+func (r *PayoutRepository) GetByID(id string) (*model.Payout, error) {
+	var p model.Payout
+	if err := r.DB.Where("id = ?", id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListByMerchant returns a merchant's payouts, newest first, optionally only those in one status
+func (r *PayoutRepository) ListByMerchant(merchantID string, status model.PayoutStatus, limit int) ([]model.Payout, error) {
+	var payouts []model.Payout
+	query := r.DB.Where("merchant_id = ?", merchantID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("created_at DESC").Limit(limit).Find(&payouts).Error
+	return payouts, err
+}
+
+// PendingMerchants returns the merchants with payouts waiting to be settled
+func (r *PayoutRepository) PendingMerchants() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.DB.Model(&model.Payout{}).Where("status = ?", model.PayoutPending).Distinct().Pluck("merchant_id", &ids).Error
+	return ids, err
+}
+
+// ListPending returns a merchant's pending payouts, oldest first
+func (r *PayoutRepository) ListPending(merchantID string, limit int) ([]model.Payout, error) {
+	var payouts []model.Payout
+	err := r.DB.Where("merchant_id = ? AND status = ?", merchantID, model.PayoutPending).
+		Order("created_at").
+		Limit(limit).
+		Find(&payouts).Error
+	return payouts, err
+}
+
+// ListByBatch returns the payouts settled by a batch, oldest first
+func (r *PayoutRepository) ListByBatch(batchID string) ([]model.Payout, error) {
+	var payouts []model.Payout
+	err := r.DB.Where("batch_id = ?", batchID).Order("created_at").Find(&payouts).Error
+	return payouts, err
+}
+
+// errNothingClaimed rolls back a batch whose payouts were all claimed by another run
+var errNothingClaimed = errors.New("no pending payouts to claim")
+
+// CreateBatch creates the batch and moves into it those of the payouts that
+// are still pending, returning them. Payouts another run claimed first are
+// left out; if that is all of them, no batch is created.
+func (r *PayoutRepository) CreateBatch(b *model.PayoutBatch, payoutIDs []uuid.UUID) ([]model.Payout, error) {
+	var claimed []model.Payout
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(b).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&model.Payout{}).
+			Where("id IN ? AND status = ?", payoutIDs, model.PayoutPending).
+			Updates(map[string]interface{}{"status": model.PayoutBatched, "batch_id": b.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNothingClaimed
+		}
+		return tx.Where("batch_id = ?", b.ID).Order("created_at").Find(&claimed).Error
+	})
+	if errors.Is(err, errNothingClaimed) {
+		return nil, nil
+	}
+	return claimed, err
+}
+
+// SaveBatch saves the batch and its payouts together
+func (r *PayoutRepository) SaveBatch(b *model.PayoutBatch, payouts []model.Payout) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(b).Error; err != nil {
+			return err
+		}
+		for i := range payouts {
+			if err := tx.Save(&payouts[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PayoutRepository) GetBatch(id string) (*model.PayoutBatch, error) {
+	var b model.PayoutBatch
+	if err := r.DB.Where("id = ?", id).First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// ListBatches returns a merchant's batches, newest first
+func (r *PayoutRepository) ListBatches(merchantID string, limit int) ([]model.PayoutBatch, error) {
+	var batches []model.PayoutBatch
+	err := r.DB.Where("merchant_id = ?", merchantID).Order("created_at DESC").Limit(limit).Find(&batches).Error
+	return batches, err
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type RefundRepository struct {
+	DB *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) *RefundRepository {
+	return &RefundRepository{DB: db}
+}
+
+func (r *RefundRepository) GetPayment(id string) (*model.Payment, error) {
+	var p model.Payment
+	if err := r.DB.Where("id = ?", id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ReserveRefund adds the refund amount to the payment's refunded amount and
+// creates the refund in one transaction. It reports false if the payment is
+// not completed or the refunds would exceed the payment amount, so concurrent
+// refunds cannot return more than was paid.
+func (r *RefundRepository) ReserveRefund(refund *model.Refund) (bool, error) {
+	reserved := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Payment{}).
+			Where("id = ? AND status = ? AND refunded_amount + ? <= amount", refund.PaymentID, model.StatusCompleted, refund.Amount).
+			Update("refunded_amount", gorm.Expr("refunded_amount + ?", refund.Amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return nil
+		}
+		reserved = true
+		return tx.Create(refund).Error
+	})
+	return reserved, err
+}
+
+// CompleteRefund records the reversing ledger entry of a pending refund
+func (r *RefundRepository) CompleteRefund(refund *model.Refund) error {
+	return r.DB.Model(refund).Where("status = ?", model.RefundPending).Updates(map[string]interface{}{
+		"status":          model.RefundCompleted,
+		"ledger_entry_id": refund.LedgerEntryID,
+	}).Error
+}
+
+// FailRefund marks a pending refund failed and releases its amount on the payment
+func (r *RefundRepository) FailRefund(refund *model.Refund) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(refund).Where("status = ?", model.RefundPending).Updates(map[string]interface{}{
+			"status":         model.RefundFailed,
+			"failure_reason": refund.FailureReason,
+		})
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		return tx.Model(&model.Payment{}).Where("id = ?", refund.PaymentID).
+			Update("refunded_amount", gorm.Expr("refunded_amount - ?", refund.Amount)).Error
+	})
+}
+
+// ListByPayment returns a payment's refunds, oldest first
+func (r *RefundRepository) ListByPayment(paymentID string) ([]model.Refund, error) {
+	var refunds []model.Refund
+	err := r.DB.Where("payment_id = ?", paymentID).Order("created_at").Find(&refunds).Error
+	return refunds, err
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// lookupChunkSize bounds the IDs passed in one IN list, well under Postgres'
+// limit on bind parameters
+const lookupChunkSize = 1000
+
+type SettlementRepository struct {
+	DB *gorm.DB
+}
+
+func NewSettlementRepository(db *gorm.DB) *SettlementRepository {
+	return &SettlementRepository{DB: db}
+}
+
+// CreateReport saves a reconciled report with its exceptions. It returns
+// gorm.ErrDuplicatedKey if the connector's report for that day was already
+// ingested.
+func (r *SettlementRepository) CreateReport(report *model.SettlementReport, exceptions []model.SettlementException) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(report).Error; err != nil {
+			return err
+		}
+		if len(exceptions) == 0 {
+			return nil
+		}
+		for i := range exceptions {
+			exceptions[i].ReportID = report.ID
+		}
+		return tx.CreateInBatches(exceptions, 500).Error
+	})
+	if err != nil && (errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "23505")) {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
+}
+
+func (r *SettlementRepository) GetReport(id string) (*model.SettlementReport, error) {
+	var report model.SettlementReport
+	if err := r.DB.Where("id = ?", id).First(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListReports returns the reports of a connector, or of all connectors if it
+// is empty, latest day first
+func (r *SettlementRepository) ListReports(connector string, limit int) ([]model.SettlementReport, error) {
+	var reports []model.SettlementReport
+	query := r.DB.Order("report_date DESC, connector").Limit(limit)
+	if connector != "" {
+		query = query.Where("connector = ?", connector)
+	}
+	err := query.Find(&reports).Error
+	return reports, err
+}
+
+// ListExceptions returns exceptions, oldest first, filtered by whichever of
+// status, type and report ID are set
+func (r *SettlementRepository) ListExceptions(status model.SettlementExceptionStatus, exceptionType model.SettlementExceptionType, reportID string, limit int) ([]model.SettlementException, error) {
+	var exceptions []model.SettlementException
+	query := r.DB.Order("created_at, id").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if exceptionType != "" {
+		query = query.Where("type = ?", exceptionType)
+	}
+	if reportID != "" {
+		query = query.Where("report_id = ?", reportID)
+	}
+	err := query.Find(&exceptions).Error
+	return exceptions, err
+}
+
+func (r *SettlementRepository) GetException(id string) (*model.SettlementException, error) {
+	var exception model.SettlementException
+	if err := r.DB.Where("id = ?", id).First(&exception).Error; err != nil {
+		return nil, err
+	}
+	return &exception, nil
+}
+
+// UpdateExceptionIfStatus saves the exception only if its stored status is
+// still expected. It reports false when another operator resolved it first.
+func (r *SettlementRepository) UpdateExceptionIfStatus(e *model.SettlementException, expected model.SettlementExceptionStatus) (bool, error) {
+	result := r.DB.Model(e).Where("status = ?", expected).Select("*").Omit("created_at").Updates(e)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ListTransfersByExternalIDs returns the connector's transfers with the given external IDs
+func (r *SettlementRepository) ListTransfersByExternalIDs(connector string, externalIDs []string) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	for chunk := range slices.Chunk(externalIDs, lookupChunkSize) {
+		var found []model.ExternalTransfer
+		if err := r.DB.Where("connector = ? AND external_id IN ?", connector, chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, found...)
+	}
+	return transfers, nil
+}
+
+// ListTransfersByIDs returns the connector's transfers with the given IDs
+func (r *SettlementRepository) ListTransfersByIDs(connector string, ids []uuid.UUID) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	for chunk := range slices.Chunk(ids, lookupChunkSize) {
+		var found []model.ExternalTransfer
+		if err := r.DB.Where("connector = ? AND id IN ?", connector, chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, found...)
+	}
+	return transfers, nil
+}
+
+// ListSettledTransfers returns the connector's transfers settled in [from, to)
+func (r *SettlementRepository) ListSettledTransfers(connector string, from, to time.Time) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	err := r.DB.Where("connector = ? AND status = ? AND settled_at >= ? AND settled_at < ?", connector, model.ExternalTransferSettled, from, to).
+		Order("settled_at").Find(&transfers).Error
+	return transfers, err
+}
+
+// ListCreditsByExternalIDs returns the connector's incoming credits with the given external IDs
+func (r *SettlementRepository) ListCreditsByExternalIDs(connector string, externalIDs []string) ([]model.IncomingCredit, error) {
+	var credits []model.IncomingCredit
+	for chunk := range slices.Chunk(externalIDs, lookupChunkSize) {
+		var found []model.IncomingCredit
+		if err := r.DB.Where("connector = ? AND external_id IN ?", connector, chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		credits = append(credits, found...)
+	}
+	return credits, nil
+}
+
+// ListCreditsReceived returns the connector's incoming credits received in [from, to)
+func (r *SettlementRepository) ListCreditsReceived(connector string, from, to time.Time) ([]model.IncomingCredit, error) {
+	var credits []model.IncomingCredit
+	err := r.DB.Where("connector = ? AND created_at >= ? AND created_at < ?", connector, from, to).
+		Order("created_at").Find(&credits).Error
+	return credits, err
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"gorm.io/gorm"
+)
+
+type TransferTimelineRepository struct {
+	DB *gorm.DB
+}
+
+func NewTransferTimelineRepository(db *gorm.DB) *TransferTimelineRepository {
+	return &TransferTimelineRepository{DB: db}
+}
+
+func (r *TransferTimelineRepository) RecordTransition(t *model.TransferTransition) error {
+	return r.DB.Create(t).Error
+}
+
+// ListTransitions returns a payment's timeline, oldest first
+func (r *TransferTimelineRepository) ListTransitions(paymentID string) ([]model.TransferTransition, error) {
+	var transitions []model.TransferTransition
+	err := r.DB.Where("payment_id = ?", paymentID).Order("created_at").Find(&transitions).Error
+	return transitions, err
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+var (
+	ErrInvalidDestination = errors.New("destination must be one of to_account_id, to_iban, or to_sort_code with to_account_number")
+	ErrAliasNotFound      = errors.New("no account has this IBAN or sort code and account number")
+)
+
+// Destination is where a transfer goes: an internal account ID, or an alias
+// the ledger resolves to one
+type Destination struct {
+	AccountID     string
+	IBAN          string
+	SortCode      string
+	AccountNumber string
+}
+
+// ResolveDestination returns the internal account ID of a transfer destination
+func (s *PaymentService) ResolveDestination(ctx context.Context, d Destination) (string, error) {
+	hasIBAN := d.IBAN != ""
+	hasDomestic := d.SortCode != "" || d.AccountNumber != ""
+	switch {
+	case d.AccountID != "" && !hasIBAN && !hasDomestic:
+		return d.AccountID, nil
+	case d.AccountID == "" && hasIBAN && !hasDomestic:
+		return s.resolveAlias(ctx, url.Values{"iban": {d.IBAN}})
+	case d.AccountID == "" && !hasIBAN && d.SortCode != "" && d.AccountNumber != "":
+		return s.resolveAlias(ctx, url.Values{"sort_code": {d.SortCode}, "account_number": {d.AccountNumber}})
+	default:
+		return "", ErrInvalidDestination
+	}
+}
+
+// resolveAlias asks the ledger for the account with an IBAN or sort code and account number
+func (s *PaymentService) resolveAlias(ctx context.Context, query url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.ledgerURL+"/api/v1/account-aliases/resolve?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.ledger.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("resolving account alias: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", ErrAliasNotFound
+	case http.StatusBadRequest:
+		return "", ErrInvalidDestination
+	default:
+		return "", fmt.Errorf("resolving account alias: ledger returned status %d", resp.StatusCode)
+	}
+
+	var resolved struct {
+		AccountID string `json:"account_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&resolved); err != nil {
+		return "", fmt.Errorf("resolving account alias: %w", err)
+	}
+	return resolved.AccountID, nil
+}
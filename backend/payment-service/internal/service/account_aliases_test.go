@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDestination(t *testing.T) {
+	ledger := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case r.URL.Path != "/api/v1/account-aliases/resolve":
+			w.WriteHeader(http.StatusNotFound)
+		case q.Get("iban") == "GB86NEOB04007500000018",
+			q.Get("sort_code") == "040075" && q.Get("account_number") == "00000018":
+			_ = json.NewEncoder(w).Encode(map[string]string{"account_id": "acct-18"})
+		case q.Get("iban") == "GB00BAD":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ledger.Close()
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	ctx := context.Background()
+
+	id, err := svc.ResolveDestination(ctx, Destination{AccountID: "acct-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "acct-1", id)
+
+	id, err = svc.ResolveDestination(ctx, Destination{IBAN: "GB86NEOB04007500000018"})
+	require.NoError(t, err)
+	assert.Equal(t, "acct-18", id)
+
+	id, err = svc.ResolveDestination(ctx, Destination{SortCode: "040075", AccountNumber: "00000018"})
+	require.NoError(t, err)
+	assert.Equal(t, "acct-18", id)
+
+	_, err = svc.ResolveDestination(ctx, Destination{IBAN: "GB26NEOB04007500000026"})
+	assert.ErrorIs(t, err, ErrAliasNotFound)
+	_, err = svc.ResolveDestination(ctx, Destination{IBAN: "GB00BAD"})
+	assert.ErrorIs(t, err, ErrInvalidDestination)
+
+	for name, d := range map[string]Destination{
+		"nothing":            {},
+		"ID and IBAN":        {AccountID: "acct-1", IBAN: "GB86NEOB04007500000018"},
+		"sort code only":     {SortCode: "040075"},
+		"IBAN and sort code": {IBAN: "GB86NEOB04007500000018", SortCode: "040075", AccountNumber: "00000018"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.ResolveDestination(ctx, d)
+			assert.ErrorIs(t, err, ErrInvalidDestination)
+		})
+	}
+}
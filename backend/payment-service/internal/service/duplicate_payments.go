@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultDuplicateWindow is how long a transfer blocks an identical one
+const DefaultDuplicateWindow = 10 * time.Minute
+
+// DuplicateConfirmationTTL is how long a confirmation token can be replayed
+const DuplicateConfirmationTTL = 5 * time.Minute
+
+var (
+	ErrDuplicatePayment             = errors.New("possible duplicate payment")
+	ErrInvalidDuplicateConfirmation = errors.New("confirmation token is invalid, expired, or for a different transfer")
+)
+
+// DuplicatePaymentError reports that an identical transfer was made within the
+// window. Replaying the transfer with ConfirmationToken makes it anyway. It
+// matches ErrDuplicatePayment with errors.Is.
+type DuplicatePaymentError struct {
+	ConfirmationToken string    `json:"confirmation_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+	WindowSeconds     int64     `json:"window_seconds"`
+}
+
+func (e *DuplicatePaymentError) Error() string {
+	return fmt.Sprintf("%s: an identical transfer was made in the last %s; resend it with the confirmation token to make it again",
+		ErrDuplicatePayment, time.Duration(e.WindowSeconds)*time.Second)
+}
+
+func (e *DuplicatePaymentError) Unwrap() error {
+	return ErrDuplicatePayment
+}
+
+// DuplicateStore remembers recent transfers and issued confirmation tokens.
+// Claim must set the key only if it is absent so two concurrent identical
+// transfers cannot both get through.
+type DuplicateStore interface {
+	// Claim records key for ttl and reports whether it was not already there
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	Release(ctx context.Context, key string) error
+	SaveConfirmation(ctx context.Context, token, key string, ttl time.Duration) error
+	// TakeConfirmation returns and deletes the key a token confirms, or "" if
+	// the token is unknown or expired
+	TakeConfirmation(ctx context.Context, token string) (string, error)
+}
+
+// DuplicateGuard catches a user accidentally sending the same transfer twice:
+// same accounts, amount, currency and description within the window. A
+// different description is a distinct reference and is not a duplicate.
+type DuplicateGuard struct {
+	store  DuplicateStore
+	window time.Duration
+	now    func() time.Time
+}
+
+func NewDuplicateGuard(store DuplicateStore, window time.Duration) *DuplicateGuard {
+	return &DuplicateGuard{store: store, window: window, now: time.Now}
+}
+
+// DuplicateClaim is a transfer recorded against the window. Release it if the
+// transfer is not made, so retrying after a failure is not blocked.
+type DuplicateClaim struct {
+	key string
+}
+
+// Check records the transfer, or returns a *DuplicatePaymentError when an
+// identical one is already recorded. A confirmation token from an earlier
+// DuplicatePaymentError lets the transfer through once. Duplicate detection is
+// a safeguard, not a control: if the store is unavailable the transfer is
+// allowed and Check returns a nil claim.
+func (g *DuplicateGuard) Check(ctx context.Context, userID, fromAcc, toAcc string, amount decimal.Decimal, currency, reference, confirmation string) (*DuplicateClaim, error) {
+	key := duplicateKey(userID, fromAcc, toAcc, amount, currency, reference)
+
+	if confirmation != "" {
+		confirmed, err := g.store.TakeConfirmation(ctx, confirmation)
+		if err != nil {
+			slog.Warn("Duplicate payment confirmation unavailable, allowing transfer", "user_id", userID, "error", err)
+			return nil, nil
+		}
+		if confirmed != key {
+			return nil, ErrInvalidDuplicateConfirmation
+		}
+		// The earlier transfer's claim still covers the window
+		return nil, nil
+	}
+
+	claimed, err := g.store.Claim(ctx, key, g.window)
+	if err != nil {
+		slog.Warn("Duplicate payment check unavailable, allowing transfer", "user_id", userID, "error", err)
+		return nil, nil
+	}
+	if claimed {
+		return &DuplicateClaim{key: key}, nil
+	}
+
+	token, err := newDuplicateConfirmationToken()
+	if err == nil {
+		err = g.store.SaveConfirmation(ctx, token, key, DuplicateConfirmationTTL)
+	}
+	if err != nil {
+		slog.Warn("Failed to issue duplicate payment confirmation, allowing transfer", "user_id", userID, "error", err)
+		return nil, nil
+	}
+	return nil, &DuplicatePaymentError{
+		ConfirmationToken: token,
+		ExpiresAt:         g.now().Add(DuplicateConfirmationTTL).UTC(),
+		WindowSeconds:     int64(g.window / time.Second),
+	}
+}
+
+// Release forgets a claimed transfer that was not made
+func (g *DuplicateGuard) Release(ctx context.Context, c *DuplicateClaim) error {
+	if c == nil {
+		return nil
+	}
+	return g.store.Release(ctx, c.key)
+}
+
+// duplicateKey fingerprints a transfer. The amount is normalised so 100 and
+// 100.00 match, and the reference is compared without case or outer spaces.
+func duplicateKey(userID, fromAcc, toAcc string, amount decimal.Decimal, currency, reference string) string {
+	h := sha256.New()
+	for _, part := range []string{
+		userID, fromAcc, toAcc, amount.String(),
+		strings.ToUpper(currency), strings.ToLower(strings.TrimSpace(reference)),
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return "duplicate_payments:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func newDuplicateConfirmationToken() (string, error) {
+	raw := make([]byte, 15)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "DPC-" + base32.StdEncoding.EncodeToString(raw), nil
+}
+
+// RedisDuplicateStore keeps recent transfer fingerprints and confirmation
+// tokens in Redis under expiring keys
+type RedisDuplicateStore struct {
+	client LinkRedis
+}
+
+func NewRedisDuplicateStore(client LinkRedis) *RedisDuplicateStore {
+	return &RedisDuplicateStore{client: client}
+}
+
+func (s *RedisDuplicateStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, key, "1", ttl)
+}
+
+func (s *RedisDuplicateStore) Release(ctx context.Context, key string) error {
+	return s.client.Delete(ctx, key)
+}
+
+func (s *RedisDuplicateStore) SaveConfirmation(ctx context.Context, token, key string, ttl time.Duration) error {
+	stored, err := s.client.SetNX(ctx, duplicateConfirmationKey(token), key, ttl)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return fmt.Errorf("confirmation token %s already in use", token)
+	}
+	return nil
+}
+
+func (s *RedisDuplicateStore) TakeConfirmation(ctx context.Context, token string) (string, error) {
+	res, err := s.client.Eval(ctx, takeScript, []string{duplicateConfirmationKey(token)})
+	if err != nil {
+		return "", err
+	}
+	key, _ := res.(string)
+	return key, nil
+}
+
+func duplicateConfirmationKey(token string) string {
+	return "duplicate_payments:confirm:" + token
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryDuplicateStore is an in-memory DuplicateStore without expiry
+type memoryDuplicateStore struct {
+	claims        map[string]bool
+	confirmations map[string]string
+	err           error
+}
+
+func newMemoryDuplicateStore() *memoryDuplicateStore {
+	return &memoryDuplicateStore{claims: map[string]bool{}, confirmations: map[string]string{}}
+}
+
+func (s *memoryDuplicateStore) Claim(_ context.Context, key string, _ time.Duration) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	if s.claims[key] {
+		return false, nil
+	}
+	s.claims[key] = true
+	return true, nil
+}
+
+func (s *memoryDuplicateStore) Release(_ context.Context, key string) error {
+	delete(s.claims, key)
+	return nil
+}
+
+func (s *memoryDuplicateStore) SaveConfirmation(_ context.Context, token, key string, _ time.Duration) error {
+	s.confirmations[token] = key
+	return nil
+}
+
+func (s *memoryDuplicateStore) TakeConfirmation(_ context.Context, token string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	key := s.confirmations[token]
+	delete(s.confirmations, token)
+	return key, nil
+}
+
+func TestDuplicateGuard_Check(t *testing.T) {
+	ctx := context.Background()
+	guard := NewDuplicateGuard(newMemoryDuplicateStore(), DefaultDuplicateWindow)
+	amount := decimal.RequireFromString("100")
+
+	claim, err := guard.Check(ctx, "user", "from", "to", amount, "USD", "Rent", "")
+	require.NoError(t, err)
+	require.NotNil(t, claim)
+
+	// The same transfer, written differently, is a duplicate
+	_, err = guard.Check(ctx, "user", "from", "to", decimal.RequireFromString("100.00"), "usd", " rent ", "")
+	var dupErr *DuplicatePaymentError
+	require.ErrorAs(t, err, &dupErr)
+	assert.ErrorIs(t, err, ErrDuplicatePayment)
+	assert.Equal(t, int64(600), dupErr.WindowSeconds)
+	assert.NotEmpty(t, dupErr.ConfirmationToken)
+
+	// A distinct reference, amount, beneficiary or user is not
+	for name, check := range map[string][6]string{
+		"reference":   {"user", "from", "to", "100", "USD", "Rent March"},
+		"amount":      {"user", "from", "to", "100.01", "USD", "Rent"},
+		"beneficiary": {"user", "from", "other", "100", "USD", "Rent"},
+		"user":        {"other", "from", "to", "100", "USD", "Rent"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := guard.Check(ctx, check[0], check[1], check[2], decimal.RequireFromString(check[3]), check[4], check[5], "")
+			assert.NoError(t, err)
+		})
+	}
+
+	// The token only confirms the transfer it was issued for, and only once
+	_, err = guard.Check(ctx, "user", "from", "other", amount, "USD", "Rent", dupErr.ConfirmationToken)
+	assert.ErrorIs(t, err, ErrInvalidDuplicateConfirmation)
+	_, err = guard.Check(ctx, "user", "from", "to", amount, "USD", "Rent", dupErr.ConfirmationToken)
+	assert.ErrorIs(t, err, ErrInvalidDuplicateConfirmation)
+
+	_, err = guard.Check(ctx, "user", "from", "to", amount, "USD", "Rent", "")
+	require.ErrorAs(t, err, &dupErr)
+	claim, err = guard.Check(ctx, "user", "from", "to", amount, "USD", "Rent", dupErr.ConfirmationToken)
+	require.NoError(t, err)
+	assert.Nil(t, claim)
+	_, err = guard.Check(ctx, "user", "from", "to", amount, "USD", "Rent", dupErr.ConfirmationToken)
+	assert.ErrorIs(t, err, ErrInvalidDuplicateConfirmation)
+}
+
+func TestDuplicateGuard_StoreUnavailable(t *testing.T) {
+	store := newMemoryDuplicateStore()
+	store.err = errors.New("connection refused")
+	guard := NewDuplicateGuard(store, DefaultDuplicateWindow)
+
+	// Detection fails open rather than blocking payments
+	claim, err := guard.Check(context.Background(), "user", "from", "to", decimal.NewFromInt(10), "USD", "", "")
+	assert.NoError(t, err)
+	assert.Nil(t, claim)
+	assert.NoError(t, guard.Release(context.Background(), claim))
+}
+
+func TestInitiateUserTransfer_Duplicates(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryDuplicateStore()
+	svc := &PaymentService{}
+	svc.SetDuplicateGuard(NewDuplicateGuard(store, DefaultDuplicateWindow))
+	accountID := uuid.New().String()
+
+	// A failed transfer is forgotten so it can be retried
+	_, err := svc.InitiateUserTransfer(ctx, "user", accountID, accountID, "100", "USD", "test", "", "")
+	assert.Contains(t, err.Error(), "cannot transfer to the same account")
+	assert.Empty(t, store.claims)
+
+	// A transfer rejected by the limits is forgotten too
+	svc.SetTransferLimiter(NewTransferLimiter(newMemoryLimitStore(), testLimits()))
+	_, err = svc.InitiateUserTransfer(ctx, "user", accountID, uuid.New().String(), "501", "USD", "test", "", "")
+	requireLimitError(t, err, LimitMaxPerTransaction, "500")
+	assert.Empty(t, store.claims)
+
+	// A recent identical transfer is reported before the limits are touched
+	toAccountID := uuid.New().String()
+	key := duplicateKey("user", accountID, toAccountID, decimal.NewFromInt(100), "USD", "test")
+	store.claims[key] = true
+	_, err = svc.InitiateUserTransfer(ctx, "user", accountID, toAccountID, "100", "USD", "test", "", "")
+	assert.ErrorIs(t, err, ErrDuplicatePayment)
+	usage, err := svc.TransferLimitUsage(ctx, "user", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage.DailyCount.Used)
+}
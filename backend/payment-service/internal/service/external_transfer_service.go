@@ -0,0 +1,392 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/bankdirectory"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// MaxExternalTransferAttempts is how many times a transfer is submitted before it fails and is refunded
+	MaxExternalTransferAttempts = 5
+	// ExternalTransferRetryBase is the delay before the first retry; it doubles on each attempt
+	ExternalTransferRetryBase = 30 * time.Second
+	// ExternalTransferApprovalCheck is how often a transfer awaiting approval checks its debit
+	ExternalTransferApprovalCheck = time.Minute
+
+	externalTransferBatchSize = 100
+	connectorTimeout          = 15 * time.Second
+)
+
+var (
+	ErrExternalTransfersDisabled = errors.New("external transfers are not configured")
+	ErrExternalTransferNotFound  = errors.New("external transfer not found")
+	ErrConnectorNotFound         = errors.New("unknown connector")
+	ErrInvalidExternalTransfer   = errors.New("invalid external transfer")
+)
+
+var isoCurrencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ExternalTransferRepository defines data access for external transfers
+type ExternalTransferRepository interface {
+	Create(t *model.ExternalTransfer) error
+	GetByID(id string) (*model.ExternalTransfer, error)
+	GetByExternalID(connector, externalID string) (*model.ExternalTransfer, error)
+	ListDue(now time.Time, limit int) ([]model.ExternalTransfer, error)
+	UpdateIfStatus(t *model.ExternalTransfer, expected model.ExternalTransferStatus) (bool, error)
+}
+
+// ExternalTransferRequest is a transfer from a user's account to another bank
+type ExternalTransferRequest struct {
+	FromAccountID string
+	Amount        string
+	Currency      string
+	Destination   connectors.Destination
+	Reference     string
+}
+
+// ExternalTransferService sends transfers to other banks through connectors.
+// Funds move from the user's account to the settlement account before the
+// transfer is submitted, and back again if the connector rejects it. A debit
+// from a business account that waits for approvals holds the transfer back
+// until it is approved.
+type ExternalTransferService struct {
+	Repo                ExternalTransferRepository
+	Transfers           TransferInitiator
+	Connectors          *connectors.Registry
+	SettlementAccountID string
+	// Payments reads the debits of transfers awaiting approval
+	Payments PaymentLookup
+
+	// Banks, when set, checks destination sort codes against the bank directory
+	Banks *bankdirectory.Directory
+}
+
+func NewExternalTransferService(repo ExternalTransferRepository, transfers TransferInitiator, registry *connectors.Registry, settlementAccountID string) *ExternalTransferService {
+	return &ExternalTransferService{
+		Repo:                repo,
+		Transfers:           transfers,
+		Connectors:          registry,
+		SettlementAccountID: settlementAccountID,
+	}
+}
+
+// CreateExternalTransfer debits the user's account and submits the transfer to
+// the connector for the destination's scheme. A transient connector failure
+// leaves the transfer PENDING for the retry worker.
+func (s *ExternalTransferService) CreateExternalTransfer(ctx context.Context, userID string, req ExternalTransferRequest) (*model.ExternalTransfer, error) {
+	if s.SettlementAccountID == "" {
+		return nil, ErrExternalTransfersDisabled
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	fromUUID, err := uuid.Parse(req.FromAccountID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid from account id", ErrInvalidExternalTransfer)
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidExternalTransfer)
+	}
+	if !isoCurrencyPattern.MatchString(req.Currency) {
+		return nil, fmt.Errorf("%w: currency must be a 3-letter ISO code", ErrInvalidExternalTransfer)
+	}
+
+	destination := req.Destination.Normalize()
+	if destination.Name == "" {
+		return nil, fmt.Errorf("%w: creditor name is required", ErrInvalidExternalTransfer)
+	}
+	scheme, err := destination.Scheme()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBank(ctx, destination); err != nil {
+		return nil, err
+	}
+	connector, err := s.Connectors.Route(scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	debit, err := s.Transfers.InitiateTransferFor(userID, req.FromAccountID, s.SettlementAccountID, req.Amount, req.Currency, externalTransferDescription(destination, req.Reference))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	transfer := &model.ExternalTransfer{
+		UserID:         userUUID,
+		FromAccountID:  fromUUID,
+		Amount:         amount,
+		Currency:       req.Currency,
+		Scheme:         string(scheme),
+		CreditorName:   destination.Name,
+		IBAN:           destination.IBAN,
+		SortCode:       destination.SortCode,
+		AccountNumber:  destination.AccountNumber,
+		Reference:      req.Reference,
+		Connector:      connector.Name(),
+		Status:         model.ExternalTransferPending,
+		NextAttemptAt:  &now,
+		DebitPaymentID: &debit.ID,
+	}
+	if debit.Status == model.StatusAwaitingApproval {
+		// Nothing is sent until the debit is approved; see checkApproval
+		check := now.Add(ExternalTransferApprovalCheck)
+		transfer.Status = model.ExternalTransferAwaitingApproval
+		transfer.NextAttemptAt = &check
+	}
+	if err := s.Repo.Create(transfer); err != nil {
+		return nil, err
+	}
+	if transfer.Status == model.ExternalTransferAwaitingApproval {
+		return transfer, nil
+	}
+
+	if err := s.submit(ctx, transfer); err != nil {
+		// The transfer is recorded and the retry worker will pick it up
+		slog.Error("Failed to record external transfer submission", "transfer_id", transfer.ID, "error", err)
+	}
+	return transfer, nil
+}
+
+// checkBank looks up the destination's sort code in the bank directory, so a
+// mistyped one fails before any money moves. GB IBANs carry the sort code after
+// the 4-letter bank code. A directory that cannot be reached does not block the
+// transfer; the connector still rejects accounts it cannot reach.
+func (s *ExternalTransferService) checkBank(ctx context.Context, destination connectors.Destination) error {
+	if s.Banks == nil {
+		return nil
+	}
+	sortCode := destination.SortCode
+	if strings.HasPrefix(destination.IBAN, "GB") && len(destination.IBAN) == 22 {
+		sortCode = destination.IBAN[8:14]
+	}
+	if sortCode == "" {
+		return nil
+	}
+	_, err := s.Banks.Lookup(ctx, bankdirectory.KindSortCode, sortCode)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, bankdirectory.ErrInvalidCode), errors.Is(err, bankdirectory.ErrBankNotFound):
+		return fmt.Errorf("%w: %v", ErrInvalidExternalTransfer, err)
+	default:
+		slog.Warn("Bank directory lookup failed, sending the transfer unchecked", "error", err)
+		return nil
+	}
+}
+
+// GetExternalTransfer returns a transfer created by the user
+func (s *ExternalTransferService) GetExternalTransfer(userID, id string) (*model.ExternalTransfer, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrExternalTransferNotFound
+	}
+	transfer, err := s.Repo.GetByID(id)
+	if err != nil || transfer.UserID.String() != userID {
+		return nil, ErrExternalTransferNotFound
+	}
+	return transfer, nil
+}
+
+// ProcessDue resubmits pending transfers whose retry is due, checks the
+// approvals of those awaiting them, and returns how many were attempted
+func (s *ExternalTransferService) ProcessDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.Repo.ListDue(now, externalTransferBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	for i := range due {
+		if due[i].Status == model.ExternalTransferAwaitingApproval {
+			if err := s.checkApproval(ctx, &due[i]); err != nil {
+				slog.Error("Failed to check external transfer approval", "transfer_id", due[i].ID, "error", err)
+			}
+			continue
+		}
+		if err := s.submit(ctx, &due[i]); err != nil {
+			slog.Error("Failed to resubmit external transfer", "transfer_id", due[i].ID, "error", err)
+		}
+	}
+	return len(due), nil
+}
+
+// checkApproval submits a transfer awaiting approval once its debit is
+// approved, rejects it if the debit was rejected, expired or failed, and
+// otherwise checks again later. A debit that was never approved moved no
+// money, so nothing is refunded.
+func (s *ExternalTransferService) checkApproval(ctx context.Context, t *model.ExternalTransfer) error {
+	if s.Payments == nil || t.DebitPaymentID == nil {
+		return errors.New("the transfer's debit cannot be checked")
+	}
+	debit, err := s.Payments.GetPayment(t.DebitPaymentID.String())
+	if err != nil {
+		return err
+	}
+
+	switch debit.Status {
+	case model.StatusAwaitingApproval:
+		next := time.Now().Add(ExternalTransferApprovalCheck)
+		t.NextAttemptAt = &next
+		_, err = s.Repo.UpdateIfStatus(t, model.ExternalTransferAwaitingApproval)
+		return err
+	case model.StatusPending, model.StatusCompleted:
+		now := time.Now()
+		t.Status = model.ExternalTransferPending
+		t.NextAttemptAt = &now
+		claimed, err := s.Repo.UpdateIfStatus(t, model.ExternalTransferAwaitingApproval)
+		if err != nil || !claimed {
+			return err
+		}
+		return s.submit(ctx, t)
+	default:
+		t.Status = model.ExternalTransferRejected
+		t.NextAttemptAt = nil
+		t.LastError = "the debit was " + strings.ToLower(string(debit.Status))
+		_, err = s.Repo.UpdateIfStatus(t, model.ExternalTransferAwaitingApproval)
+		return err
+	}
+}
+
+// RetryJob resubmits due transfers
+func (s *ExternalTransferService) RetryJob(ctx context.Context, _ *jobs.Job) error {
+	n, err := s.ProcessDue(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Resubmitted external transfers", "count", n)
+	}
+	return nil
+}
+
+// HandleWebhook applies a status update delivered by a connector's webhook.
+// Updates for transfers that are already settled or refunded are ignored.
+func (s *ExternalTransferService) HandleWebhook(connectorName string, header http.Header, body []byte) error {
+	connector, ok := s.Connectors.Get(connectorName)
+	if !ok {
+		return ErrConnectorNotFound
+	}
+	update, err := connector.ParseWebhook(header, body)
+	if err != nil {
+		return err
+	}
+
+	transfer, err := s.Repo.GetByExternalID(connectorName, update.ExternalID)
+	if err != nil {
+		return ErrExternalTransferNotFound
+	}
+	if transfer.Status != model.ExternalTransferSubmitted {
+		slog.Info("Ignoring webhook for external transfer", "transfer_id", transfer.ID, "status", transfer.Status, "update", update.Status)
+		return nil
+	}
+
+	switch update.Status {
+	case connectors.StatusSettled:
+		return s.settle(transfer, model.ExternalTransferSubmitted)
+	case connectors.StatusRejected:
+		return s.refund(transfer, model.ExternalTransferSubmitted, model.ExternalTransferRejected, update.Reason)
+	}
+	return nil
+}
+
+// submit sends a pending transfer to its connector and records the outcome
+func (s *ExternalTransferService) submit(ctx context.Context, t *model.ExternalTransfer) error {
+	connector, ok := s.Connectors.Get(t.Connector)
+	if !ok {
+		return s.refund(t, model.ExternalTransferPending, model.ExternalTransferFailed, ErrConnectorNotFound.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, connectorTimeout)
+	defer cancel()
+
+	t.Attempts++
+	submission, err := connector.Submit(ctx, connectors.PaymentInstruction{
+		ID:        t.ID.String(),
+		Amount:    t.Amount,
+		Currency:  t.Currency,
+		Scheme:    connectors.Scheme(t.Scheme),
+		Reference: t.Reference,
+		Destination: connectors.Destination{
+			Name:          t.CreditorName,
+			IBAN:          t.IBAN,
+			SortCode:      t.SortCode,
+			AccountNumber: t.AccountNumber,
+		},
+	})
+
+	switch {
+	case connectors.IsRetryable(err) && t.Attempts < MaxExternalTransferAttempts:
+		next := time.Now().Add(ExternalTransferRetryBase << (t.Attempts - 1))
+		t.NextAttemptAt = &next
+		t.LastError = err.Error()
+		slog.Warn("External transfer submission failed, will retry", "transfer_id", t.ID, "attempt", t.Attempts, "next_attempt_at", next, "error", err)
+		_, saveErr := s.Repo.UpdateIfStatus(t, model.ExternalTransferPending)
+		return saveErr
+	case connectors.IsRetryable(err):
+		return s.refund(t, model.ExternalTransferPending, model.ExternalTransferFailed, err.Error())
+	case err != nil:
+		return s.refund(t, model.ExternalTransferPending, model.ExternalTransferRejected, err.Error())
+	}
+
+	t.ExternalID = &submission.ExternalID
+	t.NextAttemptAt = nil
+	t.LastError = ""
+	if submission.Status == connectors.StatusSettled {
+		return s.settle(t, model.ExternalTransferPending)
+	}
+	t.Status = model.ExternalTransferSubmitted
+	_, err = s.Repo.UpdateIfStatus(t, model.ExternalTransferPending)
+	return err
+}
+
+func (s *ExternalTransferService) settle(t *model.ExternalTransfer, from model.ExternalTransferStatus) error {
+	now := time.Now()
+	t.Status = model.ExternalTransferSettled
+	t.SettledAt = &now
+	_, err := s.Repo.UpdateIfStatus(t, from)
+	return err
+}
+
+// refund marks the transfer as rejected or failed and moves the funds back from
+// the settlement account. The status change is claimed first so a webhook and a
+// retry racing on the same transfer cannot refund it twice.
+func (s *ExternalTransferService) refund(t *model.ExternalTransfer, from, to model.ExternalTransferStatus, reason string) error {
+	t.Status = to
+	t.NextAttemptAt = nil
+	t.LastError = reason
+	claimed, err := s.Repo.UpdateIfStatus(t, from)
+	if err != nil || !claimed {
+		return err
+	}
+
+	refund, err := s.Transfers.InitiateTransfer(s.SettlementAccountID, t.FromAccountID.String(), t.Amount.String(), t.Currency, "Refund: external transfer "+t.ID.String())
+	if err != nil {
+		slog.Error("Failed to refund external transfer; manual action required", "transfer_id", t.ID, "error", err)
+		return fmt.Errorf("refund external transfer: %w", err)
+	}
+	t.RefundPaymentID = &refund.ID
+	_, err = s.Repo.UpdateIfStatus(t, to)
+	return err
+}
+
+func externalTransferDescription(d connectors.Destination, reference string) string {
+	desc := "External transfer to " + d.Name
+	if reference != "" {
+		desc += " (" + reference + ")"
+	}
+	return desc
+}
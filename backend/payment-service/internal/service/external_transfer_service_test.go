@@ -0,0 +1,420 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/bankdirectory"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockExternalTransferRepository is a mock implementation of ExternalTransferRepository
+type MockExternalTransferRepository struct {
+	mock.Mock
+}
+
+func (m *MockExternalTransferRepository) Create(t *model.ExternalTransfer) error {
+	args := m.Called(t)
+	return args.Error(0)
+}
+
+func (m *MockExternalTransferRepository) GetByID(id string) (*model.ExternalTransfer, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ExternalTransfer), args.Error(1)
+}
+
+func (m *MockExternalTransferRepository) GetByExternalID(connector, externalID string) (*model.ExternalTransfer, error) {
+	args := m.Called(connector, externalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.ExternalTransfer), args.Error(1)
+}
+
+func (m *MockExternalTransferRepository) ListDue(now time.Time, limit int) ([]model.ExternalTransfer, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).([]model.ExternalTransfer), args.Error(1)
+}
+
+func (m *MockExternalTransferRepository) UpdateIfStatus(t *model.ExternalTransfer, expected model.ExternalTransferStatus) (bool, error) {
+	args := m.Called(t, expected)
+	return args.Bool(0), args.Error(1)
+}
+
+// scriptedConnector returns the queued results in order
+type scriptedConnector struct {
+	results []error
+	status  connectors.Status
+	calls   int
+}
+
+func (s *scriptedConnector) Name() string { return "scripted" }
+
+func (s *scriptedConnector) Supports(scheme connectors.Scheme) bool {
+	return scheme == connectors.SchemeFPS
+}
+
+func (s *scriptedConnector) Submit(_ context.Context, p connectors.PaymentInstruction) (*connectors.Submission, error) {
+	s.calls++
+	if len(s.results) > 0 {
+		err := s.results[0]
+		s.results = s.results[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &connectors.Submission{ExternalID: "ext-" + p.ID, Status: s.status}, nil
+}
+
+func (s *scriptedConnector) ParseWebhook(_ http.Header, body []byte) (*connectors.StatusUpdate, error) {
+	mock := connectors.NewMockConnector("whsec")
+	return mock.ParseWebhook(mock.Sign(body), body)
+}
+
+const settlementAccount = "7b0f5a8e-0000-4000-8000-000000000001"
+
+func fpsRequest(from uuid.UUID) ExternalTransferRequest {
+	return ExternalTransferRequest{
+		FromAccountID: from.String(),
+		Amount:        "25.00",
+		Currency:      "GBP",
+		Reference:     "Rent",
+		Destination:   connectors.Destination{Name: "Jane Doe", SortCode: "20-00-00", AccountNumber: "5512 3456"},
+	}
+}
+
+func withStatus(status model.ExternalTransferStatus) interface{} {
+	return mock.MatchedBy(func(t *model.ExternalTransfer) bool { return t.Status == status })
+}
+
+func TestCreateExternalTransfer_SubmitsThroughRoutedConnector(t *testing.T) {
+	repo := new(MockExternalTransferRepository)
+	transfers := new(MockTransferInitiator)
+	connector := &scriptedConnector{status: connectors.StatusAccepted}
+	svc := NewExternalTransferService(repo, transfers, connectors.NewRegistry(connector), settlementAccount)
+
+	from := uuid.New()
+	userID := uuid.New().String()
+	debit := &model.Payment{ID: uuid.New(), Status: model.StatusPending}
+	transfers.On("InitiateTransferFor", userID, from.String(), settlementAccount, "25.00", "GBP", "External transfer to Jane Doe (Rent)").Return(debit, nil)
+	repo.On("Create", mock.AnythingOfType("*model.ExternalTransfer")).Run(func(args mock.Arguments) {
+		args.Get(0).(*model.ExternalTransfer).ID = uuid.New()
+	}).Return(nil)
+	repo.On("UpdateIfStatus", withStatus(model.ExternalTransferSubmitted), model.ExternalTransferPending).Return(true, nil)
+
+	transfer, err := svc.CreateExternalTransfer(context.Background(), userID, fpsRequest(from))
+
+	require.NoError(t, err)
+	assert.Equal(t, model.ExternalTransferSubmitted, transfer.Status)
+	assert.Equal(t, "FPS", transfer.Scheme)
+	assert.Equal(t, "200000", transfer.SortCode)
+	assert.Equal(t, "55123456", transfer.AccountNumber)
+	assert.Equal(t, "scripted", transfer.Connector)
+	assert.Equal(t, "ext-"+transfer.ID.String(), *transfer.ExternalID)
+	assert.Equal(t, debit.ID, *transfer.DebitPaymentID)
+	assert.Equal(t, 1, transfer.Attempts)
+	assert.Nil(t, transfer.NextAttemptAt)
+}
+
+func TestCreateExternalTransfer_HoldsDebitAwaitingApproval(t *testing.T) {
+	repo := new(MockExternalTransferRepository)
+	transfers := new(MockTransferInitiator)
+	connector := &scriptedConnector{status: connectors.StatusAccepted}
+	svc := NewExternalTransferService(repo, transfers, connectors.NewRegistry(connector), settlementAccount)
+
+	// A large debit from an organization's account waits for its approvers
+	from := uuid.New()
+	userID := uuid.New().String()
+	debit := &model.Payment{ID: uuid.New(), Status: model.StatusAwaitingApproval}
+	transfers.On("InitiateTransferFor", userID, from.String(), settlementAccount, "25.00", "GBP", mock.Anything).Return(debit, nil)
+	repo.On("Create", withStatus(model.ExternalTransferAwaitingApproval)).Return(nil)
+
+	transfer, err := svc.CreateExternalTransfer(context.Background(), userID, fpsRequest(from))
+
+	require.NoError(t, err)
+	assert.Equal(t, model.ExternalTransferAwaitingApproval, transfer.Status)
+	assert.Equal(t, debit.ID, *transfer.DebitPaymentID)
+	assert.WithinDuration(t, time.Now().Add(ExternalTransferApprovalCheck), *transfer.NextAttemptAt, 5*time.Second)
+	assert.Equal(t, 0, connector.calls)
+	repo.AssertNotCalled(t, "UpdateIfStatus", mock.Anything, mock.Anything)
+}
+
+func TestCreateExternalTransfer_ValidatesBeforeDebiting(t *testing.T) {
+	transfers := new(MockTransferInitiator)
+	svc := NewExternalTransferService(new(MockExternalTransferRepository), transfers, connectors.NewRegistry(&scriptedConnector{}), settlementAccount)
+	from := uuid.New()
+
+	req := fpsRequest(from)
+	req.Destination.SortCode = "12345"
+	_, err := svc.CreateExternalTransfer(context.Background(), uuid.New().String(), req)
+	assert.ErrorIs(t, err, connectors.ErrInvalidDestination)
+
+	// The only connector does not support SEPA
+	req = fpsRequest(from)
+	req.Destination = connectors.Destination{Name: "Hans", IBAN: "DE89 3704 0044 0532 0130 00"}
+	_, err = svc.CreateExternalTransfer(context.Background(), uuid.New().String(), req)
+	assert.ErrorIs(t, err, connectors.ErrUnsupportedScheme)
+
+	req = fpsRequest(from)
+	req.Amount = "-1"
+	_, err = svc.CreateExternalTransfer(context.Background(), uuid.New().String(), req)
+	assert.ErrorIs(t, err, ErrInvalidExternalTransfer)
+
+	disabled := NewExternalTransferService(new(MockExternalTransferRepository), transfers, connectors.NewRegistry(), "")
+	_, err = disabled.CreateExternalTransfer(context.Background(), uuid.New().String(), fpsRequest(from))
+	assert.ErrorIs(t, err, ErrExternalTransfersDisabled)
+
+	transfers.AssertNotCalled(t, "InitiateTransferFor", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateExternalTransfer_RejectsSortCodesNoBankUses(t *testing.T) {
+	transfers := new(MockTransferInitiator)
+	svc := NewExternalTransferService(new(MockExternalTransferRepository), transfers, connectors.NewRegistry(&scriptedConnector{}), settlementAccount)
+	svc.Banks = bankdirectory.New(bankdirectory.Bundled())
+	from := uuid.New()
+
+	req := fpsRequest(from)
+	req.Destination.SortCode = "12-34-56"
+	_, err := svc.CreateExternalTransfer(context.Background(), uuid.New().String(), req)
+	assert.ErrorIs(t, err, ErrInvalidExternalTransfer)
+	assert.Contains(t, err.Error(), "sort code 12-34-56 is not in the bank directory")
+
+	// The sort code inside a GB IBAN is checked too
+	req = fpsRequest(from)
+	req.Destination = connectors.Destination{Name: "Jane Doe", IBAN: "GB82 WEST 1234 5698 7654 32"}
+	_, err = svc.CreateExternalTransfer(context.Background(), uuid.New().String(), req)
+	assert.ErrorIs(t, err, ErrInvalidExternalTransfer)
+
+	transfers.AssertNotCalled(t, "InitiateTransferFor", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func pendingTransfer(attempts int) *model.ExternalTransfer {
+	now := time.Now()
+	return &model.ExternalTransfer{
+		ID:            uuid.New(),
+		UserID:        uuid.New(),
+		FromAccountID: uuid.New(),
+		Amount:        decimal.NewFromInt(25),
+		Currency:      "GBP",
+		Scheme:        string(connectors.SchemeFPS),
+		CreditorName:  "Jane Doe",
+		SortCode:      "200000",
+		AccountNumber: "55123456",
+		Connector:     "scripted",
+		Status:        model.ExternalTransferPending,
+		Attempts:      attempts,
+		NextAttemptAt: &now,
+	}
+}
+
+func TestProcessDue_SchedulesRetryOnTransientFailure(t *testing.T) {
+	repo := new(MockExternalTransferRepository)
+	connector := &scriptedConnector{results: []error{fmt.Errorf("%w: timeout", connectors.ErrTransient)}}
+	svc := NewExternalTransferService(repo, new(MockTransferInitiator), connectors.NewRegistry(connector), settlementAccount)
+
+	transfer := pendingTransfer(1)
+	now := time.Now()
+	repo.On("ListDue", now, externalTransferBatchSize).Return([]model.ExternalTransfer{*transfer}, nil)
+	var saved *model.ExternalTransfer
+	repo.On("UpdateIfStatus", withStatus(model.ExternalTransferPending), model.ExternalTransferPending).Run(func(args mock.Arguments) {
+		saved = args.Get(0).(*model.ExternalTransfer)
+	}).Return(true, nil)
+
+	n, err := svc.ProcessDue(context.Background(), now)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	require.NotNil(t, saved)
+	assert.Equal(t, 2, saved.Attempts)
+	assert.Contains(t, saved.LastError, "timeout")
+	// Second attempt backs off twice the base delay
+	assert.WithinDuration(t, time.Now().Add(2*ExternalTransferRetryBase), *saved.NextAttemptAt, 5*time.Second)
+}
+
+func TestProcessDue_RefundsWhenRetriesAreExhausted(t *testing.T) {
+	repo := new(MockExternalTransferRepository)
+	transfers := new(MockTransferInitiator)
+	connector := &scriptedConnector{results: []error{fmt.Errorf("%w: timeout", connectors.ErrTransient)}}
+	svc := NewExternalTransferService(repo, transfers, connectors.NewRegistry(connector), settlementAccount)
+
+	transfer := pendingTransfer(MaxExternalTransferAttempts - 1)
+	now := time.Now()
+	refund := &model.Payment{ID: uuid.New()}
+	repo.On("ListDue", now, externalTransferBatchSize).Return([]model.ExternalTransfer{*transfer}, nil)
+	repo.On("UpdateIfStatus", withStatus(model.ExternalTransferFailed), model.ExternalTransferPending).Return(true, nil).Once()
+	repo.On("UpdateIfStatus", withStatus(model.ExternalTransferFailed), model.ExternalTransferFailed).Return(true, nil).Once()
+	transfers.On("InitiateTransfer", settlementAccount, transfer.FromAccountID.String(), "25", "GBP", "Refund: external transfer "+transfer.ID.String()).Return(refund, nil)
+
+	_, err := svc.ProcessDue(context.Background(), now)
+
+	require.NoError(t, err)
+	transfers.AssertExpectations(t)
+	repo.AssertExpectations(t)
+}
+
+func TestProcessDue_ChecksApprovals(t *testing.T) {
+	awaiting := func() *model.ExternalTransfer {
+		transfer := pendingTransfer(0)
+		debitID := uuid.New()
+		transfer.Status = model.ExternalTransferAwaitingApproval
+		transfer.DebitPaymentID = &debitID
+		return transfer
+	}
+	newService := func(transfer *model.ExternalTransfer, debit model.PaymentStatus) (*ExternalTransferService, *MockExternalTransferRepository, *MockTransferInitiator, *scriptedConnector) {
+		repo := new(MockExternalTransferRepository)
+		transfers := new(MockTransferInitiator)
+		payments := new(MockPaymentLookup)
+		connector := &scriptedConnector{status: connectors.StatusAccepted}
+		svc := NewExternalTransferService(repo, transfers, connectors.NewRegistry(connector), settlementAccount)
+		svc.Payments = payments
+		repo.On("ListDue", mock.Anything, externalTransferBatchSize).Return([]model.ExternalTransfer{*transfer}, nil)
+		payments.On("GetPayment", transfer.DebitPaymentID.String()).Return(&model.Payment{ID: *transfer.DebitPaymentID, Status: debit}, nil)
+		return svc, repo, transfers, connector
+	}
+
+	t.Run("still awaiting", func(t *testing.T) {
+		transfer := awaiting()
+		svc, repo, _, connector := newService(transfer, model.StatusAwaitingApproval)
+		repo.On("UpdateIfStatus", withStatus(model.ExternalTransferAwaitingApproval), model.ExternalTransferAwaitingApproval).Return(true, nil)
+
+		_, err := svc.ProcessDue(context.Background(), time.Now())
+
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+		assert.Equal(t, 0, connector.calls)
+	})
+
+	t.Run("approved", func(t *testing.T) {
+		transfer := awaiting()
+		svc, repo, _, connector := newService(transfer, model.StatusPending)
+		repo.On("UpdateIfStatus", withStatus(model.ExternalTransferPending), model.ExternalTransferAwaitingApproval).Return(true, nil).Once()
+		repo.On("UpdateIfStatus", withStatus(model.ExternalTransferSubmitted), model.ExternalTransferPending).Return(true, nil).Once()
+
+		_, err := svc.ProcessDue(context.Background(), time.Now())
+
+		require.NoError(t, err)
+		repo.AssertExpectations(t)
+		assert.Equal(t, 1, connector.calls)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		transfer := awaiting()
+		svc, repo, transfers, connector := newService(transfer, model.StatusRejected)
+		var saved *model.ExternalTransfer
+		repo.On("UpdateIfStatus", withStatus(model.ExternalTransferRejected), model.ExternalTransferAwaitingApproval).Run(func(args mock.Arguments) {
+			saved = args.Get(0).(*model.ExternalTransfer)
+		}).Return(true, nil)
+
+		_, err := svc.ProcessDue(context.Background(), time.Now())
+
+		require.NoError(t, err)
+		require.NotNil(t, saved)
+		assert.Equal(t, "the debit was rejected", saved.LastError)
+		assert.Equal(t, 0, connector.calls)
+		// The debit never moved money, so nothing is refunded
+		transfers.AssertNotCalled(t, "InitiateTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestSubmit_RejectionRefundsImmediately(t *testing.T) {
+	repo := new(MockExternalTransferRepository)
+	transfers := new(MockTransferInitiator)
+	connector := &scriptedConnector{results: []error{fmt.Errorf("%w: account closed", connectors.ErrRejected)}}
+	svc := NewExternalTransferService(repo, transfers, connectors.NewRegistry(connector), settlementAccount)
+
+	transfer := pendingTransfer(0)
+	repo.On("UpdateIfStatus", withStatus(model.ExternalTransferRejected), model.ExternalTransferPending).Return(true, nil).Once()
+	repo.On("UpdateIfStatus", withStatus(model.ExternalTransferRejected), model.ExternalTransferRejected).Return(true, nil).Once()
+	transfers.On("InitiateTransfer", settlementAccount, transfer.FromAccountID.String(), "25", "GBP", mock.Anything).Return(&model.Payment{ID: uuid.New()}, nil)
+
+	require.NoError(t, svc.submit(context.Background(), transfer))
+
+	assert.Equal(t, model.ExternalTransferRejected, transfer.Status)
+	assert.Contains(t, transfer.LastError, "account closed")
+	assert.NotNil(t, transfer.RefundPaymentID)
+	assert.Equal(t, 1, connector.calls)
+}
+
+func TestHandleWebhook(t *testing.T) {
+	newService := func() (*ExternalTransferService, *MockExternalTransferRepository, *MockTransferInitiator) {
+		repo := new(MockExternalTransferRepository)
+		transfers := new(MockTransferInitiator)
+		return NewExternalTransferService(repo, transfers, connectors.NewRegistry(&scriptedConnector{}), settlementAccount), repo, transfers
+	}
+	submitted := func() *model.ExternalTransfer {
+		t := pendingTransfer(1)
+		t.Status = model.ExternalTransferSubmitted
+		return t
+	}
+
+	t.Run("settles", func(t *testing.T) {
+		svc, repo, _ := newService()
+		transfer := submitted()
+		repo.On("GetByExternalID", "scripted", "ext-1").Return(transfer, nil)
+		repo.On("UpdateIfStatus", withStatus(model.ExternalTransferSettled), model.ExternalTransferSubmitted).Return(true, nil)
+
+		err := svc.HandleWebhook("scripted", nil, []byte(`{"external_id":"ext-1","status":"SETTLED"}`))
+
+		require.NoError(t, err)
+		assert.NotNil(t, transfer.SettledAt)
+	})
+
+	t.Run("rejection refunds once", func(t *testing.T) {
+		svc, repo, transfers := newService()
+		transfer := submitted()
+		repo.On("GetByExternalID", "scripted", "ext-1").Return(transfer, nil)
+		// A retry or duplicate webhook claimed the transfer first
+		repo.On("UpdateIfStatus", withStatus(model.ExternalTransferRejected), model.ExternalTransferSubmitted).Return(false, nil)
+
+		err := svc.HandleWebhook("scripted", nil, []byte(`{"external_id":"ext-1","status":"REJECTED","reason":"beneficiary unknown"}`))
+
+		require.NoError(t, err)
+		transfers.AssertNotCalled(t, "InitiateTransferFor", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("ignores final transfers", func(t *testing.T) {
+		svc, repo, _ := newService()
+		transfer := submitted()
+		transfer.Status = model.ExternalTransferSettled
+		repo.On("GetByExternalID", "scripted", "ext-1").Return(transfer, nil)
+
+		require.NoError(t, svc.HandleWebhook("scripted", nil, []byte(`{"external_id":"ext-1","status":"REJECTED"}`)))
+		repo.AssertNotCalled(t, "UpdateIfStatus", mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown connector and transfer", func(t *testing.T) {
+		svc, repo, _ := newService()
+		repo.On("GetByExternalID", "scripted", "ext-2").Return(nil, errors.New("record not found"))
+
+		assert.ErrorIs(t, svc.HandleWebhook("other", nil, nil), ErrConnectorNotFound)
+		assert.ErrorIs(t, svc.HandleWebhook("scripted", nil, []byte(`{"external_id":"ext-2","status":"SETTLED"}`)), ErrExternalTransferNotFound)
+	})
+}
+
+func TestGetExternalTransfer_HidesOtherUsersTransfers(t *testing.T) {
+	repo := new(MockExternalTransferRepository)
+	svc := NewExternalTransferService(repo, new(MockTransferInitiator), connectors.NewRegistry(), settlementAccount)
+
+	transfer := pendingTransfer(0)
+	repo.On("GetByID", transfer.ID.String()).Return(transfer, nil)
+
+	got, err := svc.GetExternalTransfer(transfer.UserID.String(), transfer.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, transfer.ID, got.ID)
+
+	_, err = svc.GetExternalTransfer(uuid.New().String(), transfer.ID.String())
+	assert.ErrorIs(t, err, ErrExternalTransferNotFound)
+}
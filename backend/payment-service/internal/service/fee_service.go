@@ -0,0 +1,247 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrFeeScheduleNotFound = errors.New("fee schedule not found")
+	ErrInvalidFeeSchedule  = errors.New("invalid fee schedule")
+)
+
+// FeeRepository defines data access for fee schedules
+type FeeRepository interface {
+	CreateSchedule(schedule *model.FeeSchedule) error
+	SaveSchedule(schedule *model.FeeSchedule) error
+	DeleteSchedule(id string) (bool, error)
+	GetSchedule(id string) (*model.FeeSchedule, error)
+	ListSchedules() ([]model.FeeSchedule, error)
+	ActiveSchedules(paymentType model.PaymentType, currency string) ([]model.FeeSchedule, error)
+}
+
+// FeeService prices payments from the configured fee schedules. Fees are
+// posted from the payer to the fee income account alongside the payment.
+type FeeService struct {
+	Repo            FeeRepository
+	IncomeAccountID string
+}
+
+func NewFeeService(repo FeeRepository, incomeAccountID string) *FeeService {
+	return &FeeService{Repo: repo, IncomeAccountID: incomeAccountID}
+}
+
+// FeeQuote is the fee charged on a payment and the schedule that set it
+type FeeQuote struct {
+	Fee        decimal.Decimal `json:"fee"`
+	ScheduleID *uuid.UUID      `json:"fee_schedule_id,omitempty"`
+}
+
+// Quote returns the fee for a payment. A schedule for the account's product
+// takes precedence over one for every product; with no schedule the payment
+// is free.
+func (s *FeeService) Quote(paymentType model.PaymentType, productCode, currency string, amount decimal.Decimal) (*FeeQuote, error) {
+	schedules, err := s.Repo.ActiveSchedules(paymentType, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var general, product *model.FeeSchedule
+	for i := range schedules {
+		switch sc := &schedules[i]; {
+		case productCode != "" && sc.ProductCode == productCode && product == nil:
+			product = sc
+		case sc.ProductCode == "" && general == nil:
+			general = sc
+		}
+	}
+	match := product
+	if match == nil {
+		match = general
+	}
+	if match == nil {
+		return &FeeQuote{Fee: decimal.Zero}, nil
+	}
+	return &FeeQuote{Fee: ComputeFee(match, amount), ScheduleID: &match.ID}, nil
+}
+
+// ComputeFee applies a schedule to a payment amount, clamps it to the
+// schedule's minimum and maximum and rounds it to the currency's minor unit
+// (cents for USD, whole yen for JPY)
+func ComputeFee(schedule *model.FeeSchedule, amount decimal.Decimal) decimal.Decimal {
+	hundred := decimal.NewFromInt(100)
+	var fee decimal.Decimal
+	switch schedule.Method {
+	case model.FeeFlat:
+		fee = schedule.FlatAmount
+	case model.FeePercentage:
+		fee = amount.Mul(schedule.Percentage).Div(hundred)
+	case model.FeeTiered:
+		for _, tier := range schedule.Tiers {
+			if tier.UpTo.IsZero() || amount.LessThanOrEqual(tier.UpTo) {
+				fee = tier.FlatAmount.Add(amount.Mul(tier.Percentage).Div(hundred))
+				break
+			}
+		}
+	}
+
+	if fee.LessThan(schedule.MinFee) {
+		fee = schedule.MinFee
+	}
+	if schedule.MaxFee.IsPositive() && fee.GreaterThan(schedule.MaxFee) {
+		fee = schedule.MaxFee
+	}
+	exp, err := money.Exponent(schedule.Currency)
+	if err != nil {
+		// Schedules are validated when saved, so this is not expected
+		exp = money.DefaultExponent
+	}
+	return fee.Round(int32(exp))
+}
+
+// ListSchedules returns every fee schedule
+func (s *FeeService) ListSchedules() ([]model.FeeSchedule, error) {
+	return s.Repo.ListSchedules()
+}
+
+// GetSchedule returns a fee schedule by ID
+func (s *FeeService) GetSchedule(id string) (*model.FeeSchedule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrFeeScheduleNotFound
+	}
+	schedule, err := s.Repo.GetSchedule(id)
+	if err != nil {
+		return nil, ErrFeeScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// CreateSchedule validates and stores a new fee schedule
+func (s *FeeService) CreateSchedule(schedule *model.FeeSchedule) (*model.FeeSchedule, error) {
+	schedule.ID = uuid.New()
+	if err := validateFeeSchedule(schedule); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.CreateSchedule(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// UpdateSchedule replaces an existing fee schedule. Payments already made keep
+// the fee they were charged.
+func (s *FeeService) UpdateSchedule(id string, update *model.FeeSchedule) (*model.FeeSchedule, error) {
+	existing, err := s.GetSchedule(id)
+	if err != nil {
+		return nil, err
+	}
+	update.ID = existing.ID
+	update.CreatedAt = existing.CreatedAt
+	if err := validateFeeSchedule(update); err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SaveSchedule(update); err != nil {
+		return nil, err
+	}
+	return update, nil
+}
+
+// DeleteSchedule removes a fee schedule
+func (s *FeeService) DeleteSchedule(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrFeeScheduleNotFound
+	}
+	deleted, err := s.Repo.DeleteSchedule(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrFeeScheduleNotFound
+	}
+	return nil
+}
+
+// validateFeeSchedule normalizes a schedule and checks it can price payments
+func validateFeeSchedule(schedule *model.FeeSchedule) error {
+	schedule.Name = strings.TrimSpace(schedule.Name)
+	schedule.ProductCode = strings.TrimSpace(schedule.ProductCode)
+	schedule.Currency = strings.ToUpper(schedule.Currency)
+
+	invalid := func(msg string) error { return fmt.Errorf("%w: %s", ErrInvalidFeeSchedule, msg) }
+	switch {
+	case schedule.Name == "":
+		return invalid("name is required")
+	case !validCurrency(schedule.Currency):
+		return invalid("currency must be a 3-letter code")
+	case !validPaymentType(schedule.PaymentType):
+		return invalid("payment_type must be TRANSFER, INSTANT_TRANSFER, DIRECT_DEBIT or PAYOUT")
+	case schedule.FlatAmount.IsNegative() || schedule.MinFee.IsNegative() || schedule.MaxFee.IsNegative():
+		return invalid("amounts must not be negative")
+	case !validPercentage(schedule.Percentage):
+		return invalid("percentage must be between 0 and 100")
+	case schedule.MaxFee.IsPositive() && schedule.MaxFee.LessThan(schedule.MinFee):
+		return invalid("max_fee must not be below min_fee")
+	case !fitsCurrency(schedule.Currency, schedule.FlatAmount, schedule.MinFee, schedule.MaxFee):
+		return invalid("amounts must not have more decimal places than the currency allows")
+	}
+
+	switch schedule.Method {
+	case model.FeeFlat, model.FeePercentage:
+		schedule.Tiers = nil
+	case model.FeeTiered:
+		if len(schedule.Tiers) == 0 {
+			return invalid("a tiered schedule needs at least one tier")
+		}
+		for i, tier := range schedule.Tiers {
+			last := i == len(schedule.Tiers)-1
+			switch {
+			case tier.FlatAmount.IsNegative() || tier.UpTo.IsNegative():
+				return invalid("tier amounts must not be negative")
+			case !fitsCurrency(schedule.Currency, tier.FlatAmount):
+				return invalid("tier amounts must not have more decimal places than the currency allows")
+			case !validPercentage(tier.Percentage):
+				return invalid("tier percentage must be between 0 and 100")
+			case tier.UpTo.IsZero() && !last:
+				return invalid("only the last tier may be open-ended")
+			case i > 0 && !tier.UpTo.IsZero() && tier.UpTo.LessThanOrEqual(schedule.Tiers[i-1].UpTo):
+				return invalid("tier up_to amounts must increase")
+			}
+		}
+	default:
+		return invalid("method must be FLAT, PERCENTAGE or TIERED")
+	}
+	return nil
+}
+
+func validPaymentType(t model.PaymentType) bool {
+	switch t {
+	case model.PaymentTypeTransfer, model.PaymentTypeInstantTransfer, model.PaymentTypeDirectDebit, model.PaymentTypePayout:
+		return true
+	}
+	return false
+}
+
+func validCurrency(currency string) bool {
+	_, err := money.Exponent(currency)
+	return err == nil
+}
+
+// fitsCurrency reports whether every amount is a whole number of the currency's minor units
+func fitsCurrency(currency string, amounts ...decimal.Decimal) bool {
+	for _, amount := range amounts {
+		if _, err := money.FromDecimal(amount, currency); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func validPercentage(p decimal.Decimal) bool {
+	return !p.IsNegative() && p.LessThanOrEqual(decimal.NewFromInt(100))
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryFeeRepository keeps fee schedules in a slice
+type memoryFeeRepository struct {
+	schedules []model.FeeSchedule
+}
+
+func (r *memoryFeeRepository) CreateSchedule(schedule *model.FeeSchedule) error {
+	r.schedules = append(r.schedules, *schedule)
+	return nil
+}
+
+func (r *memoryFeeRepository) SaveSchedule(schedule *model.FeeSchedule) error {
+	for i := range r.schedules {
+		if r.schedules[i].ID == schedule.ID {
+			r.schedules[i] = *schedule
+		}
+	}
+	return nil
+}
+
+func (r *memoryFeeRepository) DeleteSchedule(id string) (bool, error) {
+	for i := range r.schedules {
+		if r.schedules[i].ID.String() == id {
+			r.schedules = append(r.schedules[:i], r.schedules[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryFeeRepository) GetSchedule(id string) (*model.FeeSchedule, error) {
+	for i := range r.schedules {
+		if r.schedules[i].ID.String() == id {
+			schedule := r.schedules[i]
+			return &schedule, nil
+		}
+	}
+	return nil, ErrFeeScheduleNotFound
+}
+
+func (r *memoryFeeRepository) ListSchedules() ([]model.FeeSchedule, error) {
+	return r.schedules, nil
+}
+
+func (r *memoryFeeRepository) ActiveSchedules(paymentType model.PaymentType, currency string) ([]model.FeeSchedule, error) {
+	var active []model.FeeSchedule
+	for _, s := range r.schedules {
+		if s.Active && s.PaymentType == paymentType && s.Currency == currency {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestComputeFee(t *testing.T) {
+	tiered := &model.FeeSchedule{Method: model.FeeTiered, Tiers: []model.FeeTier{
+		{UpTo: dec("100"), FlatAmount: dec("0.50")},
+		{UpTo: dec("1000"), Percentage: dec("1")},
+		{FlatAmount: dec("5"), Percentage: dec("0.5")},
+	}}
+	tests := []struct {
+		name     string
+		schedule *model.FeeSchedule
+		amount   string
+		want     string
+	}{
+		{"flat", &model.FeeSchedule{Method: model.FeeFlat, FlatAmount: dec("1.25")}, "500", "1.25"},
+		{"percentage rounds to cents", &model.FeeSchedule{Method: model.FeePercentage, Percentage: dec("0.25")}, "123.45", "0.31"},
+		{"percentage raised to the minimum", &model.FeeSchedule{Method: model.FeePercentage, Percentage: dec("1"), MinFee: dec("2")}, "50", "2"},
+		{"percentage capped at the maximum", &model.FeeSchedule{Method: model.FeePercentage, Percentage: dec("1"), MaxFee: dec("10")}, "5000", "10"},
+		{"first tier", tiered, "100", "0.5"},
+		{"middle tier", tiered, "250", "2.5"},
+		{"open-ended tier", tiered, "10000", "55"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ComputeFee(tt.schedule, dec(tt.amount)).String())
+		})
+	}
+}
+
+func TestFeeQuote_PrefersProductSchedule(t *testing.T) {
+	repo := &memoryFeeRepository{}
+	fees := NewFeeService(repo, uuid.New().String())
+	general, err := fees.CreateSchedule(&model.FeeSchedule{Name: "Standard", Currency: "usd", PaymentType: model.PaymentTypeTransfer, Method: model.FeeFlat, FlatAmount: dec("1"), Active: true})
+	require.NoError(t, err)
+	premium, err := fees.CreateSchedule(&model.FeeSchedule{Name: "Premium", ProductCode: "CHECKING-PREMIUM", Currency: "USD", PaymentType: model.PaymentTypeTransfer, Method: model.FeeFlat, Active: true})
+	require.NoError(t, err)
+	_, err = fees.CreateSchedule(&model.FeeSchedule{Name: "Old", Currency: "USD", PaymentType: model.PaymentTypeDirectDebit, Method: model.FeeFlat, FlatAmount: dec("9")})
+	require.NoError(t, err)
+
+	quote, err := fees.Quote(model.PaymentTypeTransfer, "CHECKING-PREMIUM", "USD", dec("100"))
+	require.NoError(t, err)
+	assert.True(t, quote.Fee.IsZero())
+	assert.Equal(t, premium.ID, *quote.ScheduleID)
+
+	quote, err = fees.Quote(model.PaymentTypeTransfer, "SAVINGS-STD", "USD", dec("100"))
+	require.NoError(t, err)
+	assert.Equal(t, "1", quote.Fee.String())
+	assert.Equal(t, general.ID, *quote.ScheduleID)
+
+	// Inactive schedules and other currencies do not apply
+	for _, q := range []struct {
+		paymentType model.PaymentType
+		currency    string
+	}{{model.PaymentTypeDirectDebit, "USD"}, {model.PaymentTypeTransfer, "EUR"}} {
+		quote, err = fees.Quote(q.paymentType, "", q.currency, dec("100"))
+		require.NoError(t, err)
+		assert.True(t, quote.Fee.IsZero())
+		assert.Nil(t, quote.ScheduleID)
+	}
+}
+
+func TestCreateFeeSchedule_Validation(t *testing.T) {
+	fees := NewFeeService(&memoryFeeRepository{}, "")
+	valid := func() *model.FeeSchedule {
+		return &model.FeeSchedule{Name: "Standard", Currency: "USD", PaymentType: model.PaymentTypeTransfer, Method: model.FeePercentage, Percentage: dec("0.5")}
+	}
+	tests := map[string]func(*model.FeeSchedule){
+		"missing name":         func(s *model.FeeSchedule) { s.Name = " " },
+		"bad currency":         func(s *model.FeeSchedule) { s.Currency = "US" },
+		"unknown payment type": func(s *model.FeeSchedule) { s.PaymentType = "WIRE" },
+		"unknown method":       func(s *model.FeeSchedule) { s.Method = "BANDED" },
+		"percentage over 100":  func(s *model.FeeSchedule) { s.Percentage = dec("101") },
+		"negative flat amount": func(s *model.FeeSchedule) { s.FlatAmount = dec("-1") },
+		"max below min":        func(s *model.FeeSchedule) { s.MinFee, s.MaxFee = dec("5"), dec("1") },
+		"tiered without tiers": func(s *model.FeeSchedule) { s.Method = model.FeeTiered },
+		"open tier before last": func(s *model.FeeSchedule) {
+			s.Method, s.Tiers = model.FeeTiered, []model.FeeTier{{}, {UpTo: dec("10")}}
+		},
+		"tiers out of order": func(s *model.FeeSchedule) {
+			s.Method, s.Tiers = model.FeeTiered, []model.FeeTier{{UpTo: dec("10")}, {UpTo: dec("5")}}
+		},
+		"negative tier rate": func(s *model.FeeSchedule) {
+			s.Method, s.Tiers = model.FeeTiered, []model.FeeTier{{Percentage: dec("-1")}}
+		},
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			schedule := valid()
+			mutate(schedule)
+			_, err := fees.CreateSchedule(schedule)
+			assert.ErrorIs(t, err, ErrInvalidFeeSchedule)
+		})
+	}
+
+	_, err := fees.CreateSchedule(valid())
+	assert.NoError(t, err)
+	_, err = fees.UpdateSchedule(uuid.New().String(), valid())
+	assert.ErrorIs(t, err, ErrFeeScheduleNotFound)
+}
+
+func TestInitiateTransfer_FeeCountsAgainstBalance(t *testing.T) {
+	ledger := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata := `{"product_code":"CHECKING-STD"}`
+		_ = json.NewEncoder(w).Encode(AccountResponse{ID: "acct", Balance: "100.00", Metadata: &metadata})
+	}))
+	defer ledger.Close()
+
+	repo := &memoryFeeRepository{}
+	fees := NewFeeService(repo, uuid.New().String())
+	_, err := fees.CreateSchedule(&model.FeeSchedule{Name: "Checking", ProductCode: "CHECKING-STD", Currency: "USD", PaymentType: model.PaymentTypeTransfer, Method: model.FeeFlat, FlatAmount: dec("0.50"), Active: true})
+	require.NoError(t, err)
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	svc.SetFees(fees)
+
+	// 100.00 plus the 0.50 fee is more than the balance
+	_, err = svc.initiateTransfer(transferParams{
+		FromAccountID: uuid.New().String(),
+		ToAccountID:   uuid.New().String(),
+		Amount:        "100.00",
+		Currency:      "USD",
+		PaymentType:   model.PaymentTypeTransfer,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient funds: available 100, requested 100.5")
+}
+
+func TestFeePostings(t *testing.T) {
+	income := uuid.New().String()
+	svc := &PaymentService{}
+	svc.SetFees(NewFeeService(&memoryFeeRepository{}, income))
+	payment := &model.Payment{FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: dec("100"), Fee: dec("1.5")}
+
+	postings := append(transferPostings(payment.FromAccountID.String(), payment.ToAccountID.String(), "100"), svc.feePostings(payment)...)
+	require.Len(t, postings, 4)
+	assert.Equal(t, LedgerPosting{AccountID: payment.FromAccountID.String(), Amount: "1.5", Direction: -1}, postings[2])
+	assert.Equal(t, LedgerPosting{AccountID: income, Amount: "1.5", Direction: 1}, postings[3])
+
+	// Free payments post no fee legs
+	payment.Fee = decimal.Zero
+	assert.Empty(t, svc.feePostings(payment))
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultIncomingCreditListLimit caps how many credits the ops API lists at once
+	DefaultIncomingCreditListLimit = 100
+	maxIncomingCreditListLimit     = 500
+)
+
+var (
+	ErrIncomingCreditsDisabled     = errors.New("incoming credits are not configured")
+	ErrIncomingCreditNotFound      = errors.New("incoming credit not found")
+	ErrIncomingCreditNotInSuspense = errors.New("only credits held in suspense can be reposted")
+	ErrInvalidIncomingCredit       = errors.New("invalid incoming credit")
+	ErrCreditsNotSupported         = errors.New("connector does not deliver incoming credits")
+)
+
+// IncomingCreditRepository defines data access for incoming credits
+type IncomingCreditRepository interface {
+	CreateIfNew(c *model.IncomingCredit) (bool, error)
+	GetByID(id string) (*model.IncomingCredit, error)
+	GetByExternalID(connector, externalID string) (*model.IncomingCredit, error)
+	List(status model.IncomingCreditStatus, limit int) ([]model.IncomingCredit, error)
+	UpdateIfStatus(c *model.IncomingCredit, expected model.IncomingCreditStatus) (bool, error)
+}
+
+// AccountResolver finds the internal account a transfer destination refers to
+type AccountResolver interface {
+	ResolveDestination(ctx context.Context, d Destination) (string, error)
+}
+
+// IncomingCreditService receives payments from other banks. Each credit is
+// matched to an account by the IBAN or sort code and account number it was
+// sent to and moved there from the settlement account. Credits that match no
+// account are moved to the suspense account instead, where ops can repair and
+// repost them.
+type IncomingCreditService struct {
+	Repo                IncomingCreditRepository
+	Transfers           TransferInitiator
+	Accounts            AccountResolver
+	Connectors          *connectors.Registry
+	SettlementAccountID string
+	SuspenseAccountID   string
+}
+
+func NewIncomingCreditService(repo IncomingCreditRepository, transfers TransferInitiator, accounts AccountResolver, registry *connectors.Registry, settlementAccountID, suspenseAccountID string) *IncomingCreditService {
+	return &IncomingCreditService{
+		Repo:                repo,
+		Transfers:           transfers,
+		Accounts:            accounts,
+		Connectors:          registry,
+		SettlementAccountID: settlementAccountID,
+		SuspenseAccountID:   suspenseAccountID,
+	}
+}
+
+// RepostRequest moves a credit out of suspense to the account ops identified,
+// by account ID or alias, with a note saying why
+type RepostRequest struct {
+	Destination Destination
+	Note        string
+}
+
+// ReceiveCredit records a credit delivered by a connector webhook and posts
+// it. Redelivered credits are not posted twice; one whose posting failed is
+// posted again, so an error asks the connector to redeliver.
+func (s *IncomingCreditService) ReceiveCredit(ctx context.Context, connectorName string, header http.Header, body []byte) (*model.IncomingCredit, error) {
+	if s.SettlementAccountID == "" || s.SuspenseAccountID == "" {
+		return nil, ErrIncomingCreditsDisabled
+	}
+	connector, ok := s.Connectors.Get(connectorName)
+	if !ok {
+		return nil, ErrConnectorNotFound
+	}
+	receiver, ok := connector.(connectors.CreditReceiver)
+	if !ok {
+		return nil, ErrCreditsNotSupported
+	}
+	received, err := receiver.ParseCreditWebhook(header, body)
+	if err != nil {
+		return nil, err
+	}
+	if !received.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidIncomingCredit)
+	}
+	if !isoCurrencyPattern.MatchString(received.Currency) {
+		return nil, fmt.Errorf("%w: currency must be a 3-letter ISO code", ErrInvalidIncomingCredit)
+	}
+
+	credit := &model.IncomingCredit{
+		Connector:     connectorName,
+		ExternalID:    received.ExternalID,
+		Amount:        received.Amount,
+		Currency:      received.Currency,
+		Scheme:        string(received.Scheme),
+		DebtorName:    truncate(received.DebtorName, 140),
+		IBAN:          received.Beneficiary.IBAN,
+		SortCode:      received.Beneficiary.SortCode,
+		AccountNumber: received.Beneficiary.AccountNumber,
+		Reference:     truncate(received.Reference, 140),
+		Status:        model.IncomingCreditReceived,
+	}
+	created, err := s.Repo.CreateIfNew(credit)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		if credit, err = s.Repo.GetByExternalID(connectorName, received.ExternalID); err != nil {
+			return nil, err
+		}
+		if credit.Status != model.IncomingCreditReceived {
+			slog.Info("Ignoring redelivered incoming credit", "credit_id", credit.ID, "status", credit.Status)
+			return credit, nil
+		}
+	}
+
+	return credit, s.post(ctx, credit)
+}
+
+// post matches a received credit to an account and moves the funds there, or
+// to the suspense account when it matches none. The status change is claimed
+// first so two deliveries of the same credit cannot both post it.
+func (s *IncomingCreditService) post(ctx context.Context, c *model.IncomingCredit) error {
+	accountID, err := s.Accounts.ResolveDestination(ctx, creditBeneficiary(c))
+	target, description := accountID, incomingCreditDescription(c)
+	switch {
+	case err == nil:
+		accountUUID, parseErr := uuid.Parse(accountID)
+		if parseErr != nil {
+			return s.postFailed(c, fmt.Errorf("ledger resolved an invalid account id %q", accountID))
+		}
+		c.Status = model.IncomingCreditPosted
+		c.AccountID = &accountUUID
+	case errors.Is(err, ErrAliasNotFound), errors.Is(err, ErrInvalidDestination):
+		c.Status = model.IncomingCreditSuspense
+		c.SuspenseReason = err.Error()
+		target, description = s.SuspenseAccountID, "Suspense: "+description
+	default:
+		return s.postFailed(c, err)
+	}
+	c.LastError = ""
+	claimed, err := s.Repo.UpdateIfStatus(c, model.IncomingCreditReceived)
+	if err != nil || !claimed {
+		return err
+	}
+
+	payment, err := s.Transfers.InitiateTransfer(s.SettlementAccountID, target, c.Amount.String(), c.Currency, description)
+	if err != nil {
+		posted := c.Status
+		c.Status, c.AccountID, c.SuspenseReason = model.IncomingCreditReceived, nil, ""
+		c.LastError = err.Error()
+		if _, saveErr := s.Repo.UpdateIfStatus(c, posted); saveErr != nil {
+			slog.Error("Failed to release incoming credit after a failed posting", "credit_id", c.ID, "error", saveErr)
+		}
+		return fmt.Errorf("post incoming credit: %w", err)
+	}
+	if c.Status == model.IncomingCreditSuspense {
+		c.SuspensePaymentID = &payment.ID
+		slog.Warn("Incoming credit matched no account and is held in suspense", "credit_id", c.ID, "reason", c.SuspenseReason)
+	} else {
+		c.PaymentID = &payment.ID
+	}
+	_, err = s.Repo.UpdateIfStatus(c, c.Status)
+	return err
+}
+
+// postFailed records why a credit could not be posted and returns the error so
+// the connector redelivers it
+func (s *IncomingCreditService) postFailed(c *model.IncomingCredit, err error) error {
+	c.LastError = err.Error()
+	if _, saveErr := s.Repo.UpdateIfStatus(c, model.IncomingCreditReceived); saveErr != nil {
+		slog.Error("Failed to record incoming credit error", "credit_id", c.ID, "error", saveErr)
+	}
+	return fmt.Errorf("post incoming credit: %w", err)
+}
+
+// ListCredits returns incoming credits with a status, or all of them, oldest first
+func (s *IncomingCreditService) ListCredits(status model.IncomingCreditStatus, limit int) ([]model.IncomingCredit, error) {
+	switch status {
+	case "", model.IncomingCreditReceived, model.IncomingCreditPosted, model.IncomingCreditSuspense, model.IncomingCreditReposted:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidIncomingCredit, status)
+	}
+	if limit <= 0 {
+		limit = DefaultIncomingCreditListLimit
+	}
+	if limit > maxIncomingCreditListLimit {
+		limit = maxIncomingCreditListLimit
+	}
+	return s.Repo.List(status, limit)
+}
+
+// GetCredit returns one incoming credit
+func (s *IncomingCreditService) GetCredit(id string) (*model.IncomingCredit, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrIncomingCreditNotFound
+	}
+	credit, err := s.Repo.GetByID(id)
+	if err != nil {
+		return nil, ErrIncomingCreditNotFound
+	}
+	return credit, nil
+}
+
+// RepostCredit moves a credit held in suspense to the account ops identified
+// and records who repaired it and why. If the ledger transfer fails the
+// credit goes back into suspense.
+func (s *IncomingCreditService) RepostCredit(ctx context.Context, adminID, id string, req RepostRequest) (*model.IncomingCredit, error) {
+	if s.SuspenseAccountID == "" {
+		return nil, ErrIncomingCreditsDisabled
+	}
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	if req.Note == "" {
+		return nil, fmt.Errorf("%w: a note explaining the repair is required", ErrInvalidIncomingCredit)
+	}
+	credit, err := s.GetCredit(id)
+	if err != nil {
+		return nil, err
+	}
+	if credit.Status != model.IncomingCreditSuspense {
+		return nil, ErrIncomingCreditNotInSuspense
+	}
+
+	accountID, err := s.Accounts.ResolveDestination(ctx, req.Destination)
+	if err != nil {
+		return nil, err
+	}
+	accountUUID, err := uuid.Parse(accountID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid account id", ErrInvalidIncomingCredit)
+	}
+
+	now := time.Now()
+	credit.Status = model.IncomingCreditReposted
+	credit.AccountID = &accountUUID
+	credit.RepairedBy = &adminUUID
+	credit.RepairNote = req.Note
+	credit.RepairedAt = &now
+	credit.LastError = ""
+	claimed, err := s.Repo.UpdateIfStatus(credit, model.IncomingCreditSuspense)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrIncomingCreditNotInSuspense
+	}
+
+	payment, err := s.Transfers.InitiateTransfer(s.SuspenseAccountID, accountID, credit.Amount.String(), credit.Currency, "Repost: "+incomingCreditDescription(credit))
+	if err != nil {
+		credit.Status = model.IncomingCreditSuspense
+		credit.AccountID, credit.RepairedBy, credit.RepairedAt, credit.RepairNote = nil, nil, nil, ""
+		credit.LastError = err.Error()
+		if _, saveErr := s.Repo.UpdateIfStatus(credit, model.IncomingCreditReposted); saveErr != nil {
+			slog.Error("Failed to return incoming credit to suspense; manual action required", "credit_id", credit.ID, "error", saveErr)
+		}
+		return nil, fmt.Errorf("repost incoming credit: %w", err)
+	}
+	credit.PaymentID = &payment.ID
+	if _, err := s.Repo.UpdateIfStatus(credit, model.IncomingCreditReposted); err != nil {
+		return nil, err
+	}
+	slog.Info("Reposted incoming credit from suspense", "credit_id", credit.ID, "account_id", accountID, "repaired_by", adminID)
+	return credit, nil
+}
+
+// creditBeneficiary is the alias a credit was sent to
+func creditBeneficiary(c *model.IncomingCredit) Destination {
+	return Destination{IBAN: c.IBAN, SortCode: c.SortCode, AccountNumber: c.AccountNumber}
+}
+
+func incomingCreditDescription(c *model.IncomingCredit) string {
+	desc := "Incoming credit"
+	if c.DebtorName != "" {
+		desc += " from " + c.DebtorName
+	}
+	if c.Reference != "" {
+		desc += " (" + c.Reference + ")"
+	}
+	return desc
+}
+
+// truncate shortens s to at most n runes to fit its column
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryIncomingCreditRepository keeps incoming credits in memory
+type memoryIncomingCreditRepository struct {
+	mu      sync.Mutex
+	credits map[uuid.UUID]model.IncomingCredit
+}
+
+func newMemoryIncomingCreditRepository() *memoryIncomingCreditRepository {
+	return &memoryIncomingCreditRepository{credits: make(map[uuid.UUID]model.IncomingCredit)}
+}
+
+func (r *memoryIncomingCreditRepository) CreateIfNew(c *model.IncomingCredit) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.credits {
+		if existing.Connector == c.Connector && existing.ExternalID == c.ExternalID {
+			return false, nil
+		}
+	}
+	c.ID = uuid.New()
+	r.credits[c.ID] = *c
+	return true, nil
+}
+
+func (r *memoryIncomingCreditRepository) GetByID(id string) (*model.IncomingCredit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.credits[uuid.MustParse(id)]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &c, nil
+}
+
+func (r *memoryIncomingCreditRepository) GetByExternalID(connector, externalID string) (*model.IncomingCredit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.credits {
+		if c.Connector == connector && c.ExternalID == externalID {
+			return &c, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryIncomingCreditRepository) List(status model.IncomingCreditStatus, limit int) ([]model.IncomingCredit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var credits []model.IncomingCredit
+	for _, c := range r.credits {
+		if status == "" || c.Status == status {
+			credits = append(credits, c)
+		}
+	}
+	return credits, nil
+}
+
+func (r *memoryIncomingCreditRepository) UpdateIfStatus(c *model.IncomingCredit, expected model.IncomingCreditStatus) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.credits[c.ID].Status != expected {
+		return false, nil
+	}
+	r.credits[c.ID] = *c
+	return true, nil
+}
+
+// aliasTable resolves destinations from a fixed set of IBANs
+type aliasTable struct {
+	accounts map[string]string
+	err      error
+}
+
+func (a aliasTable) ResolveDestination(_ context.Context, d Destination) (string, error) {
+	if a.err != nil {
+		return "", a.err
+	}
+	if d.AccountID != "" {
+		return d.AccountID, nil
+	}
+	if id, ok := a.accounts[d.IBAN]; ok {
+		return id, nil
+	}
+	if d.IBAN == "" {
+		return "", ErrInvalidDestination
+	}
+	return "", ErrAliasNotFound
+}
+
+const knownIBAN = "GB82WEST12345698765432"
+
+var (
+	creditSettlementAccount = uuid.NewString()
+	creditSuspenseAccount   = uuid.NewString()
+)
+
+func newIncomingCreditService(accounts AccountResolver) (*IncomingCreditService, *memoryIncomingCreditRepository, *MockTransferInitiator, string) {
+	accountID := uuid.NewString()
+	if accounts == nil {
+		accounts = aliasTable{accounts: map[string]string{knownIBAN: accountID}}
+	}
+	repo := newMemoryIncomingCreditRepository()
+	transfers := new(MockTransferInitiator)
+	registry := connectors.NewRegistry(mockRail)
+	return NewIncomingCreditService(repo, transfers, accounts, registry, creditSettlementAccount, creditSuspenseAccount), repo, transfers, accountID
+}
+
+// mockRail signs credit webhooks as the mock connector's rail would
+var mockRail = connectors.NewMockConnector("whsec")
+
+// receiveMock delivers a signed mock credit webhook
+func receiveMock(svc *IncomingCreditService, body []byte) (*model.IncomingCredit, error) {
+	return svc.ReceiveCredit(context.Background(), connectors.MockConnectorName, mockRail.Sign(body), body)
+}
+
+func creditBody(externalID, iban string) []byte {
+	return []byte(`{"external_id":"` + externalID + `","amount":"40.00","currency":"GBP","debtor_name":"Ada Lovelace","iban":"` + iban + `","reference":"rent"}`)
+}
+
+func TestReceiveCredit_PostsMatchedCreditOnce(t *testing.T) {
+	svc, _, transfers, accountID := newIncomingCreditService(nil)
+	payment := &model.Payment{ID: uuid.New()}
+	transfers.On("InitiateTransfer", creditSettlementAccount, accountID, "40", "GBP", "Incoming credit from Ada Lovelace (rent)").Return(payment, nil).Once()
+
+	credit, err := receiveMock(svc, creditBody("in-1", "gb82 west 1234 5698 7654 32"))
+	require.NoError(t, err)
+	assert.Equal(t, model.IncomingCreditPosted, credit.Status)
+	assert.Equal(t, accountID, credit.AccountID.String())
+	assert.Equal(t, payment.ID, *credit.PaymentID)
+
+	// A redelivery returns the posted credit without posting it again
+	again, err := receiveMock(svc, creditBody("in-1", knownIBAN))
+	require.NoError(t, err)
+	assert.Equal(t, credit.ID, again.ID)
+	transfers.AssertExpectations(t)
+}
+
+func TestReceiveCredit_UnmatchedGoesToSuspense(t *testing.T) {
+	svc, repo, transfers, _ := newIncomingCreditService(nil)
+	suspensePayment := &model.Payment{ID: uuid.New()}
+	transfers.On("InitiateTransfer", creditSettlementAccount, creditSuspenseAccount, "40", "GBP", mock.MatchedBy(func(desc string) bool {
+		return desc == "Suspense: Incoming credit from Ada Lovelace (rent)"
+	})).Return(suspensePayment, nil)
+
+	credit, err := receiveMock(svc, creditBody("in-2", "DE89370400440532013000"))
+	require.NoError(t, err)
+	assert.Equal(t, model.IncomingCreditSuspense, credit.Status)
+	assert.Equal(t, ErrAliasNotFound.Error(), credit.SuspenseReason)
+	assert.Equal(t, suspensePayment.ID, *credit.SuspensePaymentID)
+	assert.Nil(t, credit.AccountID)
+
+	queue, err := svc.ListCredits(model.IncomingCreditSuspense, 0)
+	require.NoError(t, err)
+	require.Len(t, queue, 1)
+	assert.Equal(t, credit.ID, queue[0].ID)
+	stored, _ := repo.GetByID(credit.ID.String())
+	assert.Equal(t, model.IncomingCreditSuspense, stored.Status)
+}
+
+func TestReceiveCredit_FailedPostingIsRetriedOnRedelivery(t *testing.T) {
+	svc, repo, transfers, accountID := newIncomingCreditService(nil)
+	transfers.On("InitiateTransfer", creditSettlementAccount, accountID, "40", "GBP", mock.Anything).Return(nil, errors.New("ledger unavailable")).Once()
+
+	_, err := receiveMock(svc, creditBody("in-3", knownIBAN))
+	require.Error(t, err)
+	stored, err := repo.GetByExternalID(connectors.MockConnectorName, "in-3")
+	require.NoError(t, err)
+	assert.Equal(t, model.IncomingCreditReceived, stored.Status)
+	assert.Equal(t, "ledger unavailable", stored.LastError)
+	assert.Nil(t, stored.AccountID)
+
+	transfers.On("InitiateTransfer", creditSettlementAccount, accountID, "40", "GBP", mock.Anything).Return(&model.Payment{ID: uuid.New()}, nil).Once()
+	credit, err := receiveMock(svc, creditBody("in-3", knownIBAN))
+	require.NoError(t, err)
+	assert.Equal(t, model.IncomingCreditPosted, credit.Status)
+	assert.Empty(t, credit.LastError)
+	transfers.AssertExpectations(t)
+}
+
+func TestReceiveCredit_AliasLookupFailureIsNotSuspense(t *testing.T) {
+	svc, repo, transfers, _ := newIncomingCreditService(aliasTable{err: errors.New("ledger timeout")})
+
+	_, err := receiveMock(svc, creditBody("in-4", knownIBAN))
+	require.Error(t, err, "an outage must not put credits for real accounts into suspense")
+	stored, _ := repo.GetByExternalID(connectors.MockConnectorName, "in-4")
+	assert.Equal(t, model.IncomingCreditReceived, stored.Status)
+	transfers.AssertNotCalled(t, "InitiateTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReceiveCredit_Validation(t *testing.T) {
+	svc, _, _, _ := newIncomingCreditService(nil)
+
+	_, err := receiveMock(svc, []byte(`{"external_id":"x","amount":"0","currency":"GBP"}`))
+	assert.ErrorIs(t, err, ErrInvalidIncomingCredit)
+	_, err = receiveMock(svc, []byte(`{"external_id":"x","amount":"5","currency":"pounds"}`))
+	assert.ErrorIs(t, err, ErrInvalidIncomingCredit)
+	_, err = svc.ReceiveCredit(context.Background(), "unknown", nil, creditBody("x", knownIBAN))
+	assert.ErrorIs(t, err, ErrConnectorNotFound)
+	// The webhook route is public, so an unsigned credit is refused
+	_, err = svc.ReceiveCredit(context.Background(), connectors.MockConnectorName, nil, creditBody("x", knownIBAN))
+	assert.ErrorIs(t, err, connectors.ErrInvalidSignature)
+
+	svc.SuspenseAccountID = ""
+	_, err = receiveMock(svc, creditBody("x", knownIBAN))
+	assert.ErrorIs(t, err, ErrIncomingCreditsDisabled)
+}
+
+func TestRepostCredit(t *testing.T) {
+	svc, _, transfers, accountID := newIncomingCreditService(nil)
+	transfers.On("InitiateTransfer", creditSettlementAccount, creditSuspenseAccount, "40", "GBP", mock.Anything).Return(&model.Payment{ID: uuid.New()}, nil)
+	credit, err := receiveMock(svc, creditBody("in-5", "GB29NWBK60161331926819"))
+	require.NoError(t, err)
+	require.Equal(t, model.IncomingCreditSuspense, credit.Status)
+
+	adminID := uuid.NewString()
+	fix := RepostRequest{Destination: Destination{IBAN: knownIBAN}, Note: "Customer's old IBAN, confirmed by phone"}
+
+	_, err = svc.RepostCredit(context.Background(), adminID, credit.ID.String(), RepostRequest{Destination: fix.Destination})
+	assert.ErrorIs(t, err, ErrInvalidIncomingCredit, "a note is required")
+
+	// A failed ledger transfer leaves the credit in suspense
+	transfers.On("InitiateTransfer", creditSuspenseAccount, accountID, "40", "GBP", mock.Anything).Return(nil, errors.New("ledger unavailable")).Once()
+	_, err = svc.RepostCredit(context.Background(), adminID, credit.ID.String(), fix)
+	require.Error(t, err)
+	stored, _ := svc.GetCredit(credit.ID.String())
+	assert.Equal(t, model.IncomingCreditSuspense, stored.Status)
+	assert.Nil(t, stored.RepairedBy)
+	assert.Equal(t, "ledger unavailable", stored.LastError)
+
+	payment := &model.Payment{ID: uuid.New()}
+	transfers.On("InitiateTransfer", creditSuspenseAccount, accountID, "40", "GBP", "Repost: Incoming credit from Ada Lovelace (rent)").Return(payment, nil).Once()
+	reposted, err := svc.RepostCredit(context.Background(), adminID, credit.ID.String(), fix)
+	require.NoError(t, err)
+	assert.Equal(t, model.IncomingCreditReposted, reposted.Status)
+	assert.Equal(t, accountID, reposted.AccountID.String())
+	assert.Equal(t, payment.ID, *reposted.PaymentID)
+	assert.Equal(t, adminID, reposted.RepairedBy.String())
+	assert.Equal(t, fix.Note, reposted.RepairNote)
+	assert.NotNil(t, reposted.RepairedAt)
+
+	_, err = svc.RepostCredit(context.Background(), adminID, credit.ID.String(), fix)
+	assert.ErrorIs(t, err, ErrIncomingCreditNotInSuspense, "a credit cannot be reposted twice")
+	transfers.AssertExpectations(t)
+}
+
+func TestRepostCredit_UnknownDestination(t *testing.T) {
+	svc, _, transfers, _ := newIncomingCreditService(nil)
+	transfers.On("InitiateTransfer", creditSettlementAccount, creditSuspenseAccount, "40", "GBP", mock.Anything).Return(&model.Payment{ID: uuid.New()}, nil)
+	credit, err := receiveMock(svc, creditBody("in-6", "GB29NWBK60161331926819"))
+	require.NoError(t, err)
+
+	_, err = svc.RepostCredit(context.Background(), uuid.NewString(), credit.ID.String(), RepostRequest{Destination: Destination{IBAN: "DE89370400440532013000"}, Note: "guess"})
+	assert.ErrorIs(t, err, ErrAliasNotFound)
+	stored, _ := svc.GetCredit(credit.ID.String())
+	assert.Equal(t, model.IncomingCreditSuspense, stored.Status)
+}
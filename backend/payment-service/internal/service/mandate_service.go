@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidAPIKey        = errors.New("invalid merchant API key")
+	ErrMerchantNotFound     = errors.New("merchant not found")
+	ErrMandateNotFound      = errors.New("mandate not found")
+	ErrMandateNotActive     = errors.New("mandate is not active")
+	ErrMandateExpired       = errors.New("mandate has expired")
+	ErrMandateForbidden     = errors.New("mandate does not belong to caller")
+	ErrMandateLimitExceeded = errors.New("collection exceeds mandate limit")
+	ErrMandateInvalidState  = errors.New("mandate cannot transition from its current status")
+	ErrMandateDebtorAccount = errors.New("debtor account does not belong to the payer")
+)
+
+// MandateRepository defines data access for merchants and mandates
+type MandateRepository interface {
+	CreateMerchant(m *model.Merchant) error
+	GetMerchantByAPIKeyHash(hash string) (*model.Merchant, error)
+	GetMerchant(id string) (*model.Merchant, error)
+	CreateMandate(m *model.Mandate) error
+	GetMandate(id string) (*model.Mandate, error)
+	ListMandatesByUser(userID string) ([]model.Mandate, error)
+	SaveMandate(m *model.Mandate) error
+	SumCollectedSince(mandateID string, since time.Time) (decimal.Decimal, error)
+}
+
+// AccountOwners reads who owns an account
+type AccountOwners interface {
+	AccountOwner(accountID string) (string, error)
+}
+
+// MandateService manages direct debit mandates and merchant-initiated collections
+type MandateService struct {
+	Repo     MandateRepository
+	Payments *PaymentService
+	// Accounts checks that the payer owns the account a mandate debits
+	Accounts AccountOwners
+	producer *kafka.Producer
+}
+
+// NewMandateService creates a mandate service. The payment service is given access
+// to the mandate repository so collections are enforced in the transfer path.
+func NewMandateService(repo MandateRepository, payments *PaymentService, producer *kafka.Producer) *MandateService {
+	svc := &MandateService{
+		Repo:     repo,
+		Payments: payments,
+		producer: producer,
+	}
+	if payments != nil {
+		payments.mandates = repo
+		svc.Accounts = payments
+	}
+	return svc
+}
+
+// RegisterMerchant creates a merchant and returns its API key. The plain key is only
+// returned once; only its hash is persisted.
+func (s *MandateService) RegisterMerchant(ownerUserID, name, settlementAccountID string) (*model.Merchant, string, error) {
+	ownerUUID, err := uuid.Parse(ownerUserID)
+	if err != nil {
+		return nil, "", errors.New("invalid user id")
+	}
+	settlementUUID, err := uuid.Parse(settlementAccountID)
+	if err != nil {
+		return nil, "", errors.New("invalid settlement account id")
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	apiKey := "mk_" + hex.EncodeToString(raw)
+
+	merchant := &model.Merchant{
+		OwnerUserID:         ownerUUID,
+		Name:                name,
+		SettlementAccountID: settlementUUID,
+		APIKeyHash:          hashAPIKey(apiKey),
+		Active:              true,
+	}
+	if err := s.Repo.CreateMerchant(merchant); err != nil {
+		return nil, "", err
+	}
+	return merchant, apiKey, nil
+}
+
+// AuthenticateMerchant resolves a merchant from a plain API key
+func (s *MandateService) AuthenticateMerchant(apiKey string) (*model.Merchant, error) {
+	if apiKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	merchant, err := s.Repo.GetMerchantByAPIKeyHash(hashAPIKey(apiKey))
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return merchant, nil
+}
+
+// CreateMandateRequest holds the merchant-supplied mandate terms
+type CreateMandateRequest struct {
+	UserID          string
+	DebtorAccountID string
+	Reference       string
+	Currency        string
+	MaxAmount       string
+	MonthlyLimit    string
+	ExpiresAt       *time.Time
+}
+
+// CreateMandate creates a mandate awaiting approval by the payer
+func (s *MandateService) CreateMandate(merchantID string, req CreateMandateRequest) (*model.Mandate, error) {
+	merchantUUID, err := uuid.Parse(merchantID)
+	if err != nil {
+		return nil, ErrMerchantNotFound
+	}
+	userUUID, err := uuid.Parse(req.UserID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	debtorUUID, err := uuid.Parse(req.DebtorAccountID)
+	if err != nil {
+		return nil, errors.New("invalid debtor account id")
+	}
+
+	maxAmount, err := decimal.NewFromString(req.MaxAmount)
+	if err != nil || maxAmount.LessThanOrEqual(decimal.Zero) {
+		return nil, errors.New("max amount must be greater than zero")
+	}
+	monthlyLimit := decimal.Zero
+	if req.MonthlyLimit != "" {
+		monthlyLimit, err = decimal.NewFromString(req.MonthlyLimit)
+		if err != nil || monthlyLimit.IsNegative() {
+			return nil, errors.New("invalid monthly limit")
+		}
+		if monthlyLimit.IsPositive() && monthlyLimit.LessThan(maxAmount) {
+			return nil, errors.New("monthly limit must not be lower than max amount")
+		}
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+	if err := s.checkDebtorAccount(userUUID, debtorUUID); err != nil {
+		return nil, err
+	}
+
+	mandate := &model.Mandate{
+		MerchantID:      merchantUUID,
+		UserID:          userUUID,
+		DebtorAccountID: debtorUUID,
+		Reference:       req.Reference,
+		Currency:        req.Currency,
+		MaxAmount:       maxAmount,
+		MonthlyLimit:    monthlyLimit,
+		Status:          model.MandatePendingApproval,
+		ExpiresAt:       req.ExpiresAt,
+	}
+	if err := s.Repo.CreateMandate(mandate); err != nil {
+		return nil, err
+	}
+
+	s.publish(kafka.TopicMandateCreated, mandate)
+	return mandate, nil
+}
+
+// GetMerchantMandate returns a mandate owned by the given merchant
+func (s *MandateService) GetMerchantMandate(merchantID, mandateID string) (*model.Mandate, error) {
+	mandate, err := s.getMandate(mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate.MerchantID.String() != merchantID {
+		return nil, ErrMandateForbidden
+	}
+	return mandate, nil
+}
+
+// ListUserMandates returns mandates where the user is the payer
+func (s *MandateService) ListUserMandates(userID string) ([]model.Mandate, error) {
+	return s.Repo.ListMandatesByUser(userID)
+}
+
+// ApproveMandate activates a pending mandate on behalf of the payer
+func (s *MandateService) ApproveMandate(userID, mandateID string) (*model.Mandate, error) {
+	mandate, err := s.getUserMandate(userID, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate.Status != model.MandatePendingApproval {
+		return nil, ErrMandateInvalidState
+	}
+	if mandate.IsExpired(time.Now()) {
+		return nil, ErrMandateExpired
+	}
+	// The merchant named the debtor account, so the payer must own it before
+	// the merchant may collect from it
+	if err := s.checkDebtorAccount(mandate.UserID, mandate.DebtorAccountID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	mandate.Status = model.MandateActive
+	mandate.ApprovedAt = &now
+	if err := s.Repo.SaveMandate(mandate); err != nil {
+		return nil, err
+	}
+
+	s.publish(kafka.TopicMandateApproved, mandate)
+	return mandate, nil
+}
+
+// RevokeMandate lets the payer revoke a pending or active mandate
+func (s *MandateService) RevokeMandate(userID, mandateID string) (*model.Mandate, error) {
+	mandate, err := s.getUserMandate(userID, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.terminate(mandate, model.MandateRevoked); err != nil {
+		return nil, err
+	}
+
+	s.publish(kafka.TopicMandateRevoked, mandate)
+	return mandate, nil
+}
+
+// CancelMandate lets the merchant cancel a pending or active mandate
+func (s *MandateService) CancelMandate(merchantID, mandateID string) (*model.Mandate, error) {
+	mandate, err := s.GetMerchantMandate(merchantID, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.terminate(mandate, model.MandateCancelled); err != nil {
+		return nil, err
+	}
+
+	s.publish(kafka.TopicMandateCancelled, mandate)
+	return mandate, nil
+}
+
+// Collect pulls a payment from the payer's account into the merchant's settlement account
+func (s *MandateService) Collect(merchant *model.Merchant, mandateID, amountStr, desc string) (*model.Payment, error) {
+	mandate, err := s.GetMerchantMandate(merchant.ID.String(), mandateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if desc == "" {
+		desc = "Direct debit " + mandate.Reference
+	}
+
+	return s.Payments.initiateTransfer(transferParams{
+		FromAccountID: mandate.DebtorAccountID.String(),
+		ToAccountID:   merchant.SettlementAccountID.String(),
+		Amount:        amountStr,
+		Currency:      mandate.Currency,
+		Description:   desc,
+		Mandate:       mandate,
+		PaymentType:   model.PaymentTypeDirectDebit,
+	})
+}
+
+func (s *MandateService) getMandate(mandateID string) (*model.Mandate, error) {
+	if _, err := uuid.Parse(mandateID); err != nil {
+		return nil, ErrMandateNotFound
+	}
+	mandate, err := s.Repo.GetMandate(mandateID)
+	if err != nil {
+		return nil, ErrMandateNotFound
+	}
+	return mandate, nil
+}
+
+func (s *MandateService) getUserMandate(userID, mandateID string) (*model.Mandate, error) {
+	mandate, err := s.getMandate(mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate.UserID.String() != userID {
+		// Don't reveal mandates that belong to other users
+		return nil, ErrMandateNotFound
+	}
+	return mandate, nil
+}
+
+// checkDebtorAccount fails unless the user owns the account a mandate debits
+func (s *MandateService) checkDebtorAccount(userID, accountID uuid.UUID) error {
+	if s.Accounts == nil {
+		return ErrAccountUnavailable
+	}
+	owner, err := s.Accounts.AccountOwner(accountID.String())
+	if err != nil {
+		return err
+	}
+	if owner != userID.String() {
+		return ErrMandateDebtorAccount
+	}
+	return nil
+}
+
+func (s *MandateService) terminate(mandate *model.Mandate, status model.MandateStatus) error {
+	if mandate.Status != model.MandatePendingApproval && mandate.Status != model.MandateActive {
+		return ErrMandateInvalidState
+	}
+	now := time.Now()
+	mandate.Status = status
+	mandate.RevokedAt = &now
+	return s.Repo.SaveMandate(mandate)
+}
+
+// publish emits a mandate lifecycle event when Kafka is available
+func (s *MandateService) publish(topic string, m *model.Mandate) {
+	if s.producer == nil {
+		return
+	}
+
+	event := kafka.MandateEvent{
+		MandateID:       m.ID.String(),
+		MerchantID:      m.MerchantID.String(),
+		UserID:          m.UserID.String(),
+		DebtorAccountID: m.DebtorAccountID.String(),
+		Status:          string(m.Status),
+		Timestamp:       time.Now().Format(time.RFC3339),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.producer.Produce(ctx, topic, m.ID.String(), event); err != nil {
+		slog.Error("Failed to publish mandate event", "mandate_id", m.ID, "topic", topic, "error", err)
+	}
+}
+
+// enforceMandate checks a collection against the mandate terms. Called from the transfer path.
+func (s *PaymentService) enforceMandate(m *model.Mandate, fromAcc uuid.UUID, amount decimal.Decimal, currency string) error {
+	now := time.Now()
+
+	if m.IsExpired(now) {
+		return ErrMandateExpired
+	}
+	if m.Status != model.MandateActive {
+		return ErrMandateNotActive
+	}
+	if m.DebtorAccountID != fromAcc {
+		return ErrMandateForbidden
+	}
+	if m.Currency != currency {
+		return fmt.Errorf("currency %s does not match mandate currency %s", currency, m.Currency)
+	}
+	if amount.GreaterThan(m.MaxAmount) {
+		return fmt.Errorf("%w: max per collection is %s", ErrMandateLimitExceeded, m.MaxAmount.String())
+	}
+
+	if m.MonthlyLimit.IsPositive() {
+		if s.mandates == nil {
+			return errors.New("mandate limits cannot be verified")
+		}
+		monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		collected, err := s.mandates.SumCollectedSince(m.ID.String(), monthStart)
+		if err != nil {
+			return fmt.Errorf("failed to check mandate usage: %w", err)
+		}
+		if collected.Add(amount).GreaterThan(m.MonthlyLimit) {
+			return fmt.Errorf("%w: monthly limit is %s, already collected %s",
+				ErrMandateLimitExceeded, m.MonthlyLimit.String(), collected.String())
+		}
+	}
+
+	return nil
+}
+
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMandateRepository is a mock implementation of MandateRepository
+type MockMandateRepository struct {
+	mock.Mock
+}
+
+func (m *MockMandateRepository) CreateMerchant(merchant *model.Merchant) error {
+	args := m.Called(merchant)
+	return args.Error(0)
+}
+
+func (m *MockMandateRepository) GetMerchantByAPIKeyHash(hash string) (*model.Merchant, error) {
+	args := m.Called(hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Merchant), args.Error(1)
+}
+
+func (m *MockMandateRepository) GetMerchant(id string) (*model.Merchant, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Merchant), args.Error(1)
+}
+
+func (m *MockMandateRepository) CreateMandate(mandate *model.Mandate) error {
+	args := m.Called(mandate)
+	return args.Error(0)
+}
+
+func (m *MockMandateRepository) GetMandate(id string) (*model.Mandate, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Mandate), args.Error(1)
+}
+
+func (m *MockMandateRepository) ListMandatesByUser(userID string) ([]model.Mandate, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.Mandate), args.Error(1)
+}
+
+func (m *MockMandateRepository) SaveMandate(mandate *model.Mandate) error {
+	args := m.Called(mandate)
+	return args.Error(0)
+}
+
+func (m *MockMandateRepository) SumCollectedSince(mandateID string, since time.Time) (decimal.Decimal, error) {
+	args := m.Called(mandateID, since)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+// MockAccountOwners is a mock implementation of AccountOwners
+type MockAccountOwners struct {
+	mock.Mock
+}
+
+func (m *MockAccountOwners) AccountOwner(accountID string) (string, error) {
+	args := m.Called(accountID)
+	return args.String(0), args.Error(1)
+}
+
+func newActiveMandate() *model.Mandate {
+	return &model.Mandate{
+		ID:              uuid.New(),
+		MerchantID:      uuid.New(),
+		UserID:          uuid.New(),
+		DebtorAccountID: uuid.New(),
+		Currency:        "USD",
+		MaxAmount:       decimal.NewFromInt(100),
+		Status:          model.MandateActive,
+	}
+}
+
+func TestRegisterMerchant_StoresOnlyKeyHash(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	svc := NewMandateService(mockRepo, nil, nil)
+
+	mockRepo.On("CreateMerchant", mock.AnythingOfType("*model.Merchant")).Return(nil)
+
+	merchant, apiKey, err := svc.RegisterMerchant(uuid.New().String(), "Gym", uuid.New().String())
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, apiKey)
+	assert.NotEqual(t, apiKey, merchant.APIKeyHash)
+	assert.Equal(t, hashAPIKey(apiKey), merchant.APIKeyHash)
+}
+
+func TestAuthenticateMerchant_InvalidKey(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	svc := NewMandateService(mockRepo, nil, nil)
+
+	mockRepo.On("GetMerchantByAPIKeyHash", hashAPIKey("bogus")).Return(nil, errors.New("not found"))
+
+	_, err := svc.AuthenticateMerchant("bogus")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+
+	_, err = svc.AuthenticateMerchant("")
+	assert.ErrorIs(t, err, ErrInvalidAPIKey)
+}
+
+func TestCreateMandate_Validation(t *testing.T) {
+	svc := NewMandateService(new(MockMandateRepository), nil, nil)
+	merchantID := uuid.New().String()
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		req  CreateMandateRequest
+	}{
+		{"invalid user", CreateMandateRequest{UserID: "x", DebtorAccountID: uuid.New().String(), MaxAmount: "10"}},
+		{"zero max amount", CreateMandateRequest{UserID: uuid.New().String(), DebtorAccountID: uuid.New().String(), MaxAmount: "0"}},
+		{"monthly below max", CreateMandateRequest{UserID: uuid.New().String(), DebtorAccountID: uuid.New().String(), MaxAmount: "50", MonthlyLimit: "10"}},
+		{"expired", CreateMandateRequest{UserID: uuid.New().String(), DebtorAccountID: uuid.New().String(), MaxAmount: "50", ExpiresAt: &past}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateMandate(merchantID, tt.req)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCreateMandate_DebtorAccountMustBelongToPayer(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	accounts := new(MockAccountOwners)
+	svc := NewMandateService(mockRepo, nil, nil)
+	svc.Accounts = accounts
+	payer, victimAccount, payerAccount := uuid.New(), uuid.New(), uuid.New()
+	accounts.On("AccountOwner", victimAccount.String()).Return(uuid.New().String(), nil)
+	accounts.On("AccountOwner", payerAccount.String()).Return(payer.String(), nil)
+	mockRepo.On("CreateMandate", mock.Anything).Return(nil)
+
+	req := CreateMandateRequest{UserID: payer.String(), DebtorAccountID: victimAccount.String(), Currency: "USD", MaxAmount: "10"}
+	_, err := svc.CreateMandate(uuid.New().String(), req)
+	assert.ErrorIs(t, err, ErrMandateDebtorAccount)
+	mockRepo.AssertNotCalled(t, "CreateMandate", mock.Anything)
+
+	req.DebtorAccountID = payerAccount.String()
+	mandate, err := svc.CreateMandate(uuid.New().String(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, model.MandatePendingApproval, mandate.Status)
+
+	// Without a way to check the owner, no mandate is created
+	svc.Accounts = nil
+	_, err = svc.CreateMandate(uuid.New().String(), req)
+	assert.ErrorIs(t, err, ErrAccountUnavailable)
+}
+
+func TestApproveMandate_DebtorAccountOfAnotherUser(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	accounts := new(MockAccountOwners)
+	svc := NewMandateService(mockRepo, nil, nil)
+	svc.Accounts = accounts
+
+	// A merchant named its own user as payer and someone else's account
+	mandate := newActiveMandate()
+	mandate.Status = model.MandatePendingApproval
+	mockRepo.On("GetMandate", mandate.ID.String()).Return(mandate, nil)
+	accounts.On("AccountOwner", mandate.DebtorAccountID.String()).Return(uuid.New().String(), nil)
+
+	_, err := svc.ApproveMandate(mandate.UserID.String(), mandate.ID.String())
+	assert.ErrorIs(t, err, ErrMandateDebtorAccount)
+	assert.Equal(t, model.MandatePendingApproval, mandate.Status)
+	mockRepo.AssertNotCalled(t, "SaveMandate", mock.Anything)
+}
+
+func TestApproveMandate(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	accounts := new(MockAccountOwners)
+	svc := NewMandateService(mockRepo, nil, nil)
+	svc.Accounts = accounts
+
+	mandate := newActiveMandate()
+	mandate.Status = model.MandatePendingApproval
+
+	mockRepo.On("GetMandate", mandate.ID.String()).Return(mandate, nil)
+	accounts.On("AccountOwner", mandate.DebtorAccountID.String()).Return(mandate.UserID.String(), nil)
+	mockRepo.On("SaveMandate", mandate).Return(nil)
+
+	// Another user cannot approve
+	_, err := svc.ApproveMandate(uuid.New().String(), mandate.ID.String())
+	assert.ErrorIs(t, err, ErrMandateNotFound)
+
+	approved, err := svc.ApproveMandate(mandate.UserID.String(), mandate.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, model.MandateActive, approved.Status)
+	assert.NotNil(t, approved.ApprovedAt)
+
+	// Approving twice is not allowed
+	_, err = svc.ApproveMandate(mandate.UserID.String(), mandate.ID.String())
+	assert.ErrorIs(t, err, ErrMandateInvalidState)
+}
+
+func TestRevokeMandate(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	svc := NewMandateService(mockRepo, nil, nil)
+
+	mandate := newActiveMandate()
+	mockRepo.On("GetMandate", mandate.ID.String()).Return(mandate, nil)
+	mockRepo.On("SaveMandate", mandate).Return(nil)
+
+	revoked, err := svc.RevokeMandate(mandate.UserID.String(), mandate.ID.String())
+	assert.NoError(t, err)
+	assert.Equal(t, model.MandateRevoked, revoked.Status)
+
+	_, err = svc.RevokeMandate(mandate.UserID.String(), mandate.ID.String())
+	assert.ErrorIs(t, err, ErrMandateInvalidState)
+}
+
+func TestEnforceMandate(t *testing.T) {
+	mockRepo := new(MockMandateRepository)
+	svc := &PaymentService{mandates: mockRepo}
+
+	t.Run("active within limit", func(t *testing.T) {
+		m := newActiveMandate()
+		assert.NoError(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(50), "USD"))
+	})
+
+	t.Run("not active", func(t *testing.T) {
+		m := newActiveMandate()
+		m.Status = model.MandateRevoked
+		assert.ErrorIs(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(50), "USD"), ErrMandateNotActive)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		m := newActiveMandate()
+		past := time.Now().Add(-time.Minute)
+		m.ExpiresAt = &past
+		assert.ErrorIs(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(50), "USD"), ErrMandateExpired)
+	})
+
+	t.Run("wrong debtor account", func(t *testing.T) {
+		m := newActiveMandate()
+		assert.ErrorIs(t, svc.enforceMandate(m, uuid.New(), decimal.NewFromInt(50), "USD"), ErrMandateForbidden)
+	})
+
+	t.Run("above max amount", func(t *testing.T) {
+		m := newActiveMandate()
+		assert.ErrorIs(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(101), "USD"), ErrMandateLimitExceeded)
+	})
+
+	t.Run("currency mismatch", func(t *testing.T) {
+		m := newActiveMandate()
+		assert.Error(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(10), "EUR"))
+	})
+
+	t.Run("monthly limit reached", func(t *testing.T) {
+		m := newActiveMandate()
+		m.MonthlyLimit = decimal.NewFromInt(150)
+		mockRepo.On("SumCollectedSince", m.ID.String(), mock.AnythingOfType("time.Time")).Return(decimal.NewFromInt(100), nil)
+
+		assert.ErrorIs(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(60), "USD"), ErrMandateLimitExceeded)
+		assert.NoError(t, svc.enforceMandate(m, m.DebtorAccountID, decimal.NewFromInt(50), "USD"))
+	})
+}
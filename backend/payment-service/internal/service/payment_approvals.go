@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// expiryBatchSize caps how many payments one approval expiry run expires
+const expiryBatchSize = 500
+
+var (
+	ErrApprovalsDisabled        = errors.New("payment approvals are not enabled")
+	ErrNotAwaitingApproval      = errors.New("the payment is not awaiting approval")
+	ErrApprovalExpired          = errors.New("the payment's approval window has closed")
+	ErrSelfApproval             = errors.New("the payment's initiator cannot approve it")
+	ErrAlreadyDecided           = errors.New("you already decided on this payment")
+	ErrApproverRole             = errors.New("only organization owners and admins can approve payments")
+	ErrApprovalCheckUnavailable = errors.New("the paying account could not be checked for approval rules, try again")
+)
+
+// ApprovalPolicy decides which transfers from business accounts need
+// approvals before they are made, and how many
+type ApprovalPolicy struct {
+	// Threshold is the amount above which a transfer from a business account
+	// waits for approvals
+	Threshold decimal.Decimal
+	// Required is how many distinct approvers must approve, the M of M-of-N;
+	// the N are the organization's owners and admins other than the initiator
+	Required int
+	// Window is how long approvals are gathered before the payment expires
+	Window time.Duration
+}
+
+// DefaultApprovalPolicy holds business transfers above 5000 for two
+// approvals, gathered within two days
+func DefaultApprovalPolicy() ApprovalPolicy {
+	return ApprovalPolicy{
+		Threshold: decimal.NewFromInt(5000),
+		Required:  2,
+		Window:    48 * time.Hour,
+	}
+}
+
+// approverRoles are the organization roles that may approve payments
+var approverRoles = []string{tenant.RoleOwner, tenant.RoleAdmin}
+
+// PaymentApprovalRepository stores the approvers' decisions on payments
+// awaiting approval
+type PaymentApprovalRepository interface {
+	// AddApproval records a decision, reporting false when the approver
+	// already decided on the payment
+	AddApproval(a *model.PaymentApproval) (bool, error)
+	// ListApprovals returns the decisions on a payment, oldest first
+	ListApprovals(paymentID uuid.UUID) ([]model.PaymentApproval, error)
+	// ReleaseAwaiting moves a payment awaiting approval to status, reporting
+	// false when it no longer awaits approval
+	ReleaseAwaiting(id uuid.UUID, status model.PaymentStatus) (bool, error)
+	ListAwaitingApproval(orgID uuid.UUID) ([]model.Payment, error)
+	ExpiredApprovals(before time.Time, limit int) ([]model.Payment, error)
+}
+
+// SetApprovals holds user transfers from business accounts above the policy's
+// threshold until enough members of the organization approve them. Payments
+// not approved in time are expired by ApprovalExpiryJob.
+func (s *PaymentService) SetApprovals(policy ApprovalPolicy, repo PaymentApprovalRepository) {
+	s.approval = policy
+	s.approvals = repo
+}
+
+// pendingApproval is what a transfer waits for before it is made
+type pendingApproval struct {
+	OrgID     uuid.UUID
+	Required  int
+	ExpiresAt time.Time
+}
+
+// approvalFor returns the approvals a transfer needs, or nil if it can be made
+// straight away. Every transfer a user asks for is held, whichever feature it
+// comes through, so each must carry the user's ID; transfers between the
+// bank's own accounts are not, and direct debits were authorized by their
+// mandate. A transfer above the threshold from an account that could not be
+// read is refused, since it may be a business account.
+func (s *PaymentService) approvalFor(p transferParams, account *AccountResponse, amount decimal.Decimal) (*pendingApproval, error) {
+	if s.approvals == nil || p.UserID == "" || p.Mandate != nil || !amount.GreaterThan(s.approval.Threshold) {
+		return nil, nil
+	}
+	if account == nil {
+		return nil, ErrApprovalCheckUnavailable
+	}
+	if account.OrgID == nil {
+		return nil, nil
+	}
+	orgID, err := uuid.Parse(*account.OrgID)
+	if err != nil {
+		return nil, ErrApprovalCheckUnavailable
+	}
+	return &pendingApproval{OrgID: orgID, Required: s.approval.Required, ExpiresAt: time.Now().UTC().Add(s.approval.Window)}, nil
+}
+
+// PaymentApprovals is a payment held for approval with the decisions made on it
+type PaymentApprovals struct {
+	Payment   *model.Payment          `json:"payment"`
+	Required  int                     `json:"approvals_required"`
+	Approved  int                     `json:"approvals_received"`
+	ExpiresAt *time.Time              `json:"expires_at"`
+	Decisions []model.PaymentApproval `json:"decisions"`
+}
+
+// Approver is a member of an organization deciding on its payments
+type Approver struct {
+	UserID  string
+	OrgID   string
+	OrgRole string
+}
+
+// ListAwaitingApproval returns the organization's payments awaiting approval,
+// oldest first
+func (s *PaymentService) ListAwaitingApproval(orgID string) ([]model.Payment, error) {
+	if s.approvals == nil {
+		return nil, ErrApprovalsDisabled
+	}
+	orgUUID, err := uuid.Parse(orgID)
+	if err != nil {
+		return []model.Payment{}, nil
+	}
+	payments, err := s.approvals.ListAwaitingApproval(orgUUID)
+	if payments == nil {
+		payments = []model.Payment{}
+	}
+	return payments, err
+}
+
+// PaymentApprovalStatus returns the decisions on a payment held for approval.
+// Members of its organization and its initiator can see it.
+func (s *PaymentService) PaymentApprovalStatus(userID, orgID, paymentID string) (*PaymentApprovals, error) {
+	if s.approvals == nil {
+		return nil, ErrApprovalsDisabled
+	}
+	payment, err := s.approvalPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	initiator := payment.UserID != nil && payment.UserID.String() == userID
+	if !initiator && payment.OrgID.String() != orgID {
+		return nil, ErrPaymentNotFound
+	}
+	return s.paymentApprovals(payment)
+}
+
+// ApprovePayment records an owner's or admin's approval of one of their
+// organization's payments. The approval that completes the required number
+// releases the payment, which is then made like any other transfer. The
+// initiator cannot approve their own payment and nobody approves twice.
+func (s *PaymentService) ApprovePayment(approver Approver, paymentID, comment string) (*PaymentApprovals, error) {
+	payment, err := s.decide(approver, paymentID, model.ApprovalApproved, comment)
+	if err != nil {
+		return nil, err
+	}
+	approvals, err := s.paymentApprovals(payment)
+	if err != nil {
+		return nil, err
+	}
+	s.recordTransition(payment.ID, model.TransferApproved, model.UserActor(approver.UserID),
+		fmt.Sprintf("approval %d of %d", approvals.Approved, payment.ApprovalsRequired))
+	if approvals.Approved < payment.ApprovalsRequired {
+		return approvals, nil
+	}
+
+	released, err := s.approvals.ReleaseAwaiting(payment.ID, model.StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	if !released {
+		// Released by a concurrent approval, or expired meanwhile
+		if payment, err = s.payments.GetPayment(payment.ID.String()); err != nil {
+			return nil, err
+		}
+		approvals.Payment = payment
+		return approvals, nil
+	}
+	payment.Status = model.StatusPending
+	slog.Info("Payment approved", "payment_id", payment.ID, "org_id", payment.OrgID, "approvals", approvals.Approved)
+
+	userID := ""
+	if payment.UserID != nil {
+		userID = payment.UserID.String()
+	}
+	approvals.Payment, err = s.dispatch(payment, userID, payment.FromAccountID.String(), payment.ToAccountID.String(), payment.Amount.String(), payment.Currency, payment.Description)
+	return approvals, err
+}
+
+// RejectPayment records an owner's or admin's rejection of one of their
+// organization's payments, which rejects the payment outright
+func (s *PaymentService) RejectPayment(approver Approver, paymentID, comment string) (*PaymentApprovals, error) {
+	payment, err := s.decide(approver, paymentID, model.ApprovalRejected, comment)
+	if err != nil {
+		return nil, err
+	}
+	rejected, err := s.approvals.ReleaseAwaiting(payment.ID, model.StatusRejected)
+	if err != nil {
+		return nil, err
+	}
+	if rejected {
+		payment.Status = model.StatusRejected
+		s.recordTransition(payment.ID, model.TransferRejected, model.UserActor(approver.UserID), comment)
+		slog.Info("Payment rejected", "payment_id", payment.ID, "org_id", payment.OrgID, "approver_id", approver.UserID)
+	} else if payment, err = s.payments.GetPayment(payment.ID.String()); err != nil {
+		return nil, err
+	}
+	return s.paymentApprovals(payment)
+}
+
+// decide records an approver's decision on a payment that still awaits
+// approval and returns the payment
+func (s *PaymentService) decide(approver Approver, paymentID string, decision model.ApprovalDecision, comment string) (*model.Payment, error) {
+	if s.approvals == nil {
+		return nil, ErrApprovalsDisabled
+	}
+	approverID, err := uuid.Parse(approver.UserID)
+	if err != nil {
+		return nil, ErrApproverRole
+	}
+	payment, err := s.approvalPayment(paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment.OrgID.String() != approver.OrgID {
+		return nil, ErrPaymentNotFound
+	}
+	if !isApproverRole(approver.OrgRole) {
+		return nil, ErrApproverRole
+	}
+	switch {
+	case payment.Status != model.StatusAwaitingApproval:
+		return nil, fmt.Errorf("%w: the payment is %s", ErrNotAwaitingApproval, payment.Status)
+	case payment.ApprovalExpiresAt != nil && time.Now().After(*payment.ApprovalExpiresAt):
+		return nil, ErrApprovalExpired
+	case payment.UserID != nil && *payment.UserID == approverID:
+		return nil, ErrSelfApproval
+	}
+
+	added, err := s.approvals.AddApproval(&model.PaymentApproval{
+		PaymentID:  payment.ID,
+		ApproverID: approverID,
+		OrgRole:    approver.OrgRole,
+		Decision:   decision,
+		Comment:    strings.TrimSpace(comment),
+		CreatedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, ErrAlreadyDecided
+	}
+	return payment, nil
+}
+
+// approvalPayment returns a payment that was held for approval; other
+// payments are not found
+func (s *PaymentService) approvalPayment(paymentID string) (*model.Payment, error) {
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	payment, err := s.payments.GetPayment(paymentID)
+	if err != nil || payment.OrgID == nil {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+func (s *PaymentService) paymentApprovals(payment *model.Payment) (*PaymentApprovals, error) {
+	decisions, err := s.approvals.ListApprovals(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+	approvals := &PaymentApprovals{
+		Payment:   payment,
+		Required:  payment.ApprovalsRequired,
+		ExpiresAt: payment.ApprovalExpiresAt,
+		Decisions: []model.PaymentApproval{},
+	}
+	for _, d := range decisions {
+		if d.Decision == model.ApprovalApproved {
+			approvals.Approved++
+		}
+		approvals.Decisions = append(approvals.Decisions, d)
+	}
+	return approvals, nil
+}
+
+func isApproverRole(role string) bool {
+	for _, r := range approverRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// ApprovalExpiryJob expires the payments whose approval window closed before
+// they gathered enough approvals
+func (s *PaymentService) ApprovalExpiryJob(_ context.Context, _ *jobs.Job) error {
+	if s.approvals == nil {
+		return ErrApprovalsDisabled
+	}
+	expired, err := s.approvals.ExpiredApprovals(time.Now(), expiryBatchSize)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, payment := range expired {
+		released, err := s.approvals.ReleaseAwaiting(payment.ID, model.StatusExpired)
+		if err != nil {
+			return err
+		}
+		if released {
+			count++
+			s.recordTransition(payment.ID, model.TransferExpired, model.ActorPaymentService, "not approved in time")
+		}
+	}
+	if count > 0 {
+		slog.Info("Expired payments awaiting approval", "count", count)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryApprovals is an in-memory PaymentApprovalRepository over the payments
+// of a memoryPaymentStates
+type memoryApprovals struct {
+	states    *memoryPaymentStates
+	approvals []model.PaymentApproval
+}
+
+func (m *memoryApprovals) AddApproval(a *model.PaymentApproval) (bool, error) {
+	for _, existing := range m.approvals {
+		if existing.PaymentID == a.PaymentID && existing.ApproverID == a.ApproverID {
+			return false, nil
+		}
+	}
+	m.approvals = append(m.approvals, *a)
+	return true, nil
+}
+
+func (m *memoryApprovals) ListApprovals(paymentID uuid.UUID) ([]model.PaymentApproval, error) {
+	var out []model.PaymentApproval
+	for _, a := range m.approvals {
+		if a.PaymentID == paymentID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryApprovals) ReleaseAwaiting(id uuid.UUID, status model.PaymentStatus) (bool, error) {
+	p := m.states.payments[id.String()]
+	if p == nil || p.Status != model.StatusAwaitingApproval {
+		return false, nil
+	}
+	p.Status = status
+	return true, nil
+}
+
+func (m *memoryApprovals) ListAwaitingApproval(orgID uuid.UUID) ([]model.Payment, error) {
+	var out []model.Payment
+	for _, p := range m.states.payments {
+		if p.OrgID != nil && *p.OrgID == orgID && p.Status == model.StatusAwaitingApproval {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryApprovals) ExpiredApprovals(before time.Time, limit int) ([]model.Payment, error) {
+	var out []model.Payment
+	for _, p := range m.states.payments {
+		if p.Status == model.StatusAwaitingApproval && p.ApprovalExpiresAt.Before(before) && len(out) < limit {
+			out = append(out, *p)
+		}
+	}
+	return out, nil
+}
+
+// newApprovalService returns a service holding a standard rail payment of an
+// organization for two approvals, and the organization's ID
+func newApprovalService(t *testing.T) (*PaymentService, *memoryApprovals, *model.Payment, uuid.UUID) {
+	t.Helper()
+	svc, states, payment, _ := newCancellationService(model.StatusAwaitingApproval)
+	orgID := uuid.New()
+	expires := time.Now().UTC().Add(time.Hour)
+	payment.OrgID = &orgID
+	payment.ApprovalsRequired = 2
+	payment.ApprovalExpiresAt = &expires
+	payment.Rail = model.RailStandard
+	payment.EstimatedArrival = &expires
+	approvals := &memoryApprovals{states: states}
+	svc.SetApprovals(DefaultApprovalPolicy(), approvals)
+	return svc, approvals, payment, orgID
+}
+
+func TestApprovalFor(t *testing.T) {
+	svc := &PaymentService{}
+	svc.SetApprovals(ApprovalPolicy{Threshold: decimal.NewFromInt(5000), Required: 3, Window: time.Hour}, &memoryApprovals{})
+	orgID := uuid.New().String()
+	business := &AccountResponse{ID: "acct", OrgID: &orgID}
+	transfer := transferParams{UserID: uuid.New().String()}
+	above := decimal.NewFromInt(5001)
+
+	approval, err := svc.approvalFor(transfer, business, above)
+	require.NoError(t, err)
+	require.NotNil(t, approval)
+	assert.Equal(t, orgID, approval.OrgID.String())
+	assert.Equal(t, 3, approval.Required)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), approval.ExpiresAt, time.Minute)
+
+	for name, tt := range map[string]struct {
+		params  transferParams
+		account *AccountResponse
+		amount  decimal.Decimal
+	}{
+		"at the threshold": {transfer, business, decimal.NewFromInt(5000)},
+		"personal account": {transfer, &AccountResponse{ID: "acct"}, above},
+		"direct debit":     {transferParams{UserID: transfer.UserID, Mandate: &model.Mandate{}}, business, above},
+		"system transfer":  {transferParams{}, business, above},
+	} {
+		approval, err := svc.approvalFor(tt.params, tt.account, tt.amount)
+		assert.NoError(t, err, name)
+		assert.Nil(t, approval, name)
+	}
+
+	// An unreadable account may be a business account
+	_, err = svc.approvalFor(transfer, nil, above)
+	assert.ErrorIs(t, err, ErrApprovalCheckUnavailable)
+}
+
+func TestApprovePayment_ReleasesAfterRequiredApprovals(t *testing.T) {
+	svc, approvals, payment, orgID := newApprovalService(t)
+	id := payment.ID.String()
+	admin := func() Approver {
+		return Approver{UserID: uuid.New().String(), OrgID: orgID.String(), OrgRole: tenant.RoleAdmin}
+	}
+
+	initiator := Approver{UserID: payment.UserID.String(), OrgID: orgID.String(), OrgRole: tenant.RoleOwner}
+	_, err := svc.ApprovePayment(initiator, id, "")
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	viewer := admin()
+	viewer.OrgRole = tenant.RoleViewer
+	_, err = svc.ApprovePayment(viewer, id, "")
+	assert.ErrorIs(t, err, ErrApproverRole)
+	outsider := admin()
+	outsider.OrgID = uuid.New().String()
+	_, err = svc.ApprovePayment(outsider, id, "")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+	assert.Empty(t, approvals.approvals)
+
+	first := admin()
+	result, err := svc.ApprovePayment(first, id, " supplier invoice 42 ")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusAwaitingApproval, result.Payment.Status)
+	assert.Equal(t, 1, result.Approved)
+	assert.Equal(t, "supplier invoice 42", result.Decisions[0].Comment)
+	_, err = svc.ApprovePayment(first, id, "")
+	assert.ErrorIs(t, err, ErrAlreadyDecided, "one approver cannot count twice")
+
+	result, err = svc.ApprovePayment(admin(), id, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Approved)
+	assert.Equal(t, model.StatusPending, result.Payment.Status, "the standard rail batch now posts it")
+	assert.Equal(t, model.StatusPending, approvals.states.payments[id].Status)
+
+	_, err = svc.ApprovePayment(admin(), id, "")
+	assert.ErrorIs(t, err, ErrNotAwaitingApproval)
+}
+
+func TestRejectPayment(t *testing.T) {
+	svc, approvals, payment, orgID := newApprovalService(t)
+	id := payment.ID.String()
+	owner := Approver{UserID: uuid.New().String(), OrgID: orgID.String(), OrgRole: tenant.RoleOwner}
+
+	result, err := svc.RejectPayment(owner, id, "not ours")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusRejected, result.Payment.Status)
+	require.Len(t, result.Decisions, 1)
+	assert.Equal(t, model.ApprovalRejected, result.Decisions[0].Decision)
+
+	_, err = svc.ApprovePayment(Approver{UserID: uuid.New().String(), OrgID: orgID.String(), OrgRole: tenant.RoleAdmin}, id, "")
+	assert.ErrorIs(t, err, ErrNotAwaitingApproval)
+
+	// The initiator sees the decisions on their payment without an organization token
+	status, err := svc.PaymentApprovalStatus(payment.UserID.String(), "", id)
+	require.NoError(t, err)
+	assert.Len(t, status.Decisions, 1)
+	_, err = svc.PaymentApprovalStatus(uuid.New().String(), "", id)
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+	assert.Equal(t, model.StatusRejected, approvals.states.payments[id].Status)
+}
+
+func TestApprovalExpiryJob(t *testing.T) {
+	svc, approvals, payment, orgID := newApprovalService(t)
+	id := payment.ID.String()
+	past := time.Now().UTC().Add(-time.Minute)
+	approvals.states.payments[id].ApprovalExpiresAt = &past
+
+	_, err := svc.ApprovePayment(Approver{UserID: uuid.New().String(), OrgID: orgID.String(), OrgRole: tenant.RoleAdmin}, id, "")
+	assert.ErrorIs(t, err, ErrApprovalExpired)
+
+	require.NoError(t, svc.ApprovalExpiryJob(context.Background(), nil))
+	assert.Equal(t, model.StatusExpired, approvals.states.payments[id].Status)
+	awaiting, err := svc.ListAwaitingApproval(orgID.String())
+	require.NoError(t, err)
+	assert.Empty(t, awaiting)
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/iso20022"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultAgentBIC identifies the bank in pacs.008 messages for the simulated clearing scheme
+const DefaultAgentBIC = "NEOBGB2LXXX"
+
+var (
+	ErrPaymentBatchNotFound   = errors.New("payment batch not found")
+	ErrDuplicatePaymentBatch  = errors.New("a batch with this message ID has already been imported")
+	ErrPaymentBatchNotCleared = errors.New("payment batch has no transfers to clear")
+	ErrPaymentBatchCleared    = errors.New("payment batch has already been sent to clearing")
+)
+
+// PaymentBatchRepository defines data access for imported payment batches
+type PaymentBatchRepository interface {
+	Create(b *model.PaymentBatch) error
+	GetByID(id string) (*model.PaymentBatch, error)
+	GetByMessageID(uploadedBy, messageID string) (*model.PaymentBatch, error)
+	Save(b *model.PaymentBatch) error
+}
+
+// BatchTransferInitiator makes the transfers of a batch on behalf of the user
+// who uploaded it, and reads who owns the accounts they debit
+type BatchTransferInitiator interface {
+	InitiateUserTransfer(ctx context.Context, userID, fromAcc, toAcc, amountStr, currency, desc, confirmation string, rail model.TransferRail) (*model.Payment, error)
+	AccountOwner(accountID string) (string, error)
+}
+
+// PaymentLookup loads payments created by the transfer path
+type PaymentLookup interface {
+	GetPayment(id string) (*model.Payment, error)
+}
+
+// ClearingConnector hands pacs.008 messages to a clearing system
+type ClearingConnector interface {
+	Submit(ctx context.Context, messageID string, document []byte) error
+}
+
+// SimulatedClearingConnector stands in for a real clearing system and only logs submissions
+type SimulatedClearingConnector struct{}
+
+func (SimulatedClearingConnector) Submit(_ context.Context, messageID string, document []byte) error {
+	slog.Info("Simulated clearing accepted pacs.008 message", "message_id", messageID, "bytes", len(document))
+	return nil
+}
+
+// PaymentBatchService imports ISO 20022 pain.001 files as batches of transfers
+// and exports them as pacs.008 messages for clearing
+type PaymentBatchService struct {
+	Repo      PaymentBatchRepository
+	Transfers BatchTransferInitiator
+	Payments  PaymentLookup
+	Connector ClearingConnector
+	AgentBIC  string
+}
+
+func NewPaymentBatchService(repo PaymentBatchRepository, transfers BatchTransferInitiator, payments PaymentLookup, connector ClearingConnector, agentBIC string) *PaymentBatchService {
+	if agentBIC == "" {
+		agentBIC = DefaultAgentBIC
+	}
+	return &PaymentBatchService{
+		Repo:      repo,
+		Transfers: transfers,
+		Payments:  payments,
+		Connector: connector,
+		AgentBIC:  agentBIC,
+	}
+}
+
+// ImportPain001 validates a pain.001 message and initiates one transfer per
+// credit transfer instruction. Accounts must be identified by internal account
+// IDs in Othr/Id, written as 32 hex digits without hyphens to fit Max34Text.
+// Each transfer is made as the uploading user, with the same limits, duplicate
+// checks and approvals as a transfer they make themselves, and only from
+// accounts they own. Instructions that cannot be executed are rejected
+// individually.
+func (s *PaymentBatchService) ImportPain001(ctx context.Context, userID string, document []byte) (*model.PaymentBatch, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+
+	initiation, err := iso20022.ParsePain001(document)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.Repo.GetByMessageID(userID, initiation.MessageID); err == nil {
+		return nil, ErrDuplicatePaymentBatch
+	}
+
+	batch := &model.PaymentBatch{
+		MessageID:            initiation.MessageID,
+		UploadedBy:           userUUID,
+		InitiatingParty:      initiation.InitiatingParty,
+		NumberOfTransactions: len(initiation.Transfers),
+		ControlSum:           decimal.Zero,
+		Status:               model.PaymentBatchProcessing,
+		Items:                make([]model.PaymentBatchItem, 0, len(initiation.Transfers)),
+	}
+	for _, t := range initiation.Transfers {
+		batch.ControlSum = batch.ControlSum.Add(t.Amount)
+		batch.Items = append(batch.Items, model.PaymentBatchItem{
+			EndToEndID:      t.EndToEndID,
+			InstructionID:   t.InstructionID,
+			DebtorName:      t.DebtorName,
+			DebtorAccount:   t.DebtorAccount,
+			CreditorName:    t.CreditorName,
+			CreditorAccount: t.CreditorAccount,
+			Amount:          t.Amount,
+			Currency:        t.Currency,
+			RemittanceInfo:  t.RemittanceInfo,
+		})
+	}
+
+	// Creating the batch first claims the message ID, so a file uploaded twice
+	// at the same time is only executed once
+	if err := s.Repo.Create(batch); err != nil {
+		if _, getErr := s.Repo.GetByMessageID(userID, initiation.MessageID); getErr == nil {
+			return nil, ErrDuplicatePaymentBatch
+		}
+		return nil, err
+	}
+
+	for i := range batch.Items {
+		s.executeItem(ctx, userID, &batch.Items[i])
+		if batch.Items[i].Status == model.PaymentBatchItemAccepted {
+			batch.Accepted++
+		} else {
+			batch.Rejected++
+		}
+	}
+
+	switch {
+	case batch.Rejected == 0:
+		batch.Status = model.PaymentBatchCompleted
+	case batch.Accepted == 0:
+		batch.Status = model.PaymentBatchRejected
+	default:
+		batch.Status = model.PaymentBatchPartial
+	}
+
+	if err := s.Repo.Save(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (s *PaymentBatchService) executeItem(ctx context.Context, userID string, item *model.PaymentBatchItem) {
+	debtor, err := uuid.Parse(item.DebtorAccount)
+	if err != nil {
+		item.Status = model.PaymentBatchItemRejected
+		item.Error = "debtor account must be an internal account ID"
+		return
+	}
+	creditor, err := uuid.Parse(item.CreditorAccount)
+	if err != nil {
+		item.Status = model.PaymentBatchItemRejected
+		item.Error = "creditor account must be an internal account ID"
+		return
+	}
+
+	owner, err := s.Transfers.AccountOwner(debtor.String())
+	if err != nil {
+		item.Status = model.PaymentBatchItemRejected
+		item.Error = err.Error()
+		return
+	}
+	if owner != userID {
+		item.Status = model.PaymentBatchItemRejected
+		item.Error = "debtor account does not belong to the uploader"
+		return
+	}
+
+	desc := item.RemittanceInfo
+	if desc == "" {
+		desc = item.EndToEndID
+	}
+	payment, err := s.Transfers.InitiateUserTransfer(ctx, userID, debtor.String(), creditor.String(), item.Amount.String(), item.Currency, desc, "", "")
+	if payment != nil {
+		item.PaymentID = &payment.ID
+	}
+	if err != nil {
+		item.Status = model.PaymentBatchItemRejected
+		item.Error = err.Error()
+		return
+	}
+	item.Status = model.PaymentBatchItemAccepted
+}
+
+// GetPaymentBatch returns a batch uploaded by the user
+func (s *PaymentBatchService) GetPaymentBatch(userID, batchID string) (*model.PaymentBatch, error) {
+	batch, err := s.Repo.GetByID(batchID)
+	if err != nil || batch.UploadedBy.String() != userID {
+		return nil, ErrPaymentBatchNotFound
+	}
+	return batch, nil
+}
+
+// ExportPacs008 renders the batch's executed transfers as a pacs.008 message.
+// Transfers that were rejected, are still awaiting approval, or whose payment
+// did not go through are left out.
+func (s *PaymentBatchService) ExportPacs008(userID, batchID string) (string, []byte, error) {
+	batch, err := s.GetPaymentBatch(userID, batchID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	messageID := "P8" + compactID(batch.ID)
+	settlement := iso20022.Settlement{
+		MessageID:      messageID,
+		CreatedAt:      time.Now(),
+		SettlementDate: time.Now(),
+		AgentBIC:       s.AgentBIC,
+	}
+	for _, item := range batch.Items {
+		if item.Status != model.PaymentBatchItemAccepted || item.PaymentID == nil {
+			continue
+		}
+		payment, err := s.Payments.GetPayment(item.PaymentID.String())
+		if err != nil {
+			return "", nil, err
+		}
+		if payment.Status != model.StatusPending && payment.Status != model.StatusCompleted {
+			continue
+		}
+		settlement.Transactions = append(settlement.Transactions, iso20022.SettlementTransaction{
+			InstructionID:   item.InstructionID,
+			EndToEndID:      item.EndToEndID,
+			TransactionID:   compactID(payment.ID),
+			UETR:            payment.ID.String(),
+			Amount:          payment.Amount,
+			Currency:        payment.Currency,
+			DebtorName:      iso20022.Truncate(item.DebtorName, 140),
+			DebtorAccount:   compactID(payment.FromAccountID),
+			CreditorName:    iso20022.Truncate(item.CreditorName, 140),
+			CreditorAccount: compactID(payment.ToAccountID),
+			RemittanceInfo:  iso20022.Truncate(item.RemittanceInfo, 140),
+		})
+	}
+	if len(settlement.Transactions) == 0 {
+		return "", nil, ErrPaymentBatchNotCleared
+	}
+
+	document, err := iso20022.RenderPacs008(settlement)
+	if err != nil {
+		return "", nil, err
+	}
+	return messageID, document, nil
+}
+
+// SubmitToClearing sends the batch's pacs.008 message to the clearing connector once
+func (s *PaymentBatchService) SubmitToClearing(ctx context.Context, userID, batchID string) (*model.PaymentBatch, error) {
+	batch, err := s.GetPaymentBatch(userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.ClearedAt != nil {
+		return nil, ErrPaymentBatchCleared
+	}
+
+	messageID, document, err := s.ExportPacs008(userID, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Connector.Submit(ctx, messageID, document); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	batch.ClearingMessageID = messageID
+	batch.ClearedAt = &now
+	if err := s.Repo.Save(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// compactID formats a UUID as 32 hex digits, which fits ISO 20022 Max34Text and Max35Text fields
+func compactID(id uuid.UUID) string {
+	return strings.ReplaceAll(id.String(), "-", "")
+}
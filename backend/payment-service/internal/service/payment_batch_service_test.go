@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/iso20022"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockPaymentBatchRepository is a mock implementation of PaymentBatchRepository
+type MockPaymentBatchRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentBatchRepository) Create(b *model.PaymentBatch) error {
+	args := m.Called(b)
+	return args.Error(0)
+}
+
+func (m *MockPaymentBatchRepository) GetByID(id string) (*model.PaymentBatch, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PaymentBatch), args.Error(1)
+}
+
+func (m *MockPaymentBatchRepository) GetByMessageID(uploadedBy, messageID string) (*model.PaymentBatch, error) {
+	args := m.Called(uploadedBy, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PaymentBatch), args.Error(1)
+}
+
+func (m *MockPaymentBatchRepository) Save(b *model.PaymentBatch) error {
+	args := m.Called(b)
+	return args.Error(0)
+}
+
+// MockPaymentLookup is a mock implementation of PaymentLookup
+type MockPaymentLookup struct {
+	mock.Mock
+}
+
+func (m *MockPaymentLookup) GetPayment(id string) (*model.Payment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+// MockBatchTransferInitiator is a mock implementation of BatchTransferInitiator
+type MockBatchTransferInitiator struct {
+	mock.Mock
+}
+
+func (m *MockBatchTransferInitiator) InitiateUserTransfer(_ context.Context, userID, fromAcc, toAcc, amountStr, currency, desc, confirmation string, rail model.TransferRail) (*model.Payment, error) {
+	args := m.Called(userID, fromAcc, toAcc, amountStr, currency, desc, confirmation, rail)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockBatchTransferInitiator) AccountOwner(accountID string) (string, error) {
+	args := m.Called(accountID)
+	return args.String(0), args.Error(1)
+}
+
+// recordingConnector keeps the messages it is asked to clear
+type recordingConnector struct {
+	submitted map[string][]byte
+}
+
+func (r *recordingConnector) Submit(_ context.Context, messageID string, document []byte) error {
+	r.submitted[messageID] = document
+	return nil
+}
+
+func pain001For(debtor, creditor uuid.UUID) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.001.001.09">
+  <CstmrCdtTrfInitn>
+    <GrpHdr><MsgId>BATCH-1</MsgId><CreDtTm>2026-10-01T09:30:00Z</CreDtTm><NbOfTxs>3</NbOfTxs><InitgPty><Nm>Acme Ltd</Nm></InitgPty></GrpHdr>
+    <PmtInf>
+      <PmtInfId>P1</PmtInfId><PmtMtd>TRF</PmtMtd><ReqdExctnDt><Dt>2026-10-02</Dt></ReqdExctnDt>
+      <Dbtr><Nm>Acme Ltd</Nm></Dbtr>
+      <DbtrAcct><Id><Othr><Id>` + compactID(debtor) + `</Id></Othr></Id></DbtrAcct>
+      <CdtTrfTxInf>
+        <PmtId><EndToEndId>E2E-1</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="USD">100.00</InstdAmt></Amt>
+        <CdtrAcct><Id><Othr><Id>` + compactID(creditor) + `</Id></Othr></Id></CdtrAcct>
+        <RmtInf><Ustrd>Invoice 42</Ustrd></RmtInf>
+      </CdtTrfTxInf>
+      <CdtTrfTxInf>
+        <PmtId><EndToEndId>E2E-2</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="USD">5000.00</InstdAmt></Amt>
+        <CdtrAcct><Id><Othr><Id>` + compactID(creditor) + `</Id></Othr></Id></CdtrAcct>
+      </CdtTrfTxInf>
+      <CdtTrfTxInf>
+        <PmtId><EndToEndId>E2E-3</EndToEndId></PmtId>
+        <Amt><InstdAmt Ccy="USD">10.00</InstdAmt></Amt>
+        <CdtrAcct><Id><IBAN>GB29NWBK60161331926819</IBAN></Id></CdtrAcct>
+      </CdtTrfTxInf>
+    </PmtInf>
+  </CstmrCdtTrfInitn>
+</Document>`)
+}
+
+func TestImportPain001_ExecutesTransfersAndRecordsOutcomes(t *testing.T) {
+	repo := new(MockPaymentBatchRepository)
+	transfers := new(MockBatchTransferInitiator)
+	svc := NewPaymentBatchService(repo, transfers, new(MockPaymentLookup), SimulatedClearingConnector{}, "")
+
+	userID := uuid.New().String()
+	debtor, creditor := uuid.New(), uuid.New()
+	payment := &model.Payment{ID: uuid.New(), Status: model.StatusPending}
+
+	repo.On("GetByMessageID", userID, "BATCH-1").Return(nil, errors.New("record not found"))
+	repo.On("Create", mock.AnythingOfType("*model.PaymentBatch")).Return(nil)
+	repo.On("Save", mock.AnythingOfType("*model.PaymentBatch")).Return(nil)
+	transfers.On("AccountOwner", debtor.String()).Return(userID, nil)
+	transfers.On("InitiateUserTransfer", userID, debtor.String(), creditor.String(), "100", "USD", "Invoice 42", "", model.TransferRail("")).Return(payment, nil)
+	transfers.On("InitiateUserTransfer", userID, debtor.String(), creditor.String(), "5000", "USD", "E2E-2", "", model.TransferRail("")).Return(nil, errors.New("insufficient funds"))
+
+	batch, err := svc.ImportPain001(context.Background(), userID, pain001For(debtor, creditor))
+
+	require.NoError(t, err)
+	assert.Equal(t, "BATCH-1", batch.MessageID)
+	assert.Equal(t, "Acme Ltd", batch.InitiatingParty)
+	assert.Equal(t, 3, batch.NumberOfTransactions)
+	assert.True(t, decimal.RequireFromString("5110").Equal(batch.ControlSum))
+	assert.Equal(t, 1, batch.Accepted)
+	assert.Equal(t, 2, batch.Rejected)
+	assert.Equal(t, model.PaymentBatchPartial, batch.Status)
+
+	assert.Equal(t, model.PaymentBatchItemAccepted, batch.Items[0].Status)
+	assert.Equal(t, payment.ID, *batch.Items[0].PaymentID)
+	assert.Equal(t, "insufficient funds", batch.Items[1].Error)
+	assert.Equal(t, "creditor account must be an internal account ID", batch.Items[2].Error)
+	transfers.AssertNumberOfCalls(t, "InitiateUserTransfer", 2)
+}
+
+func TestImportPain001_RejectsAccountsTheUploaderDoesNotOwn(t *testing.T) {
+	repo := new(MockPaymentBatchRepository)
+	transfers := new(MockBatchTransferInitiator)
+	svc := NewPaymentBatchService(repo, transfers, new(MockPaymentLookup), SimulatedClearingConnector{}, "")
+
+	userID := uuid.New().String()
+	debtor, creditor := uuid.New(), uuid.New()
+	repo.On("GetByMessageID", userID, "BATCH-1").Return(nil, errors.New("record not found"))
+	repo.On("Create", mock.AnythingOfType("*model.PaymentBatch")).Return(nil)
+	repo.On("Save", mock.AnythingOfType("*model.PaymentBatch")).Return(nil)
+	transfers.On("AccountOwner", debtor.String()).Return(uuid.New().String(), nil)
+
+	batch, err := svc.ImportPain001(context.Background(), userID, pain001For(debtor, creditor))
+
+	require.NoError(t, err)
+	assert.Equal(t, model.PaymentBatchRejected, batch.Status)
+	assert.Equal(t, "debtor account does not belong to the uploader", batch.Items[0].Error)
+	assert.Equal(t, "debtor account does not belong to the uploader", batch.Items[1].Error)
+	transfers.AssertNotCalled(t, "InitiateUserTransfer", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImportPain001_HoldsItemsFromBusinessAccountsForApproval(t *testing.T) {
+	repo := new(MockPaymentBatchRepository)
+	transfers := new(MockBatchTransferInitiator)
+	payments := new(MockPaymentLookup)
+	svc := NewPaymentBatchService(repo, transfers, payments, SimulatedClearingConnector{}, "")
+
+	userID := uuid.New()
+	debtor, creditor := uuid.New(), uuid.New()
+	orgID := uuid.New()
+	// The organization's approval policy holds the large payment, as it would
+	// one the user made themselves
+	sent := &model.Payment{ID: uuid.New(), FromAccountID: debtor, ToAccountID: creditor, Amount: decimal.NewFromInt(100), Currency: "USD", Status: model.StatusPending}
+	held := &model.Payment{ID: uuid.New(), FromAccountID: debtor, ToAccountID: creditor, Amount: decimal.NewFromInt(5000), Currency: "USD", Status: model.StatusAwaitingApproval, OrgID: &orgID}
+	var batch *model.PaymentBatch
+	repo.On("GetByMessageID", userID.String(), "BATCH-1").Return(nil, errors.New("record not found"))
+	repo.On("Create", mock.AnythingOfType("*model.PaymentBatch")).Run(func(args mock.Arguments) {
+		batch = args.Get(0).(*model.PaymentBatch)
+		batch.ID = uuid.New()
+	}).Return(nil)
+	repo.On("Save", mock.AnythingOfType("*model.PaymentBatch")).Return(nil)
+	transfers.On("AccountOwner", debtor.String()).Return(userID.String(), nil)
+	transfers.On("InitiateUserTransfer", userID.String(), debtor.String(), creditor.String(), "100", "USD", "Invoice 42", "", model.TransferRail("")).Return(sent, nil)
+	transfers.On("InitiateUserTransfer", userID.String(), debtor.String(), creditor.String(), "5000", "USD", "E2E-2", "", model.TransferRail("")).Return(held, nil)
+
+	imported, err := svc.ImportPain001(context.Background(), userID.String(), pain001For(debtor, creditor))
+	require.NoError(t, err)
+	assert.Equal(t, held.ID, *imported.Items[1].PaymentID)
+
+	// The held payment is not sent to clearing until it is approved
+	repo.On("GetByID", batch.ID.String()).Return(batch, nil)
+	payments.On("GetPayment", sent.ID.String()).Return(sent, nil)
+	payments.On("GetPayment", held.ID.String()).Return(held, nil)
+
+	_, document, err := svc.ExportPacs008(userID.String(), batch.ID.String())
+	require.NoError(t, err)
+	assert.Contains(t, string(document), "<EndToEndId>E2E-1</EndToEndId>")
+	assert.NotContains(t, string(document), "E2E-2")
+}
+
+func TestImportPain001_RejectsDuplicatesAndInvalidDocuments(t *testing.T) {
+	repo := new(MockPaymentBatchRepository)
+	svc := NewPaymentBatchService(repo, new(MockBatchTransferInitiator), new(MockPaymentLookup), SimulatedClearingConnector{}, "")
+	userID := uuid.New().String()
+
+	repo.On("GetByMessageID", userID, "BATCH-1").Return(&model.PaymentBatch{}, nil)
+	_, err := svc.ImportPain001(context.Background(), userID, pain001For(uuid.New(), uuid.New()))
+	assert.ErrorIs(t, err, ErrDuplicatePaymentBatch)
+
+	_, err = svc.ImportPain001(context.Background(), userID, []byte("<Document/>"))
+	var verr *iso20022.ValidationError
+	assert.ErrorAs(t, err, &verr)
+	repo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestSubmitToClearing_SendsExecutedTransfersOnce(t *testing.T) {
+	repo := new(MockPaymentBatchRepository)
+	payments := new(MockPaymentLookup)
+	connector := &recordingConnector{submitted: map[string][]byte{}}
+	svc := NewPaymentBatchService(repo, new(MockBatchTransferInitiator), payments, connector, "")
+
+	userID := uuid.New()
+	sent := &model.Payment{ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: decimal.NewFromInt(100), Currency: "USD", Status: model.StatusCompleted}
+	failed := &model.Payment{ID: uuid.New(), Status: model.StatusFailed}
+	batch := &model.PaymentBatch{
+		ID:         uuid.New(),
+		UploadedBy: userID,
+		Items: []model.PaymentBatchItem{
+			{EndToEndID: "E2E-1", Status: model.PaymentBatchItemAccepted, PaymentID: &sent.ID, RemittanceInfo: "Invoice 42"},
+			{EndToEndID: "E2E-2", Status: model.PaymentBatchItemAccepted, PaymentID: &failed.ID},
+			{EndToEndID: "E2E-3", Status: model.PaymentBatchItemRejected},
+		},
+	}
+
+	repo.On("GetByID", batch.ID.String()).Return(batch, nil)
+	repo.On("Save", batch).Return(nil).Once()
+	payments.On("GetPayment", sent.ID.String()).Return(sent, nil)
+	payments.On("GetPayment", failed.ID.String()).Return(failed, nil)
+
+	cleared, err := svc.SubmitToClearing(context.Background(), userID.String(), batch.ID.String())
+	require.NoError(t, err)
+	require.NotNil(t, cleared.ClearedAt)
+	assert.WithinDuration(t, time.Now(), *cleared.ClearedAt, time.Second)
+
+	document := string(connector.submitted[cleared.ClearingMessageID])
+	assert.Contains(t, document, "<EndToEndId>E2E-1</EndToEndId>")
+	assert.Contains(t, document, "<UETR>"+sent.ID.String()+"</UETR>")
+	assert.Contains(t, document, "<Id>"+compactID(sent.FromAccountID)+"</Id>")
+	assert.NotContains(t, document, "E2E-2")
+	assert.Equal(t, 1, strings.Count(document, "<CdtTrfTxInf>"))
+
+	_, err = svc.SubmitToClearing(context.Background(), userID.String(), batch.ID.String())
+	assert.ErrorIs(t, err, ErrPaymentBatchCleared)
+}
+
+func TestGetPaymentBatch_HidesOtherUsersBatches(t *testing.T) {
+	repo := new(MockPaymentBatchRepository)
+	svc := NewPaymentBatchService(repo, new(MockBatchTransferInitiator), new(MockPaymentLookup), SimulatedClearingConnector{}, "")
+	batch := &model.PaymentBatch{ID: uuid.New(), UploadedBy: uuid.New()}
+	repo.On("GetByID", batch.ID.String()).Return(batch, nil)
+
+	_, err := svc.GetPaymentBatch(uuid.New().String(), batch.ID.String())
+	assert.ErrorIs(t, err, ErrPaymentBatchNotFound)
+}
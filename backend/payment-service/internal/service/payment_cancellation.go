@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentNotCancellable = errors.New("only pending payments can be cancelled")
+	ErrCancellationFailed    = errors.New("cancellation could not be sent to the ledger, try again")
+)
+
+// PaymentCancelledError is returned when cancelling a payment that is no
+// longer pending. Status is the payment's status.
+type PaymentCancelledError struct {
+	Status model.PaymentStatus `json:"status"`
+}
+
+func (e *PaymentCancelledError) Error() string {
+	return fmt.Sprintf("%s: the payment is %s", ErrPaymentNotCancellable, e.Status)
+}
+
+func (e *PaymentCancelledError) Unwrap() error {
+	return ErrPaymentNotCancellable
+}
+
+// PaymentStateRepository changes a payment's status after it is created. Each
+// change only applies to a payment that is still pending.
+type PaymentStateRepository interface {
+	GetPayment(id string) (*model.Payment, error)
+	ResolvePayment(id string, status model.PaymentStatus, ledgerEntryID *uuid.UUID) (bool, error)
+	CancelPayment(id string, at time.Time) (bool, error)
+	RestorePending(id string) error
+}
+
+// CancelTransfer cancels one of the user's transfers while it is still
+// pending, before the ledger has posted it. The status is changed only if it
+// is still PENDING, so a payment completed meanwhile is not cancelled, and a
+// cancel event tells the ledger consumer to skip the payment. A cancellation
+// that reaches the ledger after it posted the payment is reversed there.
+func (s *PaymentService) CancelTransfer(ctx context.Context, userID, paymentID string) (*model.Payment, error) {
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	payment, err := s.payments.GetPayment(paymentID)
+	if err != nil || payment.UserID == nil || payment.UserID.String() != userID {
+		return nil, ErrPaymentNotFound
+	}
+	if payment.Status != model.StatusPending {
+		return nil, &PaymentCancelledError{Status: payment.Status}
+	}
+
+	now := time.Now().UTC()
+	cancelled, err := s.payments.CancelPayment(paymentID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		// Resolved between reading and cancelling it
+		if payment, err = s.payments.GetPayment(paymentID); err != nil {
+			return nil, err
+		}
+		return nil, &PaymentCancelledError{Status: payment.Status}
+	}
+	payment.Status = model.StatusCancelled
+	payment.CancelledAt = &now
+
+	if s.producer != nil {
+		event := s.paymentEvent(payment, userID, payment.FromAccountID.String(), payment.ToAccountID.String(), payment.Amount.String(), payment.Currency, payment.Description)
+		pubCtx, cancel := context.WithTimeout(paymentTraceContext(ctx, payment, userID, payment.FromAccountID.String()), 10*time.Second)
+		defer cancel()
+		if err := s.producer.Produce(pubCtx, kafka.TopicPaymentCancelled, payment.ID.String(), event); err != nil {
+			// Without the event the ledger would still post the payment, so it
+			// goes back to pending and the user can try again
+			slog.Error("Failed to publish payment cancellation", "payment_id", payment.ID, "error", err)
+			if restoreErr := s.payments.RestorePending(paymentID); restoreErr != nil {
+				slog.Error("Failed to restore payment after unsent cancellation", "payment_id", payment.ID, "error", restoreErr)
+			}
+			return nil, ErrCancellationFailed
+		}
+	}
+	slog.Info("Payment cancelled", "payment_id", payment.ID, "user_id", userID)
+	s.recordTransition(payment.ID, model.TransferCancelled, model.UserActor(userID), "")
+	return payment, nil
+}
+
+// ApplyLedgerResult records the outcome of a payment the ledger consumer
+// processed. Payments no longer pending are left as they are: a completed
+// payment that was cancelled meanwhile is reversed by the ledger when the
+// cancellation reaches it.
+func (s *PaymentService) ApplyLedgerResult(event kafka.PaymentEvent, status model.PaymentStatus) error {
+	paymentID, err := uuid.Parse(event.PaymentID)
+	if err != nil {
+		return fmt.Errorf("invalid payment id %q", event.PaymentID)
+	}
+	var entryID *uuid.UUID
+	if id, err := uuid.Parse(event.LedgerEntryID); err == nil {
+		entryID = &id
+	}
+	resolved, err := s.payments.ResolvePayment(event.PaymentID, status, entryID)
+	if err != nil {
+		return err
+	}
+	if !resolved {
+		slog.Info("Ignoring ledger result for a payment that is no longer pending", "payment_id", event.PaymentID, "result", status)
+		return nil
+	}
+	if status == model.StatusCompleted {
+		s.recordTransition(paymentID, model.TransferPosted, model.ActorLedger, entryDetail(entryID))
+		s.recordTransition(paymentID, model.TransferCompleted, model.ActorLedger, "")
+	} else {
+		s.recordTransition(paymentID, model.TransferFailed, model.ActorLedger, "rejected by the ledger")
+	}
+	return nil
+}
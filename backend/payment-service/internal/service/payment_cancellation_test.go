@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryPaymentStates is an in-memory PaymentStateRepository. beforeCancel,
+// when set, runs between the service reading a payment and cancelling it.
+type memoryPaymentStates struct {
+	payments     map[string]*model.Payment
+	beforeCancel func()
+}
+
+func (r *memoryPaymentStates) GetPayment(id string) (*model.Payment, error) {
+	p, ok := r.payments[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *p
+	return &copied, nil
+}
+
+func (r *memoryPaymentStates) ResolvePayment(id string, status model.PaymentStatus, ledgerEntryID *uuid.UUID) (bool, error) {
+	p := r.payments[id]
+	if p == nil || p.Status != model.StatusPending {
+		return false, nil
+	}
+	p.Status = status
+	if ledgerEntryID != nil {
+		p.LedgerEntryID = ledgerEntryID
+	}
+	return true, nil
+}
+
+func (r *memoryPaymentStates) CancelPayment(id string, at time.Time) (bool, error) {
+	if r.beforeCancel != nil {
+		r.beforeCancel()
+	}
+	p := r.payments[id]
+	if p == nil || p.Status != model.StatusPending {
+		return false, nil
+	}
+	p.Status = model.StatusCancelled
+	p.CancelledAt = &at
+	return true, nil
+}
+
+func (r *memoryPaymentStates) RestorePending(id string) error {
+	p := r.payments[id]
+	if p != nil && p.Status == model.StatusCancelled {
+		p.Status = model.StatusPending
+		p.CancelledAt = nil
+	}
+	return nil
+}
+
+func newCancellationService(status model.PaymentStatus) (*PaymentService, *memoryPaymentStates, *model.Payment, string) {
+	userID := uuid.New()
+	payment := &model.Payment{
+		ID:            uuid.New(),
+		FromAccountID: uuid.New(),
+		ToAccountID:   uuid.New(),
+		Amount:        decimal.NewFromInt(25),
+		Currency:      "GBP",
+		Status:        status,
+		UserID:        &userID,
+	}
+	repo := &memoryPaymentStates{payments: map[string]*model.Payment{payment.ID.String(): payment}}
+	return &PaymentService{payments: repo}, repo, payment, userID.String()
+}
+
+func TestCancelTransfer_CancelsPendingPayment(t *testing.T) {
+	svc, repo, payment, userID := newCancellationService(model.StatusPending)
+
+	cancelled, err := svc.CancelTransfer(context.Background(), userID, payment.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusCancelled, cancelled.Status)
+	assert.NotNil(t, cancelled.CancelledAt)
+	assert.Equal(t, model.StatusCancelled, repo.payments[payment.ID.String()].Status)
+
+	// Cancelling again is a conflict, not a second cancellation
+	_, err = svc.CancelTransfer(context.Background(), userID, payment.ID.String())
+	var notCancellable *PaymentCancelledError
+	require.ErrorAs(t, err, &notCancellable)
+	assert.Equal(t, model.StatusCancelled, notCancellable.Status)
+}
+
+func TestCancelTransfer_OnlyTheOwnerCanCancel(t *testing.T) {
+	svc, repo, payment, _ := newCancellationService(model.StatusPending)
+
+	_, err := svc.CancelTransfer(context.Background(), uuid.New().String(), payment.ID.String())
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+	_, err = svc.CancelTransfer(context.Background(), uuid.New().String(), "not-a-uuid")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+	assert.Equal(t, model.StatusPending, repo.payments[payment.ID.String()].Status)
+}
+
+func TestCancelTransfer_CompletedPaymentConflicts(t *testing.T) {
+	svc, _, payment, userID := newCancellationService(model.StatusCompleted)
+
+	_, err := svc.CancelTransfer(context.Background(), userID, payment.ID.String())
+	var notCancellable *PaymentCancelledError
+	require.ErrorAs(t, err, &notCancellable)
+	assert.ErrorIs(t, err, ErrPaymentNotCancellable)
+	assert.Equal(t, model.StatusCompleted, notCancellable.Status)
+}
+
+func TestCancelTransfer_LosesRaceWithLedgerResult(t *testing.T) {
+	svc, repo, payment, userID := newCancellationService(model.StatusPending)
+	entryID := uuid.New()
+	repo.beforeCancel = func() {
+		// The ledger's result arrives after the payment was read as pending
+		require.NoError(t, svc.ApplyLedgerResult(kafka.PaymentEvent{PaymentID: payment.ID.String(), LedgerEntryID: entryID.String()}, model.StatusCompleted))
+	}
+
+	_, err := svc.CancelTransfer(context.Background(), userID, payment.ID.String())
+	var notCancellable *PaymentCancelledError
+	require.ErrorAs(t, err, &notCancellable)
+	assert.Equal(t, model.StatusCompleted, notCancellable.Status)
+	stored := repo.payments[payment.ID.String()]
+	assert.Equal(t, model.StatusCompleted, stored.Status)
+	assert.Equal(t, &entryID, stored.LedgerEntryID)
+}
+
+func TestApplyLedgerResult_LeavesCancelledPayment(t *testing.T) {
+	svc, repo, payment, userID := newCancellationService(model.StatusPending)
+	_, err := svc.CancelTransfer(context.Background(), userID, payment.ID.String())
+	require.NoError(t, err)
+
+	// The ledger reverses a payment it posted before the cancellation reached it
+	err = svc.ApplyLedgerResult(kafka.PaymentEvent{PaymentID: payment.ID.String(), LedgerEntryID: uuid.New().String()}, model.StatusCompleted)
+	require.NoError(t, err)
+	stored := repo.payments[payment.ID.String()]
+	assert.Equal(t, model.StatusCancelled, stored.Status)
+	assert.Nil(t, stored.LedgerEntryID)
+
+	assert.Error(t, svc.ApplyLedgerResult(kafka.PaymentEvent{PaymentID: "bad"}, model.StatusFailed))
+}
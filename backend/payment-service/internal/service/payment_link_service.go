@@ -0,0 +1,310 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultPaymentLinkExpiry applies when the recipient does not set an expiry
+	DefaultPaymentLinkExpiry = 24 * time.Hour
+	// MaxPaymentLinkExpiry is the longest a link may stay valid
+	MaxPaymentLinkExpiry = 30 * 24 * time.Hour
+	// maxPaymentLinkReference matches the ISO 20022 remittance reference length
+	maxPaymentLinkReference = 35
+	// PaymentLinkScheme prefixes QR payloads so a payer's app recognises them
+	PaymentLinkScheme = "neobank"
+)
+
+var (
+	ErrPaymentLinksDisabled   = errors.New("payment links are not available")
+	ErrPaymentLinkNotFound    = errors.New("payment link not found, expired or already used")
+	ErrPaymentLinkForbidden   = errors.New("payment link does not belong to caller")
+	ErrPaymentLinkOwnAccount  = errors.New("cannot resolve your own payment link")
+	ErrInvalidPaymentLinkTerm = errors.New("invalid payment link")
+)
+
+// PaymentLink is a shareable request to pay into an account. It lives only in
+// the link store and disappears when it expires or, if single-use, is resolved.
+type PaymentLink struct {
+	Code               string    `json:"code"`
+	RecipientUserID    uuid.UUID `json:"recipient_user_id"`
+	RecipientAccountID uuid.UUID `json:"recipient_account_id"`
+	// Amount is nil when the payer chooses how much to send
+	Amount    *decimal.Decimal `json:"amount,omitempty"`
+	Currency  string           `json:"currency"`
+	Reference string           `json:"reference,omitempty"`
+	SingleUse bool             `json:"single_use"`
+	ExpiresAt time.Time        `json:"expires_at"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// IssuedPaymentLink is a created link with the URL and QR payload to share
+type IssuedPaymentLink struct {
+	PaymentLink
+	URL string `json:"url"`
+	// QRPayload is the text to encode in a QR code
+	QRPayload string `json:"qr_payload"`
+}
+
+// TransferPrefill is what a payer's app needs to pre-fill a transfer
+type TransferPrefill struct {
+	Code        string           `json:"code"`
+	ToAccountID uuid.UUID        `json:"to_account_id"`
+	Amount      *decimal.Decimal `json:"amount,omitempty"`
+	Currency    string           `json:"currency"`
+	Reference   string           `json:"reference,omitempty"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+}
+
+// PaymentLinkStore keeps payment links until they expire
+type PaymentLinkStore interface {
+	Save(ctx context.Context, link *PaymentLink, ttl time.Duration) error
+	// Get returns ErrPaymentLinkNotFound for unknown and expired links
+	Get(ctx context.Context, code string) (*PaymentLink, error)
+	// Take returns and deletes a link atomically, so only one caller gets it
+	Take(ctx context.Context, code string) (*PaymentLink, error)
+	Delete(ctx context.Context, code string) error
+}
+
+// PaymentLinkService creates payment links and resolves them for payers
+type PaymentLinkService struct {
+	store   PaymentLinkStore
+	baseURL string
+	now     func() time.Time
+}
+
+// NewPaymentLinkService creates the service. baseURL is where shared links
+// point, e.g. https://pay.neobank.com; the code is appended as /pay/{code}.
+// Without a store, payment links are disabled.
+func NewPaymentLinkService(store PaymentLinkStore, baseURL string) *PaymentLinkService {
+	return &PaymentLinkService{store: store, baseURL: strings.TrimRight(baseURL, "/"), now: time.Now}
+}
+
+// CreatePaymentLinkInput holds the recipient-supplied terms
+type CreatePaymentLinkInput struct {
+	AccountID string
+	// Amount is optional; an empty amount lets the payer choose
+	Amount    string
+	Currency  string
+	Reference string
+	SingleUse bool
+	ExpiresAt *time.Time
+}
+
+// CreatePaymentLink issues a link for payment into one of the user's accounts
+func (s *PaymentLinkService) CreatePaymentLink(ctx context.Context, userID string, in CreatePaymentLinkInput) (*IssuedPaymentLink, error) {
+	if s.store == nil {
+		return nil, ErrPaymentLinksDisabled
+	}
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid user id", ErrInvalidPaymentLinkTerm)
+	}
+	accountUUID, err := uuid.Parse(in.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid account id", ErrInvalidPaymentLinkTerm)
+	}
+	var amount *decimal.Decimal
+	if in.Amount != "" {
+		a, err := decimal.NewFromString(in.Amount)
+		if err != nil || !a.IsPositive() {
+			return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidPaymentLinkTerm)
+		}
+		amount = &a
+	}
+	if len(in.Reference) > maxPaymentLinkReference {
+		return nil, fmt.Errorf("%w: reference must be at most %d characters", ErrInvalidPaymentLinkTerm, maxPaymentLinkReference)
+	}
+
+	now := s.now()
+	expiresAt := now.Add(DefaultPaymentLinkExpiry)
+	if in.ExpiresAt != nil {
+		if !in.ExpiresAt.After(now) {
+			return nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidPaymentLinkTerm)
+		}
+		if in.ExpiresAt.After(now.Add(MaxPaymentLinkExpiry)) {
+			return nil, fmt.Errorf("%w: expiry must be within 30 days", ErrInvalidPaymentLinkTerm)
+		}
+		expiresAt = *in.ExpiresAt
+	}
+
+	code, err := newPaymentLinkCode()
+	if err != nil {
+		return nil, err
+	}
+	link := &PaymentLink{
+		Code:               code,
+		RecipientUserID:    userUUID,
+		RecipientAccountID: accountUUID,
+		Amount:             amount,
+		Currency:           strings.ToUpper(in.Currency),
+		Reference:          in.Reference,
+		SingleUse:          in.SingleUse,
+		ExpiresAt:          expiresAt,
+		CreatedAt:          now,
+	}
+	if err := s.store.Save(ctx, link, expiresAt.Sub(now)); err != nil {
+		return nil, err
+	}
+	return &IssuedPaymentLink{PaymentLink: *link, URL: s.linkURL(code), QRPayload: qrPayload(link)}, nil
+}
+
+// ResolvePaymentLink returns the transfer details a payer's app pre-fills.
+// Resolving a single-use link uses it up.
+func (s *PaymentLinkService) ResolvePaymentLink(ctx context.Context, payerUserID, code string) (*TransferPrefill, error) {
+	if s.store == nil {
+		return nil, ErrPaymentLinksDisabled
+	}
+	link, err := s.store.Get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if link.RecipientUserID.String() == payerUserID {
+		return nil, ErrPaymentLinkOwnAccount
+	}
+	if link.SingleUse {
+		// Another payer may have taken the link since it was read
+		if link, err = s.store.Take(ctx, code); err != nil {
+			return nil, err
+		}
+	}
+	return &TransferPrefill{
+		Code:        link.Code,
+		ToAccountID: link.RecipientAccountID,
+		Amount:      link.Amount,
+		Currency:    link.Currency,
+		Reference:   link.Reference,
+		ExpiresAt:   link.ExpiresAt,
+	}, nil
+}
+
+// RevokePaymentLink deletes a link so it can no longer be resolved
+func (s *PaymentLinkService) RevokePaymentLink(ctx context.Context, userID, code string) error {
+	if s.store == nil {
+		return ErrPaymentLinksDisabled
+	}
+	link, err := s.store.Get(ctx, code)
+	if err != nil {
+		return err
+	}
+	if link.RecipientUserID.String() != userID {
+		return ErrPaymentLinkForbidden
+	}
+	return s.store.Delete(ctx, code)
+}
+
+func (s *PaymentLinkService) linkURL(code string) string {
+	return s.baseURL + "/pay/" + code
+}
+
+// qrPayload encodes the link's terms so an app can pre-fill a transfer even
+// before resolving it, e.g. neobank://pay?code=PL-...&account=...&amount=25.00
+func qrPayload(link *PaymentLink) string {
+	q := url.Values{}
+	q.Set("code", link.Code)
+	q.Set("account", link.RecipientAccountID.String())
+	if link.Amount != nil {
+		q.Set("amount", link.Amount.StringFixed(2))
+	}
+	q.Set("currency", link.Currency)
+	if link.Reference != "" {
+		q.Set("reference", link.Reference)
+	}
+	return PaymentLinkScheme + "://pay?" + q.Encode()
+}
+
+func newPaymentLinkCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "PL-" + base32.StdEncoding.EncodeToString(raw)[:16], nil
+}
+
+// LinkRedis is the subset of *cache.RedisClient the link store uses
+type LinkRedis interface {
+	Evaler
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	Get(ctx context.Context, key string) (string, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// takeScript reads and deletes a key in one step; a missing key returns ""
+const takeScript = `
+local v = redis.call("GET", KEYS[1])
+if not v then
+	return ""
+end
+redis.call("DEL", KEYS[1])
+return v`
+
+// RedisPaymentLinkStore keeps each link as JSON under a key that expires with it
+type RedisPaymentLinkStore struct {
+	client LinkRedis
+}
+
+func NewRedisPaymentLinkStore(client LinkRedis) *RedisPaymentLinkStore {
+	return &RedisPaymentLinkStore{client: client}
+}
+
+func (s *RedisPaymentLinkStore) Save(ctx context.Context, link *PaymentLink, ttl time.Duration) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	stored, err := s.client.SetNX(ctx, paymentLinkKey(link.Code), string(data), ttl)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return fmt.Errorf("payment link code %s already in use", link.Code)
+	}
+	return nil
+}
+
+func (s *RedisPaymentLinkStore) Get(ctx context.Context, code string) (*PaymentLink, error) {
+	val, err := s.client.Get(ctx, paymentLinkKey(code))
+	if err != nil {
+		return nil, err
+	}
+	return decodePaymentLink(val)
+}
+
+func (s *RedisPaymentLinkStore) Take(ctx context.Context, code string) (*PaymentLink, error) {
+	res, err := s.client.Eval(ctx, takeScript, []string{paymentLinkKey(code)})
+	if err != nil {
+		return nil, err
+	}
+	val, _ := res.(string)
+	return decodePaymentLink(val)
+}
+
+func (s *RedisPaymentLinkStore) Delete(ctx context.Context, code string) error {
+	return s.client.Delete(ctx, paymentLinkKey(code))
+}
+
+func decodePaymentLink(val string) (*PaymentLink, error) {
+	if val == "" {
+		return nil, ErrPaymentLinkNotFound
+	}
+	var link PaymentLink
+	if err := json.Unmarshal([]byte(val), &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func paymentLinkKey(code string) string {
+	return "payment_link:" + code
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLinkStore is an in-memory PaymentLinkStore that expires links like Redis TTLs
+type memoryLinkStore struct {
+	links   map[string]PaymentLink
+	expires map[string]time.Time
+	now     func() time.Time
+}
+
+func newMemoryLinkStore(now func() time.Time) *memoryLinkStore {
+	return &memoryLinkStore{links: map[string]PaymentLink{}, expires: map[string]time.Time{}, now: now}
+}
+
+func (s *memoryLinkStore) Save(_ context.Context, link *PaymentLink, ttl time.Duration) error {
+	s.links[link.Code] = *link
+	s.expires[link.Code] = s.now().Add(ttl)
+	return nil
+}
+
+func (s *memoryLinkStore) Get(_ context.Context, code string) (*PaymentLink, error) {
+	link, ok := s.links[code]
+	if !ok || !s.now().Before(s.expires[code]) {
+		return nil, ErrPaymentLinkNotFound
+	}
+	return &link, nil
+}
+
+func (s *memoryLinkStore) Take(ctx context.Context, code string) (*PaymentLink, error) {
+	link, err := s.Get(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.links, code)
+	return link, nil
+}
+
+func (s *memoryLinkStore) Delete(_ context.Context, code string) error {
+	delete(s.links, code)
+	return nil
+}
+
+func newTestLinkService() (*PaymentLinkService, *time.Time) {
+	now := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	svc := NewPaymentLinkService(newMemoryLinkStore(clock), "https://pay.example.com/")
+	svc.now = clock
+	return svc, &now
+}
+
+func TestCreatePaymentLink_EncodesTermsInQRPayload(t *testing.T) {
+	svc, now := newTestLinkService()
+	recipient, account := uuid.New(), uuid.New()
+
+	link, err := svc.CreatePaymentLink(context.Background(), recipient.String(), CreatePaymentLinkInput{
+		AccountID: account.String(),
+		Amount:    "25.5",
+		Currency:  "usd",
+		Reference: "Dinner",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://pay.example.com/pay/"+link.Code, link.URL)
+	assert.Equal(t, now.Add(DefaultPaymentLinkExpiry), link.ExpiresAt)
+
+	payload, err := url.Parse(link.QRPayload)
+	require.NoError(t, err)
+	assert.Equal(t, PaymentLinkScheme, payload.Scheme)
+	q := payload.Query()
+	assert.Equal(t, link.Code, q.Get("code"))
+	assert.Equal(t, account.String(), q.Get("account"))
+	assert.Equal(t, "25.50", q.Get("amount"))
+	assert.Equal(t, "USD", q.Get("currency"))
+	assert.Equal(t, "Dinner", q.Get("reference"))
+}
+
+func TestCreatePaymentLink_ValidatesTerms(t *testing.T) {
+	svc, now := newTestLinkService()
+	past := now.Add(-time.Minute)
+	tooLate := now.Add(MaxPaymentLinkExpiry + time.Hour)
+
+	tests := []struct {
+		name string
+		in   CreatePaymentLinkInput
+	}{
+		{"bad account", CreatePaymentLinkInput{AccountID: "nope", Currency: "USD"}},
+		{"zero amount", CreatePaymentLinkInput{AccountID: uuid.NewString(), Amount: "0", Currency: "USD"}},
+		{"long reference", CreatePaymentLinkInput{AccountID: uuid.NewString(), Currency: "USD", Reference: "0123456789012345678901234567890123456789"}},
+		{"expired", CreatePaymentLinkInput{AccountID: uuid.NewString(), Currency: "USD", ExpiresAt: &past}},
+		{"expiry too far", CreatePaymentLinkInput{AccountID: uuid.NewString(), Currency: "USD", ExpiresAt: &tooLate}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreatePaymentLink(context.Background(), uuid.NewString(), tt.in)
+			assert.ErrorIs(t, err, ErrInvalidPaymentLinkTerm)
+		})
+	}
+}
+
+func TestResolvePaymentLink(t *testing.T) {
+	svc, now := newTestLinkService()
+	ctx := context.Background()
+	recipient, account, payer := uuid.New(), uuid.New(), uuid.NewString()
+
+	open, err := svc.CreatePaymentLink(ctx, recipient.String(), CreatePaymentLinkInput{AccountID: account.String(), Currency: "USD"})
+	require.NoError(t, err)
+	single, err := svc.CreatePaymentLink(ctx, recipient.String(), CreatePaymentLinkInput{AccountID: account.String(), Amount: "10", Currency: "USD", SingleUse: true})
+	require.NoError(t, err)
+
+	_, err = svc.ResolvePaymentLink(ctx, recipient.String(), open.Code)
+	assert.ErrorIs(t, err, ErrPaymentLinkOwnAccount)
+
+	// A reusable link resolves until it expires, without an amount
+	for i := 0; i < 2; i++ {
+		prefill, err := svc.ResolvePaymentLink(ctx, payer, open.Code)
+		require.NoError(t, err)
+		assert.Equal(t, account, prefill.ToAccountID)
+		assert.Nil(t, prefill.Amount)
+	}
+
+	// A single-use link resolves once
+	prefill, err := svc.ResolvePaymentLink(ctx, payer, single.Code)
+	require.NoError(t, err)
+	assert.Equal(t, "10", prefill.Amount.String())
+	_, err = svc.ResolvePaymentLink(ctx, payer, single.Code)
+	assert.ErrorIs(t, err, ErrPaymentLinkNotFound)
+
+	*now = now.Add(DefaultPaymentLinkExpiry)
+	_, err = svc.ResolvePaymentLink(ctx, payer, open.Code)
+	assert.ErrorIs(t, err, ErrPaymentLinkNotFound)
+}
+
+func TestRevokePaymentLink_OnlyRecipient(t *testing.T) {
+	svc, _ := newTestLinkService()
+	ctx := context.Background()
+	recipient := uuid.NewString()
+
+	link, err := svc.CreatePaymentLink(ctx, recipient, CreatePaymentLinkInput{AccountID: uuid.NewString(), Currency: "USD"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, svc.RevokePaymentLink(ctx, uuid.NewString(), link.Code), ErrPaymentLinkForbidden)
+	require.NoError(t, svc.RevokePaymentLink(ctx, recipient, link.Code))
+	_, err = svc.ResolvePaymentLink(ctx, uuid.NewString(), link.Code)
+	assert.ErrorIs(t, err, ErrPaymentLinkNotFound)
+}
+
+func TestPaymentLinks_DisabledWithoutStore(t *testing.T) {
+	svc := NewPaymentLinkService(nil, "https://pay.example.com")
+
+	_, err := svc.CreatePaymentLink(context.Background(), uuid.NewString(), CreatePaymentLinkInput{AccountID: uuid.NewString(), Currency: "USD"})
+	assert.ErrorIs(t, err, ErrPaymentLinksDisabled)
+}
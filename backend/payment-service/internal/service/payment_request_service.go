@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultPaymentRequestExpiry applies when the requester does not set an expiry
+	DefaultPaymentRequestExpiry = 7 * 24 * time.Hour
+	// MaxPaymentRequestExpiry is the longest a request may stay open
+	MaxPaymentRequestExpiry = 30 * 24 * time.Hour
+)
+
+var (
+	ErrPaymentRequestNotFound  = errors.New("payment request not found")
+	ErrPaymentRequestNotOpen   = errors.New("payment request is no longer open")
+	ErrPaymentRequestExpired   = errors.New("payment request has expired")
+	ErrPaymentRequestForbidden = errors.New("payment request does not belong to caller")
+	ErrPayOwnPaymentRequest    = errors.New("cannot pay your own payment request")
+)
+
+// PaymentRequestRepository defines data access for payment requests
+type PaymentRequestRepository interface {
+	Create(pr *model.PaymentRequest) error
+	GetByReference(reference string) (*model.PaymentRequest, error)
+	ListByRequester(userID string) ([]model.PaymentRequest, error)
+	ListExpiredOpen(now time.Time) ([]model.PaymentRequest, error)
+	TransitionStatus(id string, from, to model.PaymentRequestStatus) (bool, error)
+	Save(pr *model.PaymentRequest) error
+}
+
+// TransferInitiator starts a transfer between two accounts
+type TransferInitiator interface {
+	InitiateTransfer(fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error)
+	// InitiateTransferFor makes a transfer on a user's behalf, held for
+	// approvals when it is from a business account
+	InitiateTransferFor(userID, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error)
+}
+
+// PaymentRequestService manages payment requests and their fulfilment
+type PaymentRequestService struct {
+	Repo      PaymentRequestRepository
+	Transfers TransferInitiator
+	producer  *kafka.Producer
+}
+
+func NewPaymentRequestService(repo PaymentRequestRepository, transfers TransferInitiator, producer *kafka.Producer) *PaymentRequestService {
+	return &PaymentRequestService{
+		Repo:      repo,
+		Transfers: transfers,
+		producer:  producer,
+	}
+}
+
+// CreatePaymentRequestInput holds the requester-supplied terms
+type CreatePaymentRequestInput struct {
+	AccountID   string
+	Amount      string
+	Currency    string
+	Description string
+	ExpiresAt   *time.Time
+}
+
+// CreatePaymentRequest opens a request for payment into one of the requester's accounts
+func (s *PaymentRequestService) CreatePaymentRequest(userID string, in CreatePaymentRequestInput) (*model.PaymentRequest, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	accountUUID, err := uuid.Parse(in.AccountID)
+	if err != nil {
+		return nil, errors.New("invalid account id")
+	}
+	amount, err := decimal.NewFromString(in.Amount)
+	if err != nil || amount.LessThanOrEqual(decimal.Zero) {
+		return nil, errors.New("amount must be greater than zero")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(DefaultPaymentRequestExpiry)
+	if in.ExpiresAt != nil {
+		if !in.ExpiresAt.After(now) {
+			return nil, errors.New("expiry must be in the future")
+		}
+		if in.ExpiresAt.After(now.Add(MaxPaymentRequestExpiry)) {
+			return nil, errors.New("expiry must be within 30 days")
+		}
+		expiresAt = *in.ExpiresAt
+	}
+
+	reference, err := newPaymentRequestReference()
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &model.PaymentRequest{
+		Reference:          reference,
+		RequesterUserID:    userUUID,
+		RequesterAccountID: accountUUID,
+		Amount:             amount,
+		Currency:           in.Currency,
+		Description:        in.Description,
+		Status:             model.PaymentRequestOpen,
+		ExpiresAt:          expiresAt,
+	}
+	if err := s.Repo.Create(pr); err != nil {
+		return nil, err
+	}
+
+	s.publish(kafka.TopicPaymentRequestCreated, pr)
+	return pr, nil
+}
+
+// GetPaymentRequest looks up a request by its shareable reference
+func (s *PaymentRequestService) GetPaymentRequest(reference string) (*model.PaymentRequest, error) {
+	pr, err := s.Repo.GetByReference(reference)
+	if err != nil {
+		return nil, ErrPaymentRequestNotFound
+	}
+	if pr.IsExpired(time.Now()) {
+		if err := s.expire(pr); err != nil {
+			return nil, err
+		}
+	}
+	return pr, nil
+}
+
+// ListPaymentRequests returns the requests created by the user
+func (s *PaymentRequestService) ListPaymentRequests(userID string) ([]model.PaymentRequest, error) {
+	return s.Repo.ListByRequester(userID)
+}
+
+// PayPaymentRequest fulfils an open request with a transfer from the payer's account
+// into the requester's account. The request is claimed before the transfer starts
+// and reopened if the transfer cannot be initiated.
+func (s *PaymentRequestService) PayPaymentRequest(payerUserID, reference, fromAccountID string) (*model.PaymentRequest, *model.Payment, error) {
+	payerUUID, err := uuid.Parse(payerUserID)
+	if err != nil {
+		return nil, nil, errors.New("invalid user id")
+	}
+
+	pr, err := s.GetPaymentRequest(reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pr.Status == model.PaymentRequestExpired {
+		return nil, nil, ErrPaymentRequestExpired
+	}
+	if pr.Status != model.PaymentRequestOpen {
+		return nil, nil, ErrPaymentRequestNotOpen
+	}
+	if pr.RequesterUserID == payerUUID {
+		return nil, nil, ErrPayOwnPaymentRequest
+	}
+
+	claimed, err := s.Repo.TransitionStatus(pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestPaid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !claimed {
+		return nil, nil, ErrPaymentRequestNotOpen
+	}
+
+	desc := "Payment request " + pr.Reference
+	if pr.Description != "" {
+		desc += ": " + pr.Description
+	}
+
+	payment, err := s.Transfers.InitiateTransferFor(payerUserID, fromAccountID, pr.RequesterAccountID.String(), pr.Amount.String(), pr.Currency, desc)
+	if err != nil {
+		if _, rerr := s.Repo.TransitionStatus(pr.ID.String(), model.PaymentRequestPaid, model.PaymentRequestOpen); rerr != nil {
+			slog.Error("Failed to reopen payment request", "reference", pr.Reference, "error", rerr)
+		}
+		return pr, payment, err
+	}
+
+	now := time.Now()
+	pr.Status = model.PaymentRequestPaid
+	pr.PayerUserID = &payerUUID
+	pr.PaymentID = &payment.ID
+	pr.PaidAt = &now
+	if err := s.Repo.Save(pr); err != nil {
+		return nil, payment, err
+	}
+
+	s.publish(kafka.TopicPaymentRequestPaid, pr)
+	return pr, payment, nil
+}
+
+// CancelPaymentRequest lets the requester withdraw an open request
+func (s *PaymentRequestService) CancelPaymentRequest(userID, reference string) (*model.PaymentRequest, error) {
+	pr, err := s.GetPaymentRequest(reference)
+	if err != nil {
+		return nil, err
+	}
+	if pr.RequesterUserID.String() != userID {
+		return nil, ErrPaymentRequestForbidden
+	}
+	if pr.Status != model.PaymentRequestOpen {
+		return nil, ErrPaymentRequestNotOpen
+	}
+
+	claimed, err := s.Repo.TransitionStatus(pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestCancelled)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrPaymentRequestNotOpen
+	}
+
+	now := time.Now()
+	pr.Status = model.PaymentRequestCancelled
+	pr.CancelledAt = &now
+	if err := s.Repo.Save(pr); err != nil {
+		return nil, err
+	}
+
+	s.publish(kafka.TopicPaymentRequestCancelled, pr)
+	return pr, nil
+}
+
+// ExpireOverdue marks every open request past its expiry as EXPIRED
+func (s *PaymentRequestService) ExpireOverdue(now time.Time) (int, error) {
+	overdue, err := s.Repo.ListExpiredOpen(now)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range overdue {
+		if err := s.expire(&overdue[i]); err != nil {
+			slog.Error("Failed to expire payment request", "reference", overdue[i].Reference, "error", err)
+			continue
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// ExpiryJob expires overdue requests
+func (s *PaymentRequestService) ExpiryJob(_ context.Context, _ *jobs.Job) error {
+	n, err := s.ExpireOverdue(time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Expired payment requests", "count", n)
+	}
+	return nil
+}
+
+func (s *PaymentRequestService) expire(pr *model.PaymentRequest) error {
+	claimed, err := s.Repo.TransitionStatus(pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestExpired)
+	if err != nil {
+		return err
+	}
+	pr.Status = model.PaymentRequestExpired
+	if claimed {
+		s.publish(kafka.TopicPaymentRequestExpired, pr)
+	}
+	return nil
+}
+
+// publish emits a payment request lifecycle event when Kafka is available
+func (s *PaymentRequestService) publish(topic string, pr *model.PaymentRequest) {
+	if s.producer == nil {
+		return
+	}
+
+	event := kafka.PaymentRequestEvent{
+		RequestID:       pr.ID.String(),
+		Reference:       pr.Reference,
+		RequesterUserID: pr.RequesterUserID.String(),
+		Amount:          pr.Amount.String(),
+		Currency:        pr.Currency,
+		Status:          string(pr.Status),
+		Timestamp:       time.Now().Format(time.RFC3339),
+	}
+	if pr.PayerUserID != nil {
+		event.PayerUserID = pr.PayerUserID.String()
+	}
+	if pr.PaymentID != nil {
+		event.PaymentID = pr.PaymentID.String()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.producer.Produce(ctx, topic, pr.ID.String(), event); err != nil {
+		slog.Error("Failed to publish payment request event", "reference", pr.Reference, "topic", topic, "error", err)
+	}
+}
+
+// newPaymentRequestReference returns a short, unambiguous reference such as PR-K3J9QX2M7A
+func newPaymentRequestReference() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "PR-" + base32.StdEncoding.EncodeToString(raw)[:10], nil
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockPaymentRequestRepository is a mock implementation of PaymentRequestRepository
+type MockPaymentRequestRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRequestRepository) Create(pr *model.PaymentRequest) error {
+	args := m.Called(pr)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRequestRepository) GetByReference(reference string) (*model.PaymentRequest, error) {
+	args := m.Called(reference)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.PaymentRequest), args.Error(1)
+}
+
+func (m *MockPaymentRequestRepository) ListByRequester(userID string) ([]model.PaymentRequest, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]model.PaymentRequest), args.Error(1)
+}
+
+func (m *MockPaymentRequestRepository) ListExpiredOpen(now time.Time) ([]model.PaymentRequest, error) {
+	args := m.Called(now)
+	return args.Get(0).([]model.PaymentRequest), args.Error(1)
+}
+
+func (m *MockPaymentRequestRepository) TransitionStatus(id string, from, to model.PaymentRequestStatus) (bool, error) {
+	args := m.Called(id, from, to)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRequestRepository) Save(pr *model.PaymentRequest) error {
+	args := m.Called(pr)
+	return args.Error(0)
+}
+
+// MockTransferInitiator is a mock implementation of TransferInitiator
+type MockTransferInitiator struct {
+	mock.Mock
+}
+
+func (m *MockTransferInitiator) InitiateTransfer(fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
+	args := m.Called(fromAcc, toAcc, amountStr, currency, desc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockTransferInitiator) InitiateTransferFor(userID, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
+	args := m.Called(userID, fromAcc, toAcc, amountStr, currency, desc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func newOpenPaymentRequest() *model.PaymentRequest {
+	return &model.PaymentRequest{
+		ID:                 uuid.New(),
+		Reference:          "PR-ABCDEFGHIJ",
+		RequesterUserID:    uuid.New(),
+		RequesterAccountID: uuid.New(),
+		Amount:             decimal.NewFromInt(25),
+		Currency:           "USD",
+		Description:        "Dinner",
+		Status:             model.PaymentRequestOpen,
+		ExpiresAt:          time.Now().Add(time.Hour),
+	}
+}
+
+func TestCreatePaymentRequest(t *testing.T) {
+	mockRepo := new(MockPaymentRequestRepository)
+	svc := NewPaymentRequestService(mockRepo, nil, nil)
+
+	mockRepo.On("Create", mock.AnythingOfType("*model.PaymentRequest")).Return(nil)
+
+	pr, err := svc.CreatePaymentRequest(uuid.New().String(), CreatePaymentRequestInput{
+		AccountID: uuid.New().String(),
+		Amount:    "12.50",
+		Currency:  "USD",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, model.PaymentRequestOpen, pr.Status)
+	assert.True(t, strings.HasPrefix(pr.Reference, "PR-"))
+	assert.Len(t, pr.Reference, 13)
+	assert.WithinDuration(t, time.Now().Add(DefaultPaymentRequestExpiry), pr.ExpiresAt, time.Minute)
+}
+
+func TestCreatePaymentRequest_Validation(t *testing.T) {
+	svc := NewPaymentRequestService(new(MockPaymentRequestRepository), nil, nil)
+	past := time.Now().Add(-time.Hour)
+	tooFar := time.Now().Add(MaxPaymentRequestExpiry + time.Hour)
+
+	tests := []struct {
+		name string
+		in   CreatePaymentRequestInput
+	}{
+		{"invalid account", CreatePaymentRequestInput{AccountID: "x", Amount: "10"}},
+		{"zero amount", CreatePaymentRequestInput{AccountID: uuid.New().String(), Amount: "0"}},
+		{"expiry in past", CreatePaymentRequestInput{AccountID: uuid.New().String(), Amount: "10", ExpiresAt: &past}},
+		{"expiry too far", CreatePaymentRequestInput{AccountID: uuid.New().String(), Amount: "10", ExpiresAt: &tooFar}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreatePaymentRequest(uuid.New().String(), tt.in)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPayPaymentRequest_TransfersToRequester(t *testing.T) {
+	mockRepo := new(MockPaymentRequestRepository)
+	transfers := new(MockTransferInitiator)
+	svc := NewPaymentRequestService(mockRepo, transfers, nil)
+
+	pr := newOpenPaymentRequest()
+	payerID := uuid.New()
+	fromAccount := uuid.New().String()
+	payment := &model.Payment{ID: uuid.New(), Status: model.StatusPending}
+
+	mockRepo.On("GetByReference", pr.Reference).Return(pr, nil)
+	mockRepo.On("TransitionStatus", pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestPaid).Return(true, nil).Once()
+	mockRepo.On("Save", pr).Return(nil)
+	transfers.On("InitiateTransferFor", payerID.String(), fromAccount, pr.RequesterAccountID.String(), "25", "USD", "Payment request PR-ABCDEFGHIJ: Dinner").
+		Return(payment, nil)
+
+	paid, got, err := svc.PayPaymentRequest(payerID.String(), pr.Reference, fromAccount)
+
+	assert.NoError(t, err)
+	assert.Equal(t, payment, got)
+	assert.Equal(t, model.PaymentRequestPaid, paid.Status)
+	assert.Equal(t, payerID, *paid.PayerUserID)
+	assert.Equal(t, payment.ID, *paid.PaymentID)
+	assert.NotNil(t, paid.PaidAt)
+
+	// Paying again is rejected
+	_, _, err = svc.PayPaymentRequest(uuid.New().String(), pr.Reference, fromAccount)
+	assert.ErrorIs(t, err, ErrPaymentRequestNotOpen)
+}
+
+func TestPayPaymentRequest_ReopensOnTransferFailure(t *testing.T) {
+	mockRepo := new(MockPaymentRequestRepository)
+	transfers := new(MockTransferInitiator)
+	svc := NewPaymentRequestService(mockRepo, transfers, nil)
+
+	pr := newOpenPaymentRequest()
+	mockRepo.On("GetByReference", pr.Reference).Return(pr, nil)
+	mockRepo.On("TransitionStatus", pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestPaid).Return(true, nil)
+	mockRepo.On("TransitionStatus", pr.ID.String(), model.PaymentRequestPaid, model.PaymentRequestOpen).Return(true, nil)
+	transfers.On("InitiateTransferFor", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("insufficient funds"))
+
+	_, _, err := svc.PayPaymentRequest(uuid.New().String(), pr.Reference, uuid.New().String())
+
+	assert.Error(t, err)
+	assert.Equal(t, model.PaymentRequestOpen, pr.Status)
+	mockRepo.AssertCalled(t, "TransitionStatus", pr.ID.String(), model.PaymentRequestPaid, model.PaymentRequestOpen)
+	mockRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestPayPaymentRequest_Rejections(t *testing.T) {
+	t.Run("own request", func(t *testing.T) {
+		mockRepo := new(MockPaymentRequestRepository)
+		svc := NewPaymentRequestService(mockRepo, nil, nil)
+		pr := newOpenPaymentRequest()
+		mockRepo.On("GetByReference", pr.Reference).Return(pr, nil)
+
+		_, _, err := svc.PayPaymentRequest(pr.RequesterUserID.String(), pr.Reference, uuid.New().String())
+		assert.ErrorIs(t, err, ErrPayOwnPaymentRequest)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mockRepo := new(MockPaymentRequestRepository)
+		svc := NewPaymentRequestService(mockRepo, nil, nil)
+		pr := newOpenPaymentRequest()
+		pr.ExpiresAt = time.Now().Add(-time.Minute)
+		mockRepo.On("GetByReference", pr.Reference).Return(pr, nil)
+		mockRepo.On("TransitionStatus", pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestExpired).Return(true, nil)
+
+		_, _, err := svc.PayPaymentRequest(uuid.New().String(), pr.Reference, uuid.New().String())
+		assert.ErrorIs(t, err, ErrPaymentRequestExpired)
+		assert.Equal(t, model.PaymentRequestExpired, pr.Status)
+	})
+
+	t.Run("concurrent payer wins", func(t *testing.T) {
+		mockRepo := new(MockPaymentRequestRepository)
+		svc := NewPaymentRequestService(mockRepo, nil, nil)
+		pr := newOpenPaymentRequest()
+		mockRepo.On("GetByReference", pr.Reference).Return(pr, nil)
+		mockRepo.On("TransitionStatus", pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestPaid).Return(false, nil)
+
+		_, _, err := svc.PayPaymentRequest(uuid.New().String(), pr.Reference, uuid.New().String())
+		assert.ErrorIs(t, err, ErrPaymentRequestNotOpen)
+	})
+}
+
+func TestCancelPaymentRequest(t *testing.T) {
+	mockRepo := new(MockPaymentRequestRepository)
+	svc := NewPaymentRequestService(mockRepo, nil, nil)
+
+	pr := newOpenPaymentRequest()
+	mockRepo.On("GetByReference", pr.Reference).Return(pr, nil)
+	mockRepo.On("TransitionStatus", pr.ID.String(), model.PaymentRequestOpen, model.PaymentRequestCancelled).Return(true, nil)
+	mockRepo.On("Save", pr).Return(nil)
+
+	// Only the requester can cancel
+	_, err := svc.CancelPaymentRequest(uuid.New().String(), pr.Reference)
+	assert.ErrorIs(t, err, ErrPaymentRequestForbidden)
+
+	cancelled, err := svc.CancelPaymentRequest(pr.RequesterUserID.String(), pr.Reference)
+	assert.NoError(t, err)
+	assert.Equal(t, model.PaymentRequestCancelled, cancelled.Status)
+	assert.NotNil(t, cancelled.CancelledAt)
+
+	_, err = svc.CancelPaymentRequest(pr.RequesterUserID.String(), pr.Reference)
+	assert.ErrorIs(t, err, ErrPaymentRequestNotOpen)
+}
+
+func TestExpireOverdue(t *testing.T) {
+	mockRepo := new(MockPaymentRequestRepository)
+	svc := NewPaymentRequestService(mockRepo, nil, nil)
+
+	now := time.Now()
+	first, second := newOpenPaymentRequest(), newOpenPaymentRequest()
+	mockRepo.On("ListExpiredOpen", now).Return([]model.PaymentRequest{*first, *second}, nil)
+	mockRepo.On("TransitionStatus", first.ID.String(), model.PaymentRequestOpen, model.PaymentRequestExpired).Return(true, nil)
+	mockRepo.On("TransitionStatus", second.ID.String(), model.PaymentRequestOpen, model.PaymentRequestExpired).Return(false, errors.New("db down"))
+
+	n, err := svc.ExpireOverdue(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
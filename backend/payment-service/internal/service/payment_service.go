@@ -13,24 +13,41 @@ import (
 
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
 type PaymentService struct {
 	Repo      *repository.PaymentRepository
+	payments  PaymentStateRepository // Status changes after creation; Repo outside tests
 	producer  *kafka.Producer
 	useKafka  bool
-	ledgerURL string // Configurable ledger service URL
+	ledgerURL string            // Configurable ledger service URL
+	ledger    *http.Client      // Authenticates ledger calls; see SetLedgerClient
+	mandates  MandateRepository // Used to enforce direct debit mandate limits
+	limits    *TransferLimiter  // Per-user velocity limits; see SetTransferLimiter
+	dupes     *DuplicateGuard   // Catches accidental repeat transfers; see SetDuplicateGuard
+	fees      *FeeService       // Prices user transfers and collections; see SetFees
+	rails     *RailPolicy       // Instant and standard rails for user transfers; see SetRails
+	railQueue StandardRailQueue
+	accounts  *cache.LocalCache[AccountResponse] // Ledger accounts read recently; see SetAccountCache
+	timeline  TransferTimelineRepository         // State history of payments; see SetTimeline
+	approvals PaymentApprovalRepository          // Approvals of business account payments; see SetApprovals
+	approval  ApprovalPolicy
 }
 
 // NewPaymentService creates a new payment service (sync mode - fallback)
 func NewPaymentService(repo *repository.PaymentRepository) *PaymentService {
 	return &PaymentService{
 		Repo:      repo,
+		payments:  repo,
 		useKafka:  false,
 		ledgerURL: getEnvOrDefault("LEDGER_SERVICE_URL", "http://localhost:8082"),
+		ledger:    http.DefaultClient,
 	}
 }
 
@@ -38,12 +55,54 @@ func NewPaymentService(repo *repository.PaymentRepository) *PaymentService {
 func NewPaymentServiceWithKafka(repo *repository.PaymentRepository, producer *kafka.Producer) *PaymentService {
 	return &PaymentService{
 		Repo:      repo,
+		payments:  repo,
 		producer:  producer,
 		useKafka:  true,
 		ledgerURL: getEnvOrDefault("LEDGER_SERVICE_URL", "http://localhost:8082"),
+		ledger:    http.DefaultClient,
 	}
 }
 
+// SetLedgerClient sets the HTTP client used for ledger calls, normally one
+// that attaches a service account token
+func (s *PaymentService) SetLedgerClient(client *http.Client) {
+	s.ledger = client
+}
+
+// SetTransferLimiter enables per-user velocity limits on user transfers
+func (s *PaymentService) SetTransferLimiter(limiter *TransferLimiter) {
+	s.limits = limiter
+}
+
+// SetDuplicateGuard enables duplicate payment detection on user transfers
+func (s *PaymentService) SetDuplicateGuard(guard *DuplicateGuard) {
+	s.dupes = guard
+}
+
+// SetFees enables fees on user transfers and direct debit collections
+func (s *PaymentService) SetFees(fees *FeeService) {
+	s.fees = fees
+}
+
+// DefaultAccountCacheTTL bounds how long a cached ledger account is used if
+// its invalidation is lost
+const DefaultAccountCacheTTL = 30 * time.Second
+
+// SetAccountCache keeps the ledger accounts read for balance checks in
+// accounts. The cache must be kept current with a cache.InvalidationSubscriber
+// on the ledger's balance invalidations.
+func (s *PaymentService) SetAccountCache(accounts *cache.LocalCache[AccountResponse]) {
+	s.accounts = accounts
+}
+
+// TransferLimitUsage returns the user's transfer limits and today's usage in currency
+func (s *PaymentService) TransferLimitUsage(ctx context.Context, userID, currency string) (*LimitUsage, error) {
+	if s.limits == nil {
+		return nil, ErrLimitsDisabled
+	}
+	return s.limits.Usage(ctx, userID, currency)
+}
+
 // getEnvOrDefault returns the environment variable value or a default
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -53,24 +112,145 @@ func getEnvOrDefault(key, defaultValue string) string {
 }
 
 type LedgerTransactionRequest struct {
-	Description string `json:"description"`
-	Postings    []struct {
-		AccountID string `json:"account_id"`
-		Amount    string `json:"amount"`
-		Direction int    `json:"direction"`
-	} `json:"postings"`
+	Description     string          `json:"description"`
+	Postings        []LedgerPosting `json:"postings"`
+	ReversesEntryID string          `json:"reverses_entry_id,omitempty"`
+}
+
+// LedgerPosting is one leg of a ledger transaction
+type LedgerPosting struct {
+	AccountID string `json:"account_id"`
+	Amount    string `json:"amount"`
+	Direction int    `json:"direction"`
+}
+
+// ledgerEntryResponse is the part of a created journal entry the payment service keeps
+type ledgerEntryResponse struct {
+	ID uuid.UUID `json:"ID"`
+}
+
+// transferParams describes a transfer going through the common transfer path
+type transferParams struct {
+	FromAccountID string
+	ToAccountID   string
+	Amount        string
+	Currency      string
+	Description   string
+	Mandate       *model.Mandate     // Set when a merchant collects against a mandate
+	PaymentType   model.PaymentType  // Set for payments the fee engine prices
+	UserID        string             // Set when a user makes the payment; carried on its events
+	Rail          model.TransferRail // Set when the user chose a rail
 }
 
 func (s *PaymentService) InitiateTransfer(fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
-	amount, err := decimal.NewFromString(amountStr)
-	if err != nil {
-		return nil, errors.New("invalid amount")
+	return s.initiateTransfer(transferParams{
+		FromAccountID: fromAcc,
+		ToAccountID:   toAcc,
+		Amount:        amountStr,
+		Currency:      currency,
+		Description:   desc,
+	})
+}
+
+// InitiateTransferFor makes a transfer a user asked for through another
+// feature, such as an external transfer or a paid payment request. The payment
+// is the user's, and one from a business account waits for approvals like the
+// user's own transfers do.
+func (s *PaymentService) InitiateTransferFor(userID, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
+	return s.initiateTransfer(transferParams{
+		FromAccountID: fromAcc,
+		ToAccountID:   toAcc,
+		Amount:        amountStr,
+		Currency:      currency,
+		Description:   desc,
+		UserID:        userID,
+	})
+}
+
+// InitiateUserTransfer makes a transfer requested by a user, checking it is
+// not an accidental repeat of a recent identical transfer and enforcing the
+// user's transfer limits first. confirmation is the token from an earlier
+// DuplicatePaymentError, to make a repeat transfer on purpose. A transfer that
+// fails is taken back off the user's daily usage; one that is still pending
+// keeps counting. rail is empty or one of the rails enabled by SetRails; with
+// no rail the transfer is processed as before rails existed.
+func (s *PaymentService) InitiateUserTransfer(ctx context.Context, userID, fromAcc, toAcc, amountStr, currency, desc, confirmation string, rail model.TransferRail) (*model.Payment, error) {
+	params := transferParams{
+		FromAccountID: fromAcc,
+		ToAccountID:   toAcc,
+		Amount:        amountStr,
+		Currency:      currency,
+		Description:   desc,
+		PaymentType:   model.PaymentTypeTransfer,
+		UserID:        userID,
+		Rail:          rail,
+	}
+	switch rail {
+	case "":
+	case model.RailInstant, model.RailStandard:
+		if s.rails == nil {
+			return nil, ErrRailsDisabled
+		}
+		params.PaymentType = railPaymentType(rail)
+	default:
+		return nil, ErrInvalidRail
+	}
+	parsed, err := money.Parse(amountStr, currency)
+	if err != nil || !parsed.IsPositive() {
+		// Invalid amounts are rejected by the transfer itself
+		return s.initiateTransfer(params)
 	}
+	amount := parsed.Decimal()
+
+	var claim *DuplicateClaim
+	if s.dupes != nil {
+		if claim, err = s.dupes.Check(ctx, userID, fromAcc, toAcc, amount, currency, desc, confirmation); err != nil {
+			return nil, err
+		}
+	}
+	release := func() {
+		if s.dupes == nil {
+			return
+		}
+		if relErr := s.dupes.Release(ctx, claim); relErr != nil {
+			slog.Warn("Failed to release duplicate payment claim", "user_id", userID, "error", relErr)
+		}
+	}
+
+	var reservation *LimitReservation
+	if s.limits != nil {
+		if reservation, err = s.limits.Reserve(ctx, userID, toAcc, currency, amount); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	payment, err := s.initiateTransfer(params)
+	if err != nil && (payment == nil || payment.Status == model.StatusFailed) {
+		release()
+		if reservation != nil {
+			if relErr := s.limits.Release(ctx, reservation); relErr != nil {
+				slog.Warn("Failed to release transfer limit reservation", "user_id", userID, "error", relErr)
+			}
+		}
+	}
+	return payment, err
+}
+
+func (s *PaymentService) initiateTransfer(p transferParams) (*model.Payment, error) {
+	fromAcc, toAcc, amountStr, currency, desc := p.FromAccountID, p.ToAccountID, p.Amount, p.Currency, p.Description
 
-	// Validate amount is positive
-	if amount.LessThanOrEqual(decimal.Zero) {
+	// Amounts are normalized to the currency's decimal places, and ones with
+	// more (a tenth of a cent, a fraction of a yen) are refused
+	parsed, err := money.Parse(amountStr, currency)
+	switch {
+	case errors.Is(err, money.ErrInvalidAmount):
+		return nil, errors.New("invalid amount")
+	case err != nil:
+		return nil, err
+	case !parsed.IsPositive():
 		return nil, errors.New("amount must be greater than zero")
 	}
+	amount := parsed.Decimal()
 
 	// Check for same account transfer
 	if fromAcc == toAcc {
@@ -86,10 +266,38 @@ func (s *PaymentService) InitiateTransfer(fromAcc, toAcc, amountStr, currency, d
 		return nil, errors.New("invalid to account id")
 	}
 
-	// Validate balance by calling ledger service
-	balanceErr := s.validateBalance(fromAcc, amountStr)
-	if balanceErr != nil {
-		return nil, balanceErr
+	// Direct debit collections must stay within the mandate terms
+	if p.Mandate != nil {
+		if err := s.enforceMandate(p.Mandate, fromUUID, amount, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	// Instant transfers are limited by amount and the account's tier
+	account := s.getAccount(fromAcc)
+	if p.Rail == model.RailInstant {
+		if err := s.rails.checkInstant(account.productCode(), amount); err != nil {
+			return nil, err
+		}
+	}
+
+	// Price the payment; the fee is charged on top of the amount
+	quote := &FeeQuote{Fee: decimal.Zero}
+	if s.fees != nil && p.PaymentType != "" {
+		if quote, err = s.fees.Quote(p.PaymentType, account.productCode(), currency, amount); err != nil {
+			return nil, fmt.Errorf("failed to price payment: %w", err)
+		}
+	}
+
+	// Validate balance against the account from the ledger service
+	if err := validateBalance(account, amount.Add(quote.Fee)); err != nil {
+		return nil, err
+	}
+
+	// Transfers from business accounts may have to wait for approvers
+	approval, err := s.approvalFor(p, account, amount)
+	if err != nil {
+		return nil, err
 	}
 
 	// 1. Create Pending Payment
@@ -100,120 +308,306 @@ func (s *PaymentService) InitiateTransfer(fromAcc, toAcc, amountStr, currency, d
 		Currency:      currency,
 		Status:        model.StatusPending,
 		Description:   desc,
+		PaymentType:   p.PaymentType,
+		Fee:           quote.Fee,
+		FeeScheduleID: quote.ScheduleID,
+	}
+	if p.Mandate != nil {
+		payment.MandateID = &p.Mandate.ID
+	}
+	if userID, err := uuid.Parse(p.UserID); err == nil {
+		payment.UserID = &userID
+	}
+	if p.Rail != "" {
+		arrival := s.rails.estimatedArrival(p.Rail, time.Now().UTC())
+		payment.Rail = p.Rail
+		payment.EstimatedArrival = &arrival
+	}
+	if approval != nil {
+		payment.Status = model.StatusAwaitingApproval
+		payment.OrgID = &approval.OrgID
+		payment.ApprovalsRequired = approval.Required
+		payment.ApprovalExpiresAt = &approval.ExpiresAt
 	}
 
 	if err := s.Repo.CreatePayment(payment); err != nil {
 		return nil, err
 	}
+	createdBy := model.ActorPaymentService
+	if payment.UserID != nil {
+		createdBy = model.UserActor(payment.UserID.String())
+	}
+	s.recordTransition(payment.ID, model.TransferCreated, createdBy, "")
+	if approval != nil {
+		s.recordTransition(payment.ID, model.TransferAwaitingApproval, model.ActorPaymentService,
+			fmt.Sprintf("%d approvals required by %s", approval.Required, approval.ExpiresAt.Format(time.RFC3339)))
+		return payment, nil
+	}
+
+	// 2. Process transfer
+	return s.dispatch(payment, p.UserID, fromAcc, toAcc, amountStr, currency, desc)
+}
 
-	// 2. Process transfer - async via Kafka or sync via HTTP
+// dispatch processes a pending payment. Instant transfers are posted while
+// the user waits, standard ones are left pending for StandardRailJob; without
+// a rail the transfer goes async via Kafka or sync via HTTP.
+func (s *PaymentService) dispatch(payment *model.Payment, userID, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
+	switch payment.Rail {
+	case model.RailInstant:
+		return s.processSync(payment, userID, fromAcc, toAcc, amountStr, currency, desc)
+	case model.RailStandard:
+		s.recordTransition(payment.ID, model.TransferQueued, model.ActorPaymentService,
+			"standard rail, estimated arrival "+payment.EstimatedArrival.Format(time.RFC3339))
+		return payment, nil
+	}
 	if s.useKafka && s.producer != nil {
 		// Async: Publish to Kafka and return immediately
-		return s.processAsync(payment, fromAcc, toAcc, amountStr, currency, desc)
+		return s.processAsync(payment, userID, fromAcc, toAcc, amountStr, currency, desc)
 	}
 
 	// Sync: Call Ledger Service directly (fallback)
-	return s.processSync(payment, fromAcc, toAcc, amountStr, desc)
+	return s.processSync(payment, userID, fromAcc, toAcc, amountStr, currency, desc)
 }
 
 // processAsync publishes payment event to Kafka for async processing
-func (s *PaymentService) processAsync(payment *model.Payment, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
-	event := kafka.PaymentEvent{
-		PaymentID:     payment.ID.String(),
-		FromAccountID: fromAcc,
-		ToAccountID:   toAcc,
-		Amount:        amountStr,
-		Currency:      currency,
-		Description:   desc,
-		Status:        string(model.StatusPending),
-		Timestamp:     time.Now().Format(time.RFC3339),
-	}
+func (s *PaymentService) processAsync(payment *model.Payment, userID, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
+	event := s.paymentEvent(payment, userID, fromAcc, toAcc, amountStr, currency, desc)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(paymentTraceContext(context.Background(), payment, userID, fromAcc), 10*time.Second)
 	defer cancel()
 
 	err := s.producer.Produce(ctx, kafka.TopicPaymentCreated, payment.ID.String(), event)
 	if err != nil {
 		slog.Error("Failed to publish payment event to Kafka", "payment_id", payment.ID, "error", err)
 		// Fallback to sync processing
-		return s.processSync(payment, fromAcc, toAcc, amountStr, desc)
+		return s.processSync(payment, userID, fromAcc, toAcc, amountStr, currency, desc)
 	}
 
 	slog.Info("Payment event published to Kafka", "payment_id", payment.ID, "topic", kafka.TopicPaymentCreated)
+	s.recordTransition(payment.ID, model.TransferQueued, model.ActorPaymentService, "published to "+kafka.TopicPaymentCreated)
 
 	// Return immediately with PENDING status - ledger service will process async
 	return payment, nil
 }
 
-// processSync calls ledger service synchronously (original behavior)
-func (s *PaymentService) processSync(payment *model.Payment, fromAcc, toAcc, amountStr, desc string) (*model.Payment, error) {
-	err := s.callLedger(fromAcc, toAcc, amountStr, desc)
+// processSync calls ledger service synchronously (original behavior). When a
+// producer is configured the outcome is still published, as the ledger
+// consumer does for payments it posts.
+func (s *PaymentService) processSync(payment *model.Payment, userID, fromAcc, toAcc, amountStr, currency, desc string) (*model.Payment, error) {
+	postings := append(transferPostings(fromAcc, toAcc, amountStr), s.feePostings(payment)...)
+	entryID, err := s.callLedger("Payment: "+desc, postings, "")
 	if err != nil {
-		s.Repo.UpdateStatus(payment.ID.String(), model.StatusFailed)
+		if _, resolveErr := s.payments.ResolvePayment(payment.ID.String(), model.StatusFailed, nil); resolveErr != nil {
+			slog.Error("Failed to record failed payment", "payment_id", payment.ID, "error", resolveErr)
+		}
+		payment.Status = model.StatusFailed
+		s.recordTransition(payment.ID, model.TransferFailed, model.ActorPaymentService, "ledger transfer failed: "+err.Error())
 		return payment, fmt.Errorf("ledger transfer failed: %w", err)
 	}
+	s.recordTransition(payment.ID, model.TransferPosted, model.ActorLedger, entryDetail(entryID))
 
-	// Mark Complete
-	s.Repo.UpdateStatus(payment.ID.String(), model.StatusCompleted)
+	// Mark Complete, keeping the journal entry so refunds can reverse it
+	completed, err := s.payments.ResolvePayment(payment.ID.String(), model.StatusCompleted, entryID)
+	if err != nil {
+		slog.Error("Failed to record completed payment", "payment_id", payment.ID, "error", err)
+	} else if !completed {
+		// Cancelled while the ledger call was in flight
+		return s.reverseCancelled(payment, entryID, fromAcc, toAcc, amountStr, desc)
+	}
 	payment.Status = model.StatusCompleted
+	payment.LedgerEntryID = entryID
+	s.recordTransition(payment.ID, model.TransferCompleted, model.ActorPaymentService, "")
+
+	if s.producer != nil {
+		event := s.paymentEvent(payment, userID, fromAcc, toAcc, amountStr, currency, desc)
+		ctx, cancel := context.WithTimeout(paymentTraceContext(context.Background(), payment, userID, fromAcc), 10*time.Second)
+		defer cancel()
+		if err := s.producer.Produce(ctx, kafka.TopicPaymentCompleted, payment.ID.String(), event); err != nil {
+			slog.Error("Failed to publish payment completed event", "payment_id", payment.ID, "error", err)
+		}
+	}
 
 	return payment, nil
 }
 
+// reverseCancelled undoes the ledger entry of a payment cancelled while it was
+// being posted synchronously
+func (s *PaymentService) reverseCancelled(payment *model.Payment, entryID *uuid.UUID, fromAcc, toAcc, amountStr, desc string) (*model.Payment, error) {
+	payment.Status = model.StatusCancelled
+	if entryID == nil {
+		slog.Error("Cancelled payment was posted but its ledger entry is unknown; reverse it manually", "payment_id", payment.ID)
+		return payment, ErrPaymentNotCancellable
+	}
+	postings := append(transferPostings(toAcc, fromAcc, amountStr), s.feeReversalPostings(payment)...)
+	if _, err := s.callLedger("Cancelled payment: "+desc, postings, entryID.String()); err != nil {
+		slog.Error("Failed to reverse cancelled payment; reverse it manually", "payment_id", payment.ID, "entry_id", entryID, "error", err)
+		return payment, fmt.Errorf("reversing cancelled payment: %w", err)
+	}
+	s.recordTransition(payment.ID, model.TransferReversed, model.ActorPaymentService, "posted after it was cancelled")
+	return payment, nil
+}
+
+// paymentTraceContext carries the payment's identifiers in the baggage of
+// ctx, so the spans of the services consuming its events can be searched by them
+func paymentTraceContext(ctx context.Context, payment *model.Payment, userID, fromAcc string) context.Context {
+	return tracing.WithBusinessContext(ctx, tracing.BusinessContext{PaymentID: payment.ID.String(), AccountID: fromAcc, UserID: userID})
+}
+
+// paymentEvent describes a payment for the payment topics
+func (s *PaymentService) paymentEvent(payment *model.Payment, userID, fromAcc, toAcc, amountStr, currency, desc string) kafka.PaymentEvent {
+	event := kafka.PaymentEvent{
+		PaymentID:     payment.ID.String(),
+		UserID:        userID,
+		FromAccountID: fromAcc,
+		ToAccountID:   toAcc,
+		Amount:        amountStr,
+		Currency:      currency,
+		Description:   desc,
+		Status:        string(payment.Status),
+		Timestamp:     time.Now().Format(time.RFC3339),
+	}
+	if payment.Fee.IsPositive() {
+		event.Fee = payment.Fee.String()
+		event.FeeAccountID = s.fees.IncomeAccountID
+	}
+	return event
+}
+
 // UpdatePaymentStatus updates payment status (called by consumer after processing)
 func (s *PaymentService) UpdatePaymentStatus(paymentID string, status model.PaymentStatus) error {
 	return s.Repo.UpdateStatus(paymentID, status)
 }
 
-func (s *PaymentService) callLedger(from, to, amount, desc string) error {
+// ReverseLedgerEntry posts a transfer from one account to another that reverses
+// all or part of an existing journal entry, returning the new entry's ID
+func (s *PaymentService) ReverseLedgerEntry(entryID, fromAcc, toAcc, amount, desc string) (*uuid.UUID, error) {
+	return s.callLedger(desc, transferPostings(fromAcc, toAcc, amount), entryID)
+}
+
+// ErrAccountUnavailable is returned when an account that decides who may act
+// could not be read from the ledger
+var ErrAccountUnavailable = errors.New("account could not be read from the ledger, try again")
+
+// AccountOwner returns the ID of the user who owns an account. Unlike the
+// balance check, it fails if the account cannot be read, since callers use
+// it to decide who may act on the account.
+func (s *PaymentService) AccountOwner(accountID string) (string, error) {
+	account := s.getAccount(accountID)
+	if account == nil {
+		return "", ErrAccountUnavailable
+	}
+	return account.UserID, nil
+}
+
+func transferPostings(from, to, amount string) []LedgerPosting {
+	return []LedgerPosting{
+		{AccountID: from, Amount: amount, Direction: -1}, // Credit Sender
+		{AccountID: to, Amount: amount, Direction: 1},    // Debit Receiver
+	}
+}
+
+// feePostings moves a payment's fee from the payer to the fee income account,
+// separately from the payment itself so the fee shows on statements
+func (s *PaymentService) feePostings(payment *model.Payment) []LedgerPosting {
+	if !payment.Fee.IsPositive() {
+		return nil
+	}
+	fee := payment.Fee.String()
+	return []LedgerPosting{
+		{AccountID: payment.FromAccountID.String(), Amount: fee, Direction: -1},
+		{AccountID: s.fees.IncomeAccountID, Amount: fee, Direction: 1},
+	}
+}
+
+// feeReversalPostings returns a payment's fee to the payer
+func (s *PaymentService) feeReversalPostings(payment *model.Payment) []LedgerPosting {
+	postings := s.feePostings(payment)
+	for i := range postings {
+		postings[i].Direction = -postings[i].Direction
+	}
+	return postings
+}
+
+// callLedger posts a transaction to the ledger. The returned entry ID is nil
+// if the ledger response could not be read.
+func (s *PaymentService) callLedger(desc string, postings []LedgerPosting, reversesEntryID string) (*uuid.UUID, error) {
 	req := LedgerTransactionRequest{
-		Description: "Payment: " + desc,
-		Postings: []struct {
-			AccountID string `json:"account_id"`
-			Amount    string `json:"amount"`
-			Direction int    `json:"direction"`
-		}{
-			{AccountID: from, Amount: amount, Direction: -1}, // Credit Sender
-			{AccountID: to, Amount: amount, Direction: 1},    // Debit Receiver
-		},
+		Description:     desc,
+		Postings:        postings,
+		ReversesEntryID: reversesEntryID,
 	}
 
 	body, _ := json.Marshal(req)
 	url := s.ledgerURL + "/api/v1/transactions"
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(body))
+	resp, err := s.ledger.Post(url, "application/json", bytes.NewBuffer(body))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
-		return errors.New("ledger service returned non-201 status")
+		return nil, errors.New("ledger service returned non-201 status")
 	}
 
-	return nil
+	var entry ledgerEntryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil || entry.ID == uuid.Nil {
+		slog.Warn("Could not read ledger entry ID", "error", err)
+		return nil, nil
+	}
+	return &entry.ID, nil
 }
 
 // AccountResponse represents the account data from ledger service
 type AccountResponse struct {
-	ID      string `json:"id"`
-	Balance string `json:"balance"`
+	ID           string  `json:"id"`
+	Balance      string  `json:"balance"`
+	Metadata     *string `json:"metadata"`
+	Status       string  `json:"status"`
+	CurrencyCode string  `json:"currency_code"`
+	// OverdraftLimit is how far below zero the balance may go, or nil
+	OverdraftLimit *string `json:"overdraft_limit"`
+	// OrgID is set for business accounts
+	OrgID *string `json:"org_id"`
+	// UserID is the account's owner
+	UserID string `json:"user_id"`
+}
+
+// productCode returns the product the account was opened for, recorded as
+// product_code in its metadata, or "" if unknown
+func (a *AccountResponse) productCode() string {
+	if a == nil || a.Metadata == nil {
+		return ""
+	}
+	var metadata struct {
+		ProductCode string `json:"product_code"`
+	}
+	if err := json.Unmarshal([]byte(*a.Metadata), &metadata); err != nil {
+		return ""
+	}
+	return metadata.ProductCode
 }
 
-// validateBalance checks if the from account has sufficient balance for the transfer
-func (s *PaymentService) validateBalance(fromAccountID, amountStr string) error {
-	// Call ledger service to get account balance
-	url := s.ledgerURL + "/api/v1/accounts/" + fromAccountID
-	resp, err := http.Get(url)
+// getAccount fetches an account from the ledger service, or from the account
+// cache if one is set. It returns nil if the account cannot be read; the
+// transfer then fails at the ledger if it is invalid.
+func (s *PaymentService) getAccount(accountID string) *AccountResponse {
+	if s.accounts != nil {
+		if account, ok := s.accounts.Get(accountID); ok {
+			return &account
+		}
+	}
+	url := s.ledgerURL + "/api/v1/accounts/" + accountID
+	resp, err := s.ledger.Get(url)
 	if err != nil {
 		// If we can't verify balance, log warning but allow transfer (may fail at ledger level)
-		slog.Warn("Could not verify balance, proceeding with transfer", "account", fromAccountID, "error", err)
+		slog.Warn("Could not verify balance, proceeding with transfer", "account", accountID, "error", err)
 		return nil
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		// If account not found, the transfer will fail anyway at ledger level
-		slog.Warn("Account not found or ledger error", "account", fromAccountID, "status", resp.StatusCode)
+		slog.Warn("Account not found or ledger error", "account", accountID, "status", resp.StatusCode)
 		return nil
 	}
 
@@ -222,16 +616,29 @@ func (s *PaymentService) validateBalance(fromAccountID, amountStr string) error
 		slog.Warn("Could not decode account response", "error", err)
 		return nil
 	}
+	if s.accounts != nil {
+		s.accounts.Set(accountID, account)
+	}
+	return &account
+}
 
+// validateBalance checks if the from account has sufficient balance for the
+// transfer and its fee, counting any overdraft limit as available. An account
+// that could not be read is not checked.
+func validateBalance(account *AccountResponse, amount decimal.Decimal) error {
+	if account == nil {
+		return nil
+	}
 	balance, err := decimal.NewFromString(account.Balance)
 	if err != nil {
 		slog.Warn("Could not parse account balance", "balance", account.Balance, "error", err)
 		return nil
 	}
 
-	amount, err := decimal.NewFromString(amountStr)
-	if err != nil {
-		return errors.New("invalid amount")
+	if account.OverdraftLimit != nil {
+		if limit, err := decimal.NewFromString(*account.OverdraftLimit); err == nil {
+			balance = balance.Add(limit)
+		}
 	}
 
 	if balance.LessThan(amount) {
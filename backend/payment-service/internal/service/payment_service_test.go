@@ -1,9 +1,14 @@
 package service
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -127,3 +132,36 @@ func TestGetEnvOrDefault(t *testing.T) {
 	result := getEnvOrDefault("NONEXISTENT_VAR_12345", "default")
 	assert.Equal(t, "default", result)
 }
+
+func TestValidateBalance_CountsOverdraft(t *testing.T) {
+	limit := "500"
+	account := &AccountResponse{ID: "acct", Balance: "-100.00", OverdraftLimit: &limit}
+
+	assert.NoError(t, validateBalance(account, decimal.NewFromInt(400)))
+	assert.Error(t, validateBalance(account, decimal.RequireFromString("400.01")))
+
+	account.OverdraftLimit = nil
+	assert.Error(t, validateBalance(account, decimal.NewFromInt(1)))
+	assert.NoError(t, validateBalance(nil, decimal.NewFromInt(1)))
+}
+
+func TestGetAccount_UsesAccountCache(t *testing.T) {
+	reads := 0
+	ledger := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		_ = json.NewEncoder(w).Encode(AccountResponse{ID: "acct", Balance: "100.00"})
+	}))
+	defer ledger.Close()
+	accounts := cache.NewLocalCache[AccountResponse](time.Minute)
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	svc.SetAccountCache(accounts)
+
+	assert.Equal(t, "100.00", svc.getAccount("acct").Balance)
+	assert.Equal(t, "100.00", svc.getAccount("acct").Balance)
+	assert.Equal(t, 1, reads, "the second read is served from the cache")
+
+	// A balance invalidation from the ledger makes the next read go to it
+	accounts.Invalidate([]string{"acct"})
+	svc.getAccount("acct")
+	assert.Equal(t, 2, reads)
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultPayoutListLimit caps how many payouts or batches the merchant API lists at once
+	DefaultPayoutListLimit = 100
+	maxPayoutListLimit     = 500
+	// MaxPayoutsPerBatch caps the payouts settled by one ledger entry; the
+	// rest wait for the next run
+	MaxPayoutsPerBatch = 500
+	// DefaultPayoutInterval is how often pending payouts are settled
+	DefaultPayoutInterval = time.Hour
+)
+
+var (
+	ErrPayoutNotFound      = errors.New("payout not found")
+	ErrPayoutBatchNotFound = errors.New("payout batch not found")
+	ErrInvalidPayout       = errors.New("invalid payout")
+)
+
+// PayoutRepository defines data access for payouts and their settlement batches
+type PayoutRepository interface {
+	Create(p *model.Payout) error
+	GetByID(id string) (*model.Payout, error)
+	ListByMerchant(merchantID string, status model.PayoutStatus, limit int) ([]model.Payout, error)
+	PendingMerchants() ([]uuid.UUID, error)
+	ListPending(merchantID string, limit int) ([]model.Payout, error)
+	ListByBatch(batchID string) ([]model.Payout, error)
+	// CreateBatch creates the batch with those of the payouts still pending,
+	// or creates nothing if none are
+	CreateBatch(b *model.PayoutBatch, payoutIDs []uuid.UUID) ([]model.Payout, error)
+	SaveBatch(b *model.PayoutBatch, payouts []model.Payout) error
+	GetBatch(id string) (*model.PayoutBatch, error)
+	ListBatches(merchantID string, limit int) ([]model.PayoutBatch, error)
+}
+
+// MerchantLookup loads the merchant a payout is paid for
+type MerchantLookup interface {
+	GetMerchant(id string) (*model.Merchant, error)
+}
+
+// PayoutService queues merchant payouts and settles them in batches. Each
+// batch is one ledger entry for all of a merchant's pending payouts in a
+// currency, so a merchant paying out to thousands of accounts costs the
+// ledger one transaction per run rather than one per payout.
+type PayoutService struct {
+	Repo      PayoutRepository
+	Merchants MerchantLookup
+	Payments  *PaymentService
+}
+
+// NewPayoutService creates a payout service. Payouts are priced by the payment
+// service's fees, if it has them.
+func NewPayoutService(repo PayoutRepository, merchants MerchantLookup, payments *PaymentService) *PayoutService {
+	return &PayoutService{Repo: repo, Merchants: merchants, Payments: payments}
+}
+
+// CreatePayoutRequest is a merchant's instruction to pay an account
+type CreatePayoutRequest struct {
+	DestinationAccountID string
+	Amount               string
+	Currency             string
+	Reference            string
+}
+
+// CreatePayout queues a payout from the merchant's settlement account for its
+// next settlement batch
+func (s *PayoutService) CreatePayout(merchant *model.Merchant, req CreatePayoutRequest) (*model.Payout, error) {
+	invalid := func(msg string) error { return fmt.Errorf("%w: %s", ErrInvalidPayout, msg) }
+
+	destination, err := uuid.Parse(req.DestinationAccountID)
+	if err != nil {
+		return nil, invalid("invalid destination account id")
+	}
+	if destination == merchant.SettlementAccountID {
+		return nil, invalid("cannot pay out to the settlement account")
+	}
+	currency := strings.ToUpper(req.Currency)
+	if !validCurrency(currency) {
+		return nil, invalid("currency must be a 3-letter code")
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return nil, invalid("amount must be greater than zero")
+	}
+	if !fitsCurrency(currency, amount) {
+		return nil, invalid("amount must not have more decimal places than the currency allows")
+	}
+
+	payout := &model.Payout{
+		MerchantID:           merchant.ID,
+		DestinationAccountID: destination,
+		Amount:               amount,
+		Currency:             currency,
+		Fee:                  decimal.Zero,
+		Reference:            strings.TrimSpace(req.Reference),
+		Status:               model.PayoutPending,
+	}
+	if err := s.Repo.Create(payout); err != nil {
+		return nil, err
+	}
+	return payout, nil
+}
+
+// GetPayout returns one of the merchant's payouts
+func (s *PayoutService) GetPayout(merchantID, id string) (*model.Payout, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrPayoutNotFound
+	}
+	payout, err := s.Repo.GetByID(id)
+	if err != nil || payout.MerchantID.String() != merchantID {
+		return nil, ErrPayoutNotFound
+	}
+	return payout, nil
+}
+
+// ListPayouts returns the merchant's payouts with a status, or all of them, newest first
+func (s *PayoutService) ListPayouts(merchantID string, status model.PayoutStatus, limit int) ([]model.Payout, error) {
+	switch status {
+	case "", model.PayoutPending, model.PayoutBatched, model.PayoutSettled, model.PayoutFailed:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidPayout, status)
+	}
+	return s.Repo.ListByMerchant(merchantID, status, payoutListLimit(limit))
+}
+
+// ListBatches returns the merchant's settlement batches, newest first
+func (s *PayoutService) ListBatches(merchantID string, limit int) ([]model.PayoutBatch, error) {
+	return s.Repo.ListBatches(merchantID, payoutListLimit(limit))
+}
+
+func payoutListLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPayoutListLimit
+	}
+	if limit > maxPayoutListLimit {
+		return maxPayoutListLimit
+	}
+	return limit
+}
+
+// PayoutBatchReport is a settlement batch with the payouts it settled
+type PayoutBatchReport struct {
+	Batch   *model.PayoutBatch `json:"batch"`
+	Payouts []model.Payout     `json:"payouts"`
+}
+
+// GetBatchReport returns one of the merchant's batches and its payouts
+func (s *PayoutService) GetBatchReport(merchantID, batchID string) (*PayoutBatchReport, error) {
+	if _, err := uuid.Parse(batchID); err != nil {
+		return nil, ErrPayoutBatchNotFound
+	}
+	batch, err := s.Repo.GetBatch(batchID)
+	if err != nil || batch.MerchantID.String() != merchantID {
+		return nil, ErrPayoutBatchNotFound
+	}
+	payouts, err := s.Repo.ListByBatch(batchID)
+	if err != nil {
+		return nil, err
+	}
+	return &PayoutBatchReport{Batch: batch, Payouts: payouts}, nil
+}
+
+// SettlePending settles every merchant's pending payouts, one batch per
+// merchant and currency, and returns how many batches it created. A merchant
+// whose batch cannot be created is retried on the next run.
+func (s *PayoutService) SettlePending(now time.Time) (int, error) {
+	merchantIDs, err := s.Repo.PendingMerchants()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, merchantID := range merchantIDs {
+		merchant, err := s.Merchants.GetMerchant(merchantID.String())
+		if err != nil {
+			slog.Error("Skipping payouts of unknown merchant", "merchant_id", merchantID, "error", err)
+			continue
+		}
+		if !merchant.Active {
+			continue
+		}
+		pending, err := s.Repo.ListPending(merchantID.String(), MaxPayoutsPerBatch)
+		if err != nil {
+			return count, err
+		}
+
+		byCurrency := make(map[string][]uuid.UUID)
+		var currencies []string
+		for _, p := range pending {
+			if _, ok := byCurrency[p.Currency]; !ok {
+				currencies = append(currencies, p.Currency)
+			}
+			byCurrency[p.Currency] = append(byCurrency[p.Currency], p.ID)
+		}
+		for _, currency := range currencies {
+			batch, err := s.settleBatch(merchant, currency, byCurrency[currency], now)
+			if err != nil {
+				return count, err
+			}
+			if batch != nil {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+// settleBatch claims the payouts into a new batch, prices them and posts the
+// batch to the ledger. A batch that cannot be posted fails with its payouts
+// rather than being retried, so a payout is never paid twice. It returns nil
+// if another run claimed the payouts first.
+func (s *PayoutService) settleBatch(merchant *model.Merchant, currency string, payoutIDs []uuid.UUID, now time.Time) (*model.PayoutBatch, error) {
+	batch := &model.PayoutBatch{
+		ID:                  uuid.New(),
+		MerchantID:          merchant.ID,
+		SettlementAccountID: merchant.SettlementAccountID,
+		Currency:            currency,
+		GrossAmount:         decimal.Zero,
+		FeeAmount:           decimal.Zero,
+		NetAmount:           decimal.Zero,
+		Status:              model.PayoutBatchProcessing,
+	}
+	payouts, err := s.Repo.CreateBatch(batch, payoutIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(payouts) == 0 {
+		return nil, nil
+	}
+
+	settlement := s.Payments.getAccount(merchant.SettlementAccountID.String())
+	for i := range payouts {
+		p := &payouts[i]
+		if err := s.price(p, settlement.productCode()); err != nil {
+			p.Status = model.PayoutFailed
+			p.Error = err.Error()
+			batch.FailedCount++
+			continue
+		}
+		batch.PayoutCount++
+		batch.GrossAmount = batch.GrossAmount.Add(p.Amount)
+		batch.FeeAmount = batch.FeeAmount.Add(p.Fee)
+	}
+	batch.NetAmount = batch.GrossAmount.Sub(batch.FeeAmount)
+
+	if err := s.post(batch, payouts, settlement); err != nil {
+		slog.Error("Payout batch failed", "batch_id", batch.ID, "merchant_id", merchant.ID, "error", err)
+		batch.Status = model.PayoutBatchFailed
+		batch.Error = err.Error()
+		for i := range payouts {
+			if payouts[i].Status == model.PayoutBatched {
+				payouts[i].Status = model.PayoutFailed
+				payouts[i].Error = "batch failed: " + err.Error()
+			}
+		}
+	} else {
+		settledAt := now
+		batch.Status = model.PayoutBatchSettled
+		batch.SettledAt = &settledAt
+		for i := range payouts {
+			if payouts[i].Status == model.PayoutBatched {
+				payouts[i].Status = model.PayoutSettled
+				payouts[i].SettledAt = &settledAt
+			}
+		}
+	}
+
+	if err := s.Repo.SaveBatch(batch, payouts); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// price sets the payout's fee, which must leave something to pay out
+func (s *PayoutService) price(p *model.Payout, productCode string) error {
+	fees := s.Payments.fees
+	if fees == nil {
+		return nil
+	}
+	quote, err := fees.Quote(model.PaymentTypePayout, productCode, p.Currency, p.Amount)
+	if err != nil {
+		return fmt.Errorf("price payout: %w", err)
+	}
+	if !quote.Fee.LessThan(p.Amount) {
+		return fmt.Errorf("fee %s is not less than the payout amount", quote.Fee)
+	}
+	p.Fee = quote.Fee
+	p.FeeScheduleID = quote.ScheduleID
+	return nil
+}
+
+// post checks the settlement account covers the batch and posts it as one
+// ledger entry
+func (s *PayoutService) post(batch *model.PayoutBatch, payouts []model.Payout, settlement *AccountResponse) error {
+	if batch.PayoutCount == 0 {
+		return errors.New("no payouts could be priced")
+	}
+	if err := validateBalance(settlement, batch.GrossAmount); err != nil {
+		return err
+	}
+
+	desc := fmt.Sprintf("Payout batch %s (%d payouts)", batch.ID, batch.PayoutCount)
+	entryID, err := s.Payments.callLedger(desc, s.batchPostings(batch, payouts), "")
+	if err != nil {
+		return fmt.Errorf("ledger: %w", err)
+	}
+	batch.LedgerEntryID = entryID
+	return nil
+}
+
+// batchPostings debits the gross from the settlement account and credits
+// each destination the net of its payouts, once per account, and the fee
+// income account the fees
+func (s *PayoutService) batchPostings(batch *model.PayoutBatch, payouts []model.Payout) []LedgerPosting {
+	postings := []LedgerPosting{{AccountID: batch.SettlementAccountID.String(), Amount: batch.GrossAmount.String(), Direction: -1}}
+	index := make(map[uuid.UUID]int)
+	net := make([]decimal.Decimal, 0, len(payouts))
+	for _, p := range payouts {
+		if p.Status != model.PayoutBatched {
+			continue
+		}
+		i, ok := index[p.DestinationAccountID]
+		if !ok {
+			i = len(postings)
+			index[p.DestinationAccountID] = i
+			postings = append(postings, LedgerPosting{AccountID: p.DestinationAccountID.String(), Direction: 1})
+			net = append(net, decimal.Zero)
+		}
+		net[i-1] = net[i-1].Add(p.NetAmount())
+	}
+	for i := range net {
+		postings[i+1].Amount = net[i].String()
+	}
+	if batch.FeeAmount.IsPositive() {
+		postings = append(postings, LedgerPosting{AccountID: s.Payments.fees.IncomeAccountID, Amount: batch.FeeAmount.String(), Direction: 1})
+	}
+	return postings
+}
+
+// PayoutJob settles pending payouts
+func (s *PayoutService) PayoutJob(_ context.Context, _ *jobs.Job) error {
+	n, err := s.SettlePending(time.Now())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		slog.Info("Settled payout batches", "count", n)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPayoutRepository keeps payouts and batches in memory, in creation order
+type memoryPayoutRepository struct {
+	mu      sync.Mutex
+	payouts []model.Payout
+	batches []model.PayoutBatch
+}
+
+func (r *memoryPayoutRepository) Create(p *model.Payout) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p.ID = uuid.New()
+	r.payouts = append(r.payouts, *p)
+	return nil
+}
+
+func (r *memoryPayoutRepository) GetByID(id string) (*model.Payout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.payouts {
+		if p.ID.String() == id {
+			return &p, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryPayoutRepository) ListByMerchant(merchantID string, status model.PayoutStatus, limit int) ([]model.Payout, error) {
+	return r.list(func(p model.Payout) bool {
+		return p.MerchantID.String() == merchantID && (status == "" || p.Status == status)
+	}), nil
+}
+
+func (r *memoryPayoutRepository) PendingMerchants() ([]uuid.UUID, error) {
+	seen := map[uuid.UUID]bool{}
+	var ids []uuid.UUID
+	for _, p := range r.list(func(p model.Payout) bool { return p.Status == model.PayoutPending }) {
+		if !seen[p.MerchantID] {
+			seen[p.MerchantID] = true
+			ids = append(ids, p.MerchantID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryPayoutRepository) ListPending(merchantID string, limit int) ([]model.Payout, error) {
+	pending := r.list(func(p model.Payout) bool {
+		return p.MerchantID.String() == merchantID && p.Status == model.PayoutPending
+	})
+	if len(pending) > limit {
+		pending = pending[:limit]
+	}
+	return pending, nil
+}
+
+func (r *memoryPayoutRepository) ListByBatch(batchID string) ([]model.Payout, error) {
+	return r.list(func(p model.Payout) bool { return p.BatchID != nil && p.BatchID.String() == batchID }), nil
+}
+
+func (r *memoryPayoutRepository) list(match func(model.Payout) bool) []model.Payout {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var payouts []model.Payout
+	for _, p := range r.payouts {
+		if match(p) {
+			payouts = append(payouts, p)
+		}
+	}
+	return payouts
+}
+
+func (r *memoryPayoutRepository) CreateBatch(b *model.PayoutBatch, payoutIDs []uuid.UUID) ([]model.Payout, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var claimed []model.Payout
+	for i := range r.payouts {
+		p := &r.payouts[i]
+		for _, id := range payoutIDs {
+			if p.ID == id && p.Status == model.PayoutPending {
+				p.Status = model.PayoutBatched
+				p.BatchID = &b.ID
+				claimed = append(claimed, *p)
+			}
+		}
+	}
+	if len(claimed) > 0 {
+		r.batches = append(r.batches, *b)
+	}
+	return claimed, nil
+}
+
+func (r *memoryPayoutRepository) SaveBatch(b *model.PayoutBatch, payouts []model.Payout) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.batches {
+		if r.batches[i].ID == b.ID {
+			r.batches[i] = *b
+		}
+	}
+	for _, saved := range payouts {
+		for i := range r.payouts {
+			if r.payouts[i].ID == saved.ID {
+				r.payouts[i] = saved
+			}
+		}
+	}
+	return nil
+}
+
+func (r *memoryPayoutRepository) GetBatch(id string) (*model.PayoutBatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.batches {
+		if b.ID.String() == id {
+			return &b, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memoryPayoutRepository) ListBatches(merchantID string, limit int) ([]model.PayoutBatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var batches []model.PayoutBatch
+	for _, b := range r.batches {
+		if b.MerchantID.String() == merchantID {
+			batches = append(batches, b)
+		}
+	}
+	return batches, nil
+}
+
+// merchantTable looks merchants up from a fixed set
+type merchantTable map[string]*model.Merchant
+
+func (m merchantTable) GetMerchant(id string) (*model.Merchant, error) {
+	if merchant, ok := m[id]; ok {
+		return merchant, nil
+	}
+	return nil, ErrMerchantNotFound
+}
+
+// payoutLedger answers account lookups with a balance and records posted transactions
+type payoutLedger struct {
+	*httptest.Server
+	mu      sync.Mutex
+	balance string
+	posted  []LedgerTransactionRequest
+}
+
+func newPayoutLedger(t *testing.T, balance string) *payoutLedger {
+	l := &payoutLedger{balance: balance}
+	l.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if r.Method == http.MethodGet {
+			_ = json.NewEncoder(w).Encode(AccountResponse{ID: "settlement", Balance: l.balance})
+			return
+		}
+		var req LedgerTransactionRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		l.posted = append(l.posted, req)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]string{"ID": uuid.NewString()})
+	}))
+	t.Cleanup(l.Close)
+	return l
+}
+
+func newPayoutService(t *testing.T, balance string) (*PayoutService, *memoryPayoutRepository, *payoutLedger, *model.Merchant, string) {
+	ledger := newPayoutLedger(t, balance)
+	merchant := &model.Merchant{ID: uuid.New(), SettlementAccountID: uuid.New(), Active: true}
+
+	income := uuid.NewString()
+	fees := NewFeeService(&memoryFeeRepository{}, income)
+	_, err := fees.CreateSchedule(&model.FeeSchedule{Name: "Payouts", Currency: "GBP", PaymentType: model.PaymentTypePayout, Method: model.FeeFlat, FlatAmount: dec("0.50"), Active: true})
+	require.NoError(t, err)
+	payments := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	payments.SetFees(fees)
+
+	repo := &memoryPayoutRepository{}
+	return NewPayoutService(repo, merchantTable{merchant.ID.String(): merchant}, payments), repo, ledger, merchant, income
+}
+
+func TestCreatePayout_Validation(t *testing.T) {
+	svc, _, _, merchant, _ := newPayoutService(t, "1000.00")
+	valid := func() CreatePayoutRequest {
+		return CreatePayoutRequest{DestinationAccountID: uuid.NewString(), Amount: "10.00", Currency: "gbp", Reference: " order-1 "}
+	}
+
+	payout, err := svc.CreatePayout(merchant, valid())
+	require.NoError(t, err)
+	assert.Equal(t, model.PayoutPending, payout.Status)
+	assert.Equal(t, "GBP", payout.Currency)
+	assert.Equal(t, "order-1", payout.Reference)
+
+	for name, change := range map[string]func(*CreatePayoutRequest){
+		"settlement account": func(r *CreatePayoutRequest) { r.DestinationAccountID = merchant.SettlementAccountID.String() },
+		"zero amount":        func(r *CreatePayoutRequest) { r.Amount = "0" },
+		"sub-minor amount":   func(r *CreatePayoutRequest) { r.Amount = "10.005" },
+		"whole yen":          func(r *CreatePayoutRequest) { r.Amount, r.Currency = "1.5", "JPY" },
+		"currency":           func(r *CreatePayoutRequest) { r.Currency = "POUNDS" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := valid()
+			change(&req)
+			_, err := svc.CreatePayout(merchant, req)
+			assert.ErrorIs(t, err, ErrInvalidPayout)
+		})
+	}
+}
+
+func TestSettlePending_OneLedgerEntryPerBatch(t *testing.T) {
+	svc, repo, ledger, merchant, income := newPayoutService(t, "1000.00")
+	seller, other := uuid.NewString(), uuid.NewString()
+	for _, req := range []CreatePayoutRequest{
+		{DestinationAccountID: seller, Amount: "10.00", Currency: "GBP"},
+		{DestinationAccountID: other, Amount: "25.00", Currency: "GBP"},
+		{DestinationAccountID: seller, Amount: "5.00", Currency: "GBP"},
+		{DestinationAccountID: seller, Amount: "7.00", Currency: "USD"},
+	} {
+		_, err := svc.CreatePayout(merchant, req)
+		require.NoError(t, err)
+	}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	n, err := svc.SettlePending(now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n, "one batch per currency")
+	require.Len(t, ledger.posted, 2)
+
+	// The seller's two GBP payouts are credited in one posting, less a 0.50 fee each
+	assert.Equal(t, []LedgerPosting{
+		{AccountID: merchant.SettlementAccountID.String(), Amount: "40", Direction: -1},
+		{AccountID: seller, Amount: "14", Direction: 1},
+		{AccountID: other, Amount: "24.5", Direction: 1},
+		{AccountID: income, Amount: "1.5", Direction: 1},
+	}, ledger.posted[0].Postings)
+	// USD has no payout fee schedule, so it is free
+	assert.Len(t, ledger.posted[1].Postings, 2)
+
+	batches, _ := repo.ListBatches(merchant.ID.String(), 0)
+	require.Len(t, batches, 2)
+	report, err := svc.GetBatchReport(merchant.ID.String(), batches[0].ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.PayoutBatchSettled, report.Batch.Status)
+	assert.Equal(t, 3, report.Batch.PayoutCount)
+	assert.Equal(t, "40", report.Batch.GrossAmount.String())
+	assert.Equal(t, "1.5", report.Batch.FeeAmount.String())
+	assert.Equal(t, "38.5", report.Batch.NetAmount.String())
+	assert.NotNil(t, report.Batch.LedgerEntryID)
+	require.Len(t, report.Payouts, 3)
+	for _, p := range report.Payouts {
+		assert.Equal(t, model.PayoutSettled, p.Status)
+		assert.Equal(t, "0.5", p.Fee.String())
+		assert.Equal(t, now, *p.SettledAt)
+	}
+
+	// Nothing is left to settle
+	n, err = svc.SettlePending(now)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Len(t, ledger.posted, 2)
+
+	_, err = svc.GetBatchReport(uuid.NewString(), batches[0].ID.String())
+	assert.ErrorIs(t, err, ErrPayoutBatchNotFound, "another merchant's batch")
+}
+
+func TestSettlePending_FeeAtLeastAmountFailsPayout(t *testing.T) {
+	svc, repo, ledger, merchant, _ := newPayoutService(t, "1000.00")
+	tiny, err := svc.CreatePayout(merchant, CreatePayoutRequest{DestinationAccountID: uuid.NewString(), Amount: "0.50", Currency: "GBP"})
+	require.NoError(t, err)
+	_, err = svc.CreatePayout(merchant, CreatePayoutRequest{DestinationAccountID: uuid.NewString(), Amount: "20.00", Currency: "GBP"})
+	require.NoError(t, err)
+
+	_, err = svc.SettlePending(time.Now())
+	require.NoError(t, err)
+	require.Len(t, ledger.posted, 1)
+
+	failed, _ := repo.GetByID(tiny.ID.String())
+	assert.Equal(t, model.PayoutFailed, failed.Status)
+	assert.Contains(t, failed.Error, "fee")
+	batch, _ := repo.GetBatch(failed.BatchID.String())
+	assert.Equal(t, model.PayoutBatchSettled, batch.Status)
+	assert.Equal(t, 1, batch.PayoutCount)
+	assert.Equal(t, 1, batch.FailedCount)
+	assert.Equal(t, "20", batch.GrossAmount.String())
+}
+
+func TestSettlePending_InsufficientFundsFailsBatch(t *testing.T) {
+	svc, repo, ledger, merchant, _ := newPayoutService(t, "30.00")
+	for _, amount := range []string{"20.00", "15.00"} {
+		_, err := svc.CreatePayout(merchant, CreatePayoutRequest{DestinationAccountID: uuid.NewString(), Amount: amount, Currency: "GBP"})
+		require.NoError(t, err)
+	}
+
+	n, err := svc.SettlePending(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, ledger.posted)
+
+	batches, _ := repo.ListBatches(merchant.ID.String(), 0)
+	require.Len(t, batches, 1)
+	assert.Equal(t, model.PayoutBatchFailed, batches[0].Status)
+	assert.Contains(t, batches[0].Error, "insufficient funds")
+	payouts, _ := svc.ListPayouts(merchant.ID.String(), model.PayoutFailed, 0)
+	assert.Len(t, payouts, 2, "payouts fail with their batch rather than being paid later")
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrPaymentNotFound      = errors.New("payment not found")
+	ErrPaymentNotRefundable = errors.New("only completed payments can be refunded")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount left to refund")
+	ErrInvalidRefund        = errors.New("invalid refund")
+	ErrRefundForbidden      = errors.New("only the payee can refund a payment")
+)
+
+// RefundRepository defines data access for refunds
+type RefundRepository interface {
+	GetPayment(id string) (*model.Payment, error)
+	ReserveRefund(refund *model.Refund) (bool, error)
+	CompleteRefund(refund *model.Refund) error
+	FailRefund(refund *model.Refund) error
+	ListByPayment(paymentID string) ([]model.Refund, error)
+}
+
+// LedgerReverser posts ledger entries that reverse an earlier entry, and
+// reads who owns the accounts involved
+type LedgerReverser interface {
+	ReverseLedgerEntry(entryID, fromAcc, toAcc, amount, desc string) (*uuid.UUID, error)
+	AccountOwner(accountID string) (string, error)
+}
+
+// RefundService returns completed payments to the payer, in full or in parts
+type RefundService struct {
+	Repo   RefundRepository
+	Ledger LedgerReverser
+}
+
+func NewRefundService(repo RefundRepository, ledger LedgerReverser) *RefundService {
+	return &RefundService{Repo: repo, Ledger: ledger}
+}
+
+// RefundPayment refunds amount of a completed payment, or everything not yet
+// refunded when amount is empty. Only the owner of the payee account, who
+// gives the money back, may refund; the payer gets ErrRefundForbidden and
+// other users do not find the payment.
+func (s *RefundService) RefundPayment(userID, paymentID, amountStr, reason string) (*model.Refund, error) {
+	return s.refund(userID, paymentID, amountStr, reason, func(p *model.Payment) error {
+		payee, err := s.payee(userID, p)
+		switch {
+		case err != nil:
+			return err
+		case payee:
+			return nil
+		case paidBy(userID, p):
+			return ErrRefundForbidden
+		default:
+			return ErrPaymentNotFound
+		}
+	})
+}
+
+// RefundAnyPayment refunds any completed payment on behalf of its payee, for
+// operations staff
+func (s *RefundService) RefundAnyPayment(adminID, paymentID, amountStr, reason string) (*model.Refund, error) {
+	return s.refund(adminID, paymentID, amountStr, reason, func(*model.Payment) error { return nil })
+}
+
+// refund refunds a payment if allowed returns no error for it. The amount is
+// reserved on the payment before the reversing ledger entry is posted and
+// released again if posting fails.
+func (s *RefundService) refund(userID, paymentID, amountStr, reason string, allowed func(*model.Payment) error) (*model.Refund, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	payment, err := s.Repo.GetPayment(paymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	if err := allowed(payment); err != nil {
+		return nil, err
+	}
+	if payment.Status != model.StatusCompleted {
+		return nil, ErrPaymentNotRefundable
+	}
+	if payment.LedgerEntryID == nil {
+		return nil, fmt.Errorf("%w: the payment has no ledger entry to reverse", ErrPaymentNotRefundable)
+	}
+
+	remaining := payment.Amount.Sub(payment.RefundedAmount)
+	amount := remaining
+	if amountStr != "" {
+		amount, err = decimal.NewFromString(amountStr)
+		if err != nil || !amount.IsPositive() {
+			return nil, fmt.Errorf("%w: amount must be greater than zero", ErrInvalidRefund)
+		}
+		if !amount.Equal(amount.Round(4)) {
+			return nil, fmt.Errorf("%w: amount has too many decimal places", ErrInvalidRefund)
+		}
+	}
+	if !amount.IsPositive() || amount.GreaterThan(remaining) {
+		return nil, ErrRefundExceedsPayment
+	}
+
+	refund := &model.Refund{
+		PaymentID:   payment.ID,
+		RequestedBy: userUUID,
+		Amount:      amount,
+		Currency:    payment.Currency,
+		Reason:      reason,
+		Status:      model.RefundPending,
+	}
+	reserved, err := s.Repo.ReserveRefund(refund)
+	if err != nil {
+		return nil, err
+	}
+	if !reserved {
+		// A concurrent refund used up the remaining amount
+		return nil, ErrRefundExceedsPayment
+	}
+
+	desc := "Refund: " + payment.Description
+	if reason != "" {
+		desc += " (" + reason + ")"
+	}
+	// The payee returns the money to the payer
+	entryID, err := s.Ledger.ReverseLedgerEntry(payment.LedgerEntryID.String(), payment.ToAccountID.String(), payment.FromAccountID.String(), amount.String(), desc)
+	if err != nil {
+		refund.Status = model.RefundFailed
+		refund.FailureReason = err.Error()
+		if failErr := s.Repo.FailRefund(refund); failErr != nil {
+			slog.Error("Failed to release refund reservation", "refund_id", refund.ID, "error", failErr)
+		}
+		return refund, fmt.Errorf("ledger reversal failed: %w", err)
+	}
+
+	refund.Status = model.RefundCompleted
+	refund.LedgerEntryID = entryID
+	if err := s.Repo.CompleteRefund(refund); err != nil {
+		// The money has moved; the refund stays PENDING and keeps its reservation
+		slog.Error("Failed to record completed refund", "refund_id", refund.ID, "error", err)
+		return refund, err
+	}
+	return refund, nil
+}
+
+// ListRefunds returns the refunds of one of the user's payments, made or
+// received, oldest first; other payments are not found
+func (s *RefundService) ListRefunds(userID, paymentID string) ([]model.Refund, error) {
+	return s.listRefunds(paymentID, func(p *model.Payment) (bool, error) {
+		if paidBy(userID, p) {
+			return true, nil
+		}
+		return s.payee(userID, p)
+	})
+}
+
+// ListAnyRefunds returns the refunds of any payment, for operations staff
+func (s *RefundService) ListAnyRefunds(paymentID string) ([]model.Refund, error) {
+	return s.listRefunds(paymentID, func(*model.Payment) (bool, error) { return true, nil })
+}
+
+func (s *RefundService) listRefunds(paymentID string, visible func(*model.Payment) (bool, error)) ([]model.Refund, error) {
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	payment, err := s.Repo.GetPayment(paymentID)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	ok, err := visible(payment)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+	return s.Repo.ListByPayment(paymentID)
+}
+
+// payee reports whether the user owns the account the payment was made to
+func (s *RefundService) payee(userID string, p *model.Payment) (bool, error) {
+	owner, err := s.Ledger.AccountOwner(p.ToAccountID.String())
+	if err != nil {
+		return false, fmt.Errorf("failed to read payee account: %w", err)
+	}
+	return owner != "" && owner == userID, nil
+}
+
+// paidBy reports whether the user made the payment
+func paidBy(userID string, p *model.Payment) bool {
+	return p.UserID != nil && p.UserID.String() == userID
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockRefundRepository is a mock implementation of RefundRepository
+type MockRefundRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefundRepository) GetPayment(id string) (*model.Payment, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Payment), args.Error(1)
+}
+
+func (m *MockRefundRepository) ReserveRefund(refund *model.Refund) (bool, error) {
+	args := m.Called(refund)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefundRepository) CompleteRefund(refund *model.Refund) error {
+	args := m.Called(refund)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) FailRefund(refund *model.Refund) error {
+	args := m.Called(refund)
+	return args.Error(0)
+}
+
+func (m *MockRefundRepository) ListByPayment(paymentID string) ([]model.Refund, error) {
+	args := m.Called(paymentID)
+	return args.Get(0).([]model.Refund), args.Error(1)
+}
+
+// MockLedgerReverser is a mock implementation of LedgerReverser
+type MockLedgerReverser struct {
+	mock.Mock
+}
+
+func (m *MockLedgerReverser) ReverseLedgerEntry(entryID, fromAcc, toAcc, amount, desc string) (*uuid.UUID, error) {
+	args := m.Called(entryID, fromAcc, toAcc, amount, desc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*uuid.UUID), args.Error(1)
+}
+
+func (m *MockLedgerReverser) AccountOwner(accountID string) (string, error) {
+	args := m.Called(accountID)
+	return args.String(0), args.Error(1)
+}
+
+// payeeOwner makes userID the owner of the payment's payee account and
+// returns it
+func payeeOwner(ledger *MockLedgerReverser, payment *model.Payment, userID string) string {
+	ledger.On("AccountOwner", payment.ToAccountID.String()).Return(userID, nil)
+	return userID
+}
+
+func completedPayment(amount, refunded int64) *model.Payment {
+	entryID := uuid.New()
+	return &model.Payment{
+		ID:             uuid.New(),
+		FromAccountID:  uuid.New(),
+		ToAccountID:    uuid.New(),
+		Amount:         decimal.NewFromInt(amount),
+		RefundedAmount: decimal.NewFromInt(refunded),
+		Currency:       "USD",
+		Status:         model.StatusCompleted,
+		Description:    "Dinner",
+		LedgerEntryID:  &entryID,
+	}
+}
+
+func TestRefundPayment_PartialRefundReversesLedgerEntry(t *testing.T) {
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	svc := NewRefundService(repo, ledger)
+	payment := completedPayment(100, 30)
+	reversal := uuid.New()
+
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	repo.On("ReserveRefund", mock.MatchedBy(func(r *model.Refund) bool {
+		return r.Amount.Equal(decimal.NewFromInt(50)) && r.Status == model.RefundPending && r.Currency == "USD"
+	})).Return(true, nil)
+	// Money moves back from the payee to the payer
+	ledger.On("ReverseLedgerEntry", payment.LedgerEntryID.String(), payment.ToAccountID.String(), payment.FromAccountID.String(), "50", "Refund: Dinner (wrong amount)").
+		Return(&reversal, nil)
+	repo.On("CompleteRefund", mock.AnythingOfType("*model.Refund")).Return(nil)
+
+	refund, err := svc.RefundPayment(payeeOwner(ledger, payment, uuid.New().String()), payment.ID.String(), "50", "wrong amount")
+	require.NoError(t, err)
+	assert.Equal(t, model.RefundCompleted, refund.Status)
+	assert.Equal(t, reversal, *refund.LedgerEntryID)
+	// The payment itself is not changed by the refund
+	assert.Equal(t, model.StatusCompleted, payment.Status)
+	repo.AssertExpectations(t)
+	ledger.AssertExpectations(t)
+}
+
+func TestRefundPayment_DefaultsToRemainingAmount(t *testing.T) {
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	payment := completedPayment(100, 40)
+	reversal := uuid.New()
+
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	repo.On("ReserveRefund", mock.AnythingOfType("*model.Refund")).Return(true, nil)
+	ledger.On("ReverseLedgerEntry", mock.Anything, mock.Anything, mock.Anything, "60", mock.Anything).Return(&reversal, nil)
+	repo.On("CompleteRefund", mock.AnythingOfType("*model.Refund")).Return(nil)
+
+	refund, err := NewRefundService(repo, ledger).RefundPayment(payeeOwner(ledger, payment, uuid.New().String()), payment.ID.String(), "", "")
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(60).Equal(refund.Amount))
+}
+
+func TestRefundPayment_LedgerFailureReleasesReservation(t *testing.T) {
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	payment := completedPayment(100, 0)
+
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	repo.On("ReserveRefund", mock.AnythingOfType("*model.Refund")).Return(true, nil)
+	ledger.On("ReverseLedgerEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("ledger down"))
+	repo.On("FailRefund", mock.MatchedBy(func(r *model.Refund) bool {
+		return r.Status == model.RefundFailed && r.FailureReason == "ledger down"
+	})).Return(nil)
+
+	refund, err := NewRefundService(repo, ledger).RefundPayment(payeeOwner(ledger, payment, uuid.New().String()), payment.ID.String(), "10", "")
+	assert.Error(t, err)
+	assert.Equal(t, model.RefundFailed, refund.Status)
+	repo.AssertExpectations(t)
+}
+
+func TestRefundPayment_Rejections(t *testing.T) {
+	userID := uuid.New().String()
+
+	tests := []struct {
+		name    string
+		payment func() *model.Payment
+		amount  string
+		reserve bool
+		wantErr error
+	}{
+		{"pending payment", func() *model.Payment { p := completedPayment(100, 0); p.Status = model.StatusPending; return p }, "10", false, ErrPaymentNotRefundable},
+		{"failed payment", func() *model.Payment { p := completedPayment(100, 0); p.Status = model.StatusFailed; return p }, "10", false, ErrPaymentNotRefundable},
+		{"no ledger entry", func() *model.Payment { p := completedPayment(100, 0); p.LedgerEntryID = nil; return p }, "10", false, ErrPaymentNotRefundable},
+		{"negative amount", func() *model.Payment { return completedPayment(100, 0) }, "-5", false, ErrInvalidRefund},
+		{"too many decimals", func() *model.Payment { return completedPayment(100, 0) }, "1.00001", false, ErrInvalidRefund},
+		{"over remaining amount", func() *model.Payment { return completedPayment(100, 80) }, "20.01", false, ErrRefundExceedsPayment},
+		{"fully refunded", func() *model.Payment { return completedPayment(100, 100) }, "", false, ErrRefundExceedsPayment},
+		{"concurrent refund", func() *model.Payment { return completedPayment(100, 0) }, "100", true, ErrRefundExceedsPayment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockRefundRepository)
+			payment := tt.payment()
+			repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+			if tt.reserve {
+				repo.On("ReserveRefund", mock.AnythingOfType("*model.Refund")).Return(false, nil)
+			}
+
+			ledger := new(MockLedgerReverser)
+			payeeOwner(ledger, payment, userID)
+
+			_, err := NewRefundService(repo, ledger).RefundPayment(userID, payment.ID.String(), tt.amount, "")
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("unknown payment", func(t *testing.T) {
+		repo := new(MockRefundRepository)
+		id := uuid.New().String()
+		repo.On("GetPayment", id).Return(nil, errors.New("record not found"))
+
+		_, err := NewRefundService(repo, new(MockLedgerReverser)).RefundPayment(userID, id, "10", "")
+		assert.ErrorIs(t, err, ErrPaymentNotFound)
+	})
+}
+
+func TestRefundPayment_OnlyPayeeMayRefund(t *testing.T) {
+	payer := uuid.New()
+	payment := completedPayment(100, 0)
+	payment.UserID = &payer
+
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	payeeOwner(ledger, payment, uuid.New().String())
+	svc := NewRefundService(repo, ledger)
+
+	// The payer cannot take the money back themselves
+	_, err := svc.RefundPayment(payer.String(), payment.ID.String(), "10", "")
+	assert.ErrorIs(t, err, ErrRefundForbidden)
+
+	// Other users do not find the payment
+	_, err = svc.RefundPayment(uuid.New().String(), payment.ID.String(), "10", "")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+	ledger.AssertNotCalled(t, "ReverseLedgerEntry", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	repo.AssertNotCalled(t, "ReserveRefund", mock.Anything)
+}
+
+func TestRefundPayment_PayeeAccountUnreadable(t *testing.T) {
+	payment := completedPayment(100, 0)
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	ledger.On("AccountOwner", payment.ToAccountID.String()).Return("", ErrAccountUnavailable)
+
+	_, err := NewRefundService(repo, ledger).RefundPayment(uuid.New().String(), payment.ID.String(), "10", "")
+	assert.ErrorIs(t, err, ErrAccountUnavailable)
+	repo.AssertNotCalled(t, "ReserveRefund", mock.Anything)
+}
+
+func TestRefundAnyPayment(t *testing.T) {
+	payment := completedPayment(100, 0)
+	reversal := uuid.New()
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	repo.On("ReserveRefund", mock.AnythingOfType("*model.Refund")).Return(true, nil)
+	ledger.On("ReverseLedgerEntry", mock.Anything, payment.ToAccountID.String(), payment.FromAccountID.String(), "100", mock.Anything).Return(&reversal, nil)
+	repo.On("CompleteRefund", mock.AnythingOfType("*model.Refund")).Return(nil)
+
+	refund, err := NewRefundService(repo, ledger).RefundAnyPayment(uuid.New().String(), payment.ID.String(), "", "merchant closed")
+	require.NoError(t, err)
+	assert.Equal(t, model.RefundCompleted, refund.Status)
+	ledger.AssertNotCalled(t, "AccountOwner", mock.Anything)
+}
+
+func TestListRefunds_OnlyPayerAndPayee(t *testing.T) {
+	payer := uuid.New()
+	payment := completedPayment(100, 0)
+	payment.UserID = &payer
+	refunds := []model.Refund{{PaymentID: payment.ID, Amount: decimal.NewFromInt(10)}}
+
+	repo := new(MockRefundRepository)
+	ledger := new(MockLedgerReverser)
+	repo.On("GetPayment", payment.ID.String()).Return(payment, nil)
+	repo.On("ListByPayment", payment.ID.String()).Return(refunds, nil)
+	payee := payeeOwner(ledger, payment, uuid.New().String())
+	svc := NewRefundService(repo, ledger)
+
+	for _, userID := range []string{payer.String(), payee} {
+		got, err := svc.ListRefunds(userID, payment.ID.String())
+		require.NoError(t, err)
+		assert.Equal(t, refunds, got)
+	}
+
+	_, err := svc.ListRefunds(uuid.New().String(), payment.ID.String())
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+
+	got, err := svc.ListAnyRefunds(payment.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, refunds, got)
+}
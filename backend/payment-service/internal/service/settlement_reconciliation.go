@@ -0,0 +1,655 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/iso20022"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultSettlementListLimit caps how many reports or exceptions the ops API lists at once
+	DefaultSettlementListLimit = 100
+	maxSettlementListLimit     = 500
+	// MaxSettlementReportBytes is the largest settlement report accepted
+	MaxSettlementReportBytes = 32 << 20
+)
+
+// Directions of the payments in a settlement report
+const (
+	SettlementDebit  = "DEBIT"
+	SettlementCredit = "CREDIT"
+)
+
+// Statuses a settlement report gives its payments
+const (
+	reportedSettled  = "SETTLED"
+	reportedRejected = "REJECTED"
+)
+
+// settlementCSVColumns are the columns of a CSV settlement report. The
+// end_to_end_id and value_date columns may be left empty.
+var settlementCSVColumns = []string{"external_id", "end_to_end_id", "direction", "amount", "currency", "status", "value_date"}
+
+var (
+	ErrInvalidSettlementReport     = errors.New("invalid settlement report")
+	ErrSettlementReportExists      = errors.New("the connector's report for that day was already ingested")
+	ErrSettlementReportNotFound    = errors.New("settlement report not found")
+	ErrSettlementReportUnavailable = errors.New("settlement report is not available")
+	ErrSettlementExceptionNotFound = errors.New("settlement exception not found")
+	ErrSettlementExceptionResolved = errors.New("settlement exception is already resolved")
+	ErrInvalidSettlementException  = errors.New("invalid settlement exception request")
+)
+
+// SettlementRepository stores settlement reports and their exceptions, and
+// looks up the payments they are reconciled against
+type SettlementRepository interface {
+	CreateReport(report *model.SettlementReport, exceptions []model.SettlementException) error
+	GetReport(id string) (*model.SettlementReport, error)
+	ListReports(connector string, limit int) ([]model.SettlementReport, error)
+	ListExceptions(status model.SettlementExceptionStatus, exceptionType model.SettlementExceptionType, reportID string, limit int) ([]model.SettlementException, error)
+	GetException(id string) (*model.SettlementException, error)
+	UpdateExceptionIfStatus(e *model.SettlementException, expected model.SettlementExceptionStatus) (bool, error)
+	ListTransfersByExternalIDs(connector string, externalIDs []string) ([]model.ExternalTransfer, error)
+	ListTransfersByIDs(connector string, ids []uuid.UUID) ([]model.ExternalTransfer, error)
+	// ListSettledTransfers returns the connector's transfers settled in [from, to)
+	ListSettledTransfers(connector string, from, to time.Time) ([]model.ExternalTransfer, error)
+	ListCreditsByExternalIDs(connector string, externalIDs []string) ([]model.IncomingCredit, error)
+	// ListCreditsReceived returns the connector's incoming credits received in [from, to)
+	ListCreditsReceived(connector string, from, to time.Time) ([]model.IncomingCredit, error)
+}
+
+// SettlementReportSource fetches the report a connector's rail published for
+// a day. It returns ErrSettlementReportUnavailable until the report is there.
+type SettlementReportSource interface {
+	FetchReport(ctx context.Context, connector string, day time.Time) ([]byte, model.SettlementReportFormat, error)
+}
+
+// SettlementReconciliationService reconciles the end-of-day settlement
+// reports of the external rails against our external transfers and incoming
+// credits. Every difference is queued as an exception for ops to investigate
+// and resolve; the payments themselves are never changed here.
+type SettlementReconciliationService struct {
+	Repo       SettlementRepository
+	Connectors *connectors.Registry
+	// Source supplies the reports the end-of-day job reconciles; without one
+	// reports are only ingested when ops upload them
+	Source SettlementReportSource
+	now    func() time.Time
+}
+
+func NewSettlementReconciliationService(repo SettlementRepository, registry *connectors.Registry, source SettlementReportSource) *SettlementReconciliationService {
+	return &SettlementReconciliationService{Repo: repo, Connectors: registry, Source: source, now: time.Now}
+}
+
+// settlementEntry is one payment in a settlement report, whatever its format
+type settlementEntry struct {
+	// ExternalID is the rail's reference, the external ID of our record
+	ExternalID string
+	// EndToEndID is the ID the payment was instructed with; for our
+	// transfers it is the transfer ID
+	EndToEndID string
+	Direction  string
+	Amount     decimal.Decimal
+	Currency   string
+	Status     string
+}
+
+// UploadReport reconciles a report uploaded by ops for a connector and day,
+// given as YYYY-MM-DD
+func (s *SettlementReconciliationService) UploadReport(adminID, connector, date string, format model.SettlementReportFormat, data []byte) (*model.SettlementReport, error) {
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	day, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: date must be YYYY-MM-DD", ErrInvalidSettlementReport)
+	}
+	return s.IngestReport(connector, day, format, data, &adminUUID)
+}
+
+// IngestReport parses a connector's report for a day, reconciles it and saves
+// it with its exceptions. Each connector's day is reconciled once; a second
+// report for it returns ErrSettlementReportExists.
+func (s *SettlementReconciliationService) IngestReport(connector string, day time.Time, format model.SettlementReportFormat, data []byte, uploadedBy *uuid.UUID) (*model.SettlementReport, error) {
+	if _, ok := s.Connectors.Get(connector); !ok {
+		return nil, ErrConnectorNotFound
+	}
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if day.AddDate(0, 0, 1).After(s.now()) {
+		return nil, fmt.Errorf("%w: reports can only be reconciled for days that have ended", ErrInvalidSettlementReport)
+	}
+	if len(data) > MaxSettlementReportBytes {
+		return nil, fmt.Errorf("%w: reports must be at most %dMB", ErrInvalidSettlementReport, MaxSettlementReportBytes>>20)
+	}
+
+	var entries []settlementEntry
+	var err error
+	switch format {
+	case model.SettlementReportCSV:
+		entries, err = parseSettlementCSV(data)
+	case model.SettlementReportCamt053:
+		entries, err = parseSettlementCamt053(data)
+	default:
+		return nil, fmt.Errorf("%w: format must be CSV or CAMT053", ErrInvalidSettlementReport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matched, exceptions, err := s.reconcile(connector, day, entries)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	report := &model.SettlementReport{
+		Connector:      connector,
+		ReportDate:     day,
+		Format:         format,
+		FileSHA256:     hex.EncodeToString(sum[:]),
+		EntryCount:     len(entries),
+		MatchedCount:   matched,
+		ExceptionCount: len(exceptions),
+		UploadedBy:     uploadedBy,
+	}
+	if err := s.Repo.CreateReport(report, exceptions); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrSettlementReportExists
+		}
+		return nil, err
+	}
+	slog.Info("Reconciled settlement report", "connector", connector, "date", day.Format(time.DateOnly),
+		"entries", report.EntryCount, "matched", report.MatchedCount, "exceptions", report.ExceptionCount)
+	return report, nil
+}
+
+// reconcile matches report entries against our records of the connector's
+// payments and returns how many matched and the exceptions for the rest. A
+// settled transfer or received credit the report does not list is missing
+// from it; one that crossed midnight on its way may show up in the next
+// day's report, and ops accept the exception.
+func (s *SettlementReconciliationService) reconcile(connector string, day time.Time, entries []settlementEntry) (int, []model.SettlementException, error) {
+	var debitRefs, creditRefs []string
+	var debitIDs []uuid.UUID
+	for _, e := range entries {
+		if e.Direction == SettlementCredit {
+			creditRefs = appendNonEmpty(creditRefs, e.ExternalID, e.EndToEndID)
+			continue
+		}
+		debitRefs = appendNonEmpty(debitRefs, e.ExternalID)
+		if id, err := uuid.Parse(e.EndToEndID); err == nil {
+			debitIDs = append(debitIDs, id)
+		}
+	}
+
+	byRef := map[string]*model.ExternalTransfer{}
+	byID := map[uuid.UUID]*model.ExternalTransfer{}
+	transfers, err := s.Repo.ListTransfersByExternalIDs(connector, debitRefs)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := range transfers {
+		byRef[*transfers[i].ExternalID] = &transfers[i]
+	}
+	transfers, err = s.Repo.ListTransfersByIDs(connector, debitIDs)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := range transfers {
+		byID[transfers[i].ID] = &transfers[i]
+	}
+	credits := map[string]*model.IncomingCredit{}
+	found, err := s.Repo.ListCreditsByExternalIDs(connector, creditRefs)
+	if err != nil {
+		return 0, nil, err
+	}
+	for i := range found {
+		credits[found[i].ExternalID] = &found[i]
+	}
+
+	matched := 0
+	var exceptions []model.SettlementException
+	seen := map[string]bool{}
+	reportedTransfers := map[uuid.UUID]bool{}
+	reportedCredits := map[uuid.UUID]bool{}
+	for _, e := range entries {
+		exception := model.SettlementException{
+			Connector:        connector,
+			Direction:        e.Direction,
+			ExternalID:       e.ExternalID,
+			ReportedAmount:   &e.Amount,
+			ReportedCurrency: e.Currency,
+			ReportedStatus:   e.Status,
+			Status:           model.SettlementExceptionOpen,
+		}
+
+		var key, internalStatus string
+		var internalAmount decimal.Decimal
+		var internalCurrency string
+		if e.Direction == SettlementCredit {
+			credit := credits[e.ExternalID]
+			if credit == nil {
+				credit = credits[e.EndToEndID]
+			}
+			if credit == nil {
+				exception.Type, exception.Details = model.SettlementUnknownEntry, "the rail reports a credit we did not receive"
+				exceptions = append(exceptions, exception)
+				continue
+			}
+			reportedCredits[credit.ID] = true
+			exception.IncomingCreditID = &credit.ID
+			key = "credit:" + credit.ID.String()
+			internalAmount, internalCurrency, internalStatus = credit.Amount, credit.Currency, string(credit.Status)
+		} else {
+			transfer := byRef[e.ExternalID]
+			if transfer == nil {
+				if id, err := uuid.Parse(e.EndToEndID); err == nil {
+					transfer = byID[id]
+				}
+			}
+			if transfer == nil {
+				exception.Type, exception.Details = model.SettlementUnknownEntry, "the rail reports a debit we did not send"
+				exceptions = append(exceptions, exception)
+				continue
+			}
+			reportedTransfers[transfer.ID] = true
+			exception.ExternalTransferID = &transfer.ID
+			// A transfer that settled and was returned is reported twice, once per status
+			key = "transfer:" + transfer.ID.String() + ":" + e.Status
+			internalAmount, internalCurrency, internalStatus = transfer.Amount, transfer.Currency, string(transfer.Status)
+		}
+		exception.InternalAmount = &internalAmount
+		exception.InternalCurrency = internalCurrency
+		exception.InternalStatus = internalStatus
+
+		switch {
+		case seen[key]:
+			exception.Type, exception.Details = model.SettlementDuplicateEntry, "the payment is reported more than once"
+		case !e.Amount.Equal(internalAmount) || e.Currency != internalCurrency:
+			exception.Type = model.SettlementAmountMismatch
+			exception.Details = fmt.Sprintf("reported %s %s, recorded %s %s", e.Amount, e.Currency, internalAmount, internalCurrency)
+		default:
+			if details := statusMismatch(e, internalStatus); details != "" {
+				exception.Type, exception.Details = model.SettlementStatusMismatch, details
+			}
+		}
+		seen[key] = true
+		if exception.Type == "" {
+			matched++
+			continue
+		}
+		exceptions = append(exceptions, exception)
+	}
+
+	from, to := day, day.AddDate(0, 0, 1)
+	settled, err := s.Repo.ListSettledTransfers(connector, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, t := range settled {
+		if reportedTransfers[t.ID] {
+			continue
+		}
+		exceptions = append(exceptions, model.SettlementException{
+			Connector:          connector,
+			Type:               model.SettlementMissingFromReport,
+			Direction:          SettlementDebit,
+			ExternalID:         derefString(t.ExternalID),
+			ExternalTransferID: &t.ID,
+			InternalAmount:     &t.Amount,
+			InternalCurrency:   t.Currency,
+			InternalStatus:     string(t.Status),
+			Details:            "the transfer settled but the rail does not report it",
+			Status:             model.SettlementExceptionOpen,
+		})
+	}
+	received, err := s.Repo.ListCreditsReceived(connector, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, c := range received {
+		if reportedCredits[c.ID] {
+			continue
+		}
+		exceptions = append(exceptions, model.SettlementException{
+			Connector:        connector,
+			Type:             model.SettlementMissingFromReport,
+			Direction:        SettlementCredit,
+			ExternalID:       c.ExternalID,
+			IncomingCreditID: &c.ID,
+			InternalAmount:   &c.Amount,
+			InternalCurrency: c.Currency,
+			InternalStatus:   string(c.Status),
+			Details:          "the credit was received but the rail does not report it",
+			Status:           model.SettlementExceptionOpen,
+		})
+	}
+	return matched, exceptions, nil
+}
+
+// statusMismatch describes how a reported payment's status disagrees with
+// ours, or returns "" when they agree
+func statusMismatch(e settlementEntry, internal string) string {
+	if e.Direction == SettlementCredit {
+		switch {
+		case e.Status == reportedRejected:
+			return "the rail returned a credit we received"
+		case internal == string(model.IncomingCreditReceived):
+			return "the credit settled but was never posted"
+		}
+		return ""
+	}
+	switch model.ExternalTransferStatus(internal) {
+	case model.ExternalTransferSettled:
+		if e.Status == reportedRejected {
+			return "the rail returned a transfer we recorded as settled"
+		}
+	case model.ExternalTransferRejected, model.ExternalTransferFailed:
+		if e.Status == reportedSettled {
+			return "the rail settled a transfer we refunded"
+		}
+	default:
+		return fmt.Sprintf("the rail reports the transfer %s while it is %s", strings.ToLower(e.Status), internal)
+	}
+	return ""
+}
+
+// parseSettlementCSV reads a CSV report with a header naming the
+// settlementCSVColumns, in any order
+func parseSettlementCSV(data []byte) ([]settlementEntry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: a header row is required", ErrInvalidSettlementReport)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range settlementCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %s", ErrInvalidSettlementReport, name)
+		}
+	}
+
+	var entries []settlementEntry
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidSettlementReport, line, err)
+		}
+		field := func(name string) string { return strings.TrimSpace(record[columns[name]]) }
+		entry := settlementEntry{
+			ExternalID: field("external_id"),
+			EndToEndID: field("end_to_end_id"),
+			Direction:  strings.ToUpper(field("direction")),
+			Currency:   strings.ToUpper(field("currency")),
+			Status:     strings.ToUpper(field("status")),
+		}
+		entry.Amount, err = decimal.NewFromString(field("amount"))
+		if err != nil || !entry.Amount.IsPositive() {
+			return nil, fmt.Errorf("%w: line %d: amount must be greater than zero", ErrInvalidSettlementReport, line)
+		}
+		if err := validateSettlementEntry(entry); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidSettlementReport, line, err)
+		}
+		if date := field("value_date"); date != "" {
+			if _, err := time.Parse(time.DateOnly, date); err != nil {
+				return nil, fmt.Errorf("%w: line %d: value_date must be YYYY-MM-DD", ErrInvalidSettlementReport, line)
+			}
+		}
+		entries = append(entries, entry)
+		if len(entries) > iso20022.MaxStatementEntries {
+			return nil, fmt.Errorf("%w: at most %d entries are accepted", ErrInvalidSettlementReport, iso20022.MaxStatementEntries)
+		}
+	}
+	return entries, nil
+}
+
+func validateSettlementEntry(e settlementEntry) error {
+	switch {
+	case e.ExternalID == "" && e.EndToEndID == "":
+		return errors.New("external_id or end_to_end_id is required")
+	case len(e.ExternalID) > 100 || len(e.EndToEndID) > 100:
+		return errors.New("IDs must be at most 100 characters")
+	case e.Direction != SettlementDebit && e.Direction != SettlementCredit:
+		return errors.New("direction must be DEBIT or CREDIT")
+	case !isoCurrencyPattern.MatchString(e.Currency):
+		return errors.New("currency must be a 3-letter ISO code")
+	case e.Status != reportedSettled && e.Status != reportedRejected:
+		return errors.New("status must be SETTLED or REJECTED")
+	}
+	return nil
+}
+
+// parseSettlementCamt053 reads the booked entries of a camt.053 statement.
+// A reversal is a return of the payment it reverses, which ran the opposite
+// way to the reversal's own entry.
+func parseSettlementCamt053(data []byte) ([]settlementEntry, error) {
+	statement, err := iso20022.ParseCamt053(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSettlementReport, err)
+	}
+	var entries []settlementEntry
+	for _, e := range statement.Entries {
+		if e.Status != "BOOK" {
+			continue
+		}
+		entry := settlementEntry{
+			ExternalID: e.AccountServicerRef,
+			EndToEndID: e.EndToEndID,
+			Direction:  SettlementDebit,
+			Amount:     e.Amount,
+			Currency:   e.Currency,
+			Status:     reportedSettled,
+		}
+		if e.Credit != e.Reversal {
+			entry.Direction = SettlementCredit
+		}
+		if e.Reversal {
+			entry.Status = reportedRejected
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ListReports returns the reports of a connector, or of all connectors,
+// latest day first
+func (s *SettlementReconciliationService) ListReports(connector string, limit int) ([]model.SettlementReport, error) {
+	return s.Repo.ListReports(connector, settlementListLimit(limit))
+}
+
+// GetReport returns one settlement report
+func (s *SettlementReconciliationService) GetReport(id string) (*model.SettlementReport, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrSettlementReportNotFound
+	}
+	report, err := s.Repo.GetReport(id)
+	if err != nil {
+		return nil, ErrSettlementReportNotFound
+	}
+	return report, nil
+}
+
+// ListExceptions returns exceptions, oldest first, filtered by whichever of
+// status, type and report ID are set; ?status=OPEN is the queue ops work through
+func (s *SettlementReconciliationService) ListExceptions(status model.SettlementExceptionStatus, exceptionType model.SettlementExceptionType, reportID string, limit int) ([]model.SettlementException, error) {
+	switch status {
+	case "", model.SettlementExceptionOpen, model.SettlementExceptionResolved:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidSettlementException, status)
+	}
+	switch exceptionType {
+	case "", model.SettlementUnknownEntry, model.SettlementAmountMismatch, model.SettlementStatusMismatch,
+		model.SettlementMissingFromReport, model.SettlementDuplicateEntry:
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSettlementException, exceptionType)
+	}
+	if reportID != "" {
+		if _, err := uuid.Parse(reportID); err != nil {
+			return nil, fmt.Errorf("%w: report_id must be a UUID", ErrInvalidSettlementException)
+		}
+	}
+	return s.Repo.ListExceptions(status, exceptionType, reportID, settlementListLimit(limit))
+}
+
+// GetException returns one settlement exception
+func (s *SettlementReconciliationService) GetException(id string) (*model.SettlementException, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrSettlementExceptionNotFound
+	}
+	exception, err := s.Repo.GetException(id)
+	if err != nil {
+		return nil, ErrSettlementExceptionNotFound
+	}
+	return exception, nil
+}
+
+// ResolveException closes an open exception and records who resolved it,
+// how and why. Corrections themselves, such as reposting a credit or
+// refunding a returned transfer, are made through their own APIs.
+func (s *SettlementReconciliationService) ResolveException(adminID, id string, resolution model.SettlementResolution, note string) (*model.SettlementException, error) {
+	adminUUID, err := uuid.Parse(adminID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	switch resolution {
+	case model.SettlementResolvedCorrected, model.SettlementResolvedAccepted, model.SettlementResolvedRailError:
+	default:
+		return nil, fmt.Errorf("%w: resolution must be CORRECTED, ACCEPTED or RAIL_ERROR", ErrInvalidSettlementException)
+	}
+	if strings.TrimSpace(note) == "" {
+		return nil, fmt.Errorf("%w: a note explaining the resolution is required", ErrInvalidSettlementException)
+	}
+	exception, err := s.GetException(id)
+	if err != nil {
+		return nil, err
+	}
+	if exception.Status != model.SettlementExceptionOpen {
+		return nil, ErrSettlementExceptionResolved
+	}
+
+	now := s.now()
+	exception.Status = model.SettlementExceptionResolved
+	exception.Resolution = resolution
+	exception.ResolutionNote = note
+	exception.ResolvedBy = &adminUUID
+	exception.ResolvedAt = &now
+	claimed, err := s.Repo.UpdateExceptionIfStatus(exception, model.SettlementExceptionOpen)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrSettlementExceptionResolved
+	}
+	slog.Info("Resolved settlement exception", "exception_id", exception.ID, "resolution", resolution, "resolved_by", adminID)
+	return exception, nil
+}
+
+// ReconciliationJob reconciles every connector's report for the UTC day
+// before the run was scheduled. Reports already reconciled are skipped, so
+// when a report is not available yet the job fails and its retries pick up
+// only the connectors still outstanding.
+func (s *SettlementReconciliationService) ReconciliationJob(ctx context.Context, job *jobs.Job) error {
+	if s.Source == nil {
+		return nil
+	}
+	runAt := job.RunAt.UTC()
+	day := time.Date(runAt.Year(), runAt.Month(), runAt.Day()-1, 0, 0, 0, 0, time.UTC)
+
+	var errs []error
+	for _, connector := range s.Connectors.Names() {
+		data, format, err := s.Source.FetchReport(ctx, connector, day)
+		if err == nil {
+			_, err = s.IngestReport(connector, day, format, data, nil)
+		}
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrSettlementReportExists):
+			slog.Info("Settlement report already reconciled", "connector", connector, "date", day.Format(time.DateOnly))
+		default:
+			errs = append(errs, fmt.Errorf("%s: %w", connector, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DirectorySettlementSource reads reports the rails deliver to a directory,
+// e.g. by SFTP, as <dir>/<YYYY-MM-DD>/<connector>.csv or <connector>.xml for
+// camt.053
+type DirectorySettlementSource struct {
+	Dir string
+}
+
+func (d DirectorySettlementSource) FetchReport(_ context.Context, connector string, day time.Time) ([]byte, model.SettlementReportFormat, error) {
+	formats := []struct {
+		ext    string
+		format model.SettlementReportFormat
+	}{{".csv", model.SettlementReportCSV}, {".xml", model.SettlementReportCamt053}}
+	for _, f := range formats {
+		path := filepath.Join(d.Dir, day.Format(time.DateOnly), connector+f.ext)
+		info, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if info.Size() > MaxSettlementReportBytes {
+			return nil, "", fmt.Errorf("%w: %s is larger than %dMB", ErrInvalidSettlementReport, path, MaxSettlementReportBytes>>20)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, f.format, nil
+	}
+	return nil, "", ErrSettlementReportUnavailable
+}
+
+func settlementListLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultSettlementListLimit
+	}
+	return min(limit, maxSettlementListLimit)
+}
+
+// appendNonEmpty appends the values that are set
+func appendNonEmpty(list []string, values ...string) []string {
+	for _, v := range values {
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/connectors"
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memorySettlementRepository keeps reports, exceptions and the payments they
+// are reconciled against in memory
+type memorySettlementRepository struct {
+	reports    []model.SettlementReport
+	exceptions []model.SettlementException
+	transfers  []model.ExternalTransfer
+	credits    []model.IncomingCredit
+}
+
+func (r *memorySettlementRepository) CreateReport(report *model.SettlementReport, exceptions []model.SettlementException) error {
+	for _, existing := range r.reports {
+		if existing.Connector == report.Connector && existing.ReportDate.Equal(report.ReportDate) {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	report.ID = uuid.New()
+	r.reports = append(r.reports, *report)
+	for _, e := range exceptions {
+		e.ID, e.ReportID = uuid.New(), report.ID
+		r.exceptions = append(r.exceptions, e)
+	}
+	return nil
+}
+
+func (r *memorySettlementRepository) GetReport(id string) (*model.SettlementReport, error) {
+	for _, report := range r.reports {
+		if report.ID.String() == id {
+			return &report, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memorySettlementRepository) ListReports(connector string, limit int) ([]model.SettlementReport, error) {
+	var reports []model.SettlementReport
+	for _, report := range r.reports {
+		if connector == "" || report.Connector == connector {
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+func (r *memorySettlementRepository) ListExceptions(status model.SettlementExceptionStatus, exceptionType model.SettlementExceptionType, reportID string, limit int) ([]model.SettlementException, error) {
+	var exceptions []model.SettlementException
+	for _, e := range r.exceptions {
+		if (status == "" || e.Status == status) && (exceptionType == "" || e.Type == exceptionType) &&
+			(reportID == "" || e.ReportID.String() == reportID) {
+			exceptions = append(exceptions, e)
+		}
+	}
+	return exceptions, nil
+}
+
+func (r *memorySettlementRepository) GetException(id string) (*model.SettlementException, error) {
+	for _, e := range r.exceptions {
+		if e.ID.String() == id {
+			return &e, nil
+		}
+	}
+	return nil, errors.New("record not found")
+}
+
+func (r *memorySettlementRepository) UpdateExceptionIfStatus(e *model.SettlementException, expected model.SettlementExceptionStatus) (bool, error) {
+	for i, existing := range r.exceptions {
+		if existing.ID == e.ID {
+			if existing.Status != expected {
+				return false, nil
+			}
+			r.exceptions[i] = *e
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memorySettlementRepository) ListTransfersByExternalIDs(connector string, externalIDs []string) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	for _, t := range r.transfers {
+		if t.Connector == connector && t.ExternalID != nil && slices.Contains(externalIDs, *t.ExternalID) {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers, nil
+}
+
+func (r *memorySettlementRepository) ListTransfersByIDs(connector string, ids []uuid.UUID) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	for _, t := range r.transfers {
+		if t.Connector == connector && slices.Contains(ids, t.ID) {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers, nil
+}
+
+func (r *memorySettlementRepository) ListSettledTransfers(connector string, from, to time.Time) ([]model.ExternalTransfer, error) {
+	var transfers []model.ExternalTransfer
+	for _, t := range r.transfers {
+		if t.Connector == connector && t.Status == model.ExternalTransferSettled &&
+			!t.SettledAt.Before(from) && t.SettledAt.Before(to) {
+			transfers = append(transfers, t)
+		}
+	}
+	return transfers, nil
+}
+
+func (r *memorySettlementRepository) ListCreditsByExternalIDs(connector string, externalIDs []string) ([]model.IncomingCredit, error) {
+	var credits []model.IncomingCredit
+	for _, c := range r.credits {
+		if c.Connector == connector && slices.Contains(externalIDs, c.ExternalID) {
+			credits = append(credits, c)
+		}
+	}
+	return credits, nil
+}
+
+func (r *memorySettlementRepository) ListCreditsReceived(connector string, from, to time.Time) ([]model.IncomingCredit, error) {
+	var credits []model.IncomingCredit
+	for _, c := range r.credits {
+		if c.Connector == connector && !c.CreatedAt.Before(from) && c.CreatedAt.Before(to) {
+			credits = append(credits, c)
+		}
+	}
+	return credits, nil
+}
+
+// settlementDay is the day the test reports cover
+var settlementDay = time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC)
+
+func newSettlementService() (*SettlementReconciliationService, *memorySettlementRepository) {
+	repo := &memorySettlementRepository{}
+	svc := NewSettlementReconciliationService(repo, connectors.NewRegistry(connectors.NewMockConnector("whsec")), nil)
+	svc.now = func() time.Time { return settlementDay.Add(26 * time.Hour) }
+	return svc, repo
+}
+
+func (r *memorySettlementRepository) addTransfer(externalID, amount string, status model.ExternalTransferStatus, settledAt time.Time) model.ExternalTransfer {
+	t := model.ExternalTransfer{
+		ID:        uuid.New(),
+		Connector: connectors.MockConnectorName,
+		Amount:    decimal.RequireFromString(amount),
+		Currency:  "GBP",
+		Status:    status,
+	}
+	if externalID != "" {
+		t.ExternalID = &externalID
+	}
+	if status == model.ExternalTransferSettled {
+		t.SettledAt = &settledAt
+	}
+	r.transfers = append(r.transfers, t)
+	return t
+}
+
+func (r *memorySettlementRepository) addCredit(externalID, amount string, status model.IncomingCreditStatus, receivedAt time.Time) model.IncomingCredit {
+	c := model.IncomingCredit{
+		ID:         uuid.New(),
+		Connector:  connectors.MockConnectorName,
+		ExternalID: externalID,
+		Amount:     decimal.RequireFromString(amount),
+		Currency:   "GBP",
+		Status:     status,
+		CreatedAt:  receivedAt,
+	}
+	r.credits = append(r.credits, c)
+	return c
+}
+
+func exceptionsByType(exceptions []model.SettlementException) map[model.SettlementExceptionType][]model.SettlementException {
+	byType := map[model.SettlementExceptionType][]model.SettlementException{}
+	for _, e := range exceptions {
+		byType[e.Type] = append(byType[e.Type], e)
+	}
+	return byType
+}
+
+func TestSettlementReconciliation_CSVReport(t *testing.T) {
+	svc, repo := newSettlementService()
+	noon := settlementDay.Add(12 * time.Hour)
+	settled := repo.addTransfer("RAIL-1", "100.00", model.ExternalTransferSettled, noon)
+	byEndToEnd := repo.addTransfer("", "25.00", model.ExternalTransferSettled, noon)
+	wrongAmount := repo.addTransfer("RAIL-3", "60.00", model.ExternalTransferSettled, noon)
+	refunded := repo.addTransfer("RAIL-4", "15.00", model.ExternalTransferRejected, noon)
+	missing := repo.addTransfer("RAIL-5", "80.00", model.ExternalTransferSettled, noon)
+	repo.addTransfer("RAIL-6", "90.00", model.ExternalTransferSettled, noon.Add(24*time.Hour))
+	repo.addCredit("IN-1", "300.00", model.IncomingCreditPosted, noon)
+	unposted := repo.addCredit("IN-2", "10.00", model.IncomingCreditReceived, noon)
+	missingCredit := repo.addCredit("IN-3", "45.00", model.IncomingCreditSuspense, noon)
+
+	report := "external_id,end_to_end_id,direction,amount,currency,status,value_date\n" +
+		"RAIL-1,,DEBIT,100.00,GBP,SETTLED,2026-10-15\n" +
+		"," + byEndToEnd.ID.String() + ",DEBIT,25,GBP,SETTLED,\n" +
+		"RAIL-3,,DEBIT,65.00,GBP,SETTLED,2026-10-15\n" +
+		"RAIL-4,,DEBIT,15.00,GBP,SETTLED,2026-10-15\n" +
+		"RAIL-1,,DEBIT,100.00,GBP,SETTLED,2026-10-15\n" +
+		"RAIL-X,,DEBIT,5.00,GBP,SETTLED,2026-10-15\n" +
+		"IN-1,,CREDIT,300.00,gbp,settled,2026-10-15\n" +
+		"IN-2,,CREDIT,10.00,GBP,SETTLED,2026-10-15\n" +
+		"IN-X,,CREDIT,7.00,GBP,SETTLED,2026-10-15\n"
+	result, err := svc.UploadReport(uuid.NewString(), connectors.MockConnectorName, "2026-10-15", model.SettlementReportCSV, []byte(report))
+	require.NoError(t, err)
+	assert.Equal(t, 9, result.EntryCount)
+	assert.Equal(t, 3, result.MatchedCount, "RAIL-1, the transfer matched by end-to-end ID and IN-1")
+	assert.Equal(t, 8, result.ExceptionCount)
+	assert.NotNil(t, result.UploadedBy)
+	assert.Len(t, result.FileSHA256, 64)
+
+	byType := exceptionsByType(repo.exceptions)
+	require.Len(t, byType[model.SettlementAmountMismatch], 1)
+	assert.Equal(t, wrongAmount.ID, *byType[model.SettlementAmountMismatch][0].ExternalTransferID)
+	require.Len(t, byType[model.SettlementStatusMismatch], 2)
+	assert.Equal(t, refunded.ID, *byType[model.SettlementStatusMismatch][0].ExternalTransferID)
+	assert.Equal(t, unposted.ID, *byType[model.SettlementStatusMismatch][1].IncomingCreditID)
+	require.Len(t, byType[model.SettlementDuplicateEntry], 1)
+	assert.Equal(t, settled.ID, *byType[model.SettlementDuplicateEntry][0].ExternalTransferID)
+	require.Len(t, byType[model.SettlementUnknownEntry], 2)
+	assert.Equal(t, "RAIL-X", byType[model.SettlementUnknownEntry][0].ExternalID)
+	assert.Equal(t, SettlementCredit, byType[model.SettlementUnknownEntry][1].Direction)
+	require.Len(t, byType[model.SettlementMissingFromReport], 2, "the transfer settled the next day is not missing")
+	assert.Equal(t, missing.ID, *byType[model.SettlementMissingFromReport][0].ExternalTransferID)
+	assert.Equal(t, missingCredit.ID, *byType[model.SettlementMissingFromReport][1].IncomingCreditID)
+	for _, e := range repo.exceptions {
+		assert.Equal(t, result.ID, e.ReportID)
+		assert.Equal(t, model.SettlementExceptionOpen, e.Status)
+	}
+
+	_, err = svc.UploadReport(uuid.NewString(), connectors.MockConnectorName, "2026-10-15", model.SettlementReportCSV, []byte(report))
+	assert.ErrorIs(t, err, ErrSettlementReportExists)
+}
+
+func TestSettlementReconciliation_Camt053Report(t *testing.T) {
+	svc, repo := newSettlementService()
+	noon := settlementDay.Add(12 * time.Hour)
+	transfer := repo.addTransfer("", "120.50", model.ExternalTransferSettled, noon)
+	repo.addTransfer("RAIL-2", "40.00", model.ExternalTransferRejected, noon)
+
+	statement := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>STMT-2026-10-15</MsgId><CreDtTm>2026-10-15T23:59:00Z</CreDtTm></GrpHdr>
+    <Stmt>
+      <Ntry>
+        <Amt Ccy="GBP">120.50</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+        <NtryDtls><TxDtls><Refs><EndToEndId>` + transfer.ID.String() + `</EndToEndId></Refs></TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="GBP">40.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><RvslInd>true</RvslInd><Sts><Cd>BOOK</Cd></Sts>
+        <AcctSvcrRef>RAIL-2</AcctSvcrRef>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="GBP">99.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>PDNG</Cd></Sts>
+        <AcctSvcrRef>IN-PENDING</AcctSvcrRef>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+	result, err := svc.IngestReport(connectors.MockConnectorName, settlementDay, model.SettlementReportCamt053, []byte(statement), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.EntryCount, "pending entries are not reconciled")
+	assert.Equal(t, 2, result.MatchedCount, "a reversal of a debit matches the refunded transfer")
+	assert.Zero(t, result.ExceptionCount)
+	assert.Nil(t, result.UploadedBy)
+}
+
+func TestSettlementReconciliation_Validation(t *testing.T) {
+	svc, _ := newSettlementService()
+	admin := uuid.NewString()
+	valid := []byte("external_id,end_to_end_id,direction,amount,currency,status,value_date\nRAIL-1,,DEBIT,1,GBP,SETTLED,\n")
+
+	_, err := svc.UploadReport(admin, "unknown", "2026-10-15", model.SettlementReportCSV, valid)
+	assert.ErrorIs(t, err, ErrConnectorNotFound)
+	_, err = svc.UploadReport(admin, connectors.MockConnectorName, "15/10/2026", model.SettlementReportCSV, valid)
+	assert.ErrorIs(t, err, ErrInvalidSettlementReport)
+	_, err = svc.UploadReport(admin, connectors.MockConnectorName, "2026-10-16", model.SettlementReportCSV, valid)
+	assert.ErrorIs(t, err, ErrInvalidSettlementReport, "the day has not ended")
+	_, err = svc.UploadReport(admin, connectors.MockConnectorName, "2026-10-15", "PDF", valid)
+	assert.ErrorIs(t, err, ErrInvalidSettlementReport)
+
+	for name, report := range map[string]string{
+		"missing column": "external_id,direction,amount,currency,status\nRAIL-1,DEBIT,1,GBP,SETTLED\n",
+		"direction":      "external_id,end_to_end_id,direction,amount,currency,status,value_date\nRAIL-1,,SIDEWAYS,1,GBP,SETTLED,\n",
+		"amount":         "external_id,end_to_end_id,direction,amount,currency,status,value_date\nRAIL-1,,DEBIT,-1,GBP,SETTLED,\n",
+		"status":         "external_id,end_to_end_id,direction,amount,currency,status,value_date\nRAIL-1,,DEBIT,1,GBP,PENDING,\n",
+		"no reference":   "external_id,end_to_end_id,direction,amount,currency,status,value_date\n,,DEBIT,1,GBP,SETTLED,\n",
+		"value date":     "external_id,end_to_end_id,direction,amount,currency,status,value_date\nRAIL-1,,DEBIT,1,GBP,SETTLED,15/10\n",
+	} {
+		_, err := svc.UploadReport(admin, connectors.MockConnectorName, "2026-10-15", model.SettlementReportCSV, []byte(report))
+		assert.ErrorIs(t, err, ErrInvalidSettlementReport, name)
+	}
+	_, err = svc.UploadReport(admin, connectors.MockConnectorName, "2026-10-15", model.SettlementReportCamt053, []byte("<Document/>"))
+	assert.ErrorIs(t, err, ErrInvalidSettlementReport)
+}
+
+func TestSettlementReconciliation_ResolveException(t *testing.T) {
+	svc, repo := newSettlementService()
+	report := "external_id,end_to_end_id,direction,amount,currency,status,value_date\nRAIL-X,,DEBIT,5.00,GBP,SETTLED,\n"
+	_, err := svc.UploadReport(uuid.NewString(), connectors.MockConnectorName, "2026-10-15", model.SettlementReportCSV, []byte(report))
+	require.NoError(t, err)
+	open, err := svc.ListExceptions(model.SettlementExceptionOpen, "", "", 0)
+	require.NoError(t, err)
+	require.Len(t, open, 1)
+	id := open[0].ID.String()
+
+	admin := uuid.NewString()
+	_, err = svc.ResolveException(admin, id, "IGNORED", "note")
+	assert.ErrorIs(t, err, ErrInvalidSettlementException)
+	_, err = svc.ResolveException(admin, id, model.SettlementResolvedRailError, " ")
+	assert.ErrorIs(t, err, ErrInvalidSettlementException)
+	_, err = svc.ResolveException(admin, uuid.NewString(), model.SettlementResolvedRailError, "note")
+	assert.ErrorIs(t, err, ErrSettlementExceptionNotFound)
+
+	resolved, err := svc.ResolveException(admin, id, model.SettlementResolvedRailError, "Rail confirmed a duplicate line in its report")
+	require.NoError(t, err)
+	assert.Equal(t, model.SettlementExceptionResolved, resolved.Status)
+	assert.Equal(t, admin, resolved.ResolvedBy.String())
+	assert.NotNil(t, resolved.ResolvedAt)
+	assert.Equal(t, model.SettlementExceptionResolved, repo.exceptions[0].Status)
+
+	_, err = svc.ResolveException(admin, id, model.SettlementResolvedAccepted, "again")
+	assert.ErrorIs(t, err, ErrSettlementExceptionResolved)
+	open, err = svc.ListExceptions(model.SettlementExceptionOpen, "", "", 0)
+	require.NoError(t, err)
+	assert.Empty(t, open)
+	_, err = svc.ListExceptions("CLOSED", "", "", 0)
+	assert.ErrorIs(t, err, ErrInvalidSettlementException)
+}
+
+func TestSettlementReconciliation_JobReadsPreviousDayFromDirectory(t *testing.T) {
+	svc, repo := newSettlementService()
+	dir := t.TempDir()
+	svc.Source = DirectorySettlementSource{Dir: dir}
+	job := &jobs.Job{RunAt: settlementDay.Add(26 * time.Hour)}
+
+	err := svc.ReconciliationJob(context.Background(), job)
+	assert.ErrorIs(t, err, ErrSettlementReportUnavailable, "the job retries until the report arrives")
+	assert.Empty(t, repo.reports)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2026-10-15"), 0o755))
+	report := "external_id,end_to_end_id,direction,amount,currency,status,value_date\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2026-10-15", connectors.MockConnectorName+".csv"), []byte(report), 0o644))
+	require.NoError(t, svc.ReconciliationJob(context.Background(), job))
+	require.Len(t, repo.reports, 1)
+	assert.True(t, settlementDay.Equal(repo.reports[0].ReportDate))
+	assert.Nil(t, repo.reports[0].UploadedBy)
+
+	require.NoError(t, svc.ReconciliationJob(context.Background(), job), "a reconciled day is skipped")
+	assert.Len(t, repo.reports, 1)
+}
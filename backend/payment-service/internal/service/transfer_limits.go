@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Names of the limits a transfer can exceed
+const (
+	LimitMaxPerTransaction      = "MAX_PER_TRANSACTION"
+	LimitDailyCount             = "DAILY_COUNT"
+	LimitDailyAmount            = "DAILY_AMOUNT"
+	LimitBeneficiaryDailyAmount = "BENEFICIARY_DAILY_AMOUNT"
+)
+
+// limitCounterTTL keeps a day's counters a little past midnight UTC so a
+// transfer that straddles it can still be released
+const limitCounterTTL = 48 * time.Hour
+
+// amountScale matches numeric(19,4); counters hold amounts in 1/10000 units
+const amountScale = 4
+
+var (
+	ErrTransferLimitExceeded = errors.New("transfer limit exceeded")
+	ErrLimitsUnavailable     = errors.New("transfer limits cannot be verified")
+	ErrLimitsDisabled        = errors.New("transfer limits are not enabled")
+)
+
+// TransferLimits are the velocity limits applied to each user. A zero value
+// disables that limit. Amount limits apply to each currency separately, in
+// units of that currency, since there are no exchange rates to convert with;
+// the daily count covers transfers in every currency.
+type TransferLimits struct {
+	MaxPerTransaction      decimal.Decimal `json:"max_per_transaction"`
+	DailyCount             int64           `json:"daily_count"`
+	DailyAmount            decimal.Decimal `json:"daily_amount"`
+	BeneficiaryDailyAmount decimal.Decimal `json:"beneficiary_daily_amount"`
+}
+
+// DefaultTransferLimits are the limits used when none are configured
+func DefaultTransferLimits() TransferLimits {
+	return TransferLimits{
+		MaxPerTransaction:      decimal.NewFromInt(10000),
+		DailyCount:             50,
+		DailyAmount:            decimal.NewFromInt(25000),
+		BeneficiaryDailyAmount: decimal.NewFromInt(10000),
+	}
+}
+
+// LimitExceededError describes which limit a transfer would exceed and how
+// much of it is left. It matches ErrTransferLimitExceeded with errors.Is.
+type LimitExceededError struct {
+	Limit     string `json:"limit"`
+	Max       string `json:"max"`
+	Used      string `json:"used"`
+	Remaining string `json:"remaining"`
+	Requested string `json:"requested"`
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s: %s limit is %s, %s remaining", ErrTransferLimitExceeded, e.Limit, e.Max, e.Remaining)
+}
+
+func (e *LimitExceededError) Unwrap() error {
+	return ErrTransferLimitExceeded
+}
+
+// LimitKeys identify one user's counters for one day. The amount counters are
+// kept per currency.
+type LimitKeys struct {
+	Count       string
+	Amount      string
+	Beneficiary string
+}
+
+// LimitCounters are the usage recorded against a user's daily limits.
+// Amounts are in 1/10000 currency units.
+type LimitCounters struct {
+	Count       int64
+	Amount      int64
+	Beneficiary int64
+}
+
+// LimitStore keeps the daily counters. Reserve must check and increment
+// atomically so concurrent transfers cannot both squeeze under a limit.
+type LimitStore interface {
+	// Reserve adds one transfer of amount to the counters unless that would
+	// exceed a limit. It returns the counters as they were before and the name
+	// of the exceeded limit, or "" if the transfer was counted.
+	Reserve(ctx context.Context, keys LimitKeys, amount int64, limits TransferLimits, ttl time.Duration) (LimitCounters, string, error)
+	// Release takes back a transfer that was reserved but not made
+	Release(ctx context.Context, keys LimitKeys, amount int64) error
+	// Usage returns the current counters; Beneficiary is only filled in when
+	// keys.Beneficiary is set
+	Usage(ctx context.Context, keys LimitKeys) (LimitCounters, error)
+}
+
+// TransferLimiter enforces per-user velocity limits on transfers
+type TransferLimiter struct {
+	store  LimitStore
+	limits TransferLimits
+	now    func() time.Time
+}
+
+func NewTransferLimiter(store LimitStore, limits TransferLimits) *TransferLimiter {
+	return &TransferLimiter{store: store, limits: limits, now: time.Now}
+}
+
+// LimitReservation is a transfer counted against a user's limits. Release it
+// if the transfer is not made.
+type LimitReservation struct {
+	keys   LimitKeys
+	amount int64
+}
+
+// LimitUsage is a user's current usage of their daily limits in one currency
+type LimitUsage struct {
+	Date                   string          `json:"date"`
+	Currency               string          `json:"currency"`
+	MaxPerTransaction      decimal.Decimal `json:"max_per_transaction"`
+	BeneficiaryDailyAmount decimal.Decimal `json:"beneficiary_daily_amount"`
+	DailyCount             UsageCount      `json:"daily_count"`
+	DailyAmount            UsageAmount     `json:"daily_amount"`
+}
+
+// UsageCount is the use of a count limit; a zero limit is unlimited
+type UsageCount struct {
+	Limit     int64  `json:"limit"`
+	Used      int64  `json:"used"`
+	Remaining *int64 `json:"remaining,omitempty"`
+}
+
+// UsageAmount is the use of an amount limit; a zero limit is unlimited
+type UsageAmount struct {
+	Limit     decimal.Decimal  `json:"limit"`
+	Used      decimal.Decimal  `json:"used"`
+	Remaining *decimal.Decimal `json:"remaining,omitempty"`
+}
+
+// Reserve counts a transfer of amount in currency from userID to beneficiary
+// against the user's limits for today (UTC), or returns a *LimitExceededError
+func (l *TransferLimiter) Reserve(ctx context.Context, userID, beneficiary, currency string, amount decimal.Decimal) (*LimitReservation, error) {
+	if err := l.checkPerTransaction(amount); err != nil {
+		return nil, err
+	}
+
+	keys := l.keys(userID, beneficiary, currency)
+	units := toUnits(amount)
+	before, exceeded, err := l.store.Reserve(ctx, keys, units, l.limits, limitCounterTTL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLimitsUnavailable, err)
+	}
+	if exceeded == "" {
+		return &LimitReservation{keys: keys, amount: units}, nil
+	}
+	return nil, l.exceededError(exceeded, before, amount)
+}
+
+// Check returns the *LimitExceededError Reserve would return for a transfer
+// of amount in currency from userID to beneficiary, without counting it. A
+// transfer made afterwards can still be refused if others are counted first.
+func (l *TransferLimiter) Check(ctx context.Context, userID, beneficiary, currency string, amount decimal.Decimal) error {
+	if err := l.checkPerTransaction(amount); err != nil {
+		return err
+	}
+
+	before, err := l.store.Usage(ctx, l.keys(userID, beneficiary, currency))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrLimitsUnavailable, err)
+	}
+	units := toUnits(amount)
+	switch {
+	case l.limits.DailyCount > 0 && before.Count+1 > l.limits.DailyCount:
+		return l.exceededError(LimitDailyCount, before, amount)
+	case l.limits.DailyAmount.IsPositive() && before.Amount+units > toUnits(l.limits.DailyAmount):
+		return l.exceededError(LimitDailyAmount, before, amount)
+	case l.limits.BeneficiaryDailyAmount.IsPositive() && before.Beneficiary+units > toUnits(l.limits.BeneficiaryDailyAmount):
+		return l.exceededError(LimitBeneficiaryDailyAmount, before, amount)
+	}
+	return nil
+}
+
+func (l *TransferLimiter) checkPerTransaction(amount decimal.Decimal) error {
+	if l.limits.MaxPerTransaction.IsPositive() && amount.GreaterThan(l.limits.MaxPerTransaction) {
+		return &LimitExceededError{
+			Limit:     LimitMaxPerTransaction,
+			Max:       l.limits.MaxPerTransaction.String(),
+			Used:      "0",
+			Remaining: l.limits.MaxPerTransaction.String(),
+			Requested: amount.String(),
+		}
+	}
+	return nil
+}
+
+// exceededError describes the daily limit a transfer of amount exceeds, given
+// the counters before it
+func (l *TransferLimiter) exceededError(exceeded string, before LimitCounters, amount decimal.Decimal) error {
+	switch exceeded {
+	case LimitDailyCount:
+		return &LimitExceededError{
+			Limit:     LimitDailyCount,
+			Max:       fmt.Sprint(l.limits.DailyCount),
+			Used:      fmt.Sprint(before.Count),
+			Remaining: fmt.Sprint(max(l.limits.DailyCount-before.Count, 0)),
+			Requested: "1",
+		}
+	case LimitDailyAmount:
+		return amountExceeded(LimitDailyAmount, l.limits.DailyAmount, before.Amount, amount)
+	case LimitBeneficiaryDailyAmount:
+		return amountExceeded(LimitBeneficiaryDailyAmount, l.limits.BeneficiaryDailyAmount, before.Beneficiary, amount)
+	default:
+		return fmt.Errorf("%w: unknown limit %q", ErrLimitsUnavailable, exceeded)
+	}
+}
+
+// Release takes a reserved transfer back off the user's counters
+func (l *TransferLimiter) Release(ctx context.Context, r *LimitReservation) error {
+	return l.store.Release(ctx, r.keys, r.amount)
+}
+
+// Usage reports how much of today's limits the user has used in currency
+func (l *TransferLimiter) Usage(ctx context.Context, userID, currency string) (*LimitUsage, error) {
+	counters, err := l.store.Usage(ctx, l.keys(userID, "", currency))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLimitsUnavailable, err)
+	}
+
+	usage := &LimitUsage{
+		Date:                   l.now().UTC().Format("2006-01-02"),
+		Currency:               strings.ToUpper(currency),
+		MaxPerTransaction:      l.limits.MaxPerTransaction,
+		BeneficiaryDailyAmount: l.limits.BeneficiaryDailyAmount,
+		DailyCount:             UsageCount{Limit: l.limits.DailyCount, Used: counters.Count},
+		DailyAmount:            UsageAmount{Limit: l.limits.DailyAmount, Used: fromUnits(counters.Amount)},
+	}
+	if l.limits.DailyCount > 0 {
+		remaining := max(l.limits.DailyCount-counters.Count, 0)
+		usage.DailyCount.Remaining = &remaining
+	}
+	if l.limits.DailyAmount.IsPositive() {
+		remaining := decimal.Max(l.limits.DailyAmount.Sub(usage.DailyAmount.Used), decimal.Zero)
+		usage.DailyAmount.Remaining = &remaining
+	}
+	return usage, nil
+}
+
+// keys names the user's counters for today, with the amounts in currency.
+// The beneficiary key is only used when beneficiary is set.
+func (l *TransferLimiter) keys(userID, beneficiary, currency string) LimitKeys {
+	prefix := fmt.Sprintf("transfer_limits:%s:%s", userID, l.now().UTC().Format("2006-01-02"))
+	currency = strings.ToUpper(currency)
+	keys := LimitKeys{Count: prefix + ":count", Amount: prefix + ":amount:" + currency}
+	if beneficiary != "" {
+		keys.Beneficiary = prefix + ":beneficiary:" + beneficiary + ":" + currency
+	}
+	return keys
+}
+
+func amountExceeded(name string, limit decimal.Decimal, usedUnits int64, requested decimal.Decimal) *LimitExceededError {
+	used := fromUnits(usedUnits)
+	return &LimitExceededError{
+		Limit:     name,
+		Max:       limit.String(),
+		Used:      used.String(),
+		Remaining: decimal.Max(limit.Sub(used), decimal.Zero).String(),
+		Requested: requested.String(),
+	}
+}
+
+func toUnits(d decimal.Decimal) int64 {
+	return d.Shift(amountScale).IntPart()
+}
+
+func fromUnits(units int64) decimal.Decimal {
+	return decimal.New(units, -amountScale)
+}
+
+// Evaler runs Lua scripts on a Redis server; *cache.RedisClient implements it
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// reserveScript checks every daily limit before incrementing any counter, so
+// a rejected transfer leaves them untouched. A limit of 0 is unlimited.
+const reserveScript = `
+local count = tonumber(redis.call("GET", KEYS[1]) or "0")
+local amount = tonumber(redis.call("GET", KEYS[2]) or "0")
+local beneficiary = tonumber(redis.call("GET", KEYS[3]) or "0")
+local add = tonumber(ARGV[1])
+local maxCount, maxAmount, maxBeneficiary = tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+
+local exceeded = ""
+if maxCount > 0 and count + 1 > maxCount then
+	exceeded = "DAILY_COUNT"
+elseif maxAmount > 0 and amount + add > maxAmount then
+	exceeded = "DAILY_AMOUNT"
+elseif maxBeneficiary > 0 and beneficiary + add > maxBeneficiary then
+	exceeded = "BENEFICIARY_DAILY_AMOUNT"
+end
+
+if exceeded == "" then
+	redis.call("INCR", KEYS[1])
+	redis.call("INCRBY", KEYS[2], add)
+	redis.call("INCRBY", KEYS[3], add)
+	for i = 1, 3 do
+		redis.call("EXPIRE", KEYS[i], ARGV[5])
+	end
+end
+return {exceeded, count, amount, beneficiary}`
+
+// releaseScript decrements counters that still exist; expired ones stay gone
+const releaseScript = `
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("DECR", KEYS[1])
+end
+for i = 2, 3 do
+	if redis.call("EXISTS", KEYS[i]) == 1 then
+		redis.call("DECRBY", KEYS[i], ARGV[1])
+	end
+end
+return 1`
+
+const usageScript = `
+local counters = {}
+for i = 1, 3 do
+	counters[i] = tonumber(redis.call("GET", KEYS[i]) or "0")
+end
+return counters`
+
+// RedisLimitStore keeps limit counters in Redis and updates them with Lua
+// scripts so each check-and-increment is atomic
+type RedisLimitStore struct {
+	client Evaler
+}
+
+func NewRedisLimitStore(client Evaler) *RedisLimitStore {
+	return &RedisLimitStore{client: client}
+}
+
+func (s *RedisLimitStore) Reserve(ctx context.Context, keys LimitKeys, amount int64, limits TransferLimits, ttl time.Duration) (LimitCounters, string, error) {
+	res, err := s.client.Eval(ctx, reserveScript, keys.list(), amount,
+		limits.DailyCount, toUnits(limits.DailyAmount), toUnits(limits.BeneficiaryDailyAmount), int64(ttl/time.Second))
+	if err != nil {
+		return LimitCounters{}, "", err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 4 {
+		return LimitCounters{}, "", fmt.Errorf("unexpected reserve reply %v", res)
+	}
+	exceeded, _ := values[0].(string)
+	counters := LimitCounters{Count: asInt64(values[1]), Amount: asInt64(values[2]), Beneficiary: asInt64(values[3])}
+	return counters, exceeded, nil
+}
+
+func (s *RedisLimitStore) Release(ctx context.Context, keys LimitKeys, amount int64) error {
+	_, err := s.client.Eval(ctx, releaseScript, keys.list(), amount)
+	return err
+}
+
+func (s *RedisLimitStore) Usage(ctx context.Context, keys LimitKeys) (LimitCounters, error) {
+	res, err := s.client.Eval(ctx, usageScript, keys.list())
+	if err != nil {
+		return LimitCounters{}, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return LimitCounters{}, fmt.Errorf("unexpected usage reply %v", res)
+	}
+	return LimitCounters{Count: asInt64(values[0]), Amount: asInt64(values[1]), Beneficiary: asInt64(values[2])}, nil
+}
+
+// list returns the keys in script order. Transfers without a beneficiary key
+// count against a throwaway key so the script always gets three.
+func (k LimitKeys) list() []string {
+	beneficiary := k.Beneficiary
+	if beneficiary == "" {
+		beneficiary = k.Count + ":unused"
+	}
+	return []string{k.Count, k.Amount, beneficiary}
+}
+
+func asInt64(v interface{}) int64 {
+	n, _ := v.(int64)
+	return n
+}
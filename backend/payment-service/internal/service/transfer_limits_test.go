@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryLimitStore is an in-memory LimitStore with the same semantics as the Lua scripts
+type memoryLimitStore struct {
+	counters map[string]int64
+	err      error
+}
+
+func newMemoryLimitStore() *memoryLimitStore {
+	return &memoryLimitStore{counters: map[string]int64{}}
+}
+
+func (s *memoryLimitStore) Reserve(_ context.Context, keys LimitKeys, amount int64, limits TransferLimits, _ time.Duration) (LimitCounters, string, error) {
+	if s.err != nil {
+		return LimitCounters{}, "", s.err
+	}
+	before := LimitCounters{Count: s.counters[keys.Count], Amount: s.counters[keys.Amount], Beneficiary: s.counters[keys.Beneficiary]}
+	switch {
+	case limits.DailyCount > 0 && before.Count+1 > limits.DailyCount:
+		return before, LimitDailyCount, nil
+	case limits.DailyAmount.IsPositive() && before.Amount+amount > toUnits(limits.DailyAmount):
+		return before, LimitDailyAmount, nil
+	case limits.BeneficiaryDailyAmount.IsPositive() && before.Beneficiary+amount > toUnits(limits.BeneficiaryDailyAmount):
+		return before, LimitBeneficiaryDailyAmount, nil
+	}
+	s.counters[keys.Count]++
+	s.counters[keys.Amount] += amount
+	s.counters[keys.Beneficiary] += amount
+	return before, "", nil
+}
+
+func (s *memoryLimitStore) Release(_ context.Context, keys LimitKeys, amount int64) error {
+	s.counters[keys.Count]--
+	s.counters[keys.Amount] -= amount
+	s.counters[keys.Beneficiary] -= amount
+	return nil
+}
+
+func (s *memoryLimitStore) Usage(_ context.Context, keys LimitKeys) (LimitCounters, error) {
+	if s.err != nil {
+		return LimitCounters{}, s.err
+	}
+	return LimitCounters{Count: s.counters[keys.Count], Amount: s.counters[keys.Amount], Beneficiary: s.counters[keys.Beneficiary]}, nil
+}
+
+func testLimits() TransferLimits {
+	return TransferLimits{
+		MaxPerTransaction:      decimal.NewFromInt(500),
+		DailyCount:             3,
+		DailyAmount:            decimal.NewFromInt(1000),
+		BeneficiaryDailyAmount: decimal.NewFromInt(600),
+	}
+}
+
+func requireLimitError(t *testing.T, err error, limit, remaining string) {
+	t.Helper()
+	var limitErr *LimitExceededError
+	require.True(t, errors.As(err, &limitErr), "expected *LimitExceededError, got %v", err)
+	assert.ErrorIs(t, err, ErrTransferLimitExceeded)
+	assert.Equal(t, limit, limitErr.Limit)
+	assert.Equal(t, remaining, limitErr.Remaining)
+}
+
+func TestTransferLimiter_MaxPerTransaction(t *testing.T) {
+	limiter := NewTransferLimiter(newMemoryLimitStore(), testLimits())
+
+	_, err := limiter.Reserve(context.Background(), "user", "acct", "USD", decimal.RequireFromString("500.01"))
+	requireLimitError(t, err, LimitMaxPerTransaction, "500")
+
+	_, err = limiter.Reserve(context.Background(), "user", "acct", "USD", decimal.NewFromInt(500))
+	assert.NoError(t, err)
+}
+
+func TestTransferLimiter_DailyLimits(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewTransferLimiter(newMemoryLimitStore(), testLimits())
+
+	_, err := limiter.Reserve(ctx, "user", "acct-1", "USD", decimal.NewFromInt(400))
+	require.NoError(t, err)
+
+	// Beneficiary cap is reached before the daily amount
+	_, err = limiter.Reserve(ctx, "user", "acct-1", "USD", decimal.NewFromInt(250))
+	requireLimitError(t, err, LimitBeneficiaryDailyAmount, "200")
+
+	_, err = limiter.Reserve(ctx, "user", "acct-2", "USD", decimal.RequireFromString("450.50"))
+	require.NoError(t, err)
+
+	_, err = limiter.Reserve(ctx, "user", "acct-3", "USD", decimal.NewFromInt(200))
+	requireLimitError(t, err, LimitDailyAmount, "149.5")
+
+	_, err = limiter.Reserve(ctx, "user", "acct-3", "USD", decimal.NewFromInt(100))
+	require.NoError(t, err)
+
+	_, err = limiter.Reserve(ctx, "user", "acct-3", "USD", decimal.NewFromInt(1))
+	requireLimitError(t, err, LimitDailyCount, "0")
+
+	// Other users have their own counters
+	_, err = limiter.Reserve(ctx, "other", "acct-1", "USD", decimal.NewFromInt(500))
+	assert.NoError(t, err)
+}
+
+func TestTransferLimiter_Check(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLimitStore()
+	limiter := NewTransferLimiter(store, testLimits())
+
+	requireLimitError(t, limiter.Check(ctx, "user", "acct-1", "USD", decimal.RequireFromString("500.01")), LimitMaxPerTransaction, "500")
+	require.NoError(t, limiter.Check(ctx, "user", "acct-1", "USD", decimal.NewFromInt(500)))
+	assert.Empty(t, store.counters, "checking counts nothing")
+
+	_, err := limiter.Reserve(ctx, "user", "acct-1", "USD", decimal.NewFromInt(400))
+	require.NoError(t, err)
+	requireLimitError(t, limiter.Check(ctx, "user", "acct-1", "USD", decimal.NewFromInt(250)), LimitBeneficiaryDailyAmount, "200")
+	assert.NoError(t, limiter.Check(ctx, "user", "acct-2", "USD", decimal.NewFromInt(250)))
+
+	store.err = errors.New("redis down")
+	assert.ErrorIs(t, limiter.Check(ctx, "user", "acct-2", "USD", decimal.NewFromInt(1)), ErrLimitsUnavailable)
+}
+
+func TestTransferLimiter_CountersResetDaily(t *testing.T) {
+	ctx := context.Background()
+	limits := testLimits()
+	limits.DailyCount = 1
+	limiter := NewTransferLimiter(newMemoryLimitStore(), limits)
+	day := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return day }
+
+	_, err := limiter.Reserve(ctx, "user", "acct", "USD", decimal.NewFromInt(10))
+	require.NoError(t, err)
+	_, err = limiter.Reserve(ctx, "user", "acct", "USD", decimal.NewFromInt(10))
+	requireLimitError(t, err, LimitDailyCount, "0")
+
+	day = day.Add(2 * time.Hour)
+	_, err = limiter.Reserve(ctx, "user", "acct", "USD", decimal.NewFromInt(10))
+	assert.NoError(t, err)
+}
+
+func TestTransferLimiter_Usage(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewTransferLimiter(newMemoryLimitStore(), testLimits())
+
+	r, err := limiter.Reserve(ctx, "user", "acct", "USD", decimal.RequireFromString("120.25"))
+	require.NoError(t, err)
+	_, err = limiter.Reserve(ctx, "user", "acct", "USD", decimal.NewFromInt(80))
+	require.NoError(t, err)
+	require.NoError(t, limiter.Release(ctx, r))
+
+	usage, err := limiter.Usage(ctx, "user", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.DailyCount.Used)
+	assert.Equal(t, int64(2), *usage.DailyCount.Remaining)
+	assert.True(t, decimal.NewFromInt(80).Equal(usage.DailyAmount.Used))
+	assert.True(t, decimal.NewFromInt(920).Equal(*usage.DailyAmount.Remaining))
+	assert.True(t, decimal.NewFromInt(500).Equal(usage.MaxPerTransaction))
+
+	// Unlimited limits have no remaining value
+	limiter = NewTransferLimiter(newMemoryLimitStore(), TransferLimits{})
+	usage, err = limiter.Usage(ctx, "user", "USD")
+	require.NoError(t, err)
+	assert.Nil(t, usage.DailyCount.Remaining)
+	assert.Nil(t, usage.DailyAmount.Remaining)
+}
+
+func TestTransferLimiter_AmountsArePerCurrency(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewTransferLimiter(newMemoryLimitStore(), testLimits())
+
+	_, err := limiter.Reserve(ctx, "user", "acct-1", "USD", decimal.NewFromInt(500))
+	require.NoError(t, err)
+	_, err = limiter.Reserve(ctx, "user", "acct-2", "USD", decimal.NewFromInt(500))
+	require.NoError(t, err)
+	_, err = limiter.Reserve(ctx, "user", "acct-2", "usd", decimal.NewFromInt(1))
+	requireLimitError(t, err, LimitDailyAmount, "0")
+
+	// Euros are not added to the dollar totals
+	requireLimitError(t, limiter.Check(ctx, "user", "acct-1", "EUR", decimal.NewFromInt(601)), LimitMaxPerTransaction, "500")
+	require.NoError(t, limiter.Check(ctx, "user", "acct-1", "EUR", decimal.NewFromInt(500)))
+	_, err = limiter.Reserve(ctx, "user", "acct-1", "EUR", decimal.NewFromInt(500))
+	require.NoError(t, err)
+
+	usd, err := limiter.Usage(ctx, "user", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, "USD", usd.Currency)
+	assert.True(t, decimal.NewFromInt(1000).Equal(usd.DailyAmount.Used))
+	eur, err := limiter.Usage(ctx, "user", "eur")
+	require.NoError(t, err)
+	assert.Equal(t, "EUR", eur.Currency)
+	assert.True(t, decimal.NewFromInt(500).Equal(eur.DailyAmount.Used))
+
+	// The number of transfers is counted across currencies
+	assert.Equal(t, int64(3), eur.DailyCount.Used)
+	_, err = limiter.Reserve(ctx, "user", "acct-3", "GBP", decimal.NewFromInt(1))
+	requireLimitError(t, err, LimitDailyCount, "0")
+}
+
+func TestTransferLimiter_StoreUnavailable(t *testing.T) {
+	store := newMemoryLimitStore()
+	store.err = errors.New("connection refused")
+	limiter := NewTransferLimiter(store, testLimits())
+
+	_, err := limiter.Reserve(context.Background(), "user", "acct", "USD", decimal.NewFromInt(10))
+	assert.ErrorIs(t, err, ErrLimitsUnavailable)
+	_, err = limiter.Usage(context.Background(), "user", "USD")
+	assert.ErrorIs(t, err, ErrLimitsUnavailable)
+}
+
+func TestInitiateUserTransfer_ReleasesFailedTransfer(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryLimitStore()
+	svc := &PaymentService{Repo: nil, useKafka: false}
+	svc.SetTransferLimiter(NewTransferLimiter(store, testLimits()))
+	accountID := uuid.New().String()
+
+	_, err := svc.InitiateUserTransfer(ctx, "user", accountID, accountID, "100", "USD", "test", "", "")
+	assert.Contains(t, err.Error(), "cannot transfer to the same account")
+
+	usage, err := svc.TransferLimitUsage(ctx, "user", "USD")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), usage.DailyCount.Used)
+	assert.True(t, usage.DailyAmount.Used.IsZero())
+
+	_, err = svc.InitiateUserTransfer(ctx, "user", accountID, uuid.New().String(), "501", "USD", "test", "", "")
+	requireLimitError(t, err, LimitMaxPerTransaction, "500")
+}
+
+func TestTransferLimitUsage_Disabled(t *testing.T) {
+	svc := &PaymentService{}
+	_, err := svc.TransferLimitUsage(context.Background(), "user", "USD")
+	assert.ErrorIs(t, err, ErrLimitsDisabled)
+}
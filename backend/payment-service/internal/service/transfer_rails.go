@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultStandardRailInterval is how often queued standard transfers are posted
+const DefaultStandardRailInterval = time.Hour
+
+// standardBatchSize caps how many standard transfers one run posts
+const standardBatchSize = 500
+
+var (
+	ErrRailsDisabled         = errors.New("transfer rails are not enabled")
+	ErrInvalidRail           = errors.New("rail must be INSTANT or STANDARD")
+	ErrInstantRailIneligible = errors.New("transfer is not eligible for the instant rail")
+)
+
+// StandardRailQueue finds the standard transfers waiting for a batch
+type StandardRailQueue interface {
+	QueuedStandardPayments(before time.Time, limit int) ([]model.Payment, error)
+}
+
+// RailPolicy decides which transfers may use the instant rail and when
+// standard transfers arrive. A user's tier is the product of the account they
+// pay from.
+type RailPolicy struct {
+	// InstantMaxAmount is the largest instant transfer; zero removes the cap
+	InstantMaxAmount decimal.Decimal
+	// TierInstantMaxAmounts override InstantMaxAmount per product code. A
+	// negative amount keeps the tier off the instant rail.
+	TierInstantMaxAmounts map[string]decimal.Decimal
+	// StandardInterval is how often standard transfers are posted
+	StandardInterval time.Duration
+}
+
+// DefaultRailPolicy allows instant transfers up to 1000 from every account
+func DefaultRailPolicy() RailPolicy {
+	return RailPolicy{
+		InstantMaxAmount: decimal.NewFromInt(1000),
+		StandardInterval: DefaultStandardRailInterval,
+	}
+}
+
+// checkInstant reports why a transfer from an account of the tier may not use
+// the instant rail, or nil if it may
+func (p RailPolicy) checkInstant(tier string, amount decimal.Decimal) error {
+	max := p.InstantMaxAmount
+	if tierMax, ok := p.TierInstantMaxAmounts[tier]; ok {
+		max = tierMax
+	}
+	switch {
+	case max.IsNegative():
+		return fmt.Errorf("%w: instant transfers are not available for %s accounts", ErrInstantRailIneligible, tier)
+	case max.IsPositive() && amount.GreaterThan(max):
+		return fmt.Errorf("%w: instant transfers are limited to %s", ErrInstantRailIneligible, max)
+	}
+	return nil
+}
+
+// estimatedArrival is when a transfer made at now reaches the payee
+func (p RailPolicy) estimatedArrival(rail model.TransferRail, now time.Time) time.Time {
+	if rail == model.RailStandard {
+		return now.Add(p.StandardInterval)
+	}
+	return now
+}
+
+// railPaymentType is the fee schedule type a rail is priced by
+func railPaymentType(rail model.TransferRail) model.PaymentType {
+	if rail == model.RailInstant {
+		return model.PaymentTypeInstantTransfer
+	}
+	return model.PaymentTypeTransfer
+}
+
+// SetRails lets users choose the instant or standard rail for transfers.
+// Standard transfers are posted by StandardRailJob.
+func (s *PaymentService) SetRails(policy RailPolicy, queue StandardRailQueue) {
+	s.rails = &policy
+	s.railQueue = queue
+}
+
+// RailQuote describes what a transfer would cost and when it would arrive on
+// one rail
+type RailQuote struct {
+	Rail             model.TransferRail `json:"rail"`
+	Eligible         bool               `json:"eligible"`
+	Reason           string             `json:"reason,omitempty"`
+	Fee              decimal.Decimal    `json:"fee"`
+	EstimatedArrival time.Time          `json:"estimated_arrival"`
+}
+
+// QuoteRails prices a transfer on each rail and says whether it may use the
+// instant rail
+func (s *PaymentService) QuoteRails(fromAcc, amountStr, currency string, now time.Time) ([]RailQuote, error) {
+	if s.rails == nil {
+		return nil, ErrRailsDisabled
+	}
+	parsed, err := money.Parse(amountStr, currency)
+	if err != nil {
+		return nil, err
+	}
+	if !parsed.IsPositive() {
+		return nil, money.ErrInvalidAmount
+	}
+	amount := parsed.Decimal()
+	tier := s.getAccount(fromAcc).productCode()
+
+	quotes := make([]RailQuote, 0, 2)
+	for _, rail := range []model.TransferRail{model.RailInstant, model.RailStandard} {
+		quote := RailQuote{Rail: rail, Eligible: true, Fee: decimal.Zero, EstimatedArrival: s.rails.estimatedArrival(rail, now)}
+		if rail == model.RailInstant {
+			if err := s.rails.checkInstant(tier, amount); err != nil {
+				quote.Eligible, quote.Reason = false, err.Error()
+			}
+		}
+		if s.fees != nil {
+			fee, err := s.fees.Quote(railPaymentType(rail), tier, currency, amount)
+			if err != nil {
+				return nil, fmt.Errorf("failed to price payment: %w", err)
+			}
+			quote.Fee = fee.Fee
+		}
+		quotes = append(quotes, quote)
+	}
+	return quotes, nil
+}
+
+// StandardRailJob posts the standard transfers queued before the run, each
+// as its own ledger entry. Transfers the ledger rejects, for example because
+// the balance has since fallen, fail like instant ones.
+func (s *PaymentService) StandardRailJob(_ context.Context, _ *jobs.Job) error {
+	if s.rails == nil {
+		return ErrRailsDisabled
+	}
+	queued, err := s.railQueue.QueuedStandardPayments(time.Now(), standardBatchSize)
+	if err != nil {
+		return err
+	}
+
+	posted, failed := 0, 0
+	for i := range queued {
+		payment := &queued[i]
+		userID := ""
+		if payment.UserID != nil {
+			userID = payment.UserID.String()
+		}
+		result, err := s.processSync(payment, userID, payment.FromAccountID.String(), payment.ToAccountID.String(), payment.Amount.String(), payment.Currency, payment.Description)
+		switch {
+		case err != nil:
+			failed++
+			slog.Warn("Standard transfer failed", "payment_id", payment.ID, "error", err)
+		case result.Status == model.StatusCompleted:
+			posted++
+		}
+	}
+	if posted+failed > 0 {
+		slog.Info("Posted standard transfers", "posted", posted, "failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queuedStandard serves the pending standard transfers of a memoryPaymentStates
+type queuedStandard struct{ *memoryPaymentStates }
+
+func (q queuedStandard) QueuedStandardPayments(before time.Time, limit int) ([]model.Payment, error) {
+	var queued []model.Payment
+	for _, p := range q.payments {
+		if p.Rail == model.RailStandard && p.Status == model.StatusPending && p.CreatedAt.Before(before) {
+			queued = append(queued, *p)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].CreatedAt.Before(queued[j].CreatedAt) })
+	if len(queued) > limit {
+		queued = queued[:limit]
+	}
+	return queued, nil
+}
+
+// tierLedger answers account lookups with an account of the given product
+func tierLedger(t *testing.T, productCode string) *httptest.Server {
+	ledger := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metadata := `{"product_code":"` + productCode + `"}`
+		_ = json.NewEncoder(w).Encode(AccountResponse{ID: "acct", Balance: "100000.00", Metadata: &metadata})
+	}))
+	t.Cleanup(ledger.Close)
+	return ledger
+}
+
+func testRailPolicy() RailPolicy {
+	policy := DefaultRailPolicy()
+	policy.TierInstantMaxAmounts = map[string]decimal.Decimal{
+		"CHECKING-PREMIUM": dec("10000"),
+		"SAVINGS-BASIC":    dec("-1"),
+	}
+	return policy
+}
+
+func TestRailPolicy_CheckInstant(t *testing.T) {
+	policy := testRailPolicy()
+
+	assert.NoError(t, policy.checkInstant("CHECKING-STD", dec("1000")))
+	assert.ErrorIs(t, policy.checkInstant("CHECKING-STD", dec("1000.01")), ErrInstantRailIneligible)
+	assert.NoError(t, policy.checkInstant("CHECKING-PREMIUM", dec("10000")), "premium accounts have a higher limit")
+	assert.ErrorIs(t, policy.checkInstant("CHECKING-PREMIUM", dec("10000.01")), ErrInstantRailIneligible)
+	assert.ErrorIs(t, policy.checkInstant("SAVINGS-BASIC", dec("1")), ErrInstantRailIneligible)
+
+	policy.InstantMaxAmount = decimal.Zero
+	assert.NoError(t, policy.checkInstant("CHECKING-STD", dec("1000000")), "zero removes the cap")
+}
+
+func TestQuoteRails(t *testing.T) {
+	ledger := tierLedger(t, "CHECKING-PREMIUM")
+	fees := NewFeeService(&memoryFeeRepository{}, uuid.NewString())
+	_, err := fees.CreateSchedule(&model.FeeSchedule{Name: "Instant", Currency: "USD", PaymentType: model.PaymentTypeInstantTransfer, Method: model.FeeFlat, FlatAmount: dec("1.00"), Active: true})
+	require.NoError(t, err)
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	svc.SetFees(fees)
+
+	_, err = svc.QuoteRails(uuid.NewString(), "5000", "USD", time.Now())
+	assert.ErrorIs(t, err, ErrRailsDisabled)
+
+	svc.SetRails(testRailPolicy(), nil)
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	quotes, err := svc.QuoteRails(uuid.NewString(), "5000", "USD", now)
+	require.NoError(t, err)
+	require.Len(t, quotes, 2)
+	assert.Equal(t, model.RailInstant, quotes[0].Rail)
+	assert.True(t, quotes[0].Eligible)
+	assert.Equal(t, "1", quotes[0].Fee.String())
+	assert.Equal(t, now, quotes[0].EstimatedArrival)
+	assert.Equal(t, model.RailStandard, quotes[1].Rail)
+	assert.True(t, quotes[1].Fee.IsZero(), "no STANDARD schedule means no fee")
+	assert.Equal(t, now.Add(time.Hour), quotes[1].EstimatedArrival, "standard transfers wait for the next batch")
+
+	quotes, err = svc.QuoteRails(uuid.NewString(), "20000", "USD", now)
+	require.NoError(t, err)
+	assert.False(t, quotes[0].Eligible)
+	assert.Contains(t, quotes[0].Reason, "limited to 10000")
+	assert.True(t, quotes[1].Eligible, "every transfer may use the standard rail")
+}
+
+func TestInitiateUserTransfer_RailChecks(t *testing.T) {
+	ledger := tierLedger(t, "SAVINGS-BASIC")
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	ctx := context.Background()
+	transfer := func(rail model.TransferRail) error {
+		_, err := svc.InitiateUserTransfer(ctx, uuid.NewString(), uuid.NewString(), uuid.NewString(), "50", "USD", "rent", "", rail)
+		return err
+	}
+
+	assert.ErrorIs(t, transfer(model.RailInstant), ErrRailsDisabled)
+	svc.SetRails(testRailPolicy(), nil)
+	assert.ErrorIs(t, transfer("SAME_DAY"), ErrInvalidRail)
+	assert.ErrorIs(t, transfer(model.RailInstant), ErrInstantRailIneligible)
+}
+
+func TestStandardRailJob(t *testing.T) {
+	ledger := newPayoutLedger(t, "1000.00")
+	states := &memoryPaymentStates{payments: map[string]*model.Payment{}}
+	svc := &PaymentService{payments: states, ledgerURL: ledger.URL, ledger: ledger.Client()}
+	svc.SetRails(DefaultRailPolicy(), queuedStandard{states})
+
+	add := func(rail model.TransferRail, status model.PaymentStatus) *model.Payment {
+		p := &model.Payment{ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: dec("25"), Currency: "USD",
+			Status: status, Rail: rail, CreatedAt: time.Now().Add(-time.Minute)}
+		states.payments[p.ID.String()] = p
+		return p
+	}
+	first := add(model.RailStandard, model.StatusPending)
+	second := add(model.RailStandard, model.StatusPending)
+	cancelled := add(model.RailStandard, model.StatusCancelled)
+	instant := add(model.RailInstant, model.StatusPending)
+
+	require.NoError(t, svc.StandardRailJob(context.Background(), nil))
+	assert.Len(t, ledger.posted, 2)
+	for _, p := range []*model.Payment{first, second} {
+		assert.Equal(t, model.StatusCompleted, p.Status)
+		assert.NotNil(t, p.LedgerEntryID)
+	}
+	assert.Equal(t, model.StatusCancelled, cancelled.Status, "cancelled transfers are not posted")
+	assert.Equal(t, model.StatusPending, instant.Status, "only standard transfers are batched")
+
+	// Posted transfers are not picked up again
+	require.NoError(t, svc.StandardRailJob(context.Background(), nil))
+	assert.Len(t, ledger.posted, 2)
+}
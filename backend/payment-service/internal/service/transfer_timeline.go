@@ -0,0 +1,91 @@
+package service
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/google/uuid"
+)
+
+// TransferTimelineRepository stores the state history of transfers
+type TransferTimelineRepository interface {
+	RecordTransition(t *model.TransferTransition) error
+	ListTransitions(paymentID string) ([]model.TransferTransition, error)
+}
+
+// TransferTimeline is a transfer's current status and how it got there
+type TransferTimeline struct {
+	PaymentID   uuid.UUID                  `json:"payment_id"`
+	Status      model.PaymentStatus        `json:"status"`
+	Transitions []model.TransferTransition `json:"transitions"`
+}
+
+// SetTimeline records every state transition of payments, from the HTTP path,
+// the standard rail job and the ledger's results alike
+func (s *PaymentService) SetTimeline(timeline TransferTimelineRepository) {
+	s.timeline = timeline
+}
+
+// recordTransition appends to a payment's timeline. The timeline is for
+// investigations, so a transition that cannot be stored is logged and the
+// payment carries on.
+func (s *PaymentService) recordTransition(paymentID uuid.UUID, state model.TransferState, actor, detail string) {
+	if s.timeline == nil {
+		return
+	}
+	err := s.timeline.RecordTransition(&model.TransferTransition{
+		PaymentID: paymentID,
+		State:     state,
+		Actor:     actor,
+		Detail:    detail,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		slog.Warn("Failed to record transfer transition", "payment_id", paymentID, "state", state, "error", err)
+	}
+}
+
+// TransferTimeline returns the timeline of one of the user's transfers
+func (s *PaymentService) TransferTimeline(userID, paymentID string) (*TransferTimeline, error) {
+	return s.transferTimeline(paymentID, func(p *model.Payment) bool {
+		return p.UserID != nil && p.UserID.String() == userID
+	})
+}
+
+// AnyTransferTimeline returns the timeline of any payment, for support staff
+func (s *PaymentService) AnyTransferTimeline(paymentID string) (*TransferTimeline, error) {
+	return s.transferTimeline(paymentID, func(*model.Payment) bool { return true })
+}
+
+// transferTimeline returns a payment's timeline if visible says the caller
+// may see it; other payments are not found
+func (s *PaymentService) transferTimeline(paymentID string, visible func(*model.Payment) bool) (*TransferTimeline, error) {
+	if _, err := uuid.Parse(paymentID); err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	payment, err := s.payments.GetPayment(paymentID)
+	if err != nil || !visible(payment) {
+		return nil, ErrPaymentNotFound
+	}
+
+	timeline := &TransferTimeline{PaymentID: payment.ID, Status: payment.Status, Transitions: []model.TransferTransition{}}
+	if s.timeline != nil {
+		transitions, err := s.timeline.ListTransitions(paymentID)
+		if err != nil {
+			return nil, err
+		}
+		if transitions != nil {
+			timeline.Transitions = transitions
+		}
+	}
+	return timeline, nil
+}
+
+// entryDetail describes the journal entry a transfer was posted as
+func entryDetail(entryID *uuid.UUID) string {
+	if entryID == nil {
+		return ""
+	}
+	return "journal entry " + entryID.String()
+}
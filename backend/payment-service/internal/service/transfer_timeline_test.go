@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryTimeline is an in-memory TransferTimelineRepository
+type memoryTimeline struct {
+	transitions []model.TransferTransition
+	fail        bool
+}
+
+func (r *memoryTimeline) RecordTransition(t *model.TransferTransition) error {
+	if r.fail {
+		return errors.New("timeline unavailable")
+	}
+	t.ID = uuid.New()
+	r.transitions = append(r.transitions, *t)
+	return nil
+}
+
+func (r *memoryTimeline) ListTransitions(paymentID string) ([]model.TransferTransition, error) {
+	var transitions []model.TransferTransition
+	for _, t := range r.transitions {
+		if t.PaymentID.String() == paymentID {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions, nil
+}
+
+// states returns the states and actors of a payment's timeline
+func (r *memoryTimeline) states(paymentID uuid.UUID) []string {
+	var states []string
+	for _, t := range r.transitions {
+		if t.PaymentID == paymentID {
+			states = append(states, string(t.State)+" by "+t.Actor)
+		}
+	}
+	return states
+}
+
+func TestTransferTimeline_LedgerResults(t *testing.T) {
+	svc, _, payment, userID := newCancellationService(model.StatusPending)
+	timeline := &memoryTimeline{}
+	svc.SetTimeline(timeline)
+
+	entryID := uuid.New()
+	require.NoError(t, svc.ApplyLedgerResult(kafka.PaymentEvent{PaymentID: payment.ID.String(), LedgerEntryID: entryID.String()}, model.StatusCompleted))
+	assert.Equal(t, []string{"POSTED by ledger-service", "COMPLETED by ledger-service"}, timeline.states(payment.ID))
+	assert.Equal(t, "journal entry "+entryID.String(), timeline.transitions[0].Detail)
+
+	// A repeated result changes nothing, so it adds nothing
+	require.NoError(t, svc.ApplyLedgerResult(kafka.PaymentEvent{PaymentID: payment.ID.String()}, model.StatusFailed))
+	assert.Len(t, timeline.transitions, 2)
+
+	got, err := svc.TransferTimeline(userID, payment.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusCompleted, got.Status)
+	assert.Len(t, got.Transitions, 2)
+}
+
+func TestTransferTimeline_Cancellation(t *testing.T) {
+	svc, _, payment, userID := newCancellationService(model.StatusPending)
+	timeline := &memoryTimeline{}
+	svc.SetTimeline(timeline)
+
+	_, err := svc.CancelTransfer(context.Background(), userID, payment.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CANCELLED by user:" + userID}, timeline.states(payment.ID))
+}
+
+func TestTransferTimeline_StandardRailJob(t *testing.T) {
+	ledger := newPayoutLedger(t, "1000.00")
+	states := &memoryPaymentStates{payments: map[string]*model.Payment{}}
+	svc := &PaymentService{payments: states, ledgerURL: ledger.URL, ledger: ledger.Client()}
+	svc.SetRails(DefaultRailPolicy(), queuedStandard{states})
+	timeline := &memoryTimeline{}
+	svc.SetTimeline(timeline)
+
+	payment := &model.Payment{ID: uuid.New(), FromAccountID: uuid.New(), ToAccountID: uuid.New(), Amount: dec("25"), Currency: "USD",
+		Status: model.StatusPending, Rail: model.RailStandard, CreatedAt: time.Now().Add(-time.Minute)}
+	states.payments[payment.ID.String()] = payment
+
+	require.NoError(t, svc.StandardRailJob(context.Background(), nil))
+	assert.Equal(t, []string{"POSTED by ledger-service", "COMPLETED by payment-service"}, timeline.states(payment.ID))
+}
+
+func TestTransferTimeline_Visibility(t *testing.T) {
+	svc, _, payment, userID := newCancellationService(model.StatusPending)
+
+	// Without a timeline the current status is still reported
+	got, err := svc.TransferTimeline(userID, payment.ID.String())
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPending, got.Status)
+	assert.Empty(t, got.Transitions)
+
+	_, err = svc.TransferTimeline(uuid.NewString(), payment.ID.String())
+	assert.ErrorIs(t, err, ErrPaymentNotFound, "only the payer sees their transfer")
+	_, err = svc.TransferTimeline(userID, "not-a-uuid")
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+
+	got, err = svc.AnyTransferTimeline(payment.ID.String())
+	require.NoError(t, err, "support sees every payment")
+	assert.Equal(t, payment.ID, got.PaymentID)
+}
+
+func TestTransferTimeline_FailuresDoNotFailPayments(t *testing.T) {
+	svc, repo, payment, _ := newCancellationService(model.StatusPending)
+	svc.SetTimeline(&memoryTimeline{fail: true})
+
+	require.NoError(t, svc.ApplyLedgerResult(kafka.PaymentEvent{PaymentID: payment.ID.String()}, model.StatusFailed))
+	assert.Equal(t, model.StatusFailed, repo.payments[payment.ID.String()].Status)
+}
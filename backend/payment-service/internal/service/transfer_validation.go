@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Names of the checks a transfer validation runs
+const (
+	CheckBeneficiary = "BENEFICIARY"
+	CheckRail        = "RAIL"
+	CheckFees        = "FEES"
+	CheckBalance     = "BALANCE"
+	CheckLimits      = "LIMITS"
+	CheckFX          = "FX"
+)
+
+// CheckStatus is the outcome of one validation check
+type CheckStatus string
+
+const (
+	CheckPassed CheckStatus = "PASSED"
+	CheckFailed CheckStatus = "FAILED"
+	// CheckUnavailable means the check could not be made; the transfer may
+	// still be refused when it is made
+	CheckUnavailable CheckStatus = "UNAVAILABLE"
+	// CheckSkipped means the check does not apply, e.g. limits are not enabled
+	CheckSkipped CheckStatus = "SKIPPED"
+)
+
+// accountActive is the ledger status of an account that can receive transfers
+const accountActive = "ACTIVE"
+
+// ValidationCheck is the outcome of one check of a transfer validation.
+// Details carry the same payload as the error the transfer would fail with,
+// e.g. a *LimitExceededError.
+type ValidationCheck struct {
+	Name    string      `json:"name"`
+	Status  CheckStatus `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// TransferValidation reports whether a transfer would be accepted now and
+// what it would cost, without making it
+type TransferValidation struct {
+	Valid         bool            `json:"valid"`
+	FromAccountID string          `json:"from_account_id"`
+	ToAccountID   string          `json:"to_account_id,omitempty"`
+	Amount        decimal.Decimal `json:"amount"`
+	Currency      string          `json:"currency"`
+	Fee           decimal.Decimal `json:"fee"`
+	FeeScheduleID *uuid.UUID      `json:"fee_schedule_id,omitempty"`
+	// Total is what leaves the account: the amount and its fee
+	Total            decimal.Decimal    `json:"total"`
+	Rail             model.TransferRail `json:"rail,omitempty"`
+	EstimatedArrival *time.Time         `json:"estimated_arrival,omitempty"`
+	Checks           []ValidationCheck  `json:"checks"`
+}
+
+// ValidateUserTransfer runs the checks InitiateUserTransfer makes on a
+// transfer, without creating a payment or counting it against the user's
+// limits: that the beneficiary exists and can receive it, that the rail may
+// be used, what it costs, that the balance covers it with its fee, that it is
+// within the user's limits and that no currency conversion is needed. Every
+// check is run and reported, so a confirmation screen can show all the
+// problems at once. Requests that could never be a transfer, such as a
+// malformed amount or the same account on both sides, are errors.
+func (s *PaymentService) ValidateUserTransfer(ctx context.Context, userID, fromAcc string, to Destination, amountStr, currency string, rail model.TransferRail, now time.Time) (*TransferValidation, error) {
+	parsed, err := money.Parse(amountStr, currency)
+	if err != nil {
+		return nil, err
+	}
+	if !parsed.IsPositive() {
+		return nil, errors.New("amount must be greater than zero")
+	}
+	if _, err := uuid.Parse(fromAcc); err != nil {
+		return nil, errors.New("invalid from account id")
+	}
+	switch rail {
+	case "", model.RailInstant, model.RailStandard:
+	default:
+		return nil, ErrInvalidRail
+	}
+	amount := parsed.Decimal()
+
+	v := &TransferValidation{FromAccountID: fromAcc, Amount: amount, Currency: currency, Fee: decimal.Zero, Rail: rail}
+	check := func(name string, status CheckStatus, message string, details interface{}) {
+		v.Checks = append(v.Checks, ValidationCheck{Name: name, Status: status, Message: message, Details: details})
+	}
+
+	// Beneficiary: the destination resolves to an active account
+	toAcc, err := s.ResolveDestination(ctx, to)
+	var toAccount *AccountResponse
+	switch {
+	case errors.Is(err, ErrInvalidDestination):
+		return nil, err
+	case errors.Is(err, ErrAliasNotFound):
+		check(CheckBeneficiary, CheckFailed, err.Error(), nil)
+	case err != nil:
+		check(CheckBeneficiary, CheckUnavailable, err.Error(), nil)
+	case toAcc == fromAcc:
+		return nil, errors.New("cannot transfer to the same account")
+	default:
+		if _, err := uuid.Parse(toAcc); err != nil {
+			return nil, errors.New("invalid to account id")
+		}
+		v.ToAccountID = toAcc
+		switch toAccount = s.getAccount(toAcc); {
+		case toAccount == nil:
+			check(CheckBeneficiary, CheckUnavailable, "the beneficiary account could not be read", nil)
+		case toAccount.Status != "" && toAccount.Status != accountActive:
+			check(CheckBeneficiary, CheckFailed, fmt.Sprintf("the beneficiary account is %s", toAccount.Status), nil)
+		default:
+			check(CheckBeneficiary, CheckPassed, "", nil)
+		}
+	}
+
+	// Rail: the chosen rail is enabled and the transfer may use it
+	account := s.getAccount(fromAcc)
+	paymentType := model.PaymentTypeTransfer
+	switch {
+	case rail == "":
+		check(CheckRail, CheckSkipped, "no rail chosen", nil)
+	case s.rails == nil:
+		check(CheckRail, CheckFailed, ErrRailsDisabled.Error(), nil)
+	default:
+		paymentType = railPaymentType(rail)
+		arrival := s.rails.estimatedArrival(rail, now)
+		v.EstimatedArrival = &arrival
+		if rail == model.RailInstant {
+			if err := s.rails.checkInstant(account.productCode(), amount); err != nil {
+				check(CheckRail, CheckFailed, err.Error(), nil)
+				break
+			}
+		}
+		check(CheckRail, CheckPassed, "", nil)
+	}
+
+	// Fees: priced as the transfer would be
+	if s.fees == nil {
+		check(CheckFees, CheckSkipped, "fees are not enabled", nil)
+	} else if quote, err := s.fees.Quote(paymentType, account.productCode(), currency, amount); err != nil {
+		check(CheckFees, CheckUnavailable, fmt.Sprintf("failed to price payment: %v", err), nil)
+	} else {
+		v.Fee, v.FeeScheduleID = quote.Fee, quote.ScheduleID
+		check(CheckFees, CheckPassed, "", nil)
+	}
+	v.Total = amount.Add(v.Fee)
+
+	// Balance: the account covers the amount and its fee
+	if account == nil {
+		check(CheckBalance, CheckUnavailable, "the account could not be read", nil)
+	} else if err := validateBalance(account, v.Total); err != nil {
+		check(CheckBalance, CheckFailed, err.Error(), nil)
+	} else {
+		check(CheckBalance, CheckPassed, "", nil)
+	}
+
+	// Limits: today's usage leaves room for the transfer
+	var limitErr *LimitExceededError
+	if s.limits == nil {
+		check(CheckLimits, CheckSkipped, ErrLimitsDisabled.Error(), nil)
+	} else if err := s.limits.Check(ctx, userID, v.ToAccountID, currency, amount); errors.As(err, &limitErr) {
+		check(CheckLimits, CheckFailed, err.Error(), limitErr)
+	} else if err != nil {
+		check(CheckLimits, CheckUnavailable, err.Error(), nil)
+	} else {
+		check(CheckLimits, CheckPassed, "", nil)
+	}
+
+	// FX: transfers are booked in one currency on both sides; there are no
+	// exchange rates to convert with
+	switch {
+	case account == nil || account.CurrencyCode == "" || toAccount == nil || toAccount.CurrencyCode == "":
+		check(CheckFX, CheckUnavailable, "the account currencies could not be read", nil)
+	case account.CurrencyCode != currency:
+		check(CheckFX, CheckFailed, fmt.Sprintf("the account is in %s; currency conversion is not supported", account.CurrencyCode), nil)
+	case toAccount.CurrencyCode != currency:
+		check(CheckFX, CheckFailed, fmt.Sprintf("the beneficiary account is in %s; currency conversion is not supported", toAccount.CurrencyCode), nil)
+	default:
+		check(CheckFX, CheckPassed, "no conversion needed", nil)
+	}
+
+	v.Valid = true
+	for _, c := range v.Checks {
+		if c.Status == CheckFailed {
+			v.Valid = false
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/payment-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// accountsLedger answers account lookups with the given accounts by ID
+func accountsLedger(t *testing.T, accounts ...AccountResponse) *httptest.Server {
+	ledger := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, account := range accounts {
+			if strings.HasSuffix(r.URL.Path, "/accounts/"+account.ID) {
+				_ = json.NewEncoder(w).Encode(account)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(ledger.Close)
+	return ledger
+}
+
+// checkStatuses maps each check of a validation to its status
+func checkStatuses(v *TransferValidation) map[string]CheckStatus {
+	statuses := map[string]CheckStatus{}
+	for _, c := range v.Checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses
+}
+
+func TestValidateUserTransfer(t *testing.T) {
+	from, to := uuid.NewString(), uuid.NewString()
+	metadata := `{"product_code":"CHECKING-STD"}`
+	ledger := accountsLedger(t,
+		AccountResponse{ID: from, Balance: "100.00", Metadata: &metadata, Status: "ACTIVE", CurrencyCode: "USD"},
+		AccountResponse{ID: to, Balance: "0", Status: "ACTIVE", CurrencyCode: "USD"},
+	)
+	fees := NewFeeService(&memoryFeeRepository{}, uuid.NewString())
+	_, err := fees.CreateSchedule(&model.FeeSchedule{Name: "Instant", Currency: "USD", PaymentType: model.PaymentTypeInstantTransfer, Method: model.FeeFlat, FlatAmount: dec("1.00"), Active: true})
+	require.NoError(t, err)
+	store := newMemoryLimitStore()
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	svc.SetFees(fees)
+	svc.SetRails(testRailPolicy(), nil)
+	svc.SetTransferLimiter(NewTransferLimiter(store, testLimits()))
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	v, err := svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: to}, "99.00", "USD", model.RailInstant, now)
+	require.NoError(t, err)
+	assert.True(t, v.Valid)
+	assert.Equal(t, map[string]CheckStatus{
+		CheckBeneficiary: CheckPassed, CheckRail: CheckPassed, CheckFees: CheckPassed,
+		CheckBalance: CheckPassed, CheckLimits: CheckPassed, CheckFX: CheckPassed,
+	}, checkStatuses(v))
+	assert.Equal(t, "1", v.Fee.String())
+	assert.Equal(t, "100", v.Total.String())
+	assert.Equal(t, now, *v.EstimatedArrival)
+	assert.Empty(t, store.counters, "nothing is counted against the limits")
+
+	// The fee takes the total over the balance; every other check is still made
+	v, err = svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: to}, "99.50", "USD", model.RailInstant, now)
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	statuses := checkStatuses(v)
+	assert.Equal(t, CheckFailed, statuses[CheckBalance])
+	assert.Equal(t, CheckPassed, statuses[CheckLimits])
+	assert.Len(t, v.Checks, 6)
+
+	// Over a limit, with the remaining allowance in the details
+	_, err = svc.limits.Reserve(ctx, "user", to, "USD", dec("500"))
+	require.NoError(t, err)
+	v, err = svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: to}, "150", "USD", "", now)
+	require.NoError(t, err)
+	assert.False(t, v.Valid)
+	for _, c := range v.Checks {
+		if c.Name == CheckLimits {
+			assert.Equal(t, CheckFailed, c.Status)
+			assert.Equal(t, LimitBeneficiaryDailyAmount, c.Details.(*LimitExceededError).Limit)
+		}
+	}
+	assert.Equal(t, CheckSkipped, checkStatuses(v)[CheckRail])
+	assert.True(t, v.Fee.IsZero(), "no TRANSFER schedule means no fee")
+}
+
+func TestValidateUserTransfer_Beneficiary(t *testing.T) {
+	from, frozen, euro := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	ledger := accountsLedger(t,
+		AccountResponse{ID: from, Balance: "100.00", Status: "ACTIVE", CurrencyCode: "USD"},
+		AccountResponse{ID: frozen, Balance: "0", Status: "FROZEN", CurrencyCode: "USD"},
+		AccountResponse{ID: euro, Balance: "0", Status: "ACTIVE", CurrencyCode: "EUR"},
+	)
+	svc := &PaymentService{ledgerURL: ledger.URL, ledger: ledger.Client()}
+	ctx := context.Background()
+	validate := func(to string) map[string]CheckStatus {
+		v, err := svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: to}, "10", "USD", "", time.Now())
+		require.NoError(t, err)
+		return checkStatuses(v)
+	}
+
+	assert.Equal(t, CheckFailed, validate(frozen)[CheckBeneficiary])
+	statuses := validate(euro)
+	assert.Equal(t, CheckPassed, statuses[CheckBeneficiary])
+	assert.Equal(t, CheckFailed, statuses[CheckFX], "currency conversion is not supported")
+	assert.Equal(t, CheckSkipped, statuses[CheckLimits])
+	statuses = validate(uuid.NewString())
+	assert.Equal(t, CheckUnavailable, statuses[CheckBeneficiary], "an account that cannot be read is not refused")
+	assert.Equal(t, CheckUnavailable, statuses[CheckFX])
+
+	// Requests that could never be a transfer are errors
+	_, err := svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: from}, "10", "USD", "", time.Now())
+	assert.Error(t, err)
+	_, err = svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: euro}, "10.001", "USD", "", time.Now())
+	assert.ErrorIs(t, err, money.ErrPrecision)
+	_, err = svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: euro, IBAN: "GB82WEST12345698765432"}, "10", "USD", "", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidDestination)
+	_, err = svc.ValidateUserTransfer(ctx, "user", from, Destination{AccountID: euro}, "10", "USD", "SAME_DAY", time.Now())
+	assert.ErrorIs(t, err, ErrInvalidRail)
+}
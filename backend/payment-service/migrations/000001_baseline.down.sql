@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS payment_batches;
+DROP TABLE IF EXISTS payment_requests;
+DROP TABLE IF EXISTS mandates;
+DROP TABLE IF EXISTS merchants;
+DROP TABLE IF EXISTS payments;
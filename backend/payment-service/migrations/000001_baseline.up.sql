@@ -0,0 +1,97 @@
+-- Baseline schema for payment-service, matching what GORM AutoMigrate created.
+-- Statements use IF NOT EXISTS so databases created by AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS payments (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_account_id uuid NOT NULL,
+    to_account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    status varchar(20) DEFAULT 'PENDING',
+    description text,
+    mandate_id uuid,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_payments_mandate_id ON payments (mandate_id);
+CREATE INDEX IF NOT EXISTS idx_payments_deleted_at ON payments (deleted_at);
+
+CREATE TABLE IF NOT EXISTS merchants (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_user_id uuid NOT NULL,
+    name varchar(100) NOT NULL,
+    settlement_account_id uuid NOT NULL,
+    api_key_hash char(64) NOT NULL,
+    active boolean DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_merchants_owner_user_id ON merchants (owner_user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_merchants_api_key_hash ON merchants (api_key_hash);
+CREATE INDEX IF NOT EXISTS idx_merchants_deleted_at ON merchants (deleted_at);
+
+CREATE TABLE IF NOT EXISTS mandates (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    debtor_account_id uuid NOT NULL,
+    reference varchar(100) NOT NULL,
+    currency char(3) NOT NULL,
+    max_amount numeric(19,4) NOT NULL,
+    monthly_limit numeric(19,4) DEFAULT 0,
+    status varchar(20) DEFAULT 'PENDING_APPROVAL',
+    expires_at timestamptz,
+    approved_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_mandates_merchant_id ON mandates (merchant_id);
+CREATE INDEX IF NOT EXISTS idx_mandates_user_id ON mandates (user_id);
+CREATE INDEX IF NOT EXISTS idx_mandates_status ON mandates (status);
+CREATE INDEX IF NOT EXISTS idx_mandates_deleted_at ON mandates (deleted_at);
+
+CREATE TABLE IF NOT EXISTS payment_requests (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    reference varchar(20) NOT NULL,
+    requester_user_id uuid NOT NULL,
+    requester_account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    description text,
+    status varchar(20) DEFAULT 'OPEN',
+    expires_at timestamptz NOT NULL,
+    payer_user_id uuid,
+    payment_id uuid,
+    paid_at timestamptz,
+    cancelled_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_requests_reference ON payment_requests (reference);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_requester_user_id ON payment_requests (requester_user_id);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_status ON payment_requests (status);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_expires_at ON payment_requests (expires_at);
+CREATE INDEX IF NOT EXISTS idx_payment_requests_deleted_at ON payment_requests (deleted_at);
+
+CREATE TABLE IF NOT EXISTS payment_batches (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    message_id varchar(35) NOT NULL,
+    uploaded_by uuid NOT NULL,
+    initiating_party varchar(140),
+    number_of_transactions bigint NOT NULL,
+    control_sum numeric(19,4) NOT NULL,
+    accepted bigint NOT NULL,
+    rejected bigint NOT NULL,
+    status varchar(20) NOT NULL,
+    items jsonb,
+    clearing_message_id varchar(35),
+    cleared_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_batch_message ON payment_batches (message_id, uploaded_by);
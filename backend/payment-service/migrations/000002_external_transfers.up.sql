@@ -0,0 +1,27 @@
+CREATE TABLE external_transfers (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    from_account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    scheme varchar(10) NOT NULL,
+    creditor_name varchar(140) NOT NULL,
+    iban varchar(34),
+    sort_code varchar(6),
+    account_number varchar(8),
+    reference varchar(35),
+    connector varchar(50) NOT NULL,
+    external_id varchar(100),
+    status varchar(20) NOT NULL,
+    attempts bigint NOT NULL,
+    next_attempt_at timestamptz,
+    last_error text,
+    debit_payment_id uuid,
+    refund_payment_id uuid,
+    settled_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_external_transfers_user_id ON external_transfers (user_id);
+CREATE INDEX idx_external_transfers_status ON external_transfers (status);
+CREATE UNIQUE INDEX idx_external_transfer_connector_ref ON external_transfers (connector, external_id);
@@ -0,0 +1,18 @@
+ALTER TABLE payments ADD COLUMN ledger_entry_id uuid;
+ALTER TABLE payments ADD COLUMN refunded_amount numeric(19,4) NOT NULL DEFAULT 0;
+
+CREATE TABLE refunds (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id uuid NOT NULL REFERENCES payments (id),
+    requested_by uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    reason varchar(140),
+    status varchar(20) NOT NULL,
+    ledger_entry_id uuid,
+    failure_reason text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_refunds_payment_id ON refunds (payment_id);
+CREATE INDEX idx_refunds_status ON refunds (status);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS fee_schedules;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS fee_schedule_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS fee;
+ALTER TABLE payments DROP COLUMN IF EXISTS payment_type;
@@ -0,0 +1,21 @@
+ALTER TABLE payments ADD COLUMN payment_type varchar(20);
+ALTER TABLE payments ADD COLUMN fee numeric(19,4) NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN fee_schedule_id uuid;
+
+CREATE TABLE fee_schedules (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    product_code varchar(50) NOT NULL DEFAULT '',
+    currency char(3) NOT NULL,
+    payment_type varchar(20) NOT NULL,
+    method varchar(20) NOT NULL,
+    flat_amount numeric(19,4) NOT NULL DEFAULT 0,
+    percentage numeric(7,4) NOT NULL DEFAULT 0,
+    tiers jsonb,
+    min_fee numeric(19,4) NOT NULL DEFAULT 0,
+    max_fee numeric(19,4) NOT NULL DEFAULT 0,
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_fee_schedules_lookup ON fee_schedules (payment_type, currency);
@@ -0,0 +1,26 @@
+CREATE TABLE incoming_credits (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    connector varchar(50) NOT NULL,
+    external_id varchar(100) NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    scheme varchar(10),
+    debtor_name varchar(140),
+    iban varchar(34),
+    sort_code varchar(6),
+    account_number varchar(8),
+    reference varchar(140),
+    status varchar(20) NOT NULL,
+    account_id uuid,
+    payment_id uuid,
+    suspense_payment_id uuid,
+    suspense_reason text,
+    last_error text,
+    repaired_by uuid,
+    repair_note text,
+    repaired_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_incoming_credits_status ON incoming_credits (status);
+CREATE UNIQUE INDEX idx_incoming_credit_connector_ref ON incoming_credits (connector, external_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_user_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE payments DROP COLUMN IF EXISTS user_id;
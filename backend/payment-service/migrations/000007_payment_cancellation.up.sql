@@ -0,0 +1,5 @@
+-- User transfers record who made them, so only that user can cancel one while
+-- it is still pending
+ALTER TABLE payments ADD COLUMN user_id uuid;
+ALTER TABLE payments ADD COLUMN cancelled_at timestamptz;
+CREATE INDEX idx_payments_user_id ON payments (user_id);
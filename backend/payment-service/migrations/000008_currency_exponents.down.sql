@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_fee_minor_units;
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_amount_minor_units;
+DROP FUNCTION IF EXISTS currency_exponent(char(3));
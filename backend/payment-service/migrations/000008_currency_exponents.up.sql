@@ -0,0 +1,17 @@
+-- currency_exponent is the number of decimal places of an ISO 4217 currency's
+-- minor unit, matching shared-lib/pkg/money: 0 for JPY, 3 for KWD, 2 for most
+CREATE FUNCTION currency_exponent(code char(3)) RETURNS integer AS $$
+    SELECT CASE
+        WHEN code IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG', 'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN code IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        WHEN code IN ('CLF', 'UYW') THEN 4
+        ELSE 2
+    END;
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE;
+
+-- Payment amounts and fees are whole minor units of the payment's currency.
+-- NOT VALID leaves existing rows unchecked until VALIDATE CONSTRAINT is run.
+ALTER TABLE payments ADD CONSTRAINT payments_amount_minor_units
+    CHECK (amount = round(amount, currency_exponent(currency))) NOT VALID;
+ALTER TABLE payments ADD CONSTRAINT payments_fee_minor_units
+    CHECK (fee = round(fee, currency_exponent(currency))) NOT VALID;
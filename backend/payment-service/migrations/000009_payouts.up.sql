@@ -0,0 +1,41 @@
+CREATE TABLE payout_batches (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id uuid NOT NULL,
+    settlement_account_id uuid NOT NULL,
+    currency char(3) NOT NULL,
+    payout_count integer NOT NULL,
+    failed_count integer NOT NULL,
+    gross_amount numeric(19,4) NOT NULL,
+    fee_amount numeric(19,4) NOT NULL,
+    net_amount numeric(19,4) NOT NULL,
+    status varchar(20) NOT NULL,
+    ledger_entry_id uuid,
+    error text,
+    settled_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_payout_batches_merchant ON payout_batches (merchant_id);
+
+CREATE TABLE payouts (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    merchant_id uuid NOT NULL,
+    destination_account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency char(3) NOT NULL,
+    fee numeric(19,4) NOT NULL DEFAULT 0,
+    fee_schedule_id uuid,
+    reference varchar(140),
+    status varchar(20) NOT NULL,
+    batch_id uuid REFERENCES payout_batches (id),
+    error text,
+    settled_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    -- Like payments, payout amounts and fees are whole minor units
+    CONSTRAINT payouts_amount_minor_units CHECK (amount = round(amount, currency_exponent(currency))),
+    CONSTRAINT payouts_fee_minor_units CHECK (fee = round(fee, currency_exponent(currency)))
+);
+CREATE INDEX idx_payouts_merchant ON payouts (merchant_id);
+CREATE INDEX idx_payouts_status ON payouts (status);
+CREATE INDEX idx_payouts_batch ON payouts (batch_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_queued_standard;
+ALTER TABLE payments DROP COLUMN IF EXISTS estimated_arrival;
+ALTER TABLE payments DROP COLUMN IF EXISTS rail;
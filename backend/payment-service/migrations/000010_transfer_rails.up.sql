@@ -0,0 +1,5 @@
+-- User transfers choose a rail: instant ones are posted while the user waits,
+-- standard ones wait for the next standard batch
+ALTER TABLE payments ADD COLUMN rail varchar(10);
+ALTER TABLE payments ADD COLUMN estimated_arrival timestamptz;
+CREATE INDEX idx_payments_queued_standard ON payments (created_at) WHERE rail = 'STANDARD' AND status = 'PENDING';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_incoming_credits_created_at;
+DROP INDEX IF EXISTS idx_external_transfers_settled_at;
+DROP TABLE IF EXISTS settlement_exceptions;
+DROP TABLE IF EXISTS settlement_reports;
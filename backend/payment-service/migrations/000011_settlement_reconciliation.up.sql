@@ -0,0 +1,44 @@
+CREATE TABLE settlement_reports (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    connector varchar(50) NOT NULL,
+    report_date date NOT NULL,
+    format varchar(10) NOT NULL,
+    file_sha256 char(64) NOT NULL,
+    entry_count integer NOT NULL,
+    matched_count integer NOT NULL,
+    exception_count integer NOT NULL,
+    uploaded_by uuid,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_settlement_reports_connector_date ON settlement_reports (connector, report_date);
+
+CREATE TABLE settlement_exceptions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    report_id uuid NOT NULL REFERENCES settlement_reports (id),
+    connector varchar(50) NOT NULL,
+    type varchar(30) NOT NULL,
+    direction varchar(10) NOT NULL,
+    external_id varchar(100),
+    external_transfer_id uuid,
+    incoming_credit_id uuid,
+    reported_amount numeric(19,4),
+    reported_currency varchar(3),
+    reported_status varchar(20),
+    internal_amount numeric(19,4),
+    internal_currency varchar(3),
+    internal_status varchar(20),
+    details text,
+    status varchar(20) NOT NULL,
+    resolution varchar(20),
+    resolution_note text,
+    resolved_by uuid,
+    resolved_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_settlement_exceptions_report_id ON settlement_exceptions (report_id);
+CREATE INDEX idx_settlement_exceptions_status ON settlement_exceptions (status);
+
+-- The reconciliation looks up what each connector settled or received in a day
+CREATE INDEX idx_external_transfers_settled_at ON external_transfers (settled_at);
+CREATE INDEX idx_incoming_credits_created_at ON incoming_credits (created_at);
@@ -0,0 +1,9 @@
+CREATE TABLE transfer_transitions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id uuid NOT NULL,
+    state varchar(20) NOT NULL,
+    actor varchar(64) NOT NULL,
+    detail text,
+    created_at timestamptz
+);
+CREATE INDEX idx_transfer_transitions_payment_id ON transfer_transitions (payment_id, created_at);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS payment_approvals;
+DROP INDEX IF EXISTS idx_payments_awaiting_approval;
+DROP INDEX IF EXISTS idx_payments_org_id;
+ALTER TABLE payments DROP COLUMN IF EXISTS approval_expires_at;
+ALTER TABLE payments DROP COLUMN IF EXISTS approvals_required;
+ALTER TABLE payments DROP COLUMN IF EXISTS org_id;
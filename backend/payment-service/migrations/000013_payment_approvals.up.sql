@@ -0,0 +1,19 @@
+-- Transfers from business accounts above the approval threshold wait in
+-- AWAITING_APPROVAL until enough members of the organization approve them
+ALTER TABLE payments ADD COLUMN org_id uuid;
+ALTER TABLE payments ADD COLUMN approvals_required integer NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN approval_expires_at timestamptz;
+CREATE INDEX idx_payments_org_id ON payments (org_id);
+CREATE INDEX idx_payments_awaiting_approval ON payments (approval_expires_at) WHERE status = 'AWAITING_APPROVAL';
+
+CREATE TABLE payment_approvals (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id uuid NOT NULL,
+    approver_id uuid NOT NULL,
+    org_role varchar(20) NOT NULL,
+    decision varchar(10) NOT NULL,
+    comment text,
+    created_at timestamptz
+);
+-- An approver decides once on a payment, so M approvals come from M people
+CREATE UNIQUE INDEX idx_payment_approvals_approver ON payment_approvals (payment_id, approver_id);
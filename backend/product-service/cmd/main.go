@@ -2,18 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 
+	apispec "github.com/femi-lawal/new_bank/backend/product-service/api"
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/handler"
-	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/repository"
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/product-service/migrations"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
 	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
 )
@@ -21,10 +26,35 @@ import (
 const serviceName = "product-service"
 
 func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema
+	command, args, err := db.ParseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
 	// Initialize Logger
 	logger.InitLogger(serviceName, true)
 	slog.Info("Starting Product Service")
 
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+
 	// Initialize Tracing
 	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
 	if err != nil {
@@ -49,9 +79,23 @@ func main() {
 		panic(err)
 	}
 
-	// Auto Migrate
-	if err := database.AutoMigrate(&model.Product{}); err != nil {
-		slog.Error("Failed to migrate database", "error", err)
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
 	}
 
 	// Wiring
@@ -59,8 +103,17 @@ func main() {
 	svc := service.NewProductService(repo)
 	h := handler.NewProductHandler(svc)
 
-	// Get JWT secret
-	jwtSecret := requireEnv("JWT_SECRET")
+	// Approved loans get an account in the ledger
+	ledgerURL := getEnv("LEDGER_SERVICE_URL", "http://localhost:8082")
+	ledgerClient := service.NewLedgerClient(ledgerURL)
+	loanSvc := service.NewLoanService(repository.NewLoanRepository(database), repo, ledgerClient)
+	// New loans are priced from the product version in effect when they are applied for
+	loanSvc.Versions = repo
+	loanHandler := handler.NewLoanHandler(loanSvc)
+
+	// Rate and term changes are scheduled as product versions; accounts opened
+	// before a version keep theirs unless it applies to existing accounts
+	versionHandler := handler.NewProductVersionHandler(service.NewProductVersionService(repo, ledgerClient))
 
 	// Setup Router
 	r := gin.Default()
@@ -72,36 +125,58 @@ func main() {
 	r.Use(middleware.RequestLogger(serviceName))
 	r.Use(middleware.Tracing(serviceName))
 	r.Use(middleware.CORS())
-	r.Use(middleware.RateLimit())
+	r.Use(middleware.RateLimitWithPolicies(cfg.RateLimitPolicies()))
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
 	r.Use(metrics.PrometheusMiddleware(serviceName))
 
+	// Readiness needs the database with its migrations applied
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	registerRoutes(r, h, loanHandler, versionHandler, cfg.JWTAuth(), healthChecks)
+
+	port := getEnv("PORT", "8084")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
+
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes.
+func registerRoutes(r *gin.Engine, h *handler.ProductHandler, loanHandler *handler.LoanHandler, versionHandler *handler.ProductVersionHandler, jwtAuth middleware.JWTAuthConfig, healthChecks *health.Handler) {
 	// ============================================
 	// Public endpoints
 	// ============================================
 	r.GET("/metrics", metrics.MetricsHandler())
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": serviceName,
-		})
-	})
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
 
 	// Products can be viewed without auth, but apply/create requires auth
 	r.GET("/api/v1/products", h.ListProducts)
+	r.GET("/api/v1/products/:code/versions", versionHandler.ListVersions)
 
 	// ============================================
 	// Protected endpoints
 	// ============================================
 	api := r.Group("/api/v1")
-	api.Use(middleware.JWTAuth(jwtSecret))
+	api.Use(middleware.JWTAuthWithConfig(jwtAuth))
+	// Users who have not accepted the current mandatory terms can only read
+	api.Use(middleware.RequireTermsAccepted())
 	{
 		api.POST("/products", h.CreateProduct)
-	}
-
-	port := getEnv("PORT", "8084")
-	slog.Info("Server listening", "port", port)
-	if err := r.Run(":" + port); err != nil {
-		slog.Error("Failed to start server", "error", err)
+		api.POST("/products/:code/versions", middleware.RequireRole("admin"), versionHandler.ScheduleVersion)
+		api.DELETE("/products/:code/versions/:version", middleware.RequireRole("admin"), versionHandler.CancelVersion)
+		api.GET("/accounts/:id/product-version", versionHandler.ResolveForAccount)
+
+		api.POST("/loans", loanHandler.Apply)
+		api.GET("/loans/:id/schedule", loanHandler.GetSchedule)
+		api.POST("/loans/:id/repayments", loanHandler.Repay)
+		api.POST("/loans/:id/approve", middleware.RequireRole("admin"), loanHandler.Approve)
+		api.POST("/loans/:id/reject", middleware.RequireRole("admin"), loanHandler.Reject)
 	}
 }
 
@@ -111,12 +186,3 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
-
-// requireEnv returns the value of an environment variable or panics if not set.
-func requireEnv(key string) string {
-	if value, exists := os.LookupEnv(key); exists && value != "" {
-		return value
-	}
-	slog.Error("Required environment variable not set", "key", key)
-	panic("Required environment variable " + key + " is not set")
-}
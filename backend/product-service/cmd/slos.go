@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the product service, reported at
+// /slo-status
+var serviceSLOs = []metrics.SLO{
+	{Name: "list-products", Method: http.MethodGet, Route: "/api/v1/products", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "loan-application", Method: http.MethodPost, Route: "/api/v1/loans", Threshold: 500 * time.Millisecond, Objective: 0.99},
+	{Name: "loan-schedule", Method: http.MethodGet, Route: "/api/v1/loans/:id/schedule", Threshold: 250 * time.Millisecond, Objective: 0.99},
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type LoanHandler struct {
+	Service *service.LoanService
+}
+
+func NewLoanHandler(s *service.LoanService) *LoanHandler {
+	return &LoanHandler{Service: s}
+}
+
+type LoanApplicationRequest struct {
+	ProductCode string `json:"product_code" binding:"required"`
+	Principal   string `json:"principal" binding:"required"`
+	TermMonths  int    `json:"term_months" binding:"required"`
+}
+
+type RepaymentRequest struct {
+	Amount string `json:"amount" binding:"required"`
+}
+
+// Apply submits a loan application for the authenticated user
+func (h *LoanHandler) Apply(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req LoanApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	loan, err := h.Service.Apply(userID, req.ProductCode, req.Principal, req.TermMonths)
+	if err != nil {
+		respondLoanError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, loan)
+}
+
+// Approve activates a pending loan, opening its ledger account and generating its schedule
+func (h *LoanHandler) Approve(c *gin.Context) {
+	schedule, err := h.Service.Approve(c.Param("id"), middleware.GetUserID(c), c.GetHeader("Authorization"))
+	if err != nil {
+		respondLoanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Reject declines a pending loan application
+func (h *LoanHandler) Reject(c *gin.Context) {
+	loan, err := h.Service.Reject(c.Param("id"), middleware.GetUserID(c))
+	if err != nil {
+		respondLoanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, loan)
+}
+
+// GetSchedule returns a loan's amortization schedule and what is still owed
+func (h *LoanHandler) GetSchedule(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	claims := middleware.GetClaims(c)
+	isAdmin := claims != nil && claims.Role == "admin"
+
+	schedule, err := h.Service.GetSchedule(userID, c.Param("id"), isAdmin)
+	if err != nil {
+		respondLoanError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// Repay applies a payment to the loan, interest first and then principal
+func (h *LoanHandler) Repay(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req RepaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repayment, err := h.Service.Repay(userID, c.Param("id"), req.Amount)
+	if err != nil {
+		respondLoanError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, repayment)
+}
+
+// respondLoanError maps loan service errors to HTTP statuses
+func respondLoanError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrProductNotFound), errors.Is(err, service.ErrLoanNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrNotLoanProduct),
+		errors.Is(err, service.ErrPrincipalOutOfRange),
+		errors.Is(err, service.ErrTermOutOfRange),
+		errors.Is(err, service.ErrInvalidAmount),
+		errors.Is(err, service.ErrRepaymentExceedsBalance):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrLoanNotPending), errors.Is(err, service.ErrLoanNotActive):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrLedgerUnavailable):
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
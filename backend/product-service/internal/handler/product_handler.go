@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
@@ -22,6 +23,12 @@ type CreateProductRequest struct {
 	Type         string `json:"type" binding:"required"`
 	InterestRate string `json:"interest_rate" binding:"required"` // e.g. "0.05"
 	Currency     string `json:"currency" binding:"required,len=3"`
+
+	// Required for LOAN products; interest_rate is then the APR
+	MinPrincipal  string `json:"min_principal"`
+	MaxPrincipal  string `json:"max_principal"`
+	MinTermMonths int    `json:"min_term_months"`
+	MaxTermMonths int    `json:"max_term_months"`
 }
 
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
@@ -31,7 +38,21 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	p, err := h.Service.CreateProduct(req.Code, req.Name, model.ProductType(req.Type), req.InterestRate, req.Currency)
+	var terms *service.LoanTerms
+	if model.ProductType(req.Type) == model.Loan {
+		terms = &service.LoanTerms{
+			MinPrincipal:  req.MinPrincipal,
+			MaxPrincipal:  req.MaxPrincipal,
+			MinTermMonths: req.MinTermMonths,
+			MaxTermMonths: req.MaxTermMonths,
+		}
+	}
+
+	p, err := h.Service.CreateProduct(req.Code, req.Name, model.ProductType(req.Type), req.InterestRate, req.Currency, terms)
+	if errors.Is(err, service.ErrInvalidLoanTerms) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
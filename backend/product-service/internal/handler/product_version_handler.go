@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type ProductVersionHandler struct {
+	Service *service.ProductVersionService
+}
+
+func NewProductVersionHandler(s *service.ProductVersionService) *ProductVersionHandler {
+	return &ProductVersionHandler{Service: s}
+}
+
+type ScheduleVersionRequest struct {
+	EffectiveFrom     time.Time       `json:"effective_from" binding:"required"`
+	AppliesToExisting bool            `json:"applies_to_existing"`
+	InterestRate      string          `json:"interest_rate" binding:"required"`
+	Metadata          json.RawMessage `json:"metadata"` // omitted keeps the latest version's
+
+	// LOAN products only; omitted keeps the latest version's limits
+	MinPrincipal  string `json:"min_principal"`
+	MaxPrincipal  string `json:"max_principal"`
+	MinTermMonths int    `json:"min_term_months"`
+	MaxTermMonths int    `json:"max_term_months"`
+}
+
+// ListVersions returns a product's versions, including scheduled ones
+func (h *ProductVersionHandler) ListVersions(c *gin.Context) {
+	versions, err := h.Service.ListVersions(c.Param("code"))
+	if err != nil {
+		respondVersionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// ScheduleVersion schedules a new version of a product
+func (h *ProductVersionHandler) ScheduleVersion(c *gin.Context) {
+	var req ScheduleVersionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	terms := service.VersionTerms{
+		EffectiveFrom:     req.EffectiveFrom,
+		AppliesToExisting: req.AppliesToExisting,
+		InterestRate:      req.InterestRate,
+	}
+	if len(req.Metadata) > 0 && string(req.Metadata) != "null" {
+		metadata := string(req.Metadata)
+		terms.Metadata = &metadata
+	}
+	if req.MinPrincipal != "" || req.MaxPrincipal != "" || req.MinTermMonths != 0 || req.MaxTermMonths != 0 {
+		terms.Loan = &service.LoanTerms{
+			MinPrincipal:  req.MinPrincipal,
+			MaxPrincipal:  req.MaxPrincipal,
+			MinTermMonths: req.MinTermMonths,
+			MaxTermMonths: req.MaxTermMonths,
+		}
+	}
+
+	v, err := h.Service.ScheduleVersion(c.Param("code"), middleware.GetUserID(c), terms)
+	if err != nil {
+		respondVersionError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, v)
+}
+
+// CancelVersion removes a version that has not taken effect yet
+func (h *ProductVersionHandler) CancelVersion(c *gin.Context) {
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a number"})
+		return
+	}
+	if err := h.Service.CancelVersion(c.Param("code"), version); err != nil {
+		respondVersionError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ResolveForAccount returns the version of its product that applies to an
+// account as of the as_of query parameter, a date or RFC 3339 time that
+// defaults to now
+func (h *ProductVersionHandler) ResolveForAccount(c *gin.Context) {
+	asOf := time.Now()
+	if value := c.Query("as_of"); value != "" {
+		parsed, err := parseAsOf(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be a date (2006-01-02) or an RFC 3339 time"})
+			return
+		}
+		asOf = parsed
+	}
+
+	resolved, err := h.Service.ResolveForAccount(c.GetHeader("Authorization"), c.Param("id"), asOf)
+	if err != nil {
+		respondVersionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resolved)
+}
+
+// parseAsOf reads an RFC 3339 time, or a date meaning its start in UTC
+func parseAsOf(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// respondVersionError maps product version errors to HTTP statuses
+func respondVersionError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrProductNotFound),
+		errors.Is(err, service.ErrVersionNotFound),
+		errors.Is(err, service.ErrAccountNotFound),
+		errors.Is(err, service.ErrNoVersionInEffect):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidInterestRate),
+		errors.Is(err, service.ErrEffectiveFromTooSoon),
+		errors.Is(err, service.ErrInvalidLoanTerms):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrAccountHasNoProduct):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrAccountLookupFailed):
+		status = http.StatusBadGateway
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
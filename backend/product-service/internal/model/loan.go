@@ -0,0 +1,75 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type LoanStatus string
+
+const (
+	LoanPendingApproval LoanStatus = "PENDING_APPROVAL"
+	LoanRejected        LoanStatus = "REJECTED"
+	LoanActive          LoanStatus = "ACTIVE"
+	LoanPaidOff         LoanStatus = "PAID_OFF"
+)
+
+// LoanAgreement is an application for a LOAN product that becomes an active loan
+// once approved. APR and currency are copied from the product so later product
+// changes do not alter it.
+type LoanAgreement struct {
+	ID                   uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID               uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
+	ProductID            uuid.UUID       `gorm:"type:uuid;not null" json:"product_id"`
+	Principal            decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"principal"`
+	APR                  decimal.Decimal `gorm:"type:numeric(5,4);not null" json:"apr"`
+	TermMonths           int             `gorm:"not null" json:"term_months"`
+	CurrencyCode         string          `gorm:"type:char(3);not null" json:"currency"`
+	Status               LoanStatus      `gorm:"type:varchar(20);not null" json:"status"`
+	OutstandingPrincipal decimal.Decimal `gorm:"type:numeric(19,4);not null;default:0" json:"outstanding_principal"`
+	LedgerAccountID      *uuid.UUID      `gorm:"type:uuid" json:"ledger_account_id,omitempty"`
+	DecidedBy            *uuid.UUID      `gorm:"type:uuid" json:"decided_by,omitempty"`
+	DecidedAt            *time.Time      `json:"decided_at,omitempty"`
+	CreatedAt            time.Time       `json:"created_at"`
+	UpdatedAt            time.Time       `json:"updated_at"`
+}
+
+func (LoanAgreement) TableName() string {
+	return "loans"
+}
+
+type InstallmentStatus string
+
+const (
+	InstallmentDue     InstallmentStatus = "DUE"
+	InstallmentPartial InstallmentStatus = "PARTIAL"
+	InstallmentPaid    InstallmentStatus = "PAID"
+)
+
+// LoanInstallment is one row of a loan's amortization schedule
+type LoanInstallment struct {
+	ID            uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	LoanID        uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_loan_installments_loan_number" json:"loan_id"`
+	Number        int               `gorm:"not null;uniqueIndex:idx_loan_installments_loan_number" json:"number"`
+	DueDate       time.Time         `gorm:"type:date;not null" json:"due_date"`
+	Payment       decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"payment"`
+	Principal     decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"principal"`
+	Interest      decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"interest"`
+	PrincipalPaid decimal.Decimal   `gorm:"type:numeric(19,4);not null;default:0" json:"principal_paid"`
+	InterestPaid  decimal.Decimal   `gorm:"type:numeric(19,4);not null;default:0" json:"interest_paid"`
+	Balance       decimal.Decimal   `gorm:"type:numeric(19,4);not null" json:"balance"` // principal left after this installment
+	Status        InstallmentStatus `gorm:"type:varchar(20);not null" json:"status"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// LoanRepayment records a payment against a loan and how it was allocated
+type LoanRepayment struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	LoanID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"loan_id"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	InterestPaid  decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"interest_paid"`
+	PrincipalPaid decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"principal_paid"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
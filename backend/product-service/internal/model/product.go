@@ -24,7 +24,33 @@ type Product struct {
 	InterestRate decimal.Decimal `gorm:"type:numeric(5,4);default:0"` // e.g. 0.0500 for 5%
 	CurrencyCode string          `gorm:"type:char(3);not null"`
 	Metadata     *string         `gorm:"type:jsonb"`
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	// Loan terms, only set for LOAN products; InterestRate is the APR
+	MinPrincipal  decimal.Decimal `gorm:"type:numeric(19,4);default:0"`
+	MaxPrincipal  decimal.Decimal `gorm:"type:numeric(19,4);default:0"`
+	MinTermMonths int             `gorm:"default:0"`
+	MaxTermMonths int             `gorm:"default:0"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+// ProductVersion is a product's rate and terms from EffectiveFrom on. Version
+// 1 is the product as created; later versions are scheduled ahead of time so
+// rates and fees change without editing the product under its customers.
+type ProductVersion struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ProductID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_product_versions_product_version" json:"product_id"`
+	Version       int       `gorm:"not null;uniqueIndex:idx_product_versions_product_version" json:"version"`
+	EffectiveFrom time.Time `gorm:"not null" json:"effective_from"`
+	// AppliesToExisting moves accounts opened before EffectiveFrom onto this
+	// version as well; otherwise they keep the version they were opened on
+	AppliesToExisting bool            `gorm:"not null;default:false" json:"applies_to_existing"`
+	InterestRate      decimal.Decimal `gorm:"type:numeric(5,4);not null;default:0" json:"interest_rate"`
+	Metadata          *string         `gorm:"type:jsonb" json:"metadata,omitempty"` // e.g. fees
+	MinPrincipal      decimal.Decimal `gorm:"type:numeric(19,4);default:0" json:"min_principal"`
+	MaxPrincipal      decimal.Decimal `gorm:"type:numeric(19,4);default:0" json:"max_principal"`
+	MinTermMonths     int             `gorm:"default:0" json:"min_term_months"`
+	MaxTermMonths     int             `gorm:"default:0" json:"max_term_months"`
+	CreatedBy         *uuid.UUID      `gorm:"type:uuid" json:"created_by,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
 }
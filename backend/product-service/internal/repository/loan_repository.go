@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoanRepository struct {
+	DB *gorm.DB
+}
+
+func NewLoanRepository(db *gorm.DB) *LoanRepository {
+	return &LoanRepository{DB: db}
+}
+
+func (r *LoanRepository) CreateLoan(loan *model.LoanAgreement) error {
+	return r.DB.Create(loan).Error
+}
+
+func (r *LoanRepository) GetLoan(id string) (*model.LoanAgreement, error) {
+	var loan model.LoanAgreement
+	if err := r.DB.Where("id = ?", id).First(&loan).Error; err != nil {
+		return nil, err
+	}
+	return &loan, nil
+}
+
+func (r *LoanRepository) ListInstallments(loanID string) ([]model.LoanInstallment, error) {
+	var installments []model.LoanInstallment
+	if err := r.DB.Where("loan_id = ?", loanID).Order("number").Find(&installments).Error; err != nil {
+		return nil, err
+	}
+	return installments, nil
+}
+
+// ActivateLoan moves a pending loan to ACTIVE and stores its schedule in one transaction.
+// Returns false if the loan was no longer pending.
+func (r *LoanRepository) ActivateLoan(loan *model.LoanAgreement, installments []model.LoanInstallment) (bool, error) {
+	activated := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.LoanAgreement{}).
+			Where("id = ? AND status = ?", loan.ID, model.LoanPendingApproval).
+			Updates(map[string]interface{}{
+				"status":                model.LoanActive,
+				"outstanding_principal": loan.OutstandingPrincipal,
+				"ledger_account_id":     loan.LedgerAccountID,
+				"decided_by":            loan.DecidedBy,
+				"decided_at":            loan.DecidedAt,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		activated = true
+		return tx.Create(&installments).Error
+	})
+	return activated, err
+}
+
+// RejectLoan marks a pending loan as rejected. Returns false if it was no longer pending.
+func (r *LoanRepository) RejectLoan(id string, decidedBy uuid.UUID, decidedAt time.Time) (bool, error) {
+	res := r.DB.Model(&model.LoanAgreement{}).
+		Where("id = ? AND status = ?", id, model.LoanPendingApproval).
+		Updates(map[string]interface{}{
+			"status":     model.LoanRejected,
+			"decided_by": decidedBy,
+			"decided_at": decidedAt,
+		})
+	return res.RowsAffected > 0, res.Error
+}
+
+// ApplyRepayment locks the loan, hands it and its schedule to allocate, and saves
+// the repayment, the installments it changed and the loan in one transaction.
+func (r *LoanRepository) ApplyRepayment(loanID string, allocate func(loan *model.LoanAgreement, installments []model.LoanInstallment) (*model.LoanRepayment, []model.LoanInstallment, error)) (*model.LoanRepayment, error) {
+	var repayment *model.LoanRepayment
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var loan model.LoanAgreement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", loanID).First(&loan).Error; err != nil {
+			return err
+		}
+		var installments []model.LoanInstallment
+		if err := tx.Where("loan_id = ?", loanID).Order("number").Find(&installments).Error; err != nil {
+			return err
+		}
+
+		rep, changed, err := allocate(&loan, installments)
+		if err != nil {
+			return err
+		}
+		for i := range changed {
+			if err := tx.Save(&changed[i]).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&loan).Updates(map[string]interface{}{
+			"status":                loan.Status,
+			"outstanding_principal": loan.OutstandingPrincipal,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(rep).Error; err != nil {
+			return err
+		}
+		repayment = rep
+		return nil
+	})
+	return repayment, err
+}
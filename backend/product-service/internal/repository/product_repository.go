@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -13,8 +16,16 @@ func NewProductRepository(db *gorm.DB) *ProductRepository {
 	return &ProductRepository{DB: db}
 }
 
-func (r *ProductRepository) CreateProduct(p *model.Product) error {
-	return r.DB.Create(p).Error
+// CreateProduct stores a product together with its first version, v
+func (r *ProductRepository) CreateProduct(p *model.Product, v *model.ProductVersion) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		v.ProductID = p.ID
+		v.EffectiveFrom = p.CreatedAt
+		return tx.Create(v).Error
+	})
 }
 
 func (r *ProductRepository) ListProducts() ([]model.Product, error) {
@@ -32,3 +43,26 @@ func (r *ProductRepository) GetProductByCode(code string) (*model.Product, error
 	}
 	return &p, nil
 }
+
+// ListVersions returns a product's versions, oldest first
+func (r *ProductRepository) ListVersions(productID uuid.UUID) ([]model.ProductVersion, error) {
+	var versions []model.ProductVersion
+	if err := r.DB.Where("product_id = ?", productID).Order("version").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// CreateVersion stores a scheduled version. The unique index on product and
+// version number rejects a version scheduled concurrently with the same number.
+func (r *ProductRepository) CreateVersion(v *model.ProductVersion) error {
+	return r.DB.Create(v).Error
+}
+
+// DeleteVersion removes a version that takes effect after notBefore. Returns
+// false if there is no such version.
+func (r *ProductRepository) DeleteVersion(productID uuid.UUID, version int, notBefore time.Time) (bool, error) {
+	res := r.DB.Where("product_id = ? AND version = ? AND effective_from > ?", productID, version, notBefore).
+		Delete(&model.ProductVersion{})
+	return res.RowsAffected == 1, res.Error
+}
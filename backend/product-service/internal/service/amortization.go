@@ -0,0 +1,110 @@
+package service
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/shopspring/decimal"
+)
+
+var monthsPerYear = decimal.NewFromInt(12)
+
+// MonthlyPayment is the level payment that repays principal over termMonths at
+// the given APR, compounded monthly, rounded to cents
+func MonthlyPayment(principal, apr decimal.Decimal, termMonths int) decimal.Decimal {
+	n := decimal.NewFromInt(int64(termMonths))
+	if apr.IsZero() {
+		return principal.Div(n).Round(2)
+	}
+	rate := apr.Div(monthsPerYear)
+	growth := decimal.NewFromInt(1).Add(rate).Pow(n)
+	return principal.Mul(rate).Mul(growth).Div(growth.Sub(decimal.NewFromInt(1))).Round(2)
+}
+
+// BuildSchedule splits a loan into monthly installments due from one month after
+// start. Interest is charged on the remaining principal each month and the final
+// installment absorbs the rounding so the principal is repaid exactly.
+func BuildSchedule(loan *model.LoanAgreement, start time.Time) []model.LoanInstallment {
+	payment := MonthlyPayment(loan.Principal, loan.APR, loan.TermMonths)
+	rate := loan.APR.Div(monthsPerYear)
+	balance := loan.Principal
+
+	installments := make([]model.LoanInstallment, loan.TermMonths)
+	for i := range installments {
+		interest := balance.Mul(rate).Round(2)
+		principal := payment.Sub(interest)
+		if i == len(installments)-1 || principal.GreaterThan(balance) {
+			principal = balance
+		}
+		balance = balance.Sub(principal)
+
+		installments[i] = model.LoanInstallment{
+			LoanID:        loan.ID,
+			Number:        i + 1,
+			DueDate:       addMonths(start, i+1),
+			Payment:       principal.Add(interest),
+			Principal:     principal,
+			Interest:      interest,
+			PrincipalPaid: decimal.Zero,
+			InterestPaid:  decimal.Zero,
+			Balance:       balance,
+			Status:        model.InstallmentDue,
+		}
+	}
+	return installments
+}
+
+// AllocateRepayment applies amount to installments in schedule order, paying each
+// installment's interest before its principal. It returns how much went to each
+// and the installments that changed; amount must not exceed what is still owed.
+func AllocateRepayment(installments []model.LoanInstallment, amount decimal.Decimal) (interestPaid, principalPaid decimal.Decimal, changed []model.LoanInstallment) {
+	remaining := amount
+	for i := range installments {
+		if remaining.IsZero() {
+			break
+		}
+		inst := &installments[i]
+		if inst.Status == model.InstallmentPaid {
+			continue
+		}
+
+		toInterest := decimal.Min(remaining, inst.Interest.Sub(inst.InterestPaid))
+		inst.InterestPaid = inst.InterestPaid.Add(toInterest)
+		remaining = remaining.Sub(toInterest)
+		interestPaid = interestPaid.Add(toInterest)
+
+		toPrincipal := decimal.Min(remaining, inst.Principal.Sub(inst.PrincipalPaid))
+		inst.PrincipalPaid = inst.PrincipalPaid.Add(toPrincipal)
+		remaining = remaining.Sub(toPrincipal)
+		principalPaid = principalPaid.Add(toPrincipal)
+
+		if inst.InterestPaid.Equal(inst.Interest) && inst.PrincipalPaid.Equal(inst.Principal) {
+			inst.Status = model.InstallmentPaid
+		} else {
+			inst.Status = model.InstallmentPartial
+		}
+		changed = append(changed, *inst)
+	}
+	return interestPaid, principalPaid, changed
+}
+
+// AmountOwed is the unpaid interest and principal left on a schedule
+func AmountOwed(installments []model.LoanInstallment) decimal.Decimal {
+	owed := decimal.Zero
+	for _, inst := range installments {
+		owed = owed.Add(inst.Payment).Sub(inst.InterestPaid).Sub(inst.PrincipalPaid)
+	}
+	return owed
+}
+
+// addMonths adds months to t, clamping to the last day of the target month so
+// a loan started on the 31st falls due on the 30th or 28th rather than rolling over
+func addMonths(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if d > lastDay {
+		d = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LedgerClient opens accounts in the ledger service on behalf of a user
+type LedgerClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+func NewLedgerClient(baseURL string) *LedgerClient {
+	return &LedgerClient{baseURL: baseURL, httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type ledgerBulkAccount struct {
+	UserID        string `json:"user_id"`
+	AccountNumber string `json:"account_number"`
+	Name          string `json:"name"`
+	Currency      string `json:"currency"`
+	Type          string `json:"type"`
+}
+
+type ledgerBulkRequest struct {
+	Reference string              `json:"reference"`
+	Accounts  []ledgerBulkAccount `json:"accounts"`
+}
+
+type ledgerBulkResponse struct {
+	Results []struct {
+		Status    string     `json:"status"`
+		AccountID *uuid.UUID `json:"account_id"`
+		Errors    []string   `json:"errors"`
+	} `json:"results"`
+}
+
+// OpenLoanAccount creates an ASSET account for the loan owned by userID. It goes
+// through the ledger's bulk provisioning endpoint, which requires an admin token
+// and is idempotent on reference, so approving the same loan again returns the
+// account created the first time.
+func (c *LedgerClient) OpenLoanAccount(authorization, reference, userID, accountNumber, name, currency string) (uuid.UUID, error) {
+	body, _ := json.Marshal(ledgerBulkRequest{
+		Reference: reference,
+		Accounts: []ledgerBulkAccount{{
+			UserID:        userID,
+			AccountNumber: accountNumber,
+			Name:          name,
+			Currency:      currency,
+			Type:          "ASSET",
+		}},
+	})
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/v1/accounts/bulk", bytes.NewBuffer(body))
+	if err != nil {
+		return uuid.Nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer resp.Body.Close()
+
+	// 200 is a replay of an earlier request with the same reference
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return uuid.Nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var batch ledgerBulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return uuid.Nil, fmt.Errorf("decode ledger response: %w", err)
+	}
+	if len(batch.Results) != 1 {
+		return uuid.Nil, fmt.Errorf("ledger returned %d results for one account", len(batch.Results))
+	}
+	result := batch.Results[0]
+	if result.Status != "CREATED" || result.AccountID == nil {
+		return uuid.Nil, fmt.Errorf("ledger rejected loan account: %s", strings.Join(result.Errors, "; "))
+	}
+	return *result.AccountID, nil
+}
+
+// LedgerAccount is the part of a ledger account product versions are resolved from
+type LedgerAccount struct {
+	ID          uuid.UUID
+	ProductCode string // "" if the account was not opened for a product
+	OpenedAt    time.Time
+}
+
+type ledgerAccountResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Metadata  *string   `json:"metadata"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetAccount reads an account from the ledger with the caller's token, so only
+// accounts the caller may see are found. Returns ErrAccountNotFound if the
+// ledger does not show it.
+func (c *LedgerClient) GetAccount(authorization, accountID string) (*LedgerAccount, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/v1/accounts/"+accountID+"/balance", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrAccountNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ledger service returned status %d", resp.StatusCode)
+	}
+
+	var acc ledgerAccountResponse
+	if err := json.NewDecoder(resp.Body).Decode(&acc); err != nil {
+		return nil, fmt.Errorf("decode ledger response: %w", err)
+	}
+	account := &LedgerAccount{ID: acc.ID, OpenedAt: acc.CreatedAt}
+	if acc.Metadata != nil {
+		var metadata struct {
+			ProductCode string `json:"product_code"`
+		}
+		if err := json.Unmarshal([]byte(*acc.Metadata), &metadata); err == nil {
+			account.ProductCode = metadata.ProductCode
+		}
+	}
+	return account, nil
+}
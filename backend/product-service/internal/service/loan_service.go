@@ -0,0 +1,269 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrProductNotFound         = errors.New("product not found")
+	ErrNotLoanProduct          = errors.New("product is not a loan product")
+	ErrPrincipalOutOfRange     = errors.New("principal is outside the product's range")
+	ErrTermOutOfRange          = errors.New("term is outside the product's range")
+	ErrLoanNotFound            = errors.New("loan not found")
+	ErrLoanNotPending          = errors.New("loan is not awaiting approval")
+	ErrLoanNotActive           = errors.New("loan is not active")
+	ErrInvalidAmount           = errors.New("amount must be a positive number with at most 2 decimal places")
+	ErrRepaymentExceedsBalance = errors.New("repayment exceeds the amount owed")
+	ErrLedgerUnavailable       = errors.New("could not open the loan account in the ledger")
+)
+
+// LoanRepository defines data access for loans and their schedules
+type LoanRepository interface {
+	CreateLoan(loan *model.LoanAgreement) error
+	GetLoan(id string) (*model.LoanAgreement, error)
+	ListInstallments(loanID string) ([]model.LoanInstallment, error)
+	ActivateLoan(loan *model.LoanAgreement, installments []model.LoanInstallment) (bool, error)
+	RejectLoan(id string, decidedBy uuid.UUID, decidedAt time.Time) (bool, error)
+	ApplyRepayment(loanID string, allocate func(loan *model.LoanAgreement, installments []model.LoanInstallment) (*model.LoanRepayment, []model.LoanInstallment, error)) (*model.LoanRepayment, error)
+}
+
+// LoanProductReader looks up the product a loan is applied for
+type LoanProductReader interface {
+	GetProductByCode(code string) (*model.Product, error)
+}
+
+// LoanAccountOpener opens the ledger account that tracks a loan's balance
+type LoanAccountOpener interface {
+	OpenLoanAccount(authorization, reference, userID, accountNumber, name, currency string) (uuid.UUID, error)
+}
+
+// LoanService handles loan applications, approval and repayments
+type LoanService struct {
+	Repo     LoanRepository
+	Products LoanProductReader
+	Ledger   LoanAccountOpener
+	// Versions, if set, prices new loans from the product version in effect
+	// instead of the product as created
+	Versions VersionLister
+	now      func() time.Time
+}
+
+func NewLoanService(repo LoanRepository, products LoanProductReader, ledger LoanAccountOpener) *LoanService {
+	return &LoanService{Repo: repo, Products: products, Ledger: ledger, now: time.Now}
+}
+
+// LoanSchedule is a loan with its amortization schedule
+type LoanSchedule struct {
+	Loan         *model.LoanAgreement    `json:"loan"`
+	Installments []model.LoanInstallment `json:"installments"`
+	AmountOwed   decimal.Decimal         `json:"amount_owed"`
+}
+
+// Apply creates a loan application for a LOAN product after checking the
+// principal and term against the product's limits
+func (s *LoanService) Apply(userID, productCode, principalStr string, termMonths int) (*model.LoanAgreement, error) {
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	principal, err := parseAmount(principalStr)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.Products.GetProductByCode(productCode)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	if product.Type != model.Loan {
+		return nil, ErrNotLoanProduct
+	}
+	if s.Versions != nil {
+		versions, err := s.Versions.ListVersions(product.ID)
+		if err != nil {
+			return nil, err
+		}
+		if v := currentVersion(versions, s.now()); v != nil {
+			current := *product
+			current.InterestRate = v.InterestRate
+			current.MinPrincipal, current.MaxPrincipal = v.MinPrincipal, v.MaxPrincipal
+			current.MinTermMonths, current.MaxTermMonths = v.MinTermMonths, v.MaxTermMonths
+			product = &current
+		}
+	}
+	if principal.LessThan(product.MinPrincipal) || principal.GreaterThan(product.MaxPrincipal) {
+		return nil, ErrPrincipalOutOfRange
+	}
+	if termMonths < product.MinTermMonths || termMonths > product.MaxTermMonths || termMonths <= 0 {
+		return nil, ErrTermOutOfRange
+	}
+
+	loan := &model.LoanAgreement{
+		ID:                   uuid.New(),
+		UserID:               userUUID,
+		ProductID:            product.ID,
+		Principal:            principal,
+		APR:                  product.InterestRate,
+		TermMonths:           termMonths,
+		CurrencyCode:         product.CurrencyCode,
+		Status:               model.LoanPendingApproval,
+		OutstandingPrincipal: decimal.Zero,
+	}
+	if err := s.Repo.CreateLoan(loan); err != nil {
+		return nil, err
+	}
+	return loan, nil
+}
+
+// Approve opens the loan's ledger account, generates its schedule starting today
+// and activates it. authorization is the approver's bearer token, forwarded to the ledger.
+func (s *LoanService) Approve(loanID, approverID, authorization string) (*LoanSchedule, error) {
+	approver, err := uuid.Parse(approverID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	loan, err := s.getLoan(loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.Status != model.LoanPendingApproval {
+		return nil, ErrLoanNotPending
+	}
+
+	accountNumber := "LN" + strings.ToUpper(strings.ReplaceAll(loan.ID.String(), "-", "")[:16])
+	accountID, err := s.Ledger.OpenLoanAccount(authorization, "loan:"+loan.ID.String(), loan.UserID.String(), accountNumber, "Loan "+accountNumber, loan.CurrencyCode)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLedgerUnavailable, err)
+	}
+
+	now := s.now().UTC()
+	loan.Status = model.LoanActive
+	loan.OutstandingPrincipal = loan.Principal
+	loan.LedgerAccountID = &accountID
+	loan.DecidedBy = &approver
+	loan.DecidedAt = &now
+	installments := BuildSchedule(loan, now)
+
+	activated, err := s.Repo.ActivateLoan(loan, installments)
+	if err != nil {
+		return nil, err
+	}
+	if !activated {
+		return nil, ErrLoanNotPending
+	}
+	return &LoanSchedule{Loan: loan, Installments: installments, AmountOwed: AmountOwed(installments)}, nil
+}
+
+// Reject declines a pending loan application
+func (s *LoanService) Reject(loanID, approverID string) (*model.LoanAgreement, error) {
+	approver, err := uuid.Parse(approverID)
+	if err != nil {
+		return nil, errors.New("invalid user id")
+	}
+	loan, err := s.getLoan(loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	rejected, err := s.Repo.RejectLoan(loanID, approver, now)
+	if err != nil {
+		return nil, err
+	}
+	if !rejected {
+		return nil, ErrLoanNotPending
+	}
+	loan.Status = model.LoanRejected
+	loan.DecidedBy = &approver
+	loan.DecidedAt = &now
+	return loan, nil
+}
+
+// GetSchedule returns a loan and its schedule. Only the borrower, or an admin
+// when isAdmin is set, can read it; anyone else gets ErrLoanNotFound.
+func (s *LoanService) GetSchedule(userID, loanID string, isAdmin bool) (*LoanSchedule, error) {
+	loan, err := s.getLoan(loanID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin && loan.UserID.String() != userID {
+		return nil, ErrLoanNotFound
+	}
+	installments, err := s.Repo.ListInstallments(loanID)
+	if err != nil {
+		return nil, err
+	}
+	return &LoanSchedule{Loan: loan, Installments: installments, AmountOwed: AmountOwed(installments)}, nil
+}
+
+// Repay applies a payment from the borrower to an active loan. The amount goes to
+// the earliest unpaid installments, interest before principal, and the loan is
+// marked PAID_OFF once nothing is owed.
+func (s *LoanService) Repay(userID, loanID, amountStr string) (*model.LoanRepayment, error) {
+	amount, err := parseAmount(amountStr)
+	if err != nil {
+		return nil, err
+	}
+	loan, err := s.getLoan(loanID)
+	if err != nil {
+		return nil, err
+	}
+	if loan.UserID.String() != userID {
+		return nil, ErrLoanNotFound
+	}
+
+	return s.Repo.ApplyRepayment(loanID, func(loan *model.LoanAgreement, installments []model.LoanInstallment) (*model.LoanRepayment, []model.LoanInstallment, error) {
+		if loan.Status != model.LoanActive {
+			return nil, nil, ErrLoanNotActive
+		}
+		if amount.GreaterThan(AmountOwed(installments)) {
+			return nil, nil, ErrRepaymentExceedsBalance
+		}
+
+		interestPaid, principalPaid, changed := AllocateRepayment(installments, amount)
+		loan.OutstandingPrincipal = loan.OutstandingPrincipal.Sub(principalPaid)
+		if AmountOwed(installments).IsZero() {
+			loan.Status = model.LoanPaidOff
+		}
+		return &model.LoanRepayment{
+			LoanID:        loan.ID,
+			Amount:        amount,
+			InterestPaid:  interestPaid,
+			PrincipalPaid: principalPaid,
+		}, changed, nil
+	})
+}
+
+func (s *LoanService) getLoan(loanID string) (*model.LoanAgreement, error) {
+	if _, err := uuid.Parse(loanID); err != nil {
+		return nil, ErrLoanNotFound
+	}
+	loan, err := s.Repo.GetLoan(loanID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrLoanNotFound
+		}
+		return nil, err
+	}
+	return loan, nil
+}
+
+// parseAmount parses a positive amount in whole cents
+func parseAmount(s string) (decimal.Decimal, error) {
+	amount, err := decimal.NewFromString(s)
+	if err != nil || !amount.IsPositive() || !amount.Equal(amount.Round(2)) {
+		return decimal.Zero, ErrInvalidAmount
+	}
+	return amount, nil
+}
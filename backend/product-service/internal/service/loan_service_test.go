@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type MockLoanRepository struct {
+	mock.Mock
+	// installments is the schedule handed to ApplyRepayment's callback
+	installments []model.LoanInstallment
+}
+
+func (m *MockLoanRepository) CreateLoan(loan *model.LoanAgreement) error {
+	return m.Called(loan).Error(0)
+}
+
+func (m *MockLoanRepository) GetLoan(id string) (*model.LoanAgreement, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LoanAgreement), args.Error(1)
+}
+
+func (m *MockLoanRepository) ListInstallments(loanID string) ([]model.LoanInstallment, error) {
+	args := m.Called(loanID)
+	return args.Get(0).([]model.LoanInstallment), args.Error(1)
+}
+
+func (m *MockLoanRepository) ActivateLoan(loan *model.LoanAgreement, installments []model.LoanInstallment) (bool, error) {
+	args := m.Called(loan, installments)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoanRepository) RejectLoan(id string, decidedBy uuid.UUID, decidedAt time.Time) (bool, error) {
+	args := m.Called(id, decidedBy, decidedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockLoanRepository) ApplyRepayment(loanID string, allocate func(loan *model.LoanAgreement, installments []model.LoanInstallment) (*model.LoanRepayment, []model.LoanInstallment, error)) (*model.LoanRepayment, error) {
+	args := m.Called(loanID)
+	loan := args.Get(0).(*model.LoanAgreement)
+	rep, _, err := allocate(loan, m.installments)
+	return rep, err
+}
+
+type MockProductReader struct {
+	mock.Mock
+}
+
+func (m *MockProductReader) GetProductByCode(code string) (*model.Product, error) {
+	args := m.Called(code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+type MockLedger struct {
+	mock.Mock
+}
+
+func (m *MockLedger) OpenLoanAccount(authorization, reference, userID, accountNumber, name, currency string) (uuid.UUID, error) {
+	args := m.Called(authorization, reference, userID, accountNumber, name, currency)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func loanProduct() *model.Product {
+	return &model.Product{
+		ID:            uuid.New(),
+		Code:          "LOAN-PERSONAL",
+		Type:          model.Loan,
+		InterestRate:  decimal.RequireFromString("0.06"),
+		CurrencyCode:  "USD",
+		MinPrincipal:  decimal.NewFromInt(1000),
+		MaxPrincipal:  decimal.NewFromInt(50000),
+		MinTermMonths: 6,
+		MaxTermMonths: 60,
+	}
+}
+
+func TestMonthlyPayment(t *testing.T) {
+	assert.Equal(t, "860.66", MonthlyPayment(decimal.NewFromInt(10000), decimal.RequireFromString("0.06"), 12).StringFixed(2))
+	assert.Equal(t, "100.00", MonthlyPayment(decimal.NewFromInt(1200), decimal.Zero, 12).StringFixed(2))
+}
+
+func TestBuildSchedule(t *testing.T) {
+	loan := &model.LoanAgreement{ID: uuid.New(), Principal: decimal.NewFromInt(10000), APR: decimal.RequireFromString("0.06"), TermMonths: 12}
+	start := time.Date(2026, time.January, 31, 15, 0, 0, 0, time.UTC)
+
+	schedule := BuildSchedule(loan, start)
+	require.Len(t, schedule, 12)
+
+	assert.Equal(t, "50.00", schedule[0].Interest.StringFixed(2))
+	assert.Equal(t, "810.66", schedule[0].Principal.StringFixed(2))
+	assert.Equal(t, time.Date(2026, time.February, 28, 0, 0, 0, 0, time.UTC), schedule[0].DueDate)
+	assert.Equal(t, time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC), schedule[1].DueDate)
+
+	total := decimal.Zero
+	for _, inst := range schedule {
+		total = total.Add(inst.Principal)
+		assert.True(t, inst.Payment.Equal(inst.Principal.Add(inst.Interest)))
+	}
+	assert.True(t, total.Equal(loan.Principal), "principal repaid exactly")
+	assert.True(t, schedule[11].Balance.IsZero())
+}
+
+func TestAllocateRepayment_InterestBeforePrincipal(t *testing.T) {
+	loan := &model.LoanAgreement{ID: uuid.New(), Principal: decimal.NewFromInt(10000), APR: decimal.RequireFromString("0.06"), TermMonths: 12}
+	schedule := BuildSchedule(loan, time.Now())
+
+	interest, principal, changed := AllocateRepayment(schedule, decimal.NewFromInt(30))
+	assert.Equal(t, "30.00", interest.StringFixed(2))
+	assert.True(t, principal.IsZero())
+	require.Len(t, changed, 1)
+	assert.Equal(t, model.InstallmentPartial, changed[0].Status)
+
+	// Finishes the first installment and starts on the second one's interest
+	interest, principal, changed = AllocateRepayment(schedule, decimal.NewFromInt(850))
+	assert.Equal(t, "39.34", interest.StringFixed(2))
+	assert.Equal(t, "810.66", principal.StringFixed(2))
+	require.Len(t, changed, 2)
+	assert.Equal(t, model.InstallmentPaid, changed[0].Status)
+	assert.Equal(t, model.InstallmentPartial, changed[1].Status)
+	assert.Equal(t, "19.34", changed[1].InterestPaid.StringFixed(2))
+	assert.True(t, changed[1].PrincipalPaid.IsZero())
+}
+
+func TestLoanService_Apply(t *testing.T) {
+	userID := uuid.New().String()
+	product := loanProduct()
+
+	tests := []struct {
+		name      string
+		principal string
+		term      int
+		product   *model.Product
+		wantErr   error
+	}{
+		{"valid application", "10000", 12, product, nil},
+		{"principal above range", "60000", 12, product, ErrPrincipalOutOfRange},
+		{"term below range", "10000", 3, product, ErrTermOutOfRange},
+		{"invalid amount", "10.001", 12, product, ErrInvalidAmount},
+		{"not a loan product", "10000", 12, &model.Product{Type: model.Savings}, ErrNotLoanProduct},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockLoanRepository)
+			products := new(MockProductReader)
+			products.On("GetProductByCode", "LOAN-PERSONAL").Return(tt.product, nil)
+			repo.On("CreateLoan", mock.Anything).Return(nil)
+			svc := NewLoanService(repo, products, new(MockLedger))
+
+			loan, err := svc.Apply(userID, "LOAN-PERSONAL", tt.principal, tt.term)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				repo.AssertNotCalled(t, "CreateLoan", mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, model.LoanPendingApproval, loan.Status)
+			assert.True(t, loan.APR.Equal(product.InterestRate))
+			assert.Equal(t, "USD", loan.CurrencyCode)
+		})
+	}
+}
+
+func TestLoanService_Apply_UnknownProduct(t *testing.T) {
+	products := new(MockProductReader)
+	products.On("GetProductByCode", "NOPE").Return(nil, gorm.ErrRecordNotFound)
+	svc := NewLoanService(new(MockLoanRepository), products, new(MockLedger))
+
+	_, err := svc.Apply(uuid.New().String(), "NOPE", "1000", 12)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestLoanService_Approve(t *testing.T) {
+	loan := &model.LoanAgreement{
+		ID: uuid.New(), UserID: uuid.New(), Principal: decimal.NewFromInt(10000),
+		APR: decimal.RequireFromString("0.06"), TermMonths: 12, CurrencyCode: "USD", Status: model.LoanPendingApproval,
+	}
+	accountID := uuid.New()
+	approver := uuid.New()
+
+	repo := new(MockLoanRepository)
+	ledger := new(MockLedger)
+	repo.On("GetLoan", loan.ID.String()).Return(loan, nil)
+	ledger.On("OpenLoanAccount", "Bearer admin", "loan:"+loan.ID.String(), loan.UserID.String(), mock.AnythingOfType("string"), mock.AnythingOfType("string"), "USD").Return(accountID, nil)
+	repo.On("ActivateLoan", loan, mock.MatchedBy(func(installments []model.LoanInstallment) bool {
+		return len(installments) == 12
+	})).Return(true, nil)
+
+	svc := NewLoanService(repo, new(MockProductReader), ledger)
+	schedule, err := svc.Approve(loan.ID.String(), approver.String(), "Bearer admin")
+	require.NoError(t, err)
+
+	assert.Equal(t, model.LoanActive, schedule.Loan.Status)
+	assert.Equal(t, accountID, *schedule.Loan.LedgerAccountID)
+	assert.True(t, schedule.Loan.OutstandingPrincipal.Equal(loan.Principal))
+	assert.Len(t, schedule.Installments, 12)
+}
+
+func TestLoanService_Approve_LedgerFailure(t *testing.T) {
+	loan := &model.LoanAgreement{ID: uuid.New(), UserID: uuid.New(), CurrencyCode: "USD", Status: model.LoanPendingApproval}
+
+	repo := new(MockLoanRepository)
+	ledger := new(MockLedger)
+	repo.On("GetLoan", loan.ID.String()).Return(loan, nil)
+	ledger.On("OpenLoanAccount", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(uuid.Nil, errors.New("connection refused"))
+
+	svc := NewLoanService(repo, new(MockProductReader), ledger)
+	_, err := svc.Approve(loan.ID.String(), uuid.New().String(), "Bearer admin")
+	assert.ErrorIs(t, err, ErrLedgerUnavailable)
+	repo.AssertNotCalled(t, "ActivateLoan", mock.Anything, mock.Anything)
+}
+
+func TestLoanService_Approve_NotPending(t *testing.T) {
+	loan := &model.LoanAgreement{ID: uuid.New(), Status: model.LoanActive}
+	repo := new(MockLoanRepository)
+	repo.On("GetLoan", loan.ID.String()).Return(loan, nil)
+
+	svc := NewLoanService(repo, new(MockProductReader), new(MockLedger))
+	_, err := svc.Approve(loan.ID.String(), uuid.New().String(), "")
+	assert.ErrorIs(t, err, ErrLoanNotPending)
+}
+
+func TestLoanService_Repay(t *testing.T) {
+	loan := &model.LoanAgreement{
+		ID: uuid.New(), UserID: uuid.New(), Principal: decimal.NewFromInt(1000),
+		APR: decimal.RequireFromString("0.12"), TermMonths: 2, Status: model.LoanActive,
+	}
+	loan.OutstandingPrincipal = loan.Principal
+
+	repo := new(MockLoanRepository)
+	repo.installments = BuildSchedule(loan, time.Now())
+	repo.On("GetLoan", loan.ID.String()).Return(loan, nil)
+	repo.On("ApplyRepayment", loan.ID.String()).Return(loan)
+	svc := NewLoanService(repo, new(MockProductReader), new(MockLedger))
+
+	_, err := svc.Repay(loan.UserID.String(), loan.ID.String(), "5000")
+	assert.ErrorIs(t, err, ErrRepaymentExceedsBalance)
+
+	_, err = svc.Repay(uuid.New().String(), loan.ID.String(), "10")
+	assert.ErrorIs(t, err, ErrLoanNotFound)
+
+	owed := AmountOwed(repo.installments)
+	rep, err := svc.Repay(loan.UserID.String(), loan.ID.String(), owed.String())
+	require.NoError(t, err)
+	assert.True(t, rep.PrincipalPaid.Equal(loan.Principal))
+	assert.True(t, rep.InterestPaid.Add(rep.PrincipalPaid).Equal(owed))
+	assert.True(t, loan.OutstandingPrincipal.IsZero())
+	assert.Equal(t, model.LoanPaidOff, loan.Status)
+
+	_, err = svc.Repay(loan.UserID.String(), loan.ID.String(), "1")
+	assert.ErrorIs(t, err, ErrLoanNotActive)
+}
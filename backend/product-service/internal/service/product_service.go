@@ -1,6 +1,8 @@
 package service
 
 import (
+	"errors"
+
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
 	"github.com/femi-lawal/new_bank/backend/product-service/internal/repository"
 	"github.com/shopspring/decimal"
@@ -14,7 +16,18 @@ func NewProductService(repo *repository.ProductRepository) *ProductService {
 	return &ProductService{Repo: repo}
 }
 
-func (s *ProductService) CreateProduct(code, name string, pType model.ProductType, interestRateStr string, currency string) (*model.Product, error) {
+// LoanTerms are the principal and term limits of a LOAN product
+type LoanTerms struct {
+	MinPrincipal  string
+	MaxPrincipal  string
+	MinTermMonths int
+	MaxTermMonths int
+}
+
+var ErrInvalidLoanTerms = errors.New("loan products need 0 < min_principal <= max_principal and 0 < min_term_months <= max_term_months")
+
+// CreateProduct creates a product. terms are required for LOAN products and ignored otherwise.
+func (s *ProductService) CreateProduct(code, name string, pType model.ProductType, interestRateStr string, currency string, terms *LoanTerms) (*model.Product, error) {
 	rate, err := decimal.NewFromString(interestRateStr)
 	if err != nil {
 		return nil, err
@@ -27,8 +40,13 @@ func (s *ProductService) CreateProduct(code, name string, pType model.ProductTyp
 		InterestRate: rate,
 		CurrencyCode: currency,
 	}
+	if pType == model.Loan {
+		if err := applyLoanTerms(p, terms); err != nil {
+			return nil, err
+		}
+	}
 
-	if err := s.Repo.CreateProduct(p); err != nil {
+	if err := s.Repo.CreateProduct(p, firstVersion(p)); err != nil {
 		return nil, err
 	}
 	return p, nil
@@ -37,3 +55,27 @@ func (s *ProductService) CreateProduct(code, name string, pType model.ProductTyp
 func (s *ProductService) ListProducts() ([]model.Product, error) {
 	return s.Repo.ListProducts()
 }
+
+func applyLoanTerms(p *model.Product, terms *LoanTerms) error {
+	if terms == nil {
+		return ErrInvalidLoanTerms
+	}
+	minPrincipal, err := decimal.NewFromString(terms.MinPrincipal)
+	if err != nil {
+		return ErrInvalidLoanTerms
+	}
+	maxPrincipal, err := decimal.NewFromString(terms.MaxPrincipal)
+	if err != nil {
+		return ErrInvalidLoanTerms
+	}
+	if !minPrincipal.IsPositive() || maxPrincipal.LessThan(minPrincipal) ||
+		terms.MinTermMonths <= 0 || terms.MaxTermMonths < terms.MinTermMonths {
+		return ErrInvalidLoanTerms
+	}
+
+	p.MinPrincipal = minPrincipal
+	p.MaxPrincipal = maxPrincipal
+	p.MinTermMonths = terms.MinTermMonths
+	p.MaxTermMonths = terms.MaxTermMonths
+	return nil
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidInterestRate  = errors.New("interest_rate must be a number between 0 and 9.9999")
+	ErrEffectiveFromTooSoon = errors.New("effective_from must be in the future and after the product's latest version")
+	ErrVersionNotFound      = errors.New("no scheduled version with that number; versions already in effect cannot be cancelled")
+	ErrAccountNotFound      = errors.New("account not found")
+	ErrAccountHasNoProduct  = errors.New("account was not opened for a product")
+	ErrNoVersionInEffect    = errors.New("no version of the product was in effect for the account on that date")
+	ErrAccountLookupFailed  = errors.New("could not read the account from the ledger")
+)
+
+// maxInterestRate is the largest rate a numeric(5,4) column holds
+var maxInterestRate = decimal.RequireFromString("9.9999")
+
+// VersionLister lists a product's versions, oldest first
+type VersionLister interface {
+	ListVersions(productID uuid.UUID) ([]model.ProductVersion, error)
+}
+
+// ProductVersionRepository defines data access for products and their versions
+type ProductVersionRepository interface {
+	VersionLister
+	GetProductByCode(code string) (*model.Product, error)
+	CreateVersion(v *model.ProductVersion) error
+	DeleteVersion(productID uuid.UUID, version int, notBefore time.Time) (bool, error)
+}
+
+// AccountReader reads the ledger account a version is resolved for
+type AccountReader interface {
+	GetAccount(authorization, accountID string) (*LedgerAccount, error)
+}
+
+// ProductVersionService schedules product versions and resolves which one
+// applies to an account
+type ProductVersionService struct {
+	Repo   ProductVersionRepository
+	Ledger AccountReader
+	now    func() time.Time
+}
+
+func NewProductVersionService(repo ProductVersionRepository, ledger AccountReader) *ProductVersionService {
+	return &ProductVersionService{Repo: repo, Ledger: ledger, now: time.Now}
+}
+
+// VersionTerms are the rate and terms of a version to schedule
+type VersionTerms struct {
+	EffectiveFrom     time.Time
+	AppliesToExisting bool
+	InterestRate      string
+	Metadata          *string    // nil keeps the latest version's
+	Loan              *LoanTerms // LOAN products only; nil keeps the latest version's
+}
+
+// ResolvedVersion is the version of its product that applies to an account
+type ResolvedVersion struct {
+	AccountID   uuid.UUID             `json:"account_id"`
+	ProductCode string                `json:"product_code"`
+	OpenedAt    time.Time             `json:"opened_at"`
+	AsOf        time.Time             `json:"as_of"`
+	Version     *model.ProductVersion `json:"version"`
+}
+
+// firstVersion is version 1 of a new product: its terms as created, in effect
+// from its creation for every account
+func firstVersion(p *model.Product) *model.ProductVersion {
+	return &model.ProductVersion{
+		Version:           1,
+		AppliesToExisting: true,
+		InterestRate:      p.InterestRate,
+		Metadata:          p.Metadata,
+		MinPrincipal:      p.MinPrincipal,
+		MaxPrincipal:      p.MaxPrincipal,
+		MinTermMonths:     p.MinTermMonths,
+		MaxTermMonths:     p.MaxTermMonths,
+	}
+}
+
+// ListVersions returns a product's versions, oldest first, including the
+// scheduled ones
+func (s *ProductVersionService) ListVersions(code string) ([]model.ProductVersion, error) {
+	product, err := s.getProduct(code)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.ListVersions(product.ID)
+}
+
+// ScheduleVersion adds a version of the product that takes effect at
+// terms.EffectiveFrom. Versions take effect in the order they are numbered,
+// so it must start after the latest one.
+func (s *ProductVersionService) ScheduleVersion(code, createdBy string, terms VersionTerms) (*model.ProductVersion, error) {
+	product, err := s.getProduct(code)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := decimal.NewFromString(terms.InterestRate)
+	if err != nil || rate.IsNegative() || rate.GreaterThan(maxInterestRate) {
+		return nil, ErrInvalidInterestRate
+	}
+	versions, err := s.Repo.ListVersions(product.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, errors.New("product has no versions")
+	}
+	latest := versions[len(versions)-1]
+	if !terms.EffectiveFrom.After(s.now()) || !terms.EffectiveFrom.After(latest.EffectiveFrom) {
+		return nil, ErrEffectiveFromTooSoon
+	}
+
+	v := &model.ProductVersion{
+		ProductID:         product.ID,
+		Version:           latest.Version + 1,
+		EffectiveFrom:     terms.EffectiveFrom,
+		AppliesToExisting: terms.AppliesToExisting,
+		InterestRate:      rate,
+		Metadata:          latest.Metadata,
+		MinPrincipal:      latest.MinPrincipal,
+		MaxPrincipal:      latest.MaxPrincipal,
+		MinTermMonths:     latest.MinTermMonths,
+		MaxTermMonths:     latest.MaxTermMonths,
+	}
+	if terms.Metadata != nil {
+		v.Metadata = terms.Metadata
+	}
+	if product.Type == model.Loan && terms.Loan != nil {
+		limits := &model.Product{}
+		if err := applyLoanTerms(limits, terms.Loan); err != nil {
+			return nil, err
+		}
+		v.MinPrincipal, v.MaxPrincipal = limits.MinPrincipal, limits.MaxPrincipal
+		v.MinTermMonths, v.MaxTermMonths = limits.MinTermMonths, limits.MaxTermMonths
+	}
+	if id, err := uuid.Parse(createdBy); err == nil {
+		v.CreatedBy = &id
+	}
+
+	if err := s.Repo.CreateVersion(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// CancelVersion removes a version that has not taken effect yet
+func (s *ProductVersionService) CancelVersion(code string, version int) error {
+	product, err := s.getProduct(code)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.Repo.DeleteVersion(product.ID, version, s.now())
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrVersionNotFound
+	}
+	return nil
+}
+
+// ResolveForAccount returns the version of the account's product that applied
+// to it at asOf. authorization is the caller's bearer token; the account is
+// read from the ledger with it, so callers only resolve accounts they can see.
+func (s *ProductVersionService) ResolveForAccount(authorization, accountID string, asOf time.Time) (*ResolvedVersion, error) {
+	if _, err := uuid.Parse(accountID); err != nil {
+		return nil, ErrAccountNotFound
+	}
+	account, err := s.Ledger.GetAccount(authorization, accountID)
+	if err != nil {
+		if errors.Is(err, ErrAccountNotFound) {
+			return nil, err
+		}
+		return nil, ErrAccountLookupFailed
+	}
+	if account.ProductCode == "" {
+		return nil, ErrAccountHasNoProduct
+	}
+	versions, err := s.ListVersions(account.ProductCode)
+	if err != nil {
+		return nil, err
+	}
+	version := resolveVersion(versions, account.OpenedAt, asOf)
+	if version == nil {
+		return nil, ErrNoVersionInEffect
+	}
+	return &ResolvedVersion{
+		AccountID:   account.ID,
+		ProductCode: account.ProductCode,
+		OpenedAt:    account.OpenedAt,
+		AsOf:        asOf,
+		Version:     version,
+	}, nil
+}
+
+// resolveVersion picks the version that applied at asOf to an account opened
+// at openedAt: the latest version in effect by then that was either already in
+// effect when the account was opened or applies to existing accounts. It
+// returns nil if the account was not open yet or no version was in effect.
+func resolveVersion(versions []model.ProductVersion, openedAt, asOf time.Time) *model.ProductVersion {
+	if asOf.Before(openedAt) {
+		return nil
+	}
+	var resolved *model.ProductVersion
+	for i := range versions {
+		v := &versions[i]
+		if v.EffectiveFrom.After(asOf) {
+			break
+		}
+		if v.AppliesToExisting || !v.EffectiveFrom.After(openedAt) {
+			resolved = v
+		}
+	}
+	return resolved
+}
+
+// currentVersion is the version a new account opened at now gets
+func currentVersion(versions []model.ProductVersion, now time.Time) *model.ProductVersion {
+	return resolveVersion(versions, now, now)
+}
+
+func (s *ProductVersionService) getProduct(code string) (*model.Product, error) {
+	product, err := s.Repo.GetProductByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	return product, nil
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/product-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryVersionRepository keeps one product and its versions
+type memoryVersionRepository struct {
+	product  *model.Product
+	versions []model.ProductVersion
+}
+
+func (r *memoryVersionRepository) GetProductByCode(code string) (*model.Product, error) {
+	if r.product == nil || r.product.Code != code {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.product, nil
+}
+
+func (r *memoryVersionRepository) ListVersions(productID uuid.UUID) ([]model.ProductVersion, error) {
+	return append([]model.ProductVersion(nil), r.versions...), nil
+}
+
+func (r *memoryVersionRepository) CreateVersion(v *model.ProductVersion) error {
+	r.versions = append(r.versions, *v)
+	return nil
+}
+
+func (r *memoryVersionRepository) DeleteVersion(productID uuid.UUID, version int, notBefore time.Time) (bool, error) {
+	for i, v := range r.versions {
+		if v.Version == version && v.EffectiveFrom.After(notBefore) {
+			r.versions = append(r.versions[:i], r.versions[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type MockAccountReader struct {
+	mock.Mock
+}
+
+func (m *MockAccountReader) GetAccount(authorization, accountID string) (*LedgerAccount, error) {
+	args := m.Called(authorization, accountID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*LedgerAccount), args.Error(1)
+}
+
+var versionsStart = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// savingsVersions has version 1 from January, a rate cut for new accounts from
+// March and a fee change for everyone from May
+func savingsVersions() *memoryVersionRepository {
+	product := &model.Product{ID: uuid.New(), Code: "SAVINGS-STD", Type: model.Savings, InterestRate: decimal.RequireFromString("0.05")}
+	v1 := firstVersion(product)
+	v1.ProductID, v1.EffectiveFrom = product.ID, versionsStart
+	fees := `{"monthly_fee":"1.00"}`
+	return &memoryVersionRepository{product: product, versions: []model.ProductVersion{
+		*v1,
+		{ProductID: product.ID, Version: 2, EffectiveFrom: versionsStart.AddDate(0, 2, 0), InterestRate: decimal.RequireFromString("0.04")},
+		{ProductID: product.ID, Version: 3, EffectiveFrom: versionsStart.AddDate(0, 4, 0), InterestRate: decimal.RequireFromString("0.04"), Metadata: &fees, AppliesToExisting: true},
+	}}
+}
+
+func TestResolveVersion(t *testing.T) {
+	versions := savingsVersions().versions
+	feb, apr, jun := versionsStart.AddDate(0, 1, 0), versionsStart.AddDate(0, 3, 0), versionsStart.AddDate(0, 5, 0)
+
+	tests := []struct {
+		name           string
+		openedAt, asOf time.Time
+		version        int
+		none           bool
+	}{
+		{"opened before the rate cut keeps version 1", feb, apr, 1, false},
+		{"opened after the rate cut gets it", apr, apr, 2, false},
+		{"changes for existing accounts apply to everyone", feb, jun, 3, false},
+		{"as of its opening day", feb, feb, 1, false},
+		{"before the account was opened", apr, feb, 0, true},
+		{"before the product existed", versionsStart.AddDate(0, -1, 0), versionsStart.AddDate(0, -1, 0), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := resolveVersion(versions, tt.openedAt, tt.asOf)
+			if tt.none {
+				assert.Nil(t, v)
+				return
+			}
+			require.NotNil(t, v)
+			assert.Equal(t, tt.version, v.Version)
+		})
+	}
+}
+
+func TestScheduleVersion(t *testing.T) {
+	repo := savingsVersions()
+	svc := NewProductVersionService(repo, nil)
+	svc.now = func() time.Time { return versionsStart.AddDate(0, 5, 0) }
+	admin := uuid.NewString()
+
+	_, err := svc.ScheduleVersion("SAVINGS-STD", admin, VersionTerms{EffectiveFrom: versionsStart.AddDate(0, 4, 15), InterestRate: "0.03"})
+	assert.ErrorIs(t, err, ErrEffectiveFromTooSoon, "versions cannot start in the past")
+	_, err = svc.ScheduleVersion("SAVINGS-STD", admin, VersionTerms{EffectiveFrom: versionsStart.AddDate(0, 6, 0), InterestRate: "-0.01"})
+	assert.ErrorIs(t, err, ErrInvalidInterestRate)
+	_, err = svc.ScheduleVersion("CHECKING-STD", admin, VersionTerms{EffectiveFrom: versionsStart.AddDate(0, 6, 0), InterestRate: "0.03"})
+	assert.ErrorIs(t, err, ErrProductNotFound)
+
+	v, err := svc.ScheduleVersion("SAVINGS-STD", admin, VersionTerms{EffectiveFrom: versionsStart.AddDate(0, 6, 0), InterestRate: "0.03"})
+	require.NoError(t, err)
+	assert.Equal(t, 4, v.Version)
+	assert.Equal(t, "0.03", v.InterestRate.String())
+	require.NotNil(t, v.Metadata)
+	assert.JSONEq(t, `{"monthly_fee":"1.00"}`, *v.Metadata, "metadata is carried over from the latest version")
+	assert.Equal(t, admin, v.CreatedBy.String())
+
+	_, err = svc.ScheduleVersion("SAVINGS-STD", admin, VersionTerms{EffectiveFrom: versionsStart.AddDate(0, 5, 15), InterestRate: "0.02"})
+	assert.ErrorIs(t, err, ErrEffectiveFromTooSoon, "versions take effect in order")
+
+	assert.ErrorIs(t, svc.CancelVersion("SAVINGS-STD", 3), ErrVersionNotFound, "versions in effect stay")
+	require.NoError(t, svc.CancelVersion("SAVINGS-STD", 4))
+	assert.Len(t, repo.versions, 3)
+}
+
+func TestResolveForAccount(t *testing.T) {
+	repo := savingsVersions()
+	ledger := new(MockAccountReader)
+	svc := NewProductVersionService(repo, ledger)
+	accountID := uuid.New()
+	opened := versionsStart.AddDate(0, 1, 0)
+	ledger.On("GetAccount", "Bearer token", accountID.String()).Return(&LedgerAccount{ID: accountID, ProductCode: "SAVINGS-STD", OpenedAt: opened}, nil)
+	unlinked := uuid.New()
+	ledger.On("GetAccount", "Bearer token", unlinked.String()).Return(&LedgerAccount{ID: unlinked, OpenedAt: opened}, nil)
+	missing := uuid.New()
+	ledger.On("GetAccount", "Bearer token", missing.String()).Return(nil, ErrAccountNotFound)
+
+	resolved, err := svc.ResolveForAccount("Bearer token", accountID.String(), versionsStart.AddDate(0, 3, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, resolved.Version.Version)
+	assert.Equal(t, "SAVINGS-STD", resolved.ProductCode)
+
+	_, err = svc.ResolveForAccount("Bearer token", unlinked.String(), opened)
+	assert.ErrorIs(t, err, ErrAccountHasNoProduct)
+	_, err = svc.ResolveForAccount("Bearer token", missing.String(), opened)
+	assert.ErrorIs(t, err, ErrAccountNotFound)
+	_, err = svc.ResolveForAccount("Bearer token", accountID.String(), versionsStart)
+	assert.ErrorIs(t, err, ErrNoVersionInEffect, "the account was not open yet")
+}
+
+func TestApply_UsesCurrentVersion(t *testing.T) {
+	product := loanProduct()
+	v1 := firstVersion(product)
+	v1.EffectiveFrom = versionsStart
+	v2 := *v1
+	v2.Version, v2.EffectiveFrom, v2.InterestRate = 2, versionsStart.AddDate(0, 3, 0), decimal.RequireFromString("0.08")
+
+	repo := new(MockLoanRepository)
+	repo.On("CreateLoan", mock.Anything).Return(nil)
+	products := new(MockProductReader)
+	products.On("GetProductByCode", product.Code).Return(product, nil)
+	svc := NewLoanService(repo, products, nil)
+	svc.Versions = &memoryVersionRepository{versions: []model.ProductVersion{*v1, v2}}
+
+	svc.now = func() time.Time { return versionsStart.AddDate(0, 1, 0) }
+	loan, err := svc.Apply(uuid.NewString(), product.Code, "10000", 12)
+	require.NoError(t, err)
+	assert.Equal(t, "0.06", loan.APR.String())
+
+	svc.now = func() time.Time { return versionsStart.AddDate(0, 4, 0) }
+	loan, err = svc.Apply(uuid.NewString(), product.Code, "10000", 12)
+	require.NoError(t, err)
+	assert.Equal(t, "0.08", loan.APR.String(), "new loans get the version in effect")
+}
@@ -0,0 +1,17 @@
+-- Baseline schema for product-service, matching what GORM AutoMigrate created.
+-- Statements use IF NOT EXISTS so databases created by AutoMigrate adopt it unchanged.
+
+CREATE TABLE IF NOT EXISTS products (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    code varchar(50) NOT NULL,
+    name varchar(100) NOT NULL,
+    type varchar(20) NOT NULL,
+    interest_rate numeric(5,4) DEFAULT 0,
+    currency_code char(3) NOT NULL,
+    metadata jsonb,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_code ON products (code);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS loan_repayments;
+DROP TABLE IF EXISTS loan_installments;
+DROP TABLE IF EXISTS loans;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS min_principal,
+    DROP COLUMN IF EXISTS max_principal,
+    DROP COLUMN IF EXISTS min_term_months,
+    DROP COLUMN IF EXISTS max_term_months;
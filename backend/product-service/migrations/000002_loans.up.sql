@@ -0,0 +1,49 @@
+ALTER TABLE products
+    ADD COLUMN min_principal numeric(19,4) DEFAULT 0,
+    ADD COLUMN max_principal numeric(19,4) DEFAULT 0,
+    ADD COLUMN min_term_months bigint DEFAULT 0,
+    ADD COLUMN max_term_months bigint DEFAULT 0;
+
+CREATE TABLE loans (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    product_id uuid NOT NULL REFERENCES products (id),
+    principal numeric(19,4) NOT NULL,
+    apr numeric(5,4) NOT NULL,
+    term_months bigint NOT NULL,
+    currency_code char(3) NOT NULL,
+    status varchar(20) NOT NULL,
+    outstanding_principal numeric(19,4) NOT NULL DEFAULT 0,
+    ledger_account_id uuid,
+    decided_by uuid,
+    decided_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_loans_user_id ON loans (user_id);
+
+CREATE TABLE loan_installments (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    loan_id uuid NOT NULL REFERENCES loans (id),
+    number bigint NOT NULL,
+    due_date date NOT NULL,
+    payment numeric(19,4) NOT NULL,
+    principal numeric(19,4) NOT NULL,
+    interest numeric(19,4) NOT NULL,
+    principal_paid numeric(19,4) NOT NULL DEFAULT 0,
+    interest_paid numeric(19,4) NOT NULL DEFAULT 0,
+    balance numeric(19,4) NOT NULL,
+    status varchar(20) NOT NULL,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_loan_installments_loan_number ON loan_installments (loan_id, number);
+
+CREATE TABLE loan_repayments (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    loan_id uuid NOT NULL REFERENCES loans (id),
+    amount numeric(19,4) NOT NULL,
+    interest_paid numeric(19,4) NOT NULL,
+    principal_paid numeric(19,4) NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX idx_loan_repayments_loan_id ON loan_repayments (loan_id);
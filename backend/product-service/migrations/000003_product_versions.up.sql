@@ -0,0 +1,23 @@
+CREATE TABLE product_versions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    product_id uuid NOT NULL REFERENCES products (id),
+    version bigint NOT NULL,
+    effective_from timestamptz NOT NULL,
+    applies_to_existing boolean NOT NULL DEFAULT false,
+    interest_rate numeric(5,4) NOT NULL DEFAULT 0,
+    metadata jsonb,
+    min_principal numeric(19,4) DEFAULT 0,
+    max_principal numeric(19,4) DEFAULT 0,
+    min_term_months bigint DEFAULT 0,
+    max_term_months bigint DEFAULT 0,
+    created_by uuid,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_product_versions_product_version ON product_versions (product_id, version);
+
+-- Existing products start at version 1, in effect since they were created
+INSERT INTO product_versions (product_id, version, effective_from, applies_to_existing, interest_rate, metadata,
+                              min_principal, max_principal, min_term_months, max_term_months, created_at)
+SELECT id, 1, COALESCE(created_at, now()), true, COALESCE(interest_rate, 0), metadata,
+       min_principal, max_principal, min_term_months, max_term_months, now()
+FROM products;
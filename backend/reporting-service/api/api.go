@@ -0,0 +1,9 @@
+// Package api holds the service's OpenAPI document
+package api
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document served on /openapi.json
+//
+//go:embed openapi.yaml
+var Spec []byte
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	apispec "github.com/femi-lawal/new_bank/backend/reporting-service/api"
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/consumer"
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/handler"
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/repository"
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/reporting-service/migrations"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/config"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/health"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const serviceName = "reporting-service"
+
+func main() {
+	// "serve" (default) runs the API; "migrate up|down [N]|status" manages the schema
+	command, args, err := db.ParseCommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Initialize Logger
+	logger.InitLogger(serviceName, true)
+	slog.Info("Starting Reporting Service")
+
+	// Check every setting this environment requires up front and report all
+	// of the problems at once, instead of failing on the first one read
+	cfg := config.FromEnv(serviceName)
+	if command == "serve" {
+		if err := cfg.Validate(); err != nil {
+			slog.Error("Invalid configuration", "error", err)
+			os.Exit(1)
+		}
+		// JWT_PREVIOUS_SECRETS keep tokens signed before a JWT_SECRET rotation
+		// valid; with JWT_SECRET_ARN, rotations in Secrets Manager are picked up
+		// without a restart
+		cfg.StartJWTSecretRefresh(context.Background())
+	}
+
+	// Errors are written as RFC 7807 problem details; ERROR_LEGACY_ENVELOPE
+	// keeps the old {"error": ...} envelope in them for older clients
+	apperrors.Configure(cfg.ErrorResponses())
+	apperrors.Problems().RegisterAll(serviceProblemTypes)
+
+	// Initialize Tracing
+	tp, err := tracing.InitTracing(context.Background(), tracing.DefaultConfig(serviceName))
+	if err != nil {
+		slog.Warn("Failed to initialize tracing", "error", err)
+	} else {
+		defer func() { _ = tp.Shutdown(context.Background()) }()
+	}
+
+	// Connect to Database
+	dbConfig := db.Config{
+		Host:     getEnv("DB_HOST", "localhost"),
+		Port:     getEnv("DB_PORT", "5433"),
+		User:     getEnv("DB_USER", "user"),
+		Password: getEnv("DB_PASSWORD", "password"),
+		DBName:   getEnv("DB_NAME", "newbank_core"),
+		SSLMode:  getEnv("DB_SSLMODE", "disable"),
+	}
+
+	database, err := db.Connect(dbConfig)
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		panic(err)
+	}
+
+	// Schema migrations are versioned SQL files embedded from migrations/
+	migrator, err := db.NewMigrator(database, serviceName, migrations.FS)
+	if err != nil {
+		slog.Error("Failed to load migrations", "error", err)
+		panic(err)
+	}
+	if command == "migrate" {
+		if err := db.RunMigrateCommand(context.Background(), migrator, args, os.Stdout); err != nil {
+			slog.Error("Migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+	// Applies pending migrations outside production; in production refuses to start until "migrate up" has run
+	if err := db.MigrateOnStartup(context.Background(), migrator, getEnv("ENVIRONMENT", "local")); err != nil {
+		slog.Error("Database schema is not up to date", "error", err)
+		panic(err)
+	}
+
+	jwtSecret := cfg.JWT.Secret
+
+	// Reports are stored in S3 when a bucket is configured, otherwise on local disk
+	// with downloads signed and served by this service
+	var store aws.ObjectStore
+	var localFiles *aws.LocalObjectStore
+	if bucket := getEnv("REPORTS_S3_BUCKET", ""); bucket != "" {
+		s3Store, err := aws.NewS3Store(context.Background(), aws.S3Config{
+			Bucket:   bucket,
+			Region:   getEnv("AWS_REGION", aws.GetRegion()),
+			Endpoint: getEnv("REPORTS_S3_ENDPOINT", ""),
+		})
+		if err != nil {
+			slog.Error("Failed to initialize S3 report storage", "error", err)
+			panic(err)
+		}
+		store = s3Store
+		slog.Info("Storing reports in S3", "bucket", bucket)
+	} else {
+		localFiles, err = aws.NewLocalObjectStore(
+			getEnv("REPORTS_LOCAL_DIR", "./data/reports"),
+			getEnv("PUBLIC_URL", "http://localhost:8086")+"/reports/files",
+			getEnv("REPORTS_SIGNING_SECRET", jwtSecret),
+		)
+		if err != nil {
+			slog.Error("Failed to initialize local report storage", "error", err)
+			panic(err)
+		}
+		store = localFiles
+		slog.Warn("REPORTS_S3_BUCKET not set, storing reports on local disk")
+	}
+
+	// Wiring
+	svc := service.NewReportService(repository.NewReadModelRepository(database), repository.NewReportRepository(database), store)
+	svc.Institution = getEnv("REPORTING_INSTITUTION", svc.Institution)
+	if value := getEnv("REGULATORY_LARGE_PAYMENT_THRESHOLD", ""); value != "" {
+		threshold, err := decimal.NewFromString(value)
+		if err != nil || !threshold.IsPositive() {
+			panic("Invalid REGULATORY_LARGE_PAYMENT_THRESHOLD: " + value)
+		}
+		svc.LargePaymentThreshold = threshold
+	}
+	h := handler.NewReportHandler(svc)
+	h.Files = localFiles
+
+	// Ledger and payment events feed the read model reports are built from
+	kafkaBrokers := []string{getEnv("KAFKA_BROKERS", "localhost:9092")}
+	// Creates the topics this service uses when they are missing, see topics.go;
+	// the outcome, including settings that drifted, is reported in /health
+	topicSettings, err := kafka.TopicSettingsFromEnv()
+	if err != nil {
+		slog.Error("Invalid Kafka topic settings", "error", err)
+		os.Exit(1)
+	}
+	topicAdmin := kafka.NewTopicAdmin(kafkaBrokers)
+	go topicAdmin.Start(context.Background(), kafka.TopicSpecs(topicSettings, serviceTopics...))
+	eventConsumer := consumer.NewEventConsumer(kafkaBrokers, svc)
+	go eventConsumer.Start(context.Background())
+	lagExporter := kafka.NewLagExporter(kafkaBrokers, consumer.ConsumerGroup, consumer.Topics, kafka.DefaultLagInterval)
+	go lagExporter.Start(context.Background())
+
+	// Queues each month's statements and exports once the month closes, and generates queued reports
+	workerInterval, err := time.ParseDuration(getEnv("REPORT_WORKER_INTERVAL", "1m"))
+	if err != nil {
+		panic("Invalid REPORT_WORKER_INTERVAL: " + err.Error())
+	}
+	// Background work runs from the jobs table, so each scheduled run happens
+	// on one replica and failures are retried and visible in the admin API
+	jobStore := jobs.NewPostgresStore(database)
+	jobRunner := jobs.NewRunner(jobStore, serviceName, jobs.DefaultConfig())
+	jobRunner.Schedule("reporting.schedule_reports", jobs.Every(workerInterval), svc.ScheduleReportsJob)
+	jobRunner.Schedule("reporting.generate_reports", jobs.Every(workerInterval), svc.GenerateReportsJob)
+	go jobRunner.Run(context.Background())
+
+	// Setup Router
+	r := gin.Default()
+
+	// ============================================
+	// Global Middleware
+	// ============================================
+	r.Use(apperrors.ErrorMiddleware())
+	r.Use(middleware.RequestLogger(serviceName))
+	r.Use(middleware.Tracing(serviceName))
+	r.Use(middleware.CORS())
+	r.Use(middleware.RateLimitWithPolicies(cfg.RateLimitPolicies()))
+	// Latency objectives recorded by the Prometheus middleware, see slos.go
+	metrics.MustRegisterSLOs(serviceName, serviceSLOs...)
+	r.Use(metrics.PrometheusMiddleware(serviceName))
+
+	// Readiness needs the database with its migrations applied; without
+	// Kafka the service only degrades
+	healthChecks := health.New(serviceName)
+	healthChecks.Register("database", health.Database(database))
+	healthChecks.Register("migrations", health.Migrations(migrator))
+	healthChecks.RegisterOptional("kafka", health.Kafka(kafkaBrokers))
+	healthChecks.Info("kafka_topics", func() interface{} { return topicAdmin.Report() })
+	registerRoutes(r, h, jobs.NewAdminHandler(jobStore, serviceName), cfg.JWTAuth(), healthChecks)
+
+	port := getEnv("PORT", "8086")
+	slog.Info("Server listening", "port", port)
+	if err := r.Run(":" + port); err != nil {
+		slog.Error("Failed to start server", "error", err)
+	}
+}
+
+// registerRoutes mounts every endpoint on r. It is separate from main so the
+// OpenAPI test can check the spec against the real routes.
+func registerRoutes(r *gin.Engine, h *handler.ReportHandler, jobAdmin *jobs.AdminHandler, jwtAuth middleware.JWTAuthConfig, healthChecks *health.Handler) {
+	// ============================================
+	// Public endpoints
+	// ============================================
+	r.GET("/metrics", metrics.MetricsHandler())
+	r.GET(metrics.SLOStatusPath, metrics.SLOStatusHandler(serviceName))
+	healthChecks.RegisterRoutes(r)
+	// The OpenAPI document and its Swagger UI page
+	openapi.Register(r, openapi.MustLoad(apispec.Spec))
+	// Signed download links for locally stored reports
+	r.GET("/reports/files", h.ServeFile)
+
+	// ============================================
+	// Protected endpoints
+	// ============================================
+	api := r.Group("/api/v1")
+	api.Use(middleware.JWTAuthWithConfig(jwtAuth))
+	// Users who have not accepted the current mandatory terms can only read
+	api.Use(middleware.RequireTermsAccepted())
+	{
+		api.GET("/reports", h.ListReports)
+		api.GET("/reports/:id", h.GetReport)
+		api.GET("/reports/:id/download", h.DownloadReport)
+		api.POST("/reports/statements", h.RequestStatement)
+		api.POST("/reports/tax-summaries", h.RequestTaxSummary)
+	}
+
+	// ============================================
+	// Admin endpoints
+	// ============================================
+	admin := r.Group("/api/v1/admin")
+	admin.Use(middleware.JWTAuthWithConfig(jwtAuth.Admin()), middleware.RequireRole("admin"))
+	{
+		admin.GET("/reports", h.ListReportsByType)
+		admin.POST("/reports/regulatory-exports", h.RequestRegulatoryExport)
+	}
+	jobAdmin.RegisterRoutes(admin)
+}
+
+func getEnv(key, fallback string) string {
+	if value, exists := os.LookupEnv(key); exists {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,8 @@
+package main
+
+// serviceProblemTypes title the error codes the reporting service defines, keyed by
+// code. Their problem types are namespaced under the service; the standard
+// codes are registered by apperrors.
+var serviceProblemTypes = map[string]string{
+	"REPORT_NOT_READY": "Report is not ready",
+}
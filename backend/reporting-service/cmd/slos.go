@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+)
+
+// serviceSLOs are the latency objectives of the reporting service, reported at
+// /slo-status. Reports are generated in the background, so only the API that
+// queues and lists them has objectives.
+var serviceSLOs = []metrics.SLO{
+	{Name: "request-statement", Method: http.MethodPost, Route: "/api/v1/reports/statements", Threshold: 250 * time.Millisecond, Objective: 0.99},
+	{Name: "list-reports", Method: http.MethodGet, Route: "/api/v1/reports", Threshold: 100 * time.Millisecond, Objective: 0.99},
+	{Name: "download-report", Method: http.MethodGet, Route: "/api/v1/reports/:id/download", Threshold: 2 * time.Second, Objective: 0.99},
+}
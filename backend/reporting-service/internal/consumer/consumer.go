@@ -0,0 +1,96 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+)
+
+// ConsumerGroup is the Kafka consumer group the reporting read model uses
+const ConsumerGroup = "reporting-service"
+
+// Topics are the events projected into the read model
+var Topics = []string{
+	kafka.TopicAccountCreated,
+	kafka.TopicTransactionCategorized,
+	kafka.TopicPaymentCompleted,
+	kafka.TopicPaymentFailed,
+}
+
+// EventConsumer feeds ledger and payment events into the reporting read model
+type EventConsumer struct {
+	consumers map[string]*kafka.Consumer
+	svc       *service.ReportService
+}
+
+// NewEventConsumer creates one consumer per topic in the reporting consumer group
+func NewEventConsumer(brokers []string, svc *service.ReportService) *EventConsumer {
+	consumers := make(map[string]*kafka.Consumer, len(Topics))
+	for _, topic := range Topics {
+		consumers[topic] = kafka.NewConsumer(brokers, ConsumerGroup, topic)
+	}
+	return &EventConsumer{consumers: consumers, svc: svc}
+}
+
+// Start consumes every topic until the context is cancelled
+func (c *EventConsumer) Start(ctx context.Context) {
+	var wg sync.WaitGroup
+	for topic, consumer := range c.consumers {
+		wg.Add(1)
+		go func(topic string, consumer *kafka.Consumer) {
+			defer wg.Done()
+			slog.Info("Starting reporting event consumer", "topic", topic)
+			if err := consumer.Consume(ctx, func(key string, value []byte) error {
+				return Handle(c.svc, topic, value)
+			}); err != nil && ctx.Err() == nil {
+				slog.Error("Kafka consumer error", "topic", topic, "error", err)
+			}
+		}(topic, consumer)
+	}
+	wg.Wait()
+}
+
+// Handle projects one event from the given topic
+func Handle(svc *service.ReportService, topic string, value []byte) error {
+	switch topic {
+	case kafka.TopicAccountCreated:
+		var event kafka.AccountEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		return svc.ApplyAccountCreated(event)
+	case kafka.TopicTransactionCategorized:
+		var event kafka.TransactionCategorizedEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		return svc.ApplyTransactionCategorized(event)
+	case kafka.TopicPaymentCompleted, kafka.TopicPaymentFailed:
+		var event kafka.PaymentEvent
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		status := service.PaymentCompleted
+		if topic == kafka.TopicPaymentFailed {
+			status = service.PaymentFailed
+		}
+		return svc.ApplyPaymentOutcome(event, status)
+	}
+	slog.Warn("Ignoring event from unexpected topic", "topic", topic)
+	return nil
+}
+
+// Close closes every topic consumer
+func (c *EventConsumer) Close() error {
+	var firstErr error
+	for _, consumer := range c.consumers {
+		if err := consumer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/service"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	Service *service.ReportService
+	// Files serves downloads when reports are stored locally; nil with S3,
+	// whose presigned URLs point at the bucket
+	Files *aws.LocalObjectStore
+}
+
+func NewReportHandler(s *service.ReportService) *ReportHandler {
+	return &ReportHandler{Service: s}
+}
+
+type StatementRequest struct {
+	AccountID string `json:"account_id" binding:"required"`
+	Month     string `json:"month" binding:"required"` // YYYY-MM
+}
+
+type TaxSummaryRequest struct {
+	Year int `json:"year" binding:"required"`
+}
+
+type RegulatoryExportRequest struct {
+	Month  string `json:"month" binding:"required"` // YYYY-MM
+	Format string `json:"format" binding:"required"`
+}
+
+// ListReports returns the authenticated user's statements and tax summaries
+func (h *ReportHandler) ListReports(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	reports, err := h.Service.ListUserReports(userID)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// GetReport returns one report and its generation status
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	report, err := h.Service.GetReport(c.Param("id"), userID, isAdmin(c))
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// DownloadReport returns a short-lived signed URL for a completed report
+func (h *ReportHandler) DownloadReport(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	link, err := h.Service.DownloadURL(c.Request.Context(), c.Param("id"), userID, isAdmin(c))
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, link)
+}
+
+// RequestStatement queues a monthly statement for one of the user's accounts
+func (h *ReportHandler) RequestStatement(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	var req StatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	report, err := h.Service.RequestStatement(userID, req.AccountID, req.Month, isAdmin(c))
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
+
+// RequestTaxSummary queues the user's tax summary for a year
+func (h *ReportHandler) RequestTaxSummary(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	if userID == "" {
+		apperrors.RespondWithError(c, apperrors.ErrUnauthorized)
+		return
+	}
+	var req TaxSummaryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	report, err := h.Service.RequestTaxSummary(userID, req.Year)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
+
+// RequestRegulatoryExport queues a month's regulatory export (admin only)
+func (h *ReportHandler) RequestRegulatoryExport(c *gin.Context) {
+	var req RegulatoryExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	report, err := h.Service.RequestRegulatoryExport(middleware.GetUserID(c), req.Month, req.Format)
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
+
+// ListReportsByType lists reports of the type in the query string (admin only)
+func (h *ReportHandler) ListReportsByType(c *gin.Context) {
+	reports, err := h.Service.ListReportsByType(c.Query("type"))
+	if err != nil {
+		respondReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// ServeFile serves a locally stored report through a signed URL. The signature
+// is the authorization, so the route is public.
+func (h *ReportHandler) ServeFile(c *gin.Context) {
+	if h.Files == nil {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound)
+		return
+	}
+	key := c.Query("key")
+	if err := h.Files.VerifyDownload(key, c.Query("expires"), c.Query("signature")); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+		return
+	}
+	data, err := h.Files.GetObject(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, aws.ErrObjectNotFound) {
+			apperrors.RespondWithError(c, apperrors.ErrNotFound)
+			return
+		}
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	contentType := "text/csv; charset=utf-8"
+	if path.Ext(key) == ".xbrl" {
+		contentType = "application/xml"
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+path.Base(key)+`"`)
+	c.Data(http.StatusOK, contentType, data)
+}
+
+func isAdmin(c *gin.Context) bool {
+	claims := middleware.GetClaims(c)
+	return claims != nil && claims.Role == "admin"
+}
+
+// respondReportError maps report service errors to HTTP statuses
+func respondReportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrReportNotFound), errors.Is(err, service.ErrAccountNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrInvalidPeriod), errors.Is(err, service.ErrInvalidFormat),
+		errors.Is(err, service.ErrFutureReport), errors.Is(err, service.ErrUnknownReportType):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, service.ErrReportNotReady):
+		apperrors.RespondWithError(c, apperrors.NewError("REPORT_NOT_READY", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
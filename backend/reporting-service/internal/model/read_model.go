@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Account is the reporting copy of a ledger account, built from account.created events
+type Account struct {
+	AccountID     uuid.UUID `gorm:"type:uuid;primary_key" json:"account_id"`
+	UserID        uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	AccountNumber string    `gorm:"type:varchar(34)" json:"account_number"`
+	Type          string    `gorm:"type:varchar(20)" json:"type"`
+	CurrencyCode  string    `gorm:"type:char(3);not null" json:"currency"`
+	OpenedAt      time.Time `gorm:"not null" json:"opened_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (Account) TableName() string {
+	return "report_accounts"
+}
+
+// Transaction is one posting on an account, built from transaction.categorized
+// events. A recategorization updates the row in place.
+type Transaction struct {
+	PostingID     uuid.UUID       `gorm:"type:uuid;primary_key" json:"posting_id"`
+	TransactionID uuid.UUID       `gorm:"type:uuid;not null;index" json:"transaction_id"`
+	AccountID     uuid.UUID       `gorm:"type:uuid;not null;index:idx_report_transactions_account_time" json:"account_id"`
+	UserID        uuid.UUID       `gorm:"type:uuid;not null;index" json:"user_id"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	Direction     int             `gorm:"not null" json:"direction"` // 1 = money in, -1 = money out
+	CurrencyCode  string          `gorm:"type:char(3);not null" json:"currency"`
+	Category      string          `gorm:"type:varchar(20)" json:"category"`
+	Merchant      string          `gorm:"type:varchar(255)" json:"merchant,omitempty"`
+	OccurredAt    time.Time       `gorm:"not null;index:idx_report_transactions_account_time" json:"occurred_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+func (Transaction) TableName() string {
+	return "report_transactions"
+}
+
+// SignedAmount is the amount with the direction applied
+func (t Transaction) SignedAmount() decimal.Decimal {
+	return t.Amount.Mul(decimal.NewFromInt(int64(t.Direction)))
+}
+
+// Payment is the outcome of a payment, built from payment.completed and payment.failed events
+type Payment struct {
+	PaymentID     uuid.UUID       `gorm:"type:uuid;primary_key" json:"payment_id"`
+	FromAccountID uuid.UUID       `gorm:"type:uuid;not null" json:"from_account_id"`
+	ToAccountID   uuid.UUID       `gorm:"type:uuid;not null" json:"to_account_id"`
+	Amount        decimal.Decimal `gorm:"type:numeric(19,4);not null" json:"amount"`
+	CurrencyCode  string          `gorm:"type:char(3);not null" json:"currency"`
+	Status        string          `gorm:"type:varchar(20);not null" json:"status"`
+	Description   string          `gorm:"type:varchar(255)" json:"description,omitempty"`
+	OccurredAt    time.Time       `gorm:"not null;index" json:"occurred_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+}
+
+func (Payment) TableName() string {
+	return "report_payments"
+}
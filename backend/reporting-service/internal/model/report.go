@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ReportType string
+
+const (
+	// ReportMonthlyStatement lists one account's transactions for a calendar month
+	ReportMonthlyStatement ReportType = "MONTHLY_STATEMENT"
+	// ReportTaxSummary totals a user's income and fees for a tax year
+	ReportTaxSummary ReportType = "TAX_SUMMARY"
+	// ReportRegulatoryExport aggregates payment volumes for a month for the regulator
+	ReportRegulatoryExport ReportType = "REGULATORY_EXPORT"
+)
+
+type ReportFormat string
+
+const (
+	FormatCSV  ReportFormat = "CSV"
+	FormatXBRL ReportFormat = "XBRL"
+)
+
+type ReportStatus string
+
+const (
+	ReportPending   ReportStatus = "PENDING"
+	ReportRunning   ReportStatus = "RUNNING"
+	ReportCompleted ReportStatus = "COMPLETED"
+	ReportFailed    ReportStatus = "FAILED"
+)
+
+// Report is a generation job and, once completed, the stored file it produced.
+// DedupeKey identifies the report's subject and period so the scheduler and
+// repeated requests never generate the same report twice.
+type Report struct {
+	ID          uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Type        ReportType   `gorm:"type:varchar(30);not null" json:"type"`
+	Format      ReportFormat `gorm:"type:varchar(10);not null" json:"format"`
+	UserID      *uuid.UUID   `gorm:"type:uuid;index" json:"user_id,omitempty"`
+	AccountID   *uuid.UUID   `gorm:"type:uuid" json:"account_id,omitempty"`
+	PeriodStart time.Time    `gorm:"type:date;not null" json:"period_start"`
+	PeriodEnd   time.Time    `gorm:"type:date;not null" json:"period_end"` // exclusive
+	DedupeKey   string       `gorm:"type:varchar(200);not null;uniqueIndex" json:"-"`
+	Status      ReportStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	StorageKey  string       `gorm:"type:varchar(255)" json:"-"`
+	ContentType string       `gorm:"type:varchar(100)" json:"content_type,omitempty"`
+	SizeBytes   int64        `gorm:"not null;default:0" json:"size_bytes"`
+	Error       string       `gorm:"type:text" json:"error,omitempty"`
+	RequestedBy *uuid.UUID   `gorm:"type:uuid" json:"requested_by,omitempty"` // nil for scheduled reports
+	ClaimedAt   *time.Time   `json:"-"`
+	CreatedAt   time.Time    `json:"created_at"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+}
+
+func (Report) TableName() string {
+	return "reports"
+}
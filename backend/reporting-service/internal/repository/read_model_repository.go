@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReadModelRepository stores the accounts, transactions and payments projected from events
+type ReadModelRepository struct {
+	DB *gorm.DB
+}
+
+func NewReadModelRepository(db *gorm.DB) *ReadModelRepository {
+	return &ReadModelRepository{DB: db}
+}
+
+// UpsertAccount records an account; redelivered events leave the first copy in place
+func (r *ReadModelRepository) UpsertAccount(account *model.Account) error {
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(account).Error
+}
+
+// UpsertTransaction records a posting. A later event for the same posting is a
+// recategorization, so only the category and merchant change.
+func (r *ReadModelRepository) UpsertTransaction(txn *model.Transaction) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "posting_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"category", "merchant", "updated_at"}),
+	}).Create(txn).Error
+}
+
+// UpsertPayment records a payment outcome, replacing an earlier status
+func (r *ReadModelRepository) UpsertPayment(payment *model.Payment) error {
+	return r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "payment_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "occurred_at", "updated_at"}),
+	}).Create(payment).Error
+}
+
+func (r *ReadModelRepository) GetAccount(id string) (*model.Account, error) {
+	var account model.Account
+	if err := r.DB.Where("account_id = ?", id).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ListUserAccounts returns all of a user's accounts
+func (r *ReadModelRepository) ListUserAccounts(userID uuid.UUID) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.DB.Where("user_id = ?", userID).Order("opened_at, account_id").Find(&accounts).Error
+	return accounts, err
+}
+
+// ListAccountsOpenedBefore returns the accounts that existed at the given time
+func (r *ReadModelRepository) ListAccountsOpenedBefore(at time.Time) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.DB.Where("opened_at < ?", at).Order("account_id").Find(&accounts).Error
+	return accounts, err
+}
+
+// ListUsersWithTransactions returns the users with postings in [from, to)
+func (r *ReadModelRepository) ListUsersWithTransactions(from, to time.Time) ([]uuid.UUID, error) {
+	var users []uuid.UUID
+	err := r.DB.Model(&model.Transaction{}).
+		Where("occurred_at >= ? AND occurred_at < ?", from, to).
+		Distinct().Order("user_id").Pluck("user_id", &users).Error
+	return users, err
+}
+
+// BalanceBefore sums the account's postings before the given time
+func (r *ReadModelRepository) BalanceBefore(accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	var balance decimal.NullDecimal
+	err := r.DB.Model(&model.Transaction{}).
+		Select("SUM(amount * direction)").
+		Where("account_id = ? AND occurred_at < ?", accountID, at).
+		Scan(&balance).Error
+	if err != nil || !balance.Valid {
+		return decimal.Zero, err
+	}
+	return balance.Decimal, nil
+}
+
+// ListAccountTransactions returns the account's postings in [from, to), oldest first
+func (r *ReadModelRepository) ListAccountTransactions(accountID uuid.UUID, from, to time.Time) ([]model.Transaction, error) {
+	var txns []model.Transaction
+	err := r.DB.Where("account_id = ? AND occurred_at >= ? AND occurred_at < ?", accountID, from, to).
+		Order("occurred_at, posting_id").Find(&txns).Error
+	return txns, err
+}
+
+// ListUserTransactions returns the user's postings across all accounts in [from, to)
+func (r *ReadModelRepository) ListUserTransactions(userID uuid.UUID, from, to time.Time) ([]model.Transaction, error) {
+	var txns []model.Transaction
+	err := r.DB.Where("user_id = ? AND occurred_at >= ? AND occurred_at < ?", userID, from, to).
+		Order("occurred_at, posting_id").Find(&txns).Error
+	return txns, err
+}
+
+// ListPayments returns the payments that reached a final status in [from, to)
+func (r *ReadModelRepository) ListPayments(from, to time.Time) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := r.DB.Where("occurred_at >= ? AND occurred_at < ?", from, to).
+		Order("occurred_at, payment_id").Find(&payments).Error
+	return payments, err
+}
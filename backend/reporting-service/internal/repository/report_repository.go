@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReportRepository struct {
+	DB *gorm.DB
+}
+
+func NewReportRepository(db *gorm.DB) *ReportRepository {
+	return &ReportRepository{DB: db}
+}
+
+// CreateReport stores a new report job. If a report with the same dedupe key
+// exists it is returned instead, with created false.
+func (r *ReportRepository) CreateReport(report *model.Report) (*model.Report, bool, error) {
+	res := r.DB.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "dedupe_key"}}, DoNothing: true}).Create(report)
+	if res.Error != nil {
+		return nil, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return report, true, nil
+	}
+	var existing model.Report
+	if err := r.DB.Where("dedupe_key = ?", report.DedupeKey).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+func (r *ReportRepository) GetReport(id string) (*model.Report, error) {
+	var report model.Report
+	if err := r.DB.Where("id = ?", id).First(&report).Error; err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListUserReports returns the user's statements and tax summaries, newest first
+func (r *ReportRepository) ListUserReports(userID uuid.UUID, limit int) ([]model.Report, error) {
+	var reports []model.Report
+	err := r.DB.Where("user_id = ?", userID).Order("period_start DESC, created_at DESC").Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+// ListReportsByType returns reports of one type, newest first
+func (r *ReportRepository) ListReportsByType(reportType model.ReportType, limit int) ([]model.Report, error) {
+	var reports []model.Report
+	err := r.DB.Where("type = ?", reportType).Order("period_start DESC, created_at DESC").Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+// ClaimReports marks up to limit pending reports as running and returns them.
+// Reports left running since before staleBefore, e.g. by a crashed worker, are
+// claimed again. Rows locked by another worker are skipped.
+func (r *ReportRepository) ClaimReports(limit int, now, staleBefore time.Time) ([]model.Report, error) {
+	var reports []model.Report
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND claimed_at < ?)", model.ReportPending, model.ReportRunning, staleBefore).
+			Order("created_at").Limit(limit).Find(&reports).Error; err != nil {
+			return err
+		}
+		if len(reports) == 0 {
+			return nil
+		}
+		ids := make([]uuid.UUID, len(reports))
+		for i := range reports {
+			ids[i] = reports[i].ID
+			reports[i].Status = model.ReportRunning
+			reports[i].ClaimedAt = &now
+		}
+		return tx.Model(&model.Report{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"status": model.ReportRunning, "claimed_at": now}).Error
+	})
+	return reports, err
+}
+
+// CompleteReport records where a generated report was stored
+func (r *ReportRepository) CompleteReport(id uuid.UUID, storageKey, contentType string, size int64, at time.Time) error {
+	return r.DB.Model(&model.Report{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       model.ReportCompleted,
+		"storage_key":  storageKey,
+		"content_type": contentType,
+		"size_bytes":   size,
+		"error":        "",
+		"completed_at": at,
+	}).Error
+}
+
+// FailReport records why a report could not be generated
+func (r *ReportRepository) FailReport(id uuid.UUID, reason string, at time.Time) error {
+	return r.DB.Model(&model.Report{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":       model.ReportFailed,
+		"error":        reason,
+		"completed_at": at,
+	}).Error
+}
+
+// RetryReport puts a failed report back in the queue
+func (r *ReportRepository) RetryReport(id uuid.UUID) error {
+	return r.DB.Model(&model.Report{}).Where("id = ? AND status = ?", id, model.ReportFailed).Updates(map[string]interface{}{
+		"status":       model.ReportPending,
+		"error":        "",
+		"claimed_at":   nil,
+		"completed_at": nil,
+	}).Error
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	contentTypeCSV  = "text/csv; charset=utf-8"
+	contentTypeXBRL = "application/xml"
+	dateLayout      = "2006-01-02"
+)
+
+// document is a generated report file
+type document struct {
+	body        []byte
+	contentType string
+	extension   string
+}
+
+// buildStatement lists an account's postings for the report period with the
+// running balance, starting from the balance of all earlier postings
+func (s *ReportService) buildStatement(report *model.Report) (*document, error) {
+	if report.AccountID == nil {
+		return nil, ErrAccountNotFound
+	}
+	account, err := s.readModel.GetAccount(report.AccountID.String())
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	opening, err := s.readModel.BalanceBefore(account.AccountID, report.PeriodStart)
+	if err != nil {
+		return nil, err
+	}
+	txns, err := s.readModel.ListAccountTransactions(account.AccountID, report.PeriodStart, report.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	closing := opening
+	for _, t := range txns {
+		closing = closing.Add(t.SignedAmount())
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.WriteAll([][]string{
+		{"Statement", account.AccountNumber},
+		{"Account ID", account.AccountID.String()},
+		{"Currency", account.CurrencyCode},
+		{"Period", report.PeriodStart.Format(dateLayout), lastDay(report.PeriodEnd)},
+		{"Opening balance", money(opening)},
+		{"Closing balance", money(closing)},
+		{},
+		{"Date", "Transaction ID", "Description", "Category", "Money in", "Money out", "Balance"},
+	})
+	balance := opening
+	for _, t := range txns {
+		balance = balance.Add(t.SignedAmount())
+		in, out := "", ""
+		if t.Direction > 0 {
+			in = money(t.Amount)
+		} else {
+			out = money(t.Amount)
+		}
+		_ = w.Write([]string{t.OccurredAt.UTC().Format(dateLayout), t.TransactionID.String(), t.Merchant, t.Category, in, out, money(balance)})
+	}
+	w.Flush()
+	return &document{body: buf.Bytes(), contentType: contentTypeCSV, extension: "csv"}, w.Error()
+}
+
+// taxLine totals one currency and category for a tax summary
+type taxLine struct {
+	currency, category string
+	in, out            decimal.Decimal
+}
+
+// buildTaxSummary totals a user's money in and out by category for the tax
+// year, with each account's balance at the end of the year
+func (s *ReportService) buildTaxSummary(report *model.Report) (*document, error) {
+	if report.UserID == nil {
+		return nil, ErrReportNotFound
+	}
+	txns, err := s.readModel.ListUserTransactions(*report.UserID, report.PeriodStart, report.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.readModel.ListUserAccounts(*report.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[[2]string]*taxLine)
+	for _, t := range txns {
+		key := [2]string{t.CurrencyCode, t.Category}
+		line, ok := totals[key]
+		if !ok {
+			line = &taxLine{currency: t.CurrencyCode, category: t.Category, in: decimal.Zero, out: decimal.Zero}
+			totals[key] = line
+		}
+		if t.Direction > 0 {
+			line.in = line.in.Add(t.Amount)
+		} else {
+			line.out = line.out.Add(t.Amount)
+		}
+	}
+	lines := make([]*taxLine, 0, len(totals))
+	for _, line := range totals {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].currency != lines[j].currency {
+			return lines[i].currency < lines[j].currency
+		}
+		return lines[i].category < lines[j].category
+	})
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.WriteAll([][]string{
+		{"Tax summary", strconv.Itoa(report.PeriodStart.Year())},
+		{"User ID", report.UserID.String()},
+		{"Period", report.PeriodStart.Format(dateLayout), lastDay(report.PeriodEnd)},
+		{},
+		{"Currency", "Category", "Money in", "Money out"},
+	})
+	for _, line := range lines {
+		_ = w.Write([]string{line.currency, line.category, money(line.in), money(line.out)})
+	}
+	_ = w.WriteAll([][]string{{}, {"Account", "Account ID", "Currency", "Year-end balance"}})
+	for _, a := range accounts {
+		if !a.OpenedAt.Before(report.PeriodEnd) {
+			continue
+		}
+		balance, err := s.readModel.BalanceBefore(a.AccountID, report.PeriodEnd)
+		if err != nil {
+			return nil, err
+		}
+		_ = w.Write([]string{a.AccountNumber, a.AccountID.String(), a.CurrencyCode, money(balance)})
+	}
+	w.Flush()
+	return &document{body: buf.Bytes(), contentType: contentTypeCSV, extension: "csv"}, w.Error()
+}
+
+// paymentVolume aggregates one currency's payments for a regulatory export
+type paymentVolume struct {
+	currency       string
+	completed      int
+	completedValue decimal.Decimal
+	failed         int
+	large          int
+}
+
+// buildRegulatoryExport aggregates the period's payments by currency and
+// itemises completed payments at or above LargePaymentThreshold
+func (s *ReportService) buildRegulatoryExport(report *model.Report) (*document, error) {
+	payments, err := s.readModel.ListPayments(report.PeriodStart, report.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	byCurrency := make(map[string]*paymentVolume)
+	var large []model.Payment
+	for _, p := range payments {
+		v, ok := byCurrency[p.CurrencyCode]
+		if !ok {
+			v = &paymentVolume{currency: p.CurrencyCode, completedValue: decimal.Zero}
+			byCurrency[p.CurrencyCode] = v
+		}
+		if p.Status != PaymentCompleted {
+			v.failed++
+			continue
+		}
+		v.completed++
+		v.completedValue = v.completedValue.Add(p.Amount)
+		if p.Amount.GreaterThanOrEqual(s.LargePaymentThreshold) {
+			v.large++
+			large = append(large, p)
+		}
+	}
+	volumes := make([]*paymentVolume, 0, len(byCurrency))
+	for _, v := range byCurrency {
+		volumes = append(volumes, v)
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].currency < volumes[j].currency })
+
+	if report.Format == model.FormatXBRL {
+		return s.regulatoryXBRL(report, volumes)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.WriteAll([][]string{
+		{"Institution", s.Institution},
+		{"Period", report.PeriodStart.Format(dateLayout), lastDay(report.PeriodEnd)},
+		{},
+		{"Currency", "Completed payments", "Completed value", "Failed payments", "Large payments"},
+	})
+	for _, v := range volumes {
+		_ = w.Write([]string{v.currency, strconv.Itoa(v.completed), money(v.completedValue), strconv.Itoa(v.failed), strconv.Itoa(v.large)})
+	}
+	_ = w.WriteAll([][]string{
+		{},
+		{"Large payments at or above", money(s.LargePaymentThreshold)},
+		{"Payment ID", "Date", "From account", "To account", "Currency", "Amount"},
+	})
+	for _, p := range large {
+		_ = w.Write([]string{p.PaymentID.String(), p.OccurredAt.UTC().Format(dateLayout), p.FromAccountID.String(), p.ToAccountID.String(), p.CurrencyCode, money(p.Amount)})
+	}
+	w.Flush()
+	return &document{body: buf.Bytes(), contentType: contentTypeCSV, extension: "csv"}, w.Error()
+}
+
+// regulatoryXBRL writes the aggregates as an XBRL instance. This is a stub:
+// the facts use a placeholder NeoBank taxonomy until the regulator's
+// taxonomy and schema reference are adopted.
+func (s *ReportService) regulatoryXBRL(report *model.Report, volumes []*paymentVolume) (*document, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<xbrli:xbrl xmlns:xbrli="http://www.xbrl.org/2003/instance" xmlns:iso4217="http://www.xbrl.org/2003/iso4217" xmlns:nb="https://neobank.example/xbrl/payments">` + "\n")
+	fmt.Fprintf(&buf, `  <xbrli:context id="period"><xbrli:entity><xbrli:identifier scheme="https://neobank.example/institution">%s</xbrli:identifier></xbrli:entity><xbrli:period><xbrli:startDate>%s</xbrli:startDate><xbrli:endDate>%s</xbrli:endDate></xbrli:period></xbrli:context>`+"\n",
+		xmlEscape(s.Institution), report.PeriodStart.Format(dateLayout), lastDay(report.PeriodEnd))
+	buf.WriteString(`  <xbrli:unit id="pure"><xbrli:measure>xbrli:pure</xbrli:measure></xbrli:unit>` + "\n")
+	for _, v := range volumes {
+		currency := xmlEscape(v.currency)
+		fmt.Fprintf(&buf, `  <xbrli:unit id="%s"><xbrli:measure>iso4217:%s</xbrli:measure></xbrli:unit>`+"\n", currency, currency)
+	}
+	for _, v := range volumes {
+		currency := xmlEscape(v.currency)
+		fmt.Fprintf(&buf, `  <nb:CompletedPaymentsCount contextRef="period" unitRef="pure" decimals="0" nb:currency="%s">%d</nb:CompletedPaymentsCount>`+"\n", currency, v.completed)
+		fmt.Fprintf(&buf, `  <nb:CompletedPaymentsValue contextRef="period" unitRef="%s" decimals="2">%s</nb:CompletedPaymentsValue>`+"\n", currency, money(v.completedValue))
+		fmt.Fprintf(&buf, `  <nb:FailedPaymentsCount contextRef="period" unitRef="pure" decimals="0" nb:currency="%s">%d</nb:FailedPaymentsCount>`+"\n", currency, v.failed)
+		fmt.Fprintf(&buf, `  <nb:LargePaymentsCount contextRef="period" unitRef="pure" decimals="0" nb:currency="%s">%d</nb:LargePaymentsCount>`+"\n", currency, v.large)
+	}
+	buf.WriteString("</xbrli:xbrl>\n")
+	return &document{body: buf.Bytes(), contentType: contentTypeXBRL, extension: "xbrl"}, nil
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func money(d decimal.Decimal) string {
+	return d.StringFixed(2)
+}
+
+// lastDay formats the inclusive last day of a period that ends at end
+func lastDay(end time.Time) string {
+	return end.AddDate(0, 0, -1).Format(dateLayout)
+}
+
+// storageKey places a report's file under its type and period
+func storageKey(report *model.Report, doc *document) string {
+	var prefix string
+	switch report.Type {
+	case model.ReportMonthlyStatement:
+		prefix = "statements/" + report.PeriodStart.Format("2006-01")
+	case model.ReportTaxSummary:
+		prefix = "tax/" + report.PeriodStart.Format("2006")
+	default:
+		prefix = "regulatory/" + report.PeriodStart.Format("2006-01")
+	}
+	subject := uuid.Nil
+	switch {
+	case report.AccountID != nil:
+		subject = *report.AccountID
+	case report.UserID != nil:
+		subject = *report.UserID
+	}
+	if subject == uuid.Nil {
+		return fmt.Sprintf("%s/%s.%s", prefix, report.ID, doc.extension)
+	}
+	return fmt.Sprintf("%s/%s-%s.%s", prefix, subject, report.ID, doc.extension)
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/jobs"
+	"github.com/google/uuid"
+)
+
+const (
+	// reportBatchSize is how many reports one worker tick generates
+	reportBatchSize = 20
+	// staleClaimAfter is when a report left running is assumed abandoned
+	staleClaimAfter = 10 * time.Minute
+)
+
+// RequestStatement queues a monthly statement for one of the user's accounts.
+// month is "YYYY-MM" and must have ended.
+func (s *ReportService) RequestStatement(userID, accountID, month string, isAdmin bool) (*model.Report, error) {
+	account, err := s.readModel.GetAccount(accountID)
+	if err != nil || (!isAdmin && account.UserID.String() != userID) {
+		return nil, ErrAccountNotFound
+	}
+	start, err := s.endedMonth(month)
+	if err != nil {
+		return nil, err
+	}
+	return s.queue(statementReport(account, start), requester(userID))
+}
+
+// RequestTaxSummary queues the user's tax summary for a tax year that has ended
+func (s *ReportService) RequestTaxSummary(userID string, year int) (*model.Report, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrReportNotFound
+	}
+	if year < 2000 {
+		return nil, ErrInvalidPeriod
+	}
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if start.AddDate(1, 0, 0).After(s.now()) {
+		return nil, ErrFutureReport
+	}
+	return s.queue(taxReport(uid, start), requester(userID))
+}
+
+// RequestRegulatoryExport queues the regulatory export of a month that has ended
+func (s *ReportService) RequestRegulatoryExport(requestedBy, month, format string) (*model.Report, error) {
+	f := model.ReportFormat(format)
+	if f != model.FormatCSV && f != model.FormatXBRL {
+		return nil, ErrInvalidFormat
+	}
+	start, err := s.endedMonth(month)
+	if err != nil {
+		return nil, err
+	}
+	return s.queue(regulatoryReport(start, f), requester(requestedBy))
+}
+
+// queue stores a report job, returning the existing one for the same subject
+// and period. A failed report is queued again.
+func (s *ReportService) queue(report *model.Report, requestedBy *uuid.UUID) (*model.Report, error) {
+	report.RequestedBy = requestedBy
+	report.Status = model.ReportPending
+	stored, created, err := s.reports.CreateReport(report)
+	if err != nil {
+		return nil, err
+	}
+	if !created && stored.Status == model.ReportFailed {
+		if err := s.reports.RetryReport(stored.ID); err != nil {
+			return nil, err
+		}
+		stored.Status = model.ReportPending
+		stored.Error = ""
+	}
+	return stored, nil
+}
+
+// ScheduleDue queues the reports owed for the last closed month: a statement
+// for every account, the regulatory exports and, in January, each active
+// user's tax summary for the previous year. Reports already queued are left
+// alone, so calling it repeatedly is safe. It returns how many were queued.
+func (s *ReportService) ScheduleDue(now time.Time) (int, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	monthEnd := monthStart.AddDate(0, 1, 0)
+	// Once a month is scheduled, later ticks in the same month skip the work
+	if monthStart.Equal(s.scheduledMonth) {
+		return 0, nil
+	}
+
+	var due []*model.Report
+	accounts, err := s.readModel.ListAccountsOpenedBefore(monthEnd)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list accounts: %w", err)
+	}
+	for i := range accounts {
+		due = append(due, statementReport(&accounts[i], monthStart))
+	}
+	due = append(due, regulatoryReport(monthStart, model.FormatCSV), regulatoryReport(monthStart, model.FormatXBRL))
+
+	if monthEnd.Month() == time.January {
+		yearStart := time.Date(monthStart.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+		users, err := s.readModel.ListUsersWithTransactions(yearStart, monthEnd)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, u := range users {
+			due = append(due, taxReport(u, yearStart))
+		}
+	}
+
+	queued := 0
+	for _, report := range due {
+		report.Status = model.ReportPending
+		_, created, err := s.reports.CreateReport(report)
+		if err != nil {
+			return queued, err
+		}
+		if created {
+			queued++
+		}
+	}
+	s.scheduledMonth = monthStart
+	return queued, nil
+}
+
+// ProcessReports generates up to one batch of queued reports and returns how many completed
+func (s *ReportService) ProcessReports(ctx context.Context) (int, error) {
+	now := s.now()
+	reports, err := s.reports.ClaimReports(reportBatchSize, now, now.Add(-staleClaimAfter))
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim reports: %w", err)
+	}
+
+	completed := 0
+	for i := range reports {
+		report := &reports[i]
+		if err := s.generate(ctx, report); err != nil {
+			slog.Error("Report generation failed", "report_id", report.ID, "type", report.Type, "error", err)
+			if ferr := s.reports.FailReport(report.ID, err.Error(), s.now()); ferr != nil {
+				return completed, ferr
+			}
+			continue
+		}
+		completed++
+	}
+	return completed, nil
+}
+
+func (s *ReportService) generate(ctx context.Context, report *model.Report) error {
+	var doc *document
+	var err error
+	switch report.Type {
+	case model.ReportMonthlyStatement:
+		doc, err = s.buildStatement(report)
+	case model.ReportTaxSummary:
+		doc, err = s.buildTaxSummary(report)
+	case model.ReportRegulatoryExport:
+		doc, err = s.buildRegulatoryExport(report)
+	default:
+		err = ErrUnknownReportType
+	}
+	if err != nil {
+		return err
+	}
+
+	key := storageKey(report, doc)
+	if err := s.store.PutObject(ctx, key, doc.body, doc.contentType); err != nil {
+		return fmt.Errorf("failed to store report: %w", err)
+	}
+	return s.reports.CompleteReport(report.ID, key, doc.contentType, int64(len(doc.body)), s.now())
+}
+
+// ScheduleReportsJob queues the reports that are due
+func (s *ReportService) ScheduleReportsJob(_ context.Context, _ *jobs.Job) error {
+	queued, err := s.ScheduleDue(s.now())
+	if err != nil {
+		return err
+	}
+	if queued > 0 {
+		slog.Info("Queued scheduled reports", "count", queued)
+	}
+	return nil
+}
+
+// GenerateReportsJob generates queued reports
+func (s *ReportService) GenerateReportsJob(ctx context.Context, _ *jobs.Job) error {
+	completed, err := s.ProcessReports(ctx)
+	if err != nil {
+		return err
+	}
+	if completed > 0 {
+		slog.Info("Generated reports", "count", completed)
+	}
+	return nil
+}
+
+// endedMonth parses "YYYY-MM" and checks the month is over
+func (s *ReportService) endedMonth(month string) (time.Time, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, ErrInvalidPeriod
+	}
+	if start.AddDate(0, 1, 0).After(s.now()) {
+		return time.Time{}, ErrFutureReport
+	}
+	return start, nil
+}
+
+func statementReport(account *model.Account, monthStart time.Time) *model.Report {
+	userID, accountID := account.UserID, account.AccountID
+	return &model.Report{
+		Type:        model.ReportMonthlyStatement,
+		Format:      model.FormatCSV,
+		UserID:      &userID,
+		AccountID:   &accountID,
+		PeriodStart: monthStart,
+		PeriodEnd:   monthStart.AddDate(0, 1, 0),
+		DedupeKey:   fmt.Sprintf("%s:%s:%s:%s", model.ReportMonthlyStatement, accountID, monthStart.Format("2006-01"), model.FormatCSV),
+	}
+}
+
+func taxReport(userID uuid.UUID, yearStart time.Time) *model.Report {
+	return &model.Report{
+		Type:        model.ReportTaxSummary,
+		Format:      model.FormatCSV,
+		UserID:      &userID,
+		PeriodStart: yearStart,
+		PeriodEnd:   yearStart.AddDate(1, 0, 0),
+		DedupeKey:   fmt.Sprintf("%s:%s:%s:%s", model.ReportTaxSummary, userID, strconv.Itoa(yearStart.Year()), model.FormatCSV),
+	}
+}
+
+func regulatoryReport(monthStart time.Time, format model.ReportFormat) *model.Report {
+	return &model.Report{
+		Type:        model.ReportRegulatoryExport,
+		Format:      format,
+		PeriodStart: monthStart,
+		PeriodEnd:   monthStart.AddDate(0, 1, 0),
+		DedupeKey:   fmt.Sprintf("%s:%s:%s", model.ReportRegulatoryExport, monthStart.Format("2006-01"), format),
+	}
+}
+
+// requester is the user who asked for a report, if the ID is valid
+func requester(userID string) *uuid.UUID {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Final payment statuses, from the payment.completed and payment.failed topics
+const (
+	PaymentCompleted = "COMPLETED"
+	PaymentFailed    = "FAILED"
+)
+
+// ApplyAccountCreated adds an account from an account.created event
+func (s *ReportService) ApplyAccountCreated(event kafka.AccountEvent) error {
+	accountID, err := parseEventID("account_id", event.AccountID)
+	if err != nil {
+		return err
+	}
+	userID, err := parseEventID("user_id", event.UserID)
+	if err != nil {
+		return err
+	}
+	return s.readModel.UpsertAccount(&model.Account{
+		AccountID:     accountID,
+		UserID:        userID,
+		AccountNumber: event.AccountNumber,
+		Type:          event.Type,
+		CurrencyCode:  event.CurrencyCode,
+		OpenedAt:      s.eventTime(event.Timestamp),
+	})
+}
+
+// ApplyTransactionCategorized adds a posting from a transaction.categorized
+// event, or updates its category when the posting was recategorized
+func (s *ReportService) ApplyTransactionCategorized(event kafka.TransactionCategorizedEvent) error {
+	postingID, err := parseEventID("posting_id", event.PostingID)
+	if err != nil {
+		return err
+	}
+	transactionID, err := parseEventID("transaction_id", event.TransactionID)
+	if err != nil {
+		return err
+	}
+	accountID, err := parseEventID("account_id", event.AccountID)
+	if err != nil {
+		return err
+	}
+	userID, err := parseEventID("user_id", event.UserID)
+	if err != nil {
+		return err
+	}
+	amount, err := decimal.NewFromString(event.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: amount %q", ErrInvalidEvent, event.Amount)
+	}
+	if event.Direction != 1 && event.Direction != -1 {
+		return fmt.Errorf("%w: direction %d", ErrInvalidEvent, event.Direction)
+	}
+	return s.readModel.UpsertTransaction(&model.Transaction{
+		PostingID:     postingID,
+		TransactionID: transactionID,
+		AccountID:     accountID,
+		UserID:        userID,
+		Amount:        amount,
+		Direction:     event.Direction,
+		CurrencyCode:  event.Currency,
+		Category:      event.Category,
+		Merchant:      event.Merchant,
+		OccurredAt:    s.eventTime(event.Timestamp),
+	})
+}
+
+// ApplyPaymentOutcome records a payment from a payment.completed or payment.failed event
+func (s *ReportService) ApplyPaymentOutcome(event kafka.PaymentEvent, status string) error {
+	if status != PaymentCompleted && status != PaymentFailed {
+		return fmt.Errorf("%w: payment status %q", ErrInvalidEvent, status)
+	}
+	paymentID, err := parseEventID("payment_id", event.PaymentID)
+	if err != nil {
+		return err
+	}
+	from, err := parseEventID("from_account_id", event.FromAccountID)
+	if err != nil {
+		return err
+	}
+	to, err := parseEventID("to_account_id", event.ToAccountID)
+	if err != nil {
+		return err
+	}
+	amount, err := decimal.NewFromString(event.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: amount %q", ErrInvalidEvent, event.Amount)
+	}
+	return s.readModel.UpsertPayment(&model.Payment{
+		PaymentID:     paymentID,
+		FromAccountID: from,
+		ToAccountID:   to,
+		Amount:        amount,
+		CurrencyCode:  event.Currency,
+		Status:        status,
+		Description:   event.Description,
+		OccurredAt:    s.eventTime(event.Timestamp),
+	})
+}
+
+func parseEventID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: %s %q", ErrInvalidEvent, field, value)
+	}
+	return id, nil
+}
+
+// eventTime parses an event timestamp, falling back to the time it was received
+func (s *ReportService) eventTime(timestamp string) time.Time {
+	if t, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return t.UTC()
+	}
+	return s.now().UTC()
+}
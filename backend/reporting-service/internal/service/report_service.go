@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultDownloadExpiry is how long a signed download URL stays valid
+	DefaultDownloadExpiry = 15 * time.Minute
+	// listLimit caps the reports returned by the list endpoints
+	listLimit = 100
+)
+
+var (
+	ErrReportNotFound    = errors.New("report not found")
+	ErrAccountNotFound   = errors.New("account not found")
+	ErrReportNotReady    = errors.New("report has not finished generating")
+	ErrInvalidPeriod     = errors.New("invalid period")
+	ErrInvalidFormat     = errors.New("format is not supported for this report type")
+	ErrInvalidEvent      = errors.New("invalid event")
+	ErrFutureReport      = errors.New("reports can only be generated for periods that have ended")
+	ErrUnknownReportType = errors.New("unknown report type")
+)
+
+// ReadModelRepository stores and queries the projected accounts, transactions and payments
+type ReadModelRepository interface {
+	UpsertAccount(account *model.Account) error
+	UpsertTransaction(txn *model.Transaction) error
+	UpsertPayment(payment *model.Payment) error
+	GetAccount(id string) (*model.Account, error)
+	ListUserAccounts(userID uuid.UUID) ([]model.Account, error)
+	ListAccountsOpenedBefore(at time.Time) ([]model.Account, error)
+	ListUsersWithTransactions(from, to time.Time) ([]uuid.UUID, error)
+	BalanceBefore(accountID uuid.UUID, at time.Time) (decimal.Decimal, error)
+	ListAccountTransactions(accountID uuid.UUID, from, to time.Time) ([]model.Transaction, error)
+	ListUserTransactions(userID uuid.UUID, from, to time.Time) ([]model.Transaction, error)
+	ListPayments(from, to time.Time) ([]model.Payment, error)
+}
+
+// ReportRepository stores report jobs and their results
+type ReportRepository interface {
+	CreateReport(report *model.Report) (*model.Report, bool, error)
+	GetReport(id string) (*model.Report, error)
+	ListUserReports(userID uuid.UUID, limit int) ([]model.Report, error)
+	ListReportsByType(reportType model.ReportType, limit int) ([]model.Report, error)
+	ClaimReports(limit int, now, staleBefore time.Time) ([]model.Report, error)
+	CompleteReport(id uuid.UUID, storageKey, contentType string, size int64, at time.Time) error
+	FailReport(id uuid.UUID, reason string, at time.Time) error
+	RetryReport(id uuid.UUID) error
+}
+
+// ReportService projects ledger and payment events into the reporting read
+// model and generates statements, tax summaries and regulatory exports from it.
+// Generated files are written to the object store and downloaded through
+// short-lived signed URLs.
+type ReportService struct {
+	readModel ReadModelRepository
+	reports   ReportRepository
+	store     aws.ObjectStore
+
+	// DownloadExpiry is the validity of signed download URLs
+	DownloadExpiry time.Duration
+	// LargePaymentThreshold is the amount from which payments are itemised in regulatory exports
+	LargePaymentThreshold decimal.Decimal
+	// Institution identifies the bank in regulatory exports
+	Institution string
+
+	// scheduledMonth is the last month ScheduleDue queued reports for
+	scheduledMonth time.Time
+	now            func() time.Time
+}
+
+func NewReportService(readModel ReadModelRepository, reports ReportRepository, store aws.ObjectStore) *ReportService {
+	return &ReportService{
+		readModel:             readModel,
+		reports:               reports,
+		store:                 store,
+		DownloadExpiry:        DefaultDownloadExpiry,
+		LargePaymentThreshold: decimal.NewFromInt(10000),
+		Institution:           "NeoBank",
+		now:                   time.Now,
+	}
+}
+
+// DownloadLink is a signed URL for a completed report
+type DownloadLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ListUserReports returns the statements and tax summaries of a user
+func (s *ReportService) ListUserReports(userID string) ([]model.Report, error) {
+	uid, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, ErrReportNotFound
+	}
+	reports, err := s.reports.ListUserReports(uid, listLimit)
+	if reports == nil {
+		reports = []model.Report{}
+	}
+	return reports, err
+}
+
+// ListReportsByType returns reports of one type for administrators
+func (s *ReportService) ListReportsByType(reportType string) ([]model.Report, error) {
+	switch t := model.ReportType(reportType); t {
+	case model.ReportMonthlyStatement, model.ReportTaxSummary, model.ReportRegulatoryExport:
+		reports, err := s.reports.ListReportsByType(t, listLimit)
+		if reports == nil {
+			reports = []model.Report{}
+		}
+		return reports, err
+	}
+	return nil, ErrUnknownReportType
+}
+
+// GetReport returns a report its owner, or an administrator, may see
+func (s *ReportService) GetReport(id, userID string, isAdmin bool) (*model.Report, error) {
+	report, err := s.reports.GetReport(id)
+	if err != nil {
+		return nil, ErrReportNotFound
+	}
+	// Other users' reports are reported as missing rather than forbidden
+	if !isAdmin && (report.UserID == nil || report.UserID.String() != userID) {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
+
+// DownloadURL signs a time-limited URL for a completed report
+func (s *ReportService) DownloadURL(ctx context.Context, id, userID string, isAdmin bool) (*DownloadLink, error) {
+	report, err := s.GetReport(id, userID, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if report.Status != model.ReportCompleted {
+		return nil, ErrReportNotReady
+	}
+	url, err := s.store.PresignGetURL(ctx, report.StorageKey, s.DownloadExpiry)
+	if err != nil {
+		return nil, err
+	}
+	return &DownloadLink{URL: url, ExpiresAt: s.now().Add(s.DownloadExpiry).UTC()}, nil
+}
@@ -0,0 +1,499 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/reporting-service/internal/model"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/kafka"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// memoryReadModel is an in-memory ReadModelRepository with the same upsert rules as the real one
+type memoryReadModel struct {
+	accounts     map[uuid.UUID]model.Account
+	transactions map[uuid.UUID]model.Transaction
+	payments     map[uuid.UUID]model.Payment
+}
+
+func newMemoryReadModel() *memoryReadModel {
+	return &memoryReadModel{
+		accounts:     map[uuid.UUID]model.Account{},
+		transactions: map[uuid.UUID]model.Transaction{},
+		payments:     map[uuid.UUID]model.Payment{},
+	}
+}
+
+func (m *memoryReadModel) UpsertAccount(account *model.Account) error {
+	if _, ok := m.accounts[account.AccountID]; !ok {
+		m.accounts[account.AccountID] = *account
+	}
+	return nil
+}
+
+func (m *memoryReadModel) UpsertTransaction(txn *model.Transaction) error {
+	if existing, ok := m.transactions[txn.PostingID]; ok {
+		existing.Category, existing.Merchant = txn.Category, txn.Merchant
+		m.transactions[txn.PostingID] = existing
+		return nil
+	}
+	m.transactions[txn.PostingID] = *txn
+	return nil
+}
+
+func (m *memoryReadModel) UpsertPayment(payment *model.Payment) error {
+	if existing, ok := m.payments[payment.PaymentID]; ok {
+		existing.Status, existing.OccurredAt = payment.Status, payment.OccurredAt
+		m.payments[payment.PaymentID] = existing
+		return nil
+	}
+	m.payments[payment.PaymentID] = *payment
+	return nil
+}
+
+func (m *memoryReadModel) GetAccount(id string) (*model.Account, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	account, ok := m.accounts[uid]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &account, nil
+}
+
+func (m *memoryReadModel) ListUserAccounts(userID uuid.UUID) ([]model.Account, error) {
+	return m.filterAccounts(func(a model.Account) bool { return a.UserID == userID }), nil
+}
+
+func (m *memoryReadModel) ListAccountsOpenedBefore(at time.Time) ([]model.Account, error) {
+	return m.filterAccounts(func(a model.Account) bool { return a.OpenedAt.Before(at) }), nil
+}
+
+func (m *memoryReadModel) ListUsersWithTransactions(from, to time.Time) ([]uuid.UUID, error) {
+	seen := map[uuid.UUID]bool{}
+	var users []uuid.UUID
+	for _, t := range m.filterTransactions(func(model.Transaction) bool { return true }, from, to) {
+		if !seen[t.UserID] {
+			seen[t.UserID] = true
+			users = append(users, t.UserID)
+		}
+	}
+	return users, nil
+}
+
+func (m *memoryReadModel) BalanceBefore(accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	balance := decimal.Zero
+	for _, t := range m.filterTransactions(func(t model.Transaction) bool { return t.AccountID == accountID }, time.Time{}, at) {
+		balance = balance.Add(t.SignedAmount())
+	}
+	return balance, nil
+}
+
+func (m *memoryReadModel) ListAccountTransactions(accountID uuid.UUID, from, to time.Time) ([]model.Transaction, error) {
+	return m.filterTransactions(func(t model.Transaction) bool { return t.AccountID == accountID }, from, to), nil
+}
+
+func (m *memoryReadModel) ListUserTransactions(userID uuid.UUID, from, to time.Time) ([]model.Transaction, error) {
+	return m.filterTransactions(func(t model.Transaction) bool { return t.UserID == userID }, from, to), nil
+}
+
+func (m *memoryReadModel) ListPayments(from, to time.Time) ([]model.Payment, error) {
+	var out []model.Payment
+	for _, p := range m.payments {
+		if !p.OccurredAt.Before(from) && p.OccurredAt.Before(to) {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OccurredAt.Before(out[j].OccurredAt) })
+	return out, nil
+}
+
+func (m *memoryReadModel) filterAccounts(keep func(model.Account) bool) []model.Account {
+	var out []model.Account
+	for _, a := range m.accounts {
+		if keep(a) {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OpenedAt.Before(out[j].OpenedAt) })
+	return out
+}
+
+func (m *memoryReadModel) filterTransactions(keep func(model.Transaction) bool, from, to time.Time) []model.Transaction {
+	var out []model.Transaction
+	for _, t := range m.transactions {
+		if keep(t) && !t.OccurredAt.Before(from) && t.OccurredAt.Before(to) {
+			out = append(out, t)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OccurredAt.Before(out[j].OccurredAt) })
+	return out
+}
+
+// memoryReports is an in-memory ReportRepository that enforces the dedupe key like the unique index
+type memoryReports struct {
+	byID map[uuid.UUID]*model.Report
+}
+
+func newMemoryReports() *memoryReports {
+	return &memoryReports{byID: map[uuid.UUID]*model.Report{}}
+}
+
+func (m *memoryReports) CreateReport(report *model.Report) (*model.Report, bool, error) {
+	for _, existing := range m.byID {
+		if existing.DedupeKey == report.DedupeKey {
+			copied := *existing
+			return &copied, false, nil
+		}
+	}
+	report.ID = uuid.New()
+	stored := *report
+	m.byID[report.ID] = &stored
+	return report, true, nil
+}
+
+func (m *memoryReports) GetReport(id string) (*model.Report, error) {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	report, ok := m.byID[uid]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *report
+	return &copied, nil
+}
+
+func (m *memoryReports) ListUserReports(userID uuid.UUID, limit int) ([]model.Report, error) {
+	var out []model.Report
+	for _, r := range m.byID {
+		if r.UserID != nil && *r.UserID == userID {
+			out = append(out, *r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryReports) ListReportsByType(reportType model.ReportType, limit int) ([]model.Report, error) {
+	var out []model.Report
+	for _, r := range m.byID {
+		if r.Type == reportType {
+			out = append(out, *r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryReports) ClaimReports(limit int, now, staleBefore time.Time) ([]model.Report, error) {
+	var out []model.Report
+	for _, r := range m.byID {
+		if len(out) == limit {
+			break
+		}
+		if r.Status == model.ReportPending || (r.Status == model.ReportRunning && r.ClaimedAt.Before(staleBefore)) {
+			r.Status = model.ReportRunning
+			claimed := now
+			r.ClaimedAt = &claimed
+			out = append(out, *r)
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryReports) CompleteReport(id uuid.UUID, storageKey, contentType string, size int64, at time.Time) error {
+	r := m.byID[id]
+	r.Status, r.StorageKey, r.ContentType, r.SizeBytes, r.Error, r.CompletedAt = model.ReportCompleted, storageKey, contentType, size, "", &at
+	return nil
+}
+
+func (m *memoryReports) FailReport(id uuid.UUID, reason string, at time.Time) error {
+	r := m.byID[id]
+	r.Status, r.Error, r.CompletedAt = model.ReportFailed, reason, &at
+	return nil
+}
+
+func (m *memoryReports) RetryReport(id uuid.UUID) error {
+	r := m.byID[id]
+	if r.Status == model.ReportFailed {
+		r.Status, r.Error, r.ClaimedAt, r.CompletedAt = model.ReportPending, "", nil, nil
+	}
+	return nil
+}
+
+func (m *memoryReports) byType(reportType model.ReportType) []*model.Report {
+	var out []*model.Report
+	for _, r := range m.byID {
+		if r.Type == reportType {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func newTestService(t *testing.T, now time.Time) (*ReportService, *memoryReadModel, *memoryReports, *aws.LocalObjectStore) {
+	store, err := aws.NewLocalObjectStore(t.TempDir(), "http://localhost:8086/reports/files", "secret")
+	require.NoError(t, err)
+	readModel, reports := newMemoryReadModel(), newMemoryReports()
+	svc := NewReportService(readModel, reports, store)
+	svc.now = func() time.Time { return now }
+	return svc, readModel, reports, store
+}
+
+func posting(t *testing.T, svc *ReportService, accountID, userID uuid.UUID, amount string, direction int, category string, at time.Time) {
+	require.NoError(t, svc.ApplyTransactionCategorized(kafka.TransactionCategorizedEvent{
+		TransactionID: uuid.NewString(),
+		PostingID:     uuid.NewString(),
+		AccountID:     accountID.String(),
+		UserID:        userID.String(),
+		Category:      category,
+		Amount:        amount,
+		Currency:      "USD",
+		Direction:     direction,
+		Timestamp:     at.Format(time.RFC3339),
+	}))
+}
+
+func openAccount(t *testing.T, svc *ReportService, userID uuid.UUID, at time.Time) uuid.UUID {
+	accountID := uuid.New()
+	require.NoError(t, svc.ApplyAccountCreated(kafka.AccountEvent{
+		AccountID:     accountID.String(),
+		UserID:        userID.String(),
+		AccountNumber: "NB" + accountID.String()[:8],
+		Type:          "CHECKING",
+		CurrencyCode:  "USD",
+		Timestamp:     at.Format(time.RFC3339),
+	}))
+	return accountID
+}
+
+func TestProjection_RecategorizationUpdatesPosting(t *testing.T) {
+	svc, readModel, _, _ := newTestService(t, time.Now())
+	event := kafka.TransactionCategorizedEvent{
+		TransactionID: uuid.NewString(),
+		PostingID:     uuid.NewString(),
+		AccountID:     uuid.NewString(),
+		UserID:        uuid.NewString(),
+		Category:      "SHOPPING",
+		Amount:        "25.00",
+		Currency:      "USD",
+		Direction:     -1,
+		Timestamp:     "2026-05-03T10:00:00Z",
+	}
+	require.NoError(t, svc.ApplyTransactionCategorized(event))
+	event.Category = "GROCERIES"
+	require.NoError(t, svc.ApplyTransactionCategorized(event))
+
+	require.Len(t, readModel.transactions, 1)
+	for _, txn := range readModel.transactions {
+		assert.Equal(t, "GROCERIES", txn.Category)
+		assert.Equal(t, "-25", txn.SignedAmount().String())
+	}
+
+	event.PostingID = "not-a-uuid"
+	assert.ErrorIs(t, svc.ApplyTransactionCategorized(event), ErrInvalidEvent)
+	event.PostingID, event.Direction = uuid.NewString(), 0
+	assert.ErrorIs(t, svc.ApplyTransactionCategorized(event), ErrInvalidEvent)
+}
+
+func TestScheduleDue_QueuesLastMonthOnce(t *testing.T) {
+	now := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	svc, _, reports, _ := newTestService(t, now)
+	user := uuid.New()
+	openAccount(t, svc, user, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	openAccount(t, svc, user, time.Date(2026, time.May, 20, 0, 0, 0, 0, time.UTC))
+	// Opened after the month closed, so it gets no May statement
+	openAccount(t, svc, user, time.Date(2026, time.June, 1, 8, 0, 0, 0, time.UTC))
+
+	queued, err := svc.ScheduleDue(now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, queued, "two statements and the CSV and XBRL regulatory exports")
+	assert.Len(t, reports.byType(model.ReportMonthlyStatement), 2)
+	assert.Len(t, reports.byType(model.ReportRegulatoryExport), 2)
+	assert.Empty(t, reports.byType(model.ReportTaxSummary), "tax summaries are only due in January")
+
+	// A restarted worker schedules the same month again without duplicates
+	svc.scheduledMonth = time.Time{}
+	queued, err = svc.ScheduleDue(now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, queued)
+	assert.Len(t, reports.byID, 4)
+}
+
+func TestScheduleDue_TaxSummariesInJanuary(t *testing.T) {
+	now := time.Date(2027, time.January, 1, 0, 5, 0, 0, time.UTC)
+	svc, _, reports, _ := newTestService(t, now)
+	active, idle := uuid.New(), uuid.New()
+	activeAccount := openAccount(t, svc, active, time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC))
+	openAccount(t, svc, idle, time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC))
+	posting(t, svc, activeAccount, active, "100.00", 1, "INCOME", time.Date(2026, time.July, 1, 0, 0, 0, 0, time.UTC))
+
+	_, err := svc.ScheduleDue(now)
+	require.NoError(t, err)
+	tax := reports.byType(model.ReportTaxSummary)
+	require.Len(t, tax, 1)
+	assert.Equal(t, active, *tax[0].UserID)
+	assert.Equal(t, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), tax[0].PeriodStart)
+	assert.Equal(t, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), tax[0].PeriodEnd)
+}
+
+func TestProcessReports_StatementWithRunningBalance(t *testing.T) {
+	now := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	svc, _, reports, store := newTestService(t, now)
+	user := uuid.New()
+	account := openAccount(t, svc, user, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	posting(t, svc, account, user, "500.00", 1, "INCOME", time.Date(2026, time.April, 30, 12, 0, 0, 0, time.UTC))
+	posting(t, svc, account, user, "42.50", -1, "GROCERIES", time.Date(2026, time.May, 3, 12, 0, 0, 0, time.UTC))
+	posting(t, svc, account, user, "100.00", 1, "INCOME", time.Date(2026, time.May, 28, 12, 0, 0, 0, time.UTC))
+	posting(t, svc, account, user, "9.99", -1, "SHOPPING", time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC))
+
+	report, err := svc.RequestStatement(user.String(), account.String(), "2026-05", false)
+	require.NoError(t, err)
+	assert.Equal(t, model.ReportPending, report.Status)
+
+	completed, err := svc.ProcessReports(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, completed)
+
+	stored := reports.byID[report.ID]
+	require.Equal(t, model.ReportCompleted, stored.Status)
+	assert.Equal(t, "statements/2026-05/"+account.String()+"-"+report.ID.String()+".csv", stored.StorageKey)
+	body, err := store.GetObject(context.Background(), stored.StorageKey)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(body), stored.SizeBytes)
+
+	csv := string(body)
+	assert.Contains(t, csv, "Period,2026-05-01,2026-05-31\n")
+	assert.Contains(t, csv, "Opening balance,500.00\n")
+	assert.Contains(t, csv, "Closing balance,557.50\n")
+	assert.Contains(t, csv, ",GROCERIES,,42.50,457.50\n")
+	assert.Contains(t, csv, ",INCOME,100.00,,557.50\n")
+	assert.NotContains(t, csv, "SHOPPING", "June postings are not on the May statement")
+
+	link, err := svc.DownloadURL(context.Background(), report.ID.String(), user.String(), false)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link.URL, "http://localhost:8086/reports/files?"))
+	assert.Equal(t, now.Add(DefaultDownloadExpiry), link.ExpiresAt)
+}
+
+func TestRequestStatement_Validation(t *testing.T) {
+	now := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	svc, _, reports, _ := newTestService(t, now)
+	owner := uuid.New()
+	account := openAccount(t, svc, owner, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+
+	_, err := svc.RequestStatement(uuid.NewString(), account.String(), "2026-05", false)
+	assert.ErrorIs(t, err, ErrAccountNotFound, "another user's account")
+	_, err = svc.RequestStatement(owner.String(), account.String(), "2026-06", false)
+	assert.ErrorIs(t, err, ErrFutureReport)
+	_, err = svc.RequestStatement(owner.String(), account.String(), "May 2026", false)
+	assert.ErrorIs(t, err, ErrInvalidPeriod)
+
+	first, err := svc.RequestStatement(owner.String(), account.String(), "2026-05", false)
+	require.NoError(t, err)
+	again, err := svc.RequestStatement(uuid.NewString(), account.String(), "2026-05", true)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "an admin request reuses the queued statement")
+	assert.Len(t, reports.byID, 1)
+}
+
+func TestDownloadURL_AccessAndReadiness(t *testing.T) {
+	now := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	svc, _, _, _ := newTestService(t, now)
+	owner := uuid.New()
+	account := openAccount(t, svc, owner, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	report, err := svc.RequestStatement(owner.String(), account.String(), "2026-05", false)
+	require.NoError(t, err)
+
+	_, err = svc.DownloadURL(context.Background(), report.ID.String(), owner.String(), false)
+	assert.ErrorIs(t, err, ErrReportNotReady)
+	_, err = svc.DownloadURL(context.Background(), report.ID.String(), uuid.NewString(), false)
+	assert.ErrorIs(t, err, ErrReportNotFound)
+
+	export, err := svc.RequestRegulatoryExport(uuid.NewString(), "2026-05", "CSV")
+	require.NoError(t, err)
+	_, err = svc.GetReport(export.ID.String(), owner.String(), false)
+	assert.ErrorIs(t, err, ErrReportNotFound, "regulatory exports are admin only")
+	_, err = svc.GetReport(export.ID.String(), uuid.NewString(), true)
+	assert.NoError(t, err)
+}
+
+func TestProcessReports_FailedReportIsRequeued(t *testing.T) {
+	now := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	svc, readModel, reports, _ := newTestService(t, now)
+	owner := uuid.New()
+	account := openAccount(t, svc, owner, time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC))
+	report, err := svc.RequestStatement(owner.String(), account.String(), "2026-05", false)
+	require.NoError(t, err)
+
+	// The account disappears from the read model before generation
+	saved := readModel.accounts[account]
+	delete(readModel.accounts, account)
+	completed, err := svc.ProcessReports(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, completed)
+	assert.Equal(t, model.ReportFailed, reports.byID[report.ID].Status)
+	assert.Equal(t, ErrAccountNotFound.Error(), reports.byID[report.ID].Error)
+
+	readModel.accounts[account] = saved
+	again, err := svc.RequestStatement(owner.String(), account.String(), "2026-05", false)
+	require.NoError(t, err)
+	assert.Equal(t, report.ID, again.ID)
+	assert.Equal(t, model.ReportPending, again.Status)
+	completed, err = svc.ProcessReports(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, completed)
+}
+
+func TestRegulatoryExport_CSVAndXBRL(t *testing.T) {
+	now := time.Date(2026, time.June, 2, 9, 0, 0, 0, time.UTC)
+	svc, _, reports, store := newTestService(t, now)
+	svc.LargePaymentThreshold = decimal.NewFromInt(1000)
+	payment := func(amount, currency, status string) {
+		require.NoError(t, svc.ApplyPaymentOutcome(kafka.PaymentEvent{
+			PaymentID:     uuid.NewString(),
+			FromAccountID: uuid.NewString(),
+			ToAccountID:   uuid.NewString(),
+			Amount:        amount,
+			Currency:      currency,
+			Timestamp:     "2026-05-15T10:00:00Z",
+		}, status))
+	}
+	payment("250.00", "USD", PaymentCompleted)
+	payment("1500.00", "USD", PaymentCompleted)
+	payment("80.00", "USD", PaymentFailed)
+	payment("40.00", "EUR", PaymentCompleted)
+
+	_, err := svc.RequestRegulatoryExport(uuid.NewString(), "2026-05", "PDF")
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+	csvReport, err := svc.RequestRegulatoryExport(uuid.NewString(), "2026-05", "CSV")
+	require.NoError(t, err)
+	xbrlReport, err := svc.RequestRegulatoryExport(uuid.NewString(), "2026-05", "XBRL")
+	require.NoError(t, err)
+	completed, err := svc.ProcessReports(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, completed)
+
+	body, err := store.GetObject(context.Background(), reports.byID[csvReport.ID].StorageKey)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "EUR,1,40.00,0,0\nUSD,2,1750.00,1,1\n")
+	assert.Contains(t, string(body), ",USD,1500.00\n")
+
+	stored := reports.byID[xbrlReport.ID]
+	assert.Equal(t, "application/xml", stored.ContentType)
+	assert.True(t, strings.HasSuffix(stored.StorageKey, ".xbrl"))
+	body, err = store.GetObject(context.Background(), stored.StorageKey)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `<nb:CompletedPaymentsValue contextRef="period" unitRef="USD" decimals="2">1750.00</nb:CompletedPaymentsValue>`)
+	assert.Contains(t, string(body), `<xbrli:endDate>2026-05-31</xbrli:endDate>`)
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS report_payments;
+DROP TABLE IF EXISTS report_transactions;
+DROP TABLE IF EXISTS report_accounts;
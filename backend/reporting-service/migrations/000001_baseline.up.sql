@@ -0,0 +1,65 @@
+CREATE TABLE report_accounts (
+    account_id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    account_number varchar(34),
+    type varchar(20),
+    currency_code char(3) NOT NULL,
+    opened_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX idx_report_accounts_user_id ON report_accounts (user_id);
+
+CREATE TABLE report_transactions (
+    posting_id uuid PRIMARY KEY,
+    transaction_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    direction bigint NOT NULL,
+    currency_code char(3) NOT NULL,
+    category varchar(20),
+    merchant varchar(255),
+    occurred_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_report_transactions_transaction_id ON report_transactions (transaction_id);
+CREATE INDEX idx_report_transactions_user_id ON report_transactions (user_id);
+CREATE INDEX idx_report_transactions_account_time ON report_transactions (account_id, occurred_at);
+
+CREATE TABLE report_payments (
+    payment_id uuid PRIMARY KEY,
+    from_account_id uuid NOT NULL,
+    to_account_id uuid NOT NULL,
+    amount numeric(19,4) NOT NULL,
+    currency_code char(3) NOT NULL,
+    status varchar(20) NOT NULL,
+    description varchar(255),
+    occurred_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_report_payments_occurred_at ON report_payments (occurred_at);
+
+CREATE TABLE reports (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    type varchar(30) NOT NULL,
+    format varchar(10) NOT NULL,
+    user_id uuid,
+    account_id uuid,
+    period_start date NOT NULL,
+    period_end date NOT NULL,
+    dedupe_key varchar(200) NOT NULL,
+    status varchar(20) NOT NULL,
+    storage_key varchar(255),
+    content_type varchar(100),
+    size_bytes bigint NOT NULL DEFAULT 0,
+    error text,
+    requested_by uuid,
+    claimed_at timestamptz,
+    created_at timestamptz,
+    completed_at timestamptz
+);
+CREATE UNIQUE INDEX idx_reports_dedupe_key ON reports (dedupe_key);
+CREATE INDEX idx_reports_user_id ON reports (user_id);
+CREATE INDEX idx_reports_status ON reports (status);
@@ -0,0 +1,22 @@
+-- Background job queue of shared-lib pkg/jobs
+CREATE TABLE jobs (
+    id uuid PRIMARY KEY,
+    kind varchar(100) NOT NULL,
+    payload jsonb,
+    status varchar(20) NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    max_attempts bigint NOT NULL,
+    run_at timestamptz NOT NULL,
+    unique_key varchar(255),
+    locked_by varchar(255),
+    locked_until timestamptz,
+    last_error text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    completed_at timestamptz
+);
+-- Scheduled runs are enqueued by every replica under the same key
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs (unique_key);
+-- Workers claim the oldest due job; the admin API lists by kind
+CREATE INDEX idx_jobs_status_run_at ON jobs (status, run_at);
+CREATE INDEX idx_jobs_kind_created_at ON jobs (kind, created_at);
@@ -0,0 +1,118 @@
+package aws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidObjectKey   = errors.New("invalid object key")
+	ErrDownloadURLInvalid = errors.New("download link is invalid")
+	ErrDownloadURLExpired = errors.New("download link has expired")
+)
+
+// LocalObjectStore keeps objects on disk for local development. Its download
+// URLs point at the service itself (BaseURL) and carry an HMAC signature and
+// expiry, which the serving handler checks with VerifyDownload.
+type LocalObjectStore struct {
+	dir     string
+	baseURL string
+	secret  []byte
+	now     func() time.Time
+}
+
+// NewLocalObjectStore stores objects under dir. baseURL is the endpoint that
+// serves them, e.g. "http://localhost:8086/reports/files".
+func NewLocalObjectStore(dir, baseURL, secret string) (*LocalObjectStore, error) {
+	if secret == "" {
+		return nil, errors.New("local object store needs a signing secret")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalObjectStore{dir: dir, baseURL: baseURL, secret: []byte(secret), now: time.Now}, nil
+}
+
+func (s *LocalObjectStore) PutObject(_ context.Context, key string, body []byte, _ string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, body, 0o640)
+}
+
+func (s *LocalObjectStore) GetObject(_ context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return data, err
+}
+
+func (s *LocalObjectStore) PresignGetURL(_ context.Context, key string, expiry time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	if expiry <= 0 || expiry > MaxPresignExpiry {
+		return "", fmt.Errorf("presign expiry must be between 1s and %s", MaxPresignExpiry)
+	}
+	expires := strconv.FormatInt(s.now().Add(expiry).Unix(), 10)
+	query := url.Values{}
+	query.Set("key", key)
+	query.Set("expires", expires)
+	query.Set("signature", s.signature(key, expires))
+	return s.baseURL + "?" + query.Encode(), nil
+}
+
+// VerifyDownload checks the key, expires and signature query parameters of a
+// URL from PresignGetURL
+func (s *LocalObjectStore) VerifyDownload(key, expires, signature string) error {
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, expires))) {
+		return ErrDownloadURLInvalid
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrDownloadURLInvalid
+	}
+	if s.now().Unix() > unix {
+		return ErrDownloadURLExpired
+	}
+	return nil
+}
+
+func (s *LocalObjectStore) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path maps a key to a file under the store's directory, rejecting keys that
+// would escape it
+func (s *LocalObjectStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidObjectKey
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return "", ErrInvalidObjectKey
+		}
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 stores objects in memory and records the signed requests it receives
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	requests []*http.Request
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r)
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})
+	store := NewS3StoreWithCredentials(S3Config{Bucket: "reports", Region: "eu-west-2", Endpoint: srv.URL}, creds)
+	ctx := context.Background()
+
+	require.NoError(t, store.PutObject(ctx, "statements/2026-05/a b.csv", []byte("date,amount\n"), "text/csv"))
+	put := fake.requests[0]
+	assert.Equal(t, "/reports/statements/2026-05/a b.csv", put.URL.Path)
+	assert.True(t, strings.HasPrefix(put.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(t, put.Header.Get("Authorization"), "/eu-west-2/s3/aws4_request")
+	assert.Len(t, put.Header.Get("X-Amz-Content-Sha256"), 64)
+	assert.Equal(t, "text/csv", put.Header.Get("Content-Type"))
+
+	body, err := store.GetObject(ctx, "statements/2026-05/a b.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "date,amount\n", string(body))
+
+	_, err = store.GetObject(ctx, "missing.csv")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+
+	signed, err := store.PresignGetURL(ctx, "statements/2026-05/a b.csv", 15*time.Minute)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	assert.Equal(t, "900", u.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+	assert.Equal(t, "AWS4-HMAC-SHA256", u.Query().Get("X-Amz-Algorithm"))
+
+	_, err = store.PresignGetURL(ctx, "k", 8*24*time.Hour)
+	assert.Error(t, err)
+}
+
+func TestS3Store_VirtualHostedURL(t *testing.T) {
+	store := NewS3StoreWithCredentials(S3Config{Bucket: "reports", Region: "us-east-1"}, aws.AnonymousCredentials{})
+	assert.Equal(t, "https://reports.s3.us-east-1.amazonaws.com/tax/2025/x%3Fy.csv", store.objectURL("tax/2025/x?y.csv"))
+}
+
+func TestLocalObjectStore(t *testing.T) {
+	store, err := NewLocalObjectStore(t.TempDir(), "http://localhost:8086/reports/files", "secret")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, store.PutObject(ctx, "statements/2026-05/report.csv", []byte("data"), "text/csv"))
+	data, err := store.GetObject(ctx, "statements/2026-05/report.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+
+	_, err = store.GetObject(ctx, "statements/missing.csv")
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+	for _, key := range []string{"../escape.csv", "/abs.csv", "a//b.csv", ""} {
+		assert.ErrorIs(t, store.PutObject(ctx, key, nil, ""), ErrInvalidObjectKey, key)
+	}
+
+	signed, err := store.PresignGetURL(ctx, "statements/2026-05/report.csv", time.Hour)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "/reports/files", u.Path)
+	assert.NoError(t, store.VerifyDownload(q.Get("key"), q.Get("expires"), q.Get("signature")))
+	assert.ErrorIs(t, store.VerifyDownload("statements/other.csv", q.Get("expires"), q.Get("signature")), ErrDownloadURLInvalid)
+
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	assert.ErrorIs(t, store.VerifyDownload(q.Get("key"), q.Get("expires"), q.Get("signature")), ErrDownloadURLExpired)
+
+	_, err = NewLocalObjectStore(t.TempDir(), "http://localhost", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,177 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// MaxPresignExpiry is the longest validity S3 accepts for a presigned URL
+const MaxPresignExpiry = 7 * 24 * time.Hour
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore stores generated files and hands out time-limited download URLs.
+// S3Store is used on AWS; LocalObjectStore is the local development fallback.
+type ObjectStore interface {
+	PutObject(ctx context.Context, key string, body []byte, contentType string) error
+	GetObject(ctx context.Context, key string) ([]byte, error)
+	PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+}
+
+// S3Config configures an S3Store
+type S3Config struct {
+	Bucket string
+	Region string
+	// Endpoint overrides the S3 endpoint, e.g. for LocalStack or MinIO.
+	// Requests then use path-style addressing.
+	Endpoint string
+}
+
+// S3Store is an ObjectStore backed by an S3 bucket. Requests are signed with
+// SigV4 directly, so only the core SDK is needed.
+type S3Store struct {
+	bucket      string
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewS3Store creates an S3 store using the default AWS credential chain
+func NewS3Store(ctx context.Context, cfg S3Config) (*S3Store, error) {
+	if cfg.Region == "" {
+		cfg.Region = GetRegion()
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return NewS3StoreWithCredentials(cfg, awsCfg.Credentials), nil
+}
+
+// NewS3StoreWithCredentials creates an S3 store with explicit credentials
+func NewS3StoreWithCredentials(cfg S3Config, credentials aws.CredentialsProvider) *S3Store {
+	if cfg.Region == "" {
+		cfg.Region = GetRegion()
+	}
+	return &S3Store{
+		bucket:      cfg.Bucket,
+		region:      cfg.Region,
+		endpoint:    strings.TrimRight(cfg.Endpoint, "/"),
+		credentials: credentials,
+		// S3 signs the path exactly as sent rather than escaping it a second time
+		signer: v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true }),
+		client: &http.Client{Timeout: 30 * time.Second},
+		now:    time.Now,
+	}
+}
+
+func (s *S3Store) PutObject(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	sum := sha256.Sum256(body)
+	if err := s.sign(ctx, req, hex.EncodeToString(sum[:])); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3 put %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", key, resp)
+	}
+	return nil
+}
+
+func (s *S3Store) GetObject(ctx context.Context, key string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	// sha256 of an empty body
+	if err := s.sign(ctx, req, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 get %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error("get", key, resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// PresignGetURL returns a URL anyone can use to download the object until it expires
+func (s *S3Store) PresignGetURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	if expiry <= 0 || expiry > MaxPresignExpiry {
+		return "", fmt.Errorf("presign expiry must be between 1s and %s", MaxPresignExpiry)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expiry/time.Second), 10))
+	req.URL.RawQuery = query.Encode()
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	signed, _, err := s.signer.PresignHTTP(ctx, creds, req, "UNSIGNED-PAYLOAD", "s3", s.region, s.now())
+	return signed, err
+}
+
+func (s *S3Store) sign(ctx context.Context, req *http.Request, payloadHash string) error {
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	return s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, s.now())
+}
+
+// objectURL uses virtual-hosted addressing on AWS and path-style addressing on a custom endpoint
+func (s *S3Store) objectURL(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	path := strings.Join(segments, "/")
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + "/" + path
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, path)
+}
+
+func s3Error(op, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s: status %d: %s", op, key, resp.StatusCode, strings.TrimSpace(string(body)))
+}
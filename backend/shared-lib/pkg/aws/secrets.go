@@ -111,10 +111,37 @@ func (p *SecretsProvider) GetSecretJSON(ctx context.Context, secretName string,
 	return json.Unmarshal([]byte(value), target)
 }
 
+// Version stages Secrets Manager labels a rotated secret's values with
+const (
+	StageCurrent  = "AWSCURRENT"
+	StagePrevious = "AWSPREVIOUS"
+)
+
+// GetSecretStage retrieves the value of a secret at a version stage, such as
+// StagePrevious during a rotation, bypassing the cache so a rotated value is
+// seen as soon as it is published. Local secrets name the value of a stage
+// other than StageCurrent "<secretName>:<stage>".
+func (p *SecretsProvider) GetSecretStage(ctx context.Context, secretName, stage string) (string, error) {
+	if p.useLocal {
+		if stage != StageCurrent {
+			secretName += ":" + stage
+		}
+		return p.getLocalSecret(secretName)
+	}
+	return p.getAWSSecretStage(ctx, secretName, stage)
+}
+
 func (p *SecretsProvider) getAWSSecret(ctx context.Context, secretName string) (string, error) {
+	return p.getAWSSecretStage(ctx, secretName, "")
+}
+
+func (p *SecretsProvider) getAWSSecretStage(ctx context.Context, secretName, stage string) (string, error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId: aws.String(secretName),
 	}
+	if stage != "" {
+		input.VersionStage = aws.String(stage)
+	}
 
 	result, err := p.client.GetSecretValue(ctx, input)
 	if err != nil {
@@ -118,6 +118,27 @@ func TestSecretsProvider_Local(t *testing.T) {
 	})
 }
 
+func TestSecretsProvider_GetSecretStage_Local(t *testing.T) {
+	path := t.TempDir() + "/secrets.json"
+	require.NoError(t, os.WriteFile(path, []byte(`{"jwt-secret": "new", "jwt-secret:AWSPREVIOUS": "old"}`), 0600))
+	ctx := context.Background()
+	provider, err := NewSecretsProvider(ctx, SecretsConfig{UseLocal: true, LocalPath: path})
+	require.NoError(t, err)
+
+	current, err := provider.GetSecretStage(ctx, "jwt-secret", StageCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, "new", current)
+	previous, err := provider.GetSecretStage(ctx, "jwt-secret", StagePrevious)
+	require.NoError(t, err)
+	assert.Equal(t, "old", previous)
+
+	// Stages are read afresh, so a rotation is seen straight away
+	require.NoError(t, os.WriteFile(path, []byte(`{"jwt-secret": "newer", "jwt-secret:AWSPREVIOUS": "new"}`), 0600))
+	current, err = provider.GetSecretStage(ctx, "jwt-secret", StageCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, "newer", current)
+}
+
 func TestIsRunningOnAWS(t *testing.T) {
 	// Save original env vars
 	origToken := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/circuitbreaker"
+	"github.com/redis/go-redis/v9"
+)
+
+// UseCircuitBreaker sends every command through b, so that once Redis is
+// failing commands return circuitbreaker.ErrOpen at once instead of waiting
+// for their timeouts. A missing key is not a failure.
+func (r *RedisClient) UseCircuitBreaker(b *circuitbreaker.Breaker) {
+	r.client.AddHook(breakerHook{breaker: b})
+}
+
+type breakerHook struct {
+	breaker *circuitbreaker.Breaker
+}
+
+func (h breakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h breakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return h.do(ctx, func(ctx context.Context) error { return next(ctx, cmd) })
+	}
+}
+
+func (h breakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		return h.do(ctx, func(ctx context.Context) error { return next(ctx, cmds) })
+	}
+}
+
+func (h breakerHook) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var result error
+	err := h.breaker.Do(ctx, func(ctx context.Context) error {
+		result = fn(ctx)
+		if errors.Is(result, redis.Nil) {
+			return nil
+		}
+		return result
+	})
+	if errors.Is(err, circuitbreaker.ErrOpen) {
+		return err
+	}
+	return result
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// BalanceInvalidationChannel carries the IDs of accounts whose balance changed.
+// The ledger publishes on it after every posting, and services that cache
+// balances drop those accounts as soon as the message arrives.
+const BalanceInvalidationChannel = "cache:invalidate:balance"
+
+const (
+	// minResubscribeDelay is the first wait before subscribing again after the
+	// subscription failed; it doubles up to maxResubscribeDelay
+	minResubscribeDelay = 100 * time.Millisecond
+	maxResubscribeDelay = 30 * time.Second
+)
+
+var (
+	invalidationsPublishedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_invalidations_published_total",
+			Help: "Total number of cache invalidation messages published",
+		},
+		[]string{"channel", "status"}, // success, failed
+	)
+
+	invalidationsReceivedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_invalidations_received_total",
+			Help: "Total number of cache invalidation messages received",
+		},
+		[]string{"channel", "status"}, // success, malformed
+	)
+
+	invalidationResubscribesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_invalidation_resubscribes_total",
+			Help: "Total number of times a cache invalidation subscription was lost and made again",
+		},
+		[]string{"channel"},
+	)
+
+	invalidationSubscribed = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cache_invalidation_subscribed",
+			Help: "Whether the cache invalidation subscription is connected (1) or not (0)",
+		},
+		[]string{"channel"},
+	)
+)
+
+// InvalidationMessage names the keys a cache should drop
+type InvalidationMessage struct {
+	Keys []string `json:"keys"`
+}
+
+// PubSub publishes messages to channels and subscribes to them
+type PubSub interface {
+	Publish(ctx context.Context, channel, message string) error
+	Subscribe(ctx context.Context, channel string) (Subscription, error)
+}
+
+// Subscription receives the messages published to a channel
+type Subscription interface {
+	// Receive blocks until a message arrives. An error means messages may have
+	// been missed and the subscription should be closed.
+	Receive(ctx context.Context) (string, error)
+	Close() error
+}
+
+// Publish sends a message to the subscribers of a channel
+func (r *RedisClient) Publish(ctx context.Context, channel, message string) error {
+	return r.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe subscribes to a channel and waits for Redis to confirm it, so
+// messages published after it returns are received
+func (r *RedisClient) Subscribe(ctx context.Context, channel string) (Subscription, error) {
+	ps := r.client.Subscribe(ctx, channel)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return redisSubscription{ps}, nil
+}
+
+type redisSubscription struct {
+	ps *redis.PubSub
+}
+
+func (s redisSubscription) Receive(ctx context.Context) (string, error) {
+	msg, err := s.ps.ReceiveMessage(ctx)
+	if err != nil {
+		return "", err
+	}
+	return msg.Payload, nil
+}
+
+func (s redisSubscription) Close() error {
+	return s.ps.Close()
+}
+
+// PublishInvalidation tells the subscribers of a channel to drop keys
+func PublishInvalidation(ctx context.Context, ps PubSub, channel string, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(InvalidationMessage{Keys: keys})
+	if err != nil {
+		return err
+	}
+	if err := ps.Publish(ctx, channel, string(data)); err != nil {
+		invalidationsPublishedTotal.WithLabelValues(channel, "failed").Inc()
+		return err
+	}
+	invalidationsPublishedTotal.WithLabelValues(channel, "success").Inc()
+	return nil
+}
+
+// InvalidationSubscriber applies the invalidations published on a channel to
+// a local cache. Pub/sub does not keep messages for disconnected subscribers,
+// so whenever the subscription is lost the whole cache is reset once it is
+// back: anything cached before may have changed in the meantime.
+type InvalidationSubscriber struct {
+	pubsub     PubSub
+	channel    string
+	invalidate func(keys []string)
+	reset      func()
+}
+
+// NewInvalidationSubscriber creates a subscriber that calls invalidate with the
+// keys of each message and reset after reconnecting
+func NewInvalidationSubscriber(ps PubSub, channel string, invalidate func(keys []string), reset func()) *InvalidationSubscriber {
+	return &InvalidationSubscriber{pubsub: ps, channel: channel, invalidate: invalidate, reset: reset}
+}
+
+// Run receives invalidations until the context is cancelled, subscribing
+// again with backoff whenever the subscription fails
+func (s *InvalidationSubscriber) Run(ctx context.Context) {
+	slog.Info("Cache invalidation subscriber started", "channel", s.channel)
+	delay := minResubscribeDelay
+	lost := false
+	for ctx.Err() == nil {
+		sub, err := s.pubsub.Subscribe(ctx, s.channel)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			slog.Warn("Cache invalidation subscribe failed", "channel", s.channel, "error", err, "retry_in", delay)
+			if !sleepCtx(ctx, delay) {
+				break
+			}
+			delay = min(delay*2, maxResubscribeDelay)
+			continue
+		}
+
+		invalidationSubscribed.WithLabelValues(s.channel).Set(1)
+		if lost {
+			invalidationResubscribesTotal.WithLabelValues(s.channel).Inc()
+			slog.Info("Cache invalidation subscription restored, resetting cache", "channel", s.channel)
+			s.reset()
+		}
+		delay = minResubscribeDelay
+
+		err = s.receive(ctx, sub)
+		sub.Close()
+		invalidationSubscribed.WithLabelValues(s.channel).Set(0)
+		if ctx.Err() != nil {
+			break
+		}
+		slog.Warn("Cache invalidation subscription lost", "channel", s.channel, "error", err)
+		lost = true
+	}
+	slog.Info("Cache invalidation subscriber stopped", "channel", s.channel)
+}
+
+// receive applies messages until the subscription fails
+func (s *InvalidationSubscriber) receive(ctx context.Context, sub Subscription) error {
+	for {
+		payload, err := sub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+		var msg InvalidationMessage
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			invalidationsReceivedTotal.WithLabelValues(s.channel, "malformed").Inc()
+			slog.Warn("Malformed cache invalidation message", "channel", s.channel, "error", err)
+			continue
+		}
+		invalidationsReceivedTotal.WithLabelValues(s.channel, "success").Inc()
+		s.invalidate(msg.Keys)
+	}
+}
+
+// sleepCtx waits for d and reports whether the context is still live
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
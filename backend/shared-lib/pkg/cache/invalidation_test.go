@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryPubSub delivers published messages to its subscriptions. fail makes
+// the open subscriptions lose their connection.
+type memoryPubSub struct {
+	mu         sync.Mutex
+	subs       []*memorySubscription
+	subscribed chan struct{}
+}
+
+func newMemoryPubSub() *memoryPubSub {
+	return &memoryPubSub{subscribed: make(chan struct{}, 10)}
+}
+
+func (p *memoryPubSub) Publish(_ context.Context, _, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.subs {
+		s.messages <- message
+	}
+	return nil
+}
+
+func (p *memoryPubSub) Subscribe(_ context.Context, _ string) (Subscription, error) {
+	p.mu.Lock()
+	sub := &memorySubscription{messages: make(chan string, 10), lost: make(chan struct{})}
+	p.subs = append(p.subs, sub)
+	p.mu.Unlock()
+	p.subscribed <- struct{}{}
+	return sub, nil
+}
+
+func (p *memoryPubSub) fail() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.subs {
+		close(s.lost)
+	}
+	p.subs = nil
+}
+
+type memorySubscription struct {
+	messages chan string
+	lost     chan struct{}
+}
+
+func (s *memorySubscription) Receive(ctx context.Context) (string, error) {
+	select {
+	case msg := <-s.messages:
+		return msg, nil
+	case <-s.lost:
+		return "", errors.New("connection reset")
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (s *memorySubscription) Close() error { return nil }
+
+func TestInvalidationSubscriber(t *testing.T) {
+	ps := newMemoryPubSub()
+	local := NewLocalCache[string](time.Minute)
+	local.Set("acc-1", "100.00")
+	local.Set("acc-2", "50.00")
+	local.Set("acc-3", "75.00")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		NewInvalidationSubscriber(ps, BalanceInvalidationChannel, local.Invalidate, local.Flush).Run(ctx)
+		close(done)
+	}()
+	<-ps.subscribed
+
+	require.NoError(t, PublishInvalidation(ctx, ps, BalanceInvalidationChannel, "acc-1"))
+	require.NoError(t, ps.Publish(ctx, BalanceInvalidationChannel, "not json"))
+	assert.Eventually(t, func() bool { _, ok := local.Get("acc-1"); return !ok }, time.Second, 5*time.Millisecond)
+	_, ok := local.Get("acc-2")
+	assert.True(t, ok, "other accounts stay cached")
+
+	// Invalidations published while disconnected are lost, so the cache is
+	// reset once the subscription is back
+	ps.fail()
+	<-ps.subscribed
+	assert.Eventually(t, func() bool { _, ok := local.Get("acc-2"); return !ok }, time.Second, 5*time.Millisecond)
+
+	local.Set("acc-3", "80.00")
+	require.NoError(t, PublishInvalidation(ctx, ps, BalanceInvalidationChannel, "acc-3"))
+	assert.Eventually(t, func() bool { _, ok := local.Get("acc-3"); return !ok }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber did not stop when its context was cancelled")
+	}
+}
+
+func TestLocalCache(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	local := NewLocalCache[int](time.Minute)
+	local.now = func() time.Time { return now }
+
+	_, ok := local.Get("a")
+	assert.False(t, ok)
+
+	local.Set("a", 1)
+	local.Set("b", 2)
+	v, ok := local.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	local.Invalidate([]string{"a"})
+	_, ok = local.Get("a")
+	assert.False(t, ok)
+
+	now = now.Add(time.Minute)
+	_, ok = local.Get("b")
+	assert.False(t, ok, "entries expire after the TTL")
+
+	local.Set("c", 3)
+	local.Flush()
+	_, ok = local.Get("c")
+	assert.False(t, ok)
+}
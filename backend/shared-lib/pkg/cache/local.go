@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// LocalCache is an in-process cache of values that expire after a TTL. It
+// suits values other services own, such as balances: the TTL bounds how stale
+// an entry can get, and an InvalidationSubscriber drops entries as soon as
+// their owner reports a change.
+type LocalCache[V any] struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]localEntry[V]
+}
+
+type localEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// NewLocalCache creates a local cache whose entries expire after ttl
+func NewLocalCache[V any](ttl time.Duration) *LocalCache[V] {
+	return &LocalCache[V]{ttl: ttl, now: time.Now, entries: make(map[string]localEntry[V])}
+}
+
+// Get returns the value cached under key, if it has not expired
+func (c *LocalCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Set caches value under key for the cache's TTL
+func (c *LocalCache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = localEntry[V]{value: value, expires: c.now().Add(c.ttl)}
+}
+
+// Invalidate drops the given keys
+func (c *LocalCache[V]) Invalidate(keys []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+// Flush drops every entry
+func (c *LocalCache[V]) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]localEntry[V])
+}
@@ -66,6 +66,16 @@ func (r *RedisClient) Set(ctx context.Context, key, value string, ttl time.Durat
 	return nil
 }
 
+// SetNX stores a value only if the key does not exist yet and reports whether it was stored
+func (r *RedisClient) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Eval runs a Lua script atomically on the server
+func (r *RedisClient) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return r.client.Eval(ctx, script, keys, args...).Result()
+}
+
 // SetJSON stores a JSON-serialized value
 func (r *RedisClient) SetJSON(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	data, err := json.Marshal(value)
@@ -108,6 +118,11 @@ func (r *RedisClient) DeletePattern(ctx context.Context, pattern string) error {
 	return iter.Err()
 }
 
+// Ping checks that Redis answers
+func (r *RedisClient) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
 // Close closes the Redis connection
 func (r *RedisClient) Close() error {
 	return r.client.Close()
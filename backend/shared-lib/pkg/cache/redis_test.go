@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/circuitbreaker"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -113,3 +115,21 @@ func TestMockRedisClient_Delete(t *testing.T) {
 	val, _ := client.Get(ctx, "to-delete")
 	assert.Empty(t, val)
 }
+
+func TestUseCircuitBreaker_FailsFastOnceRedisIsDown(t *testing.T) {
+	// Nothing listens on port 1, so every command fails to connect
+	r := &RedisClient{client: redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: time.Second})}
+	defer r.Close()
+	b := circuitbreaker.New(circuitbreaker.Config{Name: "test-redis", MinRequests: 2})
+	r.UseCircuitBreaker(b)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, err := r.Get(ctx, "key")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, circuitbreaker.ErrOpen)
+	}
+	_, err := r.Get(ctx, "key")
+	assert.ErrorIs(t, err, circuitbreaker.ErrOpen)
+	assert.Equal(t, circuitbreaker.StateOpen, b.State())
+}
@@ -0,0 +1,89 @@
+package chaos
+
+import (
+	"errors"
+	"net/http"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes endpoints to set and clear the faults of a replica
+type AdminHandler struct {
+	Injector *Injector
+	Audit    *middleware.AuditLogger
+}
+
+// NewAdminHandler creates an admin handler that audits every change
+func NewAdminHandler(injector *Injector, serviceName string) *AdminHandler {
+	return &AdminHandler{
+		Injector: injector,
+		Audit:    middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: serviceName}),
+	}
+}
+
+// RegisterRoutes mounts the chaos admin endpoints on a group that is already
+// authenticated and restricted to administrators. The paths must stay under an
+// allowlisted prefix so faults can be cleared again.
+func (h *AdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/chaos/faults", h.List)
+	rg.PUT("/chaos/faults", h.Put)
+	rg.DELETE("/chaos/faults", h.Clear)
+}
+
+// List returns the faults that still apply
+func (h *AdminHandler) List(c *gin.Context) {
+	if !h.Injector.Enabled() {
+		respondFaultError(c, ErrDisabled)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"faults": h.Injector.Faults()})
+}
+
+type PutFaultsRequest struct {
+	Faults []Fault `json:"faults" binding:"required,max=50"`
+}
+
+// Put replaces the faults
+func (h *AdminHandler) Put(c *gin.Context) {
+	var req PutFaultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+	if err := h.Injector.SetFaults(req.Faults); err != nil {
+		respondFaultError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"action": "chaos_faults_updated",
+		"faults": req.Faults,
+	})
+	c.JSON(http.StatusOK, gin.H{"faults": h.Injector.Faults()})
+}
+
+// Clear removes every fault
+func (h *AdminHandler) Clear(c *gin.Context) {
+	if err := h.Injector.SetFaults(nil); err != nil {
+		respondFaultError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"action": "chaos_faults_cleared",
+	})
+	c.Status(http.StatusNoContent)
+}
+
+func respondFaultError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidFault):
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+	case errors.Is(err, ErrDisabled):
+		apperrors.RespondWithError(c, apperrors.NewError("CHAOS_DISABLED", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
@@ -0,0 +1,200 @@
+// Package chaos injects faults into a service's endpoints for resilience
+// testing: added latency, error responses and dropped responses, for a
+// percentage of requests per route. It lets environments without a service
+// mesh, such as docker-compose, check that callers' timeouts, retries and
+// circuit breakers behave.
+//
+// Faults come from the admin API, which sets them on one replica, or from
+// request headers, which fault that request only. Injection is off unless a
+// service enables it, and it can never be enabled in production.
+package chaos
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers that fault a single request, e.g. X-Chaos-Latency: 300ms with
+// X-Chaos-Percent: 50
+const (
+	// HeaderLatency delays the request by a duration such as "300ms"
+	HeaderLatency = "X-Chaos-Latency"
+	// HeaderError answers with a status code such as 503 instead of handling the request
+	HeaderError = "X-Chaos-Error"
+	// HeaderDrop set to true handles the request but never answers it
+	HeaderDrop = "X-Chaos-Drop"
+	// HeaderPercent is the chance, 0-100, that the header's fault applies; 100 by default
+	HeaderPercent = "X-Chaos-Percent"
+)
+
+// MaxLatency bounds the latency a fault may add
+const MaxLatency = time.Minute
+
+// AnyRoute matches every route
+const AnyRoute = "*"
+
+var (
+	ErrProduction   = errors.New("fault injection cannot be enabled in production")
+	ErrDisabled     = errors.New("fault injection is not enabled")
+	ErrInvalidFault = errors.New("a fault needs a route, a percent above 0 up to 100, and latency up to 1m, an error status from 400 to 599 or drop; error and drop cannot be combined")
+)
+
+// Fault is what happens to a share of the requests to a route
+type Fault struct {
+	// Route is the route's pattern, e.g. "/api/v1/accounts/:id/balance", or
+	// AnyRoute
+	Route string `json:"route"`
+	// Method limits the fault to one HTTP method; empty matches any
+	Method string `json:"method,omitempty"`
+	// Percent is the chance, 0-100, that a matching request is faulted
+	Percent float64 `json:"percent"`
+	// LatencyMs delays the request before it is handled
+	LatencyMs int `json:"latency_ms,omitempty"`
+	// ErrorStatus answers with this status instead of handling the request
+	ErrorStatus int `json:"error_status,omitempty"`
+	// Drop handles the request but closes the connection instead of
+	// answering, as a response lost on the network would be
+	Drop bool `json:"drop,omitempty"`
+	// Until ends the fault automatically; nil keeps it until cleared
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// Latency is the delay the fault adds
+func (f *Fault) Latency() time.Duration {
+	return time.Duration(f.LatencyMs) * time.Millisecond
+}
+
+// Validate checks that the fault matches a route and does something
+func (f *Fault) Validate(now time.Time) error {
+	switch {
+	case f.Route == "" || (f.Route != AnyRoute && !strings.HasPrefix(f.Route, "/")),
+		f.Method != "" && !isMethod(strings.ToUpper(f.Method)),
+		f.Percent <= 0 || f.Percent > 100,
+		f.LatencyMs < 0 || f.Latency() > MaxLatency,
+		f.ErrorStatus != 0 && (f.ErrorStatus < 400 || f.ErrorStatus > 599),
+		f.ErrorStatus != 0 && f.Drop,
+		f.LatencyMs == 0 && f.ErrorStatus == 0 && !f.Drop,
+		f.Until != nil && !f.Until.After(now):
+		return ErrInvalidFault
+	}
+	return nil
+}
+
+// Active reports whether the fault still applies at the given time
+func (f *Fault) Active(now time.Time) bool {
+	return f.Until == nil || now.Before(*f.Until)
+}
+
+// matches reports whether the fault applies to requests to route with method
+func (f *Fault) matches(route, method string) bool {
+	return (f.Route == AnyRoute || f.Route == route) && (f.Method == "" || strings.EqualFold(f.Method, method))
+}
+
+// Config configures fault injection
+type Config struct {
+	Service string
+	// Environment is the deployment's environment, e.g. from ENVIRONMENT;
+	// "prod" and "production" refuse injection
+	Environment string
+	// Enabled switches injection on; when off, the middleware does nothing
+	// and the admin API refuses faults
+	Enabled bool
+	// Allow adds path prefixes to DefaultAllowlist
+	Allow []string
+}
+
+// Injector holds the faults set through the admin API. They live in memory,
+// so each replica has its own; local environments usually run one.
+type Injector struct {
+	cfg   Config
+	allow []string
+	// roll returns a number in [0, 100) compared against a fault's percent
+	roll func() float64
+
+	mu     sync.RWMutex
+	faults []Fault
+}
+
+// New creates an injector, refusing to enable one in production
+func New(cfg Config) (*Injector, error) {
+	if cfg.Enabled && IsProduction(cfg.Environment) {
+		return nil, ErrProduction
+	}
+	return &Injector{
+		cfg:   cfg,
+		allow: append(append([]string{}, DefaultAllowlist...), cfg.Allow...),
+		roll:  func() float64 { return rand.Float64() * 100 },
+	}, nil
+}
+
+// IsProduction reports whether environment names a production deployment
+func IsProduction(environment string) bool {
+	env := strings.ToLower(strings.TrimSpace(environment))
+	return env == "prod" || env == "production"
+}
+
+// Enabled reports whether faults are injected
+func (i *Injector) Enabled() bool {
+	return i != nil && i.cfg.Enabled
+}
+
+// Faults returns the faults that still apply
+func (i *Injector) Faults() []Fault {
+	now := time.Now()
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	faults := make([]Fault, 0, len(i.faults))
+	for _, f := range i.faults {
+		if f.Active(now) {
+			faults = append(faults, f)
+		}
+	}
+	return faults
+}
+
+// SetFaults replaces the faults; an empty list clears them
+func (i *Injector) SetFaults(faults []Fault) error {
+	if !i.Enabled() {
+		return ErrDisabled
+	}
+	now := time.Now()
+	for j := range faults {
+		if err := faults[j].Validate(now); err != nil {
+			return err
+		}
+		faults[j].Method = strings.ToUpper(faults[j].Method)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults = append([]Fault(nil), faults...)
+	return nil
+}
+
+// pick returns the fault for a request to route with method, if any: the
+// first matching fault whose roll comes up, in the order they were set
+func (i *Injector) pick(route, method string) *Fault {
+	now := time.Now()
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	for _, f := range i.faults {
+		if f.Active(now) && f.matches(route, method) && i.roll() < f.Percent {
+			return &f
+		}
+	}
+	return nil
+}
+
+func isMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
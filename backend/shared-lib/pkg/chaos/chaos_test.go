@@ -0,0 +1,179 @@
+package chaos
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newChaosRouter returns a router faulted by an enabled injector whose rolls
+// all come up as roll, and how many requests reached the handlers
+func newChaosRouter(t *testing.T, roll float64) (*gin.Engine, *Injector, *int) {
+	t.Helper()
+	injector, err := New(Config{Service: "ledger-service", Environment: "local", Enabled: true})
+	require.NoError(t, err)
+	injector.roll = func() float64 { return roll }
+
+	handled := 0
+	r := gin.New()
+	r.Use(injector.Middleware())
+	ok := func(c *gin.Context) {
+		handled++
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	}
+	r.GET("/health", ok)
+	r.GET("/api/v1/accounts/:id/balance", ok)
+	r.POST("/api/v1/transactions", ok)
+	NewAdminHandler(injector, "ledger-service").RegisterRoutes(r.Group("/api/v1/admin"))
+	return r, injector, &handled
+}
+
+func do(r http.Handler, method, path string, header http.Header, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestNew_RefusesProduction(t *testing.T) {
+	_, err := New(Config{Environment: "Production", Enabled: true})
+	assert.ErrorIs(t, err, ErrProduction)
+
+	// A disabled injector is fine anywhere, and does nothing
+	injector, err := New(Config{Environment: "prod"})
+	require.NoError(t, err)
+	assert.False(t, injector.Enabled())
+	assert.ErrorIs(t, injector.SetFaults([]Fault{{Route: AnyRoute, Percent: 100, ErrorStatus: 503}}), ErrDisabled)
+
+	r := gin.New()
+	r.Use(injector.Middleware())
+	r.GET("/api/v1/accounts", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := do(r, http.MethodGet, "/api/v1/accounts", http.Header{HeaderError: {"503"}}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestFault_Validate(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	assert.NoError(t, (&Fault{Route: "/api/v1/transactions", Method: "post", Percent: 50, LatencyMs: 300, ErrorStatus: 503}).Validate(now))
+
+	for name, f := range map[string]Fault{
+		"no route":        {Percent: 100, ErrorStatus: 503},
+		"no percent":      {Route: AnyRoute, ErrorStatus: 503},
+		"over 100":        {Route: AnyRoute, Percent: 101, ErrorStatus: 503},
+		"no effect":       {Route: AnyRoute, Percent: 100},
+		"success status":  {Route: AnyRoute, Percent: 100, ErrorStatus: 200},
+		"error and drop":  {Route: AnyRoute, Percent: 100, ErrorStatus: 503, Drop: true},
+		"too slow":        {Route: AnyRoute, Percent: 100, LatencyMs: 61000},
+		"unknown method":  {Route: AnyRoute, Method: "FETCH", Percent: 100, Drop: true},
+		"already expired": {Route: AnyRoute, Percent: 100, Drop: true, Until: &past},
+	} {
+		assert.ErrorIs(t, f.Validate(now), ErrInvalidFault, name)
+	}
+}
+
+func TestMiddleware_RouteFaults(t *testing.T) {
+	r, injector, handled := newChaosRouter(t, 10)
+	require.NoError(t, injector.SetFaults([]Fault{
+		{Route: "/api/v1/accounts/:id/balance", Percent: 5, ErrorStatus: http.StatusServiceUnavailable},
+		{Route: "/api/v1/accounts/:id/balance", Method: "get", Percent: 50, ErrorStatus: http.StatusInternalServerError},
+		{Route: AnyRoute, Percent: 100, LatencyMs: 20},
+	}))
+
+	// The first fault whose roll comes up applies
+	w := do(r, http.MethodGet, "/api/v1/accounts/acc-1/balance", nil, "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "INJECTED_FAULT")
+	assert.Equal(t, 0, *handled)
+
+	start := time.Now()
+	w = do(r, http.MethodPost, "/api/v1/transactions", nil, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Equal(t, 1, *handled)
+
+	// Probes are never faulted
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/health", nil, "").Code)
+
+	// An expired fault no longer applies
+	until := time.Now().Add(10 * time.Millisecond)
+	require.NoError(t, injector.SetFaults([]Fault{{Route: AnyRoute, Percent: 100, ErrorStatus: 503, Until: &until}}))
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPost, "/api/v1/transactions", nil, "").Code)
+	assert.Empty(t, injector.Faults())
+}
+
+func TestMiddleware_HeaderFaults(t *testing.T) {
+	r, _, handled := newChaosRouter(t, 60)
+
+	w := do(r, http.MethodPost, "/api/v1/transactions", http.Header{HeaderError: {"429"}}, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// 60 does not come up below 50
+	w = do(r, http.MethodPost, "/api/v1/transactions", http.Header{HeaderError: {"503"}, HeaderPercent: {"50"}}, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, *handled)
+
+	w = do(r, http.MethodPost, "/api/v1/transactions", http.Header{HeaderLatency: {"soon"}}, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMiddleware_DropsResponse(t *testing.T) {
+	r, _, handled := newChaosRouter(t, 0)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/api/v1/transactions", nil)
+	req.Header.Set(HeaderDrop, "true")
+	resp, err := http.DefaultClient.Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	assert.Error(t, err, "the connection closes without an answer")
+	assert.Equal(t, 1, *handled, "the request was still handled")
+
+	// HTTP/2 connections cannot be taken over, so the caller gets 502
+	w := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/transactions", nil)
+	req.ProtoMajor = 2
+	req.Header.Set(HeaderDrop, "true")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestAdminHandler(t *testing.T) {
+	r, injector, _ := newChaosRouter(t, 0)
+
+	w := do(r, http.MethodPut, "/api/v1/admin/chaos/faults", nil,
+		`{"faults":[{"route":"/api/v1/transactions","method":"POST","percent":100,"error_status":503}]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Len(t, injector.Faults(), 1)
+
+	// Invalid faults are refused
+	w = do(r, http.MethodPut, "/api/v1/admin/chaos/faults", nil, `{"faults":[{"route":"*","percent":0,"drop":true}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do(r, http.MethodPost, "/api/v1/transactions", nil, "").Code)
+
+	assert.Equal(t, http.StatusNoContent, do(r, http.MethodDelete, "/api/v1/admin/chaos/faults", nil, "").Code)
+	assert.Empty(t, injector.Faults())
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/api/v1/admin/chaos/faults", nil, "").Code)
+
+	disabled := NewAdminHandler(&Injector{}, "ledger-service")
+	d := gin.New()
+	disabled.RegisterRoutes(d.Group(""))
+	assert.Equal(t, http.StatusConflict, do(d, http.MethodGet, "/chaos/faults", nil, "").Code)
+}
@@ -0,0 +1,160 @@
+package chaos
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var injectedFaultsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "chaos_injected_faults_total",
+		Help: "Total number of faults injected into requests for resilience testing",
+	},
+	[]string{"service", "route", "fault"},
+)
+
+// DefaultAllowlist lists the path prefixes never faulted: probes, metrics,
+// SLO status, API docs and the chaos admin API, so faults can always be
+// cleared again
+var DefaultAllowlist = []string{"/health", "/metrics", metrics.SLOStatusPath, openapi.SpecPath, openapi.DocsPath, "/api/v1/admin/chaos"}
+
+// Middleware injects the fault set for the request's route, or the one its
+// headers ask for. Latency is added before the request is handled; an error
+// answers instead of the handler; a dropped request is handled and its
+// connection then closed without an answer, so callers can check that their
+// retries are idempotent. A disabled injector's middleware does nothing.
+func (i *Injector) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !i.Enabled() || allowed(i.allow, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		fault, err := i.requested(c)
+		if err != nil {
+			apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+			return
+		}
+		if fault == nil {
+			fault = i.pick(route, c.Request.Method)
+		}
+		if fault == nil {
+			c.Next()
+			return
+		}
+
+		if latency := fault.Latency(); latency > 0 {
+			injectedFaultsTotal.WithLabelValues(i.cfg.Service, route, "latency").Inc()
+			timer := time.NewTimer(latency)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+			}
+		}
+		switch {
+		case fault.ErrorStatus != 0:
+			injectedFaultsTotal.WithLabelValues(i.cfg.Service, route, "error").Inc()
+			slog.Debug("Injected error", "route", route, "status", fault.ErrorStatus)
+			apperrors.RespondWithError(c, apperrors.NewError("INJECTED_FAULT", "Fault injected for resilience testing", fault.ErrorStatus))
+		case fault.Drop:
+			injectedFaultsTotal.WithLabelValues(i.cfg.Service, route, "drop").Inc()
+			slog.Debug("Dropping response", "route", route)
+			drop(c)
+		default:
+			c.Next()
+		}
+	}
+}
+
+// requested returns the fault the request's headers ask for, if any and if
+// its percent comes up
+func (i *Injector) requested(c *gin.Context) (*Fault, error) {
+	latency, status, drop := c.GetHeader(HeaderLatency), c.GetHeader(HeaderError), c.GetHeader(HeaderDrop)
+	if latency == "" && status == "" && drop == "" {
+		return nil, nil
+	}
+
+	fault := Fault{Route: AnyRoute, Percent: 100}
+	var err error
+	if latency != "" {
+		d, parseErr := time.ParseDuration(latency)
+		err = parseErr
+		fault.LatencyMs = int(d.Milliseconds())
+	}
+	if status != "" && err == nil {
+		fault.ErrorStatus, err = strconv.Atoi(status)
+	}
+	if drop != "" && err == nil {
+		fault.Drop, err = strconv.ParseBool(drop)
+	}
+	if percent := c.GetHeader(HeaderPercent); percent != "" && err == nil {
+		fault.Percent, err = strconv.ParseFloat(percent, 64)
+	}
+	if err != nil {
+		return nil, ErrInvalidFault
+	}
+	if err := fault.Validate(time.Now()); err != nil {
+		return nil, err
+	}
+	if i.roll() >= fault.Percent {
+		return nil, nil
+	}
+	return &fault, nil
+}
+
+// drop handles the request, discarding its response, then closes the
+// connection. Where the connection cannot be taken over, as on HTTP/2, the
+// caller gets 502 with no body instead.
+func drop(c *gin.Context) {
+	w := c.Writer
+	c.Writer = &discardWriter{ResponseWriter: w}
+	c.Next()
+	c.Writer = w
+
+	// Only HTTP/1 connections can be taken over
+	if c.Request.ProtoMajor != 1 {
+		c.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+	conn, _, err := w.Hijack()
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+	if err := conn.Close(); err != nil {
+		slog.Debug("Failed to close dropped connection", "error", err)
+	}
+}
+
+// discardWriter throws away the response of a request being dropped
+type discardWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *discardWriter) WriteHeader(int)                   {}
+func (w *discardWriter) WriteHeaderNow()                   {}
+func (w *discardWriter) Write(b []byte) (int, error)       { return len(b), nil }
+func (w *discardWriter) WriteString(s string) (int, error) { return len(s), nil }
+func (w *discardWriter) Flush()                            {}
+func (w *discardWriter) Written() bool                     { return false }
+
+func allowed(allowlist []string, path string) bool {
+	for _, prefix := range allowlist {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
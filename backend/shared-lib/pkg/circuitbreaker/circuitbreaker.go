@@ -0,0 +1,387 @@
+// Package circuitbreaker stops calls to a failing dependency so that callers
+// fail fast instead of queueing behind timeouts.
+//
+// A breaker tracks the outcome of calls over a sliding time window. Once the
+// window holds at least MinRequests calls and the share that failed reaches
+// FailureRate, the breaker opens and rejects calls with ErrOpen. After
+// OpenTimeout it lets HalfOpenProbes calls through: if they all succeed it
+// closes again, and the first failure reopens it.
+//
+// Calls are made with Breaker.Do or the generic Do and DoWithFallback, and
+// HTTP clients can be wrapped with Client or Transport. State and outcomes are
+// exported as Prometheus metrics labelled with the breaker's name, which
+// should name the dependency.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned instead of calling the dependency while a breaker is
+// open, or half-open with all of its probes in flight
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a breaker
+type State int
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config configures the breaker for one dependency. Zero fields take their
+// defaults.
+type Config struct {
+	Name string
+	// Window is how far back outcomes are counted (default 60s)
+	Window time.Duration
+	// Buckets is how many slices the window is divided into; outcomes leave
+	// the window one slice at a time (default 10)
+	Buckets int
+	// MinRequests is how many calls the window must hold before the breaker
+	// can open, so a single failure after a quiet spell does not trip it
+	// (default 20)
+	MinRequests int
+	// FailureRate is the share of failed calls, between 0 and 1, that opens
+	// the breaker (default 0.5)
+	FailureRate float64
+	// OpenTimeout is how long the breaker stays open before probing (default 30s)
+	OpenTimeout time.Duration
+	// HalfOpenProbes is how many calls are let through while half-open; all
+	// of them must succeed to close the breaker (default 3)
+	HalfOpenProbes int
+	// IsFailure reports whether an error returned by a call counts against
+	// the dependency. By default every error does except the caller's own
+	// context being canceled.
+	IsFailure func(err error) bool
+	// OnStateChange is called after the breaker changes state, outside its lock
+	OnStateChange func(name string, from, to State)
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 60 * time.Second
+	}
+	if c.Buckets <= 0 {
+		c.Buckets = 10
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = 20
+	}
+	if c.FailureRate <= 0 || c.FailureRate > 1 {
+		c.FailureRate = 0.5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = 3
+	}
+	if c.IsFailure == nil {
+		c.IsFailure = defaultIsFailure
+	}
+	return c
+}
+
+func defaultIsFailure(err error) bool {
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+// Breaker is a circuit breaker for one dependency. It is safe for concurrent use.
+type Breaker struct {
+	cfg Config
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	window   *window
+	openedAt time.Time
+	// generation changes on every state change so that calls started in an
+	// earlier state do not count towards the current one
+	generation uint64
+	probes     int // calls let through while half-open
+	probesOK   int // of which succeeded
+}
+
+// New creates a closed breaker
+func New(cfg Config) *Breaker {
+	cfg = cfg.withDefaults()
+	b := &Breaker{
+		cfg:    cfg,
+		now:    time.Now,
+		window: newWindow(cfg.Window, cfg.Buckets),
+	}
+	breakerState.WithLabelValues(cfg.Name).Set(float64(StateClosed))
+	return b
+}
+
+// Name returns the dependency the breaker protects
+func (b *Breaker) Name() string { return b.cfg.Name }
+
+// State returns the breaker's current state
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	changed := b.advance(b.now())
+	state := b.state
+	b.mu.Unlock()
+
+	b.notify(changed)
+	return state
+}
+
+// Do calls fn unless the breaker is open, in which case it returns ErrOpen
+// without calling it. The error fn returns is returned unchanged.
+func (b *Breaker) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	generation, err := b.allow()
+	if err != nil {
+		return err
+	}
+	err = fn(ctx)
+	b.done(generation, b.cfg.IsFailure(err))
+	return err
+}
+
+// Do calls fn through b and returns its result
+func Do[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := b.Do(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// DoWithFallback calls fn through b. When b rejects the call or fn fails in a
+// way that counts against the dependency, fallback is called with the error
+// and its result is returned instead, e.g. a cached value or a degraded answer.
+func DoWithFallback[T any](ctx context.Context, b *Breaker, fn func(ctx context.Context) (T, error), fallback func(ctx context.Context, err error) (T, error)) (T, error) {
+	result, err := Do(ctx, b, fn)
+	if errors.Is(err, ErrOpen) || b.cfg.IsFailure(err) {
+		return fallback(ctx, err)
+	}
+	return result, err
+}
+
+// allow reports whether a call may go ahead and the generation to report its
+// outcome against
+func (b *Breaker) allow() (uint64, error) {
+	b.mu.Lock()
+	now := b.now()
+	changed := b.advance(now)
+	var err error
+	switch b.state {
+	case StateOpen:
+		err = ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			err = ErrOpen
+		} else {
+			b.probes++
+		}
+	}
+	generation := b.generation
+	b.mu.Unlock()
+
+	b.notify(changed)
+	if err != nil {
+		breakerRequestsTotal.WithLabelValues(b.cfg.Name, "rejected").Inc()
+	}
+	return generation, err
+}
+
+// done records the outcome of a call that allow let through
+func (b *Breaker) done(generation uint64, failed bool) {
+	result := "success"
+	if failed {
+		result = "failure"
+	}
+	breakerRequestsTotal.WithLabelValues(b.cfg.Name, result).Inc()
+
+	b.mu.Lock()
+	now := b.now()
+	changed := b.advance(now)
+	if generation == b.generation {
+		switch b.state {
+		case StateClosed:
+			b.window.record(now, failed)
+			total, failures := b.window.counts(now)
+			if total >= b.cfg.MinRequests && float64(failures) >= b.cfg.FailureRate*float64(total) {
+				changed = append(changed, b.setState(StateOpen, now))
+			}
+		case StateHalfOpen:
+			if failed {
+				changed = append(changed, b.setState(StateOpen, now))
+				break
+			}
+			b.probesOK++
+			if b.probesOK >= b.cfg.HalfOpenProbes {
+				changed = append(changed, b.setState(StateClosed, now))
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	b.notify(changed)
+}
+
+// transition is a state change to report once the lock is released
+type transition struct{ from, to State }
+
+// advance moves an open breaker to half-open once its timeout has passed
+func (b *Breaker) advance(now time.Time) []transition {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.cfg.OpenTimeout {
+		return []transition{b.setState(StateHalfOpen, now)}
+	}
+	return nil
+}
+
+func (b *Breaker) setState(to State, now time.Time) transition {
+	t := transition{from: b.state, to: to}
+	b.state = to
+	b.generation++
+	b.probes, b.probesOK = 0, 0
+	switch to {
+	case StateOpen:
+		b.openedAt = now
+	case StateClosed:
+		b.window.reset()
+	}
+	return t
+}
+
+func (b *Breaker) notify(changes []transition) {
+	for _, t := range changes {
+		breakerState.WithLabelValues(b.cfg.Name).Set(float64(t.to))
+		breakerTransitionsTotal.WithLabelValues(b.cfg.Name, t.to.String()).Inc()
+		slog.Warn("Circuit breaker changed state", "name", b.cfg.Name, "from", t.from.String(), "to", t.to.String())
+		if b.cfg.OnStateChange != nil {
+			b.cfg.OnStateChange(b.cfg.Name, t.from, t.to)
+		}
+	}
+}
+
+// window counts outcomes over a sliding period split into equal buckets
+type window struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+type bucket struct {
+	slot      int64 // which bucket-width period since the epoch it counts
+	successes int
+	failures  int
+}
+
+func newWindow(length time.Duration, buckets int) *window {
+	width := length / time.Duration(buckets)
+	if width <= 0 {
+		width = 1
+	}
+	return &window{width: width, buckets: make([]bucket, buckets)}
+}
+
+func (w *window) record(now time.Time, failed bool) {
+	slot := now.UnixNano() / int64(w.width)
+	b := &w.buckets[slot%int64(len(w.buckets))]
+	if b.slot != slot {
+		*b = bucket{slot: slot}
+	}
+	if failed {
+		b.failures++
+	} else {
+		b.successes++
+	}
+}
+
+func (w *window) counts(now time.Time) (total, failures int) {
+	slot := now.UnixNano() / int64(w.width)
+	for _, b := range w.buckets {
+		if slot-b.slot < int64(len(w.buckets)) {
+			total += b.successes + b.failures
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *window) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
+
+// Registry holds the breakers of a service's dependencies so each can be
+// configured separately and shared by every caller of that dependency
+type Registry struct {
+	defaults Config
+
+	mu       sync.Mutex
+	configs  map[string]Config
+	breakers map[string]*Breaker
+}
+
+// NewRegistry creates a registry whose breakers take defaults unless a
+// dependency is configured
+func NewRegistry(defaults Config) *Registry {
+	return &Registry{defaults: defaults, configs: map[string]Config{}, breakers: map[string]*Breaker{}}
+}
+
+// Configure sets the configuration of the breaker named cfg.Name. It has no
+// effect on a breaker that Get has already created.
+func (r *Registry) Configure(cfg Config) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.configs[cfg.Name] = cfg
+}
+
+// Get returns the breaker for a dependency, creating it on first use
+func (r *Registry) Get(name string) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.breakers[name]; ok {
+		return b
+	}
+	cfg, ok := r.configs[name]
+	if !ok {
+		cfg = r.defaults
+		cfg.Name = name
+	}
+	b := New(cfg)
+	r.breakers[name] = b
+	return b
+}
+
+// States returns the state of every breaker created so far
+func (r *Registry) States() map[string]State {
+	r.mu.Lock()
+	breakers := make([]*Breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+
+	states := make(map[string]State, len(breakers))
+	for _, b := range breakers {
+		states[b.Name()] = b.State()
+	}
+	return states
+}
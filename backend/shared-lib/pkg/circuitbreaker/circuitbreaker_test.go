@@ -0,0 +1,203 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDown = errors.New("dependency is down")
+
+// testBreaker returns a breaker on a clock the test advances
+func testBreaker(cfg Config) (*Breaker, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := New(cfg)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func call(b *Breaker, err error) error {
+	return b.Do(context.Background(), func(context.Context) error { return err })
+}
+
+func TestBreaker_OpensAtFailureRate(t *testing.T) {
+	b, _ := testBreaker(Config{Name: "test-rate", MinRequests: 10, FailureRate: 0.5})
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, call(b, nil))
+	}
+	for i := 0; i < 4; i++ {
+		require.ErrorIs(t, call(b, errDown), errDown)
+	}
+	assert.Equal(t, StateClosed, b.State(), "9 calls is below MinRequests")
+
+	require.ErrorIs(t, call(b, errDown), errDown)
+	assert.Equal(t, StateOpen, b.State())
+
+	called := false
+	err := b.Do(context.Background(), func(context.Context) error { called = true; return nil })
+	assert.ErrorIs(t, err, ErrOpen)
+	assert.False(t, called)
+}
+
+func TestBreaker_StaysClosedBelowFailureRate(t *testing.T) {
+	b, _ := testBreaker(Config{Name: "test-below", MinRequests: 10, FailureRate: 0.5})
+	for i := 0; i < 20; i++ {
+		err := errDown
+		if i%3 != 0 {
+			err = nil
+		}
+		_ = call(b, err)
+	}
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestBreaker_FailuresLeaveTheWindow(t *testing.T) {
+	b, now := testBreaker(Config{Name: "test-window", Window: 10 * time.Second, Buckets: 10, MinRequests: 4, FailureRate: 0.5})
+
+	for i := 0; i < 3; i++ {
+		_ = call(b, errDown)
+	}
+	*now = now.Add(11 * time.Second)
+	for i := 0; i < 3; i++ {
+		_ = call(b, nil)
+	}
+	_ = call(b, errDown)
+	assert.Equal(t, StateClosed, b.State(), "the early failures have slid out of the window")
+}
+
+func TestBreaker_HalfOpenProbes(t *testing.T) {
+	var changes []State
+	b, now := testBreaker(Config{
+		Name: "test-probes", MinRequests: 2, OpenTimeout: time.Minute, HalfOpenProbes: 2,
+		OnStateChange: func(_ string, _, to State) { changes = append(changes, to) },
+	})
+	_ = call(b, errDown)
+	_ = call(b, errDown)
+	require.Equal(t, StateOpen, b.State())
+
+	*now = now.Add(time.Minute)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	// Only HalfOpenProbes calls are let through at once
+	release := make(chan struct{})
+	started := make(chan struct{})
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- b.Do(context.Background(), func(context.Context) error {
+				started <- struct{}{}
+				<-release
+				return nil
+			})
+		}()
+	}
+	<-started
+	<-started
+	assert.ErrorIs(t, call(b, nil), ErrOpen)
+	close(release)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, StateClosed, b.State())
+	assert.Equal(t, []State{StateOpen, StateHalfOpen, StateClosed}, changes)
+}
+
+func TestBreaker_ProbeFailureReopens(t *testing.T) {
+	b, now := testBreaker(Config{Name: "test-reopen", MinRequests: 1, OpenTimeout: time.Minute})
+	_ = call(b, errDown)
+	*now = now.Add(time.Minute)
+
+	require.ErrorIs(t, call(b, errDown), errDown)
+	assert.Equal(t, StateOpen, b.State())
+
+	*now = now.Add(30 * time.Second)
+	assert.ErrorIs(t, call(b, nil), ErrOpen, "the open timeout restarts")
+}
+
+func TestBreaker_IgnoresCanceledCallsAndCustomFailures(t *testing.T) {
+	errNotFound := errors.New("not found")
+	b, _ := testBreaker(Config{
+		Name: "test-classify", MinRequests: 2,
+		IsFailure: func(err error) bool { return err != nil && !errors.Is(err, errNotFound) },
+	})
+	for i := 0; i < 5; i++ {
+		assert.ErrorIs(t, call(b, errNotFound), errNotFound)
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	d, _ := testBreaker(Config{Name: "test-canceled", MinRequests: 2})
+	for i := 0; i < 5; i++ {
+		_ = call(d, context.Canceled)
+	}
+	assert.Equal(t, StateClosed, d.State())
+}
+
+func TestDoWithFallback(t *testing.T) {
+	b, _ := testBreaker(Config{Name: "test-fallback", MinRequests: 1})
+	fallback := func(_ context.Context, err error) (string, error) { return "cached", nil }
+
+	v, err := DoWithFallback(context.Background(), b, func(context.Context) (string, error) { return "fresh", nil }, fallback)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", v)
+
+	v, err = DoWithFallback(context.Background(), b, func(context.Context) (string, error) { return "", errDown }, fallback)
+	require.NoError(t, err)
+	assert.Equal(t, "cached", v)
+
+	var fallbackErr error
+	v, err = DoWithFallback(context.Background(), b, func(context.Context) (string, error) { return "fresh", nil },
+		func(_ context.Context, err error) (string, error) { fallbackErr = err; return "cached", nil })
+	require.NoError(t, err)
+	assert.Equal(t, "cached", v)
+	assert.ErrorIs(t, fallbackErr, ErrOpen)
+}
+
+func TestClient_CountsServerErrors(t *testing.T) {
+	status := http.StatusInternalServerError
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	b := New(Config{Name: "test-http", MinRequests: 3})
+	client := b.Client(&http.Client{Timeout: time.Second})
+
+	status = http.StatusBadRequest
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.Equal(t, StateClosed, b.State(), "4xx responses mean the dependency is up")
+
+	status = http.StatusServiceUnavailable
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		resp.Body.Close()
+	}
+	assert.Equal(t, StateOpen, b.State())
+
+	_, err := client.Get(srv.URL)
+	assert.ErrorIs(t, err, ErrOpen)
+}
+
+func TestRegistry_PerDependencyConfig(t *testing.T) {
+	r := NewRegistry(Config{MinRequests: 100})
+	r.Configure(Config{Name: "ledger", MinRequests: 1})
+
+	ledger := r.Get("ledger")
+	assert.Same(t, ledger, r.Get("ledger"))
+	_ = call(ledger, errDown)
+	_ = call(r.Get("redis"), errDown)
+
+	assert.Equal(t, map[string]State{"ledger": StateOpen, "redis": StateClosed}, r.States())
+}
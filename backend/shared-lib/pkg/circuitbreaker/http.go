@@ -0,0 +1,40 @@
+package circuitbreaker
+
+import (
+	"net/http"
+)
+
+// Transport is an http.RoundTripper that sends requests through a breaker.
+// Transport errors and 5xx responses count as failures; other responses,
+// including 4xx, show the dependency is up. While the breaker is open
+// requests fail with an error wrapping ErrOpen.
+type Transport struct {
+	Breaker *Breaker
+	// Base makes the requests; nil means http.DefaultTransport
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	generation, err := t.Breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	failed := t.Breaker.cfg.IsFailure(err) || (err == nil && resp.StatusCode >= http.StatusInternalServerError)
+	t.Breaker.done(generation, failed)
+	return resp, err
+}
+
+// Client returns a copy of client whose requests go through b
+func (b *Breaker) Client(client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	wrapped := *client
+	wrapped.Transport = &Transport{Breaker: b, Base: client.Transport}
+	return &wrapped
+}
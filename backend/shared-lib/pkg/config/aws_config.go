@@ -5,10 +5,16 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	awspkg "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/logger"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/security"
 	"github.com/spf13/viper"
 )
 
@@ -41,11 +47,27 @@ type ServiceConfig struct {
 	// JWT configuration
 	JWT JWTConfig `mapstructure:"jwt"`
 
+	// Card data encryption; set by services that store card numbers
+	Card *CardConfig `mapstructure:"card"`
+
 	// Observability
 	Observability ObservabilityConfig `mapstructure:"observability"`
 
+	// Rate limiting policies
+	RateLimit RateLimitingConfig `mapstructure:"rate_limit"`
+
+	// Password policy (identity service)
+	PasswordPolicy security.PasswordPolicyConfig `mapstructure:"password_policy"`
+
+	// Error response format
+	ErrorResponse ErrorResponseConfig `mapstructure:"error_response"`
+
 	// AWS-specific configuration
 	AWS AWSConfig `mapstructure:"aws"`
+
+	// jwtKeys are the secrets JWTAuth verifies tokens with, shared by every
+	// route and kept up to date by WatchJWTSecret
+	jwtKeys *middleware.JWTKeys
 }
 
 // DatabaseConfig holds database configuration
@@ -82,15 +104,32 @@ type KafkaConfig struct {
 	SASL     bool     `mapstructure:"sasl"`
 	Username string   `mapstructure:"username"`
 	Password string   `mapstructure:"password"`
+	// Async is set by services that hand work to other services over Kafka,
+	// which makes Brokers required
+	Async bool `mapstructure:"async"`
 }
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret          string `mapstructure:"secret"`
-	ExpirationHours int    `mapstructure:"expiration_hours"`
-	Issuer          string `mapstructure:"issuer"`
-	// AWS-specific
-	SecretARN string `mapstructure:"secret_arn"`
+	Secret string `mapstructure:"secret"`
+	// PreviousSecrets are secrets that were rotated out; tokens signed with
+	// them are still accepted until they expire
+	PreviousSecrets []string `mapstructure:"previous_secrets"`
+	ExpirationHours int      `mapstructure:"expiration_hours"`
+	Issuer          string   `mapstructure:"issuer"`
+	// Audiences are the aud claims the service accepts; it defaults to the
+	// service name. A gateway in front of several services lists all of theirs.
+	Audiences []string `mapstructure:"audiences"`
+	// AWS-specific. When set, the secret is re-read every RefreshInterval so
+	// a rotation in Secrets Manager reaches running services.
+	SecretARN       string        `mapstructure:"secret_arn"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// CardConfig holds card data encryption configuration
+type CardConfig struct {
+	// EncryptionKey is the AES-256 key card numbers are encrypted with
+	EncryptionKey string `mapstructure:"encryption_key"`
 }
 
 // ObservabilityConfig holds observability configuration
@@ -101,6 +140,40 @@ type ObservabilityConfig struct {
 	OTLPEndpoint   string `mapstructure:"otlp_endpoint"`
 	LogLevel       string `mapstructure:"log_level"`
 	LogFormat      string `mapstructure:"log_format"`
+	// LogSampling keeps only a share of high-volume log levels under load
+	LogSampling LogSamplingConfig `mapstructure:"log_sampling"`
+}
+
+// LogSamplingConfig maps level names (debug, info, warn) to the fraction of
+// records kept once a level logs more than Threshold records in a second
+type LogSamplingConfig struct {
+	Rates     map[string]float64 `mapstructure:"rates"`
+	Threshold int                `mapstructure:"threshold"`
+}
+
+// RateLimitingConfig holds per-endpoint rate limit policies
+type RateLimitingConfig struct {
+	DefaultRequestsPerMinute int                     `mapstructure:"default_requests_per_minute"`
+	DefaultBurstSize         int                     `mapstructure:"default_burst_size"`
+	Policies                 []RateLimitPolicyConfig `mapstructure:"policies"`
+}
+
+// RateLimitPolicyConfig configures the limit for a single route pattern.
+// An empty method matches any HTTP method.
+type RateLimitPolicyConfig struct {
+	Name              string `mapstructure:"name"`
+	Method            string `mapstructure:"method"`
+	Path              string `mapstructure:"path"`
+	RequestsPerMinute int    `mapstructure:"requests_per_minute"`
+	BurstSize         int    `mapstructure:"burst_size"`
+}
+
+// ErrorResponseConfig holds how error responses are written. They are RFC
+// 7807 problem details; LegacyEnvelope also includes the previous
+// {"error": {...}} envelope while clients migrate.
+type ErrorResponseConfig struct {
+	ProblemBaseURI string `mapstructure:"problem_base_uri"`
+	LegacyEnvelope bool   `mapstructure:"legacy_envelope"`
 }
 
 // AWSConfig holds AWS-specific configuration
@@ -141,7 +214,8 @@ func NewLoader(opts ...LoaderOption) *Loader {
 	return l
 }
 
-// Load loads the configuration, automatically detecting environment
+// Load loads the configuration, automatically detecting environment. Callers
+// should check the result with Validate before starting.
 func (l *Loader) Load(ctx context.Context, cfg *ServiceConfig) error {
 	// First, load from config file
 	if err := l.loadFromFile(cfg); err != nil {
@@ -272,6 +346,9 @@ func (l *Loader) applyDefaults(cfg *ServiceConfig) {
 	if cfg.JWT.Issuer == "" {
 		cfg.JWT.Issuer = "neobank"
 	}
+	if cfg.JWT.RefreshInterval == 0 {
+		cfg.JWT.RefreshInterval = DefaultJWTSecretRefreshInterval
+	}
 
 	// Observability defaults
 	if cfg.Observability.MetricsPort == 0 {
@@ -284,6 +361,14 @@ func (l *Loader) applyDefaults(cfg *ServiceConfig) {
 		cfg.Observability.LogFormat = "json"
 	}
 
+	// Rate limit defaults
+	if cfg.RateLimit.DefaultRequestsPerMinute == 0 {
+		cfg.RateLimit.DefaultRequestsPerMinute = 100
+	}
+
+	// Password policy defaults
+	cfg.PasswordPolicy = cfg.PasswordPolicy.WithDefaults()
+
 	// AWS defaults
 	if cfg.AWS.Region == "" {
 		cfg.AWS.Region = awspkg.GetRegion()
@@ -307,6 +392,96 @@ func (cfg *ServiceConfig) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port)
 }
 
+// JWTAuth returns the JWT middleware configuration: tokens must be signed with
+// the JWT secret or one of the previous ones, carry the configured issuer and
+// be addressed to one of the service's audiences. Admin routes use its Admin
+// variant.
+func (cfg *ServiceConfig) JWTAuth() middleware.JWTAuthConfig {
+	result := middleware.DefaultJWTConfig(cfg.JWT.Secret)
+	result.Keys = cfg.JWTKeys()
+	result.Issuer = cfg.JWT.Issuer
+	result.Audiences = cfg.JWT.Audiences
+	if len(result.Audiences) == 0 && cfg.ServiceName != "" {
+		result.Audiences = []string{cfg.ServiceName}
+	}
+	return result
+}
+
+// RateLimitPolicies converts the rate limit configuration into middleware policies,
+// keeping the built-in defaults for whatever is not configured. The service's
+// user and admin tokens identify the user to limit.
+func (cfg *ServiceConfig) RateLimitPolicies() middleware.PolicyRateLimitConfig {
+	result := middleware.DefaultPolicyRateLimitConfig()
+	jwtAuth := cfg.JWTAuth()
+	result.Auth = []middleware.JWTAuthConfig{jwtAuth, jwtAuth.Admin()}
+
+	if cfg.RateLimit.DefaultRequestsPerMinute > 0 {
+		result.Default.RequestsPerMinute = cfg.RateLimit.DefaultRequestsPerMinute
+	}
+	if cfg.RateLimit.DefaultBurstSize > 0 {
+		result.Default.BurstSize = cfg.RateLimit.DefaultBurstSize
+	}
+
+	if len(cfg.RateLimit.Policies) > 0 {
+		result.Policies = make([]middleware.RateLimitPolicy, 0, len(cfg.RateLimit.Policies))
+		for _, p := range cfg.RateLimit.Policies {
+			method := strings.ToUpper(p.Method)
+			name := p.Name
+			if name == "" {
+				name = strings.TrimSpace(method + " " + p.Path)
+			}
+			result.Policies = append(result.Policies, middleware.RateLimitPolicy{
+				Name:              name,
+				Method:            method,
+				Path:              p.Path,
+				RequestsPerMinute: p.RequestsPerMinute,
+				BurstSize:         p.BurstSize,
+			})
+		}
+	}
+
+	return result
+}
+
+// ErrorResponses returns the error response configuration, with problem
+// types of the service's own codes namespaced under the service name
+func (cfg *ServiceConfig) ErrorResponses() apperrors.Config {
+	return apperrors.Config{
+		Service:        cfg.ServiceName,
+		ProblemBaseURI: cfg.ErrorResponse.ProblemBaseURI,
+		LegacyEnvelope: cfg.ErrorResponse.LegacyEnvelope,
+	}
+}
+
+// LoggerOptions converts the observability configuration into logger options.
+// Unknown level names fall back to info.
+func (cfg *ServiceConfig) LoggerOptions() logger.Options {
+	opts := logger.Options{
+		Level:  parseLogLevel(cfg.Observability.LogLevel),
+		Format: cfg.Observability.LogFormat,
+		Sampling: logger.SamplingConfig{
+			Threshold: cfg.Observability.LogSampling.Threshold,
+		},
+	}
+
+	if len(cfg.Observability.LogSampling.Rates) > 0 {
+		opts.Sampling.Rates = make(map[slog.Level]float64, len(cfg.Observability.LogSampling.Rates))
+		for name, rate := range cfg.Observability.LogSampling.Rates {
+			opts.Sampling.Rates[parseLogLevel(name)] = rate
+		}
+	}
+
+	return opts
+}
+
+func parseLogLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
 // IsProduction returns true if running in production
 func (cfg *ServiceConfig) IsProduction() bool {
 	env := strings.ToLower(cfg.Environment)
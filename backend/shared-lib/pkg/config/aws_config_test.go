@@ -3,9 +3,11 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"log/slog"
 	"os"
 	"testing"
 
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -43,6 +45,61 @@ func TestServiceConfig_GetRedisAddr(t *testing.T) {
 	assert.Equal(t, "localhost:6379", addr)
 }
 
+func TestServiceConfig_RateLimitPolicies(t *testing.T) {
+	cfg := &ServiceConfig{
+		RateLimit: RateLimitingConfig{
+			DefaultRequestsPerMinute: 200,
+			Policies: []RateLimitPolicyConfig{
+				{Method: "post", Path: "/api/v1/transfer", RequestsPerMinute: 20},
+			},
+		},
+	}
+
+	policies := cfg.RateLimitPolicies()
+	assert.Equal(t, 200, policies.Default.RequestsPerMinute)
+	assert.Equal(t, middleware.DefaultPolicyRateLimitConfig().Default.BurstSize, policies.Default.BurstSize,
+		"the default burst is kept unless one is configured")
+	require.Len(t, policies.Policies, 1)
+	assert.Equal(t, "POST /api/v1/transfer", policies.Policies[0].Name)
+	assert.Equal(t, "POST", policies.Policies[0].Method)
+
+	// Without configured policies the defaults are kept
+	defaults := (&ServiceConfig{}).RateLimitPolicies()
+	assert.Equal(t, middleware.DefaultPolicyRateLimitConfig().Policies, defaults.Policies)
+
+	cfg.RateLimit.DefaultBurstSize = 50
+	assert.Equal(t, 50, cfg.RateLimitPolicies().Default.BurstSize)
+
+	// Users are identified by the service's user and admin tokens
+	cfg.ServiceName = "payment-service"
+	assert.Equal(t, []middleware.JWTAuthConfig{cfg.JWTAuth(), cfg.JWTAuth().Admin()}, cfg.RateLimitPolicies().Auth)
+}
+
+func TestServiceConfig_LoggerOptions(t *testing.T) {
+	cfg := &ServiceConfig{
+		Observability: ObservabilityConfig{
+			LogLevel:  "warn",
+			LogFormat: "text",
+			LogSampling: LogSamplingConfig{
+				Rates:     map[string]float64{"info": 0.1, "DEBUG": 0},
+				Threshold: 100,
+			},
+		},
+	}
+
+	opts := cfg.LoggerOptions()
+	assert.Equal(t, slog.LevelWarn, opts.Level)
+	assert.Equal(t, "text", opts.Format)
+	assert.Equal(t, 100, opts.Sampling.Threshold)
+	assert.Equal(t, map[slog.Level]float64{slog.LevelInfo: 0.1, slog.LevelDebug: 0}, opts.Sampling.Rates)
+	assert.True(t, opts.Sampling.Enabled())
+
+	// Unknown levels fall back to info and no sampling is configured by default
+	defaults := (&ServiceConfig{Observability: ObservabilityConfig{LogLevel: "verbose"}}).LoggerOptions()
+	assert.Equal(t, slog.LevelInfo, defaults.Level)
+	assert.False(t, defaults.Sampling.Enabled())
+}
+
 func TestServiceConfig_IsProduction(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -116,6 +173,9 @@ func TestLoader_ApplyDefaults(t *testing.T) {
 	assert.Equal(t, 9090, cfg.Observability.MetricsPort)
 	assert.Equal(t, "info", cfg.Observability.LogLevel)
 	assert.Equal(t, "json", cfg.Observability.LogFormat)
+
+	// Rate limit defaults
+	assert.Equal(t, 100, cfg.RateLimit.DefaultRequestsPerMinute)
 }
 
 func TestLoader_ApplyDefaults_AWS(t *testing.T) {
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	awspkg "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var jwtSecretRefreshesTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "jwt_secret_refreshes_total",
+		Help: "Total number of times the JWT secret was re-read from Secrets Manager, by result",
+	},
+	[]string{"result"}, // rotated, unchanged or failed
+)
+
+// DefaultJWTSecretRefreshInterval is how often a JWT secret stored in Secrets
+// Manager is re-read when JWTConfig.RefreshInterval is unset
+const DefaultJWTSecretRefreshInterval = time.Minute
+
+// JWTSecretSource reads the version stages of a rotated secret;
+// *aws.SecretsProvider is one
+type JWTSecretSource interface {
+	GetSecretStage(ctx context.Context, secretName, stage string) (string, error)
+}
+
+// JWTKeys returns the secrets JWTAuth verifies tokens with: the JWT secret and
+// the previous secrets, as last refreshed
+func (cfg *ServiceConfig) JWTKeys() *middleware.JWTKeys {
+	if cfg.jwtKeys == nil {
+		cfg.jwtKeys = middleware.NewJWTKeys(cfg.JWT.Secret, cfg.JWT.PreviousSecrets...)
+	}
+	return cfg.jwtKeys
+}
+
+// RefreshJWTKeys re-reads the JWT secret named by JWT.SecretARN from source.
+// The current version becomes the secret tokens are verified with first, and
+// the previous version, with JWT.PreviousSecrets, the secrets still accepted.
+// A secret that has never been rotated has no previous version; the old
+// current secret is then kept as a previous one if it changed. On error the
+// keys are left as they were.
+func (cfg *ServiceConfig) RefreshJWTKeys(ctx context.Context, source JWTSecretSource) error {
+	current, err := source.GetSecretStage(ctx, cfg.JWT.SecretARN, awspkg.StageCurrent)
+	if err == nil && current == "" {
+		err = errors.New("secret is empty")
+	}
+	if err != nil {
+		jwtSecretRefreshesTotal.WithLabelValues("failed").Inc()
+		return fmt.Errorf("failed to refresh JWT secret: %w", err)
+	}
+
+	keys := cfg.JWTKeys()
+	old, _ := keys.Secrets()
+	rotated := current != old
+	if previous, err := source.GetSecretStage(ctx, cfg.JWT.SecretARN, awspkg.StagePrevious); err == nil {
+		keys.Set(current, append([]string{previous}, cfg.JWT.PreviousSecrets...)...)
+	} else {
+		keys.Rotate(current)
+	}
+
+	if rotated {
+		slog.Info("JWT secret rotated", "secret", cfg.JWT.SecretARN)
+		jwtSecretRefreshesTotal.WithLabelValues("rotated").Inc()
+	} else {
+		jwtSecretRefreshesTotal.WithLabelValues("unchanged").Inc()
+	}
+	return nil
+}
+
+// WatchJWTSecret refreshes the JWT keys from source every JWT.RefreshInterval
+// until ctx is done, so a secret rotated in Secrets Manager is accepted by
+// running services without a restart. Tokens signed with the secret it
+// replaced keep verifying until they expire. Failed refreshes are logged and
+// retried at the next interval.
+//
+// Tokens are signed with JWT_SECRET as read at start, so a rotation is
+// complete once the identity service has been restarted after every service
+// has refreshed; until then the new secret only verifies.
+func (cfg *ServiceConfig) WatchJWTSecret(ctx context.Context, source JWTSecretSource) {
+	interval := cfg.JWT.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultJWTSecretRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := cfg.RefreshJWTKeys(ctx, source); err != nil {
+			slog.Warn("JWT secret refresh failed", "secret", cfg.JWT.SecretARN, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// StartJWTSecretRefresh watches the JWT secret in Secrets Manager in the
+// background when JWT.SecretARN is set, and does nothing otherwise. The
+// secret must be the one JWT_SECRET is deployed from.
+func (cfg *ServiceConfig) StartJWTSecretRefresh(ctx context.Context) {
+	if cfg.JWT.SecretARN == "" {
+		return
+	}
+	provider, err := awspkg.NewSecretsProvider(ctx, awspkg.SecretsConfig{
+		Region:    awspkg.GetRegion(),
+		UseLocal:  cfg.AWS.UseLocalSecrets,
+		LocalPath: cfg.AWS.LocalSecretsPath,
+	})
+	if err != nil {
+		slog.Warn("JWT secret refresh disabled", "error", err)
+		return
+	}
+	// Create the keys before the watcher does, so JWTAuth can be called concurrently
+	cfg.JWTKeys()
+	go cfg.WatchJWTSecret(ctx, provider)
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	awspkg "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stagedSecrets is a JWTSecretSource holding the value of each version stage
+type stagedSecrets map[string]string
+
+func (s stagedSecrets) GetSecretStage(_ context.Context, _, stage string) (string, error) {
+	value, ok := s[stage]
+	if !ok {
+		return "", errors.New("no such version stage")
+	}
+	return value, nil
+}
+
+func TestRefreshJWTKeys(t *testing.T) {
+	ctx := context.Background()
+	cfg := &ServiceConfig{JWT: JWTConfig{Secret: "deployed", PreviousSecrets: []string{"retired"}, SecretARN: "jwt-secret"}}
+	keys := cfg.JWTAuth().Keys
+	require.Same(t, keys, cfg.JWTKeys(), "every route shares the keys")
+
+	// Never rotated: the deployed secret is kept until it expires
+	require.NoError(t, cfg.RefreshJWTKeys(ctx, stagedSecrets{awspkg.StageCurrent: "fetched"}))
+	current, previous := keys.Secrets()
+	assert.Equal(t, "fetched", current)
+	assert.Equal(t, []string{"deployed", "retired"}, previous)
+
+	// Rotated in Secrets Manager: the keys follow its stages
+	require.NoError(t, cfg.RefreshJWTKeys(ctx, stagedSecrets{awspkg.StageCurrent: "rotated", awspkg.StagePrevious: "fetched"}))
+	current, previous = keys.Secrets()
+	assert.Equal(t, "rotated", current)
+	assert.Equal(t, []string{"fetched", "retired"}, previous)
+
+	// A failed refresh leaves the keys alone
+	assert.Error(t, cfg.RefreshJWTKeys(ctx, stagedSecrets{}))
+	assert.Error(t, cfg.RefreshJWTKeys(ctx, stagedSecrets{awspkg.StageCurrent: ""}))
+	current, _ = keys.Secrets()
+	assert.Equal(t, "rotated", current)
+}
+
+func TestWatchJWTSecret(t *testing.T) {
+	cfg := &ServiceConfig{JWT: JWTConfig{Secret: "deployed", SecretARN: "jwt-secret", RefreshInterval: time.Millisecond}}
+	keys := cfg.JWTKeys()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		cfg.WatchJWTSecret(ctx, stagedSecrets{awspkg.StageCurrent: "rotated", awspkg.StagePrevious: "deployed"})
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		current, _ := keys.Secrets()
+		return current == "rotated"
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
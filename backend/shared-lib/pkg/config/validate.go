@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// cardKeyLength is the size of the AES-256 key card numbers are encrypted with
+const cardKeyLength = 32
+
+// minProductionJWTSecretLength is the shortest JWT secret accepted in
+// production, so that tokens cannot be forged by guessing a short secret
+const minProductionJWTSecretLength = 32
+
+// ValidationError lists every problem found in a configuration, so an
+// operator can fix them all at once instead of one failed start at a time
+type ValidationError struct {
+	Environment string
+	Problems    []string
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid configuration for environment %q (%d problems):", e.Environment, len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// Validate checks the configuration for the environment it is deployed to and
+// returns a *ValidationError listing every problem. Local environments only
+// need what a service cannot run without; dev and staging also need real
+// infrastructure; production additionally needs strong secrets and TLS.
+func (cfg *ServiceConfig) Validate() error {
+	env := strings.ToLower(cfg.Environment)
+	if env == "" {
+		env = string(EnvLocal)
+	}
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	local, production := false, false
+	switch env {
+	case string(EnvLocal):
+		local = true
+	case string(EnvDev), string(EnvStaging):
+	case string(EnvProduction), "production":
+		production = true
+	default:
+		add("environment %q is not one of local, dev, staging or prod", cfg.Environment)
+	}
+
+	if cfg.ServicePort < 0 || cfg.ServicePort > 65535 {
+		add("service_port %d is not a valid port", cfg.ServicePort)
+	}
+
+	if cfg.JWT.Secret == "" {
+		add("jwt.secret (JWT_SECRET) is required")
+	} else if production && len(cfg.JWT.Secret) < minProductionJWTSecretLength {
+		add("jwt.secret (JWT_SECRET) must be at least %d bytes in production", minProductionJWTSecretLength)
+	}
+	for _, secret := range cfg.JWT.PreviousSecrets {
+		if production && len(secret) < minProductionJWTSecretLength {
+			add("jwt.previous_secrets (JWT_PREVIOUS_SECRETS) must each be at least %d bytes in production", minProductionJWTSecretLength)
+			break
+		}
+	}
+
+	if cfg.Kafka.Async {
+		if len(cfg.Kafka.Brokers) == 0 {
+			add("kafka.brokers (KAFKA_BROKERS) is required when events are processed asynchronously")
+		}
+		for _, broker := range cfg.Kafka.Brokers {
+			if strings.TrimSpace(broker) == "" {
+				add("kafka.brokers (KAFKA_BROKERS) contains an empty broker address")
+				break
+			}
+		}
+	}
+
+	if cfg.Card != nil {
+		switch {
+		case cfg.Card.EncryptionKey == "" && production:
+			add("card.encryption_key (CARD_ENCRYPTION_KEY) is required in production")
+		case cfg.Card.EncryptionKey != "" && len(cfg.Card.EncryptionKey) != cardKeyLength:
+			add("card.encryption_key (CARD_ENCRYPTION_KEY) must be exactly %d bytes for AES-256, got %d",
+				cardKeyLength, len(cfg.Card.EncryptionKey))
+		}
+	}
+
+	if !local && cfg.Database.Host == "" {
+		add("database.host (DB_HOST) is required outside local environments")
+	}
+	if production {
+		if cfg.Database.Password == "" {
+			add("database.password (DB_PASSWORD) is required in production")
+		}
+		if strings.EqualFold(cfg.Database.SSLMode, "disable") {
+			add("database.sslmode (DB_SSLMODE) must not be disable in production")
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Environment: env, Problems: problems}
+	}
+	return nil
+}
+
+// FromEnv reads the settings Validate checks from the environment variables
+// the services are deployed with: ENVIRONMENT, JWT_SECRET, JWT_SECRET_ARN,
+// JWT_ISSUER, and JWT_PREVIOUS_SECRETS, JWT_AUDIENCES and KAFKA_BROKERS
+// (comma-separated) and DB_HOST, DB_PASSWORD and DB_SSLMODE, along with the
+// error response settings ERROR_PROBLEM_BASE_URI and ERROR_LEGACY_ENVELOPE.
+// JWT_ISSUER defaults to neobank and JWT_AUDIENCES to the service name.
+// Locally KAFKA_BROKERS defaults to localhost:9092; elsewhere it must be set.
+// Services that need Kafka or store card data set Kafka.Async or Card before
+// validating.
+func FromEnv(serviceName string) *ServiceConfig {
+	cfg := &ServiceConfig{
+		ServiceName: serviceName,
+		Environment: os.Getenv("ENVIRONMENT"),
+		Database: DatabaseConfig{
+			Host:     os.Getenv("DB_HOST"),
+			Password: os.Getenv("DB_PASSWORD"),
+			SSLMode:  os.Getenv("DB_SSLMODE"),
+		},
+		JWT: JWTConfig{
+			Secret:          os.Getenv("JWT_SECRET"),
+			PreviousSecrets: splitList(os.Getenv("JWT_PREVIOUS_SECRETS")),
+			Issuer:          os.Getenv("JWT_ISSUER"),
+			Audiences:       splitList(os.Getenv("JWT_AUDIENCES")),
+			SecretARN:       os.Getenv("JWT_SECRET_ARN"),
+		},
+		Kafka: KafkaConfig{Brokers: splitList(os.Getenv("KAFKA_BROKERS"))},
+		ErrorResponse: ErrorResponseConfig{
+			ProblemBaseURI: os.Getenv("ERROR_PROBLEM_BASE_URI"),
+			LegacyEnvelope: os.Getenv("ERROR_LEGACY_ENVELOPE") == "true",
+		},
+	}
+	if cfg.Environment == "" {
+		cfg.Environment = string(EnvLocal)
+	}
+	if cfg.JWT.Issuer == "" {
+		cfg.JWT.Issuer = "neobank"
+	}
+	cfg.JWT.RefreshInterval = DefaultJWTSecretRefreshInterval
+	if len(cfg.JWT.Audiences) == 0 {
+		cfg.JWT.Audiences = []string{serviceName}
+	}
+	if len(cfg.Kafka.Brokers) == 0 && strings.EqualFold(cfg.Environment, string(EnvLocal)) {
+		cfg.Kafka.Brokers = []string{"localhost:9092"}
+	}
+	return cfg
+}
+
+// splitList splits a comma-separated list, dropping blank entries
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
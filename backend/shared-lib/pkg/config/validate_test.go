@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func validationProblems(t *testing.T, cfg *ServiceConfig) []string {
+	t.Helper()
+	err := cfg.Validate()
+	if err == nil {
+		return nil
+	}
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "expected a *ValidationError, got %v", err)
+	return verr.Problems
+}
+
+func TestValidate_Local(t *testing.T) {
+	cfg := &ServiceConfig{JWT: JWTConfig{Secret: "short"}}
+	assert.NoError(t, cfg.Validate(), "local needs only a JWT secret")
+
+	cfg.JWT.Secret = ""
+	assert.Equal(t, []string{"jwt.secret (JWT_SECRET) is required"}, validationProblems(t, cfg))
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := &ServiceConfig{
+		Environment: "prod",
+		JWT:         JWTConfig{Secret: "too-short"},
+		Kafka:       KafkaConfig{Async: true},
+		Card:        &CardConfig{EncryptionKey: "sixteen-byte-key"},
+		Database:    DatabaseConfig{SSLMode: "disable"},
+	}
+
+	problems := validationProblems(t, cfg)
+	assert.Len(t, problems, 6)
+	report := cfg.Validate().Error()
+	for _, want := range []string{
+		`environment "prod" (6 problems)`,
+		"JWT_SECRET) must be at least 32 bytes",
+		"KAFKA_BROKERS) is required",
+		"must be exactly 32 bytes for AES-256, got 16",
+		"DB_HOST) is required",
+		"DB_PASSWORD) is required",
+		"DB_SSLMODE) must not be disable",
+	} {
+		assert.Contains(t, report, want)
+	}
+	assert.Equal(t, 6, strings.Count(report, "\n  - "))
+}
+
+func TestValidate_EnvironmentRules(t *testing.T) {
+	base := func(env string) *ServiceConfig {
+		return &ServiceConfig{
+			Environment: env,
+			JWT:         JWTConfig{Secret: "a-local-secret"},
+			Card:        &CardConfig{},
+		}
+	}
+
+	// Dev needs a database host but accepts short secrets and no card key
+	dev := base("dev")
+	assert.Equal(t, []string{"database.host (DB_HOST) is required outside local environments"}, validationProblems(t, dev))
+	dev.Database.Host = "postgres"
+	assert.NoError(t, dev.Validate())
+
+	prod := base("production")
+	prod.JWT.Secret = strings.Repeat("s", 32)
+	prod.Database = DatabaseConfig{Host: "postgres", Password: "secret", SSLMode: "require"}
+	assert.Equal(t, []string{"card.encryption_key (CARD_ENCRYPTION_KEY) is required in production"}, validationProblems(t, prod))
+	prod.Card.EncryptionKey = strings.Repeat("k", 32)
+	assert.NoError(t, prod.Validate())
+	prod.JWT.PreviousSecrets = []string{strings.Repeat("p", 32), "short"}
+	assert.Equal(t, []string{"jwt.previous_secrets (JWT_PREVIOUS_SECRETS) must each be at least 32 bytes in production"}, validationProblems(t, prod))
+
+	unknown := base("qa")
+	assert.Contains(t, validationProblems(t, unknown)[0], `environment "qa" is not one of`)
+}
+
+func TestValidate_KafkaOnlyRequiredWhenAsync(t *testing.T) {
+	cfg := &ServiceConfig{Environment: "staging", JWT: JWTConfig{Secret: "secret"}, Database: DatabaseConfig{Host: "db"}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Kafka.Async = true
+	cfg.Kafka.Brokers = []string{"kafka:9092", " "}
+	assert.Equal(t, []string{"kafka.brokers (KAFKA_BROKERS) contains an empty broker address"}, validationProblems(t, cfg))
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("ENVIRONMENT", "")
+	t.Setenv("JWT_SECRET", "env-secret")
+	t.Setenv("KAFKA_BROKERS", "")
+	t.Setenv("DB_HOST", "")
+	t.Setenv("JWT_ISSUER", "")
+	t.Setenv("JWT_AUDIENCES", "")
+	t.Setenv("JWT_PREVIOUS_SECRETS", "")
+	t.Setenv("JWT_SECRET_ARN", "")
+
+	cfg := FromEnv("test-service")
+	assert.Equal(t, "test-service", cfg.ServiceName)
+	assert.Equal(t, "local", cfg.Environment)
+	assert.Equal(t, "env-secret", cfg.JWT.Secret)
+	assert.Equal(t, "neobank", cfg.JWT.Issuer)
+	assert.Equal(t, []string{"test-service"}, cfg.JWT.Audiences, "a service accepts tokens addressed to it")
+	assert.Equal(t, []string{"localhost:9092"}, cfg.Kafka.Brokers, "local defaults to a local broker")
+	assert.Empty(t, cfg.JWT.PreviousSecrets)
+	assert.Equal(t, DefaultJWTSecretRefreshInterval, cfg.JWT.RefreshInterval)
+
+	t.Setenv("JWT_PREVIOUS_SECRETS", "old-secret, older-secret")
+	t.Setenv("JWT_SECRET_ARN", "arn:aws:secretsmanager:eu-west-2:123456789012:secret:jwt")
+	cfg = FromEnv("test-service")
+	assert.Equal(t, []string{"old-secret", "older-secret"}, cfg.JWT.PreviousSecrets)
+	assert.Equal(t, "arn:aws:secretsmanager:eu-west-2:123456789012:secret:jwt", cfg.JWT.SecretARN)
+	current, previous := cfg.JWTAuth().Keys.Secrets()
+	assert.Equal(t, "env-secret", current)
+	assert.Equal(t, []string{"old-secret", "older-secret"}, previous)
+
+	t.Setenv("ENVIRONMENT", "staging")
+	t.Setenv("DB_HOST", "postgres")
+	cfg = FromEnv("test-service")
+	assert.Empty(t, cfg.Kafka.Brokers, "brokers must be configured outside local")
+	cfg.Kafka.Async = true
+	assert.Equal(t, []string{"kafka.brokers (KAFKA_BROKERS) is required when events are processed asynchronously"}, validationProblems(t, cfg))
+
+	t.Setenv("KAFKA_BROKERS", "kafka-1:9092, kafka-2:9092")
+	cfg = FromEnv("test-service")
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, cfg.Kafka.Brokers)
+
+	t.Setenv("JWT_AUDIENCES", "ledger-service, payment-service")
+	cfg = FromEnv("gateway")
+	auth := cfg.JWTAuth()
+	assert.Equal(t, "neobank", auth.Issuer)
+	assert.Equal(t, []string{"ledger-service", "payment-service"}, auth.Audiences)
+	assert.Equal(t, []string{"ledger-service:admin", "payment-service:admin"}, auth.Admin().Audiences)
+
+	assert.Equal(t, apperrors.Config{Service: "gateway"}, cfg.ErrorResponses(), "problem details without the legacy envelope by default")
+	t.Setenv("ERROR_PROBLEM_BASE_URI", "https://docs.neobank.com/errors/")
+	t.Setenv("ERROR_LEGACY_ENVELOPE", "true")
+	cfg = FromEnv("payment-service")
+	assert.Equal(t, apperrors.Config{
+		Service:        "payment-service",
+		ProblemBaseURI: "https://docs.neobank.com/errors/",
+		LegacyEnvelope: true,
+	}, cfg.ErrorResponses())
+}
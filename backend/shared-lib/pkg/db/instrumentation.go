@@ -0,0 +1,242 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// DefaultSlowQueryThreshold is how long a query may take before it is logged
+// as slow, unless DB_SLOW_QUERY_THRESHOLD says otherwise
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// maxLoggedSQL bounds the SQL logged for a slow query
+const maxLoggedSQL = 2000
+
+var (
+	queryDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "db_query_duration_seconds",
+			Help:    "Database query latency by repository and operation",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		},
+		[]string{"repository", "operation"},
+	)
+
+	slowQueriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "db_slow_queries_total",
+			Help: "Total number of queries slower than the slow query threshold",
+		},
+		[]string{"repository", "operation"},
+	)
+)
+
+// SlowQueryThresholdFromEnv reads DB_SLOW_QUERY_THRESHOLD, a duration such as
+// 500ms; "off" turns slow query logging off. An invalid value is logged and
+// the default used.
+func SlowQueryThresholdFromEnv() time.Duration {
+	value := os.Getenv("DB_SLOW_QUERY_THRESHOLD")
+	switch value {
+	case "":
+		return DefaultSlowQueryThreshold
+	case "off":
+		return -1
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil || threshold <= 0 {
+		slog.Warn("Invalid DB_SLOW_QUERY_THRESHOLD, using the default", "value", value, "default", DefaultSlowQueryThreshold)
+		return DefaultSlowQueryThreshold
+	}
+	return threshold
+}
+
+// QueryInstrumentation is a GORM plugin that times every query into
+// db_query_duration_seconds, labelled with the repository that made it, and
+// logs the queries slower than SlowThreshold. Logged SQL carries placeholders
+// rather than bound values, and literals in it are masked, so no customer data
+// reaches the logs.
+type QueryInstrumentation struct {
+	// SlowThreshold is how long a query may take before it is logged; zero
+	// or negative logs none
+	SlowThreshold time.Duration
+}
+
+// queryStartKey holds the start time of a statement between the callbacks
+const queryStartKey = "instrumentation:start"
+
+func (p *QueryInstrumentation) Name() string {
+	return "neobank:query_instrumentation"
+}
+
+func (p *QueryInstrumentation) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("instrumentation:before_create", startQuery),
+		cb.Create().After("gorm:create").Register("instrumentation:after_create", p.finishQuery("create")),
+		cb.Query().Before("gorm:query").Register("instrumentation:before_query", startQuery),
+		cb.Query().After("gorm:query").Register("instrumentation:after_query", p.finishQuery("query")),
+		cb.Update().Before("gorm:update").Register("instrumentation:before_update", startQuery),
+		cb.Update().After("gorm:update").Register("instrumentation:after_update", p.finishQuery("update")),
+		cb.Delete().Before("gorm:delete").Register("instrumentation:before_delete", startQuery),
+		cb.Delete().After("gorm:delete").Register("instrumentation:after_delete", p.finishQuery("delete")),
+		cb.Row().Before("gorm:row").Register("instrumentation:before_row", startQuery),
+		cb.Row().After("gorm:row").Register("instrumentation:after_row", p.finishQuery("row")),
+		cb.Raw().Before("gorm:raw").Register("instrumentation:before_raw", startQuery),
+		cb.Raw().After("gorm:raw").Register("instrumentation:after_raw", p.finishQuery("raw")),
+	)
+}
+
+func startQuery(db *gorm.DB) {
+	db.InstanceSet(queryStartKey, time.Now())
+}
+
+func (p *QueryInstrumentation) finishQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(queryStartKey)
+		start, isTime := value.(time.Time)
+		if !ok || !isTime {
+			return
+		}
+		elapsed := time.Since(start)
+		repository := callerRepository()
+		queryDuration.WithLabelValues(repository, operation).Observe(elapsed.Seconds())
+
+		if p.SlowThreshold <= 0 || elapsed < p.SlowThreshold {
+			return
+		}
+		slowQueriesTotal.WithLabelValues(repository, operation).Inc()
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		attrs := []any{
+			"duration_ms", elapsed.Milliseconds(),
+			"threshold_ms", p.SlowThreshold.Milliseconds(),
+			"repository", repository,
+			"operation", operation,
+			"table", db.Statement.Table,
+			"rows", db.Statement.RowsAffected,
+			"sql", sanitizeSQL(db.Statement.SQL.String()),
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			attrs = append(attrs, "trace_id", sc.TraceID().String())
+		}
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			attrs = append(attrs, "error", db.Error)
+		}
+		slog.WarnContext(ctx, "Slow database query", attrs...)
+	}
+}
+
+// callerRepository names the repository type whose method made the query, by
+// finding the first repository package on the stack. Queries made elsewhere,
+// such as by migrations or the job runner, are "other".
+func callerRepository() string {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if name, ok := repositoryName(frame.Function); ok {
+			return name
+		}
+		if !more {
+			return "other"
+		}
+	}
+}
+
+// repositoryName returns the receiver type of a function in a repository
+// package, e.g. PaymentRepository for
+// .../internal/repository.(*PaymentRepository).GetPayment
+func repositoryName(function string) (string, bool) {
+	_, fn, ok := strings.Cut(function, "/repository.")
+	if !ok {
+		return "", false
+	}
+	receiver, _, isMethod := strings.Cut(fn, ").")
+	if !isMethod {
+		// A package function, or a method with a value receiver
+		receiver, _, _ = strings.Cut(fn, ".")
+		return strings.TrimPrefix(receiver, "("), true
+	}
+	return strings.TrimPrefix(receiver, "(*"), true
+}
+
+var (
+	stringLiteral  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	numericLiteral = regexp.MustCompile(`([^\w$.])\d+(?:\.\d+)?\b`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// sanitizeSQL masks the literals of a statement and bounds its length. GORM
+// keeps bound values out of the SQL, but raw statements and expressions may
+// still inline them.
+func sanitizeSQL(query string) string {
+	query = stringLiteral.ReplaceAllString(query, "'?'")
+	query = numericLiteral.ReplaceAllString(query, "${1}?")
+	query = strings.TrimSpace(whitespace.ReplaceAllString(query, " "))
+	if len(query) > maxLoggedSQL {
+		query = query[:maxLoggedSQL] + "..."
+	}
+	return query
+}
+
+// poolCollector exports the statistics of a connection pool, read at each scrape
+type poolCollector struct {
+	db           *sql.DB
+	maxOpen      *prometheus.Desc
+	open         *prometheus.Desc
+	inUse        *prometheus.Desc
+	idle         *prometheus.Desc
+	waitCount    *prometheus.Desc
+	waitDuration *prometheus.Desc
+}
+
+// RegisterPoolMetrics exports the connection pool of sqlDB as db_pool_*
+// metrics labelled with the database name. A database can be registered once.
+func RegisterPoolMetrics(sqlDB *sql.DB, database string) error {
+	labels := prometheus.Labels{"database": database}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(name, help, nil, labels)
+	}
+	return prometheus.Register(&poolCollector{
+		db:           sqlDB,
+		maxOpen:      desc("db_pool_max_open_connections", "Maximum number of open connections to the database"),
+		open:         desc("db_pool_open_connections", "Number of open connections, in use or idle"),
+		inUse:        desc("db_pool_in_use_connections", "Number of connections currently in use"),
+		idle:         desc("db_pool_idle_connections", "Number of idle connections"),
+		waitCount:    desc("db_pool_wait_count_total", "Total number of connections waited for"),
+		waitDuration: desc("db_pool_wait_duration_seconds_total", "Total time spent waiting for a connection"),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
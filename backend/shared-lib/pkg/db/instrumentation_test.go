@@ -0,0 +1,136 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT * FROM "payments" WHERE id = $1 LIMIT $2`, `SELECT * FROM "payments" WHERE id = $1 LIMIT $2`},
+		{"SELECT * FROM users WHERE email = 'a@b.com' AND note = 'it''s'", "SELECT * FROM users WHERE email = '?' AND note = '?'"},
+		{"UPDATE accounts SET balance = balance - 12.50 WHERE id = 7", "UPDATE accounts SET balance = balance - ? WHERE id = ?"},
+		{"SELECT  col1,\n\tcol2 FROM t2", "SELECT col1, col2 FROM t2"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, sanitizeSQL(tt.query))
+	}
+	assert.Len(t, sanitizeSQL(string(bytes.Repeat([]byte("x"), 3*maxLoggedSQL))), maxLoggedSQL+3)
+}
+
+func TestRepositoryName(t *testing.T) {
+	tests := []struct {
+		function string
+		want     string
+		ok       bool
+	}{
+		{"github.com/femi-lawal/new_bank/backend/payment-service/internal/repository.(*PaymentRepository).GetPayment", "PaymentRepository", true},
+		{"github.com/femi-lawal/new_bank/backend/ledger-service/internal/repository.(*LedgerRepository).PostEntry.func1", "LedgerRepository", true},
+		{"github.com/femi-lawal/new_bank/backend/card-service/internal/repository.CardRepository.List", "CardRepository", true},
+		{"github.com/femi-lawal/new_bank/backend/payment-service/internal/service.(*PaymentService).CreatePayment", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := repositoryName(tt.function)
+		assert.Equal(t, tt.ok, ok, tt.function)
+		assert.Equal(t, tt.want, name, tt.function)
+	}
+}
+
+// dryRunDB is a GORM database that builds statements without a connection
+func dryRunDB(t *testing.T, threshold time.Duration) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.Open("host=localhost"), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(&QueryInstrumentation{SlowThreshold: threshold}))
+	return db
+}
+
+// captureLogs sends slog's default logger to a buffer for the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+type instrumentedRow struct {
+	ID    int
+	Email string
+}
+
+func TestQueryInstrumentation_LogsSlowQueries(t *testing.T) {
+	logs := captureLogs(t)
+	db := dryRunDB(t, time.Nanosecond)
+
+	traceID := trace.TraceID{1, 2, 3, 4}
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+	var rows []instrumentedRow
+	require.NoError(t, db.WithContext(ctx).Where("email = ?", "someone@example.com").Find(&rows).Error)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "Slow database query", entry["msg"])
+	assert.Equal(t, traceID.String(), entry["trace_id"])
+	assert.Equal(t, "other", entry["repository"])
+	assert.Equal(t, "query", entry["operation"])
+	assert.Equal(t, `SELECT * FROM "instrumented_rows" WHERE email = $1`, entry["sql"])
+	assert.NotContains(t, logs.String(), "someone@example.com")
+}
+
+func TestQueryInstrumentation_FastQueriesAreNotLogged(t *testing.T) {
+	logs := captureLogs(t)
+	for _, threshold := range []time.Duration{time.Hour, -1} {
+		var rows []instrumentedRow
+		require.NoError(t, dryRunDB(t, threshold).Find(&rows).Error)
+	}
+	assert.Empty(t, logs.String())
+}
+
+func TestRegisterPoolMetrics(t *testing.T) {
+	db := dryRunDB(t, -1)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(25)
+
+	require.NoError(t, RegisterPoolMetrics(sqlDB, "pool_test"))
+	assert.Error(t, RegisterPoolMetrics(sqlDB, "pool_test"), "a database is registered once")
+	require.NoError(t, RegisterPoolMetrics(sqlDB, "pool_test_other"))
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "database" && label.GetValue() == "pool_test" {
+					values[family.GetName()] = metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	assert.Equal(t, 25.0, values["db_pool_max_open_connections"])
+	assert.Contains(t, values, "db_pool_open_connections")
+	assert.Contains(t, values, "db_pool_in_use_connections")
+	assert.Contains(t, values, "db_pool_idle_connections")
+	assert.Contains(t, values, "db_pool_wait_count_total")
+	assert.Contains(t, values, "db_pool_wait_duration_seconds_total")
+}
@@ -0,0 +1,437 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+var (
+	ErrPendingMigrations = errors.New("database has pending migrations")
+	ErrDirtyDatabase     = errors.New("database is dirty: a migration failed part-way and must be fixed by hand")
+)
+
+// Migration is one versioned schema change. Files are named the same way as
+// golang-migrate: 000001_create_users.up.sql and 000001_create_users.down.sql.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+var (
+	migrationFilePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	tableNamePattern     = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+)
+
+// LoadMigrations reads every migration in the root of fsys, ordered by version.
+// Each version needs both an up and a down file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: file name must look like 000001_name.up.sql", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: version must be a positive number", entry.Name())
+		}
+
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %06d_%s needs non-empty up and down files", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies migrations to Postgres and records the current version in
+// a golang-migrate compatible table (version, dirty). Services share one
+// database, so each keeps its own table.
+//
+// Every migration runs in its own transaction under an advisory lock, so
+// replicas starting together apply each migration once. Statements that
+// cannot run in a transaction, like CREATE INDEX CONCURRENTLY, are not supported.
+type Migrator struct {
+	DB         *sql.DB
+	Table      string
+	Migrations []Migration
+}
+
+// NewMigrator creates a migrator for a service's embedded migrations.
+// The version table is schema_migrations_<service>, e.g. schema_migrations_identity_service.
+func NewMigrator(gdb *gorm.DB, serviceName string, fsys fs.FS) (*Migrator, error) {
+	sqlDB, err := gdb.DB()
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+	table := "schema_migrations_" + strings.ReplaceAll(serviceName, "-", "_")
+	if !tableNamePattern.MatchString(table) {
+		return nil, fmt.Errorf("invalid migration table name %q", table)
+	}
+	return &Migrator{DB: sqlDB, Table: table, Migrations: migrations}, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.Table+` (version bigint NOT NULL PRIMARY KEY, dirty boolean NOT NULL)`)
+	return err
+}
+
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (m *Migrator) readVersion(ctx context.Context, q queryer) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := q.QueryRowContext(ctx, `SELECT version, dirty FROM `+m.Table+` LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+// Version returns the last applied migration version, or 0 if none have been applied
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, false, err
+	}
+	return m.readVersion(ctx, m.DB)
+}
+
+// Pending returns the migrations that have not been applied yet
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, ErrDirtyDatabase
+	}
+	return pendingAfter(m.Migrations, version), nil
+}
+
+// Check returns ErrDirtyDatabase or ErrPendingMigrations unless every
+// migration has been applied. Unlike Pending it does not create the version
+// table, so it is cheap enough for a readiness probe.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.readVersion(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return ErrDirtyDatabase
+	}
+	if pending := pendingAfter(m.Migrations, version); len(pending) > 0 {
+		return fmt.Errorf("%w: %d not applied, at version %d", ErrPendingMigrations, len(pending), version)
+	}
+	return nil
+}
+
+func pendingAfter(migrations []Migration, version uint) []Migration {
+	for i, mig := range migrations {
+		if mig.Version > version {
+			return migrations[i:]
+		}
+	}
+	return nil
+}
+
+// previousVersion returns the version before the given one, or 0 at the start
+func previousVersion(migrations []Migration, version uint) uint {
+	prev := uint(0)
+	for _, mig := range migrations {
+		if mig.Version >= version {
+			break
+		}
+		prev = mig.Version
+	}
+	return prev
+}
+
+// Up applies all pending migrations and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	applied := 0
+	for {
+		done, err := m.step(ctx, func(current uint) (*Migration, string, uint) {
+			pending := pendingAfter(m.Migrations, current)
+			if len(pending) == 0 {
+				return nil, "", 0
+			}
+			return &pending[0], pending[0].Up, pending[0].Version
+		})
+		if err != nil || done == nil {
+			return applied, err
+		}
+		slog.Info("Applied migration", "table", m.Table, "version", done.Version, "name", done.Name)
+		applied++
+	}
+}
+
+// Down rolls back up to steps migrations and returns how many were rolled back
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return 0, err
+	}
+	rolledBack := 0
+	for rolledBack < steps {
+		done, err := m.step(ctx, func(current uint) (*Migration, string, uint) {
+			for i := range m.Migrations {
+				if m.Migrations[i].Version == current {
+					return &m.Migrations[i], m.Migrations[i].Down, previousVersion(m.Migrations, current)
+				}
+			}
+			return nil, "", 0
+		})
+		if err != nil || done == nil {
+			return rolledBack, err
+		}
+		slog.Info("Rolled back migration", "table", m.Table, "version", done.Version, "name", done.Name)
+		rolledBack++
+	}
+	return rolledBack, nil
+}
+
+// step runs one migration chosen by next in a transaction. The advisory lock
+// serialises concurrent migrators, and the version is re-read under the lock.
+func (m *Migrator) step(ctx context.Context, next func(current uint) (*Migration, string, uint)) (*Migration, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, m.Table); err != nil {
+		return nil, err
+	}
+	current, dirty, err := m.readVersion(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, ErrDirtyDatabase
+	}
+
+	mig, script, newVersion := next(current)
+	if mig == nil {
+		return nil, nil
+	}
+	if current != 0 && !m.known(current) {
+		return nil, fmt.Errorf("database is at version %d, which this build does not know", current)
+	}
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return nil, fmt.Errorf("migration %06d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+m.Table); err != nil {
+		return nil, err
+	}
+	if newVersion > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+m.Table+` (version, dirty) VALUES ($1, false)`, int64(newVersion)); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return mig, nil
+}
+
+func (m *Migrator) known(version uint) bool {
+	for _, mig := range m.Migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyModels checks that the migrations create every table and column the
+// GORM models use. Services call it from a test so a model change without a
+// matching migration fails CI instead of failing at runtime.
+func VerifyModels(migrations []Migration, models ...interface{}) error {
+	var sqlText strings.Builder
+	for _, mig := range migrations {
+		sqlText.WriteString(strings.ToLower(mig.Up))
+		sqlText.WriteString("\n")
+	}
+	script := sqlText.String()
+
+	var missing []string
+	cache := &sync.Map{}
+	for _, model := range models {
+		sch, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			return err
+		}
+		table := regexp.MustCompile(`(create|alter)\s+table\s+(if\s+not\s+exists\s+)?` + regexp.QuoteMeta(sch.Table) + `\b`)
+		if !table.MatchString(script) {
+			missing = append(missing, sch.Table)
+			continue
+		}
+		for _, field := range sch.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			if !regexp.MustCompile(`\b` + regexp.QuoteMeta(field.DBName) + `\b`).MatchString(script) {
+				missing = append(missing, sch.Table+"."+field.DBName)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("migrations do not create: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// MigrateOnStartup replaces AutoMigrate at service start. Outside production
+// it applies pending migrations; in production it only checks, and returns
+// ErrPendingMigrations so the service refuses to boot on an outdated schema.
+// Run the service's "migrate up" command as a deploy step instead.
+func MigrateOnStartup(ctx context.Context, m *Migrator, environment string) error {
+	if !isProduction(environment) {
+		_, err := m.Up(ctx)
+		return err
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		names := make([]string, len(pending))
+		for i, mig := range pending {
+			names[i] = fmt.Sprintf("%06d_%s", mig.Version, mig.Name)
+		}
+		return fmt.Errorf("%w: %s", ErrPendingMigrations, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func isProduction(environment string) bool {
+	env := strings.ToLower(environment)
+	return env == "prod" || env == "production"
+}
+
+// ParseCommand splits a service's arguments (os.Args[1:]) into its command,
+// "serve" by default or "migrate", and the arguments that follow it
+func ParseCommand(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "serve", nil, nil
+	}
+	switch args[0] {
+	case "serve", "migrate":
+		return args[0], args[1:], nil
+	default:
+		return "", nil, fmt.Errorf("unknown command %q; use serve or migrate up | down [N] | status", args[0])
+	}
+}
+
+// RunMigrateCommand implements the "migrate" subcommand shared by all services:
+//
+//	migrate up          apply all pending migrations
+//	migrate down [N]    roll back N migrations (default 1)
+//	migrate status      print the current version and pending migrations
+func RunMigrateCommand(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [N] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migration(s)\n", applied)
+	case "down":
+		steps, err := parseSteps(args[1:])
+		if err != nil {
+			return err
+		}
+		rolledBack, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", rolledBack)
+	case "status":
+		version, dirty, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "version: %d (dirty: %t)\n", version, dirty)
+		for _, mig := range pendingAfter(m.Migrations, version) {
+			fmt.Fprintf(out, "pending: %06d_%s\n", mig.Version, mig.Name)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q; use up, down or status", args[0])
+	}
+	return nil
+}
+
+func parseSteps(args []string) (int, error) {
+	if len(args) == 0 {
+		return 1, nil
+	}
+	steps, err := strconv.Atoi(args[0])
+	if err != nil || steps < 1 {
+		return 0, fmt.Errorf("down steps must be a positive number, got %q", args[0])
+	}
+	return steps, nil
+}
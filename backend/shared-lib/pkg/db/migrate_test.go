@@ -0,0 +1,144 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func migrationFS() fstest.MapFS {
+	return fstest.MapFS{
+		"000002_add_role.up.sql":       {Data: []byte("ALTER TABLE users ADD COLUMN role text;")},
+		"000002_add_role.down.sql":     {Data: []byte("ALTER TABLE users DROP COLUMN role;")},
+		"000001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id uuid);")},
+		"000001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"000010_add_index.up.sql":      {Data: []byte("CREATE INDEX idx ON users (id);")},
+		"000010_add_index.down.sql":    {Data: []byte("DROP INDEX idx;")},
+		"migrations.go":                {Data: []byte("package migrations")},
+		"README.md":                    {Data: []byte("notes")},
+		"subdir/000099_ignored.up.sql": {Data: []byte("SELECT 1;")},
+	}
+}
+
+func TestLoadMigrations_OrdersByVersion(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFS())
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+
+	assert.Equal(t, uint(1), migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Equal(t, "CREATE TABLE users (id uuid);", migrations[0].Up)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].Down)
+	assert.Equal(t, uint(2), migrations[1].Version)
+	assert.Equal(t, uint(10), migrations[2].Version)
+}
+
+func TestLoadMigrations_RejectsInvalidSets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{"missing down", fstest.MapFS{
+			"000001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id uuid);")},
+		}},
+		{"empty up", fstest.MapFS{
+			"000001_create_users.up.sql":   {Data: []byte("  \n")},
+			"000001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		}},
+		{"bad name", fstest.MapFS{
+			"create_users.sql": {Data: []byte("CREATE TABLE users (id uuid);")},
+		}},
+		{"version zero", fstest.MapFS{
+			"000000_init.up.sql":   {Data: []byte("SELECT 1;")},
+			"000000_init.down.sql": {Data: []byte("SELECT 1;")},
+		}},
+		{"two names for one version", fstest.MapFS{
+			"000001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id uuid);")},
+			"000001_make_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadMigrations(tt.files)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestPendingAndPreviousVersion(t *testing.T) {
+	migrations, err := LoadMigrations(migrationFS())
+	require.NoError(t, err)
+
+	assert.Len(t, pendingAfter(migrations, 0), 3)
+	pending := pendingAfter(migrations, 2)
+	require.Len(t, pending, 1)
+	assert.Equal(t, uint(10), pending[0].Version)
+	assert.Empty(t, pendingAfter(migrations, 10))
+	// A database ahead of this build has nothing pending
+	assert.Empty(t, pendingAfter(migrations, 11))
+
+	assert.Equal(t, uint(0), previousVersion(migrations, 1))
+	assert.Equal(t, uint(2), previousVersion(migrations, 10))
+}
+
+func TestRunMigrateCommand_ValidatesArguments(t *testing.T) {
+	m := &Migrator{Table: "schema_migrations_test"}
+	var out bytes.Buffer
+
+	assert.Error(t, RunMigrateCommand(context.Background(), m, nil, &out))
+	assert.ErrorContains(t, RunMigrateCommand(context.Background(), m, []string{"sideways"}, &out), "unknown migrate command")
+	assert.ErrorContains(t, RunMigrateCommand(context.Background(), m, []string{"down", "0"}, &out), "positive number")
+	assert.ErrorContains(t, RunMigrateCommand(context.Background(), m, []string{"down", "two"}, &out), "positive number")
+
+	steps, err := parseSteps(nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, steps)
+}
+
+func TestParseCommand(t *testing.T) {
+	command, rest, err := ParseCommand(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "serve", command)
+	assert.Empty(t, rest)
+
+	command, rest, err = ParseCommand([]string{"migrate", "down", "2"})
+	require.NoError(t, err)
+	assert.Equal(t, "migrate", command)
+	assert.Equal(t, []string{"down", "2"}, rest)
+
+	_, _, err = ParseCommand([]string{"--port=80"})
+	assert.Error(t, err)
+}
+
+func TestVerifyModels(t *testing.T) {
+	type User struct {
+		ID    string
+		Role  string
+		Email string
+	}
+	type Session struct {
+		ID string
+	}
+
+	migrations, err := LoadMigrations(migrationFS())
+	require.NoError(t, err)
+
+	err = VerifyModels(migrations, &User{})
+	assert.ErrorContains(t, err, "users.email")
+	assert.NotContains(t, err.Error(), "users.role")
+
+	err = VerifyModels(migrations, &Session{})
+	assert.ErrorContains(t, err, "sessions")
+}
+
+func TestIsProduction(t *testing.T) {
+	assert.True(t, isProduction("prod"))
+	assert.True(t, isProduction("Production"))
+	assert.False(t, isProduction("staging"))
+	assert.False(t, isProduction(""))
+}
@@ -2,12 +2,15 @@ package db
 
 import (
 	"fmt"
+	"log"
 	"log/slog"
 	"net/url"
+	"os"
 	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
 )
 
 type Config struct {
@@ -17,6 +20,9 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// SlowQueryThreshold is how long a query may take before it is logged.
+	// Zero reads DB_SLOW_QUERY_THRESHOLD, negative logs no slow queries.
+	SlowQueryThreshold time.Duration
 }
 
 func Connect(cfg Config) (*gorm.DB, error) {
@@ -33,12 +39,20 @@ func Connect(cfg Config) (*gorm.DB, error) {
 
 	dsn := u.String()
 
+	// GORM's own slow query log prints bound values, so QueryInstrumentation
+	// logs slow queries instead, and errors are logged without values
+	gormLogger := gormlogger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), gormlogger.Config{
+		LogLevel:             gormlogger.Warn,
+		ParameterizedQueries: true,
+		Colorful:             true,
+	})
+
 	// Retry logic
 	var db *gorm.DB
 	var err error
 
 	for i := 0; i < 10; i++ {
-		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: gormLogger})
 		if err == nil {
 			break
 		}
@@ -60,6 +74,17 @@ func Connect(cfg Config) (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
+	threshold := cfg.SlowQueryThreshold
+	if threshold == 0 {
+		threshold = SlowQueryThresholdFromEnv()
+	}
+	if err := db.Use(&QueryInstrumentation{SlowThreshold: threshold}); err != nil {
+		return nil, fmt.Errorf("failed to instrument database: %w", err)
+	}
+	if err := RegisterPoolMetrics(sqlDB, cfg.DBName); err != nil {
+		slog.Warn("Database pool metrics not registered", "dbname", cfg.DBName, "error", err)
+	}
+
 	slog.Info("Successfully connected to database", "dbname", cfg.DBName)
 	return db, nil
 }
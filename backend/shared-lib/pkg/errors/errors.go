@@ -63,6 +63,22 @@ var (
 		Message:    "You do not have permission to access this resource",
 		HTTPStatus: http.StatusForbidden,
 	}
+
+	// ErrStepUpRequired asks the user to confirm their identity again, through
+	// the identity service's step-up endpoint, before a high-risk action
+	ErrStepUpRequired = &AppError{
+		Code:       "STEP_UP_REQUIRED",
+		Message:    "Please confirm your identity again to continue",
+		HTTPStatus: http.StatusUnauthorized,
+	}
+
+	// ErrTermsAcceptanceRequired asks the user to accept the current terms,
+	// through the identity service's terms endpoints, before changing anything
+	ErrTermsAcceptanceRequired = &AppError{
+		Code:       "TERMS_ACCEPTANCE_REQUIRED",
+		Message:    "Please accept the updated terms to continue",
+		HTTPStatus: http.StatusForbidden,
+	}
 )
 
 // Validation Errors
@@ -115,6 +131,18 @@ var (
 		HTTPStatus: http.StatusForbidden,
 	}
 
+	ErrAccountDebitsFrozen = &AppError{
+		Code:       "ACCOUNT_DEBITS_FROZEN",
+		Message:    "Account is frozen for outgoing payments",
+		HTTPStatus: http.StatusForbidden,
+	}
+
+	ErrAccountLegalHold = &AppError{
+		Code:       "ACCOUNT_LEGAL_HOLD",
+		Message:    "Account is subject to a legal hold and cannot make payments",
+		HTTPStatus: http.StatusForbidden,
+	}
+
 	ErrTransferLimit = &AppError{
 		Code:       "TRANSFER_LIMIT_EXCEEDED",
 		Message:    "Transfer amount exceeds limit",
@@ -168,27 +196,25 @@ var (
 // Error Response Helpers
 // =============================================================================
 
-// ErrorResponse is the standard error response format
+// ErrorResponse is the error envelope written before RFC 7807 problem
+// details; problems repeat it in their error member while
+// Config.LegacyEnvelope is set
 type ErrorResponse struct {
 	Error ErrorBody `json:"error"`
 }
 
-// ErrorBody is the error details in the response
+// ErrorBody is the error details in the envelope
 type ErrorBody struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
 	Details any    `json:"details,omitempty"`
 }
 
-// RespondWithError writes an error response to the gin context
+// RespondWithError writes err as application/problem+json, see NewProblem
 func RespondWithError(c *gin.Context, err *AppError) {
-	c.AbortWithStatusJSON(err.HTTPStatus, ErrorResponse{
-		Error: ErrorBody{
-			Code:    err.Code,
-			Message: err.Message,
-			Details: err.Details,
-		},
-	})
+	problem := NewProblem(c, err)
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
 }
 
 // ErrorMiddleware handles panics and converts them to proper error responses
@@ -1,12 +1,14 @@
 package errors
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -119,12 +121,15 @@ func TestPredefinedErrors(t *testing.T) {
 		{"Unauthorized", ErrUnauthorized, http.StatusUnauthorized},
 		{"InvalidToken", ErrInvalidToken, http.StatusUnauthorized},
 		{"Forbidden", ErrForbidden, http.StatusForbidden},
+		{"StepUpRequired", ErrStepUpRequired, http.StatusUnauthorized},
 		{"Validation", ErrValidation, http.StatusBadRequest},
 		{"InvalidRequest", ErrInvalidRequest, http.StatusBadRequest},
 		{"NotFound", ErrNotFound, http.StatusNotFound},
 		{"AlreadyExists", ErrAlreadyExists, http.StatusConflict},
 		{"InsufficientFunds", ErrInsufficientFunds, http.StatusBadRequest},
 		{"AccountFrozen", ErrAccountFrozen, http.StatusForbidden},
+		{"AccountDebitsFrozen", ErrAccountDebitsFrozen, http.StatusForbidden},
+		{"AccountLegalHold", ErrAccountLegalHold, http.StatusForbidden},
 		{"TransferLimit", ErrTransferLimit, http.StatusBadRequest},
 		{"SameAccount", ErrSameAccount, http.StatusBadRequest},
 		{"InvalidAmount", ErrInvalidAmount, http.StatusBadRequest},
@@ -157,3 +162,72 @@ func TestErrorMiddleware_RecoversPanic(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Contains(t, w.Body.String(), "INTERNAL_ERROR")
 }
+
+func TestRespondWithError_WritesProblemDetails(t *testing.T) {
+	t.Cleanup(func() { Configure(Config{}) })
+	Configure(Config{Service: "payment-service"})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("request_id", "req-42") })
+	r.GET("/api/v1/transfers/:id", func(c *gin.Context) {
+		RespondWithError(c, ErrInsufficientFunds.WithMessage("Balance is 10.00 GBP"))
+	})
+	r.GET("/api/v1/credits/:id", func(c *gin.Context) {
+		RespondWithError(c, NewError("INCOMING_CREDIT_STATE", "Only credits held in suspense can be reposted", http.StatusConflict))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/transfers/7", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+	var problem map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, map[string]any{
+		"type":     "https://api.neobank.com/problems/insufficient-funds",
+		"title":    "Insufficient funds for this transaction",
+		"status":   float64(http.StatusBadRequest),
+		"detail":   "Balance is 10.00 GBP",
+		"instance": "/api/v1/transfers/7",
+		"trace_id": "req-42",
+		"code":     "INSUFFICIENT_FUNDS",
+	}, problem)
+
+	// Codes a service defines are namespaced under it, titled once registered
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/credits/1", nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "https://api.neobank.com/problems/payment-service/incoming-credit-state", problem["type"])
+	assert.Equal(t, "Conflict", problem["title"])
+
+	Problems().Register("INCOMING_CREDIT_STATE", "Incoming credit is in the wrong state")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/credits/1", nil))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "Incoming credit is in the wrong state", problem["title"])
+	assert.Contains(t, Problems().Types(), ProblemType{
+		Code:  "INCOMING_CREDIT_STATE",
+		Type:  "https://api.neobank.com/problems/payment-service/incoming-credit-state",
+		Title: "Incoming credit is in the wrong state",
+	})
+}
+
+func TestRespondWithError_LegacyEnvelope(t *testing.T) {
+	t.Cleanup(func() { Configure(Config{}) })
+	Configure(Config{ProblemBaseURI: "https://docs.example.com/errors", LegacyEnvelope: true})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	RespondWithError(c, ErrValidation.WithDetails(map[string]string{"field": "email"}))
+
+	var body struct {
+		Problem
+		Error ErrorBody `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "https://docs.example.com/errors/validation-error", body.Type)
+	assert.Equal(t, "VALIDATION_ERROR", body.Code)
+	assert.Equal(t, "VALIDATION_ERROR", body.Error.Code, "clients reading the old envelope keep working")
+	assert.Equal(t, "Request validation failed", body.Error.Message)
+	assert.Equal(t, map[string]any{"field": "email"}, body.Error.Details)
+}
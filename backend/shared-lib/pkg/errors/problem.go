@@ -0,0 +1,220 @@
+package errors
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details
+const ProblemContentType = "application/problem+json"
+
+// DefaultProblemBaseURI prefixes problem type URIs unless Config sets another
+const DefaultProblemBaseURI = "https://api.neobank.com/problems/"
+
+// Problem is an RFC 7807 problem details object. Code and Details are
+// extension members carrying the AppError's code and details, so clients can
+// dispatch on either the type URI or the code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// TraceID finds the request in traces and logs
+	TraceID string `json:"trace_id,omitempty"`
+	Code    string `json:"code"`
+	Details any    `json:"details,omitempty"`
+	// Error repeats the problem in the pre-RFC 7807 envelope while
+	// Config.LegacyEnvelope is set
+	Error *ErrorBody `json:"error,omitempty"`
+}
+
+// Config sets how error responses are written
+type Config struct {
+	// Service namespaces the problem types of the codes a service defines itself
+	Service string
+	// ProblemBaseURI prefixes problem type URIs; it defaults to DefaultProblemBaseURI
+	ProblemBaseURI string
+	// LegacyEnvelope adds the {"error": {"code", "message", "details"}}
+	// member to every problem, for clients not yet reading problem details
+	LegacyEnvelope bool
+}
+
+// ProblemType is a class of problem: the URI clients dispatch on and a title
+// that stays the same whatever the occurrence
+type ProblemType struct {
+	Code  string `json:"code"`
+	Type  string `json:"type"`
+	Title string `json:"title"`
+}
+
+// ProblemRegistry maps error codes to problem types. The standard errors of
+// this package are registered with every registry; a service registers titles
+// for the codes it defines, whose types are namespaced under the service.
+// Codes never registered get a type derived from the code and the status
+// text as title.
+type ProblemRegistry struct {
+	mu      sync.RWMutex
+	baseURI string
+	service string
+	titles  map[string]string
+}
+
+// standardErrors are the errors every service shares, registered with their
+// default message as title
+var standardErrors = []*AppError{
+	ErrUnauthorized, ErrInvalidToken, ErrForbidden, ErrStepUpRequired, ErrTermsAcceptanceRequired,
+	ErrValidation, ErrInvalidRequest, ErrMissingField,
+	ErrNotFound, ErrAlreadyExists,
+	ErrInsufficientFunds, ErrAccountFrozen, ErrAccountDebitsFrozen, ErrAccountLegalHold,
+	ErrTransferLimit, ErrSameAccount, ErrInvalidAmount,
+	ErrInternal, ErrServiceUnavailable, ErrTimeout,
+	ErrRateLimited,
+}
+
+// NewProblemRegistry returns a registry of the standard problem types
+func NewProblemRegistry(baseURI, service string) *ProblemRegistry {
+	r := &ProblemRegistry{titles: make(map[string]string)}
+	r.configure(baseURI, service)
+	return r
+}
+
+func (r *ProblemRegistry) configure(baseURI, service string) {
+	if baseURI == "" {
+		baseURI = DefaultProblemBaseURI
+	}
+	if !strings.HasSuffix(baseURI, "/") {
+		baseURI += "/"
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.baseURI, r.service = baseURI, service
+}
+
+// Register names the problem type of an error code the service defines
+func (r *ProblemRegistry) Register(code, title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.titles[code] = title
+}
+
+// RegisterAll names the problem types of the codes a service defines, keyed by code
+func (r *ProblemRegistry) RegisterAll(titles map[string]string) {
+	for code, title := range titles {
+		r.Register(code, title)
+	}
+}
+
+// Lookup returns the problem type of an error code, given the status the
+// error is returned with
+func (r *ProblemRegistry) Lookup(code string, status int) ProblemType {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if std := standardError(code); std != nil {
+		return ProblemType{Code: code, Type: r.baseURI + problemSlug(code), Title: std.Message}
+	}
+	title, ok := r.titles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+	uri := r.baseURI
+	if r.service != "" {
+		uri += r.service + "/"
+	}
+	return ProblemType{Code: code, Type: uri + problemSlug(code), Title: title}
+}
+
+// Types returns the standard and registered problem types, ordered by URI
+func (r *ProblemRegistry) Types() []ProblemType {
+	r.mu.RLock()
+	codes := make([]string, 0, len(standardErrors)+len(r.titles))
+	for _, err := range standardErrors {
+		codes = append(codes, err.Code)
+	}
+	for code := range r.titles {
+		codes = append(codes, code)
+	}
+	r.mu.RUnlock()
+
+	types := make([]ProblemType, len(codes))
+	for i, code := range codes {
+		types[i] = r.Lookup(code, http.StatusInternalServerError)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Type < types[j].Type })
+	return types
+}
+
+func standardError(code string) *AppError {
+	for _, err := range standardErrors {
+		if err.Code == code {
+			return err
+		}
+	}
+	return nil
+}
+
+// problemSlug turns an error code into the last segment of its type URI,
+// e.g. INSUFFICIENT_FUNDS into insufficient-funds
+func problemSlug(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "_", "-"))
+}
+
+var (
+	configMu      sync.RWMutex
+	currentConfig Config
+	problems      = NewProblemRegistry(DefaultProblemBaseURI, "")
+)
+
+// Configure sets how this process writes error responses. Services call it
+// once at startup, before serving requests.
+func Configure(cfg Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	currentConfig = cfg
+	problems.configure(cfg.ProblemBaseURI, cfg.Service)
+}
+
+// Problems returns the problem type registry error responses are written with
+func Problems() *ProblemRegistry {
+	return problems
+}
+
+// NewProblem describes err as problem details of the request in c
+func NewProblem(c *gin.Context, err *AppError) Problem {
+	configMu.RLock()
+	cfg := currentConfig
+	configMu.RUnlock()
+
+	status := err.HTTPStatus
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	problemType := problems.Lookup(err.Code, status)
+	problem := Problem{
+		Type:    problemType.Type,
+		Title:   problemType.Title,
+		Status:  status,
+		Detail:  err.Message,
+		Code:    err.Code,
+		Details: err.Details,
+	}
+	if c.Request != nil {
+		problem.Instance = c.Request.URL.Path
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.HasTraceID() {
+			problem.TraceID = span.TraceID().String()
+		}
+	}
+	if problem.TraceID == "" {
+		// Without tracing the request ID, logged with the request, stands in
+		problem.TraceID = c.GetString("request_id")
+	}
+	if cfg.LegacyEnvelope {
+		problem.Error = &ErrorBody{Code: err.Code, Message: err.Message, Details: err.Details}
+	}
+	return problem
+}
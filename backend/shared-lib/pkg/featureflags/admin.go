@@ -0,0 +1,161 @@
+package featureflags
+
+import (
+	"errors"
+	"net/http"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes endpoints to manage flags
+type AdminHandler struct {
+	Store Store
+	Audit *middleware.AuditLogger
+}
+
+// NewAdminHandler creates an admin handler that audits every change
+func NewAdminHandler(store Store, serviceName string) *AdminHandler {
+	return &AdminHandler{
+		Store: store,
+		Audit: middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: serviceName}),
+	}
+}
+
+// RegisterRoutes mounts the flag admin endpoints on a group that is already
+// authenticated and restricted to administrators.
+func (h *AdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/feature-flags", h.List)
+	rg.GET("/feature-flags/:key", h.Get)
+	rg.PUT("/feature-flags/:key", h.Put)
+	rg.PATCH("/feature-flags/:key", h.Patch)
+	rg.DELETE("/feature-flags/:key", h.Delete)
+}
+
+// List returns all flags
+func (h *AdminHandler) List(c *gin.Context) {
+	flags, err := h.Store.List(c.Request.Context())
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, flags)
+}
+
+// Get returns a single flag
+func (h *AdminHandler) Get(c *gin.Context) {
+	flag, err := h.Store.Get(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondFlagError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+type PutFlagRequest struct {
+	Description       string   `json:"description"`
+	Enabled           bool     `json:"enabled"`
+	RolloutPercentage int      `json:"rollout_percentage"`
+	AllowedUsers      []string `json:"allowed_users"`
+}
+
+// Put creates or replaces a flag
+func (h *AdminHandler) Put(c *gin.Context) {
+	var req PutFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	flag := &Flag{
+		Key:               c.Param("key"),
+		Description:       req.Description,
+		Enabled:           req.Enabled,
+		RolloutPercentage: req.RolloutPercentage,
+		AllowedUsers:      req.AllowedUsers,
+	}
+	h.save(c, flag)
+}
+
+// PatchFlagRequest changes only the fields that are present, e.g. to toggle a
+// flag or move its rollout percentage
+type PatchFlagRequest struct {
+	Description       *string   `json:"description"`
+	Enabled           *bool     `json:"enabled"`
+	RolloutPercentage *int      `json:"rollout_percentage"`
+	AllowedUsers      *[]string `json:"allowed_users"`
+}
+
+// Patch updates an existing flag
+func (h *AdminHandler) Patch(c *gin.Context) {
+	var req PatchFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	flag, err := h.Store.Get(c.Request.Context(), c.Param("key"))
+	if err != nil {
+		respondFlagError(c, err)
+		return
+	}
+
+	if req.Description != nil {
+		flag.Description = *req.Description
+	}
+	if req.Enabled != nil {
+		flag.Enabled = *req.Enabled
+	}
+	if req.RolloutPercentage != nil {
+		flag.RolloutPercentage = *req.RolloutPercentage
+	}
+	if req.AllowedUsers != nil {
+		flag.AllowedUsers = *req.AllowedUsers
+	}
+	h.save(c, flag)
+}
+
+// Delete removes a flag; code checking it will see it as off
+func (h *AdminHandler) Delete(c *gin.Context) {
+	key := c.Param("key")
+	if err := h.Store.Delete(c.Request.Context(), key); err != nil {
+		respondFlagError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"action":   "feature_flag_deleted",
+		"flag_key": key,
+	})
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AdminHandler) save(c *gin.Context, flag *Flag) {
+	if err := flag.Validate(); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+
+	flag.UpdatedBy = middleware.GetUserID(c)
+	if err := h.Store.Save(c.Request.Context(), flag); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"action":             "feature_flag_updated",
+		"flag_key":           flag.Key,
+		"enabled":            flag.Enabled,
+		"rollout_percentage": flag.RolloutPercentage,
+	})
+	c.JSON(http.StatusOK, flag)
+}
+
+func respondFlagError(c *gin.Context, err error) {
+	if errors.Is(err, ErrFlagNotFound) {
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+		return
+	}
+	apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+}
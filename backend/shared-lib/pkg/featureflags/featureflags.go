@@ -0,0 +1,129 @@
+package featureflags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// ErrFlagNotFound is returned when a flag does not exist
+var ErrFlagNotFound = errors.New("feature flag not found")
+
+// Flag is a dark-launch switch for a feature.
+//
+// A flag is on for a user when it is Enabled and either the user is listed in
+// AllowedUsers or the user falls inside the RolloutPercentage cohort. Cohorts are
+// derived from a hash of the flag key and user ID, so a user keeps the same result
+// as the percentage grows and different flags get different cohorts.
+type Flag struct {
+	Key               string    `gorm:"primaryKey;type:varchar(100)" json:"key"`
+	Description       string    `gorm:"type:text" json:"description"`
+	Enabled           bool      `gorm:"default:false" json:"enabled"`
+	RolloutPercentage int       `gorm:"default:0" json:"rollout_percentage"`
+	AllowedUsers      []string  `gorm:"serializer:json" json:"allowed_users,omitempty"`
+	UpdatedBy         string    `gorm:"type:varchar(100)" json:"updated_by,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// TableName sets the table used for flags
+func (Flag) TableName() string {
+	return "feature_flags"
+}
+
+// Validate checks the rollout percentage is within range
+func (f *Flag) Validate() error {
+	if f.Key == "" {
+		return errors.New("flag key is required")
+	}
+	if f.RolloutPercentage < 0 || f.RolloutPercentage > 100 {
+		return fmt.Errorf("rollout percentage must be between 0 and 100, got %d", f.RolloutPercentage)
+	}
+	return nil
+}
+
+// EnabledFor evaluates the flag for a user. Anonymous users (empty userID) only
+// see the feature once it is rolled out to 100%.
+func (f *Flag) EnabledFor(userID string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if f.RolloutPercentage >= 100 {
+		return true
+	}
+	if userID == "" {
+		return false
+	}
+	if slices.Contains(f.AllowedUsers, userID) {
+		return true
+	}
+	return bucket(f.Key, userID) < f.RolloutPercentage
+}
+
+// bucket maps a user to a stable value in [0, 100) for the given flag
+func bucket(key, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key + ":" + userID))
+	return int(h.Sum32() % 100)
+}
+
+// Store persists feature flags
+type Store interface {
+	Get(ctx context.Context, key string) (*Flag, error)
+	List(ctx context.Context) ([]Flag, error)
+	Save(ctx context.Context, flag *Flag) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Flags holds the evaluated flags for a single user
+type Flags map[string]bool
+
+// Enabled reports whether a flag is on. Unknown flags are off.
+func (f Flags) Enabled(key string) bool {
+	return f[key]
+}
+
+// Client evaluates flags from a store
+type Client struct {
+	store Store
+}
+
+// NewClient creates a flag client backed by the given store
+func NewClient(store Store) *Client {
+	return &Client{store: store}
+}
+
+// Store returns the underlying store, e.g. for admin endpoints
+func (c *Client) Store() Store {
+	return c.store
+}
+
+// IsEnabled evaluates a single flag for a user. Lookup failures fail closed.
+func (c *Client) IsEnabled(ctx context.Context, key, userID string) bool {
+	flag, err := c.store.Get(ctx, key)
+	if err != nil {
+		if !errors.Is(err, ErrFlagNotFound) {
+			slog.Warn("Failed to load feature flag", "key", key, "error", err)
+		}
+		return false
+	}
+	return flag.EnabledFor(userID)
+}
+
+// EvaluateAll evaluates every flag for a user
+func (c *Client) EvaluateAll(ctx context.Context, userID string) (Flags, error) {
+	flags, err := c.store.List(ctx)
+	if err != nil {
+		return Flags{}, err
+	}
+
+	evaluated := make(Flags, len(flags))
+	for i := range flags {
+		evaluated[flags[i].Key] = flags[i].EnabledFor(userID)
+	}
+	return evaluated, nil
+}
@@ -0,0 +1,147 @@
+package featureflags
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestFlag_EnabledFor(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   Flag
+		userID string
+		want   bool
+	}{
+		{"disabled ignores rollout", Flag{Key: "f", Enabled: false, RolloutPercentage: 100}, "u1", false},
+		{"full rollout", Flag{Key: "f", Enabled: true, RolloutPercentage: 100}, "u1", true},
+		{"full rollout anonymous", Flag{Key: "f", Enabled: true, RolloutPercentage: 100}, "", true},
+		{"partial rollout anonymous", Flag{Key: "f", Enabled: true, RolloutPercentage: 99}, "", false},
+		{"zero rollout", Flag{Key: "f", Enabled: true}, "u1", false},
+		{"allowed user", Flag{Key: "f", Enabled: true, AllowedUsers: []string{"u1"}}, "u1", true},
+		{"allowed user but disabled", Flag{Key: "f", Enabled: false, AllowedUsers: []string{"u1"}}, "u1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.flag.EnabledFor(tt.userID))
+		})
+	}
+}
+
+func TestFlag_RolloutIsStableAndProportional(t *testing.T) {
+	flag := Flag{Key: "async_payments", Enabled: true, RolloutPercentage: 20}
+
+	enabled := 0
+	for i := 0; i < 10000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		on := flag.EnabledFor(userID)
+		assert.Equal(t, on, flag.EnabledFor(userID), "evaluation must be deterministic")
+		if on {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 2000, enabled, 300)
+
+	// Growing the rollout never turns the feature off for users already in it
+	wider := flag
+	wider.RolloutPercentage = 50
+	for i := 0; i < 1000; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		if flag.EnabledFor(userID) {
+			assert.True(t, wider.EnabledFor(userID))
+		}
+	}
+}
+
+func TestFlag_Validate(t *testing.T) {
+	assert.NoError(t, (&Flag{Key: "f", RolloutPercentage: 50}).Validate())
+	assert.Error(t, (&Flag{Key: "f", RolloutPercentage: 101}).Validate())
+	assert.Error(t, (&Flag{Key: "f", RolloutPercentage: -1}).Validate())
+	assert.Error(t, (&Flag{RolloutPercentage: 10}).Validate())
+}
+
+func TestClient_IsEnabled(t *testing.T) {
+	client := NewClient(NewMemoryStore(Flag{Key: "event_sourcing", Enabled: true, RolloutPercentage: 100}))
+
+	assert.True(t, client.IsEnabled(context.Background(), "event_sourcing", "u1"))
+	assert.False(t, client.IsEnabled(context.Background(), "unknown", "u1"))
+}
+
+func TestMiddleware_InjectsEvaluatedFlags(t *testing.T) {
+	store := NewMemoryStore(
+		Flag{Key: "on", Enabled: true, RolloutPercentage: 100},
+		Flag{Key: "beta", Enabled: true, AllowedUsers: []string{"u1"}},
+	)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(string(middleware.UserIDKey), c.GetHeader("X-User"))
+		c.Next()
+	})
+	r.Use(Middleware(NewClient(store)))
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"on": Enabled(c, "on"), "beta": Enabled(c, "beta"), "missing": Enabled(c, "missing")})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User", "u1")
+	r.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"on":true,"beta":true,"missing":false}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User", "u2")
+	r.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"on":true,"beta":false,"missing":false}`, w.Body.String())
+}
+
+func TestAdminHandler_ToggleAndRollout(t *testing.T) {
+	store := NewMemoryStore()
+	r := gin.New()
+	NewAdminHandler(store, "test-service").RegisterRoutes(r.Group("/admin"))
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPut, "/admin/feature-flags/async_payments", `{"description":"Kafka transfers","enabled":true,"rollout_percentage":10}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = do(http.MethodPatch, "/admin/feature-flags/async_payments", `{"rollout_percentage":50}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	flag, err := store.Get(context.Background(), "async_payments")
+	require.NoError(t, err)
+	assert.True(t, flag.Enabled)
+	assert.Equal(t, 50, flag.RolloutPercentage)
+	assert.Equal(t, "Kafka transfers", flag.Description)
+
+	w = do(http.MethodPatch, "/admin/feature-flags/async_payments", `{"enabled":false}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	flag, _ = store.Get(context.Background(), "async_payments")
+	assert.False(t, flag.Enabled)
+	assert.Equal(t, 50, flag.RolloutPercentage)
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPatch, "/admin/feature-flags/async_payments", `{"rollout_percentage":150}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPatch, "/admin/feature-flags/missing", `{"enabled":true}`).Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/admin/feature-flags/async_payments", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/feature-flags/async_payments", "").Code)
+}
@@ -0,0 +1,40 @@
+package featureflags
+
+import (
+	"log/slog"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// FlagsKey is the gin context key holding the evaluated flags
+const FlagsKey = "feature_flags"
+
+// Middleware evaluates all flags for the authenticated user and stores them in the
+// request context. Register it after JWTAuth so the user ID is available; anonymous
+// requests only see fully rolled out flags. If flags cannot be loaded, every flag is off.
+func Middleware(client *Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		flags, err := client.EvaluateAll(c.Request.Context(), middleware.GetUserID(c))
+		if err != nil {
+			slog.Warn("Failed to evaluate feature flags", "error", err)
+		}
+		c.Set(FlagsKey, flags)
+		c.Next()
+	}
+}
+
+// FromContext returns the flags evaluated by Middleware
+func FromContext(c *gin.Context) Flags {
+	if v, exists := c.Get(FlagsKey); exists {
+		if flags, ok := v.(Flags); ok {
+			return flags
+		}
+	}
+	return Flags{}
+}
+
+// Enabled reports whether a flag is on for the current request
+func Enabled(c *gin.Context, key string) bool {
+	return FromContext(c).Enabled(key)
+}
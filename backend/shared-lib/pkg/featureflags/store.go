@@ -0,0 +1,200 @@
+package featureflags
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps flags in the feature_flags table
+type PostgresStore struct {
+	DB *gorm.DB
+}
+
+// NewPostgresStore creates a Postgres-backed store. The service's migrations must create the feature_flags table.
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (*Flag, error) {
+	var flag Flag
+	if err := s.DB.WithContext(ctx).Where("key = ?", key).First(&flag).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, err
+	}
+	return &flag, nil
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]Flag, error) {
+	var flags []Flag
+	if err := s.DB.WithContext(ctx).Order("key").Find(&flags).Error; err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// Save inserts the flag or updates it if the key already exists
+func (s *PostgresStore) Save(ctx context.Context, flag *Flag) error {
+	return s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "enabled", "rollout_percentage", "allowed_users", "updated_by", "updated_at"}),
+	}).Create(flag).Error
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	result := s.DB.WithContext(ctx).Where("key = ?", key).Delete(&Flag{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrFlagNotFound
+	}
+	return nil
+}
+
+// Cache key and TTL for the Redis flag snapshot
+const (
+	KeyFlagSnapshot = "featureflags:all"
+	DefaultFlagTTL  = 30 * time.Second
+)
+
+// CachedStore serves reads from a Redis snapshot of all flags and falls back to
+// the underlying store. Writes go to the underlying store and drop the snapshot,
+// so every instance sees a change within one TTL at most.
+type CachedStore struct {
+	store Store
+	cache *cache.RedisClient
+	ttl   time.Duration
+}
+
+// NewCachedStore wraps a store with a Redis read-through cache
+func NewCachedStore(store Store, redisClient *cache.RedisClient, ttl time.Duration) *CachedStore {
+	if ttl <= 0 {
+		ttl = DefaultFlagTTL
+	}
+	return &CachedStore{store: store, cache: redisClient, ttl: ttl}
+}
+
+func (s *CachedStore) Get(ctx context.Context, key string) (*Flag, error) {
+	flags, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range flags {
+		if flags[i].Key == key {
+			return &flags[i], nil
+		}
+	}
+	return nil, ErrFlagNotFound
+}
+
+func (s *CachedStore) List(ctx context.Context) ([]Flag, error) {
+	var flags []Flag
+	if err := s.cache.GetJSON(ctx, KeyFlagSnapshot, &flags); err == nil {
+		return flags, nil
+	}
+
+	flags, err := s.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cache.SetJSON(ctx, KeyFlagSnapshot, flags, s.ttl); err != nil {
+		slog.Warn("Failed to cache feature flags", "error", err)
+	}
+	return flags, nil
+}
+
+func (s *CachedStore) Save(ctx context.Context, flag *Flag) error {
+	if err := s.store.Save(ctx, flag); err != nil {
+		return err
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+func (s *CachedStore) Delete(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+func (s *CachedStore) invalidate(ctx context.Context) {
+	if err := s.cache.Delete(ctx, KeyFlagSnapshot); err != nil {
+		slog.Warn("Failed to invalidate feature flag cache", "error", err)
+	}
+}
+
+// MemoryStore keeps flags in memory (tests and local development)
+type MemoryStore struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// NewMemoryStore creates an in-memory store seeded with the given flags
+func NewMemoryStore(flags ...Flag) *MemoryStore {
+	s := &MemoryStore{flags: make(map[string]Flag, len(flags))}
+	for _, f := range flags {
+		s.flags[f.Key] = f
+	}
+	return s
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (*Flag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flag, ok := s.flags[key]
+	if !ok {
+		return nil, ErrFlagNotFound
+	}
+	return &flag, nil
+}
+
+func (s *MemoryStore) List(_ context.Context) ([]Flag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flags := make([]Flag, 0, len(s.flags))
+	for _, f := range s.flags {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Key < flags[j].Key })
+	return flags, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, flag *Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := s.flags[flag.Key]; ok {
+		flag.CreatedAt = existing.CreatedAt
+	} else if flag.CreatedAt.IsZero() {
+		flag.CreatedAt = now
+	}
+	flag.UpdatedAt = now
+	s.flags[flag.Key] = *flag
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.flags[key]; !ok {
+		return ErrFlagNotFound
+	}
+	delete(s.flags, key)
+	return nil
+}
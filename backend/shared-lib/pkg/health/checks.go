@@ -0,0 +1,52 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/db"
+	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+)
+
+// Database pings the service's Postgres connection pool
+func Database(gdb *gorm.DB) Checker {
+	return func(ctx context.Context) error {
+		sqlDB, err := gdb.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// Redis pings Redis
+func Redis(client *cache.RedisClient) Checker {
+	return client.Ping
+}
+
+// Kafka checks that at least one of the brokers accepts a connection
+func Kafka(brokers []string) Checker {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, broker := range brokers {
+			conn, err := (&kafka.Dialer{}).DialContext(ctx, "tcp", broker)
+			if err == nil {
+				return conn.Close()
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+		}
+		if len(errs) == 0 {
+			return errors.New("no Kafka brokers configured")
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// Migrations checks that every embedded migration has been applied and none
+// failed part-way, see db.Migrator.Check
+func Migrations(m *db.Migrator) Checker {
+	return m.Check
+}
@@ -0,0 +1,171 @@
+// Package health serves a service's liveness and readiness probes.
+//
+// Liveness only says the process is serving HTTP, so an orchestrator restarts
+// it when it hangs. Readiness runs the registered dependency checks, so traffic
+// is held back while a dependency the service cannot work without is down.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTimeout bounds each check, so one hanging dependency cannot stall the probe
+const DefaultTimeout = 2 * time.Second
+
+// Probe paths. /health is kept for load balancers and Docker health checks
+// configured before the split, and reports readiness.
+const (
+	Path      = "/health"
+	LivePath  = "/health/live"
+	ReadyPath = "/health/ready"
+)
+
+// Statuses of a check and of the whole report
+const (
+	StatusUp       = "UP"
+	StatusDown     = "DOWN"
+	StatusDegraded = "DEGRADED" // only optional checks are down
+)
+
+// Checker reports whether a dependency is usable; nil means it is
+type Checker func(ctx context.Context) error
+
+// CheckResult is the outcome of one check
+type CheckResult struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the readiness payload
+type Report struct {
+	Status  string                 `json:"status"`
+	Service string                 `json:"service"`
+	Checks  map[string]CheckResult `json:"checks,omitempty"`
+	// Info holds details that do not affect the status, such as the Kafka topic report
+	Info map[string]interface{} `json:"info,omitempty"`
+}
+
+type check struct {
+	name     string
+	checker  Checker
+	critical bool
+}
+
+// Handler runs a service's checks. Register them before serving.
+type Handler struct {
+	Service string
+	Timeout time.Duration
+
+	checks []check
+	info   map[string]func() interface{}
+}
+
+// New creates a handler for a service with no checks
+func New(serviceName string) *Handler {
+	return &Handler{Service: serviceName, Timeout: DefaultTimeout, info: map[string]func() interface{}{}}
+}
+
+// Register adds a check the service cannot work without: while it fails the
+// service is not ready
+func (h *Handler) Register(name string, checker Checker) {
+	h.checks = append(h.checks, check{name: name, checker: checker, critical: true})
+}
+
+// RegisterOptional adds a check for a dependency the service degrades without,
+// such as a cache. A failure is reported but the service stays ready.
+func (h *Handler) RegisterOptional(name string, checker Checker) {
+	h.checks = append(h.checks, check{name: name, checker: checker})
+}
+
+// Info adds details to the readiness payload, read on every request
+func (h *Handler) Info(name string, fn func() interface{}) {
+	h.info[name] = fn
+}
+
+// RegisterRoutes mounts the probes
+func (h *Handler) RegisterRoutes(r gin.IRoutes) {
+	r.GET(Path, h.Ready)
+	r.GET(LivePath, h.Live)
+	r.GET(ReadyPath, h.Ready)
+}
+
+// Live answers while the process serves HTTP; it runs no checks
+func (h *Handler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: StatusUp, Service: h.Service})
+}
+
+// Ready runs every check and answers 503 while a critical one fails
+func (h *Handler) Ready(c *gin.Context) {
+	report := h.Check(c.Request.Context())
+	status := http.StatusOK
+	if report.Status == StatusDown {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// Check runs the checks concurrently, each bounded by the handler's timeout
+func (h *Handler) Check(ctx context.Context) Report {
+	results := make(map[string]CheckResult, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, chk := range h.checks {
+		wg.Add(1)
+		go func(chk check) {
+			defer wg.Done()
+			result := h.run(ctx, chk)
+			mu.Lock()
+			results[chk.name] = result
+			mu.Unlock()
+		}(chk)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Service: h.Service, Checks: results}
+	for _, result := range results {
+		switch {
+		case result.Status == StatusUp:
+		case result.Critical:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+	if len(h.info) > 0 {
+		report.Info = make(map[string]interface{}, len(h.info))
+		for name, fn := range h.info {
+			report.Info[name] = fn()
+		}
+	}
+	return report
+}
+
+func (h *Handler) run(ctx context.Context, chk check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- chk.checker(ctx) }()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Checkers that ignore the context must not hold up the probe
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusUp, Critical: chk.critical, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func up(context.Context) error { return nil }
+
+func down(context.Context) error { return errors.New("connection refused") }
+
+func probe(t *testing.T, h *Handler, path string) (int, Report) {
+	t.Helper()
+	r := gin.New()
+	h.RegisterRoutes(r)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	var report Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestReady_AllChecksUp(t *testing.T) {
+	h := New("test-service")
+	h.Register("database", up)
+	h.RegisterOptional("redis", up)
+	h.Info("kafka_topics", func() interface{} { return "OK" })
+
+	code, report := probe(t, h, ReadyPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, "test-service", report.Service)
+	assert.Equal(t, StatusUp, report.Checks["database"].Status)
+	assert.True(t, report.Checks["database"].Critical)
+	assert.False(t, report.Checks["redis"].Critical)
+	assert.Equal(t, "OK", report.Info["kafka_topics"])
+}
+
+func TestReady_CriticalCheckDown(t *testing.T) {
+	h := New("test-service")
+	h.Register("database", down)
+	h.RegisterOptional("redis", up)
+
+	for _, path := range []string{ReadyPath, Path} {
+		code, report := probe(t, h, path)
+		assert.Equal(t, http.StatusServiceUnavailable, code, path)
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, "connection refused", report.Checks["database"].Error)
+	}
+}
+
+func TestReady_OptionalCheckDownDegrades(t *testing.T) {
+	h := New("test-service")
+	h.Register("database", up)
+	h.RegisterOptional("redis", down)
+
+	code, report := probe(t, h, ReadyPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusDegraded, report.Status)
+	assert.Equal(t, StatusDown, report.Checks["redis"].Status)
+}
+
+func TestReady_TimesOutHangingCheck(t *testing.T) {
+	h := New("test-service")
+	h.Timeout = 20 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	h.Register("kafka", func(context.Context) error {
+		<-release // ignores the context
+		return nil
+	})
+
+	start := time.Now()
+	report := h.Check(context.Background())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["kafka"].Error)
+}
+
+func TestLive_RunsNoChecks(t *testing.T) {
+	h := New("test-service")
+	h.Register("database", func(context.Context) error {
+		t.Error("liveness must not run checks")
+		return nil
+	})
+
+	code, report := probe(t, h, LivePath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+func TestKafka_NoBrokersReachable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Error(t, Kafka(nil)(ctx))
+	// Port 0 is never listening
+	assert.Error(t, Kafka([]string{"127.0.0.1:0"})(ctx))
+}
@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// AdminHandler exposes endpoints to inspect jobs and retry failed ones
+type AdminHandler struct {
+	Store Store
+	Audit *middleware.AuditLogger
+}
+
+// NewAdminHandler creates an admin handler that audits every retry
+func NewAdminHandler(store Store, serviceName string) *AdminHandler {
+	return &AdminHandler{
+		Store: store,
+		Audit: middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: serviceName}),
+	}
+}
+
+// RegisterRoutes mounts the job admin endpoints on a group that is already
+// authenticated and restricted to administrators.
+func (h *AdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/jobs", h.List)
+	rg.GET("/jobs/:id", h.Get)
+	rg.POST("/jobs/:id/retry", h.Retry)
+}
+
+// List returns jobs, newest first, filtered by ?kind= and ?status=
+func (h *AdminHandler) List(c *gin.Context) {
+	limit := defaultListLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxListLimit {
+			apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("limit must be between 1 and 500"))
+			return
+		}
+		limit = n
+	}
+	status := Status(c.Query("status"))
+	switch status {
+	case "", StatusPending, StatusRunning, StatusSucceeded, StatusFailed:
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage("unknown job status"))
+		return
+	}
+
+	jobs, err := h.Store.List(c.Request.Context(), Filter{Kind: c.Query("kind"), Status: status, Limit: limit})
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// Get returns a single job
+func (h *AdminHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondJobError(c, ErrJobNotFound)
+		return
+	}
+	job, err := h.Store.Get(c.Request.Context(), id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// Retry queues a failed job to run again now with a fresh set of attempts
+func (h *AdminHandler) Retry(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondJobError(c, ErrJobNotFound)
+		return
+	}
+	job, err := h.Store.Retry(c.Request.Context(), id, time.Now())
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityWarning, c, map[string]interface{}{
+		"action":   "job_retried",
+		"job_id":   job.ID.String(),
+		"job_kind": job.Kind,
+	})
+	c.JSON(http.StatusOK, job)
+}
+
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		apperrors.RespondWithError(c, apperrors.ErrNotFound.WithMessage(err.Error()))
+	case errors.Is(err, ErrNotRetryable):
+		apperrors.RespondWithError(c, apperrors.NewError("JOB_NOT_RETRYABLE", err.Error(), http.StatusConflict))
+	default:
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+	}
+}
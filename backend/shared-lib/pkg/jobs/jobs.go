@@ -0,0 +1,96 @@
+// Package jobs runs background work from a Postgres-backed queue.
+//
+// A Runner claims due jobs with FOR UPDATE SKIP LOCKED, so any number of
+// replicas can share one queue, and runs them on a pool of workers. Failed
+// jobs are retried with exponential backoff and marked FAILED after their last
+// attempt, where the admin API can inspect and retry them. Recurring work is
+// registered with a Schedule; every replica enqueues each run under the same
+// unique key, so a run happens once however many replicas are up.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Status is the state of a job
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusRunning   Status = "RUNNING"
+	StatusSucceeded Status = "SUCCEEDED"
+	// StatusFailed jobs used all of their attempts; they stay until retried
+	// from the admin API or purged
+	StatusFailed Status = "FAILED"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrNotRetryable = errors.New("only failed jobs can be retried")
+)
+
+// Job is a unit of background work. The service's migrations must create the jobs table.
+type Job struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primaryKey" json:"id"`
+	Kind        string          `gorm:"type:varchar(100);not null" json:"kind"`
+	Payload     json.RawMessage `gorm:"type:jsonb" json:"payload,omitempty"`
+	Status      Status          `gorm:"type:varchar(20);not null" json:"status"`
+	Attempts    int             `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int             `gorm:"not null" json:"max_attempts"`
+	RunAt       time.Time       `gorm:"not null" json:"run_at"`
+	// UniqueKey makes enqueueing idempotent, e.g. one job per scheduled run
+	UniqueKey *string `gorm:"type:varchar(255);uniqueIndex" json:"unique_key,omitempty"`
+	// LockedBy and LockedUntil are the lease of the worker running the job;
+	// a job whose lease ran out is assumed abandoned and claimed again
+	LockedBy    string     `gorm:"type:varchar(255)" json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	LastError   string     `gorm:"type:text" json:"last_error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName sets the table used for jobs
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// Decode unmarshals the job's payload into v
+func (j *Job) Decode(v interface{}) error {
+	if len(j.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Filter narrows a job listing; zero fields match everything
+type Filter struct {
+	Kind   string
+	Status Status
+	Limit  int
+}
+
+// Store persists the job queue
+type Store interface {
+	// Enqueue stores a new job. It returns false, storing nothing, if a job
+	// with the same UniqueKey exists.
+	Enqueue(ctx context.Context, job *Job) (bool, error)
+	// Claim leases the oldest due job of one of the kinds to worker, counting
+	// an attempt. It returns nil when no job is due.
+	Claim(ctx context.Context, kinds []string, worker string, now time.Time, lease time.Duration) (*Job, error)
+	// Finish records the outcome of a claimed job and releases its lease. It
+	// does nothing if the lease was lost to another worker.
+	Finish(ctx context.Context, job *Job) error
+	Get(ctx context.Context, id uuid.UUID) (*Job, error)
+	// List returns jobs matching the filter, newest first
+	List(ctx context.Context, filter Filter) ([]Job, error)
+	// Retry queues a failed job again with a fresh set of attempts
+	Retry(ctx context.Context, id uuid.UUID, now time.Time) (*Job, error)
+	// Purge deletes jobs that finished with the status before the given time
+	Purge(ctx context.Context, status Status, before time.Time) (int64, error)
+}
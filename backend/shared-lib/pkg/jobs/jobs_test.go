@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRunner returns a runner on a memory store with a controllable clock
+// and a fixed one-minute backoff
+func newTestRunner(clock *time.Time) (*Runner, *MemoryStore) {
+	store := NewMemoryStore()
+	r := NewRunner(store, "test-service", Config{
+		MaxAttempts: 3,
+		Backoff:     func(int) time.Duration { return time.Minute },
+	})
+	r.now = func() time.Time { return *clock }
+	return r, store
+}
+
+func TestRunner_RetriesWithBackoffThenFails(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r, store := newTestRunner(&clock)
+	ctx := context.Background()
+
+	calls := 0
+	r.Handle("flaky", func(ctx context.Context, job *Job) error {
+		calls++
+		var payload struct{ N int }
+		require.NoError(t, job.Decode(&payload))
+		assert.Equal(t, 7, payload.N)
+		return errors.New("downstream unavailable")
+	})
+	job, err := r.Enqueue(ctx, "flaky", map[string]int{"N": 7})
+	require.NoError(t, err)
+
+	ran, err := r.RunNext(ctx)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	stored, _ := store.Get(ctx, job.ID)
+	assert.Equal(t, StatusPending, stored.Status)
+	assert.Equal(t, clock.Add(time.Minute), stored.RunAt)
+	assert.Equal(t, "downstream unavailable", stored.LastError)
+
+	// Not due again until the backoff has passed
+	ran, _ = r.RunNext(ctx)
+	assert.False(t, ran)
+
+	for i := 0; i < 2; i++ {
+		clock = clock.Add(time.Minute)
+		ran, err = r.RunNext(ctx)
+		require.NoError(t, err)
+		assert.True(t, ran)
+	}
+	stored, _ = store.Get(ctx, job.ID)
+	assert.Equal(t, StatusFailed, stored.Status)
+	assert.Equal(t, 3, stored.Attempts)
+	assert.Equal(t, 3, calls)
+
+	// Failed jobs stay put until retried
+	clock = clock.Add(time.Hour)
+	ran, _ = r.RunNext(ctx)
+	assert.False(t, ran)
+}
+
+func TestRunner_RecoversPanicsAndReclaimsAbandonedJobs(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r, store := newTestRunner(&clock)
+	ctx := context.Background()
+
+	r.Handle("panics", func(context.Context, *Job) error { panic("boom") }, MaxAttempts(1))
+	job, err := r.Enqueue(ctx, "panics", nil)
+	require.NoError(t, err)
+	_, err = r.RunNext(ctx)
+	require.NoError(t, err)
+	stored, _ := store.Get(ctx, job.ID)
+	assert.Equal(t, StatusFailed, stored.Status)
+	assert.Contains(t, stored.LastError, "boom")
+
+	// A job claimed by a worker that died is claimed again once the lease runs out
+	r.Handle("ok", func(context.Context, *Job) error { return nil })
+	job, err = r.Enqueue(ctx, "ok", nil)
+	require.NoError(t, err)
+	abandoned, err := store.Claim(ctx, []string{"ok"}, "dead-worker", clock, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, abandoned)
+
+	ran, _ := r.RunNext(ctx)
+	assert.False(t, ran)
+	clock = clock.Add(2 * time.Minute)
+	ran, err = r.RunNext(ctx)
+	require.NoError(t, err)
+	assert.True(t, ran)
+	stored, _ = store.Get(ctx, job.ID)
+	assert.Equal(t, StatusSucceeded, stored.Status)
+	assert.Equal(t, 2, stored.Attempts)
+
+	// The dead worker finishing late does not overwrite the outcome
+	abandoned.Status = StatusFailed
+	require.NoError(t, store.Finish(ctx, abandoned))
+	stored, _ = store.Get(ctx, job.ID)
+	assert.Equal(t, StatusSucceeded, stored.Status)
+}
+
+func TestRunner_SchedulesEachRunOnce(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// Two replicas sharing a store enqueue the same run once
+	runs := 0
+	var replicas []*Runner
+	for i := 0; i < 2; i++ {
+		r := NewRunner(store, "test-service", Config{})
+		r.now = func() time.Time { return clock }
+		r.Schedule("tick", Every(time.Minute), func(context.Context, *Job) error {
+			runs++
+			return nil
+		})
+		replicas = append(replicas, r)
+	}
+
+	for _, r := range replicas {
+		r.EnqueueDue(ctx)
+	}
+	jobs, _ := store.List(ctx, Filter{Kind: "tick"})
+	assert.Empty(t, jobs)
+
+	clock = clock.Add(time.Minute)
+	for _, r := range replicas {
+		r.EnqueueDue(ctx)
+	}
+	jobs, _ = store.List(ctx, Filter{Kind: "tick"})
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "tick@2026-03-01T12:01:00Z", *jobs[0].UniqueKey)
+
+	for _, r := range replicas {
+		_, err := r.RunNext(ctx)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, runs)
+}
+
+func TestRunner_RunStopsOnCancel(t *testing.T) {
+	store := NewMemoryStore()
+	r := NewRunner(store, "test-service", Config{Workers: 2, PollInterval: 10 * time.Millisecond})
+	done := make(chan struct{})
+	r.Handle("signal", func(context.Context, *Job) error {
+		close(done)
+		return nil
+	})
+	_, err := r.Enqueue(context.Background(), "signal", nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not run")
+	}
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("runner did not stop")
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Second, time.Minute)
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 9: time.Minute} {
+		d := backoff(attempt)
+		assert.GreaterOrEqual(t, d, want, "attempt %d", attempt)
+		assert.LessOrEqual(t, d, want+want/5, "attempt %d", attempt)
+	}
+}
+
+func TestAdminHandler_ListAndRetry(t *testing.T) {
+	clock := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r, store := newTestRunner(&clock)
+	ctx := context.Background()
+	r.Handle("fails", func(context.Context, *Job) error { return errors.New("nope") }, MaxAttempts(1))
+	failed, err := r.Enqueue(ctx, "fails", nil)
+	require.NoError(t, err)
+	_, err = r.RunNext(ctx)
+	require.NoError(t, err)
+
+	router := gin.New()
+	NewAdminHandler(store, "test-service").RegisterRoutes(router.Group("/admin"))
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/admin/jobs?status=FAILED")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), failed.ID.String())
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/admin/jobs?status=LOST").Code)
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/admin/jobs/"+failed.ID.String()+"/retry").Code)
+	stored, _ := store.Get(ctx, failed.ID)
+	assert.Equal(t, StatusPending, stored.Status)
+	assert.Equal(t, 0, stored.Attempts)
+
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/admin/jobs/"+failed.ID.String()+"/retry").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodGet, "/admin/jobs/not-a-uuid").Code)
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	jobsEnqueuedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_enqueued_total",
+			Help: "Total number of background jobs enqueued",
+		},
+		[]string{"service", "kind"},
+	)
+
+	jobsProcessedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "jobs_processed_total",
+			Help: "Total number of background job attempts",
+		},
+		[]string{"service", "kind", "outcome"}, // succeeded, retried, failed
+	)
+
+	jobDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "job_duration_seconds",
+			Help:    "Background job attempt duration in seconds",
+			Buckets: []float64{.01, .05, .1, .5, 1, 5, 15, 60, 300},
+		},
+		[]string{"service", "kind"},
+	)
+
+	jobsInFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "jobs_in_flight",
+			Help: "Number of background jobs currently running",
+		},
+		[]string{"service"},
+	)
+)
@@ -0,0 +1,381 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Handler does the work of a job. Returning an error schedules a retry until
+// the job's attempts run out. Handlers must be idempotent: a job whose worker
+// died mid-run is run again once its lease expires.
+type Handler func(ctx context.Context, job *Job) error
+
+// Config tunes a Runner
+type Config struct {
+	// Workers is how many jobs run concurrently on this replica
+	Workers int
+	// PollInterval is how often idle workers look for due jobs and the
+	// scheduler checks for due runs
+	PollInterval time.Duration
+	// LeaseDuration bounds how long a job may run; after it another worker
+	// may claim the job again
+	LeaseDuration time.Duration
+	// MaxAttempts is the default number of attempts before a job is FAILED
+	MaxAttempts int
+	// Backoff returns the delay before retrying after the given failed attempt
+	Backoff func(attempt int) time.Duration
+	// Retention is how long succeeded jobs are kept before they are purged
+	Retention time.Duration
+	// FailedRetention is how long failed jobs are kept for inspection
+	FailedRetention time.Duration
+}
+
+// DefaultConfig returns 4 workers polling every second, 5 attempts with
+// exponential backoff from 10 seconds up to an hour, a day of succeeded jobs
+// and a week of failed ones
+func DefaultConfig() Config {
+	return Config{
+		Workers:         4,
+		PollInterval:    time.Second,
+		LeaseDuration:   5 * time.Minute,
+		MaxAttempts:     5,
+		Backoff:         ExponentialBackoff(10*time.Second, time.Hour),
+		Retention:       24 * time.Hour,
+		FailedRetention: 7 * 24 * time.Hour,
+	}
+}
+
+// ExponentialBackoff doubles the delay after each attempt, starting at base and
+// capped at max, with up to 20% jitter so retries of many jobs spread out
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d + time.Duration(rand.Int64N(int64(d)/5+1))
+	}
+}
+
+// Option configures a registered job kind
+type Option func(*registration)
+
+// MaxAttempts overrides the runner's default attempts for a kind
+func MaxAttempts(n int) Option {
+	return func(r *registration) { r.maxAttempts = n }
+}
+
+// Timeout bounds a single attempt; it defaults to the lease duration
+func Timeout(d time.Duration) Option {
+	return func(r *registration) { r.timeout = d }
+}
+
+type registration struct {
+	handler     Handler
+	maxAttempts int
+	timeout     time.Duration
+}
+
+type scheduled struct {
+	kind     string
+	schedule Schedule
+	next     time.Time
+}
+
+// EnqueueOption configures a single enqueued job
+type EnqueueOption func(*Job)
+
+// RunAt delays a job until the given time
+func RunAt(t time.Time) EnqueueOption {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// UniqueKey skips the enqueue if a job with the same key already exists
+func UniqueKey(key string) EnqueueOption {
+	return func(j *Job) { j.UniqueKey = &key }
+}
+
+// Runner runs registered job kinds from a Store on a pool of workers
+type Runner struct {
+	store   Store
+	service string
+	cfg     Config
+	worker  string
+	now     func() time.Time
+
+	mu        sync.Mutex
+	handlers  map[string]registration
+	schedules []*scheduled
+}
+
+// NewRunner creates a runner for a service. Zero Config fields take their
+// DefaultConfig values.
+func NewRunner(store Store, service string, cfg Config) *Runner {
+	def := DefaultConfig()
+	if cfg.Workers <= 0 {
+		cfg.Workers = def.Workers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = def.PollInterval
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = def.LeaseDuration
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.Backoff == nil {
+		cfg.Backoff = def.Backoff
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	if cfg.FailedRetention <= 0 {
+		cfg.FailedRetention = def.FailedRetention
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "local"
+	}
+	return &Runner{
+		store:    store,
+		service:  service,
+		cfg:      cfg,
+		worker:   host + ":" + strconv.Itoa(os.Getpid()),
+		now:      time.Now,
+		handlers: make(map[string]registration),
+	}
+}
+
+// Handle registers the handler for a job kind
+func (r *Runner) Handle(kind string, handler Handler, opts ...Option) {
+	reg := registration{handler: handler, maxAttempts: r.cfg.MaxAttempts, timeout: r.cfg.LeaseDuration}
+	for _, opt := range opts {
+		opt(&reg)
+	}
+	if reg.timeout > r.cfg.LeaseDuration {
+		reg.timeout = r.cfg.LeaseDuration
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[kind] = reg
+}
+
+// Schedule registers a recurring job kind. Each run is enqueued once across
+// all replicas; runs missed while no replica was up are skipped.
+func (r *Runner) Schedule(kind string, schedule Schedule, handler Handler, opts ...Option) {
+	r.Handle(kind, handler, opts...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schedules = append(r.schedules, &scheduled{kind: kind, schedule: schedule, next: schedule.Next(r.now())})
+}
+
+// Enqueue adds a job of a registered kind, marshalling payload to JSON. It
+// returns nil without error if a UniqueKey option matched an existing job.
+func (r *Runner) Enqueue(ctx context.Context, kind string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	r.mu.Lock()
+	reg, ok := r.handlers[kind]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for job kind %q", kind)
+	}
+
+	job := &Job{
+		ID:          uuid.New(),
+		Kind:        kind,
+		Status:      StatusPending,
+		MaxAttempts: reg.maxAttempts,
+		RunAt:       r.now(),
+	}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+		}
+		job.Payload = raw
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+
+	created, err := r.store.Enqueue(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, nil
+	}
+	jobsEnqueuedTotal.WithLabelValues(r.service, kind).Inc()
+	return job, nil
+}
+
+// Run starts the scheduler and workers and blocks until the context is
+// cancelled and running jobs have returned
+func (r *Runner) Run(ctx context.Context) {
+	slog.Info("Job runner started", "service", r.service, "workers", r.cfg.Workers, "kinds", r.kinds())
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+	for {
+		r.EnqueueDue(ctx)
+		if now := r.now(); now.Sub(lastPurge) >= time.Hour {
+			r.purge(ctx, now)
+			lastPurge = now
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purge deletes jobs that finished before their retention period
+func (r *Runner) purge(ctx context.Context, now time.Time) {
+	for status, retention := range map[Status]time.Duration{StatusSucceeded: r.cfg.Retention, StatusFailed: r.cfg.FailedRetention} {
+		if n, err := r.store.Purge(ctx, status, now.Add(-retention)); err != nil {
+			slog.Error("Failed to purge finished jobs", "service", r.service, "status", status, "error", err)
+		} else if n > 0 {
+			slog.Info("Purged finished jobs", "service", r.service, "status", status, "count", n)
+		}
+	}
+}
+
+// EnqueueDue enqueues the scheduled runs that are due. The unique key is the
+// kind and run time, so replicas enqueueing the same run store one job.
+func (r *Runner) EnqueueDue(ctx context.Context) {
+	now := r.now()
+	r.mu.Lock()
+	var due []scheduled
+	for _, s := range r.schedules {
+		if s.next.IsZero() || s.next.After(now) {
+			continue
+		}
+		due = append(due, *s)
+		s.next = s.schedule.Next(now)
+	}
+	r.mu.Unlock()
+
+	for _, s := range due {
+		key := s.kind + "@" + s.next.UTC().Format(time.RFC3339)
+		if _, err := r.Enqueue(ctx, s.kind, nil, RunAt(s.next), UniqueKey(key)); err != nil {
+			slog.Error("Failed to enqueue scheduled job", "service", r.service, "kind", s.kind, "error", err)
+		}
+	}
+}
+
+// work claims and runs jobs until the context is cancelled
+func (r *Runner) work(ctx context.Context) {
+	for {
+		ran, err := r.RunNext(ctx)
+		if err != nil {
+			slog.Error("Failed to claim job", "service", r.service, "error", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.PollInterval):
+		}
+	}
+}
+
+// RunNext claims one due job and runs it, reporting whether there was one
+func (r *Runner) RunNext(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+	job, err := r.store.Claim(ctx, r.kinds(), r.worker, r.now(), r.cfg.LeaseDuration)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	reg := r.handlers[job.Kind]
+	r.mu.Unlock()
+
+	jobsInFlight.WithLabelValues(r.service).Inc()
+	start := time.Now()
+	runErr := r.invoke(ctx, reg, job)
+	jobDuration.WithLabelValues(r.service, job.Kind).Observe(time.Since(start).Seconds())
+	jobsInFlight.WithLabelValues(r.service).Dec()
+
+	now := r.now()
+	var outcome string
+	switch {
+	case runErr == nil:
+		outcome = "succeeded"
+		job.Status = StatusSucceeded
+		job.LastError = ""
+		job.CompletedAt = &now
+	case job.Attempts >= job.MaxAttempts:
+		outcome = "failed"
+		job.Status = StatusFailed
+		job.LastError = runErr.Error()
+		job.CompletedAt = &now
+		slog.Error("Job failed", "service", r.service, "kind", job.Kind, "job_id", job.ID, "attempts", job.Attempts, "error", runErr)
+	default:
+		outcome = "retried"
+		job.Status = StatusPending
+		job.LastError = runErr.Error()
+		job.RunAt = now.Add(r.cfg.Backoff(job.Attempts))
+		slog.Warn("Job attempt failed, will retry", "service", r.service, "kind", job.Kind, "job_id", job.ID, "attempt", job.Attempts, "retry_at", job.RunAt, "error", runErr)
+	}
+	jobsProcessedTotal.WithLabelValues(r.service, job.Kind, outcome).Inc()
+
+	// Record the outcome even if shutdown cancelled the context mid-job
+	return true, r.store.Finish(context.WithoutCancel(ctx), job)
+}
+
+// invoke runs the handler with the attempt timeout, turning a panic into an error
+func (r *Runner) invoke(ctx context.Context, reg registration, job *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, reg.timeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return reg.handler(ctx, job)
+}
+
+func (r *Runner) kinds() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kinds := make([]string, 0, len(r.handlers))
+	for kind := range r.handlers {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
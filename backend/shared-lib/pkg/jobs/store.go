@@ -0,0 +1,272 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps the queue in the jobs table
+type PostgresStore struct {
+	DB *gorm.DB
+}
+
+// NewPostgresStore creates a Postgres-backed store. The service's migrations must create the jobs table.
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{DB: db}
+}
+
+func (s *PostgresStore) Enqueue(ctx context.Context, job *Job) (bool, error) {
+	res := s.DB.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// Claim locks the job row with SKIP LOCKED, so concurrent workers on any
+// replica each get a different job
+func (s *PostgresStore) Claim(ctx context.Context, kinds []string, worker string, now time.Time, lease time.Duration) (*Job, error) {
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	var claimed []Job
+	err := s.DB.WithContext(ctx).Raw(`
+		UPDATE jobs SET status = ?, attempts = attempts + 1, locked_by = ?, locked_until = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind IN ? AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		StatusRunning, worker, now.Add(lease), now,
+		kinds, StatusPending, now, StatusRunning, now,
+	).Scan(&claimed).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+	return &claimed[0], nil
+}
+
+func (s *PostgresStore) Finish(ctx context.Context, job *Job) error {
+	return s.DB.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, StatusRunning, job.LockedBy).
+		Updates(map[string]interface{}{
+			"status":       job.Status,
+			"run_at":       job.RunAt,
+			"last_error":   job.LastError,
+			"completed_at": job.CompletedAt,
+			"locked_by":    "",
+			"locked_until": nil,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+func (s *PostgresStore) Get(ctx context.Context, id uuid.UUID) (*Job, error) {
+	var job Job
+	if err := s.DB.WithContext(ctx).Where("id = ?", id).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Job, error) {
+	q := s.DB.WithContext(ctx).Order("created_at DESC")
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(filter.Limit)
+	}
+	var jobs []Job
+	if err := q.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *PostgresStore) Retry(ctx context.Context, id uuid.UUID, now time.Time) (*Job, error) {
+	res := s.DB.WithContext(ctx).Model(&Job{}).
+		Where("id = ? AND status = ?", id, StatusFailed).
+		Updates(map[string]interface{}{"status": StatusPending, "attempts": 0, "run_at": now, "completed_at": nil, "updated_at": now})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	job, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrNotRetryable
+	}
+	return job, nil
+}
+
+func (s *PostgresStore) Purge(ctx context.Context, status Status, before time.Time) (int64, error) {
+	res := s.DB.WithContext(ctx).Where("status = ? AND completed_at < ?", status, before).Delete(&Job{})
+	return res.RowsAffected, res.Error
+}
+
+// MemoryStore keeps the queue in memory (tests and local development)
+type MemoryStore struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*Job
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[uuid.UUID]*Job)}
+}
+
+func (s *MemoryStore) Enqueue(_ context.Context, job *Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.UniqueKey != nil {
+		for _, existing := range s.jobs {
+			if existing.UniqueKey != nil && *existing.UniqueKey == *job.UniqueKey {
+				return false, nil
+			}
+		}
+	}
+	now := time.Now()
+	job.CreatedAt, job.UpdatedAt = now, now
+	stored := *job
+	s.jobs[job.ID] = &stored
+	return true, nil
+}
+
+func (s *MemoryStore) Claim(_ context.Context, kinds []string, worker string, now time.Time, lease time.Duration) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due *Job
+	for _, job := range s.jobs {
+		if !containsKind(kinds, job.Kind) {
+			continue
+		}
+		ready := job.Status == StatusPending && !job.RunAt.After(now)
+		abandoned := job.Status == StatusRunning && job.LockedUntil != nil && job.LockedUntil.Before(now)
+		if (ready || abandoned) && (due == nil || job.RunAt.Before(due.RunAt)) {
+			due = job
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+	until := now.Add(lease)
+	due.Status = StatusRunning
+	due.Attempts++
+	due.LockedBy = worker
+	due.LockedUntil = &until
+	due.UpdatedAt = now
+	claimed := *due
+	return &claimed, nil
+}
+
+func (s *MemoryStore) Finish(_ context.Context, job *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.jobs[job.ID]
+	if !ok || stored.Status != StatusRunning || stored.LockedBy != job.LockedBy {
+		return nil
+	}
+	stored.Status = job.Status
+	stored.RunAt = job.RunAt
+	stored.LastError = job.LastError
+	stored.CompletedAt = job.CompletedAt
+	stored.LockedBy = ""
+	stored.LockedUntil = nil
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id uuid.UUID) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+func (s *MemoryStore) List(_ context.Context, filter Filter) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		if (filter.Kind == "" || job.Kind == filter.Kind) && (filter.Status == "" || job.Status == filter.Status) {
+			jobs = append(jobs, *job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	if filter.Limit > 0 && len(jobs) > filter.Limit {
+		jobs = jobs[:filter.Limit]
+	}
+	return jobs, nil
+}
+
+func (s *MemoryStore) Retry(_ context.Context, id uuid.UUID, now time.Time) (*Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	if job.Status != StatusFailed {
+		return nil, ErrNotRetryable
+	}
+	job.Status = StatusPending
+	job.Attempts = 0
+	job.RunAt = now
+	job.CompletedAt = nil
+	job.UpdatedAt = now
+	retried := *job
+	return &retried, nil
+}
+
+func (s *MemoryStore) Purge(_ context.Context, status Status, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, job := range s.jobs {
+		if job.Status == status && job.CompletedAt != nil && job.CompletedAt.Before(before) {
+			delete(s.jobs, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func containsKind(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
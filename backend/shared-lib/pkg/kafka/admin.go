@@ -0,0 +1,318 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Defaults for topics created at startup, overridden by KAFKA_TOPIC_PARTITIONS,
+// KAFKA_TOPIC_REPLICATION_FACTOR and KAFKA_TOPIC_RETENTION
+const (
+	DefaultTopicPartitions        = 3
+	DefaultTopicReplicationFactor = 1
+	DefaultTopicRetention         = 7 * 24 * time.Hour
+)
+
+// DefaultTopicRetryInterval is how often Start retries when the brokers cannot be reached
+const DefaultTopicRetryInterval = 30 * time.Second
+
+// Topic states reported by EnsureTopics
+const (
+	TopicStatusCreated = "CREATED" // created by this run
+	TopicStatusOK      = "OK"      // existed with the configured settings
+	TopicStatusDrifted = "DRIFTED" // existed with different settings; left as it is
+	TopicStatusFailed  = "FAILED"  // could not be created or inspected
+)
+
+// TopicSpec is how a topic should be configured
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	// Retention is how long messages are kept; zero leaves the broker default
+	Retention time.Duration
+}
+
+// TopicSpecs builds a spec for each topic name from one set of settings
+func TopicSpecs(settings TopicSpec, names ...string) []TopicSpec {
+	specs := make([]TopicSpec, 0, len(names))
+	for _, name := range names {
+		spec := settings
+		spec.Name = name
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// TopicSettingsFromEnv reads the partitions, replication factor and retention
+// topics are created with from KAFKA_TOPIC_PARTITIONS,
+// KAFKA_TOPIC_REPLICATION_FACTOR and KAFKA_TOPIC_RETENTION (a duration such as
+// 168h), falling back to the defaults for unset variables
+func TopicSettingsFromEnv() (TopicSpec, error) {
+	settings := TopicSpec{
+		Partitions:        DefaultTopicPartitions,
+		ReplicationFactor: DefaultTopicReplicationFactor,
+		Retention:         DefaultTopicRetention,
+	}
+	if value := os.Getenv("KAFKA_TOPIC_PARTITIONS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return TopicSpec{}, fmt.Errorf("invalid KAFKA_TOPIC_PARTITIONS %q: must be a positive integer", value)
+		}
+		settings.Partitions = n
+	}
+	if value := os.Getenv("KAFKA_TOPIC_REPLICATION_FACTOR"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return TopicSpec{}, fmt.Errorf("invalid KAFKA_TOPIC_REPLICATION_FACTOR %q: must be a positive integer", value)
+		}
+		settings.ReplicationFactor = n
+	}
+	if value := os.Getenv("KAFKA_TOPIC_RETENTION"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return TopicSpec{}, fmt.Errorf("invalid KAFKA_TOPIC_RETENTION %q: must be a duration such as 168h", value)
+		}
+		settings.Retention = d
+	}
+	return settings, nil
+}
+
+// TopicStatus is the outcome of ensuring one topic
+type TopicStatus struct {
+	Name              string   `json:"name"`
+	Status            string   `json:"status"`
+	Partitions        int      `json:"partitions,omitempty"`
+	ReplicationFactor int      `json:"replication_factor,omitempty"`
+	RetentionMs       int64    `json:"retention_ms,omitempty"`
+	Drift             []string `json:"drift,omitempty"`
+	Error             string   `json:"error,omitempty"`
+}
+
+// TopicReport is the outcome of the last EnsureTopics run
+type TopicReport struct {
+	Status    string        `json:"status"` // OK, DEGRADED, or PENDING until a run has reached the brokers
+	Topics    []TopicStatus `json:"topics,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt *time.Time    `json:"checked_at,omitempty"`
+}
+
+// TopicAdmin creates the topics a service needs and records how they compare
+// with their configured settings, for the service's health payload
+type TopicAdmin struct {
+	client *kafka.Client
+
+	mu     sync.RWMutex
+	report TopicReport
+}
+
+// NewTopicAdmin creates a topic admin for the given brokers
+func NewTopicAdmin(brokers []string) *TopicAdmin {
+	return &TopicAdmin{
+		client: &kafka.Client{
+			Addr:    kafka.TCP(brokers...),
+			Timeout: 10 * time.Second,
+		},
+		report: TopicReport{Status: "PENDING"},
+	}
+}
+
+// Start ensures the topics exist, retrying every DefaultTopicRetryInterval
+// until the brokers have been reached or the context is cancelled. Drift is
+// reported, not retried: existing topics are never changed.
+func (a *TopicAdmin) Start(ctx context.Context, specs []TopicSpec) {
+	ticker := time.NewTicker(DefaultTopicRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		_, err := a.EnsureTopics(ctx, specs)
+		if err == nil {
+			return
+		}
+		slog.Warn("Failed to ensure Kafka topics, will retry", "error", err, "retry_in", DefaultTopicRetryInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EnsureTopics creates the topics that do not exist yet and compares the rest
+// with their specs. It can run on every replica: a topic created concurrently
+// by another one counts as existing. An error means the brokers could not be
+// reached; problems with single topics are in the report.
+func (a *TopicAdmin) EnsureTopics(ctx context.Context, specs []TopicSpec) (TopicReport, error) {
+	report, err := a.ensure(ctx, specs)
+	now := time.Now().UTC()
+	report.CheckedAt = &now
+	if err != nil {
+		report.Error = err.Error()
+		a.mu.Lock()
+		// Keep the last successful run's topics while the brokers are unreachable
+		a.report.Error = report.Error
+		a.report.CheckedAt = report.CheckedAt
+		a.mu.Unlock()
+		return report, err
+	}
+
+	a.mu.Lock()
+	a.report = report
+	a.mu.Unlock()
+	for _, t := range report.Topics {
+		switch t.Status {
+		case TopicStatusCreated:
+			slog.Info("Kafka topic created", "topic", t.Name, "partitions", t.Partitions, "replication_factor", t.ReplicationFactor)
+		case TopicStatusDrifted:
+			slog.Warn("Kafka topic configuration drift", "topic", t.Name, "drift", t.Drift)
+		case TopicStatusFailed:
+			slog.Error("Failed to ensure Kafka topic", "topic", t.Name, "error", t.Error)
+		}
+	}
+	return report, nil
+}
+
+// Report returns the outcome of the last EnsureTopics run
+func (a *TopicAdmin) Report() TopicReport {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	report := a.report
+	report.Topics = append([]TopicStatus(nil), a.report.Topics...)
+	return report
+}
+
+func (a *TopicAdmin) ensure(ctx context.Context, specs []TopicSpec) (TopicReport, error) {
+	names := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	meta, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return TopicReport{Status: "PENDING"}, fmt.Errorf("metadata: %w", err)
+	}
+	existing := make(map[string]kafka.Topic, len(meta.Topics))
+	for _, t := range meta.Topics {
+		if t.Error == nil {
+			existing[t.Name] = t
+		}
+	}
+
+	statuses := make([]TopicStatus, len(specs))
+	var missing []kafka.TopicConfig
+	var describe []kafka.DescribeConfigRequestResource
+	for i, spec := range specs {
+		statuses[i] = TopicStatus{Name: spec.Name}
+		if t, ok := existing[spec.Name]; ok {
+			statuses[i].Partitions = len(t.Partitions)
+			if len(t.Partitions) > 0 {
+				statuses[i].ReplicationFactor = len(t.Partitions[0].Replicas)
+			}
+			describe = append(describe, kafka.DescribeConfigRequestResource{
+				ResourceType: kafka.ResourceTypeTopic,
+				ResourceName: spec.Name,
+				ConfigNames:  []string{"retention.ms"},
+			})
+			continue
+		}
+		config := kafka.TopicConfig{
+			Topic:             spec.Name,
+			NumPartitions:     spec.Partitions,
+			ReplicationFactor: spec.ReplicationFactor,
+		}
+		if spec.Retention > 0 {
+			config.ConfigEntries = []kafka.ConfigEntry{{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(spec.Retention.Milliseconds(), 10)}}
+		}
+		missing = append(missing, config)
+	}
+
+	createErrors := map[string]error{}
+	if len(missing) > 0 {
+		resp, err := a.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{Topics: missing})
+		if err != nil {
+			return TopicReport{Status: "PENDING"}, fmt.Errorf("create topics: %w", err)
+		}
+		createErrors = resp.Errors
+	}
+
+	retention := map[string]int64{}
+	if len(describe) > 0 {
+		resp, err := a.client.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: describe})
+		if err != nil {
+			return TopicReport{Status: "PENDING"}, fmt.Errorf("describe configs: %w", err)
+		}
+		for _, r := range resp.Resources {
+			for _, entry := range r.ConfigEntries {
+				if entry.ConfigName != "retention.ms" {
+					continue
+				}
+				if ms, err := strconv.ParseInt(entry.ConfigValue, 10, 64); err == nil {
+					retention[r.ResourceName] = ms
+				}
+			}
+		}
+	}
+
+	for i, spec := range specs {
+		status := &statuses[i]
+		if _, ok := existing[spec.Name]; !ok {
+			err := createErrors[spec.Name]
+			switch {
+			case err == nil:
+				status.Status = TopicStatusCreated
+				status.Partitions = spec.Partitions
+				status.ReplicationFactor = spec.ReplicationFactor
+				status.RetentionMs = spec.Retention.Milliseconds()
+			case errors.Is(err, kafka.TopicAlreadyExists):
+				// Another replica created it first; it is checked on the next run
+				status.Status = TopicStatusOK
+			default:
+				status.Status = TopicStatusFailed
+				status.Error = err.Error()
+			}
+			continue
+		}
+		status.RetentionMs = retention[spec.Name]
+		status.Drift = topicDrift(spec, status.Partitions, status.ReplicationFactor, status.RetentionMs)
+		if len(status.Drift) > 0 {
+			status.Status = TopicStatusDrifted
+		} else {
+			status.Status = TopicStatusOK
+		}
+	}
+	return TopicReport{Status: reportStatus(statuses), Topics: statuses}, nil
+}
+
+// topicDrift lists how an existing topic differs from its spec. Retention is
+// only compared when the spec sets one.
+func topicDrift(spec TopicSpec, partitions, replicationFactor int, retentionMs int64) []string {
+	var drift []string
+	if partitions != spec.Partitions {
+		drift = append(drift, fmt.Sprintf("partitions is %d, configured %d", partitions, spec.Partitions))
+	}
+	if replicationFactor != spec.ReplicationFactor {
+		drift = append(drift, fmt.Sprintf("replication factor is %d, configured %d", replicationFactor, spec.ReplicationFactor))
+	}
+	if spec.Retention > 0 && retentionMs != spec.Retention.Milliseconds() {
+		drift = append(drift, fmt.Sprintf("retention.ms is %d, configured %d", retentionMs, spec.Retention.Milliseconds()))
+	}
+	return drift
+}
+
+// reportStatus is OK when every topic exists as configured, DEGRADED otherwise
+func reportStatus(statuses []TopicStatus) string {
+	for _, s := range statuses {
+		if s.Status == TopicStatusDrifted || s.Status == TopicStatusFailed {
+			return "DEGRADED"
+		}
+	}
+	return "OK"
+}
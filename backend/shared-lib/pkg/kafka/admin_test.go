@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopicSettingsFromEnv(t *testing.T) {
+	settings, err := TopicSettingsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, TopicSpec{Partitions: DefaultTopicPartitions, ReplicationFactor: DefaultTopicReplicationFactor, Retention: DefaultTopicRetention}, settings)
+
+	t.Setenv("KAFKA_TOPIC_PARTITIONS", "6")
+	t.Setenv("KAFKA_TOPIC_REPLICATION_FACTOR", "3")
+	t.Setenv("KAFKA_TOPIC_RETENTION", "72h")
+	settings, err = TopicSettingsFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, TopicSpec{Partitions: 6, ReplicationFactor: 3, Retention: 72 * time.Hour}, settings)
+
+	specs := TopicSpecs(settings, TopicPaymentCreated, TopicPaymentCompleted)
+	require.Len(t, specs, 2)
+	assert.Equal(t, TopicPaymentCompleted, specs[1].Name)
+	assert.Equal(t, 6, specs[1].Partitions)
+
+	t.Setenv("KAFKA_TOPIC_PARTITIONS", "0")
+	_, err = TopicSettingsFromEnv()
+	assert.ErrorContains(t, err, "KAFKA_TOPIC_PARTITIONS")
+
+	t.Setenv("KAFKA_TOPIC_PARTITIONS", "6")
+	t.Setenv("KAFKA_TOPIC_RETENTION", "a week")
+	_, err = TopicSettingsFromEnv()
+	assert.ErrorContains(t, err, "KAFKA_TOPIC_RETENTION")
+}
+
+func TestTopicDrift(t *testing.T) {
+	spec := TopicSpec{Name: TopicJournalPosted, Partitions: 3, ReplicationFactor: 3, Retention: 24 * time.Hour}
+
+	assert.Empty(t, topicDrift(spec, 3, 3, (24 * time.Hour).Milliseconds()))
+	assert.Equal(t, []string{
+		"partitions is 1, configured 3",
+		"replication factor is 1, configured 3",
+		"retention.ms is 604800000, configured 86400000",
+	}, topicDrift(spec, 1, 1, (7 * 24 * time.Hour).Milliseconds()))
+
+	// Without a configured retention the broker's is accepted
+	spec.Retention = 0
+	assert.Empty(t, topicDrift(spec, 3, 3, 1))
+}
+
+func TestReportStatus(t *testing.T) {
+	assert.Equal(t, "OK", reportStatus([]TopicStatus{{Status: TopicStatusOK}, {Status: TopicStatusCreated}}))
+	assert.Equal(t, "DEGRADED", reportStatus([]TopicStatus{{Status: TopicStatusOK}, {Status: TopicStatusDrifted}}))
+	assert.Equal(t, "DEGRADED", reportStatus([]TopicStatus{{Status: TopicStatusFailed}}))
+}
+
+func TestTopicAdmin_ReportPendingUntilRun(t *testing.T) {
+	admin := NewTopicAdmin([]string{"localhost:9092"})
+	report := admin.Report()
+	assert.Equal(t, "PENDING", report.Status)
+	assert.Nil(t, report.CheckedAt)
+}
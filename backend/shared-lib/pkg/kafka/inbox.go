@@ -0,0 +1,170 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultInboxRetention is how long processed messages are remembered. It must
+// comfortably exceed the longest time a message can be redelivered.
+const DefaultInboxRetention = 7 * 24 * time.Hour
+
+// InboxMessage records that a consumer group processed the message at a topic,
+// partition and offset. The service's migrations must create the kafka_inbox table.
+type InboxMessage struct {
+	ConsumerGroup string    `gorm:"type:varchar(100);primaryKey" json:"consumer_group"`
+	Topic         string    `gorm:"type:varchar(255);primaryKey" json:"topic"`
+	Partition     int       `gorm:"primaryKey;autoIncrement:false" json:"partition"`
+	Offset        int64     `gorm:"primaryKey;autoIncrement:false" json:"offset"`
+	MessageKey    string    `gorm:"type:varchar(255)" json:"message_key"`
+	ProcessedAt   time.Time `gorm:"not null;index" json:"processed_at"`
+}
+
+func (InboxMessage) TableName() string {
+	return "kafka_inbox"
+}
+
+// Inbox makes message handling effectively exactly-once. The inbox record and
+// the handler's writes commit in one database transaction, so a message
+// redelivered after a rebalance or a crash before the offset commit is skipped
+// instead of being applied twice.
+type Inbox struct {
+	db    *gorm.DB
+	group string
+	now   func() time.Time
+}
+
+// NewInbox creates an inbox for a consumer group
+func NewInbox(db *gorm.DB, group string) *Inbox {
+	return &Inbox{db: db, group: group, now: time.Now}
+}
+
+// Process runs handler in a transaction that also records the delivery. The
+// handler must make all of its database writes through tx. It returns false
+// without calling handler when the delivery was already processed. If handler
+// returns an error nothing is recorded, so the message is handled again when
+// it is redelivered.
+func (i *Inbox) Process(ctx context.Context, d Delivery, handler func(tx *gorm.DB) error) (bool, error) {
+	processed := false
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// A concurrent insert of the same delivery blocks on the primary key
+		// until the other transaction finishes, then inserts nothing
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&InboxMessage{
+			ConsumerGroup: i.group,
+			Topic:         d.Topic,
+			Partition:     d.Partition,
+			Offset:        d.Offset,
+			MessageKey:    d.Key,
+			ProcessedAt:   i.now(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+		if err := handler(tx); err != nil {
+			return err
+		}
+		processed = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if !processed {
+		messagesDuplicateTotal.WithLabelValues(d.Topic, i.group).Inc()
+	}
+	return processed, nil
+}
+
+// ErrInboxConflict is returned by ProcessBatch when another transaction
+// recorded one of the deliveries meanwhile; nothing was recorded, and the
+// deliveries can be processed one by one instead
+var ErrInboxConflict = errors.New("inbox: delivery recorded concurrently")
+
+// ProcessBatch is Process for several deliveries in one transaction. The
+// handler is called with the deliveries not processed before, in order, and
+// all of them are recorded with a single insert. It returns the deliveries
+// the handler was called with; if it returns an error nothing is recorded.
+func (i *Inbox) ProcessBatch(ctx context.Context, ds []Delivery, handler func(tx *gorm.DB, fresh []Delivery) error) ([]Delivery, error) {
+	var fresh []Delivery
+	err := i.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		fresh = nil
+		seen, err := i.processed(tx, ds)
+		if err != nil {
+			return err
+		}
+		records := make([]InboxMessage, 0, len(ds))
+		for _, d := range ds {
+			key := inboxKey{d.Topic, d.Partition, d.Offset}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			fresh = append(fresh, d)
+			records = append(records, InboxMessage{
+				ConsumerGroup: i.group,
+				Topic:         d.Topic,
+				Partition:     d.Partition,
+				Offset:        d.Offset,
+				MessageKey:    d.Key,
+				ProcessedAt:   i.now(),
+			})
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(records)) {
+			return ErrInboxConflict
+		}
+		return handler(tx, fresh)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if duplicates := len(ds) - len(fresh); duplicates > 0 {
+		messagesDuplicateTotal.WithLabelValues(ds[0].Topic, i.group).Add(float64(duplicates))
+	}
+	return fresh, nil
+}
+
+type inboxKey struct {
+	topic     string
+	partition int
+	offset    int64
+}
+
+// processed returns which of the deliveries the group already processed
+func (i *Inbox) processed(tx *gorm.DB, ds []Delivery) (map[inboxKey]bool, error) {
+	seen := make(map[inboxKey]bool, len(ds))
+	if len(ds) == 0 {
+		return seen, nil
+	}
+	positions := make([][]interface{}, len(ds))
+	for j, d := range ds {
+		positions[j] = []interface{}{d.Topic, d.Partition, d.Offset}
+	}
+	var existing []InboxMessage
+	if err := tx.Where("consumer_group = ? AND (topic, partition, \"offset\") IN ?", i.group, positions).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range existing {
+		seen[inboxKey{m.Topic, m.Partition, m.Offset}] = true
+	}
+	return seen, nil
+}
+
+// Purge deletes the group's inbox records processed before the given time
+func (i *Inbox) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res := i.db.WithContext(ctx).Where("consumer_group = ? AND processed_at < ?", i.group, before).Delete(&InboxMessage{})
+	return res.RowsAffected, res.Error
+}
@@ -3,44 +3,240 @@ package kafka
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"net"
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Producer wraps kafka-go writer for producing messages
+// Producer wraps kafka-go writer for producing messages. Produce and
+// ProduceBatch wait for each write; Publish queues messages and sends them in
+// batches; PublishAtomic writes several messages in one Kafka transaction.
 type Producer struct {
 	writer *kafka.Writer
+	// async sends the messages queued by Publish
+	async *kafka.Writer
+	// txn runs the transactions of PublishAtomic, or is nil without a
+	// transactional ID
+	txn *transactions
 }
 
 // Consumer wraps kafka-go reader for consuming messages
 type Consumer struct {
-	reader *kafka.Reader
+	reader  messageReader
+	groupID string
+}
+
+// messageReader is the part of kafka.Reader a Consumer uses
+type messageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	Close() error
 }
 
 // PaymentEvent represents a payment event message
 type PaymentEvent struct {
-	PaymentID     string `json:"payment_id"`
+	PaymentID string `json:"payment_id"`
+	// UserID is the customer who made the payment, when one did
+	UserID        string `json:"user_id,omitempty"`
 	FromAccountID string `json:"from_account_id"`
 	ToAccountID   string `json:"to_account_id"`
 	Amount        string `json:"amount"`
 	Currency      string `json:"currency"`
 	Description   string `json:"description"`
-	Status        string `json:"status"`
+	// Fee is charged to the payer and posted to FeeAccountID in the same entry
+	Fee          string `json:"fee,omitempty"`
+	FeeAccountID string `json:"fee_account_id,omitempty"`
+	Status       string `json:"status"`
+	// LedgerEntryID is the journal entry that posted the payment, set on
+	// payment.completed events from the ledger
+	LedgerEntryID string `json:"ledger_entry_id,omitempty"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// MandateEvent represents a direct debit mandate lifecycle event
+type MandateEvent struct {
+	MandateID       string `json:"mandate_id"`
+	MerchantID      string `json:"merchant_id"`
+	UserID          string `json:"user_id"`
+	DebtorAccountID string `json:"debtor_account_id"`
+	Status          string `json:"status"`
+	Timestamp       string `json:"timestamp"`
+}
+
+// AccountEvent represents a ledger account lifecycle event
+type AccountEvent struct {
+	AccountID      string `json:"account_id"`
+	UserID         string `json:"user_id"`
+	AccountNumber  string `json:"account_number"`
+	Type           string `json:"type"`
+	CurrencyCode   string `json:"currency_code"`
+	BatchReference string `json:"batch_reference,omitempty"` // Set for bulk-provisioned accounts
+	Timestamp      string `json:"timestamp"`
+}
+
+// TransactionCategorizedEvent is emitted when a posting is categorized by the
+// rules or recategorized by its owner, so clients can keep budgets current
+type TransactionCategorizedEvent struct {
+	TransactionID string `json:"transaction_id"`
+	PostingID     string `json:"posting_id"`
+	AccountID     string `json:"account_id"`
+	UserID        string `json:"user_id"`
+	Category      string `json:"category"`
+	Source        string `json:"source"` // RULE or USER
+	Merchant      string `json:"merchant,omitempty"`
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+	Direction     int    `json:"direction"` // 1 = money in, -1 = money out
 	Timestamp     string `json:"timestamp"`
 }
 
-// NewProducer creates a new Kafka producer
+// JournalEvent is emitted when a journal entry is posted or changes status, so
+// consumers can follow account balances without polling the ledger
+type JournalEvent struct {
+	EntryID     string                `json:"entry_id"`
+	Status      string                `json:"status"`
+	Description string                `json:"description,omitempty"`
+	Postings    []JournalPostingEvent `json:"postings"`
+	Timestamp   string                `json:"timestamp"`
+}
+
+// JournalPostingEvent is one posting of a JournalEvent
+type JournalPostingEvent struct {
+	AccountID string `json:"account_id"`
+	Amount    string `json:"amount"`
+	Direction int    `json:"direction"` // 1 = debit, -1 = credit
+}
+
+// CardEvent represents a card lifecycle event. Replacement events link the old
+// and new card so consumers can follow the chain.
+type CardEvent struct {
+	CardID           string `json:"card_id"`
+	UserID           string `json:"user_id"`
+	AccountID        string `json:"account_id"`
+	MaskedCardNumber string `json:"masked_card_number"`
+	Status           string `json:"status"`
+	Reason           string `json:"reason,omitempty"` // LOST, STOLEN, DAMAGED or EXPIRY for replacements
+	ReplacesCardID   string `json:"replaces_card_id,omitempty"`
+	ReplacedByCardID string `json:"replaced_by_card_id,omitempty"`
+	Timestamp        string `json:"timestamp"`
+}
+
+// PaymentRequestEvent represents a payment request lifecycle event
+type PaymentRequestEvent struct {
+	RequestID       string `json:"request_id"`
+	Reference       string `json:"reference"`
+	RequesterUserID string `json:"requester_user_id"`
+	PayerUserID     string `json:"payer_user_id,omitempty"`
+	PaymentID       string `json:"payment_id,omitempty"`
+	Amount          string `json:"amount"`
+	Currency        string `json:"currency"`
+	Status          string `json:"status"`
+	Timestamp       string `json:"timestamp"`
+}
+
+// NotificationEvent asks the notification pipeline to deliver a templated
+// message to a customer. Data holds the template variables.
+type NotificationEvent struct {
+	UserID    string            `json:"user_id"`
+	Channel   string            `json:"channel"` // EMAIL or SMS
+	Recipient string            `json:"recipient"`
+	Template  string            `json:"template"`
+	Data      map[string]string `json:"data,omitempty"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// ReferralEvent is published when a referred user makes their first transfer
+// and the referrer has earned their reward. ReferralID identifies the referral,
+// so consumers granting rewards can ignore redeliveries.
+type ReferralEvent struct {
+	ReferralID     string `json:"referral_id"`
+	ReferrerUserID string `json:"referrer_user_id"`
+	RefereeUserID  string `json:"referee_user_id"`
+	PaymentID      string `json:"payment_id"`
+	Timestamp      string `json:"timestamp"`
+}
+
+// UserUpdatedEvent is published when a user changes their profile, so KYC can
+// re-check changed identity details and notifications use the new contact
+// details. Fields lists the profile fields that changed and Profile holds
+// their new values.
+type UserUpdatedEvent struct {
+	UserID    string            `json:"user_id"`
+	Fields    []string          `json:"fields"`
+	Profile   map[string]string `json:"profile"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// UserPreferencesUpdatedEvent is published when a user changes their
+// preferences and carries all of them, so notification and reporting keep a
+// copy without calling back. Fields lists the preferences that changed.
+// MarketingOptIns and Notifications are keyed by channel: EMAIL, SMS or PUSH.
+type UserPreferencesUpdatedEvent struct {
+	UserID          string          `json:"user_id"`
+	Fields          []string        `json:"fields"`
+	Locale          string          `json:"locale"`
+	DisplayCurrency string          `json:"display_currency"`
+	MarketingOptIns map[string]bool `json:"marketing_opt_ins"`
+	Notifications   map[string]bool `json:"notifications"`
+	Timestamp       string          `json:"timestamp"`
+}
+
+// NewProducer creates a new Kafka producer with the default configuration
 func NewProducer(brokers []string) *Producer {
-	writer := &kafka.Writer{
-		Addr:         kafka.TCP(brokers...),
-		Balancer:     &kafka.LeastBytes{},
-		BatchTimeout: 10 * time.Millisecond,
-		RequiredAcks: kafka.RequireOne,
+	return NewProducerWithConfig(brokers, DefaultProducerConfig())
+}
+
+// NewProducerWithConfig creates a Kafka producer that batches and, with a
+// transactional ID, transacts as config says
+func NewProducerWithConfig(brokers []string, config ProducerConfig) *Producer {
+	return newProducer(kafka.TCP(brokers...), nil, config)
+}
+
+// newProducer creates a producer for the cluster at addr. A nil transport
+// uses kafka-go's default one.
+func newProducer(addr net.Addr, transport kafka.RoundTripper, config ProducerConfig) *Producer {
+	defaults := DefaultProducerConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BatchBytes <= 0 {
+		config.BatchBytes = defaults.BatchBytes
+	}
+	if config.Linger <= 0 {
+		config.Linger = defaults.Linger
+	}
+	if config.TransactionTimeout <= 0 {
+		config.TransactionTimeout = defaults.TransactionTimeout
+	}
+
+	p := &Producer{
+		writer: &kafka.Writer{
+			Addr:         addr,
+			Balancer:     &kafka.LeastBytes{},
+			BatchTimeout: 10 * time.Millisecond,
+			RequiredAcks: kafka.RequireOne,
+			Transport:    transport,
+		},
+		async: &kafka.Writer{
+			Addr:         addr,
+			Balancer:     &kafka.LeastBytes{},
+			BatchSize:    config.BatchSize,
+			BatchBytes:   config.BatchBytes,
+			BatchTimeout: config.Linger,
+			RequiredAcks: kafka.RequireOne,
+			Async:        true,
+			Completion:   completePublished,
+			Transport:    transport,
+		},
+	}
+	if config.TransactionalID != "" {
+		p.txn = newTransactions(&kafka.Client{Addr: addr, Transport: transport}, config.TransactionalID, config.TransactionTimeout)
 	}
-	slog.Info("Kafka producer initialized", "brokers", brokers)
-	return &Producer{writer: writer}
+	slog.Info("Kafka producer initialized", "brokers", addr.String(), "transactional", p.txn != nil)
+	return p
 }
 
 // Produce sends a message to the specified topic
@@ -55,24 +251,73 @@ func (p *Producer) Produce(ctx context.Context, topic string, key string, value
 		Key:   []byte(key),
 		Value: data,
 	}
+	injectTrace(ctx, &msg)
 
 	err = p.writer.WriteMessages(ctx, msg)
 	if err != nil {
+		messagesProducedTotal.WithLabelValues(topic, "failed").Inc()
 		slog.Error("Failed to produce message", "topic", topic, "error", err)
 		return err
 	}
+	messagesProducedTotal.WithLabelValues(topic, "success").Inc()
 
 	slog.Info("Message produced", "topic", topic, "key", key)
 	return nil
 }
 
-// Close closes the producer
+// Message is a keyed value for ProduceBatch
+type Message struct {
+	Key   string
+	Value interface{}
+}
+
+// ProduceBatch sends several messages to a topic in a single write
+func (p *Producer) ProduceBatch(ctx context.Context, topic string, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		data, err := json.Marshal(m.Value)
+		if err != nil {
+			return err
+		}
+		msg := kafka.Message{Topic: topic, Key: []byte(m.Key), Value: data}
+		injectTrace(ctx, &msg)
+		msgs = append(msgs, msg)
+	}
+
+	if err := p.writer.WriteMessages(ctx, msgs...); err != nil {
+		messagesProducedTotal.WithLabelValues(topic, "failed").Add(float64(len(msgs)))
+		slog.Error("Failed to produce message batch", "topic", topic, "count", len(msgs), "error", err)
+		return err
+	}
+	messagesProducedTotal.WithLabelValues(topic, "success").Add(float64(len(msgs)))
+
+	slog.Info("Message batch produced", "topic", topic, "count", len(msgs))
+	return nil
+}
+
+// Close closes the producer. Messages queued by Publish are sent first, and
+// their delivery callbacks called.
 func (p *Producer) Close() error {
-	return p.writer.Close()
+	return errors.Join(p.async.Close(), p.writer.Close())
 }
 
 // NewConsumer creates a new Kafka consumer
 func NewConsumer(brokers []string, groupID, topic string) *Consumer {
+	return newConsumer(brokers, groupID, topic, kafka.FirstOffset)
+}
+
+// NewLatestConsumer creates a consumer whose group, when it has no committed
+// offsets yet, starts at the end of the topic instead of replaying it. It suits
+// per-replica groups that only care about live events.
+func NewLatestConsumer(brokers []string, groupID, topic string) *Consumer {
+	return newConsumer(brokers, groupID, topic, kafka.LastOffset)
+}
+
+func newConsumer(brokers []string, groupID, topic string, startOffset int64) *Consumer {
 	reader := kafka.NewReader(kafka.ReaderConfig{
 		Brokers:        brokers,
 		GroupID:        groupID,
@@ -80,14 +325,46 @@ func NewConsumer(brokers []string, groupID, topic string) *Consumer {
 		MinBytes:       1,
 		MaxBytes:       10e6,
 		CommitInterval: time.Second,
-		StartOffset:    kafka.FirstOffset,
+		StartOffset:    startOffset,
+		// Messages of aborted PublishAtomic transactions are never delivered,
+		// and those of open ones only once they commit
+		IsolationLevel: kafka.ReadCommitted,
 	})
 	slog.Info("Kafka consumer initialized", "brokers", brokers, "group", groupID, "topic", topic)
-	return &Consumer{reader: reader}
+	return &Consumer{reader: reader, groupID: groupID}
+}
+
+// Delivery is a consumed message with its position in the topic
+type Delivery struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       string
+	Value     []byte
+
+	ctx context.Context
+}
+
+// Context returns the context of handling the message: its span, which
+// continues the producer's trace, and the baggage the producer sent. Spans
+// started from it get the business identifiers in that baggage as attributes.
+func (d Delivery) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
 }
 
 // Consume reads messages and calls the handler for each
 func (c *Consumer) Consume(ctx context.Context, handler func(key string, value []byte) error) error {
+	return c.ConsumeDeliveries(ctx, func(d Delivery) error {
+		return handler(d.Key, d.Value)
+	})
+}
+
+// ConsumeDeliveries is Consume for handlers that need the message's partition
+// and offset, such as those recording it in an Inbox
+func (c *Consumer) ConsumeDeliveries(ctx context.Context, handler func(d Delivery) error) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -102,10 +379,74 @@ func (c *Consumer) Consume(ctx context.Context, handler func(key string, value [
 				continue
 			}
 
-			if err := handler(string(msg.Key), msg.Value); err != nil {
+			spanCtx, span := startConsumeSpan(ctx, msg, c.groupID)
+			d := Delivery{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset, Key: string(msg.Key), Value: msg.Value, ctx: spanCtx}
+			err = handler(d)
+			endConsumeSpan(span, err)
+			if err != nil {
+				messagesConsumedTotal.WithLabelValues(msg.Topic, c.groupID, "failed").Inc()
 				slog.Error("Failed to handle message", "key", string(msg.Key), "error", err)
 				// Continue processing other messages
+				continue
+			}
+			messagesConsumedTotal.WithLabelValues(msg.Topic, c.groupID, "success").Inc()
+		}
+	}
+}
+
+// ConsumeDeliveryBatches is ConsumeDeliveries for handlers that process
+// messages in micro-batches: it collects up to maxSize messages, waiting at
+// most maxWait after the first one for the rest, and calls the handler with
+// them in the order they were read. A quiet topic still has each message
+// handled within maxWait.
+func (c *Consumer) ConsumeDeliveryBatches(ctx context.Context, maxSize int, maxWait time.Duration, handler func(ds []Delivery) error) error {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	batch := make([]Delivery, 0, maxSize)
+	spans := make([]trace.Span, 0, maxSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch, spans = batch[:0], spans[:0]
+
+		// The first message is waited for as long as it takes; the rest only
+		// until the batch is due
+		readCtx, cancel := ctx, context.CancelFunc(func() {})
+		for len(batch) < maxSize {
+			msg, err := c.reader.ReadMessage(readCtx)
+			if err != nil {
+				if ctx.Err() != nil {
+					cancel()
+					return ctx.Err()
+				}
+				if readCtx.Err() != nil {
+					break
+				}
+				slog.Error("Failed to read message", "error", err)
+				continue
 			}
+			if len(batch) == 0 {
+				readCtx, cancel = context.WithTimeout(ctx, maxWait)
+			}
+			// Each message has its own span, continuing its producer's trace,
+			// that lasts until the batch is handled
+			spanCtx, span := startConsumeSpan(ctx, msg, c.groupID)
+			batch = append(batch, Delivery{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset, Key: string(msg.Key), Value: msg.Value, ctx: spanCtx})
+			spans = append(spans, span)
+		}
+		cancel()
+
+		status := "success"
+		err := handler(batch)
+		if err != nil {
+			status = "failed"
+			slog.Error("Failed to handle message batch", "size", len(batch), "error", err)
+		}
+		for i, d := range batch {
+			endConsumeSpan(spans[i], err)
+			messagesConsumedTotal.WithLabelValues(d.Topic, c.groupID, status).Inc()
 		}
 	}
 }
@@ -115,9 +456,65 @@ func (c *Consumer) Close() error {
 	return c.reader.Close()
 }
 
+// Topics for ledger account events
+const (
+	TopicAccountCreated = "account.created"
+)
+
+// Topics for ledger journal events
+const (
+	TopicJournalPosted = "journal.posted"
+)
+
+// Topics for ledger transaction enrichment events
+const (
+	TopicTransactionCategorized = "transaction.categorized"
+)
+
+// Topics for card lifecycle events
+const (
+	TopicCardIssued  = "card.issued"
+	TopicCardBlocked = "card.blocked"
+	TopicCardExpired = "card.expired"
+)
+
+// Topics for outbound customer notifications
+const (
+	TopicNotificationEmail = "notification.email"
+	TopicNotificationSMS   = "notification.sms"
+)
+
+// Topics for user profile events
+const (
+	TopicUserUpdated            = "user.updated"
+	TopicUserPreferencesUpdated = "user.preferences.updated"
+)
+
 // Topics for payment events
 const (
 	TopicPaymentCreated   = "payment.created"
 	TopicPaymentCompleted = "payment.completed"
 	TopicPaymentFailed    = "payment.failed"
+	TopicPaymentCancelled = "payment.cancelled"
+)
+
+// Topics for referral rewards
+const (
+	TopicReferralCompleted = "referral.completed"
+)
+
+// Topics for direct debit mandate lifecycle events
+const (
+	TopicMandateCreated   = "mandate.created"
+	TopicMandateApproved  = "mandate.approved"
+	TopicMandateRevoked   = "mandate.revoked"
+	TopicMandateCancelled = "mandate.cancelled"
+)
+
+// Topics for payment request lifecycle events
+const (
+	TopicPaymentRequestCreated   = "payment_request.created"
+	TopicPaymentRequestPaid      = "payment_request.paid"
+	TopicPaymentRequestExpired   = "payment_request.expired"
+	TopicPaymentRequestCancelled = "payment_request.cancelled"
 )
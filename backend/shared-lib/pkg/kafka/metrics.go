@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/segmentio/kafka-go"
+)
+
+var (
+	messagesProducedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_produced_total",
+			Help: "Total number of messages published to Kafka",
+		},
+		[]string{"topic", "status"}, // success, failed
+	)
+
+	messagesConsumedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_consumed_total",
+			Help: "Total number of messages consumed from Kafka",
+		},
+		[]string{"topic", "group", "status"}, // success, failed
+	)
+
+	messagesDuplicateTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_duplicate_total",
+			Help: "Total number of redelivered messages skipped by a consumer inbox",
+		},
+		[]string{"topic", "group"},
+	)
+
+	consumerGroupLag = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kafka_consumer_group_lag",
+			Help: "Number of messages a consumer group is behind the latest offset",
+		},
+		[]string{"group", "topic", "partition"},
+	)
+
+	consumerGroupLagErrors = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_consumer_group_lag_errors_total",
+			Help: "Total number of failed consumer lag collections",
+		},
+		[]string{"group"},
+	)
+)
+
+// DefaultLagInterval is how often consumer lag is collected when no interval is given
+const DefaultLagInterval = 30 * time.Second
+
+// LagExporter periodically reports consumer group lag per topic and partition
+type LagExporter struct {
+	client   *kafka.Client
+	groupID  string
+	topics   []string
+	interval time.Duration
+}
+
+// NewLagExporter creates a lag exporter for a consumer group and its topics
+func NewLagExporter(brokers []string, groupID string, topics []string, interval time.Duration) *LagExporter {
+	if interval <= 0 {
+		interval = DefaultLagInterval
+	}
+	return &LagExporter{
+		client: &kafka.Client{
+			Addr:    kafka.TCP(brokers...),
+			Timeout: 10 * time.Second,
+		},
+		groupID:  groupID,
+		topics:   topics,
+		interval: interval,
+	}
+}
+
+// Start collects lag on every interval until the context is cancelled
+func (e *LagExporter) Start(ctx context.Context) {
+	slog.Info("Kafka lag exporter started", "group", e.groupID, "topics", e.topics, "interval", e.interval)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Collect(ctx); err != nil {
+			consumerGroupLagErrors.WithLabelValues(e.groupID).Inc()
+			slog.Warn("Failed to collect Kafka consumer lag", "group", e.groupID, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect fetches committed and latest offsets once and updates the lag gauges
+func (e *LagExporter) Collect(ctx context.Context) error {
+	meta, err := e.client.Metadata(ctx, &kafka.MetadataRequest{Topics: e.topics})
+	if err != nil {
+		return fmt.Errorf("metadata: %w", err)
+	}
+
+	partitions := make(map[string][]int, len(meta.Topics))
+	offsetsReq := make(map[string][]kafka.OffsetRequest, len(meta.Topics))
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			return fmt.Errorf("metadata for topic %s: %w", t.Name, t.Error)
+		}
+		for _, p := range t.Partitions {
+			partitions[t.Name] = append(partitions[t.Name], p.ID)
+			offsetsReq[t.Name] = append(offsetsReq[t.Name], kafka.FirstOffsetOf(p.ID), kafka.LastOffsetOf(p.ID))
+		}
+	}
+
+	committed, err := e.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: e.groupID,
+		Topics:  partitions,
+	})
+	if err != nil {
+		return fmt.Errorf("offset fetch: %w", err)
+	}
+	if committed.Error != nil {
+		return fmt.Errorf("offset fetch: %w", committed.Error)
+	}
+
+	latest, err := e.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: offsetsReq})
+	if err != nil {
+		return fmt.Errorf("list offsets: %w", err)
+	}
+
+	for topic, offsets := range latest.Topics {
+		committedByPartition := make(map[int]int64)
+		for _, p := range committed.Topics[topic] {
+			committedByPartition[p.Partition] = p.CommittedOffset
+		}
+
+		for _, p := range offsets {
+			if p.Error != nil {
+				slog.Warn("Failed to list partition offset", "topic", topic, "partition", p.Partition, "error", p.Error)
+				continue
+			}
+			lag := partitionLag(committedByPartition[p.Partition], p.FirstOffset, p.LastOffset)
+			consumerGroupLag.WithLabelValues(e.groupID, topic, strconv.Itoa(p.Partition)).Set(float64(lag))
+		}
+	}
+
+	return nil
+}
+
+// partitionLag computes lag from the committed offset. A negative committed offset
+// means the group has not committed yet, so everything still retained is pending.
+func partitionLag(committed, first, last int64) int64 {
+	if committed < 0 {
+		committed = first
+	}
+	if lag := last - committed; lag > 0 {
+		return lag
+	}
+	return 0
+}
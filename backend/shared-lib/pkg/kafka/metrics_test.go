@@ -0,0 +1,183 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol/listoffsets"
+	"github.com/segmentio/kafka-go/protocol/offsetfetch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReader hands out its messages in order, then waits as a reader of a
+// quiet topic does
+type fakeReader struct {
+	messages []kafka.Message
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	if len(r.messages) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// offsetsBroker is a fakeBroker that also serves a consumer group's committed
+// offsets and each partition's first and last offsets
+type offsetsBroker struct {
+	*fakeBroker
+	committed, first, last []int64
+}
+
+func (b *offsetsBroker) RoundTrip(ctx context.Context, addr net.Addr, req kafka.Request) (kafka.Response, error) {
+	switch r := req.(type) {
+	case *offsetfetch.Request:
+		res := &offsetfetch.Response{}
+		for _, topic := range r.Topics {
+			result := offsetfetch.ResponseTopic{Name: topic.Name}
+			for _, p := range topic.PartitionIndexes {
+				result.Partitions = append(result.Partitions, offsetfetch.ResponsePartition{PartitionIndex: p, CommittedOffset: b.committed[p]})
+			}
+			res.Topics = append(res.Topics, result)
+		}
+		return res, nil
+	case *listoffsets.Request:
+		res := &listoffsets.Response{}
+		for _, topic := range r.Topics {
+			result := listoffsets.ResponseTopic{Topic: topic.Topic}
+			for _, p := range topic.Partitions {
+				offset := b.last[p.Partition]
+				if p.Timestamp == kafka.FirstOffset {
+					offset = b.first[p.Partition]
+				}
+				result.Partitions = append(result.Partitions, listoffsets.ResponsePartition{Partition: p.Partition, Timestamp: p.Timestamp, Offset: offset})
+			}
+			res.Topics = append(res.Topics, result)
+		}
+		return res, nil
+	}
+	return b.fakeBroker.RoundTrip(ctx, addr, req)
+}
+
+func TestPartitionLag(t *testing.T) {
+	tests := []struct {
+		name                   string
+		committed, first, last int64
+		want                   int64
+	}{
+		{"behind the latest offset", 40, 0, 42, 2},
+		{"caught up", 42, 0, 42, 0},
+		{"committed past the latest offset", 50, 0, 42, 0},
+		{"nothing committed yet", -1, 10, 42, 32},
+		{"nothing committed on an empty partition", -1, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, partitionLag(tt.committed, tt.first, tt.last))
+		})
+	}
+}
+
+func TestLagExporter_CollectReportsEachPartition(t *testing.T) {
+	broker := &offsetsBroker{
+		fakeBroker: newFakeBroker(3),
+		committed:  []int64{100, -1, 300},
+		first:      []int64{0, 5, 0},
+		last:       []int64{120, 9, 300},
+	}
+	exporter := &LagExporter{
+		client:  &kafka.Client{Addr: kafka.TCP("broker:9092"), Transport: broker},
+		groupID: "lag-test",
+		topics:  []string{TopicPaymentCreated},
+	}
+
+	require.NoError(t, exporter.Collect(context.Background()))
+	lag := func(partition string) float64 {
+		return testutil.ToFloat64(consumerGroupLag.WithLabelValues("lag-test", TopicPaymentCreated, partition))
+	}
+	assert.Equal(t, 20.0, lag("0"))
+	// Without a commit, only the messages still retained are pending
+	assert.Equal(t, 4.0, lag("1"))
+	assert.Equal(t, 0.0, lag("2"))
+}
+
+func TestProducer_CountsPublishedMessages(t *testing.T) {
+	broker := newFakeBroker(1)
+	broker.failTopic = TopicPaymentFailed
+	producer := broker.producer(ProducerConfig{Linger: time.Millisecond})
+	succeeded := testutil.ToFloat64(messagesProducedTotal.WithLabelValues(TopicPaymentCreated, "success"))
+	failed := testutil.ToFloat64(messagesProducedTotal.WithLabelValues(TopicPaymentFailed, "failed"))
+
+	ctx := context.Background()
+	require.NoError(t, producer.Publish(ctx, TopicPaymentCreated, "a", "a", nil))
+	require.NoError(t, producer.Publish(ctx, TopicPaymentCreated, "b", "b", nil))
+	require.NoError(t, producer.Publish(ctx, TopicPaymentFailed, "c", "c", nil))
+	require.NoError(t, producer.Close())
+
+	assert.Equal(t, succeeded+2, testutil.ToFloat64(messagesProducedTotal.WithLabelValues(TopicPaymentCreated, "success")))
+	assert.Equal(t, failed+1, testutil.ToFloat64(messagesProducedTotal.WithLabelValues(TopicPaymentFailed, "failed")))
+}
+
+func TestConsumer_CountsConsumedMessages(t *testing.T) {
+	const group = "metrics-test"
+	messages := []kafka.Message{
+		{Topic: TopicPaymentCreated, Key: []byte("ok")},
+		{Topic: TopicPaymentCreated, Key: []byte("bad")},
+		{Topic: TopicPaymentCreated, Key: []byte("ok")},
+	}
+	counted := func(status string) float64 {
+		return testutil.ToFloat64(messagesConsumedTotal.WithLabelValues(TopicPaymentCreated, group, status))
+	}
+
+	t.Run("one at a time", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		consumer := &Consumer{reader: &fakeReader{messages: messages}, groupID: group}
+		succeeded, failed := counted("success"), counted("failed")
+
+		handled := 0
+		err := consumer.Consume(ctx, func(key string, _ []byte) error {
+			if handled++; handled == len(messages) {
+				cancel()
+			}
+			if key == "bad" {
+				return errors.New("cannot handle")
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, succeeded+2, counted("success"))
+		assert.Equal(t, failed+1, counted("failed"))
+	})
+
+	t.Run("in batches", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		consumer := &Consumer{reader: &fakeReader{messages: messages}, groupID: group}
+		succeeded, failed := counted("success"), counted("failed")
+
+		// A failed batch counts every message in it as failed
+		handled := 0
+		err := consumer.ConsumeDeliveryBatches(ctx, 2, 10*time.Millisecond, func(ds []Delivery) error {
+			if handled += len(ds); handled == len(messages) {
+				cancel()
+			}
+			if len(ds) == 2 {
+				return errors.New("cannot handle")
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, succeeded+1, counted("success"))
+		assert.Equal(t, failed+2, counted("failed"))
+	})
+}
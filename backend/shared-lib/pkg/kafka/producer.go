@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ProducerConfig tunes how a Producer batches Publish calls and whether it
+// can publish atomically
+type ProducerConfig struct {
+	// BatchSize is how many messages Publish sends in one write at most
+	BatchSize int
+	// BatchBytes is how large a batch Publish sends at most
+	BatchBytes int64
+	// Linger is how long Publish waits for a batch to fill before sending it
+	Linger time.Duration
+	// TransactionalID enables PublishAtomic. It must be stable for a
+	// producer across restarts and unique among running producers, so that
+	// Kafka fences off a previous instance's open transactions.
+	TransactionalID string
+	// TransactionTimeout is how long Kafka lets a transaction stay open
+	// before aborting it
+	TransactionTimeout time.Duration
+}
+
+// DefaultProducerConfig returns the configuration NewProducer uses
+func DefaultProducerConfig() ProducerConfig {
+	return ProducerConfig{
+		BatchSize:          100,
+		BatchBytes:         1 << 20,
+		Linger:             10 * time.Millisecond,
+		TransactionTimeout: 10 * time.Second,
+	}
+}
+
+// DeliveryCallback is called once a published message has been written, or
+// failed to be, with the write error
+type DeliveryCallback func(err error)
+
+// Publish queues a message for the topic and returns without waiting for it
+// to be written. Queued messages are sent in batches of up to BatchSize, after
+// Linger at the latest; delivered, when not nil, is called from the
+// producer's goroutine with the outcome. Publish only fails when the message
+// cannot be encoded or queued, or ctx ends while the queue is full.
+func (p *Producer) Publish(ctx context.Context, topic string, key string, value interface{}, delivered DeliveryCallback) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	msg := kafka.Message{
+		Topic:      topic,
+		Key:        []byte(key),
+		Value:      data,
+		WriterData: delivered,
+	}
+	injectTrace(ctx, &msg)
+	if err := p.async.WriteMessages(ctx, msg); err != nil {
+		messagesProducedTotal.WithLabelValues(topic, "failed").Inc()
+		slog.Error("Failed to queue message", "topic", topic, "error", err)
+		return err
+	}
+	return nil
+}
+
+// completePublished records the outcome of a batch of published messages and
+// reports it to their delivery callbacks
+func completePublished(messages []kafka.Message, err error) {
+	status := "success"
+	if err != nil {
+		status = "failed"
+		slog.Error("Failed to publish message batch", "count", len(messages), "error", err)
+	}
+	for _, msg := range messages {
+		messagesProducedTotal.WithLabelValues(msg.Topic, status).Inc()
+		if delivered, ok := msg.WriterData.(DeliveryCallback); ok && delivered != nil {
+			delivered(err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchmarkLatency is the round trip of a produce request to the fake broker,
+// about that of a broker in the same region
+const benchmarkLatency = time.Millisecond
+
+// BenchmarkProducer compares publishing throughput against a broker with a
+// network round trip: Produce waits for each message, ProduceBatch writes
+// BatchSize messages at a time, and Publish queues messages for the producer
+// to batch. requests/op is the produce requests each message costs, e.g.
+//
+//	go test ./pkg/kafka -run '^$' -bench Producer -benchmem
+func BenchmarkProducer(b *testing.B) {
+	ctx := context.Background()
+	event := PaymentEvent{
+		PaymentID:     "6f1c2a8e-4b0d-4d3e-9a57-2f0c7d1b9e11",
+		FromAccountID: "0b7e4f8a-91c2-4c55-8d3e-5a6b7c8d9e0f",
+		ToAccountID:   "d2c1b0a9-8f7e-4d6c-b5a4-3e2f1a0b9c8d",
+		Amount:        "125.50",
+		Currency:      "GBP",
+		Status:        "PENDING",
+		Timestamp:     "2026-10-16T09:30:00Z",
+	}
+	config := DefaultProducerConfig()
+
+	run := func(b *testing.B, publish func(*Producer, int)) {
+		broker := newFakeBroker(3)
+		broker.latency = benchmarkLatency
+		producer := broker.producer(config)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			publish(producer, i)
+		}
+		if err := producer.Close(); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		b.ReportMetric(float64(broker.requests)/float64(b.N), "requests/op")
+	}
+
+	b.Run("Produce", func(b *testing.B) {
+		run(b, func(p *Producer, i int) {
+			if err := p.Produce(ctx, TopicPaymentCreated, event.PaymentID, event); err != nil {
+				b.Fatal(err)
+			}
+		})
+	})
+
+	b.Run("ProduceBatch", func(b *testing.B) {
+		batch := make([]Message, 0, config.BatchSize)
+		run(b, func(p *Producer, i int) {
+			batch = append(batch, Message{Key: event.PaymentID, Value: event})
+			if len(batch) == config.BatchSize || i == b.N-1 {
+				if err := p.ProduceBatch(ctx, TopicPaymentCreated, batch); err != nil {
+					b.Fatal(err)
+				}
+				batch = batch[:0]
+			}
+		})
+	})
+
+	b.Run("Publish", func(b *testing.B) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var failed error
+		delivered := func(err error) {
+			if err != nil {
+				mu.Lock()
+				failed = err
+				mu.Unlock()
+			}
+			wg.Done()
+		}
+		run(b, func(p *Producer, i int) {
+			wg.Add(1)
+			if err := p.Publish(ctx, TopicPaymentCreated, event.PaymentID, event, delivered); err != nil {
+				b.Fatal(err)
+			}
+		})
+		wg.Wait()
+		if failed != nil {
+			b.Fatal(failed)
+		}
+	})
+}
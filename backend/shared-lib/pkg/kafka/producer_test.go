@@ -0,0 +1,250 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+	"github.com/segmentio/kafka-go/protocol/addpartitionstotxn"
+	"github.com/segmentio/kafka-go/protocol/endtxn"
+	"github.com/segmentio/kafka-go/protocol/initproducerid"
+	"github.com/segmentio/kafka-go/protocol/metadata"
+	"github.com/segmentio/kafka-go/protocol/produce"
+	"github.com/segmentio/kafka-go/protocol/rawproduce"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker is a single-broker Kafka cluster serving the requests producers
+// make, through a kafka.RoundTripper
+type fakeBroker struct {
+	partitions int
+	// latency delays every produce request, as a network round trip would
+	latency time.Duration
+	// failTopic has its produce requests rejected
+	failTopic string
+
+	mu       sync.Mutex
+	values   map[string][]string
+	requests int
+	epoch    int16
+	batches  []fakeBatch
+	ends     []bool
+}
+
+// fakeBatch is a record batch written by RawProduce
+type fakeBatch struct {
+	topic         string
+	partition     int32
+	transactional bool
+	producerID    int64
+	epoch         int16
+	sequence      int32
+	values        []string
+}
+
+func newFakeBroker(partitions int) *fakeBroker {
+	return &fakeBroker{partitions: partitions, values: map[string][]string{}}
+}
+
+func (b *fakeBroker) producer(config ProducerConfig) *Producer {
+	return newProducer(kafka.TCP("broker:9092"), b, config)
+}
+
+func (b *fakeBroker) RoundTrip(_ context.Context, _ net.Addr, req kafka.Request) (kafka.Response, error) {
+	switch r := req.(type) {
+	case *metadata.Request:
+		res := &metadata.Response{Brokers: []metadata.ResponseBroker{{NodeID: 1, Host: "broker", Port: 9092}}, ControllerID: 1}
+		for _, name := range r.TopicNames {
+			topic := metadata.ResponseTopic{Name: name}
+			for i := 0; i < b.partitions; i++ {
+				topic.Partitions = append(topic.Partitions, metadata.ResponsePartition{PartitionIndex: int32(i), LeaderID: 1})
+			}
+			res.Topics = append(res.Topics, topic)
+		}
+		return res, nil
+	case *produce.Request:
+		time.Sleep(b.latency)
+		res := &produce.Response{}
+		for _, topic := range r.Topics {
+			result := produce.ResponseTopic{Topic: topic.Topic}
+			for _, partition := range topic.Partitions {
+				values, err := readValues(partition.RecordSet.Records)
+				if err != nil {
+					return nil, err
+				}
+				result.Partitions = append(result.Partitions, b.append(topic.Topic, partition.Partition, values))
+			}
+			res.Topics = append(res.Topics, result)
+		}
+		return res, nil
+	case *rawproduce.Request:
+		time.Sleep(b.latency)
+		topic, partition := r.Topics[0].Topic, r.Topics[0].Partitions[0]
+		var records protocol.RecordSet
+		if _, err := records.ReadFrom(partition.RecordSet.Reader); err != nil {
+			return nil, err
+		}
+		batch := records.Records.(*protocol.RecordStream).Records[0].(*protocol.RecordBatch)
+		values, err := readValues(batch)
+		if err != nil {
+			return nil, err
+		}
+		b.mu.Lock()
+		b.batches = append(b.batches, fakeBatch{
+			topic: topic, partition: partition.Partition, transactional: batch.Attributes.Transactional(),
+			producerID: batch.ProducerID, epoch: batch.ProducerEpoch, sequence: batch.BaseSequence, values: values,
+		})
+		b.mu.Unlock()
+		return &produce.Response{Topics: []produce.ResponseTopic{{
+			Topic: topic, Partitions: []produce.ResponsePartition{b.append(topic, partition.Partition, values)},
+		}}}, nil
+	case *initproducerid.Request:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.epoch++
+		return &initproducerid.Response{ProducerID: 7, ProducerEpoch: b.epoch}, nil
+	case *addpartitionstotxn.Request:
+		res := &addpartitionstotxn.Response{}
+		for _, topic := range r.Topics {
+			result := addpartitionstotxn.ResponseResult{Name: topic.Name}
+			for _, partition := range topic.Partitions {
+				result.Results = append(result.Results, addpartitionstotxn.ResponsePartition{PartitionIndex: partition})
+			}
+			res.Results = append(res.Results, result)
+		}
+		return res, nil
+	case *endtxn.Request:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.ends = append(b.ends, r.Committed)
+		return &endtxn.Response{}, nil
+	}
+	return nil, errors.New("fake broker: unexpected request")
+}
+
+// append stores the values written to a partition, unless its topic fails
+func (b *fakeBroker) append(topic string, partition int32, values []string) produce.ResponsePartition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests++
+	if topic == b.failTopic {
+		return produce.ResponsePartition{Partition: partition, ErrorCode: int16(kafka.TopicAuthorizationFailed)}
+	}
+	b.values[topic] = append(b.values[topic], values...)
+	return produce.ResponsePartition{Partition: partition}
+}
+
+func (b *fakeBroker) topicValues(topic string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.values[topic]...)
+}
+
+func readValues(records protocol.RecordReader) ([]string, error) {
+	var values []string
+	for {
+		record, err := records.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		value, err := protocol.ReadAll(record.Value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, string(value))
+	}
+}
+
+func TestProducer_PublishReportsDelivery(t *testing.T) {
+	broker := newFakeBroker(1)
+	broker.failTopic = TopicPaymentFailed
+	producer := broker.producer(ProducerConfig{BatchSize: 2, Linger: time.Millisecond})
+
+	var mu sync.Mutex
+	delivered := map[string]error{}
+	callback := func(key string) DeliveryCallback {
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			delivered[key] = err
+		}
+	}
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, producer.Publish(ctx, TopicPaymentCreated, key, map[string]string{"id": key}, callback(key)))
+	}
+	require.NoError(t, producer.Publish(ctx, TopicPaymentFailed, "d", map[string]string{"id": "d"}, callback("d")))
+	require.NoError(t, producer.Publish(ctx, TopicPaymentCreated, "e", map[string]string{"id": "e"}, nil))
+
+	// Closing sends what is still queued
+	require.NoError(t, producer.Close())
+	assert.ElementsMatch(t, []string{`{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`, `{"id":"e"}`}, broker.topicValues(TopicPaymentCreated))
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, delivered, 4)
+	for _, key := range []string{"a", "b", "c"} {
+		assert.NoError(t, delivered[key], key)
+	}
+	assert.ErrorIs(t, delivered["d"], kafka.TopicAuthorizationFailed)
+}
+
+func TestProducer_PublishAtomic(t *testing.T) {
+	broker := newFakeBroker(2)
+	producer := broker.producer(ProducerConfig{TransactionalID: "payment-service-0"})
+	defer producer.Close()
+	ctx := context.Background()
+
+	require.NoError(t, producer.PublishAtomic(ctx, []TopicMessage{
+		{Topic: TopicPaymentCreated, Key: "p1", Value: map[string]string{"id": "p1"}},
+		{Topic: TopicPaymentCreated, Key: "p1", Value: map[string]string{"id": "p1", "n": "2"}},
+		{Topic: TopicPaymentCompleted, Key: "u1", Value: map[string]string{"user": "u1"}},
+	}))
+	assert.Equal(t, []bool{true}, broker.ends)
+	require.Len(t, broker.batches, 2)
+	for _, batch := range broker.batches {
+		assert.True(t, batch.transactional)
+		assert.Equal(t, int64(7), batch.producerID)
+		assert.Equal(t, int16(1), batch.epoch)
+		assert.Equal(t, int32(0), batch.sequence)
+	}
+	// Messages with a key share a partition, in order
+	assert.Equal(t, []string{`{"id":"p1"}`, `{"id":"p1","n":"2"}`}, broker.topicValues(TopicPaymentCreated))
+
+	// The next transaction continues the partition's sequence
+	require.NoError(t, producer.PublishAtomic(ctx, []TopicMessage{{Topic: TopicPaymentCreated, Key: "p1", Value: "again"}}))
+	last := broker.batches[len(broker.batches)-1]
+	assert.Equal(t, int32(2), last.sequence)
+	assert.Equal(t, int16(1), last.epoch)
+
+	// A failed write aborts the transaction, and the producer starts afresh
+	broker.failTopic = TopicPaymentCompleted
+	err := producer.PublishAtomic(ctx, []TopicMessage{
+		{Topic: TopicPaymentCreated, Key: "p2", Value: "created"},
+		{Topic: TopicPaymentCompleted, Key: "u2", Value: "notify"},
+	})
+	assert.ErrorIs(t, err, kafka.TopicAuthorizationFailed)
+	assert.Equal(t, []bool{true, true, false}, broker.ends)
+
+	broker.failTopic = ""
+	require.NoError(t, producer.PublishAtomic(ctx, []TopicMessage{{Topic: TopicPaymentCreated, Key: "p1", Value: "retried"}}))
+	last = broker.batches[len(broker.batches)-1]
+	assert.Equal(t, int16(2), last.epoch)
+	assert.Equal(t, int32(0), last.sequence)
+}
+
+func TestProducer_PublishAtomicNeedsTransactionalID(t *testing.T) {
+	producer := newFakeBroker(1).producer(DefaultProducerConfig())
+	defer producer.Close()
+	err := producer.PublishAtomic(context.Background(), []TopicMessage{{Topic: TopicPaymentCreated, Value: "x"}})
+	assert.ErrorIs(t, err, ErrTransactionsDisabled)
+}
@@ -0,0 +1,34 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTracePropagation(t *testing.T) {
+	previous := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	t.Cleanup(func() { otel.SetTextMapPropagator(previous) })
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "produce")
+	defer span.End()
+	member, _ := baggage.NewMemberRaw("payment_id", "pay-1")
+	bag, _ := baggage.New(member)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	msg := kafka.Message{Topic: TopicPaymentCreated, Headers: []kafka.Header{{Key: "source", Value: []byte("test")}}}
+	injectTrace(ctx, &msg)
+	assert.Len(t, msg.Headers, 3)
+
+	consumeCtx, consumeSpan := startConsumeSpan(context.Background(), msg, "ledger-service")
+	defer consumeSpan.End()
+	assert.Equal(t, span.SpanContext().TraceID(), consumeSpan.SpanContext().TraceID())
+	assert.Equal(t, "pay-1", baggage.FromContext(consumeCtx).Member("payment_id").Value())
+}
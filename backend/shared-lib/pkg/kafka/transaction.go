@@ -0,0 +1,319 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/protocol"
+)
+
+// ErrTransactionsDisabled is returned by PublishAtomic on a producer created
+// without a transactional ID
+var ErrTransactionsDisabled = errors.New("kafka: producer has no transactional ID")
+
+// TopicMessage is a keyed value for a topic, for PublishAtomic
+type TopicMessage struct {
+	Topic string
+	Key   string
+	Value interface{}
+}
+
+// PublishAtomic writes the messages in one Kafka transaction: consumers
+// reading committed messages, as Consumer does, see all of them or, when
+// PublishAtomic fails, none. Transactions of a producer run one at a time.
+func (p *Producer) PublishAtomic(ctx context.Context, messages []TopicMessage) error {
+	if p.txn == nil {
+		return ErrTransactionsDisabled
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	msgs := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		data, err := json.Marshal(m.Value)
+		if err != nil {
+			return err
+		}
+		msg := kafka.Message{Topic: m.Topic, Key: []byte(m.Key), Value: data}
+		injectTrace(ctx, &msg)
+		msgs = append(msgs, msg)
+	}
+
+	if err := p.txn.run(ctx, msgs); err != nil {
+		for _, msg := range msgs {
+			messagesProducedTotal.WithLabelValues(msg.Topic, "failed").Inc()
+		}
+		slog.Error("Failed to publish message transaction", "count", len(msgs), "error", err)
+		return err
+	}
+	for _, msg := range msgs {
+		messagesProducedTotal.WithLabelValues(msg.Topic, "success").Inc()
+	}
+
+	slog.Info("Message transaction published", "count", len(msgs))
+	return nil
+}
+
+// topicPartition identifies a partition of a topic
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// transactions runs the Kafka transactions of one transactional ID. kafka-go's
+// Writer cannot write transactional records, so transactions use the
+// protocol-level Client and encode their record batches themselves.
+type transactions struct {
+	client          *kafka.Client
+	transactionalID string
+	timeout         time.Duration
+	balancer        kafka.Hash
+
+	mu sync.Mutex
+	// producerID and epoch identify the producer to Kafka; producerID is -1
+	// until the producer is initialised, and again after a failure
+	producerID int
+	epoch      int
+	// sequences is the next sequence number of each partition written to
+	sequences map[topicPartition]int32
+}
+
+func newTransactions(client *kafka.Client, transactionalID string, timeout time.Duration) *transactions {
+	return &transactions{
+		client:          client,
+		transactionalID: transactionalID,
+		timeout:         timeout,
+		producerID:      -1,
+	}
+}
+
+// run writes the messages in a transaction and commits it. A failed
+// transaction is aborted, and the producer initialised again before the next
+// one, which also fences off anything left of the failed one.
+func (t *transactions) run(ctx context.Context, msgs []kafka.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.init(ctx); err != nil {
+		return err
+	}
+	if err := t.write(ctx, msgs); err != nil {
+		t.abort(ctx)
+		return err
+	}
+	if err := t.end(ctx, true); err != nil {
+		t.producerID = -1
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// init obtains a producer ID and epoch for the transactional ID, unless the
+// producer already has them
+func (t *transactions) init(ctx context.Context) error {
+	if t.producerID >= 0 {
+		return nil
+	}
+	res, err := t.client.InitProducerID(ctx, &kafka.InitProducerIDRequest{
+		TransactionalID:      t.transactionalID,
+		TransactionTimeoutMs: int(t.timeout.Milliseconds()),
+	})
+	if err == nil {
+		err = res.Error
+	}
+	if err != nil {
+		return fmt.Errorf("init transactional producer: %w", err)
+	}
+	t.producerID = res.Producer.ProducerID
+	t.epoch = res.Producer.ProducerEpoch
+	t.sequences = map[topicPartition]int32{}
+	return nil
+}
+
+// write partitions the messages, adds their partitions to the transaction and
+// writes each partition's messages as one transactional record batch
+func (t *transactions) write(ctx context.Context, msgs []kafka.Message) error {
+	batches, err := t.partition(ctx, msgs)
+	if err != nil {
+		return err
+	}
+
+	topics := map[string][]kafka.AddPartitionToTxn{}
+	for tp := range batches {
+		topics[tp.topic] = append(topics[tp.topic], kafka.AddPartitionToTxn{Partition: tp.partition})
+	}
+	added, err := t.client.AddPartitionsToTxn(ctx, &kafka.AddPartitionsToTxnRequest{
+		TransactionalID: t.transactionalID,
+		ProducerID:      t.producerID,
+		ProducerEpoch:   t.epoch,
+		Topics:          topics,
+	})
+	if err != nil {
+		return fmt.Errorf("add partitions to transaction: %w", err)
+	}
+	for topic, partitions := range added.Topics {
+		for _, partition := range partitions {
+			if partition.Error != nil {
+				return fmt.Errorf("add %s/%d to transaction: %w", topic, partition.Partition, partition.Error)
+			}
+		}
+	}
+
+	now := time.Now()
+	for tp, batch := range batches {
+		sequence := t.sequences[tp]
+		res, err := t.client.RawProduce(ctx, &kafka.RawProduceRequest{
+			Topic:           tp.topic,
+			Partition:       tp.partition,
+			RequiredAcks:    kafka.RequireAll,
+			TransactionalID: t.transactionalID,
+			RawRecords: protocol.RawRecordSet{
+				Reader: bytes.NewReader(encodeRecordBatch(batch, int64(t.producerID), int16(t.epoch), sequence, now)),
+			},
+		})
+		if err == nil {
+			err = res.Error
+		}
+		if err != nil {
+			return fmt.Errorf("write %s/%d: %w", tp.topic, tp.partition, err)
+		}
+		t.sequences[tp] = sequence + int32(len(batch))
+	}
+	return nil
+}
+
+// partition groups the messages by the partition their key hashes to, the
+// same partition kafka.Hash picks for writers
+func (t *transactions) partition(ctx context.Context, msgs []kafka.Message) (map[topicPartition][]kafka.Message, error) {
+	names := make([]string, 0, len(msgs))
+	seen := map[string]bool{}
+	for _, msg := range msgs {
+		if !seen[msg.Topic] {
+			seen[msg.Topic] = true
+			names = append(names, msg.Topic)
+		}
+	}
+	metadata, err := t.client.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return nil, fmt.Errorf("fetch topic metadata: %w", err)
+	}
+	partitions := map[string][]int{}
+	for _, topic := range metadata.Topics {
+		if topic.Error != nil {
+			return nil, fmt.Errorf("fetch %s metadata: %w", topic.Name, topic.Error)
+		}
+		for _, partition := range topic.Partitions {
+			partitions[topic.Name] = append(partitions[topic.Name], partition.ID)
+		}
+	}
+
+	batches := map[topicPartition][]kafka.Message{}
+	for _, msg := range msgs {
+		if len(partitions[msg.Topic]) == 0 {
+			return nil, fmt.Errorf("topic %s has no partitions", msg.Topic)
+		}
+		tp := topicPartition{topic: msg.Topic, partition: t.balancer.Balance(msg, partitions[msg.Topic]...)}
+		batches[tp] = append(batches[tp], msg)
+	}
+	return batches, nil
+}
+
+// abort ends the open transaction without committing it. A failed abort is
+// only logged: Kafka aborts the transaction itself once the producer is
+// initialised again, or it times out.
+func (t *transactions) abort(ctx context.Context) {
+	if err := t.end(ctx, false); err != nil {
+		slog.Warn("Failed to abort Kafka transaction", "transactional_id", t.transactionalID, "error", err)
+	}
+	t.producerID = -1
+}
+
+func (t *transactions) end(ctx context.Context, commit bool) error {
+	res, err := t.client.EndTxn(ctx, &kafka.EndTxnRequest{
+		TransactionalID: t.transactionalID,
+		ProducerID:      t.producerID,
+		ProducerEpoch:   t.epoch,
+		Committed:       commit,
+	})
+	if err != nil {
+		return err
+	}
+	return res.Error
+}
+
+// Record batch layout, see https://kafka.apache.org/documentation/#recordbatch
+const (
+	recordBatchMagic = 2
+	// transactionalAttribute marks a batch as part of a transaction
+	transactionalAttribute = 1 << 4
+	// recordBatchHeaderSize is the size of a batch up to its records
+	recordBatchHeaderSize = 61
+	// crcOffset is where the CRC sits in a batch; it covers the rest of it
+	crcOffset = 17
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// encodeRecordBatch encodes the messages as a transactional v2 record batch,
+// preceded by its size as RawRecordSet expects
+func encodeRecordBatch(msgs []kafka.Message, producerID int64, epoch int16, sequence int32, now time.Time) []byte {
+	timestamp := now.UnixMilli()
+	b := make([]byte, 4+recordBatchHeaderSize, 4+recordBatchHeaderSize+64*len(msgs))
+	for i, msg := range msgs {
+		b = appendRecord(b, int64(i), msg)
+	}
+
+	batch := b[4:]
+	binary.BigEndian.PutUint32(b[0:], uint32(len(batch)))
+	binary.BigEndian.PutUint64(batch[0:], 0)                     // base offset
+	binary.BigEndian.PutUint32(batch[8:], uint32(len(batch)-12)) // batch length
+	binary.BigEndian.PutUint32(batch[12:], 0xffffffff)           // partition leader epoch
+	batch[16] = recordBatchMagic
+	binary.BigEndian.PutUint16(batch[21:], transactionalAttribute)
+	binary.BigEndian.PutUint32(batch[23:], uint32(len(msgs)-1)) // last offset delta
+	binary.BigEndian.PutUint64(batch[27:], uint64(timestamp))   // first timestamp
+	binary.BigEndian.PutUint64(batch[35:], uint64(timestamp))   // max timestamp
+	binary.BigEndian.PutUint64(batch[43:], uint64(producerID))
+	binary.BigEndian.PutUint16(batch[51:], uint16(epoch))
+	binary.BigEndian.PutUint32(batch[53:], uint32(sequence))
+	binary.BigEndian.PutUint32(batch[57:], uint32(len(msgs)))
+	binary.BigEndian.PutUint32(batch[crcOffset:], crc32.Checksum(batch[crcOffset+4:], castagnoli))
+	return b
+}
+
+// appendRecord appends a record with its headers, timestamped as its batch
+func appendRecord(b []byte, offsetDelta int64, msg kafka.Message) []byte {
+	var record []byte
+	record = append(record, 0)              // attributes
+	record = binary.AppendVarint(record, 0) // timestamp delta
+	record = binary.AppendVarint(record, offsetDelta)
+	record = appendVarBytes(record, msg.Key)
+	record = appendVarBytes(record, msg.Value)
+	record = binary.AppendVarint(record, int64(len(msg.Headers)))
+	for _, h := range msg.Headers {
+		record = appendVarBytes(record, []byte(h.Key))
+		record = appendVarBytes(record, h.Value)
+	}
+
+	b = binary.AppendVarint(b, int64(len(record)))
+	return append(b, record...)
+}
+
+// appendVarBytes appends a length-prefixed byte string, -1 long when nil
+func appendVarBytes(b, data []byte) []byte {
+	if data == nil {
+		return binary.AppendVarint(b, -1)
+	}
+	b = binary.AppendVarint(b, int64(len(data)))
+	return append(b, data...)
+}
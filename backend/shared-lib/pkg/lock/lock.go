@@ -0,0 +1,279 @@
+// Package lock provides distributed locks on Redis so that a job runs on only
+// one replica at a time.
+//
+// Locks follow the Redlock algorithm: a lock is held when a majority of
+// independent Redis nodes grant it within its TTL, less an allowance for clock
+// drift. Most deployments use a single node, where this reduces to SET NX PX.
+// Each acquisition also returns a fencing token that increases with every
+// grant on a node; writers can pass it to storage that rejects stale tokens so
+// a holder whose lock expired mid-job cannot overwrite its successor's work.
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// ErrNotAcquired is returned when another holder has the lock
+	ErrNotAcquired = errors.New("lock is held by another process")
+	// ErrLockLost is returned when a lock expired or was taken over before it was extended or released
+	ErrLockLost = errors.New("lock was lost")
+)
+
+var (
+	lockAcquireTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "lock_acquire_total",
+			Help: "Total number of distributed lock acquisition attempts",
+		},
+		[]string{"name", "result"}, // acquired, contended, error
+	)
+
+	lockExtendTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "lock_extend_total",
+			Help: "Total number of distributed lock TTL extensions",
+		},
+		[]string{"name", "result"}, // extended, lost
+	)
+
+	lockHeldSeconds = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "lock_held_seconds",
+			Help:    "How long distributed locks were held",
+			Buckets: []float64{.01, .1, .5, 1, 5, 15, 60, 300, 900},
+		},
+		[]string{"name"},
+	)
+)
+
+// driftFactor and minDrift allow for clock differences between nodes when
+// deciding how long an acquired lock stays valid
+const (
+	driftFactor = 0.01
+	minDrift    = 2 * time.Millisecond
+)
+
+// releaseTimeout bounds releasing a lock once its context is already done
+const releaseTimeout = 5 * time.Second
+
+// Node is one independent lock server
+type Node interface {
+	// Acquire sets key to value for ttl if it is unset and returns the next fencing token
+	Acquire(ctx context.Context, key, value string, ttl time.Duration) (token int64, ok bool, err error)
+	// Extend resets the TTL of key if it still holds value
+	Extend(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	// Release deletes key if it still holds value
+	Release(ctx context.Context, key, value string) (bool, error)
+}
+
+// Locker acquires locks on a set of nodes
+type Locker struct {
+	nodes  []Node
+	quorum int
+}
+
+// NewLocker creates a locker over one or more independent nodes
+func NewLocker(nodes ...Node) *Locker {
+	return &Locker{nodes: nodes, quorum: len(nodes)/2 + 1}
+}
+
+// NewRedisLocker creates a locker over one or more independent Redis instances
+func NewRedisLocker(clients ...Evaler) *Locker {
+	nodes := make([]Node, len(clients))
+	for i, c := range clients {
+		nodes[i] = NewRedisNode(c)
+	}
+	return NewLocker(nodes...)
+}
+
+// Lock is a held lock
+type Lock struct {
+	locker     *Locker
+	name       string
+	value      string
+	token      int64
+	acquiredAt time.Time
+
+	mu         sync.Mutex
+	validUntil time.Time
+}
+
+// Name returns the lock's name
+func (l *Lock) Name() string { return l.name }
+
+// Token returns the fencing token of this acquisition. Tokens from one node
+// strictly increase; with several nodes they are the highest granted.
+func (l *Lock) Token() int64 { return l.token }
+
+// ValidUntil returns when the lock expires unless it is extended
+func (l *Lock) ValidUntil() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.validUntil
+}
+
+// TryLock acquires the named lock for ttl without waiting. It returns
+// ErrNotAcquired if another process holds it, or an error if too few nodes
+// could be reached to decide.
+func (lk *Locker) TryLock(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	value, err := randomValue()
+	if err != nil {
+		return nil, err
+	}
+	key := lockKey(name)
+
+	start := time.Now()
+	granted := 0
+	var token int64
+	var errs []error
+	for _, node := range lk.nodes {
+		t, ok, err := node.Acquire(ctx, key, value, ttl)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			granted++
+			token = max(token, t)
+		}
+	}
+
+	validity := ttl - time.Since(start) - drift(ttl)
+	if granted >= lk.quorum && validity > 0 {
+		lockAcquireTotal.WithLabelValues(name, "acquired").Inc()
+		return &Lock{locker: lk, name: name, value: value, token: token, acquiredAt: start, validUntil: start.Add(validity)}, nil
+	}
+
+	// Undo partial grants so the lock frees up before its TTL
+	lk.releaseAll(ctx, key, value)
+	if len(errs) > 0 {
+		lockAcquireTotal.WithLabelValues(name, "error").Inc()
+		return nil, fmt.Errorf("acquire lock %s: %w", name, errors.Join(errs...))
+	}
+	lockAcquireTotal.WithLabelValues(name, "contended").Inc()
+	return nil, ErrNotAcquired
+}
+
+// Extend resets the lock's TTL. It returns ErrLockLost if a majority of nodes
+// no longer hold it for this owner.
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	start := time.Now()
+	extended := 0
+	for _, node := range l.locker.nodes {
+		if ok, err := node.Extend(ctx, lockKey(l.name), l.value, ttl); err == nil && ok {
+			extended++
+		}
+	}
+
+	validity := ttl - time.Since(start) - drift(ttl)
+	if extended < l.locker.quorum || validity <= 0 {
+		lockExtendTotal.WithLabelValues(l.name, "lost").Inc()
+		return ErrLockLost
+	}
+	lockExtendTotal.WithLabelValues(l.name, "extended").Inc()
+
+	l.mu.Lock()
+	l.validUntil = start.Add(validity)
+	l.mu.Unlock()
+	return nil
+}
+
+// Release frees the lock on every node that still holds it for this owner
+func (l *Lock) Release(ctx context.Context) error {
+	lockHeldSeconds.WithLabelValues(l.name).Observe(time.Since(l.acquiredAt).Seconds())
+	if released := l.locker.releaseAll(ctx, lockKey(l.name), l.value); released < l.locker.quorum {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// WithLock runs fn while holding the named lock, extending it every third of
+// ttl until fn returns. fn's context is cancelled if the lock is lost, and
+// WithLock then returns ErrLockLost even if fn succeeded. If the lock is held
+// elsewhere fn does not run and ErrNotAcquired is returned.
+func (lk *Locker) WithLock(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context, lock *Lock) error) error {
+	lock, err := lk.TryLock(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-lockCtx.Done():
+				return
+			case <-ticker.C:
+				if err := lock.Extend(lockCtx, ttl); err != nil {
+					slog.Warn("Lost distributed lock", "lock", name, "token", lock.token)
+					cancel(ErrLockLost)
+					return
+				}
+			}
+		}
+	}()
+
+	fnErr := fn(lockCtx, lock)
+	close(done)
+	lost := errors.Is(context.Cause(lockCtx), ErrLockLost)
+	cancel(nil)
+
+	releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer releaseCancel()
+	if err := lock.Release(releaseCtx); err != nil && !lost {
+		slog.Warn("Failed to release distributed lock", "lock", name, "error", err)
+	}
+
+	if fnErr != nil {
+		return fnErr
+	}
+	if lost {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// releaseAll releases key on every node and returns how many released it
+func (lk *Locker) releaseAll(ctx context.Context, key, value string) int {
+	released := 0
+	for _, node := range lk.nodes {
+		if ok, err := node.Release(ctx, key, value); err == nil && ok {
+			released++
+		}
+	}
+	return released
+}
+
+// lockKey puts the lock and its fencing counter in the same Redis Cluster hash slot
+func lockKey(name string) string {
+	return "lock:{" + name + "}"
+}
+
+func drift(ttl time.Duration) time.Duration {
+	return time.Duration(float64(ttl)*driftFactor) + minDrift
+}
+
+// randomValue identifies one acquisition so only its owner can extend or release it
+func randomValue() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,250 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryNode is an in-process Node with the same semantics as RedisNode
+type memoryNode struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	fences  map[string]int64
+	err     error
+}
+
+func newMemoryNode() *memoryNode {
+	return &memoryNode{values: map[string]string{}, expires: map[string]time.Time{}, fences: map[string]int64{}}
+}
+
+func (n *memoryNode) current(key string) (string, bool) {
+	if exp, ok := n.expires[key]; ok && time.Now().After(exp) {
+		delete(n.values, key)
+		delete(n.expires, key)
+	}
+	v, ok := n.values[key]
+	return v, ok
+}
+
+func (n *memoryNode) Acquire(_ context.Context, key, value string, ttl time.Duration) (int64, bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return 0, false, n.err
+	}
+	if _, held := n.current(key); held {
+		return 0, false, nil
+	}
+	n.values[key] = value
+	n.expires[key] = time.Now().Add(ttl)
+	n.fences[key]++
+	return n.fences[key], true, nil
+}
+
+func (n *memoryNode) Extend(_ context.Context, key, value string, ttl time.Duration) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return false, n.err
+	}
+	if v, held := n.current(key); !held || v != value {
+		return false, nil
+	}
+	n.expires[key] = time.Now().Add(ttl)
+	return true, nil
+}
+
+func (n *memoryNode) Release(_ context.Context, key, value string) (bool, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return false, n.err
+	}
+	if v, held := n.current(key); !held || v != value {
+		return false, nil
+	}
+	delete(n.values, key)
+	delete(n.expires, key)
+	return true, nil
+}
+
+// steal makes another owner hold key, as if the lock expired and was taken over
+func (n *memoryNode) steal(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.values[key] = "someone-else"
+	n.expires[key] = time.Now().Add(time.Hour)
+}
+
+func TestTryLock_ExclusiveAndFenced(t *testing.T) {
+	ctx := context.Background()
+	locker := NewLocker(newMemoryNode())
+
+	first, err := locker.TryLock(ctx, "reconciliation", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "reconciliation", first.Name())
+	assert.Equal(t, int64(1), first.Token())
+	assert.True(t, first.ValidUntil().After(time.Now()))
+
+	_, err = locker.TryLock(ctx, "reconciliation", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+
+	// Other names are independent
+	_, err = locker.TryLock(ctx, "statements", time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, first.Release(ctx))
+	second, err := locker.TryLock(ctx, "reconciliation", time.Minute)
+	require.NoError(t, err)
+	assert.Greater(t, second.Token(), first.Token())
+}
+
+func TestTryLock_ExpiresAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	locker := NewLocker(newMemoryNode())
+
+	first, err := locker.TryLock(ctx, "job", 20*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.ErrorIs(t, first.Extend(ctx, time.Minute), ErrLockLost)
+	assert.ErrorIs(t, first.Release(ctx), ErrLockLost)
+}
+
+func TestTryLock_Quorum(t *testing.T) {
+	ctx := context.Background()
+	a, b, c := newMemoryNode(), newMemoryNode(), newMemoryNode()
+	locker := NewLocker(a, b, c)
+
+	// One node held elsewhere: two of three is still a majority
+	a.steal(lockKey("job"))
+	lock, err := locker.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release(ctx))
+
+	// Two nodes held elsewhere: the grant on c is undone
+	b.steal(lockKey("job"))
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, ErrNotAcquired)
+	_, held := c.current(lockKey("job"))
+	assert.False(t, held)
+}
+
+func TestTryLock_NodeErrors(t *testing.T) {
+	node := newMemoryNode()
+	node.err = errors.New("connection refused")
+
+	_, err := NewLocker(node).TryLock(context.Background(), "job", time.Minute)
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrNotAcquired)
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestWithLock_RunsOnceAndReleases(t *testing.T) {
+	ctx := context.Background()
+	locker := NewLocker(newMemoryNode())
+
+	var inner error
+	err := locker.WithLock(ctx, "job", time.Minute, func(ctx context.Context, lock *Lock) error {
+		// A second replica skips the job while it runs
+		inner = locker.WithLock(ctx, "job", time.Minute, func(context.Context, *Lock) error {
+			t.Fatal("ran concurrently")
+			return nil
+		})
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ErrorIs(t, inner, ErrNotAcquired)
+
+	// Released afterwards
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	assert.NoError(t, err)
+}
+
+func TestWithLock_ExtendsWhileRunning(t *testing.T) {
+	locker := NewLocker(newMemoryNode())
+
+	err := locker.WithLock(context.Background(), "job", 60*time.Millisecond, func(ctx context.Context, lock *Lock) error {
+		select {
+		case <-time.After(150 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	assert.NoError(t, err)
+}
+
+func TestWithLock_CancelsWhenLost(t *testing.T) {
+	node := newMemoryNode()
+	locker := NewLocker(node)
+
+	err := locker.WithLock(context.Background(), "job", 60*time.Millisecond, func(ctx context.Context, lock *Lock) error {
+		node.steal(lockKey("job"))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("not cancelled")
+		}
+	})
+	assert.ErrorIs(t, err, ErrLockLost)
+}
+
+func TestWithLock_ReturnsFnError(t *testing.T) {
+	boom := errors.New("boom")
+	err := NewLocker(newMemoryNode()).WithLock(context.Background(), "job", time.Minute, func(context.Context, *Lock) error {
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+}
+
+type fakeEvaler struct {
+	script string
+	keys   []string
+	args   []interface{}
+	result interface{}
+}
+
+func (f *fakeEvaler) Eval(_ context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	f.script, f.keys, f.args = script, keys, args
+	return f.result, nil
+}
+
+func TestRedisNode(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeEvaler{result: int64(7)}
+	node := NewRedisNode(client)
+
+	token, ok, err := node.Acquire(ctx, "lock:{job}", "owner", 1500*time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(7), token)
+	assert.Equal(t, acquireScript, client.script)
+	assert.Equal(t, []string{"lock:{job}"}, client.keys)
+	assert.Equal(t, []interface{}{"owner", int64(1500)}, client.args)
+
+	client.result = int64(0)
+	_, ok, err = node.Acquire(ctx, "lock:{job}", "owner", time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	client.result = int64(1)
+	released, err := node.Release(ctx, "lock:{job}", "owner")
+	require.NoError(t, err)
+	assert.True(t, released)
+	assert.Equal(t, releaseScript, client.script)
+
+	client.result = "OK"
+	_, err = node.Extend(ctx, "lock:{job}", "owner", time.Second)
+	assert.Error(t, err)
+}
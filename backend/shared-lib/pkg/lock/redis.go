@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Evaler runs Lua scripts on a Redis server; *cache.RedisClient implements it
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// acquireScript sets the lock if it is free and bumps the fencing counter next to it
+const acquireScript = `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[1] .. ":fence")
+end
+return 0`
+
+const extendScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`
+
+const releaseScript = `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`
+
+// RedisNode is a lock node backed by one Redis instance
+type RedisNode struct {
+	client Evaler
+}
+
+// NewRedisNode creates a lock node on a Redis client
+func NewRedisNode(client Evaler) *RedisNode {
+	return &RedisNode{client: client}
+}
+
+func (n *RedisNode) Acquire(ctx context.Context, key, value string, ttl time.Duration) (int64, bool, error) {
+	token, err := n.eval(ctx, acquireScript, key, value, ttl.Milliseconds())
+	if err != nil {
+		return 0, false, err
+	}
+	return token, token > 0, nil
+}
+
+func (n *RedisNode) Extend(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	res, err := n.eval(ctx, extendScript, key, value, ttl.Milliseconds())
+	return res == 1, err
+}
+
+func (n *RedisNode) Release(ctx context.Context, key, value string) (bool, error) {
+	res, err := n.eval(ctx, releaseScript, key, value)
+	return res == 1, err
+}
+
+func (n *RedisNode) eval(ctx context.Context, script, key string, args ...interface{}) (int64, error) {
+	res, err := n.client.Eval(ctx, script, []string{key}, args...)
+	if err != nil {
+		return 0, err
+	}
+	v, ok := res.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected lock script result %T", res)
+	}
+	return v, nil
+}
@@ -2,7 +2,10 @@ package logger
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
+	"math/big"
 	"os"
 	"regexp"
 	"strings"
@@ -14,6 +17,8 @@ var Log *slog.Logger
 var PIIPatterns = map[string]*regexp.Regexp{
 	"email":       regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`),
 	"card_number": regexp.MustCompile(`\b(?:\d{4}[-\s]?){3}\d{4}\b`),
+	"iban":        regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+	"jwt":         regexp.MustCompile(`\beyJ[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`),
 	"ssn":         regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
 	"phone":       regexp.MustCompile(`\b(?:\+1[-.\s]?)?\(?\d{3}\)?[-.\s]?\d{3}[-.\s]?\d{4}\b`),
 	"password":    regexp.MustCompile(`(?i)password["\s:=]+[^\s,}"']+`),
@@ -23,6 +28,13 @@ var PIIPatterns = map[string]*regexp.Regexp{
 	"bearer":      regexp.MustCompile(`(?i)bearer\s+[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+\.[a-zA-Z0-9_-]+`),
 }
 
+// piiPatternOrder applies the most specific patterns first so that, for
+// example, the digits of an IBAN are not mistaken for a phone number
+var piiPatternOrder = []string{
+	"bearer", "jwt", "iban", "card_number", "email", "ssn", "phone",
+	"password", "api_key", "secret", "token",
+}
+
 // SensitiveFields lists field names that should always be redacted
 var SensitiveFields = map[string]bool{
 	"password":        true,
@@ -48,8 +60,8 @@ type PIIRedactingHandler struct {
 }
 
 func (h *PIIRedactingHandler) Handle(ctx context.Context, r slog.Record) error {
-	// Create a new record with redacted attributes
-	newRecord := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	// Create a new record with redacted message and attributes
+	newRecord := slog.NewRecord(r.Time, r.Level, RedactPII(r.Message), r.PC)
 
 	r.Attrs(func(a slog.Attr) bool {
 		newRecord.AddAttrs(redactAttr(a))
@@ -71,7 +83,8 @@ func (h *PIIRedactingHandler) WithGroup(name string) slog.Handler {
 	return &PIIRedactingHandler{h.Handler.WithGroup(name)}
 }
 
-// redactAttr redacts sensitive data from a slog.Attr
+// redactAttr redacts sensitive data from a slog.Attr, including attributes
+// nested in groups and errors or Stringers logged as values
 func redactAttr(a slog.Attr) slog.Attr {
 	key := strings.ToLower(a.Key)
 
@@ -80,16 +93,36 @@ func redactAttr(a slog.Attr) slog.Attr {
 		return slog.String(a.Key, "[REDACTED]")
 	}
 
-	// For string values, check for PII patterns
-	if a.Value.Kind() == slog.KindString {
-		value := a.Value.String()
-		redacted := RedactPII(value)
-		if redacted != value {
-			return slog.String(a.Key, redacted)
+	value := a.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return redactString(a.Key, value.String(), a)
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, attr := range group {
+			redacted[i] = redactAttr(attr)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return redactString(a.Key, v.Error(), a)
+		case fmt.Stringer:
+			return redactString(a.Key, v.String(), a)
 		}
 	}
 
-	return a
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// redactString returns the original attribute when the value holds no PII
+func redactString(key, value string, original slog.Attr) slog.Attr {
+	redacted := RedactPII(value)
+	if redacted != value {
+		return slog.String(key, redacted)
+	}
+	return original
 }
 
 // RedactPII redacts personally identifiable information from a string
@@ -97,7 +130,11 @@ func RedactPII(input string) string {
 	result := input
 
 	// Redact each pattern
-	for name, pattern := range PIIPatterns {
+	for _, name := range piiPatternOrder {
+		pattern, ok := PIIPatterns[name]
+		if !ok {
+			continue
+		}
 		result = pattern.ReplaceAllStringFunc(result, func(match string) string {
 			return redactMatch(name, match)
 		})
@@ -127,6 +164,15 @@ func redactMatch(piiType, match string) string {
 			return "****-****-****-" + cleaned[len(cleaned)-4:]
 		}
 		return "[REDACTED_CARD]"
+	case "iban":
+		// Keep the country code and last 4 characters
+		cleaned := strings.ReplaceAll(match, " ", "")
+		if !validIBAN(cleaned) {
+			return match
+		}
+		return cleaned[:2] + strings.Repeat("*", len(cleaned)-6) + cleaned[len(cleaned)-4:]
+	case "jwt":
+		return "[REDACTED_JWT]"
 	case "ssn":
 		return "***-**-" + match[len(match)-4:]
 	case "phone":
@@ -148,24 +194,78 @@ func redactMatch(piiType, match string) string {
 	}
 }
 
+// validIBAN checks the ISO 13616 mod-97 checksum, so that other upper-case
+// references that happen to look like an IBAN are left alone
+func validIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// Options configures InitLoggerWithOptions
+type Options struct {
+	Level slog.Level
+	// Format is "json" (default) or "text"
+	Format   string
+	Sampling SamplingConfig
+	// Output defaults to stdout
+	Output io.Writer
+}
+
 // InitLogger initializes the logger with PII redaction
 func InitLogger(serviceName string, debug bool) {
-	opts := &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}
+	opts := Options{Level: slog.LevelInfo}
 	if debug {
 		opts.Level = slog.LevelDebug
 	}
+	InitLoggerWithOptions(serviceName, opts)
+}
 
-	baseHandler := slog.NewJSONHandler(os.Stdout, opts)
-	redactingHandler := &PIIRedactingHandler{Handler: baseHandler}
-
-	Log = slog.New(redactingHandler).With(
+// InitLoggerWithOptions initializes the logger with PII redaction and,
+// when configured, per-level sampling
+func InitLoggerWithOptions(serviceName string, opts Options) {
+	Log = slog.New(NewHandler(opts)).With(
 		slog.String("service", serviceName),
 	)
 	slog.SetDefault(Log)
 }
 
+// NewHandler builds the redacting, optionally sampling, handler used by InitLoggerWithOptions
+func NewHandler(opts Options) slog.Handler {
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+
+	var baseHandler slog.Handler
+	if strings.EqualFold(opts.Format, "text") {
+		baseHandler = slog.NewTextHandler(out, handlerOpts)
+	} else {
+		baseHandler = slog.NewJSONHandler(out, handlerOpts)
+	}
+
+	var handler slog.Handler = &PIIRedactingHandler{Handler: baseHandler}
+	// Sample before redacting so dropped records cost no regex work
+	if opts.Sampling.Enabled() {
+		handler = NewSamplingHandler(handler, opts.Sampling)
+	}
+	return handler
+}
+
 // InitLoggerWithoutRedaction initializes logger without PII redaction (for testing)
 func InitLoggerWithoutRedaction(serviceName string, debug bool) {
 	opts := &slog.HandlerOptions{
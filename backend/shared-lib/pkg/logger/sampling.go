@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// SamplingConfig thins out high-volume log levels under load
+type SamplingConfig struct {
+	// Rates is the fraction of records kept per level once the level has logged
+	// Threshold records in the current second. Levels without a rate, and
+	// ERROR and above, are never sampled.
+	Rates map[slog.Level]float64
+	// Threshold is how many records per level and second are always kept
+	Threshold int
+}
+
+// Enabled reports whether any level is sampled
+func (c SamplingConfig) Enabled() bool {
+	for level, rate := range c.Rates {
+		if level < slog.LevelError && rate < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// SamplingHandler wraps slog.Handler to drop a share of records per level
+type SamplingHandler struct {
+	slog.Handler
+	sampler *sampler
+}
+
+// NewSamplingHandler wraps next with per-level sampling
+func NewSamplingHandler(next slog.Handler, cfg SamplingConfig) *SamplingHandler {
+	return &SamplingHandler{
+		Handler: next,
+		sampler: &sampler{
+			rates:     cfg.Rates,
+			threshold: cfg.Threshold,
+			now:       time.Now,
+			counts:    make(map[slog.Level]int),
+		},
+	}
+}
+
+func (h *SamplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.sampler.keep(r.Level) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs and WithGroup share the sampler so derived loggers count
+// against the same per-level budget
+func (h *SamplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SamplingHandler{Handler: h.Handler.WithAttrs(attrs), sampler: h.sampler}
+}
+
+func (h *SamplingHandler) WithGroup(name string) slog.Handler {
+	return &SamplingHandler{Handler: h.Handler.WithGroup(name), sampler: h.sampler}
+}
+
+// sampler counts records per level in one-second windows
+type sampler struct {
+	rates     map[slog.Level]float64
+	threshold int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	window int64
+	counts map[slog.Level]int
+}
+
+func (s *sampler) keep(level slog.Level) bool {
+	if level >= slog.LevelError {
+		return true
+	}
+	rate, ok := s.rates[level]
+	if !ok || rate >= 1 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if second := s.now().Unix(); second != s.window {
+		s.window = second
+		clear(s.counts)
+	}
+	s.counts[level]++
+
+	over := s.counts[level] - s.threshold
+	if over <= 0 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	// Keep every record that crosses a multiple of 1/rate, which spreads the
+	// kept records evenly instead of relying on chance
+	return math.Floor(float64(over)*rate) > math.Floor(float64(over-1)*rate)
+}
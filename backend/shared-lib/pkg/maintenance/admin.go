@@ -0,0 +1,119 @@
+package maintenance
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler exposes endpoints to switch maintenance on and off
+type AdminHandler struct {
+	Store   Store
+	Service string
+	Audit   *middleware.AuditLogger
+}
+
+// NewAdminHandler creates an admin handler that audits every switch
+func NewAdminHandler(store Store, serviceName string) *AdminHandler {
+	return &AdminHandler{
+		Store:   store,
+		Service: serviceName,
+		Audit:   middleware.NewAuditLoggerWithConfig(middleware.AuditLoggerConfig{ServiceName: serviceName}),
+	}
+}
+
+// RegisterRoutes mounts the maintenance admin endpoints on a group that is
+// already authenticated and restricted to administrators. The paths must stay
+// under an allowlisted prefix so maintenance can be switched off again.
+func (h *AdminHandler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/maintenance", h.List)
+	rg.GET("/maintenance/:scope", h.Get)
+	rg.PUT("/maintenance/:scope", h.Put)
+}
+
+// List returns the global state and this service's state
+func (h *AdminHandler) List(c *gin.Context) {
+	states := make([]*State, 0, 2)
+	for _, scope := range []string{ScopeGlobal, h.Service} {
+		state, err := h.Store.Get(c.Request.Context(), scope)
+		if err != nil {
+			apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+			return
+		}
+		states = append(states, state)
+	}
+	c.JSON(http.StatusOK, gin.H{"states": states})
+}
+
+// Get returns the state of one scope, which may be another service's
+func (h *AdminHandler) Get(c *gin.Context) {
+	scope := c.Param("scope")
+	if !ValidScope(scope) {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(ErrInvalidScope.Error()))
+		return
+	}
+	state, err := h.Store.Get(c.Request.Context(), scope)
+	if err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+type PutStateRequest struct {
+	Enabled           *bool      `json:"enabled" binding:"required"`
+	Message           string     `json:"message" binding:"max=500"`
+	AllowReads        bool       `json:"allow_reads"`
+	RetryAfterSeconds int        `json:"retry_after_seconds"`
+	Until             *time.Time `json:"until"`
+}
+
+// Put switches a scope on or off. Scopes are shared between services, so an
+// admin of any service can freeze another during an incident.
+func (h *AdminHandler) Put(c *gin.Context) {
+	var req PutStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(err.Error()))
+		return
+	}
+
+	now := time.Now()
+	state := &State{
+		Scope:             c.Param("scope"),
+		Enabled:           *req.Enabled,
+		Message:           req.Message,
+		AllowReads:        req.AllowReads,
+		RetryAfterSeconds: req.RetryAfterSeconds,
+		Until:             req.Until,
+		UpdatedBy:         middleware.GetUserID(c),
+		UpdatedAt:         now,
+	}
+	if err := state.Validate(now); err != nil {
+		respondStateError(c, err)
+		return
+	}
+	if err := h.Store.Save(c.Request.Context(), state); err != nil {
+		respondStateError(c, err)
+		return
+	}
+
+	h.Audit.LogEvent(middleware.AuditEventAdminAction, middleware.AuditSeverityCritical, c, map[string]interface{}{
+		"action":      "maintenance_updated",
+		"scope":       state.Scope,
+		"enabled":     state.Enabled,
+		"allow_reads": state.AllowReads,
+	})
+	c.JSON(http.StatusOK, state)
+}
+
+func respondStateError(c *gin.Context, err error) {
+	if errors.Is(err, ErrInvalidScope) || errors.Is(err, ErrInvalidState) {
+		apperrors.RespondWithError(c, apperrors.ErrValidation.WithMessage(err.Error()))
+		return
+	}
+	apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+}
@@ -0,0 +1,140 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// failingStore fails every read after the first n
+type failingStore struct {
+	*MemoryStore
+	reads int
+	n     int
+}
+
+func (s *failingStore) Get(ctx context.Context, scope string) (*State, error) {
+	s.reads++
+	if s.reads > s.n {
+		return nil, errors.New("redis unavailable")
+	}
+	return s.MemoryStore.Get(ctx, scope)
+}
+
+func newGuardedRouter(cfg Config) *gin.Engine {
+	r := gin.New()
+	r.Use(Guard(cfg))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/health", ok)
+	r.GET("/api/v1/accounts", ok)
+	r.POST("/api/v1/transfer", ok)
+	r.PUT("/api/v1/admin/maintenance/:scope", ok)
+	return r
+}
+
+func do(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestGuard_ServiceKillSwitch(t *testing.T) {
+	store := NewMemoryStore()
+	r := newGuardedRouter(Config{Service: "payment-service", Store: store, RefreshInterval: time.Nanosecond})
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPost, "/api/v1/transfer").Code)
+
+	require.NoError(t, store.Save(context.Background(), &State{Scope: "payment-service", Enabled: true, AllowReads: true, Message: "Transfers are paused", RetryAfterSeconds: 120}))
+	w := do(r, http.MethodPost, "/api/v1/transfer")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Transfers are paused")
+
+	// Reads, probes and the admin API stay available
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/api/v1/accounts").Code)
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/health").Code)
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPut, "/api/v1/admin/maintenance/payment-service").Code)
+
+	// Another service's switch does not apply
+	require.NoError(t, store.Save(context.Background(), &State{Scope: "payment-service"}))
+	require.NoError(t, store.Save(context.Background(), &State{Scope: "card-service", Enabled: true}))
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPost, "/api/v1/transfer").Code)
+}
+
+func TestGuard_GlobalWindowWithEnd(t *testing.T) {
+	store := NewMemoryStore()
+	r := newGuardedRouter(Config{Service: "ledger-service", Store: store, RefreshInterval: time.Nanosecond})
+
+	until := time.Now().Add(10 * time.Minute)
+	require.NoError(t, store.Save(context.Background(), &State{Scope: ScopeGlobal, Enabled: true, Until: &until}))
+	w := do(r, http.MethodGet, "/api/v1/accounts")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "601", w.Header().Get("Retry-After"))
+
+	past := time.Now().Add(-time.Second)
+	require.NoError(t, store.Save(context.Background(), &State{Scope: ScopeGlobal, Enabled: true, Until: &past}))
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/api/v1/accounts").Code)
+}
+
+func TestGuard_ForcedAndStoreFailures(t *testing.T) {
+	forced, err := ParseMode("read-only")
+	require.NoError(t, err)
+	r := newGuardedRouter(Config{Service: "card-service", Forced: forced})
+	assert.Equal(t, http.StatusServiceUnavailable, do(r, http.MethodPost, "/api/v1/transfer").Code)
+	assert.Equal(t, http.StatusOK, do(r, http.MethodGet, "/api/v1/accounts").Code)
+
+	_, err = ParseMode("sometimes")
+	assert.Error(t, err)
+
+	// The last known state is kept while the store is unreachable
+	store := &failingStore{MemoryStore: NewMemoryStore(), n: 2}
+	require.NoError(t, store.Save(context.Background(), &State{Scope: "card-service", Enabled: true}))
+	r = newGuardedRouter(Config{Service: "card-service", Store: store, RefreshInterval: time.Nanosecond})
+	assert.Equal(t, http.StatusServiceUnavailable, do(r, http.MethodPost, "/api/v1/transfer").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, do(r, http.MethodPost, "/api/v1/transfer").Code)
+
+	// With no state ever read, requests are let through
+	r = newGuardedRouter(Config{Service: "card-service", Store: &failingStore{MemoryStore: NewMemoryStore()}})
+	assert.Equal(t, http.StatusOK, do(r, http.MethodPost, "/api/v1/transfer").Code)
+}
+
+func TestAdminHandler_SwitchesScopes(t *testing.T) {
+	store := NewMemoryStore()
+	r := gin.New()
+	NewAdminHandler(store, "payment-service").RegisterRoutes(r.Group("/admin"))
+
+	put := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPut, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, put("/admin/maintenance/ledger-service", `{"enabled":true,"allow_reads":true,"message":"Ledger incident"}`).Code)
+	state, err := store.Get(context.Background(), "ledger-service")
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.True(t, state.AllowReads)
+
+	assert.Equal(t, http.StatusBadRequest, put("/admin/maintenance/Ledger_Service", `{"enabled":true}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put("/admin/maintenance/global", `{"enabled":true,"until":"2001-01-01T00:00:00Z"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, put("/admin/maintenance/global", `{}`).Code)
+
+	w := do(r, http.MethodGet, "/admin/maintenance")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"scope":"global"`)
+	assert.Contains(t, w.Body.String(), `"scope":"payment-service"`)
+}
@@ -0,0 +1,151 @@
+package maintenance
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/metrics"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/openapi"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rejectedRequestsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "maintenance_rejected_requests_total",
+		Help: "Total number of requests rejected because of maintenance mode",
+	},
+	[]string{"service", "scope"},
+)
+
+// DefaultAllowlist lists the path prefixes that stay available during
+// maintenance: probes, metrics, SLO status, API docs and the maintenance admin
+// API, so maintenance can always be switched off again
+var DefaultAllowlist = []string{"/health", "/metrics", metrics.SLOStatusPath, openapi.SpecPath, openapi.DocsPath, "/api/v1/admin/maintenance"}
+
+// DefaultRefreshInterval is how long each replica reuses the states it read
+const DefaultRefreshInterval = 2 * time.Second
+
+// Config configures the maintenance middleware
+type Config struct {
+	Service string
+	Store   Store
+	// Forced applies regardless of the store, e.g. from MAINTENANCE_MODE during a
+	// deploy; see ParseMode
+	Forced *State
+	// Allow adds path prefixes to DefaultAllowlist
+	Allow           []string
+	RefreshInterval time.Duration
+}
+
+// ParseMode turns a MAINTENANCE_MODE setting into a forced state: "off" (or
+// empty) for none, "read-only" to reject writes and "full" to reject everything
+// outside the allowlist
+func ParseMode(mode string) (*State, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "off":
+		return nil, nil
+	case "read-only":
+		return &State{Scope: "config", Enabled: true, AllowReads: true}, nil
+	case "full":
+		return &State{Scope: "config", Enabled: true}, nil
+	default:
+		return nil, fmt.Errorf("maintenance mode must be off, read-only or full, got %q", mode)
+	}
+}
+
+// Guard rejects requests with 503 while the global scope or the service's
+// own scope is in maintenance. If the store cannot be read the last known
+// states are kept, and with none requests are let through: a Redis outage
+// must not take the service down.
+func Guard(cfg Config) gin.HandlerFunc {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = DefaultRefreshInterval
+	}
+	allow := append(append([]string{}, DefaultAllowlist...), cfg.Allow...)
+	g := &guard{cfg: cfg}
+
+	return func(c *gin.Context) {
+		if allowed(allow, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		for _, state := range g.states(c, now) {
+			if !state.Active(now) || (state.AllowReads && isRead(c.Request.Method)) {
+				continue
+			}
+			rejectedRequestsTotal.WithLabelValues(cfg.Service, state.Scope).Inc()
+			message := state.Message
+			if message == "" {
+				message = "The service is down for maintenance, please try again later"
+			}
+			c.Header("Retry-After", strconv.Itoa(int(state.RetryAfter(now).Seconds())))
+			apperrors.RespondWithError(c, apperrors.NewError("MAINTENANCE", message, http.StatusServiceUnavailable))
+			return
+		}
+		c.Next()
+	}
+}
+
+// guard caches the states of the global and service scopes between refreshes
+type guard struct {
+	cfg Config
+
+	mu        sync.Mutex
+	cached    []State
+	fetchedAt time.Time
+}
+
+func (g *guard) states(c *gin.Context, now time.Time) []State {
+	var states []State
+	if g.cfg.Forced != nil {
+		states = append(states, *g.cfg.Forced)
+	}
+	if g.cfg.Store == nil {
+		return states
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if now.Sub(g.fetchedAt) >= g.cfg.RefreshInterval {
+		fetched := make([]State, 0, 2)
+		var err error
+		for _, scope := range []string{ScopeGlobal, g.cfg.Service} {
+			var state *State
+			if state, err = g.cfg.Store.Get(c.Request.Context(), scope); err != nil {
+				break
+			}
+			fetched = append(fetched, *state)
+		}
+		if err != nil {
+			slog.Warn("Failed to read maintenance state, using last known", "error", err)
+		} else {
+			g.cached = fetched
+		}
+		// Retry a failed read after the interval too, rather than on every request
+		g.fetchedAt = now
+	}
+	return append(states, g.cached...)
+}
+
+func allowed(allowlist []string, path string) bool {
+	for _, prefix := range allowlist {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,78 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyPrefix prefixes the Redis key of each scope
+const KeyPrefix = "maintenance:"
+
+// RedisStore keeps states in Redis. A window with an end expires with it, and
+// switching a scope off removes its key.
+type RedisStore struct {
+	client *cache.RedisClient
+}
+
+// NewRedisStore creates a Redis-backed store
+func NewRedisStore(client *cache.RedisClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, scope string) (*State, error) {
+	var state State
+	if err := s.client.GetJSON(ctx, KeyPrefix+scope, &state); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return &State{Scope: scope}, nil
+		}
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (s *RedisStore) Save(ctx context.Context, state *State) error {
+	if !state.Enabled {
+		return s.client.Delete(ctx, KeyPrefix+state.Scope)
+	}
+	var ttl time.Duration
+	if state.Until != nil {
+		ttl = time.Until(*state.Until)
+	}
+	return s.client.SetJSON(ctx, KeyPrefix+state.Scope, state, ttl)
+}
+
+// MemoryStore keeps states in memory (tests, and services running without Redis,
+// where a switch only applies to the replica it was made on)
+type MemoryStore struct {
+	mu     sync.RWMutex
+	states map[string]State
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{states: make(map[string]State)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, scope string) (*State, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	state, ok := s.states[scope]
+	if !ok {
+		return &State{Scope: scope}, nil
+	}
+	return &state, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.Scope] = *state
+	return nil
+}
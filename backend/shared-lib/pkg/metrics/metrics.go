@@ -10,6 +10,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// httpDurationBuckets are the latency buckets of every request; SLO
+// histograms add each SLO's threshold to them
+var httpDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
 var (
 	// HTTP request metrics
 	httpRequestsTotal = promauto.NewCounterVec(
@@ -24,7 +28,7 @@ var (
 		prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
 			Help:    "HTTP request duration in seconds",
-			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+			Buckets: httpDurationBuckets,
 		},
 		[]string{"service", "method", "path"},
 	)
@@ -77,11 +81,12 @@ var (
 	)
 )
 
-// PrometheusMiddleware returns a Gin middleware for Prometheus metrics
+// PrometheusMiddleware returns a Gin middleware for Prometheus metrics. Requests
+// to routes with an SLO registered by RegisterSLOs are also recorded against it.
 func PrometheusMiddleware(serviceName string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Skip metrics endpoint itself
-		if c.Request.URL.Path == "/metrics" {
+		if c.Request.URL.Path == "/metrics" || c.Request.URL.Path == SLOStatusPath {
 			c.Next()
 			return
 		}
@@ -97,11 +102,15 @@ func PrometheusMiddleware(serviceName string) gin.HandlerFunc {
 		c.Next()
 
 		// Record metrics
-		duration := time.Since(start).Seconds()
+		elapsed := time.Since(start)
+		duration := elapsed.Seconds()
 		status := strconv.Itoa(c.Writer.Status())
 		path := c.FullPath()
 		if path == "" {
 			path = c.Request.URL.Path
+		} else if slos := slosFor(serviceName); slos != nil {
+			// Unmatched paths have no route, so no SLO
+			slos.observe(c.Request.Method, path, c.Writer.Status(), elapsed, time.Now())
 		}
 
 		httpRequestsTotal.WithLabelValues(serviceName, c.Request.Method, path, status).Inc()
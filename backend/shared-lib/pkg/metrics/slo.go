@@ -0,0 +1,281 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SLOStatusPath serves the in-process error budget report
+const SLOStatusPath = "/slo-status"
+
+const (
+	// sloBucket and sloWindowBuckets give the rolling window error budgets are computed over
+	sloBucket        = time.Minute
+	sloWindowBuckets = 60
+	// sloFastWindow is the short window of the burn rate, for spotting a fresh incident
+	sloFastWindow = 5
+)
+
+// SLO is a latency objective for one route: Objective of its requests must
+// succeed (status below 500) within Threshold
+type SLO struct {
+	Name string
+	// Method is the HTTP method; empty matches every method of the route
+	Method string
+	// Route is the gin route pattern, e.g. /api/v1/accounts/:id/balance
+	Route     string
+	Threshold time.Duration
+	// Objective is the target share of good requests, e.g. 0.99
+	Objective float64
+}
+
+// SLOStatus reports how an SLO did over the last hour
+type SLOStatus struct {
+	Name        string  `json:"name"`
+	Method      string  `json:"method,omitempty"`
+	Route       string  `json:"route"`
+	ThresholdMS float64 `json:"threshold_ms"`
+	Objective   float64 `json:"objective"`
+	Requests    uint64  `json:"requests"`
+	Bad         uint64  `json:"bad_requests"`
+	// SLI is the share of good requests; 1 when there were none
+	SLI float64 `json:"sli"`
+	// ErrorBudgetRemaining is the unspent share of the hour's error budget;
+	// negative once the objective is missed
+	ErrorBudgetRemaining float64 `json:"error_budget_remaining"`
+	// BurnRate is how many times faster than sustainable the budget is spent
+	BurnRate5m float64 `json:"burn_rate_5m"`
+	BurnRate1h float64 `json:"burn_rate_1h"`
+}
+
+var (
+	sloRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "slo_requests_total",
+			Help: "Requests covered by an SLO, by whether they met it",
+		},
+		[]string{"service", "slo", "result"}, // good, bad
+	)
+
+	sloMu         sync.RWMutex
+	sloRegistries = map[string]*sloRegistry{}
+)
+
+func init() {
+	prometheus.MustRegister(sloRequestsTotal)
+}
+
+// sloRegistry holds a service's SLOs and their rolling windows
+type sloRegistry struct {
+	service  string
+	slos     []SLO
+	windows  []*sloWindow
+	byRoute  map[string][]int
+	duration *prometheus.HistogramVec
+}
+
+// RegisterSLOs declares a service's latency objectives. PrometheusMiddleware
+// then records every request against them, with histogram buckets at each
+// threshold so good requests can be counted exactly from the le label.
+func RegisterSLOs(service string, slos ...SLO) error {
+	reg := &sloRegistry{service: service, byRoute: map[string][]int{}}
+	buckets := slices.Clone(httpDurationBuckets)
+	for i, slo := range slos {
+		if err := slo.validate(); err != nil {
+			return err
+		}
+		if slices.ContainsFunc(slos[:i], func(other SLO) bool { return other.Name == slo.Name }) {
+			return fmt.Errorf("duplicate SLO %q", slo.Name)
+		}
+		reg.slos = append(reg.slos, slo)
+		reg.windows = append(reg.windows, &sloWindow{})
+		reg.byRoute[slo.Route] = append(reg.byRoute[slo.Route], i)
+		buckets = append(buckets, slo.Threshold.Seconds())
+	}
+	sort.Float64s(buckets)
+
+	reg.duration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:        "http_request_slo_duration_seconds",
+			Help:        "Duration of requests covered by an SLO, with a bucket at each SLO threshold",
+			Buckets:     slices.Compact(buckets),
+			ConstLabels: prometheus.Labels{"service": service},
+		},
+		[]string{"method", "path"},
+	)
+
+	sloMu.Lock()
+	defer sloMu.Unlock()
+	if _, exists := sloRegistries[service]; exists {
+		return fmt.Errorf("SLOs for %s are already registered", service)
+	}
+	if err := prometheus.Register(reg.duration); err != nil {
+		return err
+	}
+	sloRegistries[service] = reg
+	return nil
+}
+
+// MustRegisterSLOs is RegisterSLOs for use at startup; it panics on error
+func MustRegisterSLOs(service string, slos ...SLO) {
+	if err := RegisterSLOs(service, slos...); err != nil {
+		panic(err)
+	}
+}
+
+// CheckSLORoutes returns an error naming the SLOs whose route no gin route
+// serves, so objectives do not silently stop matching after a route changes
+func CheckSLORoutes(slos []SLO, routes gin.RoutesInfo) error {
+	var missing []string
+	for _, slo := range slos {
+		served := slices.ContainsFunc(routes, func(r gin.RouteInfo) bool {
+			return r.Path == slo.Route && (slo.Method == "" || r.Method == slo.Method)
+		})
+		if !served {
+			missing = append(missing, slo.Name+" ("+slo.Method+" "+slo.Route+")")
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("SLOs match no route: %v", missing)
+	}
+	return nil
+}
+
+// SLOStatusHandler reports the error budget of each of the service's SLOs
+func SLOStatusHandler(service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"service": service, "slos": SLOStatuses(service, time.Now())})
+	}
+}
+
+// SLOStatuses computes the status of each of the service's SLOs at now
+func SLOStatuses(service string, now time.Time) []SLOStatus {
+	reg := slosFor(service)
+	if reg == nil {
+		return []SLOStatus{}
+	}
+	statuses := make([]SLOStatus, len(reg.slos))
+	for i, slo := range reg.slos {
+		total, bad := reg.windows[i].sum(now, sloWindowBuckets)
+		fastTotal, fastBad := reg.windows[i].sum(now, sloFastWindow)
+		budget := 1 - slo.Objective
+
+		status := SLOStatus{
+			Name:                 slo.Name,
+			Method:               slo.Method,
+			Route:                slo.Route,
+			ThresholdMS:          float64(slo.Threshold) / float64(time.Millisecond),
+			Objective:            slo.Objective,
+			Requests:             total,
+			Bad:                  bad,
+			SLI:                  1,
+			ErrorBudgetRemaining: 1,
+			BurnRate5m:           burnRate(fastTotal, fastBad, budget),
+			BurnRate1h:           burnRate(total, bad, budget),
+		}
+		if total > 0 {
+			status.SLI = 1 - float64(bad)/float64(total)
+			status.ErrorBudgetRemaining = 1 - status.BurnRate1h
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
+
+// burnRate is the bad share of requests relative to the budgeted share
+func burnRate(total, bad uint64, budget float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(bad) / float64(total) / budget
+}
+
+func slosFor(service string) *sloRegistry {
+	sloMu.RLock()
+	defer sloMu.RUnlock()
+	return sloRegistries[service]
+}
+
+// observe records a finished request against the SLOs of its route
+func (r *sloRegistry) observe(method, route string, status int, duration time.Duration, now time.Time) {
+	matched := false
+	for _, i := range r.byRoute[route] {
+		slo := r.slos[i]
+		if slo.Method != "" && slo.Method != method {
+			continue
+		}
+		matched = true
+		good := status < http.StatusInternalServerError && duration <= slo.Threshold
+		r.windows[i].add(now, good)
+		result := "good"
+		if !good {
+			result = "bad"
+		}
+		sloRequestsTotal.WithLabelValues(r.service, slo.Name, result).Inc()
+	}
+	if matched {
+		r.duration.WithLabelValues(method, route).Observe(duration.Seconds())
+	}
+}
+
+func (s SLO) validate() error {
+	switch {
+	case s.Name == "":
+		return errors.New("SLO name is required")
+	case len(s.Route) == 0 || s.Route[0] != '/':
+		return fmt.Errorf("SLO %q: route must start with /", s.Name)
+	case s.Threshold <= 0:
+		return fmt.Errorf("SLO %q: threshold must be positive", s.Name)
+	case s.Objective <= 0 || s.Objective >= 1:
+		return fmt.Errorf("SLO %q: objective must be between 0 and 1", s.Name)
+	}
+	return nil
+}
+
+// sloWindow counts requests in per-minute buckets over the last hour
+type sloWindow struct {
+	mu      sync.Mutex
+	buckets [sloWindowBuckets]sloCount
+}
+
+type sloCount struct {
+	minute int64
+	total  uint64
+	bad    uint64
+}
+
+func (w *sloWindow) add(now time.Time, good bool) {
+	minute := now.Unix() / int64(sloBucket/time.Second)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b := &w.buckets[minute%sloWindowBuckets]
+	if b.minute != minute {
+		*b = sloCount{minute: minute}
+	}
+	b.total++
+	if !good {
+		b.bad++
+	}
+}
+
+// sum totals the buckets of the last n minutes, including the current one
+func (w *sloWindow) sum(now time.Time, n int64) (total, bad uint64) {
+	minute := now.Unix() / int64(sloBucket/time.Second)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, b := range w.buckets {
+		if b.minute > minute-n && b.minute <= minute {
+			total += b.total
+			bad += b.bad
+		}
+	}
+	return total, bad
+}
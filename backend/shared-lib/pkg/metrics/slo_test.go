@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+var testSLOs = []SLO{
+	{Name: "balance-latency", Method: http.MethodGet, Route: "/accounts/:id/balance", Threshold: 50 * time.Millisecond, Objective: 0.9},
+	{Name: "transfer-latency", Method: http.MethodPost, Route: "/transfers", Threshold: time.Second, Objective: 0.99},
+}
+
+func TestRegisterSLOs_Validation(t *testing.T) {
+	assert.Error(t, RegisterSLOs("slo-invalid", SLO{Name: "x", Route: "accounts", Threshold: time.Second, Objective: 0.9}))
+	assert.Error(t, RegisterSLOs("slo-invalid", SLO{Name: "x", Route: "/accounts", Objective: 0.9}))
+	assert.Error(t, RegisterSLOs("slo-invalid", SLO{Name: "x", Route: "/accounts", Threshold: time.Second, Objective: 1}))
+	assert.Error(t, RegisterSLOs("slo-invalid", testSLOs[0], testSLOs[0]))
+
+	require.NoError(t, RegisterSLOs("slo-twice", testSLOs...))
+	assert.Error(t, RegisterSLOs("slo-twice", testSLOs...))
+}
+
+func TestSLOStatuses_ErrorBudgetBurn(t *testing.T) {
+	require.NoError(t, RegisterSLOs("slo-burn", testSLOs...))
+	reg := slosFor("slo-burn")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// An hour ago: 10 good balance reads, outside the 5-minute window
+	for i := 0; i < 10; i++ {
+		reg.observe(http.MethodGet, "/accounts/:id/balance", http.StatusOK, 10*time.Millisecond, now.Add(-50*time.Minute))
+	}
+	// Just now: 8 good, 1 slow and 1 failed
+	for i := 0; i < 8; i++ {
+		reg.observe(http.MethodGet, "/accounts/:id/balance", http.StatusOK, 10*time.Millisecond, now)
+	}
+	reg.observe(http.MethodGet, "/accounts/:id/balance", http.StatusOK, 80*time.Millisecond, now)
+	reg.observe(http.MethodGet, "/accounts/:id/balance", http.StatusServiceUnavailable, time.Millisecond, now)
+	// Client errors count as good; other methods are not covered
+	reg.observe(http.MethodGet, "/accounts/:id/balance", http.StatusNotFound, time.Millisecond, now)
+	reg.observe(http.MethodDelete, "/accounts/:id/balance", http.StatusInternalServerError, time.Millisecond, now)
+
+	statuses := SLOStatuses("slo-burn", now)
+	require.Len(t, statuses, 2)
+	balance := statuses[0]
+	assert.Equal(t, uint64(21), balance.Requests)
+	assert.Equal(t, uint64(2), balance.Bad)
+	assert.InDelta(t, 19.0/21, balance.SLI, 1e-9)
+	// 2 of 11 recent requests were bad against a 10% budget
+	assert.InDelta(t, 2.0/11/0.1, balance.BurnRate5m, 1e-9)
+	assert.InDelta(t, 2.0/21/0.1, balance.BurnRate1h, 1e-9)
+	assert.InDelta(t, 1-2.0/21/0.1, balance.ErrorBudgetRemaining, 1e-9)
+
+	// Requests older than the window drop out
+	later := SLOStatuses("slo-burn", now.Add(61*time.Minute))
+	assert.Zero(t, later[0].Requests)
+	assert.Equal(t, 1.0, later[0].ErrorBudgetRemaining)
+
+	assert.Equal(t, uint64(0), statuses[1].Requests)
+	assert.Equal(t, 1000.0, statuses[1].ThresholdMS)
+}
+
+func TestPrometheusMiddleware_RecordsSLOs(t *testing.T) {
+	require.NoError(t, RegisterSLOs("slo-middleware", testSLOs...))
+	r := gin.New()
+	r.Use(PrometheusMiddleware("slo-middleware"))
+	r.GET("/accounts/:id/balance", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET(SLOStatusPath, SLOStatusHandler("slo-middleware"))
+	require.Error(t, CheckSLORoutes(testSLOs, r.Routes()))
+	require.NoError(t, CheckSLORoutes(testSLOs[:1], r.Routes()))
+
+	for _, path := range []string{"/accounts/1/balance", "/accounts/2/balance", "/unknown"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, SLOStatusPath, nil)
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Service string      `json:"service"`
+		SLOs    []SLOStatus `json:"slos"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "slo-middleware", body.Service)
+	assert.Equal(t, uint64(2), body.SLOs[0].Requests)
+	assert.Equal(t, uint64(0), body.SLOs[0].Bad)
+}
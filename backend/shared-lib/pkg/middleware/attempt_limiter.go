@@ -0,0 +1,367 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var attemptLockoutsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "attempt_lockouts_total",
+		Help: "Total number of keys locked out after too many failed attempts, by limiter",
+	},
+	[]string{"limiter"},
+)
+
+// ErrLockedOut is matched by the errors an AttemptLimiter returns for a
+// locked out key
+var ErrLockedOut = errors.New("too many failed attempts")
+
+// LockedOutError reports how long a key stays locked out
+type LockedOutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedOutError) Error() string {
+	return fmt.Sprintf("too many failed attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedOutError) Is(target error) bool {
+	return target == ErrLockedOut
+}
+
+// AttemptPolicy configures an AttemptLimiter
+type AttemptPolicy struct {
+	// MaxAttempts failures within Window lock the key out
+	MaxAttempts int
+	Window      time.Duration
+	// Lockout is how long the first lockout lasts; each further lockout
+	// doubles it, up to MaxLockout. A MaxLockout below Lockout keeps every
+	// lockout at Lockout.
+	Lockout    time.Duration
+	MaxLockout time.Duration
+	// LockoutMemory is how long a lockout still counts towards doubling the
+	// next one after it ends
+	LockoutMemory time.Duration
+}
+
+// LoginAttemptPolicy locks a login out for 15 minutes after 5 wrong
+// passwords in 10 minutes, doubling up to a day
+func LoginAttemptPolicy() AttemptPolicy {
+	return AttemptPolicy{MaxAttempts: 5, Window: 10 * time.Minute, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour, LockoutMemory: 24 * time.Hour}
+}
+
+// PINAttemptPolicy locks a card's PIN out for 15 minutes after 3 wrong PINs
+// in a day, doubling up to a day
+func PINAttemptPolicy() AttemptPolicy {
+	return AttemptPolicy{MaxAttempts: 3, Window: 24 * time.Hour, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour, LockoutMemory: 7 * 24 * time.Hour}
+}
+
+// OTPAttemptPolicy locks one-time code entry out for 15 minutes after 10
+// wrong codes in an hour, whichever codes they were for, doubling up to a day
+func OTPAttemptPolicy() AttemptPolicy {
+	return AttemptPolicy{MaxAttempts: 10, Window: time.Hour, Lockout: 15 * time.Minute, MaxLockout: 24 * time.Hour, LockoutMemory: 24 * time.Hour}
+}
+
+// lockoutFor is how long the nth lockout of a key lasts
+func (p AttemptPolicy) lockoutFor(n int) time.Duration {
+	lockout := p.Lockout
+	for i := 1; i < n && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if p.MaxLockout > p.Lockout && lockout > p.MaxLockout {
+		lockout = p.MaxLockout
+	}
+	return lockout
+}
+
+// AttemptState is what a store holds for a key
+type AttemptState struct {
+	// Failures counted in the current window; reset by a lockout
+	Failures int
+	// Lockouts still remembered for doubling the next one
+	Lockouts int
+	// LockedFor is how long the key stays locked out, zero if it is not
+	LockedFor time.Duration
+}
+
+// AttemptStore counts failed attempts per key
+type AttemptStore interface {
+	// Fail counts a failed attempt of key under policy and locks it out once
+	// it reaches policy.MaxAttempts. A locked out key is not counted.
+	Fail(ctx context.Context, key string, policy AttemptPolicy) (AttemptState, error)
+	State(ctx context.Context, key string) (AttemptState, error)
+	// Reset forgets the failures and lockouts of key
+	Reset(ctx context.Context, key string) error
+}
+
+// AttemptLimiter locks keys out after too many failed attempts, for longer
+// each time. Keys are arbitrary strings such as an email, a card ID or a
+// user ID (see AttemptKey); they are hashed before being stored. Limiters
+// sharing a store need different names.
+type AttemptLimiter struct {
+	name   string
+	store  AttemptStore
+	policy AttemptPolicy
+}
+
+// NewAttemptLimiter creates a limiter named for its metrics and keys, e.g.
+// "login", "card_pin" or "phone_otp"
+func NewAttemptLimiter(name string, store AttemptStore, policy AttemptPolicy) *AttemptLimiter {
+	return &AttemptLimiter{name: name, store: store, policy: policy}
+}
+
+// AttemptKey joins the parts of a key, e.g. AttemptKey(userID, cardID)
+func AttemptKey(parts ...string) string {
+	return strings.Join(parts, ":")
+}
+
+// Policy returns the policy the limiter applies
+func (l *AttemptLimiter) Policy() AttemptPolicy {
+	return l.policy
+}
+
+func (l *AttemptLimiter) storeKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return l.name + ":" + hex.EncodeToString(sum[:])
+}
+
+// Check returns a *LockedOutError if key is locked out
+func (l *AttemptLimiter) Check(ctx context.Context, key string) error {
+	state, err := l.store.State(ctx, l.storeKey(key))
+	if err != nil {
+		return fmt.Errorf("failed to read attempts: %w", err)
+	}
+	if state.LockedFor > 0 {
+		return &LockedOutError{RetryAfter: state.LockedFor}
+	}
+	return nil
+}
+
+// Fail records a failed attempt of key and returns how many attempts are
+// left before it is locked out. The error is a *LockedOutError once it is.
+func (l *AttemptLimiter) Fail(ctx context.Context, key string) (int, error) {
+	before, err := l.store.State(ctx, l.storeKey(key))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read attempts: %w", err)
+	}
+	if before.LockedFor > 0 {
+		return 0, &LockedOutError{RetryAfter: before.LockedFor}
+	}
+	state, err := l.store.Fail(ctx, l.storeKey(key), l.policy)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record attempt: %w", err)
+	}
+	if state.LockedFor > 0 {
+		attemptLockoutsTotal.WithLabelValues(l.name).Inc()
+		return 0, &LockedOutError{RetryAfter: state.LockedFor}
+	}
+	return l.policy.MaxAttempts - state.Failures, nil
+}
+
+// Succeed forgets the failures and lockouts of key after a successful attempt
+func (l *AttemptLimiter) Succeed(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, l.storeKey(key))
+}
+
+// State returns the failures and lockout of key
+func (l *AttemptLimiter) State(ctx context.Context, key string) (AttemptState, error) {
+	return l.store.State(ctx, l.storeKey(key))
+}
+
+// InMemoryAttemptStore is a single-instance attempt store (tests and local development)
+type InMemoryAttemptStore struct {
+	entries map[string]*attemptEntry
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+type attemptEntry struct {
+	failures       int
+	windowEnds     time.Time
+	lockouts       int
+	lockoutsExpire time.Time
+	lockedUntil    time.Time
+}
+
+// NewInMemoryAttemptStore creates a new in-memory attempt store
+func NewInMemoryAttemptStore() *InMemoryAttemptStore {
+	return &InMemoryAttemptStore{entries: make(map[string]*attemptEntry), now: time.Now}
+}
+
+func (s *InMemoryAttemptStore) Fail(_ context.Context, key string, policy AttemptPolicy) (AttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e := s.entries[key]
+	if e == nil {
+		e = &attemptEntry{}
+		s.entries[key] = e
+	}
+	if now.Before(e.lockedUntil) {
+		return e.state(now), nil
+	}
+	if !now.Before(e.windowEnds) {
+		e.failures = 0
+		e.windowEnds = now.Add(policy.Window)
+	}
+	if !now.Before(e.lockoutsExpire) {
+		e.lockouts = 0
+	}
+
+	e.failures++
+	if e.failures >= policy.MaxAttempts {
+		e.failures, e.windowEnds = 0, time.Time{}
+		e.lockouts++
+		lockout := policy.lockoutFor(e.lockouts)
+		e.lockedUntil = now.Add(lockout)
+		e.lockoutsExpire = e.lockedUntil.Add(policy.LockoutMemory)
+	}
+	return e.state(now), nil
+}
+
+func (s *InMemoryAttemptStore) State(_ context.Context, key string) (AttemptState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entries[key]
+	if e == nil {
+		return AttemptState{}, nil
+	}
+	return e.state(s.now()), nil
+}
+
+func (s *InMemoryAttemptStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Cleanup removes keys whose window, lockout and remembered lockouts have all ended
+func (s *InMemoryAttemptStore) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, e := range s.entries {
+		if !now.Before(e.windowEnds) && !now.Before(e.lockedUntil) && !now.Before(e.lockoutsExpire) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func (e *attemptEntry) state(now time.Time) AttemptState {
+	state := AttemptState{}
+	if now.Before(e.windowEnds) {
+		state.Failures = e.failures
+	}
+	if now.Before(e.lockoutsExpire) {
+		state.Lockouts = e.lockouts
+	}
+	if now.Before(e.lockedUntil) {
+		state.LockedFor = e.lockedUntil.Sub(now)
+	}
+	return state
+}
+
+// Evaler runs Lua scripts on a Redis server; *cache.RedisClient implements it
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// failScript counts a failure in KEYS[1] for the window ARGV[2] and, at
+// ARGV[1] failures, locks the key out in KEYS[3] for ARGV[3] doubled for
+// each lockout remembered in KEYS[2], up to ARGV[4]. Lockouts are remembered
+// for ARGV[5] after they end. Durations are in milliseconds.
+const failScript = `
+local locked = redis.call("PTTL", KEYS[3])
+if locked > 0 then
+	return {0, tonumber(redis.call("GET", KEYS[2]) or "0"), locked}
+end
+local failures = redis.call("INCR", KEYS[1])
+if failures == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if failures < tonumber(ARGV[1]) then
+	return {failures, tonumber(redis.call("GET", KEYS[2]) or "0"), 0}
+end
+redis.call("DEL", KEYS[1])
+local lockouts = redis.call("INCR", KEYS[2])
+local lockout = tonumber(ARGV[3])
+if tonumber(ARGV[4]) > lockout then
+	lockout = math.min(lockout * 2 ^ (lockouts - 1), tonumber(ARGV[4]))
+end
+lockout = math.floor(lockout)
+redis.call("SET", KEYS[3], "1", "PX", lockout)
+redis.call("PEXPIRE", KEYS[2], lockout + tonumber(ARGV[5]))
+return {0, lockouts, lockout}`
+
+const stateScript = `
+return {tonumber(redis.call("GET", KEYS[1]) or "0"), tonumber(redis.call("GET", KEYS[2]) or "0"), redis.call("PTTL", KEYS[3])}`
+
+const resetScript = `return redis.call("DEL", KEYS[1], KEYS[2], KEYS[3])`
+
+// RedisAttemptStore shares failed attempts across all instances of a service
+type RedisAttemptStore struct {
+	client Evaler
+	prefix string
+}
+
+// NewRedisAttemptStore creates a Redis-backed attempt store. The prefix keeps
+// attempts of different services apart, e.g. "attempts:identity-service:".
+func NewRedisAttemptStore(client Evaler, prefix string) *RedisAttemptStore {
+	return &RedisAttemptStore{client: client, prefix: prefix}
+}
+
+func (s *RedisAttemptStore) keys(key string) []string {
+	key = s.prefix + key
+	return []string{key + ":failures", key + ":lockouts", key + ":locked"}
+}
+
+func (s *RedisAttemptStore) Fail(ctx context.Context, key string, policy AttemptPolicy) (AttemptState, error) {
+	return s.eval(ctx, failScript, key, policy.MaxAttempts, policy.Window.Milliseconds(),
+		policy.Lockout.Milliseconds(), policy.MaxLockout.Milliseconds(), policy.LockoutMemory.Milliseconds())
+}
+
+func (s *RedisAttemptStore) State(ctx context.Context, key string) (AttemptState, error) {
+	return s.eval(ctx, stateScript, key)
+}
+
+func (s *RedisAttemptStore) Reset(ctx context.Context, key string) error {
+	_, err := s.client.Eval(ctx, resetScript, s.keys(key))
+	return err
+}
+
+func (s *RedisAttemptStore) eval(ctx context.Context, script, key string, args ...interface{}) (AttemptState, error) {
+	res, err := s.client.Eval(ctx, script, s.keys(key), args...)
+	if err != nil {
+		return AttemptState{}, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != 3 {
+		return AttemptState{}, fmt.Errorf("unexpected attempts script result %v", res)
+	}
+	var counts [3]int64
+	for i, v := range values {
+		if counts[i], ok = v.(int64); !ok {
+			return AttemptState{}, fmt.Errorf("unexpected attempts script result %v", res)
+		}
+	}
+	state := AttemptState{Failures: int(counts[0]), Lockouts: int(counts[1])}
+	if counts[2] > 0 {
+		state.LockedFor = time.Duration(counts[2]) * time.Millisecond
+	}
+	return state, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 // AuditEventType represents the type of audit event
@@ -24,12 +25,18 @@ const (
 	AuditEventMFAVerify      AuditEventType = "MFA_VERIFY"
 	AuditEventSessionCreate  AuditEventType = "SESSION_CREATE"
 	AuditEventSessionRevoke  AuditEventType = "SESSION_REVOKE"
+	AuditEventMagicLinkSent  AuditEventType = "MAGIC_LINK_SENT"
+	AuditEventProfileUpdate  AuditEventType = "PROFILE_UPDATED"
+	AuditEventPhoneVerify    AuditEventType = "PHONE_VERIFIED"
 
 	// Account events
 	AuditEventAccountCreate AuditEventType = "ACCOUNT_CREATE"
 	AuditEventAccountUpdate AuditEventType = "ACCOUNT_UPDATE"
 	AuditEventAccountClose  AuditEventType = "ACCOUNT_CLOSE"
 	AuditEventAccountView   AuditEventType = "ACCOUNT_VIEW"
+	// Freezes and legal holds placed on or lifted from an account
+	AuditEventAccountRestrict   AuditEventType = "ACCOUNT_RESTRICTED"
+	AuditEventAccountUnrestrict AuditEventType = "ACCOUNT_RESTRICTION_LIFTED"
 
 	// Money movement events
 	AuditEventTransferInit     AuditEventType = "TRANSFER_INITIATED"
@@ -38,13 +45,25 @@ const (
 	AuditEventPaymentInit      AuditEventType = "PAYMENT_INITIATED"
 	AuditEventPaymentComplete  AuditEventType = "PAYMENT_COMPLETED"
 	AuditEventPaymentFailed    AuditEventType = "PAYMENT_FAILED"
+	// Decisions of organization members on business payments held for approval
+	AuditEventPaymentApprove AuditEventType = "PAYMENT_APPROVED"
+	AuditEventPaymentReject  AuditEventType = "PAYMENT_REJECTED"
 
 	// Card events
-	AuditEventCardIssue     AuditEventType = "CARD_ISSUED"
-	AuditEventCardActivate  AuditEventType = "CARD_ACTIVATED"
-	AuditEventCardBlock     AuditEventType = "CARD_BLOCKED"
-	AuditEventCardUnblock   AuditEventType = "CARD_UNBLOCKED"
-	AuditEventCardPINChange AuditEventType = "CARD_PIN_CHANGED"
+	AuditEventCardIssue           AuditEventType = "CARD_ISSUED"
+	AuditEventCardActivate        AuditEventType = "CARD_ACTIVATED"
+	AuditEventCardBlock           AuditEventType = "CARD_BLOCKED"
+	AuditEventCardUnblock         AuditEventType = "CARD_UNBLOCKED"
+	AuditEventCardPINChange       AuditEventType = "CARD_PIN_CHANGED"
+	AuditEventCardTokenize        AuditEventType = "CARD_TOKENIZED"
+	AuditEventTokenRevoke         AuditEventType = "CARD_TOKEN_REVOKED"
+	AuditEventCardDisputeOpen     AuditEventType = "CARD_DISPUTE_OPENED"
+	AuditEventCardDisputeResolve  AuditEventType = "CARD_DISPUTE_RESOLVED"
+	AuditEventCardTravelNotice    AuditEventType = "CARD_TRAVEL_NOTICE_CREATED"
+	AuditEventCardControlsUpdate  AuditEventType = "CARD_CONTROLS_UPDATED"
+	AuditEventCardDelegateRequest AuditEventType = "CARD_DELEGATE_REQUESTED"
+	AuditEventCardDelegateApprove AuditEventType = "CARD_DELEGATE_APPROVED"
+	AuditEventCardDelegateDecline AuditEventType = "CARD_DELEGATE_DECLINED"
 
 	// Admin events
 	AuditEventAdminAction      AuditEventType = "ADMIN_ACTION"
@@ -52,6 +71,16 @@ const (
 	AuditEventRoleAssign       AuditEventType = "ROLE_ASSIGNED"
 	AuditEventRoleRevoke       AuditEventType = "ROLE_REVOKED"
 
+	// Consent events
+	AuditEventConsentGrant  AuditEventType = "CONSENT_GRANTED"
+	AuditEventConsentReject AuditEventType = "CONSENT_REJECTED"
+	AuditEventConsentRevoke AuditEventType = "CONSENT_REVOKED"
+
+	// Delegation events
+	AuditEventDelegationGrant  AuditEventType = "DELEGATION_GRANTED"
+	AuditEventDelegationRevoke AuditEventType = "DELEGATION_REVOKED"
+	AuditEventDelegatedAction  AuditEventType = "DELEGATED_ACTION"
+
 	// Security events
 	AuditEventSuspiciousActivity AuditEventType = "SUSPICIOUS_ACTIVITY"
 	AuditEventRateLimitExceeded  AuditEventType = "RATE_LIMIT_EXCEEDED"
@@ -104,16 +133,24 @@ type AuditEvent struct {
 	ServiceVersion string                 `json:"service_version,omitempty"`
 }
 
+// AuditSink persists audit events so they can be queried later, e.g. by support tooling
+type AuditSink interface {
+	Write(event *AuditEvent) error
+}
+
 // AuditLogger provides security audit logging
 type AuditLogger struct {
 	serviceName    string
 	serviceVersion string
+	sink           AuditSink
 }
 
 // AuditLoggerConfig holds configuration for the audit logger
 type AuditLoggerConfig struct {
 	ServiceName    string
 	ServiceVersion string
+	// Sink optionally receives every event in addition to the structured log
+	Sink AuditSink
 }
 
 // NewAuditLogger creates a new audit logger
@@ -129,6 +166,7 @@ func NewAuditLoggerWithConfig(config AuditLoggerConfig) *AuditLogger {
 	return &AuditLogger{
 		serviceName:    config.ServiceName,
 		serviceVersion: config.ServiceVersion,
+		sink:           config.Sink,
 	}
 }
 
@@ -157,6 +195,13 @@ func (a *AuditLogger) Log(event *AuditEvent) {
 		"ip", event.IP,
 		"data", string(data),
 	)
+
+	// A failing sink must not fail the request; the structured log above still has the event
+	if a.sink != nil {
+		if err := a.sink.Write(event); err != nil {
+			slog.Error("Failed to write audit event to sink", "event_id", event.EventID, "error", err)
+		}
+	}
 }
 
 // LogEvent creates and logs an audit event with specific type
@@ -166,9 +211,9 @@ func (a *AuditLogger) LogEvent(eventType AuditEventType, severity AuditSeverity,
 		EventID:        generateEventID(),
 		EventType:      eventType,
 		Severity:       severity,
-		RequestID:      c.GetString("requestID"),
-		UserID:         c.GetString(string(UserIDKey)),
-		Email:          c.GetString(string(EmailKey)),
+		RequestID:      GetRequestID(c),
+		UserID:         GetUserID(c),
+		Email:          GetEmail(c),
 		Action:         string(eventType),
 		Resource:       c.FullPath(),
 		Method:         c.Request.Method,
@@ -186,12 +231,7 @@ func (a *AuditLogger) LogEvent(eventType AuditEventType, severity AuditSeverity,
 }
 
 func generateEventID() string {
-	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	id := make([]byte, 24)
-	for i := range id {
-		id[i] = chars[i%len(chars)]
-	}
-	return string(id)
+	return uuid.NewString()
 }
 
 // AuditMiddleware logs all security-relevant actions
@@ -218,9 +258,9 @@ func AuditMiddleware(logger *AuditLogger, serviceName string) gin.HandlerFunc {
 			EventID:     generateEventID(),
 			EventType:   eventType,
 			Severity:    severity,
-			RequestID:   c.GetString("requestID"),
-			UserID:      c.GetString(string(UserIDKey)),
-			Email:       c.GetString(string(EmailKey)),
+			RequestID:   GetRequestID(c),
+			UserID:      GetUserID(c),
+			Email:       GetEmail(c),
 			Action:      string(eventType),
 			Resource:    c.FullPath(),
 			Method:      c.Request.Method,
@@ -259,6 +299,15 @@ func classifyEvent(method, path string, statusCode int) (AuditEventType, AuditSe
 	}
 
 	// Authentication events
+	if strings.Contains(pathLower, "/magic-link") {
+		if strings.HasSuffix(pathLower, "/verify") {
+			if statusCode >= 400 {
+				return AuditEventLoginFailed, AuditSeverityWarning
+			}
+			return AuditEventLogin, AuditSeverityInfo
+		}
+		return AuditEventMagicLinkSent, severity
+	}
 	if strings.Contains(pathLower, "/login") {
 		if statusCode >= 400 {
 			return AuditEventLoginFailed, AuditSeverityWarning
@@ -297,12 +346,24 @@ func classifyEvent(method, path string, statusCode int) (AuditEventType, AuditSe
 
 	// Card events
 	if strings.Contains(pathLower, "/cards") {
+		if strings.Contains(pathLower, "/tokens") {
+			if method == "POST" {
+				return AuditEventCardTokenize, AuditSeverityInfo
+			}
+			if method == "DELETE" {
+				return AuditEventTokenRevoke, AuditSeverityInfo
+			}
+			return AuditEventAPICall, severity
+		}
 		if strings.Contains(pathLower, "/block") || strings.Contains(pathLower, "/freeze") {
 			return AuditEventCardBlock, AuditSeverityWarning
 		}
 		if strings.Contains(pathLower, "/unblock") || strings.Contains(pathLower, "/unfreeze") {
 			return AuditEventCardUnblock, AuditSeverityInfo
 		}
+		if strings.Contains(pathLower, "/pin") {
+			return AuditEventCardPINChange, severity
+		}
 		if method == "POST" {
 			return AuditEventCardIssue, AuditSeverityInfo
 		}
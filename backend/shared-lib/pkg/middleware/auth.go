@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"log/slog"
+	"slices"
 	"strings"
 
 	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
@@ -13,9 +14,50 @@ import (
 type Claims struct {
 	UserID string `json:"user_id"`
 	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// Scope is the space-separated list of scopes granted to a service account token
+	Scope string `json:"scope,omitempty"`
+	// OrgID and OrgRole are set on tokens issued for acting within an organization
+	OrgID   string `json:"org_id,omitempty"`
+	OrgRole string `json:"org_role,omitempty"`
+	// ConsentID and AccountIDs are set on third-party tokens; the token may
+	// only read the listed accounts of the user who gave the consent
+	ConsentID  string   `json:"consent_id,omitempty"`
+	AccountIDs []string `json:"account_ids,omitempty"`
+	// DelegationID, DelegateID and PaymentLimit are set on delegate tokens,
+	// which act for the user who granted the delegation on the listed
+	// accounts. See RequireDelegation.
+	DelegationID string `json:"delegation_id,omitempty"`
+	DelegateID   string `json:"delegate_id,omitempty"`
+	PaymentLimit string `json:"payment_limit,omitempty"`
+	// AuthTime and AMR are set on step-up tokens: when the user last proved
+	// who they are, and how (RFC 8176 method names such as pwd). See RequireStepUp.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+	// TermsPending is set on user tokens issued while the user has not
+	// accepted the current mandatory terms. See RequireTermsAccepted.
+	TermsPending bool `json:"terms_pending,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// ServiceRole is the role of tokens issued to service accounts through the
+// client credentials flow
+const ServiceRole = "service"
+
+// ThirdPartyRole is the role of tokens issued to third-party clients under a
+// user's consent. JWTAuth rejects them; only ConsentAuth accepts them.
+const ThirdPartyRole = "third_party"
+
+// HasScope reports whether the token grants scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // ContextKey is the type for context keys
 type ContextKey string
 
@@ -30,11 +72,48 @@ const (
 
 // JWTAuthConfig holds configuration for the JWT middleware
 type JWTAuthConfig struct {
-	SecretKey    string
+	SecretKey string
+	// Keys, when set, replaces SecretKey: tokens signed with its current
+	// secret or one of its previous ones are accepted, so rotating the secret
+	// does not invalidate the tokens already issued
+	Keys         *JWTKeys
 	TokenLookup  string // "header:Authorization" or "cookie:token"
 	TokenPrefix  string // "Bearer "
 	SkipPaths    []string
 	ErrorHandler func(*gin.Context, error)
+	// AllowThirdParty accepts third-party consent tokens. Routes that enable it
+	// must check the consent with RequireConsentScope.
+	AllowThirdParty bool
+	// AllowDelegates accepts delegate tokens. Routes that enable it must
+	// check the delegation with RequireDelegation.
+	AllowDelegates bool
+	// Issuer, when set, is the iss claim tokens must carry
+	Issuer string
+	// Audiences, when set, are the audiences accepted: a token must name at
+	// least one of them in its aud claim. A service accepts its own name; a
+	// gateway that fronts several services can accept all of theirs.
+	Audiences []string
+}
+
+// AdminAudience is the audience of tokens for a service's admin routes. Only
+// admin tokens carry it, so a token issued to the frontend cannot be replayed
+// against admin endpoints.
+func AdminAudience(audience string) string {
+	return audience + ":admin"
+}
+
+// Admin returns the configuration for admin routes, which accept the admin
+// audience of each configured audience instead of the audience itself
+func (c JWTAuthConfig) Admin() JWTAuthConfig {
+	if len(c.Audiences) == 0 {
+		return c
+	}
+	admin := make([]string, len(c.Audiences))
+	for i, aud := range c.Audiences {
+		admin[i] = AdminAudience(aud)
+	}
+	c.Audiences = admin
+	return c
 }
 
 // DefaultJWTConfig returns a default JWT configuration
@@ -72,17 +151,26 @@ func JWTAuthWithConfig(config JWTAuthConfig) gin.HandlerFunc {
 		}
 
 		// Parse and validate token
-		claims, err := validateToken(tokenString, config.SecretKey)
+		claims, err := validateToken(tokenString, config)
 		if err != nil {
 			slog.Debug("Invalid token", "error", err.Error())
 			errors.RespondWithError(c, errors.ErrInvalidToken)
 			return
 		}
+		if claims.Role == ThirdPartyRole && !config.AllowThirdParty {
+			errors.RespondWithError(c, errors.ErrForbidden.WithMessage("third-party tokens are only accepted by open banking endpoints"))
+			return
+		}
+		if claims.Role == DelegateRole && !config.AllowDelegates {
+			errors.RespondWithError(c, errors.ErrForbidden.WithMessage("delegate tokens are only accepted by delegated access endpoints"))
+			return
+		}
 
 		// Set user info in context
-		c.Set(string(UserIDKey), claims.UserID)
-		c.Set(string(EmailKey), claims.Email)
-		c.Set(string(ClaimsKey), claims)
+		setContextValue(c, UserIDKey, claims.UserID)
+		setContextValue(c, EmailKey, claims.Email)
+		setContextValue(c, ClaimsKey, claims)
+		traceCaller(c, claims)
 
 		slog.Debug("Authenticated request", "user_id", claims.UserID, "path", c.Request.URL.Path)
 		c.Next()
@@ -121,25 +209,29 @@ func extractToken(c *gin.Context, config JWTAuthConfig) string {
 	return ""
 }
 
-// validateToken parses and validates a JWT token
-func validateToken(tokenString string, secretKey string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return []byte(secretKey), nil
-	})
-
+// validateToken parses and validates a JWT token, signed with any of the
+// configuration's secrets, including its issuer and audience when the
+// configuration sets them
+func validateToken(tokenString string, config JWTAuthConfig) (*Claims, error) {
+	var opts []jwt.ParserOption
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	token, err := parseSigned(tokenString, config, opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrSignatureInvalid
 	}
-
-	return nil, jwt.ErrSignatureInvalid
+	if len(config.Audiences) > 0 && !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(config.Audiences, aud)
+	}) {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
+	return claims, nil
 }
 
 // GetUserID retrieves the user ID from the context
@@ -172,17 +264,54 @@ func GetClaims(c *gin.Context) *Claims {
 	return nil
 }
 
+// RequireRole rejects requests whose token does not carry one of the given roles.
+// It must run after JWTAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			errors.RespondWithError(c, errors.ErrUnauthorized)
+			return
+		}
+		for _, role := range roles {
+			if claims.Role == role {
+				c.Next()
+				return
+			}
+		}
+		errors.RespondWithError(c, errors.ErrForbidden)
+	}
+}
+
+// RequireServiceScope rejects service account tokens that do not grant scope.
+// User tokens pass through, so routes shared by end users and internal callers
+// keep working. It must run after JWTAuth.
+func RequireServiceScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			errors.RespondWithError(c, errors.ErrUnauthorized)
+			return
+		}
+		if claims.Role == ServiceRole && !claims.HasScope(scope) {
+			errors.RespondWithError(c, errors.ErrForbidden.WithMessage("token is missing scope "+scope))
+			return
+		}
+		c.Next()
+	}
+}
+
 // OptionalAuth is similar to JWTAuth but doesn't reject unauthenticated requests
 func OptionalAuth(secretKey string) gin.HandlerFunc {
 	config := DefaultJWTConfig(secretKey)
 	return func(c *gin.Context) {
 		tokenString := extractToken(c, config)
 		if tokenString != "" {
-			claims, err := validateToken(tokenString, config.SecretKey)
-			if err == nil {
-				c.Set(string(UserIDKey), claims.UserID)
-				c.Set(string(EmailKey), claims.Email)
-				c.Set(string(ClaimsKey), claims)
+			claims, err := validateToken(tokenString, config)
+			if err == nil && claims.Role != ThirdPartyRole && claims.Role != DelegateRole {
+				setContextValue(c, UserIDKey, claims.UserID)
+				setContextValue(c, EmailKey, claims.Email)
+				setContextValue(c, ClaimsKey, claims)
 			}
 		}
 		c.Next()
@@ -0,0 +1,164 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/sync/singleflight"
+)
+
+var requestCoalescingTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "request_coalescing_total",
+		Help: "Total number of GET requests seen by the coalescing middleware",
+	},
+	[]string{"route", "result"}, // result: miss (ran the handler), hit (shared a response), bypass
+)
+
+// CoalescedHeader is set on responses that were shared from an identical in-flight request
+const CoalescedHeader = "X-Coalesced"
+
+// DefaultCoalesceMaxBodyBytes is the largest response that is shared between requests
+const DefaultCoalesceMaxBodyBytes = 1 << 20
+
+// CoalesceConfig configures request coalescing
+type CoalesceConfig struct {
+	// MaxBodyBytes caps the buffered response. Requests waiting on a larger
+	// response run the handler themselves.
+	MaxBodyBytes int
+}
+
+// CoalesceGETs returns middleware that coalesces identical concurrent GET requests
+// from the same user with the default configuration
+func CoalesceGETs() gin.HandlerFunc {
+	return CoalesceGETsWithConfig(CoalesceConfig{MaxBodyBytes: DefaultCoalesceMaxBodyBytes})
+}
+
+// CoalesceGETsWithConfig returns middleware that runs the handler once for identical
+// GET requests (same user, organization, token scope, path and query) that are in
+// flight at the same time, and gives every caller the same response. Only requests
+// that arrive while the first is still running share it; nothing is cached afterwards.
+//
+// It must run after JWTAuth, and after TenantScope where the routes use it;
+// anonymous requests are never coalesced. Apply it to the route groups that
+// serve read-only data.
+func CoalesceGETsWithConfig(cfg CoalesceConfig) gin.HandlerFunc {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultCoalesceMaxBodyBytes
+	}
+	var group singleflight.Group
+
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		userID := GetUserID(c)
+		if c.Request.Method != http.MethodGet || userID == "" {
+			requestCoalescingTotal.WithLabelValues(route, "bypass").Inc()
+			c.Next()
+			return
+		}
+
+		// Do runs the handler on the first caller's goroutine; later callers
+		// with the same key block until it returns and receive its response
+		key := coalesceKey(c, userID)
+		ran := false
+		val, _, _ := group.Do(key, func() (interface{}, error) {
+			ran = true
+			w := &capturingWriter{ResponseWriter: c.Writer, limit: cfg.MaxBodyBytes}
+			c.Writer = w
+			c.Next()
+			c.Writer = w.ResponseWriter
+			shared := w.response()
+			if _, encoded := c.Get(compressedEncodingKey); encoded {
+				// The captured body is the unencoded one; each waiter negotiates its own encoding
+				shared.header.Del("Content-Encoding")
+			}
+			return shared, nil
+		})
+
+		if ran {
+			requestCoalescingTotal.WithLabelValues(route, "miss").Inc()
+			return
+		}
+
+		shared := val.(*coalescedResponse)
+		if shared.truncated {
+			requestCoalescingTotal.WithLabelValues(route, "bypass").Inc()
+			c.Next()
+			return
+		}
+
+		requestCoalescingTotal.WithLabelValues(route, "hit").Inc()
+		header := c.Writer.Header()
+		for k, v := range shared.header {
+			header[k] = append([]string(nil), v...)
+		}
+		header.Set(CoalescedHeader, "true")
+		c.Writer.WriteHeader(shared.status)
+		_, _ = c.Writer.Write(shared.body)
+		c.Abort()
+	}
+}
+
+// coalesceKey identifies the requests that may share a response: the same
+// user acting for the same organization with the same token scope, for the
+// same path and query. The parts are joined with NUL, which none of them contain.
+func coalesceKey(c *gin.Context, userID string) string {
+	parts := []string{userID, GetOrgID(c)}
+	if claims := GetClaims(c); claims != nil {
+		parts = append(parts, claims.Role, claims.OrgRole, claims.Scope, claims.ConsentID,
+			strings.Join(claims.AccountIDs, ","), claims.DelegationID)
+	}
+	parts = append(parts, c.Request.URL.Path+"?"+c.Request.URL.RawQuery)
+	return strings.Join(parts, "\x00")
+}
+
+// coalescedResponse is a completed response shared with waiting requests
+type coalescedResponse struct {
+	status    int
+	header    http.Header
+	body      []byte
+	truncated bool
+}
+
+// capturingWriter passes the response through to the client and keeps a copy of it
+type capturingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *capturingWriter) capture(b []byte) {
+	if w.truncated {
+		return
+	}
+	if w.body.Len()+len(b) > w.limit {
+		w.truncated = true
+		w.body.Reset()
+		return
+	}
+	w.body.Write(b)
+}
+
+func (w *capturingWriter) response() *coalescedResponse {
+	return &coalescedResponse{
+		status:    w.Status(),
+		header:    w.Header().Clone(),
+		body:      bytes.Clone(w.body.Bytes()),
+		truncated: w.truncated,
+	}
+}
@@ -0,0 +1,324 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCompressMinSize is the smallest response body that is compressed.
+// Below this the encoding overhead outweighs the saving.
+const DefaultCompressMinSize = 1024
+
+// DefaultCompressibleTypes are the response content types compressed by default.
+// Already compressed formats (images, PDFs, archives) and event streams are left alone.
+var DefaultCompressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/xml",
+	"text/csv",
+	"text/html",
+	"text/plain",
+	"text/xml",
+}
+
+// compressedEncodingKey is set on the gin context to the encoding applied by
+// CompressWithConfig, so body-capturing middleware can tell the captured
+// (unencoded) body apart from the encoded one on the wire
+const compressedEncodingKey = "compress_encoding"
+
+// ContentEncoding is a response encoding the compression middleware can apply
+type ContentEncoding struct {
+	// Name is the Accept-Encoding / Content-Encoding token, e.g. "gzip"
+	Name string
+	// NewWriter wraps w in an encoder. Close must flush the encoded stream.
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+// BrotliEncoding compresses responses with brotli ("br") at the given quality,
+// from brotli.BestSpeed (0) to brotli.BestCompression (11)
+func BrotliEncoding(quality int) ContentEncoding {
+	return ContentEncoding{Name: "br", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, quality), nil
+	}}
+}
+
+// GzipEncoding compresses responses with gzip at the given level
+func GzipEncoding(level int) ContentEncoding {
+	return ContentEncoding{Name: "gzip", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	}}
+}
+
+// DeflateEncoding compresses responses at the given level in the zlib format,
+// which is what the HTTP "deflate" coding means
+func DeflateEncoding(level int) ContentEncoding {
+	return ContentEncoding{Name: "deflate", NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriterLevel(w, level)
+	}}
+}
+
+// CompressConfig configures response compression
+type CompressConfig struct {
+	// MinSize is the smallest body, in bytes, that is compressed
+	MinSize int
+	// ContentTypes lists the media types that are compressed; parameters such as
+	// charset are ignored when matching
+	ContentTypes []string
+	// Encodings in order of server preference. When the client weights several
+	// equally, the first one listed wins.
+	Encodings []ContentEncoding
+}
+
+// DefaultCompressConfig compresses JSON, XML, CSV and text responses of 1 KiB or
+// more with brotli, falling back to gzip and then deflate
+func DefaultCompressConfig() CompressConfig {
+	return CompressConfig{
+		MinSize:      DefaultCompressMinSize,
+		ContentTypes: DefaultCompressibleTypes,
+		Encodings: []ContentEncoding{
+			BrotliEncoding(brotli.DefaultCompression),
+			GzipEncoding(gzip.DefaultCompression),
+			DeflateEncoding(flate.DefaultCompression),
+		},
+	}
+}
+
+// Compress returns response compression middleware with the default configuration
+func Compress() gin.HandlerFunc {
+	return CompressWithConfig(DefaultCompressConfig())
+}
+
+// CompressWithConfig returns middleware that compresses responses with the
+// encoding the client prefers in its Accept-Encoding header. Bodies are held
+// back until MinSize bytes have been written, so small responses go out
+// unencoded and the decision is made once the content type is known.
+//
+// Register it before middleware that captures response bodies (coalescing,
+// idempotency, signing) so they see the uncompressed body.
+func CompressWithConfig(cfg CompressConfig) gin.HandlerFunc {
+	if cfg.MinSize <= 0 {
+		cfg.MinSize = DefaultCompressMinSize
+	}
+	if len(cfg.ContentTypes) == 0 {
+		cfg.ContentTypes = DefaultCompressibleTypes
+	}
+	if len(cfg.Encodings) == 0 {
+		cfg.Encodings = DefaultCompressConfig().Encodings
+	}
+	names := make([]string, len(cfg.Encodings))
+	for i, e := range cfg.Encodings {
+		names[i] = e.Name
+	}
+
+	return func(c *gin.Context) {
+		// The response depends on Accept-Encoding whether or not this one is compressed
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		name := NegotiateEncoding(c.GetHeader("Accept-Encoding"), names)
+		if name == "" {
+			c.Next()
+			return
+		}
+
+		var encoding ContentEncoding
+		for _, e := range cfg.Encodings {
+			if e.Name == name {
+				encoding = e
+				break
+			}
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, ctx: c, cfg: &cfg, encoding: encoding}
+		c.Writer = w
+		defer func() {
+			w.finish()
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// NegotiateEncoding picks the content coding to use from an Accept-Encoding
+// header value (RFC 9110 section 12.5.3). It returns the supported coding with
+// the highest weight, preferring earlier entries in supported on ties, or ""
+// when the response should not be encoded.
+func NegotiateEncoding(acceptEncoding string, supported []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || parsed < 0 || parsed > 1 {
+				parsed = 0
+			}
+			q = parsed
+		}
+		if coding == "*" {
+			wildcard = q
+			continue
+		}
+		weights[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range supported {
+		q, listed := weights[name]
+		if !listed {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it, then streams the rest through the encoder or straight through
+type compressWriter struct {
+	gin.ResponseWriter
+	ctx      *gin.Context
+	cfg      *CompressConfig
+	encoding ContentEncoding
+
+	buf     bytes.Buffer
+	decided bool
+	encoder io.WriteCloser
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.cfg.MinSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow sends the headers early, which happens for body-less responses
+// such as AbortWithStatus; those are never compressed
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// Written reports whether anything has been written, including buffered bytes
+func (w *compressWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush sends buffered data to the client. A streaming handler that flushes
+// early is compressed if its content type allows, whatever the size so far.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide chooses whether the response is compressed and writes out the buffer
+func (w *compressWriter) decide(allowCompression bool) error {
+	w.decided = true
+	if allowCompression && w.compressible() {
+		encoder, err := w.encoding.NewWriter(w.ResponseWriter)
+		if err != nil {
+			return err
+		}
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding.Name)
+		// The length of the encoded body is not known up front
+		header.Del("Content-Length")
+		w.encoder = encoder
+		w.ctx.Set(compressedEncodingKey, w.encoding.Name)
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
+
+// compressible checks the response itself: status, existing encoding and content type
+func (w *compressWriter) compressible() bool {
+	status := w.Status()
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < http.StatusOK {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := strings.Cut(header.Get("Content-Type"), ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	for _, t := range w.cfg.ContentTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes out a response that stayed under MinSize and closes the encoder
+func (w *compressWriter) finish() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+	}
+}
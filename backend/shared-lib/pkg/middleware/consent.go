@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"slices"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// ConsentAuth authenticates open banking endpoints. Unlike JWTAuth it accepts
+// third-party tokens, so every route behind it must use RequireConsentScope.
+func ConsentAuth(secretKey string) gin.HandlerFunc {
+	return ConsentAuthWithConfig(DefaultJWTConfig(secretKey))
+}
+
+// ConsentAuthWithConfig is ConsentAuth with the issuer and audiences of config
+func ConsentAuthWithConfig(config JWTAuthConfig) gin.HandlerFunc {
+	config.AllowThirdParty = true
+	return JWTAuthWithConfig(config)
+}
+
+// RequireConsentScope rejects tokens that are not third-party tokens granting
+// scope. The user ID in the context is the user who gave the consent; handlers
+// must also check ConsentAllowsAccount. It must run after ConsentAuth.
+func RequireConsentScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			errors.RespondWithError(c, errors.ErrUnauthorized)
+			return
+		}
+		if claims.Role != ThirdPartyRole || claims.ConsentID == "" {
+			errors.RespondWithError(c, errors.ErrForbidden.WithMessage("a third-party consent token is required"))
+			return
+		}
+		if !claims.HasScope(scope) {
+			errors.RespondWithError(c, errors.ErrForbidden.WithMessage("consent does not grant scope "+scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ConsentAllowsAccount reports whether the request's consent covers the account
+func ConsentAllowsAccount(c *gin.Context, accountID string) bool {
+	claims := GetClaims(c)
+	return claims != nil && claims.Role == ThirdPartyRole && slices.Contains(claims.AccountIDs, accountID)
+}
+
+// GetConsentID returns the consent a third-party request acts under, or ""
+func GetConsentID(c *gin.Context) string {
+	if claims := GetClaims(c); claims != nil && claims.Role == ThirdPartyRole {
+		return claims.ConsentID
+	}
+	return ""
+}
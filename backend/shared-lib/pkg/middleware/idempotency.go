@@ -10,9 +10,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/validation"
 	"github.com/gin-gonic/gin"
 )
 
+// IdempotencyKeyHeader is the default header carrying the idempotency key
+const IdempotencyKeyHeader = "X-Idempotency-Key"
+
 // IdempotencyConfig holds configuration for idempotency
 type IdempotencyConfig struct {
 	// Header name for the idempotency key
@@ -28,7 +32,7 @@ type IdempotencyConfig struct {
 // DefaultIdempotencyConfig returns default configuration
 func DefaultIdempotencyConfig() IdempotencyConfig {
 	return IdempotencyConfig{
-		HeaderName: "X-Idempotency-Key",
+		HeaderName: IdempotencyKeyHeader,
 		TTL:        24 * time.Hour,
 		RequiredPaths: []string{
 			"/api/v1/transfer",
@@ -161,6 +165,11 @@ func Idempotency(store IdempotencyStore, config IdempotencyConfig) gin.HandlerFu
 			return
 		}
 
+		if idempotencyKey != "" && !validation.IdempotencyKey(idempotencyKey) {
+			validation.RespondWithFields(c, validation.Invalid(config.HeaderName, validation.RuleIdempotencyKey))
+			return
+		}
+
 		// If no idempotency key and not required, continue normally
 		if idempotencyKey == "" && !isRequired {
 			c.Next()
@@ -173,8 +182,10 @@ func Idempotency(store IdempotencyStore, config IdempotencyConfig) gin.HandlerFu
 			return
 		}
 
+		setContextValue(c, IdempotencyKeyKey, idempotencyKey)
+
 		// Get user ID for key scoping
-		userID := c.GetString(string(UserIDKey))
+		userID := GetUserID(c)
 		scopedKey := fmt.Sprintf("%s:%s", userID, idempotencyKey)
 
 		// Calculate request hash to detect conflicting requests
@@ -215,6 +226,10 @@ func Idempotency(store IdempotencyStore, config IdempotencyConfig) gin.HandlerFu
 					headers[k] = v[0]
 				}
 			}
+			if _, encoded := c.Get(compressedEncodingKey); encoded {
+				// The recorded body is the unencoded one; replays negotiate their own encoding
+				delete(headers, "Content-Encoding")
+			}
 
 			record := &IdempotencyRecord{
 				Key:             scopedKey,
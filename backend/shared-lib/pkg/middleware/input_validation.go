@@ -168,41 +168,3 @@ func ValidateUUID(uuid string) bool {
 	return uuidRegex.MatchString(uuid)
 }
 
-// ValidatePassword checks password strength
-func ValidatePassword(password string) (bool, string) {
-	if len(password) < 8 {
-		return false, "Password must be at least 8 characters"
-	}
-	if len(password) > 128 {
-		return false, "Password must not exceed 128 characters"
-	}
-
-	var hasUpper, hasLower, hasDigit, hasSpecial bool
-	for _, r := range password {
-		switch {
-		case unicode.IsUpper(r):
-			hasUpper = true
-		case unicode.IsLower(r):
-			hasLower = true
-		case unicode.IsDigit(r):
-			hasDigit = true
-		case unicode.IsPunct(r) || unicode.IsSymbol(r):
-			hasSpecial = true
-		}
-	}
-
-	if !hasUpper {
-		return false, "Password must contain at least one uppercase letter"
-	}
-	if !hasLower {
-		return false, "Password must contain at least one lowercase letter"
-	}
-	if !hasDigit {
-		return false, "Password must contain at least one digit"
-	}
-	if !hasSpecial {
-		return false, "Password must contain at least one special character"
-	}
-
-	return true, ""
-}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var jwtVerificationsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "jwt_verifications_total",
+		Help: "Total number of token signatures verified, by whether the current or a previous secret verified them",
+	},
+	[]string{"key"},
+)
+
+// Labels of jwt_verifications_total. Once no token is verified by a previous
+// secret any more, the previous secrets can be dropped.
+const (
+	JWTKeyCurrent  = "current"
+	JWTKeyPrevious = "previous"
+)
+
+// MaxPreviousJWTSecrets is how many previous secrets Rotate keeps
+const MaxPreviousJWTSecrets = 3
+
+// JWTKeys holds the secrets tokens are verified with: the current secret,
+// which new tokens are signed with, and the previous ones, which tokens issued
+// before the last rotation were signed with. It is safe for concurrent use and
+// can be updated while requests are being verified, so JWT_SECRET can be
+// rotated without signing everyone out.
+type JWTKeys struct {
+	mu       sync.RWMutex
+	current  string
+	previous []string
+}
+
+// NewJWTKeys returns the keys with the current secret and any previous ones
+func NewJWTKeys(current string, previous ...string) *JWTKeys {
+	k := &JWTKeys{}
+	k.Set(current, previous...)
+	return k
+}
+
+// Set replaces the secrets. Previous secrets that are blank or repeat another
+// secret are dropped.
+func (k *JWTKeys) Set(current string, previous ...string) {
+	var kept []string
+	for _, secret := range previous {
+		if secret != "" && secret != current && !slices.Contains(kept, secret) {
+			kept = append(kept, secret)
+		}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.current = current
+	k.previous = kept
+}
+
+// Rotate makes secret the current secret and the old current secret the most
+// recent previous one, keeping at most MaxPreviousJWTSecrets previous
+// secrets. It reports whether the current secret changed.
+func (k *JWTKeys) Rotate(secret string) bool {
+	current, previous := k.Secrets()
+	if secret == current {
+		return false
+	}
+	previous = append([]string{current}, previous...)
+	if len(previous) > MaxPreviousJWTSecrets {
+		previous = previous[:MaxPreviousJWTSecrets]
+	}
+	k.Set(secret, previous...)
+	return true
+}
+
+// Secrets returns the current secret and the previous ones, most recent first
+func (k *JWTKeys) Secrets() (current string, previous []string) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current, slices.Clone(k.previous)
+}
+
+// verificationSecrets are the secrets tokens are checked against, current
+// first: Keys when set, otherwise SecretKey alone
+func (c JWTAuthConfig) verificationSecrets() []string {
+	if c.Keys == nil {
+		return []string{c.SecretKey}
+	}
+	current, previous := c.Keys.Secrets()
+	return append([]string{current}, previous...)
+}
+
+// parseSigned parses a token and checks its signature against each of the
+// configuration's secrets in turn, recording which one verified it. Only a
+// bad signature moves on to the next secret; a token that is expired or
+// malformed is refused whichever secret signed it.
+func parseSigned(tokenString string, config JWTAuthConfig, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	var err error
+	for i, secret := range config.verificationSecrets() {
+		var token *jwt.Token
+		token, err = jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+			// Validate signing method
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return []byte(secret), nil
+		}, opts...)
+		if errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			continue
+		}
+		if err == nil {
+			jwtVerificationsTotal.WithLabelValues(jwtKeyLabel(i)).Inc()
+		}
+		return token, err
+	}
+	return nil, err
+}
+
+// jwtKeyLabel is the metric label of the i-th verification secret
+func jwtKeyLabel(i int) string {
+	if i == 0 {
+		return JWTKeyCurrent
+	}
+	return JWTKeyPrevious
+}
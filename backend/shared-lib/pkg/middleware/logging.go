@@ -2,14 +2,20 @@ package middleware
 
 import (
 	"log/slog"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-// RequestIDHeader is the header name for request ID
-const RequestIDHeader = "X-Request-ID"
+const (
+	// RequestIDHeader is the header name for request ID
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the context key for the request ID
+	RequestIDKey ContextKey = "request_id"
+)
 
 // RequestLogger returns a request logging middleware
 func RequestLogger(serviceName string) gin.HandlerFunc {
@@ -19,13 +25,13 @@ func RequestLogger(serviceName string) gin.HandlerFunc {
 		if requestID == "" {
 			requestID = uuid.New().String()
 		}
-		c.Set("request_id", requestID)
+		setContextValue(c, RequestIDKey, requestID)
 		c.Header(RequestIDHeader, requestID)
 
 		// Start timer
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactQueryToken(c.Request.URL.RawQuery)
 
 		// Log request start
 		slog.Info("Request started",
@@ -82,9 +88,23 @@ func RequestLogger(serviceName string) gin.HandlerFunc {
 	}
 }
 
+// redactQueryToken hides a token passed in the query string, as WebSocket
+// clients do
+func redactQueryToken(rawQuery string) string {
+	if !strings.Contains(rawQuery, WebSocketTokenParam+"=") {
+		return rawQuery
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return ""
+	}
+	values.Set(WebSocketTokenParam, "REDACTED")
+	return values.Encode()
+}
+
 // GetRequestID retrieves the request ID from the context
 func GetRequestID(c *gin.Context) string {
-	if requestID, exists := c.Get("request_id"); exists {
+	if requestID, exists := c.Get(string(RequestIDKey)); exists {
 		if id, ok := requestID.(string); ok {
 			return id
 		}
@@ -1,13 +1,25 @@
 package middleware
 
 import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/andybalholm/brotli"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -159,6 +171,94 @@ func TestJWTAuth_SkipsConfiguredPaths(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestJWTAuth_IssuerAndAudience(t *testing.T) {
+	sign := func(iss string, aud ...string) string {
+		claims := &Claims{UserID: "u1", Role: "customer", RegisteredClaims: jwt.RegisteredClaims{Issuer: iss, Audience: aud}}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return signed
+	}
+	config := DefaultJWTConfig("secret")
+	config.Issuer = "neobank"
+	config.Audiences = []string{"ledger-service"}
+	gateway := config
+	gateway.Audiences = []string{"ledger-service", "payment-service"}
+
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/v1/accounts", JWTAuthWithConfig(config), ok)
+	r.GET("/api/v1/admin/accounts", JWTAuthWithConfig(config.Admin()), ok)
+	r.GET("/gateway", JWTAuthWithConfig(gateway), ok)
+	serve := func(path, token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/api/v1/accounts", sign("neobank", "card-service", "ledger-service")))
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/accounts", sign("neobank", "card-service")), "another service's token")
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/accounts", sign("neobank")), "no audience")
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/accounts", sign("elsewhere", "ledger-service")), "wrong issuer")
+
+	// A token for the frontend is not an admin token
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/admin/accounts", sign("neobank", "ledger-service")))
+	assert.Equal(t, http.StatusOK, serve("/api/v1/admin/accounts", sign("neobank", AdminAudience("ledger-service"))))
+	assert.Equal(t, http.StatusUnauthorized, serve("/api/v1/accounts", sign("neobank", AdminAudience("ledger-service"))))
+
+	// The gateway accepts a token for any of the services it fronts
+	assert.Equal(t, http.StatusOK, serve("/gateway", sign("neobank", "payment-service")))
+	assert.Equal(t, http.StatusUnauthorized, serve("/gateway", sign("neobank", "card-service")))
+}
+
+func TestJWTAuth_RotatedSecrets(t *testing.T) {
+	sign := func(secret string, expiresIn time.Duration) string {
+		claims := &Claims{UserID: "u1", Role: "customer", RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn))}}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return signed
+	}
+	keys := NewJWTKeys("first")
+	config := DefaultJWTConfig("ignored")
+	config.Keys = keys
+
+	r := gin.New()
+	r.GET("/api/v1/accounts", JWTAuthWithConfig(config), func(c *gin.Context) { c.Status(http.StatusOK) })
+	serve := func(token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/accounts", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	issuedBefore := sign("first", time.Hour)
+	assert.Equal(t, http.StatusOK, serve(issuedBefore))
+	assert.Equal(t, http.StatusUnauthorized, serve(sign("ignored", time.Hour)), "SecretKey is replaced by Keys")
+
+	// Tokens issued before the rotation keep working alongside new ones
+	assert.True(t, keys.Rotate("second"))
+	assert.False(t, keys.Rotate("second"))
+	assert.Equal(t, http.StatusOK, serve(issuedBefore))
+	assert.Equal(t, http.StatusOK, serve(sign("second", time.Hour)))
+	assert.Equal(t, http.StatusUnauthorized, serve(sign("first", -time.Minute)), "an expired token is refused whichever secret signed it")
+	assert.Equal(t, http.StatusUnauthorized, serve(sign("unknown", time.Hour)))
+
+	// Only the most recent previous secrets are kept
+	keys.Rotate("third")
+	keys.Rotate("fourth")
+	keys.Rotate("fifth")
+	current, previous := keys.Secrets()
+	assert.Equal(t, "fifth", current)
+	assert.Equal(t, []string{"fourth", "third", "second"}, previous)
+	assert.Equal(t, http.StatusUnauthorized, serve(issuedBefore))
+
+	keys.Set("fifth", "", "fifth", "fourth", "fourth")
+	_, previous = keys.Secrets()
+	assert.Equal(t, []string{"fourth"}, previous)
+}
+
 func TestGetUserID_ReturnsEmptyWhenNotSet(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
@@ -176,6 +276,303 @@ func TestGetUserID_ReturnsValueWhenSet(t *testing.T) {
 	assert.Equal(t, "user-123", userID)
 }
 
+func TestRequireRole(t *testing.T) {
+	serve := func(claims *Claims) int {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if claims != nil {
+				c.Set(string(ClaimsKey), claims)
+			}
+			c.Next()
+		})
+		r.Use(RequireRole("admin"))
+		r.GET("/admin", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/admin", nil)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(nil))
+	assert.Equal(t, http.StatusForbidden, serve(&Claims{UserID: "u1", Role: "customer"}))
+	assert.Equal(t, http.StatusOK, serve(&Claims{UserID: "u1", Role: "admin"}))
+}
+
+func TestRequireServiceScope(t *testing.T) {
+	serve := func(claims *Claims) int {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if claims != nil {
+				c.Set(string(ClaimsKey), claims)
+			}
+			c.Next()
+		})
+		r.Use(RequireServiceScope("ledger:write"))
+		r.POST("/transactions", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/transactions", nil)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(nil))
+	assert.Equal(t, http.StatusOK, serve(&Claims{UserID: "u1", Role: "customer"}))
+	assert.Equal(t, http.StatusForbidden, serve(&Claims{UserID: "svc", Role: ServiceRole, Scope: "ledger:read"}))
+	assert.Equal(t, http.StatusOK, serve(&Claims{UserID: "svc", Role: ServiceRole, Scope: "ledger:read ledger:write"}))
+}
+
+func TestRequireStepUp(t *testing.T) {
+	serve := func(claims *Claims) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if claims != nil {
+				c.Set(string(ClaimsKey), claims)
+			}
+			c.Next()
+		})
+		r.Use(RequireStepUp(DefaultStepUpMaxAge))
+		r.POST("/cards/1/pin", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/cards/1/pin", nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	authAt := func(ago time.Duration) *jwt.NumericDate { return jwt.NewNumericDate(time.Now().Add(-ago)) }
+
+	assert.Equal(t, http.StatusUnauthorized, serve(nil).Code)
+	assert.Equal(t, http.StatusOK, serve(&Claims{UserID: "u1", AuthTime: authAt(time.Minute), AMR: []string{"pwd"}}).Code)
+	assert.Equal(t, http.StatusOK, serve(&Claims{UserID: "svc", Role: ServiceRole}).Code, "service accounts act without a user")
+
+	for _, claims := range []*Claims{
+		{UserID: "u1", Role: "customer"},
+		{UserID: "u1", AuthTime: authAt(DefaultStepUpMaxAge + time.Second)},
+		{UserID: "u1", AuthTime: authAt(-time.Hour)},
+	} {
+		w := serve(claims)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "STEP_UP_REQUIRED")
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="insufficient_user_authentication"`)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "max_age=300")
+	}
+}
+
+func TestRequireTermsAccepted(t *testing.T) {
+	serve := func(claims *Claims, method, path string) *httptest.ResponseRecorder {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set(string(ClaimsKey), claims)
+			c.Next()
+		})
+		r.Use(RequireTermsAccepted("/api/v1/terms"))
+		r.Handle(method, path, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	pending := &Claims{UserID: "u1", TermsPending: true}
+
+	assert.Equal(t, http.StatusOK, serve(&Claims{UserID: "u1"}, http.MethodPost, "/api/v1/transfers").Code)
+	assert.Equal(t, http.StatusOK, serve(pending, http.MethodGet, "/api/v1/accounts").Code, "reads stay available")
+	assert.Equal(t, http.StatusOK, serve(pending, http.MethodPost, "/api/v1/terms/accept").Code, "acceptance is exempt")
+
+	w := serve(pending, http.MethodPost, "/api/v1/transfers")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "TERMS_ACCEPTANCE_REQUIRED")
+}
+
+func TestConsentScope(t *testing.T) {
+	sign := func(claims *Claims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return signed
+	}
+	r := gin.New()
+	ok := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"allowed": ConsentAllowsAccount(c, "acc-1")})
+	}
+	r.GET("/api/v1/accounts", JWTAuth("secret"), ok)
+	r.GET("/open-banking/accounts", ConsentAuth("secret"), RequireConsentScope("accounts:read"), ok)
+	serve := func(path string, claims *Claims) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+sign(claims))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	thirdParty := &Claims{UserID: "u1", Role: ThirdPartyRole, Scope: "accounts:read", ConsentID: "c1", AccountIDs: []string{"acc-1"}}
+	w := serve("/open-banking/accounts", thirdParty)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"allowed":true}`, w.Body.String())
+
+	// Consent tokens are not accepted as user tokens, and user tokens are not consents
+	assert.Equal(t, http.StatusForbidden, serve("/api/v1/accounts", thirdParty).Code)
+	assert.Equal(t, http.StatusForbidden, serve("/open-banking/accounts", &Claims{UserID: "u1", Role: "customer"}).Code)
+	assert.Equal(t, http.StatusForbidden, serve("/open-banking/accounts", &Claims{UserID: "u1", Role: ThirdPartyRole, Scope: "balances:read", ConsentID: "c1"}).Code)
+
+	w = serve("/open-banking/accounts", &Claims{UserID: "u1", Role: ThirdPartyRole, Scope: "accounts:read", ConsentID: "c1", AccountIDs: []string{"acc-2"}})
+	assert.JSONEq(t, `{"allowed":false}`, w.Body.String())
+}
+
+func TestDelegation(t *testing.T) {
+	sign := func(claims *Claims) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		require.NoError(t, err)
+		return signed
+	}
+	r := gin.New()
+	ok := func(c *gin.Context) {
+		limit, canPay := DelegationPaymentLimit(c)
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":  GetUserID(c),
+			"delegate": GetDelegateID(c),
+			"allowed":  DelegationAllowsAccount(c, "acc-1"),
+			"limit":    limit.String(),
+			"can_pay":  canPay,
+		})
+	}
+	r.GET("/api/v1/accounts", JWTAuth("secret"), ok)
+	r.GET("/api/v1/delegated/accounts", DelegationAuth("secret"), RequireDelegation(DelegationReadScope), ok)
+	r.POST("/api/v1/delegated/transfers", DelegationAuth("secret"), RequireDelegation(DelegationPayScope), ok)
+	serve := func(method, path string, claims *Claims) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+sign(claims))
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	reader := &Claims{UserID: "grantor", Role: DelegateRole, Scope: DelegationReadScope, DelegationID: "d1", DelegateID: "grantee", AccountIDs: []string{"acc-1"}}
+	w := serve(http.MethodGet, "/api/v1/delegated/accounts", reader)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":"grantor","delegate":"grantee","allowed":true,"limit":"0","can_pay":false}`, w.Body.String())
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/v1/delegated/transfers", reader).Code, "read-only delegations cannot pay")
+
+	payer := &Claims{UserID: "grantor", Role: DelegateRole, Scope: DelegationReadScope + " " + DelegationPayScope, DelegationID: "d1", DelegateID: "grantee", AccountIDs: []string{"acc-2"}, PaymentLimit: "250.00"}
+	w = serve(http.MethodPost, "/api/v1/delegated/transfers", payer)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":"grantor","delegate":"grantee","allowed":false,"limit":"250","can_pay":true}`, w.Body.String())
+
+	// Delegate tokens are not accepted as user tokens, and user or consent tokens are not delegations
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/accounts", reader).Code)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/delegated/accounts", &Claims{UserID: "u1", Role: "customer", Scope: DelegationReadScope}).Code)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/api/v1/delegated/accounts", &Claims{UserID: "u1", Role: ThirdPartyRole, Scope: DelegationReadScope, ConsentID: "c1"}).Code)
+}
+
+func TestTenantScope(t *testing.T) {
+	orgID := "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"
+	serve := func(claims *Claims, roles ...string) (int, string) {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set(string(ClaimsKey), claims)
+			c.Next()
+		})
+		r.Use(TenantScope())
+		if len(roles) > 0 {
+			r.Use(RequireOrgRole(roles...))
+		}
+		var seen string
+		r.GET("/accounts", func(c *gin.Context) {
+			seen = tenant.OrgID(c.Request.Context())
+			c.JSON(http.StatusOK, gin.H{"org_id": GetOrgID(c)})
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/accounts", nil)
+		r.ServeHTTP(w, req)
+		return w.Code, seen
+	}
+
+	code, seen := serve(&Claims{UserID: "u1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, seen)
+
+	code, seen = serve(&Claims{UserID: "u1", OrgID: orgID, OrgRole: tenant.RoleMember})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, orgID, seen)
+
+	code, _ = serve(&Claims{UserID: "u1", OrgID: "not-a-uuid", OrgRole: tenant.RoleMember})
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _ = serve(&Claims{UserID: "u1"}, tenant.RoleOwner)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serve(&Claims{UserID: "u1", OrgID: orgID, OrgRole: tenant.RoleViewer}, tenant.RoleOwner, tenant.RoleAdmin)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = serve(&Claims{UserID: "u1", OrgID: orgID, OrgRole: tenant.RoleAdmin}, tenant.RoleOwner, tenant.RoleAdmin)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestGetRequestContext(t *testing.T) {
+	orgID := "6f1c2a9e-3b4d-4e5f-8a7b-9c0d1e2f3a4b"
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID: "u1", Email: "u1@example.com", Role: "admin", Scope: "accounts:read payments:write",
+		OrgID: orgID, OrgRole: tenant.RoleAdmin,
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	r := gin.New()
+	var before, seen *RequestContext
+	r.Use(RequestLogger("test"), func(c *gin.Context) {
+		// Read before authentication; the cache must not hide what JWTAuth adds
+		before = GetRequestContext(c)
+		c.Next()
+	}, JWTAuth("secret"), TenantScope())
+	r.POST("/transfers", func(c *gin.Context) {
+		seen = RequestContextFrom(RequestCtx(c))
+		c.Status(http.StatusNoContent)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/transfers", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set(IdempotencyKeyHeader, "idem-1")
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.False(t, before.IsAuthenticated())
+	require.NotNil(t, seen)
+	assert.True(t, seen.IsAuthenticated())
+	assert.Equal(t, "u1", seen.UserID)
+	assert.Equal(t, "u1@example.com", seen.Email)
+	assert.Equal(t, "req-1", seen.RequestID)
+	assert.Equal(t, "idem-1", seen.IdempotencyKey)
+	assert.True(t, seen.HasRole("user", "admin"))
+	assert.True(t, seen.HasScope("payments:write"))
+	assert.False(t, seen.HasScope("cards:write"))
+	assert.True(t, seen.ActsForOrg())
+	assert.Equal(t, tenant.RoleAdmin, seen.OrgRole)
+}
+
+func TestSetRequestContext(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest(http.MethodGet, "/accounts", nil)
+	SetRequestContext(c, &RequestContext{UserID: "u1", Role: "user", Scopes: []string{"accounts:read"}, RequestID: "req-1"})
+
+	// The getters and the claims agree with the fabricated context
+	assert.Equal(t, "u1", GetUserID(c))
+	assert.Equal(t, "req-1", GetRequestID(c))
+	require.NotNil(t, GetClaims(c))
+	assert.True(t, GetClaims(c).HasScope("accounts:read"))
+	assert.Equal(t, "u1", RequestContextFrom(c.Request.Context()).UserID)
+
+	// A context without one yields an unauthenticated RequestContext
+	assert.False(t, RequestContextFrom(context.Background()).IsAuthenticated())
+}
+
 func TestDefaultJWTConfig(t *testing.T) {
 	config := DefaultJWTConfig("my-secret")
 
@@ -203,3 +600,683 @@ func TestDefaultCORSConfig(t *testing.T) {
 	assert.Contains(t, config.AllowHeaders, "Authorization")
 	assert.True(t, config.AllowCredentials)
 }
+
+func TestRateLimitWithPolicies_AppliesEndpointPolicy(t *testing.T) {
+	r := gin.New()
+	r.Use(RateLimitWithPolicies(PolicyRateLimitConfig{
+		Default: RateLimitPolicy{RequestsPerMinute: 100, BurstSize: 100},
+		Policies: []RateLimitPolicy{
+			{Name: "login", Method: http.MethodPost, Path: "/auth/login", RequestsPerMinute: 2},
+		},
+		CleanupInterval: time.Minute,
+	}))
+	r.POST("/auth/login", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.GET("/auth/login", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "login", w.Header().Get("X-RateLimit-Policy"))
+		if i < 2 {
+			assert.Equal(t, http.StatusOK, w.Code, "Request %d should succeed", i)
+		} else {
+			assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		}
+	}
+
+	// Other methods on the same path fall back to the default policy
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/auth/login", nil)
+	req.RemoteAddr = "192.168.1.1:12345"
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, DefaultPolicyName, w.Header().Get("X-RateLimit-Policy"))
+}
+
+func TestRateLimitWithPolicies_SeparatesUserAndIPBuckets(t *testing.T) {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if userID := c.GetHeader("X-Test-User"); userID != "" {
+			c.Set(string(UserIDKey), userID)
+		}
+		c.Next()
+	})
+	r.Use(RateLimitWithPolicies(PolicyRateLimitConfig{
+		Default:         RateLimitPolicy{RequestsPerMinute: 1},
+		CleanupInterval: time.Minute,
+	}))
+	r.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	send := func(userID string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("X-Test-User", userID)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send(""))
+	assert.Equal(t, http.StatusTooManyRequests, send(""))
+	// Same IP, but authenticated users get their own bucket
+	assert.Equal(t, http.StatusOK, send("user-1"))
+	assert.Equal(t, http.StatusOK, send("user-2"))
+}
+
+func TestRateLimitWithPolicies_LimitsUsersBeforeAuthentication(t *testing.T) {
+	// As in the services: the limiter is global, authentication is per group
+	jwtAuth := DefaultJWTConfig("secret")
+	r := gin.New()
+	r.Use(RateLimitWithPolicies(PolicyRateLimitConfig{
+		Default:         RateLimitPolicy{RequestsPerMinute: 1},
+		CleanupInterval: time.Minute,
+		Auth:            []JWTAuthConfig{jwtAuth},
+	}))
+	api := r.Group("/api", JWTAuthWithConfig(jwtAuth))
+	api.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	send := func(token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/api/test", nil)
+		req.RemoteAddr = "10.0.0.1:12345"
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	user1, user2 := wsToken(t, "user-1", time.Minute), wsToken(t, "user-2", time.Minute)
+
+	// Two users behind one IP each have their own budget
+	assert.Equal(t, http.StatusOK, send(user1))
+	assert.Equal(t, http.StatusOK, send(user2))
+	assert.Equal(t, http.StatusTooManyRequests, send(user1))
+	assert.Equal(t, http.StatusTooManyRequests, send(user2))
+
+	// Tokens that do not verify share the IP's budget, whatever user they name
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: "user-3"}).SignedString([]byte("other-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, send(forged))
+	forged, err = jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: "user-4"}).SignedString([]byte("other-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, send(forged))
+}
+
+// coalescingRouter serves GET /data through CoalesceGETsWithConfig. The handler
+// blocks until release is closed so concurrent requests overlap. The test
+// headers stand in for JWTAuth and TenantScope.
+func coalescingRouter(cfg CoalesceConfig, body string, calls *int32, release chan struct{}) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(string(UserIDKey), user)
+			c.Set(string(ClaimsKey), &Claims{UserID: user, Scope: c.GetHeader("X-Test-Scope")})
+		}
+		if org := c.GetHeader("X-Test-Org"); org != "" {
+			c.Set(string(OrgIDKey), org)
+		}
+	})
+	r.Use(CoalesceGETsWithConfig(cfg))
+	handler := func(c *gin.Context) {
+		n := atomic.AddInt32(calls, 1)
+		<-release
+		c.Header("X-Call", string(rune('0'+n)))
+		c.String(http.StatusOK, body)
+	}
+	r.GET("/data", handler)
+	r.POST("/data", handler)
+	return r
+}
+
+// serveConcurrently starts one request per user header, waits for them to reach
+// the handler or the in-flight call, then releases the handler
+func serveConcurrently(r *gin.Engine, method string, users []string, release chan struct{}) []*httptest.ResponseRecorder {
+	requests := make([]*http.Request, len(users))
+	for i, user := range users {
+		requests[i] = httptest.NewRequest(method, "/data?page=1", nil)
+		if user != "" {
+			requests[i].Header.Set("X-Test-User", user)
+		}
+	}
+	return serveRequestsConcurrently(r, requests, release)
+}
+
+// serveRequestsConcurrently is serveConcurrently for prepared requests
+func serveRequestsConcurrently(r *gin.Engine, requests []*http.Request, release chan struct{}) []*httptest.ResponseRecorder {
+	recorders := make([]*httptest.ResponseRecorder, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		recorders[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(w *httptest.ResponseRecorder, req *http.Request) {
+			defer wg.Done()
+			r.ServeHTTP(w, req)
+		}(recorders[i], req)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	return recorders
+}
+
+func TestCoalesceGETs_SharesOneResponseBetweenIdenticalRequests(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	r := coalescingRouter(CoalesceConfig{}, `{"balance":"10.00"}`, &calls, release)
+
+	recorders := serveConcurrently(r, http.MethodGet, []string{"user-1", "user-1", "user-1", "user-1"}, release)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	coalesced := 0
+	for _, w := range recorders {
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"balance":"10.00"}`, w.Body.String())
+		assert.Equal(t, "1", w.Header().Get("X-Call"))
+		if w.Header().Get(CoalescedHeader) == "true" {
+			coalesced++
+		}
+	}
+	assert.Equal(t, 3, coalesced)
+}
+
+func TestCoalesceGETs_KeepsUsersSeparate(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	r := coalescingRouter(CoalesceConfig{}, "ok", &calls, release)
+
+	serveConcurrently(r, http.MethodGet, []string{"user-1", "user-2"}, release)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCoalesceGETs_KeepsOrganizationsAndScopesSeparate(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	r := coalescingRouter(CoalesceConfig{}, "ok", &calls, release)
+
+	request := func(org, scope string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/data?page=1", nil)
+		req.Header.Set("X-Test-User", "user-1")
+		req.Header.Set("X-Test-Org", org)
+		req.Header.Set("X-Test-Scope", scope)
+		return req
+	}
+	// The same user acting personally, for two organizations, and with a
+	// narrower scope each get their own response
+	serveRequestsConcurrently(r, []*http.Request{
+		request("", "ledger:read"),
+		request("org-a", "ledger:read"),
+		request("org-b", "ledger:read"),
+		request("org-a", "ledger:read ledger:write"),
+		request("org-a", "ledger:read"),
+	}, release)
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
+
+func TestCoalesceGETs_BypassesAnonymousAndNonGETRequests(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	r := coalescingRouter(CoalesceConfig{}, "ok", &calls, release)
+	serveConcurrently(r, http.MethodGet, []string{"", ""}, release)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	calls = 0
+	release = make(chan struct{})
+	r = coalescingRouter(CoalesceConfig{}, "ok", &calls, release)
+	serveConcurrently(r, http.MethodPost, []string{"user-1", "user-1"}, release)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCoalesceGETs_LargeResponsesAreNotShared(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	body := strings.Repeat("x", 64)
+	r := coalescingRouter(CoalesceConfig{MaxBodyBytes: 16}, body, &calls, release)
+
+	recorders := serveConcurrently(r, http.MethodGet, []string{"user-1", "user-1"}, release)
+
+	// The waiting request ran the handler itself once the first finished
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	for _, w := range recorders {
+		assert.Equal(t, body, w.Body.String())
+		assert.Empty(t, w.Header().Get(CoalescedHeader))
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"br", "gzip", "deflate"}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "br"},
+		{"deflate, gzip;q=0.5", "deflate"},
+		{"GZIP;Q=0.8, deflate;q=0.9", "deflate"},
+		{"br;q=0, gzip", "gzip"},
+		{"*", "br"},
+		{"*;q=0.5, gzip;q=0", "br"},
+		{"identity", ""},
+		{"gzip;q=0, *;q=0", ""},
+		{"zstd, compress", ""},
+		{"gzip;q=abc, deflate", "deflate"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NegotiateEncoding(tt.header, supported), tt.header)
+	}
+}
+
+func compressRouter(body, contentType string) *gin.Engine {
+	r := gin.New()
+	r.Use(CompressWithConfig(CompressConfig{MinSize: 256}))
+	r.GET("/data", func(c *gin.Context) {
+		c.Data(http.StatusOK, contentType, []byte(body))
+	})
+	r.GET("/empty", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func getWithEncoding(r *gin.Engine, path, acceptEncoding string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompress_EncodesLargeResponses(t *testing.T) {
+	body := `{"items":[` + strings.Repeat(`{"amount":"10.00","description":"Coffee"},`, 50) + `{}]}`
+	r := compressRouter(body, "application/json; charset=utf-8")
+
+	w := getWithEncoding(r, "/data", "gzip, deflate")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	assert.Less(t, w.Body.Len(), len(body))
+	zr, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	decoded, _ := io.ReadAll(zr)
+	assert.Equal(t, body, string(decoded))
+
+	w = getWithEncoding(r, "/data", "deflate")
+	assert.Equal(t, "deflate", w.Header().Get("Content-Encoding"))
+	fr, err := zlib.NewReader(w.Body)
+	assert.NoError(t, err)
+	decoded, _ = io.ReadAll(fr)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestCompress_PrefersBrotli(t *testing.T) {
+	body := `{"items":[` + strings.Repeat(`{"amount":"10.00","description":"Coffee"},`, 50) + `{}]}`
+	r := compressRouter(body, "application/json")
+
+	w := getWithEncoding(r, "/data", "gzip, deflate, br")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Less(t, w.Body.Len(), len(body))
+	decoded, err := io.ReadAll(brotli.NewReader(w.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+
+	// The client's weights come before the server's preference
+	w = getWithEncoding(r, "/data", "br;q=0.5, gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	w = getWithEncoding(r, "/data", "br;q=0, gzip;q=0.1")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	w = getWithEncoding(r, "/data", "*")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+}
+
+func TestCompress_LeavesResponsesAlone(t *testing.T) {
+	large := strings.Repeat("a", 1024)
+
+	// Client does not accept any supported encoding
+	w := getWithEncoding(compressRouter(large, "application/json"), "/data", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+
+	// Below the size threshold
+	w = getWithEncoding(compressRouter("small", "application/json"), "/data", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "small", w.Body.String())
+
+	// Content type outside the allowlist
+	w = getWithEncoding(compressRouter(large, "application/pdf"), "/data", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, large, w.Body.String())
+
+	// No body
+	w = getWithEncoding(compressRouter(large, "application/json"), "/empty", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestCompress_CoalescedResponsesAreEncodedPerRequest(t *testing.T) {
+	body := strings.Repeat(`{"amount":"10.00"},`, 100)
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(CompressWithConfig(CompressConfig{MinSize: 256}))
+	r.Use(func(c *gin.Context) {
+		c.Set(string(UserIDKey), "user-1")
+	})
+	r.Use(CoalesceGETs())
+	r.GET("/data", func(c *gin.Context) {
+		<-release
+		c.Data(http.StatusOK, "application/json", []byte(body))
+	})
+
+	encodings := []string{"gzip", ""}
+	recorders := make([]*httptest.ResponseRecorder, len(encodings))
+	var wg sync.WaitGroup
+	for i, enc := range encodings {
+		wg.Add(1)
+		go func(i int, enc string) {
+			defer wg.Done()
+			recorders[i] = getWithEncoding(r, "/data", enc)
+		}(i, enc)
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, w := range recorders {
+		if encodings[i] == "" {
+			assert.Empty(t, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, body, w.Body.String())
+			continue
+		}
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		zr, err := gzip.NewReader(w.Body)
+		assert.NoError(t, err)
+		decoded, _ := io.ReadAll(zr)
+		assert.Equal(t, body, string(decoded))
+	}
+}
+
+// timeoutRouter serves /slow, which waits for its context or for delay, with
+// a 50ms group deadline, and /report, which gets its own 500ms deadline
+func timeoutRouter(delay time.Duration) *gin.Engine {
+	r := gin.New()
+	slow := func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+		case <-time.After(delay):
+		}
+		c.Header("Content-Disposition", "attachment")
+		c.JSON(http.StatusOK, gin.H{"status": "done"})
+	}
+	g := r.Group("", Timeout(50*time.Millisecond))
+	g.GET("/slow", slow)
+	g.GET("/report", Timeout(500*time.Millisecond), slow)
+	return r
+}
+
+func TestTimeout_RespondsGatewayTimeout(t *testing.T) {
+	r := timeoutRouter(time.Second)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"), "headers of the discarded response are dropped")
+	var body struct {
+		Code    string         `json:"code"`
+		Details map[string]any `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "TIMEOUT", body.Code)
+	assert.Equal(t, float64(50), body.Details["timeout_ms"])
+}
+
+func TestTimeout_PassesFastResponses(t *testing.T) {
+	r := timeoutRouter(0)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"done"}`, w.Body.String())
+}
+
+func TestTimeout_RouteDeadlineReplacesGroupDeadline(t *testing.T) {
+	r := timeoutRouter(150 * time.Millisecond)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/report", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "the route's 500ms applies instead of the group's 50ms")
+
+	// The caller's remaining time can shorten the deadline but not extend it
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/report", nil)
+	req.Header.Set(RequestTimeoutHeader, "20")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set(RequestTimeoutHeader, "60000")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestPropagateDeadline(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(RequestTimeoutHeader)
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: PropagateDeadline(nil)}
+
+	// No deadline, no header
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Empty(t, received)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	remaining, ok := parseRequestTimeout(received)
+	require.True(t, ok)
+	assert.LessOrEqual(t, remaining, 2*time.Second)
+	assert.Greater(t, remaining, time.Second)
+	assert.Empty(t, req.Header.Get(RequestTimeoutHeader), "the caller's request is not modified")
+}
+
+type redactedOwner struct {
+	Email string `json:"email" redact:"support,third_party=email"`
+	Phone string `json:"phone,omitempty" redact:"support=phone,reveal=pii:read"`
+}
+
+type redactedCard struct {
+	ID          string        `json:"id"`
+	CardNumber  string        `json:"card_number" redact:"*=last4"`
+	Owner       redactedOwner `json:"owner"`
+	DailyLimit  float64       `json:"daily_limit"`
+	Attachments []struct {
+		Secret string `json:"secret" redact:"support"`
+	} `json:"attachments"`
+}
+
+func TestRedactResponses(t *testing.T) {
+	card := redactedCard{
+		ID:         "c1",
+		CardNumber: "4111 1111 1111 1234",
+		Owner:      redactedOwner{Email: "ada@example.com", Phone: "+447700900123"},
+		DailyLimit: 1000.25,
+	}
+	card.Attachments = append(card.Attachments, struct {
+		Secret string `json:"secret" redact:"support"`
+	}{Secret: "s"})
+
+	serve := func(claims *Claims) map[string]interface{} {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if claims != nil {
+				c.Set(string(ClaimsKey), claims)
+			}
+			c.Next()
+		})
+		r.Use(RedactResponses(redactedCard{}))
+		r.GET("/card", func(c *gin.Context) {
+			c.JSON(http.StatusOK, card)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/card", nil)
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	// Unauthenticated responses are left alone
+	body := serve(nil)
+	assert.Equal(t, "4111 1111 1111 1234", body["card_number"])
+
+	body = serve(&Claims{UserID: "u1", Role: "customer"})
+	assert.Equal(t, "************1234", body["card_number"])
+	assert.Equal(t, map[string]interface{}{"email": "ada@example.com", "phone": "+447700900123"}, body["owner"])
+	assert.Equal(t, 1000.25, body["daily_limit"])
+
+	body = serve(&Claims{UserID: "s1", Role: "support"})
+	assert.Equal(t, map[string]interface{}{"phone": "********0123"}, body["owner"])
+	assert.Equal(t, []interface{}{map[string]interface{}{}}, body["attachments"])
+
+	body = serve(&Claims{UserID: "s1", Role: "support", Scope: "pii:read"})
+	assert.Equal(t, map[string]interface{}{"phone": "+447700900123"}, body["owner"])
+
+	body = serve(&Claims{UserID: "t1", Role: ThirdPartyRole})
+	assert.Equal(t, "ad*@example.com", body["owner"].(map[string]interface{})["email"])
+}
+
+func TestRedactResponses_LeavesNonJSONResponses(t *testing.T) {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(string(ClaimsKey), &Claims{UserID: "s1", Role: "support"})
+		c.Next()
+	})
+	r.Use(RedactResponses(redactedOwner{}))
+	r.GET("/export", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/csv", []byte(`email,phone`))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/export", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "email,phone", w.Body.String())
+}
+
+func TestNewRedactionPolicy_RejectsInvalidTags(t *testing.T) {
+	assert.Panics(t, func() {
+		NewRedactionPolicy(struct {
+			Email string `json:"email" redact:"support=scramble"`
+		}{})
+	})
+	assert.Panics(t, func() {
+		NewRedactionPolicy(struct {
+			Email string `json:"email" redact:"reveal=pii:read"`
+		}{})
+	})
+}
+
+func TestAttemptLimiter_ExponentialLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	store := NewInMemoryAttemptStore()
+	store.now = func() time.Time { return now }
+	limiter := NewAttemptLimiter("login", store, AttemptPolicy{MaxAttempts: 3, Window: time.Minute, Lockout: time.Minute, MaxLockout: 3 * time.Minute, LockoutMemory: time.Hour})
+	failUntilLocked := func(key string) time.Duration {
+		for i := 2; i > 0; i-- {
+			remaining, err := limiter.Fail(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, i, remaining)
+		}
+		_, err := limiter.Fail(ctx, key)
+		var locked *LockedOutError
+		require.ErrorAs(t, err, &locked)
+		return locked.RetryAfter
+	}
+
+	assert.Equal(t, time.Minute, failUntilLocked("a@example.com"))
+	assert.ErrorIs(t, limiter.Check(ctx, "a@example.com"), ErrLockedOut)
+	assert.NoError(t, limiter.Check(ctx, "b@example.com"), "keys are limited apart")
+	_, err := limiter.Fail(ctx, "a@example.com")
+	assert.ErrorIs(t, err, ErrLockedOut, "a locked out key is not counted")
+
+	// Each further lockout doubles, up to the maximum
+	now = now.Add(time.Minute)
+	assert.NoError(t, limiter.Check(ctx, "a@example.com"))
+	assert.Equal(t, 2*time.Minute, failUntilLocked("a@example.com"))
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 3*time.Minute, failUntilLocked("a@example.com"))
+
+	// Lockouts are forgotten after the lockout memory, or a success
+	now = now.Add(3*time.Minute + time.Hour)
+	assert.Equal(t, time.Minute, failUntilLocked("a@example.com"))
+	now = now.Add(time.Minute)
+	require.NoError(t, limiter.Succeed(ctx, "a@example.com"))
+	assert.Equal(t, time.Minute, failUntilLocked("a@example.com"))
+
+	// Failures outside the window are not counted
+	now = now.Add(time.Minute)
+	_, err = limiter.Fail(ctx, "a@example.com")
+	require.NoError(t, err)
+	now = now.Add(2 * time.Minute)
+	remaining, err := limiter.Fail(ctx, "a@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 2, remaining)
+
+	now = now.Add(48 * time.Hour)
+	store.Cleanup()
+	assert.Empty(t, store.entries)
+}
+
+// scriptedEvaler answers each Eval with the next of its results
+type scriptedEvaler struct {
+	results []interface{}
+	keys    [][]string
+}
+
+func (e *scriptedEvaler) Eval(_ context.Context, _ string, keys []string, _ ...interface{}) (interface{}, error) {
+	e.keys = append(e.keys, keys)
+	res := e.results[0]
+	e.results = e.results[1:]
+	return res, nil
+}
+
+func TestRedisAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	client := &scriptedEvaler{results: []interface{}{
+		[]interface{}{int64(0), int64(0), int64(-2)},
+		[]interface{}{int64(0), int64(2), int64(120000)},
+		[]interface{}{int64(1), int64(0)},
+	}}
+	limiter := NewAttemptLimiter("card_pin", NewRedisAttemptStore(client, "attempts:card-service:"), PINAttemptPolicy())
+
+	_, err := limiter.Fail(ctx, AttemptKey("user", "card"))
+	var locked *LockedOutError
+	require.ErrorAs(t, err, &locked)
+	assert.Equal(t, 2*time.Minute, locked.RetryAfter)
+	assert.True(t, strings.HasPrefix(client.keys[0][0], "attempts:card-service:card_pin:"))
+	assert.NotContains(t, client.keys[0][0], "user:card", "keys are hashed")
+	assert.Len(t, client.keys[1], 3)
+
+	assert.Error(t, limiter.Check(ctx, "user"), "a malformed script result is an error")
+}
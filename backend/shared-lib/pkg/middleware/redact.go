@@ -0,0 +1,310 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/security"
+	"github.com/gin-gonic/gin"
+)
+
+// RedactTag is the struct tag naming who a response field is hidden from and
+// how. It is a comma-separated list of role=action rules, for example
+//
+//	Email string `json:"email" redact:"support=omit,third_party=email"`
+//
+// A rule without an action omits the field, and the role * matches every
+// caller. A reveal=<scope> entry shows the field unchanged to tokens granting
+// that scope, whatever their role.
+const RedactTag = "redact"
+
+// Redaction actions
+const (
+	RedactOmit  = "omit"  // the field is removed
+	RedactLast4 = "last4" // all but the last 4 characters are masked, as for a PAN
+	RedactEmail = "email" // the local part of an email address is masked
+	RedactPhone = "phone" // all but the last 4 digits of a phone number are masked
+)
+
+// redactRule is how a field is redacted for one role
+type redactRule struct {
+	role   string
+	action string
+}
+
+// fieldPolicy is how one JSON field is redacted
+type fieldPolicy struct {
+	rules  []redactRule
+	reveal []string
+}
+
+// action returns how the field is redacted for the caller, or "" to leave it
+func (p *fieldPolicy) action(claims *Claims) string {
+	for _, scope := range p.reveal {
+		if claims.HasScope(scope) {
+			return ""
+		}
+	}
+	for _, r := range p.rules {
+		if r.role == "*" || r.role == claims.Role {
+			return r.action
+		}
+	}
+	return ""
+}
+
+// RedactionPolicy maps JSON field names to how they are redacted, built from
+// the redact tags of the response types it was created with
+type RedactionPolicy struct {
+	fields map[string]*fieldPolicy
+}
+
+// NewRedactionPolicy builds a policy from the redact tags of the given
+// response types, including nested and embedded structs. It panics on an
+// invalid tag, as it runs when routes are set up.
+func NewRedactionPolicy(models ...interface{}) *RedactionPolicy {
+	p := &RedactionPolicy{fields: map[string]*fieldPolicy{}}
+	visited := map[reflect.Type]bool{}
+	for _, m := range models {
+		if err := p.add(reflect.TypeOf(m), visited); err != nil {
+			panic(err)
+		}
+	}
+	return p
+}
+
+func (p *RedactionPolicy) add(t reflect.Type, visited map[reflect.Type]bool) error {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || visited[t] {
+		return nil
+	}
+	visited[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if err := p.add(f.Type, visited); err != nil {
+			return err
+		}
+		tag, ok := f.Tag.Lookup(RedactTag)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		policy, err := parseRedactTag(tag)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), f.Name, err)
+		}
+		if existing, ok := p.fields[name]; ok {
+			// Types sharing a field name share its rules; the first rule listed for a role wins
+			existing.rules = append(existing.rules, policy.rules...)
+			existing.reveal = append(existing.reveal, policy.reveal...)
+			continue
+		}
+		p.fields[name] = policy
+	}
+	return nil
+}
+
+func parseRedactTag(tag string) (*fieldPolicy, error) {
+	policy := &fieldPolicy{}
+	for _, entry := range strings.Split(tag, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, action, _ := strings.Cut(entry, "=")
+		if role == "reveal" {
+			if action == "" {
+				return nil, fmt.Errorf("redact tag %q: reveal needs a scope", tag)
+			}
+			policy.reveal = append(policy.reveal, action)
+			continue
+		}
+		if action == "" {
+			action = RedactOmit
+		}
+		switch action {
+		case RedactOmit, RedactLast4, RedactEmail, RedactPhone:
+		default:
+			return nil, fmt.Errorf("redact tag %q: unknown action %q", tag, action)
+		}
+		policy.rules = append(policy.rules, redactRule{role: role, action: action})
+	}
+	if len(policy.rules) == 0 {
+		return nil, fmt.Errorf("redact tag %q has no rules", tag)
+	}
+	return policy, nil
+}
+
+// appliesTo reports whether any field is redacted for the caller
+func (p *RedactionPolicy) appliesTo(claims *Claims) bool {
+	for _, f := range p.fields {
+		if f.action(claims) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact applies the policy to a decoded JSON value for the caller, in place.
+// Rules apply by field name wherever the field appears in the body, so a field
+// tagged on one type is also redacted in untagged types that use its name.
+func (p *RedactionPolicy) Redact(v interface{}, claims *Claims) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for name, field := range value {
+			policy, ok := p.fields[name]
+			if !ok {
+				value[name] = p.Redact(field, claims)
+				continue
+			}
+			switch action := policy.action(claims); action {
+			case "":
+				value[name] = p.Redact(field, claims)
+			case RedactOmit:
+				delete(value, name)
+			default:
+				s, ok := field.(string)
+				if !ok {
+					// Only strings can be masked; anything else is left out
+					delete(value, name)
+					continue
+				}
+				value[name] = mask(action, s)
+			}
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = p.Redact(value[i], claims)
+		}
+	}
+	return v
+}
+
+var redactMasker = security.NewDataMasker()
+
+func mask(action, s string) string {
+	if s == "" {
+		return s
+	}
+	switch action {
+	case RedactEmail:
+		return redactMasker.MaskEmail(s)
+	case RedactPhone:
+		return redactMasker.MaskPhone(s)
+	default:
+		return redactMasker.MaskCardNumber(s)
+	}
+}
+
+// RedactResponses returns middleware that strips or masks the fields of JSON
+// responses that the caller's role may not see, as declared by the redact tags
+// of the given response types. Handlers stay the same for customer, support
+// and admin clients.
+//
+// Register it after JWTAuth, since it reads the caller's claims, and after
+// Compress, so it sees the uncompressed body. Responses to callers no rule
+// applies to are passed through untouched.
+func RedactResponses(models ...interface{}) gin.HandlerFunc {
+	policy := NewRedactionPolicy(models...)
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !policy.appliesTo(claims) {
+			c.Next()
+			return
+		}
+
+		w := &redactWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			w.finish(policy, claims)
+		}()
+		c.Next()
+	}
+}
+
+// redactWriter holds back the response body so it can be redacted as a whole.
+// A handler that flushes early is streaming, and its response is passed
+// through as it is; event streams are not JSON responses.
+type redactWriter struct {
+	gin.ResponseWriter
+	buf         bytes.Buffer
+	passThrough bool
+}
+
+func (w *redactWriter) Write(b []byte) (int, error) {
+	if w.passThrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *redactWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Written reports whether anything has been written, including buffered bytes
+func (w *redactWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *redactWriter) Flush() {
+	if !w.passThrough {
+		w.passThrough = true
+		if w.buf.Len() > 0 {
+			_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+			w.buf.Reset()
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+// finish writes out the buffered body, redacted if it is JSON
+func (w *redactWriter) finish(policy *RedactionPolicy, claims *Claims) {
+	if w.passThrough || w.buf.Len() == 0 {
+		return
+	}
+	body := w.buf.Bytes()
+	if isJSON(w.Header().Get("Content-Type")) {
+		if redacted, err := redactJSON(body, policy, claims); err == nil {
+			body = redacted
+		}
+	}
+	w.Header().Del("Content-Length")
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+func redactJSON(body []byte, policy *RedactionPolicy, claims *Claims) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Numbers are kept as written, so amounts and IDs are not rounded
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("body holds more than one JSON value")
+	}
+	return json.Marshal(policy.Redact(v, claims))
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
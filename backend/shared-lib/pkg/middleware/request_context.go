@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IdempotencyKeyKey is the context key for the idempotency key of a request
+const IdempotencyKeyKey ContextKey = "idempotency_key"
+
+// requestContextKey caches the RequestContext of a request on the gin context
+const requestContextKey = "request_context"
+
+// RequestContext is what the middleware chain has established about a request:
+// who is calling, for which organization, and how to trace it. Handlers read
+// it once with GetRequestContext instead of reading context keys one by one,
+// and pass it to services on the request context with WithRequestContext.
+type RequestContext struct {
+	UserID         string
+	Email          string
+	Role           string
+	Scopes         []string
+	OrgID          string
+	OrgRole        string
+	ConsentID      string
+	RequestID      string
+	TraceID        string
+	SpanID         string
+	IdempotencyKey string
+	// Claims is the verified token, or nil for unauthenticated requests
+	Claims *Claims
+}
+
+// IsAuthenticated reports whether the request carried a valid token
+func (rc *RequestContext) IsAuthenticated() bool {
+	return rc.UserID != ""
+}
+
+// HasRole reports whether the caller's role is one of roles
+func (rc *RequestContext) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if rc.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the token grants scope
+func (rc *RequestContext) HasScope(scope string) bool {
+	for _, s := range rc.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ActsForOrg reports whether the request acts for an organization rather than a person
+func (rc *RequestContext) ActsForOrg() bool {
+	return rc.OrgID != ""
+}
+
+// GetRequestContext returns the RequestContext of the request. It is read from
+// the context keys on first use and cached; middleware that changes those keys
+// clears the cache, so the result is never stale.
+func GetRequestContext(c *gin.Context) *RequestContext {
+	if cached, ok := c.Get(requestContextKey); ok {
+		if rc, ok := cached.(*RequestContext); ok && rc != nil {
+			return rc
+		}
+	}
+
+	rc := &RequestContext{
+		UserID:         GetUserID(c),
+		Email:          GetEmail(c),
+		OrgID:          GetOrgID(c),
+		OrgRole:        GetOrgRole(c),
+		ConsentID:      GetConsentID(c),
+		RequestID:      GetRequestID(c),
+		IdempotencyKey: c.GetString(string(IdempotencyKeyKey)),
+		Claims:         GetClaims(c),
+	}
+	if rc.Claims != nil {
+		rc.Role = rc.Claims.Role
+		rc.Scopes = strings.Fields(rc.Claims.Scope)
+	}
+	if rc.IdempotencyKey == "" {
+		rc.IdempotencyKey = c.GetHeader(IdempotencyKeyHeader)
+	}
+	if c.Request != nil {
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			rc.TraceID = sc.TraceID().String()
+			rc.SpanID = sc.SpanID().String()
+		}
+	}
+	c.Set(requestContextKey, rc)
+	return rc
+}
+
+// SetRequestContext makes rc the RequestContext of the request and sets the
+// context keys it is read from, so GetUserID and the other getters agree with
+// it. Tests use it to fabricate an authenticated request without a token.
+func SetRequestContext(c *gin.Context, rc *RequestContext) {
+	claims := rc.Claims
+	if claims == nil && rc.UserID != "" {
+		claims = &Claims{
+			UserID:    rc.UserID,
+			Email:     rc.Email,
+			Role:      rc.Role,
+			Scope:     strings.Join(rc.Scopes, " "),
+			OrgID:     rc.OrgID,
+			OrgRole:   rc.OrgRole,
+			ConsentID: rc.ConsentID,
+		}
+	}
+	c.Set(string(UserIDKey), rc.UserID)
+	c.Set(string(EmailKey), rc.Email)
+	if claims != nil {
+		c.Set(string(ClaimsKey), claims)
+	}
+	c.Set(string(OrgIDKey), rc.OrgID)
+	c.Set(string(OrgRoleKey), rc.OrgRole)
+	c.Set(string(RequestIDKey), rc.RequestID)
+	c.Set(string(IdempotencyKeyKey), rc.IdempotencyKey)
+	c.Set(requestContextKey, rc)
+	if c.Request != nil {
+		c.Request = c.Request.WithContext(WithRequestContext(c.Request.Context(), rc))
+	}
+}
+
+// setContextValue sets a context key the RequestContext is read from and
+// clears the cached RequestContext so the next GetRequestContext sees it
+func setContextValue(c *gin.Context, key ContextKey, value any) {
+	c.Set(string(key), value)
+	clearRequestContext(c)
+}
+
+// clearRequestContext drops the cached RequestContext, for middleware that
+// changes what it is read from
+func clearRequestContext(c *gin.Context) {
+	c.Set(requestContextKey, nil)
+}
+
+type requestContextCtxKey struct{}
+
+// WithRequestContext returns a copy of ctx carrying rc, for services that need
+// the caller without taking a *gin.Context
+func WithRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextCtxKey{}, rc)
+}
+
+// RequestContextFrom returns the RequestContext carried by ctx. A *gin.Context
+// is read with GetRequestContext. It never returns nil: a context without one
+// yields an empty, unauthenticated RequestContext.
+func RequestContextFrom(ctx context.Context) *RequestContext {
+	if c, ok := ctx.(*gin.Context); ok {
+		return GetRequestContext(c)
+	}
+	if rc, ok := ctx.Value(requestContextCtxKey{}).(*RequestContext); ok && rc != nil {
+		return rc
+	}
+	return &RequestContext{}
+}
+
+// RequestCtx returns the request's context.Context carrying its RequestContext,
+// for handlers to pass to services
+func RequestCtx(c *gin.Context) context.Context {
+	return WithRequestContext(c.Request.Context(), GetRequestContext(c))
+}
@@ -42,12 +42,12 @@ func SecurityHeaders() gin.HandlerFunc {
 // RequestID adds a unique request ID to each request for tracing
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requestID := c.GetHeader("X-Request-ID")
+		requestID := c.GetHeader(RequestIDHeader)
 		if requestID == "" {
 			requestID = generateRequestID()
 		}
-		c.Header("X-Request-ID", requestID)
-		c.Set("requestID", requestID)
+		c.Header(RequestIDHeader, requestID)
+		setContextValue(c, RequestIDKey, requestID)
 		c.Next()
 	}
 }
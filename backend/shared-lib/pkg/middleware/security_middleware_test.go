@@ -3,8 +3,10 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 	"time"
 
@@ -63,6 +65,22 @@ func TestIdempotency_AcceptsRequestWithKey(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestIdempotency_RejectsMalformedKey(t *testing.T) {
+	r := gin.New()
+	r.Use(Idempotency(NewInMemoryIdempotencyStore(), DefaultIdempotencyConfig()))
+	r.POST("/api/v1/transfer", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/transfer", nil)
+	req.Header.Set("X-Idempotency-Key", "key with spaces")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"X-Idempotency-Key","rule":"idempotency_key"`)
+}
+
 func TestIdempotency_ReturnsCachedResponseForSameKey(t *testing.T) {
 	r := gin.New()
 	store := NewInMemoryIdempotencyStore()
@@ -313,46 +331,6 @@ func TestInputValidation_BlocksLongURL(t *testing.T) {
 	assert.Equal(t, http.StatusRequestURITooLong, w.Code)
 }
 
-// =====================================
-// Password Validation Tests
-// =====================================
-
-func TestValidatePassword_RequiresMinLength(t *testing.T) {
-	valid, msg := ValidatePassword("Short1!")
-	assert.False(t, valid)
-	assert.Contains(t, msg, "8 characters")
-}
-
-func TestValidatePassword_RequiresUppercase(t *testing.T) {
-	valid, msg := ValidatePassword("lowercase1!")
-	assert.False(t, valid)
-	assert.Contains(t, msg, "uppercase")
-}
-
-func TestValidatePassword_RequiresLowercase(t *testing.T) {
-	valid, msg := ValidatePassword("UPPERCASE1!")
-	assert.False(t, valid)
-	assert.Contains(t, msg, "lowercase")
-}
-
-func TestValidatePassword_RequiresDigit(t *testing.T) {
-	valid, msg := ValidatePassword("NoDigits!")
-	assert.False(t, valid)
-	assert.Contains(t, msg, "digit")
-}
-
-func TestValidatePassword_RequiresSpecialChar(t *testing.T) {
-	valid, msg := ValidatePassword("NoSpecial1")
-	assert.False(t, valid)
-	assert.Contains(t, msg, "special")
-}
-
-func TestValidatePassword_AcceptsValidPassword(t *testing.T) {
-	valid, msg := ValidatePassword("SecurePass123!")
-	assert.True(t, valid)
-	assert.Empty(t, msg)
-}
-
 // =====================================
 // Email Validation Tests
 // =====================================
@@ -447,6 +425,46 @@ func TestAuditLogger_LogsEvent(t *testing.T) {
 	assert.Equal(t, "test-service", event.ServiceName)
 }
 
+type recordingAuditSink struct {
+	events []*AuditEvent
+	err    error
+}
+
+func (s *recordingAuditSink) Write(event *AuditEvent) error {
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestAuditLogger_WritesToSink(t *testing.T) {
+	sink := &recordingAuditSink{}
+	logger := NewAuditLoggerWithConfig(AuditLoggerConfig{ServiceName: "test-service", Sink: sink})
+
+	router := gin.New()
+	router.Use(AuditMiddleware(logger, "test-service"))
+	router.POST("/auth/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/auth/login", nil),
+		httptest.NewRequest(http.MethodPost, "/auth/login", nil),
+		httptest.NewRequest(http.MethodGet, "/health", nil),
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, AuditEventLogin, sink.events[0].EventType)
+	assert.Equal(t, "test-service", sink.events[0].ServiceName)
+	assert.NotEqual(t, sink.events[0].EventID, sink.events[1].EventID)
+
+	// A failing sink does not affect the caller
+	sink.err = assert.AnError
+	require.NotPanics(t, func() {
+		logger.Log(&AuditEvent{EventType: AuditEventLogout})
+	})
+	assert.Len(t, sink.events, 3)
+}
+
 func TestClassifyEvent_IdentifiesLoginEvent(t *testing.T) {
 	eventType, severity := classifyEvent("POST", "/auth/login", 200)
 	assert.Equal(t, AuditEventLogin, eventType)
@@ -459,12 +477,43 @@ func TestClassifyEvent_IdentifiesFailedLogin(t *testing.T) {
 	assert.Equal(t, AuditSeverityWarning, severity)
 }
 
+func TestClassifyEvent_IdentifiesMagicLinkEvents(t *testing.T) {
+	eventType, _ := classifyEvent("POST", "/auth/magic-link", 202)
+	assert.Equal(t, AuditEventMagicLinkSent, eventType)
+
+	eventType, _ = classifyEvent("GET", "/auth/magic-link/verify", 200)
+	assert.Equal(t, AuditEventLogin, eventType)
+
+	eventType, severity := classifyEvent("GET", "/auth/magic-link/verify", 401)
+	assert.Equal(t, AuditEventLoginFailed, eventType)
+	assert.Equal(t, AuditSeverityWarning, severity)
+}
+
+func TestClassifyEvent_IdentifiesCardTokenEvents(t *testing.T) {
+	eventType, _ := classifyEvent("POST", "/api/v1/cards/123/tokens", 201)
+	assert.Equal(t, AuditEventCardTokenize, eventType)
+
+	eventType, _ = classifyEvent("DELETE", "/api/v1/cards/123/tokens/456", 200)
+	assert.Equal(t, AuditEventTokenRevoke, eventType)
+
+	eventType, _ = classifyEvent("GET", "/api/v1/cards/123/tokens", 200)
+	assert.Equal(t, AuditEventAPICall, eventType)
+}
+
 func TestClassifyEvent_IdentifiesTransferEvent(t *testing.T) {
 	eventType, severity := classifyEvent("POST", "/api/v1/transfer", 200)
 	assert.Equal(t, AuditEventTransferInit, eventType)
 	assert.Equal(t, AuditSeverityInfo, severity)
 }
 
+func TestClassifyEvent_IdentifiesCardPINChange(t *testing.T) {
+	eventType, _ := classifyEvent("POST", "/api/v1/cards/123/pin", 200)
+	assert.Equal(t, AuditEventCardPINChange, eventType)
+
+	eventType, _ = classifyEvent("POST", "/api/v1/cards/123/pin/unblock", 200)
+	assert.Equal(t, AuditEventCardUnblock, eventType)
+}
+
 func TestClassifyEvent_IdentifiesRateLimitExceeded(t *testing.T) {
 	eventType, severity := classifyEvent("GET", "/api/v1/anything", 429)
 	assert.Equal(t, AuditEventRateLimitExceeded, eventType)
@@ -613,3 +662,99 @@ func TestBodyWithIdempotency(t *testing.T) {
 	json.Unmarshal(w.Body.Bytes(), &response)
 	assert.Equal(t, float64(100), response["amount"])
 }
+
+// =====================================
+// Request Signing Tests
+// =====================================
+
+func newSignedRouter(config SignatureConfig) *gin.Engine {
+	r := gin.New()
+	r.Use(VerifySignature(config))
+	r.POST("/webhooks/fraud", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusOK, gin.H{"key_id": GetSignatureKeyID(c), "body": string(body)})
+	})
+	return r
+}
+
+func newSignedRequest(t *testing.T, body string, secret []byte) *http.Request {
+	req, _ := http.NewRequest(http.MethodPost, "/webhooks/fraud?source=partner", bytes.NewBufferString(body))
+	require.NoError(t, SignRequest(req, "fraud-partner", secret))
+	return req
+}
+
+func TestVerifySignature_AcceptsValidSignature(t *testing.T) {
+	secret := []byte("partner-secret")
+	r := newSignedRouter(DefaultSignatureConfig(map[string][]byte{"fraud-partner": secret}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newSignedRequest(t, `{"score":97}`, secret))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"key_id":"fraud-partner","body":"{\"score\":97}"}`, w.Body.String())
+}
+
+func TestVerifySignature_RejectsTamperingAndUnknownKeys(t *testing.T) {
+	secret := []byte("partner-secret")
+	r := newSignedRouter(DefaultSignatureConfig(map[string][]byte{"fraud-partner": secret}))
+
+	tampered := newSignedRequest(t, `{"score":97}`, secret)
+	tampered.Body = io.NopCloser(bytes.NewBufferString(`{"score":1}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, tampered)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	wrongSecret := newSignedRequest(t, `{}`, []byte("other-secret"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, wrongSecret)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	unknownKey := newSignedRequest(t, `{}`, secret)
+	unknownKey.Header.Set(SignatureKeyIDHeader, "someone-else")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, unknownKey)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	unsigned, _ := http.NewRequest(http.MethodPost, "/webhooks/fraud", bytes.NewBufferString(`{}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, unsigned)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestVerifySignature_RejectsReplayAndStaleTimestamps(t *testing.T) {
+	secret := []byte("partner-secret")
+	r := newSignedRouter(DefaultSignatureConfig(map[string][]byte{"fraud-partner": secret}))
+
+	req := newSignedRequest(t, `{"score":97}`, secret)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	replay, _ := http.NewRequest(http.MethodPost, req.URL.String(), bytes.NewBufferString(`{"score":97}`))
+	replay.Header = req.Header.Clone()
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, replay)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	stale, _ := http.NewRequest(http.MethodPost, "/webhooks/fraud", bytes.NewBufferString(`{}`))
+	timestamp := strconv.FormatInt(time.Now().Add(-10*time.Minute).Unix(), 10)
+	stale.Header.Set(SignatureKeyIDHeader, "fraud-partner")
+	stale.Header.Set(SignatureTimestampHeader, timestamp)
+	stale.Header.Set(SignatureNonceHeader, "abc")
+	stale.Header.Set(SignatureHeader, computeSignature(secret, http.MethodPost, "/webhooks/fraud", timestamp, "abc", []byte(`{}`)))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, stale)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSigningTransport_SignsOutgoingRequests(t *testing.T) {
+	secret := []byte("internal-secret")
+	server := httptest.NewServer(newSignedRouter(DefaultSignatureConfig(map[string][]byte{"payment-service": secret})))
+	defer server.Close()
+
+	client := &http.Client{Transport: &SigningTransport{KeyID: "payment-service", Secret: secret}}
+	resp, err := client.Post(server.URL+"/webhooks/fraud", "application/json", bytes.NewBufferString(`{"ok":true}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
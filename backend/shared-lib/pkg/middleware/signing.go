@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/cache"
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// Request signing headers
+const (
+	SignatureHeader          = "X-Signature"
+	SignatureTimestampHeader = "X-Signature-Timestamp"
+	SignatureNonceHeader     = "X-Signature-Nonce"
+	SignatureKeyIDHeader     = "X-Signature-Key-Id"
+)
+
+// SignatureKeyIDKey is the context key for the key ID of a verified request
+const SignatureKeyIDKey ContextKey = "signature_key_id"
+
+var (
+	ErrSignatureMissing   = errors.New("request signature headers missing")
+	ErrSignatureUnknownID = errors.New("unknown signature key id")
+	ErrSignatureSkew      = errors.New("request timestamp outside allowed window")
+	ErrSignatureInvalid   = errors.New("request signature invalid")
+	ErrSignatureReplayed  = errors.New("request nonce already used")
+)
+
+// SignatureConfig holds configuration for signed request verification
+type SignatureConfig struct {
+	// Secrets maps a key ID (one per partner or calling service) to its shared secret
+	Secrets map[string][]byte
+	// MaxSkew is how far the request timestamp may be from the server clock
+	MaxSkew time.Duration
+	// MaxBodyBytes limits how much of the body is read to verify the signature
+	MaxBodyBytes int64
+	// Nonces remembers nonces for the skew window so a captured request cannot be replayed
+	Nonces NonceStore
+}
+
+// DefaultSignatureConfig returns a configuration with a 5 minute skew window,
+// a 1MB body limit and an in-memory nonce store
+func DefaultSignatureConfig(secrets map[string][]byte) SignatureConfig {
+	return SignatureConfig{
+		Secrets:      secrets,
+		MaxSkew:      5 * time.Minute,
+		MaxBodyBytes: 1 << 20,
+		Nonces:       NewInMemoryNonceStore(),
+	}
+}
+
+// NonceStore records nonces that have been seen
+type NonceStore interface {
+	// Claim records the nonce and returns false if it was already recorded
+	Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// InMemoryNonceStore is a single-instance nonce store (tests and local development)
+type InMemoryNonceStore struct {
+	nonces map[string]time.Time
+	mu     sync.Mutex
+}
+
+// NewInMemoryNonceStore creates a new in-memory nonce store
+func NewInMemoryNonceStore() *InMemoryNonceStore {
+	return &InMemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *InMemoryNonceStore) Claim(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for n, expiry := range s.nonces {
+		if now.After(expiry) {
+			delete(s.nonces, n)
+		}
+	}
+	if _, exists := s.nonces[nonce]; exists {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// RedisNonceStore shares seen nonces across all instances of a service
+type RedisNonceStore struct {
+	client *cache.RedisClient
+	prefix string
+}
+
+// NewRedisNonceStore creates a Redis-backed nonce store. The prefix keeps
+// nonces of different services apart, e.g. "signing:fraud-service:".
+func NewRedisNonceStore(client *cache.RedisClient, prefix string) *RedisNonceStore {
+	return &RedisNonceStore{client: client, prefix: prefix}
+}
+
+func (s *RedisNonceStore) Claim(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, s.prefix+nonce, "1", ttl)
+}
+
+// SignRequest signs an outgoing request with HMAC-SHA256 and sets the signature
+// headers. The body is read and replaced so the request can still be sent.
+func SignRequest(req *http.Request, keyID string, secret []byte) error {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+
+	req.Header.Set(SignatureKeyIDHeader, keyID)
+	req.Header.Set(SignatureTimestampHeader, timestamp)
+	req.Header.Set(SignatureNonceHeader, nonceHex)
+	req.Header.Set(SignatureHeader, computeSignature(secret, req.Method, req.URL.RequestURI(), timestamp, nonceHex, body))
+	return nil
+}
+
+// SigningTransport is an http.RoundTripper that signs every request it sends
+type SigningTransport struct {
+	Base   http.RoundTripper
+	KeyID  string
+	Secret []byte
+}
+
+func (t *SigningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	signed := req.Clone(req.Context())
+	if err := SignRequest(signed, t.KeyID, t.Secret); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(signed)
+}
+
+// VerifySignature returns a middleware that authenticates requests signed with
+// SignRequest, for partner webhooks and internal callbacks that carry no JWT.
+// Requests are rejected when the timestamp is outside MaxSkew or the nonce has
+// been seen before.
+func VerifySignature(config SignatureConfig) gin.HandlerFunc {
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = 5 * time.Minute
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = 1 << 20
+	}
+	if config.Nonces == nil {
+		config.Nonces = NewInMemoryNonceStore()
+	}
+
+	return func(c *gin.Context) {
+		keyID, err := verifyRequestSignature(c, config)
+		if err != nil {
+			slog.Warn("Rejected signed request", "path", c.Request.URL.Path, "key_id", c.GetHeader(SignatureKeyIDHeader), "error", err)
+			apperrors.RespondWithError(c, apperrors.ErrUnauthorized.WithMessage(err.Error()))
+			return
+		}
+
+		c.Set(string(SignatureKeyIDKey), keyID)
+		c.Next()
+	}
+}
+
+func verifyRequestSignature(c *gin.Context, config SignatureConfig) (string, error) {
+	keyID := c.GetHeader(SignatureKeyIDHeader)
+	signature := c.GetHeader(SignatureHeader)
+	timestamp := c.GetHeader(SignatureTimestampHeader)
+	nonce := c.GetHeader(SignatureNonceHeader)
+	if keyID == "" || signature == "" || timestamp == "" || nonce == "" {
+		return "", ErrSignatureMissing
+	}
+
+	secret, ok := config.Secrets[keyID]
+	if !ok {
+		return "", ErrSignatureUnknownID
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrSignatureSkew
+	}
+	skew := time.Since(time.Unix(unix, 0))
+	if skew > config.MaxSkew || skew < -config.MaxSkew {
+		return "", ErrSignatureSkew
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(c.Request.Body, config.MaxBodyBytes+1))
+		if err != nil {
+			return "", fmt.Errorf("failed to read body: %w", err)
+		}
+		if int64(len(body)) > config.MaxBodyBytes {
+			return "", ErrSignatureInvalid
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := computeSignature(secret, c.Request.Method, c.Request.URL.RequestURI(), timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", ErrSignatureInvalid
+	}
+
+	// Only claim the nonce once the signature is valid so forged requests cannot burn nonces.
+	// It must outlive the whole window in which the timestamp would still be accepted.
+	fresh, err := config.Nonces.Claim(c.Request.Context(), keyID+":"+nonce, 2*config.MaxSkew)
+	if err != nil {
+		return "", fmt.Errorf("failed to check nonce: %w", err)
+	}
+	if !fresh {
+		return "", ErrSignatureReplayed
+	}
+	return keyID, nil
+}
+
+// computeSignature returns the hex HMAC-SHA256 of method, request URI, timestamp,
+// nonce and body, separated by newlines
+func computeSignature(secret []byte, method, uri, timestamp, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GetSignatureKeyID returns the key ID of a request verified by VerifySignature
+func GetSignatureKeyID(c *gin.Context) string {
+	return c.GetString(string(SignatureKeyIDKey))
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// OrgIDKey is the context key for the organization a request acts for
+	OrgIDKey ContextKey = "org_id"
+	// OrgRoleKey is the context key for the caller's role in that organization
+	OrgRoleKey ContextKey = "org_role"
+)
+
+// TenantScope makes the token's organization available to handlers through
+// GetOrgID and to code below them through tenant.OrgID on the request context.
+// Personal tokens pass through without an organization. It must run after JWTAuth.
+func TenantScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || claims.OrgID == "" {
+			c.Next()
+			return
+		}
+		if _, err := uuid.Parse(claims.OrgID); err != nil || !tenant.IsValidRole(claims.OrgRole) {
+			errors.RespondWithError(c, errors.ErrInvalidToken)
+			return
+		}
+
+		setContextValue(c, OrgIDKey, claims.OrgID)
+		setContextValue(c, OrgRoleKey, claims.OrgRole)
+		c.Request = c.Request.WithContext(tenant.WithOrgID(c.Request.Context(), claims.OrgID))
+		c.Next()
+	}
+}
+
+// GetOrgID returns the organization the request acts for, or "" for personal requests
+func GetOrgID(c *gin.Context) string {
+	return c.GetString(string(OrgIDKey))
+}
+
+// GetOrgRole returns the caller's role in the organization of the request
+func GetOrgRole(c *gin.Context) string {
+	return c.GetString(string(OrgRoleKey))
+}
+
+// RequireOrgRole rejects requests that do not act for an organization, or whose
+// caller does not hold one of the given roles in it. It must run after TenantScope.
+func RequireOrgRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetOrgID(c) == "" {
+			errors.RespondWithError(c, errors.ErrForbidden.WithMessage("an organization token is required"))
+			return
+		}
+		role := GetOrgRole(c)
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		errors.RespondWithError(c, errors.ErrForbidden)
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireTermsAccepted refuses requests that change anything while the
+// caller's token says they have not accepted the current mandatory terms.
+// Reads stay available so the app can still show balances, and paths under
+// any of exemptPrefixes, such as the acceptance endpoints, are never refused.
+// It must run after JWTAuth. Tokens are re-issued once the terms are
+// accepted, so the block lifts with the next token.
+func RequireTermsAccepted(exemptPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil || !claims.TermsPending || readOnlyMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		for _, prefix := range exemptPrefixes {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+		errors.RespondWithError(c, errors.ErrTermsAcceptanceRequired)
+	}
+}
+
+func readOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var requestTimeoutsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_request_timeouts_total",
+		Help: "Total number of requests answered with 504 because they ran past their deadline",
+	},
+	[]string{"route"},
+)
+
+// RequestTimeoutHeader carries the time a caller is still willing to wait, in
+// milliseconds. Timeout shortens a request's deadline to it, and
+// PropagateDeadline sets it on outgoing calls, so one deadline holds across
+// services.
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// DefaultRequestTimeout is the deadline used when TimeoutConfig.Timeout is unset
+const DefaultRequestTimeout = 5 * time.Second
+
+// timeoutWriterKey holds the *timeoutWriter of the outermost Timeout, so a
+// route-level Timeout can replace a group's deadline instead of nesting in it
+const timeoutWriterKey = "timeout_writer"
+
+// TimeoutConfig configures request deadlines
+type TimeoutConfig struct {
+	// Timeout is how long a request may run. A caller's RequestTimeoutHeader
+	// can shorten it but never extend it.
+	Timeout time.Duration
+}
+
+// Timeout returns middleware that gives each request a deadline of d
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: d})
+}
+
+// TimeoutWithConfig returns middleware that sets a deadline on the request
+// context and answers 504 with a TIMEOUT error if the handler has not
+// responded by then. Handlers are not interrupted: they should pass
+// c.Request.Context() to database, HTTP, gRPC and Kafka calls, which then give
+// up at the deadline. Anything the handler writes after the deadline is
+// discarded.
+//
+// Apply it per route group, e.g. 5s on reads. A Timeout registered on a single
+// route replaces the group's, so a slow endpoint such as statement generation
+// can be given 30s inside a 5s group. Do not apply it to streaming routes.
+func TimeoutWithConfig(cfg TimeoutConfig) gin.HandlerFunc {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultRequestTimeout
+	}
+
+	return func(c *gin.Context) {
+		timeout := cfg.Timeout
+		if callerTimeout, ok := parseRequestTimeout(c.GetHeader(RequestTimeoutHeader)); ok && callerTimeout < timeout {
+			timeout = callerTimeout
+		}
+
+		if v, ok := c.Get(timeoutWriterKey); ok {
+			// The deadline is restarted from a context without the group's,
+			// but the request is still cancelled if the client goes away
+			w := v.(*timeoutWriter)
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), timeout)
+			defer cancel()
+			stop := context.AfterFunc(w.origin, cancel)
+			defer stop()
+			w.ctx, w.timeout = ctx, timeout
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+
+		origin := c.Request.Context()
+		ctx, cancel := context.WithTimeout(origin, timeout)
+		defer cancel()
+
+		header := c.Writer.Header().Clone()
+		w := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx, origin: origin, timeout: timeout}
+		c.Writer = w
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(timeoutWriterKey, w)
+
+		c.Next()
+
+		c.Writer = w.ResponseWriter
+		if !w.expired() {
+			return
+		}
+
+		// Drop the headers the handler set for the response it no longer sends
+		h := c.Writer.Header()
+		for k := range h {
+			delete(h, k)
+		}
+		for k, v := range header {
+			h[k] = v
+		}
+		requestTimeoutsTotal.WithLabelValues(c.FullPath()).Inc()
+		slog.Warn("Request exceeded its deadline", "method", c.Request.Method, "path", c.Request.URL.Path, "timeout", w.timeout)
+		apperrors.RespondWithError(c, apperrors.ErrTimeout.WithDetails(gin.H{"timeout_ms": w.timeout.Milliseconds()}))
+	}
+}
+
+// timeoutWriter discards the response once the deadline has passed before
+// anything was written, leaving the 504 to TimeoutWithConfig
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	origin   context.Context
+	timeout  time.Duration
+	timedOut bool
+}
+
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && !w.ResponseWriter.Written() && w.ctx.Err() == context.DeadlineExceeded {
+		w.timedOut = true
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if w.expired() {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if !w.expired() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Flush() {
+	if !w.expired() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// parseRequestTimeout reads a RequestTimeoutHeader value
+func parseRequestTimeout(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms <= 0 {
+		return 0, false
+	}
+	return time.Duration(ms) * time.Millisecond, true
+}
+
+// RemainingTimeout returns how long is left until ctx's deadline, and false if
+// it has none. Use it to bound calls that take a timeout rather than a context.
+func RemainingTimeout(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// PropagateDeadline returns a RoundTripper that sends the time left on each
+// request's context in RequestTimeoutHeader, so the service called gives up
+// when the caller does. A nil base uses http.DefaultTransport. gRPC sends
+// context deadlines itself and Kafka writes stop at the context's deadline,
+// so those calls only need the request context.
+func PropagateDeadline(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &deadlineTransport{base: base}
+}
+
+type deadlineTransport struct {
+	base http.RoundTripper
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	remaining, ok := RemainingTimeout(req.Context())
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	if remaining <= 0 {
+		return nil, context.DeadlineExceeded
+	}
+
+	// RoundTrippers must not modify the caller's request
+	out := req.Clone(req.Context())
+	ms := remaining.Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	out.Header.Set(RequestTimeoutHeader, strconv.FormatInt(ms, 10))
+	return t.base.RoundTrip(out)
+}
@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/tracing"
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
@@ -37,12 +39,13 @@ func Tracing(serviceName string) gin.HandlerFunc {
 		defer span.End()
 
 		// Add request ID if present
-		if requestID := c.GetHeader("X-Request-ID"); requestID != "" {
+		if requestID := c.GetHeader(RequestIDHeader); requestID != "" {
 			span.SetAttributes(attribute.String("request.id", requestID))
 		}
 
 		// Store span in context
 		c.Request = c.Request.WithContext(ctx)
+		clearRequestContext(c)
 
 		// Process request
 		c.Next()
@@ -66,3 +69,23 @@ func Tracing(serviceName string) gin.HandlerFunc {
 		}
 	}
 }
+
+// TraceBusinessContext puts the business identifiers a request is about in
+// its baggage and on its span, e.g. the payment a handler just created. Spans
+// of downstream services and Kafka consumers then carry them too.
+func TraceBusinessContext(c *gin.Context, bc tracing.BusinessContext) {
+	c.Request = c.Request.WithContext(tracing.WithBusinessContext(c.Request.Context(), bc))
+}
+
+// traceCaller adds the hashed ID of an authenticated user to the request's
+// baggage at the edge. Service tokens, and requests whose baggage already
+// names a user, keep the user the request came in with.
+func traceCaller(c *gin.Context, claims *Claims) {
+	if claims.Role == ServiceRole || claims.UserID == "" {
+		return
+	}
+	if baggage.FromContext(c.Request.Context()).Member(tracing.BaggageUserHash).Value() != "" {
+		return
+	}
+	TraceBusinessContext(c, tracing.BusinessContext{UserID: claims.UserID})
+}
@@ -93,7 +93,7 @@ func (rl *UserRateLimiter) Remaining(userID string) int {
 func UserRateLimitMiddleware(limiter *UserRateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get user ID from context (set by auth middleware)
-		userID := c.GetString("userID")
+		userID := GetUserID(c)
 
 		// Fall back to IP if no user ID
 		if userID == "" {
@@ -163,7 +163,7 @@ func SensitiveRateLimitMiddleware(limiter *SensitiveEndpointRateLimiter) gin.Han
 			return
 		}
 
-		userID := c.GetString("userID")
+		userID := GetUserID(c)
 		if userID == "" {
 			userID = "ip:" + c.ClientIP()
 		}
@@ -0,0 +1,437 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// DefaultWebSocketAuthTimeout is how long a client that connected without
+	// a token has to send its auth message
+	DefaultWebSocketAuthTimeout = 10 * time.Second
+	// DefaultWebSocketPingInterval is how often the server pings a connection
+	DefaultWebSocketPingInterval = 30 * time.Second
+	// DefaultWebSocketPongWait is how long a connection may stay silent,
+	// including not answering pings, before it is closed
+	DefaultWebSocketPongWait = 75 * time.Second
+	// DefaultWebSocketMaxPerUser caps the open connections of one user in a registry
+	DefaultWebSocketMaxPerUser = 5
+	// DefaultWebSocketMaxMessageBytes bounds the messages a client may send
+	DefaultWebSocketMaxMessageBytes = 64 << 10
+
+	// WebSocketTokenParam is the query parameter a token may be passed in;
+	// browsers cannot set headers on a WebSocket
+	WebSocketTokenParam = "access_token"
+
+	webSocketWriteTimeout = 10 * time.Second
+	webSocketInbox        = 16
+)
+
+// Message types of the WebSocket protocol. Either side may send a ping, which
+// is answered with a pong; other messages are the application's.
+const (
+	WebSocketAuth  = "auth"  // client → server: {"type":"auth","token":"..."}
+	WebSocketReady = "ready" // server → client once the connection is authenticated
+	WebSocketPing  = "ping"
+	WebSocketPong  = "pong"
+	WebSocketError = "error" // server → client, data is {"code","message"}; the connection then closes
+	WebSocketClose = "close" // server → client, data is {"reason"}; the connection then closes
+)
+
+// ErrTooManyConnections is returned when a user already has the most open
+// connections a registry allows
+var ErrTooManyConnections = errors.New("too many open connections")
+
+var errThirdPartyToken = errors.New("third-party tokens are only accepted by open banking endpoints")
+
+var (
+	webSocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "websocket_connections",
+		Help: "Open authenticated WebSocket connections",
+	})
+	webSocketRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "websocket_connections_rejected_total",
+		Help: "WebSocket connections refused, by reason",
+	}, []string{"reason"})
+)
+
+// WebSocketMessage is a message in either direction, sent as a JSON text frame
+type WebSocketMessage struct {
+	Type  string          `json:"type"`
+	Token string          `json:"token,omitempty"` // auth messages only
+	Data  json.RawMessage `json:"data,omitempty"`
+}
+
+// WebSocketConfig configures WebSocketHandler; zero durations and limits use the defaults
+type WebSocketConfig struct {
+	// Auth validates tokens as JWTAuthWithConfig does. A token is read from
+	// the access_token query parameter or the configured header; without one
+	// the client must send an auth message first.
+	Auth JWTAuthConfig
+	// Registry tracks the open connections and enforces its per-user limit
+	Registry *WebSocketRegistry
+	// AllowedOrigins, when set, are the Origin headers accepted. Tokens are
+	// never taken from cookies, so other origins cannot ride a user's session.
+	AllowedOrigins  []string
+	AuthTimeout     time.Duration
+	PingInterval    time.Duration
+	PongWait        time.Duration
+	MaxMessageBytes int
+}
+
+func (cfg WebSocketConfig) withDefaults() WebSocketConfig {
+	if cfg.Registry == nil {
+		cfg.Registry = NewWebSocketRegistry(0)
+	}
+	if cfg.AuthTimeout <= 0 {
+		cfg.AuthTimeout = DefaultWebSocketAuthTimeout
+	}
+	if cfg.PingInterval <= 0 {
+		cfg.PingInterval = DefaultWebSocketPingInterval
+	}
+	if cfg.PongWait <= 0 {
+		cfg.PongWait = DefaultWebSocketPongWait
+	}
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = DefaultWebSocketMaxMessageBytes
+	}
+	return cfg
+}
+
+// WebSocketHandler upgrades a request to an authenticated WebSocket and runs
+// handle with it; the connection is closed when handle returns. Requests with
+// a token are authenticated and checked against the per-user limit before the
+// upgrade, so they are refused with a plain HTTP error. The server pings every
+// PingInterval and closes connections that send nothing for PongWait, and
+// closes every connection when its token expires.
+func WebSocketHandler(cfg WebSocketConfig, handle func(*WebSocketConn)) gin.HandlerFunc {
+	cfg = cfg.withDefaults()
+	return func(c *gin.Context) {
+		var claims *Claims
+		if token := webSocketToken(c, cfg.Auth); token != "" {
+			var err error
+			if claims, err = webSocketClaims(token, cfg.Auth); err != nil {
+				webSocketRejected.WithLabelValues("unauthorized").Inc()
+				if errors.Is(err, errThirdPartyToken) {
+					apperrors.RespondWithError(c, apperrors.ErrForbidden.WithMessage(err.Error()))
+					return
+				}
+				apperrors.RespondWithError(c, apperrors.ErrInvalidToken)
+				return
+			}
+			if cfg.Registry.Full(claims.UserID) {
+				webSocketRejected.WithLabelValues("too_many_connections").Inc()
+				apperrors.RespondWithError(c, apperrors.ErrRateLimited.WithMessage(ErrTooManyConnections.Error()))
+				return
+			}
+			setContextValue(c, UserIDKey, claims.UserID)
+			setContextValue(c, ClaimsKey, claims)
+		}
+
+		server := websocket.Server{
+			Handshake: cfg.checkOrigin,
+			Handler: func(ws *websocket.Conn) {
+				serveWebSocket(ws, claims, cfg, handle)
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func (cfg WebSocketConfig) checkOrigin(_ *websocket.Config, req *http.Request) error {
+	if len(cfg.AllowedOrigins) == 0 {
+		return nil
+	}
+	if origin := req.Header.Get("Origin"); !slices.Contains(cfg.AllowedOrigins, origin) {
+		webSocketRejected.WithLabelValues("origin").Inc()
+		return fmt.Errorf("origin %q is not allowed", origin)
+	}
+	return nil
+}
+
+// webSocketToken reads the token from the query parameter or the configured header
+func webSocketToken(c *gin.Context, config JWTAuthConfig) string {
+	if token := c.Query(WebSocketTokenParam); token != "" {
+		return token
+	}
+	return extractToken(c, config)
+}
+
+// webSocketClaims validates a token, refusing third-party tokens as JWTAuth does
+func webSocketClaims(token string, config JWTAuthConfig) (*Claims, error) {
+	claims, err := validateToken(strings.TrimPrefix(token, "Bearer "), config)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Role == ThirdPartyRole && !config.AllowThirdParty {
+		return nil, errThirdPartyToken
+	}
+	return claims, nil
+}
+
+func serveWebSocket(ws *websocket.Conn, claims *Claims, cfg WebSocketConfig, handle func(*WebSocketConn)) {
+	ws.MaxPayloadBytes = cfg.MaxMessageBytes
+	conn := &WebSocketConn{ws: ws, inbox: make(chan WebSocketMessage, webSocketInbox), done: make(chan struct{})}
+	defer conn.close()
+
+	if claims == nil {
+		var err error
+		if claims, err = conn.awaitAuth(cfg); err != nil {
+			webSocketRejected.WithLabelValues("unauthorized").Inc()
+			conn.sendError(apperrors.ErrUnauthorized.Code, err.Error())
+			return
+		}
+	}
+	conn.UserID, conn.Claims = claims.UserID, claims
+	if err := cfg.Registry.add(conn); err != nil {
+		webSocketRejected.WithLabelValues("too_many_connections").Inc()
+		conn.sendError(apperrors.ErrRateLimited.Code, err.Error())
+		return
+	}
+	defer cfg.Registry.remove(conn)
+
+	if err := conn.Send(WebSocketReady, gin.H{"user_id": claims.UserID}); err != nil {
+		return
+	}
+	go conn.readLoop(cfg.PongWait)
+	go conn.keepAlive(cfg.PingInterval, claims)
+	handle(conn)
+}
+
+// WebSocketConn is an authenticated WebSocket connection. Send may be called
+// from any goroutine.
+type WebSocketConn struct {
+	UserID string
+	Claims *Claims
+
+	ws        *websocket.Conn
+	writeMu   sync.Mutex
+	inbox     chan WebSocketMessage
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Messages delivers the client's messages other than pings and pongs. It is
+// closed when the connection closes. Messages are dropped while it is full, so
+// handlers that only push need not read it.
+func (c *WebSocketConn) Messages() <-chan WebSocketMessage {
+	return c.inbox
+}
+
+// Done is closed when the connection closes, from either side
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Send writes a message of the given type; data is encoded as JSON and may be nil
+func (c *WebSocketConn) Send(msgType string, data interface{}) error {
+	msg := WebSocketMessage{Type: msgType}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		msg.Data = raw
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.SetWriteDeadline(time.Now().Add(webSocketWriteTimeout)); err != nil {
+		return err
+	}
+	return websocket.JSON.Send(c.ws, msg)
+}
+
+// Close tells the client why the connection is ending and closes it
+func (c *WebSocketConn) Close(reason string) {
+	_ = c.Send(WebSocketClose, gin.H{"reason": reason})
+	c.close()
+}
+
+func (c *WebSocketConn) sendError(code, message string) {
+	_ = c.Send(WebSocketError, gin.H{"code": code, "message": message})
+}
+
+func (c *WebSocketConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.ws.Close()
+	})
+}
+
+// awaitAuth reads the auth message a client without a token must send first
+func (c *WebSocketConn) awaitAuth(cfg WebSocketConfig) (*Claims, error) {
+	if err := c.ws.SetReadDeadline(time.Now().Add(cfg.AuthTimeout)); err != nil {
+		return nil, err
+	}
+	var msg WebSocketMessage
+	if err := websocket.JSON.Receive(c.ws, &msg); err != nil || msg.Type != WebSocketAuth || msg.Token == "" {
+		return nil, errors.New("the first message must be an auth message with a token")
+	}
+	claims, err := webSocketClaims(msg.Token, cfg.Auth)
+	if err != nil {
+		if errors.Is(err, errThirdPartyToken) {
+			return nil, err
+		}
+		return nil, errors.New(apperrors.ErrInvalidToken.Message)
+	}
+	return claims, nil
+}
+
+// readLoop answers pings and queues the client's other messages until the
+// connection fails or stays silent for pongWait
+func (c *WebSocketConn) readLoop(pongWait time.Duration) {
+	defer close(c.inbox)
+	defer c.close()
+	for {
+		if err := c.ws.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+			return
+		}
+		var msg WebSocketMessage
+		if err := websocket.JSON.Receive(c.ws, &msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.sendError(apperrors.ErrInvalidRequest.Code, "messages must be JSON objects with a type")
+				continue
+			}
+			return
+		}
+		switch msg.Type {
+		case WebSocketPing:
+			if err := c.Send(WebSocketPong, nil); err != nil {
+				return
+			}
+		case WebSocketPong:
+		default:
+			select {
+			case c.inbox <- msg:
+			default:
+				slog.Debug("Dropped WebSocket message", "user_id", c.UserID, "type", msg.Type)
+			}
+		}
+	}
+}
+
+// keepAlive pings the client and closes the connection when its token expires
+func (c *WebSocketConn) keepAlive(interval time.Duration, claims *Claims) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var expired <-chan time.Time
+	if claims.ExpiresAt != nil {
+		timer := time.NewTimer(time.Until(claims.ExpiresAt.Time))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-expired:
+			c.Close("token_expired")
+			return
+		case <-ticker.C:
+			if err := c.Send(WebSocketPing, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// WebSocketRegistry tracks the open connections of each user on this replica,
+// so services can push to a user and cap how many connections they hold
+type WebSocketRegistry struct {
+	maxPerUser int
+
+	mu    sync.Mutex
+	conns map[string]map[*WebSocketConn]struct{}
+}
+
+// NewWebSocketRegistry creates a registry; a zero limit uses DefaultWebSocketMaxPerUser
+func NewWebSocketRegistry(maxPerUser int) *WebSocketRegistry {
+	if maxPerUser <= 0 {
+		maxPerUser = DefaultWebSocketMaxPerUser
+	}
+	return &WebSocketRegistry{maxPerUser: maxPerUser, conns: make(map[string]map[*WebSocketConn]struct{})}
+}
+
+// Count returns the user's open connections
+func (r *WebSocketRegistry) Count(userID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.conns[userID])
+}
+
+// Full reports whether the user has as many open connections as allowed
+func (r *WebSocketRegistry) Full(userID string) bool {
+	return r.Count(userID) >= r.maxPerUser
+}
+
+// Send sends a message to each of the user's connections and returns how
+// many it was written to
+func (r *WebSocketRegistry) Send(userID, msgType string, data interface{}) int {
+	sent := 0
+	for _, conn := range r.userConns(userID) {
+		if err := conn.Send(msgType, data); err == nil {
+			sent++
+		}
+	}
+	return sent
+}
+
+// CloseUser closes the user's connections, e.g. when their sessions are revoked
+func (r *WebSocketRegistry) CloseUser(userID, reason string) {
+	for _, conn := range r.userConns(userID) {
+		conn.Close(reason)
+	}
+}
+
+func (r *WebSocketRegistry) userConns(userID string) []*WebSocketConn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conns := make([]*WebSocketConn, 0, len(r.conns[userID]))
+	for conn := range r.conns[userID] {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+func (r *WebSocketRegistry) add(conn *WebSocketConn) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.conns[conn.UserID]) >= r.maxPerUser {
+		return ErrTooManyConnections
+	}
+	if r.conns[conn.UserID] == nil {
+		r.conns[conn.UserID] = make(map[*WebSocketConn]struct{})
+	}
+	r.conns[conn.UserID][conn] = struct{}{}
+	webSocketConnections.Inc()
+	return nil
+}
+
+func (r *WebSocketRegistry) remove(conn *WebSocketConn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.conns[conn.UserID][conn]; !ok {
+		return
+	}
+	delete(r.conns[conn.UserID], conn)
+	if len(r.conns[conn.UserID]) == 0 {
+		delete(r.conns, conn.UserID)
+	}
+	webSocketConnections.Dec()
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+// echoServer serves WebSocketHandler at /ws; each connection echoes the
+// client's messages back with type "echo"
+func echoServer(t *testing.T, cfg WebSocketConfig) *httptest.Server {
+	t.Helper()
+	r := gin.New()
+	r.GET("/ws", WebSocketHandler(cfg, func(conn *WebSocketConn) {
+		for {
+			select {
+			case <-conn.Done():
+				return
+			case msg, ok := <-conn.Messages():
+				if !ok {
+					return
+				}
+				_ = conn.Send("echo", msg.Data)
+			}
+		}
+	}))
+	server := httptest.NewServer(r)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func wsToken(t *testing.T, userID string, ttl time.Duration) string {
+	t.Helper()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+		UserID:           userID,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl))},
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+	return signed
+}
+
+func dialWS(t *testing.T, server *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws" + query
+	ws, err := websocket.Dial(url, "", server.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func receiveWS(t *testing.T, ws *websocket.Conn) WebSocketMessage {
+	t.Helper()
+	require.NoError(t, ws.SetReadDeadline(time.Now().Add(2*time.Second)))
+	var msg WebSocketMessage
+	require.NoError(t, websocket.JSON.Receive(ws, &msg))
+	return msg
+}
+
+func TestWebSocket_QueryTokenAuth(t *testing.T) {
+	server := echoServer(t, WebSocketConfig{Auth: DefaultJWTConfig("secret")})
+	ws := dialWS(t, server, "?access_token="+wsToken(t, "u1", time.Hour))
+
+	ready := receiveWS(t, ws)
+	assert.Equal(t, WebSocketReady, ready.Type)
+	assert.JSONEq(t, `{"user_id":"u1"}`, string(ready.Data))
+
+	require.NoError(t, websocket.JSON.Send(ws, WebSocketMessage{Type: WebSocketPing}))
+	assert.Equal(t, WebSocketPong, receiveWS(t, ws).Type)
+
+	require.NoError(t, websocket.JSON.Send(ws, WebSocketMessage{Type: "subscribe", Data: json.RawMessage(`{"account_id":"a1"}`)}))
+	echo := receiveWS(t, ws)
+	assert.Equal(t, "echo", echo.Type)
+	assert.JSONEq(t, `{"account_id":"a1"}`, string(echo.Data))
+}
+
+func TestWebSocket_RejectsInvalidTokenBeforeUpgrade(t *testing.T) {
+	server := echoServer(t, WebSocketConfig{Auth: DefaultJWTConfig("secret")})
+
+	resp, err := http.Get(server.URL + "/ws?access_token=not-a-token")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestWebSocket_FirstMessageAuth(t *testing.T) {
+	server := echoServer(t, WebSocketConfig{Auth: DefaultJWTConfig("secret")})
+
+	ws := dialWS(t, server, "")
+	require.NoError(t, websocket.JSON.Send(ws, WebSocketMessage{Type: WebSocketAuth, Token: wsToken(t, "u1", time.Hour)}))
+	assert.Equal(t, WebSocketReady, receiveWS(t, ws).Type)
+
+	bad := dialWS(t, server, "")
+	require.NoError(t, websocket.JSON.Send(bad, WebSocketMessage{Type: "subscribe"}))
+	msg := receiveWS(t, bad)
+	assert.Equal(t, WebSocketError, msg.Type)
+	assert.Contains(t, string(msg.Data), "UNAUTHORIZED")
+	var next WebSocketMessage
+	assert.Error(t, websocket.JSON.Receive(bad, &next), "the connection is closed")
+}
+
+func TestWebSocket_MaxConnectionsPerUser(t *testing.T) {
+	registry := NewWebSocketRegistry(2)
+	server := echoServer(t, WebSocketConfig{Auth: DefaultJWTConfig("secret"), Registry: registry})
+	token := wsToken(t, "u1", time.Hour)
+
+	first := dialWS(t, server, "?access_token="+token)
+	receiveWS(t, first)
+	second := dialWS(t, server, "?access_token="+token)
+	receiveWS(t, second)
+	assert.Equal(t, 2, registry.Count("u1"))
+
+	resp, err := http.Get(server.URL + "/ws?access_token=" + token)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+
+	// Other users are unaffected, and pushes reach every connection of the user
+	other := dialWS(t, server, "?access_token="+wsToken(t, "u2", time.Hour))
+	receiveWS(t, other)
+	assert.Equal(t, 2, registry.Send("u1", "balance", gin.H{"balance": "10.00"}))
+	assert.Equal(t, "balance", receiveWS(t, first).Type)
+	assert.Equal(t, "balance", receiveWS(t, second).Type)
+
+	first.Close()
+	assert.Eventually(t, func() bool { return registry.Count("u1") == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestWebSocket_KeepAlive(t *testing.T) {
+	registry := NewWebSocketRegistry(0)
+	server := echoServer(t, WebSocketConfig{
+		Auth:         DefaultJWTConfig("secret"),
+		Registry:     registry,
+		PingInterval: 20 * time.Millisecond,
+		PongWait:     100 * time.Millisecond,
+	})
+	ws := dialWS(t, server, "?access_token="+wsToken(t, "u1", time.Hour))
+	receiveWS(t, ws)
+
+	// Answering pings keeps the connection open past the pong wait
+	deadline := time.Now().Add(250 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		msg := receiveWS(t, ws)
+		require.Equal(t, WebSocketPing, msg.Type)
+		require.NoError(t, websocket.JSON.Send(ws, WebSocketMessage{Type: WebSocketPong}))
+	}
+	assert.Equal(t, 1, registry.Count("u1"))
+
+	// A client that stops answering is dropped
+	assert.Eventually(t, func() bool { return registry.Count("u1") == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWebSocket_ClosesWhenTokenExpires(t *testing.T) {
+	server := echoServer(t, WebSocketConfig{Auth: DefaultJWTConfig("secret")})
+	ws := dialWS(t, server, "?access_token="+wsToken(t, "u1", 1500*time.Millisecond))
+	receiveWS(t, ws)
+
+	msg := receiveWS(t, ws)
+	assert.Equal(t, WebSocketClose, msg.Type)
+	assert.JSONEq(t, `{"reason":"token_expired"}`, string(msg.Data))
+}
+
+func TestRedactQueryToken(t *testing.T) {
+	assert.Equal(t, "page=2", redactQueryToken("page=2"))
+	assert.Equal(t, "access_token=REDACTED&page=2", redactQueryToken("page=2&access_token=eyJhbGciOi"))
+}
@@ -0,0 +1,210 @@
+// Package money represents amounts as a currency and an integer number of
+// the currency's minor units, so amounts cannot carry more precision than the
+// currency has (a tenth of a cent, a fraction of a yen) and arithmetic is exact.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidCurrency  = errors.New("currency must be a 3-letter ISO 4217 code")
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrPrecision        = errors.New("amount has more decimal places than the currency allows")
+	ErrOverflow         = errors.New("amount is out of range")
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// exponents are the ISO 4217 currencies whose minor unit is not a hundredth
+// of the major unit; every other currency has two decimal places
+var exponents = map[string]int{
+	// No minor unit
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	// Thousandths
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	// Ten-thousandths
+	"CLF": 4, "UYW": 4,
+}
+
+// DefaultExponent is the number of decimal places of currencies not listed in Exponents
+const DefaultExponent = 2
+
+// Exponent returns the number of decimal places of a currency's minor unit:
+// 2 for USD, 0 for JPY, 3 for KWD
+func Exponent(currency string) (int, error) {
+	if !currencyPattern.MatchString(currency) {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCurrency, currency)
+	}
+	if exp, ok := exponents[currency]; ok {
+		return exp, nil
+	}
+	return DefaultExponent, nil
+}
+
+// Exponents returns the currencies whose exponent is not DefaultExponent
+func Exponents() map[string]int {
+	out := make(map[string]int, len(exponents))
+	for currency, exp := range exponents {
+		out[currency] = exp
+	}
+	return out
+}
+
+// Money is an amount of a currency in its minor units. The zero value has no
+// currency and is only useful as a placeholder.
+type Money struct {
+	currency string
+	minor    int64
+}
+
+// New returns minor units of currency, e.g. New("USD", 1050) is 10.50 USD
+func New(currency string, minor int64) (Money, error) {
+	if _, err := Exponent(currency); err != nil {
+		return Money{}, err
+	}
+	return Money{currency: currency, minor: minor}, nil
+}
+
+// Zero returns no money in currency
+func Zero(currency string) (Money, error) {
+	return New(currency, 0)
+}
+
+// Parse reads a decimal amount in major units, such as "10.50", in currency.
+// Trailing zeros beyond the currency's decimal places are accepted ("10.500"
+// USD); significant digits beyond them are not ("10.505" USD, "100.5" JPY).
+func Parse(amount, currency string) (Money, error) {
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	return FromDecimal(d, currency)
+}
+
+// FromDecimal converts an amount in major units, as Parse does
+func FromDecimal(amount decimal.Decimal, currency string) (Money, error) {
+	exp, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	minor := amount.Shift(int32(exp))
+	if !minor.Equal(minor.Truncate(0)) {
+		return Money{}, fmt.Errorf("%w: %s %s has at most %d", ErrPrecision, amount, currency, exp)
+	}
+	if minor.GreaterThan(decimal.NewFromInt(math.MaxInt64)) || minor.LessThan(decimal.NewFromInt(math.MinInt64)) {
+		return Money{}, fmt.Errorf("%w: %s %s", ErrOverflow, amount, currency)
+	}
+	return Money{currency: currency, minor: minor.IntPart()}, nil
+}
+
+// Normalize checks that amount fits currency and returns it with exactly the
+// currency's decimal places, so 10.5 USD is 10.50 and 100.0 JPY is 100
+func Normalize(amount decimal.Decimal, currency string) (decimal.Decimal, error) {
+	m, err := FromDecimal(amount, currency)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return m.Decimal(), nil
+}
+
+// Currency is the ISO 4217 code of the amount
+func (m Money) Currency() string { return m.currency }
+
+// Minor is the amount in the currency's minor units
+func (m Money) Minor() int64 { return m.minor }
+
+// Decimal is the amount in major units, with the currency's decimal places
+func (m Money) Decimal() decimal.Decimal {
+	exp, _ := Exponent(m.currency)
+	return decimal.New(m.minor, -int32(exp))
+}
+
+// String formats the amount in major units with the currency's decimal
+// places, e.g. "10.50" for USD and "1050" for JPY
+func (m Money) String() string {
+	exp, _ := Exponent(m.currency)
+	return m.Decimal().StringFixed(int32(exp))
+}
+
+func (m Money) IsZero() bool     { return m.minor == 0 }
+func (m Money) IsPositive() bool { return m.minor > 0 }
+func (m Money) IsNegative() bool { return m.minor < 0 }
+
+// Neg returns the amount with the opposite sign
+func (m Money) Neg() (Money, error) {
+	if m.minor == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return Money{currency: m.currency, minor: -m.minor}, nil
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.currency != o.currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	sum := m.minor + o.minor
+	// An overflowing sum wraps round and ends up on the wrong side of m
+	if (o.minor > 0 && sum < m.minor) || (o.minor < 0 && sum > m.minor) {
+		return Money{}, ErrOverflow
+	}
+	return Money{currency: m.currency, minor: sum}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	neg, err := o.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(neg)
+}
+
+// Cmp compares m and o, returning -1, 0 or +1. Both must be in the same currency.
+func (m Money) Cmp(o Money) (int, error) {
+	if m.currency != o.currency {
+		return 0, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.currency, o.currency)
+	}
+	switch {
+	case m.minor < o.minor:
+		return -1, nil
+	case m.minor > o.minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// moneyJSON is how Money is written in JSON: the amount as a string in major
+// units, as amounts are elsewhere in the API, so it is never read as a float
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.currency})
+}
+
+// UnmarshalJSON reads {"amount": "10.50", "currency": "USD"}, rejecting
+// amounts with more decimal places than the currency has
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := Parse(v.Amount, v.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,109 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponent(t *testing.T) {
+	for currency, want := range map[string]int{"USD": 2, "GBP": 2, "JPY": 0, "KRW": 0, "KWD": 3, "CLF": 4} {
+		exp, err := Exponent(currency)
+		require.NoError(t, err, currency)
+		assert.Equal(t, want, exp, currency)
+	}
+	for _, currency := range []string{"", "usd", "US", "USDT"} {
+		_, err := Exponent(currency)
+		assert.ErrorIs(t, err, ErrInvalidCurrency, currency)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		amount, currency string
+		minor            int64
+		formatted        string
+	}{
+		{"10.50", "USD", 1050, "10.50"},
+		{"10.5", "USD", 1050, "10.50"},
+		{"10.500", "USD", 1050, "10.50"},
+		{"-3", "EUR", -300, "-3.00"},
+		{"1050", "JPY", 1050, "1050"},
+		{"1050.00", "JPY", 1050, "1050"},
+		{"1.234", "KWD", 1234, "1.234"},
+	}
+	for _, c := range cases {
+		m, err := Parse(c.amount, c.currency)
+		require.NoError(t, err, c.amount+" "+c.currency)
+		assert.Equal(t, c.minor, m.Minor(), c.amount+" "+c.currency)
+		assert.Equal(t, c.formatted, m.String(), c.amount+" "+c.currency)
+	}
+
+	_, err := Parse("10.505", "USD")
+	assert.ErrorIs(t, err, ErrPrecision)
+	_, err = Parse("100.5", "JPY")
+	assert.ErrorIs(t, err, ErrPrecision)
+	_, err = Parse("ten", "USD")
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+	_, err = Parse("100000000000000000000", "USD")
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestNormalize(t *testing.T) {
+	d, err := Normalize(decimal.RequireFromString("10.5"), "USD")
+	require.NoError(t, err)
+	assert.True(t, d.Equal(decimal.RequireFromString("10.50")))
+	assert.Equal(t, "10.5", d.String())
+
+	_, err = Normalize(decimal.RequireFromString("0.001"), "GBP")
+	assert.ErrorIs(t, err, ErrPrecision)
+}
+
+func TestArithmeticGuards(t *testing.T) {
+	usd, _ := Parse("10.25", "USD")
+	more, _ := Parse("0.75", "USD")
+	jpy, _ := Parse("100", "JPY")
+
+	sum, err := usd.Add(more)
+	require.NoError(t, err)
+	assert.Equal(t, "11.00", sum.String())
+	diff, err := usd.Sub(more)
+	require.NoError(t, err)
+	assert.Equal(t, "9.50", diff.String())
+	cmp, err := usd.Cmp(more)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cmp)
+
+	_, err = usd.Add(jpy)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+	_, err = usd.Cmp(jpy)
+	assert.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	max, _ := New("USD", math.MaxInt64)
+	one, _ := New("USD", 1)
+	_, err = max.Add(one)
+	assert.ErrorIs(t, err, ErrOverflow)
+	min, _ := New("USD", math.MinInt64)
+	_, err = min.Sub(one)
+	assert.ErrorIs(t, err, ErrOverflow)
+	_, err = min.Neg()
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func TestJSON(t *testing.T) {
+	m, _ := Parse("1050", "JPY")
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1050","currency":"JPY"}`, string(data))
+
+	var decoded Money
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"12.3","currency":"USD"}`), &decoded))
+	assert.Equal(t, int64(1230), decoded.Minor())
+	assert.Equal(t, "USD", decoded.Currency())
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"12.5","currency":"JPY"}`), &decoded), ErrPrecision)
+}
@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// componentSections are the components a merged document combines
+var componentSections = []string{"schemas", "parameters", "responses", "requestBodies", "headers", "securitySchemes", "examples", "links", "callbacks"}
+
+// Merge combines the specs of several services, keyed by service name, into
+// one document for a gateway. Paths must not overlap. Components with the same
+// name and content are shared; ones whose content differs are renamed to
+// "<service>_<name>" and the references to them rewritten.
+func Merge(title, version string, specs map[string]*Spec) (*Spec, error) {
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	paths := make(map[string]interface{})
+	components := make(map[string]interface{})
+	var tags []interface{}
+	seenTags := make(map[string]bool)
+
+	for _, service := range names {
+		// Work on a copy so the service's own spec is left untouched
+		doc := deepCopy(specs[service].doc).(map[string]interface{})
+
+		renames := make(map[string]string)
+		docComponents, _ := doc["components"].(map[string]interface{})
+		for _, section := range componentSections {
+			entries, _ := docComponents[section].(map[string]interface{})
+			merged, _ := components[section].(map[string]interface{})
+			for name, value := range entries {
+				existing, taken := merged[name]
+				if taken && !reflect.DeepEqual(existing, value) {
+					renames[componentRef(section, name)] = componentRef(section, service+"_"+name)
+				}
+			}
+		}
+		if len(renames) > 0 {
+			doc = rewriteRefs(doc, renames).(map[string]interface{})
+			docComponents, _ = doc["components"].(map[string]interface{})
+		}
+
+		for _, section := range componentSections {
+			entries, _ := docComponents[section].(map[string]interface{})
+			if len(entries) == 0 {
+				continue
+			}
+			merged, _ := components[section].(map[string]interface{})
+			if merged == nil {
+				merged = make(map[string]interface{})
+				components[section] = merged
+			}
+			for name, value := range entries {
+				if _, renamed := renames[componentRef(section, name)]; renamed {
+					name = service + "_" + name
+				}
+				merged[name] = value
+			}
+		}
+
+		docPaths, _ := doc["paths"].(map[string]interface{})
+		for path, item := range docPaths {
+			if _, exists := paths[path]; exists {
+				return nil, fmt.Errorf("path %s is documented by more than one service", path)
+			}
+			paths[path] = item
+		}
+
+		docTags, _ := doc["tags"].([]interface{})
+		for _, tag := range docTags {
+			t, _ := tag.(map[string]interface{})
+			name, _ := t["name"].(string)
+			if !seenTags[name] {
+				seenTags[name] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	merged := map[string]interface{}{
+		"openapi":    "3.0.3",
+		"info":       map[string]interface{}{"title": title, "version": version},
+		"paths":      paths,
+		"components": components,
+	}
+	if len(tags) > 0 {
+		merged["tags"] = tags
+	}
+	return newSpec(merged)
+}
+
+func componentRef(section, name string) string {
+	return "#/components/" + section + "/" + name
+}
+
+// rewriteRefs replaces $ref values according to renames
+func rewriteRefs(v interface{}, renames map[string]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if ref, ok := item.(string); ok && k == "$ref" {
+				if renamed, found := renames[ref]; found {
+					v[k] = renamed
+				}
+				continue
+			}
+			v[k] = rewriteRefs(item, renames)
+		}
+		// Discriminator mappings also hold refs
+		if mapping, ok := v["mapping"].(map[string]interface{}); ok {
+			for k, ref := range mapping {
+				if s, ok := ref.(string); ok && strings.HasPrefix(s, "#/components/") {
+					if renamed, found := renames[s]; found {
+						mapping[k] = renamed
+					}
+				}
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = rewriteRefs(item, renames)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = deepCopy(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = deepCopy(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,235 @@
+// Package openapi serves a service's OpenAPI 3 document, merges the documents
+// of several services into one, and checks a document against the routes a
+// gin engine actually serves.
+//
+// Each service keeps its spec in api/openapi.yaml and embeds it from the api
+// package, so the served spec is exactly the reviewed file. A test in each
+// service calls CheckRoutes to keep the spec and the router in sync.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Paths the spec is served on
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+// methods are the OpenAPI operation keys of a path item, upper-cased as HTTP methods
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is a parsed OpenAPI document
+type Spec struct {
+	doc  map[string]interface{}
+	json []byte
+}
+
+// Load parses an OpenAPI document in YAML or JSON
+func Load(data []byte) (*Spec, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	doc, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid OpenAPI document: not an object")
+	}
+	if version, _ := doc["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("invalid OpenAPI document: unsupported version %q", version)
+	}
+	return newSpec(doc)
+}
+
+// MustLoad is Load for embedded specs, which are checked by tests
+func MustLoad(data []byte) *Spec {
+	spec, err := Load(data)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+func newSpec(doc map[string]interface{}) (*Spec, error) {
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Spec{doc: doc, json: encoded}, nil
+}
+
+// JSON returns the document encoded as JSON
+func (s *Spec) JSON() []byte {
+	return s.json
+}
+
+// Operation is one documented method and path, in OpenAPI path syntax
+type Operation struct {
+	Method string
+	Path   string
+}
+
+func (o Operation) String() string {
+	return o.Method + " " + o.Path
+}
+
+// Operations lists the documented operations, sorted by path then method
+func (s *Spec) Operations() []Operation {
+	paths, _ := s.doc["paths"].(map[string]interface{})
+	var ops []Operation
+	for path, item := range paths {
+		item, _ := item.(map[string]interface{})
+		for _, m := range methods {
+			if _, ok := item[m]; ok {
+				ops = append(ops, Operation{Method: strings.ToUpper(m), Path: path})
+			}
+		}
+	}
+	sortOperations(ops)
+	return ops
+}
+
+// Register serves the spec as JSON on SpecPath and a Swagger UI page for it on DocsPath
+func Register(r gin.IRoutes, spec *Spec) {
+	r.GET(SpecPath, func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", spec.JSON())
+	})
+	r.GET(DocsPath, func(c *gin.Context) {
+		// The page loads Swagger UI from a CDN, which the API's default policy forbids
+		c.Header("Content-Security-Policy", "default-src 'self'; script-src 'self' "+swaggerUICDN+"; style-src 'self' 'unsafe-inline' "+swaggerUICDN+"; img-src 'self' data:; connect-src 'self'")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	})
+	r.GET(DocsPath+"/init.js", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/javascript; charset=utf-8", []byte(docsScript))
+	})
+}
+
+// CheckRoutes compares the documented operations with the routes a gin engine
+// serves (engine.Routes()). It returns an error listing routes missing from
+// the spec and operations no route serves. The spec's own routes and any
+// paths in skip, such as /metrics, are left out of the comparison.
+func CheckRoutes(spec *Spec, routes gin.RoutesInfo, skip ...string) error {
+	skipped := map[string]bool{SpecPath: true, DocsPath: true, DocsPath + "/init.js": true}
+	for _, p := range skip {
+		skipped[p] = true
+	}
+
+	served := make(map[Operation]bool)
+	for _, route := range routes {
+		op := Operation{Method: route.Method, Path: ginPathToOpenAPI(route.Path)}
+		if !skipped[op.Path] {
+			served[op] = true
+		}
+	}
+	documented := make(map[Operation]bool)
+	for _, op := range spec.Operations() {
+		if !skipped[op.Path] {
+			documented[op] = true
+		}
+	}
+
+	var undocumented, unserved []Operation
+	for op := range served {
+		if !documented[op] {
+			undocumented = append(undocumented, op)
+		}
+	}
+	for op := range documented {
+		if !served[op] {
+			unserved = append(unserved, op)
+		}
+	}
+	if len(undocumented) == 0 && len(unserved) == 0 {
+		return nil
+	}
+
+	sortOperations(undocumented)
+	sortOperations(unserved)
+	var b strings.Builder
+	b.WriteString("OpenAPI spec is out of sync with the router")
+	for _, op := range undocumented {
+		fmt.Fprintf(&b, "\n  not documented: %s", op)
+	}
+	for _, op := range unserved {
+		fmt.Fprintf(&b, "\n  documented but not served: %s", op)
+	}
+	return errors.New(b.String())
+}
+
+// ginPathToOpenAPI rewrites gin path parameters (":id", "*path") as OpenAPI templates ("{id}")
+func ginPathToOpenAPI(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func sortOperations(ops []Operation) {
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+}
+
+// normalize converts YAML maps with non-string keys (e.g. unquoted response
+// codes) into JSON-compatible maps
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = normalize(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[fmt.Sprint(k)] = normalize(item)
+		}
+		return out
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+const swaggerUICDN = "https://cdn.jsdelivr.net"
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API documentation</title>
+  <link rel="stylesheet" href="` + swaggerUICDN + `/npm/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="` + swaggerUICDN + `/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script src="docs/init.js"></script>
+</body>
+</html>
+`
+
+// docsScript is served from the service itself so the page needs no inline script
+const docsScript = `window.ui = SwaggerUIBundle({
+  url: new URL("openapi.json", document.baseURI).href,
+  dom_id: "#swagger-ui",
+});
+`
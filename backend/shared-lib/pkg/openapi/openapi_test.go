@@ -0,0 +1,201 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const accountsSpec = `
+openapi: 3.0.3
+info:
+  title: Accounts
+  version: 1.0.0
+tags:
+  - name: Accounts
+paths:
+  /api/v1/accounts:
+    get:
+      responses:
+        200:
+          description: Accounts
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Account"
+    post:
+      responses:
+        "201":
+          description: Created
+  /api/v1/accounts/{id}:
+    get:
+      responses:
+        "200":
+          description: Account
+components:
+  securitySchemes:
+    BearerAuth:
+      type: http
+      scheme: bearer
+  schemas:
+    Account:
+      type: object
+      properties:
+        id:
+          type: string
+    Error:
+      type: object
+`
+
+const cardsSpec = `
+openapi: 3.0.3
+info:
+  title: Cards
+  version: 1.0.0
+tags:
+  - name: Cards
+paths:
+  /api/v1/cards:
+    get:
+      responses:
+        "200":
+          description: Cards
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Account"
+components:
+  securitySchemes:
+    BearerAuth:
+      type: http
+      scheme: bearer
+  schemas:
+    Account:
+      type: object
+      properties:
+        card_account:
+          type: string
+    Error:
+      type: object
+`
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestLoad(t *testing.T) {
+	spec, err := Load([]byte(accountsSpec))
+	require.NoError(t, err)
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(spec.JSON(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	// Unquoted response codes become string keys
+	responses := doc["paths"].(map[string]interface{})["/api/v1/accounts"].(map[string]interface{})["get"].(map[string]interface{})["responses"].(map[string]interface{})
+	assert.Contains(t, responses, "200")
+
+	assert.Equal(t, []Operation{
+		{Method: "GET", Path: "/api/v1/accounts"},
+		{Method: "POST", Path: "/api/v1/accounts"},
+		{Method: "GET", Path: "/api/v1/accounts/{id}"},
+	}, spec.Operations())
+
+	_, err = Load([]byte("swagger: '2.0'\n"))
+	assert.Error(t, err)
+	_, err = Load([]byte("- not a document"))
+	assert.Error(t, err)
+}
+
+func TestRegister(t *testing.T) {
+	r := gin.New()
+	Register(r, MustLoad([]byte(accountsSpec)))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, SpecPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/json")
+	assert.True(t, json.Valid(w.Body.Bytes()))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DocsPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "swagger-ui")
+	assert.Contains(t, w.Header().Get("Content-Security-Policy"), swaggerUICDN)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DocsPath+"/init.js", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "openapi.json")
+}
+
+func TestCheckRoutes(t *testing.T) {
+	spec := MustLoad([]byte(accountsSpec))
+	noop := func(*gin.Context) {}
+
+	r := gin.New()
+	Register(r, spec)
+	r.GET("/metrics", noop)
+	api := r.Group("/api/v1")
+	api.GET("/accounts", noop)
+	api.POST("/accounts", noop)
+	api.GET("/accounts/:id", noop)
+	assert.NoError(t, CheckRoutes(spec, r.Routes(), "/metrics"))
+
+	// An undocumented route and a documented operation without a route are both reported
+	r = gin.New()
+	api = r.Group("/api/v1")
+	api.GET("/accounts", noop)
+	api.GET("/accounts/:id", noop)
+	api.DELETE("/accounts/:id", noop)
+	err := CheckRoutes(spec, r.Routes())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not documented: DELETE /api/v1/accounts/{id}")
+	assert.Contains(t, err.Error(), "documented but not served: POST /api/v1/accounts")
+}
+
+func TestGinPathToOpenAPI(t *testing.T) {
+	assert.Equal(t, "/api/v1/accounts/{id}/balance", ginPathToOpenAPI("/api/v1/accounts/:id/balance"))
+	assert.Equal(t, "/files/{path}", ginPathToOpenAPI("/files/*path"))
+	assert.Equal(t, "/health", ginPathToOpenAPI("/health"))
+}
+
+func TestMerge(t *testing.T) {
+	accounts := MustLoad([]byte(accountsSpec))
+	cards := MustLoad([]byte(cardsSpec))
+
+	merged, err := Merge("NeoBank API", "1.0.0", map[string]*Spec{"ledger": accounts, "card": cards})
+	require.NoError(t, err)
+
+	assert.Len(t, merged.Operations(), 4)
+
+	var doc struct {
+		Info       map[string]string `json:"info"`
+		Tags       []map[string]string
+		Paths      map[string]map[string]json.RawMessage
+		Components map[string]map[string]json.RawMessage
+	}
+	require.NoError(t, json.Unmarshal(merged.JSON(), &doc))
+	assert.Equal(t, "NeoBank API", doc.Info["title"])
+	assert.Len(t, doc.Tags, 2)
+
+	// Identical components are shared; conflicting ones are renamed for the later service
+	schemas := doc.Components["schemas"]
+	assert.Contains(t, schemas, "Error")
+	assert.NotContains(t, schemas, "card_Error")
+	assert.Contains(t, schemas, "Account")
+	assert.Contains(t, schemas, "ledger_Account")
+	assert.Contains(t, doc.Components["securitySchemes"], "BearerAuth")
+	assert.Contains(t, string(doc.Paths["/api/v1/accounts"]["get"]), "#/components/schemas/ledger_Account")
+	assert.Contains(t, string(doc.Paths["/api/v1/cards"]["get"]), `"#/components/schemas/Account"`)
+
+	// The inputs are not modified
+	assert.NotContains(t, string(accounts.JSON()), "ledger_Account")
+
+	_, err = Merge("Dup", "1", map[string]*Spec{"a": accounts, "b": accounts})
+	assert.ErrorContains(t, err, "more than one service")
+}
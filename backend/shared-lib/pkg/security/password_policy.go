@@ -0,0 +1,72 @@
+package security
+
+import "time"
+
+// Character classes a password policy can require
+const (
+	CharClassUpper  = "upper"
+	CharClassLower  = "lower"
+	CharClassDigit  = "digit"
+	CharClassSymbol = "symbol"
+)
+
+// PasswordPolicyConfig configures the password rules enforced by the identity
+// service. Zero values take the defaults from DefaultPasswordPolicyConfig.
+type PasswordPolicyConfig struct {
+	MinLength int `mapstructure:"min_length"`
+	MaxLength int `mapstructure:"max_length"`
+	// RequiredClasses are the character classes every password must contain.
+	// Nil requires all four; an empty list requires none.
+	RequiredClasses []string `mapstructure:"required_classes"`
+	// Denylist holds passwords that are always rejected, compared case-insensitively
+	Denylist    []string          `mapstructure:"denylist"`
+	BreachCheck BreachCheckConfig `mapstructure:"breach_check"`
+}
+
+// BreachCheckConfig configures the check of new passwords against known breaches
+type BreachCheckConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// APIURL is a k-anonymity range API (Have I Been Pwned compatible). Only the
+	// first five hex characters of the password's SHA-1 are sent.
+	APIURL  string        `mapstructure:"api_url"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// BloomFilterPath is a local filter of breached SHA-1 hashes, used when the
+	// API cannot be reached and on its own in offline mode
+	BloomFilterPath string `mapstructure:"bloom_filter_path"`
+	Offline         bool   `mapstructure:"offline"`
+}
+
+// DefaultPasswordPolicyConfig requires 12 to 128 characters with upper and
+// lower case letters, a digit and a symbol. The breach check is off.
+func DefaultPasswordPolicyConfig() PasswordPolicyConfig {
+	return PasswordPolicyConfig{
+		MinLength:       12,
+		MaxLength:       128,
+		RequiredClasses: []string{CharClassUpper, CharClassLower, CharClassDigit, CharClassSymbol},
+		BreachCheck: BreachCheckConfig{
+			APIURL:  "https://api.pwnedpasswords.com",
+			Timeout: 2 * time.Second,
+		},
+	}
+}
+
+// WithDefaults fills unset fields from DefaultPasswordPolicyConfig
+func (c PasswordPolicyConfig) WithDefaults() PasswordPolicyConfig {
+	d := DefaultPasswordPolicyConfig()
+	if c.MinLength == 0 {
+		c.MinLength = d.MinLength
+	}
+	if c.MaxLength == 0 {
+		c.MaxLength = d.MaxLength
+	}
+	if c.RequiredClasses == nil {
+		c.RequiredClasses = d.RequiredClasses
+	}
+	if c.BreachCheck.APIURL == "" {
+		c.BreachCheck.APIURL = d.BreachCheck.APIURL
+	}
+	if c.BreachCheck.Timeout == 0 {
+		c.BreachCheck.Timeout = d.BreachCheck.Timeout
+	}
+	return c
+}
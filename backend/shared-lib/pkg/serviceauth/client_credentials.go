@@ -0,0 +1,143 @@
+// Package serviceauth authenticates service-to-service calls with short-lived
+// tokens obtained through the OAuth 2.0 client credentials grant.
+package serviceauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// expiryMargin renews a cached token this long before it expires so requests
+// in flight do not carry a token that lapses on arrival
+const expiryMargin = 30 * time.Second
+
+// ClientCredentials fetches access tokens for a service account from the
+// identity service's token endpoint and caches them until shortly before expiry
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Audience names the services the token is for; a token without an
+	// audience is rejected by services that check it
+	Audience   []string
+	HTTPClient *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewClientCredentials creates a token source for a service account
+func NewClientCredentials(tokenURL, clientID, clientSecret string, scopes ...string) *ClientCredentials {
+	return &ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Token returns a valid access token, requesting a new one when the cached
+// token is missing or about to expire
+func (c *ClientCredentials) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Add(expiryMargin).Before(c.expiry) {
+		return c.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if len(c.Audience) > 0 {
+		form.Set("audience", strings.Join(c.Audience, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+
+	requestedAt := time.Now()
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request service token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode service token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", fmt.Errorf("service token request failed with status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+
+	c.token = body.AccessToken
+	c.expiry = requestedAt.Add(time.Duration(body.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// Invalidate drops the cached token so the next call fetches a new one
+func (c *ClientCredentials) Invalidate() {
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+}
+
+// Client returns an HTTP client that sends a bearer token from c with every request
+func (c *ClientCredentials) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &Transport{Source: c, Base: http.DefaultTransport},
+	}
+}
+
+// Transport is an http.RoundTripper that authenticates requests with a service
+// token and propagates the caller's trace context and baggage
+type Transport struct {
+	Source *ClientCredentials
+	Base   http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the caller's request
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+token)
+	// The called service continues the trace, with its business baggage
+	otel.GetTextMapPropagator().Inject(req.Context(), propagation.HeaderCarrier(authed.Header))
+
+	resp, err := t.Base.RoundTrip(authed)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		// The token may have been revoked or signed with a rotated key
+		t.Source.Invalidate()
+	}
+	return resp, err
+}
@@ -0,0 +1,112 @@
+package serviceauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tokenServer(t *testing.T, expiresIn int, issued *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "svc_payment" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "ledger:read ledger:write", r.PostForm.Get("scope"))
+		assert.Equal(t, "ledger-service", r.PostForm.Get("audience"))
+
+		n := atomic.AddInt32(issued, 1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token-" + string(rune('0'+n)),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		})
+	}))
+}
+
+func TestClientCredentials_CachesToken(t *testing.T) {
+	var issued int32
+	srv := tokenServer(t, 300, &issued)
+	defer srv.Close()
+
+	source := NewClientCredentials(srv.URL, "svc_payment", "s3cret", "ledger:read", "ledger:write")
+	source.Audience = []string{"ledger-service"}
+	first, err := source.Token(t.Context())
+	require.NoError(t, err)
+	second, err := source.Token(t.Context())
+	require.NoError(t, err)
+
+	assert.Equal(t, "token-1", first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+}
+
+func TestClientCredentials_RenewsNearExpiry(t *testing.T) {
+	var issued int32
+	srv := tokenServer(t, int(expiryMargin/time.Second), &issued)
+	defer srv.Close()
+
+	source := NewClientCredentials(srv.URL, "svc_payment", "s3cret", "ledger:read", "ledger:write")
+	source.Audience = []string{"ledger-service"}
+	_, err := source.Token(t.Context())
+	require.NoError(t, err)
+	second, err := source.Token(t.Context())
+	require.NoError(t, err)
+
+	assert.Equal(t, "token-2", second)
+}
+
+func TestClientCredentials_RejectedClient(t *testing.T) {
+	var issued int32
+	srv := tokenServer(t, 300, &issued)
+	defer srv.Close()
+
+	_, err := NewClientCredentials(srv.URL, "svc_payment", "wrong", "ledger:read", "ledger:write").Token(t.Context())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_client")
+}
+
+func TestTransport_AddsBearerAndDropsRejectedToken(t *testing.T) {
+	var issued int32
+	tokens := tokenServer(t, 300, &issued)
+	defer tokens.Close()
+
+	var seen []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		if len(seen) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer api.Close()
+
+	source := NewClientCredentials(tokens.URL, "svc_payment", "s3cret", "ledger:read", "ledger:write")
+	source.Audience = []string{"ledger-service"}
+	client := source.Client(5 * time.Second)
+
+	req, _ := http.NewRequest(http.MethodGet, api.URL, nil)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Empty(t, req.Header.Get("Authorization"), "caller's request is not modified")
+
+	resp, err = client.Get(api.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, []string{"Bearer token-1", "Bearer token-2"}, seen)
+}
@@ -0,0 +1,61 @@
+// Package tenant scopes data to the organization a request acts for. Business
+// users get tokens carrying an org_id; personal tokens have none.
+package tenant
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Roles a member can hold in an organization, from most to least privileged
+const (
+	RoleOwner  = "OWNER"
+	RoleAdmin  = "ADMIN"
+	RoleMember = "MEMBER"
+	RoleViewer = "VIEWER"
+)
+
+// Roles lists the valid organization roles
+var Roles = []string{RoleOwner, RoleAdmin, RoleMember, RoleViewer}
+
+// IsValidRole reports whether role is one of Roles
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey struct{}
+
+// WithOrgID returns a context carrying the organization the request acts for
+func WithOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, orgID)
+}
+
+// OrgID returns the organization in ctx, or "" for personal requests
+func OrgID(ctx context.Context) string {
+	orgID, _ := ctx.Value(contextKey{}).(string)
+	return orgID
+}
+
+// Scope restricts a query to rows of the organization, or to rows without an
+// organization when orgID is empty. The table must have an org_id column.
+//
+//	db.Scopes(tenant.Scope(orgID)).Where("user_id = ?", userID).Find(&accounts)
+func Scope(orgID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if orgID == "" {
+			return db.Where("org_id IS NULL")
+		}
+		return db.Where("org_id = ?", orgID)
+	}
+}
+
+// ScopeContext is Scope for the organization in ctx
+func ScopeContext(ctx context.Context) func(*gorm.DB) *gorm.DB {
+	return Scope(OrgID(ctx))
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Baggage keys of the business identifiers carried with a request across
+// services and Kafka topics
+const (
+	BaggagePaymentID = "payment_id"
+	BaggageAccountID = "account_id"
+	// BaggageUserHash is the user ID hashed with HashUserID; baggage is sent
+	// to every downstream service in clear, so it never carries the ID itself
+	BaggageUserHash = "user_hash"
+)
+
+// AttrUserHash is the span attribute of a hashed user ID
+var AttrUserHash = attribute.Key("user.hash")
+
+// baggageAttributes maps the business baggage keys to span attributes
+var baggageAttributes = []struct {
+	member string
+	attr   attribute.Key
+}{
+	{BaggagePaymentID, AttrPaymentID},
+	{BaggageAccountID, AttrAccountID},
+	{BaggageUserHash, AttrUserHash},
+}
+
+// BusinessContext identifies what a request is about. Empty fields are left
+// as they are.
+type BusinessContext struct {
+	PaymentID string
+	AccountID string
+	// UserID is hashed before it is put in baggage
+	UserID string
+}
+
+// HashUserID returns the pseudonymous form of a user ID that traces carry, so
+// a user's traces can be found without their ID leaving the services
+func HashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:8])
+}
+
+// WithBusinessContext returns a copy of ctx whose baggage carries the
+// identifiers of bc, set at the edge so that spans in downstream services and
+// Kafka consumers get them as attributes, see BaggageSpanProcessor. The
+// current span gets them straight away.
+func WithBusinessContext(ctx context.Context, bc BusinessContext) context.Context {
+	bag := baggage.FromContext(ctx)
+	for key, value := range map[string]string{
+		BaggagePaymentID: bc.PaymentID,
+		BaggageAccountID: bc.AccountID,
+		BaggageUserHash:  hashIfSet(bc.UserID),
+	} {
+		if value == "" {
+			continue
+		}
+		member, err := baggage.NewMemberRaw(key, value)
+		if err != nil {
+			slog.Debug("Invalid baggage member", "key", key, "error", err)
+			continue
+		}
+		if bag, err = bag.SetMember(member); err != nil {
+			slog.Debug("Failed to set baggage member", "key", key, "error", err)
+		}
+	}
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+	trace.SpanFromContext(ctx).SetAttributes(BusinessAttributes(ctx)...)
+	return ctx
+}
+
+func hashIfSet(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	return HashUserID(userID)
+}
+
+// BusinessAttributes returns the business identifiers in the baggage of ctx
+// as span attributes
+func BusinessAttributes(ctx context.Context) []attribute.KeyValue {
+	bag := baggage.FromContext(ctx)
+	var attrs []attribute.KeyValue
+	for _, b := range baggageAttributes {
+		if value := bag.Member(b.member).Value(); value != "" {
+			attrs = append(attrs, b.attr.String(value))
+		}
+	}
+	return attrs
+}
+
+// BaggageSpanProcessor adds the business identifiers in a span's parent
+// context to the span when it starts, so every span of a request, including
+// those of Kafka consumers, can be searched by them
+type BaggageSpanProcessor struct{}
+
+var _ sdktrace.SpanProcessor = BaggageSpanProcessor{}
+
+func (BaggageSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs := BusinessAttributes(parent); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+func (BaggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (BaggageSpanProcessor) Shutdown(context.Context) error   { return nil }
+func (BaggageSpanProcessor) ForceFlush(context.Context) error { return nil }
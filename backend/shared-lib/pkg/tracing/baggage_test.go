@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithBusinessContext(t *testing.T) {
+	ctx := WithBusinessContext(context.Background(), BusinessContext{AccountID: "acc-1", UserID: "user-1"})
+	ctx = WithBusinessContext(ctx, BusinessContext{PaymentID: "pay-1"})
+
+	bag := baggage.FromContext(ctx)
+	assert.Equal(t, "pay-1", bag.Member(BaggagePaymentID).Value())
+	assert.Equal(t, "acc-1", bag.Member(BaggageAccountID).Value(), "empty fields keep the earlier value")
+	assert.Equal(t, HashUserID("user-1"), bag.Member(BaggageUserHash).Value())
+	assert.NotContains(t, bag.String(), "user-1")
+	assert.Len(t, HashUserID("user-1"), 16)
+	assert.NotEqual(t, HashUserID("user-1"), HashUserID("user-2"))
+}
+
+func TestBaggageSpanProcessor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(BaggageSpanProcessor{}),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	tracer := provider.Tracer("test")
+
+	ctx, edge := tracer.Start(context.Background(), "edge")
+	ctx = WithBusinessContext(ctx, BusinessContext{PaymentID: "pay-1"})
+	_, child := tracer.Start(ctx, "downstream")
+	child.End()
+	edge.End()
+	_, unrelated := tracer.Start(context.Background(), "unrelated")
+	unrelated.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	for _, span := range spans[:2] {
+		assert.Contains(t, span.Attributes(), AttrPaymentID.String("pay-1"), span.Name())
+	}
+	assert.NotContains(t, spans[2].Attributes(), AttrPaymentID.String("pay-1"))
+	assert.Equal(t, []attribute.KeyValue{AttrPaymentID.String("pay-1")}, BusinessAttributes(ctx))
+}
@@ -87,6 +87,8 @@ func InitTracing(ctx context.Context, cfg Config) (*TracerProvider, error) {
 
 	// Create tracer provider
 	provider := sdktrace.NewTracerProvider(
+		// Business identifiers in baggage become attributes of every span
+		sdktrace.WithSpanProcessor(BaggageSpanProcessor{}),
 		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(res),
 		sdktrace.WithSampler(sdktrace.AlwaysSample()),
@@ -0,0 +1,139 @@
+package validation
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/femi-lawal/new_bank/backend/shared-lib/pkg/money"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// Custom rules, usable in binding tags next to validator's own such as
+// required, max and uuid
+const (
+	// RuleCurrency is a 3-letter upper-case ISO 4217 code
+	RuleCurrency = "currency"
+	// RuleAmount is a positive plain decimal, e.g. "12.50"; whether it fits
+	// the currency's precision is left to the service
+	RuleAmount = "amount"
+	// RuleIBAN is an IBAN with valid check digits, in any case and with or
+	// without spaces
+	RuleIBAN = "iban"
+	// RuleIdempotencyKey is a client-chosen key that makes a request safe to
+	// retry, see IdempotencyKey
+	RuleIdempotencyKey = "idempotency_key"
+)
+
+var (
+	amountPattern         = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+	ibanPattern           = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[A-Z0-9]{11,30}$`)
+	idempotencyKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]{8,128}$`)
+)
+
+// rules maps each custom rule to its check
+var rules = map[string]func(string) bool{
+	RuleCurrency:       Currency,
+	RuleAmount:         Amount,
+	RuleIBAN:           IBAN,
+	RuleIdempotencyKey: IdempotencyKey,
+}
+
+// Currency reports whether s is a 3-letter upper-case currency code
+func Currency(s string) bool {
+	_, err := money.Exponent(s)
+	return err == nil
+}
+
+// Amount reports whether s is a positive decimal without sign or exponent
+func Amount(s string) bool {
+	if !amountPattern.MatchString(s) {
+		return false
+	}
+	d, err := decimal.NewFromString(s)
+	return err == nil && d.IsPositive()
+}
+
+// NormalizeIBAN removes spaces and upper-cases an IBAN as customers type it
+func NormalizeIBAN(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+}
+
+// IBAN reports whether s, once normalized, has the format and ISO 13616
+// check digits of an IBAN
+func IBAN(s string) bool {
+	iban := NormalizeIBAN(s)
+	if !ibanPattern.MatchString(iban) {
+		return false
+	}
+	var digits strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		if r >= 'A' && r <= 'Z' {
+			fmt.Fprintf(&digits, "%d", r-'A'+10)
+		} else {
+			digits.WriteRune(r)
+		}
+	}
+	n, ok := new(big.Int).SetString(digits.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// IdempotencyKey reports whether s is 8 to 128 letters, digits and "_.:-",
+// long enough for a UUID or a prefixed reference
+func IdempotencyKey(s string) bool {
+	return idempotencyKeyPattern.MatchString(s)
+}
+
+// registerRules adds the custom rules to v. Rules apply to string fields;
+// on other kinds they fail.
+func registerRules(v *validator.Validate) error {
+	for tag, check := range rules {
+		check := check
+		err := v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			s, ok := fl.Field().Interface().(string)
+			return ok && check(s)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to register %s rule: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+// ruleMessages describe a failed rule to the client; %s is the rule's parameter
+var ruleMessages = map[string]string{
+	"required":         "is required",
+	"len":              "must have length %s",
+	"min":              "must be at least %s",
+	"max":              "must be at most %s",
+	"gt":               "must be greater than %s",
+	"gte":              "must be at least %s",
+	"lt":               "must be less than %s",
+	"lte":              "must be at most %s",
+	"oneof":            "must be one of: %s",
+	"email":            "must be an email address",
+	"url":              "must be a URL",
+	"uuid":             "must be a UUID",
+	"datetime":         "must be a date in the format %s",
+	RuleCurrency:       "must be a 3-letter ISO 4217 currency code",
+	RuleAmount:         "must be a positive decimal amount, e.g. 12.50",
+	RuleIBAN:           "must be a valid IBAN",
+	RuleIdempotencyKey: "must be 8 to 128 letters, digits or _.:-",
+}
+
+func ruleMessage(tag, param string) string {
+	msg, ok := ruleMessages[tag]
+	if !ok {
+		if param != "" {
+			return fmt.Sprintf("failed the %s=%s rule", tag, param)
+		}
+		return fmt.Sprintf("failed the %s rule", tag)
+	}
+	if strings.Contains(msg, "%s") {
+		param = strings.ReplaceAll(param, " ", ", ")
+		return fmt.Sprintf(msg, param)
+	}
+	return msg
+}
@@ -0,0 +1,172 @@
+// Package validation binds request bodies and query strings with gin's
+// validator plus the bank's own rules (currency, amount, iban,
+// idempotency_key), and reports every failing field in one 400 response:
+//
+//	{"error": {"code": "VALIDATION_ERROR", "message": "Request validation failed",
+//	  "details": {"fields": [{"field": "postings[0].amount", "rule": "amount",
+//	  "message": "must be a positive decimal amount, e.g. 12.50"}]}}}
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	apperrors "github.com/femi-lawal/new_bank/backend/shared-lib/pkg/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is one field that failed validation. Field is the JSON (or
+// query) name, with the path to it for nested fields, e.g. postings[0].amount.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Details is the details object of a validation error response
+type Details struct {
+	Fields []FieldError `json:"fields"`
+}
+
+var (
+	registerOnce sync.Once
+	registerErr  error
+)
+
+// Register adds the custom rules to gin's validator and makes field errors
+// use JSON names. BindJSON and BindQuery call it; services may call it at
+// startup to fail fast.
+func Register() error {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			registerErr = errors.New("gin's validator is not go-playground/validator")
+			return
+		}
+		v.RegisterTagNameFunc(fieldName)
+		registerErr = registerRules(v)
+	})
+	return registerErr
+}
+
+// fieldName names struct fields by their json tag, or form tag for query
+// parameters, so errors use the names clients send
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		if name := strings.Split(f.Tag.Get(key), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// BindJSON decodes and validates the request body into obj. On failure it
+// responds 400 VALIDATION_ERROR listing the failing fields and returns false.
+func BindJSON(c *gin.Context, obj any) bool {
+	return bind(c, obj, binding.JSON)
+}
+
+// BindQuery is BindJSON for query parameters, named by form tags
+func BindQuery(c *gin.Context, obj any) bool {
+	return bind(c, obj, binding.Query)
+}
+
+func bind(c *gin.Context, obj any, b binding.Binding) bool {
+	if err := Register(); err != nil {
+		apperrors.RespondWithError(c, apperrors.ErrInternal.WithMessage(err.Error()))
+		return false
+	}
+	if err := c.ShouldBindWith(obj, b); err != nil {
+		RespondWithError(c, err)
+		return false
+	}
+	return true
+}
+
+// RespondWithError responds 400 VALIDATION_ERROR for an error from binding
+// or validating a request
+func RespondWithError(c *gin.Context, err error) {
+	RespondWithFields(c, FieldErrors(err)...)
+}
+
+// RespondWithFields responds 400 VALIDATION_ERROR for fields checked by hand,
+// such as headers
+func RespondWithFields(c *gin.Context, fields ...FieldError) {
+	apperrors.RespondWithError(c, apperrors.ErrValidation.WithDetails(Details{Fields: fields}))
+}
+
+// Invalid describes field failing rule, with the rule's usual message
+func Invalid(field, rule string) FieldError {
+	return FieldError{Field: field, Rule: rule, Message: ruleMessage(rule, "")}
+}
+
+// FieldErrors lists the fields an error from binding or validating a request
+// is about. Errors that are not about one field, such as malformed JSON, are
+// reported against the field "body".
+func FieldErrors(err error) []FieldError {
+	var invalid validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &invalid):
+		fields := make([]FieldError, len(invalid))
+		for i, fe := range invalid {
+			fields[i] = FieldError{Field: fieldPath(fe), Rule: fe.Tag(), Message: fieldMessage(fe)}
+		}
+		return fields
+	case errors.As(err, &typeErr):
+		return []FieldError{{Field: typeErr.Field, Rule: "type", Message: "must be " + jsonType(typeErr.Type)}}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return []FieldError{{Field: "body", Rule: "json", Message: "must be a valid JSON object"}}
+	default:
+		return []FieldError{{Field: "body", Rule: "invalid", Message: err.Error()}}
+	}
+}
+
+// fieldPath drops the request struct's name from the field's namespace
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	msg := ruleMessage(fe.Tag(), fe.Param())
+	switch fe.Tag() {
+	case "len", "min", "max":
+		switch fe.Kind() {
+		case reflect.String:
+			msg += " characters"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			msg += " items"
+		}
+	}
+	return msg
+}
+
+// jsonType names a Go type the way a JSON client would think of it
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
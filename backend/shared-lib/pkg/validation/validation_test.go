@@ -0,0 +1,99 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRules(t *testing.T) {
+	assert.True(t, Currency("GBP"))
+	assert.False(t, Currency("gbp"))
+	assert.False(t, Currency("POUND"))
+
+	assert.True(t, Amount("12.50"))
+	assert.True(t, Amount("1000"))
+	for _, s := range []string{"0", "0.00", "-5", "+5", "1e3", "12.", ".5", "1,000", ""} {
+		assert.False(t, Amount(s), s)
+	}
+
+	assert.True(t, IBAN("GB82WEST12345698765432"))
+	assert.True(t, IBAN("gb82 west 1234 5698 7654 32"), "as customers type it")
+	assert.False(t, IBAN("GB82WEST12345698765433"), "wrong check digits")
+	assert.False(t, IBAN("GB82"))
+
+	assert.True(t, IdempotencyKey("550e8400-e29b-41d4-a716-446655440000"))
+	assert.True(t, IdempotencyKey("onboard:2026-10.batch_1"))
+	assert.False(t, IdempotencyKey("short"))
+	assert.False(t, IdempotencyKey("has spaces in it"))
+	assert.False(t, IdempotencyKey(strings.Repeat("k", 129)))
+}
+
+type testPosting struct {
+	AccountID string `json:"account_id" binding:"required,uuid"`
+	Amount    string `json:"amount" binding:"required,amount"`
+}
+
+type testTransfer struct {
+	Currency  string        `json:"currency" binding:"required,currency"`
+	IBAN      string        `json:"iban" binding:"omitempty,iban"`
+	Reference string        `json:"reference" binding:"omitempty,idempotency_key"`
+	Note      string        `json:"note" binding:"max=5"`
+	Postings  []testPosting `json:"postings" binding:"required,min=1,dive"`
+}
+
+func postTransfer(t *testing.T, body string) (*httptest.ResponseRecorder, testTransfer) {
+	gin.SetMode(gin.TestMode)
+	var req testTransfer
+	r := gin.New()
+	r.POST("/transfers", func(c *gin.Context) {
+		if !BindJSON(c, &req) {
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	w := httptest.NewRecorder()
+	httpReq, _ := http.NewRequest(http.MethodPost, "/transfers", strings.NewReader(body))
+	r.ServeHTTP(w, httpReq)
+	return w, req
+}
+
+func responseFields(t *testing.T, w *httptest.ResponseRecorder) []FieldError {
+	var resp struct {
+		Code    string  `json:"code"`
+		Details Details `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "VALIDATION_ERROR", resp.Code)
+	return resp.Details.Fields
+}
+
+func TestBindJSON(t *testing.T) {
+	w, req := postTransfer(t, `{"currency":"GBP","iban":"GB82 WEST 1234 5698 7654 32","postings":[{"account_id":"550e8400-e29b-41d4-a716-446655440000","amount":"10.00"}]}`)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "10.00", req.Postings[0].Amount)
+
+	// Every failing field is listed, by its JSON path
+	w, _ = postTransfer(t, `{"currency":"pounds","iban":"GB00","reference":"x","note":"too long","postings":[{"account_id":"nope","amount":"-1"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.ElementsMatch(t, []FieldError{
+		{Field: "currency", Rule: "currency", Message: "must be a 3-letter ISO 4217 currency code"},
+		{Field: "iban", Rule: "iban", Message: "must be a valid IBAN"},
+		{Field: "reference", Rule: "idempotency_key", Message: "must be 8 to 128 letters, digits or _.:-"},
+		{Field: "note", Rule: "max", Message: "must be at most 5 characters"},
+		{Field: "postings[0].account_id", Rule: "uuid", Message: "must be a UUID"},
+		{Field: "postings[0].amount", Rule: "amount", Message: "must be a positive decimal amount, e.g. 12.50"},
+	}, responseFields(t, w))
+
+	w, _ = postTransfer(t, `{"currency":"GBP","postings":"all of them"}`)
+	assert.Equal(t, []FieldError{{Field: "postings", Rule: "type", Message: "must be an array"}}, responseFields(t, w))
+
+	w, _ = postTransfer(t, `{"currency":`)
+	assert.Equal(t, []FieldError{{Field: "body", Rule: "json", Message: "must be a valid JSON object"}}, responseFields(t, w))
+}